@@ -0,0 +1,462 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.27.1
+// 	protoc        v3.19.1
+// source: api/proto/apiserver/v1/watch.proto
+
+package v1
+
+import (
+	context "context"
+	v1 "github.com/marmotedu/api/proto/apiserver/v1"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// EventType defines the type of a watch event.
+type EventType int32
+
+const (
+	// SYNC carries an object of the current state at the beginning of a stream.
+	EventType_SYNC EventType = 0
+	// SYNCED marks the end of the current state, the objects which are not synced are deleted.
+	EventType_SYNCED EventType = 1
+	// PUT carries an added or modified object.
+	EventType_PUT EventType = 2
+	// DELETE carries a deleted object.
+	EventType_DELETE EventType = 3
+	// PING is sent periodically to check the liveness of the stream.
+	EventType_PING EventType = 4
+)
+
+// Enum value maps for EventType.
+var (
+	EventType_name = map[int32]string{
+		0: "SYNC",
+		1: "SYNCED",
+		2: "PUT",
+		3: "DELETE",
+		4: "PING",
+	}
+	EventType_value = map[string]int32{
+		"SYNC":   0,
+		"SYNCED": 1,
+		"PUT":    2,
+		"DELETE": 3,
+		"PING":   4,
+	}
+)
+
+func (x EventType) Enum() *EventType {
+	p := new(EventType)
+	*p = x
+	return p
+}
+
+func (x EventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_proto_apiserver_v1_watch_proto_enumTypes[0].Descriptor()
+}
+
+func (EventType) Type() protoreflect.EnumType {
+	return &file_api_proto_apiserver_v1_watch_proto_enumTypes[0]
+}
+
+func (x EventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EventType.Descriptor instead.
+func (EventType) EnumDescriptor() ([]byte, []int) {
+	return file_api_proto_apiserver_v1_watch_proto_rawDescGZIP(), []int{0}
+}
+
+// WatchRequest defines Watch request struct.
+type WatchRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// epoch and resource_version are the watermark of the last received event. The stream is
+	// resumed after the watermark if possible, otherwise it starts with the current state.
+	Epoch           int64 `protobuf:"varint,1,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	ResourceVersion int64 `protobuf:"varint,2,opt,name=resource_version,json=resourceVersion,proto3" json:"resource_version,omitempty"`
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_proto_apiserver_v1_watch_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_apiserver_v1_watch_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_apiserver_v1_watch_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *WatchRequest) GetEpoch() int64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *WatchRequest) GetResourceVersion() int64 {
+	if x != nil {
+		return x.ResourceVersion
+	}
+	return 0
+}
+
+// WatchEvent defines a change of a secret or a policy.
+type WatchEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type            EventType      `protobuf:"varint,1,opt,name=type,proto3,enum=proto.EventType" json:"type,omitempty"`
+	Epoch           int64          `protobuf:"varint,2,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	ResourceVersion int64          `protobuf:"varint,3,opt,name=resource_version,json=resourceVersion,proto3" json:"resource_version,omitempty"`
+	Secret          *v1.SecretInfo `protobuf:"bytes,4,opt,name=secret,proto3" json:"secret,omitempty"`
+	Policy          *v1.PolicyInfo `protobuf:"bytes,5,opt,name=policy,proto3" json:"policy,omitempty"`
+}
+
+func (x *WatchEvent) Reset() {
+	*x = WatchEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_proto_apiserver_v1_watch_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WatchEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEvent) ProtoMessage() {}
+
+func (x *WatchEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_apiserver_v1_watch_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEvent.ProtoReflect.Descriptor instead.
+func (*WatchEvent) Descriptor() ([]byte, []int) {
+	return file_api_proto_apiserver_v1_watch_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *WatchEvent) GetType() EventType {
+	if x != nil {
+		return x.Type
+	}
+	return EventType_SYNC
+}
+
+func (x *WatchEvent) GetEpoch() int64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *WatchEvent) GetResourceVersion() int64 {
+	if x != nil {
+		return x.ResourceVersion
+	}
+	return 0
+}
+
+func (x *WatchEvent) GetSecret() *v1.SecretInfo {
+	if x != nil {
+		return x.Secret
+	}
+	return nil
+}
+
+func (x *WatchEvent) GetPolicy() *v1.PolicyInfo {
+	if x != nil {
+		return x.Policy
+	}
+	return nil
+}
+
+var File_api_proto_apiserver_v1_watch_proto protoreflect.FileDescriptor
+
+var file_api_proto_apiserver_v1_watch_proto_rawDesc = []byte{
+	0x0a, 0x22, 0x61, 0x70, 0x69, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x61, 0x70, 0x69, 0x73,
+	0x65, 0x72, 0x76, 0x65, 0x72, 0x2f, 0x76, 0x31, 0x2f, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x12, 0x05, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x2f, 0x61, 0x70, 0x69, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2f, 0x76, 0x31, 0x2f,
+	0x63, 0x61, 0x63, 0x68, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x4f, 0x0a, 0x0c, 0x57,
+	0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x65,
+	0x70, 0x6f, 0x63, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x65, 0x70, 0x6f, 0x63,
+	0x68, 0x12, 0x29, 0x0a, 0x10, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x5f, 0x76, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0f, 0x72, 0x65, 0x73,
+	0x6f, 0x75, 0x72, 0x63, 0x65, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0xc9, 0x01, 0x0a,
+	0x0a, 0x57, 0x61, 0x74, 0x63, 0x68, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x24, 0x0a, 0x04, 0x74,
+	0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x10, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x2e, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70,
+	0x65, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x12, 0x29, 0x0a, 0x10, 0x72, 0x65, 0x73, 0x6f, 0x75,
+	0x72, 0x63, 0x65, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x0f, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x56, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x12, 0x29, 0x0a, 0x06, 0x73, 0x65, 0x63, 0x72, 0x65, 0x74, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x11, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x53, 0x65, 0x63, 0x72, 0x65,
+	0x74, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x06, 0x73, 0x65, 0x63, 0x72, 0x65, 0x74, 0x12, 0x29, 0x0a,
+	0x06, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x49, 0x6e, 0x66, 0x6f,
+	0x52, 0x06, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x2a, 0x40, 0x0a, 0x09, 0x45, 0x76, 0x65, 0x6e,
+	0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x08, 0x0a, 0x04, 0x53, 0x59, 0x4e, 0x43, 0x10, 0x00, 0x12,
+	0x0a, 0x0a, 0x06, 0x53, 0x59, 0x4e, 0x43, 0x45, 0x44, 0x10, 0x01, 0x12, 0x07, 0x0a, 0x03, 0x50,
+	0x55, 0x54, 0x10, 0x02, 0x12, 0x0a, 0x0a, 0x06, 0x44, 0x45, 0x4c, 0x45, 0x54, 0x45, 0x10, 0x03,
+	0x12, 0x08, 0x0a, 0x04, 0x50, 0x49, 0x4e, 0x47, 0x10, 0x04, 0x32, 0x41, 0x0a, 0x0a, 0x43, 0x61,
+	0x63, 0x68, 0x65, 0x57, 0x61, 0x74, 0x63, 0x68, 0x12, 0x33, 0x0a, 0x05, 0x57, 0x61, 0x74, 0x63,
+	0x68, 0x12, 0x13, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x11, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x57,
+	0x61, 0x74, 0x63, 0x68, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x22, 0x00, 0x30, 0x01, 0x42, 0x31, 0x5a,
+	0x2f, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6d, 0x61, 0x72, 0x6d,
+	0x6f, 0x74, 0x65, 0x64, 0x75, 0x2f, 0x69, 0x61, 0x6d, 0x2f, 0x61, 0x70, 0x69, 0x2f, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x2f, 0x61, 0x70, 0x69, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2f, 0x76, 0x31,
+	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_api_proto_apiserver_v1_watch_proto_rawDescOnce sync.Once
+	file_api_proto_apiserver_v1_watch_proto_rawDescData = file_api_proto_apiserver_v1_watch_proto_rawDesc
+)
+
+func file_api_proto_apiserver_v1_watch_proto_rawDescGZIP() []byte {
+	file_api_proto_apiserver_v1_watch_proto_rawDescOnce.Do(func() {
+		file_api_proto_apiserver_v1_watch_proto_rawDescData = protoimpl.X.CompressGZIP(file_api_proto_apiserver_v1_watch_proto_rawDescData)
+	})
+	return file_api_proto_apiserver_v1_watch_proto_rawDescData
+}
+
+var file_api_proto_apiserver_v1_watch_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_api_proto_apiserver_v1_watch_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_api_proto_apiserver_v1_watch_proto_goTypes = []interface{}{
+	(EventType)(0),        // 0: proto.EventType
+	(*WatchRequest)(nil),  // 1: proto.WatchRequest
+	(*WatchEvent)(nil),    // 2: proto.WatchEvent
+	(*v1.SecretInfo)(nil), // 3: proto.SecretInfo
+	(*v1.PolicyInfo)(nil), // 4: proto.PolicyInfo
+}
+var file_api_proto_apiserver_v1_watch_proto_depIdxs = []int32{
+	0, // 0: proto.WatchEvent.type:type_name -> proto.EventType
+	3, // 1: proto.WatchEvent.secret:type_name -> proto.SecretInfo
+	4, // 2: proto.WatchEvent.policy:type_name -> proto.PolicyInfo
+	1, // 3: proto.CacheWatch.Watch:input_type -> proto.WatchRequest
+	2, // 4: proto.CacheWatch.Watch:output_type -> proto.WatchEvent
+	4, // [4:5] is the sub-list for method output_type
+	3, // [3:4] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_api_proto_apiserver_v1_watch_proto_init() }
+func file_api_proto_apiserver_v1_watch_proto_init() {
+	if File_api_proto_apiserver_v1_watch_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_api_proto_apiserver_v1_watch_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WatchRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_proto_apiserver_v1_watch_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WatchEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_api_proto_apiserver_v1_watch_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_proto_apiserver_v1_watch_proto_goTypes,
+		DependencyIndexes: file_api_proto_apiserver_v1_watch_proto_depIdxs,
+		EnumInfos:         file_api_proto_apiserver_v1_watch_proto_enumTypes,
+		MessageInfos:      file_api_proto_apiserver_v1_watch_proto_msgTypes,
+	}.Build()
+	File_api_proto_apiserver_v1_watch_proto = out.File
+	file_api_proto_apiserver_v1_watch_proto_rawDesc = nil
+	file_api_proto_apiserver_v1_watch_proto_goTypes = nil
+	file_api_proto_apiserver_v1_watch_proto_depIdxs = nil
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// CacheWatchClient is the client API for CacheWatch service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type CacheWatchClient interface {
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (CacheWatch_WatchClient, error)
+}
+
+type cacheWatchClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCacheWatchClient(cc grpc.ClientConnInterface) CacheWatchClient {
+	return &cacheWatchClient{cc}
+}
+
+func (c *cacheWatchClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (CacheWatch_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_CacheWatch_serviceDesc.Streams[0], "/proto.CacheWatch/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &cacheWatchWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CacheWatch_WatchClient interface {
+	Recv() (*WatchEvent, error)
+	grpc.ClientStream
+}
+
+type cacheWatchWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *cacheWatchWatchClient) Recv() (*WatchEvent, error) {
+	m := new(WatchEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CacheWatchServer is the server API for CacheWatch service.
+type CacheWatchServer interface {
+	Watch(*WatchRequest, CacheWatch_WatchServer) error
+}
+
+// UnimplementedCacheWatchServer can be embedded to have forward compatible implementations.
+type UnimplementedCacheWatchServer struct {
+}
+
+func (*UnimplementedCacheWatchServer) Watch(*WatchRequest, CacheWatch_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+
+func RegisterCacheWatchServer(s *grpc.Server, srv CacheWatchServer) {
+	s.RegisterService(&_CacheWatch_serviceDesc, srv)
+}
+
+func _CacheWatch_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CacheWatchServer).Watch(m, &cacheWatchWatchServer{stream})
+}
+
+type CacheWatch_WatchServer interface {
+	Send(*WatchEvent) error
+	grpc.ServerStream
+}
+
+type cacheWatchWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *cacheWatchWatchServer) Send(m *WatchEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _CacheWatch_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.CacheWatch",
+	HandlerType: (*CacheWatchServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _CacheWatch_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/proto/apiserver/v1/watch.proto",
+}
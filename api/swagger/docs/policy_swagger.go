@@ -86,6 +86,11 @@ import (
 type listPolicyRequestParamsWrapper struct {
 	// in:query
 	metav1.ListOptions
+
+	// Continue token of the previous page returned by the list, the next page is then listed after it
+	// rather than from the offset.
+	// in:query
+	Continue string `json:"continue"`
 }
 
 // List policies response.
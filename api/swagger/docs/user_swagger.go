@@ -98,6 +98,19 @@ import (
 type listUserRequestParamsWrapper struct {
 	// in:query
 	metav1.ListOptions
+
+	// Only list the users not logged in since the unix time, to find the inactive accounts.
+	// in:query
+	LastLoginBefore int64 `json:"last_login_before"`
+
+	// List the soft deleted users too, along with their policies.
+	// in:query
+	WithDeleted bool `json:"with_deleted"`
+
+	// Continue token of the previous page returned by the list, the next page is then listed after it
+	// rather than from the offset.
+	// in:query
+	Continue string `json:"continue"`
 }
 
 // List users response.
@@ -0,0 +1,210 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The tables of iam.sql for postgres, used when --datastore.engine is postgres, e.g.:
+--   createdb iam && psql -d iam -f configs/iam.postgres.sql
+-- The columns keep the camelCase names of the mysql tables, so they are quoted.
+
+--
+-- Table structure for table "user"
+--
+
+DROP TABLE IF EXISTS "user" CASCADE;
+CREATE TABLE "user" (
+  "id" bigserial PRIMARY KEY,
+  "instanceID" varchar(32) DEFAULT NULL,
+  "name" varchar(45) NOT NULL,
+  "status" integer DEFAULT 1,
+  "nickname" varchar(30) NOT NULL,
+  "password" varchar(255) NOT NULL,
+  "email" varchar(256) NOT NULL,
+  "phone" varchar(20) DEFAULT NULL,
+  "isAdmin" smallint NOT NULL DEFAULT 0,
+  "extendShadow" text DEFAULT NULL,
+  "loginedAt" timestamp NULL DEFAULT NULL,
+  "createdAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "updatedAt" timestamp NOT NULL DEFAULT current_timestamp,
+  CONSTRAINT "user_idx_name" UNIQUE ("name"),
+  CONSTRAINT "user_instanceID_UNIQUE" UNIQUE ("instanceID")
+);
+CREATE INDEX "user_idx_loginedAt" ON "user" ("loginedAt");
+COMMENT ON COLUMN "user"."status" IS '1:可用，0:不可用';
+COMMENT ON COLUMN "user"."isAdmin" IS '1: administrator, 0: non-administrator';
+COMMENT ON COLUMN "user"."loginedAt" IS 'last login time';
+
+--
+-- Table structure for table "policy"
+--
+
+DROP TABLE IF EXISTS "policy";
+CREATE TABLE "policy" (
+  "id" bigserial PRIMARY KEY,
+  "instanceID" varchar(32) DEFAULT NULL,
+  "name" varchar(45) NOT NULL,
+  "username" varchar(255) NOT NULL,
+  "policyShadow" text DEFAULT NULL,
+  "extendShadow" text DEFAULT NULL,
+  "createdAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "updatedAt" timestamp NOT NULL DEFAULT current_timestamp,
+  CONSTRAINT "policy_instanceID_UNIQUE" UNIQUE ("instanceID"),
+  CONSTRAINT "fk_policy_user" FOREIGN KEY ("username") REFERENCES "user" ("name") ON DELETE NO ACTION ON UPDATE NO ACTION
+);
+CREATE INDEX "fk_policy_user_idx" ON "policy" ("username");
+CREATE UNIQUE INDEX "policy_idx_username_name" ON "policy" ("username", "name");
+
+--
+-- Table structure for table "policy_audit"
+--
+
+DROP TABLE IF EXISTS "policy_audit";
+CREATE TABLE "policy_audit" (
+  "id" bigint PRIMARY KEY,
+  "instanceID" varchar(32) DEFAULT NULL,
+  "name" varchar(45) NOT NULL,
+  "username" varchar(255) NOT NULL,
+  "policyShadow" text DEFAULT NULL,
+  "extendShadow" text DEFAULT NULL,
+  "createdAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "updatedAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "deletedAt" timestamp NOT NULL DEFAULT current_timestamp
+);
+CREATE INDEX "fk_policy_audit_user_idx" ON "policy_audit" ("username");
+
+--
+-- Table structure for table "policy_groups"
+--
+
+DROP TABLE IF EXISTS "policy_groups";
+CREATE TABLE "policy_groups" (
+  "id" bigserial PRIMARY KEY,
+  "instanceID" varchar(32) DEFAULT NULL,
+  "name" varchar(45) NOT NULL,
+  "username" varchar(255) NOT NULL,
+  "description" varchar(255) DEFAULT NULL,
+  "policyIDs" text DEFAULT NULL,
+  "applied" boolean NOT NULL DEFAULT false,
+  "extendShadow" text DEFAULT NULL,
+  "createdAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "updatedAt" timestamp NOT NULL DEFAULT current_timestamp,
+  CONSTRAINT "policy_groups_instanceID_UNIQUE" UNIQUE ("instanceID"),
+  CONSTRAINT "policy_groups_idx_username_name" UNIQUE ("username", "name")
+);
+COMMENT ON COLUMN "policy_groups"."policyIDs" IS 'json array of the member policy names';
+COMMENT ON COLUMN "policy_groups"."applied" IS 'true: the member policies are enforced';
+
+--
+-- Table structure for table "secret"
+--
+
+DROP TABLE IF EXISTS "secret";
+CREATE TABLE "secret" (
+  "id" bigserial PRIMARY KEY,
+  "instanceID" varchar(32) DEFAULT NULL,
+  "name" varchar(45) NOT NULL,
+  "username" varchar(255) NOT NULL,
+  "secretID" varchar(36) NOT NULL,
+  "secretKey" varchar(255) NOT NULL,
+  "expires" bigint NOT NULL DEFAULT 1534308590,
+  "description" varchar(255) NOT NULL,
+  "extendShadow" text DEFAULT NULL,
+  "createdAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "updatedAt" timestamp NOT NULL DEFAULT current_timestamp,
+  CONSTRAINT "secret_instanceID_UNIQUE" UNIQUE ("instanceID"),
+  CONSTRAINT "fk_secret_user" FOREIGN KEY ("username") REFERENCES "user" ("name") ON DELETE NO ACTION ON UPDATE NO ACTION
+);
+CREATE INDEX "fk_secret_user_idx" ON "secret" ("username");
+
+--
+-- Table structure for table "secret_shares"
+--
+
+DROP TABLE IF EXISTS "secret_shares";
+CREATE TABLE "secret_shares" (
+  "id" bigserial PRIMARY KEY,
+  "instanceID" varchar(32) DEFAULT NULL,
+  "name" varchar(45) NOT NULL,
+  "username" varchar(255) NOT NULL,
+  "secretID" varchar(36) NOT NULL,
+  "targetUsername" varchar(255) NOT NULL,
+  "expiresAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "extendShadow" text DEFAULT NULL,
+  "createdAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "updatedAt" timestamp NOT NULL DEFAULT current_timestamp,
+  CONSTRAINT "secret_shares_instanceID_UNIQUE" UNIQUE ("instanceID"),
+  CONSTRAINT "secret_shares_idx_secretID_targetUsername" UNIQUE ("secretID", "targetUsername")
+);
+CREATE INDEX "secret_shares_idx_targetUsername" ON "secret_shares" ("targetUsername");
+COMMENT ON COLUMN "secret_shares"."name" IS 'name of the shared secret';
+COMMENT ON COLUMN "secret_shares"."username" IS 'owner of the shared secret';
+
+--
+-- Table structure for table "service_accounts"
+--
+
+DROP TABLE IF EXISTS "service_accounts";
+CREATE TABLE "service_accounts" (
+  "id" bigserial PRIMARY KEY,
+  "instanceID" varchar(32) DEFAULT NULL,
+  "name" varchar(45) NOT NULL,
+  "scope" varchar(255) NOT NULL,
+  "description" varchar(255) DEFAULT NULL,
+  "apiKeyHash" varchar(64) NOT NULL,
+  "extendShadow" text DEFAULT NULL,
+  "createdAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "updatedAt" timestamp NOT NULL DEFAULT current_timestamp,
+  CONSTRAINT "service_accounts_instanceID_UNIQUE" UNIQUE ("instanceID"),
+  CONSTRAINT "service_accounts_idx_name" UNIQUE ("name")
+);
+COMMENT ON COLUMN "service_accounts"."name" IS 'service id, the sub claim of the service tokens';
+COMMENT ON COLUMN "service_accounts"."scope" IS 'scope claim of the service tokens';
+COMMENT ON COLUMN "service_accounts"."apiKeyHash" IS 'hex encoded sha256 of the api key';
+
+--
+-- Table structure for table "user_sessions"
+--
+
+DROP TABLE IF EXISTS "user_sessions";
+CREATE TABLE "user_sessions" (
+  "id" bigserial PRIMARY KEY,
+  "jti" varchar(36) NOT NULL,
+  "username" varchar(255) NOT NULL,
+  "issued_at" timestamp NOT NULL DEFAULT current_timestamp,
+  "expires_at" timestamp NOT NULL DEFAULT current_timestamp,
+  "user_agent" varchar(255) NOT NULL DEFAULT '',
+  "client_ip" varchar(45) NOT NULL DEFAULT '',
+  CONSTRAINT "user_sessions_jti_UNIQUE" UNIQUE ("jti")
+);
+CREATE INDEX "user_sessions_idx_username_expires_at" ON "user_sessions" ("username", "expires_at");
+COMMENT ON COLUMN "user_sessions"."jti" IS 'id of the jwt token';
+
+--
+-- Triggers, the ones of the mysql tables
+--
+
+-- the deleted policies are kept in policy_audit.
+CREATE OR REPLACE FUNCTION "policy_audit_insert"() RETURNS trigger AS $$
+BEGIN
+  INSERT INTO "policy_audit" VALUES (old."id", old."instanceID", old."name", old."username",
+    old."policyShadow", old."extendShadow", old."createdAt", old."updatedAt", now());
+  RETURN old;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "policy_BEFORE_DELETE" BEFORE DELETE ON "policy"
+  FOR EACH ROW EXECUTE PROCEDURE "policy_audit_insert"();
+
+-- the resources of a user are deleted with it, before the foreign keys are checked.
+CREATE OR REPLACE FUNCTION "user_delete_resources"() RETURNS trigger AS $$
+BEGIN
+  DELETE FROM "secret" WHERE "username" = old."name";
+  DELETE FROM "policy" WHERE "username" = old."name";
+  DELETE FROM "secret_shares" WHERE "username" = old."name" OR "targetUsername" = old."name";
+  DELETE FROM "policy_groups" WHERE "username" = old."name";
+  DELETE FROM "user_sessions" WHERE "username" = old."name";
+  RETURN old;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "user_BEFORE_DELETE" BEFORE DELETE ON "user"
+  FOR EACH ROW EXECUTE PROCEDURE "user_delete_resources"();
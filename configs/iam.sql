@@ -39,6 +39,7 @@ CREATE TABLE `policy` (
   `updatedAt` timestamp NOT NULL DEFAULT current_timestamp() ON UPDATE current_timestamp(),
   PRIMARY KEY (`id`),
   UNIQUE KEY `instanceID_UNIQUE` (`instanceID`),
+  UNIQUE KEY `idx_username_name` (`username`,`name`),
   KEY `fk_policy_user_idx` (`username`),
   CONSTRAINT `fk_policy_user` FOREIGN KEY (`username`) REFERENCES `user` (`name`) ON DELETE NO ACTION ON UPDATE NO ACTION
 ) ENGINE=InnoDB AUTO_INCREMENT=47 DEFAULT CHARSET=utf8;
@@ -102,6 +103,39 @@ LOCK TABLES `policy_audit` WRITE;
 /*!40000 ALTER TABLE `policy_audit` ENABLE KEYS */;
 UNLOCK TABLES;
 
+--
+-- Table structure for table `policy_groups`
+--
+
+DROP TABLE IF EXISTS `policy_groups`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `policy_groups` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL,
+  `name` varchar(45) NOT NULL,
+  `username` varchar(255) NOT NULL,
+  `description` varchar(255) DEFAULT NULL,
+  `policyIDs` longtext DEFAULT NULL COMMENT 'json array of the member policy names',
+  `applied` tinyint(1) unsigned NOT NULL DEFAULT 0 COMMENT '1: the member policies are enforced',
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp(),
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp() ON UPDATE current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `instanceID_UNIQUE` (`instanceID`),
+  UNIQUE KEY `idx_username_name` (`username`,`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `policy_groups`
+--
+
+LOCK TABLES `policy_groups` WRITE;
+/*!40000 ALTER TABLE `policy_groups` DISABLE KEYS */;
+/*!40000 ALTER TABLE `policy_groups` ENABLE KEYS */;
+UNLOCK TABLES;
+
 --
 -- Table structure for table `secret`
 --
@@ -137,6 +171,72 @@ LOCK TABLES `secret` WRITE;
 /*!40000 ALTER TABLE `secret` ENABLE KEYS */;
 UNLOCK TABLES;
 
+--
+-- Table structure for table `secret_shares`
+--
+
+DROP TABLE IF EXISTS `secret_shares`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `secret_shares` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL,
+  `name` varchar(45) NOT NULL COMMENT 'name of the shared secret',
+  `username` varchar(255) NOT NULL COMMENT 'owner of the shared secret',
+  `secretID` varchar(36) NOT NULL,
+  `targetUsername` varchar(255) NOT NULL,
+  `expiresAt` timestamp NOT NULL DEFAULT current_timestamp(),
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp(),
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp() ON UPDATE current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `instanceID_UNIQUE` (`instanceID`),
+  UNIQUE KEY `idx_secretID_targetUsername` (`secretID`,`targetUsername`),
+  KEY `idx_targetUsername` (`targetUsername`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `secret_shares`
+--
+
+LOCK TABLES `secret_shares` WRITE;
+/*!40000 ALTER TABLE `secret_shares` DISABLE KEYS */;
+/*!40000 ALTER TABLE `secret_shares` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `service_accounts`
+--
+
+DROP TABLE IF EXISTS `service_accounts`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `service_accounts` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL,
+  `name` varchar(45) NOT NULL COMMENT 'service id, the sub claim of the service tokens',
+  `scope` varchar(255) NOT NULL COMMENT 'scope claim of the service tokens',
+  `description` varchar(255) DEFAULT NULL,
+  `apiKeyHash` varchar(64) NOT NULL COMMENT 'hex encoded sha256 of the api key',
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp(),
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp() ON UPDATE current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `instanceID_UNIQUE` (`instanceID`),
+  UNIQUE KEY `idx_name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `service_accounts`
+--
+
+LOCK TABLES `service_accounts` WRITE;
+/*!40000 ALTER TABLE `service_accounts` DISABLE KEYS */;
+/*!40000 ALTER TABLE `service_accounts` ENABLE KEYS */;
+UNLOCK TABLES;
+
 --
 -- Table structure for table `user`
 --
@@ -160,7 +260,8 @@ CREATE TABLE `user` (
   `updatedAt` timestamp NOT NULL DEFAULT current_timestamp() ON UPDATE current_timestamp(),
   PRIMARY KEY (`id`),
   UNIQUE KEY `idx_name` (`name`),
-  UNIQUE KEY `instanceID_UNIQUE` (`instanceID`)
+  UNIQUE KEY `instanceID_UNIQUE` (`instanceID`),
+  KEY `idx_loginedAt` (`loginedAt`)
 ) ENGINE=InnoDB AUTO_INCREMENT=38 DEFAULT CHARSET=utf8;
 /*!40101 SET character_set_client = @saved_cs_client */;
 
@@ -186,6 +287,9 @@ DELIMITER ;;
 BEGIN
 	delete from secret where username = old.name;
     delete from policy where username = old.name;
+    delete from secret_shares where username = old.name or targetUsername = old.name;
+    delete from policy_groups where username = old.name;
+    delete from user_sessions where username = old.name;
 END */;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
@@ -193,6 +297,36 @@ DELIMITER ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
 
+--
+-- Table structure for table `user_sessions`
+--
+
+DROP TABLE IF EXISTS `user_sessions`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `user_sessions` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `jti` varchar(36) NOT NULL COMMENT 'id of the jwt token',
+  `username` varchar(255) NOT NULL,
+  `issued_at` timestamp NOT NULL DEFAULT current_timestamp(),
+  `expires_at` timestamp NOT NULL DEFAULT current_timestamp(),
+  `user_agent` varchar(255) NOT NULL DEFAULT '',
+  `client_ip` varchar(45) NOT NULL DEFAULT '',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `jti_UNIQUE` (`jti`),
+  KEY `idx_username_expires_at` (`username`,`expires_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `user_sessions`
+--
+
+LOCK TABLES `user_sessions` WRITE;
+/*!40000 ALTER TABLE `user_sessions` DISABLE KEYS */;
+/*!40000 ALTER TABLE `user_sessions` ENABLE KEYS */;
+UNLOCK TABLES;
+
 --
 -- Dumping events for database 'iam'
 --
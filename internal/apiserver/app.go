@@ -16,6 +16,11 @@ const commandDesc = `The IAM API server validates and configures data
 for the api objects which include users, policies, secrets, and
 others. The API Server services REST operations to do the api objects management.
 
+The options are read from the flags, the environment variables and the configuration file, in this
+order of precedence. The environment variable of an option is named after it with the IAM_APISERVER_
+prefix, in upper case with the dots and dashes replaced by underscores, e.g. IAM_APISERVER_MYSQL_PASSWORD
+for --mysql.password.
+
 Find more iam-apiserver information at:
     https://github.com/marmotedu/iam/blob/master/docs/guide/en-US/cmd/iam-apiserver.md`
 
@@ -28,6 +33,7 @@ func NewApp(basename string) *app.App {
 		app.WithDescription(commandDesc),
 		app.WithDefaultValidArgs(),
 		app.WithRunFunc(run(opts)),
+		app.WithCommands(newMigrateCommand()),
 	)
 
 	return application
@@ -14,13 +14,18 @@ import (
 	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
 	v1 "github.com/marmotedu/api/apiserver/v1"
+	"github.com/marmotedu/component-base/pkg/json"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 	"github.com/spf13/viper"
 
 	"github.com/marmotedu/iam/internal/apiserver/store"
+	iamjwt "github.com/marmotedu/iam/internal/pkg/jwt"
 	"github.com/marmotedu/iam/internal/pkg/middleware"
 	"github.com/marmotedu/iam/internal/pkg/middleware/auth"
+	"github.com/marmotedu/iam/internal/pkg/notice"
 	"github.com/marmotedu/iam/pkg/log"
+	"github.com/marmotedu/iam/pkg/shutdown"
+	"github.com/marmotedu/iam/pkg/storage"
 )
 
 const (
@@ -56,7 +61,60 @@ func newBasicAuth() middleware.AuthStrategy {
 	})
 }
 
+// keyRotator signs and verifies the jwt tokens of iam-apiserver, it is set by initKeyRotator.
+var keyRotator *iamjwt.KeyRotator
+
+// initKeyRotator creates the key rotator and rotates its keys until shutdown, each rotation is
+// published so that the other components can refresh their caches.
+func (s *apiServer) initKeyRotator() {
+	rotator, err := iamjwt.NewKeyRotator(
+		viper.GetString("jwt.algorithm"),
+		[]byte(viper.GetString("jwt.key")),
+		viper.GetDuration("jwt.rotation-interval"),
+		viper.GetInt("jwt.retained-keys"),
+	)
+	if err != nil {
+		log.Fatalf("Failed to create jwt key rotator: %s", err.Error())
+	}
+
+	rotator.OnRotate(func(key *iamjwt.Key) {
+		redisStore := &storage.RedisCluster{}
+		message, _ := json.Marshal(notice.Notification{Command: notice.KeyRotated, Payload: key.ID})
+
+		if err := redisStore.Publish(context.Background(), notice.RedisPubSubChannel, string(message)); err != nil {
+			log.Errorw("publish redis message failed", "error", err.Error())
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.gs.AddShutdownCallbackCtx("key rotator", shutdown.ShutdownFuncCtx(func(context.Context, string) error {
+		cancel()
+
+		return nil
+	}), shutdown.PriorityStopJobs)
+
+	go rotator.Start(ctx)
+
+	keyRotator = rotator
+}
+
+// newServiceTokenSigner creates the signer of the service tokens, nil if no signing key is configured.
+func newServiceTokenSigner() *iamjwt.ServiceTokenSigner {
+	file := viper.GetString("service-token.private-key-file")
+	if file == "" {
+		return nil
+	}
+
+	key, err := iamjwt.LoadRSAPrivateKey(file)
+	if err != nil {
+		log.Fatalf("Failed to load service token signing key: %s", err.Error())
+	}
+
+	return iamjwt.NewServiceTokenSigner(key, APIServerIssuer, viper.GetDuration("service-token.timeout"))
+}
+
 func newJWTAuth() middleware.AuthStrategy {
+	// the tokens are signed and verified by keyRotator, Key only satisfies the checks of gin-jwt.
 	ginjwt, _ := jwt.New(&jwt.GinJWTMiddleware{
 		Realm:            viper.GetString("jwt.Realm"),
 		SigningAlgorithm: "HS256",
@@ -89,7 +147,7 @@ func newJWTAuth() middleware.AuthStrategy {
 		// TODO: HTTPStatusMessageFunc:
 	})
 
-	return auth.NewJWTStrategy(*ginjwt)
+	return auth.NewRotatingJWTStrategy(*ginjwt, keyRotator).WithSessions(newSessionTracker())
 }
 
 func newAutoAuth() middleware.AuthStrategy {
@@ -9,19 +9,25 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/AlekSi/pointer"
 	pb "github.com/marmotedu/api/proto/apiserver/v1"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 	"github.com/marmotedu/errors"
 
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
 	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/middleware/auth"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
 // Cache defines a cache service used to list all secrets and policies.
 type Cache struct {
 	store store.Factory
+	// hub is set by StartWatch, the Watch rpc is not served before.
+	hub *watchHub
 }
 
 var (
@@ -33,7 +39,7 @@ var (
 func GetCacheInsOr(store store.Factory) (*Cache, error) {
 	if store != nil {
 		once.Do(func() {
-			cacheServer = &Cache{store}
+			cacheServer = &Cache{store: store}
 		})
 	}
 
@@ -44,7 +50,7 @@ func GetCacheInsOr(store store.Factory) (*Cache, error) {
 	return cacheServer, nil
 }
 
-// ListSecrets returns all secrets.
+// ListSecrets returns all secrets, they may be read from a read replica.
 func (c *Cache) ListSecrets(ctx context.Context, r *pb.ListSecretsRequest) (*pb.ListSecretsResponse, error) {
 	log.L(ctx).Info("list secrets function called.")
 	opts := metav1.ListOptions{
@@ -52,13 +58,63 @@ func (c *Cache) ListSecrets(ctx context.Context, r *pb.ListSecretsRequest) (*pb.
 		Limit:  r.Limit,
 	}
 
+	items, totalCount, err := c.listSecrets(store.WithReplicaReads(ctx), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ListSecretsResponse{
+		TotalCount: totalCount,
+		Items:      items,
+	}, nil
+}
+
+// listSecrets returns the secrets, including the shared ones, and their total count. The store excludes
+// the secrets of the soft deleted users, and the shares with them.
+func (c *Cache) listSecrets(ctx context.Context, opts metav1.ListOptions) ([]*pb.SecretInfo, int64, error) {
 	secrets, err := c.store.Secrets().List(ctx, "", opts)
 	if err != nil {
-		return nil, errors.WithCode(code.ErrDatabase, err.Error())
+		return nil, 0, errors.WithCode(code.ErrDatabase, err.Error())
+	}
+
+	shares, err := c.store.SecretShares().List(ctx, "", metav1.ListOptions{
+		Offset: pointer.ToInt64(0),
+		Limit:  pointer.ToInt64(-1),
+	})
+	if err != nil {
+		return nil, 0, errors.WithCode(code.ErrDatabase, err.Error())
+	}
+
+	sharedWith := make(map[string][]*modelv1.SecretShare)
+	now := time.Now()
+
+	for _, share := range shares.Items {
+		if !share.Expired(now) {
+			sharedWith[share.SecretID] = append(sharedWith[share.SecretID], share)
+		}
 	}
 
 	items := make([]*pb.SecretInfo, 0)
 	for _, secret := range secrets.Items {
+		// shared secrets are returned with the recipient as the owner, so the authz server can
+		// authenticate the recipient with the owner's secret key.
+		for _, share := range sharedWith[secret.SecretID] {
+			expires := share.ExpiresAt.Unix()
+			if secret.Expires > 0 && secret.Expires < expires {
+				expires = secret.Expires
+			}
+
+			items = append(items, &pb.SecretInfo{
+				SecretId:    auth.SharedSecretID(secret.SecretID, share.TargetUsername),
+				Username:    share.TargetUsername,
+				SecretKey:   secret.SecretKey,
+				Expires:     expires,
+				Description: secret.Description,
+				CreatedAt:   share.CreatedAt.Format("2006-01-02 15:04:05"),
+				UpdatedAt:   share.UpdatedAt.Format("2006-01-02 15:04:05"),
+			})
+		}
+
 		items = append(items, &pb.SecretInfo{
 			SecretId:    secret.SecretID,
 			Username:    secret.Username,
@@ -70,13 +126,10 @@ func (c *Cache) ListSecrets(ctx context.Context, r *pb.ListSecretsRequest) (*pb.
 		})
 	}
 
-	return &pb.ListSecretsResponse{
-		TotalCount: secrets.TotalCount,
-		Items:      items,
-	}, nil
+	return items, secrets.TotalCount + int64(len(items)-len(secrets.Items)), nil
 }
 
-// ListPolicies returns all policies.
+// ListPolicies returns all policies, they may be read from a read replica.
 func (c *Cache) ListPolicies(ctx context.Context, r *pb.ListPoliciesRequest) (*pb.ListPoliciesResponse, error) {
 	log.L(ctx).Info("list policies function called.")
 	opts := metav1.ListOptions{
@@ -84,13 +137,51 @@ func (c *Cache) ListPolicies(ctx context.Context, r *pb.ListPoliciesRequest) (*p
 		Limit:  r.Limit,
 	}
 
+	items, totalCount, err := c.listPolicies(store.WithReplicaReads(ctx), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ListPoliciesResponse{
+		TotalCount: totalCount,
+		Items:      items,
+	}, nil
+}
+
+// listPolicies returns the enforced policies and their total count. The member policies of
+// the policy groups which are not applied yet are not enforced, the store excludes the policies of the
+// soft deleted users.
+func (c *Cache) listPolicies(ctx context.Context, opts metav1.ListOptions) ([]*pb.PolicyInfo, int64, error) {
 	policies, err := c.store.Policies().List(ctx, "", opts)
 	if err != nil {
-		return nil, errors.WithCode(code.ErrDatabase, err.Error())
+		return nil, 0, errors.WithCode(code.ErrDatabase, err.Error())
+	}
+
+	groups, err := c.store.PolicyGroups().List(ctx, "", metav1.ListOptions{
+		Offset: pointer.ToInt64(0),
+		Limit:  pointer.ToInt64(-1),
+	})
+	if err != nil {
+		return nil, 0, errors.WithCode(code.ErrDatabase, err.Error())
+	}
+
+	pending := make(map[string]bool)
+	for _, group := range groups.Items {
+		if group.Applied {
+			continue
+		}
+
+		for _, id := range group.PolicyIDs {
+			pending[group.Username+"/"+id] = true
+		}
 	}
 
 	items := make([]*pb.PolicyInfo, 0)
 	for _, pol := range policies.Items {
+		if pending[pol.Username+"/"+pol.Name] {
+			continue
+		}
+
 		items = append(items, &pb.PolicyInfo{
 			Name:         pol.Name,
 			Username:     pol.Username,
@@ -99,8 +190,5 @@ func (c *Cache) ListPolicies(ctx context.Context, r *pb.ListPoliciesRequest) (*p
 		})
 	}
 
-	return &pb.ListPoliciesResponse{
-		TotalCount: policies.TotalCount,
-		Items:      items,
-	}, nil
+	return items, policies.TotalCount + int64(len(items)-len(policies.Items)), nil
 }
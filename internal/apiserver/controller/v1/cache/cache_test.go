@@ -13,8 +13,12 @@ import (
 	"github.com/golang/mock/gomock"
 	v1 "github.com/marmotedu/api/apiserver/v1"
 	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/marmotedu/component-base/pkg/json"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
 
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
 	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/apiserver/store/fake"
 )
@@ -92,6 +96,10 @@ func TestCache_ListSecrets(t *testing.T) {
 
 	mockSecretStore.EXPECT().List(gomock.Any(), gomock.Eq(""), gomock.Any()).Return(secrets, nil)
 
+	mockSecretShareStore := store.NewMockSecretShareStore(ctrl)
+	mockFactory.EXPECT().SecretShares().Return(mockSecretShareStore)
+	mockSecretShareStore.EXPECT().List(gomock.Any(), gomock.Eq(""), gomock.Any()).Return(&modelv1.SecretShareList{}, nil)
+
 	type fields struct {
 		store store.Factory
 	}
@@ -166,6 +174,10 @@ func TestCache_ListPolicies(t *testing.T) {
 	}
 	mockPolicyStore.EXPECT().List(gomock.Any(), gomock.Eq(""), gomock.Any()).Return(policies, nil)
 
+	mockPolicyGroupStore := store.NewMockPolicyGroupStore(ctrl)
+	mockFactory.EXPECT().PolicyGroups().Return(mockPolicyGroupStore)
+	mockPolicyGroupStore.EXPECT().List(gomock.Any(), gomock.Eq(""), gomock.Any()).Return(&modelv1.PolicyGroupList{}, nil)
+
 	type fields struct {
 		store store.Factory
 	}
@@ -209,3 +221,113 @@ func TestCache_ListPolicies(t *testing.T) {
 		})
 	}
 }
+
+// isAllowed evaluates the request against the policies listed by the cache, as the authz
+// server does.
+func isAllowed(t *testing.T, items []*pb.PolicyInfo, r *ladon.Request) bool {
+	t.Helper()
+
+	manager := memory.NewMemoryManager()
+	for _, item := range items {
+		var policy ladon.DefaultPolicy
+		if err := json.Unmarshal([]byte(item.PolicyShadow), &policy); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+
+		if err := manager.Create(&policy); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	warden := &ladon.Ladon{Manager: manager, AuditLogger: &ladon.AuditLoggerNoOp{}}
+
+	return warden.IsAllowed(r) == nil
+}
+
+func TestCache_ListPolicies_PolicyGroups(t *testing.T) {
+	allowRead := &v1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: "articles-read"},
+		Username:   "colin",
+		Policy: v1.AuthzPolicy{DefaultPolicy: ladon.DefaultPolicy{
+			ID:        "articles-read",
+			Subjects:  []string{"users:colin"},
+			Resources: []string{"resources:articles:<.*>"},
+			Actions:   []string{"read"},
+			Effect:    ladon.AllowAccess,
+		}},
+	}
+	allowRead.PolicyShadow = allowRead.Policy.String()
+
+	group := func(applied bool, policyIDs ...string) []*modelv1.PolicyGroup {
+		return []*modelv1.PolicyGroup{{
+			ObjectMeta: metav1.ObjectMeta{Name: "articles"},
+			Username:   "colin",
+			PolicyIDs:  policyIDs,
+			Applied:    applied,
+		}}
+	}
+
+	request := &ladon.Request{
+		Subject:  "users:colin",
+		Resource: "resources:articles:ladon-introduction",
+		Action:   "read",
+	}
+
+	tests := []struct {
+		name        string
+		policies    []*v1.Policy
+		groups      []*modelv1.PolicyGroup
+		wantAllowed bool
+	}{
+		{name: "policy in no group", policies: []*v1.Policy{allowRead}, wantAllowed: true},
+		{name: "group created", policies: []*v1.Policy{allowRead}, groups: group(false, "articles-read")},
+		{name: "group applied", policies: []*v1.Policy{allowRead}, groups: group(true, "articles-read"), wantAllowed: true},
+		{
+			name:        "policy removed from group",
+			policies:    []*v1.Policy{allowRead},
+			groups:      group(false, "articles-write"),
+			wantAllowed: true,
+		},
+		{
+			name:        "policy of another user in group",
+			policies:    []*v1.Policy{allowRead},
+			groups:      []*modelv1.PolicyGroup{{Username: "admin", PolicyIDs: []string{"articles-read"}}},
+			wantAllowed: true,
+		},
+		// deleting a group deletes its member policies.
+		{name: "group deleted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockFactory := store.NewMockFactory(ctrl)
+			mockPolicyStore := store.NewMockPolicyStore(ctrl)
+			mockPolicyGroupStore := store.NewMockPolicyGroupStore(ctrl)
+			mockFactory.EXPECT().Policies().Return(mockPolicyStore)
+			mockFactory.EXPECT().PolicyGroups().Return(mockPolicyGroupStore)
+			mockPolicyStore.EXPECT().List(gomock.Any(), gomock.Eq(""), gomock.Any()).Return(&v1.PolicyList{
+				ListMeta: metav1.ListMeta{TotalCount: int64(len(tt.policies))},
+				Items:    tt.policies,
+			}, nil)
+			mockPolicyGroupStore.EXPECT().List(gomock.Any(), gomock.Eq(""), gomock.Any()).Return(&modelv1.PolicyGroupList{
+				Items: tt.groups,
+			}, nil)
+
+			c := &Cache{store: mockFactory}
+			got, err := c.ListPolicies(context.TODO(), &pb.ListPoliciesRequest{})
+			if err != nil {
+				t.Fatalf("Cache.ListPolicies() error = %v", err)
+			}
+
+			if got.TotalCount != int64(len(got.Items)) {
+				t.Errorf("Cache.ListPolicies() totalCount = %d, want %d", got.TotalCount, len(got.Items))
+			}
+
+			if allowed := isAllowed(t, got.Items, request); allowed != tt.wantAllowed {
+				t.Errorf("IsAllowed() = %t, want %t", allowed, tt.wantAllowed)
+			}
+		})
+	}
+}
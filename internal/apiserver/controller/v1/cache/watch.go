@@ -0,0 +1,331 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/AlekSi/pointer"
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	watchpb "github.com/marmotedu/iam/api/proto/apiserver/v1"
+	"github.com/marmotedu/iam/internal/pkg/notice"
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+const (
+	// watchBacklog is the number of events kept to resume the streams.
+	watchBacklog = 1024
+	// watchBuffer is the number of events queued for a watcher before it is dropped.
+	watchBuffer = 256
+	// watchPingInterval is the interval of the pings sent on every stream.
+	watchPingInterval = 10 * time.Second
+	// watchResyncInterval is the interval of the full refreshes, in case of a lost notification.
+	watchResyncInterval = time.Minute
+)
+
+// watchHub keeps the last known secrets and policies, and broadcasts their changes to
+// the watchers.
+type watchHub struct {
+	lock sync.Mutex
+	// epoch identifies the versions of this hub, the watermarks of another epoch can not
+	// be resumed.
+	epoch    int64
+	version  int64
+	synced   bool
+	secrets  map[string]*pb.SecretInfo
+	policies map[string]*pb.PolicyInfo
+	// backlog holds the last events, from version-len(backlog)+1 to version.
+	backlog  []*watchpb.WatchEvent
+	watchers map[chan *watchpb.WatchEvent]struct{}
+}
+
+func newWatchHub() *watchHub {
+	return &watchHub{
+		epoch:    time.Now().UnixNano(),
+		secrets:  make(map[string]*pb.SecretInfo),
+		policies: make(map[string]*pb.PolicyInfo),
+		watchers: make(map[chan *watchpb.WatchEvent]struct{}),
+	}
+}
+
+func policyKey(policy *pb.PolicyInfo) string {
+	return policy.Username + "/" + policy.Name
+}
+
+// update replaces the known state, a PUT or DELETE event is broadcast for every
+// difference. The first update only records the state.
+func (h *watchHub) update(secrets []*pb.SecretInfo, policies []*pb.PolicyInfo) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	newSecrets := make(map[string]*pb.SecretInfo, len(secrets))
+	for _, secret := range secrets {
+		newSecrets[secret.SecretId] = secret
+	}
+
+	newPolicies := make(map[string]*pb.PolicyInfo, len(policies))
+	for _, policy := range policies {
+		newPolicies[policyKey(policy)] = policy
+	}
+
+	if h.synced {
+		for id, secret := range newSecrets {
+			if old, ok := h.secrets[id]; !ok || !proto.Equal(old, secret) {
+				h.emit(watchpb.EventType_PUT, secret, nil)
+			}
+		}
+
+		for id, secret := range h.secrets {
+			if _, ok := newSecrets[id]; !ok {
+				h.emit(watchpb.EventType_DELETE, secret, nil)
+			}
+		}
+
+		for key, policy := range newPolicies {
+			if old, ok := h.policies[key]; !ok || !proto.Equal(old, policy) {
+				h.emit(watchpb.EventType_PUT, nil, policy)
+			}
+		}
+
+		for key, policy := range h.policies {
+			if _, ok := newPolicies[key]; !ok {
+				h.emit(watchpb.EventType_DELETE, nil, policy)
+			}
+		}
+	}
+
+	h.secrets, h.policies, h.synced = newSecrets, newPolicies, true
+}
+
+// emit records a new event and sends it to the watchers. A watcher which can not keep up
+// is dropped, it resumes from its watermark on the next stream.
+func (h *watchHub) emit(typ watchpb.EventType, secret *pb.SecretInfo, policy *pb.PolicyInfo) {
+	h.version++
+	event := &watchpb.WatchEvent{
+		Type:            typ,
+		Epoch:           h.epoch,
+		ResourceVersion: h.version,
+		Secret:          secret,
+		Policy:          policy,
+	}
+
+	h.backlog = append(h.backlog, event)
+	if len(h.backlog) > watchBacklog {
+		h.backlog = h.backlog[len(h.backlog)-watchBacklog:]
+	}
+
+	for ch := range h.watchers {
+		select {
+		case ch <- event:
+		default:
+			delete(h.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe registers a new watcher. It returns the events to send before the ones
+// received from the channel: the events after the watermark if the backlog still holds
+// them, the current state followed by a SYNCED event otherwise. It returns false if the
+// state has not been loaded yet.
+func (h *watchHub) subscribe(epoch, version int64) ([]*watchpb.WatchEvent, chan *watchpb.WatchEvent, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if !h.synced {
+		return nil, nil, false
+	}
+
+	ch := make(chan *watchpb.WatchEvent, watchBuffer)
+	h.watchers[ch] = struct{}{}
+
+	if epoch == h.epoch && version <= h.version && version >= h.version-int64(len(h.backlog)) {
+		missed := h.backlog[len(h.backlog)-int(h.version-version):]
+
+		return append([]*watchpb.WatchEvent(nil), missed...), ch, true
+	}
+
+	events := make([]*watchpb.WatchEvent, 0, len(h.secrets)+len(h.policies)+1)
+	for _, secret := range h.secrets {
+		events = append(events, &watchpb.WatchEvent{
+			Type:            watchpb.EventType_SYNC,
+			Epoch:           h.epoch,
+			ResourceVersion: h.version,
+			Secret:          secret,
+		})
+	}
+
+	for _, policy := range h.policies {
+		events = append(events, &watchpb.WatchEvent{
+			Type:            watchpb.EventType_SYNC,
+			Epoch:           h.epoch,
+			ResourceVersion: h.version,
+			Policy:          policy,
+		})
+	}
+
+	events = append(events, &watchpb.WatchEvent{
+		Type:            watchpb.EventType_SYNCED,
+		Epoch:           h.epoch,
+		ResourceVersion: h.version,
+	})
+
+	return events, ch, true
+}
+
+// unsubscribe removes a watcher, it is a no-op if the watcher has been dropped.
+func (h *watchHub) unsubscribe(ch chan *watchpb.WatchEvent) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if _, ok := h.watchers[ch]; ok {
+		delete(h.watchers, ch)
+		close(ch)
+	}
+}
+
+// stop drops all the watchers and rejects the new ones.
+func (h *watchHub) stop() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.synced = false
+
+	for ch := range h.watchers {
+		delete(h.watchers, ch)
+		close(ch)
+	}
+}
+
+func (h *watchHub) ping() *watchpb.WatchEvent {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return &watchpb.WatchEvent{
+		Type:            watchpb.EventType_PING,
+		Epoch:           h.epoch,
+		ResourceVersion: h.version,
+	}
+}
+
+// StartWatch starts to track the changes of secrets and policies for the Watch rpc. The
+// state is refreshed on every change notification published on redis, and periodically.
+// The watchers are dropped once ctx is done.
+func (c *Cache) StartWatch(ctx context.Context) {
+	c.hub = newWatchHub()
+	trigger := make(chan struct{}, 1)
+
+	notify := func() {
+		select {
+		case trigger <- struct{}{}:
+		default:
+		}
+	}
+
+	// the changes notified while the subscription was lost are caught up by a refresh.
+	go (&storage.RedisCluster{}).StartPubSubLoop(ctx, notice.RedisPubSubChannel, func(interface{}) {
+		notify()
+	}, storage.PubSubReconnect{OnResubscribe: notify})
+
+	go func() {
+		ticker := time.NewTicker(watchResyncInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := c.refreshWatch(ctx); err != nil {
+				log.Errorf("failed to refresh watched secrets and policies: %s", err.Error())
+			}
+
+			select {
+			case <-ctx.Done():
+				c.hub.stop()
+
+				return
+			case <-trigger:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// refreshWatch reads the secrets and the policies from the primary database, not from a read replica: it
+// is triggered by their writes.
+func (c *Cache) refreshWatch(ctx context.Context) error {
+	opts := metav1.ListOptions{
+		Offset: pointer.ToInt64(0),
+		Limit:  pointer.ToInt64(-1),
+	}
+
+	secrets, _, err := c.listSecrets(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	policies, _, err := c.listPolicies(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	c.hub.update(secrets, policies)
+
+	return nil
+}
+
+// Watch streams the changes of secrets and policies. The stream starts with the events
+// after the given watermark, or with the current state if it can not be resumed, and a
+// PING is sent periodically to check its liveness.
+func (c *Cache) Watch(r *watchpb.WatchRequest, stream watchpb.CacheWatch_WatchServer) error {
+	if c.hub == nil {
+		return status.Error(codes.Unimplemented, "watch is not started")
+	}
+
+	log.L(stream.Context()).Infow("watch function called.", "epoch", r.Epoch, "resourceVersion", r.ResourceVersion)
+
+	events, ch, ok := c.hub.subscribe(r.Epoch, r.ResourceVersion)
+	if !ok {
+		return status.Error(codes.Unavailable, "secrets and policies are not loaded yet")
+	}
+	defer c.hub.unsubscribe(ch)
+
+	for _, event := range events {
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+
+	if err := stream.Send(c.hub.ping()); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(watchPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case event, ok := <-ch:
+			if !ok {
+				return status.Error(codes.Unavailable, "watcher is dropped")
+			}
+
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if err := stream.Send(c.hub.ping()); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"testing"
+
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+
+	watchpb "github.com/marmotedu/iam/api/proto/apiserver/v1"
+)
+
+func Test_watchHub(t *testing.T) {
+	h := newWatchHub()
+
+	if _, _, ok := h.subscribe(0, 0); ok {
+		t.Fatal("subscribe() succeeded before the state is loaded")
+	}
+
+	secrets := []*pb.SecretInfo{{SecretId: "id1", SecretKey: "key1"}, {SecretId: "id2", SecretKey: "key2"}}
+	policies := []*pb.PolicyInfo{{Username: "colin", Name: "p1", PolicyShadow: "{}"}}
+	h.update(secrets, policies)
+
+	events, ch, ok := h.subscribe(0, 0)
+	if !ok {
+		t.Fatal("subscribe() failed after the state is loaded")
+	}
+
+	// 2 secrets, 1 policy and the SYNCED event.
+	if len(events) != 4 || events[3].Type != watchpb.EventType_SYNCED || events[3].ResourceVersion != 0 {
+		t.Fatalf("subscribe() = %v, want the current state", events)
+	}
+
+	// id1 is modified, id2 is deleted, p2 is added.
+	h.update(
+		[]*pb.SecretInfo{{SecretId: "id1", SecretKey: "key3"}},
+		[]*pb.PolicyInfo{policies[0], {Username: "colin", Name: "p2", PolicyShadow: "{}"}},
+	)
+
+	want := []struct {
+		typ  watchpb.EventType
+		name string
+	}{
+		{watchpb.EventType_PUT, "id1"},
+		{watchpb.EventType_DELETE, "id2"},
+		{watchpb.EventType_PUT, "p2"},
+	}
+	for i, w := range want {
+		event := <-ch
+		name := event.GetSecret().GetSecretId() + event.GetPolicy().GetName()
+
+		if event.Type != w.typ || name != w.name || event.ResourceVersion != int64(i+1) {
+			t.Errorf("event %d = %s %s (%d), want %s %s", i, event.Type, name, event.ResourceVersion, w.typ, w.name)
+		}
+	}
+
+	h.unsubscribe(ch)
+
+	tests := []struct {
+		name    string
+		epoch   int64
+		version int64
+		want    int
+		synced  bool
+	}{
+		{name: "resume", epoch: h.epoch, version: 1, want: 2},
+		{name: "up to date", epoch: h.epoch, version: 3, want: 0},
+		{name: "other epoch", epoch: h.epoch - 1, version: 1, want: 4, synced: true},
+		{name: "future version", epoch: h.epoch, version: 4, want: 4, synced: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, ch, _ := h.subscribe(tt.epoch, tt.version)
+			defer h.unsubscribe(ch)
+
+			if len(events) != tt.want {
+				t.Fatalf("subscribe() returned %d events, want %d", len(events), tt.want)
+			}
+
+			if synced := tt.want > 0 && events[len(events)-1].Type == watchpb.EventType_SYNCED; synced != tt.synced {
+				t.Errorf("subscribe() synced = %t, want %t", synced, tt.synced)
+			}
+		})
+	}
+}
+
+func Test_watchHub_slowWatcher(t *testing.T) {
+	h := newWatchHub()
+	h.update(nil, nil)
+
+	_, ch, _ := h.subscribe(0, 0)
+
+	secrets := make([]*pb.SecretInfo, 0, watchBuffer+1)
+	for i := 0; i <= watchBuffer; i++ {
+		secrets = append(secrets, &pb.SecretInfo{SecretId: string(rune('a' + i))})
+		h.update(secrets, nil)
+	}
+
+	received := 0
+	for range ch {
+		received++
+	}
+
+	if received != watchBuffer {
+		t.Errorf("slow watcher received %d events, want %d before being dropped", received, watchBuffer)
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package jwks
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/component-base/pkg/core"
+
+	"github.com/marmotedu/iam/internal/pkg/jwt"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// KeyController create a key handler used to handle request for the jwt signing keys.
+type KeyController struct {
+	keys *jwt.KeyRotator
+}
+
+// NewKeyController creates a key handler.
+func NewKeyController(keys *jwt.KeyRotator) *KeyController {
+	return &KeyController{keys: keys}
+}
+
+// JWKS return the public keys accepted to verify jwt tokens in the JSON Web Key Set format.
+func (k *KeyController) JWKS(c *gin.Context) {
+	core.WriteResponse(c, nil, k.keys.JWKS())
+}
+
+// List return the id and the expiry of the keys accepted to verify jwt tokens, the current key first.
+func (k *KeyController) List(c *gin.Context) {
+	log.L(c).Info("list jwt keys function called.")
+
+	core.WriteResponse(c, nil, k.keys.Keys())
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	redis "github.com/go-redis/redis/v7"
+	"github.com/marmotedu/component-base/pkg/json"
+
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+	"github.com/marmotedu/iam/internal/pkg/middleware/cache"
+	"github.com/marmotedu/iam/internal/pkg/notice"
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+// cacheKeyPrefix prefixes the keys of the cached responses of Get.
+const cacheKeyPrefix = "policies/"
+
+var (
+	// generation counts the notifications of changes of all the policies.
+	generation uint64
+	// policyGenerations counts the notifications of changes of each policy since the last change of
+	// all of them, by their notification payload.
+	policyGenerations sync.Map
+)
+
+// CacheKey returns the key of the cached response of Get, the policies are cached per user. The key
+// changes with each notified change of the policy, so that a response computed while the policy
+// changed is not returned once the change is notified, even if it is cached after its invalidation.
+func CacheKey(c *gin.Context) string {
+	payload := notice.PolicyPayload(c.GetString(middleware.UsernameKey), c.Param("name"))
+
+	var policyGeneration uint64
+	if gen, ok := policyGenerations.Load(payload); ok {
+		policyGeneration = atomic.LoadUint64(gen.(*uint64))
+	}
+
+	return fmt.Sprintf("%s%s@%d.%d", cacheKeyPrefix, payload, atomic.LoadUint64(&generation), policyGeneration)
+}
+
+// invalidateAll changes the keys of all the policies and deletes their cached responses.
+func invalidateAll(ctx context.Context, store cache.CacheStore) {
+	atomic.AddUint64(&generation, 1)
+	policyGenerations.Range(func(key, _ interface{}) bool {
+		policyGenerations.Delete(key)
+
+		return true
+	})
+
+	store.DeletePrefix(ctx, cacheKeyPrefix)
+}
+
+// invalidatePolicy changes the key of the policy of the notification payload and deletes its cached
+// responses.
+func invalidatePolicy(ctx context.Context, store cache.CacheStore, payload string) {
+	gen, _ := policyGenerations.LoadOrStore(payload, new(uint64))
+	atomic.AddUint64(gen.(*uint64), 1)
+
+	store.DeletePrefix(ctx, cacheKeyPrefix+payload+"@")
+}
+
+// InvalidateCache deletes the cached responses of Get on every policy change notification published
+// on redis, until ctx is done. All of them are deleted when the notification does not name a single
+// policy, and once the lost subscription is established again.
+func InvalidateCache(ctx context.Context, store cache.CacheStore) {
+	go (&storage.RedisCluster{}).StartPubSubLoop(ctx, notice.RedisPubSubChannel, func(v interface{}) {
+		invalidate(ctx, store, v)
+	}, storage.PubSubReconnect{OnResubscribe: func() {
+		invalidateAll(ctx, store)
+	}})
+}
+
+func invalidate(ctx context.Context, store cache.CacheStore, v interface{}) {
+	message, ok := v.(*redis.Message)
+	if !ok {
+		return
+	}
+
+	var notif notice.Notification
+	if err := json.Unmarshal([]byte(message.Payload), &notif); err != nil || notif.Command != notice.PolicyChanged {
+		return
+	}
+
+	if notif.Payload == "" {
+		invalidateAll(ctx, store)
+
+		return
+	}
+
+	invalidatePolicy(ctx, store, notif.Payload)
+}
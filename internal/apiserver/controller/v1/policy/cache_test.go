@@ -0,0 +1,100 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	redis "github.com/go-redis/redis/v7"
+	"github.com/marmotedu/component-base/pkg/json"
+
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+	"github.com/marmotedu/iam/internal/pkg/middleware/cache"
+	"github.com/marmotedu/iam/internal/pkg/notice"
+)
+
+func cacheKey(username, name string) string {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set(middleware.UsernameKey, username)
+	c.Params = gin.Params{{Key: "name", Value: name}}
+
+	return CacheKey(c)
+}
+
+func Test_invalidate(t *testing.T) {
+	policies := [][2]string{{"colin", "p1"}, {"colin", "p2"}, {"admin", "p1"}}
+
+	tests := []struct {
+		name    string
+		message interface{}
+		// want are the policies whose cached response is still returned.
+		want []string
+	}{
+		{
+			name:    "single policy",
+			message: notice.Notification{Command: notice.PolicyChanged, Payload: notice.PolicyPayload("colin", "p1")},
+			want:    []string{"colin/p2", "admin/p1"},
+		},
+		{
+			name:    "all policies",
+			message: notice.Notification{Command: notice.PolicyChanged},
+			want:    nil,
+		},
+		{
+			name:    "secret",
+			message: notice.Notification{Command: notice.SecretChanged, Payload: "colin/p1"},
+			want:    []string{"colin/p1", "colin/p2", "admin/p1"},
+		},
+		{
+			name:    "malformed",
+			message: "{",
+			want:    []string{"colin/p1", "colin/p2", "admin/p1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := cache.NewLRUStore(10)
+
+			for _, p := range policies {
+				store.Set(ctx, cacheKey(p[0], p[1]), &cache.Entry{}, time.Minute)
+			}
+
+			payload, ok := tt.message.(string)
+			if !ok {
+				data, _ := json.Marshal(tt.message)
+				payload = string(data)
+			}
+
+			// the responses computed before the change and cached after its notification are not returned.
+			keys := make([]string, 0, len(policies))
+			for _, p := range policies {
+				keys = append(keys, cacheKey(p[0], p[1]))
+			}
+
+			invalidate(ctx, store, &redis.Message{Channel: notice.RedisPubSubChannel, Payload: payload})
+
+			for _, key := range keys {
+				store.Set(ctx, key, &cache.Entry{}, time.Minute)
+			}
+
+			var got []string
+			for _, p := range policies {
+				if _, ok := store.Get(ctx, cacheKey(p[0], p[1])); ok {
+					got = append(got, notice.PolicyPayload(p[0], p[1]))
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cached %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -13,11 +13,12 @@ import (
 	"github.com/marmotedu/iam/pkg/log"
 )
 
-// Get return policy by the policy identifier.
+// Get return policy by the policy identifier, along with its resource version to echo by the updates. The
+// policy is read from the primary, as the resource version of a lagging read replica would be stale.
 func (p *PolicyController) Get(c *gin.Context) {
 	log.L(c).Info("get policy function called.")
 
-	pol, err := p.srv.Policies().Get(c, c.GetString(middleware.UsernameKey), c.Param("name"), metav1.GetOptions{})
+	pol, err := p.srv.Policies().GetVersioned(c, c.GetString(middleware.UsernameKey), c.Param("name"), metav1.GetOptions{})
 	if err != nil {
 		core.WriteResponse(c, err, nil)
 
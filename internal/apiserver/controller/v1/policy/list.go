@@ -6,16 +6,25 @@ package policy
 
 import (
 	"github.com/gin-gonic/gin"
+	v1 "github.com/marmotedu/api/apiserver/v1"
 	"github.com/marmotedu/component-base/pkg/core"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 	"github.com/marmotedu/errors"
 
+	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/pkg/code"
 	"github.com/marmotedu/iam/internal/pkg/middleware"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
-// List return all policies.
+// listQuery is the query of the policy list in addition to metav1.ListOptions.
+type listQuery struct {
+	// Continue is the continue token of the previous page, the policies are then listed after it rather than
+	// from the offset.
+	Continue string `form:"continue"`
+}
+
+// List return all policies, they may be read from a read replica.
 func (p *PolicyController) List(c *gin.Context) {
 	log.L(c).Info("list policy function called.")
 
@@ -26,12 +35,34 @@ func (p *PolicyController) List(c *gin.Context) {
 		return
 	}
 
-	policies, err := p.srv.Policies().List(c, c.GetString(middleware.UsernameKey), r)
+	var q listQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, err.Error()), nil)
+
+		return
+	}
+
+	ctx, err := store.WithContinue(store.WithReplicaReads(c), store.CursorPolicies, q.Continue)
 	if err != nil {
 		core.WriteResponse(c, err, nil)
 
 		return
 	}
 
-	core.WriteResponse(c, nil, policies)
+	policies, err := p.srv.Policies().List(ctx, c.GetString(middleware.UsernameKey), r)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	var lastID uint64
+	if len(policies.Items) > 0 {
+		lastID = policies.Items[len(policies.Items)-1].ID
+	}
+
+	core.WriteResponse(c, nil, struct {
+		*v1.PolicyList
+		Continue string `json:"continue,omitempty"`
+	}{policies, store.ContinueToken(store.CursorPolicies, r, len(policies.Items), lastID)})
 }
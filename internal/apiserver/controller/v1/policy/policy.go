@@ -7,6 +7,8 @@ package policy
 import (
 	srvv1 "github.com/marmotedu/iam/internal/apiserver/service/v1"
 	"github.com/marmotedu/iam/internal/apiserver/store"
+	// register the iam specific ladon conditions, so that policies using them can be decoded.
+	_ "github.com/marmotedu/iam/internal/pkg/conditions"
 )
 
 // PolicyController create a policy handler used to handle request for policy resource.
@@ -6,28 +6,35 @@ package policy
 
 import (
 	"github.com/gin-gonic/gin"
-	v1 "github.com/marmotedu/api/apiserver/v1"
 	"github.com/marmotedu/component-base/pkg/core"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 	"github.com/marmotedu/errors"
 
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
 	"github.com/marmotedu/iam/internal/pkg/code"
 	"github.com/marmotedu/iam/internal/pkg/middleware"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
-// Update updates policy by the policy identifier.
+// Update updates policy by the policy identifier. The request echoes the resource version of the policy
+// read, the update fails with a conflict if the policy changed since.
 func (p *PolicyController) Update(c *gin.Context) {
 	log.L(c).Info("update policy function called.")
 
-	var r v1.Policy
+	var r modelv1.VersionedPolicy
 	if err := c.ShouldBindJSON(&r); err != nil {
-		core.WriteResponse(c, errors.WithCode(code.ErrBind, err.Error()), nil)
+		core.WriteResponse(c, middleware.BindError(c, err), nil)
 
 		return
 	}
 
-	pol, err := p.srv.Policies().Get(c, c.GetString(middleware.UsernameKey), c.Param("name"), metav1.GetOptions{})
+	if r.ResourceVersion == 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "resourceVersion is required, get the policy first"), nil)
+
+		return
+	}
+
+	pol, err := p.srv.Policies().GetVersioned(c, c.GetString(middleware.UsernameKey), c.Param("name"), metav1.GetOptions{})
 	if err != nil {
 		core.WriteResponse(c, err, nil)
 
@@ -35,8 +42,9 @@ func (p *PolicyController) Update(c *gin.Context) {
 	}
 
 	// only update policy string
-	pol.Policy = r.Policy
+	pol.Policy.Policy = r.Policy.Policy
 	pol.Extend = r.Extend
+	pol.ResourceVersion = r.ResourceVersion
 
 	if errs := pol.Validate(); len(errs) != 0 {
 		core.WriteResponse(c, errors.WithCode(code.ErrValidation, errs.ToAggregate().Error()), nil)
@@ -44,7 +52,7 @@ func (p *PolicyController) Update(c *gin.Context) {
 		return
 	}
 
-	if err := p.srv.Policies().Update(c, pol, metav1.UpdateOptions{}); err != nil {
+	if err := p.srv.Policies().UpdateVersioned(c, pol, metav1.UpdateOptions{}); err != nil {
 		core.WriteResponse(c, err, nil)
 
 		return
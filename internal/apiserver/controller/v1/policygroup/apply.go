@@ -0,0 +1,35 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package policygroup
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/component-base/pkg/core"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// Apply activates all the member policies of the policy group at once.
+func (g *PolicyGroupController) Apply(c *gin.Context) {
+	log.L(c).Info("apply policy group function called.")
+
+	username := c.GetString(middleware.UsernameKey)
+	if err := g.srv.PolicyGroups().Apply(c, username, c.Param("name")); err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	group, err := g.srv.PolicyGroups().Get(c, username, c.Param("name"), metav1.GetOptions{})
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	core.WriteResponse(c, nil, group)
+}
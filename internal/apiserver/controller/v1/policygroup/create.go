@@ -0,0 +1,46 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package policygroup
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/component-base/pkg/core"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// Create creates a new policy group, its member policies are enforced once it is applied.
+func (g *PolicyGroupController) Create(c *gin.Context) {
+	log.L(c).Info("create policy group function called.")
+
+	var r v1.PolicyGroup
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, middleware.BindError(c, err), nil)
+
+		return
+	}
+
+	if errs := r.Validate(); len(errs) != 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, errs.ToAggregate().Error()), nil)
+
+		return
+	}
+
+	r.Username = c.GetString(middleware.UsernameKey)
+	r.Applied = false
+
+	if err := g.srv.PolicyGroups().Create(c, &r, metav1.CreateOptions{}); err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	core.WriteResponse(c, nil, r)
+}
@@ -0,0 +1,22 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package policygroup
+
+import (
+	srvv1 "github.com/marmotedu/iam/internal/apiserver/service/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
+)
+
+// PolicyGroupController create a policy group handler used to handle request for policy group resource.
+type PolicyGroupController struct {
+	srv srvv1.Service
+}
+
+// NewPolicyGroupController creates a policy group handler.
+func NewPolicyGroupController(store store.Factory) *PolicyGroupController {
+	return &PolicyGroupController{
+		srv: srvv1.NewService(store),
+	}
+}
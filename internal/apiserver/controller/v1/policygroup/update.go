@@ -0,0 +1,94 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package policygroup
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/component-base/pkg/core"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// Update updates the description of the policy group by the policy group identifier.
+func (g *PolicyGroupController) Update(c *gin.Context) {
+	log.L(c).Info("update policy group function called.")
+
+	var r v1.PolicyGroup
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, middleware.BindError(c, err), nil)
+
+		return
+	}
+
+	group, err := g.srv.PolicyGroups().Get(c, c.GetString(middleware.UsernameKey), c.Param("name"), metav1.GetOptions{})
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	// the member policies are updated by SetPolicies
+	group.Description = r.Description
+	group.Extend = r.Extend
+
+	if errs := group.Validate(); len(errs) != 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, errs.ToAggregate().Error()), nil)
+
+		return
+	}
+
+	if err := g.srv.PolicyGroups().Update(c, group, metav1.UpdateOptions{}); err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	core.WriteResponse(c, nil, group)
+}
+
+// SetPoliciesRequest defines the request body of SetPolicies.
+type SetPoliciesRequest struct {
+	PolicyIDs []string `json:"policyIDs"`
+}
+
+// SetPolicies replaces the member policies of the policy group.
+func (g *PolicyGroupController) SetPolicies(c *gin.Context) {
+	log.L(c).Info("set policy group policies function called.")
+
+	var r SetPoliciesRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, middleware.BindError(c, err), nil)
+
+		return
+	}
+
+	group, err := g.srv.PolicyGroups().Get(c, c.GetString(middleware.UsernameKey), c.Param("name"), metav1.GetOptions{})
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	group.PolicyIDs = r.PolicyIDs
+
+	if errs := group.Validate(); len(errs) != 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, errs.ToAggregate().Error()), nil)
+
+		return
+	}
+
+	if err := g.srv.PolicyGroups().Update(c, group, metav1.UpdateOptions{}); err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	core.WriteResponse(c, nil, group)
+}
@@ -27,7 +27,7 @@ func (s *SecretController) Create(c *gin.Context) {
 	var r v1.Secret
 
 	if err := c.ShouldBindJSON(&r); err != nil {
-		core.WriteResponse(c, errors.WithCode(code.ErrBind, err.Error()), nil)
+		core.WriteResponse(c, middleware.BindError(c, err), nil)
 
 		return
 	}
@@ -59,9 +59,11 @@ func (s *SecretController) Create(c *gin.Context) {
 	// must reassign username
 	r.Username = username
 
-	// generate secret id and secret key
+	// generate secret id, and secret key unless an existing one is imported
 	r.SecretID = idutil.NewSecretID()
-	r.SecretKey = idutil.NewSecretKey()
+	if r.SecretKey == "" {
+		r.SecretKey = idutil.NewSecretKey()
+	}
 
 	if err := s.srv.Secrets().Create(c, &r, metav1.CreateOptions{}); err != nil {
 		core.WriteResponse(c, err, nil)
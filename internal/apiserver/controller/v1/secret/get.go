@@ -13,11 +13,12 @@ import (
 	"github.com/marmotedu/iam/pkg/log"
 )
 
-// Get get an policy by the secret identifier.
+// Get get an policy by the secret identifier, along with its resource version to echo by the updates. The
+// secret is read from the primary, as the resource version of a lagging read replica would be stale.
 func (s *SecretController) Get(c *gin.Context) {
 	log.L(c).Info("get secret function called.")
 
-	secret, err := s.srv.Secrets().Get(c, c.GetString(middleware.UsernameKey), c.Param("name"), metav1.GetOptions{})
+	secret, err := s.srv.Secrets().GetVersioned(c, c.GetString(middleware.UsernameKey), c.Param("name"), metav1.GetOptions{})
 	if err != nil {
 		core.WriteResponse(c, err, nil)
 
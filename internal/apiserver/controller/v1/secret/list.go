@@ -6,16 +6,25 @@ package secret
 
 import (
 	"github.com/gin-gonic/gin"
+	v1 "github.com/marmotedu/api/apiserver/v1"
 	"github.com/marmotedu/component-base/pkg/core"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 	"github.com/marmotedu/errors"
 
+	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/pkg/code"
 	"github.com/marmotedu/iam/internal/pkg/middleware"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
-// List list all the secrets.
+// listQuery is the query of the secret list in addition to metav1.ListOptions.
+type listQuery struct {
+	// Continue is the continue token of the previous page, the secrets are then listed after it rather than
+	// from the offset.
+	Continue string `form:"continue"`
+}
+
+// List list all the secrets, they may be read from a read replica.
 func (s *SecretController) List(c *gin.Context) {
 	log.L(c).Info("list secret function called.")
 	var r metav1.ListOptions
@@ -25,12 +34,34 @@ func (s *SecretController) List(c *gin.Context) {
 		return
 	}
 
-	secrets, err := s.srv.Secrets().List(c, c.GetString(middleware.UsernameKey), r)
+	var q listQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, err.Error()), nil)
+
+		return
+	}
+
+	ctx, err := store.WithContinue(store.WithReplicaReads(c), store.CursorSecrets, q.Continue)
 	if err != nil {
 		core.WriteResponse(c, err, nil)
 
 		return
 	}
 
-	core.WriteResponse(c, nil, secrets)
+	secrets, err := s.srv.Secrets().List(ctx, c.GetString(middleware.UsernameKey), r)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	var lastID uint64
+	if len(secrets.Items) > 0 {
+		lastID = secrets.Items[len(secrets.Items)-1].ID
+	}
+
+	core.WriteResponse(c, nil, struct {
+		*v1.SecretList
+		Continue string `json:"continue,omitempty"`
+	}{secrets, store.ContinueToken(store.CursorSecrets, r, len(secrets.Items), lastID)})
 }
@@ -0,0 +1,69 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/component-base/pkg/core"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// Share grants another user read-only access to a secret until the given time.
+func (s *SecretController) Share(c *gin.Context) {
+	log.L(c).Info("share secret function called.")
+
+	var r v1.SecretShare
+
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, middleware.BindError(c, err), nil)
+
+		return
+	}
+
+	// must reassign the owner and the shared secret
+	r.Username = c.GetString(middleware.UsernameKey)
+	r.Name = c.Param("name")
+
+	if errs := r.Validate(); len(errs) != 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, errs.ToAggregate().Error()), nil)
+
+		return
+	}
+
+	if err := s.srv.SecretShares().Create(c, &r, metav1.CreateOptions{}); err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	core.WriteResponse(c, nil, r)
+}
+
+// ListSharedWithMe list the unexpired secrets shared with the current user.
+func (s *SecretController) ListSharedWithMe(c *gin.Context) {
+	log.L(c).Info("list shared secret function called.")
+
+	var r metav1.ListOptions
+	if err := c.ShouldBindQuery(&r); err != nil {
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, err.Error()), nil)
+
+		return
+	}
+
+	shares, err := s.srv.SecretShares().ListSharedWith(c, c.GetString(middleware.UsernameKey), r)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	core.WriteResponse(c, nil, shares)
+}
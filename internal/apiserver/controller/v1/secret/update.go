@@ -6,23 +6,30 @@ package secret
 
 import (
 	"github.com/gin-gonic/gin"
-	v1 "github.com/marmotedu/api/apiserver/v1"
 	"github.com/marmotedu/component-base/pkg/core"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 	"github.com/marmotedu/errors"
 
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
 	"github.com/marmotedu/iam/internal/pkg/code"
 	"github.com/marmotedu/iam/internal/pkg/middleware"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
-// Update update a key by the secret key identifier.
+// Update update a key by the secret key identifier. The request echoes the resource version of the
+// secret read, the update fails with a conflict if the secret changed since.
 func (s *SecretController) Update(c *gin.Context) {
 	log.L(c).Info("update secret function called.")
 
-	var r v1.Secret
+	var r modelv1.VersionedSecret
 	if err := c.ShouldBindJSON(&r); err != nil {
-		core.WriteResponse(c, errors.WithCode(code.ErrBind, err.Error()), nil)
+		core.WriteResponse(c, middleware.BindError(c, err), nil)
+
+		return
+	}
+
+	if r.ResourceVersion == 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "resourceVersion is required, get the secret first"), nil)
 
 		return
 	}
@@ -30,7 +37,7 @@ func (s *SecretController) Update(c *gin.Context) {
 	username := c.GetString(middleware.UsernameKey)
 	name := c.Param("name")
 
-	secret, err := s.srv.Secrets().Get(c, username, name, metav1.GetOptions{})
+	secret, err := s.srv.Secrets().GetVersioned(c, username, name, metav1.GetOptions{})
 	if err != nil {
 		core.WriteResponse(c, errors.WithCode(code.ErrDatabase, err.Error()), nil)
 
@@ -41,6 +48,7 @@ func (s *SecretController) Update(c *gin.Context) {
 	secret.Expires = r.Expires
 	secret.Description = r.Description
 	secret.Extend = r.Extend
+	secret.ResourceVersion = r.ResourceVersion
 
 	if errs := secret.Validate(); len(errs) != 0 {
 		core.WriteResponse(c, errors.WithCode(code.ErrValidation, errs.ToAggregate().Error()), nil)
@@ -48,7 +56,7 @@ func (s *SecretController) Update(c *gin.Context) {
 		return
 	}
 
-	if err := s.srv.Secrets().Update(c, secret, metav1.UpdateOptions{}); err != nil {
+	if err := s.srv.Secrets().UpdateVersioned(c, secret, metav1.UpdateOptions{}); err != nil {
 		core.WriteResponse(c, err, nil)
 
 		return
@@ -0,0 +1,27 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package serviceaccount
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/component-base/pkg/core"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// Delete deletes the service account by the service id, the service tokens already issued stay valid
+// until they expire.
+func (s *ServiceAccountController) Delete(c *gin.Context) {
+	log.L(c).Info("delete service account function called.")
+
+	if err := s.srv.ServiceAccounts().Delete(c, c.Param("name"), metav1.DeleteOptions{Unscoped: true}); err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	core.WriteResponse(c, nil, nil)
+}
@@ -0,0 +1,22 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package serviceaccount
+
+import (
+	srvv1 "github.com/marmotedu/iam/internal/apiserver/service/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
+)
+
+// ServiceAccountController create a service account handler used to handle request for service account resource.
+type ServiceAccountController struct {
+	srv srvv1.Service
+}
+
+// NewServiceAccountController creates a service account handler.
+func NewServiceAccountController(store store.Factory) *ServiceAccountController {
+	return &ServiceAccountController{
+		srv: srvv1.NewService(store),
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package token
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/component-base/pkg/core"
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// APIKeyHeader is the header holding the api key of the service account requesting a service token.
+const APIKeyHeader = "X-API-Key"
+
+type serviceTokenRequest struct {
+	ServiceID string `json:"serviceID" binding:"required"`
+	Audience  string `json:"audience"  binding:"required"`
+}
+
+// ServiceTokenResponse is the response of a service token request.
+type ServiceTokenResponse struct {
+	Token  string `json:"token"`
+	Expire string `json:"expire"`
+}
+
+// IssueService issues a short-lived service token to the service account authenticated by its api key,
+// sub is the service id, aud the requested audience and scope the scope of the service account.
+func (t *TokenController) IssueService(c *gin.Context) {
+	log.L(c).Info("issue service token function called.")
+
+	if t.signer == nil {
+		core.WriteResponse(c, errors.WithCode(code.ErrServiceTokenDisabled, "no service token signing key is configured"), nil)
+
+		return
+	}
+
+	var r serviceTokenRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, middleware.BindError(c, err), nil)
+
+		return
+	}
+
+	account, err := t.srv.ServiceAccounts().Authenticate(c, r.ServiceID, c.GetHeader(APIKeyHeader))
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	token, expire, err := t.signer.Sign(account.Name, r.Audience, account.Scope)
+	if err != nil {
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, err.Error()), nil)
+
+		return
+	}
+
+	log.L(c).Infow("service token issued", "serviceID", account.Name, "audience", r.Audience)
+
+	core.WriteResponse(c, nil, ServiceTokenResponse{Token: token, Expire: expire.Format(time.RFC3339)})
+}
@@ -0,0 +1,26 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package token
+
+import (
+	srvv1 "github.com/marmotedu/iam/internal/apiserver/service/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/pkg/jwt"
+)
+
+// TokenController create a token handler used to issue the service tokens.
+type TokenController struct {
+	srv srvv1.Service
+	// signer is nil if the service tokens are not enabled.
+	signer *jwt.ServiceTokenSigner
+}
+
+// NewTokenController creates a token handler, the service tokens are signed by signer.
+func NewTokenController(store store.Factory, signer *jwt.ServiceTokenSigner) *TokenController {
+	return &TokenController{
+		srv:    srvv1.NewService(store),
+		signer: signer,
+	}
+}
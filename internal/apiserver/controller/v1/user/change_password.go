@@ -12,6 +12,7 @@ import (
 	"github.com/marmotedu/errors"
 
 	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
@@ -33,7 +34,7 @@ func (u *UserController) ChangePassword(c *gin.Context) {
 	var r ChangePasswordRequest
 
 	if err := c.ShouldBindJSON(&r); err != nil {
-		core.WriteResponse(c, errors.WithCode(code.ErrBind, err.Error()), nil)
+		core.WriteResponse(c, middleware.BindError(c, err), nil)
 
 		return
 	}
@@ -6,58 +6,95 @@ package user
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 
 	srvv1 "github.com/marmotedu/iam/internal/apiserver/service/v1"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
 )
 
 func TestUserController_Create(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		wantCode int
+	}{
+		{name: "created", username: "admin", wantCode: http.StatusOK},
+		{name: "already exist", username: "colin", wantCode: http.StatusBadRequest},
+		// the usernames are case insensitive, like with the default collation of mysql.
+		{name: "already exist in other case", username: "Colin", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			factory := newTestStore(t, newTestUser("colin", 0))
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			body := bytes.NewBufferString(`{"metadata":{"name":"` + tt.username + `"},"nickname":"admin",` +
+				`"email":"aaa@qq.com","password":"Admin@2020","phone":"1812884xxx"}`)
+			c.Request, _ = http.NewRequest("POST", "/v1/users", body)
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			NewUserController(factory).Create(c)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("Create() status = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+
+			user, err := factory.Users().Get(context.TODO(), tt.username, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Users().Get() error = %v", err)
+			}
+
+			if tt.wantCode == http.StatusOK && (user.Password == "Admin@2020" || user.Compare("Admin@2020") != nil) {
+				t.Errorf("Create() stored password %q, want the hash of Admin@2020", user.Password)
+			}
+		})
+	}
+}
+
+func TestUserController_CreateBodyTooLarge(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockService := srvv1.NewMockService(ctrl)
-	mockUserSrv := srvv1.NewMockUserSrv(ctrl)
-	mockUserSrv.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
-	mockService.EXPECT().Users().Return(mockUserSrv)
-	c, _ := gin.CreateTestContext(httptest.NewRecorder())
-	body := bytes.NewBufferString(
-		`{"metadata":{"name":"admin"},"nickname":"admin","email":"aaa@qq.com","password":"Admin@2020","phone":"1812884xxx"}`,
-	)
-	c.Request, _ = http.NewRequest("POST", "/v1/users", body)
-	c.Request.Header.Set("Content-Type", "application/json")
-
-	type fields struct {
-		srv srvv1.Service
-	}
-	type args struct {
-		c *gin.Context
-	}
+	// the user is never created.
+	u := &UserController{srv: srvv1.NewMockService(ctrl)}
+
+	engine := gin.New()
+	engine.Use(middleware.BodyLimit(1024))
+	engine.POST("/v1/users", u.Create)
+
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
+		name     string
+		chunked  bool
+		wantCode int
 	}{
-		{
-			name: "default",
-			fields: fields{
-				srv: mockService,
-			},
-			args: args{
-				c: c,
-			},
-		},
+		{name: "content length", wantCode: http.StatusRequestEntityTooLarge},
+		{name: "chunked", chunked: true, wantCode: http.StatusRequestEntityTooLarge},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			u := &UserController{
-				srv: tt.fields.srv,
+			body := `{"metadata":{"name":"admin"},"nickname":"` + strings.Repeat("a", 2048) + `"}`
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Create() status = %d, want %d", w.Code, tt.wantCode)
 			}
-			u.Create(tt.args.c)
 		})
 	}
 }
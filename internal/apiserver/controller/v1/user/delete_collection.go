@@ -12,7 +12,8 @@ import (
 	"github.com/marmotedu/iam/pkg/log"
 )
 
-// DeleteCollection batch delete users by multiple usernames.
+// DeleteCollection batch delete users by multiple usernames. The users are soft deleted, they are
+// listed again with the with_deleted query of List.
 // Only administrator can call this function.
 func (u *UserController) DeleteCollection(c *gin.Context) {
 	log.L(c).Info("batch delete user function called.")
@@ -5,55 +5,29 @@
 package user
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang/mock/gomock"
-
-	srvv1 "github.com/marmotedu/iam/internal/apiserver/service/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 )
 
 func TestUserController_DeleteCollection(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
-
-	mockService := srvv1.NewMockService(ctrl)
-	mockUserSrv := srvv1.NewMockUserSrv(ctrl)
-	mockUserSrv.EXPECT().DeleteCollection(gomock.Any(), gomock.Eq([]string{"colin", "john"}), gomock.Any()).Return(nil)
-	mockService.EXPECT().Users().Return(mockUserSrv)
+	factory := newTestStore(t, newTestUser("admin", 1), newTestUser("colin", 0), newTestUser("john", 0))
 
 	c, _ := gin.CreateTestContext(httptest.NewRecorder())
 	c.Request, _ = http.NewRequest("DELETE", "/v1/users?name=colin&name=john", nil)
 
-	type fields struct {
-		srv srvv1.Service
-	}
-	type args struct {
-		c *gin.Context
-	}
-	tests := []struct {
-		name   string
-		fields fields
-		args   args
-	}{
-		{
-			name: "default",
-			fields: fields{
-				srv: mockService,
-			},
-			args: args{
-				c: c,
-			},
-		},
+	NewUserController(factory).DeleteCollection(c)
+
+	users, err := factory.Users().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Users().List() error = %v", err)
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			u := &UserController{
-				srv: tt.fields.srv,
-			}
-			u.DeleteCollection(tt.args.c)
-		})
+
+	if got := userNames(users); len(got) != 1 || got[0] != "admin" {
+		t.Errorf("users left = %v, want [admin]", got)
 	}
 }
@@ -5,56 +5,45 @@
 package user
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang/mock/gomock"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
 
-	srvv1 "github.com/marmotedu/iam/internal/apiserver/service/v1"
+	"github.com/marmotedu/iam/internal/pkg/code"
 )
 
 func TestUserController_Delete(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
-
-	mockService := srvv1.NewMockService(ctrl)
-	mockUserSrv := srvv1.NewMockUserSrv(ctrl)
-	mockUserSrv.EXPECT().Delete(gomock.Any(), gomock.Eq("admin"), gomock.Any()).Return(nil)
-	mockService.EXPECT().Users().Return(mockUserSrv)
-
-	c, _ := gin.CreateTestContext(httptest.NewRecorder())
-	c.Request, _ = http.NewRequest("DELETE", "/v1/users/admin", nil)
-	c.Params = []gin.Param{{Key: "name", Value: "admin"}}
-
-	type fields struct {
-		srv srvv1.Service
-	}
-	type args struct {
-		c *gin.Context
-	}
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
+		name     string
+		username string
 	}{
-		{
-			name: "default",
-			fields: fields{
-				srv: mockService,
-			},
-			args: args{
-				c: c,
-			},
-		},
+		{name: "default", username: "admin"},
+		{name: "not found", username: "colin"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			u := &UserController{
-				srv: tt.fields.srv,
+			factory := newTestStore(t, newTestUser("admin", 1))
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest("DELETE", "/v1/users/"+tt.username, nil)
+			c.Params = []gin.Param{{Key: "name", Value: tt.username}}
+
+			NewUserController(factory).Delete(c)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Delete() status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+			}
+
+			_, err := factory.Users().Get(context.TODO(), tt.username, metav1.GetOptions{})
+			if !errors.IsCode(err, code.ErrUserNotFound) {
+				t.Errorf("Users().Get() of the deleted user error = %v, want code %d", err, code.ErrUserNotFound)
 			}
-			u.Delete(tt.args.c)
 		})
 	}
 }
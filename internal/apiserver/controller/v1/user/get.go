@@ -9,14 +9,15 @@ import (
 	"github.com/marmotedu/component-base/pkg/core"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 
+	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
-// Get get an user by the user identifier.
+// Get get an user by the user identifier. The user may be read from a read replica.
 func (u *UserController) Get(c *gin.Context) {
 	log.L(c).Info("get user function called.")
 
-	user, err := u.srv.Users().Get(c, c.Param("name"), metav1.GetOptions{})
+	user, err := u.srv.Users().Get(store.WithReplicaReads(c), c.Param("name"), metav1.GetOptions{})
 	if err != nil {
 		core.WriteResponse(c, err, nil)
 
@@ -5,68 +5,52 @@
 package user
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang/mock/gomock"
 	v1 "github.com/marmotedu/api/apiserver/v1"
-	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
-
-	srvv1 "github.com/marmotedu/iam/internal/apiserver/service/v1"
 )
 
 func TestUserController_Get(t *testing.T) {
-	user := &v1.User{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "admin",
-			ID:   0,
-		},
-		Nickname: "admin",
-		Password: "Admin@2020",
-		Email:    "admin@foxmail.com",
-	}
-
-	c, _ := gin.CreateTestContext(httptest.NewRecorder())
-	c.Request, _ = http.NewRequest("GET", "/v1/users/colin", nil)
-	c.Params = []gin.Param{{Key: "name", Value: "admin"}}
-
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
+	u := NewUserController(newTestStore(t, newTestUser("admin", 1)))
 
-	mockService := srvv1.NewMockService(ctrl)
-	mockUserSrv := srvv1.NewMockUserSrv(ctrl)
-	mockUserSrv.EXPECT().Get(gomock.Any(), gomock.Eq("admin"), gomock.Any()).Return(user, nil)
-	mockService.EXPECT().Users().Return(mockUserSrv)
-
-	type fields struct {
-		srv srvv1.Service
-	}
-	type args struct {
-		c *gin.Context
-	}
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
+		name     string
+		username string
+		wantCode int
 	}{
-		{
-			name: "default",
-			fields: fields{
-				srv: mockService,
-			},
-			args: args{
-				c: c,
-			},
-		},
+		{name: "default", username: "admin", wantCode: http.StatusOK},
+		{name: "other case", username: "Admin", wantCode: http.StatusOK},
+		{name: "not found", username: "colin", wantCode: http.StatusNotFound},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			u := &UserController{
-				srv: tt.fields.srv,
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest("GET", "/v1/users/"+tt.username, nil)
+			c.Params = []gin.Param{{Key: "name", Value: tt.username}}
+
+			u.Get(c)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("Get() status = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var user v1.User
+			if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+				t.Fatalf("Get() returned %s: %v", w.Body.String(), err)
+			}
+
+			if user.Name != "admin" || user.Email != "admin@foxmail.com" {
+				t.Errorf("Get() = %s <%s>, want admin", user.Name, user.Email)
 			}
-			u.Get(tt.args.c)
 		})
 	}
 }
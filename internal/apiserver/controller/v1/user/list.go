@@ -5,16 +5,31 @@
 package user
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
+	v1 "github.com/marmotedu/api/apiserver/v1"
 	"github.com/marmotedu/component-base/pkg/core"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 	"github.com/marmotedu/errors"
 
+	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/pkg/code"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
-// List list the users in the storage.
+// listQuery is the query of the user list in addition to metav1.ListOptions.
+type listQuery struct {
+	// LastLoginBefore is a unix time, only the users not logged in since then are listed if it is set.
+	LastLoginBefore *int64 `form:"last_login_before"`
+	// WithDeleted lists the soft deleted users too, along with their policies.
+	WithDeleted bool `form:"with_deleted"`
+	// Continue is the continue token of the previous page, the users are then listed after it rather than
+	// from the offset.
+	Continue string `form:"continue"`
+}
+
+// List list the users in the storage, they may be read from a read replica.
 // Only administrator can call this function.
 func (u *UserController) List(c *gin.Context) {
 	log.L(c).Info("list user function called.")
@@ -26,12 +41,45 @@ func (u *UserController) List(c *gin.Context) {
 		return
 	}
 
-	users, err := u.srv.Users().List(c, r)
+	var q listQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, err.Error()), nil)
+
+		return
+	}
+
+	var users *v1.UserList
+
+	ctx, err := store.WithContinue(store.WithReplicaReads(c), store.CursorUsers, q.Continue)
 	if err != nil {
 		core.WriteResponse(c, err, nil)
 
 		return
 	}
 
-	core.WriteResponse(c, nil, users)
+	if q.WithDeleted {
+		ctx = store.WithDeleted(ctx)
+	}
+
+	if q.LastLoginBefore != nil {
+		users, err = u.srv.Users().ListInactive(ctx, time.Unix(*q.LastLoginBefore, 0), r)
+	} else {
+		users, err = u.srv.Users().List(ctx, r)
+	}
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	var lastID uint64
+	if len(users.Items) > 0 {
+		lastID = users.Items[len(users.Items)-1].ID
+	}
+
+	core.WriteResponse(c, nil, struct {
+		*v1.UserList
+		Continue string `json:"continue,omitempty"`
+	}{users, store.ContinueToken(store.CursorUsers, r, len(users.Items), lastID)})
 }
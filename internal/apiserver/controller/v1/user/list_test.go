@@ -5,56 +5,153 @@
 package user
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang/mock/gomock"
+	v1 "github.com/marmotedu/api/apiserver/v1"
 
-	srvv1 "github.com/marmotedu/iam/internal/apiserver/service/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
 )
 
+// userNames returns the names of the users, in the order of the list.
+func userNames(users *v1.UserList) []string {
+	names := []string{}
+	for _, user := range users.Items {
+		names = append(names, user.Name)
+	}
+
+	return names
+}
+
 func TestUserController_List(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
+	colin := newTestUser("colin", 0)
+	colin.LoginedAt = time.Unix(1500000000, 0)
+	u := NewUserController(newTestStore(t, colin, newTestUser("john", 0)))
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantUsers []string
+	}{
+		{name: "all users", query: "?limit=10", wantCode: http.StatusOK, wantUsers: []string{"john", "colin"}},
+		{name: "by name", query: "?fieldSelector=name=col", wantCode: http.StatusOK, wantUsers: []string{"colin"}},
+		{
+			name:      "last login before",
+			query:     "?last_login_before=1600000000&limit=10",
+			wantCode:  http.StatusOK,
+			wantUsers: []string{"colin"},
+		},
+		{
+			name:      "no inactive user",
+			query:     "?last_login_before=1400000000",
+			wantCode:  http.StatusOK,
+			wantUsers: []string{},
+		},
+		{name: "invalid last login", query: "?last_login_before=90d", wantCode: http.StatusBadRequest},
+		{
+			name:      "with deleted",
+			query:     "?with_deleted=true&limit=10",
+			wantCode:  http.StatusOK,
+			wantUsers: []string{"john", "colin"},
+		},
+		{name: "invalid with deleted", query: "?with_deleted=maybe", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest("GET", "/v1/users"+tt.query, nil)
+
+			u.List(c)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("List() status = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var users v1.UserList
+			if err := json.Unmarshal(w.Body.Bytes(), &users); err != nil {
+				t.Fatalf("List() returned %s: %v", w.Body.String(), err)
+			}
+
+			if got := userNames(&users); !reflect.DeepEqual(got, tt.wantUsers) || users.TotalCount != int64(len(got)) {
+				t.Errorf("List() = %v of %d, want %v", got, users.TotalCount, tt.wantUsers)
+			}
+		})
+	}
+}
+
+func TestUserController_List_Continue(t *testing.T) {
+	u := NewUserController(newTestStore(t, newTestUser("colin", 0), newTestUser("john", 0), newTestUser("tony", 0)))
+
+	list := func(query string) (int, []string, string) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("GET", "/v1/users"+query, nil)
 
-	mockService := srvv1.NewMockService(ctrl)
-	mockUserSrv := srvv1.NewMockUserSrv(ctrl)
-	mockUserSrv.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, nil)
-	mockService.EXPECT().Users().Return(mockUserSrv)
+		u.List(c)
 
-	c, _ := gin.CreateTestContext(httptest.NewRecorder())
-	c.Request, _ = http.NewRequest("GET", "/v1/users", nil)
-	c.Params = []gin.Param{{Key: "name", Value: "colin"}}
+		var page struct {
+			v1.UserList
+			Continue string `json:"continue"`
+		}
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+				t.Fatalf("List() returned %s: %v", w.Body.String(), err)
+			}
+		}
+
+		return w.Code, userNames(&page.UserList), page.Continue
+	}
+
+	// the pages of two users are listed until the continue token is empty.
+	var got []string
+	query := "?limit=2"
+	for pages := 0; ; pages++ {
+		if pages == 3 {
+			t.Fatalf("List() returned more than 2 pages: %v", got)
+		}
+
+		code, users, token := list(query)
+		if code != http.StatusOK {
+			t.Fatalf("List(%s) status = %d, want %d", query, code, http.StatusOK)
+		}
 
-	type fields struct {
-		srv srvv1.Service
+		got = append(got, users...)
+		if token == "" {
+			break
+		}
+
+		query = "?limit=2&continue=" + url.QueryEscape(token)
 	}
-	type args struct {
-		c *gin.Context
+
+	if want := []string{"tony", "john", "colin"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List() pages = %v, want %v", got, want)
 	}
+
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
+		name  string
+		token string
 	}{
-		{
-			name: "default",
-			fields: fields{
-				srv: mockService,
-			},
-			args: args{
-				c: c,
-			},
-		},
+		{name: "forged token", token: store.EncodeCursor(store.Cursor{List: store.CursorUsers, ID: 3}) + "x"},
+		{name: "token of another list", token: store.EncodeCursor(store.Cursor{List: store.CursorPolicies, ID: 3})},
+		{name: "malformed token", token: "3"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			u := &UserController{
-				srv: tt.fields.srv,
+			if code, _, _ := list("?continue=" + url.QueryEscape(tt.token)); code != http.StatusBadRequest {
+				t.Errorf("List() status = %d, want %d", code, http.StatusBadRequest)
 			}
-			u.List(tt.args.c)
 		})
 	}
 }
@@ -0,0 +1,28 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUserController_ListTokens(t *testing.T) {
+	u := NewUserController(newTestStore(t, newTestUser("colin", 0)))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/v1/users/colin/tokens", nil)
+	c.Params = []gin.Param{{Key: "name", Value: "colin"}}
+
+	u.ListTokens(c)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("ListTokens() status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+}
@@ -12,17 +12,25 @@ import (
 	"github.com/marmotedu/errors"
 
 	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
+// updateRequest is the request of the user update, Status is nil if it is not given.
+type updateRequest struct {
+	v1.User
+	Status *int `json:"status"`
+}
+
 // Update update a user info by the user identifier.
+// Only administrator can enable or disable a user.
 func (u *UserController) Update(c *gin.Context) {
 	log.L(c).Info("update user function called.")
 
-	var r v1.User
+	var r updateRequest
 
 	if err := c.ShouldBindJSON(&r); err != nil {
-		core.WriteResponse(c, errors.WithCode(code.ErrBind, err.Error()), nil)
+		core.WriteResponse(c, middleware.BindError(c, err), nil)
 
 		return
 	}
@@ -39,6 +47,16 @@ func (u *UserController) Update(c *gin.Context) {
 	user.Phone = r.Phone
 	user.Extend = r.Extend
 
+	if r.Status != nil && *r.Status != user.Status {
+		if err := u.checkStatusUpdate(c, *r.Status); err != nil {
+			core.WriteResponse(c, err, nil)
+
+			return
+		}
+
+		user.Status = *r.Status
+	}
+
 	if errs := user.ValidateUpdate(); len(errs) != 0 {
 		core.WriteResponse(c, errors.WithCode(code.ErrValidation, errs.ToAggregate().Error()), nil)
 
@@ -54,3 +72,20 @@ func (u *UserController) Update(c *gin.Context) {
 
 	core.WriteResponse(c, nil, user)
 }
+
+func (u *UserController) checkStatusUpdate(c *gin.Context, status int) error {
+	if status != 0 && status != 1 {
+		return errors.WithCode(code.ErrValidation, "status must be 1 (enabled) or 0 (disabled)")
+	}
+
+	operator, err := u.srv.Users().Get(c, c.GetString(middleware.UsernameKey), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if operator.IsAdmin != 1 {
+		return errors.WithCode(code.ErrPermissionDenied, "only administrator can change the status of a user")
+	}
+
+	return nil
+}
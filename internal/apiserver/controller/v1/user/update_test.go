@@ -6,79 +6,112 @@ package user
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang/mock/gomock"
-	v1 "github.com/marmotedu/api/apiserver/v1"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
 
-	srvv1 "github.com/marmotedu/iam/internal/apiserver/service/v1"
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
 )
 
 func TestUserController_Update(t *testing.T) {
-	user := &v1.User{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "admin",
-			ID:   0,
-		},
-		Nickname: "admin",
-		Password: "Admin@2020",
-		Email:    "admin@foxmail.com",
-		Phone:    "1812884xxxx",
-	}
+	factory := newTestStore(t, newTestUser("admin", 1))
 
-	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
 	body := bytes.NewBufferString(`{"nickname":"admin2","email":"admin2@foxmail.com","phone":"1812885xxx"}`)
 	c.Request, _ = http.NewRequest("PUT", "/v1/users/admin", body)
 	c.Params = []gin.Param{{Key: "name", Value: "admin"}}
 	c.Request.Header.Set("Content-Type", "application/json")
 
-	// deep copy
-	user2 := new(v1.User)
-	*user2 = *user
-	user2.Nickname = "admin2"
-	user2.Email = "admin2@foxmail.com"
-	user2.Phone = "1812885xxx"
-
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
+	NewUserController(factory).Update(c)
 
-	mockService := srvv1.NewMockService(ctrl)
-	mockUserSrv := srvv1.NewMockUserSrv(ctrl)
-	mockUserSrv.EXPECT().Get(gomock.Any(), gomock.Eq("admin"), gomock.Any()).Return(user, nil)
-	mockUserSrv.EXPECT().Update(gomock.Any(), gomock.Eq(user2), gomock.Any()).Return(nil)
-	mockService.EXPECT().Users().Return(mockUserSrv).Times(2)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Update() status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
 
-	type fields struct {
-		srv srvv1.Service
+	user, err := factory.Users().Get(context.TODO(), "admin", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Users().Get() error = %v", err)
 	}
-	type args struct {
-		c *gin.Context
+
+	if user.Nickname != "admin2" || user.Email != "admin2@foxmail.com" || user.Phone != "1812885xxx" {
+		t.Errorf("Update() stored %s <%s> %s, want admin2 <admin2@foxmail.com> 1812885xxx",
+			user.Nickname, user.Email, user.Phone)
 	}
+}
+
+func TestUserController_UpdateStatus(t *testing.T) {
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
+		name       string
+		operator   string
+		body       string
+		wantStatus int
+		wantCode   int
 	}{
 		{
-			name: "default",
-			fields: fields{
-				srv: mockService,
-			},
-			args: args{
-				c: c,
-			},
+			name:       "disabled by administrator",
+			operator:   "admin",
+			body:       `{"nickname":"colin","email":"colin@foxmail.com","status":0}`,
+			wantStatus: 0,
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "status unchanged",
+			operator:   "colin",
+			body:       `{"nickname":"colin","email":"colin@foxmail.com","status":1}`,
+			wantStatus: 1,
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "status not given",
+			operator:   "colin",
+			body:       `{"nickname":"colin","email":"colin@foxmail.com"}`,
+			wantStatus: 1,
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "disabled by user",
+			operator:   "colin",
+			body:       `{"nickname":"colin","email":"colin@foxmail.com","status":0}`,
+			wantStatus: 1,
+			wantCode:   http.StatusForbidden,
+		},
+		{
+			name:       "invalid status",
+			operator:   "admin",
+			body:       `{"nickname":"colin","email":"colin@foxmail.com","status":2}`,
+			wantStatus: 1,
+			wantCode:   http.StatusBadRequest,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			u := &UserController{
-				srv: tt.fields.srv,
+			factory := newTestStore(t, newTestUser("admin", 1), newTestUser("colin", 0))
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest("PUT", "/v1/users/colin", bytes.NewBufferString(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = []gin.Param{{Key: "name", Value: "colin"}}
+			c.Set(middleware.UsernameKey, tt.operator)
+
+			NewUserController(factory).Update(c)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("Update() status = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+
+			// the disabled users are not found.
+			_, err := factory.Users().Get(context.TODO(), "colin", metav1.GetOptions{})
+			if got := !errors.IsCode(err, code.ErrUserNotFound); got != (tt.wantStatus == 1) {
+				t.Errorf("Users().Get() error = %v, want the user enabled %v", err, tt.wantStatus == 1)
 			}
-			u.Update(tt.args.c)
 		})
 	}
 }
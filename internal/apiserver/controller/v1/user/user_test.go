@@ -5,15 +5,56 @@
 package user
 
 import (
+	"context"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 
 	srvv1 "github.com/marmotedu/iam/internal/apiserver/service/v1"
 	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/apiserver/store/sqlite"
+	"github.com/marmotedu/iam/pkg/db"
 )
 
+// testPassword is the bcrypt hash of Admin@2020, the password of the test users.
+const testPassword = "$2a$10$KqZhl5WStpa2K.ddEyzyf.zXllEXP4gIG8xQUgMhU1ZvMUn/Ta5um"
+
+// newTestUser returns an enabled user, an administrator if isAdmin is 1.
+func newTestUser(name string, isAdmin int) *v1.User {
+	return &v1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     1,
+		Nickname:   name,
+		Password:   testPassword,
+		Email:      name + "@foxmail.com",
+		IsAdmin:    isAdmin,
+		LoginedAt:  time.Now(),
+	}
+}
+
+// newTestStore returns an in-memory sqlite store holding the given users, closed at the end of the test.
+func newTestStore(t *testing.T, users ...*v1.User) store.Factory {
+	t.Helper()
+
+	factory, err := sqlite.Open(&db.SqliteOptions{Path: db.SqliteMemory})
+	if err != nil {
+		t.Fatalf("sqlite.Open() error = %v", err)
+	}
+	t.Cleanup(func() { factory.Close() })
+
+	for _, user := range users {
+		if err := factory.Users().Create(context.TODO(), user, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("Users().Create() error = %v", err)
+		}
+	}
+
+	return factory
+}
+
 func TestNewUserController(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
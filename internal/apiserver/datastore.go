@@ -0,0 +1,183 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/apiserver/store/migrate"
+	"github.com/marmotedu/iam/internal/apiserver/store/mysql"
+	"github.com/marmotedu/iam/internal/apiserver/store/postgres"
+	"github.com/marmotedu/iam/internal/apiserver/store/sqlite"
+	"github.com/marmotedu/iam/internal/pkg/logger"
+	genericoptions "github.com/marmotedu/iam/internal/pkg/options"
+	"github.com/marmotedu/iam/pkg/db"
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/marmotedu/iam/pkg/shutdown"
+)
+
+// getStoreFactoryOr creates the store factory of the database engine selected by --datastore.engine.
+func getStoreFactoryOr(engine string, mysqlOptions *genericoptions.MySQLOptions,
+	postgresOptions *genericoptions.PostgresOptions, sqliteOptions *genericoptions.SqliteOptions,
+) (store.Factory, error) {
+	switch engine {
+	case genericoptions.DatastoreEnginePostgres:
+		return postgres.GetPostgresFactoryOr(postgresOptions)
+	case genericoptions.DatastoreEngineSqlite:
+		return sqlite.GetSqliteFactoryOr(sqliteOptions)
+	default:
+		return mysql.GetMySQLFactoryOr(mysqlOptions)
+	}
+}
+
+// pingStore returns the health check of the database of the given engine.
+func pingStore(engine string) func(context.Context) error {
+	switch engine {
+	case genericoptions.DatastoreEnginePostgres:
+		return postgres.Ping
+	case genericoptions.DatastoreEngineSqlite:
+		return sqlite.Ping
+	default:
+		return mysql.Ping
+	}
+}
+
+// reloadMySQLPassword rotates the password of the new connections of the mysql store, re-read from
+// --mysql.password-file, or --mysql.password, on reload.
+func reloadMySQLPassword() error {
+	password := viper.GetString("mysql.password")
+	if file := viper.GetString("mysql.password-file"); file != "" {
+		var err error
+		if password, err = genericoptions.ReadSecretFile(file); err != nil {
+			return fmt.Errorf("--mysql.password-file: %w", err)
+		}
+	}
+
+	mysql.SetPassword(password)
+
+	log.Info("The mysql password is rotated, the new connections use it")
+
+	return nil
+}
+
+// runHealthCheck pings the database of the mysql store periodically, until the stores are closed, for the
+// store to heal once mysql is back, see mysql.RunHealthCheck. The other databases are only checked by
+// /healthz and /readyz.
+func runHealthCheck(gs *shutdown.GracefulShutdown, engine string, mysqlOptions *genericoptions.MySQLOptions) {
+	if engine != genericoptions.DatastoreEngineMySQL || mysqlOptions.HealthCheckInterval == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go mysql.RunHealthCheck(ctx, mysqlOptions.HealthCheckInterval, mysqlOptions.HealthCheckTimeout)
+
+	gs.AddShutdownCallbackCtx("mysql health check", shutdown.ShutdownFuncCtx(func(context.Context, string) error {
+		cancel()
+
+		return nil
+	}), shutdown.PriorityCloseStores)
+}
+
+// newMigrator returns the migrator of the database of the given engine, with a connection of its own
+// which is closed with it.
+func newMigrator(engine string, mysqlOptions *genericoptions.MySQLOptions,
+	postgresOptions *genericoptions.PostgresOptions, sqliteOptions *genericoptions.SqliteOptions,
+) (*migrate.Migrator, error) {
+	var (
+		dbIns *gorm.DB
+		err   error
+	)
+
+	switch engine {
+	case genericoptions.DatastoreEnginePostgres:
+		dbIns, err = db.NewPostgres(&db.PostgresOptions{
+			Host:     postgresOptions.Host,
+			Username: postgresOptions.Username,
+			Password: postgresOptions.Password,
+			Database: postgresOptions.Database,
+			SSLMode:  postgresOptions.SSLMode,
+			LogLevel: postgresOptions.LogLevel,
+			Logger:   logger.New(postgresOptions.LogLevel),
+		})
+	case genericoptions.DatastoreEngineSqlite:
+		dbIns, err = db.NewSqlite(&db.SqliteOptions{
+			Path:     sqliteOptions.Path,
+			LogLevel: sqliteOptions.LogLevel,
+			Logger:   logger.New(sqliteOptions.LogLevel),
+		})
+	default:
+		// the statements of a migration are executed at once.
+		dbIns, err = db.New(&db.Options{
+			Host:            mysqlOptions.Host,
+			Username:        mysqlOptions.Username,
+			Password:        mysqlOptions.Password,
+			Database:        mysqlOptions.Database,
+			MultiStatements: true,
+			LogLevel:        mysqlOptions.LogLevel,
+			Logger:          logger.New(mysqlOptions.LogLevel),
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open the %s database for the migrations failed: %w", engine, err)
+	}
+
+	return migrate.New(dbIns, engine)
+}
+
+// migrateStore applies the pending migrations of the database with --datastore.auto-migrate. Otherwise
+// the apiserver refuses to serve a database with pending migrations, older than its binary, unless
+// --datastore.pending-migrations is warn. The sqlite databases are migrated when they are opened.
+func migrateStore(datastoreOptions *genericoptions.DatastoreOptions, mysqlOptions *genericoptions.MySQLOptions,
+	postgresOptions *genericoptions.PostgresOptions, sqliteOptions *genericoptions.SqliteOptions,
+) error {
+	if datastoreOptions.Engine == genericoptions.DatastoreEngineSqlite {
+		return nil
+	}
+
+	migrator, err := newMigrator(datastoreOptions.Engine, mysqlOptions, postgresOptions, sqliteOptions)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	ctx := context.Background()
+	if datastoreOptions.AutoMigrate {
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			log.Infof("Applied the migration %s of the %s database", migration, datastoreOptions.Engine)
+		}
+
+		return err
+	}
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		return fmt.Errorf("check the migrations of the %s database failed: %w", datastoreOptions.Engine, err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(pending))
+	for _, migration := range pending {
+		names = append(names, migration.String())
+	}
+
+	if datastoreOptions.PendingMigrations == genericoptions.PendingMigrationsWarn {
+		log.Warnf("The %s database has pending migrations %s, apply them by the migrate command",
+			datastoreOptions.Engine, strings.Join(names, ", "))
+
+		return nil
+	}
+
+	return fmt.Errorf("the %s database has pending migrations %s, apply them by the migrate command, "+
+		"or set --datastore.pending-migrations to warn to serve anyway", datastoreOptions.Engine, strings.Join(names, ", "))
+}
@@ -5,16 +5,22 @@
 package apiserver
 
 import (
+	"context"
 	"net"
 
 	"google.golang.org/grpc"
 
+	genericapiserver "github.com/marmotedu/iam/internal/pkg/server"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
 type grpcAPIServer struct {
 	*grpc.Server
 	address string
+	// stopWatch stops the watch streams, which never end by themselves.
+	stopWatch context.CancelFunc
+	// certificates serves the certificate of the server, it is reloaded with the one of the https server.
+	certificates *genericapiserver.CertificateProvider
 }
 
 func (s *grpcAPIServer) Run() {
@@ -32,7 +38,41 @@ func (s *grpcAPIServer) Run() {
 	log.Infof("start grpc server at %s", s.address)
 }
 
-func (s *grpcAPIServer) Close() {
-	s.GracefulStop()
-	log.Infof("GRPC server on %s stopped", s.address)
+// Shutdown stops accepting new connections and waits for the in-flight rpcs to complete, the watch
+// streams are ended first. Once ctx is done, the connections still open are closed, cancelling
+// their rpcs, and the error of ctx is returned.
+func (s *grpcAPIServer) Shutdown(ctx context.Context) error {
+	s.stopWatch()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		log.Infof("GRPC server on %s stopped", s.address)
+
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+		<-stopped
+
+		return ctx.Err()
+	}
+}
+
+// ReloadCertificate re-reads the certificate files, the new connections are served the new certificate.
+func (s *grpcAPIServer) ReloadCertificate() error {
+	changed, err := s.certificates.Reload()
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		log.Infof("Reloaded the certificate of the grpc server on %s", s.address)
+	}
+
+	return nil
 }
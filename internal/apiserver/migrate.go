@@ -0,0 +1,97 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gosuri/uitable"
+
+	"github.com/marmotedu/iam/internal/apiserver/options"
+	"github.com/marmotedu/iam/pkg/app"
+)
+
+// migrateCommandDesc is short, the command is listed with it. up applies the pending migrations, down
+// reverts the last applied one, and status lists them.
+const migrateCommandDesc = "Migrate the schema of the database of the store."
+
+// newMigrateCommand creates the migrate command, applying the migrations of the database selected
+// by --datastore.engine.
+func newMigrateCommand() *app.Command {
+	opts := options.NewMigrateOptions()
+
+	return app.NewCommand("migrate up|down|status", migrateCommandDesc,
+		app.WithCommandOptions(opts),
+		app.WithCommandRunFunc(runMigrate(opts)),
+	)
+}
+
+func runMigrate(opts *options.MigrateOptions) app.RunCommandFunc {
+	return func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("migrate takes one of up, down or status, got %q", args)
+		}
+
+		migrator, err := newMigrator(opts.DatastoreOptions.Engine, opts.MySQLOptions, opts.PostgresOptions,
+			opts.SqliteOptions)
+		if err != nil {
+			return err
+		}
+		defer migrator.Close()
+
+		ctx := context.Background()
+		switch args[0] {
+		case "up":
+			applied, err := migrator.Up(ctx)
+			for _, migration := range applied {
+				fmt.Printf("Applied the migration %s\n", migration)
+			}
+			if err == nil && len(applied) == 0 {
+				fmt.Println("No pending migration")
+			}
+
+			return err
+		case "down":
+			reverted, err := migrator.Down(ctx)
+			if err != nil {
+				return err
+			}
+
+			if reverted == nil {
+				fmt.Println("No applied migration")
+			} else {
+				fmt.Printf("Reverted the migration %s\n", reverted)
+			}
+
+			return nil
+		case "status":
+			statuses, err := migrator.Status(ctx)
+			if err != nil {
+				return err
+			}
+
+			table := uitable.New()
+			table.AddRow("VERSION", "NAME", "APPLIED AT")
+			for _, status := range statuses {
+				appliedAt := "pending"
+				if status.AppliedAt != nil {
+					appliedAt = status.AppliedAt.Format(time.RFC3339)
+				}
+				if status.Unknown {
+					appliedAt += " (unknown to this binary)"
+				}
+
+				table.AddRow(fmt.Sprintf("%04d", status.Version), status.Name, appliedAt)
+			}
+			fmt.Println(table)
+
+			return nil
+		default:
+			return fmt.Errorf("migrate takes one of up, down or status, got %q", args[0])
+		}
+	}
+}
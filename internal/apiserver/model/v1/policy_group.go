@@ -0,0 +1,137 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package v1
+
+import (
+	"github.com/marmotedu/component-base/pkg/json"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/component-base/pkg/util/idutil"
+	"github.com/marmotedu/component-base/pkg/validation"
+	"github.com/marmotedu/component-base/pkg/validation/field"
+	"gorm.io/gorm"
+)
+
+// PolicyGroup groups related policies of a user so they are managed as a unit. The member
+// policies of a group are only enforced once the group is applied.
+// It is also used as gorm model.
+type PolicyGroup struct {
+	// Standard object's metadata.
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Username is the owner of the group and of its member policies.
+	Username    string `json:"username"    gorm:"column:username"    validate:"omitempty"`
+	Description string `json:"description" gorm:"column:description" validate:"omitempty"`
+
+	// PolicyIDs are the names of the member policies, will not be stored in db.
+	PolicyIDs []string `json:"policyIDs" gorm:"-" validate:"omitempty"`
+
+	// Applied is true once the member policies are activated.
+	Applied bool `json:"applied" gorm:"column:applied" validate:"omitempty"`
+
+	// The member policies, just a json format of PolicyIDs. DO NOT modify directly.
+	PolicyIDsShadow string `json:"-" gorm:"column:policyIDs" validate:"omitempty"`
+}
+
+// PolicyGroupList is the whole list of all policy groups which have been stored in stroage.
+type PolicyGroupList struct {
+	// Standard list metadata.
+	metav1.ListMeta `json:",inline"`
+
+	// List of policy groups.
+	Items []*PolicyGroup `json:"items"`
+}
+
+// TableName maps to mysql table name.
+func (g *PolicyGroup) TableName() string {
+	return "policy_groups"
+}
+
+// BeforeCreate run before create database record.
+func (g *PolicyGroup) BeforeCreate(tx *gorm.DB) error {
+	if err := g.ObjectMeta.BeforeCreate(tx); err != nil {
+		return err
+	}
+
+	return g.marshalPolicyIDs()
+}
+
+// AfterCreate run after create database record.
+func (g *PolicyGroup) AfterCreate(tx *gorm.DB) error {
+	g.InstanceID = idutil.GetInstanceID(g.ID, "pgroup-")
+
+	return tx.Save(g).Error
+}
+
+// BeforeUpdate run before update database record.
+func (g *PolicyGroup) BeforeUpdate(tx *gorm.DB) error {
+	if err := g.ObjectMeta.BeforeUpdate(tx); err != nil {
+		return err
+	}
+
+	return g.marshalPolicyIDs()
+}
+
+// AfterFind run after find to unmarshal the member policies.
+func (g *PolicyGroup) AfterFind(tx *gorm.DB) error {
+	if err := g.ObjectMeta.AfterFind(tx); err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(g.PolicyIDsShadow), &g.PolicyIDs)
+}
+
+func (g *PolicyGroup) marshalPolicyIDs() error {
+	if g.PolicyIDs == nil {
+		g.PolicyIDs = []string{}
+	}
+
+	data, err := json.Marshal(g.PolicyIDs)
+	if err != nil {
+		return err
+	}
+
+	g.PolicyIDsShadow = string(data)
+
+	return nil
+}
+
+// HasPolicy returns whether the policy is a member of the group.
+func (g *PolicyGroup) HasPolicy(name string) bool {
+	for _, id := range g.PolicyIDs {
+		if id == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate validates that a policy group object is valid.
+func (g *PolicyGroup) Validate() field.ErrorList {
+	val := validation.NewValidator(g)
+	allErrs := val.Validate()
+	allErrs = append(allErrs, ValidatePolicyIDs(g.PolicyIDs, field.NewPath("policyIDs"))...)
+
+	return allErrs
+}
+
+// ValidatePolicyIDs validates the member policies of a policy group.
+func ValidatePolicyIDs(ids []string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	seen := make(map[string]bool, len(ids))
+
+	for i, id := range ids {
+		switch {
+		case id == "":
+			allErrs = append(allErrs, field.Required(fldPath.Index(i), "policy name is required"))
+		case seen[id]:
+			allErrs = append(allErrs, field.Duplicate(fldPath.Index(i), id))
+		}
+
+		seen[id] = true
+	}
+
+	return allErrs
+}
@@ -0,0 +1,73 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package v1
+
+import (
+	"time"
+
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/component-base/pkg/util/idutil"
+	"github.com/marmotedu/component-base/pkg/validation"
+	"github.com/marmotedu/component-base/pkg/validation/field"
+	"gorm.io/gorm"
+)
+
+// SecretShare grants a user read-only access to a secret owned by another user until ExpiresAt.
+// It is also used as gorm model.
+type SecretShare struct {
+	// Standard object's metadata, the name is the name of the shared secret.
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Username is the owner of the shared secret.
+	Username       string     `json:"username"         gorm:"column:username"       validate:"omitempty"`
+	SecretID       string     `json:"secretID"         gorm:"column:secretID"       validate:"omitempty"`
+	TargetUsername string     `json:"targetUsername"   gorm:"column:targetUsername" validate:"required"`
+	ExpiresAt      time.Time  `json:"expiresAt"        gorm:"column:expiresAt"      validate:"required"`
+	Secret         *v1.Secret `json:"secret,omitempty" gorm:"-"                     validate:"-"`
+}
+
+// SecretShareList is the whole list of all secret shares which have been stored in stroage.
+type SecretShareList struct {
+	// Standard list metadata.
+	metav1.ListMeta `json:",inline"`
+
+	// List of secret shares
+	Items []*SecretShare `json:"items"`
+}
+
+// TableName maps to mysql table name.
+func (s *SecretShare) TableName() string {
+	return "secret_shares"
+}
+
+// AfterCreate run after create database record.
+func (s *SecretShare) AfterCreate(tx *gorm.DB) error {
+	s.InstanceID = idutil.GetInstanceID(s.ID, "share-")
+
+	return tx.Save(s).Error
+}
+
+// Expired returns whether the share has expired at the given time.
+func (s *SecretShare) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// Validate validates that a secret share object is valid.
+func (s *SecretShare) Validate() field.ErrorList {
+	val := validation.NewValidator(s)
+	allErrs := val.Validate()
+
+	if s.Expired(time.Now()) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("expiresAt"), s.ExpiresAt, "must be in the future"))
+	}
+
+	if s.TargetUsername != "" && s.TargetUsername == s.Username {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("targetUsername"), s.TargetUsername,
+			"can not share a secret with its owner"))
+	}
+
+	return allErrs
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package v1
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/component-base/pkg/util/idutil"
+	"github.com/marmotedu/component-base/pkg/validation"
+	"github.com/marmotedu/component-base/pkg/validation/field"
+	"gorm.io/gorm"
+)
+
+// ServiceAccount is an internal service which requests service tokens on behalf of itself, not of a
+// user. It authenticates with its api key, which is only returned when the service account is created.
+// It is also used as gorm model.
+type ServiceAccount struct {
+	// Standard object's metadata, the name is the service id.
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Scope is the scope claim of the service tokens.
+	Scope       string `json:"scope"       gorm:"column:scope"       validate:"required"`
+	Description string `json:"description" gorm:"column:description" validate:"omitempty"`
+
+	// APIKey is only set in the response of the creation, will not be stored in db.
+	APIKey string `json:"apiKey,omitempty" gorm:"-" validate:"omitempty"`
+
+	// The sha256 hash of the api key.
+	APIKeyHash string `json:"-" gorm:"column:apiKeyHash" validate:"omitempty"`
+}
+
+// ServiceAccountList is the whole list of all service accounts which have been stored in stroage.
+type ServiceAccountList struct {
+	// Standard list metadata.
+	metav1.ListMeta `json:",inline"`
+
+	// List of service accounts
+	Items []*ServiceAccount `json:"items"`
+}
+
+// TableName maps to mysql table name.
+func (s *ServiceAccount) TableName() string {
+	return "service_accounts"
+}
+
+// AfterCreate run after create database record.
+func (s *ServiceAccount) AfterCreate(tx *gorm.DB) error {
+	s.InstanceID = idutil.GetInstanceID(s.ID, "svc-")
+
+	return tx.Save(s).Error
+}
+
+// SetAPIKey sets the api key of the service account along with its hash.
+func (s *ServiceAccount) SetAPIKey(key string) {
+	s.APIKey = key
+	s.APIKeyHash = hashAPIKey(key)
+}
+
+// CompareAPIKey returns whether key is the api key of the service account.
+func (s *ServiceAccount) CompareAPIKey(key string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashAPIKey(key)), []byte(s.APIKeyHash)) == 1
+}
+
+// Validate validates that a service account object is valid.
+func (s *ServiceAccount) Validate() field.ErrorList {
+	val := validation.NewValidator(s)
+	allErrs := val.Validate()
+
+	if errs := validation.IsQualifiedName(s.Name); len(errs) != 0 {
+		for _, msg := range errs {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("metadata", "name"), s.Name, msg))
+		}
+	}
+
+	return allErrs
+}
+
+// the api keys are random, a fast hash is enough to not store them in clear.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,44 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package v1
+
+import (
+	"time"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+)
+
+// UserSession is a jwt token issued to a user, recorded on login and on refresh.
+// It is also used as gorm model.
+type UserSession struct {
+	ID uint64 `json:"-" gorm:"primary_key;AUTO_INCREMENT;column:id"`
+
+	// JTI is the id of the token, its jti claim.
+	JTI       string    `json:"jti"       gorm:"column:jti"`
+	Username  string    `json:"-"         gorm:"column:username"`
+	IssuedAt  time.Time `json:"issuedAt"  gorm:"column:issued_at"`
+	ExpiresAt time.Time `json:"expiresAt" gorm:"column:expires_at"`
+	UserAgent string    `json:"userAgent" gorm:"column:user_agent"`
+	ClientIP  string    `json:"clientIP"  gorm:"column:client_ip"`
+}
+
+// UserSessionList is the whole list of the sessions of a user.
+type UserSessionList struct {
+	// Standard list metadata.
+	metav1.ListMeta `json:",inline"`
+
+	// List of user sessions
+	Items []*UserSession `json:"items"`
+}
+
+// TableName maps to mysql table name.
+func (s *UserSession) TableName() string {
+	return "user_sessions"
+}
+
+// Expired returns whether the token of the session has expired at the given time.
+func (s *UserSession) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
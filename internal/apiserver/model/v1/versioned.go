@@ -0,0 +1,29 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package v1
+
+import (
+	apiv1 "github.com/marmotedu/api/apiserver/v1"
+)
+
+// VersionedPolicy is a policy with its resource version, incremented by every write of the policy. The
+// updates of the api echo the version of the policy read, and fail with a conflict if it changed since.
+// It is also used as gorm model.
+type VersionedPolicy struct {
+	apiv1.Policy `json:",inline"`
+
+	// ResourceVersion is incremented by the database, it is never written by the stores.
+	ResourceVersion uint64 `json:"resourceVersion" gorm:"column:resourceVersion;->"`
+}
+
+// VersionedSecret is a secret with its resource version, incremented by every write of the secret. The
+// updates of the api echo the version of the secret read, and fail with a conflict if it changed since.
+// It is also used as gorm model.
+type VersionedSecret struct {
+	apiv1.Secret `json:",inline"`
+
+	// ResourceVersion is incremented by the database, it is never written by the stores.
+	ResourceVersion uint64 `json:"resourceVersion" gorm:"column:resourceVersion;->"`
+}
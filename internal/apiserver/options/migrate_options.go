@@ -0,0 +1,67 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	cliflag "github.com/marmotedu/component-base/pkg/cli/flag"
+	"github.com/marmotedu/errors"
+
+	genericoptions "github.com/marmotedu/iam/internal/pkg/options"
+)
+
+// MigrateOptions are the options of the migrate command, the ones of the database of the store.
+type MigrateOptions struct {
+	DatastoreOptions *genericoptions.DatastoreOptions `json:"datastore" mapstructure:"datastore"`
+	MySQLOptions     *genericoptions.MySQLOptions     `json:"mysql"     mapstructure:"mysql"`
+	PostgresOptions  *genericoptions.PostgresOptions  `json:"postgres"  mapstructure:"postgres"`
+	SqliteOptions    *genericoptions.SqliteOptions    `json:"sqlite"    mapstructure:"sqlite"`
+}
+
+// NewMigrateOptions creates a new MigrateOptions object with default parameters.
+func NewMigrateOptions() *MigrateOptions {
+	return &MigrateOptions{
+		DatastoreOptions: genericoptions.NewDatastoreOptions(),
+		MySQLOptions:     genericoptions.NewMySQLOptions(),
+		PostgresOptions:  genericoptions.NewPostgresOptions(),
+		SqliteOptions:    genericoptions.NewSqliteOptions(),
+	}
+}
+
+// Flags returns flags for the migrate command by section name.
+func (o *MigrateOptions) Flags() (fss cliflag.NamedFlagSets) {
+	o.DatastoreOptions.AddFlags(fss.FlagSet("datastore"))
+	o.MySQLOptions.AddFlags(fss.FlagSet("mysql"))
+	o.PostgresOptions.AddFlags(fss.FlagSet("postgres"))
+	o.SqliteOptions.AddFlags(fss.FlagSet("sqlite"))
+
+	return fss
+}
+
+// Complete reads the passwords of the databases from their files if set.
+func (o *MigrateOptions) Complete() error {
+	var errs []error
+
+	if err := o.MySQLOptions.Complete(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := o.PostgresOptions.Complete(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.NewAggregate(errs)
+}
+
+// Validate checks MigrateOptions and return a slice of found errs.
+func (o *MigrateOptions) Validate() []error {
+	var errs []error
+
+	errs = append(errs, o.DatastoreOptions.Validate()...)
+	errs = append(errs, o.MySQLOptions.Validate()...)
+	errs = append(errs, o.PostgresOptions.Validate()...)
+	errs = append(errs, o.SqliteOptions.Validate()...)
+
+	return errs
+}
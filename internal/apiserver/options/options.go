@@ -9,6 +9,7 @@ import (
 	cliflag "github.com/marmotedu/component-base/pkg/cli/flag"
 	"github.com/marmotedu/component-base/pkg/json"
 	"github.com/marmotedu/component-base/pkg/util/idutil"
+	"github.com/marmotedu/errors"
 
 	genericoptions "github.com/marmotedu/iam/internal/pkg/options"
 	"github.com/marmotedu/iam/internal/pkg/server"
@@ -17,15 +18,20 @@ import (
 
 // Options runs an iam api server.
 type Options struct {
-	GenericServerRunOptions *genericoptions.ServerRunOptions       `json:"server"   mapstructure:"server"`
-	GRPCOptions             *genericoptions.GRPCOptions            `json:"grpc"     mapstructure:"grpc"`
-	InsecureServing         *genericoptions.InsecureServingOptions `json:"insecure" mapstructure:"insecure"`
-	SecureServing           *genericoptions.SecureServingOptions   `json:"secure"   mapstructure:"secure"`
-	MySQLOptions            *genericoptions.MySQLOptions           `json:"mysql"    mapstructure:"mysql"`
-	RedisOptions            *genericoptions.RedisOptions           `json:"redis"    mapstructure:"redis"`
-	JwtOptions              *genericoptions.JwtOptions             `json:"jwt"      mapstructure:"jwt"`
-	Log                     *log.Options                           `json:"log"      mapstructure:"log"`
-	FeatureOptions          *genericoptions.FeatureOptions         `json:"feature"  mapstructure:"feature"`
+	GenericServerRunOptions *genericoptions.ServerRunOptions       `json:"server"        mapstructure:"server"`
+	GRPCOptions             *genericoptions.GRPCOptions            `json:"grpc"          mapstructure:"grpc"`
+	InsecureServing         *genericoptions.InsecureServingOptions `json:"insecure"      mapstructure:"insecure"`
+	SecureServing           *genericoptions.SecureServingOptions   `json:"secure"        mapstructure:"secure"`
+	DatastoreOptions        *genericoptions.DatastoreOptions       `json:"datastore"     mapstructure:"datastore"`
+	MySQLOptions            *genericoptions.MySQLOptions           `json:"mysql"         mapstructure:"mysql"`
+	PostgresOptions         *genericoptions.PostgresOptions        `json:"postgres"      mapstructure:"postgres"`
+	SqliteOptions           *genericoptions.SqliteOptions          `json:"sqlite"        mapstructure:"sqlite"`
+	RedisOptions            *genericoptions.RedisOptions           `json:"redis"         mapstructure:"redis"`
+	JwtOptions              *genericoptions.JwtOptions             `json:"jwt"           mapstructure:"jwt"`
+	ServiceTokenOptions     *genericoptions.ServiceTokenOptions    `json:"service-token" mapstructure:"service-token"`
+	Log                     *log.Options                           `json:"log"           mapstructure:"log"`
+	FeatureOptions          *genericoptions.FeatureOptions         `json:"feature"       mapstructure:"feature"`
+	AccessLogOptions        *genericoptions.AccessLogOptions       `json:"access-log"    mapstructure:"access-log"`
 }
 
 // NewOptions creates a new Options object with default parameters.
@@ -35,11 +41,16 @@ func NewOptions() *Options {
 		GRPCOptions:             genericoptions.NewGRPCOptions(),
 		InsecureServing:         genericoptions.NewInsecureServingOptions(),
 		SecureServing:           genericoptions.NewSecureServingOptions(),
+		DatastoreOptions:        genericoptions.NewDatastoreOptions(),
 		MySQLOptions:            genericoptions.NewMySQLOptions(),
+		PostgresOptions:         genericoptions.NewPostgresOptions(),
+		SqliteOptions:           genericoptions.NewSqliteOptions(),
 		RedisOptions:            genericoptions.NewRedisOptions(),
 		JwtOptions:              genericoptions.NewJwtOptions(),
+		ServiceTokenOptions:     genericoptions.NewServiceTokenOptions(),
 		Log:                     log.NewOptions(),
 		FeatureOptions:          genericoptions.NewFeatureOptions(),
+		AccessLogOptions:        genericoptions.NewAccessLogOptions(),
 	}
 
 	return &o
@@ -54,10 +65,15 @@ func (o *Options) ApplyTo(c *server.Config) error {
 func (o *Options) Flags() (fss cliflag.NamedFlagSets) {
 	o.GenericServerRunOptions.AddFlags(fss.FlagSet("generic"))
 	o.JwtOptions.AddFlags(fss.FlagSet("jwt"))
+	o.ServiceTokenOptions.AddFlags(fss.FlagSet("service token"))
 	o.GRPCOptions.AddFlags(fss.FlagSet("grpc"))
+	o.DatastoreOptions.AddFlags(fss.FlagSet("datastore"))
 	o.MySQLOptions.AddFlags(fss.FlagSet("mysql"))
+	o.PostgresOptions.AddFlags(fss.FlagSet("postgres"))
+	o.SqliteOptions.AddFlags(fss.FlagSet("sqlite"))
 	o.RedisOptions.AddFlags(fss.FlagSet("redis"))
 	o.FeatureOptions.AddFlags(fss.FlagSet("features"))
+	o.AccessLogOptions.AddFlags(fss.FlagSet("access log"))
 	o.InsecureServing.AddFlags(fss.FlagSet("insecure serving"))
 	o.SecureServing.AddFlags(fss.FlagSet("secure serving"))
 	o.Log.AddFlags(fss.FlagSet("logs"))
@@ -73,6 +89,25 @@ func (o *Options) String() string {
 
 // Complete set default Options.
 func (o *Options) Complete() error {
+	// the secrets are read from their files first, the jwt key is generated only if none is set.
+	var errs []error
+
+	for _, complete := range []func() error{
+		o.MySQLOptions.Complete,
+		o.PostgresOptions.Complete,
+		o.RedisOptions.Complete,
+		o.JwtOptions.Complete,
+		o.GRPCOptions.Complete,
+	} {
+		if err := complete(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.NewAggregate(errs)
+	}
+
 	if o.JwtOptions.Key == "" {
 		o.JwtOptions.Key = idutil.NewSecretKey()
 	}
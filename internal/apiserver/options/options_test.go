@@ -0,0 +1,190 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/marmotedu/iam/pkg/app"
+)
+
+const testConfig = `mysql:
+  host: config
+  username: config
+  database: config
+  password: config
+jwt:
+  timeout: 2h
+redis:
+  port: 6380
+`
+
+func TestOptions_precedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iam-apiserver.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	defer viper.Reset()
+
+	t.Setenv("IAM_APISERVER_MYSQL_HOST", "env")
+	t.Setenv("IAM_APISERVER_MYSQL_USERNAME", "env")
+	t.Setenv("IAM_APISERVER_MYSQL_PASSWORD", "env-secret")
+	t.Setenv("IAM_APISERVER_MYSQL_MAX_IDLE_CONNECTIONS", "50")
+	t.Setenv("IAM_APISERVER_JWT_KEY", "env-jwt-secret-key")
+	t.Setenv("IAM_APISERVER_GRPC_TOKENS", "env-token1,env-token2")
+
+	opts := NewOptions()
+	defaults := NewOptions()
+
+	application := app.NewApp("IAM API Server", "iam-apiserver",
+		app.WithOptions(opts),
+		app.WithSilence(),
+		app.WithNoVersion(),
+		app.WithRunFunc(func(string) error { return nil }),
+	)
+	cmd := application.Command()
+	cmd.SetArgs([]string{"--config", path, "--mysql.host", "flag", "--jwt.timeout", "3h"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	// flags > environment variables > configuration file > defaults.
+	tests := []struct {
+		key  string
+		got  interface{}
+		want interface{}
+	}{
+		{key: "mysql.host", got: opts.MySQLOptions.Host, want: "flag"},
+		{key: "mysql.username", got: opts.MySQLOptions.Username, want: "env"},
+		{key: "mysql.database", got: opts.MySQLOptions.Database, want: "config"},
+		{key: "mysql.password", got: opts.MySQLOptions.Password, want: "env-secret"},
+		{key: "mysql.max-idle-connections", got: opts.MySQLOptions.MaxIdleConnections, want: 50},
+		{key: "mysql.max-open-connections", got: opts.MySQLOptions.MaxOpenConnections, want: defaults.MySQLOptions.MaxOpenConnections},
+		{key: "jwt.timeout", got: opts.JwtOptions.Timeout, want: 3 * time.Hour},
+		{key: "jwt.key", got: opts.JwtOptions.Key, want: "env-jwt-secret-key"},
+		{key: "redis.port", got: opts.RedisOptions.Port, want: 6380},
+		{key: "grpc.tokens", got: strings.Join(opts.GRPCOptions.Tokens, ","), want: "env-token1,env-token2"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.key, tt.got, tt.want)
+		}
+	}
+
+	// the secrets set by the environment are not printed with the options.
+	printed := opts.String()
+	for _, secret := range []string{"env-secret", "env-jwt-secret-key", "env-token"} {
+		if strings.Contains(printed, secret) {
+			t.Errorf("String() = %s, prints the secret %s", printed, secret)
+		}
+	}
+}
+
+func TestOptions_secretFiles(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "iam-apiserver.yaml")
+
+	for name, content := range map[string]string{
+		"iam-apiserver.yaml": "jwt:\n  timeout: 2h\n",
+		"mysql-password":     "file-secret\n",
+		"jwt-key":            "  file-jwt-secret-key  \n",
+		"grpc-tokens":        "file-token1\nfile-token2\n",
+		"empty":              "\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name: "files",
+			args: []string{
+				"--mysql.password-file", filepath.Join(dir, "mysql-password"),
+				"--jwt.key-file", filepath.Join(dir, "jwt-key"),
+				"--grpc.tokens-file", filepath.Join(dir, "grpc-tokens"),
+			},
+		},
+		{
+			name:    "literal and file",
+			args:    []string{"--mysql.password", "flag-secret", "--mysql.password-file", filepath.Join(dir, "mysql-password")},
+			wantErr: "--mysql.password and --mysql.password-file",
+		},
+		{
+			name:    "empty file",
+			args:    []string{"--jwt.key-file", filepath.Join(dir, "empty")},
+			wantErr: "--jwt.key-file: the file " + filepath.Join(dir, "empty") + " is empty",
+		},
+		{
+			name:    "missing file",
+			args:    []string{"--redis.password-file", filepath.Join(dir, "missing")},
+			wantErr: "--redis.password-file: open " + filepath.Join(dir, "missing"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer viper.Reset()
+
+			opts := NewOptions()
+			application := app.NewApp("IAM API Server", "iam-apiserver",
+				app.WithOptions(opts),
+				app.WithSilence(),
+				app.WithNoVersion(),
+				app.WithRunFunc(func(string) error { return nil }),
+			)
+			cmd := application.Command()
+			cmd.SetArgs(append([]string{"--config", config}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Execute() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			if got := opts.MySQLOptions.Password; got != "file-secret" {
+				t.Errorf("mysql.password = %s, want file-secret", got)
+			}
+
+			if got := opts.JwtOptions.Key; got != "file-jwt-secret-key" {
+				t.Errorf("jwt.key = %s, want file-jwt-secret-key", got)
+			}
+
+			if got := strings.Join(opts.GRPCOptions.Tokens, ","); got != "file-token1,file-token2" {
+				t.Errorf("grpc.tokens = %s, want file-token1,file-token2", got)
+			}
+
+			// the paths of the files are printed with the options, not their contents.
+			printed := opts.String()
+			if !strings.Contains(printed, filepath.Join(dir, "mysql-password")) {
+				t.Errorf("String() = %s, does not print the path of --mysql.password-file", printed)
+			}
+
+			for _, secret := range []string{"file-secret", "file-jwt-secret-key", "file-token"} {
+				if strings.Contains(printed, secret) {
+					t.Errorf("String() = %s, prints the secret %s", printed, secret)
+				}
+			}
+		})
+	}
+}
@@ -12,11 +12,16 @@ func (o *Options) Validate() []error {
 	errs = append(errs, o.GRPCOptions.Validate()...)
 	errs = append(errs, o.InsecureServing.Validate()...)
 	errs = append(errs, o.SecureServing.Validate()...)
+	errs = append(errs, o.DatastoreOptions.Validate()...)
 	errs = append(errs, o.MySQLOptions.Validate()...)
+	errs = append(errs, o.PostgresOptions.Validate()...)
+	errs = append(errs, o.SqliteOptions.Validate()...)
 	errs = append(errs, o.RedisOptions.Validate()...)
 	errs = append(errs, o.JwtOptions.Validate()...)
+	errs = append(errs, o.ServiceTokenOptions.Validate()...)
 	errs = append(errs, o.Log.Validate()...)
 	errs = append(errs, o.FeatureOptions.Validate()...)
+	errs = append(errs, o.AccessLogOptions.Validate()...)
 
 	return errs
 }
@@ -0,0 +1,122 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(o *Options)
+		// wantErrs are substrings of the errors, in order
+		wantErrs []string
+	}{
+		{name: "defaults", modify: func(o *Options) {}},
+		{
+			name:     "negative mysql connections",
+			modify:   func(o *Options) { o.MySQLOptions.MaxOpenConnections = -1 },
+			wantErrs: []string{"--mysql.max-open-connections -1 cannot be negative"},
+		},
+		{
+			name: "more idle than open mysql connections",
+			modify: func(o *Options) {
+				o.MySQLOptions.MaxIdleConnections = 20
+				o.MySQLOptions.MaxOpenConnections = 10
+			},
+			wantErrs: []string{"--mysql.max-idle-connections 20 cannot be greater than --mysql.max-open-connections 10"},
+		},
+		{
+			name: "mysql circuit breaker without timeout",
+			modify: func(o *Options) {
+				o.MySQLOptions.CircuitBreakerThreshold = 5
+				o.MySQLOptions.CircuitBreakerTimeout = 0
+			},
+			wantErrs: []string{
+				"--mysql.circuit-breaker-timeout 0s must be greater than 0 with --mysql.circuit-breaker-threshold 5",
+			},
+		},
+		{
+			name:     "unknown datastore engine",
+			modify:   func(o *Options) { o.DatastoreOptions.Engine = "oracle" },
+			wantErrs: []string{`--datastore.engine "oracle" must be one of`},
+		},
+		{
+			name:     "short jwt key",
+			modify:   func(o *Options) { o.JwtOptions.Key = "key" },
+			wantErrs: []string{"--jwt.key of 3 characters must have between 6 and 32 characters"},
+		},
+		{
+			name:     "unknown jwt algorithm",
+			modify:   func(o *Options) { o.JwtOptions.Algorithm = "ES256" },
+			wantErrs: []string{`--jwt.algorithm "ES256" must be HS256 or RS256`},
+		},
+		{
+			name:     "jwt keys rotated too often",
+			modify:   func(o *Options) { o.JwtOptions.RotationInterval, o.JwtOptions.RetainedKeys = time.Hour, 0 },
+			wantErrs: []string{"--jwt.rotation-interval 1h0m0s * (--jwt.retained-keys 0 + 1) must not be less than"},
+		},
+		{
+			name:     "grpc port out of range",
+			modify:   func(o *Options) { o.GRPCOptions.BindPort = 70000 },
+			wantErrs: []string{"--grpc.bind-port 70000 must be between 0 and 65535"},
+		},
+		{
+			name: "tls certificate without key",
+			modify: func(o *Options) {
+				o.SecureServing.ServerCert.CertKey.CertFile = "/etc/iam/cert/iam-apiserver.pem"
+				o.SecureServing.ServerCert.CertKey.KeyFile = ""
+			},
+			wantErrs: []string{
+				`--secure.tls.cert-key.cert-file "/etc/iam/cert/iam-apiserver.pem" and ` +
+					`--secure.tls.cert-key.private-key-file "" must be set together`,
+			},
+		},
+		{
+			name:     "redis cluster with database",
+			modify:   func(o *Options) { o.RedisOptions.EnableCluster, o.RedisOptions.Database = true, 1 },
+			wantErrs: []string{"--redis.database 1 can not be used with --redis.enable-cluster"},
+		},
+		{
+			name: "all aggregated",
+			modify: func(o *Options) {
+				o.MySQLOptions.MaxOpenConnections = -1
+				o.RedisOptions.EnableCluster, o.RedisOptions.Database = true, 1
+				o.JwtOptions.Algorithm = "ES256"
+				o.AccessLogOptions.SampleRate = 2
+			},
+			wantErrs: []string{
+				"--mysql.max-open-connections -1 cannot be negative",
+				"--redis.database 1 can not be used with --redis.enable-cluster",
+				`--jwt.algorithm "ES256" must be HS256 or RS256`,
+				"--access-log.sample-rate 2 must be between 0 and 1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewOptions()
+			if err := o.Complete(); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+
+			tt.modify(o)
+
+			errs := o.Validate()
+			if len(errs) != len(tt.wantErrs) {
+				t.Fatalf("Validate() = %v, want %d errors", errs, len(tt.wantErrs))
+			}
+
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), tt.wantErrs[i]) {
+					t.Errorf("Validate() error %d = %q, want it to contain %q", i, err, tt.wantErrs[i])
+				}
+			}
+		})
+	}
+}
@@ -5,31 +5,46 @@
 package apiserver
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/marmotedu/component-base/pkg/core"
 	"github.com/marmotedu/errors"
+	"github.com/spf13/viper"
 
+	"github.com/marmotedu/iam/internal/apiserver/controller/v1/jwks"
 	"github.com/marmotedu/iam/internal/apiserver/controller/v1/policy"
+	"github.com/marmotedu/iam/internal/apiserver/controller/v1/policygroup"
 	"github.com/marmotedu/iam/internal/apiserver/controller/v1/secret"
+	"github.com/marmotedu/iam/internal/apiserver/controller/v1/serviceaccount"
+	"github.com/marmotedu/iam/internal/apiserver/controller/v1/token"
 	"github.com/marmotedu/iam/internal/apiserver/controller/v1/user"
-	"github.com/marmotedu/iam/internal/apiserver/store/mysql"
+	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/pkg/code"
 	"github.com/marmotedu/iam/internal/pkg/middleware"
 	"github.com/marmotedu/iam/internal/pkg/middleware/auth"
+	"github.com/marmotedu/iam/internal/pkg/middleware/cache"
+	"github.com/marmotedu/iam/internal/pkg/middleware/cors"
 
 	// custom gin validators.
 	_ "github.com/marmotedu/iam/pkg/validator"
 )
 
-func initRouter(g *gin.Engine) {
-	installMiddleware(g)
-	installController(g)
-}
+const (
+	// policyCacheSize is the number of policies cached by each instance.
+	policyCacheSize = 10000
+	// policyCacheTTL bounds the staleness of the cached policies, if their change notification is lost.
+	policyCacheTTL = 30 * time.Second
+	// batchBodyBytes is the maximum size of the requests of the batch apis, e.g. setting the policies of
+	// a policy group.
+	batchBodyBytes = 8 << 20
+)
 
-func installMiddleware(g *gin.Engine) {
+func initRouter(g *gin.Engine, policyCache cache.CacheStore) {
+	installController(g, policyCache)
 }
 
-func installController(g *gin.Engine) *gin.Engine {
+func installController(g *gin.Engine, policyCache cache.CacheStore) *gin.Engine {
 	// Middlewares.
 	jwtStrategy, _ := newJWTAuth().(auth.JWTStrategy)
 	g.POST("/login", jwtStrategy.LoginHandler)
@@ -37,33 +52,55 @@ func installController(g *gin.Engine) *gin.Engine {
 	// Refresh time can be longer than token timeout
 	g.POST("/refresh", jwtStrategy.RefreshHandler)
 
+	// public keys used to verify the jwt tokens, it requires no authentication.
+	keyController := jwks.NewKeyController(keyRotator)
+	g.GET("/.well-known/jwks.json", keyController.JWKS)
+
 	auto := newAutoAuth()
 	g.NoRoute(auto.AuthFunc(), func(c *gin.Context) {
 		core.WriteResponse(c, errors.WithCode(code.ErrPageNotFound, "Page not found."), nil)
 	})
 
 	// v1 handlers, requiring authentication
-	storeIns, _ := mysql.GetMySQLFactoryOr(nil)
+	storeIns := store.Client()
 	v1 := g.Group("/v1")
 	{
 		// user RESTful resource
 		userv1 := v1.Group("/users")
 		{
 			userController := user.NewUserController(storeIns)
+			// the admin apis only accept the cross-origin requests of the admin origins, never *.
+			admin := cors.AllowOrigins(viper.GetStringSlice("server.admin-allowed-origins")...)
 
 			userv1.POST("", userController.Create)
 			userv1.Use(auto.AuthFunc(), middleware.Validation())
 			// v1.PUT("/find_password", userController.FindPassword)
-			userv1.DELETE("", userController.DeleteCollection) // admin api
-			userv1.DELETE(":name", userController.Delete)      // admin api
+			userv1.DELETE("", admin, userController.DeleteCollection) // admin api
+			userv1.DELETE(":name", admin, userController.Delete)      // admin api
 			userv1.PUT(":name/change-password", userController.ChangePassword)
 			userv1.PUT(":name", userController.Update)
-			userv1.GET("", userController.List)
-			userv1.GET(":name", userController.Get) // admin api
+			userv1.GET("", admin, userController.List) // admin api
+			userv1.GET(":name", userController.Get)    // admin api
+			userv1.GET(":name/tokens", userController.ListTokens)
 		}
 
+		// service tokens, the service accounts authenticate with their api key
+		tokenController := token.NewTokenController(storeIns, newServiceTokenSigner())
+		v1.POST("/token/service", tokenController.IssueService)
+
 		v1.Use(auto.AuthFunc())
 
+		// service account RESTful resource, admin api
+		serviceAccountv1 := v1.Group("/service-accounts", middleware.Validation())
+		{
+			serviceAccountController := serviceaccount.NewServiceAccountController(storeIns)
+			admin := cors.AllowOrigins(viper.GetStringSlice("server.admin-allowed-origins")...)
+
+			serviceAccountv1.POST("", admin, serviceAccountController.Create)
+			serviceAccountv1.DELETE(":name", admin, serviceAccountController.Delete)
+			serviceAccountv1.GET("", admin, serviceAccountController.List)
+		}
+
 		// policy RESTful resource
 		policyv1 := v1.Group("/policies", middleware.Publish())
 		{
@@ -74,7 +111,7 @@ func installController(g *gin.Engine) *gin.Engine {
 			policyv1.DELETE(":name", policyController.Delete)
 			policyv1.PUT(":name", policyController.Update)
 			policyv1.GET("", policyController.List)
-			policyv1.GET(":name", policyController.Get)
+			policyv1.GET(":name", cache.ResponseCache(policyCache, policyCacheTTL, policy.CacheKey), policyController.Get)
 		}
 
 		// secret RESTful resource
@@ -87,7 +124,27 @@ func installController(g *gin.Engine) *gin.Engine {
 			secretv1.PUT(":name", secretController.Update)
 			secretv1.GET("", secretController.List)
 			secretv1.GET(":name", secretController.Get)
+			secretv1.POST(":name/share", secretController.Share)
+			secretv1.GET("shared-with-me", secretController.ListSharedWithMe)
+		}
+
+		// policy group RESTful resource
+		policyGroupv1 := v1.Group("/policy-groups", middleware.Publish())
+		{
+			policyGroupController := policygroup.NewPolicyGroupController(storeIns)
+			batchBody := middleware.BodyLimit(batchBodyBytes)
+
+			policyGroupv1.POST("", batchBody, policyGroupController.Create)
+			policyGroupv1.DELETE(":name", policyGroupController.Delete)
+			policyGroupv1.PUT(":name", policyGroupController.Update)
+			policyGroupv1.PUT(":name/policies", batchBody, policyGroupController.SetPolicies)
+			policyGroupv1.POST(":name/apply", policyGroupController.Apply)
+			policyGroupv1.GET("", policyGroupController.List)
+			policyGroupv1.GET(":name", policyGroupController.Get)
 		}
+
+		// jwt signing keys
+		v1.GET("/jwt/keys", keyController.List)
 	}
 
 	return g
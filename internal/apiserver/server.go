@@ -6,22 +6,35 @@ package apiserver
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"sync"
+	"time"
 
 	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
 
+	watchpb "github.com/marmotedu/iam/api/proto/apiserver/v1"
 	"github.com/marmotedu/iam/internal/apiserver/config"
 	cachev1 "github.com/marmotedu/iam/internal/apiserver/controller/v1/cache"
+	"github.com/marmotedu/iam/internal/apiserver/controller/v1/policy"
+	"github.com/marmotedu/iam/internal/apiserver/options"
 	"github.com/marmotedu/iam/internal/apiserver/store"
-	"github.com/marmotedu/iam/internal/apiserver/store/mysql"
+	"github.com/marmotedu/iam/internal/pkg/grpcauth"
+	"github.com/marmotedu/iam/internal/pkg/grpcquota"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+	"github.com/marmotedu/iam/internal/pkg/middleware/cache"
 	genericoptions "github.com/marmotedu/iam/internal/pkg/options"
 	genericapiserver "github.com/marmotedu/iam/internal/pkg/server"
+	"github.com/marmotedu/iam/pkg/app"
 	"github.com/marmotedu/iam/pkg/log"
 	"github.com/marmotedu/iam/pkg/shutdown"
 	"github.com/marmotedu/iam/pkg/shutdown/shutdownmanagers/posixsignal"
+	"github.com/marmotedu/iam/pkg/shutdown/shutdownmanagers/prestop"
 	"github.com/marmotedu/iam/pkg/storage"
 )
 
@@ -30,6 +43,15 @@ type apiServer struct {
 	redisOptions     *genericoptions.RedisOptions
 	gRPCAPIServer    *grpcAPIServer
 	genericAPIServer *genericapiserver.GenericAPIServer
+	policyCache      cache.CacheStore
+	// routes reports the error rates of the routes of genericAPIServer.
+	routes *genericapiserver.InstrumentedEngine
+	// reloader applies the configuration changes on SIGHUP, and on the changes of the configuration file
+	// if watchConfig is set.
+	reloader    *app.Reloader
+	watchConfig bool
+	// datastore is the database engine of the store, mysql, postgres or sqlite.
+	datastore string
 }
 
 type preparedAPIServer struct {
@@ -38,16 +60,27 @@ type preparedAPIServer struct {
 
 // ExtraConfig defines extra configuration for the iam-apiserver.
 type ExtraConfig struct {
-	Addr         string
-	MaxMsgSize   int
-	ServerCert   genericoptions.GeneratableKeyCert
-	mysqlOptions *genericoptions.MySQLOptions
+	Addr                 string
+	MaxRecvMsgSize       int
+	MaxSendMsgSize       int
+	MaxConcurrentStreams uint32
+	Tokens               []string
+	ServerCert           genericoptions.GeneratableKeyCert
+	TLSConfig            *tls.Config
+	datastoreOptions     *genericoptions.DatastoreOptions
+	mysqlOptions         *genericoptions.MySQLOptions
+	postgresOptions      *genericoptions.PostgresOptions
+	sqliteOptions        *genericoptions.SqliteOptions
 	// etcdOptions      *genericoptions.EtcdOptions
 }
 
 func createAPIServer(cfg *config.Config) (*apiServer, error) {
 	gs := shutdown.New()
 	gs.AddShutdownManager(posixsignal.NewPosixSignalManager())
+	gs.SetErrorHandler(shutdown.ErrorFunc(func(err error) {
+		log.Warnf("Shutdown error: %s", err.Error())
+	}))
+	gs.SetTimeout(cfg.GenericServerRunOptions.GracefulShutdownTimeout)
 
 	genericConfig, err := buildGenericConfig(cfg)
 	if err != nil {
@@ -59,10 +92,32 @@ func createAPIServer(cfg *config.Config) (*apiServer, error) {
 		return nil, err
 	}
 
+	// the continue tokens of the lists are signed with a key derived from the jwt key, shared by the
+	// instances of the apiserver, for a token returned by one instance to be accepted by the others.
+	store.SetCursorKey(cfg.JwtOptions.Key)
+
+	// the database is migrated, or checked, before its store is opened by the grpc server.
+	if err := migrateStore(cfg.DatastoreOptions, cfg.MySQLOptions, cfg.PostgresOptions, cfg.SqliteOptions); err != nil {
+		return nil, err
+	}
+
 	genericServer, err := genericConfig.Complete().New()
 	if err != nil {
 		return nil, err
 	}
+
+	// the routes installed by PrepareRun report their error rates.
+	var registerer prometheus.Registerer
+	if cfg.FeatureOptions.EnableMetrics {
+		registerer = prometheus.DefaultRegisterer
+	}
+	routes := genericapiserver.NewInstrumentedEngine(genericServer.Engine, registerer)
+
+	// the preStop hook marks the server not ready before SIGTERM, for the traffic to be drained first.
+	if cfg.FeatureOptions.EnablePreStop {
+		gs.AddShutdownManager(prestop.NewPreStopManager(cfg.FeatureOptions.PreStopAddress,
+			cfg.FeatureOptions.PreStopDelay, genericServer.StopReadiness))
+	}
 	extraServer, err := extraConfig.complete().New()
 	if err != nil {
 		return nil, err
@@ -71,33 +126,109 @@ func createAPIServer(cfg *config.Config) (*apiServer, error) {
 	server := &apiServer{
 		gs:               gs,
 		redisOptions:     cfg.RedisOptions,
+		datastore:        cfg.DatastoreOptions.Engine,
 		genericAPIServer: genericServer,
+		routes:           routes,
 		gRPCAPIServer:    extraServer,
+		reloader:         app.NewReloader(),
+		watchConfig:      cfg.GenericServerRunOptions.WatchConfig,
 	}
 
+	// the store of the grpc server was created by extraConfig.complete().New().
+	runHealthCheck(gs, cfg.DatastoreOptions.Engine, cfg.MySQLOptions)
+
 	return server, nil
 }
 
 func (s *apiServer) PrepareRun() preparedAPIServer {
-	initRouter(s.genericAPIServer.Engine)
+	s.initKeyRotator()
+
+	// the requests rejected by the open circuit breakers of the store can be retried once it is half-open,
+	// the sqlite store has none.
+	if timeout := viper.GetDuration(s.datastore + ".circuit-breaker-timeout"); timeout > 0 {
+		s.genericAPIServer.Use(middleware.RetryAfter(timeout))
+	}
+	// the policies are cached by each instance, until their change is notified.
+	s.policyCache = cache.NewLRUStore(policyCacheSize)
+	initRouter(s.genericAPIServer.Engine, s.policyCache)
 
 	s.initRedisStore()
 
-	s.gs.AddShutdownCallback(shutdown.ShutdownFunc(func(string) error {
-		mysqlStore, _ := mysql.GetMySQLFactoryOr(nil)
-		if mysqlStore != nil {
-			_ = mysqlStore.Close()
-		}
+	s.genericAPIServer.AddHealthzCheck(s.datastore, pingStore(s.datastore))
+	s.genericAPIServer.AddHealthzCheck("redis", storage.HealthCheck)
+	s.genericAPIServer.AddReadyzCheck("redis-ping", (&storage.RedisCluster{}).Ping)
+
+	// the new configurations are validated as on startup before they are applied.
+	s.reloader.AddValidator(app.ValidateOptions(func() app.CliOptions { return options.NewOptions() }))
+
+	// the certificates of the https and grpc servers are renewed together.
+	s.genericAPIServer.AddReloadHooks(s.reloader)
+	s.reloader.AddReloadHook(s.gRPCAPIServer.ReloadCertificate)
+
+	// the rotations of the secret files are reloaded, the new mysql password is applied to the new
+	// connections while the other secrets require a restart.
+	s.reloader.AddSecretFiles("mysql.password-file", "postgres.password-file", "redis.password-file",
+		"redis.sentinel-password-file", "jwt.key-file", "grpc.tokens-file")
+	if s.datastore == genericoptions.DatastoreEngineMySQL {
+		s.reloader.AddReloadHook(reloadMySQLPassword, "mysql.password", "mysql.password-file")
+	}
 
-		s.gRPCAPIServer.Close()
-		s.genericAPIServer.Close()
+	s.gs.AddShutdownCallbackCtx("reloader", shutdown.ShutdownFuncCtx(func(context.Context, string) error {
+		s.reloader.Stop()
 
 		return nil
-	}))
+	}), shutdown.PriorityStopReadiness)
+
+	s.gs.AddShutdownCallbackCtx("stop readiness", s.genericAPIServer.StopReadinessCallback(),
+		shutdown.PriorityStopReadiness)
+
+	s.gs.AddShutdownCallbackCtx("drain", shutdown.ShutdownFuncCtx(func(ctx context.Context, _ string) error {
+		s.drain(ctx)
+
+		return nil
+	}), shutdown.PriorityDrain)
+
+	// the in-flight requests are drained before closing the stores they use.
+	s.gs.AddShutdownCallbackCtx(s.datastore, shutdown.ShutdownFuncCtx(func(context.Context, string) error {
+		if storeIns := store.Client(); storeIns != nil {
+			return storeIns.Close()
+		}
+
+		return nil
+	}), shutdown.PriorityCloseStores)
 
 	return preparedAPIServer{s}
 }
 
+// drain gracefully shuts down the http and grpc servers concurrently, within the shutdown timeout
+// and the deadline of ctx.
+func (s *apiServer) drain(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, s.genericAPIServer.ShutdownTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		if err := s.genericAPIServer.Shutdown(ctx); err != nil {
+			log.Warnf("Failed to drain the in-flight http requests: %s", err.Error())
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		if err := s.gRPCAPIServer.Shutdown(ctx); err != nil {
+			log.Warnf("Failed to drain the in-flight grpc requests: %s", err.Error())
+		}
+	}()
+
+	wg.Wait()
+}
+
 func (s preparedAPIServer) Run() error {
 	go s.gRPCAPIServer.Run()
 
@@ -106,6 +237,14 @@ func (s preparedAPIServer) Run() error {
 		log.Fatalf("start shutdown manager failed: %s", err.Error())
 	}
 
+	s.reloader.Start()
+
+	if s.watchConfig {
+		if err := s.reloader.Watch(); err != nil {
+			log.Warnf("Failed to watch the configuration file, it is reloaded on SIGHUP only: %s", err.Error())
+		}
+	}
+
 	return s.genericAPIServer.Run()
 }
 
@@ -124,14 +263,52 @@ func (c *ExtraConfig) complete() *completedExtraConfig {
 
 // New create a grpcAPIServer instance.
 func (c *completedExtraConfig) New() (*grpcAPIServer, error) {
-	creds, err := credentials.NewServerTLSFromFile(c.ServerCert.CertKey.CertFile, c.ServerCert.CertKey.KeyFile)
+	certificates, err := genericapiserver.NewCertificateProvider(c.ServerCert.CertKey.CertFile, c.ServerCert.CertKey.KeyFile)
 	if err != nil {
 		log.Fatalf("Failed to generate credentials %s", err.Error())
 	}
-	opts := []grpc.ServerOption{grpc.MaxRecvMsgSize(c.MaxMsgSize), grpc.Creds(creds)}
+
+	tlsConfig := c.TLSConfig.Clone()
+	tlsConfig.GetCertificate = certificates.GetCertificate
+	creds := credentials.NewTLS(tlsConfig)
+
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(c.MaxRecvMsgSize),
+		grpc.MaxSendMsgSize(c.MaxSendMsgSize),
+		grpc.MaxConcurrentStreams(c.MaxConcurrentStreams),
+		grpc.Creds(creds),
+	}
+
+	var (
+		unaryInterceptors  []grpc.UnaryServerInterceptor
+		streamInterceptors []grpc.StreamServerInterceptor
+	)
+
+	if len(c.Tokens) > 0 {
+		authenticator := grpcauth.NewTokenAuthenticator(c.Tokens)
+		unaryInterceptors = append(unaryInterceptors, authenticator.UnaryServerInterceptor())
+		streamInterceptors = append(streamInterceptors, authenticator.StreamServerInterceptor())
+		log.Infof("Enable grpc token authentication with %d accepted token(s)", len(c.Tokens))
+	}
+
+	// grpc.MaxConcurrentStreams only limits the streams of a connection, the quota limits
+	// the streams over all the connections.
+	quota := grpcquota.NewStreamQuota(c.MaxConcurrentStreams)
+	grpcquota.RegisterMetrics(quota)
+	unaryInterceptors = append(unaryInterceptors, quota.UnaryServerInterceptor())
+	streamInterceptors = append(streamInterceptors, quota.StreamServerInterceptor())
+
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)
 	grpcServer := grpc.NewServer(opts...)
 
-	storeIns, _ := mysql.GetMySQLFactoryOr(c.mysqlOptions)
+	// the server fails to start without its store, rather than failing each request.
+	storeIns, err := getStoreFactoryOr(c.datastoreOptions.Engine, c.mysqlOptions, c.postgresOptions, c.sqliteOptions)
+	if err != nil {
+		return nil, err
+	}
 	// storeIns, _ := etcd.GetEtcdFactoryOr(c.etcdOptions, nil)
 	store.SetClient(storeIns)
 	cacheIns, err := cachev1.GetCacheInsOr(storeIns)
@@ -141,9 +318,13 @@ func (c *completedExtraConfig) New() (*grpcAPIServer, error) {
 
 	pb.RegisterCacheServer(grpcServer, cacheIns)
 
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	cacheIns.StartWatch(watchCtx)
+	watchpb.RegisterCacheWatchServer(grpcServer, cacheIns)
+
 	reflection.Register(grpcServer)
 
-	return &grpcAPIServer{grpcServer, c.Addr}, nil
+	return &grpcAPIServer{grpcServer, c.Addr, stopWatch, certificates}, nil
 }
 
 func buildGenericConfig(cfg *config.Config) (genericConfig *genericapiserver.Config, lastErr error) {
@@ -156,6 +337,10 @@ func buildGenericConfig(cfg *config.Config) (genericConfig *genericapiserver.Con
 		return
 	}
 
+	if lastErr = cfg.AccessLogOptions.ApplyTo(genericConfig); lastErr != nil {
+		return
+	}
+
 	if lastErr = cfg.SecureServing.ApplyTo(genericConfig); lastErr != nil {
 		return
 	}
@@ -167,41 +352,43 @@ func buildGenericConfig(cfg *config.Config) (genericConfig *genericapiserver.Con
 	return
 }
 
-//nolint: unparam
 func buildExtraConfig(cfg *config.Config) (*ExtraConfig, error) {
+	tlsConfig, err := cfg.SecureServing.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	return &ExtraConfig{
-		Addr:         fmt.Sprintf("%s:%d", cfg.GRPCOptions.BindAddress, cfg.GRPCOptions.BindPort),
-		MaxMsgSize:   cfg.GRPCOptions.MaxMsgSize,
-		ServerCert:   cfg.SecureServing.ServerCert,
-		mysqlOptions: cfg.MySQLOptions,
+		Addr:                 fmt.Sprintf("%s:%d", cfg.GRPCOptions.BindAddress, cfg.GRPCOptions.BindPort),
+		MaxRecvMsgSize:       cfg.GRPCOptions.RecvMsgSize(),
+		MaxSendMsgSize:       cfg.GRPCOptions.MaxSendMsgSize,
+		MaxConcurrentStreams: cfg.GRPCOptions.MaxConcurrentStreams,
+		Tokens:               cfg.GRPCOptions.Tokens,
+		ServerCert:           cfg.SecureServing.ServerCert,
+		TLSConfig:            tlsConfig,
+		datastoreOptions:     cfg.DatastoreOptions,
+		mysqlOptions:         cfg.MySQLOptions,
+		postgresOptions:      cfg.PostgresOptions,
+		sqliteOptions:        cfg.SqliteOptions,
 		// etcdOptions:      cfg.EtcdOptions,
 	}, nil
 }
 
 func (s *apiServer) initRedisStore() {
 	ctx, cancel := context.WithCancel(context.Background())
-	s.gs.AddShutdownCallback(shutdown.ShutdownFunc(func(string) error {
+	s.gs.AddShutdownCallbackCtx("redis", shutdown.ShutdownFuncCtx(func(context.Context, string) error {
 		cancel()
 
 		return nil
-	}))
+	}), shutdown.PriorityCloseStores)
 
-	config := &storage.Config{
-		Host:                  s.redisOptions.Host,
-		Port:                  s.redisOptions.Port,
-		Addrs:                 s.redisOptions.Addrs,
-		MasterName:            s.redisOptions.MasterName,
-		Username:              s.redisOptions.Username,
-		Password:              s.redisOptions.Password,
-		Database:              s.redisOptions.Database,
-		MaxIdle:               s.redisOptions.MaxIdle,
-		MaxActive:             s.redisOptions.MaxActive,
-		Timeout:               s.redisOptions.Timeout,
-		EnableCluster:         s.redisOptions.EnableCluster,
-		UseSSL:                s.redisOptions.UseSSL,
-		SSLInsecureSkipVerify: s.redisOptions.SSLInsecureSkipVerify,
-	}
+	config := s.redisOptions.StorageConfig()
 
 	// try to connect to redis
 	go storage.ConnectToRedis(ctx, config)
+	go storage.LogHealth(ctx, time.Minute)
+
+	policy.InvalidateCache(ctx, s.policyCache)
+
+	storage.RegisterMetrics()
 }
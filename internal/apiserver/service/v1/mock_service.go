@@ -3,7 +3,7 @@
 // license that can be found in the LICENSE file.
 
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/marmotedu/iam/internal/apiserver/service/v1 (interfaces: Service,UserSrv,SecretSrv,PolicySrv)
+// Source: github.com/marmotedu/iam/internal/apiserver/service/v1 (interfaces: Service,UserSrv,SecretSrv,PolicySrv,SecretShareSrv,PolicyGroupSrv,UserSessionSrv,ServiceAccountSrv)
 
 // Package v1 is a generated GoMock package.
 package v1
@@ -11,10 +11,12 @@ package v1
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	v1 "github.com/marmotedu/api/apiserver/v1"
 	v10 "github.com/marmotedu/component-base/pkg/meta/v1"
+	v11 "github.com/marmotedu/iam/internal/apiserver/model/v1"
 )
 
 // MockService is a mock of Service interface.
@@ -54,6 +56,34 @@ func (mr *MockServiceMockRecorder) Policies() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Policies", reflect.TypeOf((*MockService)(nil).Policies))
 }
 
+// PolicyGroups mocks base method.
+func (m *MockService) PolicyGroups() PolicyGroupSrv {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PolicyGroups")
+	ret0, _ := ret[0].(PolicyGroupSrv)
+	return ret0
+}
+
+// PolicyGroups indicates an expected call of PolicyGroups.
+func (mr *MockServiceMockRecorder) PolicyGroups() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PolicyGroups", reflect.TypeOf((*MockService)(nil).PolicyGroups))
+}
+
+// SecretShares mocks base method.
+func (m *MockService) SecretShares() SecretShareSrv {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SecretShares")
+	ret0, _ := ret[0].(SecretShareSrv)
+	return ret0
+}
+
+// SecretShares indicates an expected call of SecretShares.
+func (mr *MockServiceMockRecorder) SecretShares() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SecretShares", reflect.TypeOf((*MockService)(nil).SecretShares))
+}
+
 // Secrets mocks base method.
 func (m *MockService) Secrets() SecretSrv {
 	m.ctrl.T.Helper()
@@ -68,6 +98,34 @@ func (mr *MockServiceMockRecorder) Secrets() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Secrets", reflect.TypeOf((*MockService)(nil).Secrets))
 }
 
+// ServiceAccounts mocks base method.
+func (m *MockService) ServiceAccounts() ServiceAccountSrv {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ServiceAccounts")
+	ret0, _ := ret[0].(ServiceAccountSrv)
+	return ret0
+}
+
+// ServiceAccounts indicates an expected call of ServiceAccounts.
+func (mr *MockServiceMockRecorder) ServiceAccounts() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ServiceAccounts", reflect.TypeOf((*MockService)(nil).ServiceAccounts))
+}
+
+// UserSessions mocks base method.
+func (m *MockService) UserSessions() UserSessionSrv {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserSessions")
+	ret0, _ := ret[0].(UserSessionSrv)
+	return ret0
+}
+
+// UserSessions indicates an expected call of UserSessions.
+func (mr *MockServiceMockRecorder) UserSessions() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserSessions", reflect.TypeOf((*MockService)(nil).UserSessions))
+}
+
 // Users mocks base method.
 func (m *MockService) Users() UserSrv {
 	m.ctrl.T.Helper()
@@ -191,6 +249,21 @@ func (mr *MockUserSrvMockRecorder) List(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockUserSrv)(nil).List), arg0, arg1)
 }
 
+// ListInactive mocks base method.
+func (m *MockUserSrv) ListInactive(arg0 context.Context, arg1 time.Time, arg2 v10.ListOptions) (*v1.UserList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListInactive", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*v1.UserList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListInactive indicates an expected call of ListInactive.
+func (mr *MockUserSrvMockRecorder) ListInactive(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListInactive", reflect.TypeOf((*MockUserSrv)(nil).ListInactive), arg0, arg1, arg2)
+}
+
 // ListWithBadPerformance mocks base method.
 func (m *MockUserSrv) ListWithBadPerformance(arg0 context.Context, arg1 v10.ListOptions) (*v1.UserList, error) {
 	m.ctrl.T.Helper()
@@ -300,6 +373,21 @@ func (mr *MockSecretSrvMockRecorder) Get(arg0, arg1, arg2, arg3 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockSecretSrv)(nil).Get), arg0, arg1, arg2, arg3)
 }
 
+// GetVersioned mocks base method.
+func (m *MockSecretSrv) GetVersioned(arg0 context.Context, arg1, arg2 string, arg3 v10.GetOptions) (*v11.VersionedSecret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVersioned", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*v11.VersionedSecret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVersioned indicates an expected call of GetVersioned.
+func (mr *MockSecretSrvMockRecorder) GetVersioned(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVersioned", reflect.TypeOf((*MockSecretSrv)(nil).GetVersioned), arg0, arg1, arg2, arg3)
+}
+
 // List mocks base method.
 func (m *MockSecretSrv) List(arg0 context.Context, arg1 string, arg2 v10.ListOptions) (*v1.SecretList, error) {
 	m.ctrl.T.Helper()
@@ -329,6 +417,20 @@ func (mr *MockSecretSrvMockRecorder) Update(arg0, arg1, arg2 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockSecretSrv)(nil).Update), arg0, arg1, arg2)
 }
 
+// UpdateVersioned mocks base method.
+func (m *MockSecretSrv) UpdateVersioned(arg0 context.Context, arg1 *v11.VersionedSecret, arg2 v10.UpdateOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateVersioned", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateVersioned indicates an expected call of UpdateVersioned.
+func (mr *MockSecretSrvMockRecorder) UpdateVersioned(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateVersioned", reflect.TypeOf((*MockSecretSrv)(nil).UpdateVersioned), arg0, arg1, arg2)
+}
+
 // MockPolicySrv is a mock of PolicySrv interface.
 type MockPolicySrv struct {
 	ctrl     *gomock.Controller
@@ -409,6 +511,21 @@ func (mr *MockPolicySrvMockRecorder) Get(arg0, arg1, arg2, arg3 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockPolicySrv)(nil).Get), arg0, arg1, arg2, arg3)
 }
 
+// GetVersioned mocks base method.
+func (m *MockPolicySrv) GetVersioned(arg0 context.Context, arg1, arg2 string, arg3 v10.GetOptions) (*v11.VersionedPolicy, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVersioned", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*v11.VersionedPolicy)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVersioned indicates an expected call of GetVersioned.
+func (mr *MockPolicySrvMockRecorder) GetVersioned(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVersioned", reflect.TypeOf((*MockPolicySrv)(nil).GetVersioned), arg0, arg1, arg2, arg3)
+}
+
 // List mocks base method.
 func (m *MockPolicySrv) List(arg0 context.Context, arg1 string, arg2 v10.ListOptions) (*v1.PolicyList, error) {
 	m.ctrl.T.Helper()
@@ -437,3 +554,312 @@ func (mr *MockPolicySrvMockRecorder) Update(arg0, arg1, arg2 interface{}) *gomoc
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockPolicySrv)(nil).Update), arg0, arg1, arg2)
 }
+
+// UpdateVersioned mocks base method.
+func (m *MockPolicySrv) UpdateVersioned(arg0 context.Context, arg1 *v11.VersionedPolicy, arg2 v10.UpdateOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateVersioned", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateVersioned indicates an expected call of UpdateVersioned.
+func (mr *MockPolicySrvMockRecorder) UpdateVersioned(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateVersioned", reflect.TypeOf((*MockPolicySrv)(nil).UpdateVersioned), arg0, arg1, arg2)
+}
+
+// MockSecretShareSrv is a mock of SecretShareSrv interface.
+type MockSecretShareSrv struct {
+	ctrl     *gomock.Controller
+	recorder *MockSecretShareSrvMockRecorder
+}
+
+// MockSecretShareSrvMockRecorder is the mock recorder for MockSecretShareSrv.
+type MockSecretShareSrvMockRecorder struct {
+	mock *MockSecretShareSrv
+}
+
+// NewMockSecretShareSrv creates a new mock instance.
+func NewMockSecretShareSrv(ctrl *gomock.Controller) *MockSecretShareSrv {
+	mock := &MockSecretShareSrv{ctrl: ctrl}
+	mock.recorder = &MockSecretShareSrvMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSecretShareSrv) EXPECT() *MockSecretShareSrvMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockSecretShareSrv) Create(arg0 context.Context, arg1 *v11.SecretShare, arg2 v10.CreateOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockSecretShareSrvMockRecorder) Create(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSecretShareSrv)(nil).Create), arg0, arg1, arg2)
+}
+
+// ListSharedWith mocks base method.
+func (m *MockSecretShareSrv) ListSharedWith(arg0 context.Context, arg1 string, arg2 v10.ListOptions) (*v11.SecretShareList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSharedWith", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*v11.SecretShareList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSharedWith indicates an expected call of ListSharedWith.
+func (mr *MockSecretShareSrvMockRecorder) ListSharedWith(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSharedWith", reflect.TypeOf((*MockSecretShareSrv)(nil).ListSharedWith), arg0, arg1, arg2)
+}
+
+// MockPolicyGroupSrv is a mock of PolicyGroupSrv interface.
+type MockPolicyGroupSrv struct {
+	ctrl     *gomock.Controller
+	recorder *MockPolicyGroupSrvMockRecorder
+}
+
+// MockPolicyGroupSrvMockRecorder is the mock recorder for MockPolicyGroupSrv.
+type MockPolicyGroupSrvMockRecorder struct {
+	mock *MockPolicyGroupSrv
+}
+
+// NewMockPolicyGroupSrv creates a new mock instance.
+func NewMockPolicyGroupSrv(ctrl *gomock.Controller) *MockPolicyGroupSrv {
+	mock := &MockPolicyGroupSrv{ctrl: ctrl}
+	mock.recorder = &MockPolicyGroupSrvMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPolicyGroupSrv) EXPECT() *MockPolicyGroupSrvMockRecorder {
+	return m.recorder
+}
+
+// Apply mocks base method.
+func (m *MockPolicyGroupSrv) Apply(arg0 context.Context, arg1 string, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Apply", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Apply indicates an expected call of Apply.
+func (mr *MockPolicyGroupSrvMockRecorder) Apply(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Apply", reflect.TypeOf((*MockPolicyGroupSrv)(nil).Apply), arg0, arg1, arg2)
+}
+
+// Create mocks base method.
+func (m *MockPolicyGroupSrv) Create(arg0 context.Context, arg1 *v11.PolicyGroup, arg2 v10.CreateOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockPolicyGroupSrvMockRecorder) Create(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockPolicyGroupSrv)(nil).Create), arg0, arg1, arg2)
+}
+
+// Delete mocks base method.
+func (m *MockPolicyGroupSrv) Delete(arg0 context.Context, arg1 string, arg2 string, arg3 v10.DeleteOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockPolicyGroupSrvMockRecorder) Delete(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockPolicyGroupSrv)(nil).Delete), arg0, arg1, arg2, arg3)
+}
+
+// Get mocks base method.
+func (m *MockPolicyGroupSrv) Get(arg0 context.Context, arg1 string, arg2 string, arg3 v10.GetOptions) (*v11.PolicyGroup, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*v11.PolicyGroup)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockPolicyGroupSrvMockRecorder) Get(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockPolicyGroupSrv)(nil).Get), arg0, arg1, arg2, arg3)
+}
+
+// List mocks base method.
+func (m *MockPolicyGroupSrv) List(arg0 context.Context, arg1 string, arg2 v10.ListOptions) (*v11.PolicyGroupList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*v11.PolicyGroupList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockPolicyGroupSrvMockRecorder) List(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockPolicyGroupSrv)(nil).List), arg0, arg1, arg2)
+}
+
+// Update mocks base method.
+func (m *MockPolicyGroupSrv) Update(arg0 context.Context, arg1 *v11.PolicyGroup, arg2 v10.UpdateOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockPolicyGroupSrvMockRecorder) Update(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockPolicyGroupSrv)(nil).Update), arg0, arg1, arg2)
+}
+
+// MockUserSessionSrv is a mock of UserSessionSrv interface.
+type MockUserSessionSrv struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserSessionSrvMockRecorder
+}
+
+// MockUserSessionSrvMockRecorder is the mock recorder for MockUserSessionSrv.
+type MockUserSessionSrvMockRecorder struct {
+	mock *MockUserSessionSrv
+}
+
+// NewMockUserSessionSrv creates a new mock instance.
+func NewMockUserSessionSrv(ctrl *gomock.Controller) *MockUserSessionSrv {
+	mock := &MockUserSessionSrv{ctrl: ctrl}
+	mock.recorder = &MockUserSessionSrvMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserSessionSrv) EXPECT() *MockUserSessionSrvMockRecorder {
+	return m.recorder
+}
+
+// ListActive mocks base method.
+func (m *MockUserSessionSrv) ListActive(arg0 context.Context, arg1 string, arg2 v10.ListOptions) (*v11.UserSessionList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListActive", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*v11.UserSessionList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListActive indicates an expected call of ListActive.
+func (mr *MockUserSessionSrvMockRecorder) ListActive(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListActive", reflect.TypeOf((*MockUserSessionSrv)(nil).ListActive), arg0, arg1, arg2)
+}
+
+// MockServiceAccountSrv is a mock of ServiceAccountSrv interface.
+type MockServiceAccountSrv struct {
+	ctrl     *gomock.Controller
+	recorder *MockServiceAccountSrvMockRecorder
+}
+
+// MockServiceAccountSrvMockRecorder is the mock recorder for MockServiceAccountSrv.
+type MockServiceAccountSrvMockRecorder struct {
+	mock *MockServiceAccountSrv
+}
+
+// NewMockServiceAccountSrv creates a new mock instance.
+func NewMockServiceAccountSrv(ctrl *gomock.Controller) *MockServiceAccountSrv {
+	mock := &MockServiceAccountSrv{ctrl: ctrl}
+	mock.recorder = &MockServiceAccountSrvMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockServiceAccountSrv) EXPECT() *MockServiceAccountSrvMockRecorder {
+	return m.recorder
+}
+
+// Authenticate mocks base method.
+func (m *MockServiceAccountSrv) Authenticate(arg0 context.Context, arg1 string, arg2 string) (*v11.ServiceAccount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Authenticate", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*v11.ServiceAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Authenticate indicates an expected call of Authenticate.
+func (mr *MockServiceAccountSrvMockRecorder) Authenticate(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authenticate", reflect.TypeOf((*MockServiceAccountSrv)(nil).Authenticate), arg0, arg1, arg2)
+}
+
+// Create mocks base method.
+func (m *MockServiceAccountSrv) Create(arg0 context.Context, arg1 *v11.ServiceAccount, arg2 v10.CreateOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockServiceAccountSrvMockRecorder) Create(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockServiceAccountSrv)(nil).Create), arg0, arg1, arg2)
+}
+
+// Delete mocks base method.
+func (m *MockServiceAccountSrv) Delete(arg0 context.Context, arg1 string, arg2 v10.DeleteOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockServiceAccountSrvMockRecorder) Delete(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockServiceAccountSrv)(nil).Delete), arg0, arg1, arg2)
+}
+
+// Get mocks base method.
+func (m *MockServiceAccountSrv) Get(arg0 context.Context, arg1 string, arg2 v10.GetOptions) (*v11.ServiceAccount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*v11.ServiceAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockServiceAccountSrvMockRecorder) Get(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockServiceAccountSrv)(nil).Get), arg0, arg1, arg2)
+}
+
+// List mocks base method.
+func (m *MockServiceAccountSrv) List(arg0 context.Context, arg1 v10.ListOptions) (*v11.ServiceAccountList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0, arg1)
+	ret0, _ := ret[0].(*v11.ServiceAccountList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockServiceAccountSrvMockRecorder) List(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockServiceAccountSrv)(nil).List), arg0, arg1)
+}
@@ -11,6 +11,7 @@ import (
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 	"github.com/marmotedu/errors"
 
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
 	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/pkg/code"
 )
@@ -19,9 +20,12 @@ import (
 type PolicySrv interface {
 	Create(ctx context.Context, policy *v1.Policy, opts metav1.CreateOptions) error
 	Update(ctx context.Context, policy *v1.Policy, opts metav1.UpdateOptions) error
+	// UpdateVersioned fails with code.ErrResourceVersionConflict if the policy changed since it was read.
+	UpdateVersioned(ctx context.Context, policy *modelv1.VersionedPolicy, opts metav1.UpdateOptions) error
 	Delete(ctx context.Context, username string, name string, opts metav1.DeleteOptions) error
 	DeleteCollection(ctx context.Context, username string, names []string, opts metav1.DeleteOptions) error
 	Get(ctx context.Context, username string, name string, opts metav1.GetOptions) (*v1.Policy, error)
+	GetVersioned(ctx context.Context, username string, name string, opts metav1.GetOptions) (*modelv1.VersionedPolicy, error)
 	List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.PolicyList, error)
 }
 
@@ -52,6 +56,22 @@ func (s *policyService) Update(ctx context.Context, policy *v1.Policy, opts meta
 	return nil
 }
 
+func (s *policyService) UpdateVersioned(
+	ctx context.Context,
+	policy *modelv1.VersionedPolicy,
+	opts metav1.UpdateOptions,
+) error {
+	if err := s.store.Policies().UpdateVersioned(ctx, policy, opts); err != nil {
+		if errors.IsCode(err, code.ErrResourceVersionConflict) {
+			return err
+		}
+
+		return errors.WithCode(code.ErrDatabase, err.Error())
+	}
+
+	return nil
+}
+
 func (s *policyService) Delete(ctx context.Context, username, name string, opts metav1.DeleteOptions) error {
 	if err := s.store.Policies().Delete(ctx, username, name, opts); err != nil {
 		return err
@@ -82,6 +102,19 @@ func (s *policyService) Get(ctx context.Context, username, name string, opts met
 	return policy, nil
 }
 
+func (s *policyService) GetVersioned(
+	ctx context.Context,
+	username, name string,
+	opts metav1.GetOptions,
+) (*modelv1.VersionedPolicy, error) {
+	policy, err := s.store.Policies().GetVersioned(ctx, username, name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
 func (s *policyService) List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.PolicyList, error) {
 	policies, err := s.store.Policies().List(ctx, username, opts)
 	if err != nil {
@@ -0,0 +1,127 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package v1
+
+import (
+	"context"
+
+	"github.com/AlekSi/pointer"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+// PolicyGroupSrv defines functions used to handle policy group request.
+type PolicyGroupSrv interface {
+	Create(ctx context.Context, group *v1.PolicyGroup, opts metav1.CreateOptions) error
+	Update(ctx context.Context, group *v1.PolicyGroup, opts metav1.UpdateOptions) error
+	Delete(ctx context.Context, username, name string, opts metav1.DeleteOptions) error
+	Get(ctx context.Context, username, name string, opts metav1.GetOptions) (*v1.PolicyGroup, error)
+	List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.PolicyGroupList, error)
+	Apply(ctx context.Context, username, name string) error
+}
+
+type policyGroupService struct {
+	store store.Factory
+}
+
+var _ PolicyGroupSrv = (*policyGroupService)(nil)
+
+func newPolicyGroups(srv *service) *policyGroupService {
+	return &policyGroupService{store: srv.store}
+}
+
+// Create creates the policy group, its member policies must exist and belong to no other group.
+func (s *policyGroupService) Create(ctx context.Context, group *v1.PolicyGroup, opts metav1.CreateOptions) error {
+	if err := s.checkMembers(ctx, group); err != nil {
+		return err
+	}
+
+	if err := s.store.PolicyGroups().Create(ctx, group, opts); err != nil {
+		return errors.WithCode(code.ErrDatabase, err.Error())
+	}
+
+	return nil
+}
+
+// Update updates the policy group, its member policies must exist and belong to no other group.
+func (s *policyGroupService) Update(ctx context.Context, group *v1.PolicyGroup, opts metav1.UpdateOptions) error {
+	if err := s.checkMembers(ctx, group); err != nil {
+		return err
+	}
+
+	if err := s.store.PolicyGroups().Update(ctx, group, opts); err != nil {
+		return errors.WithCode(code.ErrDatabase, err.Error())
+	}
+
+	return nil
+}
+
+// Delete deletes the policy group together with its member policies.
+func (s *policyGroupService) Delete(ctx context.Context, username, name string, opts metav1.DeleteOptions) error {
+	return s.store.PolicyGroups().Delete(ctx, username, name, opts)
+}
+
+func (s *policyGroupService) Get(
+	ctx context.Context,
+	username, name string,
+	opts metav1.GetOptions,
+) (*v1.PolicyGroup, error) {
+	return s.store.PolicyGroups().Get(ctx, username, name, opts)
+}
+
+func (s *policyGroupService) List(
+	ctx context.Context,
+	username string,
+	opts metav1.ListOptions,
+) (*v1.PolicyGroupList, error) {
+	groups, err := s.store.PolicyGroups().List(ctx, username, opts)
+	if err != nil {
+		return nil, errors.WithCode(code.ErrDatabase, err.Error())
+	}
+
+	return groups, nil
+}
+
+// Apply activates the member policies of the policy group at once.
+func (s *policyGroupService) Apply(ctx context.Context, username, name string) error {
+	return s.store.PolicyGroups().Apply(ctx, username, name)
+}
+
+// checkMembers checks that the member policies of the group exist and are not members of
+// another group of the user.
+func (s *policyGroupService) checkMembers(ctx context.Context, group *v1.PolicyGroup) error {
+	for _, id := range group.PolicyIDs {
+		if _, err := s.store.Policies().Get(ctx, group.Username, id, metav1.GetOptions{}); err != nil {
+			return err
+		}
+	}
+
+	groups, err := s.store.PolicyGroups().List(ctx, group.Username, metav1.ListOptions{
+		Offset: pointer.ToInt64(0),
+		Limit:  pointer.ToInt64(-1),
+	})
+	if err != nil {
+		return errors.WithCode(code.ErrDatabase, err.Error())
+	}
+
+	for _, other := range groups.Items {
+		if other.Name == group.Name {
+			continue
+		}
+
+		for _, id := range group.PolicyIDs {
+			if other.HasPolicy(id) {
+				return errors.WithCode(code.ErrPolicyInOtherGroup,
+					"policy %s already belongs to policy group %s", id, other.Name)
+			}
+		}
+	}
+
+	return nil
+}
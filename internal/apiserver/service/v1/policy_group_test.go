@@ -0,0 +1,145 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package v1
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store/fake"
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+func Test_policyGroupService_Lifecycle(t *testing.T) {
+	// the policies of user911 are changed, the store is not shared with the other tests.
+	factory := fake.NewFakeFactory()
+
+	ctx := context.TODO()
+	srv := NewService(factory)
+	groups := srv.PolicyGroups()
+
+	// user911 owns policy911, policy911-b and policy911-c.
+	username := "user911"
+	for _, name := range []string{"policy911-b", "policy911-c"} {
+		policy := &v1.Policy{ObjectMeta: metav1.ObjectMeta{Name: name}, Username: username}
+		if err := factory.Policies().Create(ctx, policy, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	group := func(name string, policyIDs ...string) *modelv1.PolicyGroup {
+		return &modelv1.PolicyGroup{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Username:   username,
+			PolicyIDs:  policyIDs,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		run      func() error
+		wantCode int
+	}{
+		{
+			name: "create with unknown policy",
+			run: func() error {
+				return groups.Create(ctx, group("group1", "policy911", "unknown"), metav1.CreateOptions{})
+			},
+			wantCode: code.ErrPolicyNotFound,
+		},
+		{
+			name: "create with policy of other user",
+			run: func() error {
+				return groups.Create(ctx, group("group1", "policy912"), metav1.CreateOptions{})
+			},
+			wantCode: code.ErrPolicyNotFound,
+		},
+		{
+			name: "create",
+			run: func() error {
+				return groups.Create(ctx, group("group1", "policy911"), metav1.CreateOptions{})
+			},
+		},
+		{
+			name: "create with policy of other group",
+			run: func() error {
+				return groups.Create(ctx, group("group2", "policy911"), metav1.CreateOptions{})
+			},
+			wantCode: code.ErrPolicyInOtherGroup,
+		},
+		{
+			name: "update membership",
+			run: func() error {
+				return groups.Update(ctx, group("group1", "policy911", "policy911-b"), metav1.UpdateOptions{})
+			},
+		},
+		{
+			name: "apply",
+			run: func() error {
+				return groups.Apply(ctx, username, "group1")
+			},
+		},
+		{
+			name: "apply with deleted policy",
+			run: func() error {
+				if err := groups.Create(ctx, group("group2", "policy911-c"), metav1.CreateOptions{}); err != nil {
+					return err
+				}
+
+				if err := srv.Policies().Delete(ctx, username, "policy911-c", metav1.DeleteOptions{}); err != nil {
+					return err
+				}
+
+				return groups.Apply(ctx, username, "group2")
+			},
+			wantCode: code.ErrPolicyNotFound,
+		},
+		{
+			name: "apply unknown group",
+			run: func() error {
+				return groups.Apply(ctx, username, "unknown")
+			},
+			wantCode: code.ErrPolicyGroupNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			if tt.wantCode == 0 && err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if tt.wantCode != 0 && !errors.IsCode(err, tt.wantCode) {
+				t.Fatalf("error = %v, wantCode %d", err, tt.wantCode)
+			}
+		})
+	}
+
+	got, err := groups.Get(ctx, username, "group1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if want := []string{"policy911", "policy911-b"}; !got.Applied || !reflect.DeepEqual(got.PolicyIDs, want) {
+		t.Fatalf("Get() = %+v, want applied group of %v", got, want)
+	}
+
+	// deleting the group deletes its member policies.
+	if err := groups.Delete(ctx, username, "group1", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := groups.Get(ctx, username, "group1", metav1.GetOptions{}); !errors.IsCode(err, code.ErrPolicyGroupNotFound) {
+		t.Fatalf("Get() error = %v, wantCode %d", err, code.ErrPolicyGroupNotFound)
+	}
+	for _, name := range []string{"policy911", "policy911-b"} {
+		if _, err := srv.Policies().Get(ctx, username, name, metav1.GetOptions{}); !errors.IsCode(err, code.ErrPolicyNotFound) {
+			t.Errorf("Get(%s) error = %v, wantCode %d", name, err, code.ErrPolicyNotFound)
+		}
+	}
+}
@@ -12,10 +12,13 @@ import (
 	gomock "github.com/golang/mock/gomock"
 	v1 "github.com/marmotedu/api/apiserver/v1"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
 	"github.com/stretchr/testify/suite"
 
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
 	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/apiserver/store/fake"
+	"github.com/marmotedu/iam/internal/pkg/code"
 )
 
 type Suite struct {
@@ -370,3 +373,63 @@ func Test_newPolicies(t *testing.T) {
 		})
 	}
 }
+
+func Test_policyService_UpdateVersioned(t *testing.T) {
+	factory, err := fake.GetFakeFactoryOr()
+	if err != nil {
+		t.Fatalf("GetFakeFactoryOr() error = %v", err)
+	}
+
+	ctx := context.TODO()
+	policies := NewService(factory).Policies()
+
+	// Two clients read policy913, then both update it.
+	get := func() *modelv1.VersionedPolicy {
+		policy, err := policies.GetVersioned(ctx, "user913", "policy913", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("GetVersioned() error = %v", err)
+		}
+
+		return policy
+	}
+	first, second := get(), get()
+
+	tests := []struct {
+		name        string
+		policy      func() *modelv1.VersionedPolicy
+		wantCode    int
+		wantVersion uint64
+	}{
+		{
+			name:        "first update",
+			policy:      func() *modelv1.VersionedPolicy { return first },
+			wantVersion: second.ResourceVersion + 1,
+		},
+		{
+			name:        "concurrent update",
+			policy:      func() *modelv1.VersionedPolicy { return second },
+			wantCode:    code.ErrResourceVersionConflict,
+			wantVersion: second.ResourceVersion,
+		},
+		{
+			name:        "update after read again",
+			policy:      get,
+			wantVersion: second.ResourceVersion + 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := tt.policy()
+			err := policies.UpdateVersioned(ctx, policy, metav1.UpdateOptions{})
+			if tt.wantCode == 0 && err != nil {
+				t.Fatalf("UpdateVersioned() error = %v", err)
+			}
+			if tt.wantCode != 0 && !errors.IsCode(err, tt.wantCode) {
+				t.Fatalf("UpdateVersioned() error = %v, wantCode %d", err, tt.wantCode)
+			}
+			if policy.ResourceVersion != tt.wantVersion {
+				t.Errorf("ResourceVersion = %d, want %d", policy.ResourceVersion, tt.wantVersion)
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 	"github.com/marmotedu/errors"
 
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
 	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/pkg/code"
 )
@@ -19,9 +20,12 @@ import (
 type SecretSrv interface {
 	Create(ctx context.Context, secret *v1.Secret, opts metav1.CreateOptions) error
 	Update(ctx context.Context, secret *v1.Secret, opts metav1.UpdateOptions) error
+	// UpdateVersioned fails with code.ErrResourceVersionConflict if the secret changed since it was read.
+	UpdateVersioned(ctx context.Context, secret *modelv1.VersionedSecret, opts metav1.UpdateOptions) error
 	Delete(ctx context.Context, username, secretID string, opts metav1.DeleteOptions) error
 	DeleteCollection(ctx context.Context, username string, secretIDs []string, opts metav1.DeleteOptions) error
 	Get(ctx context.Context, username, secretID string, opts metav1.GetOptions) (*v1.Secret, error)
+	GetVersioned(ctx context.Context, username, secretID string, opts metav1.GetOptions) (*modelv1.VersionedSecret, error)
 	List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.SecretList, error)
 }
 
@@ -52,6 +56,22 @@ func (s *secretService) Update(ctx context.Context, secret *v1.Secret, opts meta
 	return nil
 }
 
+func (s *secretService) UpdateVersioned(
+	ctx context.Context,
+	secret *modelv1.VersionedSecret,
+	opts metav1.UpdateOptions,
+) error {
+	if err := s.store.Secrets().UpdateVersioned(ctx, secret, opts); err != nil {
+		if errors.IsCode(err, code.ErrResourceVersionConflict) {
+			return err
+		}
+
+		return errors.WithCode(code.ErrDatabase, err.Error())
+	}
+
+	return nil
+}
+
 func (s *secretService) Delete(ctx context.Context, username, secretID string, opts metav1.DeleteOptions) error {
 	if err := s.store.Secrets().Delete(ctx, username, secretID, opts); err != nil {
 		return err
@@ -86,6 +106,19 @@ func (s *secretService) Get(
 	return secret, nil
 }
 
+func (s *secretService) GetVersioned(
+	ctx context.Context,
+	username, secretID string,
+	opts metav1.GetOptions,
+) (*modelv1.VersionedSecret, error) {
+	secret, err := s.store.Secrets().GetVersioned(ctx, username, secretID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
 func (s *secretService) List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.SecretList, error) {
 	secrets, err := s.store.Secrets().List(ctx, username, opts)
 	if err != nil {
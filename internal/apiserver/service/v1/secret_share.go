@@ -0,0 +1,103 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+// SecretShareSrv defines functions used to handle secret share request.
+type SecretShareSrv interface {
+	Create(ctx context.Context, share *v1.SecretShare, opts metav1.CreateOptions) error
+	ListSharedWith(ctx context.Context, username string, opts metav1.ListOptions) (*v1.SecretShareList, error)
+}
+
+type secretShareService struct {
+	store store.Factory
+}
+
+var _ SecretShareSrv = (*secretShareService)(nil)
+
+func newSecretShares(srv *service) *secretShareService {
+	return &secretShareService{store: srv.store}
+}
+
+// Create shares the secret share.Name owned by share.Username with share.TargetUsername.
+func (s *secretShareService) Create(ctx context.Context, share *v1.SecretShare, opts metav1.CreateOptions) error {
+	secret, err := s.store.Secrets().Get(ctx, share.Username, share.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.store.Users().Get(ctx, share.TargetUsername, metav1.GetOptions{}); err != nil {
+		return err
+	}
+
+	share.SecretID = secret.SecretID
+
+	if err := s.store.SecretShares().Create(ctx, share, opts); err != nil {
+		if errors.Is(err, store.ErrDuplicateKey) {
+			return errors.WithCode(code.ErrSecretAlreadyShared, err.Error())
+		}
+
+		return errors.WithCode(code.ErrDatabase, err.Error())
+	}
+
+	return nil
+}
+
+// ListSharedWith returns the unexpired shares granted to username together with the shared secrets.
+func (s *secretShareService) ListSharedWith(
+	ctx context.Context,
+	username string,
+	opts metav1.ListOptions,
+) (*v1.SecretShareList, error) {
+	shares, err := s.store.SecretShares().List(ctx, username, opts)
+	if err != nil {
+		return nil, errors.WithCode(code.ErrDatabase, err.Error())
+	}
+
+	now := time.Now()
+	items := make([]*v1.SecretShare, 0, len(shares.Items))
+
+	for _, share := range shares.Items {
+		if share.Expired(now) {
+			continue
+		}
+
+		secret, err := s.store.Secrets().Get(ctx, share.Username, share.Name, metav1.GetOptions{})
+		if err != nil {
+			// the secret has been deleted by its owner.
+			if errors.IsCode(err, code.ErrSecretNotFound) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		// the secret has been recreated with the same name, which is not shared.
+		if secret.SecretID != share.SecretID {
+			continue
+		}
+
+		share.Secret = secret
+		items = append(items, share)
+	}
+
+	return &v1.SecretShareList{
+		ListMeta: metav1.ListMeta{
+			TotalCount: int64(len(items)),
+		},
+		Items: items,
+	}, nil
+}
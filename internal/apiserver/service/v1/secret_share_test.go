@@ -0,0 +1,128 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package v1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store/fake"
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+func sharedNames(t *testing.T, srv SecretShareSrv, username string) []string {
+	t.Helper()
+
+	shares, err := srv.ListSharedWith(context.TODO(), username, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("ListSharedWith() error = %v", err)
+	}
+
+	names := make([]string, 0, len(shares.Items))
+	for _, share := range shares.Items {
+		if share.Secret == nil || share.Secret.SecretID != share.SecretID {
+			t.Errorf("ListSharedWith() returns share %s without the shared secret", share.Name)
+		}
+
+		names = append(names, share.Name)
+	}
+
+	return names
+}
+
+func Test_secretShareService_Lifecycle(t *testing.T) {
+	factory, err := fake.GetFakeFactoryOr()
+	if err != nil {
+		t.Fatalf("GetFakeFactoryOr() error = %v", err)
+	}
+
+	ctx := context.TODO()
+	srv := NewService(factory)
+	shares := srv.SecretShares()
+
+	// user901 owns secret901 and secret902 is owned by user902.
+	owner, target := "user901", "user903"
+	share := func(name string, expiresAt time.Time) *modelv1.SecretShare {
+		return &modelv1.SecretShare{
+			ObjectMeta:     metav1.ObjectMeta{Name: name},
+			Username:       owner,
+			TargetUsername: target,
+			ExpiresAt:      expiresAt,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		share    *modelv1.SecretShare
+		wantCode int
+	}{
+		{name: "share owned secret", share: share("secret901", time.Now().Add(time.Hour))},
+		{name: "share owned secret again", share: share("secret901", time.Now().Add(time.Hour)),
+			wantCode: code.ErrSecretAlreadyShared},
+		{name: "share secret of other user", share: share("secret902", time.Now().Add(time.Hour)),
+			wantCode: code.ErrSecretNotFound},
+		{name: "share with unknown user", share: &modelv1.SecretShare{
+			ObjectMeta:     metav1.ObjectMeta{Name: "secret901"},
+			Username:       owner,
+			TargetUsername: "nobody",
+			ExpiresAt:      time.Now().Add(time.Hour),
+		}, wantCode: code.ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := shares.Create(ctx, tt.share, metav1.CreateOptions{})
+			if tt.wantCode == 0 && err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if tt.wantCode != 0 && !errors.IsCode(err, tt.wantCode) {
+				t.Fatalf("Create() error = %v, wantCode %d", err, tt.wantCode)
+			}
+		})
+	}
+
+	if got := sharedNames(t, shares, target); len(got) != 1 || got[0] != "secret901" {
+		t.Fatalf("ListSharedWith() = %v, want [secret901]", got)
+	}
+
+	// shared secrets are read-only, the recipient can not delete them.
+	if err := srv.Secrets().Delete(ctx, target, "secret901", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := srv.Secrets().Get(ctx, owner, "secret901", metav1.GetOptions{}); err != nil {
+		t.Fatalf("Get() error = %v, secret deleted by the recipient", err)
+	}
+
+	// expired shares are hidden.
+	secret904, err := srv.Secrets().Get(ctx, "user904", "secret904", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	expired := &modelv1.SecretShare{
+		ObjectMeta:     metav1.ObjectMeta{Name: "secret904"},
+		Username:       "user904",
+		SecretID:       secret904.SecretID,
+		TargetUsername: target,
+		ExpiresAt:      time.Now().Add(-time.Second),
+	}
+	if err := factory.SecretShares().Create(ctx, expired, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if got := sharedNames(t, shares, target); len(got) != 1 || got[0] != "secret901" {
+		t.Fatalf("ListSharedWith() = %v, want [secret901]", got)
+	}
+
+	// shares of deleted secrets are hidden.
+	if err := srv.Secrets().Delete(ctx, owner, "secret901", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got := sharedNames(t, shares, target); len(got) != 0 {
+		t.Fatalf("ListSharedWith() = %v, want []", got)
+	}
+}
@@ -4,7 +4,7 @@
 
 package v1
 
-//go:generate mockgen -self_package=github.com/marmotedu/iam/internal/apiserver/service/v1 -destination mock_service.go -package v1 github.com/marmotedu/iam/internal/apiserver/service/v1 Service,UserSrv,SecretSrv,PolicySrv
+//go:generate mockgen -self_package=github.com/marmotedu/iam/internal/apiserver/service/v1 -destination mock_service.go -package v1 github.com/marmotedu/iam/internal/apiserver/service/v1 Service,UserSrv,SecretSrv,PolicySrv,SecretShareSrv,PolicyGroupSrv,UserSessionSrv,ServiceAccountSrv
 
 import "github.com/marmotedu/iam/internal/apiserver/store"
 
@@ -13,6 +13,10 @@ type Service interface {
 	Users() UserSrv
 	Secrets() SecretSrv
 	Policies() PolicySrv
+	SecretShares() SecretShareSrv
+	PolicyGroups() PolicyGroupSrv
+	UserSessions() UserSessionSrv
+	ServiceAccounts() ServiceAccountSrv
 }
 
 type service struct {
@@ -37,3 +41,19 @@ func (s *service) Secrets() SecretSrv {
 func (s *service) Policies() PolicySrv {
 	return newPolicies(s)
 }
+
+func (s *service) SecretShares() SecretShareSrv {
+	return newSecretShares(s)
+}
+
+func (s *service) PolicyGroups() PolicyGroupSrv {
+	return newPolicyGroups(s)
+}
+
+func (s *service) UserSessions() UserSessionSrv {
+	return newUserSessions(s)
+}
+
+func (s *service) ServiceAccounts() ServiceAccountSrv {
+	return newServiceAccounts(s)
+}
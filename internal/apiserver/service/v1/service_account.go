@@ -0,0 +1,98 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package v1
+
+import (
+	"context"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/component-base/pkg/util/idutil"
+	"github.com/marmotedu/errors"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+// ServiceAccountSrv defines functions used to handle service account request.
+type ServiceAccountSrv interface {
+	// Create creates the service account with a new api key, which is only returned in account.APIKey.
+	Create(ctx context.Context, account *v1.ServiceAccount, opts metav1.CreateOptions) error
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.ServiceAccount, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*v1.ServiceAccountList, error)
+	// Authenticate returns the service account of serviceID if apiKey is its api key.
+	Authenticate(ctx context.Context, serviceID, apiKey string) (*v1.ServiceAccount, error)
+}
+
+type serviceAccountService struct {
+	store store.Factory
+}
+
+var _ ServiceAccountSrv = (*serviceAccountService)(nil)
+
+func newServiceAccounts(srv *service) *serviceAccountService {
+	return &serviceAccountService{store: srv.store}
+}
+
+func (s *serviceAccountService) Create(
+	ctx context.Context,
+	account *v1.ServiceAccount,
+	opts metav1.CreateOptions,
+) error {
+	account.SetAPIKey(idutil.NewSecretKey())
+
+	if err := s.store.ServiceAccounts().Create(ctx, account, opts); err != nil {
+		if errors.Is(err, store.ErrDuplicateKey) {
+			return errors.WithCode(code.ErrServiceAccountAlreadyExist, err.Error())
+		}
+
+		return errors.WithCode(code.ErrDatabase, err.Error())
+	}
+
+	return nil
+}
+
+func (s *serviceAccountService) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return s.store.ServiceAccounts().Delete(ctx, name, opts)
+}
+
+func (s *serviceAccountService) Get(
+	ctx context.Context,
+	name string,
+	opts metav1.GetOptions,
+) (*v1.ServiceAccount, error) {
+	return s.store.ServiceAccounts().Get(ctx, name, opts)
+}
+
+func (s *serviceAccountService) List(ctx context.Context, opts metav1.ListOptions) (*v1.ServiceAccountList, error) {
+	accounts, err := s.store.ServiceAccounts().List(ctx, opts)
+	if err != nil {
+		return nil, errors.WithCode(code.ErrDatabase, err.Error())
+	}
+
+	return accounts, nil
+}
+
+// Authenticate does not tell whether the service account exists, both failures are ErrInvalidAPIKey.
+func (s *serviceAccountService) Authenticate(
+	ctx context.Context,
+	serviceID, apiKey string,
+) (*v1.ServiceAccount, error) {
+	account, err := s.store.ServiceAccounts().Get(ctx, serviceID, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsCode(err, code.ErrServiceAccountNotFound) {
+			return nil, errors.WithCode(code.ErrInvalidAPIKey, "invalid api key of service %s", serviceID)
+		}
+
+		return nil, err
+	}
+
+	if apiKey == "" || !account.CompareAPIKey(apiKey) {
+		return nil, errors.WithCode(code.ErrInvalidAPIKey, "invalid api key of service %s", serviceID)
+	}
+
+	return account, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package v1
+
+import (
+	"context"
+	"testing"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store/fake"
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+func Test_serviceAccountService_Authenticate(t *testing.T) {
+	factory, err := fake.GetFakeFactoryOr()
+	if err != nil {
+		t.Fatalf("GetFakeFactoryOr() error = %v", err)
+	}
+
+	ctx := context.TODO()
+	s := newServiceAccounts(&service{store: factory})
+
+	account := &modelv1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "billing"}, Scope: "authz"}
+	if err := s.Create(ctx, account, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	defer s.Delete(ctx, "billing", metav1.DeleteOptions{}) //nolint: errcheck
+
+	if account.APIKey == "" || account.APIKeyHash == "" || account.APIKeyHash == account.APIKey {
+		t.Fatalf("Create() api key = %q, hash = %q, want a hashed api key", account.APIKey, account.APIKeyHash)
+	}
+
+	duplicate := &modelv1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "billing"}, Scope: "authz"}
+	if err := s.Create(ctx, duplicate, metav1.CreateOptions{}); !errors.IsCode(err, code.ErrServiceAccountAlreadyExist) {
+		t.Errorf("Create() duplicate error = %v, want ErrServiceAccountAlreadyExist", err)
+	}
+
+	tests := []struct {
+		name      string
+		serviceID string
+		apiKey    string
+		wantErr   bool
+	}{
+		{
+			name:      "valid api key",
+			serviceID: "billing",
+			apiKey:    account.APIKey,
+		},
+		{
+			name:      "wrong api key",
+			serviceID: "billing",
+			apiKey:    account.APIKey + "x",
+			wantErr:   true,
+		},
+		{
+			name:      "empty api key",
+			serviceID: "billing",
+			wantErr:   true,
+		},
+		{
+			name:      "unknown service",
+			serviceID: "unknown",
+			apiKey:    account.APIKey,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Authenticate(ctx, tt.serviceID, tt.apiKey)
+			if tt.wantErr {
+				if !errors.IsCode(err, code.ErrInvalidAPIKey) {
+					t.Errorf("Authenticate() error = %v, want ErrInvalidAPIKey", err)
+				}
+
+				return
+			}
+
+			if err != nil || got.Name != tt.serviceID || got.Scope != "authz" {
+				t.Errorf("Authenticate() = %v, %v, want service account %s", got, err, tt.serviceID)
+			}
+		})
+	}
+}
@@ -6,8 +6,8 @@ package v1
 
 import (
 	"context"
-	"regexp"
 	"sync"
+	"time"
 
 	v1 "github.com/marmotedu/api/apiserver/v1"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
@@ -26,6 +26,7 @@ type UserSrv interface {
 	DeleteCollection(ctx context.Context, usernames []string, opts metav1.DeleteOptions) error
 	Get(ctx context.Context, username string, opts metav1.GetOptions) (*v1.User, error)
 	List(ctx context.Context, opts metav1.ListOptions) (*v1.UserList, error)
+	ListInactive(ctx context.Context, lastLoginBefore time.Time, opts metav1.ListOptions) (*v1.UserList, error)
 	ListWithBadPerformance(ctx context.Context, opts metav1.ListOptions) (*v1.UserList, error)
 	ChangePassword(ctx context.Context, user *v1.User) error
 }
@@ -49,6 +50,27 @@ func (u *userService) List(ctx context.Context, opts metav1.ListOptions) (*v1.Us
 		return nil, errors.WithCode(code.ErrDatabase, err.Error())
 	}
 
+	return u.withPolicyCount(ctx, users)
+}
+
+// ListInactive returns the users not logged in since lastLoginBefore, to deprovision the stale accounts.
+func (u *userService) ListInactive(
+	ctx context.Context,
+	lastLoginBefore time.Time,
+	opts metav1.ListOptions,
+) (*v1.UserList, error) {
+	users, err := u.store.Users().ListInactive(ctx, lastLoginBefore, opts)
+	if err != nil {
+		log.L(ctx).Errorf("list inactive users from storage failed: %s", err.Error())
+
+		return nil, errors.WithCode(code.ErrDatabase, err.Error())
+	}
+
+	return u.withPolicyCount(ctx, users)
+}
+
+// withPolicyCount returns the users without their password, along with the number of their policies.
+func (u *userService) withPolicyCount(ctx context.Context, users *v1.UserList) (*v1.UserList, error) {
 	wg := sync.WaitGroup{}
 	errChan := make(chan error, 1)
 	finished := make(chan bool, 1)
@@ -79,10 +101,12 @@ func (u *userService) List(ctx context.Context, opts metav1.ListOptions) (*v1.Us
 					CreatedAt:  user.CreatedAt,
 					UpdatedAt:  user.UpdatedAt,
 				},
+				Status:      user.Status,
 				Nickname:    user.Nickname,
 				Email:       user.Email,
 				Phone:       user.Phone,
 				TotalPolicy: policies.TotalCount,
+				LoginedAt:   user.LoginedAt,
 			})
 		}(user)
 	}
@@ -142,7 +166,7 @@ func (u *userService) ListWithBadPerformance(ctx context.Context, opts metav1.Li
 
 func (u *userService) Create(ctx context.Context, user *v1.User, opts metav1.CreateOptions) error {
 	if err := u.store.Users().Create(ctx, user, opts); err != nil {
-		if match, _ := regexp.MatchString("Duplicate entry '.*' for key 'idx_name'", err.Error()); match {
+		if errors.Is(err, store.ErrDuplicateKey) {
 			return errors.WithCode(code.ErrUserAlreadyExist, err.Error())
 		}
 
@@ -0,0 +1,100 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package v1
+
+import (
+	"context"
+
+	"github.com/AlekSi/pointer"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/pkg/code"
+	iamjwt "github.com/marmotedu/iam/internal/pkg/jwt"
+	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+// UserSessionSrv defines functions used to handle user session request.
+type UserSessionSrv interface {
+	ListActive(ctx context.Context, username string, opts metav1.ListOptions) (*v1.UserSessionList, error)
+}
+
+// revocationChecker returns the revoked tokens among jtis.
+type revocationChecker interface {
+	Revoked(ctx context.Context, jtis []string) (map[string]bool, error)
+}
+
+type userSessionService struct {
+	store       store.Factory
+	revocations revocationChecker
+}
+
+var _ UserSessionSrv = (*userSessionService)(nil)
+
+func newUserSessions(srv *service) *userSessionService {
+	return &userSessionService{
+		store:       srv.store,
+		revocations: iamjwt.NewRevocationStore(&storage.RedisCluster{}),
+	}
+}
+
+// ListActive returns the sessions of username whose token has neither expired nor been revoked.
+// The revocations of all the sessions are checked in one batch.
+func (s *userSessionService) ListActive(
+	ctx context.Context,
+	username string,
+	opts metav1.ListOptions,
+) (*v1.UserSessionList, error) {
+	// the revoked sessions are filtered out below, so the store can not paginate them.
+	sessions, err := s.store.UserSessions().List(ctx, username, metav1.ListOptions{
+		Offset: pointer.ToInt64(0),
+		Limit:  pointer.ToInt64(-1),
+	})
+	if err != nil {
+		return nil, errors.WithCode(code.ErrDatabase, err.Error())
+	}
+
+	jtis := make([]string, 0, len(sessions.Items))
+	for _, session := range sessions.Items {
+		jtis = append(jtis, session.JTI)
+	}
+
+	revoked, err := s.revocations.Revoked(ctx, jtis)
+	if err != nil {
+		return nil, errors.WithCode(code.ErrUnknown, err.Error())
+	}
+
+	active := make([]*v1.UserSession, 0, len(sessions.Items))
+	for _, session := range sessions.Items {
+		if !revoked[session.JTI] {
+			active = append(active, session)
+		}
+	}
+
+	ol := gormutil.Unpointer(opts.Offset, opts.Limit)
+	start, end := ol.Offset, len(active)
+
+	if start < 0 {
+		start = 0
+	}
+
+	if start > end {
+		start = end
+	}
+
+	if ol.Limit >= 0 && start+ol.Limit < end {
+		end = start + ol.Limit
+	}
+
+	return &v1.UserSessionList{
+		ListMeta: metav1.ListMeta{
+			TotalCount: int64(len(active)),
+		},
+		Items: active[start:end],
+	}, nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package v1
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/AlekSi/pointer"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store/fake"
+)
+
+// fakeRevocations revokes the tokens of revoked and records the jtis of each batch checked.
+type fakeRevocations struct {
+	revoked map[string]bool
+	batches [][]string
+}
+
+func (r *fakeRevocations) Revoked(ctx context.Context, jtis []string) (map[string]bool, error) {
+	r.batches = append(r.batches, jtis)
+
+	revoked := make(map[string]bool)
+	for _, jti := range jtis {
+		if r.revoked[jti] {
+			revoked[jti] = true
+		}
+	}
+
+	return revoked, nil
+}
+
+func Test_userSessionService_ListActive(t *testing.T) {
+	factory, err := fake.GetFakeFactoryOr()
+	if err != nil {
+		t.Fatalf("GetFakeFactoryOr() error = %v", err)
+	}
+
+	ctx := context.TODO()
+	username := "user905"
+	now := time.Now()
+
+	// jti1 is the oldest session and jti5 the most recent one.
+	sessions := []*modelv1.UserSession{
+		{JTI: "jti1", ExpiresAt: now.Add(time.Hour)},
+		{JTI: "jti2", ExpiresAt: now.Add(-time.Second)},
+		{JTI: "jti3", ExpiresAt: now.Add(time.Hour)},
+		{JTI: "jti4", ExpiresAt: now.Add(time.Hour)},
+		{JTI: "jti5", ExpiresAt: now.Add(time.Hour)},
+	}
+	for _, session := range sessions {
+		session.Username = username
+		session.IssuedAt = now.Add(-time.Minute)
+
+		if err := factory.UserSessions().Create(ctx, session, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	other := &modelv1.UserSession{JTI: "other", Username: "user906", ExpiresAt: now.Add(time.Hour)}
+	if err := factory.UserSessions().Create(ctx, other, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		opts      metav1.ListOptions
+		want      []string
+		wantTotal int64
+	}{
+		{
+			name:      "all",
+			want:      []string{"jti5", "jti3", "jti1"},
+			wantTotal: 3,
+		},
+		{
+			name:      "first page",
+			opts:      metav1.ListOptions{Offset: pointer.ToInt64(0), Limit: pointer.ToInt64(2)},
+			want:      []string{"jti5", "jti3"},
+			wantTotal: 3,
+		},
+		{
+			name:      "second page",
+			opts:      metav1.ListOptions{Offset: pointer.ToInt64(2), Limit: pointer.ToInt64(2)},
+			want:      []string{"jti1"},
+			wantTotal: 3,
+		},
+		{
+			name:      "after last page",
+			opts:      metav1.ListOptions{Offset: pointer.ToInt64(5), Limit: pointer.ToInt64(2)},
+			want:      []string{},
+			wantTotal: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revocations := &fakeRevocations{revoked: map[string]bool{"jti4": true}}
+			srv := &userSessionService{store: factory, revocations: revocations}
+
+			got, err := srv.ListActive(ctx, username, tt.opts)
+			if err != nil {
+				t.Fatalf("ListActive() error = %v", err)
+			}
+
+			jtis := make([]string, 0, len(got.Items))
+			for _, session := range got.Items {
+				jtis = append(jtis, session.JTI)
+			}
+
+			if !reflect.DeepEqual(jtis, tt.want) || got.TotalCount != tt.wantTotal {
+				t.Errorf("ListActive() = %v (total %d), want %v (total %d)", jtis, got.TotalCount, tt.want, tt.wantTotal)
+			}
+
+			// the revocations of all the unexpired sessions are checked in a single batch.
+			wantBatches := [][]string{{"jti5", "jti4", "jti3", "jti1"}}
+			if !reflect.DeepEqual(revocations.batches, wantBatches) {
+				t.Errorf("revocation batches = %v, want %v", revocations.batches, wantBatches)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/AlekSi/pointer"
 	gomock "github.com/golang/mock/gomock"
@@ -148,6 +149,63 @@ func (s *Suite) Test_userService_List() {
 	}
 }
 
+func (s *Suite) Test_userService_ListInactive() {
+	storeIns, _ := fake.GetFakeFactoryOr()
+
+	opts := metav1.ListOptions{
+		Offset: pointer.ToInt64(0),
+		Limit:  pointer.ToInt64(3),
+	}
+
+	// the fake users never logged in.
+	items := make([]*v1.User, 0, 3)
+	for _, u := range fake.FakeUsers(3) {
+		items = append(items, &v1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				ID:        u.ID,
+				Name:      u.Name,
+				CreatedAt: u.CreatedAt,
+				UpdatedAt: u.UpdatedAt,
+			},
+			Nickname:    u.Nickname,
+			Email:       u.Email,
+			Phone:       u.Phone,
+			TotalPolicy: fake.ResourceCount,
+		})
+	}
+
+	tests := []struct {
+		name            string
+		lastLoginBefore time.Time
+		want            *v1.UserList
+	}{
+		{
+			name:            "inactive",
+			lastLoginBefore: time.Now(),
+			want:            &v1.UserList{ListMeta: metav1.ListMeta{TotalCount: 3}, Items: items},
+		},
+		{
+			name:            "none inactive",
+			lastLoginBefore: time.Time{},
+			want:            &v1.UserList{Items: []*v1.User{}},
+		},
+	}
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			u := &userService{
+				store: storeIns,
+			}
+			got, err := u.ListInactive(context.TODO(), tt.lastLoginBefore, opts)
+			if err != nil {
+				t.Fatalf("userService.ListInactive() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("userService.ListInactive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func (s *Suite) Test_userService_ListWithBadPerformance() {
 	storeIns, _ := fake.GetFakeFactoryOr()
 	var limit int64 = 3
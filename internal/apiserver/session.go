@@ -0,0 +1,64 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/prometheus/client_golang/prometheus"
+
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	iamjwt "github.com/marmotedu/iam/internal/pkg/jwt"
+	"github.com/marmotedu/iam/internal/pkg/middleware/auth"
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+// sessionTracker records the jwt tokens issued by iam-apiserver in the user_sessions table and their
+// revocations in redis.
+type sessionTracker struct {
+	revocations *iamjwt.RevocationStore
+}
+
+var _ auth.SessionTracker = (*sessionTracker)(nil)
+
+// registerSessionMetrics registers auth.RevocationCheckFailures once, whatever the number of jwt strategies.
+var registerSessionMetrics sync.Once
+
+func newSessionTracker() *sessionTracker {
+	registerSessionMetrics.Do(func() {
+		prometheus.MustRegister(auth.RevocationCheckFailures)
+	})
+
+	return &sessionTracker{revocations: iamjwt.NewRevocationStore(&storage.RedisCluster{})}
+}
+
+// Issued records the session, a failure is only logged as the token is already signed.
+func (t *sessionTracker) Issued(c *gin.Context, username string, jti string, issuedAt time.Time, expiresAt time.Time) {
+	session := &modelv1.UserSession{
+		JTI:       jti,
+		Username:  username,
+		IssuedAt:  issuedAt,
+		ExpiresAt: expiresAt,
+		UserAgent: c.Request.UserAgent(),
+		ClientIP:  c.ClientIP(),
+	}
+
+	if err := store.Client().UserSessions().Create(c, session, metav1.CreateOptions{}); err != nil {
+		log.L(c).Errorf("record session of user %s failed: %s", username, err.Error())
+	}
+}
+
+func (t *sessionTracker) Revoke(c *gin.Context, jti string, expiresAt time.Time) error {
+	return t.revocations.Revoke(c, jti, expiresAt)
+}
+
+func (t *sessionTracker) IsRevoked(c *gin.Context, jti string) (bool, error) {
+	return t.revocations.IsRevoked(c, jti)
+}
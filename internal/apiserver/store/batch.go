@@ -0,0 +1,26 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package store
+
+import "errors"
+
+// CreateEach creates the n rows of a batch one by one, by calling create with their index, for the stores
+// without multi-row inserts. The rows failing with ErrDuplicateKey are returned by their index, the other
+// errors stop the batch, see UserStore.CreateBatch.
+func CreateEach(n int, create func(i int) error) (map[int]error, error) {
+	failed := map[int]error{}
+
+	for i := 0; i < n; i++ {
+		if err := create(i); err != nil {
+			if !errors.Is(err, ErrDuplicateKey) {
+				return nil, err
+			}
+
+			failed[i] = err
+		}
+	}
+
+	return failed, nil
+}
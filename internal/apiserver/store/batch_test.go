@@ -0,0 +1,55 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCreateEach(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+
+	tests := []struct {
+		name       string
+		errs       []error
+		wantFailed []int
+		wantErr    error
+		wantCalls  int
+	}{
+		{name: "created", errs: []error{nil, nil}, wantCalls: 2},
+		{
+			name:       "duplicates",
+			errs:       []error{fmt.Errorf("user: %w", ErrDuplicateKey), nil, ErrDuplicateKey},
+			wantFailed: []int{0, 2},
+			wantCalls:  3,
+		},
+		{name: "failure", errs: []error{nil, errUnavailable, nil}, wantErr: errUnavailable, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			failed, err := CreateEach(len(tt.errs), func(i int) error {
+				calls++
+
+				return tt.errs[i]
+			})
+			if !errors.Is(err, tt.wantErr) || calls != tt.wantCalls {
+				t.Fatalf("CreateEach() error = %v after %d calls, want %v after %d", err, calls, tt.wantErr, tt.wantCalls)
+			}
+
+			if len(failed) != len(tt.wantFailed) {
+				t.Errorf("CreateEach() failed = %v, want the rows %v", failed, tt.wantFailed)
+			}
+
+			for _, i := range tt.wantFailed {
+				if !errors.Is(failed[i], ErrDuplicateKey) {
+					t.Errorf("CreateEach() error of the row %d = %v, want %v", i, failed[i], ErrDuplicateKey)
+				}
+			}
+		})
+	}
+}
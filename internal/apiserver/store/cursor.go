@@ -0,0 +1,152 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
+)
+
+// The lists paginated by keyset, a cursor only applies to the list it was returned by.
+const (
+	CursorUsers    = "users"
+	CursorPolicies = "policies"
+	CursorSecrets  = "secrets"
+)
+
+// Cursor is the position of a page of a keyset pagination of a list: the sort key and the id of the last
+// item of the previous page. The lists are sorted by descending (sort key, id), the sort key is empty for
+// the lists sorted by id only, like all the lists of the stores so far.
+type Cursor struct {
+	List    string `json:"l"`
+	SortKey string `json:"k,omitempty"`
+	ID      uint64 `json:"i"`
+}
+
+// withCursorKey is the context key of WithCursor.
+type withCursorKey struct{}
+
+// WithCursor returns a copy of ctx whose list of cursor.List starts after cursor, instead of at the offset of
+// its list options, the other lists read with ctx are not changed. The TotalCount of the list then counts
+// the items from the cursor on. The stores without keyset pagination, e.g. the fake store, ignore it.
+func WithCursor(ctx context.Context, cursor Cursor) context.Context {
+	return context.WithValue(ctx, withCursorKey{}, cursor)
+}
+
+// CursorFrom returns the cursor of ctx, see WithCursor.
+func CursorFrom(ctx context.Context) (Cursor, bool) {
+	cursor, ok := ctx.Value(withCursorKey{}).(Cursor)
+
+	return cursor, ok
+}
+
+// cursorKey signs the continue tokens, random unless set by SetCursorKey.
+var cursorKey = newCursorKey()
+
+func newCursorKey() []byte {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+
+	return key
+}
+
+// SetCursorKey derives the key the continue tokens are signed with from secret, see EncodeCursor. The
+// instances of a service must share it, for the tokens of an instance to be accepted by the others. It is
+// not safe to call it once the stores are in use.
+func SetCursorKey(secret string) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("iam continue token"))
+	cursorKey = mac.Sum(nil)
+}
+
+func signCursor(payload string) []byte {
+	mac := hmac.New(sha256.New, cursorKey)
+	mac.Write([]byte(payload))
+
+	return mac.Sum(nil)
+}
+
+// EncodeCursor returns the opaque continue token of cursor given to the clients, signed so that they cannot
+// forge the cursor.
+func EncodeCursor(cursor Cursor) string {
+	data, _ := json.Marshal(cursor)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signCursor(payload))
+}
+
+// DecodeCursor returns the cursor of a continue token returned by EncodeCursor, code.ErrValidation if the
+// token is malformed or was not signed by the service.
+func DecodeCursor(token string) (Cursor, error) {
+	var cursor Cursor
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return cursor, errors.WithCode(code.ErrValidation, "invalid continue token")
+	}
+
+	payload := parts[0]
+
+	mac, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(mac, signCursor(payload)) {
+		return cursor, errors.WithCode(code.ErrValidation, "invalid continue token")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return cursor, errors.WithCode(code.ErrValidation, "invalid continue token")
+	}
+
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, errors.WithCode(code.ErrValidation, "invalid continue token")
+	}
+
+	return cursor, nil
+}
+
+// WithContinue returns a copy of ctx whose list starts after the page of the list which returned the
+// continue token, see WithCursor, or ctx if the token is empty. It returns code.ErrValidation if the token
+// is invalid, or was returned by another list.
+func WithContinue(ctx context.Context, list, token string) (context.Context, error) {
+	if token == "" {
+		return ctx, nil
+	}
+
+	cursor, err := DecodeCursor(token)
+	if err != nil {
+		return ctx, err
+	}
+
+	if cursor.List != list {
+		return ctx, errors.WithCode(code.ErrValidation, "continue token of another list")
+	}
+
+	return WithCursor(ctx, cursor), nil
+}
+
+// ContinueToken returns the continue token of the page following the page of the list read with opts,
+// given its number of items and the id of its last item, or an empty token if the page is not full, i.e.
+// is the last one.
+func ContinueToken(list string, opts metav1.ListOptions, count int, lastID uint64) string {
+	limit := gormutil.Unpointer(opts.Offset, opts.Limit).Limit
+	if limit <= 0 || count < limit {
+		return ""
+	}
+
+	return EncodeCursor(Cursor{List: list, ID: lastID})
+}
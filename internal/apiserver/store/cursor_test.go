@@ -0,0 +1,133 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/AlekSi/pointer"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	cursor := Cursor{List: CursorUsers, SortKey: "colin", ID: 42}
+	token := EncodeCursor(cursor)
+	parts := strings.Split(token, ".")
+
+	// forged is the token of another cursor, signed with the signature of the first one.
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"l":"users","i":1}`)) + "." + parts[1]
+
+	tests := []struct {
+		name    string
+		token   string
+		want    Cursor
+		wantErr bool
+	}{
+		{name: "valid", token: token, want: cursor},
+		{name: "forged", token: forged, wantErr: true},
+		{name: "invalid signature", token: parts[0] + ".c2lnbmF0dXJl", wantErr: true},
+		{name: "unsigned", token: parts[0], wantErr: true},
+		{name: "not base64", token: "!." + parts[1], wantErr: true},
+		{name: "empty", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCursor(tt.token)
+			if tt.wantErr {
+				if !errors.IsCode(err, code.ErrValidation) {
+					t.Errorf("DecodeCursor() error = %v, want code %d", err, code.ErrValidation)
+				}
+
+				return
+			}
+
+			if err != nil || got != tt.want {
+				t.Errorf("DecodeCursor() = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursor_OtherKey(t *testing.T) {
+	defer func(key []byte) { cursorKey = key }(cursorKey)
+
+	SetCursorKey("instance secret")
+	token := EncodeCursor(Cursor{List: CursorUsers, ID: 42})
+
+	// the instances sharing the secret accept the token, the others do not.
+	SetCursorKey("instance secret")
+	if _, err := DecodeCursor(token); err != nil {
+		t.Errorf("DecodeCursor() with the same secret error = %v", err)
+	}
+
+	SetCursorKey("other secret")
+	if _, err := DecodeCursor(token); !errors.IsCode(err, code.ErrValidation) {
+		t.Errorf("DecodeCursor() with another secret error = %v, want code %d", err, code.ErrValidation)
+	}
+}
+
+func TestWithContinue(t *testing.T) {
+	cursor := Cursor{List: CursorUsers, ID: 42}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantCursor bool
+		wantErr    bool
+	}{
+		{name: "no token"},
+		{name: "token of the list", token: EncodeCursor(cursor), wantCursor: true},
+		{name: "token of another list", token: EncodeCursor(Cursor{List: CursorSecrets, ID: 42}), wantErr: true},
+		{name: "invalid token", token: "42", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := WithContinue(context.TODO(), CursorUsers, tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithContinue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got, ok := CursorFrom(ctx); ok != tt.wantCursor || (ok && got != cursor) {
+				t.Errorf("CursorFrom() = %+v, %v, want %v", got, ok, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestContinueToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      metav1.ListOptions
+		count     int
+		wantToken bool
+	}{
+		{name: "full page", opts: metav1.ListOptions{Limit: pointer.ToInt64(10)}, count: 10, wantToken: true},
+		{name: "last page", opts: metav1.ListOptions{Limit: pointer.ToInt64(10)}, count: 3},
+		{name: "default limit", count: 3},
+		{name: "no limit", opts: metav1.ListOptions{Limit: pointer.ToInt64(-1)}, count: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := ContinueToken(CursorUsers, tt.opts, tt.count, 42)
+			if (token != "") != tt.wantToken {
+				t.Fatalf("ContinueToken() = %q, wantToken %v", token, tt.wantToken)
+			}
+
+			if !tt.wantToken {
+				return
+			}
+
+			if got, err := DecodeCursor(token); err != nil || got != (Cursor{List: CursorUsers, ID: 42}) {
+				t.Errorf("DecodeCursor() = %+v, %v", got, err)
+			}
+		})
+	}
+}
@@ -65,6 +65,28 @@ func (ds *datastore) PolicyAudits() store.PolicyAuditStore {
 	return newPolicyAudits(ds)
 }
 
+func (ds *datastore) SecretShares() store.SecretShareStore {
+	return newSecretShares(ds)
+}
+
+func (ds *datastore) PolicyGroups() store.PolicyGroupStore {
+	return newPolicyGroups(ds)
+}
+
+func (ds *datastore) UserSessions() store.UserSessionStore {
+	return newUserSessions(ds)
+}
+
+func (ds *datastore) ServiceAccounts() store.ServiceAccountStore {
+	return newServiceAccounts(ds)
+}
+
+// WithTransaction calls fn with the etcd store itself, etcd has no transactions of the stores: the writes
+// of fn are not rolled back if it fails.
+func (ds *datastore) WithTransaction(ctx context.Context, fn func(store.Factory) error) error {
+	return fn(ds)
+}
+
 // Close clsoe the etcdStore clinet.
 func (ds *datastore) Close() error {
 	if ds.cli != nil {
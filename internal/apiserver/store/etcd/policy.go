@@ -13,6 +13,10 @@ import (
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 	"github.com/marmotedu/component-base/pkg/util/jsonutil"
 	"github.com/marmotedu/errors"
+
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/pkg/code"
 )
 
 type policies struct {
@@ -34,6 +38,14 @@ func (p *policies) Create(ctx context.Context, policy *v1.Policy, opts metav1.Cr
 	return p.ds.Put(ctx, p.getKey(policy.Username, policy.Name), jsonutil.ToString(policy))
 }
 
+// CreateBatch creates the policies one by one, see store.CreateEach. The policies created before a failure
+// are kept.
+func (p *policies) CreateBatch(ctx context.Context, batch []*v1.Policy, opts metav1.CreateOptions) (map[int]error, error) {
+	return store.CreateEach(len(batch), func(i int) error {
+		return p.Create(ctx, batch[i], opts)
+	})
+}
+
 // Update updates an policy information.
 func (p *policies) Update(ctx context.Context, policy *v1.Policy, opts metav1.UpdateOptions) error {
 	return p.ds.Put(ctx, p.getKey(policy.Username, policy.Name), jsonutil.ToString(policy))
@@ -87,6 +99,35 @@ func (p *policies) Get(ctx context.Context, username, name string, opts metav1.G
 	return &policy, nil
 }
 
+// GetVersioned return the policy by the policy identifier, along with the revision of its key as resource version.
+func (p *policies) GetVersioned(
+	ctx context.Context,
+	username, name string,
+	opts metav1.GetOptions,
+) (*modelv1.VersionedPolicy, error) {
+	resp, version, err := p.ds.getVersioned(ctx, p.getKey(username, name))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp == nil {
+		return nil, errors.WithCode(code.ErrPolicyNotFound, "no such key")
+	}
+
+	policy := &modelv1.VersionedPolicy{ResourceVersion: version}
+	if err := json.Unmarshal(resp, &policy.Policy); err != nil {
+		return nil, errors.Wrap(err, "unmarshal to Policy struct failed")
+	}
+
+	return policy, nil
+}
+
+// UpdateVersioned updates the policy if the revision of its key is unchanged.
+func (p *policies) UpdateVersioned(ctx context.Context, policy *modelv1.VersionedPolicy, opts metav1.UpdateOptions) error {
+	return p.ds.putVersioned(ctx, p.getKey(policy.Username, policy.Name),
+		jsonutil.ToString(&policy.Policy), &policy.ResourceVersion)
+}
+
 // List return all policies.
 func (p *policies) List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.PolicyList, error) {
 	kvs, err := p.ds.List(ctx, p.getKey(username, ""))
@@ -111,3 +152,34 @@ func (p *policies) List(ctx context.Context, username string, opts metav1.ListOp
 
 	return ret, nil
 }
+
+// Search returns the policies of the user found by the search, of all the users if username is empty,
+// ordered by relevance then id. The policies are searched in memory.
+func (p *policies) Search(
+	ctx context.Context,
+	username string,
+	search store.SearchOptions,
+	opts metav1.ListOptions,
+) (*v1.PolicyList, error) {
+	prefix := p.getKey(username, "")
+	if username == "" {
+		prefix = "/policies/"
+	}
+
+	kvs, err := p.ds.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make([]*v1.Policy, 0, len(kvs))
+	for _, v := range kvs {
+		var policy v1.Policy
+		if err := json.Unmarshal(v.Value, &policy); err != nil {
+			return nil, errors.Wrap(err, "unmarshal to Policy struct failed")
+		}
+
+		policies = append(policies, &policy)
+	}
+
+	return search.SearchPolicies(policies, opts)
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package etcd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/marmotedu/component-base/pkg/json"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/component-base/pkg/util/jsonutil"
+	"github.com/marmotedu/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+type policyGroups struct {
+	ds *datastore
+}
+
+func newPolicyGroups(ds *datastore) *policyGroups {
+	return &policyGroups{ds: ds}
+}
+
+var keyPolicyGroup = "/policy_groups/%v/%v"
+
+func (g *policyGroups) getKey(username string, name string) string {
+	return fmt.Sprintf(keyPolicyGroup, username, name)
+}
+
+// Create creates a new policy group.
+func (g *policyGroups) Create(ctx context.Context, group *v1.PolicyGroup, opts metav1.CreateOptions) error {
+	return g.ds.Put(ctx, g.getKey(group.Username, group.Name), jsonutil.ToString(group))
+}
+
+// Update updates the policy group by the policy group identifier.
+func (g *policyGroups) Update(ctx context.Context, group *v1.PolicyGroup, opts metav1.UpdateOptions) error {
+	return g.ds.Put(ctx, g.getKey(group.Username, group.Name), jsonutil.ToString(group))
+}
+
+// Delete deletes the policy group and its member policies in one transaction.
+func (g *policyGroups) Delete(ctx context.Context, username, name string, opts metav1.DeleteOptions) error {
+	group, rev, err := g.get(ctx, username, name)
+	if err != nil {
+		if errors.IsCode(err, code.ErrPolicyGroupNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	ops := []clientv3.Op{clientv3.OpDelete(g.ds.getKey(g.getKey(username, name)))}
+	for _, id := range group.PolicyIDs {
+		ops = append(ops, clientv3.OpDelete(g.ds.getKey(fmt.Sprintf(keyPolicy, username, id))))
+	}
+
+	return g.commit(ctx, username, name, rev, nil, ops)
+}
+
+// Get return policy group by the policy group identifier.
+func (g *policyGroups) Get(
+	ctx context.Context,
+	username, name string,
+	opts metav1.GetOptions,
+) (*v1.PolicyGroup, error) {
+	group, _, err := g.get(ctx, username, name)
+
+	return group, err
+}
+
+// List return the policy groups of the user, or all policy groups if username is empty.
+func (g *policyGroups) List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.PolicyGroupList, error) {
+	prefix := g.getKey(username, "")
+	if username == "" {
+		prefix = "/policy_groups/"
+	}
+
+	kvs, err := g.ds.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &v1.PolicyGroupList{
+		ListMeta: metav1.ListMeta{
+			TotalCount: int64(len(kvs)),
+		},
+	}
+
+	for _, v := range kvs {
+		var group v1.PolicyGroup
+		if err := json.Unmarshal(v.Value, &group); err != nil {
+			return nil, errors.Wrap(err, "unmarshal to PolicyGroup struct failed")
+		}
+
+		ret.Items = append(ret.Items, &group)
+	}
+
+	return ret, nil
+}
+
+// Apply marks the policy group as applied in one transaction, which fails if some member
+// policies do not exist.
+func (g *policyGroups) Apply(ctx context.Context, username, name string) error {
+	group, rev, err := g.get(ctx, username, name)
+	if err != nil {
+		return err
+	}
+
+	cmps := make([]clientv3.Cmp, 0, len(group.PolicyIDs))
+	for _, id := range group.PolicyIDs {
+		cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(g.ds.getKey(fmt.Sprintf(keyPolicy, username, id))), ">", 0))
+	}
+
+	group.Applied = true
+	ops := []clientv3.Op{clientv3.OpPut(g.ds.getKey(g.getKey(username, name)), jsonutil.ToString(group))}
+
+	return g.commit(ctx, username, name, rev, cmps, ops)
+}
+
+// get returns the policy group along with the revision of its key.
+func (g *policyGroups) get(ctx context.Context, username, name string) (*v1.PolicyGroup, int64, error) {
+	nctx, cancel := context.WithTimeout(ctx, g.ds.requestTimeout)
+	defer cancel()
+
+	resp, err := g.ds.cli.Get(nctx, g.ds.getKey(g.getKey(username, name)))
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "get key from etcd failed")
+	}
+
+	if len(resp.Kvs) == 0 {
+		return nil, 0, errors.WithCode(code.ErrPolicyGroupNotFound, "no such key")
+	}
+
+	var group v1.PolicyGroup
+	if err := json.Unmarshal(resp.Kvs[0].Value, &group); err != nil {
+		return nil, 0, errors.Wrap(err, "unmarshal to PolicyGroup struct failed")
+	}
+
+	return &group, resp.Kvs[0].ModRevision, nil
+}
+
+// commit runs the ops if the policy group is unchanged since rev and the cmps succeed.
+func (g *policyGroups) commit(
+	ctx context.Context,
+	username, name string,
+	rev int64,
+	cmps []clientv3.Cmp,
+	ops []clientv3.Op,
+) error {
+	nctx, cancel := context.WithTimeout(ctx, g.ds.requestTimeout)
+	defer cancel()
+
+	cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(g.ds.getKey(g.getKey(username, name))), "=", rev))
+
+	resp, err := g.ds.cli.Txn(nctx).If(cmps...).Then(ops...).Commit()
+	if err != nil {
+		return errors.Wrap(err, "commit etcd transaction failed")
+	}
+
+	if !resp.Succeeded {
+		return errors.WithCode(code.ErrPolicyNotFound,
+			"policy group %s was modified or some of its member policies do not exist", name)
+	}
+
+	return nil
+}
@@ -13,6 +13,9 @@ import (
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 	"github.com/marmotedu/component-base/pkg/util/jsonutil"
 	"github.com/marmotedu/errors"
+
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/pkg/code"
 )
 
 type secrets struct {
@@ -73,6 +76,35 @@ func (s *secrets) Get(ctx context.Context, username, secretID string, opts metav
 	return &secret, nil
 }
 
+// GetVersioned return the secret by the secret identifier, along with the revision of its key as resource version.
+func (s *secrets) GetVersioned(
+	ctx context.Context,
+	username, secretID string,
+	opts metav1.GetOptions,
+) (*modelv1.VersionedSecret, error) {
+	resp, version, err := s.ds.getVersioned(ctx, s.getKey(username, secretID))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp == nil {
+		return nil, errors.WithCode(code.ErrSecretNotFound, "no such key")
+	}
+
+	secret := &modelv1.VersionedSecret{ResourceVersion: version}
+	if err := json.Unmarshal(resp, &secret.Secret); err != nil {
+		return nil, errors.Wrap(err, "unmarshal to Secret struct failed")
+	}
+
+	return secret, nil
+}
+
+// UpdateVersioned updates the secret if the revision of its key is unchanged.
+func (s *secrets) UpdateVersioned(ctx context.Context, secret *modelv1.VersionedSecret, opts metav1.UpdateOptions) error {
+	return s.ds.putVersioned(ctx, s.getKey(secret.Username, secret.SecretID),
+		jsonutil.ToString(&secret.Secret), &secret.ResourceVersion)
+}
+
 // List return all secrets.
 func (s *secrets) List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.SecretList, error) {
 	kvs, err := s.ds.List(ctx, s.getKey(username, ""))
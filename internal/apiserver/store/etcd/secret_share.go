@@ -0,0 +1,69 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package etcd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/marmotedu/component-base/pkg/json"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/component-base/pkg/util/jsonutil"
+	"github.com/marmotedu/errors"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+)
+
+type secretShares struct {
+	ds *datastore
+}
+
+func newSecretShares(ds *datastore) *secretShares {
+	return &secretShares{ds: ds}
+}
+
+var keySecretShare = "/secret_shares/%v"
+
+func (s *secretShares) getKey(targetUsername, secretID string) string {
+	if targetUsername == "" {
+		return fmt.Sprintf(keySecretShare, "")
+	}
+
+	return fmt.Sprintf(keySecretShare, targetUsername+"/"+secretID)
+}
+
+// Create creates a new secret share.
+func (s *secretShares) Create(ctx context.Context, share *v1.SecretShare, opts metav1.CreateOptions) error {
+	return s.ds.Put(ctx, s.getKey(share.TargetUsername, share.SecretID), jsonutil.ToString(share))
+}
+
+// List return the secret shares granted to the target user.
+func (s *secretShares) List(
+	ctx context.Context,
+	targetUsername string,
+	opts metav1.ListOptions,
+) (*v1.SecretShareList, error) {
+	kvs, err := s.ds.List(ctx, s.getKey(targetUsername, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &v1.SecretShareList{
+		ListMeta: metav1.ListMeta{
+			TotalCount: int64(len(kvs)),
+		},
+	}
+
+	for _, v := range kvs {
+		var share v1.SecretShare
+		if err := json.Unmarshal(v.Value, &share); err != nil {
+			return nil, errors.Wrap(err, "unmarshal to SecretShare struct failed")
+		}
+
+		ret.Items = append(ret.Items, &share)
+	}
+
+	return ret, nil
+}
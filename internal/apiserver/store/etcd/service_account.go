@@ -0,0 +1,105 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package etcd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/marmotedu/component-base/pkg/json"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/component-base/pkg/util/jsonutil"
+	"github.com/marmotedu/errors"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+type serviceAccounts struct {
+	ds *datastore
+}
+
+func newServiceAccounts(ds *datastore) *serviceAccounts {
+	return &serviceAccounts{ds: ds}
+}
+
+var keyServiceAccount = "/service_accounts/%v"
+
+// storedServiceAccount is the value of a service account key, the hash of the api key is not
+// marshaled by v1.ServiceAccount.
+type storedServiceAccount struct {
+	*v1.ServiceAccount
+	APIKeyHash string `json:"apiKeyHash"`
+}
+
+func (s *serviceAccounts) getKey(name string) string {
+	return fmt.Sprintf(keyServiceAccount, name)
+}
+
+// Create creates a new service account, the api key is only stored hashed.
+func (s *serviceAccounts) Create(ctx context.Context, account *v1.ServiceAccount, opts metav1.CreateOptions) error {
+	stored := *account
+	stored.APIKey = ""
+
+	return s.ds.Put(ctx, s.getKey(account.Name), jsonutil.ToString(storedServiceAccount{
+		ServiceAccount: &stored,
+		APIKeyHash:     account.APIKeyHash,
+	}))
+}
+
+// Delete deletes the service account by the service id.
+func (s *serviceAccounts) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	if _, err := s.ds.Delete(ctx, s.getKey(name)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Get return service account by the service id.
+func (s *serviceAccounts) Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.ServiceAccount, error) {
+	resp, err := s.ds.Get(ctx, s.getKey(name))
+	if err != nil {
+		return nil, errors.WithCode(code.ErrServiceAccountNotFound, err.Error())
+	}
+
+	return unmarshalServiceAccount(resp)
+}
+
+// List return all service accounts.
+func (s *serviceAccounts) List(ctx context.Context, opts metav1.ListOptions) (*v1.ServiceAccountList, error) {
+	kvs, err := s.ds.List(ctx, s.getKey(""))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &v1.ServiceAccountList{
+		ListMeta: metav1.ListMeta{
+			TotalCount: int64(len(kvs)),
+		},
+	}
+
+	for _, v := range kvs {
+		account, err := unmarshalServiceAccount(v.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		ret.Items = append(ret.Items, account)
+	}
+
+	return ret, nil
+}
+
+func unmarshalServiceAccount(data []byte) (*v1.ServiceAccount, error) {
+	stored := storedServiceAccount{ServiceAccount: &v1.ServiceAccount{}}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, errors.Wrap(err, "unmarshal to ServiceAccount struct failed")
+	}
+
+	stored.ServiceAccount.APIKeyHash = stored.APIKeyHash
+
+	return stored.ServiceAccount, nil
+}
@@ -7,12 +7,15 @@ package etcd
 import (
 	"context"
 	"fmt"
+	"time"
 
 	v1 "github.com/marmotedu/api/apiserver/v1"
 	"github.com/marmotedu/component-base/pkg/json"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 	"github.com/marmotedu/component-base/pkg/util/jsonutil"
 	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
 )
 
 type users struct {
@@ -34,12 +37,21 @@ func (u *users) Create(ctx context.Context, user *v1.User, opts metav1.CreateOpt
 	return u.ds.Put(ctx, u.getKey(user.Name), jsonutil.ToString(user))
 }
 
+// CreateBatch creates the users one by one, see store.CreateEach. The users created before a failure are
+// kept.
+func (u *users) CreateBatch(ctx context.Context, batch []*v1.User, opts metav1.CreateOptions) (map[int]error, error) {
+	return store.CreateEach(len(batch), func(i int) error {
+		return u.Create(ctx, batch[i], opts)
+	})
+}
+
 // Update updates an user account information.
 func (u *users) Update(ctx context.Context, user *v1.User, opts metav1.UpdateOptions) error {
 	return u.ds.Put(ctx, u.getKey(user.Name), jsonutil.ToString(user))
 }
 
-// Delete deletes the user by the user identifier.
+// Delete deletes the user by the user identifier. The etcd store has no soft delete, the users are always
+// deleted for good, and store.WithDeleted is ignored.
 func (u *users) Delete(ctx context.Context, username string, opts metav1.DeleteOptions) error {
 	// delete related policy first
 	pol := newPolicies(u.ds)
@@ -101,3 +113,50 @@ func (u *users) List(ctx context.Context, opts metav1.ListOptions) (*v1.UserList
 
 	return ret, nil
 }
+
+// ListInactive return the users not logged in since lastLoginBefore.
+func (u *users) ListInactive(
+	ctx context.Context,
+	lastLoginBefore time.Time,
+	opts metav1.ListOptions,
+) (*v1.UserList, error) {
+	all, err := u.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &v1.UserList{}
+
+	for _, user := range all.Items {
+		lastLogin := user.LoginedAt
+		if lastLogin.IsZero() {
+			lastLogin = user.CreatedAt
+		}
+
+		if user.Status == 1 && lastLogin.Before(lastLoginBefore) {
+			ret.Items = append(ret.Items, user)
+		}
+	}
+
+	ret.TotalCount = int64(len(ret.Items))
+
+	return ret, nil
+}
+
+// Search returns the enabled users found by the search, ordered by relevance then id. The users are
+// searched in memory.
+func (u *users) Search(ctx context.Context, search store.SearchOptions, opts metav1.ListOptions) (*v1.UserList, error) {
+	all, err := u.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*v1.User, 0)
+	for _, user := range all.Items {
+		if user.Status == 1 {
+			users = append(users, user)
+		}
+	}
+
+	return search.SearchUsers(users, opts)
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/marmotedu/component-base/pkg/json"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/component-base/pkg/util/jsonutil"
+	"github.com/marmotedu/errors"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+)
+
+type userSessions struct {
+	ds *datastore
+}
+
+func newUserSessions(ds *datastore) *userSessions {
+	return &userSessions{ds: ds}
+}
+
+var keyUserSession = "/user_sessions/%v/%v"
+
+func (s *userSessions) getKey(username string, jti string) string {
+	return fmt.Sprintf(keyUserSession, username, jti)
+}
+
+// Create creates a new user session, the key is removed by etcd when the token expires.
+func (s *userSessions) Create(ctx context.Context, session *v1.UserSession, opts metav1.CreateOptions) error {
+	ttl := int64(time.Until(session.ExpiresAt).Seconds())
+	if ttl <= 0 {
+		return nil
+	}
+
+	return s.ds.PutWithLease(ctx, s.getKey(session.Username, session.JTI), jsonutil.ToString(session), ttl)
+}
+
+// List return the sessions of the user whose token has not expired.
+func (s *userSessions) List(
+	ctx context.Context,
+	username string,
+	opts metav1.ListOptions,
+) (*v1.UserSessionList, error) {
+	kvs, err := s.ds.List(ctx, s.getKey(username, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &v1.UserSessionList{}
+	now := time.Now()
+
+	for _, v := range kvs {
+		var session v1.UserSession
+		if err := json.Unmarshal(v.Value, &session); err != nil {
+			return nil, errors.Wrap(err, "unmarshal to UserSession struct failed")
+		}
+
+		// the lease may not be revoked yet.
+		if session.Expired(now) {
+			continue
+		}
+
+		ret.Items = append(ret.Items, &session)
+	}
+
+	sort.Slice(ret.Items, func(i, j int) bool { return ret.Items[i].IssuedAt.After(ret.Items[j].IssuedAt) })
+	ret.TotalCount = int64(len(ret.Items))
+
+	return ret, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package etcd
+
+import (
+	"context"
+
+	"github.com/marmotedu/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+// getVersioned returns the value of the key along with its modification revision, used as resource
+// version. The value is nil if the key does not exist.
+func (ds *datastore) getVersioned(ctx context.Context, key string) ([]byte, uint64, error) {
+	nctx, cancel := context.WithTimeout(ctx, ds.requestTimeout)
+	defer cancel()
+
+	resp, err := ds.cli.Get(nctx, ds.getKey(key))
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "get key from etcd failed")
+	}
+
+	if len(resp.Kvs) == 0 {
+		return nil, 0, nil
+	}
+
+	return resp.Kvs[0].Value, uint64(resp.Kvs[0].ModRevision), nil
+}
+
+// putVersioned puts the value of the key if its modification revision is still version, and sets
+// version to the new one. It fails with code.ErrResourceVersionConflict otherwise.
+func (ds *datastore) putVersioned(ctx context.Context, key string, val string, version *uint64) error {
+	nctx, cancel := context.WithTimeout(ctx, ds.requestTimeout)
+	defer cancel()
+
+	key = ds.getKey(key)
+
+	resp, err := ds.cli.Txn(nctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", int64(*version))).
+		Then(clientv3.OpPut(key, val)).
+		Commit()
+	if err != nil {
+		return errors.Wrap(err, "commit etcd transaction failed")
+	}
+
+	if !resp.Succeeded {
+		return errors.WithCode(code.ErrResourceVersionConflict, "resource version %d is outdated", *version)
+	}
+
+	*version = uint64(resp.Header.Revision)
+
+	return nil
+}
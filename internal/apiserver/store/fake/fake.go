@@ -5,6 +5,7 @@
 package fake
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/marmotedu/component-base/pkg/util/idutil"
 	"github.com/ory/ladon"
 
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
 	"github.com/marmotedu/iam/internal/apiserver/store"
 )
 
@@ -21,9 +23,16 @@ const ResourceCount = 1000
 
 type datastore struct {
 	sync.RWMutex
-	users    []*v1.User
-	secrets  []*v1.Secret
-	policies []*v1.Policy
+	users           []*v1.User
+	secrets         []*v1.Secret
+	policies        []*v1.Policy
+	secretShares    []*modelv1.SecretShare
+	policyGroups    []*modelv1.PolicyGroup
+	userSessions    []*modelv1.UserSession
+	serviceAccounts []*modelv1.ServiceAccount
+
+	// versions counts the updates of the stored policies and secrets, their resource version is one more.
+	versions map[interface{}]uint64
 }
 
 func (ds *datastore) Users() store.UserStore {
@@ -42,6 +51,41 @@ func (ds *datastore) PolicyAudits() store.PolicyAuditStore {
 	return newPolicyAudits(ds)
 }
 
+func (ds *datastore) SecretShares() store.SecretShareStore {
+	return newSecretShares(ds)
+}
+
+func (ds *datastore) PolicyGroups() store.PolicyGroupStore {
+	return newPolicyGroups(ds)
+}
+
+func (ds *datastore) UserSessions() store.UserSessionStore {
+	return newUserSessions(ds)
+}
+
+func (ds *datastore) ServiceAccounts() store.ServiceAccountStore {
+	return newServiceAccounts(ds)
+}
+
+// WithTransaction calls fn with the fake store itself, the writes of fn are not rolled back if it fails.
+func (ds *datastore) WithTransaction(ctx context.Context, fn func(store.Factory) error) error {
+	return fn(ds)
+}
+
+// version returns the resource version of the stored policy or secret, the caller holds the lock.
+func (ds *datastore) version(obj interface{}) uint64 {
+	return ds.versions[obj] + 1
+}
+
+// bump increments the resource version of the stored policy or secret, the caller holds the lock.
+func (ds *datastore) bump(obj interface{}) {
+	if ds.versions == nil {
+		ds.versions = make(map[interface{}]uint64)
+	}
+
+	ds.versions[obj]++
+}
+
 func (ds *datastore) Close() error {
 	return nil
 }
@@ -54,11 +98,7 @@ var (
 // GetFakeFactoryOr create fake store.
 func GetFakeFactoryOr() (store.Factory, error) {
 	once.Do(func() {
-		fakeFactory = &datastore{
-			users:    FakeUsers(ResourceCount),
-			secrets:  FakeSecrets(ResourceCount),
-			policies: FakePolicies(ResourceCount),
-		}
+		fakeFactory = NewFakeFactory()
 	})
 
 	if fakeFactory == nil {
@@ -68,6 +108,16 @@ func GetFakeFactoryOr() (store.Factory, error) {
 	return fakeFactory, nil
 }
 
+// NewFakeFactory creates a fake store of its own, unlike GetFakeFactoryOr, for the tests changing the
+// stored resources not to change the resources of the other tests.
+func NewFakeFactory() store.Factory {
+	return &datastore{
+		users:    FakeUsers(ResourceCount),
+		secrets:  FakeSecrets(ResourceCount),
+		policies: FakePolicies(ResourceCount),
+	}
+}
+
 // FakeUsers returns fake user data.
 func FakeUsers(count int) []*v1.User {
 	// init some user records
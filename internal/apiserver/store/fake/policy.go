@@ -15,6 +15,8 @@ import (
 	"github.com/marmotedu/component-base/pkg/util/stringutil"
 	"github.com/marmotedu/errors"
 
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/pkg/code"
 	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
 	reflectutil "github.com/marmotedu/iam/internal/pkg/util/reflect"
@@ -35,7 +37,7 @@ func (p *policies) Create(ctx context.Context, policy *v1.Policy, opts metav1.Cr
 
 	for _, pol := range p.ds.policies {
 		if pol.Username == policy.Username && pol.Name == policy.Name {
-			return errors.New("record already exist")
+			return store.ErrDuplicateKey
 		}
 	}
 
@@ -47,6 +49,14 @@ func (p *policies) Create(ctx context.Context, policy *v1.Policy, opts metav1.Cr
 	return nil
 }
 
+// CreateBatch creates the policies one by one, see store.CreateEach. The policies created before a failure
+// are kept.
+func (p *policies) CreateBatch(ctx context.Context, batch []*v1.Policy, opts metav1.CreateOptions) (map[int]error, error) {
+	return store.CreateEach(len(batch), func(i int) error {
+		return p.Create(ctx, batch[i], opts)
+	})
+}
+
 // Update updates policy by the policy identifier.
 func (p *policies) Update(ctx context.Context, policy *v1.Policy, opts metav1.UpdateOptions) error {
 	p.ds.Lock()
@@ -57,6 +67,8 @@ func (p *policies) Update(ctx context.Context, policy *v1.Policy, opts metav1.Up
 			if _, err := reflectutil.CopyObj(policy, pol, nil); err != nil {
 				return errors.Wrap(err, "copy policy failed")
 			}
+
+			p.ds.bump(pol)
 		}
 	}
 
@@ -153,6 +165,48 @@ func (p *policies) Get(ctx context.Context, username, name string, opts metav1.G
 	return nil, errors.WithCode(code.ErrPolicyNotFound, "record not found")
 }
 
+// GetVersioned return policy by the policy identifier, along with its resource version.
+func (p *policies) GetVersioned(
+	ctx context.Context,
+	username, name string,
+	opts metav1.GetOptions,
+) (*modelv1.VersionedPolicy, error) {
+	p.ds.RLock()
+	defer p.ds.RUnlock()
+
+	for _, pol := range p.ds.policies {
+		if pol.Username == username && pol.Name == name {
+			return &modelv1.VersionedPolicy{Policy: *pol, ResourceVersion: p.ds.version(pol)}, nil
+		}
+	}
+
+	return nil, errors.WithCode(code.ErrPolicyNotFound, "record not found")
+}
+
+// UpdateVersioned updates the policy if its resource version is unchanged, and increments it.
+func (p *policies) UpdateVersioned(ctx context.Context, policy *modelv1.VersionedPolicy, opts metav1.UpdateOptions) error {
+	p.ds.Lock()
+	defer p.ds.Unlock()
+
+	for _, pol := range p.ds.policies {
+		if pol.Username != policy.Username || pol.Name != policy.Name {
+			continue
+		}
+
+		if p.ds.version(pol) != policy.ResourceVersion {
+			return errors.WithCode(code.ErrResourceVersionConflict, "resource version %d is outdated", policy.ResourceVersion)
+		}
+
+		*pol = policy.Policy
+		p.ds.bump(pol)
+		policy.ResourceVersion++
+
+		return nil
+	}
+
+	return errors.WithCode(code.ErrPolicyNotFound, "record not found")
+}
+
 // List return all policies.
 func (p *policies) List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.PolicyList, error) {
 	p.ds.RLock()
@@ -191,3 +245,24 @@ func (p *policies) List(ctx context.Context, username string, opts metav1.ListOp
 		Items: policies,
 	}, nil
 }
+
+// Search returns the policies of the user found by the search, of all the users if username is empty,
+// ordered by relevance then id.
+func (p *policies) Search(
+	ctx context.Context,
+	username string,
+	search store.SearchOptions,
+	opts metav1.ListOptions,
+) (*v1.PolicyList, error) {
+	p.ds.RLock()
+	defer p.ds.RUnlock()
+
+	policies := make([]*v1.Policy, 0)
+	for _, pol := range p.ds.policies {
+		if username == "" || pol.Username == username {
+			policies = append(policies, pol)
+		}
+	}
+
+	return search.SearchPolicies(policies, opts)
+}
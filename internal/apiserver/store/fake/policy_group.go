@@ -0,0 +1,165 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package fake
+
+import (
+	"context"
+
+	apiv1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
+)
+
+type policyGroups struct {
+	ds *datastore
+}
+
+func newPolicyGroups(ds *datastore) *policyGroups {
+	return &policyGroups{ds}
+}
+
+// Create creates a new policy group.
+func (g *policyGroups) Create(ctx context.Context, group *v1.PolicyGroup, opts metav1.CreateOptions) error {
+	g.ds.Lock()
+	defer g.ds.Unlock()
+
+	for _, pg := range g.ds.policyGroups {
+		if pg.Username == group.Username && pg.Name == group.Name {
+			return store.ErrDuplicateKey
+		}
+	}
+
+	if len(g.ds.policyGroups) > 0 {
+		group.ID = g.ds.policyGroups[len(g.ds.policyGroups)-1].ID + 1
+	}
+	g.ds.policyGroups = append(g.ds.policyGroups, group)
+
+	return nil
+}
+
+// Update updates the policy group by the policy group identifier.
+func (g *policyGroups) Update(ctx context.Context, group *v1.PolicyGroup, opts metav1.UpdateOptions) error {
+	g.ds.Lock()
+	defer g.ds.Unlock()
+
+	for i, pg := range g.ds.policyGroups {
+		if pg.Username == group.Username && pg.Name == group.Name {
+			g.ds.policyGroups[i] = group
+		}
+	}
+
+	return nil
+}
+
+// Delete deletes the policy group and its member policies.
+func (g *policyGroups) Delete(ctx context.Context, username, name string, opts metav1.DeleteOptions) error {
+	g.ds.Lock()
+	defer g.ds.Unlock()
+
+	groups := g.ds.policyGroups
+	g.ds.policyGroups = make([]*v1.PolicyGroup, 0)
+	for _, pg := range groups {
+		if pg.Username != username || pg.Name != name {
+			g.ds.policyGroups = append(g.ds.policyGroups, pg)
+
+			continue
+		}
+
+		policies := g.ds.policies
+		g.ds.policies = make([]*apiv1.Policy, 0)
+		for _, pol := range policies {
+			if pol.Username == username && pg.HasPolicy(pol.Name) {
+				continue
+			}
+
+			g.ds.policies = append(g.ds.policies, pol)
+		}
+	}
+
+	return nil
+}
+
+// Get return policy group by the policy group identifier.
+func (g *policyGroups) Get(
+	ctx context.Context,
+	username, name string,
+	opts metav1.GetOptions,
+) (*v1.PolicyGroup, error) {
+	g.ds.RLock()
+	defer g.ds.RUnlock()
+
+	for _, pg := range g.ds.policyGroups {
+		if pg.Username == username && pg.Name == name {
+			return pg, nil
+		}
+	}
+
+	return nil, errors.WithCode(code.ErrPolicyGroupNotFound, "record not found")
+}
+
+// List return the policy groups of the user, or all policy groups if username is empty.
+func (g *policyGroups) List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.PolicyGroupList, error) {
+	g.ds.RLock()
+	defer g.ds.RUnlock()
+
+	ol := gormutil.Unpointer(opts.Offset, opts.Limit)
+
+	groups := make([]*v1.PolicyGroup, 0)
+	i := 0
+	for _, pg := range g.ds.policyGroups {
+		if i == ol.Limit {
+			break
+		}
+
+		if username != "" && pg.Username != username {
+			continue
+		}
+
+		groups = append(groups, pg)
+		i++
+	}
+
+	return &v1.PolicyGroupList{
+		ListMeta: metav1.ListMeta{
+			TotalCount: int64(len(groups)),
+		},
+		Items: groups,
+	}, nil
+}
+
+// Apply marks the policy group as applied if all its member policies exist.
+func (g *policyGroups) Apply(ctx context.Context, username, name string) error {
+	g.ds.Lock()
+	defer g.ds.Unlock()
+
+	for _, pg := range g.ds.policyGroups {
+		if pg.Username != username || pg.Name != name {
+			continue
+		}
+
+		found := 0
+		for _, pol := range g.ds.policies {
+			if pol.Username == username && pg.HasPolicy(pol.Name) {
+				found++
+			}
+		}
+
+		if found != len(pg.PolicyIDs) {
+			return errors.WithCode(code.ErrPolicyNotFound,
+				"%d member policies of policy group %s do not exist", len(pg.PolicyIDs)-found, name)
+		}
+
+		pg.Applied = true
+
+		return nil
+	}
+
+	return errors.WithCode(code.ErrPolicyGroupNotFound, "record not found")
+}
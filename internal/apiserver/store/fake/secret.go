@@ -14,6 +14,8 @@ import (
 	"github.com/marmotedu/component-base/pkg/util/stringutil"
 	"github.com/marmotedu/errors"
 
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/pkg/code"
 	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
 	reflectutil "github.com/marmotedu/iam/internal/pkg/util/reflect"
@@ -34,7 +36,7 @@ func (s *secrets) Create(ctx context.Context, secret *v1.Secret, opts metav1.Cre
 
 	for _, sec := range s.ds.secrets {
 		if sec.Username == secret.Username && sec.Name == secret.Name {
-			return errors.New("record already exist")
+			return store.ErrDuplicateKey
 		}
 	}
 
@@ -56,6 +58,8 @@ func (s *secrets) Update(ctx context.Context, secret *v1.Secret, opts metav1.Upd
 			if _, err := reflectutil.CopyObj(secret, sec, nil); err != nil {
 				return errors.Wrap(err, "copy secret failed")
 			}
+
+			s.ds.bump(sec)
 		}
 	}
 
@@ -117,6 +121,48 @@ func (s *secrets) Get(ctx context.Context, username, name string, opts metav1.Ge
 	return nil, errors.WithCode(code.ErrSecretNotFound, "record not found")
 }
 
+// GetVersioned return secret by the secret identifier, along with its resource version.
+func (s *secrets) GetVersioned(
+	ctx context.Context,
+	username, name string,
+	opts metav1.GetOptions,
+) (*modelv1.VersionedSecret, error) {
+	s.ds.RLock()
+	defer s.ds.RUnlock()
+
+	for _, sec := range s.ds.secrets {
+		if sec.Username == username && sec.Name == name {
+			return &modelv1.VersionedSecret{Secret: *sec, ResourceVersion: s.ds.version(sec)}, nil
+		}
+	}
+
+	return nil, errors.WithCode(code.ErrSecretNotFound, "record not found")
+}
+
+// UpdateVersioned updates the secret if its resource version is unchanged, and increments it.
+func (s *secrets) UpdateVersioned(ctx context.Context, secret *modelv1.VersionedSecret, opts metav1.UpdateOptions) error {
+	s.ds.Lock()
+	defer s.ds.Unlock()
+
+	for _, sec := range s.ds.secrets {
+		if sec.Username != secret.Username || sec.Name != secret.Name {
+			continue
+		}
+
+		if s.ds.version(sec) != secret.ResourceVersion {
+			return errors.WithCode(code.ErrResourceVersionConflict, "resource version %d is outdated", secret.ResourceVersion)
+		}
+
+		*sec = secret.Secret
+		s.ds.bump(sec)
+		secret.ResourceVersion++
+
+		return nil
+	}
+
+	return errors.WithCode(code.ErrSecretNotFound, "record not found")
+}
+
 // List return all secrets.
 func (s *secrets) List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.SecretList, error) {
 	s.ds.RLock()
@@ -0,0 +1,74 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package fake
+
+import (
+	"context"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
+)
+
+type secretShares struct {
+	ds *datastore
+}
+
+func newSecretShares(ds *datastore) *secretShares {
+	return &secretShares{ds}
+}
+
+// Create creates a new secret share.
+func (s *secretShares) Create(ctx context.Context, share *v1.SecretShare, opts metav1.CreateOptions) error {
+	s.ds.Lock()
+	defer s.ds.Unlock()
+
+	for _, sh := range s.ds.secretShares {
+		if sh.SecretID == share.SecretID && sh.TargetUsername == share.TargetUsername {
+			return store.ErrDuplicateKey
+		}
+	}
+
+	share.ID = uint64(len(s.ds.secretShares) + 1)
+	s.ds.secretShares = append(s.ds.secretShares, share)
+
+	return nil
+}
+
+// List return the secret shares granted to the target user.
+func (s *secretShares) List(
+	ctx context.Context,
+	targetUsername string,
+	opts metav1.ListOptions,
+) (*v1.SecretShareList, error) {
+	s.ds.RLock()
+	defer s.ds.RUnlock()
+
+	ol := gormutil.Unpointer(opts.Offset, opts.Limit)
+
+	shares := make([]*v1.SecretShare, 0)
+	i := 0
+	for _, sh := range s.ds.secretShares {
+		if i == ol.Limit {
+			break
+		}
+
+		if targetUsername != "" && sh.TargetUsername != targetUsername {
+			continue
+		}
+
+		shares = append(shares, sh)
+		i++
+	}
+
+	return &v1.SecretShareList{
+		ListMeta: metav1.ListMeta{
+			TotalCount: int64(len(shares)),
+		},
+		Items: shares,
+	}, nil
+}
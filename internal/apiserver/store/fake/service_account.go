@@ -0,0 +1,102 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package fake
+
+import (
+	"context"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
+)
+
+type serviceAccounts struct {
+	ds *datastore
+}
+
+func newServiceAccounts(ds *datastore) *serviceAccounts {
+	return &serviceAccounts{ds}
+}
+
+// Create creates a new service account.
+func (s *serviceAccounts) Create(ctx context.Context, account *v1.ServiceAccount, opts metav1.CreateOptions) error {
+	s.ds.Lock()
+	defer s.ds.Unlock()
+
+	for _, sa := range s.ds.serviceAccounts {
+		if sa.Name == account.Name {
+			return store.ErrDuplicateKey
+		}
+	}
+
+	if len(s.ds.serviceAccounts) > 0 {
+		account.ID = s.ds.serviceAccounts[len(s.ds.serviceAccounts)-1].ID + 1
+	}
+	s.ds.serviceAccounts = append(s.ds.serviceAccounts, account)
+
+	return nil
+}
+
+// Delete deletes the service account by the service id.
+func (s *serviceAccounts) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	s.ds.Lock()
+	defer s.ds.Unlock()
+
+	accounts := s.ds.serviceAccounts
+	s.ds.serviceAccounts = make([]*v1.ServiceAccount, 0)
+	for _, sa := range accounts {
+		if sa.Name == name {
+			continue
+		}
+
+		s.ds.serviceAccounts = append(s.ds.serviceAccounts, sa)
+	}
+
+	return nil
+}
+
+// Get return service account by the service id.
+func (s *serviceAccounts) Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.ServiceAccount, error) {
+	s.ds.RLock()
+	defer s.ds.RUnlock()
+
+	for _, sa := range s.ds.serviceAccounts {
+		if sa.Name == name {
+			return sa, nil
+		}
+	}
+
+	return nil, errors.WithCode(code.ErrServiceAccountNotFound, "record not found")
+}
+
+// List return all service accounts.
+func (s *serviceAccounts) List(ctx context.Context, opts metav1.ListOptions) (*v1.ServiceAccountList, error) {
+	s.ds.RLock()
+	defer s.ds.RUnlock()
+
+	ol := gormutil.Unpointer(opts.Offset, opts.Limit)
+
+	accounts := make([]*v1.ServiceAccount, 0)
+	i := 0
+	for _, sa := range s.ds.serviceAccounts {
+		if i == ol.Limit {
+			break
+		}
+
+		accounts = append(accounts, sa)
+		i++
+	}
+
+	return &v1.ServiceAccountList{
+		ListMeta: metav1.ListMeta{
+			TotalCount: int64(len(accounts)),
+		},
+		Items: accounts,
+	}, nil
+}
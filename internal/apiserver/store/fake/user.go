@@ -7,6 +7,7 @@ package fake
 import (
 	"context"
 	"strings"
+	"time"
 
 	v1 "github.com/marmotedu/api/apiserver/v1"
 	"github.com/marmotedu/component-base/pkg/fields"
@@ -14,6 +15,7 @@ import (
 	"github.com/marmotedu/component-base/pkg/util/stringutil"
 	"github.com/marmotedu/errors"
 
+	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/pkg/code"
 	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
 	reflectutil "github.com/marmotedu/iam/internal/pkg/util/reflect"
@@ -46,6 +48,19 @@ func (u *users) Create(ctx context.Context, user *v1.User, opts metav1.CreateOpt
 	return nil
 }
 
+// CreateBatch creates the users one by one, see store.CreateEach. The users created before a failure are
+// kept.
+func (u *users) CreateBatch(ctx context.Context, batch []*v1.User, opts metav1.CreateOptions) (map[int]error, error) {
+	return store.CreateEach(len(batch), func(i int) error {
+		err := u.Create(ctx, batch[i], opts)
+		if errors.IsCode(err, code.ErrUserAlreadyExist) {
+			return errors.Wrap(store.ErrDuplicateKey, err.Error())
+		}
+
+		return err
+	})
+}
+
 // Update updates an user account information.
 func (u *users) Update(ctx context.Context, user *v1.User, opts metav1.UpdateOptions) error {
 	u.ds.Lock()
@@ -62,7 +77,8 @@ func (u *users) Update(ctx context.Context, user *v1.User, opts metav1.UpdateOpt
 	return nil
 }
 
-// Delete deletes the user by the user identifier.
+// Delete deletes the user by the user identifier. The fake store has no soft delete, the users are always
+// deleted for good, and store.WithDeleted is ignored.
 func (u *users) Delete(ctx context.Context, username string, opts metav1.DeleteOptions) error {
 	u.ds.Lock()
 	defer u.ds.Unlock()
@@ -153,3 +169,54 @@ func (u *users) List(ctx context.Context, opts metav1.ListOptions) (*v1.UserList
 		Items: users,
 	}, nil
 }
+
+// ListInactive return the users not logged in since lastLoginBefore.
+func (u *users) ListInactive(
+	ctx context.Context,
+	lastLoginBefore time.Time,
+	opts metav1.ListOptions,
+) (*v1.UserList, error) {
+	u.ds.RLock()
+	defer u.ds.RUnlock()
+
+	ol := gormutil.Unpointer(opts.Offset, opts.Limit)
+
+	users := make([]*v1.User, 0)
+	i := 0
+	for _, user := range u.ds.users {
+		if i == ol.Limit {
+			break
+		}
+		lastLogin := user.LoginedAt
+		if lastLogin.IsZero() {
+			lastLogin = user.CreatedAt
+		}
+		if !lastLogin.Before(lastLoginBefore) {
+			continue
+		}
+		users = append(users, user)
+		i++
+	}
+
+	return &v1.UserList{
+		ListMeta: metav1.ListMeta{
+			TotalCount: int64(len(users)),
+		},
+		Items: users,
+	}, nil
+}
+
+// Search returns the enabled users found by the search, ordered by relevance then id.
+func (u *users) Search(ctx context.Context, search store.SearchOptions, opts metav1.ListOptions) (*v1.UserList, error) {
+	u.ds.RLock()
+	defer u.ds.RUnlock()
+
+	users := make([]*v1.User, 0)
+	for _, user := range u.ds.users {
+		if user.Status == 1 {
+			users = append(users, user)
+		}
+	}
+
+	return search.SearchUsers(users, opts)
+}
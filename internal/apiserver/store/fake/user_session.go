@@ -0,0 +1,70 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package fake
+
+import (
+	"context"
+	"time"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
+)
+
+type userSessions struct {
+	ds *datastore
+}
+
+func newUserSessions(ds *datastore) *userSessions {
+	return &userSessions{ds}
+}
+
+// Create creates a new user session.
+func (s *userSessions) Create(ctx context.Context, session *v1.UserSession, opts metav1.CreateOptions) error {
+	s.ds.Lock()
+	defer s.ds.Unlock()
+
+	session.ID = uint64(len(s.ds.userSessions) + 1)
+	s.ds.userSessions = append(s.ds.userSessions, session)
+
+	return nil
+}
+
+// List return the sessions of the user whose token has not expired.
+func (s *userSessions) List(
+	ctx context.Context,
+	username string,
+	opts metav1.ListOptions,
+) (*v1.UserSessionList, error) {
+	s.ds.RLock()
+	defer s.ds.RUnlock()
+
+	ol := gormutil.Unpointer(opts.Offset, opts.Limit)
+	now := time.Now()
+
+	sessions := make([]*v1.UserSession, 0)
+	i := 0
+	for j := len(s.ds.userSessions) - 1; j >= 0; j-- {
+		if i == ol.Limit {
+			break
+		}
+
+		session := s.ds.userSessions[j]
+		if session.Username != username || session.Expired(now) {
+			continue
+		}
+
+		sessions = append(sessions, session)
+		i++
+	}
+
+	return &v1.UserSessionList{
+		ListMeta: metav1.ListMeta{
+			TotalCount: int64(len(sessions)),
+		},
+		Items: sessions,
+	}, nil
+}
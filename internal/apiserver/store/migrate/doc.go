@@ -0,0 +1,19 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package migrate applies the versioned migrations of the schema of the store, embedded in the binary.
+//
+// The migrations of each database engine are in migrations/<engine>, a migration being the pair of files
+// <version>_<name>.up.sql and <version>_<name>.down.sql. The versions are the same for all the engines, so
+// that a version stands for the same schema whatever the engine. The applied migrations are recorded in
+// the schema_migrations table of the database.
+//
+// A migration is applied in a transaction with its record. mysql commits the DDL statements implicitly
+// though, so the migrations only use idempotent statements, e.g. CREATE TABLE IF NOT EXISTS: a migration
+// failing midway is applied again from its start. It also adopts the databases created by configs/iam.sql
+// before the migrations.
+//
+// The statements of a migration are executed at once, the mysql connections of the migrations must
+// allow multiple statements, see db.Options.MultiStatements.
+package migrate
@@ -0,0 +1,314 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package migrate
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations
+var migrationsFS embed.FS
+
+// fileName matches the files of the migrations, e.g. 0001_init.up.sql.
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a versioned change of the schema, applied by its Up statements and reverted by its
+// Down statements.
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Status is the state of a migration in the database.
+type Status struct {
+	Version uint64
+	Name    string
+	// AppliedAt is nil if the migration is pending.
+	AppliedAt *time.Time
+	// Unknown is true if the migration is applied but not embedded in the binary, which is older
+	// than the database.
+	Unknown bool
+}
+
+// schemaMigration is the record of an applied migration.
+type schemaMigration struct {
+	Version   uint64    `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name;size:255;not null"`
+	AppliedAt time.Time `gorm:"column:appliedAt;not null"`
+}
+
+// TableName maps to mysql table name.
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrations returns the migrations of the database engine, ordered by version.
+func Migrations(engine string) ([]Migration, error) {
+	fsys, err := fs.Sub(migrationsFS, "migrations/"+engine)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("load the %s migrations failed: %w", engine, err)
+	}
+
+	return migrations, nil
+}
+
+// load reads the migrations in the files of fsys.
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint64]*Migration{}
+	for _, entry := range entries {
+		matches := fileName.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+
+		version, err := strconv.ParseUint(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version of the migration file %s: %w", entry.Name(), err)
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != matches[2] {
+			return nil, fmt.Errorf("the migration %d is named both %s and %s", version, migration.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("the migration %s misses its up or down file", migration)
+		}
+
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Migrator applies the migrations of a database engine to a database.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New returns the migrator of the database of the given engine.
+func New(db *gorm.DB, engine string) (*Migrator, error) {
+	migrations, err := Migrations(engine)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Close closes the connections to the database.
+func (m *Migrator) Close() error {
+	sqlDB, err := m.db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
+}
+
+// Status returns the state of the migrations, the known ones and the applied ones, ordered by version.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+			delete(applied, migration.Version)
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	for _, record := range applied {
+		record := record
+		statuses = append(statuses, Status{
+			Version:   record.Version,
+			Name:      record.Name,
+			AppliedAt: &record.AppliedAt,
+			Unknown:   true,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+
+	return statuses, nil
+}
+
+// Pending returns the migrations not applied yet, ordered by version.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// Up applies the pending migrations in order, and returns the applied ones. It stops at the first
+// failing migration.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	if err := m.createTable(ctx); err != nil {
+		return nil, err
+	}
+
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]Migration, 0, len(pending))
+	for _, migration := range pending {
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(migration.Up).Error; err != nil {
+				return err
+			}
+
+			return tx.Create(&schemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("apply the migration %s failed: %w", migration, err)
+		}
+
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// Down reverts the last applied migration, and returns it. It returns nil if no migration is applied.
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	if err := m.createTable(ctx); err != nil {
+		return nil, err
+	}
+
+	var record schemaMigration
+	err := m.db.WithContext(ctx).Order("version desc").Limit(1).Find(&record).Error
+	if err != nil {
+		return nil, err
+	}
+	if record.Version == 0 {
+		return nil, nil
+	}
+
+	var migration *Migration
+	for i := range m.migrations {
+		if m.migrations[i].Version == record.Version {
+			migration = &m.migrations[i]
+		}
+	}
+	if migration == nil {
+		return nil, fmt.Errorf("the applied migration %04d_%s is unknown, the binary is older than the database",
+			record.Version, record.Name)
+	}
+
+	err = m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(migration.Down).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&schemaMigration{Version: migration.Version}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("revert the migration %s failed: %w", migration, err)
+	}
+
+	return migration, nil
+}
+
+// applied returns the records of the applied migrations by version.
+func (m *Migrator) applied(ctx context.Context) (map[uint64]schemaMigration, error) {
+	db := m.db.WithContext(ctx)
+	if !db.Migrator().HasTable(&schemaMigration{}) {
+		return map[uint64]schemaMigration{}, nil
+	}
+
+	var records []schemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[uint64]schemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+
+	return applied, nil
+}
+
+// createTable creates the schema_migrations table if it does not exist.
+func (m *Migrator) createTable(ctx context.Context) error {
+	db := m.db.WithContext(ctx)
+	if db.Migrator().HasTable(&schemaMigration{}) {
+		return nil
+	}
+
+	if err := db.Migrator().CreateTable(&schemaMigration{}); err != nil {
+		return fmt.Errorf("create the schema_migrations table failed: %w", err)
+	}
+
+	return nil
+}
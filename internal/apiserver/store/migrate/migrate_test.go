@@ -0,0 +1,338 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package migrate
+
+import (
+	"context"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/marmotedu/iam/pkg/db"
+)
+
+// tables are the tables created by the migrations.
+var tables = []string{
+	"user", "policy", "policy_audit", "secret", "secret_shares", "policy_groups", "user_sessions",
+	"service_accounts",
+}
+
+func Test_load(t *testing.T) {
+	file := func(data string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(data)} }
+
+	tests := []struct {
+		name         string
+		fsys         fstest.MapFS
+		wantVersions []uint64
+		wantErr      bool
+	}{
+		{
+			name: "ordered by version",
+			fsys: fstest.MapFS{
+				"0010_b.up.sql":   file("up b"),
+				"0010_b.down.sql": file("down b"),
+				"0002_a.up.sql":   file("up a"),
+				"0002_a.down.sql": file("down a"),
+			},
+			wantVersions: []uint64{2, 10},
+		},
+		{
+			name:         "no migration",
+			fsys:         fstest.MapFS{},
+			wantVersions: []uint64{},
+		},
+		{
+			name:    "missing down file",
+			fsys:    fstest.MapFS{"0001_a.up.sql": file("up a")},
+			wantErr: true,
+		},
+		{
+			name: "different names",
+			fsys: fstest.MapFS{
+				"0001_a.up.sql":   file("up a"),
+				"0001_b.down.sql": file("down b"),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "unexpected file",
+			fsys:    fstest.MapFS{"README.md": file("readme")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := load(tt.fsys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			versions := []uint64{}
+			for _, migration := range migrations {
+				if migration.Up == "" || migration.Down == "" {
+					t.Errorf("load() migration %s misses its statements", migration)
+				}
+				versions = append(versions, migration.Version)
+			}
+			if len(versions) != len(tt.wantVersions) {
+				t.Fatalf("load() versions = %v, want %v", versions, tt.wantVersions)
+			}
+			for i := range versions {
+				if versions[i] != tt.wantVersions[i] {
+					t.Errorf("load() versions = %v, want %v", versions, tt.wantVersions)
+				}
+			}
+		})
+	}
+}
+
+// TestMigrations checks that a version is the same migration for all the engines.
+func TestMigrations(t *testing.T) {
+	want, err := Migrations("mysql")
+	if err != nil {
+		t.Fatalf("Migrations(mysql) error = %v", err)
+	}
+
+	for _, engine := range []string{"postgres", "sqlite"} {
+		t.Run(engine, func(t *testing.T) {
+			got, err := Migrations(engine)
+			if err != nil {
+				t.Fatalf("Migrations() error = %v", err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("Migrations() returned %d migrations, mysql has %d", len(got), len(want))
+			}
+			for i := range got {
+				if got[i].String() != want[i].String() {
+					t.Errorf("Migrations()[%d] = %s, mysql has %s", i, got[i], want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := Migrations("oracle"); err == nil {
+		t.Error("Migrations(oracle) succeeded")
+	}
+}
+
+func TestMigrator_Sqlite(t *testing.T) {
+	dbIns, err := db.NewSqlite(&db.SqliteOptions{Path: db.SqliteMemory})
+	if err != nil {
+		t.Fatalf("NewSqlite() error = %v", err)
+	}
+
+	testMigrator(t, dbIns, "sqlite")
+
+	// the resources of a deleted user are deleted with it, see 0007_user_delete_cascade.
+	statements := []string{
+		"INSERT INTO `user` (`name`, `nickname`, `password`, `email`) VALUES ('colin', 'colin', 'x', 'colin@foxmail.com')",
+		"INSERT INTO `user_sessions` (`jti`, `username`) VALUES ('jti', 'colin')",
+		"DELETE FROM `user` WHERE `name` = 'colin'",
+	}
+	for _, statement := range statements {
+		if err := dbIns.Exec(statement).Error; err != nil {
+			t.Fatalf("Exec(%q) error = %v", statement, err)
+		}
+	}
+
+	var sessions int64
+	if err := dbIns.Table("user_sessions").Count(&sessions).Error; err != nil {
+		t.Fatalf("Count() error = %v", err)
+	}
+	if sessions != 0 {
+		t.Errorf("the sessions of a deleted user = %d, want 0", sessions)
+	}
+
+	// every update increments the resource version, see 0008_resource_version.
+	statements = []string{
+		"INSERT INTO `user` (`name`, `nickname`, `password`, `email`) VALUES ('colin', 'colin', 'x', 'colin@foxmail.com')",
+		"INSERT INTO `policy` (`name`, `username`) VALUES ('policy', 'colin')",
+		"UPDATE `policy` SET `policyShadow` = '{}' WHERE `name` = 'policy'",
+		"UPDATE `policy` SET `policyShadow` = '{}' WHERE `name` = 'policy' AND `resourceVersion` = 2",
+	}
+	for _, statement := range statements {
+		if err := dbIns.Exec(statement).Error; err != nil {
+			t.Fatalf("Exec(%q) error = %v", statement, err)
+		}
+	}
+
+	var version uint64
+	if err := dbIns.Table("policy").Select("resourceVersion").Row().Scan(&version); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if version != 3 {
+		t.Errorf("the resource version of a policy updated twice = %d, want 3", version)
+	}
+
+	// a user has a single policy of a name, see 0011_policy_name_unique.
+	if err := dbIns.Exec("INSERT INTO `policy` (`name`, `username`) VALUES ('policy', 'colin')").Error; err == nil {
+		t.Error("the creation of a policy of the same name succeeded")
+	}
+
+	// the soft deleted users are deleted for good with their resources when the column is dropped, see
+	// 0009_user_soft_delete.
+	if err := dbIns.Exec("UPDATE `user` SET `deletedAt` = current_timestamp WHERE `name` = 'colin'").Error; err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	m, err := New(dbIns, "sqlite")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if reverted, err := m.Down(context.Background()); err != nil || reverted.Version != 11 {
+		t.Fatalf("Down() = %v, %v, want 0011_policy_name_unique reverted", reverted, err)
+	}
+	if reverted, err := m.Down(context.Background()); err != nil || reverted.Version != 10 {
+		t.Fatalf("Down() = %v, %v, want 0010_search_fulltext reverted", reverted, err)
+	}
+	if reverted, err := m.Down(context.Background()); err != nil || reverted.Version != 9 {
+		t.Fatalf("Down() = %v, %v, want 0009_user_soft_delete reverted", reverted, err)
+	}
+
+	var policies int64
+	if err := dbIns.Table("policy").Count(&policies).Error; err != nil {
+		t.Fatalf("Count() error = %v", err)
+	}
+	if policies != 0 {
+		t.Errorf("the policies of a soft deleted user after the revert = %d, want 0", policies)
+	}
+}
+
+// TestMigrator_MySQL runs the migrations against the mysql database of the IAM_TEST_MYSQL_DSN
+// environment variable, see the conformance tests of the mysql store. The tables are dropped first.
+func TestMigrator_MySQL(t *testing.T) {
+	dsn := os.Getenv("IAM_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("IAM_TEST_MYSQL_DSN is not set")
+	}
+
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("ParseDSN() error = %v", err)
+	}
+	cfg.MultiStatements = true
+
+	dbIns, err := gorm.Open(mysql.Open(cfg.FormatDSN()), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	dropTables(t, dbIns)
+	testMigrator(t, dbIns, "mysql")
+}
+
+// TestMigrator_Postgres runs the migrations against the postgres database of the IAM_TEST_POSTGRES_DSN
+// environment variable, see the conformance tests of the postgres store. The tables are dropped first.
+func TestMigrator_Postgres(t *testing.T) {
+	dsn := os.Getenv("IAM_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("IAM_TEST_POSTGRES_DSN is not set")
+	}
+
+	dbIns, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	dropTables(t, dbIns)
+	testMigrator(t, dbIns, "postgres")
+}
+
+func dropTables(t *testing.T, dbIns *gorm.DB) {
+	t.Helper()
+
+	// the tables referencing the user table are dropped before it.
+	for i := len(tables) - 1; i >= 0; i-- {
+		if err := dbIns.Migrator().DropTable(tables[i]); err != nil {
+			t.Fatalf("DropTable(%s) error = %v", tables[i], err)
+		}
+	}
+
+	if err := dbIns.Migrator().DropTable(&schemaMigration{}); err != nil {
+		t.Fatalf("DropTable(schema_migrations) error = %v", err)
+	}
+}
+
+// testMigrator runs the full chain of the migrations against the fresh database dbIns: up, down
+// step by step, and up again. The migrations are applied at the end.
+func testMigrator(t *testing.T, dbIns *gorm.DB, engine string) {
+	t.Helper()
+
+	ctx := context.Background()
+	m, err := New(dbIns, engine)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		t.Fatalf("Pending() error = %v", err)
+	}
+	if len(pending) != len(m.migrations) {
+		t.Fatalf("Pending() of a fresh database = %d migrations, want %d", len(pending), len(m.migrations))
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if len(applied) != len(m.migrations) {
+		t.Fatalf("Up() applied %d migrations, want %d", len(applied), len(m.migrations))
+	}
+	for _, table := range tables {
+		if !dbIns.Migrator().HasTable(table) {
+			t.Errorf("Up() did not create the table %s", table)
+		}
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil || status.Unknown {
+			t.Errorf("Status() of %04d_%s = %+v, want applied", status.Version, status.Name, status)
+		}
+	}
+
+	if applied, err = m.Up(ctx); err != nil || len(applied) != 0 {
+		t.Fatalf("Up() of a migrated database = %v, %v, want nothing applied", applied, err)
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		reverted, err := m.Down(ctx)
+		if err != nil {
+			t.Fatalf("Down() error = %v", err)
+		}
+		if reverted == nil || reverted.Version != m.migrations[i].Version {
+			t.Fatalf("Down() reverted %v, want %s", reverted, m.migrations[i])
+		}
+	}
+	for _, table := range tables {
+		if dbIns.Migrator().HasTable(table) {
+			t.Errorf("Down() did not drop the table %s", table)
+		}
+	}
+
+	if reverted, err := m.Down(ctx); err != nil || reverted != nil {
+		t.Fatalf("Down() of an empty database = %v, %v, want nothing reverted", reverted, err)
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up() after Down() error = %v", err)
+	}
+	if pending, err := m.Pending(ctx); err != nil || len(pending) != 0 {
+		t.Fatalf("Pending() after Up() = %v, %v, want none", pending, err)
+	}
+}
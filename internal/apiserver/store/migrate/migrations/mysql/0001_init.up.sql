@@ -0,0 +1,86 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The tables of the users, their secrets and policies, and the audit of the deleted policies. The
+-- existing tables are kept, so that the databases created by configs/iam.sql are adopted.
+
+CREATE TABLE IF NOT EXISTS `user` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL,
+  `name` varchar(45) NOT NULL,
+  `status` int(1) DEFAULT 1 COMMENT '1:可用，0:不可用',
+  `nickname` varchar(30) NOT NULL,
+  `password` varchar(255) NOT NULL,
+  `email` varchar(256) NOT NULL,
+  `phone` varchar(20) DEFAULT NULL,
+  `isAdmin` tinyint(1) unsigned NOT NULL DEFAULT 0 COMMENT '1: administrator, 0: non-administrator',
+  `extendShadow` longtext DEFAULT NULL,
+  `loginedAt` timestamp NULL DEFAULT NULL COMMENT 'last login time',
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp(),
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp() ON UPDATE current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_name` (`name`),
+  UNIQUE KEY `instanceID_UNIQUE` (`instanceID`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `policy` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL,
+  `name` varchar(45) NOT NULL,
+  `username` varchar(255) NOT NULL,
+  `policyShadow` longtext DEFAULT NULL,
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp(),
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp() ON UPDATE current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `instanceID_UNIQUE` (`instanceID`),
+  KEY `fk_policy_user_idx` (`username`),
+  CONSTRAINT `fk_policy_user` FOREIGN KEY (`username`) REFERENCES `user` (`name`) ON DELETE NO ACTION ON UPDATE NO ACTION
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `policy_audit` (
+  `id` bigint(20) unsigned NOT NULL,
+  `instanceID` varchar(32) DEFAULT NULL,
+  `name` varchar(45) NOT NULL,
+  `username` varchar(255) NOT NULL,
+  `policyShadow` longtext DEFAULT NULL,
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp(),
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp() ON UPDATE current_timestamp(),
+  `deletedAt` timestamp NOT NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  KEY `fk_policy_user_idx` (`username`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `secret` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL,
+  `name` varchar(45) NOT NULL,
+  `username` varchar(255) NOT NULL,
+  `secretID` varchar(36) NOT NULL,
+  `secretKey` varchar(255) NOT NULL,
+  `expires` int(64) unsigned NOT NULL DEFAULT 1534308590,
+  `description` varchar(255) NOT NULL,
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp(),
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp() ON UPDATE current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `instanceID_UNIQUE` (`instanceID`),
+  KEY `fk_secret_user_idx` (`username`),
+  CONSTRAINT `fk_secret_user` FOREIGN KEY (`username`) REFERENCES `user` (`name`) ON DELETE NO ACTION ON UPDATE NO ACTION
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- the deleted policies are kept in policy_audit.
+CREATE TRIGGER IF NOT EXISTS `policy_BEFORE_DELETE` BEFORE DELETE ON `policy` FOR EACH ROW
+BEGIN
+  insert into policy_audit values(old.id, old.instanceID, old.name, old.username, old.policyShadow,
+    old.extendShadow, old.createdAt, old.updatedAt, now());
+END;
+
+-- the resources of a user are deleted with it, before the foreign keys are checked.
+CREATE TRIGGER IF NOT EXISTS `user_BEFORE_DELETE` BEFORE DELETE ON `user` FOR EACH ROW
+BEGIN
+  delete from secret where username = old.name;
+  delete from policy where username = old.name;
+END;
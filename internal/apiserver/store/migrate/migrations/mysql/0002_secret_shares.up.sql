@@ -0,0 +1,22 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The secrets shared with other users, see iamctl secret share.
+
+CREATE TABLE IF NOT EXISTS `secret_shares` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL,
+  `name` varchar(45) NOT NULL COMMENT 'name of the shared secret',
+  `username` varchar(255) NOT NULL COMMENT 'owner of the shared secret',
+  `secretID` varchar(36) NOT NULL,
+  `targetUsername` varchar(255) NOT NULL,
+  `expiresAt` timestamp NOT NULL DEFAULT current_timestamp(),
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp(),
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp() ON UPDATE current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `instanceID_UNIQUE` (`instanceID`),
+  UNIQUE KEY `idx_secretID_targetUsername` (`secretID`,`targetUsername`),
+  KEY `idx_targetUsername` (`targetUsername`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
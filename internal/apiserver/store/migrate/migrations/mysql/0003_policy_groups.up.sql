@@ -0,0 +1,21 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The policy groups, managing related policies as a unit.
+
+CREATE TABLE IF NOT EXISTS `policy_groups` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL,
+  `name` varchar(45) NOT NULL,
+  `username` varchar(255) NOT NULL,
+  `description` varchar(255) DEFAULT NULL,
+  `policyIDs` longtext DEFAULT NULL COMMENT 'json array of the member policy names',
+  `applied` tinyint(1) unsigned NOT NULL DEFAULT 0 COMMENT '1: the member policies are enforced',
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp(),
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp() ON UPDATE current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `instanceID_UNIQUE` (`instanceID`),
+  UNIQUE KEY `idx_username_name` (`username`,`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
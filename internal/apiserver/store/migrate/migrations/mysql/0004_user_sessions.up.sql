@@ -0,0 +1,18 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The jwt sessions of the users, listed by GET /v1/users/:name/tokens.
+
+CREATE TABLE IF NOT EXISTS `user_sessions` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `jti` varchar(36) NOT NULL COMMENT 'id of the jwt token',
+  `username` varchar(255) NOT NULL,
+  `issued_at` timestamp NOT NULL DEFAULT current_timestamp(),
+  `expires_at` timestamp NOT NULL DEFAULT current_timestamp(),
+  `user_agent` varchar(255) NOT NULL DEFAULT '',
+  `client_ip` varchar(45) NOT NULL DEFAULT '',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `jti_UNIQUE` (`jti`),
+  KEY `idx_username_expires_at` (`username`,`expires_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
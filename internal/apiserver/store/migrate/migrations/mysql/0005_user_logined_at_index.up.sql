@@ -0,0 +1,7 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The users not logged in since a time are listed, see the last_login_before query of GET /v1/users.
+
+ALTER TABLE `user` ADD INDEX IF NOT EXISTS `idx_loginedAt` (`loginedAt`);
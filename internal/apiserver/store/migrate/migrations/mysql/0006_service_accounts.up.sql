@@ -0,0 +1,20 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The service accounts, issued service tokens at POST /v1/token/service.
+
+CREATE TABLE IF NOT EXISTS `service_accounts` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL,
+  `name` varchar(45) NOT NULL COMMENT 'service id, the sub claim of the service tokens',
+  `scope` varchar(255) NOT NULL COMMENT 'scope claim of the service tokens',
+  `description` varchar(255) DEFAULT NULL,
+  `apiKeyHash` varchar(64) NOT NULL COMMENT 'hex encoded sha256 of the api key',
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp(),
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp() ON UPDATE current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `instanceID_UNIQUE` (`instanceID`),
+  UNIQUE KEY `idx_name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -0,0 +1,11 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+DROP TRIGGER IF EXISTS `user_BEFORE_DELETE`;
+
+CREATE TRIGGER `user_BEFORE_DELETE` BEFORE DELETE ON `user` FOR EACH ROW
+BEGIN
+  delete from secret where username = old.name;
+  delete from policy where username = old.name;
+END;
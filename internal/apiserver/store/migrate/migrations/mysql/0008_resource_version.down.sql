@@ -0,0 +1,9 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+DROP TRIGGER IF EXISTS `policy_BEFORE_UPDATE`;
+DROP TRIGGER IF EXISTS `secret_BEFORE_UPDATE`;
+
+ALTER TABLE `policy` DROP COLUMN IF EXISTS `resourceVersion`;
+ALTER TABLE `secret` DROP COLUMN IF EXISTS `resourceVersion`;
@@ -0,0 +1,23 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The policies and the secrets are versioned: every update increments their resourceVersion, the updates
+-- of the api fail with a conflict if it changed since the resource was read.
+
+ALTER TABLE `policy` ADD COLUMN IF NOT EXISTS `resourceVersion` bigint(20) unsigned NOT NULL DEFAULT 1;
+ALTER TABLE `secret` ADD COLUMN IF NOT EXISTS `resourceVersion` bigint(20) unsigned NOT NULL DEFAULT 1;
+
+DROP TRIGGER IF EXISTS `policy_BEFORE_UPDATE`;
+
+CREATE TRIGGER `policy_BEFORE_UPDATE` BEFORE UPDATE ON `policy` FOR EACH ROW
+BEGIN
+  set new.resourceVersion = old.resourceVersion + 1;
+END;
+
+DROP TRIGGER IF EXISTS `secret_BEFORE_UPDATE`;
+
+CREATE TRIGGER `secret_BEFORE_UPDATE` BEFORE UPDATE ON `secret` FOR EACH ROW
+BEGIN
+  set new.resourceVersion = old.resourceVersion + 1;
+END;
@@ -0,0 +1,10 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The soft deleted users are deleted for good first, they would be live again without the column.
+
+DELETE FROM `user` WHERE `deletedAt` IS NOT NULL;
+
+ALTER TABLE `user` DROP INDEX IF EXISTS `idx_deletedAt`;
+ALTER TABLE `user` DROP COLUMN IF EXISTS `deletedAt`;
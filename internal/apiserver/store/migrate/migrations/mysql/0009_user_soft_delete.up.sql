@@ -0,0 +1,9 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The users are soft deleted: their deletedAt is set, and the stores exclude them and the resources they
+-- own from their reads. A user deleted for good, or whose name is reused, is deleted with its resources.
+
+ALTER TABLE `user` ADD COLUMN IF NOT EXISTS `deletedAt` timestamp NULL DEFAULT NULL COMMENT 'soft delete time';
+ALTER TABLE `user` ADD INDEX IF NOT EXISTS `idx_deletedAt` (`deletedAt`);
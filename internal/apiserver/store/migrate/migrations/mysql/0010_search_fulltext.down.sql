@@ -0,0 +1,9 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+ALTER TABLE `policy` DROP INDEX IF EXISTS `ft_username`;
+ALTER TABLE `policy` DROP INDEX IF EXISTS `ft_name`;
+ALTER TABLE `user` DROP INDEX IF EXISTS `ft_email`;
+ALTER TABLE `user` DROP INDEX IF EXISTS `ft_nickname`;
+ALTER TABLE `user` DROP INDEX IF EXISTS `ft_name`;
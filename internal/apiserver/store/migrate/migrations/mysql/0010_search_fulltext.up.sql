@@ -0,0 +1,12 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The FULLTEXT indexes of the searched columns rank the users and the policies of the same relevance
+-- found by the searches of the stores, see store.SearchOptions.
+
+ALTER TABLE `user` ADD FULLTEXT INDEX IF NOT EXISTS `ft_name` (`name`);
+ALTER TABLE `user` ADD FULLTEXT INDEX IF NOT EXISTS `ft_nickname` (`nickname`);
+ALTER TABLE `user` ADD FULLTEXT INDEX IF NOT EXISTS `ft_email` (`email`);
+ALTER TABLE `policy` ADD FULLTEXT INDEX IF NOT EXISTS `ft_name` (`name`);
+ALTER TABLE `policy` ADD FULLTEXT INDEX IF NOT EXISTS `ft_username` (`username`);
@@ -0,0 +1,8 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- A user has a single policy of a name, the creation of a policy of the same name fails with ErrDuplicateKey.
+-- The migration fails if a user already has several policies of a name, they must be renamed or deleted first.
+
+ALTER TABLE `policy` ADD UNIQUE INDEX IF NOT EXISTS `idx_username_name` (`username`,`name`);
@@ -0,0 +1,10 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+DROP TABLE IF EXISTS "secret";
+DROP TABLE IF EXISTS "policy_audit";
+DROP TABLE IF EXISTS "policy";
+DROP TABLE IF EXISTS "user";
+DROP FUNCTION IF EXISTS "user_delete_resources"();
+DROP FUNCTION IF EXISTS "policy_audit_insert"();
@@ -0,0 +1,97 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The tables of the users, their secrets and policies, and the audit of the deleted policies. The
+-- existing tables are kept, so that the databases created by configs/iam.postgres.sql are adopted.
+
+CREATE TABLE IF NOT EXISTS "user" (
+  "id" bigserial PRIMARY KEY,
+  "instanceID" varchar(32) DEFAULT NULL,
+  "name" varchar(45) NOT NULL,
+  "status" integer DEFAULT 1,
+  "nickname" varchar(30) NOT NULL,
+  "password" varchar(255) NOT NULL,
+  "email" varchar(256) NOT NULL,
+  "phone" varchar(20) DEFAULT NULL,
+  "isAdmin" smallint NOT NULL DEFAULT 0,
+  "extendShadow" text DEFAULT NULL,
+  "loginedAt" timestamp NULL DEFAULT NULL,
+  "createdAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "updatedAt" timestamp NOT NULL DEFAULT current_timestamp,
+  CONSTRAINT "user_idx_name" UNIQUE ("name"),
+  CONSTRAINT "user_instanceID_UNIQUE" UNIQUE ("instanceID")
+);
+COMMENT ON COLUMN "user"."status" IS '1:可用，0:不可用';
+COMMENT ON COLUMN "user"."isAdmin" IS '1: administrator, 0: non-administrator';
+COMMENT ON COLUMN "user"."loginedAt" IS 'last login time';
+
+CREATE TABLE IF NOT EXISTS "policy" (
+  "id" bigserial PRIMARY KEY,
+  "instanceID" varchar(32) DEFAULT NULL,
+  "name" varchar(45) NOT NULL,
+  "username" varchar(255) NOT NULL,
+  "policyShadow" text DEFAULT NULL,
+  "extendShadow" text DEFAULT NULL,
+  "createdAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "updatedAt" timestamp NOT NULL DEFAULT current_timestamp,
+  CONSTRAINT "policy_instanceID_UNIQUE" UNIQUE ("instanceID"),
+  CONSTRAINT "fk_policy_user" FOREIGN KEY ("username") REFERENCES "user" ("name") ON DELETE NO ACTION ON UPDATE NO ACTION
+);
+CREATE INDEX IF NOT EXISTS "fk_policy_user_idx" ON "policy" ("username");
+
+CREATE TABLE IF NOT EXISTS "policy_audit" (
+  "id" bigint PRIMARY KEY,
+  "instanceID" varchar(32) DEFAULT NULL,
+  "name" varchar(45) NOT NULL,
+  "username" varchar(255) NOT NULL,
+  "policyShadow" text DEFAULT NULL,
+  "extendShadow" text DEFAULT NULL,
+  "createdAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "updatedAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "deletedAt" timestamp NOT NULL DEFAULT current_timestamp
+);
+CREATE INDEX IF NOT EXISTS "fk_policy_audit_user_idx" ON "policy_audit" ("username");
+
+CREATE TABLE IF NOT EXISTS "secret" (
+  "id" bigserial PRIMARY KEY,
+  "instanceID" varchar(32) DEFAULT NULL,
+  "name" varchar(45) NOT NULL,
+  "username" varchar(255) NOT NULL,
+  "secretID" varchar(36) NOT NULL,
+  "secretKey" varchar(255) NOT NULL,
+  "expires" bigint NOT NULL DEFAULT 1534308590,
+  "description" varchar(255) NOT NULL,
+  "extendShadow" text DEFAULT NULL,
+  "createdAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "updatedAt" timestamp NOT NULL DEFAULT current_timestamp,
+  CONSTRAINT "secret_instanceID_UNIQUE" UNIQUE ("instanceID"),
+  CONSTRAINT "fk_secret_user" FOREIGN KEY ("username") REFERENCES "user" ("name") ON DELETE NO ACTION ON UPDATE NO ACTION
+);
+CREATE INDEX IF NOT EXISTS "fk_secret_user_idx" ON "secret" ("username");
+
+-- the deleted policies are kept in policy_audit.
+CREATE OR REPLACE FUNCTION "policy_audit_insert"() RETURNS trigger AS $$
+BEGIN
+  INSERT INTO "policy_audit" VALUES (old."id", old."instanceID", old."name", old."username",
+    old."policyShadow", old."extendShadow", old."createdAt", old."updatedAt", now());
+  RETURN old;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS "policy_BEFORE_DELETE" ON "policy";
+CREATE TRIGGER "policy_BEFORE_DELETE" BEFORE DELETE ON "policy"
+  FOR EACH ROW EXECUTE PROCEDURE "policy_audit_insert"();
+
+-- the resources of a user are deleted with it, before the foreign keys are checked.
+CREATE OR REPLACE FUNCTION "user_delete_resources"() RETURNS trigger AS $$
+BEGIN
+  DELETE FROM "secret" WHERE "username" = old."name";
+  DELETE FROM "policy" WHERE "username" = old."name";
+  RETURN old;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS "user_BEFORE_DELETE" ON "user";
+CREATE TRIGGER "user_BEFORE_DELETE" BEFORE DELETE ON "user"
+  FOR EACH ROW EXECUTE PROCEDURE "user_delete_resources"();
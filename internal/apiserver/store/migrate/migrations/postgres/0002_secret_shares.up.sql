@@ -0,0 +1,23 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The secrets shared with other users, see iamctl secret share.
+
+CREATE TABLE IF NOT EXISTS "secret_shares" (
+  "id" bigserial PRIMARY KEY,
+  "instanceID" varchar(32) DEFAULT NULL,
+  "name" varchar(45) NOT NULL,
+  "username" varchar(255) NOT NULL,
+  "secretID" varchar(36) NOT NULL,
+  "targetUsername" varchar(255) NOT NULL,
+  "expiresAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "extendShadow" text DEFAULT NULL,
+  "createdAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "updatedAt" timestamp NOT NULL DEFAULT current_timestamp,
+  CONSTRAINT "secret_shares_instanceID_UNIQUE" UNIQUE ("instanceID"),
+  CONSTRAINT "secret_shares_idx_secretID_targetUsername" UNIQUE ("secretID", "targetUsername")
+);
+CREATE INDEX IF NOT EXISTS "secret_shares_idx_targetUsername" ON "secret_shares" ("targetUsername");
+COMMENT ON COLUMN "secret_shares"."name" IS 'name of the shared secret';
+COMMENT ON COLUMN "secret_shares"."username" IS 'owner of the shared secret';
@@ -0,0 +1,22 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The policy groups, managing related policies as a unit.
+
+CREATE TABLE IF NOT EXISTS "policy_groups" (
+  "id" bigserial PRIMARY KEY,
+  "instanceID" varchar(32) DEFAULT NULL,
+  "name" varchar(45) NOT NULL,
+  "username" varchar(255) NOT NULL,
+  "description" varchar(255) DEFAULT NULL,
+  "policyIDs" text DEFAULT NULL,
+  "applied" boolean NOT NULL DEFAULT false,
+  "extendShadow" text DEFAULT NULL,
+  "createdAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "updatedAt" timestamp NOT NULL DEFAULT current_timestamp,
+  CONSTRAINT "policy_groups_instanceID_UNIQUE" UNIQUE ("instanceID"),
+  CONSTRAINT "policy_groups_idx_username_name" UNIQUE ("username", "name")
+);
+COMMENT ON COLUMN "policy_groups"."policyIDs" IS 'json array of the member policy names';
+COMMENT ON COLUMN "policy_groups"."applied" IS 'true: the member policies are enforced';
@@ -0,0 +1,18 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The jwt sessions of the users, listed by GET /v1/users/:name/tokens.
+
+CREATE TABLE IF NOT EXISTS "user_sessions" (
+  "id" bigserial PRIMARY KEY,
+  "jti" varchar(36) NOT NULL,
+  "username" varchar(255) NOT NULL,
+  "issued_at" timestamp NOT NULL DEFAULT current_timestamp,
+  "expires_at" timestamp NOT NULL DEFAULT current_timestamp,
+  "user_agent" varchar(255) NOT NULL DEFAULT '',
+  "client_ip" varchar(45) NOT NULL DEFAULT '',
+  CONSTRAINT "user_sessions_jti_UNIQUE" UNIQUE ("jti")
+);
+CREATE INDEX IF NOT EXISTS "user_sessions_idx_username_expires_at" ON "user_sessions" ("username", "expires_at");
+COMMENT ON COLUMN "user_sessions"."jti" IS 'id of the jwt token';
@@ -0,0 +1,22 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The service accounts, issued service tokens at POST /v1/token/service.
+
+CREATE TABLE IF NOT EXISTS "service_accounts" (
+  "id" bigserial PRIMARY KEY,
+  "instanceID" varchar(32) DEFAULT NULL,
+  "name" varchar(45) NOT NULL,
+  "scope" varchar(255) NOT NULL,
+  "description" varchar(255) DEFAULT NULL,
+  "apiKeyHash" varchar(64) NOT NULL,
+  "extendShadow" text DEFAULT NULL,
+  "createdAt" timestamp NOT NULL DEFAULT current_timestamp,
+  "updatedAt" timestamp NOT NULL DEFAULT current_timestamp,
+  CONSTRAINT "service_accounts_instanceID_UNIQUE" UNIQUE ("instanceID"),
+  CONSTRAINT "service_accounts_idx_name" UNIQUE ("name")
+);
+COMMENT ON COLUMN "service_accounts"."name" IS 'service id, the sub claim of the service tokens';
+COMMENT ON COLUMN "service_accounts"."scope" IS 'scope claim of the service tokens';
+COMMENT ON COLUMN "service_accounts"."apiKeyHash" IS 'hex encoded sha256 of the api key';
@@ -0,0 +1,11 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+CREATE OR REPLACE FUNCTION "user_delete_resources"() RETURNS trigger AS $$
+BEGIN
+  DELETE FROM "secret" WHERE "username" = old."name";
+  DELETE FROM "policy" WHERE "username" = old."name";
+  RETURN old;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,24 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The policies and the secrets are versioned: every update increments their resourceVersion, the updates
+-- of the api fail with a conflict if it changed since the resource was read.
+
+ALTER TABLE "policy" ADD COLUMN IF NOT EXISTS "resourceVersion" bigint NOT NULL DEFAULT 1;
+ALTER TABLE "secret" ADD COLUMN IF NOT EXISTS "resourceVersion" bigint NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION "resource_version_increment"() RETURNS trigger AS $$
+BEGIN
+  new."resourceVersion" := old."resourceVersion" + 1;
+  RETURN new;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS "policy_BEFORE_UPDATE" ON "policy";
+CREATE TRIGGER "policy_BEFORE_UPDATE" BEFORE UPDATE ON "policy"
+  FOR EACH ROW EXECUTE PROCEDURE "resource_version_increment"();
+
+DROP TRIGGER IF EXISTS "secret_BEFORE_UPDATE" ON "secret";
+CREATE TRIGGER "secret_BEFORE_UPDATE" BEFORE UPDATE ON "secret"
+  FOR EACH ROW EXECUTE PROCEDURE "resource_version_increment"();
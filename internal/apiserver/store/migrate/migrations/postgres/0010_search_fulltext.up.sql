@@ -0,0 +1,8 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The searches of the stores are not ranked by FULLTEXT indexes on postgres, the migration only keeps the
+-- versions of the engines aligned, see store.SearchOptions.
+
+SELECT 1;
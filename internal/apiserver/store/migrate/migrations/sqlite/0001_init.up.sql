@@ -0,0 +1,76 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The tables of the users, their secrets and policies, and the audit of the deleted policies.
+-- The names are case-insensitive like in mysql, see the NOCASE collation.
+
+CREATE TABLE IF NOT EXISTS `user` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL UNIQUE,
+  `name` varchar(45) NOT NULL UNIQUE COLLATE NOCASE,
+  `status` int(1) DEFAULT 1,
+  `nickname` varchar(30) NOT NULL,
+  `password` varchar(255) NOT NULL,
+  `email` varchar(256) NOT NULL COLLATE NOCASE,
+  `phone` varchar(20) DEFAULT NULL,
+  `isAdmin` tinyint(1) NOT NULL DEFAULT 0,
+  `extendShadow` longtext DEFAULT NULL,
+  `loginedAt` timestamp NULL DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp,
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp
+);
+
+CREATE TABLE IF NOT EXISTS `policy` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL UNIQUE,
+  `name` varchar(45) NOT NULL COLLATE NOCASE,
+  `username` varchar(255) NOT NULL COLLATE NOCASE REFERENCES `user` (`name`),
+  `policyShadow` longtext DEFAULT NULL,
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp,
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp
+);
+CREATE INDEX IF NOT EXISTS `fk_policy_user_idx` ON `policy` (`username`);
+
+CREATE TABLE IF NOT EXISTS `policy_audit` (
+  `id` integer PRIMARY KEY,
+  `instanceID` varchar(32) DEFAULT NULL,
+  `name` varchar(45) NOT NULL COLLATE NOCASE,
+  `username` varchar(255) NOT NULL COLLATE NOCASE,
+  `policyShadow` longtext DEFAULT NULL,
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp,
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp,
+  `deletedAt` timestamp NOT NULL DEFAULT current_timestamp
+);
+CREATE INDEX IF NOT EXISTS `fk_policy_audit_user_idx` ON `policy_audit` (`username`);
+
+CREATE TABLE IF NOT EXISTS `secret` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL UNIQUE,
+  `name` varchar(45) NOT NULL COLLATE NOCASE,
+  `username` varchar(255) NOT NULL COLLATE NOCASE REFERENCES `user` (`name`),
+  `secretID` varchar(36) NOT NULL,
+  `secretKey` varchar(255) NOT NULL,
+  `expires` int(64) NOT NULL DEFAULT 1534308590,
+  `description` varchar(255) NOT NULL,
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp,
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp
+);
+CREATE INDEX IF NOT EXISTS `fk_secret_user_idx` ON `secret` (`username`);
+
+-- the deleted policies are kept in policy_audit.
+CREATE TRIGGER IF NOT EXISTS `policy_BEFORE_DELETE` BEFORE DELETE ON `policy` FOR EACH ROW
+BEGIN
+  INSERT INTO `policy_audit` VALUES (old.`id`, old.`instanceID`, old.`name`, old.`username`,
+    old.`policyShadow`, old.`extendShadow`, old.`createdAt`, old.`updatedAt`, datetime('now', 'localtime'));
+END;
+
+-- the resources of a user are deleted with it, before the foreign keys are checked.
+CREATE TRIGGER IF NOT EXISTS `user_BEFORE_DELETE` BEFORE DELETE ON `user` FOR EACH ROW
+BEGIN
+  DELETE FROM `secret` WHERE `username` = old.`name`;
+  DELETE FROM `policy` WHERE `username` = old.`name`;
+END;
@@ -0,0 +1,20 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The secrets shared with other users, see iamctl secret share.
+
+CREATE TABLE IF NOT EXISTS `secret_shares` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL UNIQUE,
+  `name` varchar(45) NOT NULL COLLATE NOCASE,
+  `username` varchar(255) NOT NULL COLLATE NOCASE,
+  `secretID` varchar(36) NOT NULL,
+  `targetUsername` varchar(255) NOT NULL COLLATE NOCASE,
+  `expiresAt` timestamp NOT NULL DEFAULT current_timestamp,
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp,
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp,
+  UNIQUE (`secretID`, `targetUsername`)
+);
+CREATE INDEX IF NOT EXISTS `idx_targetUsername` ON `secret_shares` (`targetUsername`);
@@ -0,0 +1,19 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The policy groups, managing related policies as a unit.
+
+CREATE TABLE IF NOT EXISTS `policy_groups` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL UNIQUE,
+  `name` varchar(45) NOT NULL COLLATE NOCASE,
+  `username` varchar(255) NOT NULL COLLATE NOCASE,
+  `description` varchar(255) DEFAULT NULL,
+  `policyIDs` longtext DEFAULT NULL,
+  `applied` tinyint(1) NOT NULL DEFAULT 0,
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp,
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp,
+  UNIQUE (`username`, `name`)
+);
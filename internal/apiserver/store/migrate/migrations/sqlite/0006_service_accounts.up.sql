@@ -0,0 +1,17 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The service accounts, issued service tokens at POST /v1/token/service.
+
+CREATE TABLE IF NOT EXISTS `service_accounts` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL UNIQUE,
+  `name` varchar(45) NOT NULL UNIQUE COLLATE NOCASE,
+  `scope` varchar(255) NOT NULL,
+  `description` varchar(255) DEFAULT NULL,
+  `apiKeyHash` varchar(64) NOT NULL,
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp,
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp
+);
@@ -0,0 +1,11 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+DROP TRIGGER IF EXISTS `user_BEFORE_DELETE`;
+
+CREATE TRIGGER `user_BEFORE_DELETE` BEFORE DELETE ON `user` FOR EACH ROW
+BEGIN
+  DELETE FROM `secret` WHERE `username` = old.`name`;
+  DELETE FROM `policy` WHERE `username` = old.`name`;
+END;
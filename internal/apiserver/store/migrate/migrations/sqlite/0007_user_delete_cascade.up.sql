@@ -0,0 +1,16 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The shares, policy groups and sessions of a user are deleted with it too.
+
+DROP TRIGGER IF EXISTS `user_BEFORE_DELETE`;
+
+CREATE TRIGGER `user_BEFORE_DELETE` BEFORE DELETE ON `user` FOR EACH ROW
+BEGIN
+  DELETE FROM `secret` WHERE `username` = old.`name`;
+  DELETE FROM `policy` WHERE `username` = old.`name`;
+  DELETE FROM `secret_shares` WHERE `username` = old.`name` OR `targetUsername` = old.`name`;
+  DELETE FROM `policy_groups` WHERE `username` = old.`name`;
+  DELETE FROM `user_sessions` WHERE `username` = old.`name`;
+END;
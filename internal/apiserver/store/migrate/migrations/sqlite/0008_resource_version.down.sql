@@ -0,0 +1,54 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The sqlite of the driver cannot drop a column, the tables are copied without it. The legacy renames
+-- ignore the triggers of the other tables, which reference the dropped tables meanwhile.
+
+DROP TRIGGER IF EXISTS `policy_AFTER_UPDATE`;
+DROP TRIGGER IF EXISTS `secret_AFTER_UPDATE`;
+
+PRAGMA legacy_alter_table = ON;
+
+CREATE TABLE `policy_copy` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL UNIQUE,
+  `name` varchar(45) NOT NULL COLLATE NOCASE,
+  `username` varchar(255) NOT NULL COLLATE NOCASE REFERENCES `user` (`name`),
+  `policyShadow` longtext DEFAULT NULL,
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp,
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp
+);
+INSERT INTO `policy_copy` SELECT `id`, `instanceID`, `name`, `username`, `policyShadow`, `extendShadow`,
+  `createdAt`, `updatedAt` FROM `policy`;
+DROP TABLE `policy`;
+ALTER TABLE `policy_copy` RENAME TO `policy`;
+CREATE INDEX IF NOT EXISTS `fk_policy_user_idx` ON `policy` (`username`);
+
+CREATE TRIGGER IF NOT EXISTS `policy_BEFORE_DELETE` BEFORE DELETE ON `policy` FOR EACH ROW
+BEGIN
+  INSERT INTO `policy_audit` VALUES (old.`id`, old.`instanceID`, old.`name`, old.`username`,
+    old.`policyShadow`, old.`extendShadow`, old.`createdAt`, old.`updatedAt`, datetime('now', 'localtime'));
+END;
+
+CREATE TABLE `secret_copy` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL UNIQUE,
+  `name` varchar(45) NOT NULL COLLATE NOCASE,
+  `username` varchar(255) NOT NULL COLLATE NOCASE REFERENCES `user` (`name`),
+  `secretID` varchar(36) NOT NULL,
+  `secretKey` varchar(255) NOT NULL,
+  `expires` int(64) NOT NULL DEFAULT 1534308590,
+  `description` varchar(255) NOT NULL,
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp,
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp
+);
+INSERT INTO `secret_copy` SELECT `id`, `instanceID`, `name`, `username`, `secretID`, `secretKey`, `expires`,
+  `description`, `extendShadow`, `createdAt`, `updatedAt` FROM `secret`;
+DROP TABLE `secret`;
+ALTER TABLE `secret_copy` RENAME TO `secret`;
+CREATE INDEX IF NOT EXISTS `fk_secret_user_idx` ON `secret` (`username`);
+
+PRAGMA legacy_alter_table = OFF;
@@ -0,0 +1,20 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The policies and the secrets are versioned: every update increments their resourceVersion, the updates
+-- of the api fail with a conflict if it changed since the resource was read.
+-- The sqlite triggers cannot change the updated row, it is updated again, which fires no trigger.
+
+ALTER TABLE `policy` ADD COLUMN `resourceVersion` integer NOT NULL DEFAULT 1;
+ALTER TABLE `secret` ADD COLUMN `resourceVersion` integer NOT NULL DEFAULT 1;
+
+CREATE TRIGGER IF NOT EXISTS `policy_AFTER_UPDATE` AFTER UPDATE ON `policy` FOR EACH ROW
+BEGIN
+  UPDATE `policy` SET `resourceVersion` = old.`resourceVersion` + 1 WHERE `id` = new.`id`;
+END;
+
+CREATE TRIGGER IF NOT EXISTS `secret_AFTER_UPDATE` AFTER UPDATE ON `secret` FOR EACH ROW
+BEGIN
+  UPDATE `secret` SET `resourceVersion` = old.`resourceVersion` + 1 WHERE `id` = new.`id`;
+END;
@@ -0,0 +1,43 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- The soft deleted users are deleted for good first, they would be live again without the column.
+
+DELETE FROM `user` WHERE `deletedAt` IS NOT NULL;
+
+-- The sqlite of the driver cannot drop a column, the table is created again without it. The foreign keys
+-- of the tables referencing it are deferred: they are checked again when the users are copied back.
+
+PRAGMA defer_foreign_keys = ON;
+
+CREATE TEMP TABLE `user_rows` AS SELECT `id`, `instanceID`, `name`, `status`, `nickname`, `password`, `email`,
+  `phone`, `isAdmin`, `extendShadow`, `loginedAt`, `createdAt`, `updatedAt` FROM `user`;
+DROP TABLE `user`;
+
+CREATE TABLE `user` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL UNIQUE,
+  `name` varchar(45) NOT NULL UNIQUE COLLATE NOCASE,
+  `status` int(1) DEFAULT 1,
+  `nickname` varchar(30) NOT NULL,
+  `password` varchar(255) NOT NULL,
+  `email` varchar(256) NOT NULL COLLATE NOCASE,
+  `phone` varchar(20) DEFAULT NULL,
+  `isAdmin` tinyint(1) NOT NULL DEFAULT 0,
+  `extendShadow` longtext DEFAULT NULL,
+  `loginedAt` timestamp NULL DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp,
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp
+);
+INSERT INTO `user` SELECT * FROM `user_rows`;
+DROP TABLE `user_rows`;
+CREATE INDEX IF NOT EXISTS `idx_loginedAt` ON `user` (`loginedAt`);
+CREATE TRIGGER `user_BEFORE_DELETE` BEFORE DELETE ON `user` FOR EACH ROW
+BEGIN
+  DELETE FROM `secret` WHERE `username` = old.`name`;
+  DELETE FROM `policy` WHERE `username` = old.`name`;
+  DELETE FROM `secret_shares` WHERE `username` = old.`name` OR `targetUsername` = old.`name`;
+  DELETE FROM `policy_groups` WHERE `username` = old.`name`;
+  DELETE FROM `user_sessions` WHERE `username` = old.`name`;
+END;
@@ -0,0 +1,7 @@
+-- Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+-- Use of this source code is governed by a MIT style
+-- license that can be found in the LICENSE file.
+
+-- Nothing to revert, see 0010_search_fulltext.up.sql.
+
+SELECT 1;
@@ -3,7 +3,7 @@
 // license that can be found in the LICENSE file.
 
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/marmotedu/iam/internal/apiserver/store (interfaces: Factory,UserStore,SecretStore,PolicyStore)
+// Source: github.com/marmotedu/iam/internal/apiserver/store (interfaces: Factory,UserStore,SecretStore,PolicyStore,SecretShareStore,PolicyGroupStore,UserSessionStore,ServiceAccountStore)
 
 // Package store is a generated GoMock package.
 package store
@@ -11,10 +11,12 @@ package store
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	v1 "github.com/marmotedu/api/apiserver/v1"
 	v10 "github.com/marmotedu/component-base/pkg/meta/v1"
+	v11 "github.com/marmotedu/iam/internal/apiserver/model/v1"
 )
 
 // MockFactory is a mock of Factory interface.
@@ -82,6 +84,34 @@ func (mr *MockFactoryMockRecorder) PolicyAudits() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PolicyAudits", reflect.TypeOf((*MockFactory)(nil).PolicyAudits))
 }
 
+// PolicyGroups mocks base method.
+func (m *MockFactory) PolicyGroups() PolicyGroupStore {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PolicyGroups")
+	ret0, _ := ret[0].(PolicyGroupStore)
+	return ret0
+}
+
+// PolicyGroups indicates an expected call of PolicyGroups.
+func (mr *MockFactoryMockRecorder) PolicyGroups() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PolicyGroups", reflect.TypeOf((*MockFactory)(nil).PolicyGroups))
+}
+
+// SecretShares mocks base method.
+func (m *MockFactory) SecretShares() SecretShareStore {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SecretShares")
+	ret0, _ := ret[0].(SecretShareStore)
+	return ret0
+}
+
+// SecretShares indicates an expected call of SecretShares.
+func (mr *MockFactoryMockRecorder) SecretShares() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SecretShares", reflect.TypeOf((*MockFactory)(nil).SecretShares))
+}
+
 // Secrets mocks base method.
 func (m *MockFactory) Secrets() SecretStore {
 	m.ctrl.T.Helper()
@@ -96,6 +126,34 @@ func (mr *MockFactoryMockRecorder) Secrets() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Secrets", reflect.TypeOf((*MockFactory)(nil).Secrets))
 }
 
+// ServiceAccounts mocks base method.
+func (m *MockFactory) ServiceAccounts() ServiceAccountStore {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ServiceAccounts")
+	ret0, _ := ret[0].(ServiceAccountStore)
+	return ret0
+}
+
+// ServiceAccounts indicates an expected call of ServiceAccounts.
+func (mr *MockFactoryMockRecorder) ServiceAccounts() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ServiceAccounts", reflect.TypeOf((*MockFactory)(nil).ServiceAccounts))
+}
+
+// UserSessions mocks base method.
+func (m *MockFactory) UserSessions() UserSessionStore {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserSessions")
+	ret0, _ := ret[0].(UserSessionStore)
+	return ret0
+}
+
+// UserSessions indicates an expected call of UserSessions.
+func (mr *MockFactoryMockRecorder) UserSessions() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserSessions", reflect.TypeOf((*MockFactory)(nil).UserSessions))
+}
+
 // Users mocks base method.
 func (m *MockFactory) Users() UserStore {
 	m.ctrl.T.Helper()
@@ -110,6 +168,20 @@ func (mr *MockFactoryMockRecorder) Users() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Users", reflect.TypeOf((*MockFactory)(nil).Users))
 }
 
+// WithTransaction mocks base method.
+func (m *MockFactory) WithTransaction(arg0 context.Context, arg1 func(Factory) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTransaction", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithTransaction indicates an expected call of WithTransaction.
+func (mr *MockFactoryMockRecorder) WithTransaction(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTransaction", reflect.TypeOf((*MockFactory)(nil).WithTransaction), arg0, arg1)
+}
+
 // MockUserStore is a mock of UserStore interface.
 type MockUserStore struct {
 	ctrl     *gomock.Controller
@@ -147,6 +219,21 @@ func (mr *MockUserStoreMockRecorder) Create(arg0, arg1, arg2 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUserStore)(nil).Create), arg0, arg1, arg2)
 }
 
+// CreateBatch mocks base method.
+func (m *MockUserStore) CreateBatch(arg0 context.Context, arg1 []*v1.User, arg2 v10.CreateOptions) (map[int]error, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBatch", arg0, arg1, arg2)
+	ret0, _ := ret[0].(map[int]error)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateBatch indicates an expected call of CreateBatch.
+func (mr *MockUserStoreMockRecorder) CreateBatch(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBatch", reflect.TypeOf((*MockUserStore)(nil).CreateBatch), arg0, arg1, arg2)
+}
+
 // Delete mocks base method.
 func (m *MockUserStore) Delete(arg0 context.Context, arg1 string, arg2 v10.DeleteOptions) error {
 	m.ctrl.T.Helper()
@@ -205,6 +292,36 @@ func (mr *MockUserStoreMockRecorder) List(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockUserStore)(nil).List), arg0, arg1)
 }
 
+// ListInactive mocks base method.
+func (m *MockUserStore) ListInactive(arg0 context.Context, arg1 time.Time, arg2 v10.ListOptions) (*v1.UserList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListInactive", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*v1.UserList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListInactive indicates an expected call of ListInactive.
+func (mr *MockUserStoreMockRecorder) ListInactive(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListInactive", reflect.TypeOf((*MockUserStore)(nil).ListInactive), arg0, arg1, arg2)
+}
+
+// Search mocks base method.
+func (m *MockUserStore) Search(arg0 context.Context, arg1 SearchOptions, arg2 v10.ListOptions) (*v1.UserList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*v1.UserList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockUserStoreMockRecorder) Search(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockUserStore)(nil).Search), arg0, arg1, arg2)
+}
+
 // Update mocks base method.
 func (m *MockUserStore) Update(arg0 context.Context, arg1 *v1.User, arg2 v10.UpdateOptions) error {
 	m.ctrl.T.Helper()
@@ -299,6 +416,21 @@ func (mr *MockSecretStoreMockRecorder) Get(arg0, arg1, arg2, arg3 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockSecretStore)(nil).Get), arg0, arg1, arg2, arg3)
 }
 
+// GetVersioned mocks base method.
+func (m *MockSecretStore) GetVersioned(arg0 context.Context, arg1, arg2 string, arg3 v10.GetOptions) (*v11.VersionedSecret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVersioned", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*v11.VersionedSecret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVersioned indicates an expected call of GetVersioned.
+func (mr *MockSecretStoreMockRecorder) GetVersioned(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVersioned", reflect.TypeOf((*MockSecretStore)(nil).GetVersioned), arg0, arg1, arg2, arg3)
+}
+
 // List mocks base method.
 func (m *MockSecretStore) List(arg0 context.Context, arg1 string, arg2 v10.ListOptions) (*v1.SecretList, error) {
 	m.ctrl.T.Helper()
@@ -328,6 +460,20 @@ func (mr *MockSecretStoreMockRecorder) Update(arg0, arg1, arg2 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockSecretStore)(nil).Update), arg0, arg1, arg2)
 }
 
+// UpdateVersioned mocks base method.
+func (m *MockSecretStore) UpdateVersioned(arg0 context.Context, arg1 *v11.VersionedSecret, arg2 v10.UpdateOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateVersioned", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateVersioned indicates an expected call of UpdateVersioned.
+func (mr *MockSecretStoreMockRecorder) UpdateVersioned(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateVersioned", reflect.TypeOf((*MockSecretStore)(nil).UpdateVersioned), arg0, arg1, arg2)
+}
+
 // MockPolicyStore is a mock of PolicyStore interface.
 type MockPolicyStore struct {
 	ctrl     *gomock.Controller
@@ -365,6 +511,21 @@ func (mr *MockPolicyStoreMockRecorder) Create(arg0, arg1, arg2 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockPolicyStore)(nil).Create), arg0, arg1, arg2)
 }
 
+// CreateBatch mocks base method.
+func (m *MockPolicyStore) CreateBatch(arg0 context.Context, arg1 []*v1.Policy, arg2 v10.CreateOptions) (map[int]error, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBatch", arg0, arg1, arg2)
+	ret0, _ := ret[0].(map[int]error)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateBatch indicates an expected call of CreateBatch.
+func (mr *MockPolicyStoreMockRecorder) CreateBatch(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBatch", reflect.TypeOf((*MockPolicyStore)(nil).CreateBatch), arg0, arg1, arg2)
+}
+
 // Delete mocks base method.
 func (m *MockPolicyStore) Delete(arg0 context.Context, arg1, arg2 string, arg3 v10.DeleteOptions) error {
 	m.ctrl.T.Helper()
@@ -408,6 +569,21 @@ func (mr *MockPolicyStoreMockRecorder) Get(arg0, arg1, arg2, arg3 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockPolicyStore)(nil).Get), arg0, arg1, arg2, arg3)
 }
 
+// GetVersioned mocks base method.
+func (m *MockPolicyStore) GetVersioned(arg0 context.Context, arg1, arg2 string, arg3 v10.GetOptions) (*v11.VersionedPolicy, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVersioned", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*v11.VersionedPolicy)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVersioned indicates an expected call of GetVersioned.
+func (mr *MockPolicyStoreMockRecorder) GetVersioned(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVersioned", reflect.TypeOf((*MockPolicyStore)(nil).GetVersioned), arg0, arg1, arg2, arg3)
+}
+
 // List mocks base method.
 func (m *MockPolicyStore) List(arg0 context.Context, arg1 string, arg2 v10.ListOptions) (*v1.PolicyList, error) {
 	m.ctrl.T.Helper()
@@ -423,6 +599,21 @@ func (mr *MockPolicyStoreMockRecorder) List(arg0, arg1, arg2 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockPolicyStore)(nil).List), arg0, arg1, arg2)
 }
 
+// Search mocks base method.
+func (m *MockPolicyStore) Search(arg0 context.Context, arg1 string, arg2 SearchOptions, arg3 v10.ListOptions) (*v1.PolicyList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*v1.PolicyList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockPolicyStoreMockRecorder) Search(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockPolicyStore)(nil).Search), arg0, arg1, arg2, arg3)
+}
+
 // Update mocks base method.
 func (m *MockPolicyStore) Update(arg0 context.Context, arg1 *v1.Policy, arg2 v10.UpdateOptions) error {
 	m.ctrl.T.Helper()
@@ -436,3 +627,311 @@ func (mr *MockPolicyStoreMockRecorder) Update(arg0, arg1, arg2 interface{}) *gom
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockPolicyStore)(nil).Update), arg0, arg1, arg2)
 }
+
+// UpdateVersioned mocks base method.
+func (m *MockPolicyStore) UpdateVersioned(arg0 context.Context, arg1 *v11.VersionedPolicy, arg2 v10.UpdateOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateVersioned", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateVersioned indicates an expected call of UpdateVersioned.
+func (mr *MockPolicyStoreMockRecorder) UpdateVersioned(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateVersioned", reflect.TypeOf((*MockPolicyStore)(nil).UpdateVersioned), arg0, arg1, arg2)
+}
+
+// MockSecretShareStore is a mock of SecretShareStore interface.
+type MockSecretShareStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockSecretShareStoreMockRecorder
+}
+
+// MockSecretShareStoreMockRecorder is the mock recorder for MockSecretShareStore.
+type MockSecretShareStoreMockRecorder struct {
+	mock *MockSecretShareStore
+}
+
+// NewMockSecretShareStore creates a new mock instance.
+func NewMockSecretShareStore(ctrl *gomock.Controller) *MockSecretShareStore {
+	mock := &MockSecretShareStore{ctrl: ctrl}
+	mock.recorder = &MockSecretShareStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSecretShareStore) EXPECT() *MockSecretShareStoreMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockSecretShareStore) Create(arg0 context.Context, arg1 *v11.SecretShare, arg2 v10.CreateOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockSecretShareStoreMockRecorder) Create(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSecretShareStore)(nil).Create), arg0, arg1, arg2)
+}
+
+// List mocks base method.
+func (m *MockSecretShareStore) List(arg0 context.Context, arg1 string, arg2 v10.ListOptions) (*v11.SecretShareList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*v11.SecretShareList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockSecretShareStoreMockRecorder) List(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSecretShareStore)(nil).List), arg0, arg1, arg2)
+}
+
+// MockPolicyGroupStore is a mock of PolicyGroupStore interface.
+type MockPolicyGroupStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockPolicyGroupStoreMockRecorder
+}
+
+// MockPolicyGroupStoreMockRecorder is the mock recorder for MockPolicyGroupStore.
+type MockPolicyGroupStoreMockRecorder struct {
+	mock *MockPolicyGroupStore
+}
+
+// NewMockPolicyGroupStore creates a new mock instance.
+func NewMockPolicyGroupStore(ctrl *gomock.Controller) *MockPolicyGroupStore {
+	mock := &MockPolicyGroupStore{ctrl: ctrl}
+	mock.recorder = &MockPolicyGroupStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPolicyGroupStore) EXPECT() *MockPolicyGroupStoreMockRecorder {
+	return m.recorder
+}
+
+// Apply mocks base method.
+func (m *MockPolicyGroupStore) Apply(arg0 context.Context, arg1 string, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Apply", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Apply indicates an expected call of Apply.
+func (mr *MockPolicyGroupStoreMockRecorder) Apply(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Apply", reflect.TypeOf((*MockPolicyGroupStore)(nil).Apply), arg0, arg1, arg2)
+}
+
+// Create mocks base method.
+func (m *MockPolicyGroupStore) Create(arg0 context.Context, arg1 *v11.PolicyGroup, arg2 v10.CreateOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockPolicyGroupStoreMockRecorder) Create(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockPolicyGroupStore)(nil).Create), arg0, arg1, arg2)
+}
+
+// Delete mocks base method.
+func (m *MockPolicyGroupStore) Delete(arg0 context.Context, arg1 string, arg2 string, arg3 v10.DeleteOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockPolicyGroupStoreMockRecorder) Delete(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockPolicyGroupStore)(nil).Delete), arg0, arg1, arg2, arg3)
+}
+
+// Get mocks base method.
+func (m *MockPolicyGroupStore) Get(arg0 context.Context, arg1 string, arg2 string, arg3 v10.GetOptions) (*v11.PolicyGroup, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*v11.PolicyGroup)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockPolicyGroupStoreMockRecorder) Get(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockPolicyGroupStore)(nil).Get), arg0, arg1, arg2, arg3)
+}
+
+// List mocks base method.
+func (m *MockPolicyGroupStore) List(arg0 context.Context, arg1 string, arg2 v10.ListOptions) (*v11.PolicyGroupList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*v11.PolicyGroupList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockPolicyGroupStoreMockRecorder) List(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockPolicyGroupStore)(nil).List), arg0, arg1, arg2)
+}
+
+// Update mocks base method.
+func (m *MockPolicyGroupStore) Update(arg0 context.Context, arg1 *v11.PolicyGroup, arg2 v10.UpdateOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockPolicyGroupStoreMockRecorder) Update(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockPolicyGroupStore)(nil).Update), arg0, arg1, arg2)
+}
+
+// MockUserSessionStore is a mock of UserSessionStore interface.
+type MockUserSessionStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserSessionStoreMockRecorder
+}
+
+// MockUserSessionStoreMockRecorder is the mock recorder for MockUserSessionStore.
+type MockUserSessionStoreMockRecorder struct {
+	mock *MockUserSessionStore
+}
+
+// NewMockUserSessionStore creates a new mock instance.
+func NewMockUserSessionStore(ctrl *gomock.Controller) *MockUserSessionStore {
+	mock := &MockUserSessionStore{ctrl: ctrl}
+	mock.recorder = &MockUserSessionStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserSessionStore) EXPECT() *MockUserSessionStoreMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockUserSessionStore) Create(arg0 context.Context, arg1 *v11.UserSession, arg2 v10.CreateOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockUserSessionStoreMockRecorder) Create(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUserSessionStore)(nil).Create), arg0, arg1, arg2)
+}
+
+// List mocks base method.
+func (m *MockUserSessionStore) List(arg0 context.Context, arg1 string, arg2 v10.ListOptions) (*v11.UserSessionList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*v11.UserSessionList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockUserSessionStoreMockRecorder) List(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockUserSessionStore)(nil).List), arg0, arg1, arg2)
+}
+
+// MockServiceAccountStore is a mock of ServiceAccountStore interface.
+type MockServiceAccountStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockServiceAccountStoreMockRecorder
+}
+
+// MockServiceAccountStoreMockRecorder is the mock recorder for MockServiceAccountStore.
+type MockServiceAccountStoreMockRecorder struct {
+	mock *MockServiceAccountStore
+}
+
+// NewMockServiceAccountStore creates a new mock instance.
+func NewMockServiceAccountStore(ctrl *gomock.Controller) *MockServiceAccountStore {
+	mock := &MockServiceAccountStore{ctrl: ctrl}
+	mock.recorder = &MockServiceAccountStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockServiceAccountStore) EXPECT() *MockServiceAccountStoreMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockServiceAccountStore) Create(arg0 context.Context, arg1 *v11.ServiceAccount, arg2 v10.CreateOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockServiceAccountStoreMockRecorder) Create(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockServiceAccountStore)(nil).Create), arg0, arg1, arg2)
+}
+
+// Delete mocks base method.
+func (m *MockServiceAccountStore) Delete(arg0 context.Context, arg1 string, arg2 v10.DeleteOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockServiceAccountStoreMockRecorder) Delete(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockServiceAccountStore)(nil).Delete), arg0, arg1, arg2)
+}
+
+// Get mocks base method.
+func (m *MockServiceAccountStore) Get(arg0 context.Context, arg1 string, arg2 v10.GetOptions) (*v11.ServiceAccount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*v11.ServiceAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockServiceAccountStoreMockRecorder) Get(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockServiceAccountStore)(nil).Get), arg0, arg1, arg2)
+}
+
+// List mocks base method.
+func (m *MockServiceAccountStore) List(arg0 context.Context, arg1 v10.ListOptions) (*v11.ServiceAccountList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0, arg1)
+	ret0, _ := ret[0].(*v11.ServiceAccountList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockServiceAccountStoreMockRecorder) List(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockServiceAccountStore)(nil).List), arg0, arg1)
+}
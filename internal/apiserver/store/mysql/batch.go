@@ -0,0 +1,110 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"github.com/marmotedu/component-base/pkg/util/idutil"
+	"gorm.io/gorm"
+)
+
+// defaultBatchSize is the number of rows inserted by a statement of the batch creations, unless the
+// factory was created with FactoryOptions.BatchSize.
+const defaultBatchSize = 500
+
+// batchPlugin is a gorm plugin which only carries the batch size of the batch creations of a db, shared by
+// its sessions and transactions, see batchSize.
+type batchPlugin struct {
+	size int
+}
+
+var _ gorm.Plugin = (*batchPlugin)(nil)
+
+// Name returns the name of the batch plugin.
+func (p *batchPlugin) Name() string {
+	return "batchPlugin"
+}
+
+// Initialize registers nothing, the batch size is read by the stores.
+func (p *batchPlugin) Initialize(db *gorm.DB) error {
+	return nil
+}
+
+// batchSize returns the number of rows inserted by a statement of the batch creations of db.
+func batchSize(db *gorm.DB) int {
+	if plugin, ok := db.Config.Plugins[(&batchPlugin{}).Name()].(*batchPlugin); ok {
+		return plugin.size
+	}
+
+	return defaultBatchSize
+}
+
+// chunks calls fn with the bounds of the chunks of n rows, of the batch size of db, until it fails.
+func chunks(db *gorm.DB, n int, fn func(start, end int) error) error {
+	size := batchSize(db)
+	for start := 0; start < n; start += size {
+		end := start + size
+		if end > n {
+			end = n
+		}
+
+		if err := fn(start, end); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createBatch inserts the n rows of a batch creation, by chunks of the batch size of tx with a multi-row
+// INSERT each, rows returning the rows of [start, end) as a slice of models. A chunk violating a unique key
+// is rolled back to its savepoint and inserted again row by row, each in its own savepoint: the rows
+// violating a unique key are skipped and returned by their index, with store.ErrDuplicateKey. The other
+// errors fail the whole batch. tx must be a transaction, the batch is created once it is committed.
+//
+// The hooks of the models are skipped, so that their AfterCreate does not update each row after the
+// INSERT: the rows must be prepared by the callers, with their BeforeCreate hook and an instanceID of
+// batchInstanceID.
+func createBatch(tx *gorm.DB, n int, rows func(start, end int) interface{}) (map[int]error, error) {
+	failed := map[int]error{}
+	tx = tx.Session(&gorm.Session{SkipHooks: true})
+
+	err := chunks(tx, n, func(start, end int) error {
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			return tx.Create(rows(start, end)).Error
+		})
+		if err == nil || !isDuplicateKey(err) {
+			return translateError(err)
+		}
+
+		for i := start; i < end; i++ {
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				return tx.Create(rows(i, i+1)).Error
+			})
+			if err == nil {
+				continue
+			}
+
+			if !isDuplicateKey(err) {
+				return err
+			}
+
+			failed[i] = translateError(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return failed, nil
+}
+
+// batchInstanceID returns the instanceID of a row of a batch creation, with prefix. It can not be derived
+// from the id of the row as by the AfterCreate hook of the models, which is only known once inserted, so
+// it is derived from a unique id generated instead.
+func batchInstanceID(prefix string) string {
+	return idutil.GetInstanceID(idutil.GetIntID(), prefix)
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+)
+
+func TestChunks(t *testing.T) {
+	tests := []struct {
+		name      string
+		batchSize int
+		n         int
+		want      [][2]int
+	}{
+		{name: "no rows", batchSize: 2},
+		{name: "last chunk partial", batchSize: 2, n: 5, want: [][2]int{{0, 2}, {2, 4}, {4, 5}}},
+		{name: "last chunk full", batchSize: 2, n: 4, want: [][2]int{{0, 2}, {2, 4}}},
+		{name: "default batch size", n: 1200, want: [][2]int{{0, 500}, {500, 1000}, {1000, 1200}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds, _ := newMockDatastore(t)
+			if tt.batchSize > 0 {
+				if err := ds.db.Use(&batchPlugin{size: tt.batchSize}); err != nil {
+					t.Fatalf("Use() error = %v", err)
+				}
+			}
+
+			var got [][2]int
+			err := chunks(ds.db, tt.n, func(start, end int) error {
+				got = append(got, [2]int{start, end})
+
+				return nil
+			})
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunks() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicies_CreateBatch(t *testing.T) {
+	ds, mock := newMockDatastore(t)
+
+	// the rows are inserted by a single statement, without updating their instanceID afterwards.
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `policy`").WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectCommit()
+
+	batch := []*v1.Policy{
+		{ObjectMeta: metav1.ObjectMeta{Name: "p1"}, Username: "colin"},
+		{ObjectMeta: metav1.ObjectMeta{Name: "p2"}, Username: "colin"},
+	}
+
+	failed, err := newPolicies(ds).CreateBatch(context.TODO(), batch, metav1.CreateOptions{})
+	if err != nil || len(failed) != 0 {
+		t.Fatalf("CreateBatch() = %v, %v", failed, err)
+	}
+
+	if batch[0].InstanceID == "" || batch[0].InstanceID == batch[1].InstanceID {
+		t.Errorf("CreateBatch() instanceIDs = %q, %q, want unique ones", batch[0].InstanceID, batch[1].InstanceID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,607 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sony/gobreaker"
+	"gorm.io/gorm"
+
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// The operation types which have their own circuit breaker, so that the reads are still served
+// when only the writes fail, e.g. when mysql is read only.
+const (
+	operationRead  = "read"
+	operationWrite = "write"
+)
+
+// circuitBreakers guard the store methods, once threshold consecutive operations of a type failed,
+// the operations of this type fail fast with code.ErrDatabaseUnavailable for timeout instead of
+// waiting for the connection timeouts of mysql. The breakers are nil if disabled. The reads failing
+// with a transient error are retried by retry first, if set.
+type circuitBreakers struct {
+	read  *gobreaker.CircuitBreaker
+	write *gobreaker.CircuitBreaker
+	retry *retrier
+}
+
+func newCircuitBreakers(threshold uint32, timeout time.Duration) *circuitBreakers {
+	settings := func(operation string) gobreaker.Settings {
+		return gobreaker.Settings{
+			Name:    operation,
+			Timeout: timeout,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return counts.ConsecutiveFailures >= threshold
+			},
+			OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+				log.Warnw("MySQL circuit breaker state changed", "operation", name,
+					"from", from.String(), "to", to.String())
+			},
+			IsSuccessful: isSuccessful,
+		}
+	}
+
+	return &circuitBreakers{
+		read:  gobreaker.NewCircuitBreaker(settings(operationRead)),
+		write: gobreaker.NewCircuitBreaker(settings(operationWrite)),
+	}
+}
+
+// isSuccessful reports whether the database answered the operation. The errors returned by the database
+// itself, like a duplicate entry, and the business errors, like a user not found, do not trip the breakers.
+func isSuccessful(err error) bool {
+	switch {
+	case err == nil, errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, store.ErrDuplicateKey), isDatabaseError(err):
+		return true
+	case errors.IsCode(err, code.ErrDatabase):
+		return false
+	default:
+		return errors.ParseCoder(err).HTTPStatus() < http.StatusInternalServerError
+	}
+}
+
+func (b *circuitBreakers) execute(cb *gobreaker.CircuitBreaker, fn func() error) error {
+	if cb == nil {
+		return fn()
+	}
+
+	_, err := cb.Execute(func() (interface{}, error) {
+		return nil, fn()
+	})
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return errors.WithCode(code.ErrDatabaseUnavailable, "mysql %s circuit breaker: %s", cb.Name(), err.Error())
+	}
+
+	return err
+}
+
+func (b *circuitBreakers) doRead(fn func() error) error {
+	return b.execute(b.read, func() error { return b.retry.do(fn) })
+}
+
+func (b *circuitBreakers) doWrite(fn func() error) error {
+	return b.execute(b.write, fn)
+}
+
+// registerMetrics registers the db_circuit_open gauges into the default prometheus registry.
+func (b *circuitBreakers) registerMetrics() {
+	gauge := func(cb *gobreaker.CircuitBreaker) prometheus.GaugeFunc {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   "iam",
+			Name:        "db_circuit_open",
+			Help:        "Whether the mysql circuit breaker of the operation type is open, 1 if open.",
+			ConstLabels: prometheus.Labels{"operation": cb.Name()},
+		}, func() float64 {
+			if cb.State() == gobreaker.StateOpen {
+				return 1
+			}
+
+			return 0
+		})
+	}
+
+	prometheus.MustRegister(gauge(b.read), gauge(b.write))
+}
+
+// circuitBreakerFactory is a store.Factory whose stores are guarded by the circuit breakers.
+type circuitBreakerFactory struct {
+	*datastore
+	breakers *circuitBreakers
+}
+
+var _ store.Factory = (*circuitBreakerFactory)(nil)
+
+func newCircuitBreakerFactory(ds *datastore, breakers *circuitBreakers) *circuitBreakerFactory {
+	return &circuitBreakerFactory{datastore: ds, breakers: breakers}
+}
+
+func (f *circuitBreakerFactory) Users() store.UserStore {
+	return &breakerUsers{UserStore: f.datastore.Users(), circuitBreakers: f.breakers}
+}
+
+func (f *circuitBreakerFactory) Secrets() store.SecretStore {
+	return &breakerSecrets{SecretStore: f.datastore.Secrets(), circuitBreakers: f.breakers}
+}
+
+func (f *circuitBreakerFactory) Policies() store.PolicyStore {
+	return &breakerPolicies{PolicyStore: f.datastore.Policies(), circuitBreakers: f.breakers}
+}
+
+func (f *circuitBreakerFactory) PolicyAudits() store.PolicyAuditStore {
+	return &breakerPolicyAudits{PolicyAuditStore: f.datastore.PolicyAudits(), circuitBreakers: f.breakers}
+}
+
+func (f *circuitBreakerFactory) SecretShares() store.SecretShareStore {
+	return &breakerSecretShares{SecretShareStore: f.datastore.SecretShares(), circuitBreakers: f.breakers}
+}
+
+func (f *circuitBreakerFactory) PolicyGroups() store.PolicyGroupStore {
+	return &breakerPolicyGroups{PolicyGroupStore: f.datastore.PolicyGroups(), circuitBreakers: f.breakers}
+}
+
+func (f *circuitBreakerFactory) UserSessions() store.UserSessionStore {
+	return &breakerUserSessions{UserSessionStore: f.datastore.UserSessions(), circuitBreakers: f.breakers}
+}
+
+func (f *circuitBreakerFactory) ServiceAccounts() store.ServiceAccountStore {
+	return &breakerServiceAccounts{ServiceAccountStore: f.datastore.ServiceAccounts(), circuitBreakers: f.breakers}
+}
+
+// WithTransaction guards the transaction as one write, the stores of its factory are not guarded. The
+// transaction failing with a transient error is retried as a whole, fn included.
+func (f *circuitBreakerFactory) WithTransaction(ctx context.Context, fn func(store.Factory) error) error {
+	return f.breakers.doWrite(func() error {
+		return f.breakers.retry.do(func() error { return f.datastore.WithTransaction(ctx, fn) })
+	})
+}
+
+type breakerUsers struct {
+	store.UserStore
+	*circuitBreakers
+}
+
+func (u *breakerUsers) Create(ctx context.Context, user *v1.User, opts metav1.CreateOptions) error {
+	return u.doWrite(func() error { return u.UserStore.Create(ctx, user, opts) })
+}
+
+func (u *breakerUsers) CreateBatch(
+	ctx context.Context,
+	batch []*v1.User,
+	opts metav1.CreateOptions,
+) (map[int]error, error) {
+	var failed map[int]error
+	err := u.doWrite(func() (err error) {
+		failed, err = u.UserStore.CreateBatch(ctx, batch, opts)
+
+		return err
+	})
+
+	return failed, err
+}
+
+func (u *breakerUsers) Update(ctx context.Context, user *v1.User, opts metav1.UpdateOptions) error {
+	return u.doWrite(func() error { return u.UserStore.Update(ctx, user, opts) })
+}
+
+func (u *breakerUsers) Delete(ctx context.Context, username string, opts metav1.DeleteOptions) error {
+	return u.doWrite(func() error { return u.UserStore.Delete(ctx, username, opts) })
+}
+
+func (u *breakerUsers) DeleteCollection(ctx context.Context, usernames []string, opts metav1.DeleteOptions) error {
+	return u.doWrite(func() error { return u.UserStore.DeleteCollection(ctx, usernames, opts) })
+}
+
+func (u *breakerUsers) Get(ctx context.Context, username string, opts metav1.GetOptions) (*v1.User, error) {
+	var user *v1.User
+	err := u.doRead(func() (err error) {
+		user, err = u.UserStore.Get(ctx, username, opts)
+
+		return err
+	})
+
+	return user, err
+}
+
+func (u *breakerUsers) List(ctx context.Context, opts metav1.ListOptions) (*v1.UserList, error) {
+	var users *v1.UserList
+	err := u.doRead(func() (err error) {
+		users, err = u.UserStore.List(ctx, opts)
+
+		return err
+	})
+
+	return users, err
+}
+
+func (u *breakerUsers) ListInactive(
+	ctx context.Context,
+	lastLoginBefore time.Time,
+	opts metav1.ListOptions,
+) (*v1.UserList, error) {
+	var users *v1.UserList
+	err := u.doRead(func() (err error) {
+		users, err = u.UserStore.ListInactive(ctx, lastLoginBefore, opts)
+
+		return err
+	})
+
+	return users, err
+}
+
+func (u *breakerUsers) Search(
+	ctx context.Context,
+	searchOpts store.SearchOptions,
+	opts metav1.ListOptions,
+) (*v1.UserList, error) {
+	var users *v1.UserList
+	err := u.doRead(func() (err error) {
+		users, err = u.UserStore.Search(ctx, searchOpts, opts)
+
+		return err
+	})
+
+	return users, err
+}
+
+type breakerSecrets struct {
+	store.SecretStore
+	*circuitBreakers
+}
+
+func (s *breakerSecrets) Create(ctx context.Context, secret *v1.Secret, opts metav1.CreateOptions) error {
+	return s.doWrite(func() error { return s.SecretStore.Create(ctx, secret, opts) })
+}
+
+func (s *breakerSecrets) Update(ctx context.Context, secret *v1.Secret, opts metav1.UpdateOptions) error {
+	return s.doWrite(func() error { return s.SecretStore.Update(ctx, secret, opts) })
+}
+
+func (s *breakerSecrets) Delete(ctx context.Context, username, secretID string, opts metav1.DeleteOptions) error {
+	return s.doWrite(func() error { return s.SecretStore.Delete(ctx, username, secretID, opts) })
+}
+
+func (s *breakerSecrets) DeleteCollection(
+	ctx context.Context,
+	username string,
+	secretIDs []string,
+	opts metav1.DeleteOptions,
+) error {
+	return s.doWrite(func() error { return s.SecretStore.DeleteCollection(ctx, username, secretIDs, opts) })
+}
+
+func (s *breakerSecrets) Get(
+	ctx context.Context,
+	username, secretID string,
+	opts metav1.GetOptions,
+) (*v1.Secret, error) {
+	var secret *v1.Secret
+	err := s.doRead(func() (err error) {
+		secret, err = s.SecretStore.Get(ctx, username, secretID, opts)
+
+		return err
+	})
+
+	return secret, err
+}
+
+func (s *breakerSecrets) List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.SecretList, error) {
+	var secrets *v1.SecretList
+	err := s.doRead(func() (err error) {
+		secrets, err = s.SecretStore.List(ctx, username, opts)
+
+		return err
+	})
+
+	return secrets, err
+}
+
+func (s *breakerSecrets) GetVersioned(
+	ctx context.Context,
+	username, secretID string,
+	opts metav1.GetOptions,
+) (*modelv1.VersionedSecret, error) {
+	var secret *modelv1.VersionedSecret
+	err := s.doRead(func() (err error) {
+		secret, err = s.SecretStore.GetVersioned(ctx, username, secretID, opts)
+
+		return err
+	})
+
+	return secret, err
+}
+
+func (s *breakerSecrets) UpdateVersioned(
+	ctx context.Context,
+	secret *modelv1.VersionedSecret,
+	opts metav1.UpdateOptions,
+) error {
+	return s.doWrite(func() error { return s.SecretStore.UpdateVersioned(ctx, secret, opts) })
+}
+
+type breakerPolicies struct {
+	store.PolicyStore
+	*circuitBreakers
+}
+
+func (p *breakerPolicies) Create(ctx context.Context, policy *v1.Policy, opts metav1.CreateOptions) error {
+	return p.doWrite(func() error { return p.PolicyStore.Create(ctx, policy, opts) })
+}
+
+func (p *breakerPolicies) CreateBatch(
+	ctx context.Context,
+	batch []*v1.Policy,
+	opts metav1.CreateOptions,
+) (map[int]error, error) {
+	var failed map[int]error
+	err := p.doWrite(func() (err error) {
+		failed, err = p.PolicyStore.CreateBatch(ctx, batch, opts)
+
+		return err
+	})
+
+	return failed, err
+}
+
+func (p *breakerPolicies) Update(ctx context.Context, policy *v1.Policy, opts metav1.UpdateOptions) error {
+	return p.doWrite(func() error { return p.PolicyStore.Update(ctx, policy, opts) })
+}
+
+func (p *breakerPolicies) Delete(ctx context.Context, username string, name string, opts metav1.DeleteOptions) error {
+	return p.doWrite(func() error { return p.PolicyStore.Delete(ctx, username, name, opts) })
+}
+
+func (p *breakerPolicies) DeleteCollection(
+	ctx context.Context,
+	username string,
+	names []string,
+	opts metav1.DeleteOptions,
+) error {
+	return p.doWrite(func() error { return p.PolicyStore.DeleteCollection(ctx, username, names, opts) })
+}
+
+func (p *breakerPolicies) Get(
+	ctx context.Context,
+	username string,
+	name string,
+	opts metav1.GetOptions,
+) (*v1.Policy, error) {
+	var policy *v1.Policy
+	err := p.doRead(func() (err error) {
+		policy, err = p.PolicyStore.Get(ctx, username, name, opts)
+
+		return err
+	})
+
+	return policy, err
+}
+
+func (p *breakerPolicies) List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.PolicyList, error) {
+	var policies *v1.PolicyList
+	err := p.doRead(func() (err error) {
+		policies, err = p.PolicyStore.List(ctx, username, opts)
+
+		return err
+	})
+
+	return policies, err
+}
+
+func (p *breakerPolicies) Search(
+	ctx context.Context,
+	username string,
+	searchOpts store.SearchOptions,
+	opts metav1.ListOptions,
+) (*v1.PolicyList, error) {
+	var policies *v1.PolicyList
+	err := p.doRead(func() (err error) {
+		policies, err = p.PolicyStore.Search(ctx, username, searchOpts, opts)
+
+		return err
+	})
+
+	return policies, err
+}
+
+func (p *breakerPolicies) GetVersioned(
+	ctx context.Context,
+	username, name string,
+	opts metav1.GetOptions,
+) (*modelv1.VersionedPolicy, error) {
+	var policy *modelv1.VersionedPolicy
+	err := p.doRead(func() (err error) {
+		policy, err = p.PolicyStore.GetVersioned(ctx, username, name, opts)
+
+		return err
+	})
+
+	return policy, err
+}
+
+func (p *breakerPolicies) UpdateVersioned(
+	ctx context.Context,
+	policy *modelv1.VersionedPolicy,
+	opts metav1.UpdateOptions,
+) error {
+	return p.doWrite(func() error { return p.PolicyStore.UpdateVersioned(ctx, policy, opts) })
+}
+
+type breakerPolicyAudits struct {
+	store.PolicyAuditStore
+	*circuitBreakers
+}
+
+func (p *breakerPolicyAudits) ClearOutdated(ctx context.Context, maxReserveDays int) (int64, error) {
+	var affected int64
+	err := p.doWrite(func() (err error) {
+		affected, err = p.PolicyAuditStore.ClearOutdated(ctx, maxReserveDays)
+
+		return err
+	})
+
+	return affected, err
+}
+
+type breakerSecretShares struct {
+	store.SecretShareStore
+	*circuitBreakers
+}
+
+func (s *breakerSecretShares) Create(ctx context.Context, share *modelv1.SecretShare, opts metav1.CreateOptions) error {
+	return s.doWrite(func() error { return s.SecretShareStore.Create(ctx, share, opts) })
+}
+
+func (s *breakerSecretShares) List(
+	ctx context.Context,
+	targetUsername string,
+	opts metav1.ListOptions,
+) (*modelv1.SecretShareList, error) {
+	var shares *modelv1.SecretShareList
+	err := s.doRead(func() (err error) {
+		shares, err = s.SecretShareStore.List(ctx, targetUsername, opts)
+
+		return err
+	})
+
+	return shares, err
+}
+
+type breakerPolicyGroups struct {
+	store.PolicyGroupStore
+	*circuitBreakers
+}
+
+func (p *breakerPolicyGroups) Create(ctx context.Context, group *modelv1.PolicyGroup, opts metav1.CreateOptions) error {
+	return p.doWrite(func() error { return p.PolicyGroupStore.Create(ctx, group, opts) })
+}
+
+func (p *breakerPolicyGroups) Update(ctx context.Context, group *modelv1.PolicyGroup, opts metav1.UpdateOptions) error {
+	return p.doWrite(func() error { return p.PolicyGroupStore.Update(ctx, group, opts) })
+}
+
+func (p *breakerPolicyGroups) Delete(
+	ctx context.Context,
+	username string,
+	name string,
+	opts metav1.DeleteOptions,
+) error {
+	return p.doWrite(func() error { return p.PolicyGroupStore.Delete(ctx, username, name, opts) })
+}
+
+func (p *breakerPolicyGroups) Get(
+	ctx context.Context,
+	username string,
+	name string,
+	opts metav1.GetOptions,
+) (*modelv1.PolicyGroup, error) {
+	var group *modelv1.PolicyGroup
+	err := p.doRead(func() (err error) {
+		group, err = p.PolicyGroupStore.Get(ctx, username, name, opts)
+
+		return err
+	})
+
+	return group, err
+}
+
+func (p *breakerPolicyGroups) List(
+	ctx context.Context,
+	username string,
+	opts metav1.ListOptions,
+) (*modelv1.PolicyGroupList, error) {
+	var groups *modelv1.PolicyGroupList
+	err := p.doRead(func() (err error) {
+		groups, err = p.PolicyGroupStore.List(ctx, username, opts)
+
+		return err
+	})
+
+	return groups, err
+}
+
+func (p *breakerPolicyGroups) Apply(ctx context.Context, username string, name string) error {
+	return p.doWrite(func() error { return p.PolicyGroupStore.Apply(ctx, username, name) })
+}
+
+type breakerUserSessions struct {
+	store.UserSessionStore
+	*circuitBreakers
+}
+
+func (s *breakerUserSessions) Create(ctx context.Context, session *modelv1.UserSession, opts metav1.CreateOptions) error {
+	return s.doWrite(func() error { return s.UserSessionStore.Create(ctx, session, opts) })
+}
+
+func (s *breakerUserSessions) List(
+	ctx context.Context,
+	username string,
+	opts metav1.ListOptions,
+) (*modelv1.UserSessionList, error) {
+	var sessions *modelv1.UserSessionList
+	err := s.doRead(func() (err error) {
+		sessions, err = s.UserSessionStore.List(ctx, username, opts)
+
+		return err
+	})
+
+	return sessions, err
+}
+
+type breakerServiceAccounts struct {
+	store.ServiceAccountStore
+	*circuitBreakers
+}
+
+func (s *breakerServiceAccounts) Create(
+	ctx context.Context,
+	account *modelv1.ServiceAccount,
+	opts metav1.CreateOptions,
+) error {
+	return s.doWrite(func() error { return s.ServiceAccountStore.Create(ctx, account, opts) })
+}
+
+func (s *breakerServiceAccounts) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return s.doWrite(func() error { return s.ServiceAccountStore.Delete(ctx, name, opts) })
+}
+
+func (s *breakerServiceAccounts) Get(
+	ctx context.Context,
+	name string,
+	opts metav1.GetOptions,
+) (*modelv1.ServiceAccount, error) {
+	var account *modelv1.ServiceAccount
+	err := s.doRead(func() (err error) {
+		account, err = s.ServiceAccountStore.Get(ctx, name, opts)
+
+		return err
+	})
+
+	return account, err
+}
+
+func (s *breakerServiceAccounts) List(
+	ctx context.Context,
+	opts metav1.ListOptions,
+) (*modelv1.ServiceAccountList, error) {
+	var accounts *modelv1.ServiceAccountList
+	err := s.doRead(func() (err error) {
+		accounts, err = s.ServiceAccountStore.List(ctx, opts)
+
+		return err
+	})
+
+	return accounts, err
+}
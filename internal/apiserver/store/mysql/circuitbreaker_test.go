@@ -0,0 +1,143 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+	"github.com/sony/gobreaker"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+var errTimeout = errors.New("read tcp 127.0.0.1:3306: i/o timeout")
+
+// newMockDatastore returns a datastore whose queries are answered by the returned mock.
+func newMockDatastore(t *testing.T) (*datastore, sqlmock.Sqlmock) {
+	t.Helper()
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(gormmysql.New(gormmysql.Config{
+		Conn:                      sqlDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+
+	return &datastore{db}, mock
+}
+
+// expectUserQuery makes the next user query fail with err, or find no user if err is nil.
+func expectUserQuery(mock sqlmock.Sqlmock, err error) {
+	query := mock.ExpectQuery("SELECT (.+) FROM `user`")
+	if err != nil {
+		query.WillReturnError(err)
+
+		return
+	}
+
+	query.WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+}
+
+func TestCircuitBreakerFactory_Read(t *testing.T) {
+	tests := []struct {
+		name string
+		// queryErrs are the errors of the successive queries, nil if the user is not found.
+		queryErrs []error
+		wantOpen  bool
+	}{
+		{
+			name:      "opens after threshold consecutive failures",
+			queryErrs: []error{errTimeout, errTimeout, errTimeout},
+			wantOpen:  true,
+		},
+		{
+			name:      "below threshold",
+			queryErrs: []error{errTimeout, errTimeout},
+		},
+		{
+			name:      "not found resets the consecutive failures",
+			queryErrs: []error{errTimeout, errTimeout, nil, errTimeout, errTimeout},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds, mock := newMockDatastore(t)
+			breakers := newCircuitBreakers(3, time.Minute)
+			users := newCircuitBreakerFactory(ds, breakers).Users()
+
+			for _, err := range tt.queryErrs {
+				expectUserQuery(mock, err)
+
+				if _, err := users.Get(context.Background(), "colin", metav1.GetOptions{}); err == nil {
+					t.Fatal("Get() error = nil, want an error")
+				}
+			}
+
+			// the query is not sent to mysql once the circuit is open.
+			if !tt.wantOpen {
+				expectUserQuery(mock, nil)
+			}
+
+			_, err := users.Get(context.Background(), "colin", metav1.GetOptions{})
+			if got := errors.IsCode(err, code.ErrDatabaseUnavailable); got != tt.wantOpen {
+				t.Errorf("Get() error = %v, want ErrDatabaseUnavailable %v", err, tt.wantOpen)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+
+			// the writes are guarded by their own circuit breaker.
+			if state := breakers.write.State(); state != gobreaker.StateClosed {
+				t.Errorf("write circuit breaker state = %s, want closed", state)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerFactory_HalfOpen(t *testing.T) {
+	ds, mock := newMockDatastore(t)
+	breakers := newCircuitBreakers(1, 100*time.Millisecond)
+	users := newCircuitBreakerFactory(ds, breakers).Users()
+
+	expectUserQuery(mock, errTimeout)
+	_, _ = users.Get(context.Background(), "colin", metav1.GetOptions{})
+
+	if state := breakers.read.State(); state != gobreaker.StateOpen {
+		t.Fatalf("read circuit breaker state = %s, want open", state)
+	}
+
+	// a trial query is let through once the timeout elapsed, the circuit closes if it succeeds.
+	time.Sleep(150 * time.Millisecond)
+	expectUserQuery(mock, nil)
+
+	_, err := users.Get(context.Background(), "colin", metav1.GetOptions{})
+	if !errors.IsCode(err, code.ErrUserNotFound) {
+		t.Errorf("Get() error = %v, want ErrUserNotFound", err)
+	}
+
+	if state := breakers.read.State(); state != gobreaker.StateClosed {
+		t.Errorf("read circuit breaker state = %s, want closed", state)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"os"
+	"testing"
+
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+
+	"github.com/marmotedu/iam/internal/apiserver/store/storetest"
+)
+
+// TestFactory_Conformance runs the conformance tests against the mysql database of the
+// IAM_TEST_MYSQL_DSN environment variable, e.g. iam:iam@tcp(127.0.0.1:3306)/iam_test?parseTime=true.
+func TestFactory_Conformance(t *testing.T) {
+	dsn := os.Getenv("IAM_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("IAM_TEST_MYSQL_DSN is not set")
+	}
+
+	dbIns, err := gorm.Open(gormmysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	factory, err := NewFactory(dbIns, FactoryOptions{})
+	if err != nil {
+		t.Fatalf("NewFactory() error = %v", err)
+	}
+	defer factory.Close()
+
+	storetest.TestFactory(t, factory)
+}
@@ -3,4 +3,5 @@
 // license that can be found in the LICENSE file.
 
 // Package mysql implements `github.com/marmotedu/iam/internal/apiserver/store.Store` interface.
+// The stores only use the gorm apis independent of the dialect, they back the postgres store too.
 package mysql
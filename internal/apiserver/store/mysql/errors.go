@@ -0,0 +1,59 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+)
+
+const (
+	// mysqlDuplicateEntry is the mysql error number of the violations of a unique key.
+	mysqlDuplicateEntry = 1062
+	// postgresUniqueViolation is the postgres SQLSTATE of the violations of a unique key, the sqlite store
+	// gives it to its violations too.
+	postgresUniqueViolation = "23505"
+)
+
+// sqlStateError is an error returned by the database server with its SQLSTATE, e.g. *pgconn.PgError.
+type sqlStateError interface {
+	error
+	SQLState() string
+}
+
+// isDatabaseError reports whether err was returned by the database server itself, like a duplicate
+// entry, rather than caused by its unavailability.
+func isDatabaseError(err error) bool {
+	var (
+		mysqlErr *gomysql.MySQLError
+		sqlErr   sqlStateError
+	)
+
+	return errors.As(err, &mysqlErr) || errors.As(err, &sqlErr)
+}
+
+// isDuplicateKey reports whether err is the violation of a unique key, by mysql, postgres or sqlite.
+func isDuplicateKey(err error) bool {
+	var mysqlErr *gomysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlDuplicateEntry
+	}
+
+	var sqlErr sqlStateError
+
+	return errors.As(err, &sqlErr) && sqlErr.SQLState() == postgresUniqueViolation
+}
+
+// translateError returns store.ErrDuplicateKey for the violations of a unique key, whatever the engine,
+// and the other errors as is.
+func translateError(err error) error {
+	if err != nil && isDuplicateKey(err) {
+		return errors.Wrap(store.ErrDuplicateKey, err.Error())
+	}
+
+	return err
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"testing"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+)
+
+// pgError is an error of postgres with its SQLSTATE, like *pgconn.PgError.
+type pgError struct {
+	code string
+}
+
+func (e *pgError) Error() string    { return "ERROR: postgres error (SQLSTATE " + e.code + ")" }
+func (e *pgError) SQLState() string { return e.code }
+
+func Test_translateError(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantDuplicate bool
+		wantSuccess   bool
+	}{
+		{name: "nil", wantSuccess: true},
+		{
+			name:          "mysql duplicate entry",
+			err:           &gomysql.MySQLError{Number: 1062, Message: "Duplicate entry 'colin' for key 'idx_name'"},
+			wantDuplicate: true,
+			wantSuccess:   true,
+		},
+		{
+			name:        "mysql other error",
+			err:         &gomysql.MySQLError{Number: 1406, Message: "Data too long for column 'name'"},
+			wantSuccess: true,
+		},
+		{
+			name:          "postgres unique violation",
+			err:           errors.Wrap(&pgError{code: "23505"}, "create user"),
+			wantDuplicate: true,
+			wantSuccess:   true,
+		},
+		{name: "postgres other error", err: &pgError{code: "23503"}, wantSuccess: true},
+		{name: "unavailable", err: errTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := translateError(tt.err)
+			if got := errors.Is(err, store.ErrDuplicateKey); got != tt.wantDuplicate {
+				t.Errorf("translateError() = %v, duplicate %v, want %v", err, got, tt.wantDuplicate)
+			}
+
+			// the errors of the database server itself do not open the circuit breakers.
+			if got := isSuccessful(err); got != tt.wantSuccess {
+				t.Errorf("isSuccessful(%v) = %v, want %v", err, got, tt.wantSuccess)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// healthCheck checks the database of a store factory by pinging it, periodically by run and on demand by
+// check, e.g. for /readyz. The factory is got from factory by each check, which may create it again if its
+// creation failed before, so that the server heals once the database is back, without a restart.
+//
+// A failed ping closes the idle connections of the database, likely stale, e.g. after its restart, for the
+// next operations to open new connections rather than fail on the stale ones.
+type healthCheck struct {
+	name               string
+	maxIdleConnections int
+	factory            func() (store.Factory, error)
+
+	lock sync.Mutex
+	err  error
+	// since is the time the database became unhealthy, or healthy again.
+	since time.Time
+}
+
+func newHealthCheck(name string, maxIdleConnections int, factory func() (store.Factory, error)) *healthCheck {
+	return &healthCheck{name: name, maxIdleConnections: maxIdleConnections, factory: factory}
+}
+
+// check pings the database, it returns an error telling since when the database is unhealthy if it fails.
+func (h *healthCheck) check(ctx context.Context) error {
+	factory, err := h.factory()
+	if err == nil {
+		err = h.ping(ctx, factory)
+	}
+
+	return h.update(err)
+}
+
+func (h *healthCheck) ping(ctx context.Context, factory store.Factory) error {
+	db, err := factoryDB(factory)
+	if err != nil {
+		return err
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		// the idle connections above the limit are closed.
+		db.SetMaxIdleConns(0)
+		db.SetMaxIdleConns(h.maxIdleConnections)
+
+		return err
+	}
+
+	return nil
+}
+
+// update records the result of a check, and logs the changes of the health of the database.
+func (h *healthCheck) update(err error) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	switch {
+	case err != nil && h.err == nil:
+		h.since = time.Now()
+		log.Warnf("The %s database is unhealthy: %s", h.name, err.Error())
+	case err == nil && h.err != nil:
+		log.Infof("The %s database is healthy again, after %s", h.name, time.Since(h.since).Round(time.Second))
+		h.since = time.Now()
+	}
+
+	h.err = err
+	if err != nil {
+		return fmt.Errorf("unhealthy since %s: %w", h.since.Format(time.RFC3339), err)
+	}
+
+	return nil
+}
+
+// run checks the database every interval, each check within timeout, until ctx is done.
+func (h *healthCheck) run(ctx context.Context, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		_ = h.check(checkCtx)
+		cancel()
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"testing"
+
+	"github.com/marmotedu/errors"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+)
+
+// errUnreachable is the error of the operations while the fake database is down.
+var errUnreachable = errors.New("dial tcp 127.0.0.1:3306: i/o timeout")
+
+// fakeDatabase is a database which can disappear and come back, its connections fail while it is down.
+type fakeDatabase struct {
+	lock sync.Mutex
+	down bool
+}
+
+var _ driver.Connector = (*fakeDatabase)(nil)
+
+func (d *fakeDatabase) setDown(down bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.down = down
+}
+
+func (d *fakeDatabase) isDown() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.down
+}
+
+func (d *fakeDatabase) Connect(context.Context) (driver.Conn, error) {
+	if d.isDown() {
+		return nil, errUnreachable
+	}
+
+	return &fakeConn{database: d}, nil
+}
+
+func (d *fakeDatabase) Driver() driver.Driver {
+	return nil
+}
+
+// fakeConn is a connection of a fakeDatabase, it only supports the pings.
+type fakeConn struct {
+	database *fakeDatabase
+}
+
+func (c *fakeConn) Ping(context.Context) error {
+	if c.database.isDown() {
+		return errUnreachable
+	}
+
+	return nil
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func TestHealthCheck(t *testing.T) {
+	database := &fakeDatabase{down: true}
+
+	// the factory is created by the first check database is up for, like by GetMySQLFactoryOr.
+	var factory store.Factory
+	health := newHealthCheck("mysql", 2, func() (store.Factory, error) {
+		if factory != nil {
+			return factory, nil
+		}
+
+		db, err := gorm.Open(gormmysql.New(gormmysql.Config{
+			Conn:                      sql.OpenDB(database),
+			SkipInitializeWithVersion: true,
+		}), &gorm.Config{})
+		if err != nil {
+			return nil, err
+		}
+
+		factory = &datastore{db}
+		t.Cleanup(func() { _ = factory.Close() })
+
+		return factory, nil
+	})
+
+	steps := []struct {
+		name string
+		down bool
+	}{
+		{name: "down at startup", down: true},
+		{name: "up"},
+		{name: "down", down: true},
+		{name: "still down", down: true},
+		{name: "back"},
+	}
+
+	var lastErr error
+	for _, step := range steps {
+		database.setDown(step.down)
+
+		checkErr := health.check(context.TODO())
+		if step.down != (checkErr != nil) || (checkErr != nil && !errors.Is(checkErr, errUnreachable)) {
+			t.Fatalf("%s: check() error = %v, want down %v", step.name, checkErr, step.down)
+		}
+
+		// the database is unhealthy since it went down.
+		if step.name == "still down" && checkErr.Error() != lastErr.Error() {
+			t.Errorf("%s: check() error = %v, want %v", step.name, checkErr, lastErr)
+		}
+
+		lastErr = checkErr
+
+		if factory == nil {
+			if !step.down {
+				t.Fatalf("%s: the factory was not created", step.name)
+			}
+
+			continue
+		}
+
+		db, err := factoryDB(factory)
+		if err != nil {
+			t.Fatalf("factoryDB() error = %v", err)
+		}
+
+		// the connections of the database which went down are stale, they are not reused.
+		if idle := db.Stats().Idle; step.down && idle != 0 {
+			t.Errorf("%s: %d idle connections, want none", step.name, idle)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+)
+
+// sortByID is the sort column of the lists ordered by descending id only.
+const sortByID = ""
+
+// keyset returns a scope restricting the given list, ordered by descending (sortColumn, id), to the rows
+// after the cursor of ctx, see store.WithCursor, instead of skipping the rows before its offset: the deep
+// pages are then read from the index rather than by scanning all the rows before them. The list is not
+// changed if ctx has no cursor of the list.
+func keyset(ctx context.Context, list, sortColumn string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		cursor, ok := store.CursorFrom(ctx)
+		if !ok || cursor.List != list {
+			return db
+		}
+
+		db = db.Offset(-1)
+		if sortColumn == sortByID {
+			return db.Where("id < ?", cursor.ID)
+		}
+
+		return db.Where("(?, id) < (?, ?)", clause.Column{Name: sortColumn}, cursor.SortKey, cursor.ID)
+	}
+}
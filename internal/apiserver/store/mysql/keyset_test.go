@@ -0,0 +1,83 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	"gorm.io/gorm"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+)
+
+func TestKeyset(t *testing.T) {
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		sortColumn string
+		wantSQL    string
+		wantVars   []interface{}
+	}{
+		{
+			name:     "no cursor",
+			ctx:      context.TODO(),
+			wantSQL:  "SELECT * FROM `user` WHERE status = 1 ORDER BY id desc LIMIT 20 OFFSET 40",
+			wantVars: []interface{}{},
+		},
+		{
+			name:     "cursor",
+			ctx:      store.WithCursor(context.TODO(), store.Cursor{List: store.CursorUsers, ID: 42}),
+			wantSQL:  "SELECT * FROM `user` WHERE status = 1 AND id < ? ORDER BY id desc LIMIT 20",
+			wantVars: []interface{}{uint64(42)},
+		},
+		{
+			name:     "cursor of another list",
+			ctx:      store.WithCursor(context.TODO(), store.Cursor{List: store.CursorSecrets, ID: 42}),
+			wantSQL:  "SELECT * FROM `user` WHERE status = 1 ORDER BY id desc LIMIT 20 OFFSET 40",
+			wantVars: []interface{}{},
+		},
+		{
+			name: "cursor of a sort column",
+			ctx: store.WithCursor(context.TODO(), store.Cursor{
+				List:    store.CursorUsers,
+				SortKey: "colin",
+				ID:      42,
+			}),
+			sortColumn: "name",
+			wantSQL:    "SELECT * FROM `user` WHERE status = 1 AND (`name`, id) < (?, ?) ORDER BY name desc, id desc LIMIT 20",
+			wantVars:   []interface{}{"colin", uint64(42)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds, _ := newMockDatastore(t)
+
+			order := "id desc"
+			if tt.sortColumn != sortByID {
+				order = tt.sortColumn + " desc, " + order
+			}
+
+			var users []*v1.User
+			stmt := ds.db.Session(&gorm.Session{DryRun: true}).
+				Where("status = 1").
+				Offset(40).
+				Limit(20).
+				Order(order).
+				Scopes(keyset(tt.ctx, store.CursorUsers, tt.sortColumn)).
+				Find(&users).Statement
+
+			if got := stmt.SQL.String(); got != tt.wantSQL {
+				t.Errorf("keyset() SQL = %s, want %s", got, tt.wantSQL)
+			}
+
+			if got := append([]interface{}{}, stmt.Vars...); !reflect.DeepEqual(got, tt.wantVars) {
+				t.Errorf("keyset() vars = %v, want %v", got, tt.wantVars)
+			}
+		})
+	}
+}
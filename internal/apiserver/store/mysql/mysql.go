@@ -5,17 +5,24 @@
 package mysql
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	v1 "github.com/marmotedu/api/apiserver/v1"
 	"github.com/marmotedu/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"gorm.io/gorm"
 
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
 	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/pkg/logger"
 	genericoptions "github.com/marmotedu/iam/internal/pkg/options"
 	"github.com/marmotedu/iam/pkg/db"
+	"github.com/marmotedu/iam/pkg/log"
 )
 
 type datastore struct {
@@ -42,54 +49,354 @@ func (ds *datastore) PolicyAudits() store.PolicyAuditStore {
 	return newPolicyAudits(ds)
 }
 
+func (ds *datastore) SecretShares() store.SecretShareStore {
+	return newSecretShares(ds)
+}
+
+func (ds *datastore) PolicyGroups() store.PolicyGroupStore {
+	return newPolicyGroups(ds)
+}
+
+func (ds *datastore) UserSessions() store.UserSessionStore {
+	return newUserSessions(ds)
+}
+
+func (ds *datastore) ServiceAccounts() store.ServiceAccountStore {
+	return newServiceAccounts(ds)
+}
+
+func (ds *datastore) WithTransaction(ctx context.Context, fn func(store.Factory) error) error {
+	return transaction(ctx, ds.db, func(tx *datastore) error {
+		return fn(tx)
+	})
+}
+
 func (ds *datastore) Close() error {
 	db, err := ds.db.DB()
 	if err != nil {
 		return errors.Wrap(err, "get gorm db instance failed")
 	}
 
-	return db.Close()
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	if replicas, ok := ds.db.Config.Plugins[(&replicaPlugin{}).Name()].(*replicaPlugin); ok {
+		if err := replicas.Close(); err != nil {
+			return errors.Wrap(err, "close the read replicas failed")
+		}
+	}
+
+	return nil
+}
+
+// transaction calls fn with a datastore of a transaction of db, committed if fn returns nil. If db is a
+// transaction already, it is nested in a savepoint.
+func transaction(ctx context.Context, db *gorm.DB, fn func(tx *datastore) error) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&datastore{tx})
+	})
 }
 
 var (
 	mysqlFactory store.Factory
-	once         sync.Once
+	// mysqlOptions are the options of the first call of GetMySQLFactoryOr, mysqlHealth checks the database
+	// of the factory created with them.
+	mysqlOptions *genericoptions.MySQLOptions
+	mysqlHealth  *healthCheck
+	mysqlLock    sync.Mutex
+	// mysqlPassword is the password of the new connections of the factory, rotated by SetPassword.
+	mysqlPassword atomic.Value
+)
+
+// registerQueryMetrics, registerReplicaFallbacks and registerRetries register their metrics once, whatever
+// the number of stores.
+var (
+	registerQueryMetrics     sync.Once
+	registerReplicaFallbacks sync.Once
+	registerRetries          sync.Once
 )
 
-// GetMySQLFactoryOr create mysql factory with the given config.
+// FactoryOptions configures the store created by NewFactory.
+type FactoryOptions struct {
+	// SlowQueryThreshold is the duration after which the queries are logged as slow, zero disables it.
+	SlowQueryThreshold time.Duration
+	// QueryMetrics records the duration and the failures of the operations, by table and operation type,
+	// e.g. iam_db_operation_duration_seconds.
+	QueryMetrics bool
+	// CircuitBreakerThreshold is the number of consecutive failures opening the circuit breakers of the
+	// reads or writes, zero disables them. They stay open for CircuitBreakerTimeout.
+	CircuitBreakerThreshold int
+	CircuitBreakerTimeout   time.Duration
+	// MaxRetries is the number of times the reads and the transactions failing with a transient error, e.g.
+	// a deadlock, are retried, after a jittered backoff doubling from RetryBackoff. Zero disables it.
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// PoolMetrics exports the stats of the connection pool of the db, e.g. iam_db_connections_in_use. The
+	// metrics are registered once, by the store of the process.
+	PoolMetrics bool
+	// Replicas are the connection pools of the read replicas of the db, closed with the factory. The
+	// reads whose context is accepted by ReadRouter are served by them in turn, store.IsWithReplicaReads
+	// by default. The writes and the transactions always use the db, as do the reads once a replica fails.
+	Replicas   []*sql.DB
+	ReadRouter ReadRouter
+	// BatchSize is the number of rows inserted by a statement of the batch creations, e.g.
+	// UserStore.CreateBatch, small enough for the statements to fit in max_allowed_packet. Zero means 500.
+	BatchSize int
+}
+
+// NewFactory creates the store of the given gorm db. The stores only use the gorm apis independent of
+// the dialect, so the db can be of mysql or postgres, see the postgres package. The queries of the db
+// exclude the soft deleted users from then on, see softDeletePlugin, and the searches use the FULLTEXT
+// indexes of mysql when the db has them, see fullTextPlugin.
+func NewFactory(dbIns *gorm.DB, opts FactoryOptions) (store.Factory, error) {
+	if opts.PoolMetrics {
+		sqlDB, err := dbIns.DB()
+		if err != nil {
+			return nil, errors.Wrap(err, "get gorm db instance failed")
+		}
+
+		prometheus.MustRegister(poolMetrics(sqlDB)...)
+	}
+
+	if err := dbIns.Use(&softDeletePlugin{}); err != nil {
+		return nil, err
+	}
+
+	if opts.BatchSize > 0 {
+		if err := dbIns.Use(&batchPlugin{size: opts.BatchSize}); err != nil {
+			return nil, err
+		}
+	}
+
+	if fullTextIndexed(dbIns) {
+		if err := dbIns.Use(&fullTextPlugin{}); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(opts.Replicas) > 0 {
+		router := opts.ReadRouter
+		if router == nil {
+			router = store.IsWithReplicaReads
+		}
+
+		registerReplicaFallbacks.Do(func() {
+			prometheus.MustRegister(replicaFallbacks)
+		})
+
+		if err := dbIns.Use(newReplicaPlugin(opts.Replicas, router)); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.SlowQueryThreshold > 0 || opts.QueryMetrics {
+		registerQueryMetrics.Do(func() {
+			prometheus.MustRegister(slowQueries, operationDuration, operationErrors)
+		})
+
+		if err := dbIns.Use(newQueryPlugin(opts.SlowQueryThreshold, opts.QueryMetrics)); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.CircuitBreakerThreshold > 0 || opts.MaxRetries > 0 {
+		breakers := &circuitBreakers{}
+		if opts.CircuitBreakerThreshold > 0 {
+			breakers = newCircuitBreakers(uint32(opts.CircuitBreakerThreshold), opts.CircuitBreakerTimeout)
+			breakers.registerMetrics()
+		}
+
+		if opts.MaxRetries > 0 {
+			registerRetries.Do(func() {
+				prometheus.MustRegister(retries)
+			})
+
+			breakers.retry = newRetrier(opts.MaxRetries, opts.RetryBackoff)
+		}
+
+		return newCircuitBreakerFactory(&datastore{dbIns}, breakers), nil
+	}
+
+	return &datastore{dbIns}, nil
+}
+
+// GetMySQLFactoryOr create mysql factory with the given config. The factory is created once, with the
+// options of the first call, but its creation is tried again by the next calls if it failed, e.g. as mysql
+// was down, so that a later successful connection heals the process without a restart.
 func GetMySQLFactoryOr(opts *genericoptions.MySQLOptions) (store.Factory, error) {
-	if opts == nil && mysqlFactory == nil {
+	mysqlLock.Lock()
+	defer mysqlLock.Unlock()
+
+	if mysqlFactory != nil {
+		return mysqlFactory, nil
+	}
+
+	if mysqlOptions == nil && opts != nil {
+		mysqlOptions = opts
+		mysqlPassword.Store(opts.Password)
+		mysqlHealth = newHealthCheck("mysql", opts.MaxIdleConnections, func() (store.Factory, error) {
+			return GetMySQLFactoryOr(nil)
+		})
+	}
+
+	if mysqlOptions == nil {
 		return nil, fmt.Errorf("failed to get mysql store fatory")
 	}
 
-	var err error
-	var dbIns *gorm.DB
-	once.Do(func() {
-		options := &db.Options{
-			Host:                  opts.Host,
-			Username:              opts.Username,
-			Password:              opts.Password,
-			Database:              opts.Database,
-			MaxIdleConnections:    opts.MaxIdleConnections,
-			MaxOpenConnections:    opts.MaxOpenConnections,
-			MaxConnectionLifeTime: opts.MaxConnectionLifeTime,
-			LogLevel:              opts.LogLevel,
-			Logger:                logger.New(opts.LogLevel),
+	factory, err := newMySQLFactory(mysqlOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mysql store fatory: %w", err)
+	}
+
+	mysqlFactory = factory
+
+	return mysqlFactory, nil
+}
+
+// SetPassword rotates the password of the mysql store, e.g. read again from --mysql.password-file. The
+// connections opened from then on use it, the open ones are kept until --mysql.max-connection-life-time.
+func SetPassword(newPassword string) {
+	mysqlPassword.Store(newPassword)
+}
+
+func currentPassword() string {
+	p, _ := mysqlPassword.Load().(string)
+
+	return p
+}
+
+func newMySQLFactory(opts *genericoptions.MySQLOptions) (store.Factory, error) {
+	options := &db.Options{
+		Host:                  opts.Host,
+		Username:              opts.Username,
+		Password:              opts.Password,
+		Database:              opts.Database,
+		MaxIdleConnections:    opts.MaxIdleConnections,
+		MaxOpenConnections:    opts.MaxOpenConnections,
+		MaxConnectionLifeTime: opts.MaxConnectionLifeTime,
+		MaxConnectionIdleTime: opts.MaxConnectionIdleTime,
+		PasswordFunc:          currentPassword,
+		LogLevel:              opts.LogLevel,
+		Logger:                logger.New(opts.LogLevel),
+	}
+	dbIns, err := db.New(options)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make([]*sql.DB, 0, len(opts.ReplicaDSNs))
+	closeAll := func() {
+		if sqlDB, err := dbIns.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+
+		for _, replica := range replicas {
+			_ = replica.Close()
+		}
+	}
+
+	for _, dsn := range opts.ReplicaDSNs {
+		replica, err := db.NewReplica(dsn, options)
+		if err != nil {
+			closeAll()
+
+			return nil, err
 		}
-		dbIns, err = db.New(options)
 
-		// uncomment the following line if you need auto migration the given models
-		// not suggested in production environment.
-		// migrateDatabase(dbIns)
+		replicas = append(replicas, replica)
+	}
+
+	// uncomment the following line if you need auto migration the given models
+	// not suggested in production environment.
+	// migrateDatabase(dbIns)
 
-		mysqlFactory = &datastore{dbIns}
+	factory, err := NewFactory(dbIns, FactoryOptions{
+		SlowQueryThreshold:      opts.SlowQueryThreshold,
+		QueryMetrics:            opts.QueryMetrics,
+		CircuitBreakerThreshold: opts.CircuitBreakerThreshold,
+		CircuitBreakerTimeout:   opts.CircuitBreakerTimeout,
+		MaxRetries:              opts.MaxRetries,
+		RetryBackoff:            opts.RetryBackoff,
+		PoolMetrics:             true,
+		Replicas:                replicas,
+		BatchSize:               opts.CreateBatchSize,
 	})
+	if err != nil {
+		closeAll()
 
-	if mysqlFactory == nil || err != nil {
-		return nil, fmt.Errorf("failed to get mysql store fatory, mysqlFactory: %+v, error: %w", mysqlFactory, err)
+		return nil, err
 	}
 
-	return mysqlFactory, nil
+	return factory, nil
+}
+
+// Ping checks the connection to the database of the mysql factory, creating the factory if its creation
+// failed before. The error tells since when the database is unhealthy.
+func Ping(ctx context.Context) error {
+	health, err := getMySQLHealth()
+	if err != nil {
+		return err
+	}
+
+	return health.check(ctx)
+}
+
+// RunHealthCheck pings the database of the mysql factory every interval, within timeout, until ctx is
+// done, see Ping. The idle connections of the factory are closed once a ping fails, as they are likely
+// stale, and the factory is created if its creation failed before.
+func RunHealthCheck(ctx context.Context, interval, timeout time.Duration) {
+	health, err := getMySQLHealth()
+	if err != nil {
+		log.Warnf("Failed to start the mysql health check: %s", err.Error())
+
+		return
+	}
+
+	health.run(ctx, interval, timeout)
+}
+
+func getMySQLHealth() (*healthCheck, error) {
+	mysqlLock.Lock()
+	defer mysqlLock.Unlock()
+
+	if mysqlHealth == nil {
+		return nil, fmt.Errorf("failed to get mysql store fatory")
+	}
+
+	return mysqlHealth, nil
+}
+
+// PingFactory checks the connection to the database of a factory created by NewFactory.
+func PingFactory(ctx context.Context, factory store.Factory) error {
+	db, err := factoryDB(factory)
+	if err != nil {
+		return err
+	}
+
+	return db.PingContext(ctx)
+}
+
+// factoryDB returns the connection pool of the database of a factory created by NewFactory.
+func factoryDB(factory store.Factory) (*sql.DB, error) {
+	var ds *datastore
+	switch f := factory.(type) {
+	case *datastore:
+		ds = f
+	case *circuitBreakerFactory:
+		// the pings are not guarded by the circuit breakers, so that they report the actual state of the
+		// database.
+		ds = f.datastore
+	default:
+		return nil, fmt.Errorf("unexpected store factory %T", factory)
+	}
+
+	db, err := ds.db.DB()
+	if err != nil {
+		return nil, errors.Wrap(err, "get gorm db instance failed")
+	}
+
+	return db, nil
 }
 
 // cleanDatabase tear downs the database tables.
@@ -104,6 +411,18 @@ func cleanDatabase(db *gorm.DB) error {
 	if err := db.Migrator().DropTable(&v1.Secret{}); err != nil {
 		return errors.Wrap(err, "drop secret table failed")
 	}
+	if err := db.Migrator().DropTable(&modelv1.SecretShare{}); err != nil {
+		return errors.Wrap(err, "drop secret share table failed")
+	}
+	if err := db.Migrator().DropTable(&modelv1.PolicyGroup{}); err != nil {
+		return errors.Wrap(err, "drop policy group table failed")
+	}
+	if err := db.Migrator().DropTable(&modelv1.UserSession{}); err != nil {
+		return errors.Wrap(err, "drop user session table failed")
+	}
+	if err := db.Migrator().DropTable(&modelv1.ServiceAccount{}); err != nil {
+		return errors.Wrap(err, "drop service account table failed")
+	}
 
 	return nil
 }
@@ -121,6 +440,18 @@ func migrateDatabase(db *gorm.DB) error {
 	if err := db.AutoMigrate(&v1.Secret{}); err != nil {
 		return errors.Wrap(err, "migrate secret model failed")
 	}
+	if err := db.AutoMigrate(&modelv1.SecretShare{}); err != nil {
+		return errors.Wrap(err, "migrate secret share model failed")
+	}
+	if err := db.AutoMigrate(&modelv1.PolicyGroup{}); err != nil {
+		return errors.Wrap(err, "migrate policy group model failed")
+	}
+	if err := db.AutoMigrate(&modelv1.UserSession{}); err != nil {
+		return errors.Wrap(err, "migrate user session model failed")
+	}
+	if err := db.AutoMigrate(&modelv1.ServiceAccount{}); err != nil {
+		return errors.Wrap(err, "migrate service account model failed")
+	}
 
 	return nil
 }
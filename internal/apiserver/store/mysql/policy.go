@@ -13,6 +13,8 @@ import (
 	"github.com/marmotedu/errors"
 	"gorm.io/gorm"
 
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/pkg/code"
 	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
 )
@@ -27,7 +29,33 @@ func newPolicies(ds *datastore) *policies {
 
 // Create creates a new ladon policy.
 func (p *policies) Create(ctx context.Context, policy *v1.Policy, opts metav1.CreateOptions) error {
-	return p.db.Create(&policy).Error
+	return translateError(p.db.Create(&policy).Error)
+}
+
+// CreateBatch creates the policies by multi-row inserts in a transaction, see createBatch.
+func (p *policies) CreateBatch(ctx context.Context, batch []*v1.Policy, opts metav1.CreateOptions) (map[int]error, error) {
+	var failed map[int]error
+	err := transaction(ctx, p.db, func(tx *datastore) error {
+		for _, policy := range batch {
+			if err := policy.BeforeCreate(tx.db); err != nil {
+				return err
+			}
+
+			policy.InstanceID = batchInstanceID("policy-")
+		}
+
+		var err error
+		failed, err = createBatch(tx.db, len(batch), func(start, end int) interface{} {
+			return batch[start:end]
+		})
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return failed, nil
 }
 
 // Update updates policy by the policy identifier.
@@ -43,7 +71,7 @@ func (p *policies) Delete(ctx context.Context, username, name string, opts metav
 
 	err := p.db.Where("username = ? and name = ?", username, name).Delete(&v1.Policy{}).Error
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return errors.WithCode(code.ErrDatabase, err.Error())
+		return errors.WrapC(err, code.ErrDatabase, err.Error())
 	}
 
 	return nil
@@ -84,18 +112,46 @@ func (p *policies) DeleteCollectionByUser(ctx context.Context, usernames []strin
 // Get return policy by the policy identifier.
 func (p *policies) Get(ctx context.Context, username, name string, opts metav1.GetOptions) (*v1.Policy, error) {
 	policy := &v1.Policy{}
-	err := p.db.Where("username = ? and name = ?", username, name).First(&policy).Error
+	err := p.db.WithContext(ctx).Where("username = ? and name = ?", username, name).First(&policy).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.WithCode(code.ErrPolicyNotFound, err.Error())
 		}
 
-		return nil, errors.WithCode(code.ErrDatabase, err.Error())
+		return nil, errors.WrapC(err, code.ErrDatabase, err.Error())
 	}
 
 	return policy, nil
 }
 
+// GetVersioned return policy by the policy identifier, with its resource version.
+func (p *policies) GetVersioned(
+	ctx context.Context,
+	username, name string,
+	opts metav1.GetOptions,
+) (*modelv1.VersionedPolicy, error) {
+	policy := &modelv1.VersionedPolicy{}
+	err := p.db.WithContext(ctx).Where("username = ? and name = ?", username, name).First(&policy).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithCode(code.ErrPolicyNotFound, err.Error())
+		}
+
+		return nil, errors.WrapC(err, code.ErrDatabase, err.Error())
+	}
+
+	return policy, nil
+}
+
+// UpdateVersioned updates the policy if its resource version is still the stored one.
+func (p *policies) UpdateVersioned(
+	ctx context.Context,
+	policy *modelv1.VersionedPolicy,
+	opts metav1.UpdateOptions,
+) error {
+	return updateVersioned(p.db, policy, &policy.ResourceVersion)
+}
+
 // List return all policies.
 func (p *policies) List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.PolicyList, error) {
 	ret := &v1.PolicyList{}
@@ -108,10 +164,43 @@ func (p *policies) List(ctx context.Context, username string, opts metav1.ListOp
 	selector, _ := fields.ParseSelector(opts.FieldSelector)
 	name, _ := selector.RequiresExactMatch("name")
 
-	d := p.db.Where("name like ?", "%"+name+"%").
+	d := p.db.WithContext(ctx).Where("name like ?", "%"+name+"%").
 		Offset(ol.Offset).
 		Limit(ol.Limit).
 		Order("id desc").
+		Scopes(keyset(ctx, store.CursorPolicies, sortByID)).
+		Find(&ret.Items).
+		Offset(-1).
+		Limit(-1).
+		Count(&ret.TotalCount)
+
+	return ret, d.Error
+}
+
+// Search returns the policies of the user found by the search, of all the users if username is empty,
+// ordered by relevance then id.
+func (p *policies) Search(
+	ctx context.Context,
+	username string,
+	searchOpts store.SearchOptions,
+	opts metav1.ListOptions,
+) (*v1.PolicyList, error) {
+	columns, err := searchOpts.SearchFields(store.PolicySearchFields...)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &v1.PolicyList{}
+	ol := gormutil.Unpointer(opts.Offset, opts.Limit)
+
+	db := p.db.WithContext(ctx)
+	if username != "" {
+		db = db.Where("username = ?", username)
+	}
+
+	d := search(db, columns, searchOpts).
+		Offset(ol.Offset).
+		Limit(ol.Limit).
 		Find(&ret.Items).
 		Offset(-1).
 		Limit(-1).
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type policyAudit struct {
@@ -23,7 +24,9 @@ func newPolicyAudits(ds *datastore) *policyAudit {
 func (p *policyAudit) ClearOutdated(ctx context.Context, maxReserveDays int) (int64, error) {
 	date := time.Now().AddDate(0, 0, -maxReserveDays).Format("2006-01-02 15:04:05")
 
-	d := p.db.Exec("delete from policy_audit where deletedAt < ?", date)
+	// the table and the column are quoted by the dialect, for postgres not to fold them to lower case.
+	d := p.db.Exec("delete from ? where ? < ?",
+		clause.Table{Name: "policy_audit"}, clause.Column{Name: "deletedAt"}, date)
 
 	return d.RowsAffected, d.Error
 }
@@ -0,0 +1,158 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+
+	apiv1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
+)
+
+type policyGroups struct {
+	db *gorm.DB
+}
+
+func newPolicyGroups(ds *datastore) *policyGroups {
+	return &policyGroups{ds.db}
+}
+
+// Create creates a new policy group.
+func (g *policyGroups) Create(ctx context.Context, group *v1.PolicyGroup, opts metav1.CreateOptions) error {
+	return translateError(g.db.Create(&group).Error)
+}
+
+// Update updates the policy group by the policy group identifier.
+func (g *policyGroups) Update(ctx context.Context, group *v1.PolicyGroup, opts metav1.UpdateOptions) error {
+	return g.db.Save(group).Error
+}
+
+// Delete deletes the policy group and its member policies in one transaction.
+func (g *policyGroups) Delete(ctx context.Context, username, name string, opts metav1.DeleteOptions) error {
+	db := g.db
+	if opts.Unscoped {
+		db = db.Unscoped()
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		group, err := getPolicyGroupForUpdate(tx, username, name)
+		if err != nil {
+			if errors.IsCode(err, code.ErrPolicyGroupNotFound) {
+				return nil
+			}
+
+			return err
+		}
+
+		if len(group.PolicyIDs) > 0 {
+			err := tx.Where("username = ? and name in (?)", username, group.PolicyIDs).Delete(&apiv1.Policy{}).Error
+			if err != nil {
+				return errors.WrapC(err, code.ErrDatabase, err.Error())
+			}
+		}
+
+		if err := tx.Delete(group).Error; err != nil {
+			return errors.WrapC(err, code.ErrDatabase, err.Error())
+		}
+
+		return nil
+	})
+}
+
+// Get return policy group by the policy group identifier.
+func (g *policyGroups) Get(
+	ctx context.Context,
+	username, name string,
+	opts metav1.GetOptions,
+) (*v1.PolicyGroup, error) {
+	group := &v1.PolicyGroup{}
+	err := g.db.Where("username = ? and name = ?", username, name).First(&group).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithCode(code.ErrPolicyGroupNotFound, err.Error())
+		}
+
+		return nil, errors.WrapC(err, code.ErrDatabase, err.Error())
+	}
+
+	return group, nil
+}
+
+// List return the policy groups of the user, or all policy groups if username is empty.
+func (g *policyGroups) List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.PolicyGroupList, error) {
+	ret := &v1.PolicyGroupList{}
+	ol := gormutil.Unpointer(opts.Offset, opts.Limit)
+
+	db := g.db
+	if username != "" {
+		db = db.Where("username = ?", username)
+	}
+
+	d := db.Offset(ol.Offset).
+		Limit(ol.Limit).
+		Order("id desc").
+		Find(&ret.Items).
+		Offset(-1).
+		Limit(-1).
+		Count(&ret.TotalCount)
+
+	return ret, d.Error
+}
+
+// Apply marks the policy group as applied in one transaction, which fails if some member
+// policies do not exist.
+func (g *policyGroups) Apply(ctx context.Context, username, name string) error {
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		group, err := getPolicyGroupForUpdate(tx, username, name)
+		if err != nil {
+			return err
+		}
+
+		var count int64
+		if len(group.PolicyIDs) > 0 {
+			err := tx.Model(&apiv1.Policy{}).
+				Where("username = ? and name in (?)", username, group.PolicyIDs).
+				Count(&count).Error
+			if err != nil {
+				return errors.WrapC(err, code.ErrDatabase, err.Error())
+			}
+		}
+
+		if count != int64(len(group.PolicyIDs)) {
+			return errors.WithCode(code.ErrPolicyNotFound,
+				"%d member policies of policy group %s do not exist", int64(len(group.PolicyIDs))-count, name)
+		}
+
+		if err := tx.Model(group).Update("applied", true).Error; err != nil {
+			return errors.WrapC(err, code.ErrDatabase, err.Error())
+		}
+
+		return nil
+	})
+}
+
+// getPolicyGroupForUpdate returns the policy group and locks it until the end of the transaction.
+func getPolicyGroupForUpdate(tx *gorm.DB, username, name string) (*v1.PolicyGroup, error) {
+	group := &v1.PolicyGroup{}
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("username = ? and name = ?", username, name).
+		First(&group).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithCode(code.ErrPolicyGroupNotFound, err.Error())
+		}
+
+		return nil, errors.WrapC(err, code.ErrDatabase, err.Error())
+	}
+
+	return group, nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolMetrics returns the metrics of the connection pool of db, read from its stats on each scrape.
+func poolMetrics(db *sql.DB) []prometheus.Collector {
+	gauge := func(name, help string, value func(sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "iam",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return value(db.Stats()) })
+	}
+	counter := func(name, help string, value func(sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "iam",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return value(db.Stats()) })
+	}
+
+	return []prometheus.Collector{
+		gauge("db_connections_max_open", "Maximum number of open connections to the database, 0 if unlimited.",
+			func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }),
+		gauge("db_connections_open", "Number of open connections to the database, in use or idle.",
+			func(s sql.DBStats) float64 { return float64(s.OpenConnections) }),
+		gauge("db_connections_in_use", "Number of connections to the database in use.",
+			func(s sql.DBStats) float64 { return float64(s.InUse) }),
+		gauge("db_connections_idle", "Number of idle connections to the database.",
+			func(s sql.DBStats) float64 { return float64(s.Idle) }),
+		// the waits are counted by database/sql since the start, so they are exported as counters.
+		counter("db_connections_wait_total", "Number of connections to the database waited for, "+
+			"the open connections being all in use.",
+			func(s sql.DBStats) float64 { return float64(s.WaitCount) }),
+		counter("db_connections_wait_seconds_total", "Time spent waiting for a connection to the database.",
+			func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }),
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func Test_poolMetrics(t *testing.T) {
+	sqlDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer sqlDB.Close()
+
+	sqlDB.SetMaxOpenConns(1)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(poolMetrics(sqlDB)...)
+
+	// the only connection is in use, the next one waits for it until its context is done.
+	conn, err := sqlDB.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Conn() error = %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := sqlDB.Conn(ctx); err == nil {
+		t.Fatal("Conn() of a full pool succeeded")
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+
+	got := map[string]float64{}
+	for _, family := range families {
+		metric := family.GetMetric()[0]
+		if counter := metric.GetCounter(); counter != nil {
+			got[family.GetName()] = counter.GetValue()
+		} else {
+			got[family.GetName()] = metric.GetGauge().GetValue()
+		}
+	}
+
+	tests := []struct {
+		name string
+		want float64
+	}{
+		{name: "iam_db_connections_max_open", want: 1},
+		{name: "iam_db_connections_open", want: 1},
+		{name: "iam_db_connections_in_use", want: 1},
+		{name: "iam_db_connections_idle", want: 0},
+		{name: "iam_db_connections_wait_total", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if value, ok := got[tt.name]; !ok || value != tt.want {
+				t.Errorf("%s = %v (exported %v), want %v", tt.name, value, ok, tt.want)
+			}
+		})
+	}
+
+	if wait := got["iam_db_connections_wait_seconds_total"]; wait < 0.01 {
+		t.Errorf("iam_db_connections_wait_seconds_total = %v, want at least the 10ms waited", wait)
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/marmotedu/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// The operation types of the queries.
+const (
+	operationList   = "list"
+	operationGet    = "get"
+	operationCreate = "create"
+	operationUpdate = "update"
+	operationDelete = "delete"
+)
+
+const (
+	queryBeforeName = "query:before"
+	queryAfterName  = "query:after"
+	queryStartTime  = "query:start_time"
+)
+
+// maskedValue replaces the values of the sensitive columns in the logged queries.
+const maskedValue = "******"
+
+// sensitiveColumns are the columns whose values are never logged, e.g. the password of the users.
+var sensitiveColumns = []string{"password", "secret_key"}
+
+var (
+	slowQueries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "iam",
+		Name:      "db_slow_queries_total",
+		Help:      "Number of mysql queries slower than --mysql.slow-query-threshold, by operation type.",
+	}, []string{"operation"})
+
+	operationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "iam",
+		Name:      "db_operation_duration_seconds",
+		Help:      "Duration of the database operations, by table and operation type.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"table", "operation"})
+
+	operationErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "iam",
+		Name:      "db_operation_errors_total",
+		Help:      "Number of failed database operations, by table and operation type, the missing records excluded.",
+	}, []string{"table", "operation"})
+)
+
+// queryPlugin is a gorm plugin which records the duration and the failures of the operations in the
+// db_operation_* metrics if metrics is set, and logs the operations taking longer than slowThreshold,
+// counted in the db_slow_queries_total counter, unless it is zero.
+type queryPlugin struct {
+	slowThreshold time.Duration
+	metrics       bool
+}
+
+var _ gorm.Plugin = (*queryPlugin)(nil)
+
+func newQueryPlugin(slowThreshold time.Duration, metrics bool) *queryPlugin {
+	return &queryPlugin{slowThreshold: slowThreshold, metrics: metrics}
+}
+
+// Name returns the name of the query plugin.
+func (p *queryPlugin) Name() string {
+	return "queryPlugin"
+}
+
+// Initialize registers the callbacks timing the create, query, update and delete operations.
+func (p *queryPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+
+	for _, err := range []error{
+		callback.Create().Before("gorm:before_create").Register(queryBeforeName, p.BeforeQuery),
+		callback.Query().Before("gorm:query").Register(queryBeforeName, p.BeforeQuery),
+		callback.Update().Before("gorm:setup_reflect_value").Register(queryBeforeName, p.BeforeQuery),
+		callback.Delete().Before("gorm:before_delete").Register(queryBeforeName, p.BeforeQuery),
+
+		callback.Create().After("gorm:after_create").Register(queryAfterName, p.afterQuery(operationCreate)),
+		// the queries are either lists or gets, which is known from their destination.
+		callback.Query().After("gorm:after_query").Register(queryAfterName, p.afterQuery("")),
+		callback.Update().After("gorm:after_update").Register(queryAfterName, p.afterQuery(operationUpdate)),
+		callback.Delete().After("gorm:after_delete").Register(queryAfterName, p.afterQuery(operationDelete)),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BeforeQuery records the start time of the operation.
+func (p *queryPlugin) BeforeQuery(db *gorm.DB) {
+	db.InstanceSet(queryStartTime, time.Now())
+}
+
+// AfterQuery records the operation, and logs and counts it if it was slow. The operation type is
+// deduced from the statement if operation is empty.
+func (p *queryPlugin) AfterQuery(db *gorm.DB, operation string) {
+	v, ok := db.InstanceGet(queryStartTime)
+	if !ok {
+		return
+	}
+
+	start, ok := v.(time.Time)
+	if !ok {
+		return
+	}
+
+	elapsed := time.Since(start)
+
+	if operation == "" {
+		operation = queryOperation(db)
+	}
+
+	table := db.Statement.Table
+
+	if p.metrics {
+		operationDuration.WithLabelValues(table, operation).Observe(elapsed.Seconds())
+
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			operationErrors.WithLabelValues(table, operation).Inc()
+		}
+	}
+
+	if p.slowThreshold == 0 || elapsed < p.slowThreshold {
+		return
+	}
+
+	slowQueries.WithLabelValues(operation).Inc()
+
+	log.L(db.Statement.Context).Warnw("Slow query",
+		"query", sanitizedSQL(db.Statement),
+		"table", table,
+		"operation", operation,
+		"duration_ms", elapsed.Milliseconds(),
+		"rows_affected", db.RowsAffected,
+	)
+}
+
+func (p *queryPlugin) afterQuery(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		p.AfterQuery(db, operation)
+	}
+}
+
+// queryOperation returns list if the query fills a slice, get otherwise.
+func queryOperation(db *gorm.DB) string {
+	value := reflect.ValueOf(db.Statement.Dest)
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+
+	if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+		return operationList
+	}
+
+	return operationGet
+}
+
+// sanitizedSQL returns the SQL of stmt with its values, the values of the sensitive columns of the
+// statement are masked.
+func sanitizedSQL(stmt *gorm.Statement) string {
+	sensitive := sensitiveValues(stmt)
+
+	vars := make([]interface{}, len(stmt.Vars))
+	for i, v := range stmt.Vars {
+		vars[i] = v
+		if s, ok := v.(string); ok && sensitive[s] {
+			vars[i] = maskedValue
+		}
+	}
+
+	return stmt.Dialector.Explain(stmt.SQL.String(), vars...)
+}
+
+// sensitiveValues returns the values of the sensitive columns given to stmt, by a model, the values of
+// a create or an update, or the map of an update.
+func sensitiveValues(stmt *gorm.Statement) map[string]bool {
+	values := map[string]bool{}
+
+	add := func(v interface{}) {
+		if s, ok := v.(string); ok && s != "" {
+			values[s] = true
+		}
+	}
+
+	if dest, ok := stmt.Dest.(map[string]interface{}); ok {
+		for _, column := range sensitiveColumns {
+			add(dest[column])
+		}
+	}
+
+	if stmt.Schema == nil {
+		return values
+	}
+
+	var fields []*schema.Field
+	for _, column := range sensitiveColumns {
+		if field := stmt.Schema.LookUpField(column); field != nil {
+			fields = append(fields, field)
+		}
+	}
+
+	if len(fields) == 0 {
+		return values
+	}
+
+	var addModels func(value reflect.Value)
+	addModels = func(value reflect.Value) {
+		value = reflect.Indirect(value)
+
+		switch value.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < value.Len(); i++ {
+				addModels(value.Index(i))
+			}
+		case reflect.Struct:
+			if value.Type() != stmt.Schema.ModelType {
+				return
+			}
+
+			for _, field := range fields {
+				v, _ := field.ValueOf(value)
+				add(v)
+			}
+		default:
+		}
+	}
+
+	addModels(stmt.ReflectValue)
+	addModels(reflect.ValueOf(stmt.Dest))
+	addModels(reflect.ValueOf(stmt.Model))
+
+	return values
+}
@@ -0,0 +1,381 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/gorm"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+type slowQueryRow struct {
+	ID       uint64
+	Name     string
+	Password string
+}
+
+const slowQueryTable = "slow_query_rows"
+
+// captureLogs makes the logs of the test written as json to a file, the returned function
+// returns the entries logged so far.
+func captureLogs(t *testing.T) func() []map[string]interface{} {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "log.json")
+	opts := log.NewOptions()
+	opts.Format = "json"
+	opts.OutputPaths = []string{path}
+	log.Init(opts)
+
+	t.Cleanup(func() { log.Init(log.NewOptions()) })
+
+	return func() []map[string]interface{} {
+		log.Flush()
+
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("open log failed: %v", err)
+		}
+		defer file.Close()
+
+		var entries []map[string]interface{}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			entry := map[string]interface{}{}
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				t.Fatalf("invalid log entry %q: %v", scanner.Text(), err)
+			}
+
+			entries = append(entries, entry)
+		}
+
+		return entries
+	}
+}
+
+func TestQueryPlugin(t *testing.T) {
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "colin").AddRow(2, "alice")
+	}
+
+	tests := []struct {
+		name      string
+		delay     time.Duration
+		expect    func(mock sqlmock.Sqlmock, delay time.Duration)
+		run       func(db *gorm.DB) error
+		operation string
+		wantSlow  bool
+		// wantRows is the rows_affected logged.
+		wantRows float64
+		// wantErr is whether the operation returns an error, counted as a failure if wantFailed.
+		wantErr    bool
+		wantFailed bool
+	}{
+		{
+			name:  "slow list",
+			delay: 50 * time.Millisecond,
+			expect: func(mock sqlmock.Sqlmock, delay time.Duration) {
+				mock.ExpectQuery("SELECT (.+) FROM `slow_query_rows`").WillDelayFor(delay).WillReturnRows(rows())
+			},
+			run: func(db *gorm.DB) error {
+				var found []slowQueryRow
+
+				return db.Find(&found).Error
+			},
+			operation: operationList,
+			wantSlow:  true,
+			wantRows:  2,
+		},
+		{
+			name:  "slow get",
+			delay: 50 * time.Millisecond,
+			expect: func(mock sqlmock.Sqlmock, delay time.Duration) {
+				mock.ExpectQuery("SELECT (.+) FROM `slow_query_rows`").WillDelayFor(delay).WillReturnRows(rows())
+			},
+			run: func(db *gorm.DB) error {
+				var found slowQueryRow
+
+				return db.First(&found).Error
+			},
+			operation: operationGet,
+			wantSlow:  true,
+			wantRows:  1,
+		},
+		{
+			name:  "slow create",
+			delay: 50 * time.Millisecond,
+			expect: func(mock sqlmock.Sqlmock, delay time.Duration) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO `slow_query_rows`").WillDelayFor(delay).
+					WillReturnResult(sqlmock.NewResult(3, 1))
+				mock.ExpectCommit()
+			},
+			run: func(db *gorm.DB) error {
+				return db.Create(&slowQueryRow{Name: "bob"}).Error
+			},
+			operation: operationCreate,
+			wantSlow:  true,
+			wantRows:  1,
+		},
+		{
+			name: "fast delete",
+			expect: func(mock sqlmock.Sqlmock, delay time.Duration) {
+				mock.ExpectBegin()
+				mock.ExpectExec("DELETE FROM `slow_query_rows`").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			run: func(db *gorm.DB) error {
+				return db.Delete(&slowQueryRow{ID: 1}).Error
+			},
+			operation: operationDelete,
+		},
+		{
+			name: "missing get",
+			expect: func(mock sqlmock.Sqlmock, delay time.Duration) {
+				mock.ExpectQuery("SELECT (.+) FROM `slow_query_rows`").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			run: func(db *gorm.DB) error {
+				var found slowQueryRow
+
+				return db.First(&found).Error
+			},
+			operation: operationGet,
+			wantErr:   true,
+		},
+		{
+			name: "failed update",
+			expect: func(mock sqlmock.Sqlmock, delay time.Duration) {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE `slow_query_rows`").WillReturnError(errTimeout)
+				mock.ExpectRollback()
+			},
+			run: func(db *gorm.DB) error {
+				return db.Model(&slowQueryRow{ID: 1}).Update("name", "bob").Error
+			},
+			operation:  operationUpdate,
+			wantErr:    true,
+			wantFailed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			ds, mock := newMockDatastore(t)
+
+			if err := ds.db.Use(newQueryPlugin(20*time.Millisecond, true)); err != nil {
+				t.Fatalf("Use() error = %v", err)
+			}
+
+			tt.expect(mock, tt.delay)
+
+			before := testutil.ToFloat64(slowQueries.WithLabelValues(tt.operation))
+			operationsBefore := operationCount(t, slowQueryTable, tt.operation)
+			failuresBefore := testutil.ToFloat64(operationErrors.WithLabelValues(slowQueryTable, tt.operation))
+
+			if err := tt.run(ds.db); (err != nil) != tt.wantErr {
+				t.Fatalf("query error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := operationCount(t, slowQueryTable, tt.operation) - operationsBefore; got != 1 {
+				t.Errorf("db_operation_duration_seconds{operation=%q} observed %d operations, want 1", tt.operation, got)
+			}
+
+			wantFailures := 0.0
+			if tt.wantFailed {
+				wantFailures = 1
+			}
+
+			failures := testutil.ToFloat64(operationErrors.WithLabelValues(slowQueryTable, tt.operation))
+			if got := failures - failuresBefore; got != wantFailures {
+				t.Errorf("db_operation_errors_total{operation=%q} increased by %v, want %v", tt.operation, got, wantFailures)
+			}
+
+			wantCount := 0
+			if tt.wantSlow {
+				wantCount = 1
+			}
+
+			if got := testutil.ToFloat64(slowQueries.WithLabelValues(tt.operation)) - before; got != float64(wantCount) {
+				t.Errorf("db_slow_queries_total{operation=%q} increased by %v, want %d", tt.operation, got, wantCount)
+			}
+
+			var slow []map[string]interface{}
+
+			for _, entry := range logs() {
+				if entry["message"] == "Slow query" {
+					slow = append(slow, entry)
+				}
+			}
+
+			if len(slow) != wantCount {
+				t.Fatalf("%d slow queries logged, want %d", len(slow), wantCount)
+			}
+
+			if !tt.wantSlow {
+				return
+			}
+
+			entry := slow[0]
+			if entry["level"] != "WARN" || entry["operation"] != tt.operation || entry["table"] != slowQueryTable ||
+				entry["rows_affected"] != tt.wantRows {
+				t.Errorf("logged %v, want a warning of the %s affecting %v rows", entry, tt.operation, tt.wantRows)
+			}
+
+			if duration, _ := entry["duration_ms"].(float64); duration < float64(tt.delay.Milliseconds()) {
+				t.Errorf("duration_ms = %v, want at least %d", entry["duration_ms"], tt.delay.Milliseconds())
+			}
+
+			if query, _ := entry["query"].(string); query == "" {
+				t.Error("the query is not logged")
+			}
+		})
+	}
+}
+
+func TestQueryPlugin_SensitiveColumns(t *testing.T) {
+	const password = "$2a$10$secret-password-hash"
+
+	tests := []struct {
+		name   string
+		expect func(mock sqlmock.Sqlmock)
+		run    func(db *gorm.DB) error
+	}{
+		{
+			name: "create",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO `slow_query_rows`").WillDelayFor(30 * time.Millisecond).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			run: func(db *gorm.DB) error {
+				return db.Create(&slowQueryRow{Name: "colin", Password: password}).Error
+			},
+		},
+		{
+			name: "create in batch",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO `slow_query_rows`").WillDelayFor(30 * time.Millisecond).
+					WillReturnResult(sqlmock.NewResult(2, 2))
+				mock.ExpectCommit()
+			},
+			run: func(db *gorm.DB) error {
+				return db.Create([]*slowQueryRow{{Name: "colin", Password: password}, {Name: "alice"}}).Error
+			},
+		},
+		{
+			name: "update of the model",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE `slow_query_rows`").WillDelayFor(30 * time.Millisecond).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			run: func(db *gorm.DB) error {
+				return db.Save(&slowQueryRow{ID: 1, Name: "colin", Password: password}).Error
+			},
+		},
+		{
+			name: "update of a column",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE `slow_query_rows`").WillDelayFor(30 * time.Millisecond).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			run: func(db *gorm.DB) error {
+				return db.Model(&slowQueryRow{ID: 1}).Update("password", password).Error
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			ds, mock := newMockDatastore(t)
+
+			if err := ds.db.Use(newQueryPlugin(20*time.Millisecond, false)); err != nil {
+				t.Fatalf("Use() error = %v", err)
+			}
+
+			tt.expect(mock)
+
+			ctx := context.WithValue(context.Background(), log.KeyRequestID, "request-1")
+			if err := tt.run(ds.db.WithContext(ctx)); err != nil {
+				t.Fatalf("query error = %v", err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+
+			entries := logs()
+			if len(entries) != 1 {
+				t.Fatalf("%d entries logged, want the slow query", len(entries))
+			}
+
+			entry := entries[0]
+			if entry[log.KeyRequestID] != "request-1" {
+				t.Errorf("logged %s = %v, want request-1", log.KeyRequestID, entry[log.KeyRequestID])
+			}
+
+			query, _ := entry["query"].(string)
+			if !strings.Contains(query, maskedValue) {
+				t.Errorf("logged query %q, want the password masked", query)
+			}
+
+			raw, _ := json.Marshal(entries)
+			if strings.Contains(string(raw), password) {
+				t.Errorf("logged the password: %s", raw)
+			}
+		})
+	}
+}
+
+// operationCount returns the number of operations of the given table and type observed by
+// db_operation_duration_seconds.
+func operationCount(t *testing.T, table, operation string) uint64 {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(operationDuration)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			if labels["table"] == table && labels["operation"] == operation {
+				return metric.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+
+	return 0
+}
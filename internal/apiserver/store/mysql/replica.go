@@ -0,0 +1,147 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+const replicaName = "replica:route"
+
+var replicaFallbacks = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "iam",
+	Name:      "db_replica_fallbacks_total",
+	Help:      "Number of reads routed to a read replica which failed and were served by the primary database.",
+})
+
+// ReadRouter decides whether the reads of ctx are served by the read replicas, see FactoryOptions.
+type ReadRouter func(ctx context.Context) bool
+
+// replicaPlugin is a gorm plugin which routes the queries whose context is accepted by router to the
+// replicas, in turn. The writes, and the queries of the transactions, are always executed by the primary.
+type replicaPlugin struct {
+	replicas []*sql.DB
+	router   ReadRouter
+	next     uint32
+}
+
+var _ gorm.Plugin = (*replicaPlugin)(nil)
+
+func newReplicaPlugin(replicas []*sql.DB, router ReadRouter) *replicaPlugin {
+	return &replicaPlugin{replicas: replicas, router: router}
+}
+
+// Name returns the name of the replica plugin.
+func (p *replicaPlugin) Name() string {
+	return "replicaPlugin"
+}
+
+// Initialize registers the callbacks routing the queries.
+func (p *replicaPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+
+	if err := callback.Query().Before("gorm:query").Register(replicaName, p.Route); err != nil {
+		return err
+	}
+
+	return callback.Row().Before("gorm:row").Register(replicaName, p.Route)
+}
+
+// Route sets the connection pool of the statement to a replica if its context is routed to the replicas.
+func (p *replicaPlugin) Route(db *gorm.DB) {
+	stmt := db.Statement
+
+	switch stmt.ConnPool.(type) {
+	case gorm.TxCommitter:
+		// the transactions read their own writes.
+		return
+	case *replicaPool:
+		// routed already, e.g. the count following the find of a list.
+		return
+	}
+
+	if !p.router(stmt.Context) {
+		return
+	}
+
+	next := atomic.AddUint32(&p.next, 1)
+	stmt.ConnPool = &replicaPool{
+		replica: p.replicas[int(next)%len(p.replicas)],
+		primary: stmt.ConnPool,
+	}
+}
+
+// Close closes the connection pools of the replicas.
+func (p *replicaPlugin) Close() error {
+	var err error
+
+	for _, replica := range p.replicas {
+		if e := replica.Close(); e != nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// replicaPool is the connection pool of a statement routed to a replica. The queries failing on the
+// replica are executed again by the primary, the statements executed by the primary.
+type replicaPool struct {
+	replica *sql.DB
+	primary gorm.ConnPool
+}
+
+var _ gorm.ConnPool = (*replicaPool)(nil)
+
+func (p *replicaPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	stmt, err := p.replica.PrepareContext(ctx, query)
+	if err != nil && p.fallback(ctx, err) {
+		return p.primary.PrepareContext(ctx, query)
+	}
+
+	return stmt, err
+}
+
+func (p *replicaPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return p.primary.ExecContext(ctx, query, args...)
+}
+
+func (p *replicaPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := p.replica.QueryContext(ctx, query, args...)
+	if err != nil && p.fallback(ctx, err) {
+		return p.primary.QueryContext(ctx, query, args...)
+	}
+
+	return rows, err
+}
+
+func (p *replicaPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	row := p.replica.QueryRowContext(ctx, query, args...)
+	if err := row.Err(); err != nil && p.fallback(ctx, err) {
+		return p.primary.QueryRowContext(ctx, query, args...)
+	}
+
+	return row
+}
+
+// fallback logs the failure of the replica and reports whether the query is executed by the primary,
+// unless ctx is done.
+func (p *replicaPool) fallback(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	replicaFallbacks.Inc()
+	log.L(ctx).Warnw("Read replica failed, reading from the primary", "error", err.Error())
+
+	return true
+}
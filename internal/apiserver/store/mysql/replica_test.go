@@ -0,0 +1,141 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+)
+
+// newMockReplica returns a datastore whose reads are routed to a mocked replica if route is set, the
+// routings are counted by routed.
+func newMockReplica(t *testing.T, route bool) (ds *datastore, primary, replica sqlmock.Sqlmock, routed *int) {
+	t.Helper()
+
+	ds, primary = newMockDatastore(t)
+
+	replicaDB, replica, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+
+	t.Cleanup(func() { replicaDB.Close() })
+
+	routed = new(int)
+	router := func(ctx context.Context) bool {
+		*routed++
+
+		return route
+	}
+	if err := ds.db.Use(newReplicaPlugin([]*sql.DB{replicaDB}, router)); err != nil {
+		t.Fatalf("Use() error = %v", err)
+	}
+
+	return ds, primary, replica, routed
+}
+
+func TestReplicaPlugin_Read(t *testing.T) {
+	tests := []struct {
+		name  string
+		route bool
+		// replicaErr is the error of the replica if the read is routed to it, the read is then served
+		// by the primary.
+		replicaErr  error
+		wantPrimary bool
+	}{
+		{name: "routed to the replica", route: true},
+		{name: "not routed", wantPrimary: true},
+		{name: "replica failed", route: true, replicaErr: errTimeout, wantPrimary: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds, primary, replica, _ := newMockReplica(t, tt.route)
+
+			if tt.route {
+				expectUserQuery(replica, tt.replicaErr)
+			}
+			if tt.wantPrimary {
+				expectUserQuery(primary, nil)
+			}
+
+			// the user is not found, whoever reads it.
+			_, _ = newUsers(ds).Get(context.TODO(), "colin", metav1.GetOptions{})
+
+			if err := replica.ExpectationsWereMet(); err != nil {
+				t.Errorf("replica: %v", err)
+			}
+			if err := primary.ExpectationsWereMet(); err != nil {
+				t.Errorf("primary: %v", err)
+			}
+		})
+	}
+}
+
+func TestReplicaPlugin_Primary(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect func(primary sqlmock.Sqlmock)
+		run    func(ds *datastore) error
+	}{
+		{
+			name: "write",
+			expect: func(primary sqlmock.Sqlmock) {
+				primary.ExpectBegin()
+				primary.ExpectExec("INSERT INTO `policy`").WillReturnResult(sqlmock.NewResult(1, 1))
+				// the instanceID is set by the AfterCreate hook of the policy.
+				primary.ExpectExec("UPDATE `policy` SET `instanceID`").WillReturnResult(sqlmock.NewResult(1, 1))
+				primary.ExpectCommit()
+			},
+			run: func(ds *datastore) error {
+				policy := &v1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "policy"}, Username: "colin"}
+
+				return newPolicies(ds).Create(context.TODO(), policy, metav1.CreateOptions{})
+			},
+		},
+		{
+			name: "read of a transaction",
+			expect: func(primary sqlmock.Sqlmock) {
+				primary.ExpectBegin()
+				expectUserQuery(primary, nil)
+				primary.ExpectCommit()
+			},
+			run: func(ds *datastore) error {
+				return ds.WithTransaction(context.TODO(), func(tx store.Factory) error {
+					_, _ = tx.Users().Get(context.TODO(), "colin", metav1.GetOptions{})
+
+					return nil
+				})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds, primary, replica, routed := newMockReplica(t, true)
+			tt.expect(primary)
+
+			if err := tt.run(ds); err != nil {
+				t.Fatalf("run error = %v", err)
+			}
+
+			if *routed != 0 {
+				t.Errorf("routed %d queries, want none", *routed)
+			}
+
+			if err := replica.ExpectationsWereMet(); err != nil {
+				t.Errorf("replica: %v", err)
+			}
+			if err := primary.ExpectationsWereMet(); err != nil {
+				t.Errorf("primary: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"database/sql/driver"
+	"math/rand"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/marmotedu/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// The causes of the transient errors, after which the operations are retried.
+const (
+	causeDeadlock        = "deadlock"
+	causeLockWaitTimeout = "lock_wait_timeout"
+	causeBadConnection   = "bad_connection"
+)
+
+const (
+	// mysqlDeadlock and mysqlLockWaitTimeout are the mysql error numbers of a transaction rolled back
+	// because of a deadlock, and of a statement waiting too long for a lock.
+	mysqlDeadlock        = 1213
+	mysqlLockWaitTimeout = 1205
+	// postgresDeadlock and postgresLockNotAvailable are their postgres SQLSTATE.
+	postgresDeadlock         = "40P01"
+	postgresLockNotAvailable = "55P03"
+)
+
+var retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "iam",
+	Name:      "db_retries_total",
+	Help:      "Number of database operations retried after a transient error, by cause.",
+}, []string{"cause"})
+
+// transientCause returns the cause of err if the operation failing with it may succeed once retried, e.g.
+// a transaction rolled back because of a deadlock.
+func transientCause(err error) (string, bool) {
+	if err == nil {
+		return "", false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) {
+		return causeBadConnection, true
+	}
+
+	var mysqlErr *gomysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case mysqlDeadlock:
+			return causeDeadlock, true
+		case mysqlLockWaitTimeout:
+			return causeLockWaitTimeout, true
+		}
+
+		return "", false
+	}
+
+	var sqlErr sqlStateError
+	if errors.As(err, &sqlErr) {
+		switch sqlErr.SQLState() {
+		case postgresDeadlock:
+			return causeDeadlock, true
+		case postgresLockNotAvailable:
+			return causeLockWaitTimeout, true
+		}
+	}
+
+	return "", false
+}
+
+// retrier retries the operations failing with a transient error up to maxRetries times, after a jittered
+// backoff doubling from backoff. Only the operations safe to run again are retried: the reads, and the
+// transactions, which are rolled back as a whole.
+type retrier struct {
+	maxRetries int
+	backoff    time.Duration
+	// sleep waits between the attempts, replaced by the tests.
+	sleep func(time.Duration)
+}
+
+func newRetrier(maxRetries int, backoff time.Duration) *retrier {
+	return &retrier{maxRetries: maxRetries, backoff: backoff, sleep: time.Sleep}
+}
+
+// do calls fn until it succeeds, fails with an error which is not transient, or was retried maxRetries
+// times. A nil retrier calls fn once.
+func (r *retrier) do(fn func() error) error {
+	err := fn()
+
+	for retry := 0; r != nil && retry < r.maxRetries; retry++ {
+		cause, ok := transientCause(err)
+		if !ok {
+			return err
+		}
+
+		retries.WithLabelValues(cause).Inc()
+		log.Debugw("Retry the database operation", "cause", cause, "retry", retry+1, "error", err.Error())
+
+		r.sleep(r.wait(retry))
+
+		err = fn()
+	}
+
+	return err
+}
+
+// wait returns the backoff before the given retry, between the half and the whole of the doubled backoff,
+// so that the operations which deadlocked together are not retried at the same time.
+func (r *retrier) wait(retry int) time.Duration {
+	backoff := r.backoff << retry
+	if backoff <= 0 {
+		return 0
+	}
+
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)) // nolint: gosec
+}
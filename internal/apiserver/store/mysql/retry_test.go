@@ -0,0 +1,231 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	gomysql "github.com/go-sql-driver/mysql"
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+var errDeadlock = &gomysql.MySQLError{Number: mysqlDeadlock, Message: "Deadlock found when trying to get lock"}
+
+// sqlStateErr is an error of the database server of the given SQLSTATE, like *pgconn.PgError.
+type sqlStateErr string
+
+func (e sqlStateErr) Error() string    { return "ERROR: SQLSTATE " + string(e) }
+func (e sqlStateErr) SQLState() string { return string(e) }
+
+func TestTransientCause(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantCause string
+	}{
+		{name: "deadlock", err: errDeadlock, wantCause: causeDeadlock},
+		{
+			name:      "lock wait timeout",
+			err:       &gomysql.MySQLError{Number: mysqlLockWaitTimeout, Message: "Lock wait timeout exceeded"},
+			wantCause: causeLockWaitTimeout,
+		},
+		{name: "bad connection", err: driver.ErrBadConn, wantCause: causeBadConnection},
+		{
+			name:      "wrapped by the store",
+			err:       errors.WrapC(errDeadlock, code.ErrDatabase, errDeadlock.Error()),
+			wantCause: causeDeadlock,
+		},
+		{name: "postgres deadlock", err: sqlStateErr(postgresDeadlock), wantCause: causeDeadlock},
+		{name: "postgres lock not available", err: sqlStateErr(postgresLockNotAvailable), wantCause: causeLockWaitTimeout},
+		{name: "duplicate entry", err: &gomysql.MySQLError{Number: mysqlDuplicateEntry, Message: "Duplicate entry"}},
+		{name: "timeout", err: errTimeout},
+		{name: "no error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cause, ok := transientCause(tt.err)
+			if cause != tt.wantCause || ok != (tt.wantCause != "") {
+				t.Errorf("transientCause() = %q, %v, want %q", cause, ok, tt.wantCause)
+			}
+		})
+	}
+}
+
+func TestRetrier_Do(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries int
+		// errs are the errors of the successive calls, the calls after the last one succeed.
+		errs        []error
+		wantCalls   int
+		wantErr     error
+		wantRetries map[string]float64
+	}{
+		{
+			name:        "retried until it succeeds",
+			maxRetries:  3,
+			errs:        []error{errDeadlock, driver.ErrBadConn},
+			wantCalls:   3,
+			wantRetries: map[string]float64{causeDeadlock: 1, causeBadConnection: 1},
+		},
+		{
+			name:        "retries exhausted",
+			maxRetries:  2,
+			errs:        []error{errDeadlock, errDeadlock, errDeadlock, errDeadlock},
+			wantCalls:   3,
+			wantErr:     errDeadlock,
+			wantRetries: map[string]float64{causeDeadlock: 2},
+		},
+		{
+			name:       "not transient",
+			maxRetries: 3,
+			errs:       []error{errTimeout, errDeadlock},
+			wantCalls:  1,
+			wantErr:    errTimeout,
+		},
+		{
+			name:      "disabled",
+			errs:      []error{errDeadlock},
+			wantCalls: 1,
+			wantErr:   errDeadlock,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var waits []time.Duration
+
+			r := newRetrier(tt.maxRetries, 10*time.Millisecond)
+			r.sleep = func(d time.Duration) { waits = append(waits, d) }
+
+			before := map[string]float64{}
+			for _, cause := range []string{causeDeadlock, causeLockWaitTimeout, causeBadConnection} {
+				before[cause] = testutil.ToFloat64(retries.WithLabelValues(cause))
+			}
+
+			calls := 0
+			err := r.do(func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+
+				return nil
+			})
+
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("do() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("do() called fn %d times, want %d", calls, tt.wantCalls)
+			}
+
+			for cause, count := range before {
+				if got := testutil.ToFloat64(retries.WithLabelValues(cause)) - count; got != tt.wantRetries[cause] {
+					t.Errorf("db_retries_total{cause=%q} increased by %v, want %v", cause, got, tt.wantRetries[cause])
+				}
+			}
+
+			// the backoff doubles, jittered between its half and its whole.
+			for i, wait := range waits {
+				backoff := 10 * time.Millisecond << i
+				if wait < backoff/2 || wait > backoff {
+					t.Errorf("wait %d = %s, want between %s and %s", i, wait, backoff/2, backoff)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerFactory_Retry(t *testing.T) {
+	createPolicy := func(ctx context.Context, factory store.Factory) error {
+		policy := &v1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "policy"}, Username: "colin"}
+
+		return factory.Policies().Create(ctx, policy, metav1.CreateOptions{})
+	}
+
+	tests := []struct {
+		name    string
+		expect  func(mock sqlmock.Sqlmock)
+		run     func(factory store.Factory) error
+		wantErr bool
+	}{
+		{
+			name: "read",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectUserQuery(mock, errDeadlock)
+				expectUserQuery(mock, nil)
+			},
+			run: func(factory store.Factory) error {
+				_, err := factory.Users().Get(context.Background(), "colin", metav1.GetOptions{})
+				if errors.IsCode(err, code.ErrUserNotFound) {
+					return nil
+				}
+
+				return err
+			},
+		},
+		{
+			name: "write not retried",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO `policy`").WillReturnError(errDeadlock)
+				mock.ExpectRollback()
+			},
+			run: func(factory store.Factory) error {
+				return createPolicy(context.Background(), factory)
+			},
+			wantErr: true,
+		},
+		{
+			name: "transaction retried as a whole",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO `policy`").WillReturnError(errDeadlock)
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO `policy`").WillReturnResult(sqlmock.NewResult(1, 1))
+				// the instanceID is set by the AfterCreate hook of the policy.
+				mock.ExpectExec("UPDATE `policy` SET `instanceID`").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			run: func(factory store.Factory) error {
+				return factory.WithTransaction(context.Background(), func(tx store.Factory) error {
+					return createPolicy(context.Background(), tx)
+				})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds, mock := newMockDatastore(t)
+
+			retry := newRetrier(2, time.Millisecond)
+			retry.sleep = func(time.Duration) {}
+
+			factory := newCircuitBreakerFactory(ds, &circuitBreakers{retry: retry})
+
+			tt.expect(mock)
+
+			if err := tt.run(factory); (err != nil) != tt.wantErr {
+				t.Fatalf("run error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
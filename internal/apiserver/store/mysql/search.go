@@ -0,0 +1,116 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+)
+
+// likeEscape escapes the wildcards of the LIKE patterns of the searches. It is not the backslash, which
+// is the escape of the string literals of mysql but not of postgres.
+const likeEscape = "!"
+
+var likeEscaper = strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_")
+
+// escapeLike returns s escaped for a LIKE pattern escaped by likeEscape, its wildcards are matched
+// literally.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// fullTextIndexes are the FULLTEXT indexes of the searched columns, see 0010_search_fulltext.
+var fullTextIndexes = map[string][]string{
+	"user":   {"ft_name", "ft_nickname", "ft_email"},
+	"policy": {"ft_name", "ft_username"},
+}
+
+// fullTextPlugin is a gorm plugin which marks the mysql dbs whose searched columns have their FULLTEXT
+// indexes, the searches of the stores rank the rows of the same relevance with them.
+type fullTextPlugin struct{}
+
+var _ gorm.Plugin = (*fullTextPlugin)(nil)
+
+// Name returns the name of the full text plugin.
+func (p *fullTextPlugin) Name() string {
+	return "fullTextPlugin"
+}
+
+// Initialize does nothing, the plugin is a marker of the db.
+func (p *fullTextPlugin) Initialize(db *gorm.DB) error {
+	return nil
+}
+
+// fullTextIndexed reports whether db is of mysql and has the FULLTEXT indexes of the searched columns.
+func fullTextIndexed(db *gorm.DB) bool {
+	if db.Dialector.Name() != "mysql" {
+		return false
+	}
+
+	for table, indexes := range fullTextIndexes {
+		for _, index := range indexes {
+			if !db.Migrator().HasIndex(table, index) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// search returns db restricted to the rows whose columns contain the normalized query of opts, ordered by
+// relevance then by descending id, see store.SearchOptions. The rows are compared in lower case, for
+// postgres whose LIKE is case sensitive. The rows of the same relevance are ranked by the FULLTEXT
+// indexes of the columns first when available, see fullTextPlugin.
+func search(db *gorm.DB, columns []string, opts store.SearchOptions) *gorm.DB {
+	query := opts.Normalized()
+	if query == "" {
+		return db.Order("id desc")
+	}
+
+	var (
+		contains, exact, prefix []string
+		containsVars            []interface{}
+		exactVars, prefixVars   []interface{}
+		fullText                []string
+		fullTextVars            []interface{}
+	)
+
+	for _, name := range columns {
+		column := clause.Column{Name: name}
+
+		contains = append(contains, "LOWER(?) LIKE ? ESCAPE '"+likeEscape+"'")
+		containsVars = append(containsVars, column, "%"+escapeLike(query)+"%")
+		exact = append(exact, "LOWER(?) = ?")
+		exactVars = append(exactVars, column, query)
+		prefix = append(prefix, "LOWER(?) LIKE ? ESCAPE '"+likeEscape+"'")
+		prefixVars = append(prefixVars, column, escapeLike(query)+"%")
+		fullText = append(fullText, "MATCH(?) AGAINST(? IN NATURAL LANGUAGE MODE)")
+		fullTextVars = append(fullTextVars, column, query)
+	}
+
+	// the relevances are constants of the statement, postgres cannot infer the type of parameters.
+	relevance := "CASE WHEN " + strings.Join(exact, " OR ") + " THEN " + strconv.Itoa(store.RelevanceExact) +
+		" WHEN " + strings.Join(prefix, " OR ") + " THEN " + strconv.Itoa(store.RelevancePrefix) +
+		" ELSE " + strconv.Itoa(store.RelevanceContains) + " END DESC"
+	vars := append(exactVars, prefixVars...)
+
+	if _, ok := db.Config.Plugins[(&fullTextPlugin{}).Name()]; ok {
+		relevance += ", " + strings.Join(fullText, " + ") + " DESC"
+		vars = append(vars, fullTextVars...)
+	}
+
+	return db.Where(clause.Expr{SQL: strings.Join(contains, " OR "), Vars: containsVars}).
+		Clauses(clause.OrderBy{Expression: clause.Expr{
+			SQL:                relevance + ", ? DESC",
+			Vars:               append(vars, clause.Column{Name: "id"}),
+			WithoutParentheses: true,
+		}})
+}
@@ -0,0 +1,143 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+func TestUsers_Search(t *testing.T) {
+	const (
+		nickname = "SELECT \\* FROM `user` WHERE status = 1 AND LOWER\\(`nickname`\\) LIKE \\? ESCAPE '!' " +
+			"ORDER BY CASE WHEN LOWER\\(`nickname`\\) = \\? THEN 3 WHEN LOWER\\(`nickname`\\) LIKE \\? ESCAPE '!' " +
+			"THEN 2 ELSE 1 END DESC, "
+		countNickname = "SELECT count\\(\\*\\) FROM `user` WHERE status = 1 AND LOWER\\(`nickname`\\) LIKE \\? ESCAPE '!'$"
+	)
+
+	tests := []struct {
+		name     string
+		search   store.SearchOptions
+		fullText bool
+		// query is the query of the users found, with args, counted by count with countArgs.
+		query     string
+		args      []driver.Value
+		count     string
+		countArgs []driver.Value
+		wantCode  int
+	}{
+		{
+			name:   "empty query",
+			search: store.SearchOptions{Query: "  "},
+			query:  "SELECT \\* FROM `user` WHERE status = 1 ORDER BY id desc LIMIT 1000$",
+			count:  "SELECT count\\(\\*\\) FROM `user` WHERE status = 1$",
+		},
+		{
+			name:      "percent",
+			search:    store.SearchOptions{Fields: []string{"nickname"}, Query: "100%"},
+			query:     nickname + "`id` DESC LIMIT 1000$",
+			args:      []driver.Value{"%100!%%", "100%", "100!%%"},
+			count:     countNickname,
+			countArgs: []driver.Value{"%100!%%"},
+		},
+		{
+			name:      "underscore",
+			search:    store.SearchOptions{Fields: []string{"nickname"}, Query: "a_b"},
+			query:     nickname + "`id` DESC LIMIT 1000$",
+			args:      []driver.Value{"%a!_b%", "a_b", "a!_b%"},
+			count:     countNickname,
+			countArgs: []driver.Value{"%a!_b%"},
+		},
+		{
+			name:      "escape",
+			search:    store.SearchOptions{Fields: []string{"nickname"}, Query: "a!\\b"},
+			query:     nickname + "`id` DESC LIMIT 1000$",
+			args:      []driver.Value{"%a!!\\b%", "a!\\b", "a!!\\b%"},
+			count:     countNickname,
+			countArgs: []driver.Value{"%a!!\\b%"},
+		},
+		{
+			name:      "case and spaces",
+			search:    store.SearchOptions{Fields: []string{"nickname"}, Query: " Colin "},
+			query:     nickname + "`id` DESC LIMIT 1000$",
+			args:      []driver.Value{"%colin%", "colin", "colin%"},
+			count:     countNickname,
+			countArgs: []driver.Value{"%colin%"},
+		},
+		{
+			name:      "full text",
+			search:    store.SearchOptions{Fields: []string{"nickname"}, Query: "colin"},
+			fullText:  true,
+			query:     nickname + "MATCH\\(`nickname`\\) AGAINST\\(\\? IN NATURAL LANGUAGE MODE\\) DESC, `id` DESC LIMIT 1000$",
+			args:      []driver.Value{"%colin%", "colin", "colin%", "colin"},
+			count:     countNickname,
+			countArgs: []driver.Value{"%colin%"},
+		},
+		{
+			name:   "all fields",
+			search: store.SearchOptions{Query: "colin"},
+			query: "SELECT \\* FROM `user` WHERE status = 1 AND \\(LOWER\\(`name`\\) LIKE \\? ESCAPE '!' OR " +
+				"LOWER\\(`nickname`\\) LIKE \\? ESCAPE '!' OR LOWER\\(`email`\\) LIKE \\? ESCAPE '!'\\) ORDER BY",
+			args: []driver.Value{
+				"%colin%", "%colin%", "%colin%",
+				"colin", "colin", "colin",
+				"colin%", "colin%", "colin%",
+			},
+			count:     "SELECT count\\(\\*\\) FROM `user` WHERE status = 1 AND \\(.+\\)$",
+			countArgs: []driver.Value{"%colin%", "%colin%", "%colin%"},
+		},
+		{
+			name:     "unknown field",
+			search:   store.SearchOptions{Fields: []string{"password"}, Query: "colin"},
+			wantCode: code.ErrValidation,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds, mock := newMockDatastore(t)
+
+			if tt.fullText {
+				if err := ds.db.Use(&fullTextPlugin{}); err != nil {
+					t.Fatalf("Use() error = %v", err)
+				}
+			}
+
+			if tt.wantCode == 0 {
+				mock.ExpectQuery(tt.query).WithArgs(tt.args...).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "extendShadow"}).AddRow(1, "colin", "{}"))
+				mock.ExpectQuery(tt.count).WithArgs(tt.countArgs...).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			}
+
+			users, err := newUsers(ds).Search(context.TODO(), tt.search, metav1.ListOptions{})
+			if tt.wantCode != 0 {
+				if !errors.IsCode(err, tt.wantCode) {
+					t.Fatalf("Search() error = %v, wantCode %d", err, tt.wantCode)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+
+			if users.TotalCount != 1 || len(users.Items) != 1 {
+				t.Errorf("Search() = %d users %v, want colin", users.TotalCount, users.Items)
+			}
+		})
+	}
+}
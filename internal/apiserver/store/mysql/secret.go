@@ -13,6 +13,8 @@ import (
 	"github.com/marmotedu/errors"
 	"gorm.io/gorm"
 
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/pkg/code"
 	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
 )
@@ -27,7 +29,7 @@ func newSecrets(ds *datastore) *secrets {
 
 // Create creates a new secret.
 func (s *secrets) Create(ctx context.Context, secret *v1.Secret, opts metav1.CreateOptions) error {
-	return s.db.Create(&secret).Error
+	return translateError(s.db.Create(&secret).Error)
 }
 
 // Update updates an secret information by the secret identifier.
@@ -43,7 +45,7 @@ func (s *secrets) Delete(ctx context.Context, username, name string, opts metav1
 
 	err := s.db.Where("username = ? and name = ?", username, name).Delete(&v1.Secret{}).Error
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return errors.WithCode(code.ErrDatabase, err.Error())
+		return errors.WrapC(err, code.ErrDatabase, err.Error())
 	}
 
 	return nil
@@ -66,18 +68,46 @@ func (s *secrets) DeleteCollection(
 // Get return an secret by the secret identifier.
 func (s *secrets) Get(ctx context.Context, username, name string, opts metav1.GetOptions) (*v1.Secret, error) {
 	secret := &v1.Secret{}
-	err := s.db.Where("username = ? and name= ?", username, name).First(&secret).Error
+	err := s.db.WithContext(ctx).Where("username = ? and name= ?", username, name).First(&secret).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.WithCode(code.ErrSecretNotFound, err.Error())
 		}
 
-		return nil, errors.WithCode(code.ErrDatabase, err.Error())
+		return nil, errors.WrapC(err, code.ErrDatabase, err.Error())
 	}
 
 	return secret, nil
 }
 
+// GetVersioned return an secret by the secret identifier, with its resource version.
+func (s *secrets) GetVersioned(
+	ctx context.Context,
+	username, name string,
+	opts metav1.GetOptions,
+) (*modelv1.VersionedSecret, error) {
+	secret := &modelv1.VersionedSecret{}
+	err := s.db.WithContext(ctx).Where("username = ? and name= ?", username, name).First(&secret).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithCode(code.ErrSecretNotFound, err.Error())
+		}
+
+		return nil, errors.WrapC(err, code.ErrDatabase, err.Error())
+	}
+
+	return secret, nil
+}
+
+// UpdateVersioned updates the secret if its resource version is still the stored one.
+func (s *secrets) UpdateVersioned(
+	ctx context.Context,
+	secret *modelv1.VersionedSecret,
+	opts metav1.UpdateOptions,
+) error {
+	return updateVersioned(s.db, secret, &secret.ResourceVersion)
+}
+
 // List return all secrets.
 func (s *secrets) List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.SecretList, error) {
 	ret := &v1.SecretList{}
@@ -90,10 +120,11 @@ func (s *secrets) List(ctx context.Context, username string, opts metav1.ListOpt
 	selector, _ := fields.ParseSelector(opts.FieldSelector)
 	name, _ := selector.RequiresExactMatch("name")
 
-	d := s.db.Where(" name like ?", "%"+name+"%").
+	d := s.db.WithContext(ctx).Where(" name like ?", "%"+name+"%").
 		Offset(ol.Offset).
 		Limit(ol.Limit).
 		Order("id desc").
+		Scopes(keyset(ctx, store.CursorSecrets, sortByID)).
 		Find(&ret.Items).
 		Offset(-1).
 		Limit(-1).
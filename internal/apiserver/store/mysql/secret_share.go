@@ -0,0 +1,54 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
+)
+
+type secretShares struct {
+	db *gorm.DB
+}
+
+func newSecretShares(ds *datastore) *secretShares {
+	return &secretShares{ds.db}
+}
+
+// Create creates a new secret share.
+func (s *secretShares) Create(ctx context.Context, share *v1.SecretShare, opts metav1.CreateOptions) error {
+	return translateError(s.db.Create(&share).Error)
+}
+
+// List return the secret shares granted to the target user.
+func (s *secretShares) List(
+	ctx context.Context,
+	targetUsername string,
+	opts metav1.ListOptions,
+) (*v1.SecretShareList, error) {
+	ret := &v1.SecretShareList{}
+	ol := gormutil.Unpointer(opts.Offset, opts.Limit)
+
+	db := s.db.WithContext(ctx)
+	if targetUsername != "" {
+		db = db.Where("? = ?", clause.Column{Name: "targetUsername"}, targetUsername)
+	}
+
+	d := db.Offset(ol.Offset).
+		Limit(ol.Limit).
+		Order("id desc").
+		Find(&ret.Items).
+		Offset(-1).
+		Limit(-1).
+		Count(&ret.TotalCount)
+
+	return ret, d.Error
+}
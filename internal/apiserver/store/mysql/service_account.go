@@ -0,0 +1,76 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+	"gorm.io/gorm"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
+)
+
+type serviceAccounts struct {
+	db *gorm.DB
+}
+
+func newServiceAccounts(ds *datastore) *serviceAccounts {
+	return &serviceAccounts{ds.db}
+}
+
+// Create creates a new service account.
+func (s *serviceAccounts) Create(ctx context.Context, account *v1.ServiceAccount, opts metav1.CreateOptions) error {
+	return translateError(s.db.Create(&account).Error)
+}
+
+// Delete deletes the service account by the service id.
+func (s *serviceAccounts) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	db := s.db
+	if opts.Unscoped {
+		db = db.Unscoped()
+	}
+
+	err := db.Where("name = ?", name).Delete(&v1.ServiceAccount{}).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.WrapC(err, code.ErrDatabase, err.Error())
+	}
+
+	return nil
+}
+
+// Get return service account by the service id.
+func (s *serviceAccounts) Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.ServiceAccount, error) {
+	account := &v1.ServiceAccount{}
+	err := s.db.Where("name = ?", name).First(&account).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithCode(code.ErrServiceAccountNotFound, err.Error())
+		}
+
+		return nil, errors.WrapC(err, code.ErrDatabase, err.Error())
+	}
+
+	return account, nil
+}
+
+// List return all service accounts.
+func (s *serviceAccounts) List(ctx context.Context, opts metav1.ListOptions) (*v1.ServiceAccountList, error) {
+	ret := &v1.ServiceAccountList{}
+	ol := gormutil.Unpointer(opts.Offset, opts.Limit)
+
+	d := s.db.Offset(ol.Offset).
+		Limit(ol.Limit).
+		Order("id desc").
+		Find(&ret.Items).
+		Offset(-1).
+		Limit(-1).
+		Count(&ret.TotalCount)
+
+	return ret, d.Error
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+)
+
+const (
+	softDeleteName = "soft_delete:filter"
+	// softDeleteFiltered marks the statements filtered already, e.g. the count following the find of a list.
+	softDeleteFiltered = "soft_delete:filtered"
+
+	userTable       = "user"
+	deletedAtColumn = "deletedAt"
+)
+
+// ownerColumns are the columns of the users owning the rows of the tables, the rows of the soft deleted
+// users are excluded with them.
+var ownerColumns = map[string][]string{
+	"policy":        {"username"},
+	"secret":        {"username"},
+	"secret_shares": {"username", "targetUsername"},
+	"policy_groups": {"username"},
+	"user_sessions": {"username"},
+}
+
+// softDeletePlugin is a gorm plugin which excludes the soft deleted users, whose deletedAt is set, from
+// the queries of the stores, along with the rows of the tables they own. The queries of the Unscoped
+// statements and of the contexts of store.WithDeleted include them.
+//
+// The users are soft deleted by the user store, the gorm soft delete is not used as the users are the
+// models of the api package.
+type softDeletePlugin struct{}
+
+var _ gorm.Plugin = (*softDeletePlugin)(nil)
+
+// Name returns the name of the soft delete plugin.
+func (p *softDeletePlugin) Name() string {
+	return "softDeletePlugin"
+}
+
+// Initialize registers the callbacks filtering the queries.
+func (p *softDeletePlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+
+	if err := callback.Query().Before("gorm:query").Register(softDeleteName, p.Filter); err != nil {
+		return err
+	}
+
+	return callback.Row().Before("gorm:row").Register(softDeleteName, p.Filter)
+}
+
+// Filter adds the conditions excluding the soft deleted users, or the rows they own, to the statement.
+func (p *softDeletePlugin) Filter(db *gorm.DB) {
+	stmt := db.Statement
+	if stmt.Unscoped || store.IsWithDeleted(stmt.Context) {
+		return
+	}
+
+	if _, ok := stmt.Clauses[softDeleteFiltered]; ok {
+		return
+	}
+
+	var exprs []clause.Expression
+
+	if stmt.Table == userTable {
+		exprs = append(exprs, clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: deletedAtColumn},
+			Value:  nil,
+		})
+	}
+
+	for _, column := range ownerColumns[stmt.Table] {
+		exprs = append(exprs, clause.Expr{
+			SQL: "? NOT IN (SELECT ? FROM ? WHERE ? IS NOT NULL)",
+			Vars: []interface{}{
+				clause.Column{Table: clause.CurrentTable, Name: column},
+				clause.Column{Name: "name"},
+				clause.Table{Name: userTable},
+				clause.Column{Name: deletedAtColumn},
+			},
+		})
+	}
+
+	if len(exprs) == 0 {
+		return
+	}
+
+	// a single or condition is merged with the conditions added, the conditions of the statement are
+	// grouped first, like the gorm soft delete does.
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 1 {
+			for _, expr := range where.Exprs {
+				if orCond, ok := expr.(clause.OrConditions); ok && len(orCond.Exprs) == 1 {
+					where.Exprs = []clause.Expression{clause.And(where.Exprs...)}
+					c.Expression = where
+					stmt.Clauses["WHERE"] = c
+
+					break
+				}
+			}
+		}
+	}
+
+	stmt.AddClause(clause.Where{Exprs: exprs})
+	stmt.Clauses[softDeleteFiltered] = clause.Clause{}
+}
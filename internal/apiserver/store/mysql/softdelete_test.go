@@ -0,0 +1,159 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"gorm.io/gorm"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+)
+
+func TestSoftDeletePlugin(t *testing.T) {
+	const notDeleted = "`policy`.`username` NOT IN \\(SELECT `name` FROM `user` WHERE `deletedAt` IS NOT NULL\\)"
+
+	// the shadow columns of the users and policies are unmarshaled after the query, they must be json.
+	userRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "extendShadow"}).AddRow(1, "{}")
+	}
+	policyRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "extendShadow", "policyShadow"}).AddRow(1, "{}", "{}")
+	}
+	idRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id"}).AddRow(1)
+	}
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		query string
+		rows  func() *sqlmock.Rows
+		run   func(db *gorm.DB) error
+	}{
+		{
+			name:  "user get",
+			ctx:   context.Background(),
+			query: "SELECT \\* FROM `user` WHERE name = \\? AND `user`.`deletedAt` IS NULL ORDER BY `user`.`id` LIMIT 1",
+			rows:  userRows,
+			run: func(db *gorm.DB) error {
+				return db.Where("name = ?", "colin").First(&v1.User{}).Error
+			},
+		},
+		{
+			name:  "user get with deleted",
+			ctx:   store.WithDeleted(context.Background()),
+			query: "SELECT \\* FROM `user` WHERE name = \\? ORDER BY `user`.`id` LIMIT 1",
+			rows:  userRows,
+			run: func(db *gorm.DB) error {
+				return db.Where("name = ?", "colin").First(&v1.User{}).Error
+			},
+		},
+		{
+			name:  "unscoped user get",
+			ctx:   context.Background(),
+			query: "SELECT \\* FROM `user` WHERE name = \\? ORDER BY `user`.`id` LIMIT 1",
+			rows:  userRows,
+			run: func(db *gorm.DB) error {
+				return db.Unscoped().Where("name = ?", "colin").First(&v1.User{}).Error
+			},
+		},
+		{
+			name:  "user or conditions",
+			ctx:   context.Background(),
+			query: "SELECT \\* FROM `user` WHERE \\(name = \\? OR name = \\?\\) AND `user`.`deletedAt` IS NULL",
+			rows:  userRows,
+			run: func(db *gorm.DB) error {
+				var users []*v1.User
+
+				return db.Where("name = ?", "colin").Or("name = ?", "alice").Find(&users).Error
+			},
+		},
+		{
+			name:  "policy list",
+			ctx:   context.Background(),
+			query: "SELECT \\* FROM `policy` WHERE username = \\? AND " + notDeleted,
+			rows:  policyRows,
+			run: func(db *gorm.DB) error {
+				var policies []*v1.Policy
+
+				return db.Where("username = ?", "colin").Find(&policies).Error
+			},
+		},
+		{
+			name:  "policy count",
+			ctx:   context.Background(),
+			query: "SELECT count\\(\\*\\) FROM `policy` WHERE " + notDeleted,
+			rows:  idRows,
+			run: func(db *gorm.DB) error {
+				var count int64
+
+				return db.Model(&v1.Policy{}).Count(&count).Error
+			},
+		},
+		{
+			name:  "not owned",
+			ctx:   context.Background(),
+			query: "SELECT \\* FROM `policy_audit`$",
+			rows:  idRows,
+			run: func(db *gorm.DB) error {
+				var audits []map[string]interface{}
+
+				return db.Table("policy_audit").Find(&audits).Error
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds, mock := newMockDatastore(t)
+
+			if err := ds.db.Use(&softDeletePlugin{}); err != nil {
+				t.Fatalf("Use() error = %v", err)
+			}
+
+			mock.ExpectQuery(tt.query).WillReturnRows(tt.rows())
+
+			if err := tt.run(ds.db.WithContext(tt.ctx)); err != nil {
+				t.Fatalf("query error = %v", err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSoftDeletePlugin_ListCount(t *testing.T) {
+	ds, mock := newMockDatastore(t)
+
+	if err := ds.db.Use(&softDeletePlugin{}); err != nil {
+		t.Fatalf("Use() error = %v", err)
+	}
+
+	// the count reuses the statement of the list, it is filtered once.
+	where := "WHERE \\(name like \\? and status = 1\\) AND `user`.`deletedAt` IS NULL"
+	mock.ExpectQuery("SELECT \\* FROM `user` " + where + " ORDER BY id desc").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "extendShadow"}).AddRow(1, "colin", "{}"))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `user` " + where + "$").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	users, err := newUsers(ds).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	if users.TotalCount != 1 || len(users.Items) != 1 {
+		t.Errorf("List() = %d users %v, want colin", users.TotalCount, users.Items)
+	}
+}
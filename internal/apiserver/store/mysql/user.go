@@ -6,13 +6,16 @@ package mysql
 
 import (
 	"context"
+	"time"
 
 	v1 "github.com/marmotedu/api/apiserver/v1"
 	"github.com/marmotedu/component-base/pkg/fields"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 	"github.com/marmotedu/errors"
 	gorm "gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/pkg/code"
 	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
 )
@@ -25,9 +28,54 @@ func newUsers(ds *datastore) *users {
 	return &users{ds.db}
 }
 
-// Create creates a new user account.
+// Create creates a new user account. A soft deleted user of the same name is deleted for good first, along
+// with its resources, in the same transaction.
 func (u *users) Create(ctx context.Context, user *v1.User, opts metav1.CreateOptions) error {
-	return u.db.Create(&user).Error
+	return transaction(ctx, u.db, func(tx *datastore) error {
+		if err := purgeDeleted(tx.db, []string{user.Name}); err != nil {
+			return err
+		}
+
+		return translateError(tx.db.Create(&user).Error)
+	})
+}
+
+// CreateBatch creates the users by multi-row inserts in a transaction, see createBatch. The soft deleted
+// users of the same names are deleted for good first, as by Create.
+func (u *users) CreateBatch(ctx context.Context, batch []*v1.User, opts metav1.CreateOptions) (map[int]error, error) {
+	var failed map[int]error
+	err := transaction(ctx, u.db, func(tx *datastore) error {
+		for _, user := range batch {
+			if err := user.BeforeCreate(tx.db); err != nil {
+				return err
+			}
+
+			user.InstanceID = batchInstanceID("user-")
+		}
+
+		err := chunks(tx.db, len(batch), func(start, end int) error {
+			names := make([]string, 0, end-start)
+			for _, user := range batch[start:end] {
+				names = append(names, user.Name)
+			}
+
+			return purgeDeleted(tx.db, names)
+		})
+		if err != nil {
+			return err
+		}
+
+		failed, err = createBatch(tx.db, len(batch), func(start, end int) interface{} {
+			return batch[start:end]
+		})
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return failed, nil
 }
 
 // Update updates an user account information.
@@ -35,51 +83,81 @@ func (u *users) Update(ctx context.Context, user *v1.User, opts metav1.UpdateOpt
 	return u.db.Save(user).Error
 }
 
-// Delete deletes the user by the user identifier.
+// Delete soft deletes the user by the user identifier. It deletes the user for good if opts.Unscoped is
+// set, and its policies in the same transaction.
 func (u *users) Delete(ctx context.Context, username string, opts metav1.DeleteOptions) error {
-	// delete related policy first
-	pol := newPolicies(&datastore{u.db})
-	if err := pol.DeleteByUser(ctx, username, opts); err != nil {
-		return err
+	if !opts.Unscoped {
+		return softDelete(u.db.WithContext(ctx), []string{username})
 	}
 
-	if opts.Unscoped {
-		u.db = u.db.Unscoped()
-	}
+	return transaction(ctx, u.db, func(tx *datastore) error {
+		// delete related policy first
+		if err := newPolicies(tx).DeleteByUser(ctx, username, opts); err != nil {
+			return err
+		}
 
-	err := u.db.Where("name = ?", username).Delete(&v1.User{}).Error
-	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return errors.WithCode(code.ErrDatabase, err.Error())
-	}
+		err := tx.db.Unscoped().Where("name = ?", username).Delete(&v1.User{}).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.WrapC(err, code.ErrDatabase, err.Error())
+		}
 
-	return nil
+		return nil
+	})
 }
 
-// DeleteCollection batch deletes the users.
+// DeleteCollection batch soft deletes the users. It deletes the users for good if opts.Unscoped is set,
+// and their policies in the same transaction.
 func (u *users) DeleteCollection(ctx context.Context, usernames []string, opts metav1.DeleteOptions) error {
-	// delete related policy first
-	pol := newPolicies(&datastore{u.db})
-	if err := pol.DeleteCollectionByUser(ctx, usernames, opts); err != nil {
-		return err
+	if !opts.Unscoped {
+		return softDelete(u.db.WithContext(ctx), usernames)
+	}
+
+	return transaction(ctx, u.db, func(tx *datastore) error {
+		// delete related policy first
+		if err := newPolicies(tx).DeleteCollectionByUser(ctx, usernames, opts); err != nil {
+			return err
+		}
+
+		return tx.db.Unscoped().Where("name in (?)", usernames).Delete(&v1.User{}).Error
+	})
+}
+
+// softDelete sets the deletedAt of the live users of usernames, which excludes them from the queries, see
+// softDeletePlugin. Their resources are kept, for the users to be deleted for good later.
+func softDelete(db *gorm.DB, usernames []string) error {
+	err := db.Model(&v1.User{}).
+		Where("name in (?) and ? is null", usernames, clause.Column{Name: deletedAtColumn}).
+		Update(deletedAtColumn, time.Now()).Error
+	if err != nil {
+		return errors.WrapC(err, code.ErrDatabase, err.Error())
 	}
 
-	if opts.Unscoped {
-		u.db = u.db.Unscoped()
+	return nil
+}
+
+// purgeDeleted deletes for good the soft deleted users of usernames, their resources are deleted with them
+// by the trigger of the user table.
+func purgeDeleted(db *gorm.DB, usernames []string) error {
+	err := db.Unscoped().
+		Where("name in (?) and ? is not null", usernames, clause.Column{Name: deletedAtColumn}).
+		Delete(&v1.User{}).Error
+	if err != nil {
+		return errors.WrapC(err, code.ErrDatabase, err.Error())
 	}
 
-	return u.db.Where("name in (?)", usernames).Delete(&v1.User{}).Error
+	return nil
 }
 
 // Get return an user by the user identifier.
 func (u *users) Get(ctx context.Context, username string, opts metav1.GetOptions) (*v1.User, error) {
 	user := &v1.User{}
-	err := u.db.Where("name = ? and status = 1", username).First(&user).Error
+	err := u.db.WithContext(ctx).Where("name = ? and status = 1", username).First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.WithCode(code.ErrUserNotFound, err.Error())
 		}
 
-		return nil, errors.WithCode(code.ErrDatabase, err.Error())
+		return nil, errors.WrapC(err, code.ErrDatabase, err.Error())
 	}
 
 	return user, nil
@@ -92,10 +170,57 @@ func (u *users) List(ctx context.Context, opts metav1.ListOptions) (*v1.UserList
 
 	selector, _ := fields.ParseSelector(opts.FieldSelector)
 	username, _ := selector.RequiresExactMatch("name")
-	d := u.db.Where("name like ? and status = 1", "%"+username+"%").
+	d := u.db.WithContext(ctx).Where("name like ? and status = 1", "%"+username+"%").
+		Offset(ol.Offset).
+		Limit(ol.Limit).
+		Order("id desc").
+		Scopes(keyset(ctx, store.CursorUsers, sortByID)).
+		Find(&ret.Items).
+		Offset(-1).
+		Limit(-1).
+		Count(&ret.TotalCount)
+
+	return ret, d.Error
+}
+
+// ListInactive return the users not logged in since lastLoginBefore.
+func (u *users) ListInactive(
+	ctx context.Context,
+	lastLoginBefore time.Time,
+	opts metav1.ListOptions,
+) (*v1.UserList, error) {
+	ret := &v1.UserList{}
+	ol := gormutil.Unpointer(opts.Offset, opts.Limit)
+
+	// the camel case columns are quoted by the dialect, for postgres not to fold them to lower case.
+	loginedAt, createdAt := clause.Column{Name: "loginedAt"}, clause.Column{Name: "createdAt"}
+	d := u.db.WithContext(ctx).Where("status = 1").
+		Where("? < ? or (? is null and ? < ?)", loginedAt, lastLoginBefore, loginedAt, createdAt, lastLoginBefore).
 		Offset(ol.Offset).
 		Limit(ol.Limit).
 		Order("id desc").
+		Scopes(keyset(ctx, store.CursorUsers, sortByID)).
+		Find(&ret.Items).
+		Offset(-1).
+		Limit(-1).
+		Count(&ret.TotalCount)
+
+	return ret, d.Error
+}
+
+// Search returns the enabled users found by the search, ordered by relevance then id.
+func (u *users) Search(ctx context.Context, searchOpts store.SearchOptions, opts metav1.ListOptions) (*v1.UserList, error) {
+	columns, err := searchOpts.SearchFields(store.UserSearchFields...)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &v1.UserList{}
+	ol := gormutil.Unpointer(opts.Offset, opts.Limit)
+
+	d := search(u.db.WithContext(ctx).Where("status = 1"), columns, searchOpts).
+		Offset(ol.Offset).
+		Limit(ol.Limit).
 		Find(&ret.Items).
 		Offset(-1).
 		Limit(-1).
@@ -119,7 +244,7 @@ func (u *users) ListOptional(ctx context.Context, opts metav1.ListOptions) (*v1.
 		where.Name = username
 	}
 
-	d := u.db.Where(where).
+	d := u.db.WithContext(ctx).Where(where).
 		Not(whereNot).
 		Offset(ol.Offset).
 		Limit(ol.Limit).
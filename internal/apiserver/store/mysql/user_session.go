@@ -0,0 +1,50 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+	"time"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"gorm.io/gorm"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
+)
+
+type userSessions struct {
+	db *gorm.DB
+}
+
+func newUserSessions(ds *datastore) *userSessions {
+	return &userSessions{ds.db}
+}
+
+// Create creates a new user session.
+func (s *userSessions) Create(ctx context.Context, session *v1.UserSession, opts metav1.CreateOptions) error {
+	return translateError(s.db.WithContext(ctx).Create(session).Error)
+}
+
+// List return the sessions of the user whose token has not expired.
+func (s *userSessions) List(
+	ctx context.Context,
+	username string,
+	opts metav1.ListOptions,
+) (*v1.UserSessionList, error) {
+	ret := &v1.UserSessionList{}
+	ol := gormutil.Unpointer(opts.Offset, opts.Limit)
+
+	d := s.db.Where("username = ? and expires_at > ?", username, time.Now()).
+		Offset(ol.Offset).
+		Limit(ol.Limit).
+		Order("id desc").
+		Find(&ret.Items).
+		Offset(-1).
+		Limit(-1).
+		Count(&ret.TotalCount)
+
+	return ret, d.Error
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AlekSi/pointer"
+	"github.com/DATA-DOG/go-sqlmock"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+)
+
+func TestUsers_ListInactive(t *testing.T) {
+	ds, mock := newMockDatastore(t)
+	before := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	where := "WHERE status = 1 AND \\(`loginedAt` < \\? or \\(`loginedAt` is null and `createdAt` < \\?\\)\\)"
+	mock.ExpectQuery("SELECT \\* FROM `user` "+where+" ORDER BY id desc LIMIT 10 OFFSET 20").
+		WithArgs(before, before).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "extendShadow", "loginedAt"}).
+			AddRow(3, "colin", "{}", before.AddDate(0, -4, 0)))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `user` "+where).
+		WithArgs(before, before).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(21))
+
+	users, err := newUsers(ds).ListInactive(context.TODO(), before, metav1.ListOptions{
+		Offset: pointer.ToInt64(20),
+		Limit:  pointer.ToInt64(10),
+	})
+	if err != nil {
+		t.Fatalf("ListInactive() error = %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	if users.TotalCount != 21 || len(users.Items) != 1 || users.Items[0].Name != "colin" {
+		t.Errorf("ListInactive() = %d users %v, want colin of 21 users", users.TotalCount, users.Items)
+	}
+}
+
+func TestUsers_Delete_Unscoped(t *testing.T) {
+	errDelete := errors.New("delete failed")
+
+	tests := []struct {
+		name string
+		// policiesErr and userErr fail the deletes of the policies and of the user.
+		policiesErr error
+		userErr     error
+		wantErr     bool
+	}{
+		{name: "committed"},
+		{name: "policies delete failed", policiesErr: errDelete, wantErr: true},
+		{name: "user delete failed", userErr: errDelete, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds, mock := newMockDatastore(t)
+
+			mock.ExpectBegin()
+			policies := mock.ExpectExec("DELETE FROM `policy` WHERE username = \\?").WithArgs("colin")
+			if tt.policiesErr != nil {
+				policies.WillReturnError(tt.policiesErr)
+			} else {
+				policies.WillReturnResult(sqlmock.NewResult(0, 2))
+
+				user := mock.ExpectExec("DELETE FROM `user` WHERE name = \\?").WithArgs("colin")
+				if tt.userErr != nil {
+					user.WillReturnError(tt.userErr)
+				} else {
+					user.WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+			}
+			if tt.wantErr {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectCommit()
+			}
+
+			err := newUsers(ds).Delete(context.TODO(), "colin", metav1.DeleteOptions{Unscoped: true})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Delete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestUsers_Delete(t *testing.T) {
+	ds, mock := newMockDatastore(t)
+
+	// the user is only marked deleted, its policies are kept.
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `user` SET `deletedAt`=\\?,`updatedAt`=\\? WHERE name in \\(\\?\\) and `deletedAt` is null").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "colin").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := newUsers(ds).Delete(context.TODO(), "colin", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"github.com/marmotedu/errors"
+	"gorm.io/gorm"
+
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+// updateVersioned updates all the fields of the versioned model, found by its primary key, if its stored
+// resource version is still *version. The stored version is incremented by the triggers of the
+// migrations, *version is incremented likewise.
+func updateVersioned(db *gorm.DB, model interface{}, version *uint64) error {
+	result := db.Model(model).Where("resourceVersion = ?", *version).Select("*").Updates(model)
+	if result.Error != nil {
+		return errors.WrapC(result.Error, code.ErrDatabase, result.Error.Error())
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.WithCode(code.ErrResourceVersionConflict, "resource version %d is outdated", *version)
+	}
+
+	*version++
+
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+func TestSecrets_UpdateVersioned(t *testing.T) {
+	tests := []struct {
+		name string
+		// rowsAffected is the number of rows updated, or the update fails if err is set.
+		rowsAffected int64
+		err          error
+		wantCode     int
+		wantVersion  uint64
+	}{
+		{name: "updated", rowsAffected: 1, wantVersion: 4},
+		{name: "changed since read", wantCode: code.ErrResourceVersionConflict, wantVersion: 3},
+		{name: "update failed", err: errors.New("update failed"), wantCode: code.ErrDatabase, wantVersion: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds, mock := newMockDatastore(t)
+
+			mock.ExpectBegin()
+			update := mock.ExpectExec("UPDATE `secret` SET (.+) WHERE resourceVersion = \\? AND `id` = \\?")
+			if tt.err != nil {
+				update.WillReturnError(tt.err)
+				mock.ExpectRollback()
+			} else {
+				update.WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+				mock.ExpectCommit()
+			}
+
+			secret := &modelv1.VersionedSecret{
+				Secret: v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{ID: 7, Name: "secret"},
+					Username:   "colin",
+				},
+				ResourceVersion: 3,
+			}
+
+			err := newSecrets(ds).UpdateVersioned(context.TODO(), secret, metav1.UpdateOptions{})
+			if tt.wantCode == 0 && err != nil {
+				t.Fatalf("UpdateVersioned() error = %v", err)
+			}
+			if tt.wantCode != 0 && !errors.IsCode(err, tt.wantCode) {
+				t.Fatalf("UpdateVersioned() error = %v, wantCode %d", err, tt.wantCode)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+
+			if secret.ResourceVersion != tt.wantVersion {
+				t.Errorf("ResourceVersion = %d, want %d", secret.ResourceVersion, tt.wantVersion)
+			}
+		})
+	}
+}
@@ -9,14 +9,26 @@ import (
 
 	v1 "github.com/marmotedu/api/apiserver/v1"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
 )
 
 // PolicyStore defines the policy storage interface.
 type PolicyStore interface {
 	Create(ctx context.Context, policy *v1.Policy, opts metav1.CreateOptions) error
+	// CreateBatch creates the policies but the duplicates, returned by their index, see UserStore.CreateBatch.
+	CreateBatch(ctx context.Context, policies []*v1.Policy, opts metav1.CreateOptions) (map[int]error, error)
 	Update(ctx context.Context, policy *v1.Policy, opts metav1.UpdateOptions) error
 	Delete(ctx context.Context, username string, name string, opts metav1.DeleteOptions) error
 	DeleteCollection(ctx context.Context, username string, names []string, opts metav1.DeleteOptions) error
 	Get(ctx context.Context, username string, name string, opts metav1.GetOptions) (*v1.Policy, error)
 	List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.PolicyList, error)
+	// Search returns the policies of the user found by the search in their name and username, ordered by
+	// relevance then id, see SearchOptions. The policies of all the users are searched if username is empty.
+	Search(ctx context.Context, username string, search SearchOptions, opts metav1.ListOptions) (*v1.PolicyList, error)
+	// GetVersioned returns the policy with its resource version, incremented by every write of the policy.
+	GetVersioned(ctx context.Context, username, name string, opts metav1.GetOptions) (*modelv1.VersionedPolicy, error)
+	// UpdateVersioned updates the policy if its resource version did not change since it was read, and
+	// increments the version. It fails with code.ErrResourceVersionConflict otherwise.
+	UpdateVersioned(ctx context.Context, policy *modelv1.VersionedPolicy, opts metav1.UpdateOptions) error
 }
@@ -0,0 +1,26 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"context"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+)
+
+// PolicyGroupStore defines the policy_groups storage interface.
+type PolicyGroupStore interface {
+	Create(ctx context.Context, group *v1.PolicyGroup, opts metav1.CreateOptions) error
+	Update(ctx context.Context, group *v1.PolicyGroup, opts metav1.UpdateOptions) error
+	// Delete deletes the policy group along with its member policies, atomically.
+	Delete(ctx context.Context, username string, name string, opts metav1.DeleteOptions) error
+	Get(ctx context.Context, username string, name string, opts metav1.GetOptions) (*v1.PolicyGroup, error)
+	// List returns the policy groups of username, or all policy groups if username is empty.
+	List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.PolicyGroupList, error)
+	// Apply marks the policy group as applied once all its member policies exist, atomically.
+	Apply(ctx context.Context, username string, name string) error
+}
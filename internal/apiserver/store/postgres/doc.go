@@ -0,0 +1,7 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package postgres implements `github.com/marmotedu/iam/internal/apiserver/store.Store` interface
+// with a postgres database, using the stores of the mysql package over the gorm postgres driver.
+package postgres
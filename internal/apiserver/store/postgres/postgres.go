@@ -0,0 +1,77 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/apiserver/store/mysql"
+	"github.com/marmotedu/iam/internal/pkg/logger"
+	genericoptions "github.com/marmotedu/iam/internal/pkg/options"
+	"github.com/marmotedu/iam/pkg/db"
+)
+
+var (
+	postgresFactory store.Factory
+	once            sync.Once
+)
+
+// GetPostgresFactoryOr create postgres factory with the given config.
+func GetPostgresFactoryOr(opts *genericoptions.PostgresOptions) (store.Factory, error) {
+	if opts == nil && postgresFactory == nil {
+		return nil, fmt.Errorf("failed to get postgres store fatory")
+	}
+
+	var err error
+	once.Do(func() {
+		options := &db.PostgresOptions{
+			Host:                  opts.Host,
+			Username:              opts.Username,
+			Password:              opts.Password,
+			Database:              opts.Database,
+			SSLMode:               opts.SSLMode,
+			MaxIdleConnections:    opts.MaxIdleConnections,
+			MaxOpenConnections:    opts.MaxOpenConnections,
+			MaxConnectionLifeTime: opts.MaxConnectionLifeTime,
+			LogLevel:              opts.LogLevel,
+			Logger:                logger.New(opts.LogLevel),
+		}
+		dbIns, dbErr := db.NewPostgres(options)
+		if dbErr != nil {
+			err = dbErr
+
+			return
+		}
+
+		postgresFactory, err = mysql.NewFactory(dbIns, mysql.FactoryOptions{
+			SlowQueryThreshold:      opts.SlowQueryThreshold,
+			QueryMetrics:            opts.QueryMetrics,
+			CircuitBreakerThreshold: opts.CircuitBreakerThreshold,
+			CircuitBreakerTimeout:   opts.CircuitBreakerTimeout,
+			MaxRetries:              opts.MaxRetries,
+			RetryBackoff:            opts.RetryBackoff,
+			PoolMetrics:             true,
+		})
+	})
+
+	if postgresFactory == nil || err != nil {
+		return nil, fmt.Errorf("failed to get postgres store fatory, postgresFactory: %+v, error: %w", postgresFactory, err)
+	}
+
+	return postgresFactory, nil
+}
+
+// Ping checks the connection to the database of the postgres factory.
+func Ping(ctx context.Context) error {
+	factory, err := GetPostgresFactoryOr(nil)
+	if err != nil {
+		return err
+	}
+
+	return mysql.PingFactory(ctx, factory)
+}
@@ -0,0 +1,214 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"sort"
+	"strings"
+
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
+)
+
+// SearchOptions is the search of the stores supporting it, e.g. UserStore.Search, in addition to
+// metav1.ListOptions which cannot be extended.
+type SearchOptions struct {
+	// Fields are the names of the fields searched, all the searchable fields of the resource if empty.
+	Fields []string
+	// Query is searched in the fields as a substring, case insensitively. Its wildcards of the sql LIKE,
+	// % and _, are matched literally. A query empty, or of spaces only, matches all the resources.
+	Query string
+}
+
+// The searchable fields of the users and the policies, they are the names of their columns in the sql
+// stores.
+var (
+	UserSearchFields   = []string{"name", "nickname", "email"}
+	PolicySearchFields = []string{"name", "username"}
+)
+
+// The relevances of the resources found by a search, the resources are ordered by relevance then by
+// descending id. The mysql store also ranks the resources of the same relevance by their FULLTEXT
+// indexes.
+const (
+	RelevanceNone = iota
+	// RelevanceContains is the relevance of a resource with a field containing the query, and of all the
+	// resources for an empty query.
+	RelevanceContains
+	// RelevancePrefix is the relevance of a resource with a field starting with the query.
+	RelevancePrefix
+	// RelevanceExact is the relevance of a resource with a field equal to the query.
+	RelevanceExact
+)
+
+// SearchFields returns the fields searched among the searchable ones, all of them if o.Fields is empty.
+// It fails with code.ErrValidation if a field is not searchable.
+func (o SearchOptions) SearchFields(searchable ...string) ([]string, error) {
+	if len(o.Fields) == 0 {
+		return searchable, nil
+	}
+
+	for _, field := range o.Fields {
+		if !contains(searchable, field) {
+			return nil, errors.WithCode(code.ErrValidation, "the field %q is not searchable, the searchable fields are %s",
+				field, strings.Join(searchable, ", "))
+		}
+	}
+
+	return o.Fields, nil
+}
+
+// Normalized returns the query searched, case folded and without its leading and trailing spaces.
+func (o SearchOptions) Normalized() string {
+	return strings.ToLower(strings.TrimSpace(o.Query))
+}
+
+// Relevance returns the relevance of a resource whose searched fields have the values, for the stores
+// searching in memory. It is RelevanceNone if the resource is not found.
+func (o SearchOptions) Relevance(values ...string) int {
+	query := o.Normalized()
+	if query == "" {
+		return RelevanceContains
+	}
+
+	relevance := RelevanceNone
+
+	for _, value := range values {
+		value = strings.ToLower(value)
+
+		switch {
+		case value == query:
+			return RelevanceExact
+		case strings.HasPrefix(value, query) && relevance < RelevancePrefix:
+			relevance = RelevancePrefix
+		case strings.Contains(value, query) && relevance < RelevanceContains:
+			relevance = RelevanceContains
+		}
+	}
+
+	return relevance
+}
+
+// SearchUsers returns the page of opts of the users found by the search, ordered by relevance then by
+// descending id, for the stores searching in memory.
+func (o SearchOptions) SearchUsers(users []*v1.User, opts metav1.ListOptions) (*v1.UserList, error) {
+	fields, err := o.SearchFields(UserSearchFields...)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := o.rank(fields, len(users), func(i int, field string) (uint64, string) {
+		switch field {
+		case "nickname":
+			return users[i].ID, users[i].Nickname
+		case "email":
+			return users[i].ID, users[i].Email
+		default:
+			return users[i].ID, users[i].Name
+		}
+	})
+
+	ret := &v1.UserList{ListMeta: metav1.ListMeta{TotalCount: int64(len(ranked))}, Items: make([]*v1.User, 0)}
+	for _, i := range page(ranked, opts) {
+		ret.Items = append(ret.Items, users[i])
+	}
+
+	return ret, nil
+}
+
+// SearchPolicies returns the page of opts of the policies found by the search, ordered by relevance then
+// by descending id, for the stores searching in memory.
+func (o SearchOptions) SearchPolicies(policies []*v1.Policy, opts metav1.ListOptions) (*v1.PolicyList, error) {
+	fields, err := o.SearchFields(PolicySearchFields...)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := o.rank(fields, len(policies), func(i int, field string) (uint64, string) {
+		if field == "username" {
+			return policies[i].ID, policies[i].Username
+		}
+
+		return policies[i].ID, policies[i].Name
+	})
+
+	ret := &v1.PolicyList{ListMeta: metav1.ListMeta{TotalCount: int64(len(ranked))}, Items: make([]*v1.Policy, 0)}
+	for _, i := range page(ranked, opts) {
+		ret.Items = append(ret.Items, policies[i])
+	}
+
+	return ret, nil
+}
+
+// rank returns the indexes of the n resources found by the search in the fields, ordered by relevance
+// then by descending id. resource returns the id of the resource of index i and the value of its field.
+func (o SearchOptions) rank(fields []string, n int, resource func(i int, field string) (uint64, string)) []int {
+	type found struct {
+		index, relevance int
+		id               uint64
+	}
+
+	var ranked []found
+
+	for i := 0; i < n; i++ {
+		var id uint64
+
+		values := make([]string, 0, len(fields))
+		for _, field := range fields {
+			var value string
+			id, value = resource(i, field)
+			values = append(values, value)
+		}
+
+		if relevance := o.Relevance(values...); relevance != RelevanceNone {
+			ranked = append(ranked, found{index: i, relevance: relevance, id: id})
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].relevance != ranked[j].relevance {
+			return ranked[i].relevance > ranked[j].relevance
+		}
+
+		return ranked[i].id > ranked[j].id
+	})
+
+	indexes := make([]int, 0, len(ranked))
+	for _, f := range ranked {
+		indexes = append(indexes, f.index)
+	}
+
+	return indexes
+}
+
+// page returns the indexes of the page of opts.
+func page(indexes []int, opts metav1.ListOptions) []int {
+	ol := gormutil.Unpointer(opts.Offset, opts.Limit)
+
+	if ol.Offset >= len(indexes) {
+		return nil
+	}
+
+	indexes = indexes[ol.Offset:]
+	if ol.Limit >= 0 && ol.Limit < len(indexes) {
+		indexes = indexes[:ol.Limit]
+	}
+
+	return indexes
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"strings"
+	"testing"
+
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+func TestSearchOptions_SearchUsers(t *testing.T) {
+	users := []*v1.User{
+		{ObjectMeta: metav1.ObjectMeta{ID: 1, Name: "colin"}, Nickname: "100% sure", Email: "colin@foxmail.com"},
+		{ObjectMeta: metav1.ObjectMeta{ID: 2, Name: "alice"}, Nickname: "100 percent", Email: "alice@foxmail.com"},
+		{ObjectMeta: metav1.ObjectMeta{ID: 3, Name: "a_b"}, Nickname: "a_b", Email: "a_b@foxmail.com"},
+		{ObjectMeta: metav1.ObjectMeta{ID: 4, Name: "axb"}, Nickname: "AxB", Email: "axb@foxmail.com"},
+		{ObjectMeta: metav1.ObjectMeta{ID: 5, Name: "colinx"}, Nickname: "colin", Email: "colinx@foxmail.com"},
+	}
+
+	limit := int64(2)
+
+	tests := []struct {
+		name   string
+		search SearchOptions
+		opts   metav1.ListOptions
+		// want are the names of the users found, in order, among total.
+		want     []string
+		total    int64
+		wantCode int
+	}{
+		{
+			name:   "empty query",
+			search: SearchOptions{Query: " "},
+			want:   []string{"colinx", "axb", "a_b", "alice", "colin"},
+			total:  5,
+		},
+		{
+			name:   "empty query page",
+			search: SearchOptions{},
+			opts:   metav1.ListOptions{Limit: &limit},
+			want:   []string{"colinx", "axb"},
+			total:  5,
+		},
+		{
+			name:   "percent",
+			search: SearchOptions{Fields: []string{"nickname"}, Query: "100%"},
+			want:   []string{"colin"},
+			total:  1,
+		},
+		{
+			name:   "underscore",
+			search: SearchOptions{Fields: []string{"nickname"}, Query: "_"},
+			want:   []string{"a_b"},
+			total:  1,
+		},
+		{
+			name:   "exact in any field",
+			search: SearchOptions{Fields: []string{"name", "nickname"}, Query: "COLIN"},
+			want:   []string{"colinx", "colin"},
+			total:  2,
+		},
+		{
+			name:   "prefix then contains",
+			search: SearchOptions{Fields: []string{"email"}, Query: "a"},
+			want:   []string{"axb", "a_b", "alice", "colinx", "colin"},
+			total:  5,
+		},
+		{
+			name:     "unknown field",
+			search:   SearchOptions{Fields: []string{"password"}},
+			wantCode: code.ErrValidation,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := tt.search.SearchUsers(users, tt.opts)
+			if tt.wantCode != 0 {
+				if !errors.IsCode(err, tt.wantCode) {
+					t.Fatalf("SearchUsers() error = %v, wantCode %d", err, tt.wantCode)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("SearchUsers() error = %v", err)
+			}
+
+			var got []string
+			for _, user := range found.Items {
+				got = append(got, user.Name)
+			}
+
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") || found.TotalCount != tt.total {
+				t.Errorf("SearchUsers() = %d %q, want %d %q", found.TotalCount, got, tt.total, tt.want)
+			}
+		})
+	}
+}
@@ -9,6 +9,8 @@ import (
 
 	v1 "github.com/marmotedu/api/apiserver/v1"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
 )
 
 // SecretStore defines the secret storage interface.
@@ -19,4 +21,9 @@ type SecretStore interface {
 	DeleteCollection(ctx context.Context, username string, secretIDs []string, opts metav1.DeleteOptions) error
 	Get(ctx context.Context, username, secretID string, opts metav1.GetOptions) (*v1.Secret, error)
 	List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.SecretList, error)
+	// GetVersioned returns the secret with its resource version, incremented by every write of the secret.
+	GetVersioned(ctx context.Context, username, secretID string, opts metav1.GetOptions) (*modelv1.VersionedSecret, error)
+	// UpdateVersioned updates the secret if its resource version did not change since it was read, and
+	// increments the version. It fails with code.ErrResourceVersionConflict otherwise.
+	UpdateVersioned(ctx context.Context, secret *modelv1.VersionedSecret, opts metav1.UpdateOptions) error
 }
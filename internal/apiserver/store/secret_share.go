@@ -0,0 +1,20 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"context"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+)
+
+// SecretShareStore defines the secret_shares storage interface.
+type SecretShareStore interface {
+	Create(ctx context.Context, share *v1.SecretShare, opts metav1.CreateOptions) error
+	// List returns the shares granted to targetUsername, or all shares if targetUsername is empty.
+	List(ctx context.Context, targetUsername string, opts metav1.ListOptions) (*v1.SecretShareList, error)
+}
@@ -0,0 +1,23 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package sqlite implements `github.com/marmotedu/iam/internal/apiserver/store.Store` interface
+// with a sqlite database, in a file or in memory, using the stores of the mysql package. It is meant for
+// the tests and the demos, the pending migrations of the migrate package are applied when the database
+// is opened.
+//
+// The behaviors of mysql are kept where it is cheap:
+//   - the names are compared case-insensitively, like the utf8_general_ci collation of mysql, by the
+//     NOCASE collation of their columns.
+//   - the foreign keys are checked, and the triggers of the mysql migrations are created.
+//   - the violations of a unique key are returned as store.ErrDuplicateKey.
+//
+// The known differences are:
+//   - the times are stored and compared as text, in the time zone of the apiserver.
+//   - the row locks of the transactions are ignored, sqlite serializes the writes anyway.
+//   - the database has a single connection, the stores used in the fn of WithTransaction, other than the
+//     ones of its factory, wait for the end of the transaction forever.
+//   - the column types are not enforced, e.g. the length of the varchar columns.
+//   - the sqlite driver requires cgo.
+package sqlite
@@ -0,0 +1,106 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/marmotedu/errors"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/apiserver/store/migrate"
+	"github.com/marmotedu/iam/internal/apiserver/store/mysql"
+	"github.com/marmotedu/iam/internal/pkg/logger"
+	genericoptions "github.com/marmotedu/iam/internal/pkg/options"
+	"github.com/marmotedu/iam/pkg/db"
+)
+
+var (
+	sqliteFactory store.Factory
+	once          sync.Once
+)
+
+// GetSqliteFactoryOr create sqlite factory with the given config.
+func GetSqliteFactoryOr(opts *genericoptions.SqliteOptions) (store.Factory, error) {
+	if opts == nil && sqliteFactory == nil {
+		return nil, fmt.Errorf("failed to get sqlite store fatory")
+	}
+
+	var err error
+	once.Do(func() {
+		sqliteFactory, err = Open(&db.SqliteOptions{
+			Path:     opts.Path,
+			LogLevel: opts.LogLevel,
+			Logger:   logger.New(opts.LogLevel),
+		})
+	})
+
+	if sqliteFactory == nil || err != nil {
+		return nil, fmt.Errorf("failed to get sqlite store fatory, sqliteFactory: %+v, error: %w", sqliteFactory, err)
+	}
+
+	return sqliteFactory, nil
+}
+
+// Open opens the sqlite database, applies its pending migrations, and returns its store. Each store of
+// db.SqliteMemory has its own database, e.g. for the tests.
+func Open(opts *db.SqliteOptions) (store.Factory, error) {
+	dbIns, err := db.NewSqlite(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := migrate.New(dbIns, genericoptions.DatastoreEngineSqlite)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := migrator.Up(context.Background()); err != nil {
+		return nil, errors.Wrap(err, "migrate the sqlite database failed")
+	}
+
+	err = dbIns.Callback().Create().After("gorm:create").Register("sqlite:unique_violation", translateUniqueViolation)
+	if err != nil {
+		return nil, err
+	}
+
+	return mysql.NewFactory(dbIns, mysql.FactoryOptions{})
+}
+
+// Ping checks the connection to the database of the sqlite factory.
+func Ping(ctx context.Context) error {
+	factory, err := GetSqliteFactoryOr(nil)
+	if err != nil {
+		return err
+	}
+
+	return mysql.PingFactory(ctx, factory)
+}
+
+// uniqueViolation is the violation of a unique key by an insert. sqlite has no SQLSTATE, it is given the
+// one of the standard, for the stores to return store.ErrDuplicateKey like for mysql and postgres.
+type uniqueViolation struct {
+	error
+}
+
+func (e *uniqueViolation) SQLState() string { return "23505" }
+
+func (e *uniqueViolation) Unwrap() error { return e.error }
+
+// translateUniqueViolation is a create callback wrapping the violations of a unique key.
+func translateUniqueViolation(tx *gorm.DB) {
+	var sqliteErr sqlite3.Error
+	if !errors.As(tx.Error, &sqliteErr) {
+		return
+	}
+
+	if sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+		tx.Error = &uniqueViolation{tx.Error}
+	}
+}
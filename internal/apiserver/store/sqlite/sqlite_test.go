@@ -0,0 +1,226 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/AlekSi/pointer"
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/apiserver/store/storetest"
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/pkg/db"
+)
+
+func TestFactory_Conformance(t *testing.T) {
+	factory, err := Open(&db.SqliteOptions{Path: db.SqliteMemory})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer factory.Close()
+
+	storetest.TestFactory(t, factory)
+}
+
+func TestFactory_CaseInsensitive(t *testing.T) {
+	ctx := context.TODO()
+
+	factory, err := Open(&db.SqliteOptions{Path: db.SqliteMemory})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer factory.Close()
+
+	user := func(name string) *v1.User {
+		return &v1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     1,
+			Nickname:   name,
+			Password:   "Sqlite@2020",
+			Email:      name + "@iam.test",
+			LoginedAt:  time.Now(),
+		}
+	}
+	if err := factory.Users().Create(ctx, user("colin"), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		wantErr  error
+	}{
+		{name: "same case", username: "colin", wantErr: store.ErrDuplicateKey},
+		{name: "other case", username: "Colin", wantErr: store.ErrDuplicateKey},
+		{name: "other user", username: "colin2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := factory.Users().Create(ctx, user(tt.username), metav1.CreateOptions{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	got, err := factory.Users().Get(ctx, "COLIN", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if got.Name != "colin" {
+		t.Errorf("Get() = %s, want colin", got.Name)
+	}
+
+	if _, err := factory.Users().Get(ctx, "colin3", metav1.GetOptions{}); !errors.IsCode(err, code.ErrUserNotFound) {
+		t.Errorf("Get() of a missing user error = %v, want code %d", err, code.ErrUserNotFound)
+	}
+}
+
+// BenchmarkUsers_ListDeepPage compares the latency of the last page of a large user list read by offset
+// and by keyset, see store.WithCursor.
+func BenchmarkUsers_ListDeepPage(b *testing.B) {
+	const users, limit = 10000, 20
+
+	ctx := context.TODO()
+
+	factory, err := Open(&db.SqliteOptions{Path: db.SqliteMemory})
+	if err != nil {
+		b.Fatalf("Open() error = %v", err)
+	}
+	defer factory.Close()
+
+	err = factory.WithTransaction(ctx, func(tx store.Factory) error {
+		for i := 0; i < users; i++ {
+			name := fmt.Sprintf("user%05d", i)
+			user := &v1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Status:     1,
+				Nickname:   name,
+				Password:   "Sqlite@2020",
+				Email:      name + "@iam.test",
+				LoginedAt:  time.Now(),
+			}
+			if err := tx.Users().Create(ctx, user, metav1.CreateOptions{}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		b.Fatalf("Create() error = %v", err)
+	}
+
+	// the cursor of the last page is the item preceding it.
+	offset := int64(users - limit)
+	previous, err := factory.Users().List(ctx, metav1.ListOptions{
+		Offset: pointer.ToInt64(offset - 1),
+		Limit:  pointer.ToInt64(1),
+	})
+	if err != nil || len(previous.Items) != 1 {
+		b.Fatalf("List() = %v, error = %v", previous, err)
+	}
+
+	cursor := store.Cursor{List: store.CursorUsers, ID: previous.Items[0].ID}
+
+	benchmarks := []struct {
+		name string
+		ctx  context.Context
+		opts metav1.ListOptions
+	}{
+		{name: "offset", ctx: ctx, opts: metav1.ListOptions{Offset: &offset, Limit: pointer.ToInt64(limit)}},
+		{name: "keyset", ctx: store.WithCursor(ctx, cursor), opts: metav1.ListOptions{Limit: pointer.ToInt64(limit)}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				list, err := factory.Users().List(bm.ctx, bm.opts)
+				if err != nil || len(list.Items) != limit {
+					b.Fatalf("List() = %v, error = %v", list, err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkUsers_CreateBatch compares the import of 10k users created one by one, each in its transaction,
+// and by a batch creation, see UserStore.CreateBatch.
+func BenchmarkUsers_CreateBatch(b *testing.B) {
+	const users = 10000
+
+	ctx := context.TODO()
+
+	benchmarks := []struct {
+		name   string
+		create func(factory store.Factory, batch []*v1.User) error
+	}{
+		{
+			name: "one by one",
+			create: func(factory store.Factory, batch []*v1.User) error {
+				for _, user := range batch {
+					if err := factory.Users().Create(ctx, user, metav1.CreateOptions{}); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			name: "batch",
+			create: func(factory store.Factory, batch []*v1.User) error {
+				failed, err := factory.Users().CreateBatch(ctx, batch, metav1.CreateOptions{})
+				if err == nil && len(failed) > 0 {
+					err = fmt.Errorf("%d duplicates", len(failed))
+				}
+
+				return err
+			},
+		},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+
+				factory, err := Open(&db.SqliteOptions{Path: db.SqliteMemory})
+				if err != nil {
+					b.Fatalf("Open() error = %v", err)
+				}
+
+				batch := make([]*v1.User, 0, users)
+				for j := 0; j < users; j++ {
+					name := fmt.Sprintf("user%05d", j)
+					batch = append(batch, &v1.User{
+						ObjectMeta: metav1.ObjectMeta{Name: name},
+						Status:     1,
+						Nickname:   name,
+						Password:   "Sqlite@2020",
+						Email:      name + "@iam.test",
+						LoginedAt:  time.Now(),
+					})
+				}
+
+				b.StartTimer()
+
+				if err := bm.create(factory, batch); err != nil {
+					b.Fatalf("create() error = %v", err)
+				}
+
+				b.StopTimer()
+				factory.Close()
+				b.StartTimer()
+			}
+		})
+	}
+}
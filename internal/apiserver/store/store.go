@@ -4,19 +4,55 @@
 
 package store
 
-//go:generate mockgen -self_package=github.com/marmotedu/iam/internal/apiserver/store -destination mock_store.go -package store github.com/marmotedu/iam/internal/apiserver/store Factory,UserStore,SecretStore,PolicyStore
+import (
+	"context"
+	"errors"
+)
+
+//go:generate mockgen -self_package=github.com/marmotedu/iam/internal/apiserver/store -destination mock_store.go -package store github.com/marmotedu/iam/internal/apiserver/store Factory,UserStore,SecretStore,PolicyStore,SecretShareStore,PolicyGroupStore,UserSessionStore,ServiceAccountStore
 
 var client Factory
 
+// ErrDuplicateKey is the error of the creations violating a unique key, e.g. of a user already existing,
+// whatever the engine of the store. The stores wrap it, test it with errors.Is.
+var ErrDuplicateKey = errors.New("record already exist")
+
 // Factory defines the iam platform storage interface.
 type Factory interface {
 	Users() UserStore
 	Secrets() SecretStore
 	Policies() PolicyStore
 	PolicyAudits() PolicyAuditStore
+	SecretShares() SecretShareStore
+	PolicyGroups() PolicyGroupStore
+	UserSessions() UserSessionStore
+	ServiceAccounts() ServiceAccountStore
+	// WithTransaction calls fn with a factory whose stores share one transaction, committed if fn returns
+	// nil and rolled back otherwise. A WithTransaction nested in fn, on the given factory, runs in a
+	// savepoint of the outer transaction. fn must only use the given factory. The stores may call fn again
+	// once the transaction failed with a transient error, e.g. a deadlock, so fn must have no other effect.
+	WithTransaction(ctx context.Context, fn func(Factory) error) error
 	Close() error
 }
 
+// withReplicaReadsKey is the context key of WithReplicaReads.
+type withReplicaReadsKey struct{}
+
+// WithReplicaReads returns a copy of ctx whose reads may be served by the read replicas of the store, e.g.
+// for the lists and the gets of the api. The replicas may lag behind the primary database, so the reads
+// following a write of the same request must not use it. The stores without replicas ignore it.
+func WithReplicaReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withReplicaReadsKey{}, true)
+}
+
+// IsWithReplicaReads reports whether the reads of ctx may be served by the read replicas, see
+// WithReplicaReads.
+func IsWithReplicaReads(ctx context.Context) bool {
+	withReplicaReads, _ := ctx.Value(withReplicaReadsKey{}).(bool)
+
+	return withReplicaReads
+}
+
 // Client return the store client instance.
 func Client() Factory {
 	return client
@@ -0,0 +1,877 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package storetest implements the conformance tests shared by the store factories of the database
+// engines, e.g. mysql, postgres and sqlite.
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+// TestFactory runs the conformance tests against the stores of factory. The database must be dedicated
+// to the tests, with all the migrations of its engine applied, e.g. by iam-apiserver migrate up, or the
+// ones applied by sqlite.Open. The records created by the tests are deleted with their user, the policy audits
+// are cleared.
+func TestFactory(t *testing.T, factory store.Factory) {
+	ctx := context.TODO()
+	username := fmt.Sprintf("storetest%d", time.Now().UnixNano())
+
+	user := &v1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: username},
+		Status:     1,
+		Nickname:   "storetest",
+		Password:   "Storetest@2020",
+		Email:      username + "@iam.test",
+		LoginedAt:  time.Now(),
+	}
+	if err := factory.Users().Create(ctx, user, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Users().Create() error = %v", err)
+	}
+
+	defer func() {
+		if err := factory.Users().Delete(ctx, username, metav1.DeleteOptions{Unscoped: true}); err != nil {
+			t.Errorf("Users().Delete() error = %v", err)
+		}
+
+		if _, err := factory.Users().Get(ctx, username, metav1.GetOptions{}); !errors.IsCode(err, code.ErrUserNotFound) {
+			t.Errorf("Users().Get() of the deleted user error = %v, want code %d", err, code.ErrUserNotFound)
+		}
+	}()
+
+	t.Run("users", func(t *testing.T) { testUsers(ctx, t, factory, username) })
+	t.Run("secrets", func(t *testing.T) { testSecrets(ctx, t, factory, username) })
+	t.Run("policies", func(t *testing.T) { testPolicies(ctx, t, factory, username) })
+	t.Run("batch creations", func(t *testing.T) { testCreateBatch(ctx, t, factory, username) })
+	t.Run("versioned updates", func(t *testing.T) { testVersionedUpdates(ctx, t, factory, username) })
+	t.Run("policy groups", func(t *testing.T) { testPolicyGroups(ctx, t, factory, username) })
+	t.Run("policy audits", func(t *testing.T) { testPolicyAudits(ctx, t, factory) })
+	t.Run("transactions", func(t *testing.T) { testTransactions(ctx, t, factory, username) })
+	t.Run("soft delete", func(t *testing.T) { testSoftDelete(ctx, t, factory, username) })
+	t.Run("search", func(t *testing.T) { testSearch(ctx, t, factory, username) })
+}
+
+// wantDuplicateKey checks that err is store.ErrDuplicateKey, whatever the engine.
+func wantDuplicateKey(t *testing.T, err error) {
+	t.Helper()
+
+	if !errors.Is(err, store.ErrDuplicateKey) {
+		t.Errorf("Create() of a duplicate error = %v, want %v", err, store.ErrDuplicateKey)
+	}
+}
+
+func testUsers(ctx context.Context, t *testing.T, factory store.Factory, username string) {
+	user, err := factory.Users().Get(ctx, username, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if user.InstanceID == "" || user.Email != username+"@iam.test" {
+		t.Errorf("Get() = %s of instance %q, want the user created", user.Email, user.InstanceID)
+	}
+
+	wantDuplicateKey(t, factory.Users().Create(ctx, &v1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: username},
+		Nickname:   "duplicate",
+		Password:   "Storetest@2020",
+		Email:      "duplicate@iam.test",
+		LoginedAt:  time.Now(),
+	}, metav1.CreateOptions{}))
+
+	user.Nickname = "updated"
+	if err := factory.Users().Update(ctx, user, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if user, _ := factory.Users().Get(ctx, username, metav1.GetOptions{}); user == nil || user.Nickname != "updated" {
+		t.Errorf("Get() of the updated user = %v, want nickname updated", user)
+	}
+
+	tests := []struct {
+		name            string
+		lastLoginBefore time.Time
+		want            bool
+	}{
+		{name: "logged in since", lastLoginBefore: time.Now().Add(-time.Hour), want: false},
+		{name: "not logged in since", lastLoginBefore: time.Now().Add(time.Hour), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := factory.Users().ListInactive(ctx, tt.lastLoginBefore, metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("ListInactive() error = %v", err)
+			}
+
+			if got := containsUser(users, username); got != tt.want {
+				t.Errorf("ListInactive() contains the user = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func containsUser(users *v1.UserList, username string) bool {
+	for _, user := range users.Items {
+		if user.Name == username {
+			return true
+		}
+	}
+
+	return false
+}
+
+func testSecrets(ctx context.Context, t *testing.T, factory store.Factory, username string) {
+	secret := &v1.Secret{
+		ObjectMeta:  metav1.ObjectMeta{Name: "secret"},
+		Username:    username,
+		SecretID:    "id-" + username,
+		SecretKey:   "key-" + username,
+		Description: "storetest",
+	}
+	if err := factory.Secrets().Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	got, err := factory.Secrets().Get(ctx, username, "secret", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if got.SecretID != "id-"+username || got.SecretKey != "key-"+username {
+		t.Errorf("Get() = %s, want the secret created", got.SecretID)
+	}
+
+	list, err := factory.Secrets().List(ctx, username, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	if list.TotalCount != 1 || len(list.Items) != 1 {
+		t.Errorf("List() = %d secrets, want 1", list.TotalCount)
+	}
+
+	if err := factory.Secrets().Delete(ctx, username, "secret", metav1.DeleteOptions{Unscoped: true}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if _, err := factory.Secrets().Get(ctx, username, "secret", metav1.GetOptions{}); !errors.IsCode(err, code.ErrSecretNotFound) {
+		t.Errorf("Get() of the deleted secret error = %v, want code %d", err, code.ErrSecretNotFound)
+	}
+}
+
+func testPolicies(ctx context.Context, t *testing.T, factory store.Factory, username string) {
+	policy := &v1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "policy"}, Username: username}
+	if err := factory.Policies().Create(ctx, policy, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	got, err := factory.Policies().Get(ctx, username, "policy", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	got.Policy.Description = "updated"
+	if err := factory.Policies().Update(ctx, got, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if got, _ := factory.Policies().Get(ctx, username, "policy", metav1.GetOptions{}); got == nil ||
+		got.Policy.Description != "updated" {
+		t.Errorf("Get() of the updated policy = %v, want description updated", got)
+	}
+
+	if err := factory.Policies().Delete(ctx, username, "policy", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if _, err := factory.Policies().Get(ctx, username, "policy", metav1.GetOptions{}); !errors.IsCode(err, code.ErrPolicyNotFound) {
+		t.Errorf("Get() of the deleted policy error = %v, want code %d", err, code.ErrPolicyNotFound)
+	}
+}
+
+func testCreateBatch(ctx context.Context, t *testing.T, factory store.Factory, username string) {
+	user := func(name string) *v1.User {
+		return &v1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     1,
+			Nickname:   "storetest",
+			Password:   "Storetest@2020",
+			Email:      name + "@iam.test",
+			LoginedAt:  time.Now(),
+		}
+	}
+	users := []*v1.User{user(username + "b1"), user(username), user(username + "b2"), user(username + "b1")}
+
+	defer func() {
+		names := []string{users[0].Name, users[2].Name}
+		if err := factory.Users().DeleteCollection(ctx, names, metav1.DeleteOptions{Unscoped: true}); err != nil {
+			t.Errorf("Users().DeleteCollection() error = %v", err)
+		}
+	}()
+
+	policy := func(name string) *v1.Policy {
+		return &v1.Policy{ObjectMeta: metav1.ObjectMeta{Name: name}, Username: username}
+	}
+	if err := factory.Policies().Create(ctx, policy("batch-existing"), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	policies := []*v1.Policy{policy("batch1"), policy("batch1"), policy("batch-existing"), policy("batch2")}
+
+	tests := []struct {
+		name       string
+		create     func() (map[int]error, error)
+		wantFailed []int
+		get        func(i int) (instanceID string, err error)
+	}{
+		{
+			name: "users",
+			create: func() (map[int]error, error) {
+				return factory.Users().CreateBatch(ctx, users, metav1.CreateOptions{})
+			},
+			// the existing user and the second user of the same name.
+			wantFailed: []int{1, 3},
+			get: func(i int) (string, error) {
+				user, err := factory.Users().Get(ctx, users[i].Name, metav1.GetOptions{})
+				if err != nil {
+					return "", err
+				}
+
+				return user.InstanceID, nil
+			},
+		},
+		{
+			name: "policies",
+			create: func() (map[int]error, error) {
+				return factory.Policies().CreateBatch(ctx, policies, metav1.CreateOptions{})
+			},
+			wantFailed: []int{1, 2},
+			get: func(i int) (string, error) {
+				policy, err := factory.Policies().Get(ctx, username, policies[i].Name, metav1.GetOptions{})
+				if err != nil {
+					return "", err
+				}
+
+				return policy.InstanceID, nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failed, err := tt.create()
+			if err != nil {
+				t.Fatalf("CreateBatch() error = %v", err)
+			}
+
+			if len(failed) != len(tt.wantFailed) {
+				t.Errorf("CreateBatch() failed = %v, want the rows %v", failed, tt.wantFailed)
+			}
+
+			for _, i := range tt.wantFailed {
+				if !errors.Is(failed[i], store.ErrDuplicateKey) {
+					t.Errorf("CreateBatch() error of the row %d = %v, want %v", i, failed[i], store.ErrDuplicateKey)
+				}
+			}
+
+			// the other rows are created, with their instance id.
+			for i := 0; i < 4; i++ {
+				if _, ok := failed[i]; ok {
+					continue
+				}
+
+				if instanceID, err := tt.get(i); err != nil || instanceID == "" {
+					t.Errorf("Get() of the row %d = instance %q, error %v, want it created", i, instanceID, err)
+				}
+			}
+		})
+	}
+}
+
+func testVersionedUpdates(ctx context.Context, t *testing.T, factory store.Factory, username string) {
+	policy := &v1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "versioned"}, Username: username}
+	if err := factory.Policies().Create(ctx, policy, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	get := func() *modelv1.VersionedPolicy {
+		got, err := factory.Policies().GetVersioned(ctx, username, "versioned", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("GetVersioned() error = %v", err)
+		}
+
+		return got
+	}
+
+	// Two clients read the policy, then both update it.
+	first, second := get(), get()
+
+	first.Policy.Policy.Description = "first"
+	if err := factory.Policies().UpdateVersioned(ctx, first, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("UpdateVersioned() error = %v", err)
+	}
+
+	second.Policy.Policy.Description = "second"
+	err := factory.Policies().UpdateVersioned(ctx, second, metav1.UpdateOptions{})
+	if !errors.IsCode(err, code.ErrResourceVersionConflict) {
+		t.Fatalf("concurrent UpdateVersioned() error = %v, want code %d", err, code.ErrResourceVersionConflict)
+	}
+
+	got := get()
+	if got.Policy.Policy.Description != "first" || got.ResourceVersion != first.ResourceVersion ||
+		got.ResourceVersion <= second.ResourceVersion {
+		t.Errorf("GetVersioned() = %q version %d, want %q version %d", got.Policy.Policy.Description,
+			got.ResourceVersion, "first", first.ResourceVersion)
+	}
+
+	// The unversioned updates change the version too.
+	if err := factory.Policies().Update(ctx, &got.Policy, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if err := factory.Policies().UpdateVersioned(ctx, got, metav1.UpdateOptions{}); !errors.IsCode(err, code.ErrResourceVersionConflict) {
+		t.Errorf("UpdateVersioned() after Update() error = %v, want code %d", err, code.ErrResourceVersionConflict)
+	}
+
+	if err := factory.Policies().Delete(ctx, username, "versioned", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+}
+
+func testPolicyGroups(ctx context.Context, t *testing.T, factory store.Factory, username string) {
+	group := func() *modelv1.PolicyGroup {
+		return &modelv1.PolicyGroup{
+			ObjectMeta: metav1.ObjectMeta{Name: "group"},
+			Username:   username,
+			PolicyIDs:  []string{"member"},
+		}
+	}
+
+	if err := factory.PolicyGroups().Create(ctx, group(), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	wantDuplicateKey(t, factory.PolicyGroups().Create(ctx, group(), metav1.CreateOptions{}))
+
+	if err := factory.PolicyGroups().Apply(ctx, username, "group"); !errors.IsCode(err, code.ErrPolicyNotFound) {
+		t.Errorf("Apply() without the member policy error = %v, want code %d", err, code.ErrPolicyNotFound)
+	}
+
+	member := &v1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "member"}, Username: username}
+	if err := factory.Policies().Create(ctx, member, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Policies().Create() error = %v", err)
+	}
+
+	if err := factory.PolicyGroups().Apply(ctx, username, "group"); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	got, err := factory.PolicyGroups().Get(ctx, username, "group", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if !got.Applied || len(got.PolicyIDs) != 1 || got.PolicyIDs[0] != "member" {
+		t.Errorf("Get() = applied %v with %v, want applied with member", got.Applied, got.PolicyIDs)
+	}
+
+	if err := factory.PolicyGroups().Delete(ctx, username, "group", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if _, err := factory.Policies().Get(ctx, username, "member", metav1.GetOptions{}); !errors.IsCode(err, code.ErrPolicyNotFound) {
+		t.Errorf("Get() of the member policy of the deleted group error = %v, want code %d", err, code.ErrPolicyNotFound)
+	}
+}
+
+func testPolicyAudits(ctx context.Context, t *testing.T, factory store.Factory) {
+	// the policies deleted above are audited by the trigger of the policy table, as of now, so they are
+	// older than a day from now.
+	cleared, err := factory.PolicyAudits().ClearOutdated(ctx, -1)
+	if err != nil {
+		t.Fatalf("ClearOutdated() error = %v", err)
+	}
+
+	if cleared < 2 {
+		t.Errorf("ClearOutdated() cleared %d audits, want at least the 2 policies deleted", cleared)
+	}
+}
+
+// errRollback fails the transactions of the tests, which must be rolled back.
+var errRollback = errors.New("rollback")
+
+func testTransactions(ctx context.Context, t *testing.T, factory store.Factory, username string) {
+	createSecret := func(factory store.Factory, name string) error {
+		return factory.Secrets().Create(ctx, &v1.Secret{
+			ObjectMeta:  metav1.ObjectMeta{Name: name},
+			Username:    username,
+			SecretID:    name + "-" + username,
+			SecretKey:   "key-" + username,
+			Description: "storetest",
+		}, metav1.CreateOptions{})
+	}
+
+	secretExists := func(name string) bool {
+		_, err := factory.Secrets().Get(ctx, username, name, metav1.GetOptions{})
+		if err != nil && !errors.IsCode(err, code.ErrSecretNotFound) {
+			t.Fatalf("Get() error = %v", err)
+		}
+
+		return err == nil
+	}
+
+	err := factory.WithTransaction(ctx, func(tx store.Factory) error {
+		if err := createSecret(tx, "rolled-back"); err != nil {
+			return err
+		}
+
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("WithTransaction() error = %v, want %v", err, errRollback)
+	}
+
+	if secretExists("rolled-back") {
+		t.Error("the secret created by a failed transaction exists")
+	}
+
+	// the failure of a nested transaction only rolls back its own writes.
+	err = factory.WithTransaction(ctx, func(tx store.Factory) error {
+		if err := createSecret(tx, "committed"); err != nil {
+			return err
+		}
+
+		err := tx.WithTransaction(ctx, func(nested store.Factory) error {
+			if err := createSecret(nested, "nested"); err != nil {
+				return err
+			}
+
+			return errRollback
+		})
+		if !errors.Is(err, errRollback) {
+			return fmt.Errorf("nested WithTransaction() error = %v, want %v", err, errRollback)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTransaction() error = %v", err)
+	}
+
+	if !secretExists("committed") {
+		t.Error("the secret created by a committed transaction does not exist")
+	}
+
+	if secretExists("nested") {
+		t.Error("the secret created by a failed nested transaction exists")
+	}
+}
+
+// softDeleteFixtures creates a user owning a policy, a secret shared with the live user, and the target of
+// a share of a secret of the live user. The user is soft deleted.
+func softDeleteFixtures(ctx context.Context, t *testing.T, factory store.Factory, username, deleted string) {
+	t.Helper()
+
+	user := &v1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: deleted},
+		Status:     1,
+		Nickname:   "storetest",
+		Password:   "Storetest@2020",
+		Email:      deleted + "@iam.test",
+		LoginedAt:  time.Now(),
+	}
+	if err := factory.Users().Create(ctx, user, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Users().Create() error = %v", err)
+	}
+
+	secret := func(owner string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta:  metav1.ObjectMeta{Name: "shared"},
+			Username:    owner,
+			SecretID:    "shared-" + owner,
+			SecretKey:   "key-" + owner,
+			Description: "storetest",
+		}
+	}
+	share := func(owner, target string) *modelv1.SecretShare {
+		return &modelv1.SecretShare{
+			ObjectMeta:     metav1.ObjectMeta{Name: "shared"},
+			Username:       owner,
+			SecretID:       "shared-" + owner,
+			TargetUsername: target,
+			ExpiresAt:      time.Now().Add(time.Hour),
+		}
+	}
+
+	for _, err := range []error{
+		factory.Policies().Create(ctx, &v1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "owned"}, Username: deleted},
+			metav1.CreateOptions{}),
+		factory.Policies().Create(ctx, &v1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "owned"}, Username: username},
+			metav1.CreateOptions{}),
+		factory.Secrets().Create(ctx, secret(deleted), metav1.CreateOptions{}),
+		factory.Secrets().Create(ctx, secret(username), metav1.CreateOptions{}),
+		factory.SecretShares().Create(ctx, share(deleted, username), metav1.CreateOptions{}),
+		factory.SecretShares().Create(ctx, share(username, deleted), metav1.CreateOptions{}),
+	} {
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	if err := factory.Users().Delete(ctx, deleted, metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Users().Delete() error = %v", err)
+	}
+}
+
+func testSoftDelete(ctx context.Context, t *testing.T, factory store.Factory, username string) {
+	deleted := username + "-deleted"
+	softDeleteFixtures(ctx, t, factory, username, deleted)
+
+	defer func() {
+		if err := factory.Users().Delete(ctx, deleted, metav1.DeleteOptions{Unscoped: true}); err != nil {
+			t.Errorf("Users().Delete() error = %v", err)
+		}
+	}()
+
+	byName := metav1.ListOptions{FieldSelector: "name=" + deleted}
+
+	// every query path reads whether the deleted user, or the resources it owns, are found. The ones of
+	// the live user must be found whatever the context.
+	tests := []struct {
+		name string
+		read func(ctx context.Context) (found bool, err error)
+		// live reads the same of the live user, if the path reads them.
+		live func(ctx context.Context) (found bool, err error)
+	}{
+		{
+			name: "user get",
+			read: func(ctx context.Context) (bool, error) {
+				_, err := factory.Users().Get(ctx, deleted, metav1.GetOptions{})
+				if errors.IsCode(err, code.ErrUserNotFound) {
+					return false, nil
+				}
+
+				return err == nil, err
+			},
+			live: func(ctx context.Context) (bool, error) {
+				_, err := factory.Users().Get(ctx, username, metav1.GetOptions{})
+
+				return err == nil, err
+			},
+		},
+		{
+			name: "user list",
+			read: func(ctx context.Context) (bool, error) {
+				users, err := factory.Users().List(ctx, byName)
+				if err != nil {
+					return false, err
+				}
+
+				return containsUser(users, deleted), nil
+			},
+		},
+		{
+			name: "inactive user list",
+			read: func(ctx context.Context) (bool, error) {
+				users, err := factory.Users().ListInactive(ctx, time.Now().Add(time.Hour), metav1.ListOptions{})
+				if err != nil {
+					return false, err
+				}
+
+				return containsUser(users, deleted), nil
+			},
+			live: func(ctx context.Context) (bool, error) {
+				users, err := factory.Users().ListInactive(ctx, time.Now().Add(time.Hour), metav1.ListOptions{})
+				if err != nil {
+					return false, err
+				}
+
+				return containsUser(users, username), nil
+			},
+		},
+		{
+			name: "policy get",
+			read: func(ctx context.Context) (bool, error) {
+				_, err := factory.Policies().Get(ctx, deleted, "owned", metav1.GetOptions{})
+				if errors.IsCode(err, code.ErrPolicyNotFound) {
+					return false, nil
+				}
+
+				return err == nil, err
+			},
+		},
+		{
+			name: "policy list of the user",
+			read: func(ctx context.Context) (bool, error) {
+				policies, err := factory.Policies().List(ctx, deleted, metav1.ListOptions{})
+				if err != nil {
+					return false, err
+				}
+
+				return policies.TotalCount > 0 || len(policies.Items) > 0, nil
+			},
+		},
+		{
+			// the lists of all the policies and secrets are the ones of the cache service.
+			name: "policy list of all the users",
+			read: func(ctx context.Context) (bool, error) {
+				policies, err := factory.Policies().List(ctx, "", metav1.ListOptions{})
+				if err != nil {
+					return false, err
+				}
+
+				return containsPolicyOf(policies, deleted), nil
+			},
+			live: func(ctx context.Context) (bool, error) {
+				policies, err := factory.Policies().List(ctx, "", metav1.ListOptions{})
+				if err != nil {
+					return false, err
+				}
+
+				return containsPolicyOf(policies, username), nil
+			},
+		},
+		{
+			name: "secret get",
+			read: func(ctx context.Context) (bool, error) {
+				_, err := factory.Secrets().Get(ctx, deleted, "shared", metav1.GetOptions{})
+				if errors.IsCode(err, code.ErrSecretNotFound) {
+					return false, nil
+				}
+
+				return err == nil, err
+			},
+		},
+		{
+			name: "secret list of all the users",
+			read: func(ctx context.Context) (bool, error) {
+				secrets, err := factory.Secrets().List(ctx, "", metav1.ListOptions{})
+				if err != nil {
+					return false, err
+				}
+
+				return containsSecretOf(secrets, deleted), nil
+			},
+			live: func(ctx context.Context) (bool, error) {
+				secrets, err := factory.Secrets().List(ctx, "", metav1.ListOptions{})
+				if err != nil {
+					return false, err
+				}
+
+				return containsSecretOf(secrets, username), nil
+			},
+		},
+		{
+			name: "share of the user",
+			read: func(ctx context.Context) (bool, error) {
+				shares, err := factory.SecretShares().List(ctx, username, metav1.ListOptions{})
+				if err != nil {
+					return false, err
+				}
+
+				return len(shares.Items) > 0, nil
+			},
+		},
+		{
+			name: "share with the user",
+			read: func(ctx context.Context) (bool, error) {
+				shares, err := factory.SecretShares().List(ctx, deleted, metav1.ListOptions{})
+				if err != nil {
+					return false, err
+				}
+
+				return len(shares.Items) > 0, nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if found, err := tt.read(ctx); err != nil || found {
+				t.Errorf("read of the deleted user = %v, %v, want not found", found, err)
+			}
+
+			if found, err := tt.read(store.WithDeleted(ctx)); err != nil || !found {
+				t.Errorf("read of the deleted user with the deleted = %v, %v, want found", found, err)
+			}
+
+			if tt.live == nil {
+				return
+			}
+
+			if found, err := tt.live(ctx); err != nil || !found {
+				t.Errorf("read of the live user = %v, %v, want found", found, err)
+			}
+		})
+	}
+
+	// the name of a deleted user is available, the resources of the deleted user are not inherited.
+	user := &v1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: deleted},
+		Status:     1,
+		Nickname:   "reused",
+		Password:   "Storetest@2020",
+		Email:      deleted + "@iam.test",
+		LoginedAt:  time.Now(),
+	}
+	if err := factory.Users().Create(ctx, user, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Users().Create() with the name of a deleted user error = %v", err)
+	}
+
+	if got, err := factory.Users().Get(ctx, deleted, metav1.GetOptions{}); err != nil || got.Nickname != "reused" {
+		t.Errorf("Users().Get() of the reused name = %v, %v, want the user created", got, err)
+	}
+
+	policies, err := factory.Policies().List(store.WithDeleted(ctx), deleted, metav1.ListOptions{})
+	if err != nil || len(policies.Items) != 0 {
+		t.Errorf("Policies().List() of the reused name = %v, %v, want none", policies, err)
+	}
+}
+
+func containsPolicyOf(policies *v1.PolicyList, username string) bool {
+	for _, policy := range policies.Items {
+		if policy.Username == username {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsSecretOf(secrets *v1.SecretList, username string) bool {
+	for _, secret := range secrets.Items {
+		if secret.Username == username {
+			return true
+		}
+	}
+
+	return false
+}
+
+func testSearch(ctx context.Context, t *testing.T, factory store.Factory, username string) {
+	// the users are created in order, the users of the same relevance are found by descending id.
+	fixtures := []struct {
+		suffix, nickname string
+		status           int
+	}{
+		{suffix: "-percent", nickname: "100% sure", status: 1},
+		{suffix: "-number", nickname: "100 percent", status: 1},
+		{suffix: "-underscore", nickname: "a_b", status: 1},
+		{suffix: "-letter", nickname: "AxB", status: 1},
+		{suffix: "-disabled", nickname: "100% disabled"},
+	}
+	for _, fixture := range fixtures {
+		user := &v1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: username + fixture.suffix},
+			Status:     fixture.status,
+			Nickname:   fixture.nickname,
+			Password:   "Storetest@2020",
+			Email:      username + fixture.suffix + "@iam.test",
+		}
+		if err := factory.Users().Create(ctx, user, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("Users().Create() error = %v", err)
+		}
+	}
+
+	defer func() {
+		for _, fixture := range fixtures {
+			if err := factory.Users().Delete(ctx, username+fixture.suffix, metav1.DeleteOptions{Unscoped: true}); err != nil {
+				t.Errorf("Users().Delete() error = %v", err)
+			}
+		}
+	}()
+
+	byNickname := []string{"nickname"}
+
+	tests := []struct {
+		name   string
+		search store.SearchOptions
+		// want are the suffixes of the users found among the fixtures and the user of the tests, in order.
+		want []string
+	}{
+		{name: "percent", search: store.SearchOptions{Fields: byNickname, Query: "100%"}, want: []string{"-percent"}},
+		{name: "percent only", search: store.SearchOptions{Fields: byNickname, Query: "%"}, want: []string{"-percent"}},
+		{name: "underscore", search: store.SearchOptions{Fields: byNickname, Query: "a_b"}, want: []string{"-underscore"}},
+		{name: "underscore only", search: store.SearchOptions{Fields: byNickname, Query: "_"}, want: []string{"-underscore"}},
+		{name: "case insensitive", search: store.SearchOptions{Fields: byNickname, Query: " axb "}, want: []string{"-letter"}},
+		{
+			name:   "exact then prefix",
+			search: store.SearchOptions{Fields: []string{"name"}, Query: username},
+			want:   []string{"", "-letter", "-underscore", "-number", "-percent"},
+		},
+		{
+			name:   "empty query",
+			search: store.SearchOptions{Query: "  "},
+			want:   []string{"-letter", "-underscore", "-number", "-percent", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := factory.Users().Search(ctx, tt.search, metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Users().Search() error = %v", err)
+			}
+
+			var got []string
+			for _, user := range users.Items {
+				if suffix := strings.TrimPrefix(user.Name, username); suffix != user.Name || user.Name == username {
+					got = append(got, suffix)
+				}
+			}
+
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Users().Search() found %q, want %q", got, tt.want)
+			}
+
+			if users.TotalCount < int64(len(tt.want)) {
+				t.Errorf("Users().Search() total count = %d, want at least %d", users.TotalCount, len(tt.want))
+			}
+		})
+	}
+
+	if _, err := factory.Users().Search(ctx, store.SearchOptions{Fields: []string{"password"}}, metav1.ListOptions{}); !errors.IsCode(err, code.ErrValidation) {
+		t.Errorf("Users().Search() of an unsearchable field error = %v, want code %d", err, code.ErrValidation)
+	}
+
+	// the policies of the user are ranked by relevance: exact, prefix, then contains by descending id.
+	names := []string{"a-search", "search-b", "b-search", "search"}
+	for _, name := range names {
+		policy := &v1.Policy{ObjectMeta: metav1.ObjectMeta{Name: name}, Username: username}
+		if err := factory.Policies().Create(ctx, policy, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("Policies().Create() error = %v", err)
+		}
+	}
+
+	defer func() {
+		if err := factory.Policies().DeleteCollection(ctx, username, names, metav1.DeleteOptions{}); err != nil {
+			t.Errorf("Policies().DeleteCollection() error = %v", err)
+		}
+	}()
+
+	policies, err := factory.Policies().Search(ctx, username, store.SearchOptions{
+		Fields: []string{"name"},
+		Query:  "SEARCH",
+	}, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Policies().Search() error = %v", err)
+	}
+
+	var got []string
+	for _, policy := range policies.Items {
+		got = append(got, policy.Name)
+	}
+
+	if want := "search,search-b,b-search,a-search"; strings.Join(got, ",") != want || policies.TotalCount != 4 {
+		t.Errorf("Policies().Search() = %d %q, want %s", policies.TotalCount, got, want)
+	}
+}
@@ -6,6 +6,7 @@ package store
 
 import (
 	"context"
+	"time"
 
 	v1 "github.com/marmotedu/api/apiserver/v1"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
@@ -13,10 +14,40 @@ import (
 
 // UserStore defines the user storage interface.
 type UserStore interface {
+	// Create creates the user, the name of a soft deleted user is reused: it is deleted for good first.
 	Create(ctx context.Context, user *v1.User, opts metav1.CreateOptions) error
+	// CreateBatch creates the users but the duplicates: the users violating a unique key, e.g. of an
+	// existing name, are skipped and returned by their index with ErrDuplicateKey. The other errors fail
+	// the whole batch, the stores with transactions then create none of the users. The sql stores insert
+	// them by chunks of multiple rows.
+	CreateBatch(ctx context.Context, users []*v1.User, opts metav1.CreateOptions) (map[int]error, error)
 	Update(ctx context.Context, user *v1.User, opts metav1.UpdateOptions) error
+	// Delete soft deletes the user, or deletes it for good with its resources if opts.Unscoped is set. The
+	// soft deleted users and their resources are excluded from the reads of the stores, see WithDeleted.
 	Delete(ctx context.Context, username string, opts metav1.DeleteOptions) error
 	DeleteCollection(ctx context.Context, usernames []string, opts metav1.DeleteOptions) error
 	Get(ctx context.Context, username string, opts metav1.GetOptions) (*v1.User, error)
 	List(ctx context.Context, opts metav1.ListOptions) (*v1.UserList, error)
+	// ListInactive returns the enabled users who have not logged in since lastLoginBefore, including the
+	// ones created before it who never logged in.
+	ListInactive(ctx context.Context, lastLoginBefore time.Time, opts metav1.ListOptions) (*v1.UserList, error)
+	// Search returns the enabled users found by the search in their name, nickname and email, ordered by
+	// relevance then id, see SearchOptions.
+	Search(ctx context.Context, search SearchOptions, opts metav1.ListOptions) (*v1.UserList, error)
+}
+
+// withDeletedKey is the context key of WithDeleted.
+type withDeletedKey struct{}
+
+// WithDeleted returns a copy of ctx whose reads of the stores include the soft deleted users, and the
+// resources they own, e.g. for the administrators to list them. They are excluded by default.
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withDeletedKey{}, true)
+}
+
+// IsWithDeleted reports whether the reads of ctx include the soft deleted users, see WithDeleted.
+func IsWithDeleted(ctx context.Context) bool {
+	withDeleted, _ := ctx.Value(withDeletedKey{}).(bool)
+
+	return withDeleted
 }
@@ -0,0 +1,20 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"context"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+)
+
+// UserSessionStore defines the user_sessions storage interface.
+type UserSessionStore interface {
+	Create(ctx context.Context, session *v1.UserSession, opts metav1.CreateOptions) error
+	// List returns the sessions of username whose token has not expired, most recent first.
+	List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.UserSessionList, error)
+}
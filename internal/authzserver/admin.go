@@ -0,0 +1,35 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authzserver
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/component-base/pkg/core"
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+)
+
+// newAdminAuth only lets the given users through, it must be installed after the
+// authentication middleware.
+func newAdminAuth(admins []string) gin.HandlerFunc {
+	allowed := make(map[string]struct{}, len(admins))
+	for _, admin := range admins {
+		allowed[admin] = struct{}{}
+	}
+
+	return func(c *gin.Context) {
+		username := c.GetString(middleware.UsernameKey)
+		if _, ok := allowed[username]; !ok {
+			core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "user %s is not a administrator", username), nil)
+			c.Abort()
+
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -6,6 +6,8 @@
 package analytics
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -18,8 +20,13 @@ import (
 
 const analyticsKeyName = "iam-system-analytics"
 
+// RedisKeyPrefix defines the prefix key in redis for analytics data.
+const RedisKeyPrefix = "analytics-"
+
 const (
 	recordsBufferForcedFlushInterval = 1 * time.Second
+	// recordsFlushTimeout bounds the time spent to send a buffer of records to redis.
+	recordsFlushTimeout = 5 * time.Second
 )
 
 // AnalyticsRecord encodes the details of a authorization request.
@@ -56,8 +63,12 @@ type Analytics struct {
 	recordsChan                chan *AnalyticsRecord
 	workerBufferSize           uint64
 	recordsBufferFlushInterval uint64
+	maxRecordAge               time.Duration
 	shouldStop                 uint32
 	poolWg                     sync.WaitGroup
+	// lock serializes Start and Stop, stopped is true once Stop is called.
+	lock    sync.Mutex
+	stopped bool
 }
 
 // NewAnalytics returns a new analytics instance.
@@ -75,19 +86,45 @@ func NewAnalytics(options *AnalyticsOptions, store storage.AnalyticsHandler) *An
 		recordsChan:                recordsChan,
 		workerBufferSize:           workerBufferSize,
 		recordsBufferFlushInterval: options.FlushInterval,
+		maxRecordAge:               options.MaxRecordAge,
 	}
 
 	return analytics
 }
 
+// NewStore returns the redis storage of the analytics records configured by options.
+func NewStore(options *AnalyticsOptions) storage.AnalyticsHandler {
+	store := storage.RedisCluster{KeyPrefix: RedisKeyPrefix}
+	if options.StorageBackend == StorageBackendStream {
+		return &storage.RedisStream{
+			RedisCluster: store,
+			Stream:       options.StreamName,
+			MaxLen:       options.StreamMaxLen,
+		}
+	}
+
+	return &storage.RedisList{
+		RedisCluster: store,
+		MaxLen:       options.ListMaxLen,
+		Expiration:   options.StorageExpirationTime,
+	}
+}
+
 // GetAnalytics returns the existed analytics instance.
 // Need to initialize `analytics` instance before calling GetAnalytics.
 func GetAnalytics() *Analytics {
 	return analytics
 }
 
-// Start start the analytics service.
+// Start start the analytics service, it does nothing if the service is already stopped.
 func (r *Analytics) Start() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.stopped {
+		return
+	}
+
 	r.store.Connect()
 
 	// start worker pool
@@ -100,6 +137,11 @@ func (r *Analytics) Start() {
 
 // Stop stop the analytics service.
 func (r *Analytics) Stop() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.stopped = true
+
 	// flag to stop sending records into channel
 	atomic.SwapUint32(&r.shouldStop, 1)
 
@@ -110,6 +152,25 @@ func (r *Analytics) Stop() {
 	r.poolWg.Wait()
 }
 
+// StopCtx stops the analytics service like Stop, within the deadline of ctx. It returns
+// the error of ctx if the workers did not flush the records left before it is done, they
+// are not waited for anymore.
+func (r *Analytics) StopCtx(ctx context.Context) error {
+	stopped := make(chan struct{})
+
+	go func() {
+		r.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("flush the analytics records: %w", ctx.Err())
+	}
+}
+
 // RecordHit will store an AnalyticsRecord in Redis.
 func (r *Analytics) RecordHit(record *AnalyticsRecord) error {
 	// check if we should stop sending records 1st
@@ -117,6 +178,11 @@ func (r *Analytics) RecordHit(record *AnalyticsRecord) error {
 		return nil
 	}
 
+	r.capExpiry(record)
+
+	// stream the record to the websocket subscribers
+	broadcaster.Publish(record)
+
 	// just send record to channel consumed by pool of workers
 	// leave all data crunching and Redis I/O work for pool workers
 	r.recordsChan <- record
@@ -124,6 +190,21 @@ func (r *Analytics) RecordHit(record *AnalyticsRecord) error {
 	return nil
 }
 
+// capExpiry applies the global retention policy whatever the expiration time set by the caller.
+func (r *Analytics) capExpiry(record *AnalyticsRecord) {
+	if r.maxRecordAge > 0 {
+		if maxExpireAt := time.Now().Add(r.maxRecordAge); record.ExpireAt.After(maxExpireAt) {
+			record.ExpireAt = maxExpireAt
+		}
+	}
+}
+
+// SetFlushInterval changes the interval, in milliseconds, after which the workers flush their buffered
+// records if they received no new one, e.g. on a configuration reload.
+func (r *Analytics) SetFlushInterval(interval uint64) {
+	atomic.StoreUint64(&r.recordsBufferFlushInterval, interval)
+}
+
 func (r *Analytics) recordWorker() {
 	defer r.poolWg.Done()
 
@@ -140,7 +221,7 @@ func (r *Analytics) recordWorker() {
 			// check if channel was closed and it is time to exit from worker
 			if !ok {
 				// send what is left in buffer
-				r.store.AppendToSetPipelined(analyticsKeyName, recordsBuffer)
+				r.flush(recordsBuffer)
 
 				return
 			}
@@ -156,7 +237,7 @@ func (r *Analytics) recordWorker() {
 			// identify that buffer is ready to be sent
 			readyToSend = uint64(len(recordsBuffer)) == r.workerBufferSize
 
-		case <-time.After(time.Duration(r.recordsBufferFlushInterval) * time.Millisecond):
+		case <-time.After(time.Duration(atomic.LoadUint64(&r.recordsBufferFlushInterval)) * time.Millisecond):
 			// nothing was received for that period of time
 			// anyways send whatever we have, don't hold data too long in buffer
 			readyToSend = true
@@ -164,13 +245,22 @@ func (r *Analytics) recordWorker() {
 
 		// send data to Redis and reset buffer
 		if len(recordsBuffer) > 0 && (readyToSend || time.Since(lastSentTS) >= recordsBufferForcedFlushInterval) {
-			r.store.AppendToSetPipelined(analyticsKeyName, recordsBuffer)
+			r.flush(recordsBuffer)
 			recordsBuffer = recordsBuffer[:0]
 			lastSentTS = time.Now()
 		}
 	}
 }
 
+// flush sends the buffered records to redis, a redis which does not respond can not block
+// the worker longer than recordsFlushTimeout.
+func (r *Analytics) flush(recordsBuffer [][]byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), recordsFlushTimeout)
+	defer cancel()
+
+	r.store.AppendToSetPipelined(ctx, analyticsKeyName, recordsBuffer)
+}
+
 // DurationToMillisecond convert time duration type to float64.
 func DurationToMillisecond(d time.Duration) float64 {
 	return float64(d) / 1e6
@@ -11,12 +11,25 @@ import (
 	"github.com/spf13/pflag"
 )
 
+// Analytics storage backends.
+const (
+	// StorageBackendList stores the analytics records into a redis list.
+	StorageBackendList = "list"
+	// StorageBackendStream stores the analytics records into a redis stream.
+	StorageBackendStream = "stream"
+)
+
 // AnalyticsOptions contains configuration items related to analytics.
 type AnalyticsOptions struct {
 	PoolSize                int           `json:"pool-size"                 mapstructure:"pool-size"`
 	RecordsBufferSize       uint64        `json:"records-buffer-size"       mapstructure:"records-buffer-size"`
 	FlushInterval           uint64        `json:"flush-interval"            mapstructure:"flush-interval"`
 	StorageExpirationTime   time.Duration `json:"storage-expiration-time"   mapstructure:"storage-expiration-time"`
+	MaxRecordAge            time.Duration `json:"max-record-age"            mapstructure:"max-record-age"`
+	StorageBackend          string        `json:"storage-backend"           mapstructure:"storage-backend"`
+	ListMaxLen              int64         `json:"list-max-len"              mapstructure:"list-max-len"`
+	StreamName              string        `json:"stream-name"               mapstructure:"stream-name"`
+	StreamMaxLen            int64         `json:"stream-max-len"            mapstructure:"stream-max-len"`
 	Enable                  bool          `json:"enable"                    mapstructure:"enable"`
 	EnableDetailedRecording bool          `json:"enable-detailed-recording" mapstructure:"enable-detailed-recording"`
 }
@@ -30,6 +43,11 @@ func NewAnalyticsOptions() *AnalyticsOptions {
 		FlushInterval:           200,
 		EnableDetailedRecording: true,
 		StorageExpirationTime:   time.Duration(24) * time.Hour,
+		MaxRecordAge:            0,
+		StorageBackend:          StorageBackendList,
+		ListMaxLen:              0,
+		StreamName:              "iam-system-analytics-stream",
+		StreamMaxLen:            100000,
 	}
 }
 
@@ -45,6 +63,28 @@ func (o *AnalyticsOptions) Validate() []error {
 		errors = append(errors, fmt.Errorf("--analytics.flush-interval %v must be between 1 and 1000", o.FlushInterval))
 	}
 
+	if o.MaxRecordAge < 0 {
+		errors = append(errors, fmt.Errorf("--analytics.max-record-age %v can not be negative", o.MaxRecordAge))
+	}
+
+	switch o.StorageBackend {
+	case StorageBackendList:
+		if o.ListMaxLen < 0 {
+			errors = append(errors, fmt.Errorf("--analytics.list-max-len %v can not be negative", o.ListMaxLen))
+		}
+	case StorageBackendStream:
+		if o.StreamName == "" {
+			errors = append(errors, fmt.Errorf("--analytics.stream-name can not be empty"))
+		}
+
+		if o.StreamMaxLen < 0 {
+			errors = append(errors, fmt.Errorf("--analytics.stream-max-len %v can not be negative", o.StreamMaxLen))
+		}
+	default:
+		errors = append(errors, fmt.Errorf("--analytics.storage-backend %q must be %s or %s",
+			o.StorageBackend, StorageBackendList, StorageBackendStream))
+	}
+
 	return errors
 }
 
@@ -70,4 +110,23 @@ func (o *AnalyticsOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.DurationVar(&o.StorageExpirationTime, "analytics.storage-expiration-time", o.StorageExpirationTime, ""+
 		"Set to a value larger than the Pump's purge_delay. "+
 		"This allows the analytics data to exist long enough in Redis to be processed by the Pump.")
+
+	fs.DurationVar(&o.MaxRecordAge, "analytics.max-record-age", o.MaxRecordAge, ""+
+		"The maximum retention of the analytics records, the expiration time of a record set further is "+
+		"capped to this duration from now. Set to 0 to keep the expiration time of the records.")
+
+	fs.StringVar(&o.StorageBackend, "analytics.storage-backend", o.StorageBackend, ""+
+		"The redis data type storing the analytics data, list or stream. Unlike a list, a stream can be "+
+		"consumed by several consumer groups with acknowledgement.")
+
+	fs.Int64Var(&o.ListMaxLen, "analytics.list-max-len", o.ListMaxLen, ""+
+		"Cap the redis list to this number of records if --analytics.storage-backend=list, the oldest "+
+		"records are evicted. Set to 0 to not cap the list.")
+
+	fs.StringVar(&o.StreamName, "analytics.stream-name", o.StreamName,
+		"The name of the redis stream storing the analytics data if --analytics.storage-backend=stream.")
+
+	fs.Int64Var(&o.StreamMaxLen, "analytics.stream-max-len", o.StreamMaxLen, ""+
+		"Cap the redis stream to about this number of records, the oldest records are evicted. "+
+		"Set to 0 to not cap the stream.")
 }
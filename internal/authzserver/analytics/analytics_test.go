@@ -0,0 +1,95 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package analytics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAnalytics_RecordHit_MaxRecordAge(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxRecordAge time.Duration
+		expiresIn    int64
+		want         time.Duration
+	}{
+		{name: "default expiry capped", maxRecordAge: 30 * 24 * time.Hour, expiresIn: 0, want: 30 * 24 * time.Hour},
+		{name: "long expiry capped", maxRecordAge: time.Hour, expiresIn: 7200, want: time.Hour},
+		{name: "short expiry kept", maxRecordAge: time.Hour, expiresIn: 60, want: time.Minute},
+		{name: "no max age", expiresIn: 60, want: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := NewAnalyticsOptions()
+			options.MaxRecordAge = tt.maxRecordAge
+			r := NewAnalytics(options, nil)
+
+			record := &AnalyticsRecord{Username: "colin"}
+			record.SetExpiry(tt.expiresIn)
+
+			now := time.Now()
+			if err := r.RecordHit(record); err != nil {
+				t.Fatalf("RecordHit() error = %v", err)
+			}
+
+			if got := record.ExpireAt.Sub(now); got < tt.want-time.Second || got > tt.want+time.Second {
+				t.Errorf("record expires in %v, want %v", got, tt.want)
+			}
+
+			if queued := <-r.recordsChan; queued != record {
+				t.Errorf("queued record = %v, want %v", queued, record)
+			}
+		})
+	}
+}
+
+func TestAnalytics_StartAfterStop(t *testing.T) {
+	r := NewAnalytics(NewAnalyticsOptions(), nil)
+
+	// the service is stopped while waiting for redis, it must not be started afterwards.
+	r.Stop()
+	r.Start()
+
+	if err := r.RecordHit(&AnalyticsRecord{Username: "colin"}); err != nil {
+		t.Fatalf("RecordHit() error = %v", err)
+	}
+}
+
+func TestAnalytics_StopCtx(t *testing.T) {
+	tests := []struct {
+		name string
+		// flushDelay is the time the store takes to append the records left.
+		flushDelay time.Duration
+		wantErr    error
+	}{
+		{name: "flushed before the deadline"},
+		{name: "deadline exceeded", flushDelay: time.Second, wantErr: context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a single worker, the fake store is not safe for concurrent use.
+			options := NewAnalyticsOptions()
+			options.PoolSize = 1
+
+			store := &fakeListStore{onAppend: func() { time.Sleep(tt.flushDelay) }}
+			r := NewAnalytics(options, store)
+			r.Start()
+
+			if err := r.RecordHit(&AnalyticsRecord{Username: "colin"}); err != nil {
+				t.Fatalf("RecordHit() error = %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			if err := r.StopCtx(ctx); !errors.Is(err, tt.wantErr) {
+				t.Errorf("StopCtx() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
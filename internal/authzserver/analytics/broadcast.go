@@ -0,0 +1,100 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package analytics
+
+import (
+	"sync"
+)
+
+// Filter selects the analytics records streamed to a subscriber, an empty field matches
+// any record.
+type Filter struct {
+	Username string `json:"username"`
+	Effect   string `json:"effect"`
+}
+
+// Match returns true if the record is selected by the filter.
+func (f Filter) Match(record *AnalyticsRecord) bool {
+	return (f.Username == "" || f.Username == record.Username) && (f.Effect == "" || f.Effect == record.Effect)
+}
+
+// Subscription receives the analytics records matching its filter.
+type Subscription struct {
+	filter Filter
+	// C is closed when the subscription is canceled, or when the subscriber can not keep up.
+	C chan *AnalyticsRecord
+}
+
+// Broadcaster sends the analytics records to the subscribers, in addition to the analytics
+// storage.
+type Broadcaster struct {
+	lock          sync.Mutex
+	subscriptions map[*Subscription]struct{}
+}
+
+var broadcaster = NewBroadcaster()
+
+// NewBroadcaster returns a broadcaster without subscribers.
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+}
+
+// GetBroadcaster returns the broadcaster of the records passed to RecordHit.
+func GetBroadcaster() *Broadcaster {
+	return broadcaster
+}
+
+// Subscribe registers a subscription for the records matching filter, up to buffer records
+// are queued before it is dropped.
+func (b *Broadcaster) Subscribe(filter Filter, buffer int) *Subscription {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	s := &Subscription{filter: filter, C: make(chan *AnalyticsRecord, buffer)}
+	b.subscriptions[s] = struct{}{}
+
+	return s
+}
+
+// Unsubscribe cancels a subscription, it is a no-op if the subscription has been dropped.
+func (b *Broadcaster) Unsubscribe(s *Subscription) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if _, ok := b.subscriptions[s]; ok {
+		delete(b.subscriptions, s)
+		close(s.C)
+	}
+}
+
+// Publish sends the record to the matching subscriptions without blocking, the
+// subscriptions which are full are dropped.
+func (b *Broadcaster) Publish(record *AnalyticsRecord) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for s := range b.subscriptions {
+		if !s.filter.Match(record) {
+			continue
+		}
+
+		select {
+		case s.C <- record:
+		default:
+			delete(b.subscriptions, s)
+			close(s.C)
+		}
+	}
+}
+
+// Len returns the number of subscriptions.
+func (b *Broadcaster) Len() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return len(b.subscriptions)
+}
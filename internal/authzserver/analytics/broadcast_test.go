@@ -0,0 +1,70 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package analytics
+
+import (
+	"testing"
+)
+
+func TestFilter_Match(t *testing.T) {
+	record := &AnalyticsRecord{Username: "colin", Effect: "deny"}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{name: "empty", filter: Filter{}, want: true},
+		{name: "username", filter: Filter{Username: "colin"}, want: true},
+		{name: "username and effect", filter: Filter{Username: "colin", Effect: "deny"}, want: true},
+		{name: "other username", filter: Filter{Username: "admin"}, want: false},
+		{name: "other effect", filter: Filter{Username: "colin", Effect: "allow"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(record); got != tt.want {
+				t.Errorf("Match() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBroadcaster_Publish(t *testing.T) {
+	b := NewBroadcaster()
+
+	colin := b.Subscribe(Filter{Username: "colin"}, 1)
+	admin := b.Subscribe(Filter{Username: "admin"}, 1)
+
+	record := &AnalyticsRecord{Username: "colin", Effect: "allow"}
+	b.Publish(record)
+
+	if got := <-colin.C; got != record {
+		t.Errorf("colin received %+v, want %+v", got, record)
+	}
+
+	if len(admin.C) != 0 {
+		t.Errorf("admin received %d records, want none", len(admin.C))
+	}
+
+	// the buffer of colin is full after the first record, so it is dropped.
+	b.Publish(record)
+	b.Publish(record)
+
+	<-colin.C
+	if _, ok := <-colin.C; ok {
+		t.Error("colin is not dropped, want the full subscription closed")
+	}
+
+	if b.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", b.Len())
+	}
+
+	b.Unsubscribe(colin)
+	b.Unsubscribe(admin)
+
+	if b.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", b.Len())
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package analytics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"sync/atomic"
+
+	msgpack "gopkg.in/vmihailenco/msgpack.v2"
+
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+// DefaultReplayBatchSize is the number of records sent to redis at once when ReplayOptions.BatchSize is not set.
+const DefaultReplayBatchSize = 100
+
+// ErrReplayStopped is returned by ReplayFromFile when the analytics service is stopped during the replay.
+var ErrReplayStopped = errors.New("analytics service is stopped, replay interrupted")
+
+// ReplayOptions configures the replay of the analytics records of a file.
+type ReplayOptions struct {
+	// DryRun reads and checks the records without sending them to redis.
+	DryRun bool
+	// BatchSize is the number of records sent to redis at once, DefaultReplayBatchSize if not positive.
+	BatchSize int
+	// SkipDuplicates skips the records with the timestamp and the username of a record already stored
+	// in redis or already replayed. The records of a stream storage backend are not listed, only the
+	// duplicates of the file are skipped then.
+	SkipDuplicates bool
+}
+
+// ReplayReport counts the records of a replay.
+type ReplayReport struct {
+	// Processed is the number of records sent to redis, or which would have been sent in a dry run.
+	Processed int `json:"processed"`
+	// Skipped is the number of duplicated records skipped.
+	Skipped int `json:"skipped"`
+	// Errors is the number of lines of the file which are not valid records.
+	Errors int `json:"errors"`
+}
+
+// recordLister is implemented by the storages whose records can be listed, e.g. storage.RedisList.
+type recordLister interface {
+	GetListRange(ctx context.Context, keyName string, from, to int64) ([]string, error)
+}
+
+// ReplayFromFile sends the analytics records of the file at path to the redis of a, e.g. to recover
+// the records saved to a dead-letter queue or exported to a file. The file holds one json encoded
+// AnalyticsRecord per line, the expiration time of the records is capped like the live ones.
+// The replay stops with ErrReplayStopped as soon as a is stopped, the records already sent are
+// counted by the returned report.
+func ReplayFromFile(path string, a *Analytics, opts ReplayOptions) (ReplayReport, error) {
+	var report ReplayReport
+
+	file, err := os.Open(path)
+	if err != nil {
+		return report, err
+	}
+	defer file.Close()
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultReplayBatchSize
+	}
+
+	var seen map[uint64]struct{}
+	if opts.SkipDuplicates {
+		if seen, err = a.storedRecordKeys(); err != nil {
+			return report, err
+		}
+	}
+
+	batch := make([][]byte, 0, batchSize)
+	send := func() {
+		if !opts.DryRun {
+			a.flush(batch)
+		}
+
+		report.Processed += len(batch)
+		batch = batch[:0]
+	}
+
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		if atomic.LoadUint32(&a.shouldStop) > 0 {
+			return report, ErrReplayStopped
+		}
+
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return report, err
+		}
+
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			if record, ok := decodeReplayedRecord(path, line, data, &report); ok {
+				if opts.SkipDuplicates && seenRecord(seen, record) {
+					report.Skipped++
+				} else if encoded, ok := a.encodeReplayedRecord(record, &report); ok {
+					batch = append(batch, encoded)
+				}
+			}
+		}
+
+		if len(batch) == batchSize {
+			send()
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	if len(batch) > 0 {
+		send()
+	}
+
+	return report, nil
+}
+
+func decodeReplayedRecord(path string, line int, data []byte, report *ReplayReport) (*AnalyticsRecord, bool) {
+	var record AnalyticsRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		log.Warnf("Invalid analytics record at %s:%d: %s", path, line, err.Error())
+		report.Errors++
+
+		return nil, false
+	}
+
+	return &record, true
+}
+
+func (r *Analytics) encodeReplayedRecord(record *AnalyticsRecord, report *ReplayReport) ([]byte, bool) {
+	r.capExpiry(record)
+
+	encoded, err := msgpack.Marshal(record)
+	if err != nil {
+		log.Errorf("Error encoding analytics data: %s", err.Error())
+		report.Errors++
+
+		return nil, false
+	}
+
+	return encoded, true
+}
+
+// storedRecordKeys returns the keys of the records stored in redis, see recordKey.
+func (r *Analytics) storedRecordKeys() (map[uint64]struct{}, error) {
+	keys := make(map[uint64]struct{})
+
+	lister, ok := r.store.(recordLister)
+	if _, stream := r.store.(*storage.RedisStream); !ok || stream {
+		return keys, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), recordsFlushTimeout)
+	defer cancel()
+
+	values, err := lister.GetListRange(ctx, analyticsKeyName, 0, -1)
+	if err != nil {
+		return nil, fmt.Errorf("list the stored analytics records failed: %w", err)
+	}
+
+	for _, value := range values {
+		var record AnalyticsRecord
+		if err := msgpack.Unmarshal([]byte(value), &record); err != nil {
+			continue
+		}
+
+		keys[recordKey(&record)] = struct{}{}
+	}
+
+	return keys, nil
+}
+
+// seenRecord returns whether a record with the key of record was seen, and marks it seen otherwise.
+func seenRecord(seen map[uint64]struct{}, record *AnalyticsRecord) bool {
+	key := recordKey(record)
+	if _, ok := seen[key]; ok {
+		return true
+	}
+
+	seen[key] = struct{}{}
+
+	return false
+}
+
+// recordKey returns the hash of the timestamp and the username of record, which identifies the
+// duplicated records.
+func recordKey(record *AnalyticsRecord) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%s", record.TimeStamp, record.Username)
+
+	return h.Sum64()
+}
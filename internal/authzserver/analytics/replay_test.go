@@ -0,0 +1,197 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	msgpack "gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// fakeListStore is an AnalyticsHandler storing the records into a list, like storage.RedisList.
+type fakeListStore struct {
+	list    []string
+	batches []int
+	// onAppend is called after each batch is appended.
+	onAppend func()
+}
+
+func (s *fakeListStore) Connect() bool { return true }
+
+func (s *fakeListStore) AppendToSetPipelined(_ context.Context, _ string, values [][]byte) {
+	for _, value := range values {
+		s.list = append(s.list, string(value))
+	}
+
+	s.batches = append(s.batches, len(values))
+
+	if s.onAppend != nil {
+		s.onAppend()
+	}
+}
+
+func (s *fakeListStore) GetAndDeleteSet(context.Context, string) []interface{} { return nil }
+
+func (s *fakeListStore) SetExp(context.Context, string, time.Duration) error { return nil }
+
+func (s *fakeListStore) GetExp(context.Context, string) (int64, error) { return 0, nil }
+
+func (s *fakeListStore) GetListRange(_ context.Context, _ string, from, to int64) ([]string, error) {
+	return s.list, nil
+}
+
+func writeReplayFile(t *testing.T, lines ...string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "records.json")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	return path
+}
+
+func replayRecord(t *testing.T, timestamp int64, username string) string {
+	t.Helper()
+
+	data, err := json.Marshal(&AnalyticsRecord{TimeStamp: timestamp, Username: username, Effect: "allow"})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	return string(data)
+}
+
+func TestReplayFromFile(t *testing.T) {
+	// 250 records, the record 1/colin is stored already and the record 2/colin is duplicated in the file.
+	lines := []string{replayRecord(t, 1, "colin"), "", "{invalid", replayRecord(t, 2, "colin")}
+	for i := int64(2); i < 250; i++ {
+		lines = append(lines, replayRecord(t, i, "colin"))
+	}
+
+	stored, _ := msgpack.Marshal(&AnalyticsRecord{TimeStamp: 1, Username: "colin"})
+
+	tests := []struct {
+		name        string
+		opts        ReplayOptions
+		wantReport  ReplayReport
+		wantBatches []int
+	}{
+		{
+			name:        "default",
+			wantReport:  ReplayReport{Processed: 250, Errors: 1},
+			wantBatches: []int{100, 100, 50},
+		},
+		{
+			name:        "batch size",
+			opts:        ReplayOptions{BatchSize: 120},
+			wantReport:  ReplayReport{Processed: 250, Errors: 1},
+			wantBatches: []int{120, 120, 10},
+		},
+		{
+			name:        "skip duplicates",
+			opts:        ReplayOptions{SkipDuplicates: true},
+			wantReport:  ReplayReport{Processed: 248, Skipped: 2, Errors: 1},
+			wantBatches: []int{100, 100, 48},
+		},
+		{
+			name:        "skip duplicates with batch size",
+			opts:        ReplayOptions{SkipDuplicates: true, BatchSize: 124},
+			wantReport:  ReplayReport{Processed: 248, Skipped: 2, Errors: 1},
+			wantBatches: []int{124, 124},
+		},
+		{
+			name:       "dry run",
+			opts:       ReplayOptions{DryRun: true},
+			wantReport: ReplayReport{Processed: 250, Errors: 1},
+		},
+		{
+			name:       "dry run with batch size",
+			opts:       ReplayOptions{DryRun: true, BatchSize: 7},
+			wantReport: ReplayReport{Processed: 250, Errors: 1},
+		},
+		{
+			name:       "dry run skipping duplicates",
+			opts:       ReplayOptions{DryRun: true, SkipDuplicates: true},
+			wantReport: ReplayReport{Processed: 248, Skipped: 2, Errors: 1},
+		},
+		{
+			name:       "dry run skipping duplicates with batch size",
+			opts:       ReplayOptions{DryRun: true, SkipDuplicates: true, BatchSize: 7},
+			wantReport: ReplayReport{Processed: 248, Skipped: 2, Errors: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeListStore{list: []string{string(stored)}}
+			a := NewAnalytics(NewAnalyticsOptions(), store)
+
+			report, err := ReplayFromFile(writeReplayFile(t, lines...), a, tt.opts)
+			if err != nil {
+				t.Fatalf("ReplayFromFile() error = %v", err)
+			}
+
+			if report != tt.wantReport {
+				t.Errorf("ReplayFromFile() = %+v, want %+v", report, tt.wantReport)
+			}
+
+			if len(store.batches) != len(tt.wantBatches) {
+				t.Fatalf("batches = %v, want %v", store.batches, tt.wantBatches)
+			}
+
+			for i := range store.batches {
+				if store.batches[i] != tt.wantBatches[i] {
+					t.Errorf("batches = %v, want %v", store.batches, tt.wantBatches)
+				}
+			}
+
+			// the records are stored like the live ones.
+			if !tt.opts.DryRun {
+				var record AnalyticsRecord
+				if err := msgpack.Unmarshal([]byte(store.list[len(store.list)-1]), &record); err != nil ||
+					record.TimeStamp != 249 || record.Username != "colin" {
+					t.Errorf("last stored record = %+v, %v, want 249/colin", record, err)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayFromFile_Stopped(t *testing.T) {
+	lines := make([]string, 0, 30)
+	for i := int64(0); i < 30; i++ {
+		lines = append(lines, replayRecord(t, i, "colin"))
+	}
+
+	store := &fakeListStore{}
+	a := NewAnalytics(NewAnalyticsOptions(), store)
+
+	// the analytics service is shutting down once the first batch is sent.
+	store.onAppend = a.Stop
+
+	report, err := ReplayFromFile(writeReplayFile(t, lines...), a, ReplayOptions{BatchSize: 10})
+	if !errors.Is(err, ErrReplayStopped) {
+		t.Fatalf("ReplayFromFile() error = %v, want %v", err, ErrReplayStopped)
+	}
+
+	if report.Processed != 10 || len(store.list) != 10 {
+		t.Errorf("ReplayFromFile() processed %d records, stored %d, want 10", report.Processed, len(store.list))
+	}
+}
+
+func TestReplayFromFile_MissingFile(t *testing.T) {
+	a := NewAnalytics(NewAnalyticsOptions(), &fakeListStore{})
+
+	if _, err := ReplayFromFile(filepath.Join(t.TempDir(), "missing.json"), a, ReplayOptions{}); err == nil {
+		t.Error("ReplayFromFile() of a missing file succeeded")
+	}
+}
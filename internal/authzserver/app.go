@@ -15,6 +15,11 @@ import (
 const commandDesc = `Authorization server to run ladon policies which can protecting your resources.
 It is written inspired by AWS IAM policiis.
 
+The options are read from the flags, the environment variables and the configuration file, in this
+order of precedence. The environment variable of an option is named after it with the IAM_AUTHZ_SERVER_
+prefix, in upper case with the dots and dashes replaced by underscores, e.g. IAM_AUTHZ_SERVER_REDIS_PASSWORD
+for --redis.password.
+
 Find more iam-authz-server information at:
     https://github.com/marmotedu/iam/blob/master/docs/guide/en-US/cmd/iam-authz-server.md,
 
@@ -8,6 +8,8 @@ import (
 	authzv1 "github.com/marmotedu/api/authz/v1"
 	"github.com/ory/ladon"
 
+	// register the iam specific ladon conditions.
+	_ "github.com/marmotedu/iam/internal/pkg/conditions"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
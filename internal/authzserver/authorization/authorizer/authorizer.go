@@ -69,18 +69,7 @@ func (auth *Authorization) List(username string) ([]*ladon.DefaultPolicy, error)
 
 // LogRejectedAccessRequest write rejected subject access to redis.
 func (auth *Authorization) LogRejectedAccessRequest(r *ladon.Request, p ladon.Policies, d ladon.Policies) {
-	var conclusion string
-	if len(d) > 1 {
-		allowed := joinPoliciesNames(d[0 : len(d)-1])
-		denied := d[len(d)-1].GetID()
-		conclusion = fmt.Sprintf("policies %s allow access, but policy %s forcefully denied it", allowed, denied)
-	} else if len(d) == 1 {
-		denied := d[len(d)-1].GetID()
-		conclusion = fmt.Sprintf("policy %s forcefully denied the access", denied)
-	} else {
-		conclusion = "no policy allowed access"
-	}
-
+	conclusion := rejectedConclusion(d)
 	rstring, pstring, dstring := convertToString(r, p, d)
 	record := analytics.AnalyticsRecord{
 		TimeStamp:  time.Now().Unix(),
@@ -98,7 +87,7 @@ func (auth *Authorization) LogRejectedAccessRequest(r *ladon.Request, p ladon.Po
 
 // LogGrantedAccessRequest write granted subject access to redis.
 func (auth *Authorization) LogGrantedAccessRequest(r *ladon.Request, p ladon.Policies, d ladon.Policies) {
-	conclusion := fmt.Sprintf("policies %s allow access", joinPoliciesNames(d))
+	conclusion := grantedConclusion(d)
 	rstring, pstring, dstring := convertToString(r, p, d)
 	record := analytics.AnalyticsRecord{
 		TimeStamp:  time.Now().Unix(),
@@ -114,6 +103,25 @@ func (auth *Authorization) LogGrantedAccessRequest(r *ladon.Request, p ladon.Pol
 	_ = analytics.GetAnalytics().RecordHit(&record)
 }
 
+func rejectedConclusion(d ladon.Policies) string {
+	if len(d) > 1 {
+		allowed := joinPoliciesNames(d[0 : len(d)-1])
+		denied := d[len(d)-1].GetID()
+
+		return fmt.Sprintf("policies %s allow access, but policy %s forcefully denied it", allowed, denied)
+	}
+
+	if len(d) == 1 {
+		return fmt.Sprintf("policy %s forcefully denied the access", d[0].GetID())
+	}
+
+	return "no policy allowed access"
+}
+
+func grantedConclusion(d ladon.Policies) string {
+	return fmt.Sprintf("policies %s allow access", joinPoliciesNames(d))
+}
+
 func joinPoliciesNames(policies ladon.Policies) string {
 	names := []string{}
 	for _, policy := range policies {
@@ -0,0 +1,68 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authorizer
+
+import (
+	"github.com/ory/ladon"
+)
+
+// Preview implements authorization.AuthorizationInterface interface, it evaluates the requests
+// against the live policies of the user amended with ephemeral policies. Nothing is persisted
+// and no analytics record is written, the deciders are kept in the Preview instead.
+type Preview struct {
+	*Authorization
+	extraPolicies    []*ladon.DefaultPolicy
+	excludePolicyIDs map[string]struct{}
+
+	// Deciders are the policies which made the decision of the last evaluated request.
+	Deciders ladon.Policies
+	// Conclusion explains the decision of the last evaluated request.
+	Conclusion string
+}
+
+// NewPreview create a new Preview instance which adds extraPolicies to the policies
+// returned by getter and removes the ones identified by excludePolicyIDs.
+func NewPreview(getter PolicyGetter, extraPolicies []*ladon.DefaultPolicy, excludePolicyIDs []string) *Preview {
+	exclude := make(map[string]struct{}, len(excludePolicyIDs))
+	for _, id := range excludePolicyIDs {
+		exclude[id] = struct{}{}
+	}
+
+	return &Preview{
+		Authorization:    &Authorization{getter},
+		extraPolicies:    extraPolicies,
+		excludePolicyIDs: exclude,
+	}
+}
+
+// List returns the live policies under the username without the excluded ones, followed by
+// the extra policies.
+func (p *Preview) List(username string) ([]*ladon.DefaultPolicy, error) {
+	policies, err := p.Authorization.List(username)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*ladon.DefaultPolicy, 0, len(policies)+len(p.extraPolicies))
+	for _, policy := range policies {
+		if _, ok := p.excludePolicyIDs[policy.GetID()]; !ok {
+			ret = append(ret, policy)
+		}
+	}
+
+	return append(ret, p.extraPolicies...), nil
+}
+
+// LogRejectedAccessRequest keeps the policies which rejected the access.
+func (p *Preview) LogRejectedAccessRequest(r *ladon.Request, pool ladon.Policies, d ladon.Policies) {
+	p.Deciders = d
+	p.Conclusion = rejectedConclusion(d)
+}
+
+// LogGrantedAccessRequest keeps the policies which granted the access.
+func (p *Preview) LogGrantedAccessRequest(r *ladon.Request, pool ladon.Policies, d ladon.Policies) {
+	p.Deciders = d
+	p.Conclusion = grantedConclusion(d)
+}
@@ -0,0 +1,166 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authorizer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ory/ladon"
+
+	"github.com/marmotedu/iam/internal/authzserver/authorization"
+)
+
+// fakePolicyGetter returns the live policies of the users.
+type fakePolicyGetter map[string][]*ladon.DefaultPolicy
+
+func (f fakePolicyGetter) GetPolicy(key string) ([]*ladon.DefaultPolicy, error) {
+	return f[key], nil
+}
+
+func newPolicy(id, action, effect string) *ladon.DefaultPolicy {
+	return &ladon.DefaultPolicy{
+		ID:        id,
+		Subjects:  []string{"users:colin"},
+		Resources: []string{"resources:articles:<.*>"},
+		Actions:   []string{action},
+		Effect:    effect,
+	}
+}
+
+func policyIDs(policies []*ladon.DefaultPolicy) []string {
+	ids := []string{}
+	for _, policy := range policies {
+		ids = append(ids, policy.GetID())
+	}
+
+	return ids
+}
+
+func TestPreview_List(t *testing.T) {
+	getter := fakePolicyGetter{
+		"colin": {newPolicy("allow-read", "read", ladon.AllowAccess), newPolicy("deny-delete", "delete", ladon.DenyAccess)},
+	}
+
+	tests := []struct {
+		name    string
+		extra   []*ladon.DefaultPolicy
+		exclude []string
+		want    []string
+	}{
+		{name: "live", want: []string{"allow-read", "deny-delete"}},
+		{
+			name:  "add",
+			extra: []*ladon.DefaultPolicy{newPolicy("allow-write", "write", ladon.AllowAccess)},
+			want:  []string{"allow-read", "deny-delete", "allow-write"},
+		},
+		{name: "exclude", exclude: []string{"deny-delete", "unknown"}, want: []string{"allow-read"}},
+		{
+			name:    "combined",
+			extra:   []*ladon.DefaultPolicy{newPolicy("allow-delete", "delete", ladon.AllowAccess)},
+			exclude: []string{"deny-delete"},
+			want:    []string{"allow-read", "allow-delete"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies, err := NewPreview(getter, tt.extra, tt.exclude).List("colin")
+			if err != nil {
+				t.Fatalf("Preview.List() error = %v", err)
+			}
+
+			if got := policyIDs(policies); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Preview.List() = %v, want %v", got, tt.want)
+			}
+
+			// the live policies are left untouched.
+			if got := policyIDs(getter["colin"]); !reflect.DeepEqual(got, []string{"allow-read", "deny-delete"}) {
+				t.Errorf("live policies = %v, want them unchanged", got)
+			}
+		})
+	}
+}
+
+func TestPreview_Authorize(t *testing.T) {
+	getter := fakePolicyGetter{
+		"colin": {newPolicy("allow-read", "read", ladon.AllowAccess), newPolicy("deny-delete", "delete", ladon.DenyAccess)},
+	}
+
+	tests := []struct {
+		name           string
+		action         string
+		extra          []*ladon.DefaultPolicy
+		exclude        []string
+		wantAllowed    bool
+		wantDeciders   []string
+		wantConclusion string
+	}{
+		{
+			name:           "live",
+			action:         "delete",
+			wantDeciders:   []string{"deny-delete"},
+			wantConclusion: "policy deny-delete forcefully denied the access",
+		},
+		{
+			name:           "add allow",
+			action:         "write",
+			extra:          []*ladon.DefaultPolicy{newPolicy("allow-write", "write", ladon.AllowAccess)},
+			wantAllowed:    true,
+			wantDeciders:   []string{"allow-write"},
+			wantConclusion: "policies allow-write allow access",
+		},
+		{
+			name:           "add deny",
+			action:         "read",
+			extra:          []*ladon.DefaultPolicy{newPolicy("deny-read", "read", ladon.DenyAccess)},
+			wantDeciders:   []string{"allow-read", "deny-read"},
+			wantConclusion: "policies allow-read allow access, but policy deny-read forcefully denied it",
+		},
+		{
+			name:           "exclude",
+			action:         "delete",
+			exclude:        []string{"deny-delete"},
+			wantDeciders:   []string{},
+			wantConclusion: "no policy allowed access",
+		},
+		{
+			name:           "combined",
+			action:         "delete",
+			extra:          []*ladon.DefaultPolicy{newPolicy("allow-delete", "delete", ladon.AllowAccess)},
+			exclude:        []string{"deny-delete"},
+			wantAllowed:    true,
+			wantDeciders:   []string{"allow-delete"},
+			wantConclusion: "policies allow-delete allow access",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preview := NewPreview(getter, tt.extra, tt.exclude)
+			rsp := authorization.NewAuthorizer(preview).Authorize(&ladon.Request{
+				Subject:  "users:colin",
+				Action:   tt.action,
+				Resource: "resources:articles:ladon-introduction",
+				Context:  ladon.Context{"username": "colin"},
+			})
+
+			if rsp.Allowed != tt.wantAllowed || rsp.Denied == tt.wantAllowed {
+				t.Errorf("Authorize() = %v, want allowed %v", rsp, tt.wantAllowed)
+			}
+
+			deciders := []string{}
+			for _, policy := range preview.Deciders {
+				deciders = append(deciders, policy.GetID())
+			}
+
+			if !reflect.DeepEqual(deciders, tt.wantDeciders) {
+				t.Errorf("Preview.Deciders = %v, want %v", deciders, tt.wantDeciders)
+			}
+
+			if preview.Conclusion != tt.wantConclusion {
+				t.Errorf("Preview.Conclusion = %q, want %q", preview.Conclusion, tt.wantConclusion)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authorization
+
+import (
+	"fmt"
+	"testing"
+
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+
+	"github.com/marmotedu/iam/internal/authzserver/load/cache"
+	"github.com/marmotedu/iam/internal/authzserver/store"
+)
+
+// The benchmarks guard the policy evaluation hot path against regressions, compare the
+// results before and after a change with:
+//
+//	go test -run=^$ -bench=. -count=10 ./internal/authzserver/authorization/ > new.txt
+//	benchstat old.txt new.txt
+
+const benchUsers = 100
+
+// benchPolicy returns a realistic policy of the i-th user: regex subjects, resources and
+// actions, restricted to the intranet.
+func benchPolicy(i int) *ladon.DefaultPolicy {
+	return &ladon.DefaultPolicy{
+		ID:          fmt.Sprintf("policy-%d", i),
+		Description: "Allow the user to manage its articles.",
+		Subjects:    []string{fmt.Sprintf("users:<user%d|admin>", i), "groups:editors"},
+		Resources:   []string{fmt.Sprintf("resources:articles:user%d:<.*>", i), "resources:printer"},
+		Actions:     []string{"delete", "<create|update|get>"},
+		Effect:      ladon.AllowAccess,
+		Conditions:  ladon.Conditions{"remoteIPAddress": &ladon.CIDRCondition{CIDR: "192.168.0.1/16"}},
+	}
+}
+
+func benchRequest(i int) *ladon.Request {
+	return &ladon.Request{
+		Subject:  fmt.Sprintf("users:user%d", i),
+		Resource: fmt.Sprintf("resources:articles:user%d:ladon-introduction", i),
+		Action:   "update",
+		Context: ladon.Context{
+			"username":        fmt.Sprintf("user%d", i),
+			"remoteIPAddress": "192.168.0.5",
+		},
+	}
+}
+
+// newMemoryAuthorizer returns an authorizer evaluating the given policies kept in an
+// in-memory ladon manager.
+func newMemoryAuthorizer(b *testing.B, policies ...*ladon.DefaultPolicy) *Authorizer {
+	b.Helper()
+
+	manager := memory.NewMemoryManager()
+	for _, policy := range policies {
+		if err := manager.Create(policy); err != nil {
+			b.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	return &Authorizer{
+		warden: &ladon.Ladon{
+			Manager:     manager,
+			AuditLogger: &ladon.AuditLoggerNoOp{},
+		},
+	}
+}
+
+func benchmarkAuthorize(b *testing.B, a *Authorizer, r *ladon.Request, allowed bool) {
+	b.Helper()
+
+	if got := a.Authorize(r); got.Allowed != allowed {
+		b.Fatalf("Authorize() = %+v, want allowed %t", got, allowed)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		a.Authorize(r)
+	}
+}
+
+func BenchmarkAuthorizer_Authorize_Allow_1Policy(b *testing.B) {
+	benchmarkAuthorize(b, newMemoryAuthorizer(b, benchPolicy(0)), benchRequest(0), true)
+}
+
+func BenchmarkAuthorizer_Authorize_Deny_1Policy(b *testing.B) {
+	policy := benchPolicy(0)
+	policy.Effect = ladon.DenyAccess
+
+	benchmarkAuthorize(b, newMemoryAuthorizer(b, policy), benchRequest(0), false)
+}
+
+func BenchmarkAuthorizer_Authorize_100Policies_NoMatch(b *testing.B) {
+	policies := make([]*ladon.DefaultPolicy, 0, benchUsers)
+	for i := 0; i < benchUsers; i++ {
+		policies = append(policies, benchPolicy(i))
+	}
+
+	// no policy is granted to the user.
+	benchmarkAuthorize(b, newMemoryAuthorizer(b, policies...), benchRequest(benchUsers), false)
+}
+
+func BenchmarkAuthorizer_Authorize_Parallel(b *testing.B) {
+	policies := make([]*ladon.DefaultPolicy, 0, benchUsers)
+	for i := 0; i < benchUsers; i++ {
+		policies = append(policies, benchPolicy(i))
+	}
+
+	a := newMemoryAuthorizer(b, policies...)
+	requests := make([]*ladon.Request, 0, benchUsers)
+
+	for i := 0; i < benchUsers; i++ {
+		requests = append(requests, benchRequest(i))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if got := a.Authorize(requests[i%benchUsers]); !got.Allowed {
+				b.Errorf("Authorize() = %+v, want allowed", got)
+			}
+			i++
+		}
+	})
+}
+
+// benchStore serves one policy per user to the authorization cache.
+type benchStore struct{}
+
+func (benchStore) Policies() store.PolicyStore { return benchStore{} }
+func (benchStore) Secrets() store.SecretStore  { return benchSecretStore{} }
+func (benchStore) Watcher() store.WatchStore   { return nil }
+
+func (benchStore) List() (map[string][]*ladon.DefaultPolicy, int, error) {
+	policies := make(map[string][]*ladon.DefaultPolicy, benchUsers)
+	for i := 0; i < benchUsers; i++ {
+		policies[fmt.Sprintf("user%d", i)] = []*ladon.DefaultPolicy{benchPolicy(i)}
+	}
+
+	return policies, 0, nil
+}
+
+type benchSecretStore struct{}
+
+func (benchSecretStore) List() (map[string]*pb.SecretInfo, int, error) {
+	return map[string]*pb.SecretInfo{}, 0, nil
+}
+
+// cacheAuthorization lists the policies from the authorization cache, the same as the
+// authorization client used by iam-authz-server, without recording analytics.
+type cacheAuthorization struct {
+	*cache.Cache
+}
+
+func (a cacheAuthorization) Create(*ladon.DefaultPolicy) error {
+	return nil
+}
+
+func (a cacheAuthorization) Update(*ladon.DefaultPolicy) error {
+	return nil
+}
+
+func (a cacheAuthorization) Delete(id string) error {
+	return nil
+}
+
+func (a cacheAuthorization) DeleteCollection(idList []string) error {
+	return nil
+}
+
+func (a cacheAuthorization) Get(id string) (*ladon.DefaultPolicy, error) {
+	return &ladon.DefaultPolicy{}, nil
+}
+
+func (a cacheAuthorization) List(username string) ([]*ladon.DefaultPolicy, error) {
+	return a.GetPolicy(username)
+}
+
+func (a cacheAuthorization) LogRejectedAccessRequest(*ladon.Request, ladon.Policies, ladon.Policies) {
+}
+
+func (a cacheAuthorization) LogGrantedAccessRequest(*ladon.Request, ladon.Policies, ladon.Policies) {
+}
+
+// newCachingAuthorizer returns an authorizer which finds the request candidates in the
+// authorization cache loaded with one policy per user.
+func newCachingAuthorizer(b *testing.B) *Authorizer {
+	b.Helper()
+
+	cacheIns, _ := cache.GetCacheInsOr(benchStore{})
+	if _, err := cacheIns.Reload(false); err != nil {
+		b.Fatalf("Reload() error = %v", err)
+	}
+
+	return NewAuthorizer(cacheAuthorization{cacheIns})
+}
+
+func BenchmarkCachingAuthorizer_CacheHit(b *testing.B) {
+	benchmarkAuthorize(b, newCachingAuthorizer(b), benchRequest(0), true)
+}
+
+func BenchmarkCachingAuthorizer_CacheMiss(b *testing.B) {
+	// the user has no policy in the cache.
+	benchmarkAuthorize(b, newCachingAuthorizer(b), benchRequest(benchUsers), false)
+}
@@ -0,0 +1,144 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authorization
+
+import (
+	"sort"
+
+	authzv1 "github.com/marmotedu/api/authz/v1"
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/marmotedu/errors"
+	"github.com/ory/ladon"
+)
+
+// ConditionTrace is the evaluation of a condition of a policy.
+type ConditionTrace struct {
+	// Key is the key of the request context whose value is checked by the condition.
+	Key  string `json:"key"`
+	Type string `json:"type"`
+	// Input is the value of Key in the request context.
+	Input interface{} `json:"input"`
+	// Expected are the options of the condition, the value of its only option if it has one.
+	Expected interface{} `json:"expected"`
+	Matched  bool        `json:"matched"`
+}
+
+// PolicyTrace is the evaluation of a policy against a request.
+type PolicyTrace struct {
+	PolicyID        string           `json:"policyID"`
+	Effect          string           `json:"effect"`
+	SubjectMatched  bool             `json:"subjectMatched"`
+	ResourceMatched bool             `json:"resourceMatched"`
+	ActionMatched   bool             `json:"actionMatched"`
+	Conditions      []ConditionTrace `json:"conditions"`
+	// Matched is true if the policy applies to the request: its subjects, resources and actions
+	// match and all its conditions are fulfilled.
+	Matched bool `json:"matched"`
+	// Decider is true if the policy is one of the policies which made the decision.
+	Decider bool `json:"decider"`
+	// Error is set if the subjects, resources or actions of the policy could not be matched.
+	Error string `json:"error,omitempty"`
+}
+
+// Trace authorizes the request like Authorize, and returns how each policy of the subject was
+// evaluated. Unlike the evaluation of the decision, which stops at the first check failing, all
+// the checks of all the policies are traced.
+func (a *Authorizer) Trace(request *ladon.Request) (*authzv1.Response, []PolicyTrace, error) {
+	warden, ok := a.warden.(*ladon.Ladon)
+	if !ok {
+		return nil, nil, errors.New("the authorizer does not support tracing")
+	}
+
+	policies, err := warden.Manager.FindRequestCandidates(request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	matcher := warden.Matcher
+	if matcher == nil {
+		matcher = ladon.DefaultMatcher
+	}
+
+	traces := make([]PolicyTrace, 0, len(policies))
+	// denied is true once a deny policy matched, the following policies do not decide.
+	denied := false
+
+	for _, policy := range policies {
+		trace := PolicyTrace{
+			PolicyID:   policy.GetID(),
+			Effect:     policy.GetEffect(),
+			Conditions: []ConditionTrace{},
+		}
+
+		var errs []error
+
+		trace.SubjectMatched, err = matcher.Matches(policy, policy.GetSubjects(), request.Subject)
+		errs = append(errs, err)
+		trace.ResourceMatched, err = matcher.Matches(policy, policy.GetResources(), request.Resource)
+		errs = append(errs, err)
+		trace.ActionMatched, err = matcher.Matches(policy, policy.GetActions(), request.Action)
+		errs = append(errs, err)
+
+		if err := errors.NewAggregate(errs); err != nil {
+			trace.Error = err.Error()
+		}
+
+		trace.Matched = trace.SubjectMatched && trace.ResourceMatched && trace.ActionMatched
+
+		conditions := policy.GetConditions()
+
+		keys := make([]string, 0, len(conditions))
+		for key := range conditions {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			condition := conditions[key]
+			matched := condition.Fulfills(request.Context[key], request)
+
+			trace.Conditions = append(trace.Conditions, ConditionTrace{
+				Key:      key,
+				Type:     condition.GetName(),
+				Input:    request.Context[key],
+				Expected: conditionOptions(condition),
+				Matched:  matched,
+			})
+
+			trace.Matched = trace.Matched && matched
+		}
+
+		if trace.Matched && !denied {
+			trace.Decider = true
+			denied = !policy.AllowAccess()
+		}
+
+		traces = append(traces, trace)
+	}
+
+	return a.Authorize(request), traces, nil
+}
+
+// conditionOptions returns the options of the condition, the value of its only option if it has one.
+func conditionOptions(condition ladon.Condition) interface{} {
+	data, err := json.Marshal(condition)
+	if err != nil {
+		return nil
+	}
+
+	var options map[string]interface{}
+	if err := json.Unmarshal(data, &options); err != nil || len(options) == 0 {
+		return nil
+	}
+
+	if len(options) == 1 {
+		for _, value := range options {
+			return value
+		}
+	}
+
+	return options
+}
@@ -0,0 +1,164 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authorization
+
+import (
+	"reflect"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	authzv1 "github.com/marmotedu/api/authz/v1"
+	"github.com/ory/ladon"
+)
+
+func TestAuthorizer_Trace(t *testing.T) {
+	officePolicy := &ladon.DefaultPolicy{
+		ID:        "office",
+		Subjects:  []string{"users:<peter|maria>"},
+		Resources: []string{"resources:articles:<.*>"},
+		Actions:   []string{"delete", "update"},
+		Effect:    ladon.AllowAccess,
+		Conditions: ladon.Conditions{
+			"remoteIPAddress": &ladon.CIDRCondition{CIDR: "10.0.0.0/8"},
+			"owner":           &ladon.StringEqualCondition{Equals: "peter"},
+		},
+	}
+	readPolicy := &ladon.DefaultPolicy{
+		ID:        "read",
+		Subjects:  []string{"users:<.*>"},
+		Resources: []string{"resources:articles:<.*>"},
+		Actions:   []string{"read"},
+		Effect:    ladon.AllowAccess,
+	}
+	lockPolicy := &ladon.DefaultPolicy{
+		ID:        "lock",
+		Subjects:  []string{"users:<.*>"},
+		Resources: []string{"resources:articles:<.*>"},
+		Actions:   []string{"<.*>"},
+		Effect:    ladon.DenyAccess,
+		Conditions: ladon.Conditions{
+			"locked": &ladon.BooleanCondition{BooleanValue: true},
+		},
+	}
+
+	request := func(ip, owner string, locked bool) *ladon.Request {
+		return &ladon.Request{
+			Subject:  "users:peter",
+			Action:   "delete",
+			Resource: "resources:articles:ladon-introduction",
+			Context: ladon.Context{
+				"username":        "peter",
+				"remoteIPAddress": ip,
+				"owner":           owner,
+				"locked":          locked,
+			},
+		}
+	}
+
+	officeTrace := func(ip, owner string, ipMatched, ownerMatched, decider bool) PolicyTrace {
+		return PolicyTrace{
+			PolicyID:        "office",
+			Effect:          ladon.AllowAccess,
+			SubjectMatched:  true,
+			ResourceMatched: true,
+			ActionMatched:   true,
+			Conditions: []ConditionTrace{
+				{Key: "owner", Type: "StringEqualCondition", Input: owner, Expected: "peter", Matched: ownerMatched},
+				{
+					Key: "remoteIPAddress", Type: "CIDRCondition", Input: ip, Expected: "10.0.0.0/8",
+					Matched: ipMatched,
+				},
+			},
+			Matched: ipMatched && ownerMatched,
+			Decider: decider,
+		}
+	}
+	readTrace := PolicyTrace{
+		PolicyID:        "read",
+		Effect:          ladon.AllowAccess,
+		SubjectMatched:  true,
+		ResourceMatched: true,
+		Conditions:      []ConditionTrace{},
+	}
+	lockTrace := func(locked, decider bool) PolicyTrace {
+		return PolicyTrace{
+			PolicyID:        "lock",
+			Effect:          ladon.DenyAccess,
+			SubjectMatched:  true,
+			ResourceMatched: true,
+			ActionMatched:   true,
+			Conditions: []ConditionTrace{
+				{Key: "locked", Type: "BooleanCondition", Input: locked, Expected: true, Matched: locked},
+			},
+			Matched: locked,
+			Decider: decider,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		policies  []*ladon.DefaultPolicy
+		request   *ladon.Request
+		want      *authzv1.Response
+		wantTrace []PolicyTrace
+	}{
+		{
+			name:      "one condition failed",
+			policies:  []*ladon.DefaultPolicy{officePolicy, readPolicy},
+			request:   request("1.2.3.4", "peter", false),
+			want:      &authzv1.Response{Denied: true, Reason: "Request was denied by default"},
+			wantTrace: []PolicyTrace{officeTrace("1.2.3.4", "peter", false, true, false), readTrace},
+		},
+		{
+			name:      "all conditions failed",
+			policies:  []*ladon.DefaultPolicy{officePolicy},
+			request:   request("1.2.3.4", "maria", false),
+			want:      &authzv1.Response{Denied: true, Reason: "Request was denied by default"},
+			wantTrace: []PolicyTrace{officeTrace("1.2.3.4", "maria", false, false, false)},
+		},
+		{
+			name:     "all conditions fulfilled",
+			policies: []*ladon.DefaultPolicy{officePolicy, readPolicy, lockPolicy},
+			request:  request("10.1.2.3", "peter", false),
+			want:     &authzv1.Response{Allowed: true},
+			wantTrace: []PolicyTrace{
+				officeTrace("10.1.2.3", "peter", true, true, true), readTrace, lockTrace(false, false),
+			},
+		},
+		{
+			name:     "forcefully denied",
+			policies: []*ladon.DefaultPolicy{officePolicy, lockPolicy},
+			request:  request("10.1.2.3", "peter", true),
+			want:     &authzv1.Response{Denied: true, Reason: "Request was forcefully denied"},
+			wantTrace: []PolicyTrace{
+				officeTrace("10.1.2.3", "peter", true, true, true), lockTrace(true, true),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockAuthz := NewMockAuthorizationInterface(ctrl)
+			mockAuthz.EXPECT().List(gomock.Eq("peter")).AnyTimes().Return(tt.policies, nil)
+			mockAuthz.EXPECT().LogRejectedAccessRequest(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+			mockAuthz.EXPECT().LogGrantedAccessRequest(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+
+			got, trace, err := NewAuthorizer(mockAuthz).Trace(tt.request)
+			if err != nil {
+				t.Fatalf("Authorizer.Trace() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Authorizer.Trace() response = %v, want %v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(trace, tt.wantTrace) {
+				t.Errorf("Authorizer.Trace() trace = %+v, want %+v", trace, tt.wantTrace)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package analytics implements the handlers to stream the authorization analytics records.
+package analytics
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/ory/ladon"
+
+	"github.com/marmotedu/iam/internal/authzserver/analytics"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+const (
+	// subscribeTimeout is the time given to the client to send its subscription.
+	subscribeTimeout = 10 * time.Second
+	// writeTimeout is the maximum duration of sending a record.
+	writeTimeout = 10 * time.Second
+	// subscriptionBuffer is the number of records queued for a client before it is disconnected.
+	subscriptionBuffer = 256
+)
+
+// WSController streams the authorization analytics records over websocket.
+type WSController struct {
+	broadcaster    *analytics.Broadcaster
+	maxConnections int64
+	connections    int64
+	upgrader       websocket.Upgrader
+}
+
+// NewWSController creates a websocket handler, up to maxConnections clients are served
+// at the same time.
+func NewWSController(broadcaster *analytics.Broadcaster, maxConnections int) *WSController {
+	return &WSController{
+		broadcaster:    broadcaster,
+		maxConnections: int64(maxConnections),
+		upgrader: websocket.Upgrader{
+			// the clients are authenticated with a bearer token instead of cookies, so the
+			// connections can not be forged by other sites.
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// Stream upgrades the request to a websocket connection. The client first sends a JSON
+// subscription filter, e.g. {"username": "colin", "effect": "deny"}, then receives the
+// matching records as JSON messages until it disconnects. A client can only subscribe to
+// the records of its own user.
+func (w *WSController) Stream(c *gin.Context) {
+	if atomic.AddInt64(&w.connections, 1) > w.maxConnections {
+		atomic.AddInt64(&w.connections, -1)
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"code":    http.StatusTooManyRequests,
+			"message": "Too many websocket connections",
+		})
+
+		return
+	}
+	defer atomic.AddInt64(&w.connections, -1)
+
+	conn, err := w.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader has replied with an http error.
+		log.L(c).Warnf("upgrade to websocket failed: %s", err.Error())
+
+		return
+	}
+	defer conn.Close()
+
+	filter, ok := w.readFilter(conn, c.GetString(middleware.UsernameKey))
+	if !ok {
+		return
+	}
+
+	sub := w.broadcaster.Subscribe(filter, subscriptionBuffer)
+	defer w.broadcaster.Unsubscribe(sub)
+
+	log.L(c).Infow("websocket subscribed", "username", filter.Username, "effect", filter.Effect)
+
+	// the client is not expected to send anything else, reading detects the disconnection.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case record, ok := <-sub.C:
+			if !ok {
+				closeWith(conn, websocket.CloseTryAgainLater, "subscriber is too slow")
+
+				return
+			}
+
+			_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := conn.WriteJSON(record); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readFilter reads the subscription of the client authenticated as username, the connection
+// is closed if the subscription is invalid.
+func (w *WSController) readFilter(conn *websocket.Conn, username string) (analytics.Filter, bool) {
+	var filter analytics.Filter
+
+	_ = conn.SetReadDeadline(time.Now().Add(subscribeTimeout))
+	if err := conn.ReadJSON(&filter); err != nil {
+		closeWith(conn, websocket.CloseUnsupportedData, "invalid subscription filter")
+
+		return filter, false
+	}
+
+	_ = conn.SetReadDeadline(time.Time{})
+
+	if filter.Username == "" {
+		filter.Username = username
+	}
+
+	if filter.Username != username {
+		closeWith(conn, websocket.ClosePolicyViolation, "can only subscribe to the records of "+username)
+
+		return filter, false
+	}
+
+	if filter.Effect != "" && filter.Effect != ladon.AllowAccess && filter.Effect != ladon.DenyAccess {
+		closeWith(conn, websocket.CloseUnsupportedData, "effect must be allow or deny")
+
+		return filter, false
+	}
+
+	return filter, true
+}
+
+func closeWith(conn *websocket.Conn, code int, text string) {
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(writeTimeout))
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package analytics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/marmotedu/iam/internal/authzserver/analytics"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+)
+
+// newTestServer serves the websocket handler to clients authenticated as colin.
+func newTestServer(w *WSController) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+
+	g := gin.New()
+	g.GET("/v1/ws/analytics", func(c *gin.Context) {
+		c.Set(middleware.UsernameKey, "colin")
+	}, w.Stream)
+
+	return httptest.NewServer(g)
+}
+
+func dial(t *testing.T, s *httptest.Server) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+
+	return websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(s.URL, "http")+"/v1/ws/analytics", nil)
+}
+
+// waitSubscribed waits until the broadcaster has n subscriptions.
+func waitSubscribed(t *testing.T, b *analytics.Broadcaster, n int) {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		if b.Len() == n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("broadcaster has %d subscriptions, want %d", b.Len(), n)
+}
+
+func TestWSController_Stream(t *testing.T) {
+	b := analytics.NewBroadcaster()
+	s := newTestServer(NewWSController(b, 10))
+	defer s.Close()
+
+	conn, _, err := dial(t, s)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON(analytics.Filter{Effect: "deny"}); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	waitSubscribed(t, b, 1)
+
+	b.Publish(&analytics.AnalyticsRecord{Username: "colin", Effect: "allow", Policies: "allowed"})
+	b.Publish(&analytics.AnalyticsRecord{Username: "admin", Effect: "deny", Policies: "other user"})
+	b.Publish(&analytics.AnalyticsRecord{Username: "colin", Effect: "deny", Policies: "denied"})
+
+	var got analytics.AnalyticsRecord
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := conn.ReadJSON(&got); err != nil {
+		t.Fatalf("ReadJSON() error = %v", err)
+	}
+
+	if got.Username != "colin" || got.Effect != "deny" || got.Policies != "denied" {
+		t.Errorf("ReadJSON() = %+v, want the denied record of colin", got)
+	}
+
+	conn.Close()
+	waitSubscribed(t, b, 0)
+}
+
+func TestWSController_Stream_InvalidFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   interface{}
+		wantCode int
+	}{
+		{
+			name:     "other user",
+			filter:   analytics.Filter{Username: "admin"},
+			wantCode: websocket.ClosePolicyViolation,
+		},
+		{
+			name:     "invalid effect",
+			filter:   analytics.Filter{Effect: "maybe"},
+			wantCode: websocket.CloseUnsupportedData,
+		},
+		{
+			name:     "not a filter",
+			filter:   []string{"colin"},
+			wantCode: websocket.CloseUnsupportedData,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := analytics.NewBroadcaster()
+			s := newTestServer(NewWSController(b, 10))
+			defer s.Close()
+
+			conn, _, err := dial(t, s)
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			defer conn.Close()
+
+			if err := conn.WriteJSON(tt.filter); err != nil {
+				t.Fatalf("WriteJSON() error = %v", err)
+			}
+
+			_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			_, _, err = conn.ReadMessage()
+			if !websocket.IsCloseError(err, tt.wantCode) {
+				t.Errorf("ReadMessage() error = %v, want close code %d", err, tt.wantCode)
+			}
+
+			if b.Len() != 0 {
+				t.Errorf("broadcaster has %d subscriptions, want none", b.Len())
+			}
+		})
+	}
+}
+
+func TestWSController_Stream_MaxConnections(t *testing.T) {
+	b := analytics.NewBroadcaster()
+	s := newTestServer(NewWSController(b, 1))
+	defer s.Close()
+
+	conn, _, err := dial(t, s)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+
+	_, resp, err := dial(t, s)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Dial() = %v, %v, want %d", resp, err, http.StatusTooManyRequests)
+	}
+	resp.Body.Close()
+
+	// the connection is released on disconnect.
+	conn.Close()
+
+	for i := 0; i < 100; i++ {
+		if conn, _, err = dial(t, s); err == nil {
+			conn.Close()
+
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("Dial() error = %v, want the closed connection released", err)
+}
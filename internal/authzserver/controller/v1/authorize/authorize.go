@@ -7,6 +7,7 @@ package authorize
 
 import (
 	"github.com/gin-gonic/gin"
+	authzv1 "github.com/marmotedu/api/authz/v1"
 	"github.com/marmotedu/component-base/pkg/core"
 	"github.com/marmotedu/errors"
 	"github.com/ory/ladon"
@@ -14,6 +15,7 @@ import (
 	"github.com/marmotedu/iam/internal/authzserver/authorization"
 	"github.com/marmotedu/iam/internal/authzserver/authorization/authorizer"
 	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
 )
 
 // AuthzController create a authorize handler used to handle authorize request.
@@ -33,7 +35,7 @@ func NewAuthzController(store authorizer.PolicyGetter) *AuthzController {
 func (a *AuthzController) Authorize(c *gin.Context) {
 	var r ladon.Request
 	if err := c.ShouldBind(&r); err != nil {
-		core.WriteResponse(c, errors.WithCode(code.ErrBind, err.Error()), nil)
+		core.WriteResponse(c, middleware.BindError(c, err), nil)
 
 		return
 	}
@@ -48,3 +50,92 @@ func (a *AuthzController) Authorize(c *gin.Context) {
 
 	core.WriteResponse(c, nil, rsp)
 }
+
+// PreviewRequest is the request of the authorization preview, Request is evaluated against the
+// live policies of the user with ExtraPolicies added and ExcludePolicyIDs removed.
+type PreviewRequest struct {
+	Request          *ladon.Request         `json:"request"`
+	ExtraPolicies    []*ladon.DefaultPolicy `json:"extraPolicies,omitempty"`
+	ExcludePolicyIDs []string               `json:"excludePolicyIDs,omitempty"`
+}
+
+// PreviewResponse is the response of the authorization preview.
+type PreviewResponse struct {
+	*authzv1.Response
+	// Deciders are the IDs of the policies which caused the decision.
+	Deciders   []string `json:"deciders"`
+	Conclusion string   `json:"conclusion,omitempty"`
+}
+
+// Preview returns whether a request would be allowed or denied if the given policies were added
+// to or removed from the policies of the user, and which policies would cause the decision.
+// Nothing is persisted and no analytics record is written.
+func (a *AuthzController) Preview(c *gin.Context) {
+	var r PreviewRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, middleware.BindError(c, err), nil)
+
+		return
+	}
+
+	if r.Request == nil {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "request is required"), nil)
+
+		return
+	}
+
+	preview := authorizer.NewPreview(a.store, r.ExtraPolicies, r.ExcludePolicyIDs)
+	auth := authorization.NewAuthorizer(preview)
+	if r.Request.Context == nil {
+		r.Request.Context = ladon.Context{}
+	}
+
+	r.Request.Context["username"] = c.GetString("username")
+	rsp := &PreviewResponse{
+		Response: auth.Authorize(r.Request),
+		Deciders: []string{},
+	}
+
+	for _, policy := range preview.Deciders {
+		rsp.Deciders = append(rsp.Deciders, policy.GetID())
+	}
+
+	rsp.Conclusion = preview.Conclusion
+
+	core.WriteResponse(c, nil, rsp)
+}
+
+// TraceResponse is the response of the authorization trace.
+type TraceResponse struct {
+	*authzv1.Response
+	// Trace is the evaluation of each policy of the user, in the order they were evaluated.
+	Trace []authorization.PolicyTrace `json:"trace"`
+}
+
+// Trace returns whether a request is allowed or denied along with how each policy of the user was
+// evaluated: whether its subjects, resources and actions matched and, for each of its conditions,
+// the value checked, the expected one and whether it matched. No analytics record is written.
+func (a *AuthzController) Trace(c *gin.Context) {
+	var r ladon.Request
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, middleware.BindError(c, err), nil)
+
+		return
+	}
+
+	auth := authorization.NewAuthorizer(authorizer.NewPreview(a.store, nil, nil))
+	if r.Context == nil {
+		r.Context = ladon.Context{}
+	}
+
+	r.Context["username"] = c.GetString("username")
+
+	rsp, trace, err := auth.Trace(&r)
+	if err != nil {
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, err.Error()), nil)
+
+		return
+	}
+
+	core.WriteResponse(c, nil, &TraceResponse{Response: rsp, Trace: trace})
+}
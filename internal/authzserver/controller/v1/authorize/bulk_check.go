@@ -0,0 +1,104 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authorize
+
+import (
+	"github.com/gin-gonic/gin"
+	authzv1 "github.com/marmotedu/api/authz/v1"
+	"github.com/marmotedu/component-base/pkg/core"
+	"github.com/marmotedu/errors"
+	"github.com/ory/ladon"
+
+	"github.com/marmotedu/iam/internal/authzserver/authorization"
+	"github.com/marmotedu/iam/internal/authzserver/authorization/authorizer"
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+)
+
+// BulkCheckRequest is the request of the bulk check, Requests are evaluated in order.
+type BulkCheckRequest struct {
+	Requests []*ladon.Request `json:"requests"`
+	// StopOnFirstDeny stops the evaluation at the first request denied.
+	StopOnFirstDeny bool `json:"stopOnFirstDeny"`
+}
+
+// BulkCheckResponse is the response of the bulk check.
+type BulkCheckResponse struct {
+	// Results are the responses of the requests evaluated, in the order of the requests. The requests
+	// after the first one denied are not evaluated if StopOnFirstDeny is set.
+	Results    []*authzv1.Response `json:"results"`
+	AllAllowed bool                `json:"allAllowed"`
+	// FirstDeniedIndex is the index of the first request denied, -1 if all the requests are allowed.
+	FirstDeniedIndex int `json:"firstDeniedIndex"`
+}
+
+// requestAuthorizer authorizes a request, e.g. authorization.Authorizer.
+type requestAuthorizer interface {
+	Authorize(request *ladon.Request) *authzv1.Response
+}
+
+// BulkCheck returns whether each of the requests is allowed or denied. Unlike a batch evaluated
+// concurrently, the requests are evaluated one after the other within the call, which saves the round
+// trips of a caller checking a chain of permissions before proceeding.
+func (a *AuthzController) BulkCheck(c *gin.Context) {
+	var r BulkCheckRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, middleware.BindError(c, err), nil)
+
+		return
+	}
+
+	if len(r.Requests) == 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "requests is required"), nil)
+
+		return
+	}
+
+	for i, request := range r.Requests {
+		if request == nil {
+			core.WriteResponse(c, errors.WithCode(code.ErrValidation, "requests[%d] is null", i), nil)
+
+			return
+		}
+
+		if request.Context == nil {
+			request.Context = ladon.Context{}
+		}
+
+		request.Context["username"] = c.GetString("username")
+	}
+
+	auth := authorization.NewAuthorizer(authorizer.NewAuthorization(a.store))
+
+	core.WriteResponse(c, nil, bulkCheck(auth, &r))
+}
+
+func bulkCheck(auth requestAuthorizer, r *BulkCheckRequest) *BulkCheckResponse {
+	rsp := &BulkCheckResponse{
+		Results:          make([]*authzv1.Response, 0, len(r.Requests)),
+		AllAllowed:       true,
+		FirstDeniedIndex: -1,
+	}
+
+	for i, request := range r.Requests {
+		result := auth.Authorize(request)
+		rsp.Results = append(rsp.Results, result)
+
+		if result.Allowed {
+			continue
+		}
+
+		if rsp.AllAllowed {
+			rsp.AllAllowed = false
+			rsp.FirstDeniedIndex = i
+		}
+
+		if r.StopOnFirstDeny {
+			break
+		}
+	}
+
+	return rsp
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authorize
+
+import (
+	"testing"
+
+	authzv1 "github.com/marmotedu/api/authz/v1"
+	"github.com/ory/ladon"
+)
+
+// fakeAuthorizer denies the delete requests and records the actions of the requests evaluated.
+type fakeAuthorizer struct {
+	evaluated []string
+}
+
+func (f *fakeAuthorizer) Authorize(request *ladon.Request) *authzv1.Response {
+	f.evaluated = append(f.evaluated, request.Action)
+
+	if request.Action == "delete" {
+		return &authzv1.Response{Denied: true, Reason: "Request was denied by default"}
+	}
+
+	return &authzv1.Response{Allowed: true}
+}
+
+func bulkRequests(actions ...string) []*ladon.Request {
+	requests := make([]*ladon.Request, 0, len(actions))
+	for _, action := range actions {
+		requests = append(requests, &ladon.Request{Subject: "users:colin", Resource: "articles:1", Action: action})
+	}
+
+	return requests
+}
+
+func TestBulkCheck(t *testing.T) {
+	tests := []struct {
+		name            string
+		actions         []string
+		stopOnFirstDeny bool
+		wantEvaluated   int
+		wantAllAllowed  bool
+		wantFirstDenied int
+	}{
+		{
+			name:            "all allowed",
+			actions:         []string{"read", "write", "read", "write", "read"},
+			wantEvaluated:   5,
+			wantAllAllowed:  true,
+			wantFirstDenied: -1,
+		},
+		{
+			name:            "all allowed stopping on first deny",
+			actions:         []string{"read", "write", "read", "write", "read"},
+			stopOnFirstDeny: true,
+			wantEvaluated:   5,
+			wantAllAllowed:  true,
+			wantFirstDenied: -1,
+		},
+		{
+			name:            "denied",
+			actions:         []string{"read", "delete", "write", "delete", "read"},
+			wantEvaluated:   5,
+			wantFirstDenied: 1,
+		},
+		{
+			name:            "stop on first deny",
+			actions:         []string{"read", "delete", "write", "delete", "read"},
+			stopOnFirstDeny: true,
+			wantEvaluated:   2,
+			wantFirstDenied: 1,
+		},
+		{
+			name:            "stop on first deny at the last request",
+			actions:         []string{"read", "write", "read", "write", "delete"},
+			stopOnFirstDeny: true,
+			wantEvaluated:   5,
+			wantFirstDenied: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := &fakeAuthorizer{}
+
+			rsp := bulkCheck(auth, &BulkCheckRequest{
+				Requests:        bulkRequests(tt.actions...),
+				StopOnFirstDeny: tt.stopOnFirstDeny,
+			})
+
+			if len(auth.evaluated) != tt.wantEvaluated || len(rsp.Results) != tt.wantEvaluated {
+				t.Fatalf("evaluated %v with %d results, want %d", auth.evaluated, len(rsp.Results), tt.wantEvaluated)
+			}
+
+			for i, result := range rsp.Results {
+				if result.Allowed == (tt.actions[i] == "delete") {
+					t.Errorf("results[%d].Allowed = %v for %s", i, result.Allowed, tt.actions[i])
+				}
+			}
+
+			if rsp.AllAllowed != tt.wantAllAllowed {
+				t.Errorf("AllAllowed = %v, want %v", rsp.AllAllowed, tt.wantAllAllowed)
+			}
+
+			if rsp.FirstDeniedIndex != tt.wantFirstDenied {
+				t.Errorf("FirstDeniedIndex = %d, want %d", rsp.FirstDeniedIndex, tt.wantFirstDenied)
+			}
+		})
+	}
+}
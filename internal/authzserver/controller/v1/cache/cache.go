@@ -0,0 +1,118 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package cache implements the handlers to inspect the authorization cache.
+package cache
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/component-base/pkg/core"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/internal/authzserver/load/cache"
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/util/gormutil"
+)
+
+const (
+	defaultTopN = 10
+	// maxTopN bounds the users listed by Status, whose response would otherwise grow with the users.
+	maxTopN = 100
+)
+
+// StatsGetter defines function to get the statistics of the cached content.
+type StatsGetter interface {
+	Stats(topN int) *cache.Stats
+}
+
+// MetaLister defines functions to list the metadata of the cached content.
+type MetaLister interface {
+	ListSecrets(kid string, offset, limit int) *cache.SecretMetaList
+	ListPolicies(username string, offset, limit int) *cache.PolicyMetaList
+}
+
+// CacheStore defines functions to inspect the cached content.
+type CacheStore interface {
+	StatsGetter
+	MetaLister
+}
+
+// CacheController create a cache handler used to inspect the cached secrets and policies.
+type CacheController struct {
+	store CacheStore
+}
+
+// NewCacheController creates a cache handler.
+func NewCacheController(store CacheStore) *CacheController {
+	return &CacheController{
+		store: store,
+	}
+}
+
+// Status returns the statistics of the cached secrets and policies. The number of users
+// listed with their policy counts is controlled by the `top` query parameter, up to maxTopN.
+func (cc *CacheController) Status(c *gin.Context) {
+	topN := defaultTopN
+
+	if top := c.Query("top"); top != "" {
+		n, err := strconv.Atoi(top)
+		if err != nil || n < 0 {
+			core.WriteResponse(c, errors.WithCode(code.ErrValidation, "top must be a non-negative integer"), nil)
+
+			return
+		}
+
+		topN = n
+		if topN > maxTopN {
+			topN = maxTopN
+		}
+	}
+
+	core.WriteResponse(c, nil, cc.store.Stats(topN))
+}
+
+// Secrets returns a page of the cached secrets metadata, the secret keys are never returned.
+// The `kid` query parameter selects a single secret, `offset` and `limit` select the page.
+func (cc *CacheController) Secrets(c *gin.Context) {
+	page, err := bindPage(c)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	core.WriteResponse(c, nil, cc.store.ListSecrets(c.Query("kid"), page.Offset, page.Limit))
+}
+
+// Policies returns a page of the cached policies metadata. The `username` query parameter
+// selects the policies of a user, `offset` and `limit` select the page.
+func (cc *CacheController) Policies(c *gin.Context) {
+	page, err := bindPage(c)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	core.WriteResponse(c, nil, cc.store.ListPolicies(c.Query("username"), page.Offset, page.Limit))
+}
+
+// bindPage returns the page selected by the `offset` and `limit` query parameters, a limit of
+// -1 selects all the remaining items.
+func bindPage(c *gin.Context) (*gormutil.LimitAndOffset, error) {
+	var r metav1.ListOptions
+	if err := c.ShouldBindQuery(&r); err != nil {
+		return nil, errors.WithCode(code.ErrBind, err.Error())
+	}
+
+	page := gormutil.Unpointer(r.Offset, r.Limit)
+	if page.Offset < 0 || page.Limit < -1 {
+		return nil, errors.WithCode(code.ErrValidation, "offset must be non-negative and limit must be -1 or greater")
+	}
+
+	return page, nil
+}
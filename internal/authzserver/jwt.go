@@ -5,15 +5,31 @@
 package authzserver
 
 import (
+	"crypto/rsa"
+
 	"github.com/marmotedu/errors"
 
 	"github.com/marmotedu/iam/internal/authzserver/load/cache"
+	"github.com/marmotedu/iam/internal/pkg/jwt"
 	"github.com/marmotedu/iam/internal/pkg/middleware"
 	"github.com/marmotedu/iam/internal/pkg/middleware/auth"
+	"github.com/marmotedu/iam/pkg/log"
 )
 
-func newCacheAuth() middleware.AuthStrategy {
-	return auth.NewCacheStrategy(getSecretFunc())
+// newCacheAuth creates the cache strategy, which also accepts the service tokens verified by the public
+// keys of serviceKeyFiles.
+func newCacheAuth(serviceKeyFiles []string) middleware.AuthStrategy {
+	keys := make([]*rsa.PublicKey, 0, len(serviceKeyFiles))
+	for _, file := range serviceKeyFiles {
+		key, err := jwt.LoadRSAPublicKey(file)
+		if err != nil {
+			log.Fatalf("Failed to load service token public key: %s", err.Error())
+		}
+
+		keys = append(keys, key)
+	}
+
+	return auth.NewCacheStrategy(getSecretFunc()).WithServiceKeys(keys...)
 }
 
 func getSecretFunc() func(string) (auth.Secret, error) {
@@ -5,22 +5,73 @@
 package cache
 
 import (
+	"encoding/json"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/dgraph-io/ristretto"
 	pb "github.com/marmotedu/api/proto/apiserver/v1"
 	"github.com/marmotedu/errors"
 	"github.com/ory/ladon"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/marmotedu/iam/internal/authzserver/load"
 	"github.com/marmotedu/iam/internal/authzserver/store"
 )
 
-// Cache is used to store secrets and policies.
+// Cache is used to store secrets and policies. Reads never block: every reload builds a new
+// immutable snapshot off to the side and publishes it with a single atomic swap.
 type Cache struct {
-	lock     *sync.RWMutex
+	// lock serializes reloads, readers never take it.
+	lock     *sync.Mutex
 	cli      store.Factory
-	secrets  *ristretto.Cache
-	policies *ristretto.Cache
+	snapshot atomic.Value // *snapshot
+	// lastReload holds the *load.ReloadResult of the last reload, successful or not.
+	lastReload atomic.Value
+	// watch is the state maintained from the watch stream, it is protected by lock.
+	watch *watchState
+}
+
+// snapshot is a complete view of the cached secrets and policies, it must not be modified
+// once published.
+type snapshot struct {
+	secrets  map[string]*pb.SecretInfo
+	policies map[string][]*ladon.DefaultPolicy
+	stats    stats
+}
+
+// stats is computed once per snapshot, so that it is cheap to read on every scrape.
+type stats struct {
+	secrets       int
+	policies      int
+	secretsBytes  int64
+	policiesBytes int64
+	lastLoadTime  time.Time
+	// subjects is the policy count of each user sorted in descending order.
+	subjects []SubjectPolicyCount
+}
+
+// SubjectPolicyCount is the number of policies cached for a user.
+type SubjectPolicyCount struct {
+	Username string `json:"username"`
+	Policies int    `json:"policies"`
+}
+
+// Stats describes the content of the cache.
+type Stats struct {
+	Secrets  int `json:"secrets"`
+	Policies int `json:"policies"`
+	Subjects int `json:"subjects"`
+	// TopSubjects holds the users with the most policies.
+	TopSubjects []SubjectPolicyCount `json:"topSubjects"`
+	// LastLoadTime is the time of the last successful reload, zero if never loaded.
+	LastLoadTime time.Time `json:"lastLoadTime"`
+	// SecretsBytes and PoliciesBytes are the estimated memory used by the cached items.
+	SecretsBytes  int64 `json:"secretsBytes"`
+	PoliciesBytes int64 `json:"policiesBytes"`
+	// LastReload is the result of the last reload, nil if never reloaded.
+	LastReload *load.ReloadResult `json:"lastReload,omitempty"`
 }
 
 var (
@@ -31,100 +82,192 @@ var (
 )
 
 var (
-	onceCache sync.Once
-	cacheIns  *Cache
+	cacheLock sync.Mutex
+	cacheIns  atomic.Value // *Cache
 )
 
-// GetCacheInsOr return store instance.
+// GetCacheInsOr return store instance. The instance is created with the first non-nil cli.
 func GetCacheInsOr(cli store.Factory) (*Cache, error) {
-	var err error
-	if cli != nil {
-		var (
-			secretCache *ristretto.Cache
-			policyCache *ristretto.Cache
-		)
-
-		onceCache.Do(func() {
-			c := &ristretto.Config{
-				NumCounters: 1e7,     // number of keys to track frequency of (10M).
-				MaxCost:     1 << 30, // maximum cost of cache (1GB).
-				BufferItems: 64,      // number of keys per Get buffer.
-				Cost:        nil,
-			}
-
-			secretCache, err = ristretto.NewCache(c)
-			if err != nil {
-				return
-			}
-			policyCache, err = ristretto.NewCache(c)
-			if err != nil {
-				return
-			}
-
-			cacheIns = &Cache{
-				cli:      cli,
-				lock:     new(sync.RWMutex),
-				secrets:  secretCache,
-				policies: policyCache,
-			}
-		})
+	if c, _ := cacheIns.Load().(*Cache); c != nil || cli == nil {
+		return c, nil
 	}
 
-	return cacheIns, err
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	c, _ := cacheIns.Load().(*Cache)
+	if c == nil {
+		c = newCache(cli)
+		cacheIns.Store(c)
+	}
+
+	return c, nil
+}
+
+// SetCacheIns replaces the instance returned by GetCacheInsOr, nil resets it so that the next
+// call creates a new instance with its cli. It is intended for tests only.
+func SetCacheIns(c *Cache) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	cacheIns.Store(c)
+}
+
+func newCache(cli store.Factory) *Cache {
+	c := &Cache{
+		cli:   cli,
+		lock:  new(sync.Mutex),
+		watch: newWatchState(0),
+	}
+	c.snapshot.Store(&snapshot{})
+
+	return c
+}
+
+func (c *Cache) load() *snapshot {
+	return c.snapshot.Load().(*snapshot)
 }
 
 // GetSecret return secret detail for the given key.
 func (c *Cache) GetSecret(key string) (*pb.SecretInfo, error) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	value, ok := c.secrets.Get(key)
+	value, ok := c.load().secrets[key]
 	if !ok {
 		return nil, ErrSecretNotFound
 	}
 
-	return value.(*pb.SecretInfo), nil
+	return value, nil
 }
 
 // GetPolicy return user's ladon policies for the given user.
 func (c *Cache) GetPolicy(key string) ([]*ladon.DefaultPolicy, error) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	value, ok := c.policies.Get(key)
+	value, ok := c.load().policies[key]
 	if !ok {
 		return nil, ErrPolicyNotFound
 	}
 
-	return value.([]*ladon.DefaultPolicy), nil
+	return value, nil
 }
 
-// Reload reload secrets and policies.
-func (c *Cache) Reload() error {
+// Reload reloads secrets and policies. Both phases are always run and the errors of the
+// failed ones are aggregated. If only one phase failed, the other resource is refreshed while
+// the failed one keeps its previous content when acceptPartial is true, otherwise the cache
+// keeps serving the previous snapshot.
+func (c *Cache) Reload(acceptPartial bool) (*load.ReloadResult, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
+	prev := c.load()
+	result := &load.ReloadResult{Time: time.Now()}
+
+	var errs []error
+
 	// reload secrets
-	secrets, err := c.cli.Secrets().List()
+	start := time.Now()
+	secrets, skippedSecrets, err := c.cli.Secrets().List()
+	result.SecretsDuration = time.Since(start)
+
 	if err != nil {
-		return errors.Wrap(err, "list secrets failed")
+		errs = append(errs, errors.Wrap(err, "list secrets failed"))
+		secrets = prev.secrets
+	} else {
+		result.Secrets, result.SkippedSecrets = len(secrets), skippedSecrets
 	}
 
-	c.secrets.Clear()
-	for key, val := range secrets {
-		c.secrets.Set(key, val, 1)
+	// reload policies
+	start = time.Now()
+	policies, skippedPolicies, err := c.cli.Policies().List()
+	result.PoliciesDuration = time.Since(start)
+
+	if err != nil {
+		errs = append(errs, errors.Wrap(err, "list policies failed"))
+		policies = prev.policies
+	} else {
+		for _, pols := range policies {
+			result.Policies += len(pols)
+		}
+		result.SkippedPolicies = skippedPolicies
 	}
 
-	// reload policies
-	policies, err := c.cli.Policies().List()
+	// partial means exactly one of the two phases failed.
+	result.Partial = len(errs) == 1
+	result.Applied = len(errs) == 0 || (result.Partial && acceptPartial)
+
+	if result.Applied {
+		c.snapshot.Store(newSnapshot(secrets, policies))
+	}
+
+	err = errors.NewAggregate(errs)
 	if err != nil {
-		return errors.Wrap(err, "list policies failed")
+		result.Error = err.Error()
+	}
+
+	c.lastReload.Store(result)
+
+	return result, err
+}
+
+// Stats returns the statistics of the cached secrets and policies, topN limits the
+// number of users returned in TopSubjects.
+func (c *Cache) Stats(topN int) *Stats {
+	st := c.load().stats
+
+	if topN < 0 || topN > len(st.subjects) {
+		topN = len(st.subjects)
+	}
+
+	top := make([]SubjectPolicyCount, topN)
+	copy(top, st.subjects)
+
+	lastReload, _ := c.lastReload.Load().(*load.ReloadResult)
+
+	return &Stats{
+		Secrets:       st.secrets,
+		Policies:      st.policies,
+		Subjects:      len(st.subjects),
+		TopSubjects:   top,
+		LastLoadTime:  st.lastLoadTime,
+		SecretsBytes:  st.secretsBytes,
+		PoliciesBytes: st.policiesBytes,
+		LastReload:    lastReload,
+	}
+}
+
+func newSnapshot(secrets map[string]*pb.SecretInfo, policies map[string][]*ladon.DefaultPolicy) *snapshot {
+	return &snapshot{
+		secrets:  secrets,
+		policies: policies,
+		stats:    buildStats(secrets, policies),
+	}
+}
+
+func buildStats(secrets map[string]*pb.SecretInfo, policies map[string][]*ladon.DefaultPolicy) stats {
+	st := stats{
+		secrets:      len(secrets),
+		subjects:     make([]SubjectPolicyCount, 0, len(policies)),
+		lastLoadTime: time.Now(),
 	}
 
-	c.policies.Clear()
-	for key, val := range policies {
-		c.policies.Set(key, val, 1)
+	for _, secret := range secrets {
+		st.secretsBytes += int64(proto.Size(secret))
 	}
 
-	return nil
+	for username, pols := range policies {
+		st.policies += len(pols)
+		st.subjects = append(st.subjects, SubjectPolicyCount{Username: username, Policies: len(pols)})
+
+		for _, pol := range pols {
+			data, _ := json.Marshal(pol)
+			st.policiesBytes += int64(len(data))
+		}
+	}
+
+	sort.Slice(st.subjects, func(i, j int) bool {
+		if st.subjects[i].Policies != st.subjects[j].Policies {
+			return st.subjects[i].Policies > st.subjects[j].Policies
+		}
+
+		return st.subjects[i].Username < st.subjects[j].Username
+	})
+
+	return st
 }
@@ -0,0 +1,356 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/marmotedu/errors"
+	"github.com/ory/ladon"
+
+	"github.com/marmotedu/iam/internal/authzserver/load"
+	"github.com/marmotedu/iam/internal/authzserver/store"
+)
+
+func TestCache_Stats(t *testing.T) {
+	secrets := map[string]*pb.SecretInfo{
+		"id1": {Name: "secret1", SecretId: "id1", Username: "colin"},
+		"id2": {Name: "secret2", SecretId: "id2", Username: "lkong"},
+	}
+	policies := map[string][]*ladon.DefaultPolicy{
+		"colin": {{ID: "p1"}},
+		"lkong": {{ID: "p2"}, {ID: "p3"}},
+		"admin": {{ID: "p4"}, {ID: "p5"}},
+	}
+
+	c := newCache(nil)
+	c.snapshot.Store(newSnapshot(secrets, policies))
+
+	tests := []struct {
+		name string
+		topN int
+		want []SubjectPolicyCount
+	}{
+		{
+			name: "top 2",
+			topN: 2,
+			want: []SubjectPolicyCount{{Username: "admin", Policies: 2}, {Username: "lkong", Policies: 2}},
+		},
+		{
+			name: "top larger than subjects",
+			topN: 10,
+			want: []SubjectPolicyCount{
+				{Username: "admin", Policies: 2},
+				{Username: "lkong", Policies: 2},
+				{Username: "colin", Policies: 1},
+			},
+		},
+		{
+			name: "no top",
+			topN: 0,
+			want: []SubjectPolicyCount{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.Stats(tt.topN)
+			if got.Secrets != 2 || got.Policies != 5 || got.Subjects != 3 {
+				t.Errorf("Stats() counts = %d/%d/%d, want 2/5/3", got.Secrets, got.Policies, got.Subjects)
+			}
+
+			if got.LastLoadTime.IsZero() || got.SecretsBytes == 0 || got.PoliciesBytes == 0 {
+				t.Errorf("Stats() = %+v, want load time and memory estimates set", got)
+			}
+
+			if !reflect.DeepEqual(got.TopSubjects, tt.want) {
+				t.Errorf("Stats().TopSubjects = %v, want %v", got.TopSubjects, tt.want)
+			}
+		})
+	}
+}
+
+// phaseStore returns the configured secrets and policies, or fails the phases configured to fail.
+type phaseStore struct {
+	secrets      map[string]*pb.SecretInfo
+	policies     map[string][]*ladon.DefaultPolicy
+	skipped      int
+	failSecrets  bool
+	failPolicies bool
+}
+
+func (s *phaseStore) Policies() store.PolicyStore { return phasePolicyStore{s} }
+func (s *phaseStore) Secrets() store.SecretStore  { return phaseSecretStore{s} }
+func (s *phaseStore) Watcher() store.WatchStore   { return nil }
+
+type phaseSecretStore struct{ *phaseStore }
+
+func (s phaseSecretStore) List() (map[string]*pb.SecretInfo, int, error) {
+	if s.failSecrets {
+		return nil, 0, errors.New("secrets unavailable")
+	}
+
+	return s.secrets, s.skipped, nil
+}
+
+type phasePolicyStore struct{ *phaseStore }
+
+func (s phasePolicyStore) List() (map[string][]*ladon.DefaultPolicy, int, error) {
+	if s.failPolicies {
+		return nil, 0, errors.New("policies unavailable")
+	}
+
+	return s.policies, s.skipped, nil
+}
+
+func TestCache_Reload(t *testing.T) {
+	oldSecrets := map[string]*pb.SecretInfo{"old": {SecretId: "old", Username: "colin"}}
+	oldPolicies := map[string][]*ladon.DefaultPolicy{"colin": {{ID: "old"}}}
+	newSecrets := map[string]*pb.SecretInfo{"new": {SecretId: "new", Username: "colin"}}
+	newPolicies := map[string][]*ladon.DefaultPolicy{"colin": {{ID: "new1"}, {ID: "new2"}}}
+
+	tests := []struct {
+		name          string
+		failSecrets   bool
+		failPolicies  bool
+		acceptPartial bool
+		wantErrs      int
+		wantPartial   bool
+		wantApplied   bool
+		wantSecret    string
+		wantPolicy    string
+	}{
+		{name: "all loaded", wantApplied: true, wantSecret: "new", wantPolicy: "new1"},
+		{
+			name:         "policies failed and partial rejected",
+			failPolicies: true,
+			wantErrs:     1,
+			wantPartial:  true,
+			wantSecret:   "old",
+			wantPolicy:   "old",
+		},
+		{
+			name:          "policies failed and partial accepted",
+			failPolicies:  true,
+			acceptPartial: true,
+			wantErrs:      1,
+			wantPartial:   true,
+			wantApplied:   true,
+			wantSecret:    "new",
+			wantPolicy:    "old",
+		},
+		{
+			name:          "secrets failed and partial accepted",
+			failSecrets:   true,
+			acceptPartial: true,
+			wantErrs:      1,
+			wantPartial:   true,
+			wantApplied:   true,
+			wantSecret:    "old",
+			wantPolicy:    "new1",
+		},
+		{
+			name:          "all failed",
+			failSecrets:   true,
+			failPolicies:  true,
+			acceptPartial: true,
+			wantErrs:      2,
+			wantSecret:    "old",
+			wantPolicy:    "old",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCache(&phaseStore{
+				secrets:      newSecrets,
+				policies:     newPolicies,
+				skipped:      1,
+				failSecrets:  tt.failSecrets,
+				failPolicies: tt.failPolicies,
+			})
+			c.snapshot.Store(newSnapshot(oldSecrets, oldPolicies))
+
+			result, err := c.Reload(tt.acceptPartial)
+
+			var gotErrs int
+			if agg, ok := err.(errors.Aggregate); ok {
+				gotErrs = len(agg.Errors())
+			}
+			if gotErrs != tt.wantErrs {
+				t.Errorf("Reload() error = %v, want %d aggregated errors", err, tt.wantErrs)
+			}
+
+			if result.Partial != tt.wantPartial || result.Applied != tt.wantApplied {
+				t.Errorf("Reload() partial/applied = %t/%t, want %t/%t",
+					result.Partial, result.Applied, tt.wantPartial, tt.wantApplied)
+			}
+
+			if !tt.failSecrets && (result.Secrets != 1 || result.SkippedSecrets != 1) {
+				t.Errorf("Reload() secrets = %d (skipped %d), want 1 (skipped 1)", result.Secrets, result.SkippedSecrets)
+			}
+
+			if !tt.failPolicies && (result.Policies != 2 || result.SkippedPolicies != 1) {
+				t.Errorf("Reload() policies = %d (skipped %d), want 2 (skipped 1)",
+					result.Policies, result.SkippedPolicies)
+			}
+
+			if _, err := c.GetSecret(tt.wantSecret); err != nil {
+				t.Errorf("GetSecret(%s) error = %v", tt.wantSecret, err)
+			}
+
+			if pols, _ := c.GetPolicy("colin"); len(pols) == 0 || pols[0].ID != tt.wantPolicy {
+				t.Errorf("GetPolicy() = %v, want %s", pols, tt.wantPolicy)
+			}
+
+			if got := c.Stats(0).LastReload; got != result {
+				t.Errorf("Stats().LastReload = %v, want %v", got, result)
+			}
+		})
+	}
+}
+
+const (
+	benchUsers          = 1000
+	benchPoliciesOfUser = 5
+)
+
+// fakeStore simulates iam-apiserver by unmarshalling every policy on each List call.
+type fakeStore struct{}
+
+func (fakeStore) Policies() store.PolicyStore { return fakeStore{} }
+func (fakeStore) Secrets() store.SecretStore  { return fakeSecretStore{} }
+func (fakeStore) Watcher() store.WatchStore   { return nil }
+
+func (fakeStore) List() (map[string][]*ladon.DefaultPolicy, int, error) {
+	policies := make(map[string][]*ladon.DefaultPolicy, benchUsers)
+
+	for i := 0; i < benchUsers; i++ {
+		username := fmt.Sprintf("user%d", i)
+		for j := 0; j < benchPoliciesOfUser; j++ {
+			data := fmt.Sprintf(`{"id":"%s-%d","subjects":["users:%s"],"effect":"allow",`+
+				`"resources":["resources:articles:<.*>"],"actions":["delete","<create|update>"]}`, username, j, username)
+
+			var policy ladon.DefaultPolicy
+			if err := json.Unmarshal([]byte(data), &policy); err != nil {
+				return nil, 0, err
+			}
+
+			policies[username] = append(policies[username], &policy)
+		}
+	}
+
+	return policies, 0, nil
+}
+
+type fakeSecretStore struct{}
+
+func (fakeSecretStore) List() (map[string]*pb.SecretInfo, int, error) {
+	secrets := make(map[string]*pb.SecretInfo, benchUsers)
+	for i := 0; i < benchUsers; i++ {
+		id := fmt.Sprintf("secret%d", i)
+		secrets[id] = &pb.SecretInfo{SecretId: id, Username: fmt.Sprintf("user%d", i)}
+	}
+
+	return secrets, 0, nil
+}
+
+// mutexCache is the previous implementation which guards the maps with a mutex held by
+// readers and by the whole reload, used as the baseline of the benchmark.
+type mutexCache struct {
+	lock     sync.Mutex
+	cli      store.Factory
+	secrets  map[string]*pb.SecretInfo
+	policies map[string][]*ladon.DefaultPolicy
+}
+
+func (c *mutexCache) GetPolicy(key string) ([]*ladon.DefaultPolicy, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	value, ok := c.policies[key]
+	if !ok {
+		return nil, ErrPolicyNotFound
+	}
+
+	return value, nil
+}
+
+func (c *mutexCache) Reload(acceptPartial bool) (*load.ReloadResult, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	secrets, _, err := c.cli.Secrets().List()
+	if err != nil {
+		return nil, err
+	}
+
+	policies, _, err := c.cli.Policies().List()
+	if err != nil {
+		return nil, err
+	}
+
+	c.secrets, c.policies = secrets, policies
+
+	return &load.ReloadResult{}, nil
+}
+
+type reloadableCache interface {
+	GetPolicy(key string) ([]*ladon.DefaultPolicy, error)
+	Reload(acceptPartial bool) (*load.ReloadResult, error)
+}
+
+// BenchmarkCache_GetPolicyDuringReload measures the read throughput while the cache is
+// reloaded continuously in the background.
+func BenchmarkCache_GetPolicyDuringReload(b *testing.B) {
+	caches := []struct {
+		name  string
+		cache reloadableCache
+	}{
+		{name: "mutex", cache: &mutexCache{cli: fakeStore{}}},
+		{name: "snapshot", cache: newCache(fakeStore{})},
+	}
+	for _, cc := range caches {
+		b.Run(cc.name, func(b *testing.B) {
+			if _, err := cc.cache.Reload(false); err != nil {
+				b.Fatalf("Reload() error = %v", err)
+			}
+
+			stop := make(chan struct{})
+			done := make(chan struct{})
+
+			go func() {
+				defer close(done)
+
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						_, _ = cc.cache.Reload(false)
+					}
+				}
+			}()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					if _, err := cc.cache.GetPolicy(fmt.Sprintf("user%d", i%benchUsers)); err != nil {
+						b.Errorf("GetPolicy() error = %v", err)
+					}
+					i++
+				}
+			})
+			b.StopTimer()
+
+			close(stop)
+			<-done
+		})
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"sort"
+	"time"
+)
+
+// SecretMeta is the metadata of a cached secret, the secret key is never exposed.
+type SecretMeta struct {
+	SecretID    string `json:"secretID"`
+	Name        string `json:"name"`
+	Username    string `json:"username"`
+	Expires     int64  `json:"expires"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+}
+
+// SecretMetaList is a page of the cached secrets sorted by secret id.
+type SecretMetaList struct {
+	// TotalCount is the number of secrets matched, regardless of the page.
+	TotalCount int `json:"totalCount"`
+	// LoadedAt is the time the listed snapshot was built.
+	LoadedAt time.Time     `json:"loadedAt"`
+	Items    []*SecretMeta `json:"items"`
+}
+
+// PolicyMeta is the metadata of a cached policy.
+type PolicyMeta struct {
+	ID          string   `json:"id"`
+	Username    string   `json:"username"`
+	Description string   `json:"description"`
+	Subjects    []string `json:"subjects"`
+	Effect      string   `json:"effect"`
+	Resources   []string `json:"resources"`
+	Actions     []string `json:"actions"`
+}
+
+// PolicyMetaList is a page of the cached policies sorted by username and id.
+type PolicyMetaList struct {
+	// TotalCount is the number of policies matched, regardless of the page.
+	TotalCount int `json:"totalCount"`
+	// LoadedAt is the time the listed snapshot was built.
+	LoadedAt time.Time     `json:"loadedAt"`
+	Items    []*PolicyMeta `json:"items"`
+}
+
+// ListSecrets returns the metadata of the cached secrets, only the secret with id kid is
+// listed if kid is not empty. offset and limit select the page returned, a negative limit
+// returns all the remaining secrets.
+func (c *Cache) ListSecrets(kid string, offset, limit int) *SecretMetaList {
+	snap := c.load()
+
+	ids := make([]string, 0, len(snap.secrets))
+	for id := range snap.secrets {
+		if kid == "" || kid == id {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	list := &SecretMetaList{TotalCount: len(ids), LoadedAt: snap.stats.lastLoadTime, Items: []*SecretMeta{}}
+	start, end := page(len(ids), offset, limit)
+
+	for _, id := range ids[start:end] {
+		secret := snap.secrets[id]
+		list.Items = append(list.Items, &SecretMeta{
+			SecretID:    secret.SecretId,
+			Name:        secret.Name,
+			Username:    secret.Username,
+			Expires:     secret.Expires,
+			Description: secret.Description,
+			CreatedAt:   secret.CreatedAt,
+			UpdatedAt:   secret.UpdatedAt,
+		})
+	}
+
+	return list
+}
+
+// ListPolicies returns the metadata of the cached policies, only the policies of username are
+// listed if username is not empty. offset and limit select the page returned, a negative
+// limit returns all the remaining policies.
+func (c *Cache) ListPolicies(username string, offset, limit int) *PolicyMetaList {
+	snap := c.load()
+
+	var items []*PolicyMeta
+
+	for user, pols := range snap.policies {
+		if username != "" && username != user {
+			continue
+		}
+
+		for _, pol := range pols {
+			items = append(items, &PolicyMeta{
+				ID:          pol.ID,
+				Username:    user,
+				Description: pol.Description,
+				Subjects:    pol.Subjects,
+				Effect:      pol.Effect,
+				Resources:   pol.Resources,
+				Actions:     pol.Actions,
+			})
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Username != items[j].Username {
+			return items[i].Username < items[j].Username
+		}
+
+		return items[i].ID < items[j].ID
+	})
+
+	start, end := page(len(items), offset, limit)
+
+	return &PolicyMetaList{
+		TotalCount: len(items),
+		LoadedAt:   snap.stats.lastLoadTime,
+		Items:      append([]*PolicyMeta{}, items[start:end]...),
+	}
+}
+
+// page returns the bounds of the page of a list of total items.
+func page(total, offset, limit int) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+
+	if offset > total {
+		offset = total
+	}
+
+	if limit < 0 || offset+limit > total {
+		return offset, total
+	}
+
+	return offset, offset + limit
+}
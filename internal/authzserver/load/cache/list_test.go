@@ -0,0 +1,124 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/ory/ladon"
+)
+
+func newListCache() *Cache {
+	secrets := map[string]*pb.SecretInfo{
+		"id1": {Name: "secret1", SecretId: "id1", Username: "colin", SecretKey: "key1", UpdatedAt: "2021-01-01 00:00:00"},
+		"id2": {Name: "secret2", SecretId: "id2", Username: "lkong", SecretKey: "key2"},
+		"id3": {Name: "secret3", SecretId: "id3", Username: "colin", SecretKey: "key3"},
+	}
+	policies := map[string][]*ladon.DefaultPolicy{
+		"colin": {{ID: "p2", Subjects: []string{"users:colin"}, Effect: ladon.AllowAccess}, {ID: "p1"}},
+		"lkong": {{ID: "p3"}},
+	}
+
+	c := newCache(nil)
+	c.snapshot.Store(newSnapshot(secrets, policies))
+
+	return c
+}
+
+func TestCache_ListSecrets(t *testing.T) {
+	c := newListCache()
+
+	tests := []struct {
+		name      string
+		kid       string
+		offset    int
+		limit     int
+		wantTotal int
+		wantIDs   []string
+	}{
+		{name: "all", limit: -1, wantTotal: 3, wantIDs: []string{"id1", "id2", "id3"}},
+		{name: "first page", limit: 2, wantTotal: 3, wantIDs: []string{"id1", "id2"}},
+		{name: "last page", offset: 2, limit: 2, wantTotal: 3, wantIDs: []string{"id3"}},
+		{name: "offset out of range", offset: 5, limit: 2, wantTotal: 3, wantIDs: []string{}},
+		{name: "kid", kid: "id2", limit: -1, wantTotal: 1, wantIDs: []string{"id2"}},
+		{name: "unknown kid", kid: "id4", limit: -1, wantTotal: 0, wantIDs: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.ListSecrets(tt.kid, tt.offset, tt.limit)
+
+			ids := []string{}
+			for _, item := range got.Items {
+				ids = append(ids, item.SecretID)
+			}
+
+			if got.TotalCount != tt.wantTotal || !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("ListSecrets() = %d %v, want %d %v", got.TotalCount, ids, tt.wantTotal, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestCache_ListSecrets_NoSecretKey(t *testing.T) {
+	got := newListCache().ListSecrets("", 0, -1)
+
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	for _, key := range []string{"key1", "key2", "key3", "secretKey", "secret_key"} {
+		if strings.Contains(string(data), key) {
+			t.Errorf("ListSecrets() = %s, contains %q", data, key)
+		}
+	}
+
+	if got.Items[0].UpdatedAt != "2021-01-01 00:00:00" || got.LoadedAt.IsZero() {
+		t.Errorf("ListSecrets() = %s, want the timestamps set", data)
+	}
+}
+
+func TestCache_ListPolicies(t *testing.T) {
+	c := newListCache()
+
+	tests := []struct {
+		name      string
+		username  string
+		offset    int
+		limit     int
+		wantTotal int
+		wantIDs   []string
+	}{
+		{name: "all", limit: -1, wantTotal: 3, wantIDs: []string{"p1", "p2", "p3"}},
+		{name: "page", offset: 1, limit: 1, wantTotal: 3, wantIDs: []string{"p2"}},
+		{name: "username", username: "colin", limit: -1, wantTotal: 2, wantIDs: []string{"p1", "p2"}},
+		{name: "unknown username", username: "admin", limit: -1, wantTotal: 0, wantIDs: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.ListPolicies(tt.username, tt.offset, tt.limit)
+
+			ids := []string{}
+			for _, item := range got.Items {
+				ids = append(ids, item.ID)
+			}
+
+			if got.TotalCount != tt.wantTotal || !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("ListPolicies() = %d %v, want %d %v", got.TotalCount, ids, tt.wantTotal, tt.wantIDs)
+			}
+		})
+	}
+
+	got := c.ListPolicies("colin", 1, 1).Items[0]
+	want := &PolicyMeta{ID: "p2", Username: "colin", Subjects: []string{"users:colin"}, Effect: ladon.AllowAccess}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListPolicies() = %+v, want %+v", got, want)
+	}
+}
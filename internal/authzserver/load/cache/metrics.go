@@ -0,0 +1,67 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsSubsystem = "authz_cache"
+
+var (
+	registerMetricsOnce sync.Once
+	// metricsCache is the *Cache the gauges are computed from, the cache of the last RegisterMetrics call.
+	metricsCache atomic.Value
+)
+
+// RegisterMetrics registers the cache gauges into the default prometheus registry, which is
+// exposed by the /metrics endpoint of the generic api server. The gauges are registered once,
+// the next calls, e.g. by the servers initialized again by the tests, only set the cache they
+// are computed from.
+func RegisterMetrics(c *Cache) {
+	metricsCache.Store(c)
+
+	registerMetricsOnce.Do(func() {
+		prometheus.MustRegister(
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Namespace: "iam",
+				Subsystem: metricsSubsystem,
+				Name:      "secrets",
+				Help:      "Total number of cached secrets.",
+			}, func() float64 {
+				return float64(metricsStats().Secrets)
+			}),
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Namespace: "iam",
+				Subsystem: metricsSubsystem,
+				Name:      "policies",
+				Help:      "Total number of cached policies.",
+			}, func() float64 {
+				return float64(metricsStats().Policies)
+			}),
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Namespace: "iam",
+				Subsystem: metricsSubsystem,
+				Name:      "age_seconds",
+				Help:      "Seconds since the cache was last loaded, -1 if it has never been loaded.",
+			}, func() float64 {
+				last := metricsStats().LastLoadTime
+				if last.IsZero() {
+					return -1
+				}
+
+				return time.Since(last).Seconds()
+			}),
+		)
+	})
+}
+
+func metricsStats() *Stats {
+	return metricsCache.Load().(*Cache).Stats(0)
+}
@@ -0,0 +1,197 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/marmotedu/errors"
+	"github.com/ory/ladon"
+
+	watchpb "github.com/marmotedu/iam/api/proto/apiserver/v1"
+	"github.com/marmotedu/iam/internal/authzserver/store"
+)
+
+// watchState is the state maintained from a watch stream, it is protected by the cache lock.
+type watchState struct {
+	// epoch and version are the watermark of the last applied event.
+	epoch    int64
+	version  int64
+	secrets  map[string]*pb.SecretInfo
+	policies map[string]map[string]*ladon.DefaultPolicy
+}
+
+func newWatchState(epoch int64) *watchState {
+	return &watchState{
+		epoch:    epoch,
+		secrets:  make(map[string]*pb.SecretInfo),
+		policies: make(map[string]map[string]*ladon.DefaultPolicy),
+	}
+}
+
+func (s *watchState) apply(event *store.Event) {
+	put := event.Type != watchpb.EventType_DELETE
+
+	if event.Secret != nil {
+		if put {
+			s.secrets[event.Secret.SecretId] = event.Secret
+		} else {
+			delete(s.secrets, event.Secret.SecretId)
+		}
+	}
+
+	if event.Username == "" && event.Name == "" {
+		return
+	}
+
+	if put && event.Policy != nil {
+		if s.policies[event.Username] == nil {
+			s.policies[event.Username] = make(map[string]*ladon.DefaultPolicy)
+		}
+
+		s.policies[event.Username][event.Name] = event.Policy
+
+		return
+	}
+
+	delete(s.policies[event.Username], event.Name)
+
+	if len(s.policies[event.Username]) == 0 {
+		delete(s.policies, event.Username)
+	}
+}
+
+// snapshot returns a copy of the state to publish.
+func (s *watchState) snapshot() *snapshot {
+	secrets := make(map[string]*pb.SecretInfo, len(s.secrets))
+	for id, secret := range s.secrets {
+		secrets[id] = secret
+	}
+
+	policies := make(map[string][]*ladon.DefaultPolicy, len(s.policies))
+
+	for username, named := range s.policies {
+		names := make([]string, 0, len(named))
+		for name := range named {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			policies[username] = append(policies[username], named[name])
+		}
+	}
+
+	return newSnapshot(secrets, policies)
+}
+
+// Watch maintains the cache from a watch stream of the store until the stream breaks or ctx
+// is done. The stream is resumed after the last applied event when possible. It is
+// considered broken if no event, including pings, is received within timeout. established
+// is called each time the cache is known to be up to date with the stream.
+func (c *Cache) Watch(ctx context.Context, timeout time.Duration, established func()) error {
+	watcher := c.cli.Watcher()
+	if watcher == nil {
+		return errors.New("store does not support watch")
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	liveness := time.AfterFunc(timeout, cancel)
+	defer liveness.Stop()
+
+	c.lock.Lock()
+	epoch, version := c.watch.epoch, c.watch.version
+	c.lock.Unlock()
+
+	stream, err := watcher.Watch(watchCtx, epoch, version)
+	if err != nil {
+		return err
+	}
+
+	// syncing holds the state received since the first SYNC event.
+	var syncing *watchState
+
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			if watchCtx.Err() != nil && ctx.Err() == nil {
+				return fmt.Errorf("no watch event received in %v", timeout)
+			}
+
+			return fmt.Errorf("receive watch event failed: %w", err)
+		}
+
+		liveness.Reset(timeout)
+
+		switch event.Type {
+		case watchpb.EventType_SYNC:
+			if syncing == nil {
+				syncing = newWatchState(event.Epoch)
+			}
+
+			syncing.apply(event)
+		case watchpb.EventType_SYNCED:
+			if syncing == nil {
+				syncing = newWatchState(event.Epoch)
+			}
+
+			syncing.version = event.ResourceVersion
+			c.publishWatch(func() { c.watch = syncing })
+			syncing = nil
+
+			established()
+		case watchpb.EventType_PUT, watchpb.EventType_DELETE:
+			if syncing != nil {
+				syncing.apply(event)
+
+				continue
+			}
+
+			if err := c.applyWatch(event); err != nil {
+				return err
+			}
+		case watchpb.EventType_PING:
+			c.lock.Lock()
+			synced := c.watch.epoch == event.Epoch
+			c.lock.Unlock()
+
+			if synced {
+				established()
+			}
+		}
+	}
+}
+
+// applyWatch applies a change received after the stream is synced.
+func (c *Cache) applyWatch(event *store.Event) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if event.Epoch != c.watch.epoch {
+		return fmt.Errorf("watch epoch changed from %d to %d", c.watch.epoch, event.Epoch)
+	}
+
+	c.watch.apply(event)
+	c.watch.version = event.ResourceVersion
+	c.snapshot.Store(c.watch.snapshot())
+
+	return nil
+}
+
+// publishWatch replaces the watch state with the given function and publishes it.
+func (c *Cache) publishWatch(replace func()) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	replace()
+	c.snapshot.Store(c.watch.snapshot())
+}
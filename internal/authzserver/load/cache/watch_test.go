@@ -0,0 +1,231 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/ory/ladon"
+
+	watchpb "github.com/marmotedu/iam/api/proto/apiserver/v1"
+	"github.com/marmotedu/iam/internal/authzserver/store"
+)
+
+var errStreamClosed = errors.New("stream closed")
+
+// watchStore serves the configured events on every stream, the streams are closed once all
+// the events are sent, or block until canceled if hang is true.
+type watchStore struct {
+	phaseStore
+	events []*store.Event
+	hang   bool
+	// watermarks records the watermark of each Watch call.
+	watermarks [][2]int64
+}
+
+func (s *watchStore) Watcher() store.WatchStore { return s }
+
+func (s *watchStore) Watch(ctx context.Context, epoch, resourceVersion int64) (store.EventStream, error) {
+	s.watermarks = append(s.watermarks, [2]int64{epoch, resourceVersion})
+
+	return &eventStream{ctx: ctx, events: s.events, hang: s.hang}, nil
+}
+
+type eventStream struct {
+	ctx    context.Context
+	events []*store.Event
+	hang   bool
+}
+
+func (s *eventStream) Recv() (*store.Event, error) {
+	if len(s.events) == 0 {
+		if s.hang {
+			<-s.ctx.Done()
+
+			return nil, s.ctx.Err()
+		}
+
+		return nil, errStreamClosed
+	}
+
+	event := s.events[0]
+	s.events = s.events[1:]
+
+	return event, nil
+}
+
+func secretEvent(typ watchpb.EventType, epoch, version int64, id string) *store.Event {
+	return &store.Event{
+		Type:            typ,
+		Epoch:           epoch,
+		ResourceVersion: version,
+		Secret:          &pb.SecretInfo{SecretId: id, Username: "colin", SecretKey: "key"},
+	}
+}
+
+func policyEvent(typ watchpb.EventType, epoch, version int64, name string) *store.Event {
+	event := &store.Event{
+		Type:            typ,
+		Epoch:           epoch,
+		ResourceVersion: version,
+		Username:        "colin",
+		Name:            name,
+	}
+
+	if typ != watchpb.EventType_DELETE {
+		event.Policy = &ladon.DefaultPolicy{ID: name}
+	}
+
+	return event
+}
+
+func TestCache_Watch(t *testing.T) {
+	synced := []*store.Event{
+		secretEvent(watchpb.EventType_SYNC, 1, 2, "id1"),
+		secretEvent(watchpb.EventType_SYNC, 1, 2, "id2"),
+		policyEvent(watchpb.EventType_SYNC, 1, 2, "p1"),
+		{Type: watchpb.EventType_SYNCED, Epoch: 1, ResourceVersion: 2},
+	}
+
+	tests := []struct {
+		name         string
+		events       []*store.Event
+		wantErr      string
+		wantCause    error
+		wantSecrets  []string
+		wantPolicies []string
+		wantVersion  int64
+		established  int
+	}{
+		{
+			name:         "sync",
+			events:       synced,
+			wantCause:    errStreamClosed,
+			wantSecrets:  []string{"id1", "id2"},
+			wantPolicies: []string{"p1"},
+			wantVersion:  2,
+			established:  1,
+		},
+		{
+			name: "changes",
+			events: append(append([]*store.Event{}, synced...),
+				secretEvent(watchpb.EventType_DELETE, 1, 3, "id1"),
+				policyEvent(watchpb.EventType_PUT, 1, 4, "p2"),
+				policyEvent(watchpb.EventType_DELETE, 1, 5, "p1"),
+				&store.Event{Type: watchpb.EventType_PING, Epoch: 1, ResourceVersion: 5},
+			),
+			wantCause:    errStreamClosed,
+			wantSecrets:  []string{"id2"},
+			wantPolicies: []string{"p2"},
+			wantVersion:  5,
+			established:  2,
+		},
+		{
+			name: "epoch changed",
+			events: append(append([]*store.Event{}, synced...),
+				secretEvent(watchpb.EventType_PUT, 2, 1, "id3"),
+			),
+			wantErr:      "watch epoch changed",
+			wantSecrets:  []string{"id1", "id2"},
+			wantPolicies: []string{"p1"},
+			wantVersion:  2,
+			established:  1,
+		},
+		{
+			name: "unfinished sync",
+			events: []*store.Event{
+				secretEvent(watchpb.EventType_SYNC, 1, 2, "id1"),
+			},
+			wantCause:   errStreamClosed,
+			wantVersion: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCache(&watchStore{events: tt.events})
+
+			established := 0
+			err := c.Watch(context.Background(), time.Second, func() { established++ })
+			if tt.wantCause != nil && !errors.Is(err, tt.wantCause) {
+				t.Fatalf("Watch() error = %v, want %v", err, tt.wantCause)
+			}
+
+			if tt.wantCause == nil && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Watch() error = %v, want %q", err, tt.wantErr)
+			}
+
+			for _, id := range tt.wantSecrets {
+				if _, err := c.GetSecret(id); err != nil {
+					t.Errorf("GetSecret(%s) error = %v", id, err)
+				}
+			}
+
+			policies, _ := c.GetPolicy("colin")
+			if len(policies) != len(tt.wantPolicies) {
+				t.Fatalf("GetPolicy() = %v, want %v", policies, tt.wantPolicies)
+			}
+
+			for i, policy := range policies {
+				if policy.ID != tt.wantPolicies[i] {
+					t.Errorf("GetPolicy()[%d] = %s, want %s", i, policy.ID, tt.wantPolicies[i])
+				}
+			}
+
+			if got := c.Stats(0).Secrets; got != len(tt.wantSecrets) {
+				t.Errorf("Stats().Secrets = %d, want %d", got, len(tt.wantSecrets))
+			}
+
+			if c.watch.version != tt.wantVersion {
+				t.Errorf("watch version = %d, want %d", c.watch.version, tt.wantVersion)
+			}
+
+			if established != tt.established {
+				t.Errorf("established called %d times, want %d", established, tt.established)
+			}
+		})
+	}
+}
+
+func TestCache_Watch_Resume(t *testing.T) {
+	s := &watchStore{events: []*store.Event{
+		secretEvent(watchpb.EventType_SYNC, 7, 3, "id1"),
+		{Type: watchpb.EventType_SYNCED, Epoch: 7, ResourceVersion: 3},
+	}}
+	c := newCache(s)
+
+	if err := c.Watch(context.Background(), time.Second, func() {}); !errors.Is(err, errStreamClosed) {
+		t.Fatalf("Watch() error = %v, want %v", err, errStreamClosed)
+	}
+
+	s.events = []*store.Event{secretEvent(watchpb.EventType_PUT, 7, 4, "id2")}
+	if err := c.Watch(context.Background(), time.Second, func() {}); !errors.Is(err, errStreamClosed) {
+		t.Fatalf("Watch() error = %v, want %v", err, errStreamClosed)
+	}
+
+	want := [][2]int64{{0, 0}, {7, 3}}
+	if len(s.watermarks) != 2 || s.watermarks[0] != want[0] || s.watermarks[1] != want[1] {
+		t.Errorf("Watch() watermarks = %v, want %v", s.watermarks, want)
+	}
+
+	for _, id := range []string{"id1", "id2"} {
+		if _, err := c.GetSecret(id); err != nil {
+			t.Errorf("GetSecret(%s) error = %v", id, err)
+		}
+	}
+}
+
+func TestCache_Watch_Timeout(t *testing.T) {
+	c := newCache(&watchStore{hang: true})
+
+	err := c.Watch(context.Background(), 10*time.Millisecond, func() {})
+	if err == nil || !strings.Contains(err.Error(), "no watch event received") {
+		t.Errorf("Watch() error = %v, want timeout", err)
+	}
+}
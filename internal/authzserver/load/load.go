@@ -6,64 +6,265 @@ package load
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/marmotedu/iam/internal/pkg/notice"
 	"github.com/marmotedu/iam/pkg/log"
 	"github.com/marmotedu/iam/pkg/storage"
 )
 
 // Loader defines function to reload storage.
 type Loader interface {
-	Reload() error
+	// Reload reloads the storage, the returned error aggregates the errors of all failed
+	// phases. If only some phases failed, the successfully loaded resources are applied
+	// when acceptPartial is true and the whole reload is rejected otherwise.
+	Reload(acceptPartial bool) (*ReloadResult, error)
+}
+
+// Watcher defines function to maintain the storage from a watch stream.
+type Watcher interface {
+	// Watch maintains the storage from a watch stream until it breaks or ctx is done. The
+	// stream is considered broken if no event is received within timeout. established is
+	// called each time the storage is known to be up to date with the stream.
+	Watch(ctx context.Context, timeout time.Duration, established func()) error
+}
+
+// ReloadResult describes what a reload has loaded.
+type ReloadResult struct {
+	Secrets          int           `json:"secrets"`
+	Policies         int           `json:"policies"`
+	SkippedSecrets   int           `json:"skippedSecrets"`
+	SkippedPolicies  int           `json:"skippedPolicies"`
+	SecretsDuration  time.Duration `json:"secretsDuration"`
+	PoliciesDuration time.Duration `json:"policiesDuration"`
+	// Partial is true if some phases failed while others succeeded.
+	Partial bool `json:"partial"`
+	// Applied is true if the loaded resources are served from now on.
+	Applied bool      `json:"applied"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+func (r *ReloadResult) String() string {
+	return fmt.Sprintf("secrets: %d (skipped %d, %v), policies: %d (skipped %d, %v), partial: %t, applied: %t",
+		r.Secrets, r.SkippedSecrets, r.SecretsDuration, r.Policies, r.SkippedPolicies, r.PoliciesDuration,
+		r.Partial, r.Applied)
 }
 
 // Load is used to reload given storage.
 type Load struct {
-	ctx    context.Context
-	lock   *sync.RWMutex
-	loader Loader
+	ctx           context.Context
+	lock          *sync.RWMutex
+	loader        Loader
+	acceptPartial bool
+	watcher       Watcher
+	watchTimeout  time.Duration
+	// connected is closed once redis is connected, the pub/sub loop is not started before.
+	connected <-chan struct{}
+	// watching is 1 while the watch stream is established, reloads are skipped meanwhile.
+	watching int32
+	// cancel stops the goroutines started by Start, wg waits for them.
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 }
 
-// NewLoader return a loader with a loader implement.
-func NewLoader(ctx context.Context, loader Loader) *Load {
+// NewLoader return a loader with a loader implement. If acceptPartial is true, a reload
+// in which only some resources are loaded successfully is applied instead of rejected.
+func NewLoader(ctx context.Context, loader Loader, acceptPartial bool) *Load {
+	ctx, cancel := context.WithCancel(ctx)
+
 	return &Load{
-		ctx:    ctx,
-		lock:   new(sync.RWMutex),
-		loader: loader,
+		ctx:           ctx,
+		lock:          new(sync.RWMutex),
+		loader:        loader,
+		acceptPartial: acceptPartial,
+		cancel:        cancel,
 	}
 }
 
+// EnableWatch makes the storage maintained from the watch stream of watcher. Reloads are
+// only performed while the stream is broken.
+func (l *Load) EnableWatch(watcher Watcher, timeout time.Duration) *Load {
+	l.watcher = watcher
+	l.watchTimeout = timeout
+
+	return l
+}
+
+// WaitConnected delays the pub/sub loop until connected is closed, which is done once redis
+// is connected. The reloads do not depend on redis and are not delayed.
+func (l *Load) WaitConnected(connected <-chan struct{}) *Load {
+	l.connected = connected
+
+	return l
+}
+
 // Start start a loop service.
 func (l *Load) Start() {
-	go startPubSubLoop()
-	go l.reloadQueueLoop()
+	l.run(func() {
+		if l.connected != nil {
+			select {
+			case <-l.connected:
+			case <-l.ctx.Done():
+				return
+			}
+		}
+
+		startPubSubLoop(l.ctx, &storage.RedisCluster{})
+	})
+	l.run(func() { l.reloadQueueLoop() })
 	// 1s is the minimum amount of time between hot reloads. The
 	// interval counts from the start of one reload to the next.
-	go l.reloadLoop()
+	l.run(func() { l.reloadLoop() })
 	l.DoReload()
+
+	if l.watcher != nil {
+		l.run(l.watchLoop)
+	}
 }
 
-func startPubSubLoop() {
-	cacheStore := storage.RedisCluster{}
-	cacheStore.Connect()
-	// On message, synchronize
+// run runs fn in a goroutine waited for by Shutdown.
+func (l *Load) run(fn func()) {
+	l.wg.Add(1)
+
+	go func() {
+		defer l.wg.Done()
+
+		fn()
+	}()
+}
+
+// Shutdown stops the goroutines started by Start. The reloads queued until they are stopped,
+// including the one a notification handler may be blocked queuing, are not lost: they are
+// drained from reloadQueue and a final reload is performed before Shutdown returns. If ctx is
+// done first, Shutdown returns its error without waiting any longer.
+func (l *Load) Shutdown(ctx context.Context) error {
+	l.cancel()
+
+	stopped := make(chan struct{})
+
+	go func() {
+		l.wg.Wait()
+		close(stopped)
+	}()
+
+	for draining := true; draining; {
+		select {
+		case fn := <-reloadQueue:
+			queueReload(fn)
+		case <-stopped:
+			draining = false
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for draining := true; draining; {
+		select {
+		case fn := <-reloadQueue:
+			queueReload(fn)
+		default:
+			draining = false
+		}
+	}
+
+	reloaded := make(chan struct{})
+
+	go func() {
+		defer close(reloaded)
+
+		cb, _ := shouldReload()
+		l.DoReload()
+
+		for _, c := range cb {
+			if c != nil {
+				c()
+			}
+		}
+
+		log.Info("reload: final cycle completed")
+	}()
+
+	select {
+	case <-reloaded:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *Load) isWatching() bool {
+	return atomic.LoadInt32(&l.watching) == 1
+}
+
+// watchLoop keeps the watch stream open. When an established stream breaks, the storage
+// is reloaded to catch up with the changes missed, and the stream is reopened with an
+// exponential backoff, resumed after the last received event.
+func (l *Load) watchLoop() {
+	const (
+		minBackoff = time.Second
+		maxBackoff = 30 * time.Second
+	)
+
+	backoff := minBackoff
+
 	for {
-		err := cacheStore.StartPubSubHandler(RedisPubSubChannel, func(v interface{}) {
-			handleRedisEvent(v, nil, nil)
-		})
-		if err != nil {
-			if !errors.Is(err, storage.ErrRedisIsDown) {
-				log.Errorf("Connection to Redis failed, reconnect in 10s: %s", err.Error())
+		err := l.watcher.Watch(l.ctx, l.watchTimeout, func() {
+			if atomic.CompareAndSwapInt32(&l.watching, 0, 1) {
+				log.Info("Watch stream established, reloads are suspended")
 			}
 
-			time.Sleep(10 * time.Second)
-			log.Warnf("Reconnecting: %s", err.Error())
+			backoff = minBackoff
+		})
+
+		if l.ctx.Err() != nil {
+			return
+		}
+
+		if atomic.SwapInt32(&l.watching, 0) == 1 {
+			log.Warnf("Watch stream broken, fall back to reload: %v", err)
+			l.DoReload()
+		} else {
+			log.Warnf("Failed to establish watch stream, retry in %v: %v", backoff, err)
+		}
+
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
 		}
 	}
 }
 
+// pubSubscriber is the part of storage.RedisCluster used by the pub/sub loop.
+type pubSubscriber interface {
+	StartPubSubLoop(ctx context.Context, channel string, callback func(interface{}), reconnect storage.PubSubReconnect)
+}
+
+func startPubSubLoop(ctx context.Context, subscriber pubSubscriber) {
+	// On message, synchronize
+	subscriber.StartPubSubLoop(ctx, notice.RedisPubSubChannel, func(v interface{}) {
+		handleRedisEvent(v, nil, nil)
+	}, storage.PubSubReconnect{
+		// the notifications published while the subscription was lost are missed.
+		OnResubscribe: func() {
+			log.Info("Reloading secrets and policies after the pub/sub subscription was lost")
+
+			select {
+			case reloadQueue <- nil:
+			case <-ctx.Done():
+			}
+		},
+	})
+}
+
 // shouldReload returns true if we should perform any reload. Reloads happens if
 // we have reload callback queued.
 func shouldReload() ([]func(), bool) {
@@ -93,7 +294,10 @@ func (l *Load) reloadLoop(complete ...func()) {
 				continue
 			}
 			start := time.Now()
-			l.DoReload()
+			// the changes are applied from the watch stream.
+			if !l.isWatching() {
+				l.DoReload()
+			}
 			for _, c := range cb {
 				// most of the callbacks are nil, we don't want to execute nil functions to
 				// avoid panics.
@@ -125,10 +329,7 @@ func (l *Load) reloadQueueLoop(cb ...func()) {
 		case <-l.ctx.Done():
 			return
 		case fn := <-reloadQueue:
-			requeueLock.Lock()
-			requeue = append(requeue, fn)
-			requeueLock.Unlock()
-			log.Info("Reload queued")
+			queueReload(fn)
 			if len(cb) != 0 {
 				cb[0]()
 			}
@@ -136,13 +337,39 @@ func (l *Load) reloadQueueLoop(cb ...func()) {
 	}
 }
 
+// QueueReload queues a reload of the secrets and policies, performed on the next reload cycle within
+// a second, e.g. once the storage may have changed unnoticed. It returns false if ctx is done first.
+func QueueReload(ctx context.Context) bool {
+	select {
+	case reloadQueue <- nil:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// queueReload queues fn to be executed on the next reload.
+func queueReload(fn func()) {
+	requeueLock.Lock()
+	requeue = append(requeue, fn)
+	requeueLock.Unlock()
+	log.Info("Reload queued")
+}
+
 // DoReload reload secrets and policies.
 func (l *Load) DoReload() {
 	l.lock.Lock()
 	defer l.lock.Unlock()
 
-	if err := l.loader.Reload(); err != nil {
+	result, err := l.loader.Reload(l.acceptPartial)
+	if result != nil {
+		log.Infof("reload result: %s", result)
+	}
+
+	if err != nil {
 		log.Errorf("faild to refresh target storage: %s", err.Error())
+
+		return
 	}
 
 	log.Debug("refresh target storage succ")
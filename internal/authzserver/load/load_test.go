@@ -0,0 +1,384 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package load
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+
+	"github.com/marmotedu/iam/internal/pkg/notice"
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+// fakeSubscriber loses the subscription resubscriptions times, then blocks until ctx is done.
+type fakeSubscriber struct {
+	resubscriptions int
+}
+
+func (s *fakeSubscriber) StartPubSubLoop(ctx context.Context, channel string, callback func(interface{}),
+	reconnect storage.PubSubReconnect) {
+	for i := 0; i < s.resubscriptions; i++ {
+		reconnect.OnResubscribe()
+	}
+
+	<-ctx.Done()
+}
+
+func TestStartPubSubLoop_Resubscribe(t *testing.T) {
+	tests := []struct {
+		name            string
+		resubscriptions int
+	}{
+		{name: "never lost", resubscriptions: 0},
+		{name: "lost once", resubscriptions: 1},
+		{name: "lost twice", resubscriptions: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go startPubSubLoop(ctx, &fakeSubscriber{resubscriptions: tt.resubscriptions})
+
+			// a full reload, with no callback, is queued after each gap.
+			for i := 0; i < tt.resubscriptions; i++ {
+				select {
+				case fn := <-reloadQueue:
+					if fn != nil {
+						t.Error("queued reload has a callback, want a full reload")
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("%d reloads queued, want %d", i, tt.resubscriptions)
+				}
+			}
+
+			select {
+			case <-reloadQueue:
+				t.Errorf("more than %d reloads queued", tt.resubscriptions)
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+	}
+}
+
+// notificationRedis starts a redis server which answers the health checks of the storage and the
+// subscriptions, the connections subscribed are sent to the returned channel.
+func notificationRedis(t *testing.T) (string, chan net.Conn) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	t.Cleanup(func() { ln.Close() })
+
+	subscribed := make(chan net.Conn, 10)
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				reader := bufio.NewReader(conn)
+				for {
+					args, err := readCommand(reader)
+					if err != nil {
+						conn.Close()
+
+						return
+					}
+
+					switch strings.ToLower(args[0]) {
+					case "set":
+						_, _ = conn.Write([]byte("+OK\r\n"))
+					case "get":
+						_, _ = conn.Write([]byte("$4\r\ntest\r\n"))
+					case "subscribe":
+						_, _ = fmt.Fprintf(conn, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(args[1]), args[1])
+						subscribed <- conn
+					default:
+						_, _ = conn.Write([]byte("-ERR unknown command\r\n"))
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return ln.Addr().String(), subscribed
+}
+
+// readCommand reads a command sent by a client as an array of bulk strings.
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	n, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	args := make([]string, n)
+
+	for i := range args {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+
+	return args, nil
+}
+
+func TestStartPubSubLoop_ConnectionKilled(t *testing.T) {
+	addr, subscribed := notificationRedis(t)
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go storage.ConnectToRedis(ctx, &storage.Config{Host: host, Port: portNum})
+
+	for !storage.Connected() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	go startPubSubLoop(ctx, &storage.RedisCluster{})
+
+	conn := <-subscribed
+
+	// no reload is queued by the first subscription.
+	select {
+	case <-reloadQueue:
+		t.Fatal("reload queued on the first subscription")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// kill the pub/sub connection, the subscription is established again and a reload is queued
+	// for the notifications missed meanwhile.
+	conn.Close()
+
+	select {
+	case <-subscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the subscription was not established again")
+	}
+
+	select {
+	case fn := <-reloadQueue:
+		if fn != nil {
+			t.Error("queued reload has a callback, want a full reload")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload queued after the subscription was established again")
+	}
+}
+
+func TestStartPubSubLoop_MemoryStore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := &storage.MemoryStore{}
+
+	go startPubSubLoop(ctx, store)
+
+	notification := notice.Notification{Command: notice.PolicyChanged}
+	notification.Sign()
+
+	message, _ := json.Marshal(notification)
+
+	// the notifications published before the subscription is established are lost.
+	tick := time.NewTicker(20 * time.Millisecond)
+	defer tick.Stop()
+
+	deadline := time.After(5 * time.Second)
+
+publish:
+	for {
+		if err := store.Publish(ctx, notice.RedisPubSubChannel, string(message)); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+
+		select {
+		case <-reloadQueue:
+			break publish
+		case <-tick.C:
+		case <-deadline:
+			t.Fatal("no reload queued by the notification")
+		}
+	}
+
+	cancel()
+
+	// drain the reloads queued by the notifications published meanwhile.
+	for {
+		select {
+		case <-reloadQueue:
+		case <-time.After(100 * time.Millisecond):
+			return
+		}
+	}
+}
+
+// slowLoader takes delay to reload, it counts the reloads completed.
+type slowLoader struct {
+	delay     time.Duration
+	completed int32
+}
+
+func (s *slowLoader) Reload(acceptPartial bool) (*ReloadResult, error) {
+	time.Sleep(s.delay)
+	atomic.AddInt32(&s.completed, 1)
+
+	return &ReloadResult{Applied: true}, nil
+}
+
+func Test_handleRedisEvent(t *testing.T) {
+	tests := []struct {
+		name       string
+		command    notice.Command
+		wantReload bool
+	}{
+		{name: "policy changed", command: notice.PolicyChanged, wantReload: true},
+		{name: "secret changed", command: notice.SecretChanged, wantReload: true},
+		{name: "key rotated", command: notice.KeyRotated, wantReload: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, _ := json.Marshal(notice.Notification{Command: tt.command})
+			handled := make(chan notice.Command, 1)
+
+			go handleRedisEvent(&redis.Message{Payload: string(message)}, func(c notice.Command) { handled <- c }, nil)
+
+			select {
+			case <-reloadQueue:
+				if !tt.wantReload {
+					t.Fatalf("handleRedisEvent() queued a reload for %s", tt.command)
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.wantReload {
+					t.Fatalf("handleRedisEvent() queued no reload for %s", tt.command)
+				}
+			}
+
+			if got := <-handled; got != tt.command {
+				t.Errorf("handleRedisEvent() handled %s, want %s", got, tt.command)
+			}
+		})
+	}
+}
+
+func TestLoad_Shutdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		delay   time.Duration
+		timeout time.Duration
+		// queued queues a reload with a callback from a goroutine stopped by Shutdown, like
+		// a notification handler blocked queuing it.
+		queued  bool
+		wantErr error
+	}{
+		{name: "final reload", delay: 200 * time.Millisecond, timeout: 5 * time.Second},
+		{name: "queued reload", delay: 200 * time.Millisecond, timeout: 5 * time.Second, queued: true},
+		{
+			name:    "timeout",
+			delay:   2 * time.Second,
+			timeout: 100 * time.Millisecond,
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := &slowLoader{}
+			l := NewLoader(context.Background(), loader, false)
+			l.Start()
+
+			atomic.StoreInt32(&loader.completed, 0)
+			loader.delay = tt.delay
+
+			var called int32
+
+			if tt.queued {
+				l.run(func() {
+					reloadQueue <- func() { atomic.StoreInt32(&called, 1) }
+				})
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := l.Shutdown(ctx)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Shutdown() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				if elapsed := time.Since(start); elapsed > tt.delay {
+					t.Errorf("Shutdown() returned after %v, want before the reload completes", elapsed)
+				}
+
+				return
+			}
+
+			if completed := atomic.LoadInt32(&loader.completed); completed != 1 {
+				t.Errorf("%d reloads completed before Shutdown() returned, want 1", completed)
+			}
+
+			if tt.queued && atomic.LoadInt32(&called) != 1 {
+				t.Error("the callback of the queued reload was not called before Shutdown() returned")
+			}
+		})
+	}
+}
+
+func TestQueueReload(t *testing.T) {
+	queued := make(chan bool)
+
+	go func() {
+		queued <- QueueReload(context.Background())
+	}()
+
+	select {
+	case fn := <-reloadQueue:
+		if fn != nil {
+			t.Error("queued reload has a callback, want a full reload")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload queued")
+	}
+
+	if !<-queued {
+		t.Error("QueueReload() = false, want true")
+	}
+
+	// nothing reads the queue, the reload is given up once ctx is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if QueueReload(ctx) {
+		t.Error("QueueReload() with a canceled context = true, want false")
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package load
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// Define the ways the cache is kept in sync with iam-apiserver.
+const (
+	// SyncModeReload reloads all the secrets and policies on every change notification.
+	SyncModeReload = "reload"
+	// SyncModeWatch applies the changes streamed by iam-apiserver, and falls back to
+	// reloads while the stream is broken.
+	SyncModeWatch = "watch"
+)
+
+// CacheOptions contains configuration items related to the secrets and policies cache.
+type CacheOptions struct {
+	SyncMode     string        `json:"sync-mode"     mapstructure:"sync-mode"`
+	WatchTimeout time.Duration `json:"watch-timeout" mapstructure:"watch-timeout"`
+}
+
+// NewCacheOptions creates a CacheOptions object with default parameters.
+func NewCacheOptions() *CacheOptions {
+	return &CacheOptions{
+		SyncMode:     SyncModeReload,
+		WatchTimeout: 30 * time.Second,
+	}
+}
+
+// Validate is used to parse and validate the parameters entered by the user at
+// the command line when the program starts.
+func (o *CacheOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errors := []error{}
+
+	if o.SyncMode != SyncModeReload && o.SyncMode != SyncModeWatch {
+		errors = append(errors, fmt.Errorf("--cache.sync-mode %s must be %s or %s", o.SyncMode, SyncModeWatch, SyncModeReload))
+	}
+
+	if o.WatchTimeout <= 0 {
+		errors = append(errors, fmt.Errorf("--cache.watch-timeout %v must be greater than 0", o.WatchTimeout))
+	}
+
+	return errors
+}
+
+// AddFlags adds flags related to the cache for a specific authz server to the
+// specified FlagSet.
+func (o *CacheOptions) AddFlags(fs *pflag.FlagSet) {
+	if fs == nil {
+		return
+	}
+
+	fs.StringVar(&o.SyncMode, "cache.sync-mode", o.SyncMode, ""+
+		"How the secrets and policies are kept in sync with the iam rpc server, one of watch or reload. "+
+		"In watch mode the changes are streamed by the rpc server, and the cache is reloaded while the stream is broken.")
+
+	fs.DurationVar(&o.WatchTimeout, "cache.watch-timeout", o.WatchTimeout, ""+
+		"The watch stream is considered broken if no event or ping is received within this duration. "+
+		"It should be larger than the ping interval of the rpc server (10s).")
+}
@@ -5,50 +5,24 @@
 package load
 
 import (
-	"crypto"
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
 	"errors"
 
 	redis "github.com/go-redis/redis/v7"
 	"github.com/marmotedu/component-base/pkg/json"
 
+	"github.com/marmotedu/iam/internal/pkg/notice"
 	"github.com/marmotedu/iam/pkg/log"
 	"github.com/marmotedu/iam/pkg/storage"
 )
 
-// NotificationCommand defines a new notification type.
-type NotificationCommand string
-
-// Define Redis pub/sub events.
-const (
-	RedisPubSubChannel                      = "iam.cluster.notifications"
-	NoticePolicyChanged NotificationCommand = "PolicyChanged"
-	NoticeSecretChanged NotificationCommand = "SecretChanged"
-)
-
-// Notification is a type that encodes a message published to a pub sub channel (shared between implementations).
-type Notification struct {
-	Command       NotificationCommand `json:"command"`
-	Payload       string              `json:"payload"`
-	Signature     string              `json:"signature"`
-	SignatureAlgo crypto.Hash         `json:"algorithm"`
-}
-
-// Sign sign Notification with SHA256 algorithm.
-func (n *Notification) Sign() {
-	n.SignatureAlgo = crypto.SHA256
-	hash := sha256.Sum256([]byte(string(n.Command) + n.Payload))
-	n.Signature = hex.EncodeToString(hash[:])
-}
-
-func handleRedisEvent(v interface{}, handled func(NotificationCommand), reloaded func()) {
+func handleRedisEvent(v interface{}, handled func(notice.Command), reloaded func()) {
 	message, ok := v.(*redis.Message)
 	if !ok {
 		return
 	}
 
-	notif := Notification{}
+	notif := notice.Notification{}
 	if err := json.Unmarshal([]byte(message.Payload), &notif); err != nil {
 		log.Errorf("Unmarshalling message body failed, malformed: ", err)
 
@@ -57,9 +31,12 @@ func handleRedisEvent(v interface{}, handled func(NotificationCommand), reloaded
 	log.Infow("receive redis message", "command", notif.Command, "payload", message.Payload)
 
 	switch notif.Command {
-	case NoticePolicyChanged, NoticeSecretChanged:
+	case notice.PolicyChanged, notice.SecretChanged:
 		log.Info("Reloading secrets and policies")
 		reloadQueue <- reloaded
+	case notice.KeyRotated:
+		// the secrets and policies do not depend on the jwt signing key, nothing to reload.
+		log.Infow("Jwt signing key of iam-apiserver rotated", "kid", notif.Payload)
 	default:
 		log.Warnf("Unknown notification command: %q", notif.Command)
 
@@ -80,7 +57,7 @@ type RedisNotifier struct {
 
 // Notify will send a notification to a channel.
 func (r *RedisNotifier) Notify(notif interface{}) bool {
-	if n, ok := notif.(Notification); ok {
+	if n, ok := notif.(notice.Notification); ok {
 		n.Sign()
 		notif = n
 	}
@@ -94,7 +71,7 @@ func (r *RedisNotifier) Notify(notif interface{}) bool {
 
 	log.Debugf("Sending notification: %v", notif)
 
-	if err := r.store.Publish(r.channel, string(toSend)); err != nil {
+	if err := r.store.Publish(context.Background(), r.channel, string(toSend)); err != nil {
 		if !errors.Is(err, storage.ErrRedisIsDown) {
 			log.Errorf("Could not send notification: %s", err.Error())
 		}
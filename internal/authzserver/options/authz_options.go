@@ -0,0 +1,119 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"github.com/marmotedu/iam/internal/pkg/conditions"
+	"github.com/marmotedu/iam/internal/pkg/jwt"
+)
+
+// AuthzOptions contains configuration items related to the authorization apis.
+type AuthzOptions struct {
+	WSMaxConnections int      `json:"ws-max-connections" mapstructure:"ws-max-connections"`
+	AdminUsers       []string `json:"admin-users"        mapstructure:"admin-users"`
+	PreviewRateLimit float64  `json:"preview-rate-limit" mapstructure:"preview-rate-limit"`
+	PreviewRateBurst int      `json:"preview-rate-burst" mapstructure:"preview-rate-burst"`
+	ServiceKeyFiles  []string `json:"service-key-files"  mapstructure:"service-key-files"`
+
+	RemoteConditionURLs     []string `json:"remote-condition-urls"     mapstructure:"remote-condition-urls"`
+	RemoteConditionUsername string   `json:"remote-condition-username" mapstructure:"remote-condition-username"`
+	RemoteConditionPassword string   `json:"remote-condition-password" mapstructure:"remote-condition-password"`
+}
+
+// NewAuthzOptions creates a AuthzOptions object with default parameters.
+func NewAuthzOptions() *AuthzOptions {
+	return &AuthzOptions{
+		WSMaxConnections: 100,
+		AdminUsers:       []string{"admin"},
+		PreviewRateLimit: 10,
+		PreviewRateBurst: 20,
+		ServiceKeyFiles:  []string{},
+
+		RemoteConditionURLs: []string{},
+	}
+}
+
+// RemoteEndpoints returns the endpoints the remote conditions of the policies may fetch their conditions from.
+func (o *AuthzOptions) RemoteEndpoints() conditions.RemoteEndpoints {
+	return conditions.RemoteEndpoints{
+		URLs:     o.RemoteConditionURLs,
+		Username: o.RemoteConditionUsername,
+		Password: o.RemoteConditionPassword,
+	}
+}
+
+// Validate is used to parse and validate the parameters entered by the user at
+// the command line when the program starts.
+func (o *AuthzOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errors := []error{}
+
+	if o.WSMaxConnections <= 0 {
+		errors = append(errors, fmt.Errorf("--authz.ws-max-connections %d must be greater than 0", o.WSMaxConnections))
+	}
+
+	if o.PreviewRateLimit <= 0 {
+		errors = append(errors, fmt.Errorf("--authz.preview-rate-limit %v must be greater than 0", o.PreviewRateLimit))
+	}
+
+	if o.PreviewRateBurst <= 0 {
+		errors = append(errors, fmt.Errorf("--authz.preview-rate-burst %d must be greater than 0", o.PreviewRateBurst))
+	}
+
+	for _, file := range o.ServiceKeyFiles {
+		if _, err := jwt.LoadRSAPublicKey(file); err != nil {
+			errors = append(errors, fmt.Errorf("--authz.service-key-files: %w", err))
+		}
+	}
+
+	if err := o.RemoteEndpoints().Validate(); err != nil {
+		errors = append(errors, fmt.Errorf("--authz.remote-condition-urls: %w", err))
+	}
+
+	return errors
+}
+
+// AddFlags adds flags related to the authorization apis to the specified FlagSet.
+func (o *AuthzOptions) AddFlags(fs *pflag.FlagSet) {
+	if fs == nil {
+		return
+	}
+
+	fs.IntVar(&o.WSMaxConnections, "authz.ws-max-connections", o.WSMaxConnections, ""+
+		"The maximum number of concurrent websocket connections streaming the authorization analytics records, "+
+		"the connections over the limit are rejected.")
+
+	fs.StringSliceVar(&o.AdminUsers, "authz.admin-users", o.AdminUsers, ""+
+		"The users allowed to inspect and dump the cached secrets and policies metadata from the /debug/cache apis.")
+
+	fs.Float64Var(&o.PreviewRateLimit, "authz.preview-rate-limit", o.PreviewRateLimit, ""+
+		"The maximum number of authorization previews per second, the previews are more expensive than "+
+		"the authorizations so they are rate-limited separately.")
+
+	fs.IntVar(&o.PreviewRateBurst, "authz.preview-rate-burst", o.PreviewRateBurst, ""+
+		"The maximum burst size of the authorization previews.")
+
+	fs.StringSliceVar(&o.ServiceKeyFiles, "authz.service-key-files", o.ServiceKeyFiles, ""+
+		"The PEM files of the rsa public keys registered to verify the service tokens issued by iam-apiserver, "+
+		"they match the --service-token.private-key-file of the iam-apiserver instances. The service tokens are "+
+		"rejected if none is set.")
+
+	fs.StringSliceVar(&o.RemoteConditionURLs, "authz.remote-condition-urls", o.RemoteConditionURLs, ""+
+		"The base urls of the endpoints the RemoteConditions of the policies may fetch their conditions from, "+
+		"the url of a RemoteCondition must have the scheme and the host of one of them and a path below its path. "+
+		"The RemoteConditions are never fulfilled if none is set.")
+
+	fs.StringVar(&o.RemoteConditionUsername, "authz.remote-condition-username", o.RemoteConditionUsername, ""+
+		"The username answering the HTTP digest authentication challenges of the --authz.remote-condition-urls.")
+
+	fs.StringVar(&o.RemoteConditionPassword, "authz.remote-condition-password", o.RemoteConditionPassword, ""+
+		"The password answering the HTTP digest authentication challenges of the --authz.remote-condition-urls.")
+}
@@ -6,10 +6,14 @@
 package options
 
 import (
+	"time"
+
 	cliflag "github.com/marmotedu/component-base/pkg/cli/flag"
 	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/marmotedu/errors"
 
 	"github.com/marmotedu/iam/internal/authzserver/analytics"
+	"github.com/marmotedu/iam/internal/authzserver/load"
 	genericoptions "github.com/marmotedu/iam/internal/pkg/options"
 	"github.com/marmotedu/iam/internal/pkg/server"
 	"github.com/marmotedu/iam/pkg/log"
@@ -17,29 +21,44 @@ import (
 
 // Options runs a authzserver.
 type Options struct {
-	RPCServer               string                                 `json:"rpcserver"      mapstructure:"rpcserver"`
-	ClientCA                string                                 `json:"client-ca-file" mapstructure:"client-ca-file"`
-	GenericServerRunOptions *genericoptions.ServerRunOptions       `json:"server"         mapstructure:"server"`
-	InsecureServing         *genericoptions.InsecureServingOptions `json:"insecure"       mapstructure:"insecure"`
-	SecureServing           *genericoptions.SecureServingOptions   `json:"secure"         mapstructure:"secure"`
-	RedisOptions            *genericoptions.RedisOptions           `json:"redis"          mapstructure:"redis"`
-	FeatureOptions          *genericoptions.FeatureOptions         `json:"feature"        mapstructure:"feature"`
-	Log                     *log.Options                           `json:"log"            mapstructure:"log"`
-	AnalyticsOptions        *analytics.AnalyticsOptions            `json:"analytics"      mapstructure:"analytics"`
+	RPCServer               string                                 `json:"rpcserver"                 mapstructure:"rpcserver"`
+	RPCToken                string                                 `json:"-"                         mapstructure:"rpcserver-token"`
+	RPCTokenFile            string                                 `json:"rpcserver-token-file"      mapstructure:"rpcserver-token-file"`
+	ClientCA                string                                 `json:"client-ca-file"            mapstructure:"client-ca-file"`
+	RPCMaxRetries           int                                    `json:"rpcserver-max-retries"     mapstructure:"rpcserver-max-retries"`
+	RPCInitialBackoff       time.Duration                          `json:"rpcserver-initial-backoff" mapstructure:"rpcserver-initial-backoff"`
+	AcceptPartialReload     bool                                   `json:"accept-partial-reload"     mapstructure:"accept-partial-reload"`
+	GenericServerRunOptions *genericoptions.ServerRunOptions       `json:"server"                    mapstructure:"server"`
+	InsecureServing         *genericoptions.InsecureServingOptions `json:"insecure"                  mapstructure:"insecure"`
+	SecureServing           *genericoptions.SecureServingOptions   `json:"secure"                    mapstructure:"secure"`
+	RedisOptions            *genericoptions.RedisOptions           `json:"redis"                     mapstructure:"redis"`
+	FeatureOptions          *genericoptions.FeatureOptions         `json:"feature"                   mapstructure:"feature"`
+	AccessLogOptions        *genericoptions.AccessLogOptions       `json:"access-log"                mapstructure:"access-log"`
+	Log                     *log.Options                           `json:"log"                       mapstructure:"log"`
+	AnalyticsOptions        *analytics.AnalyticsOptions            `json:"analytics"                 mapstructure:"analytics"`
+	CacheOptions            *load.CacheOptions                     `json:"cache"                     mapstructure:"cache"`
+	AuthzOptions            *AuthzOptions                          `json:"authz"                     mapstructure:"authz"`
 }
 
 // NewOptions creates a new Options object with default parameters.
 func NewOptions() *Options {
 	o := Options{
 		RPCServer:               "127.0.0.1:8081",
+		RPCToken:                "",
 		ClientCA:                "",
+		RPCMaxRetries:           3,
+		RPCInitialBackoff:       100 * time.Millisecond,
+		AcceptPartialReload:     false,
 		GenericServerRunOptions: genericoptions.NewServerRunOptions(),
 		InsecureServing:         genericoptions.NewInsecureServingOptions(),
 		SecureServing:           genericoptions.NewSecureServingOptions(),
 		RedisOptions:            genericoptions.NewRedisOptions(),
 		FeatureOptions:          genericoptions.NewFeatureOptions(),
+		AccessLogOptions:        genericoptions.NewAccessLogOptions(),
 		Log:                     log.NewOptions(),
 		AnalyticsOptions:        analytics.NewAnalyticsOptions(),
+		CacheOptions:            load.NewCacheOptions(),
+		AuthzOptions:            NewAuthzOptions(),
 	}
 
 	return &o
@@ -54,8 +73,11 @@ func (o *Options) ApplyTo(c *server.Config) error {
 func (o *Options) Flags() (fss cliflag.NamedFlagSets) {
 	o.GenericServerRunOptions.AddFlags(fss.FlagSet("generic"))
 	o.AnalyticsOptions.AddFlags(fss.FlagSet("analytics"))
+	o.CacheOptions.AddFlags(fss.FlagSet("cache"))
+	o.AuthzOptions.AddFlags(fss.FlagSet("authz"))
 	o.RedisOptions.AddFlags(fss.FlagSet("redis"))
 	o.FeatureOptions.AddFlags(fss.FlagSet("features"))
+	o.AccessLogOptions.AddFlags(fss.FlagSet("access log"))
 	o.InsecureServing.AddFlags(fss.FlagSet("insecure serving"))
 	o.SecureServing.AddFlags(fss.FlagSet("secure serving"))
 	o.Log.AddFlags(fss.FlagSet("logs"))
@@ -65,10 +87,26 @@ func (o *Options) Flags() (fss cliflag.NamedFlagSets) {
 	fs := fss.FlagSet("misc")
 	fs.StringVar(&o.RPCServer, "rpcserver", o.RPCServer, "The address of iam rpc server. "+
 		"The rpc server can provide all the secrets and policies to use.")
+	fs.StringVar(&o.RPCToken, "rpcserver-token", o.RPCToken, ""+
+		"The bearer token used to authenticate to the iam rpc server. It must be one of the --grpc.tokens "+
+		"of iam-apiserver. It is recommended to set it in the configuration file rather than in the command line.")
+	fs.StringVar(&o.RPCTokenFile, "rpcserver-token-file", o.RPCTokenFile, ""+
+		"File containing the bearer token used to authenticate to the iam rpc server, e.g. a mounted secret, "+
+		"instead of --rpcserver-token.")
+	fs.IntVar(&o.RPCMaxRetries, "rpcserver-max-retries", o.RPCMaxRetries, ""+
+		"The maximum number of retries of an rpc to the iam rpc server failed with a transient error, "+
+		"like an unavailable server or an exceeded deadline. 0 disables the retries.")
+	fs.DurationVar(&o.RPCInitialBackoff, "rpcserver-initial-backoff", o.RPCInitialBackoff, ""+
+		"The delay before the first retry of an rpc to the iam rpc server, it grows exponentially "+
+		"with the next retries.")
 	fs.StringVar(&o.ClientCA, "client-ca-file", o.ClientCA, ""+
 		"If set, any request presenting a client certificate signed by one of "+
 		"the authorities in the client-ca-file is authenticated with an identity "+
 		"corresponding to the CommonName of the client certificate.")
+	fs.BoolVar(&o.AcceptPartialReload, "accept-partial-reload", o.AcceptPartialReload, ""+
+		"If true, when only secrets or only policies are reloaded successfully from the iam rpc server, "+
+		"the reloaded ones are served and the others keep their previous content. "+
+		"Otherwise the whole reload is rejected.")
 
 	return fss
 }
@@ -81,5 +119,17 @@ func (o *Options) String() string {
 
 // Complete set default Options.
 func (o *Options) Complete() error {
-	return o.SecureServing.Complete()
+	var errs []error
+
+	for _, complete := range []func() error{
+		func() error { return genericoptions.CompleteSecret(&o.RPCToken, o.RPCTokenFile, "rpcserver-token") },
+		o.RedisOptions.Complete,
+		o.SecureServing.Complete,
+	} {
+		if err := complete(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.NewAggregate(errs)
 }
@@ -0,0 +1,88 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/marmotedu/iam/pkg/app"
+)
+
+const testConfig = `rpcserver: config:8081
+rpcserver-max-retries: 5
+rpcserver-initial-backoff: 1s
+redis:
+  host: config
+  password: config
+authz:
+  preview-rate-limit: 5
+`
+
+func TestOptions_precedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iam-authz-server.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	defer viper.Reset()
+
+	t.Setenv("IAM_AUTHZ_SERVER_RPCSERVER", "env:8081")
+	t.Setenv("IAM_AUTHZ_SERVER_RPCSERVER_TOKEN", "env-token")
+	t.Setenv("IAM_AUTHZ_SERVER_RPCSERVER_MAX_RETRIES", "7")
+	t.Setenv("IAM_AUTHZ_SERVER_REDIS_PASSWORD", "env-secret")
+	t.Setenv("IAM_AUTHZ_SERVER_AUTHZ_ADMIN_USERS", "admin,colin")
+
+	opts := NewOptions()
+	defaults := NewOptions()
+
+	application := app.NewApp("IAM Authorization Server", "iam-authz-server",
+		app.WithOptions(opts),
+		app.WithSilence(),
+		app.WithNoVersion(),
+		app.WithRunFunc(func(string) error { return nil }),
+	)
+	cmd := application.Command()
+	cmd.SetArgs([]string{"--config", path, "--rpcserver", "flag:8081", "--authz.preview-rate-limit", "20"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	// flags > environment variables > configuration file > defaults.
+	tests := []struct {
+		key  string
+		got  interface{}
+		want interface{}
+	}{
+		{key: "rpcserver", got: opts.RPCServer, want: "flag:8081"},
+		{key: "rpcserver-token", got: opts.RPCToken, want: "env-token"},
+		{key: "rpcserver-max-retries", got: opts.RPCMaxRetries, want: 7},
+		{key: "rpcserver-initial-backoff", got: opts.RPCInitialBackoff, want: time.Second},
+		{key: "redis.host", got: opts.RedisOptions.Host, want: "config"},
+		{key: "redis.password", got: opts.RedisOptions.Password, want: "env-secret"},
+		{key: "redis.database", got: opts.RedisOptions.Database, want: defaults.RedisOptions.Database},
+		{key: "authz.preview-rate-limit", got: opts.AuthzOptions.PreviewRateLimit, want: float64(20)},
+		{key: "authz.admin-users", got: strings.Join(opts.AuthzOptions.AdminUsers, ","), want: "admin,colin"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.key, tt.got, tt.want)
+		}
+	}
+
+	// the secrets set by the environment are not printed with the options.
+	printed := opts.String()
+	for _, secret := range []string{"env-token", "env-secret"} {
+		if strings.Contains(printed, secret) {
+			t.Errorf("String() = %s, prints the secret %s", printed, secret)
+		}
+	}
+}
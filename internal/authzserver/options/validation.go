@@ -4,17 +4,56 @@
 
 package options
 
+import (
+	"fmt"
+
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
 // Validate checks Options and return a slice of found errs.
 func (o *Options) Validate() []error {
 	var errs []error
 
+	if o.RPCMaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("--rpcserver-max-retries %d must not be negative", o.RPCMaxRetries))
+	}
+
+	if o.RPCInitialBackoff <= 0 {
+		errs = append(errs, fmt.Errorf("--rpcserver-initial-backoff %v must be greater than 0", o.RPCInitialBackoff))
+	}
+
 	errs = append(errs, o.GenericServerRunOptions.Validate()...)
 	errs = append(errs, o.InsecureServing.Validate()...)
 	errs = append(errs, o.SecureServing.Validate()...)
 	errs = append(errs, o.RedisOptions.Validate()...)
 	errs = append(errs, o.FeatureOptions.Validate()...)
+	errs = append(errs, o.AccessLogOptions.Validate()...)
 	errs = append(errs, o.Log.Validate()...)
 	errs = append(errs, o.AnalyticsOptions.Validate()...)
+	errs = append(errs, o.CacheOptions.Validate()...)
+	errs = append(errs, o.AuthzOptions.Validate()...)
+	errs = append(errs, o.validateAnalyticsStorage()...)
 
 	return errs
 }
+
+// validateAnalyticsStorage checks the analytics records can be read by iam-pump, from the redis server they
+// are pushed to.
+func (o *Options) validateAnalyticsStorage() []error {
+	if o.AnalyticsOptions == nil || !o.AnalyticsOptions.Enable {
+		return nil
+	}
+
+	redis := o.RedisOptions
+	if redis.Type == storage.TypeMemory {
+		return []error{fmt.Errorf("--analytics.enable requires --redis.type %s, got %q: "+
+			"the records kept in memory can not be read by iam-pump", storage.TypeRedis, redis.Type)}
+	}
+
+	if redis.Port == 0 && len(redis.Addrs) == 0 && len(redis.SentinelAddrs) == 0 {
+		return []error{fmt.Errorf("--analytics.enable requires a redis server, " +
+			"set by --redis.host and --redis.port, --redis.addrs or --redis.sentinel-addrs")}
+	}
+
+	return nil
+}
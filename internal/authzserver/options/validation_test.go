@@ -0,0 +1,101 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(o *Options)
+		// wantErrs are substrings of the errors, in order
+		wantErrs []string
+	}{
+		{name: "defaults", modify: func(o *Options) {}},
+		{
+			name:     "negative rpc retries",
+			modify:   func(o *Options) { o.RPCMaxRetries = -1 },
+			wantErrs: []string{"--rpcserver-max-retries -1 must not be negative"},
+		},
+		{
+			name:     "unknown cache sync mode",
+			modify:   func(o *Options) { o.CacheOptions.SyncMode = "poll" },
+			wantErrs: []string{"--cache.sync-mode poll must be"},
+		},
+		{
+			name:     "unknown analytics storage backend",
+			modify:   func(o *Options) { o.AnalyticsOptions.StorageBackend = "kafka" },
+			wantErrs: []string{`--analytics.storage-backend "kafka" must be list or stream`},
+		},
+		{
+			name: "redis certificate without key",
+			modify: func(o *Options) {
+				o.RedisOptions.UseSSL = true
+				o.RedisOptions.CertFile = "/etc/iam/cert/redis-client.pem"
+			},
+			wantErrs: []string{
+				`--redis.ssl-cert-file "/etc/iam/cert/redis-client.pem" and --redis.ssl-key-file "" must be set together`,
+			},
+		},
+		{
+			name:     "analytics with the memory storage",
+			modify:   func(o *Options) { o.RedisOptions.Type = storage.TypeMemory },
+			wantErrs: []string{`--analytics.enable requires --redis.type redis, got "memory"`},
+		},
+		{
+			name: "memory storage without analytics",
+			modify: func(o *Options) {
+				o.RedisOptions.Type = storage.TypeMemory
+				o.AnalyticsOptions.Enable = false
+			},
+		},
+		{
+			name:     "analytics without redis server",
+			modify:   func(o *Options) { o.RedisOptions.Port = 0 },
+			wantErrs: []string{"--analytics.enable requires a redis server"},
+		},
+		{
+			name: "all aggregated",
+			modify: func(o *Options) {
+				o.RPCMaxRetries = -1
+				o.RedisOptions.EnableCluster, o.RedisOptions.Database = true, 1
+				o.AnalyticsOptions.FlushInterval = 0
+				o.RedisOptions.Type = storage.TypeMemory
+			},
+			wantErrs: []string{
+				"--rpcserver-max-retries -1 must not be negative",
+				"--redis.database 1 can not be used with --redis.enable-cluster",
+				"--analytics.flush-interval 0 must be between 1 and 1000",
+				`--analytics.enable requires --redis.type redis, got "memory"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewOptions()
+			if err := o.Complete(); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+
+			tt.modify(o)
+
+			errs := o.Validate()
+			if len(errs) != len(tt.wantErrs) {
+				t.Fatalf("Validate() = %v, want %d errors", errs, len(tt.wantErrs))
+			}
+
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), tt.wantErrs[i]) {
+					t.Errorf("Validate() error %d = %q, want it to contain %q", i, err, tt.wantErrs[i])
+				}
+			}
+		})
+	}
+}
@@ -8,23 +8,34 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/marmotedu/component-base/pkg/core"
 	"github.com/marmotedu/errors"
+	"golang.org/x/time/rate"
 
+	"github.com/marmotedu/iam/internal/authzserver/analytics"
+	analyticscontroller "github.com/marmotedu/iam/internal/authzserver/controller/v1/analytics"
 	"github.com/marmotedu/iam/internal/authzserver/controller/v1/authorize"
+	cachecontroller "github.com/marmotedu/iam/internal/authzserver/controller/v1/cache"
 	"github.com/marmotedu/iam/internal/authzserver/load/cache"
+	"github.com/marmotedu/iam/internal/authzserver/options"
 	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
-func initRouter(g *gin.Engine) {
-	installMiddleware(g)
-	installController(g)
-}
+// previewBodyBytes is the maximum size of the preview and trace requests, which can carry a batch of
+// ephemeral policies.
+const previewBodyBytes = 8 << 20
+
+// initRouter installs the routes, it returns the limiter of the preview and trace requests, which is
+// tuned on the configuration reloads.
+func initRouter(g *gin.Engine, authzOptions *options.AuthzOptions) *rate.Limiter {
+	previewLimiter := rate.NewLimiter(rate.Limit(authzOptions.PreviewRateLimit), authzOptions.PreviewRateBurst)
+	installController(g, authzOptions, previewLimiter)
 
-func installMiddleware(g *gin.Engine) {
+	return previewLimiter
 }
 
-func installController(g *gin.Engine) *gin.Engine {
-	auth := newCacheAuth()
+func installController(g *gin.Engine, authzOptions *options.AuthzOptions, previewLimiter *rate.Limiter) *gin.Engine {
+	auth := newCacheAuth(authzOptions.ServiceKeyFiles)
 	g.NoRoute(auth.AuthFunc(), func(c *gin.Context) {
 		core.WriteResponse(c, errors.WithCode(code.ErrPageNotFound, "page not found."), nil)
 	})
@@ -40,6 +51,35 @@ func installController(g *gin.Engine) *gin.Engine {
 
 		// Router for authorization
 		apiv1.POST("/authz", authzController.Authorize)
+
+		// Router for evaluating a chain of authorizations one after the other in a single call
+		apiv1.POST("/authz/bulk-check", authzController.BulkCheck)
+
+		// Router for previewing an authorization with ephemeral policies, which is more expensive
+		previewLimit := middleware.LimitWith(previewLimiter)
+		previewBody := middleware.BodyLimit(previewBodyBytes)
+		apiv1.POST("/authz/preview", previewLimit, previewBody, authzController.Preview)
+
+		// Router for tracing the evaluation of each policy of an authorization, rate limited like the previews
+		apiv1.POST("/authz/trace", previewLimit, previewBody, authzController.Trace)
+
+		wsController := analyticscontroller.NewWSController(analytics.GetBroadcaster(), authzOptions.WSMaxConnections)
+
+		// Router for streaming the authorization analytics records over websocket
+		apiv1.GET("/ws/analytics", wsController.Stream)
+	}
+
+	debug := g.Group("/debug", auth.AuthFunc())
+	{
+		cacheController := cachecontroller.NewCacheController(cacheIns)
+		admin := newAdminAuth(authzOptions.AdminUsers)
+
+		// Router for inspecting the cached secrets and policies
+		debug.GET("/cache/status", admin, cacheController.Status)
+
+		// Router for dumping the cached secrets and policies metadata
+		debug.GET("/cache/secrets", admin, cacheController.Secrets)
+		debug.GET("/cache/policies", admin, cacheController.Policies)
 	}
 
 	return g
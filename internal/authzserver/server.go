@@ -6,33 +6,56 @@ package authzserver
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/marmotedu/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	"golang.org/x/time/rate"
 
 	"github.com/marmotedu/iam/internal/authzserver/analytics"
 	"github.com/marmotedu/iam/internal/authzserver/config"
 	"github.com/marmotedu/iam/internal/authzserver/load"
 	"github.com/marmotedu/iam/internal/authzserver/load/cache"
+	"github.com/marmotedu/iam/internal/authzserver/options"
+	"github.com/marmotedu/iam/internal/authzserver/store"
 	"github.com/marmotedu/iam/internal/authzserver/store/apiserver"
+	"github.com/marmotedu/iam/internal/pkg/conditions"
 	genericoptions "github.com/marmotedu/iam/internal/pkg/options"
 	genericapiserver "github.com/marmotedu/iam/internal/pkg/server"
+	"github.com/marmotedu/iam/pkg/app"
 	"github.com/marmotedu/iam/pkg/log"
 	"github.com/marmotedu/iam/pkg/shutdown"
 	"github.com/marmotedu/iam/pkg/shutdown/shutdownmanagers/posixsignal"
+	"github.com/marmotedu/iam/pkg/shutdown/shutdownmanagers/prestop"
 	"github.com/marmotedu/iam/pkg/storage"
 )
 
 // RedisKeyPrefix defines the prefix key in redis for analytics data.
-const RedisKeyPrefix = "analytics-"
+const RedisKeyPrefix = analytics.RedisKeyPrefix
+
+// loaderShutdownTimeout bounds the time spent to perform the final reload on shutdown.
+const loaderShutdownTimeout = 10 * time.Second
 
 type authzServer struct {
 	gs               *shutdown.GracefulShutdown
-	rpcServer        string
-	clientCA         string
+	storeFactory     store.ClientFactory
+	acceptPartial    bool
+	cacheOptions     *load.CacheOptions
 	redisOptions     *genericoptions.RedisOptions
 	genericAPIServer *genericapiserver.GenericAPIServer
+	// routes reports the error rates of the routes of genericAPIServer.
+	routes           *genericapiserver.InstrumentedEngine
 	analyticsOptions *analytics.AnalyticsOptions
+	authzOptions     *options.AuthzOptions
+	loader           *load.Load
 	redisCancelFunc  context.CancelFunc
+	// reloader applies the configuration changes on SIGHUP, and on the changes of the configuration file
+	// if watchConfig is set, e.g. of previewLimiter.
+	reloader       *app.Reloader
+	watchConfig    bool
+	previewLimiter *rate.Limiter
 }
 
 type preparedAuthzServer struct {
@@ -43,6 +66,10 @@ type preparedAuthzServer struct {
 func createAuthzServer(cfg *config.Config) (*authzServer, error) {
 	gs := shutdown.New()
 	gs.AddShutdownManager(posixsignal.NewPosixSignalManager())
+	gs.SetErrorHandler(shutdown.ErrorFunc(func(err error) {
+		log.Warnf("Shutdown error: %s", err.Error())
+	}))
+	gs.SetTimeout(cfg.GenericServerRunOptions.GracefulShutdownTimeout)
 
 	genericConfig, err := buildGenericConfig(cfg)
 	if err != nil {
@@ -54,22 +81,57 @@ func createAuthzServer(cfg *config.Config) (*authzServer, error) {
 		return nil, err
 	}
 
+	// the routes installed by PrepareRun report their error rates.
+	var registerer prometheus.Registerer
+	if cfg.FeatureOptions.EnableMetrics {
+		registerer = prometheus.DefaultRegisterer
+	}
+	routes := genericapiserver.NewInstrumentedEngine(genericServer.Engine, registerer)
+
+	// the preStop hook marks the server not ready before SIGTERM, for the traffic to be drained first.
+	if cfg.FeatureOptions.EnablePreStop {
+		gs.AddShutdownManager(prestop.NewPreStopManager(cfg.FeatureOptions.PreStopAddress,
+			cfg.FeatureOptions.PreStopDelay, genericServer.StopReadiness))
+	}
+
+	storeFactory := apiserver.NewClientFactory(cfg.RPCServer, cfg.ClientCA, cfg.RPCToken,
+		cfg.RPCMaxRetries, cfg.RPCInitialBackoff)
+
 	server := &authzServer{
 		gs:               gs,
 		redisOptions:     cfg.RedisOptions,
 		analyticsOptions: cfg.AnalyticsOptions,
-		rpcServer:        cfg.RPCServer,
-		clientCA:         cfg.ClientCA,
+		authzOptions:     cfg.AuthzOptions,
+		storeFactory:     storeFactory,
+		acceptPartial:    cfg.AcceptPartialReload,
+		cacheOptions:     cfg.CacheOptions,
 		genericAPIServer: genericServer,
+		routes:           routes,
+		reloader:         app.NewReloader(),
+		watchConfig:      cfg.GenericServerRunOptions.WatchConfig,
 	}
 
 	return server, nil
 }
 
 func (s *authzServer) PrepareRun() preparedAuthzServer {
-	_ = s.initialize()
+	if err := s.initialize(); err != nil {
+		log.Fatalf("initialize authz server failed: %s", err.Error())
+	}
+
+	s.previewLimiter = initRouter(s.genericAPIServer.Engine, s.authzOptions)
 
-	initRouter(s.genericAPIServer.Engine)
+	// the new configurations are validated as on startup before they are applied.
+	s.reloader.AddValidator(app.ValidateOptions(func() app.CliOptions { return options.NewOptions() }))
+
+	s.genericAPIServer.AddReloadHooks(s.reloader)
+	s.reloader.AddReloadHook(s.reloadPreviewRateLimit, "authz.preview-rate-limit", "authz.preview-rate-burst")
+	s.reloader.AddReloadHook(s.reloadAnalyticsFlushInterval, "analytics.flush-interval")
+	// the rotations of the secret files are reloaded, they require a restart.
+	s.reloader.AddSecretFiles("rpcserver-token-file", "redis.password-file", "redis.sentinel-password-file")
+
+	s.genericAPIServer.AddHealthzCheck("redis", storage.HealthCheck)
+	s.genericAPIServer.AddReadyzCheck("redis-ping", (&storage.RedisCluster{}).Ping)
 
 	return preparedAuthzServer{s}
 }
@@ -83,20 +145,61 @@ func (s preparedAuthzServer) Run() error {
 		log.Fatalf("start shutdown manager failed: %s", err.Error())
 	}
 
+	s.reloader.Start()
+
+	if s.watchConfig {
+		if err := s.reloader.Watch(); err != nil {
+			log.Warnf("Failed to watch the configuration file, it is reloaded on SIGHUP only: %s", err.Error())
+		}
+	}
+
 	//nolint: errcheck
 	go s.genericAPIServer.Run()
 
-	// in order to ensure that the reported data is not lost,
-	// please ensure the following graceful shutdown sequence
-	s.gs.AddShutdownCallback(shutdown.ShutdownFunc(func(string) error {
-		s.genericAPIServer.Close()
-		if s.analyticsOptions.Enable {
-			analytics.GetAnalytics().Stop()
+	// in order to ensure that the reported data is not lost, the requests are drained before
+	// flushing the analytics records, and redis is closed last.
+	s.gs.AddShutdownCallbackCtx("reloader", shutdown.ShutdownFuncCtx(func(context.Context, string) error {
+		s.reloader.Stop()
+
+		return nil
+	}), shutdown.PriorityStopReadiness)
+
+	s.gs.AddShutdownCallbackCtx("stop readiness", s.genericAPIServer.StopReadinessCallback(),
+		shutdown.PriorityStopReadiness)
+
+	s.gs.AddShutdownCallbackCtx("drain", shutdown.ShutdownFuncCtx(func(ctx context.Context, _ string) error {
+		ctx, cancel := context.WithTimeout(ctx, s.genericAPIServer.ShutdownTimeout)
+		defer cancel()
+
+		if err := s.genericAPIServer.Shutdown(ctx); err != nil {
+			return errors.Wrap(err, "drain the in-flight requests failed")
 		}
+
+		return nil
+	}), shutdown.PriorityDrain)
+
+	if s.analyticsOptions.Enable {
+		s.gs.AddShutdownCallbackCtx("analytics", shutdown.ShutdownFuncCtx(func(ctx context.Context, _ string) error {
+			return analytics.GetAnalytics().StopCtx(ctx)
+		}), shutdown.PriorityFlush)
+	}
+
+	s.gs.AddShutdownCallbackCtx("loader", shutdown.ShutdownFuncCtx(func(ctx context.Context, _ string) error {
+		ctx, cancel := context.WithTimeout(ctx, loaderShutdownTimeout)
+		defer cancel()
+
+		if err := s.loader.Shutdown(ctx); err != nil {
+			return errors.Wrap(err, "perform the final reload failed")
+		}
+
+		return nil
+	}), shutdown.PriorityStopJobs)
+
+	s.gs.AddShutdownCallbackCtx("redis", shutdown.ShutdownFuncCtx(func(context.Context, string) error {
 		s.redisCancelFunc()
 
 		return nil
-	}))
+	}), shutdown.PriorityCloseStores)
 
 	// blocking here via channel to prevents the process exit.
 	<-stopCh
@@ -114,6 +217,10 @@ func buildGenericConfig(cfg *config.Config) (genericConfig *genericapiserver.Con
 		return
 	}
 
+	if lastErr = cfg.AccessLogOptions.ApplyTo(genericConfig); lastErr != nil {
+		return
+	}
+
 	if lastErr = cfg.SecureServing.ApplyTo(genericConfig); lastErr != nil {
 		return
 	}
@@ -125,45 +232,99 @@ func buildGenericConfig(cfg *config.Config) (genericConfig *genericapiserver.Con
 	return
 }
 
-func (s *authzServer) buildStorageConfig() *storage.Config {
-	return &storage.Config{
-		Host:                  s.redisOptions.Host,
-		Port:                  s.redisOptions.Port,
-		Addrs:                 s.redisOptions.Addrs,
-		MasterName:            s.redisOptions.MasterName,
-		Username:              s.redisOptions.Username,
-		Password:              s.redisOptions.Password,
-		Database:              s.redisOptions.Database,
-		MaxIdle:               s.redisOptions.MaxIdle,
-		MaxActive:             s.redisOptions.MaxActive,
-		Timeout:               s.redisOptions.Timeout,
-		EnableCluster:         s.redisOptions.EnableCluster,
-		UseSSL:                s.redisOptions.UseSSL,
-		SSLInsecureSkipVerify: s.redisOptions.SSLInsecureSkipVerify,
-	}
-}
-
 func (s *authzServer) initialize() error {
 	ctx, cancel := context.WithCancel(context.Background())
 	s.redisCancelFunc = cancel
 
-	// keep redis connected
-	go storage.ConnectToRedis(ctx, s.buildStorageConfig())
+	// keep redis connected, the services using redis are started once it is connected.
+	connected := make(chan struct{})
+
+	var once sync.Once
+
+	go storage.ConnectToRedis(ctx, s.redisOptions.StorageConfig(), func() {
+		once.Do(func() { close(connected) })
+	})
+	go storage.LogHealth(ctx, time.Minute)
+
+	storage.RegisterMetrics()
 
 	// cron to reload all secrets and policies from iam-apiserver
-	cacheIns, err := cache.GetCacheInsOr(apiserver.GetAPIServerFactoryOrDie(s.rpcServer, s.clientCA))
+	cli, err := s.storeFactory.NewClient()
+	if err != nil {
+		return errors.Wrap(err, "create store client failed")
+	}
+
+	cacheIns, err := cache.GetCacheInsOr(cli)
 	if err != nil {
 		return errors.Wrap(err, "get cache instance failed")
 	}
 
-	load.NewLoader(ctx, cacheIns).Start()
+	cache.RegisterMetrics(cacheIns)
+
+	// the remote conditions of the policies only fetch the endpoints allowed by the operator.
+	if err := conditions.SetRemoteEndpoints(s.authzOptions.RemoteEndpoints()); err != nil {
+		return errors.Wrap(err, "set the remote condition endpoints failed")
+	}
+
+	s.loader = load.NewLoader(ctx, cacheIns, s.acceptPartial)
+	if s.cacheOptions.SyncMode == load.SyncModeWatch {
+		s.loader.EnableWatch(cacheIns, s.cacheOptions.WatchTimeout)
+	}
+
+	s.loader.WaitConnected(connected).Start()
+
+	// the changes made while iam-apiserver was unreachable are loaded as soon as it is reachable again.
+	if notifier, ok := cli.(store.ConnectivityNotifier); ok {
+		notifier.WatchConnectivity(ctx, func() { load.QueueReload(ctx) })
+	}
 
-	// start analytics service
+	// start analytics service, the records are buffered until redis is connected.
 	if s.analyticsOptions.Enable {
-		analyticsStore := storage.RedisCluster{KeyPrefix: RedisKeyPrefix}
-		analyticsIns := analytics.NewAnalytics(s.analyticsOptions, &analyticsStore)
-		analyticsIns.Start()
+		analyticsIns := analytics.NewAnalytics(s.analyticsOptions, analytics.NewStore(s.analyticsOptions))
+
+		go func() {
+			select {
+			case <-connected:
+				analyticsIns.Start()
+			case <-ctx.Done():
+			}
+		}()
 	}
 
 	return nil
 }
+
+// reloadPreviewRateLimit applies the rate limit of the preview and trace requests re-read on reload.
+func (s *authzServer) reloadPreviewRateLimit() error {
+	limit, burst := viper.GetFloat64("authz.preview-rate-limit"), viper.GetInt("authz.preview-rate-burst")
+	if limit <= 0 || burst <= 0 {
+		return errors.Errorf("preview rate limit %v and burst %d must be greater than 0", limit, burst)
+	}
+
+	s.previewLimiter.SetLimit(rate.Limit(limit))
+	s.previewLimiter.SetBurst(burst)
+
+	log.Infof("Preview rate limit set to %v/s with a burst of %d", limit, burst)
+
+	return nil
+}
+
+// reloadAnalyticsFlushInterval applies the flush interval of the analytics records re-read on reload.
+func (s *authzServer) reloadAnalyticsFlushInterval() error {
+	// the analytics are enabled on startup only.
+	analyticsIns := analytics.GetAnalytics()
+	if analyticsIns == nil {
+		return nil
+	}
+
+	interval := viper.GetUint64("analytics.flush-interval")
+	if interval < 1 || interval > 1000 {
+		return errors.Errorf("analytics flush interval %v must be between 1 and 1000", interval)
+	}
+
+	analyticsIns.SetFlushInterval(interval)
+
+	log.Infof("Analytics flush interval set to %dms", interval)
+
+	return nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authzserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go/v4"
+	"github.com/gin-gonic/gin"
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/ory/ladon"
+
+	"github.com/marmotedu/iam/internal/authzserver/analytics"
+	"github.com/marmotedu/iam/internal/authzserver/load"
+	"github.com/marmotedu/iam/internal/authzserver/load/cache"
+	"github.com/marmotedu/iam/internal/authzserver/options"
+	"github.com/marmotedu/iam/internal/authzserver/store"
+	"github.com/marmotedu/iam/internal/pkg/middleware/auth"
+	genericoptions "github.com/marmotedu/iam/internal/pkg/options"
+	genericapiserver "github.com/marmotedu/iam/internal/pkg/server"
+	"github.com/marmotedu/iam/pkg/shutdown"
+)
+
+// fakeStore serves a fixed set of secrets and policies instead of iam-apiserver.
+type fakeStore struct{}
+
+func (fakeStore) Policies() store.PolicyStore { return fakeStore{} }
+func (fakeStore) Secrets() store.SecretStore  { return fakeSecretStore{} }
+func (fakeStore) Watcher() store.WatchStore   { return nil }
+
+func (fakeStore) List() (map[string][]*ladon.DefaultPolicy, int, error) {
+	return map[string][]*ladon.DefaultPolicy{"colin": {{ID: "policy1"}}}, 0, nil
+}
+
+type fakeSecretStore struct{}
+
+func (fakeSecretStore) List() (map[string]*pb.SecretInfo, int, error) {
+	return map[string]*pb.SecretInfo{
+		"secret1": {SecretId: "secret1", Username: "colin", SecretKey: "key1"},
+	}, 0, nil
+}
+
+func newTestAuthzServer(factory store.ClientFactory) *authzServer {
+	gin.SetMode(gin.TestMode)
+
+	return &authzServer{
+		gs:               shutdown.New(),
+		storeFactory:     factory,
+		cacheOptions:     load.NewCacheOptions(),
+		redisOptions:     genericoptions.NewRedisOptions(),
+		analyticsOptions: &analytics.AnalyticsOptions{Enable: false},
+		authzOptions:     options.NewAuthzOptions(),
+		genericAPIServer: &genericapiserver.GenericAPIServer{Engine: gin.New()},
+	}
+}
+
+// signToken returns a token signed with the secret of colin served by fakeStore.
+func signToken(t *testing.T) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"aud": auth.AuthzAudience,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = "secret1"
+
+	signed, err := token.SignedString([]byte("key1"))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+
+	return signed
+}
+
+func Test_authzServer_initialize(t *testing.T) {
+	s := newTestAuthzServer(store.ClientFactoryFunc(func() (store.Factory, error) {
+		return fakeStore{}, nil
+	}))
+
+	cache.SetCacheIns(nil)
+	defer cache.SetCacheIns(nil)
+
+	s.authzOptions.AdminUsers = []string{"colin"}
+
+	if err := s.initialize(); err != nil {
+		t.Fatalf("initialize() error = %v", err)
+	}
+	defer s.redisCancelFunc()
+
+	initRouter(s.genericAPIServer.Engine, s.authzOptions)
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/cache/status", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t))
+
+	w := httptest.NewRecorder()
+	s.genericAPIServer.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /debug/cache/status = %d %s, want %d", w.Code, w.Body.String(), http.StatusOK)
+	}
+
+	var stats cache.Stats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	if stats.Secrets != 1 || stats.Policies != 1 {
+		t.Errorf("cache status = %+v, want the secrets and policies of the fake store", stats)
+	}
+}
+
+func Test_authzServer_initialize_Error(t *testing.T) {
+	s := newTestAuthzServer(store.ClientFactoryFunc(func() (store.Factory, error) {
+		return nil, errors.New("connection refused")
+	}))
+
+	cache.SetCacheIns(nil)
+	defer cache.SetCacheIns(nil)
+
+	if err := s.initialize(); err == nil {
+		t.Error("initialize() error = nil, want the store client error")
+	}
+	s.redisCancelFunc()
+}
+
+func Test_authzServer_DebugCache(t *testing.T) {
+	tests := []struct {
+		name       string
+		adminUsers []string
+		path       string
+		wantCode   int
+	}{
+		{name: "secrets", adminUsers: []string{"colin"}, path: "/debug/cache/secrets?kid=secret1", wantCode: http.StatusOK},
+		{name: "policies", adminUsers: []string{"colin"}, path: "/debug/cache/policies?username=colin", wantCode: http.StatusOK},
+		{name: "invalid page", adminUsers: []string{"colin"}, path: "/debug/cache/secrets?offset=-1", wantCode: http.StatusBadRequest},
+		{name: "not admin", adminUsers: []string{"admin"}, path: "/debug/cache/secrets", wantCode: http.StatusForbidden},
+		{name: "status not admin", adminUsers: []string{"admin"}, path: "/debug/cache/status", wantCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestAuthzServer(store.ClientFactoryFunc(func() (store.Factory, error) {
+				return fakeStore{}, nil
+			}))
+			s.authzOptions.AdminUsers = tt.adminUsers
+
+			cache.SetCacheIns(nil)
+			defer cache.SetCacheIns(nil)
+
+			if err := s.initialize(); err != nil {
+				t.Fatalf("initialize() error = %v", err)
+			}
+			defer s.redisCancelFunc()
+
+			initRouter(s.genericAPIServer.Engine, s.authzOptions)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+signToken(t))
+
+			w := httptest.NewRecorder()
+			s.genericAPIServer.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("GET %s = %d %s, want %d", tt.path, w.Code, w.Body.String(), tt.wantCode)
+			}
+
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var list struct {
+				TotalCount int `json:"totalCount"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+
+			if list.TotalCount != 1 {
+				t.Errorf("GET %s = %s, want a single item", tt.path, w.Body.String())
+			}
+
+			if strings.Contains(w.Body.String(), "key1") || strings.Contains(w.Body.String(), "secretKey") {
+				t.Errorf("GET %s = %s, want the secret key absent", tt.path, w.Body.String())
+			}
+		})
+	}
+}
@@ -5,18 +5,26 @@
 package apiserver
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/marmotedu/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 
+	watchpb "github.com/marmotedu/iam/api/proto/apiserver/v1"
 	"github.com/marmotedu/iam/internal/authzserver/store"
+	"github.com/marmotedu/iam/internal/pkg/grpcauth"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
 type datastore struct {
-	cli pb.CacheClient
+	cli      pb.CacheClient
+	watchCli watchpb.CacheWatchClient
+	conn     StateGetter
 }
 
 func (ds *datastore) Secrets() store.SecretStore {
@@ -27,37 +35,91 @@ func (ds *datastore) Policies() store.PolicyStore {
 	return newPolicies(ds)
 }
 
+func (ds *datastore) Watcher() store.WatchStore {
+	return newWatcher(ds)
+}
+
+// WatchConnectivity implements store.ConnectivityNotifier with a ConnectivityWatcher.
+func (ds *datastore) WatchConnectivity(ctx context.Context, onRecovered func()) {
+	registerConnectivityMetrics.Do(func() {
+		prometheus.MustRegister(reconnectReloads)
+	})
+
+	go NewConnectivityWatcher(ds.conn, connectivityPollInterval, onRecovered).Run(ctx)
+}
+
 var (
 	apiServerFactory store.Factory
-	once             sync.Once
+	lock             sync.Mutex
 )
 
-// GetAPIServerFactoryOrDie return cache instance and panics on any error.
-func GetAPIServerFactoryOrDie(address string, clientCA string) store.Factory {
-	once.Do(func() {
-		var (
-			err   error
-			conn  *grpc.ClientConn
-			creds credentials.TransportCredentials
-		)
-
-		creds, err = credentials.NewClientTLSFromFile(clientCA, "")
-		if err != nil {
-			log.Panicf("credentials.NewClientTLSFromFile err: %v", err)
-		}
+// NewAPIServerFactory connects to the grpc server of iam-apiserver and returns a store backed
+// by it. If token is not empty, it is attached to every rpc as a bearer token. The rpcs failed
+// with a transient error are retried up to maxRetries times, see RetryingStoreClient.
+func NewAPIServerFactory(address string, clientCA string, token string, maxRetries int,
+	initialBackoff time.Duration) (store.Factory, error) {
+	creds, err := credentials.NewClientTLSFromFile(clientCA, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "credentials.NewClientTLSFromFile err")
+	}
 
-		conn, err = grpc.Dial(address, grpc.WithBlock(), grpc.WithTransportCredentials(creds))
-		if err != nil {
-			log.Panicf("Connect to grpc server failed, error: %s", err.Error())
+	opts := []grpc.DialOption{grpc.WithBlock(), grpc.WithTransportCredentials(creds)}
+	if token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(grpcauth.NewTokenCredentials(token)))
+	}
+
+	conn, err := grpc.Dial(address, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "connect to grpc server failed")
+	}
+
+	log.Infof("Connected to grpc server, address: %s", address)
+
+	return &datastore{
+		NewRetryingStoreClient(pb.NewCacheClient(conn), maxRetries, initialBackoff),
+		watchpb.NewCacheWatchClient(conn),
+		conn,
+	}, nil
+}
+
+// NewClientFactory returns a client factory which returns the shared apiserver store.
+func NewClientFactory(address string, clientCA string, token string, maxRetries int,
+	initialBackoff time.Duration) store.ClientFactory {
+	return store.ClientFactoryFunc(func() (store.Factory, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if apiServerFactory == nil {
+			factory, err := NewAPIServerFactory(address, clientCA, token, maxRetries, initialBackoff)
+			if err != nil {
+				return nil, err
+			}
+
+			apiServerFactory = factory
 		}
 
-		apiServerFactory = &datastore{pb.NewCacheClient(conn)}
-		log.Infof("Connected to grpc server, address: %s", address)
+		return apiServerFactory, nil
 	})
+}
 
-	if apiServerFactory == nil {
-		log.Panicf("failed to get apiserver store fatory")
+// GetAPIServerFactoryOrDie return cache instance and panics on any error.
+// If token is not empty, it is attached to every rpc as a bearer token.
+// The store is shared, only the arguments of the first successful call are used.
+func GetAPIServerFactoryOrDie(address string, clientCA string, token string, maxRetries int,
+	initialBackoff time.Duration) store.Factory {
+	factory, err := NewClientFactory(address, clientCA, token, maxRetries, initialBackoff).NewClient()
+	if err != nil {
+		log.Panicf("failed to get apiserver store fatory: %s", err.Error())
 	}
 
-	return apiServerFactory
+	return factory
+}
+
+// SetAPIServerFactory replaces the shared apiserver store, nil resets it so that the next
+// call connects again. It is intended for tests only.
+func SetAPIServerFactory(factory store.Factory) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	apiServerFactory = factory
 }
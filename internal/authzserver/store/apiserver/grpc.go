@@ -0,0 +1,194 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// RetryingStoreClient is a pb.CacheClient which retries the rpcs failed with a transient error,
+// codes.Unavailable or codes.DeadlineExceeded, with an exponential backoff. The other errors, like
+// codes.PermissionDenied or codes.NotFound, are returned right away.
+type RetryingStoreClient struct {
+	pb.CacheClient
+	// MaxRetries is the maximum number of retries of an rpc, 0 disables the retries.
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry, it grows by backoff.DefaultConfig.Multiplier
+	// after each retry up to backoff.DefaultConfig.MaxDelay.
+	InitialBackoff time.Duration
+}
+
+var _ pb.CacheClient = (*RetryingStoreClient)(nil)
+
+// NewRetryingStoreClient returns a RetryingStoreClient retrying the rpcs of cli.
+func NewRetryingStoreClient(cli pb.CacheClient, maxRetries int, initialBackoff time.Duration) *RetryingStoreClient {
+	return &RetryingStoreClient{
+		CacheClient:    cli,
+		MaxRetries:     maxRetries,
+		InitialBackoff: initialBackoff,
+	}
+}
+
+// ListSecrets implements pb.CacheClient.
+func (c *RetryingStoreClient) ListSecrets(ctx context.Context, in *pb.ListSecretsRequest,
+	opts ...grpc.CallOption) (*pb.ListSecretsResponse, error) {
+	var resp *pb.ListSecretsResponse
+
+	err := c.retry(ctx, "ListSecrets", func() (err error) {
+		resp, err = c.CacheClient.ListSecrets(ctx, in, opts...)
+
+		return err
+	})
+
+	return resp, err
+}
+
+// ListPolicies implements pb.CacheClient.
+func (c *RetryingStoreClient) ListPolicies(ctx context.Context, in *pb.ListPoliciesRequest,
+	opts ...grpc.CallOption) (*pb.ListPoliciesResponse, error) {
+	var resp *pb.ListPoliciesResponse
+
+	err := c.retry(ctx, "ListPolicies", func() (err error) {
+		resp, err = c.CacheClient.ListPolicies(ctx, in, opts...)
+
+		return err
+	})
+
+	return resp, err
+}
+
+// isTransient reports whether the rpc may succeed if retried.
+func isTransient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *RetryingStoreClient) retry(ctx context.Context, method string, rpc func() error) error {
+	for retries := 0; ; retries++ {
+		err := rpc()
+		if err == nil || !isTransient(err) || retries >= c.MaxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		delay := c.backoff(retries)
+		log.Warnw("Retrying rpc to iam-apiserver", "method", method, "attempt", retries+2,
+			"delay", delay.String(), "error", err.Error())
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the delay before the retry following retries retries.
+func (c *RetryingStoreClient) backoff(retries int) time.Duration {
+	config := backoff.DefaultConfig
+	config.BaseDelay = c.InitialBackoff
+
+	delay := float64(config.BaseDelay) * math.Pow(config.Multiplier, float64(retries))
+	if max := float64(config.MaxDelay); delay > max {
+		delay = max
+	}
+
+	// randomize the delays so that the instances do not retry all together.
+	delay *= 1 + config.Jitter*(rand.Float64()*2-1) // nolint: gosec
+
+	return time.Duration(delay)
+}
+
+// connectivityPollInterval is the interval the state of the grpc connection is polled at, the reload is
+// queued within it once iam-apiserver is reachable again.
+const connectivityPollInterval = 200 * time.Millisecond
+
+var (
+	reconnectReloads = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "iam",
+		Name:      "store_reconnect_reload_total",
+		Help:      "Number of reloads queued because the grpc connection to iam-apiserver recovered.",
+	})
+	registerConnectivityMetrics sync.Once
+)
+
+// StateGetter returns the state of a grpc connection, e.g. grpc.ClientConn.
+type StateGetter interface {
+	GetState() connectivity.State
+}
+
+// ConnectivityWatcher polls the state of the grpc connection to iam-apiserver and calls onRecovered each
+// time it is ready again after a failure, e.g. once iam-apiserver restarted. The changes made while the
+// connection was broken are missed otherwise, until the next reload.
+type ConnectivityWatcher struct {
+	conn        StateGetter
+	interval    time.Duration
+	onRecovered func()
+}
+
+// NewConnectivityWatcher returns a ConnectivityWatcher polling the state of conn every interval.
+func NewConnectivityWatcher(conn StateGetter, interval time.Duration, onRecovered func()) *ConnectivityWatcher {
+	return &ConnectivityWatcher{
+		conn:        conn,
+		interval:    interval,
+		onRecovered: onRecovered,
+	}
+}
+
+// Run polls the state of the connection until ctx is done. The connection recovers when its state goes
+// from connectivity.TransientFailure or connectivity.Shutdown back to connectivity.Ready, whatever the
+// states in between.
+func (w *ConnectivityWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	failed := false
+
+	for {
+		switch state := w.conn.GetState(); state {
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			if !failed {
+				log.Warnf("Connection to iam-apiserver lost, state: %s", state)
+			}
+
+			failed = true
+		case connectivity.Ready:
+			if failed {
+				log.Info("Connection to iam-apiserver recovered, reloading secrets and policies")
+				reconnectReloads.Inc()
+				w.onRecovered()
+			}
+
+			failed = false
+		default:
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
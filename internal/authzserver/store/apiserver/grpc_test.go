@@ -0,0 +1,265 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// flakyCacheServer fails the first failures rpcs with code, then lists its secrets and policies.
+type flakyCacheServer struct {
+	pb.UnimplementedCacheServer
+	code     codes.Code
+	failures int32
+	calls    int32
+}
+
+func (s *flakyCacheServer) fail() error {
+	if atomic.AddInt32(&s.calls, 1) <= s.failures {
+		return status.Error(s.code, "flaky")
+	}
+
+	return nil
+}
+
+func (s *flakyCacheServer) ListSecrets(context.Context, *pb.ListSecretsRequest) (*pb.ListSecretsResponse, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+
+	return &pb.ListSecretsResponse{
+		TotalCount: 1,
+		Items:      []*pb.SecretInfo{{Name: "secret", SecretId: "id", SecretKey: "key"}},
+	}, nil
+}
+
+func (s *flakyCacheServer) ListPolicies(context.Context, *pb.ListPoliciesRequest) (*pb.ListPoliciesResponse, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+
+	return &pb.ListPoliciesResponse{
+		TotalCount: 1,
+		Items:      []*pb.PolicyInfo{{Name: "policy", Username: "admin"}},
+	}, nil
+}
+
+// retryingClient starts a grpc server serving srv and returns a RetryingStoreClient connected to it.
+func retryingClient(t *testing.T, srv pb.CacheServer, maxRetries int) *RetryingStoreClient {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	server := grpc.NewServer()
+	pb.RegisterCacheServer(server, srv)
+
+	go func() {
+		_ = server.Serve(ln)
+	}()
+
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return NewRetryingStoreClient(pb.NewCacheClient(conn), maxRetries, time.Millisecond)
+}
+
+func TestRetryingStoreClient(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       codes.Code
+		failures   int32
+		maxRetries int
+		wantCode   codes.Code
+		wantCalls  int32
+	}{
+		{name: "unavailable", code: codes.Unavailable, failures: 2, maxRetries: 3, wantCode: codes.OK, wantCalls: 3},
+		{
+			name: "deadline exceeded", code: codes.DeadlineExceeded, failures: 2, maxRetries: 3,
+			wantCode: codes.OK, wantCalls: 3,
+		},
+		{
+			name: "retries exhausted", code: codes.Unavailable, failures: 2, maxRetries: 1,
+			wantCode: codes.Unavailable, wantCalls: 2,
+		},
+		{
+			name: "permission denied", code: codes.PermissionDenied, failures: 2, maxRetries: 3,
+			wantCode: codes.PermissionDenied, wantCalls: 1,
+		},
+		{name: "not found", code: codes.NotFound, failures: 2, maxRetries: 3, wantCode: codes.NotFound, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &flakyCacheServer{code: tt.code, failures: tt.failures}
+			cli := retryingClient(t, srv, tt.maxRetries)
+
+			resp, err := cli.ListSecrets(context.Background(), &pb.ListSecretsRequest{})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("ListSecrets() code = %v, want %v", code, tt.wantCode)
+			}
+
+			if calls := atomic.LoadInt32(&srv.calls); calls != tt.wantCalls {
+				t.Errorf("ListSecrets() calls = %d, want %d", calls, tt.wantCalls)
+			}
+
+			if tt.wantCode == codes.OK && (len(resp.Items) != 1 || resp.Items[0].SecretId != "id") {
+				t.Errorf("ListSecrets() items = %v, want the secret id", resp.Items)
+			}
+		})
+	}
+}
+
+func TestRetryingStoreClient_ListPolicies(t *testing.T) {
+	srv := &flakyCacheServer{code: codes.Unavailable, failures: 2}
+	cli := retryingClient(t, srv, 3)
+
+	resp, err := cli.ListPolicies(context.Background(), &pb.ListPoliciesRequest{})
+	if err != nil {
+		t.Fatalf("ListPolicies() error = %v", err)
+	}
+
+	if len(resp.Items) != 1 || resp.Items[0].Name != "policy" {
+		t.Errorf("ListPolicies() items = %v, want the policy", resp.Items)
+	}
+
+	if calls := atomic.LoadInt32(&srv.calls); calls != 3 {
+		t.Errorf("ListPolicies() calls = %d, want 3", calls)
+	}
+}
+
+func TestRetryingStoreClient_Canceled(t *testing.T) {
+	srv := &flakyCacheServer{code: codes.Unavailable, failures: 100}
+	cli := retryingClient(t, srv, 100)
+	cli.InitialBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// the backoff is interrupted once ctx is done.
+	if _, err := cli.ListSecrets(ctx, &pb.ListSecretsRequest{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("ListSecrets() error = %v, want %v", err, codes.Unavailable)
+	}
+
+	if calls := atomic.LoadInt32(&srv.calls); calls != 1 {
+		t.Errorf("ListSecrets() calls = %d, want 1", calls)
+	}
+}
+
+// scriptedConn goes through states, one per GetState call, then stays in the last one. done is closed
+// once the last state is reached.
+type scriptedConn struct {
+	states []connectivity.State
+	calls  int
+	done   chan struct{}
+}
+
+func (c *scriptedConn) GetState() connectivity.State {
+	if c.calls++; c.calls == len(c.states) {
+		close(c.done)
+	}
+
+	if c.calls >= len(c.states) {
+		return c.states[len(c.states)-1]
+	}
+
+	return c.states[c.calls-1]
+}
+
+func TestConnectivityWatcher(t *testing.T) {
+	tests := []struct {
+		name        string
+		states      []connectivity.State
+		wantReloads int
+	}{
+		{
+			name:   "initial connection",
+			states: []connectivity.State{connectivity.Idle, connectivity.Connecting, connectivity.Ready},
+		},
+		{
+			name: "connecting again without failure",
+			states: []connectivity.State{
+				connectivity.Ready, connectivity.Idle, connectivity.Connecting, connectivity.Ready,
+			},
+		},
+		{
+			name: "transient failure",
+			states: []connectivity.State{
+				connectivity.Ready, connectivity.TransientFailure, connectivity.Connecting, connectivity.Ready,
+			},
+			wantReloads: 1,
+		},
+		{
+			name:        "shutdown",
+			states:      []connectivity.State{connectivity.Ready, connectivity.Shutdown, connectivity.Ready},
+			wantReloads: 1,
+		},
+		{
+			name:   "failed",
+			states: []connectivity.State{connectivity.Ready, connectivity.TransientFailure},
+		},
+		{
+			name: "failures",
+			states: []connectivity.State{
+				connectivity.TransientFailure, connectivity.TransientFailure, connectivity.Connecting,
+				connectivity.Ready, connectivity.Ready, connectivity.Idle, connectivity.TransientFailure,
+				connectivity.Ready,
+			},
+			wantReloads: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &scriptedConn{states: tt.states, done: make(chan struct{})}
+			reloads := 0
+			counted := testutil.ToFloat64(reconnectReloads)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			stopped := make(chan struct{})
+
+			go func() {
+				defer close(stopped)
+
+				NewConnectivityWatcher(conn, time.Millisecond, func() { reloads++ }).Run(ctx)
+			}()
+
+			select {
+			case <-conn.done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the state of the connection is not polled")
+			}
+
+			cancel()
+			<-stopped
+
+			if reloads != tt.wantReloads {
+				t.Errorf("reloads = %d, want %d", reloads, tt.wantReloads)
+			}
+
+			if got := testutil.ToFloat64(reconnectReloads) - counted; got != float64(tt.wantReloads) {
+				t.Errorf("store_reconnect_reload_total increased by %v, want %d", got, tt.wantReloads)
+			}
+		})
+	}
+}
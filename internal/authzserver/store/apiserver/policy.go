@@ -9,7 +9,6 @@ import (
 	"encoding/json"
 
 	"github.com/AlekSi/pointer"
-	"github.com/avast/retry-go"
 	pb "github.com/marmotedu/api/proto/apiserver/v1"
 	"github.com/marmotedu/errors"
 	"github.com/ory/ladon"
@@ -25,8 +24,8 @@ func newPolicies(ds *datastore) *policies {
 	return &policies{ds.cli}
 }
 
-// List returns all the authorization policies.
-func (p *policies) List() (map[string][]*ladon.DefaultPolicy, error) {
+// List returns all the authorization policies, policies which can not be decoded are skipped.
+func (p *policies) List() (map[string][]*ladon.DefaultPolicy, int, error) {
 	pols := make(map[string][]*ladon.DefaultPolicy)
 
 	log.Info("Loading policies")
@@ -36,24 +35,14 @@ func (p *policies) List() (map[string][]*ladon.DefaultPolicy, error) {
 		Limit:  pointer.ToInt64(-1),
 	}
 
-	var resp *pb.ListPoliciesResponse
-	err := retry.Do(
-		func() error {
-			var listErr error
-			resp, listErr = p.cli.ListPolicies(context.Background(), req)
-			if listErr != nil {
-				return listErr
-			}
-
-			return nil
-		}, retry.Attempts(3),
-	)
+	resp, err := p.cli.ListPolicies(context.Background(), req)
 	if err != nil {
-		return nil, errors.Wrap(err, "list policies failed")
+		return nil, 0, errors.Wrap(err, "list policies failed")
 	}
 
 	log.Infof("Policies found (%d total)[username:name]:", len(resp.Items))
 
+	skipped := 0
 	for _, v := range resp.Items {
 		log.Infof(" - %s:%s", v.Username, v.Name)
 
@@ -61,6 +50,7 @@ func (p *policies) List() (map[string][]*ladon.DefaultPolicy, error) {
 
 		if err := json.Unmarshal([]byte(v.PolicyShadow), &policy); err != nil {
 			log.Warnf("failed to load policy for %s, error: %s", v.Name, err.Error())
+			skipped++
 
 			continue
 		}
@@ -68,5 +58,5 @@ func (p *policies) List() (map[string][]*ladon.DefaultPolicy, error) {
 		pols[v.Username] = append(pols[v.Username], &policy)
 	}
 
-	return pols, nil
+	return pols, skipped, nil
 }
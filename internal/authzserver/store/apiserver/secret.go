@@ -8,7 +8,6 @@ import (
 	"context"
 
 	"github.com/AlekSi/pointer"
-	"github.com/avast/retry-go"
 	pb "github.com/marmotedu/api/proto/apiserver/v1"
 	"github.com/marmotedu/errors"
 
@@ -23,8 +22,8 @@ func newSecrets(ds *datastore) *secrets {
 	return &secrets{ds.cli}
 }
 
-// List returns all the authorization secrets.
-func (s *secrets) List() (map[string]*pb.SecretInfo, error) {
+// List returns all the authorization secrets, secrets without id or key are skipped.
+func (s *secrets) List() (map[string]*pb.SecretInfo, int, error) {
 	secrets := make(map[string]*pb.SecretInfo)
 
 	log.Info("Loading secrets")
@@ -34,28 +33,26 @@ func (s *secrets) List() (map[string]*pb.SecretInfo, error) {
 		Limit:  pointer.ToInt64(-1),
 	}
 
-	var resp *pb.ListSecretsResponse
-	err := retry.Do(
-		func() error {
-			var listErr error
-			resp, listErr = s.cli.ListSecrets(context.Background(), req)
-			if listErr != nil {
-				return listErr
-			}
-
-			return nil
-		}, retry.Attempts(3),
-	)
+	resp, err := s.cli.ListSecrets(context.Background(), req)
 	if err != nil {
-		return nil, errors.Wrap(err, "list secrets failed")
+		return nil, 0, errors.Wrap(err, "list secrets failed")
 	}
 
 	log.Infof("Secrets found (%d total):", len(resp.Items))
 
+	skipped := 0
 	for _, v := range resp.Items {
 		log.Infof(" - %s:%s", v.Username, v.SecretId)
+
+		if v.SecretId == "" || v.SecretKey == "" {
+			log.Warnf("failed to load secret %s of %s, error: empty secret id or key", v.Name, v.Username)
+			skipped++
+
+			continue
+		}
+
 		secrets[v.SecretId] = v
 	}
 
-	return secrets, nil
+	return secrets, skipped, nil
 }
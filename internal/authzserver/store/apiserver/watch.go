@@ -0,0 +1,84 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/marmotedu/errors"
+	"github.com/ory/ladon"
+
+	watchpb "github.com/marmotedu/iam/api/proto/apiserver/v1"
+	"github.com/marmotedu/iam/internal/authzserver/store"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+type watcher struct {
+	cli watchpb.CacheWatchClient
+}
+
+func newWatcher(ds *datastore) *watcher {
+	return &watcher{ds.watchCli}
+}
+
+// Watch opens a watch stream of secrets and policies on iam-apiserver.
+func (w *watcher) Watch(ctx context.Context, epoch, resourceVersion int64) (store.EventStream, error) {
+	stream, err := w.cli.Watch(ctx, &watchpb.WatchRequest{
+		Epoch:           epoch,
+		ResourceVersion: resourceVersion,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "watch failed")
+	}
+
+	return &eventStream{stream}, nil
+}
+
+type eventStream struct {
+	stream watchpb.CacheWatch_WatchClient
+}
+
+// Recv returns the next event, the policies of PUT and SYNC events are decoded. Invalid
+// secrets and policies are returned without their content, so that they are removed from
+// the cache the same as they are skipped on reload.
+func (s *eventStream) Recv() (*store.Event, error) {
+	e, err := s.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+
+	event := &store.Event{
+		Type:            e.Type,
+		Epoch:           e.Epoch,
+		ResourceVersion: e.ResourceVersion,
+		Secret:          e.Secret,
+	}
+
+	if e.Secret != nil && e.Type != watchpb.EventType_DELETE && (e.Secret.SecretId == "" || e.Secret.SecretKey == "") {
+		log.Warnf("failed to load secret %s of %s, error: empty secret id or key", e.Secret.Name, e.Secret.Username)
+		event.Type = watchpb.EventType_DELETE
+	}
+
+	if e.Policy == nil {
+		return event, nil
+	}
+
+	event.Username, event.Name = e.Policy.Username, e.Policy.Name
+	if e.Type == watchpb.EventType_DELETE {
+		return event, nil
+	}
+
+	var policy ladon.DefaultPolicy
+	if err := json.Unmarshal([]byte(e.Policy.PolicyShadow), &policy); err != nil {
+		log.Warnf("failed to load policy for %s, error: %s", e.Policy.Name, err.Error())
+
+		return event, nil
+	}
+
+	event.Policy = &policy
+
+	return event, nil
+}
@@ -3,12 +3,13 @@
 // license that can be found in the LICENSE file.
 
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/marmotedu/iam/internal/authzserver/store (interfaces: Factory,SecretStore,PolicyStore)
+// Source: github.com/marmotedu/iam/internal/authzserver/store (interfaces: Factory,SecretStore,PolicyStore,WatchStore,EventStream)
 
 // Package store is a generated GoMock package.
 package store
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -67,6 +68,20 @@ func (mr *MockFactoryMockRecorder) Secrets() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Secrets", reflect.TypeOf((*MockFactory)(nil).Secrets))
 }
 
+// Watcher mocks base method.
+func (m *MockFactory) Watcher() WatchStore {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Watcher")
+	ret0, _ := ret[0].(WatchStore)
+	return ret0
+}
+
+// Watcher indicates an expected call of Watcher.
+func (mr *MockFactoryMockRecorder) Watcher() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Watcher", reflect.TypeOf((*MockFactory)(nil).Watcher))
+}
+
 // MockSecretStore is a mock of SecretStore interface.
 type MockSecretStore struct {
 	ctrl     *gomock.Controller
@@ -91,12 +106,13 @@ func (m *MockSecretStore) EXPECT() *MockSecretStoreMockRecorder {
 }
 
 // List mocks base method.
-func (m *MockSecretStore) List() (map[string]*v1.SecretInfo, error) {
+func (m *MockSecretStore) List() (map[string]*v1.SecretInfo, int, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "List")
 	ret0, _ := ret[0].(map[string]*v1.SecretInfo)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // List indicates an expected call of List.
@@ -129,12 +145,13 @@ func (m *MockPolicyStore) EXPECT() *MockPolicyStoreMockRecorder {
 }
 
 // List mocks base method.
-func (m *MockPolicyStore) List() (map[string][]*ladon.DefaultPolicy, error) {
+func (m *MockPolicyStore) List() (map[string][]*ladon.DefaultPolicy, int, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "List")
 	ret0, _ := ret[0].(map[string][]*ladon.DefaultPolicy)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // List indicates an expected call of List.
@@ -142,3 +159,79 @@ func (mr *MockPolicyStoreMockRecorder) List() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockPolicyStore)(nil).List))
 }
+
+// MockWatchStore is a mock of WatchStore interface.
+type MockWatchStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockWatchStoreMockRecorder
+}
+
+// MockWatchStoreMockRecorder is the mock recorder for MockWatchStore.
+type MockWatchStoreMockRecorder struct {
+	mock *MockWatchStore
+}
+
+// NewMockWatchStore creates a new mock instance.
+func NewMockWatchStore(ctrl *gomock.Controller) *MockWatchStore {
+	mock := &MockWatchStore{ctrl: ctrl}
+	mock.recorder = &MockWatchStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWatchStore) EXPECT() *MockWatchStoreMockRecorder {
+	return m.recorder
+}
+
+// Watch mocks base method.
+func (m *MockWatchStore) Watch(arg0 context.Context, arg1, arg2 int64) (EventStream, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Watch", arg0, arg1, arg2)
+	ret0, _ := ret[0].(EventStream)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Watch indicates an expected call of Watch.
+func (mr *MockWatchStoreMockRecorder) Watch(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Watch", reflect.TypeOf((*MockWatchStore)(nil).Watch), arg0, arg1, arg2)
+}
+
+// MockEventStream is a mock of EventStream interface.
+type MockEventStream struct {
+	ctrl     *gomock.Controller
+	recorder *MockEventStreamMockRecorder
+}
+
+// MockEventStreamMockRecorder is the mock recorder for MockEventStream.
+type MockEventStreamMockRecorder struct {
+	mock *MockEventStream
+}
+
+// NewMockEventStream creates a new mock instance.
+func NewMockEventStream(ctrl *gomock.Controller) *MockEventStream {
+	mock := &MockEventStream{ctrl: ctrl}
+	mock.recorder = &MockEventStreamMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEventStream) EXPECT() *MockEventStreamMockRecorder {
+	return m.recorder
+}
+
+// Recv mocks base method.
+func (m *MockEventStream) Recv() (*Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Recv")
+	ret0, _ := ret[0].(*Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Recv indicates an expected call of Recv.
+func (mr *MockEventStreamMockRecorder) Recv() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Recv", reflect.TypeOf((*MockEventStream)(nil).Recv))
+}
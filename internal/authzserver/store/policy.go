@@ -8,5 +8,6 @@ import "github.com/ory/ladon"
 
 // PolicyStore defines the policy storage interface.
 type PolicyStore interface {
-	List() (map[string][]*ladon.DefaultPolicy, error)
+	// List returns all the policies grouped by username and the number of invalid policies skipped.
+	List() (map[string][]*ladon.DefaultPolicy, int, error)
 }
@@ -9,5 +9,6 @@ import pb "github.com/marmotedu/api/proto/apiserver/v1"
 // SecretStore defines the secret storage interface.
 type SecretStore interface {
 	// List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.SecretList, error)
-	List() (map[string]*pb.SecretInfo, error)
+	// List returns all the secrets and the number of invalid secrets skipped.
+	List() (map[string]*pb.SecretInfo, int, error)
 }
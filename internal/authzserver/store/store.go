@@ -4,7 +4,9 @@
 
 package store
 
-//go:generate mockgen -self_package=github.com/marmotedu/iam/internal/authzserver/store -destination mock_store.go -package store github.com/marmotedu/iam/internal/authzserver/store Factory,SecretStore,PolicyStore
+import "context"
+
+//go:generate mockgen -self_package=github.com/marmotedu/iam/internal/authzserver/store -destination mock_store.go -package store github.com/marmotedu/iam/internal/authzserver/store Factory,SecretStore,PolicyStore,WatchStore,EventStream
 
 var client Factory
 
@@ -12,6 +14,29 @@ var client Factory
 type Factory interface {
 	Policies() PolicyStore
 	Secrets() SecretStore
+	// Watcher returns nil if the storage can not be watched.
+	Watcher() WatchStore
+}
+
+// ConnectivityNotifier is implemented by the storages connected to a remote server, which can miss
+// changes while the connection is broken.
+type ConnectivityNotifier interface {
+	// WatchConnectivity calls onRecovered each time the connection recovers from a failure, until ctx
+	// is done. It does not block.
+	WatchConnectivity(ctx context.Context, onRecovered func())
+}
+
+// ClientFactory creates the store client used by iam-authz-server.
+type ClientFactory interface {
+	NewClient() (Factory, error)
+}
+
+// ClientFactoryFunc is an adapter to allow the use of an ordinary function as a ClientFactory.
+type ClientFactoryFunc func() (Factory, error)
+
+// NewClient calls f().
+func (f ClientFactoryFunc) NewClient() (Factory, error) {
+	return f()
 }
 
 // Client return the store client instance.
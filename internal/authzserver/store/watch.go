@@ -0,0 +1,39 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"context"
+
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/ory/ladon"
+
+	watchpb "github.com/marmotedu/iam/api/proto/apiserver/v1"
+)
+
+// Event is a change of a secret or a policy received from a watch stream.
+type Event struct {
+	Type watchpb.EventType
+	// Epoch and ResourceVersion are the watermark used to resume a stream.
+	Epoch           int64
+	ResourceVersion int64
+	Secret          *pb.SecretInfo
+	// Username and Name identify the policy, Policy is nil for a deleted policy.
+	Username string
+	Name     string
+	Policy   *ladon.DefaultPolicy
+}
+
+// EventStream returns the events of a watch stream one by one.
+type EventStream interface {
+	Recv() (*Event, error)
+}
+
+// WatchStore defines the watch storage interface.
+type WatchStore interface {
+	// Watch opens a stream resumed after the given watermark, the stream starts with the
+	// current state when it can not be resumed.
+	Watch(ctx context.Context, epoch, resourceVersion int64) (EventStream, error)
+}
@@ -0,0 +1,35 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package analytics is used to manage the analytics records of iam-authz-server.
+package analytics
+
+import (
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+var analyticsLong = templates.LongDesc(`
+	Analytics management commands.
+
+	This commands allow you to manage the analytics records of iam-authz-server stored in redis.`)
+
+// NewCmdAnalytics returns new initialized instance of 'analytics' sub command.
+func NewCmdAnalytics(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "analytics SUBCOMMAND",
+		DisableFlagsInUseLine: true,
+		Short:                 "Manage the analytics records of iam-authz-server",
+		Long:                  analyticsLong,
+		Run:                   cmdutil.DefaultSubCommandRun(ioStreams.ErrOut),
+	}
+
+	// add subcommands
+	cmd.AddCommand(NewCmdReplay(f, ioStreams))
+
+	return cmd
+}
@@ -0,0 +1,175 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/marmotedu/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/marmotedu/iam/internal/authzserver/analytics"
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/internal/pkg/options"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+// ReplayOptions is an options struct to support replay subcommands.
+type ReplayOptions struct {
+	File           string
+	DryRun         bool
+	BatchSize      int
+	SkipDuplicates bool
+	ConnectTimeout time.Duration
+
+	RedisOptions     *options.RedisOptions
+	AnalyticsOptions *analytics.AnalyticsOptions
+
+	genericclioptions.IOStreams
+}
+
+var replayExample = templates.Examples(`
+		# Check the analytics records of a file without sending them to redis
+		iamctl analytics replay --file=records.json --dry-run
+
+		# Replay the analytics records of a file into redis, skipping the records already stored
+		iamctl analytics replay --file=records.json --skip-duplicates --redis.host=127.0.0.1 --redis.port=6379`)
+
+// NewReplayOptions returns an initialized ReplayOptions instance.
+func NewReplayOptions(ioStreams genericclioptions.IOStreams) *ReplayOptions {
+	return &ReplayOptions{
+		BatchSize:        analytics.DefaultReplayBatchSize,
+		ConnectTimeout:   30 * time.Second,
+		RedisOptions:     options.NewRedisOptions(),
+		AnalyticsOptions: analytics.NewAnalyticsOptions(),
+		IOStreams:        ioStreams,
+	}
+}
+
+// NewCmdReplay returns new initialized instance of replay sub command.
+func NewCmdReplay(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewReplayOptions(ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   "replay --file=FILE",
+		DisableFlagsInUseLine: true,
+		Short:                 "Replay the analytics records of a file into redis",
+		TraverseChildren:      true,
+		Long: templates.LongDesc(`
+			Replay the analytics records of a file into the redis of iam-authz-server, e.g. to recover the records
+			of a dead-letter queue or exported to a file. The file holds one json encoded record per line.`),
+		Example: replayExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate(cmd, args))
+			cmdutil.CheckErr(o.Run(args))
+		},
+		SuggestFor: []string{},
+	}
+
+	cmd.Flags().StringVar(&o.File, "file", o.File, "The file of the analytics records, one json record per line.")
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", o.DryRun, "Read and check the records without sending them to redis.")
+	cmd.Flags().IntVar(&o.BatchSize, "batch-size", o.BatchSize, "The number of records sent to redis at once.")
+	cmd.Flags().BoolVar(&o.SkipDuplicates, "skip-duplicates", o.SkipDuplicates, ""+
+		"Skip the records with the timestamp and the username of a record already stored in redis or already replayed.")
+	cmd.Flags().DurationVar(&o.ConnectTimeout, "connect-timeout", o.ConnectTimeout, "The time to wait for redis.")
+	o.RedisOptions.AddFlags(cmd.Flags())
+	o.AnalyticsOptions.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+// Complete completes all the required options.
+func (o *ReplayOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	return nil
+}
+
+// Validate makes sure there is no discrepency in command options.
+func (o *ReplayOptions) Validate(cmd *cobra.Command, args []string) error {
+	if o.File == "" {
+		return cmdutil.UsageErrorf(cmd, "--file is required")
+	}
+
+	if o.BatchSize <= 0 {
+		return cmdutil.UsageErrorf(cmd, "--batch-size must be greater than 0")
+	}
+
+	errs := o.AnalyticsOptions.Validate()
+	if o.needRedis() {
+		errs = append(errs, o.RedisOptions.Validate()...)
+	}
+
+	return errors.NewAggregate(errs)
+}
+
+// Run executes a replay subcommand using the specified options.
+func (o *ReplayOptions) Run(args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if o.needRedis() {
+		if err := o.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	a := analytics.NewAnalytics(o.AnalyticsOptions, analytics.NewStore(o.AnalyticsOptions))
+
+	// the replay stops after the batch being sent once interrupted.
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			a.Stop()
+		case <-done:
+		}
+	}()
+
+	report, err := analytics.ReplayFromFile(o.File, a, analytics.ReplayOptions{
+		DryRun:         o.DryRun,
+		BatchSize:      o.BatchSize,
+		SkipDuplicates: o.SkipDuplicates,
+	})
+
+	fmt.Fprintf(o.Out, "processed: %d, skipped: %d, errors: %d\n", report.Processed, report.Skipped, report.Errors)
+
+	return err
+}
+
+// needRedis returns whether the replay reads or writes redis, a dry run only lists the stored records to
+// skip the duplicates.
+func (o *ReplayOptions) needRedis() bool {
+	return !o.DryRun || o.SkipDuplicates
+}
+
+// connect connects to redis and waits for the connection up to ConnectTimeout.
+func (o *ReplayOptions) connect(ctx context.Context) error {
+	connected := make(chan struct{})
+
+	var once sync.Once
+
+	go storage.ConnectToRedis(ctx, o.RedisOptions.StorageConfig(), func() {
+		once.Do(func() { close(connected) })
+	})
+
+	select {
+	case <-connected:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(o.ConnectTimeout):
+		return fmt.Errorf("failed to connect to redis in %v", o.ConnectTimeout)
+	}
+}
@@ -0,0 +1,326 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package apply provides functions to apply the resources declared in a manifest file.
+package apply
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/marmotedu/component-base/pkg/validation/field"
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+const (
+	applyUsageStr = "apply -f FILENAME"
+)
+
+// ApplyOptions is an options struct to support 'apply' sub command.
+type ApplyOptions struct {
+	Filename       string
+	Prune          bool
+	Yes            bool
+	PruneWhitelist []string
+
+	resources []*Resource
+	clients   map[string]resourceClient
+
+	genericclioptions.IOStreams
+}
+
+var (
+	applyLong = templates.LongDesc(`Apply the secrets and policies declared in a manifest file.
+
+The resources which do not exist are created, the others are updated. The applied resources
+are marked with the 'managed-by: iamctl-apply' extend field.
+
+With --prune, the resources marked as managed by apply which are no longer declared in the
+manifest file are deleted after the file is applied. Resources created by other commands are
+never pruned.`)
+
+	applyExample = templates.Examples(`
+		# Apply the resources declared in iam.yaml
+		iamctl apply -f iam.yaml
+
+		# Apply iam.yaml and delete the managed resources removed from it
+		iamctl apply -f iam.yaml --prune
+
+		# Apply iam.yaml and only delete the managed policies removed from it, without confirmation
+		iamctl apply -f iam.yaml --prune --prune-whitelist-resource=policies --yes`)
+
+	applyUsageErrStr = fmt.Sprintf(
+		"expected '%s'.\nFILENAME is required for the apply command",
+		applyUsageStr,
+	)
+)
+
+// NewApplyOptions returns an initialized ApplyOptions instance.
+func NewApplyOptions(ioStreams genericclioptions.IOStreams) *ApplyOptions {
+	return &ApplyOptions{
+		IOStreams: ioStreams,
+	}
+}
+
+// NewCmdApply returns new initialized instance of 'apply' sub command.
+func NewCmdApply(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewApplyOptions(ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   applyUsageStr,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{},
+		Short:                 "Apply the resources declared in a manifest file",
+		TraverseChildren:      true,
+		Long:                  applyLong,
+		Example:               applyExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate(cmd, args))
+			cmdutil.CheckErr(o.Run(args))
+		},
+		SuggestFor: []string{},
+	}
+
+	cmd.Flags().StringVarP(&o.Filename, "filename", "f", o.Filename, "The manifest file declaring the resources to apply.")
+	cmd.Flags().BoolVar(&o.Prune, "prune", o.Prune,
+		"Delete the resources managed by apply which are not declared in the manifest file.")
+	cmd.Flags().BoolVarP(&o.Yes, "yes", "y", o.Yes, "Prune the resources without asking for confirmation.")
+	cmd.Flags().StringSliceVar(&o.PruneWhitelist, "prune-whitelist-resource", o.PruneWhitelist,
+		"Only prune the given resource types, e.g. policies. All the types are pruned if not specified.")
+
+	return cmd
+}
+
+// Complete completes all the required options.
+func (o *ApplyOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if o.Filename == "" {
+		return cmdutil.UsageErrorf(cmd, applyUsageErrStr)
+	}
+
+	data, err := ioutil.ReadFile(o.Filename)
+	if err != nil {
+		return err
+	}
+
+	if err := o.AddManifest(o.Filename, data); err != nil {
+		return err
+	}
+
+	return o.CompleteClients(f)
+}
+
+// AddManifest adds the resources declared in the manifest data, read from source, to the
+// resources to apply. A resource can not be declared by several manifests.
+func (o *ApplyOptions) AddManifest(source string, data []byte) error {
+	resources, err := parseManifest(data)
+	if err != nil {
+		return fmt.Errorf("parse %s failed: %w", source, err)
+	}
+
+	for _, r := range resources {
+		for _, added := range o.resources {
+			if added.String() == r.String() {
+				return fmt.Errorf("%s: %s is already declared", source, r)
+			}
+		}
+	}
+
+	o.resources = append(o.resources, resources...)
+
+	return nil
+}
+
+// Resources returns the resources to apply, in the order they are declared.
+func (o *ApplyOptions) Resources() []*Resource {
+	return o.resources
+}
+
+// CompleteClients completes the clients of iam-apiserver used to apply the resources.
+func (o *ApplyOptions) CompleteClients(f cmdutil.Factory) error {
+	iamclient, err := f.IAMClient()
+	if err != nil {
+		return err
+	}
+
+	o.clients = newResourceClients(iamclient.APIV1())
+
+	return nil
+}
+
+// Validate makes sure there is no discrepency in command options.
+func (o *ApplyOptions) Validate(cmd *cobra.Command, args []string) error {
+	if len(o.PruneWhitelist) != 0 && !o.Prune {
+		return cmdutil.UsageErrorf(cmd, "--prune-whitelist-resource requires --prune")
+	}
+
+	for _, resource := range o.PruneWhitelist {
+		if !isResourceType(resource) {
+			return cmdutil.UsageErrorf(cmd, "--prune-whitelist-resource: unsupported resource type '%s'", resource)
+		}
+	}
+
+	for _, r := range o.resources {
+		if v, ok := r.Object.(interface{ Validate() field.ErrorList }); ok {
+			if errs := v.Validate(); len(errs) != 0 {
+				return fmt.Errorf("%s: %w", r, errs.ToAggregate())
+			}
+		}
+	}
+
+	return nil
+}
+
+// Run executes an apply sub command using the specified options.
+func (o *ApplyOptions) Run(args []string) error {
+	ctx := context.TODO()
+
+	// existing decides between create and update, managed holds the candidates of prune.
+	existing := make(map[string]bool)
+	managed := make(map[string][]string)
+
+	for _, k := range kinds {
+		metas, err := o.clients[k.name].List(ctx)
+		if err != nil {
+			return fmt.Errorf("list %s failed: %w", k.resource, err)
+		}
+
+		for _, meta := range metas {
+			existing[k.name+"/"+meta.Name] = true
+
+			if isManaged(meta) {
+				managed[k.name] = append(managed[k.name], meta.Name)
+			}
+		}
+	}
+
+	for _, r := range o.resources {
+		if err := o.apply(ctx, r, existing[r.String()]); err != nil {
+			return err
+		}
+	}
+
+	if !o.Prune {
+		return nil
+	}
+
+	return o.prune(ctx, managed)
+}
+
+// apply creates or updates a resource, it is marked as managed by apply.
+func (o *ApplyOptions) apply(ctx context.Context, r *Resource, exists bool) error {
+	setManaged(r.Meta)
+
+	if exists {
+		if err := o.clients[r.Kind].Update(ctx, r.Object); err != nil {
+			return fmt.Errorf("update %s failed: %w", r, err)
+		}
+
+		fmt.Fprintf(o.Out, "%s configured\n", r)
+
+		return nil
+	}
+
+	if err := o.clients[r.Kind].Create(ctx, r.Object); err != nil {
+		return fmt.Errorf("create %s failed: %w", r, err)
+	}
+
+	fmt.Fprintf(o.Out, "%s created\n", r)
+
+	return nil
+}
+
+// prune deletes the managed resources which are not declared in the manifest file.
+func (o *ApplyOptions) prune(ctx context.Context, managed map[string][]string) error {
+	declared := make(map[string]bool, len(o.resources))
+	for _, r := range o.resources {
+		declared[r.String()] = true
+	}
+
+	var pruned []*Resource
+
+	for _, k := range kinds {
+		if !o.shouldPrune(k) {
+			continue
+		}
+
+		for _, name := range managed[k.name] {
+			if declared[k.name+"/"+name] {
+				continue
+			}
+
+			obj, meta := k.new()
+			meta.Name = name
+			pruned = append(pruned, &Resource{Kind: k.name, Meta: meta, Object: obj})
+		}
+	}
+
+	if len(pruned) == 0 {
+		return nil
+	}
+
+	if !o.Yes && !o.confirm(pruned) {
+		fmt.Fprintln(o.Out, "prune canceled")
+
+		return nil
+	}
+
+	for _, r := range pruned {
+		if err := o.clients[r.Kind].Delete(ctx, r.Meta.Name); err != nil {
+			return fmt.Errorf("delete %s failed: %w", r, err)
+		}
+
+		fmt.Fprintf(o.Out, "%s pruned\n", r)
+	}
+
+	return nil
+}
+
+// confirm asks the user to confirm the deletion of the resources.
+func (o *ApplyOptions) confirm(resources []*Resource) bool {
+	fmt.Fprintln(o.Out, "The following resources will be pruned:")
+
+	for _, r := range resources {
+		fmt.Fprintf(o.Out, "  %s\n", r)
+	}
+
+	fmt.Fprint(o.Out, "Do you want to continue? [y/N]: ")
+
+	answer, _ := bufio.NewReader(o.In).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
+
+// shouldPrune returns true if the resources of the kind can be pruned.
+func (o *ApplyOptions) shouldPrune(k kind) bool {
+	return len(o.PruneWhitelist) == 0 || contains(o.PruneWhitelist, k.resource)
+}
+
+func isResourceType(resource string) bool {
+	for _, k := range kinds {
+		if k.resource == resource {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+
+	return false
+}
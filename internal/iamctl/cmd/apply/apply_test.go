@@ -0,0 +1,284 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package apply
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+// fakeClient stores the metadata of the resources of a kind in memory.
+type fakeClient struct {
+	objects map[string]*metav1.ObjectMeta
+}
+
+func newFakeClient(managed []string, unmanaged []string) *fakeClient {
+	c := &fakeClient{objects: make(map[string]*metav1.ObjectMeta)}
+
+	for _, n := range managed {
+		meta := &metav1.ObjectMeta{Name: n}
+		setManaged(meta)
+		c.objects[n] = meta
+	}
+
+	for _, n := range unmanaged {
+		c.objects[n] = &metav1.ObjectMeta{Name: n}
+	}
+
+	return c
+}
+
+func (c *fakeClient) List(ctx context.Context) ([]*metav1.ObjectMeta, error) {
+	metas := make([]*metav1.ObjectMeta, 0, len(c.objects))
+	for _, meta := range c.objects {
+		metas = append(metas, meta)
+	}
+
+	return metas, nil
+}
+
+func (c *fakeClient) Create(ctx context.Context, obj interface{}) error {
+	return c.store(obj)
+}
+
+func (c *fakeClient) Update(ctx context.Context, obj interface{}) error {
+	return c.store(obj)
+}
+
+func (c *fakeClient) store(obj interface{}) error {
+	var meta *metav1.ObjectMeta
+
+	switch o := obj.(type) {
+	case *v1.Secret:
+		meta = &o.ObjectMeta
+	case *v1.Policy:
+		meta = &o.ObjectMeta
+	}
+
+	c.objects[meta.Name] = meta
+
+	return nil
+}
+
+func (c *fakeClient) Delete(ctx context.Context, name string) error {
+	delete(c.objects, name)
+
+	return nil
+}
+
+func (c *fakeClient) names() []string {
+	names := make([]string, 0, len(c.objects))
+	for name := range c.objects {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+const manifest = `
+kind: secret
+metadata:
+  name: secret1
+expires: 0
+description: applied secret
+---
+kind: policy
+metadata:
+  name: policy1
+policy:
+  description: applied policy
+  subjects: ["users:colin"]
+  actions: ["get"]
+  resources: ["resources:articles"]
+  effect: allow
+`
+
+func TestApplyOptions_Run(t *testing.T) {
+	tests := []struct {
+		name           string
+		prune          bool
+		yes            bool
+		in             string
+		pruneWhitelist []string
+		wantSecrets    []string
+		wantPolicies   []string
+	}{
+		{
+			name:         "apply",
+			wantSecrets:  []string{"secret1", "secret2", "unmanaged-secret"},
+			wantPolicies: []string{"policy1", "policy2", "unmanaged-policy"},
+		},
+		{
+			name:         "prune",
+			prune:        true,
+			yes:          true,
+			wantSecrets:  []string{"secret1", "unmanaged-secret"},
+			wantPolicies: []string{"policy1", "unmanaged-policy"},
+		},
+		{
+			name:           "prune whitelist",
+			prune:          true,
+			yes:            true,
+			pruneWhitelist: []string{"policies"},
+			wantSecrets:    []string{"secret1", "secret2", "unmanaged-secret"},
+			wantPolicies:   []string{"policy1", "unmanaged-policy"},
+		},
+		{
+			name:         "prune confirmed",
+			prune:        true,
+			in:           "y\n",
+			wantSecrets:  []string{"secret1", "unmanaged-secret"},
+			wantPolicies: []string{"policy1", "unmanaged-policy"},
+		},
+		{
+			name:         "prune canceled",
+			prune:        true,
+			in:           "n\n",
+			wantSecrets:  []string{"secret1", "secret2", "unmanaged-secret"},
+			wantPolicies: []string{"policy1", "policy2", "unmanaged-policy"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resources, err := parseManifest([]byte(manifest))
+			if err != nil {
+				t.Fatalf("parseManifest() error = %v", err)
+			}
+
+			secrets := newFakeClient([]string{"secret1", "secret2"}, []string{"unmanaged-secret"})
+			policies := newFakeClient([]string{"policy2"}, []string{"unmanaged-policy"})
+
+			out := &bytes.Buffer{}
+			o := &ApplyOptions{
+				Prune:          tt.prune,
+				Yes:            tt.yes,
+				PruneWhitelist: tt.pruneWhitelist,
+				resources:      resources,
+				clients:        map[string]resourceClient{"secret": secrets, "policy": policies},
+				IOStreams:      genericclioptions.IOStreams{In: strings.NewReader(tt.in), Out: out, ErrOut: out},
+			}
+
+			if err := o.Run(nil); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if got := secrets.names(); !reflect.DeepEqual(got, tt.wantSecrets) {
+				t.Errorf("Run() secrets = %v, want %v\n%s", got, tt.wantSecrets, out)
+			}
+
+			if got := policies.names(); !reflect.DeepEqual(got, tt.wantPolicies) {
+				t.Errorf("Run() policies = %v, want %v\n%s", got, tt.wantPolicies, out)
+			}
+
+			for _, name := range []string{"secret1", "policy1"} {
+				meta := secrets.objects[name]
+				if meta == nil {
+					meta = policies.objects[name]
+				}
+
+				if !isManaged(meta) {
+					t.Errorf("Run() %s is not marked as managed", name)
+				}
+			}
+		})
+	}
+}
+
+func Test_parseManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "manifest", data: manifest, want: []string{"secret/secret1", "policy/policy1"}},
+		{name: "empty documents", data: "---\n---\nkind: secret\nmetadata:\n  name: foo\n--- \n", want: []string{"secret/foo"}},
+		{name: "unsupported kind", data: "kind: user\nmetadata:\n  name: foo\n", wantErr: true},
+		{name: "missing name", data: "kind: secret\n", wantErr: true},
+		{name: "duplicated", data: "kind: secret\nmetadata:\n  name: foo\n---\nkind: secret\nmetadata:\n  name: foo\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resources, err := parseManifest([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var got []string
+			for _, r := range resources {
+				got = append(got, r.String())
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseManifest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyOptions_AddManifest(t *testing.T) {
+	tests := []struct {
+		name      string
+		manifests []string
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name:      "several manifests",
+			manifests: []string{manifest, "kind: policy\nmetadata:\n  name: policy2\n"},
+			want:      []string{"secret/secret1", "policy/policy1", "policy/policy2"},
+		},
+		{
+			name:      "declared by several manifests",
+			manifests: []string{manifest, "kind: policy\nmetadata:\n  name: policy1\n"},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid manifest",
+			manifests: []string{"kind: user\nmetadata:\n  name: foo\n"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewApplyOptions(genericclioptions.IOStreams{})
+
+			var err error
+			for i, m := range tt.manifests {
+				if err = o.AddManifest("manifest"+string(rune('1'+i)), []byte(m)); err != nil {
+					break
+				}
+			}
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AddManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			var got []string
+			for _, r := range o.Resources() {
+				got = append(got, r.String())
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package apply
+
+import (
+	"context"
+
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	apiclientv1 "github.com/marmotedu/marmotedu-sdk-go/marmotedu/service/iam/apiserver/v1"
+)
+
+// resourceClient manages the resources of a kind on iam-apiserver.
+type resourceClient interface {
+	// List returns the metadata of all the resources of the kind.
+	List(ctx context.Context) ([]*metav1.ObjectMeta, error)
+	Create(ctx context.Context, obj interface{}) error
+	Update(ctx context.Context, obj interface{}) error
+	Delete(ctx context.Context, name string) error
+}
+
+// newResourceClients returns the clients of the kinds which can be applied, keyed by kind.
+func newResourceClients(client apiclientv1.APIV1Interface) map[string]resourceClient {
+	return map[string]resourceClient{
+		"secret": &secretClient{client: client.Secrets()},
+		"policy": &policyClient{client: client.Policies()},
+	}
+}
+
+// unlimited lists all the resources at once.
+var unlimited int64 = -1
+
+type secretClient struct {
+	client apiclientv1.SecretInterface
+}
+
+func (c *secretClient) List(ctx context.Context) ([]*metav1.ObjectMeta, error) {
+	list, err := c.client.List(ctx, metav1.ListOptions{Limit: &unlimited})
+	if err != nil {
+		return nil, err
+	}
+
+	metas := make([]*metav1.ObjectMeta, 0, len(list.Items))
+	for _, item := range list.Items {
+		metas = append(metas, &item.ObjectMeta)
+	}
+
+	return metas, nil
+}
+
+func (c *secretClient) Create(ctx context.Context, obj interface{}) error {
+	_, err := c.client.Create(ctx, obj.(*v1.Secret), metav1.CreateOptions{})
+
+	return err
+}
+
+func (c *secretClient) Update(ctx context.Context, obj interface{}) error {
+	_, err := c.client.Update(ctx, obj.(*v1.Secret), metav1.UpdateOptions{})
+
+	return err
+}
+
+func (c *secretClient) Delete(ctx context.Context, name string) error {
+	return c.client.Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+type policyClient struct {
+	client apiclientv1.PolicyInterface
+}
+
+func (c *policyClient) List(ctx context.Context) ([]*metav1.ObjectMeta, error) {
+	list, err := c.client.List(ctx, metav1.ListOptions{Limit: &unlimited})
+	if err != nil {
+		return nil, err
+	}
+
+	metas := make([]*metav1.ObjectMeta, 0, len(list.Items))
+	for _, item := range list.Items {
+		metas = append(metas, &item.ObjectMeta)
+	}
+
+	return metas, nil
+}
+
+func (c *policyClient) Create(ctx context.Context, obj interface{}) error {
+	_, err := c.client.Create(ctx, obj.(*v1.Policy), metav1.CreateOptions{})
+
+	return err
+}
+
+func (c *policyClient) Update(ctx context.Context, obj interface{}) error {
+	_, err := c.client.Update(ctx, obj.(*v1.Policy), metav1.UpdateOptions{})
+
+	return err
+}
+
+func (c *policyClient) Delete(ctx context.Context, name string) error {
+	return c.client.Delete(ctx, name, metav1.DeleteOptions{})
+}
@@ -0,0 +1,174 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package apply
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ghodss/yaml"
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+)
+
+const (
+	// managedByKey is the key of the extend field marking the resources managed by apply.
+	managedByKey = "managed-by"
+	// managedByValue is the value of the extend field marking the resources managed by apply.
+	managedByValue = "iamctl-apply"
+)
+
+// kind describes a type of resource which can be applied.
+type kind struct {
+	// name is the kind of the resource in a manifest file, e.g. policy.
+	name string
+	// resource is the resource type used by --prune-whitelist-resource, e.g. policies.
+	resource string
+	// new returns an empty object of the kind and its metadata.
+	new func() (interface{}, *metav1.ObjectMeta)
+}
+
+// kinds are the kinds which can be applied, in the order they are applied and pruned.
+var kinds = []kind{
+	{
+		name:     "secret",
+		resource: "secrets",
+		new: func() (interface{}, *metav1.ObjectMeta) {
+			secret := &v1.Secret{}
+
+			return secret, &secret.ObjectMeta
+		},
+	},
+	{
+		name:     "policy",
+		resource: "policies",
+		new: func() (interface{}, *metav1.ObjectMeta) {
+			policy := &v1.Policy{}
+
+			return policy, &policy.ObjectMeta
+		},
+	},
+}
+
+// Resource is a resource declared in a manifest file.
+type Resource struct {
+	Kind   string
+	Meta   *metav1.ObjectMeta
+	Object interface{}
+}
+
+// String returns the resource as KIND/NAME.
+func (r *Resource) String() string {
+	return r.Kind + "/" + r.Meta.Name
+}
+
+// isManaged returns true if the resource is marked as managed by apply.
+func isManaged(meta *metav1.ObjectMeta) bool {
+	value, _ := meta.Extend[managedByKey].(string)
+
+	return value == managedByValue
+}
+
+// setManaged marks the resource as managed by apply.
+func setManaged(meta *metav1.ObjectMeta) {
+	if meta.Extend == nil {
+		meta.Extend = metav1.Extend{}
+	}
+
+	meta.Extend[managedByKey] = managedByValue
+}
+
+// findKind returns the kind with the given name.
+func findKind(name string) (kind, bool) {
+	for _, k := range kinds {
+		if k.name == name {
+			return k, true
+		}
+	}
+
+	return kind{}, false
+}
+
+// parseManifest parses the resources of a manifest file, which holds YAML or JSON documents
+// separated by '---' lines. Each document declares its kind along with the fields of the
+// resource, e.g.:
+//
+//	kind: policy
+//	metadata:
+//	  name: foo
+//	policy:
+//	  effect: allow
+func parseManifest(data []byte) ([]*Resource, error) {
+	var resources []*Resource
+
+	seen := make(map[string]bool)
+
+	for i, doc := range splitDocuments(data) {
+		jsonData, err := yaml.YAMLToJSON(doc)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i+1, err)
+		}
+
+		var typeMeta struct {
+			Kind string `json:"kind"`
+		}
+		if err := json.Unmarshal(jsonData, &typeMeta); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i+1, err)
+		}
+
+		k, ok := findKind(typeMeta.Kind)
+		if !ok {
+			return nil, fmt.Errorf("document %d: unsupported kind '%s'", i+1, typeMeta.Kind)
+		}
+
+		obj, meta := k.new()
+		if err := json.Unmarshal(jsonData, obj); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i+1, err)
+		}
+
+		r := &Resource{Kind: k.name, Meta: meta, Object: obj}
+		if meta.Name == "" {
+			return nil, fmt.Errorf("document %d: metadata.name is required", i+1)
+		}
+
+		if seen[r.String()] {
+			return nil, fmt.Errorf("document %d: %s is declared more than once", i+1, r)
+		}
+
+		seen[r.String()] = true
+		resources = append(resources, r)
+	}
+
+	return resources, nil
+}
+
+// splitDocuments splits the documents of a manifest file, empty documents are skipped.
+func splitDocuments(data []byte) [][]byte {
+	var (
+		docs [][]byte
+		doc  []byte
+	)
+
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if string(bytes.TrimSpace(line)) != "---" {
+			doc = append(doc, line...)
+
+			continue
+		}
+
+		if len(bytes.TrimSpace(doc)) != 0 {
+			docs = append(docs, doc)
+		}
+
+		doc = nil
+	}
+
+	if len(bytes.TrimSpace(doc)) != 0 {
+		docs = append(docs, doc)
+	}
+
+	return docs
+}
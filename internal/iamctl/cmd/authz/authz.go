@@ -0,0 +1,36 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package authz provides functions to query iam-authz-server.
+package authz
+
+import (
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+var authzLong = templates.LongDesc(`
+	Authz command.
+
+	This commands is used to query iam-authz-server with the secretID and secretKey of the user.`)
+
+// NewCmdAuthz returns new initialized instance of 'authz' sub command.
+func NewCmdAuthz(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "authz SUBCOMMAND",
+		DisableFlagsInUseLine: true,
+		Short:                 "Query the authorization decisions of iam-authz-server",
+		Long:                  authzLong,
+		Run:                   cmdutil.DefaultSubCommandRun(ioStreams.ErrOut),
+	}
+
+	// add subcommands
+	cmd.AddCommand(NewCmdPreview(f, ioStreams))
+	cmd.AddCommand(NewCmdPolicyTrace(f, ioStreams))
+
+	return cmd
+}
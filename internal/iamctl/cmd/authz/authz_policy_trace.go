@@ -0,0 +1,239 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authz
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	authzv1 "github.com/marmotedu/api/authz/v1"
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/marmotedu/component-base/pkg/scheme"
+	restclient "github.com/marmotedu/marmotedu-sdk-go/rest"
+	"github.com/ory/ladon"
+	"github.com/spf13/cobra"
+
+	"github.com/marmotedu/iam/internal/authzserver/authorization"
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+const (
+	policyTraceUsageStr = "policy-trace SUBJECT RESOURCE ACTION"
+	policyTracePath     = "/v1/authz/trace"
+)
+
+// traceResponse is the response body of the authorization trace api.
+type traceResponse struct {
+	authzv1.Response
+	Trace []authorization.PolicyTrace `json:"trace"`
+}
+
+// PolicyTraceOptions is an options struct to support 'authz policy-trace' sub command.
+type PolicyTraceOptions struct {
+	AuthzServer string
+	Context     string
+
+	request *ladon.Request
+	client  *restclient.RESTClient
+	genericclioptions.IOStreams
+}
+
+var (
+	policyTraceLong = templates.LongDesc(`
+		Show how the policies of the user are evaluated for a request.
+
+		The request is evaluated by iam-authz-server against the live policies of the user. The decision is
+		printed along with a tree of the policies: whether their subjects, resources and actions matched and,
+		for each of their conditions, the value of the request context checked, the expected one and whether
+		it matched. All the conditions are evaluated, even once a condition of the policy failed.`)
+
+	policyTraceExample = templates.Examples(`
+		# Show why a request is denied
+		iamctl authz policy-trace users:maria resources:articles:ladon-introduction delete
+
+		# Show how the conditions of the policies are evaluated against the request context
+		iamctl authz policy-trace users:maria resources:articles:ladon-introduction delete --context='{"remoteIPAddress":"1.2.3.4"}'`)
+
+	policyTraceUsageErrStr = fmt.Sprintf(
+		"expected '%s'.\nSUBJECT, RESOURCE and ACTION are required arguments for the policy-trace command",
+		policyTraceUsageStr,
+	)
+)
+
+// NewPolicyTraceOptions returns an initialized PolicyTraceOptions instance.
+func NewPolicyTraceOptions(ioStreams genericclioptions.IOStreams) *PolicyTraceOptions {
+	return &PolicyTraceOptions{
+		AuthzServer: "http://127.0.0.1:9090",
+		IOStreams:   ioStreams,
+	}
+}
+
+// NewCmdPolicyTrace returns new initialized instance of 'authz policy-trace' sub command.
+func NewCmdPolicyTrace(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewPolicyTraceOptions(ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   policyTraceUsageStr,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{},
+		Short:                 "Show how the policies of the user are evaluated for a request",
+		TraverseChildren:      true,
+		Long:                  policyTraceLong,
+		Example:               policyTraceExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate(cmd, args))
+			cmdutil.CheckErr(o.Run(args))
+		},
+		SuggestFor: []string{},
+	}
+
+	cmd.Flags().StringVar(&o.AuthzServer, "authz-server", o.AuthzServer, "The address of iam-authz-server.")
+	cmd.Flags().StringVar(&o.Context, "context", o.Context, ""+
+		"The JSON object of the request context, whose values are checked by the conditions of the policies.")
+
+	return cmd
+}
+
+// Complete completes all the required options.
+func (o *PolicyTraceOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) != 3 {
+		return cmdutil.UsageErrorf(cmd, policyTraceUsageErrStr)
+	}
+
+	o.request = &ladon.Request{
+		Subject:  args[0],
+		Resource: args[1],
+		Action:   args[2],
+		Context:  ladon.Context{},
+	}
+
+	if o.Context != "" {
+		if err := json.Unmarshal([]byte(o.Context), &o.request.Context); err != nil {
+			return fmt.Errorf("decode --context failed: %w", err)
+		}
+	}
+
+	clientConfig, err := f.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	// iam-authz-server only accepts the jwt tokens signed with the secret of the user,
+	// whose audience is derived from the group.
+	config := *clientConfig
+	config.Host = o.AuthzServer
+	config.GroupVersion = &scheme.GroupVersion{Group: "iam.authz", Version: "v1"}
+	config.BearerToken = ""
+	config.Username = ""
+	config.Password = ""
+
+	o.client, err = restclient.RESTClientFor(&config)
+
+	return err
+}
+
+// Validate makes sure there is no discrepency in command options.
+func (o *PolicyTraceOptions) Validate(cmd *cobra.Command, args []string) error {
+	if o.AuthzServer == "" {
+		return cmdutil.UsageErrorf(cmd, "--authz-server must not be empty")
+	}
+
+	return nil
+}
+
+// Run executes a 'authz policy-trace' sub command using the specified options.
+func (o *PolicyTraceOptions) Run(args []string) error {
+	var rsp traceResponse
+	if err := o.client.Post().AbsPath(policyTracePath).Body(o.request).Do(context.TODO()).Into(&rsp); err != nil {
+		return err
+	}
+
+	printTrace(o.Out, &rsp)
+
+	return nil
+}
+
+// printTrace prints the decision followed by the tree of the evaluated policies.
+func printTrace(w io.Writer, rsp *traceResponse) {
+	decision := "denied"
+	if rsp.Allowed {
+		decision = "allowed"
+	}
+
+	fmt.Fprintf(w, "Decision: %s\n", decision)
+
+	if rsp.Reason != "" {
+		fmt.Fprintf(w, "Reason:   %s\n", rsp.Reason)
+	}
+
+	if len(rsp.Trace) == 0 {
+		fmt.Fprintln(w, "No policy evaluated")
+
+		return
+	}
+
+	fmt.Fprintln(w, "Policies:")
+
+	for i, policy := range rsp.Trace {
+		branch, indent := treeBranch(i == len(rsp.Trace)-1)
+
+		status := matchStatus(policy.Matched)
+		if policy.Decider {
+			status += ", decider"
+		}
+
+		fmt.Fprintf(w, "%s%s (%s): %s\n", branch, policy.PolicyID, policy.Effect, status)
+
+		lines := []string{
+			"subject: " + matchStatus(policy.SubjectMatched),
+			"resource: " + matchStatus(policy.ResourceMatched),
+			"action: " + matchStatus(policy.ActionMatched),
+		}
+
+		if policy.Error != "" {
+			lines = append(lines, "error: "+policy.Error)
+		}
+
+		for _, condition := range policy.Conditions {
+			lines = append(lines, fmt.Sprintf("condition %s (%s): %s, input %s, expected %s", condition.Key,
+				condition.Type, matchStatus(condition.Matched), jsonValue(condition.Input), jsonValue(condition.Expected)))
+		}
+
+		for j, line := range lines {
+			branch, _ := treeBranch(j == len(lines)-1)
+			fmt.Fprintf(w, "%s%s%s\n", indent, branch, line)
+		}
+	}
+}
+
+// treeBranch returns the branch of a node of the tree and the indentation of its children.
+func treeBranch(last bool) (string, string) {
+	if last {
+		return "└── ", "    "
+	}
+
+	return "├── ", "│   "
+}
+
+func matchStatus(matched bool) string {
+	if matched {
+		return "matched"
+	}
+
+	return "not matched"
+}
+
+func jsonValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+
+	return string(data)
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authz
+
+import (
+	"bytes"
+	"testing"
+
+	authzv1 "github.com/marmotedu/api/authz/v1"
+
+	"github.com/marmotedu/iam/internal/authzserver/authorization"
+)
+
+func TestPrintTrace(t *testing.T) {
+	tests := []struct {
+		name string
+		rsp  *traceResponse
+		want string
+	}{
+		{
+			name: "no policy",
+			rsp:  &traceResponse{Response: authzv1.Response{Denied: true, Reason: "Request was denied by default"}},
+			want: "Decision: denied\nReason:   Request was denied by default\nNo policy evaluated\n",
+		},
+		{
+			name: "multi-condition policies",
+			rsp: &traceResponse{
+				Response: authzv1.Response{Allowed: true},
+				Trace: []authorization.PolicyTrace{
+					{
+						PolicyID: "office", Effect: "allow", SubjectMatched: true, ResourceMatched: true,
+						ActionMatched: true, Matched: true, Decider: true,
+						Conditions: []authorization.ConditionTrace{
+							{Key: "owner", Type: "StringEqualCondition", Input: "peter", Expected: "peter", Matched: true},
+							{
+								Key: "remoteIPAddress", Type: "CIDRCondition", Input: "10.1.2.3", Expected: "10.0.0.0/8",
+								Matched: true,
+							},
+						},
+					},
+					{
+						PolicyID: "lock", Effect: "deny", SubjectMatched: true, ResourceMatched: true, ActionMatched: true,
+						Conditions: []authorization.ConditionTrace{
+							{Key: "locked", Type: "BooleanCondition", Expected: true},
+						},
+					},
+				},
+			},
+			want: `Decision: allowed
+Policies:
+├── office (allow): matched, decider
+│   ├── subject: matched
+│   ├── resource: matched
+│   ├── action: matched
+│   ├── condition owner (StringEqualCondition): matched, input "peter", expected "peter"
+│   └── condition remoteIPAddress (CIDRCondition): matched, input "10.1.2.3", expected "10.0.0.0/8"
+└── lock (deny): not matched
+    ├── subject: matched
+    ├── resource: matched
+    ├── action: matched
+    └── condition locked (BooleanCondition): not matched, input null, expected true
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			printTrace(&out, tt.rsp)
+
+			if got := out.String(); got != tt.want {
+				t.Errorf("printTrace() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authz
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	authzv1 "github.com/marmotedu/api/authz/v1"
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/marmotedu/component-base/pkg/scheme"
+	restclient "github.com/marmotedu/marmotedu-sdk-go/rest"
+	"github.com/ory/ladon"
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	// register the iam specific ladon conditions, so that policies using them can be decoded.
+	_ "github.com/marmotedu/iam/internal/pkg/conditions"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+const (
+	previewUsageStr = "preview REQUEST"
+	previewPath     = "/v1/authz/preview"
+)
+
+// previewRequest is the request body of the authorization preview api.
+type previewRequest struct {
+	Request          *ladon.Request         `json:"request"`
+	ExtraPolicies    []*ladon.DefaultPolicy `json:"extraPolicies,omitempty"`
+	ExcludePolicyIDs []string               `json:"excludePolicyIDs,omitempty"`
+}
+
+// previewResponse is the response body of the authorization preview api.
+type previewResponse struct {
+	authzv1.Response
+	Deciders   []string `json:"deciders"`
+	Conclusion string   `json:"conclusion,omitempty"`
+}
+
+// PreviewOptions is an options struct to support 'authz preview' sub command.
+type PreviewOptions struct {
+	AuthzServer string
+	AddPolicies []string
+	Exclude     []string
+
+	request *previewRequest
+	client  *restclient.RESTClient
+	genericclioptions.IOStreams
+}
+
+var (
+	previewLong = templates.LongDesc(`
+		Preview the authorization decision of a request with ephemeral policies.
+
+		The request is evaluated by iam-authz-server against the live policies of the user,
+		with the policies read from the --add-policy files added and the policies identified by --exclude removed.
+		Nothing is persisted. The decision is printed along with the policies which caused it.`)
+
+	previewExample = templates.Examples(`
+		# Preview whether a request would be allowed with an additional policy
+		iamctl authz preview '{"subject":"users:maria","action":"delete","resource":"resources:articles:ladon-introduction"}' --add-policy=policy.json
+
+		# Preview whether a request would still be allowed without a policy
+		iamctl authz preview '{"subject":"users:maria","action":"delete","resource":"resources:articles:ladon-introduction"}' --exclude=authztest
+
+		# Preview a request against iam-authz-server listening on another address
+		iamctl authz preview '{"subject":"users:maria","action":"delete","resource":"resources:printer"}' --authz-server=https://127.0.0.1:9443`)
+
+	previewUsageErrStr = fmt.Sprintf(
+		"expected '%s'.\nREQUEST is a required argument for the preview command",
+		previewUsageStr,
+	)
+)
+
+// NewPreviewOptions returns an initialized PreviewOptions instance.
+func NewPreviewOptions(ioStreams genericclioptions.IOStreams) *PreviewOptions {
+	return &PreviewOptions{
+		AuthzServer: "http://127.0.0.1:9090",
+		IOStreams:   ioStreams,
+	}
+}
+
+// NewCmdPreview returns new initialized instance of 'authz preview' sub command.
+func NewCmdPreview(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewPreviewOptions(ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   previewUsageStr,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{},
+		Short:                 "Preview the authorization decision of a request with ephemeral policies",
+		TraverseChildren:      true,
+		Long:                  previewLong,
+		Example:               previewExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate(cmd, args))
+			cmdutil.CheckErr(o.Run(args))
+		},
+		SuggestFor: []string{},
+	}
+
+	cmd.Flags().StringVar(&o.AuthzServer, "authz-server", o.AuthzServer, "The address of iam-authz-server.")
+	cmd.Flags().StringSliceVar(&o.AddPolicies, "add-policy", o.AddPolicies, ""+
+		"The JSON file of a policy to add to the policies of the user, may be used more than once.")
+	cmd.Flags().StringSliceVar(&o.Exclude, "exclude", o.Exclude, ""+
+		"The ID of a policy to remove from the policies of the user, may be used more than once.")
+
+	return cmd
+}
+
+// Complete completes all the required options.
+func (o *PreviewOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return cmdutil.UsageErrorf(cmd, previewUsageErrStr)
+	}
+
+	var r ladon.Request
+	if err := json.Unmarshal([]byte(args[0]), &r); err != nil {
+		return fmt.Errorf("decode request failed: %w", err)
+	}
+
+	o.request = &previewRequest{
+		Request:          &r,
+		ExcludePolicyIDs: o.Exclude,
+	}
+
+	for _, filename := range o.AddPolicies {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("read policy file failed: %w", err)
+		}
+
+		var pol ladon.DefaultPolicy
+		if err := json.Unmarshal(data, &pol); err != nil {
+			return fmt.Errorf("decode policy file %s failed: %w", filename, err)
+		}
+
+		o.request.ExtraPolicies = append(o.request.ExtraPolicies, &pol)
+	}
+
+	clientConfig, err := f.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	// iam-authz-server only accepts the jwt tokens signed with the secret of the user,
+	// whose audience is derived from the group.
+	config := *clientConfig
+	config.Host = o.AuthzServer
+	config.GroupVersion = &scheme.GroupVersion{Group: "iam.authz", Version: "v1"}
+	config.BearerToken = ""
+	config.Username = ""
+	config.Password = ""
+
+	o.client, err = restclient.RESTClientFor(&config)
+
+	return err
+}
+
+// Validate makes sure there is no discrepency in command options.
+func (o *PreviewOptions) Validate(cmd *cobra.Command, args []string) error {
+	if o.AuthzServer == "" {
+		return cmdutil.UsageErrorf(cmd, "--authz-server must not be empty")
+	}
+
+	return nil
+}
+
+// Run executes a 'authz preview' sub command using the specified options.
+func (o *PreviewOptions) Run(args []string) error {
+	var rsp previewResponse
+	if err := o.client.Post().AbsPath(previewPath).Body(o.request).Do(context.TODO()).Into(&rsp); err != nil {
+		return err
+	}
+
+	decision := "denied"
+	if rsp.Allowed {
+		decision = "allowed"
+	}
+
+	fmt.Fprintf(o.Out, "Decision:   %s\n", decision)
+
+	if rsp.Reason != "" {
+		fmt.Fprintf(o.Out, "Reason:     %s\n", rsp.Reason)
+	}
+
+	fmt.Fprintf(o.Out, "Deciders:   %s\n", strings.Join(rsp.Deciders, ", "))
+	fmt.Fprintf(o.Out, "Conclusion: %s\n", rsp.Conclusion)
+
+	return nil
+}
@@ -14,6 +14,9 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/marmotedu/iam/internal/iamctl/cmd/analytics"
+	"github.com/marmotedu/iam/internal/iamctl/cmd/apply"
+	"github.com/marmotedu/iam/internal/iamctl/cmd/authz"
 	"github.com/marmotedu/iam/internal/iamctl/cmd/color"
 	"github.com/marmotedu/iam/internal/iamctl/cmd/completion"
 	"github.com/marmotedu/iam/internal/iamctl/cmd/info"
@@ -21,7 +24,9 @@ import (
 	"github.com/marmotedu/iam/internal/iamctl/cmd/new"
 	"github.com/marmotedu/iam/internal/iamctl/cmd/options"
 	"github.com/marmotedu/iam/internal/iamctl/cmd/policy"
+	"github.com/marmotedu/iam/internal/iamctl/cmd/policygroup"
 	"github.com/marmotedu/iam/internal/iamctl/cmd/secret"
+	"github.com/marmotedu/iam/internal/iamctl/cmd/serviceaccount"
 	"github.com/marmotedu/iam/internal/iamctl/cmd/set"
 	"github.com/marmotedu/iam/internal/iamctl/cmd/user"
 	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
@@ -30,6 +35,7 @@ import (
 	"github.com/marmotedu/iam/internal/iamctl/util/templates"
 	genericapiserver "github.com/marmotedu/iam/internal/pkg/server"
 	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+	"github.com/marmotedu/iam/pkg/version/verflag"
 )
 
 // NewDefaultIAMCtlCommand creates the `iamctl` command with default arguments.
@@ -52,6 +58,8 @@ func NewIAMCtlCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 		// Hook before and after Run initialize and write profiles to disk,
 		// respectively.
 		PersistentPreRunE: func(*cobra.Command, []string) error {
+			verflag.PrintAndExitIfRequested()
+
 			return initProfiling()
 		},
 		PersistentPostRunE: func(*cobra.Command, []string) error {
@@ -67,6 +75,7 @@ func NewIAMCtlCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 	flags.SetNormalizeFunc(cliflag.WordSepNormalizeFunc)
 
 	addProfilingFlags(flags)
+	verflag.AddFlags(flags)
 
 	iamConfigFlags := genericclioptions.NewConfigFlags(true).WithDeprecatedPasswordFlag().WithDeprecatedSecretFlag()
 	iamConfigFlags.AddFlags(flags)
@@ -102,12 +111,17 @@ func NewIAMCtlCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 				user.NewCmdUser(f, ioStreams),
 				secret.NewCmdSecret(f, ioStreams),
 				policy.NewCmdPolicy(f, ioStreams),
+				policygroup.NewCmdPolicyGroup(f, ioStreams),
+				serviceaccount.NewCmdServiceAccount(f, ioStreams),
+				apply.NewCmdApply(f, ioStreams),
 			},
 		},
 		{
 			Message: "Troubleshooting and Debugging Commands:",
 			Commands: []*cobra.Command{
 				validate.NewCmdValidate(f, ioStreams),
+				authz.NewCmdAuthz(f, ioStreams),
+				analytics.NewCmdAnalytics(f, ioStreams),
 			},
 		},
 		{
@@ -16,7 +16,7 @@ import (
 var jwtLong = templates.LongDesc(`
 	JWT command.
 
-	This commands is used to sigin/show/verify jwt token.`)
+	This commands is used to sigin/show/verify jwt token and to list the jwt signing keys of iam-apiserver.`)
 
 // NewCmdJWT returns new initialized instance of 'jwt' sub command.
 func NewCmdJWT(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
@@ -32,6 +32,7 @@ func NewCmdJWT(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.
 	cmd.AddCommand(NewCmdSign(f, ioStreams))
 	cmd.AddCommand(NewCmdShow(f, ioStreams))
 	cmd.AddCommand(NewCmdVerify(f, ioStreams))
+	cmd.AddCommand(NewCmdListKeys(f, ioStreams))
 
 	return cmd
 }
@@ -0,0 +1,107 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package jwt
+
+import (
+	"context"
+	"strconv"
+
+	restclient "github.com/marmotedu/marmotedu-sdk-go/rest"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	iamjwt "github.com/marmotedu/iam/internal/pkg/jwt"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+const jwtKeysPath = "/v1/jwt/keys"
+
+// ListKeysOptions is an options struct to support list-keys subcommands.
+type ListKeysOptions struct {
+	client *restclient.RESTClient
+	genericclioptions.IOStreams
+}
+
+var listKeysExample = templates.Examples(`
+		# Display the keys accepted by iam-apiserver to verify jwt tokens
+		iamctl jwt list-keys`)
+
+// NewListKeysOptions returns an initialized ListKeysOptions instance.
+func NewListKeysOptions(ioStreams genericclioptions.IOStreams) *ListKeysOptions {
+	return &ListKeysOptions{
+		IOStreams: ioStreams,
+	}
+}
+
+// NewCmdListKeys returns new initialized instance of list-keys sub command.
+func NewCmdListKeys(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewListKeysOptions(ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   "list-keys",
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{},
+		Short:                 "Display the active jwt signing keys of iam-apiserver",
+		TraverseChildren:      true,
+		Long:                  "Display the id and the expiry of the keys accepted by iam-apiserver to verify jwt tokens.",
+		Example:               listKeysExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate(cmd, args))
+			cmdutil.CheckErr(o.Run(args))
+		},
+		SuggestFor: []string{},
+	}
+
+	return cmd
+}
+
+// Complete completes all the required options.
+func (o *ListKeysOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	var err error
+	o.client, err = f.RESTClient()
+
+	return err
+}
+
+// Validate makes sure there is no discrepency in command options.
+func (o *ListKeysOptions) Validate(cmd *cobra.Command, args []string) error {
+	return nil
+}
+
+// Run executes a list-keys subcommand using the specified options.
+func (o *ListKeysOptions) Run(args []string) error {
+	var keys iamjwt.KeyList
+	if err := o.client.Get().AbsPath(jwtKeysPath).Do(context.TODO()).Into(&keys); err != nil {
+		return err
+	}
+
+	data := make([][]string, 0, len(keys.Items))
+	table := tablewriter.NewWriter(o.Out)
+
+	for _, key := range keys.Items {
+		expires := "never"
+		if !key.ExpiresAt.IsZero() {
+			expires = key.ExpiresAt.Format("2006-01-02 15:04:05")
+		}
+
+		data = append(data, []string{
+			key.ID,
+			key.Algorithm,
+			strconv.FormatBool(key.Current),
+			key.CreatedAt.Format("2006-01-02 15:04:05"),
+			expires,
+		})
+	}
+
+	table.SetHeader([]string{"Kid", "Algorithm", "Current", "Created", "Expires"})
+	table = cmdutil.TableWriterDefaultConfig(table)
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
@@ -10,6 +10,8 @@ import (
 
 	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
 	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	// register the iam specific ladon conditions, so that policies using them can be decoded.
+	_ "github.com/marmotedu/iam/internal/pkg/conditions"
 	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
 )
 
@@ -33,6 +35,7 @@ func NewCmdPolicy(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cob
 	cmd.AddCommand(NewCmdList(f, ioStreams))
 	cmd.AddCommand(NewCmdDelete(f, ioStreams))
 	cmd.AddCommand(NewCmdUpdate(f, ioStreams))
+	cmd.AddCommand(NewCmdApplyFromGit(f, ioStreams))
 
 	return cmd
 }
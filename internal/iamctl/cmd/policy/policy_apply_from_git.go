@@ -0,0 +1,276 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/spf13/cobra"
+
+	"github.com/marmotedu/iam/internal/iamctl/cmd/apply"
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+const (
+	applyFromGitUsageStr = "apply-from-git REPO_URL PATH"
+)
+
+// ApplyFromGitOptions is an options struct to support apply-from-git subcommands.
+type ApplyFromGitOptions struct {
+	Branch     string
+	Tag        string
+	Depth      int
+	SSHKeyFile string
+
+	repoURL string
+	path    string
+	// commit is the hash of the commit the policies are read from.
+	commit string
+	apply  *apply.ApplyOptions
+	genericclioptions.IOStreams
+}
+
+var (
+	applyFromGitLong = templates.LongDesc(`Apply the policies declared in a git repository.
+
+The repository is cloned to a temporary directory, and the policies declared in the
+*.yaml, *.yml and *.json manifest files under PATH are applied like 'iamctl apply' does.
+The hash of the applied commit is printed on success.`)
+
+	applyFromGitExample = templates.Examples(`
+		# Apply the policies declared under policies/ on the default branch
+		iamctl policy apply-from-git https://github.com/example/iam-policies.git policies
+
+		# Apply the policies of the v1.0.0 tag of a private repository
+		iamctl policy apply-from-git git@github.com:example/iam-policies.git policies --tag=v1.0.0 --ssh-key-file=$HOME/.ssh/id_rsa`)
+
+	applyFromGitUsageErrStr = fmt.Sprintf(
+		"expected '%s'.\nREPO_URL and PATH are required arguments for the apply-from-git command",
+		applyFromGitUsageStr,
+	)
+)
+
+// NewApplyFromGitOptions returns an initialized ApplyFromGitOptions instance.
+func NewApplyFromGitOptions(ioStreams genericclioptions.IOStreams) *ApplyFromGitOptions {
+	return &ApplyFromGitOptions{
+		Depth:     1,
+		apply:     apply.NewApplyOptions(ioStreams),
+		IOStreams: ioStreams,
+	}
+}
+
+// NewCmdApplyFromGit returns new initialized instance of apply-from-git sub command.
+func NewCmdApplyFromGit(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewApplyFromGitOptions(ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   applyFromGitUsageStr,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{},
+		Short:                 "Apply the policies declared in a git repository",
+		TraverseChildren:      true,
+		Long:                  applyFromGitLong,
+		Example:               applyFromGitExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate(cmd, args))
+			cmdutil.CheckErr(o.Run(args))
+		},
+		SuggestFor: []string{},
+	}
+
+	cmd.Flags().StringVar(&o.Branch, "branch", o.Branch, "The branch to apply, the default branch if neither --branch nor --tag is set.")
+	cmd.Flags().StringVar(&o.Tag, "tag", o.Tag, "The tag to apply.")
+	cmd.Flags().IntVar(&o.Depth, "depth", o.Depth, "The number of commits to fetch, 0 fetches the whole history.")
+	cmd.Flags().StringVar(&o.SSHKeyFile, "ssh-key-file", o.SSHKeyFile,
+		"The private key used to clone the repository over ssh, it must not be protected by a passphrase.")
+
+	return cmd
+}
+
+// Complete completes all the required options.
+func (o *ApplyFromGitOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return cmdutil.UsageErrorf(cmd, applyFromGitUsageErrStr)
+	}
+
+	o.repoURL, o.path = args[0], args[1]
+
+	if o.Branch != "" && o.Tag != "" {
+		return cmdutil.UsageErrorf(cmd, "--branch and --tag are mutually exclusive")
+	}
+
+	if o.Depth < 0 {
+		return cmdutil.UsageErrorf(cmd, "--depth can not be negative")
+	}
+
+	commit, manifests, err := o.clone(context.TODO())
+	if err != nil {
+		return err
+	}
+
+	o.commit = commit
+
+	for _, m := range manifests {
+		if err := o.apply.AddManifest(m.path, m.data); err != nil {
+			return err
+		}
+	}
+
+	return o.apply.CompleteClients(f)
+}
+
+// Validate makes sure there is no discrepency in command options.
+func (o *ApplyFromGitOptions) Validate(cmd *cobra.Command, args []string) error {
+	resources := o.apply.Resources()
+	if len(resources) == 0 {
+		return fmt.Errorf("no policy is declared under %s", o.path)
+	}
+
+	for _, r := range resources {
+		if r.Kind != "policy" {
+			return fmt.Errorf("%s: only the policies can be applied from git", r)
+		}
+	}
+
+	return o.apply.Validate(cmd, args)
+}
+
+// Run executes an apply-from-git subcommand using the specified options.
+func (o *ApplyFromGitOptions) Run(args []string) error {
+	if err := o.apply.Run(args); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(o.Out, "commit %s applied\n", o.commit)
+
+	return nil
+}
+
+// manifest is a manifest file read from the repository.
+type manifest struct {
+	// path is the path of the file in the repository.
+	path string
+	data []byte
+}
+
+// clone clones the repository to a temporary directory, and returns the hash of the cloned
+// commit and the manifest files under the path, in lexical order.
+func (o *ApplyFromGitOptions) clone(ctx context.Context) (string, []manifest, error) {
+	dir, err := ioutil.TempDir("", "iamctl-policy-")
+	if err != nil {
+		return "", nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	cloneOptions := &git.CloneOptions{
+		URL:   o.repoURL,
+		Depth: o.Depth,
+	}
+
+	switch {
+	case o.Branch != "":
+		cloneOptions.ReferenceName = plumbing.NewBranchReferenceName(o.Branch)
+		cloneOptions.SingleBranch = true
+	case o.Tag != "":
+		cloneOptions.ReferenceName = plumbing.NewTagReferenceName(o.Tag)
+		cloneOptions.SingleBranch = true
+	}
+
+	if o.SSHKeyFile != "" {
+		var auth transport.AuthMethod
+
+		auth, err = ssh.NewPublicKeysFromFile("git", o.SSHKeyFile, "")
+		if err != nil {
+			return "", nil, fmt.Errorf("load --ssh-key-file failed: %w", err)
+		}
+
+		cloneOptions.Auth = auth
+	}
+
+	repo, err := git.PlainCloneContext(ctx, dir, false, cloneOptions)
+	if err != nil {
+		return "", nil, fmt.Errorf("clone %s failed: %w", o.repoURL, err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return "", nil, err
+	}
+
+	manifests, err := readManifests(dir, o.path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return head.Hash().String(), manifests, nil
+}
+
+// readManifests returns the manifest files under the path of the repository cloned in dir.
+func readManifests(dir, path string) ([]manifest, error) {
+	clean := filepath.Clean(path)
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%s is not a path in the repository", path)
+	}
+
+	root := filepath.Join(dir, clean)
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("%s not found in the repository", path)
+	}
+
+	var manifests []manifest
+
+	err = filepath.Walk(root, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		rel, _ := filepath.Rel(dir, name)
+		manifests = append(manifests, manifest{path: filepath.ToSlash(rel), data: data})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() && len(manifests) == 0 {
+		return nil, fmt.Errorf("%s is not a manifest file", path)
+	}
+
+	return manifests, nil
+}
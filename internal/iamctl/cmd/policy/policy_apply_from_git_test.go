@@ -0,0 +1,176 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// commitFiles writes the files to the worktree of repo and commits them.
+func commitFiles(t *testing.T, repo *git.Repository, files map[string]string) plumbing.Hash {
+	t.Helper()
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Worktree() error = %v", err)
+	}
+
+	for name, content := range files {
+		path := filepath.Join(wt.Filesystem.Root(), name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := wt.Add(name); err != nil {
+			t.Fatalf("Add(%s) error = %v", name, err)
+		}
+	}
+
+	hash, err := wt.Commit("update policies", &git.CommitOptions{
+		Author: &object.Signature{Name: "colin", Email: "colin@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	return hash
+}
+
+// newBareRepository returns a bare repository whose master branch has two commits, the
+// first one being the v1.0.0 tag and the dev branch.
+func newBareRepository(t *testing.T) (string, plumbing.Hash, plumbing.Hash) {
+	t.Helper()
+
+	bare := t.TempDir()
+	if _, err := git.PlainInit(bare, true); err != nil {
+		t.Fatalf("PlainInit() error = %v", err)
+	}
+
+	repo, err := git.PlainInit(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("PlainInit() error = %v", err)
+	}
+
+	first := commitFiles(t, repo, map[string]string{
+		"policies/a.yaml":     "kind: policy\nmetadata:\n  name: a\n",
+		"policies/sub/b.json": `{"kind":"policy","metadata":{"name":"b"}}`,
+		"policies/README.md":  "# policies",
+		"secrets/c.yaml":      "kind: secret\nmetadata:\n  name: c\n",
+	})
+
+	if _, err := repo.CreateTag("v1.0.0", first, nil); err != nil {
+		t.Fatalf("CreateTag() error = %v", err)
+	}
+
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("dev"), first)); err != nil {
+		t.Fatalf("SetReference() error = %v", err)
+	}
+
+	second := commitFiles(t, repo, map[string]string{
+		"policies/d.yml": "kind: policy\nmetadata:\n  name: d\n",
+	})
+
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{bare}}); err != nil {
+		t.Fatalf("CreateRemote() error = %v", err)
+	}
+
+	err = repo.Push(&git.PushOptions{
+		RefSpecs: []config.RefSpec{"refs/heads/*:refs/heads/*", "refs/tags/*:refs/tags/*"},
+	})
+	if err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+
+	return bare, first, second
+}
+
+func TestApplyFromGitOptions_clone(t *testing.T) {
+	bare, first, second := newBareRepository(t)
+
+	tests := []struct {
+		name       string
+		options    *ApplyFromGitOptions
+		wantCommit plumbing.Hash
+		want       []string
+		wantErr    bool
+	}{
+		{
+			name:       "default branch",
+			options:    &ApplyFromGitOptions{path: "policies", Depth: 1},
+			wantCommit: second,
+			want:       []string{"policies/a.yaml", "policies/d.yml", "policies/sub/b.json"},
+		},
+		{
+			name:       "branch",
+			options:    &ApplyFromGitOptions{path: "policies", Branch: "dev", Depth: 1},
+			wantCommit: first,
+			want:       []string{"policies/a.yaml", "policies/sub/b.json"},
+		},
+		{
+			name:       "tag",
+			options:    &ApplyFromGitOptions{path: "policies/", Tag: "v1.0.0"},
+			wantCommit: first,
+			want:       []string{"policies/a.yaml", "policies/sub/b.json"},
+		},
+		{
+			name:       "file",
+			options:    &ApplyFromGitOptions{path: "policies/a.yaml", Depth: 1},
+			wantCommit: second,
+			want:       []string{"policies/a.yaml"},
+		},
+		{
+			name:    "path outside of the repository",
+			options: &ApplyFromGitOptions{path: "../policies", Depth: 1},
+			wantErr: true,
+		},
+		{
+			name:    "missing branch",
+			options: &ApplyFromGitOptions{path: "policies", Branch: "missing", Depth: 1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.repoURL = bare
+
+			commit, manifests, err := tt.options.clone(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("clone() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if commit != tt.wantCommit.String() {
+				t.Errorf("clone() commit = %s, want %s", commit, tt.wantCommit)
+			}
+
+			var got []string
+			for _, m := range manifests {
+				got = append(got, m.path)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("clone() manifests = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
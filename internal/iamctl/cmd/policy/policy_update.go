@@ -11,10 +11,11 @@ import (
 	v1 "github.com/marmotedu/api/apiserver/v1"
 	"github.com/marmotedu/component-base/pkg/json"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
-	"github.com/marmotedu/marmotedu-sdk-go/marmotedu/service/iam"
+	restclient "github.com/marmotedu/marmotedu-sdk-go/rest"
 	"github.com/ory/ladon"
 	"github.com/spf13/cobra"
 
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
 	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
 	"github.com/marmotedu/iam/internal/iamctl/util/templates"
 	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
@@ -22,17 +23,24 @@ import (
 
 const (
 	updateUsageStr = "update POLICY_NAME POLICY"
+
+	policiesPath = "/v1/policies"
 )
 
 // UpdateOptions is an options struct to support update subcommands.
 type UpdateOptions struct {
 	Policy *v1.Policy
 
-	iamclient iam.IamInterface
+	client restclient.Interface
 	genericclioptions.IOStreams
 }
 
 var (
+	updateLong = templates.LongDesc(`Update a authorization policy resource.
+
+The policy is read before its update, the update is tried again once if the policy is changed
+meanwhile by someone else.`)
+
 	updateExample = templates.Examples(`
 		# Update a authorization policy with new policy.
 		iamctl policy update foo "{"description":"This is a updated policy","subjects":["users:<peter|ken>","users:maria","groups:admins"],"actions":["delete","<create|update>"],"effect":"allow","resources":["resources:articles:<.*>","resources:printer"],"conditions":{"remoteIPAddress":{"type":"CIDRCondition","options":{"cidr":"192.168.0.1/16"}}}}"`)
@@ -60,7 +68,7 @@ func NewCmdUpdate(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cob
 		Aliases:               []string{},
 		Short:                 "Update a authorization policy resource",
 		TraverseChildren:      true,
-		Long:                  "Update a authorization policy resource.",
+		Long:                  updateLong,
 		Example:               updateExample,
 		Run: func(cmd *cobra.Command, args []string) {
 			cmdutil.CheckErr(o.Complete(f, cmd, args))
@@ -95,12 +103,9 @@ func (o *UpdateOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []s
 		},
 	}
 
-	o.iamclient, err = f.IAMClient()
-	if err != nil {
-		return err
-	}
+	o.client, err = f.RESTClient()
 
-	return nil
+	return err
 }
 
 // Validate makes sure there is no discrepency in command options.
@@ -110,8 +115,8 @@ func (o *UpdateOptions) Validate(cmd *cobra.Command, args []string) error {
 
 // Run executes a update subcommand using the specified options.
 func (o *UpdateOptions) Run(args []string) error {
-	ret, err := o.iamclient.APIV1().Policies().Update(context.TODO(), o.Policy, metav1.UpdateOptions{})
-	if err != nil {
+	var ret modelv1.VersionedPolicy
+	if err := cmdutil.RetryOnConflict(func() error { return o.update(&ret) }); err != nil {
 		return err
 	}
 
@@ -119,3 +124,18 @@ func (o *UpdateOptions) Run(args []string) error {
 
 	return nil
 }
+
+// update reads the policy, and updates it with the resource version read.
+func (o *UpdateOptions) update(ret *modelv1.VersionedPolicy) error {
+	var pol modelv1.VersionedPolicy
+	if err := o.client.Get().AbsPath(policiesPath, o.Policy.Name).Do(context.TODO()).Into(&pol); err != nil {
+		return err
+	}
+
+	pol.Policy.Policy = o.Policy.Policy
+
+	// the struct is sent as json, a []byte would be sent as a json array.
+	err := o.client.Put().AbsPath(policiesPath, o.Policy.Name).Body(&pol).Do(context.TODO()).Into(ret)
+
+	return cmdutil.ConflictError(err)
+}
@@ -0,0 +1,178 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+	apiclientv1 "github.com/marmotedu/marmotedu-sdk-go/marmotedu/service/iam/apiserver/v1"
+	restclient "github.com/marmotedu/marmotedu-sdk-go/rest"
+	"github.com/ory/ladon"
+
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+// fakePolicyAPI serves the policy API of iam-apiserver used by the update command.
+type fakePolicyAPI struct {
+	mu     sync.Mutex
+	policy modelv1.VersionedPolicy
+	// concurrentUpdates is the number of reads followed by an update of someone else.
+	concurrentUpdates int
+	// requests holds the requests received, as "METHOD path" followed by the resource version of a PUT request.
+	requests []string
+}
+
+func newFakePolicyAPI(t *testing.T, concurrentUpdates int) (*fakePolicyAPI, restclient.Interface) {
+	t.Helper()
+
+	api := &fakePolicyAPI{
+		policy: modelv1.VersionedPolicy{
+			Policy:          v1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "foo"}, Username: "colin"},
+			ResourceVersion: 1,
+		},
+		concurrentUpdates: concurrentUpdates,
+	}
+
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	client, err := apiclientv1.NewForConfig(&restclient.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatalf("NewForConfig() error = %v", err)
+	}
+
+	return api, client.RESTClient()
+}
+
+func (a *fakePolicyAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	request := r.Method + " " + r.URL.Path
+	status := http.StatusOK
+
+	var reply interface{}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/policies/foo":
+		reply = a.policy
+
+		if a.concurrentUpdates > 0 {
+			a.concurrentUpdates--
+			a.policy.ResourceVersion++
+		}
+	case r.Method == http.MethodPut && r.URL.Path == "/v1/policies/foo":
+		var pol modelv1.VersionedPolicy
+		if err := json.NewDecoder(r.Body).Decode(&pol); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		request += " resourceVersion=" + strconv.FormatUint(pol.ResourceVersion, 10)
+		if pol.ResourceVersion != a.policy.ResourceVersion {
+			status = http.StatusConflict
+			reply = map[string]interface{}{"code": 100103, "message": "Resource was changed since it was read"}
+
+			break
+		}
+
+		a.policy.Policy.Policy = pol.Policy.Policy
+		a.policy.ResourceVersion++
+		reply = a.policy
+	default:
+		http.NotFound(w, r)
+
+		return
+	}
+
+	a.requests = append(a.requests, request)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(reply)
+}
+
+func TestUpdateOptions_Run(t *testing.T) {
+	tests := []struct {
+		name              string
+		concurrentUpdates int
+		wantRequests      []string
+		wantErr           bool
+	}{
+		{
+			name:         "unchanged",
+			wantRequests: []string{"GET /v1/policies/foo", "PUT /v1/policies/foo resourceVersion=1"},
+		},
+		{
+			name:              "changed once",
+			concurrentUpdates: 1,
+			wantRequests: []string{
+				"GET /v1/policies/foo", "PUT /v1/policies/foo resourceVersion=1",
+				"GET /v1/policies/foo", "PUT /v1/policies/foo resourceVersion=2",
+			},
+		},
+		{
+			name:              "changed twice",
+			concurrentUpdates: 2,
+			wantRequests: []string{
+				"GET /v1/policies/foo", "PUT /v1/policies/foo resourceVersion=1",
+				"GET /v1/policies/foo", "PUT /v1/policies/foo resourceVersion=2",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, client := newFakePolicyAPI(t, tt.concurrentUpdates)
+			ioStreams, _, out, _ := genericclioptions.NewTestIOStreams()
+
+			o := NewUpdateOptions(ioStreams)
+			o.client = client
+			o.Policy = &v1.Policy{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Policy: v1.AuthzPolicy{
+					DefaultPolicy: ladon.DefaultPolicy{Description: "updated", Effect: ladon.AllowAccess},
+				},
+			}
+
+			err := o.Run(nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(api.requests, tt.wantRequests) {
+				t.Errorf("requests = %q, want %q", api.requests, tt.wantRequests)
+			}
+
+			if tt.wantErr {
+				if !errors.Is(err, cmdutil.ErrConflict) {
+					t.Errorf("Run() error = %v, want a conflict", err)
+				}
+
+				return
+			}
+
+			if api.policy.Policy.Policy.Description != "updated" {
+				t.Errorf("policy description = %q, want %q", api.policy.Policy.Policy.Description, "updated")
+			}
+
+			if want := "policy/foo updated\n"; out.String() != want {
+				t.Errorf("output = %q, want %q", out.String(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package policygroup provides functions to manage policy groups on iam platform.
+package policygroup
+
+import (
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+const policyGroupPath = "/v1/policy-groups"
+
+var policyGroupLong = templates.LongDesc(`
+	Policy group management commands.
+
+	A policy group manages related authorization policies as a unit. The policies of a group
+	are only enforced once the group is applied, deleting a group deletes its policies.`)
+
+// NewCmdPolicyGroup returns new initialized instance of 'policy-group' sub command.
+func NewCmdPolicyGroup(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "policy-group SUBCOMMAND",
+		DisableFlagsInUseLine: true,
+		Short:                 "Manage policy groups on iam platform",
+		Long:                  policyGroupLong,
+		Run:                   cmdutil.DefaultSubCommandRun(ioStreams.ErrOut),
+	}
+
+	cmd.AddCommand(NewCmdCreate(f, ioStreams))
+	cmd.AddCommand(NewCmdList(f, ioStreams))
+	cmd.AddCommand(NewCmdUpdate(f, ioStreams))
+	cmd.AddCommand(NewCmdDelete(f, ioStreams))
+	cmd.AddCommand(NewCmdApply(f, ioStreams))
+
+	return cmd
+}
+
+// setHeader set headers for policy group commands.
+func setHeader(table *tablewriter.Table) *tablewriter.Table {
+	table.SetHeader([]string{"Name", "Policies", "Applied", "Description", "Created"})
+	table.SetHeaderColor(tablewriter.Colors{tablewriter.FgGreenColor},
+		tablewriter.Colors{tablewriter.FgRedColor},
+		tablewriter.Colors{tablewriter.FgCyanColor},
+		tablewriter.Colors{tablewriter.FgMagentaColor},
+		tablewriter.Colors{tablewriter.FgGreenColor})
+
+	return table
+}
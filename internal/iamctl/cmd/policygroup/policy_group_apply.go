@@ -0,0 +1,107 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package policygroup
+
+import (
+	"context"
+	"fmt"
+
+	restclient "github.com/marmotedu/marmotedu-sdk-go/rest"
+	"github.com/spf13/cobra"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+const (
+	applyUsageStr = "apply POLICY_GROUP_NAME"
+)
+
+// ApplyOptions is an options struct to support apply subcommands.
+type ApplyOptions struct {
+	Name string
+
+	client *restclient.RESTClient
+	genericclioptions.IOStreams
+}
+
+var (
+	applyLong = templates.LongDesc(`Apply a policy group.
+
+All the policies of the group are enforced at once. The group can not be applied if some
+of its policies do not exist.`)
+
+	applyExample = templates.Examples(`
+		# Enforce the policies of the policy group foo
+		iamctl policy-group apply foo`)
+
+	applyUsageErrStr = fmt.Sprintf(
+		"expected '%s'.\nPOLICY_GROUP_NAME is required arguments for the apply command",
+		applyUsageStr,
+	)
+)
+
+// NewApplyOptions returns an initialized ApplyOptions instance.
+func NewApplyOptions(ioStreams genericclioptions.IOStreams) *ApplyOptions {
+	return &ApplyOptions{
+		IOStreams: ioStreams,
+	}
+}
+
+// NewCmdApply returns new initialized instance of apply sub command.
+func NewCmdApply(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewApplyOptions(ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   applyUsageStr,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{},
+		Short:                 "Enforce the policies of a policy group at once",
+		TraverseChildren:      true,
+		Long:                  applyLong,
+		Example:               applyExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate(cmd, args))
+			cmdutil.CheckErr(o.Run())
+		},
+		SuggestFor: []string{},
+	}
+
+	return cmd
+}
+
+// Complete completes all the required options.
+func (o *ApplyOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return cmdutil.UsageErrorf(cmd, applyUsageErrStr)
+	}
+
+	o.Name = args[0]
+
+	var err error
+	o.client, err = f.RESTClient()
+
+	return err
+}
+
+// Validate makes sure there is no discrepency in command options.
+func (o *ApplyOptions) Validate(cmd *cobra.Command, args []string) error {
+	return nil
+}
+
+// Run executes an apply subcommand using the specified options.
+func (o *ApplyOptions) Run() error {
+	var group v1.PolicyGroup
+	if err := o.client.Post().AbsPath(policyGroupPath, o.Name, "apply").Do(context.TODO()).Into(&group); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(o.Out, "policy-group/%s applied, %d policies enforced\n", group.Name, len(group.PolicyIDs))
+
+	return nil
+}
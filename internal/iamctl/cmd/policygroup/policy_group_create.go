@@ -0,0 +1,129 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package policygroup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	restclient "github.com/marmotedu/marmotedu-sdk-go/rest"
+	"github.com/spf13/cobra"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+const (
+	createUsageStr = "create POLICY_GROUP_NAME --policies=POLICY_NAME[,POLICY_NAME...]"
+)
+
+// CreateOptions is an options struct to support create subcommands.
+type CreateOptions struct {
+	Description string
+	Policies    []string
+
+	PolicyGroup *v1.PolicyGroup
+
+	client *restclient.RESTClient
+	genericclioptions.IOStreams
+}
+
+var (
+	createLong = templates.LongDesc(`Create a policy group resource.
+
+The policies of the group are not enforced until the group is applied with 'iamctl policy-group apply'.`)
+
+	createExample = templates.Examples(`
+		# Create a policy group foo of the policies bar and baz
+		iamctl policy-group create foo --policies=bar,baz --description="policies of the articles service"`)
+
+	createUsageErrStr = fmt.Sprintf(
+		"expected '%s'.\nPOLICY_GROUP_NAME is required arguments for the create command",
+		createUsageStr,
+	)
+)
+
+// NewCreateOptions returns an initialized CreateOptions instance.
+func NewCreateOptions(ioStreams genericclioptions.IOStreams) *CreateOptions {
+	return &CreateOptions{
+		IOStreams: ioStreams,
+	}
+}
+
+// NewCmdCreate returns new initialized instance of create sub command.
+func NewCmdCreate(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewCreateOptions(ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   createUsageStr,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{},
+		Short:                 "Create a policy group resource",
+		TraverseChildren:      true,
+		Long:                  createLong,
+		Example:               createExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate(cmd, args))
+			cmdutil.CheckErr(o.Run(args))
+		},
+		SuggestFor: []string{},
+	}
+
+	cmd.Flags().StringVar(&o.Description, "description", o.Description, "The description of the policy group.")
+	cmd.Flags().StringSliceVar(&o.Policies, "policies", o.Policies, "The names of the policies of the policy group.")
+
+	return cmd
+}
+
+// Complete completes all the required options.
+func (o *CreateOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return cmdutil.UsageErrorf(cmd, createUsageErrStr)
+	}
+
+	o.PolicyGroup = &v1.PolicyGroup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: args[0],
+		},
+		Description: o.Description,
+		PolicyIDs:   o.Policies,
+	}
+
+	var err error
+	o.client, err = f.RESTClient()
+
+	return err
+}
+
+// Validate makes sure there is no discrepency in command options.
+func (o *CreateOptions) Validate(cmd *cobra.Command, args []string) error {
+	if errs := o.PolicyGroup.Validate(); len(errs) != 0 {
+		return errs.ToAggregate()
+	}
+
+	return nil
+}
+
+// Run executes a create subcommand using the specified options.
+func (o *CreateOptions) Run(args []string) error {
+	body, err := json.Marshal(o.PolicyGroup)
+	if err != nil {
+		return err
+	}
+
+	var ret v1.PolicyGroup
+	if err := o.client.Post().AbsPath(policyGroupPath).Body(body).Do(context.TODO()).Into(&ret); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(o.Out, "policy-group/%s created\n", ret.Name)
+
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package policygroup
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	restclient "github.com/marmotedu/marmotedu-sdk-go/rest"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+const (
+	defaultLimit = 1000
+)
+
+// ListOptions is an options struct to support list subcommands.
+type ListOptions struct {
+	Offset int64
+	Limit  int64
+
+	client *restclient.RESTClient
+	genericclioptions.IOStreams
+}
+
+var listExample = templates.Examples(`
+		# Display all policy group resources
+		iamctl policy-group list
+
+		# Display all policy group resources with offset and limit
+		iamctl policy-group list --offset=0 --limit=10`)
+
+// NewListOptions returns an initialized ListOptions instance.
+func NewListOptions(ioStreams genericclioptions.IOStreams) *ListOptions {
+	return &ListOptions{
+		Offset:    0,
+		Limit:     defaultLimit,
+		IOStreams: ioStreams,
+	}
+}
+
+// NewCmdList returns new initialized instance of list sub command.
+func NewCmdList(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewListOptions(ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   "list",
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{},
+		Short:                 "Display all policy group resources",
+		TraverseChildren:      true,
+		Long:                  "Display all policy group resources.",
+		Example:               listExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate(cmd, args))
+			cmdutil.CheckErr(o.Run(args))
+		},
+		SuggestFor: []string{},
+	}
+
+	cmd.Flags().Int64VarP(&o.Offset, "offset", "o", o.Offset, "Specify the offset of the first row to be returned.")
+	cmd.Flags().Int64VarP(&o.Limit, "limit", "l", o.Limit, "Specify the amount records to be returned.")
+
+	return cmd
+}
+
+// Complete completes all the required options.
+func (o *ListOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	var err error
+	o.client, err = f.RESTClient()
+
+	return err
+}
+
+// Validate makes sure there is no discrepency in command options.
+func (o *ListOptions) Validate(cmd *cobra.Command, args []string) error {
+	return nil
+}
+
+// Run executes a list subcommand using the specified options.
+func (o *ListOptions) Run(args []string) error {
+	var groups v1.PolicyGroupList
+	if err := o.client.Get().
+		AbsPath(policyGroupPath).
+		Param("offset", strconv.FormatInt(o.Offset, 10)).
+		Param("limit", strconv.FormatInt(o.Limit, 10)).
+		Do(context.TODO()).
+		Into(&groups); err != nil {
+		return err
+	}
+
+	data := make([][]string, 0, len(groups.Items))
+	table := tablewriter.NewWriter(o.Out)
+
+	for _, group := range groups.Items {
+		data = append(data, []string{
+			group.Name,
+			strings.Join(group.PolicyIDs, ","),
+			strconv.FormatBool(group.Applied),
+			group.Description,
+			group.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	table = setHeader(table)
+	table = cmdutil.TableWriterDefaultConfig(table)
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
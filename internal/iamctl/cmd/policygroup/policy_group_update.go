@@ -0,0 +1,145 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package policygroup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/marmotedu/component-base/pkg/validation/field"
+	restclient "github.com/marmotedu/marmotedu-sdk-go/rest"
+	"github.com/spf13/cobra"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+const (
+	updateUsageStr = "update POLICY_GROUP_NAME [--policies=POLICY_NAME[,POLICY_NAME...]] [--description=DESCRIPTION]"
+)
+
+// UpdateOptions is an options struct to support update subcommands.
+type UpdateOptions struct {
+	Description string
+	Policies    []string
+
+	Name string
+
+	client *restclient.RESTClient
+	genericclioptions.IOStreams
+}
+
+var (
+	updateLong = templates.LongDesc(`Update a policy group resource.
+
+The policies removed from the group belong to no group anymore, they are enforced right away.`)
+
+	updateExample = templates.Examples(`
+		# Replace the policies of the policy group foo
+		iamctl policy-group update foo --policies=bar
+
+		# Update the description of the policy group foo
+		iamctl policy-group update foo --description="policies of the articles service v2"`)
+
+	updateUsageErrStr = fmt.Sprintf(
+		"expected '%s'.\nPOLICY_GROUP_NAME is required arguments for the update command",
+		updateUsageStr,
+	)
+)
+
+// NewUpdateOptions returns an initialized UpdateOptions instance.
+func NewUpdateOptions(ioStreams genericclioptions.IOStreams) *UpdateOptions {
+	return &UpdateOptions{
+		IOStreams: ioStreams,
+	}
+}
+
+// NewCmdUpdate returns new initialized instance of update sub command.
+func NewCmdUpdate(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewUpdateOptions(ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   updateUsageStr,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{},
+		Short:                 "Update a policy group resource",
+		TraverseChildren:      true,
+		Long:                  updateLong,
+		Example:               updateExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate(cmd, args))
+			cmdutil.CheckErr(o.Run(cmd, args))
+		},
+		SuggestFor: []string{},
+	}
+
+	cmd.Flags().StringVar(&o.Description, "description", o.Description, "The description of the policy group.")
+	cmd.Flags().StringSliceVar(&o.Policies, "policies", o.Policies, "The names of the policies of the policy group.")
+
+	return cmd
+}
+
+// Complete completes all the required options.
+func (o *UpdateOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return cmdutil.UsageErrorf(cmd, updateUsageErrStr)
+	}
+
+	o.Name = args[0]
+
+	var err error
+	o.client, err = f.RESTClient()
+
+	return err
+}
+
+// Validate makes sure there is no discrepency in command options.
+func (o *UpdateOptions) Validate(cmd *cobra.Command, args []string) error {
+	if !cmd.Flags().Changed("description") && !cmd.Flags().Changed("policies") {
+		return cmdutil.UsageErrorf(cmd, "at least one of --description and --policies must be specified")
+	}
+
+	if errs := v1.ValidatePolicyIDs(o.Policies, field.NewPath("policies")); len(errs) != 0 {
+		return errs.ToAggregate()
+	}
+
+	return nil
+}
+
+// Run executes an update subcommand using the specified options.
+func (o *UpdateOptions) Run(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Changed("description") {
+		if err := o.put(o.client.Put().AbsPath(policyGroupPath, o.Name), &v1.PolicyGroup{
+			Description: o.Description,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if cmd.Flags().Changed("policies") {
+		if err := o.put(o.client.Put().AbsPath(policyGroupPath, o.Name, "policies"), &v1.PolicyGroup{
+			PolicyIDs: o.Policies,
+		}); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(o.Out, "policy-group/%s updated\n", o.Name)
+
+	return nil
+}
+
+func (o *UpdateOptions) put(req *restclient.Request, group *v1.PolicyGroup) error {
+	body, err := json.Marshal(group)
+	if err != nil {
+		return err
+	}
+
+	return req.Body(body).Do(context.TODO()).Into(&v1.PolicyGroup{})
+}
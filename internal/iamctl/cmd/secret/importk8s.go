@@ -0,0 +1,292 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AlekSi/pointer"
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	apiclientv1 "github.com/marmotedu/marmotedu-sdk-go/marmotedu/service/iam/apiserver/v1"
+	"github.com/spf13/cobra"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+const (
+	importK8sUsageStr = "import --from-k8s-secret=NAMESPACE/NAME"
+
+	// ConflictStrategySkip keeps the existing iam secret of the same name.
+	ConflictStrategySkip = "skip"
+	// ConflictStrategyOverwrite replaces the existing iam secret of the same name.
+	ConflictStrategyOverwrite = "overwrite"
+)
+
+// ImportK8sOptions is an options struct to support import subcommands.
+type ImportK8sOptions struct {
+	FromK8sSecret    string
+	Kubeconfig       string
+	KeyFilter        string
+	ConflictStrategy string
+	Expires          int64
+	DryRun           bool
+
+	namespace string
+	name      string
+	keyFilter *regexp.Regexp
+
+	KubeClient kubernetes.Interface
+	Client     apiclientv1.APIV1Interface
+
+	genericclioptions.IOStreams
+}
+
+var (
+	importK8sLong = templates.LongDesc(`Import the keys of a Kubernetes Secret as iam secrets.
+
+Each key of the data of the Kubernetes Secret creates an iam secret named after the key,
+whose secretKey is the value of the key. The secretID is generated by iam-apiserver.`)
+
+	importK8sExample = templates.Examples(`
+		# Import all the keys of the Kubernetes Secret default/jwt-keys
+		iamctl secret import --from-k8s-secret=default/jwt-keys
+
+		# Only import the keys ending with -signing-key, replacing the existing iam secrets
+		iamctl secret import --from-k8s-secret=default/jwt-keys --key-filter='-signing-key$' --conflict-strategy=overwrite
+
+		# Display the iam secrets which would be imported without creating them
+		iamctl secret import --from-k8s-secret=default/jwt-keys --dry-run`)
+)
+
+// NewImportK8sOptions returns an initialized ImportK8sOptions instance.
+func NewImportK8sOptions(ioStreams genericclioptions.IOStreams) *ImportK8sOptions {
+	return &ImportK8sOptions{
+		ConflictStrategy: ConflictStrategySkip,
+		Expires:          time.Now().Add(144 * time.Hour).Unix(),
+		IOStreams:        ioStreams,
+	}
+}
+
+// NewCmdSecretImportK8s returns new initialized instance of import sub command.
+func NewCmdSecretImportK8s(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewImportK8sOptions(ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   importK8sUsageStr,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{},
+		Short:                 "Import the keys of a Kubernetes Secret as iam secrets",
+		TraverseChildren:      true,
+		Long:                  importK8sLong,
+		Example:               importK8sExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate(cmd, args))
+			cmdutil.CheckErr(o.Run(args))
+		},
+		SuggestFor: []string{},
+	}
+
+	cmd.Flags().StringVar(&o.FromK8sSecret, "from-k8s-secret", o.FromK8sSecret,
+		"The Kubernetes Secret to import, in the NAMESPACE/NAME format.")
+	cmd.Flags().StringVar(&o.Kubeconfig, "kubeconfig", o.Kubeconfig, ""+
+		"Path to the kubeconfig file used to read the Kubernetes Secret. The KUBECONFIG environment "+
+		"variable, then ~/.kube/config and the in-cluster config are used if not set.")
+	cmd.Flags().StringVar(&o.KeyFilter, "key-filter", o.KeyFilter,
+		"Only import the keys of the Kubernetes Secret matching this regular expression.")
+	cmd.Flags().StringVar(&o.ConflictStrategy, "conflict-strategy", o.ConflictStrategy, ""+
+		"What to do when an iam secret of the same name exists, skip keeps it and overwrite replaces it.")
+	cmd.Flags().Int64Var(&o.Expires, "expires", o.Expires, "The expire time of the imported secrets.")
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", o.DryRun,
+		"If true, only print the iam secrets which would be imported, without creating them.")
+
+	return cmd
+}
+
+// Complete completes all the required options.
+func (o *ImportK8sOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	var err error
+
+	if o.KeyFilter != "" {
+		if o.keyFilter, err = regexp.Compile(o.KeyFilter); err != nil {
+			return cmdutil.UsageErrorf(cmd, "invalid --key-filter: %v", err)
+		}
+	}
+
+	if o.KubeClient == nil {
+		loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+		loadingRules.ExplicitPath = o.Kubeconfig
+
+		kubeConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			loadingRules,
+			&clientcmd.ConfigOverrides{},
+		).ClientConfig()
+		if err != nil {
+			return err
+		}
+
+		if o.KubeClient, err = kubernetes.NewForConfig(kubeConfig); err != nil {
+			return err
+		}
+	}
+
+	if o.Client == nil {
+		client, err := f.IAMClient()
+		if err != nil {
+			return err
+		}
+
+		o.Client = client.APIV1()
+	}
+
+	return nil
+}
+
+// Validate makes sure there is no discrepency in command options.
+func (o *ImportK8sOptions) Validate(cmd *cobra.Command, args []string) error {
+	parts := strings.Split(o.FromK8sSecret, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return cmdutil.UsageErrorf(cmd, "--from-k8s-secret must be in the NAMESPACE/NAME format, got %q",
+			o.FromK8sSecret)
+	}
+
+	o.namespace, o.name = parts[0], parts[1]
+
+	switch o.ConflictStrategy {
+	case ConflictStrategySkip, ConflictStrategyOverwrite:
+	default:
+		return cmdutil.UsageErrorf(cmd, "--conflict-strategy must be %s or %s, got %s",
+			ConflictStrategySkip, ConflictStrategyOverwrite, o.ConflictStrategy)
+	}
+
+	return nil
+}
+
+// Run executes an import subcommand using the specified options.
+func (o *ImportK8sOptions) Run(args []string) error {
+	ctx := context.TODO()
+
+	k8sSecret, err := o.KubeClient.CoreV1().Secrets(o.namespace).Get(ctx, o.name, k8smetav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	existing, err := o.existingSecrets(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(k8sSecret.Data))
+	for key := range k8sSecret.Data {
+		if o.keyFilter == nil || o.keyFilter.MatchString(key) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	dryRun := ""
+	if o.DryRun {
+		dryRun = " (dry run)"
+	}
+
+	var failed int
+
+	for _, key := range keys {
+		secret := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: secretName(key),
+			},
+			SecretKey:   string(k8sSecret.Data[key]),
+			Expires:     o.Expires,
+			Description: fmt.Sprintf("imported from kubernetes secret %s key %s", o.FromK8sSecret, key),
+		}
+
+		if errs := secret.Validate(); len(errs) != 0 {
+			fmt.Fprintf(o.ErrOut, "key %s: %v\n", key, errs.ToAggregate())
+			failed++
+
+			continue
+		}
+
+		action := "created"
+
+		if existing[secret.Name] {
+			if o.ConflictStrategy == ConflictStrategySkip {
+				fmt.Fprintf(o.Out, "secret/%s skipped, already exists%s\n", secret.Name, dryRun)
+
+				continue
+			}
+
+			action = "overwritten"
+		}
+
+		if !o.DryRun {
+			if err := o.importSecret(ctx, secret, existing[secret.Name]); err != nil {
+				fmt.Fprintf(o.ErrOut, "key %s: %v\n", key, err)
+				failed++
+
+				continue
+			}
+		}
+
+		fmt.Fprintf(o.Out, "secret/%s %s%s\n", secret.Name, action, dryRun)
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("failed to import %d of %d keys of kubernetes secret %s", failed, len(keys), o.FromK8sSecret)
+	}
+
+	return nil
+}
+
+// existingSecrets returns the names of the iam secrets of the user.
+func (o *ImportK8sOptions) existingSecrets(ctx context.Context) (map[string]bool, error) {
+	secrets, err := o.Client.Secrets().List(ctx, metav1.ListOptions{
+		Offset: pointer.ToInt64(0),
+		Limit:  pointer.ToInt64(-1),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(secrets.Items))
+	for _, secret := range secrets.Items {
+		names[secret.Name] = true
+	}
+
+	return names, nil
+}
+
+// importSecret creates the secret, the existing secret of the same name is deleted first if exists is true:
+// the secretKey of an existing secret can not be updated.
+func (o *ImportK8sOptions) importSecret(ctx context.Context, secret *v1.Secret, exists bool) error {
+	if exists {
+		if err := o.Client.Secrets().Delete(ctx, secret.Name, metav1.DeleteOptions{}); err != nil {
+			return err
+		}
+	}
+
+	_, err := o.Client.Secrets().Create(ctx, secret, metav1.CreateOptions{})
+
+	return err
+}
+
+// secretName returns the name of the iam secret of a key of a Kubernetes Secret, the keys may contain
+// underscores and dots which are not allowed in a name.
+func secretName(key string) string {
+	return strings.NewReplacer("_", "-", ".", "-").Replace(strings.ToLower(key))
+}
@@ -0,0 +1,266 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	apiclientv1 "github.com/marmotedu/marmotedu-sdk-go/marmotedu/service/iam/apiserver/v1"
+	restclient "github.com/marmotedu/marmotedu-sdk-go/rest"
+	corev1 "k8s.io/api/core/v1"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+// fakeSecretAPI serves the secret API of iam-apiserver used by the import command.
+type fakeSecretAPI struct {
+	mu sync.Mutex
+	// secrets holds the secret keys of the secrets, by name
+	secrets map[string]string
+	// requests holds the requests received, as "METHOD path"
+	requests []string
+}
+
+func newFakeSecretAPI(t *testing.T, existing ...string) (*fakeSecretAPI, apiclientv1.APIV1Interface) {
+	t.Helper()
+
+	api := &fakeSecretAPI{secrets: make(map[string]string)}
+	for _, name := range existing {
+		api.secrets[name] = "existing-key"
+	}
+
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	client, err := apiclientv1.NewForConfig(&restclient.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatalf("NewForConfig() error = %v", err)
+	}
+
+	return api, client
+}
+
+func (a *fakeSecretAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.requests = append(a.requests, r.Method+" "+r.URL.Path)
+
+	var reply interface{}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/secrets":
+		list := &v1.SecretList{}
+		for name, key := range a.secrets {
+			secret := &v1.Secret{SecretKey: key}
+			secret.Name = name
+			list.Items = append(list.Items, secret)
+		}
+
+		list.TotalCount = int64(len(list.Items))
+		reply = list
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/secrets":
+		var secret v1.Secret
+		if err := json.NewDecoder(r.Body).Decode(&secret); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		a.secrets[secret.Name] = secret.SecretKey
+		reply = secret
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/secrets/"):
+		name := strings.TrimPrefix(r.URL.Path, "/v1/secrets/")
+		if _, ok := a.secrets[name]; !ok {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		secret := &v1.Secret{SecretID: "id-" + name, SecretKey: a.secrets[name]}
+		secret.Name = name
+		reply = secret
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/v1/secrets/"):
+		delete(a.secrets, strings.TrimPrefix(r.URL.Path, "/v1/secrets/"))
+	default:
+		http.NotFound(w, r)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(reply)
+}
+
+func TestImportK8sOptions_Run(t *testing.T) {
+	k8sSecret := &corev1.Secret{
+		ObjectMeta: k8smetav1.ObjectMeta{Namespace: "default", Name: "jwt-keys"},
+		Data: map[string][]byte{
+			"admin_signing.key": []byte("admin-key"),
+			"colin-signing-key": []byte("colin-key"),
+			"tls.crt":           []byte("certificate"),
+		},
+	}
+
+	tests := []struct {
+		name             string
+		existing         []string
+		keyFilter        string
+		conflictStrategy string
+		dryRun           bool
+		wantSecrets      map[string]string
+		wantRequests     []string
+	}{
+		{
+			name:             "all keys",
+			conflictStrategy: ConflictStrategySkip,
+			wantSecrets: map[string]string{
+				"admin-signing-key": "admin-key",
+				"colin-signing-key": "colin-key",
+				"tls-crt":           "certificate",
+			},
+			wantRequests: []string{"GET /v1/secrets", "POST /v1/secrets", "POST /v1/secrets", "POST /v1/secrets"},
+		},
+		{
+			name:             "key filter",
+			keyFilter:        "signing",
+			conflictStrategy: ConflictStrategySkip,
+			wantSecrets: map[string]string{
+				"admin-signing-key": "admin-key",
+				"colin-signing-key": "colin-key",
+			},
+			wantRequests: []string{"GET /v1/secrets", "POST /v1/secrets", "POST /v1/secrets"},
+		},
+		{
+			name:             "skip existing",
+			existing:         []string{"colin-signing-key"},
+			keyFilter:        "signing",
+			conflictStrategy: ConflictStrategySkip,
+			wantSecrets: map[string]string{
+				"admin-signing-key": "admin-key",
+				"colin-signing-key": "existing-key",
+			},
+			wantRequests: []string{"GET /v1/secrets", "POST /v1/secrets"},
+		},
+		{
+			name:             "overwrite existing",
+			existing:         []string{"colin-signing-key"},
+			keyFilter:        "^colin",
+			conflictStrategy: ConflictStrategyOverwrite,
+			wantSecrets: map[string]string{
+				"colin-signing-key": "colin-key",
+			},
+			wantRequests: []string{"GET /v1/secrets", "DELETE /v1/secrets/colin-signing-key", "POST /v1/secrets"},
+		},
+		{
+			name:             "dry run",
+			existing:         []string{"colin-signing-key"},
+			conflictStrategy: ConflictStrategyOverwrite,
+			dryRun:           true,
+			wantSecrets: map[string]string{
+				"colin-signing-key": "existing-key",
+			},
+			wantRequests: []string{"GET /v1/secrets"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, client := newFakeSecretAPI(t, tt.existing...)
+			kubeClient := fake.NewSimpleClientset(k8sSecret)
+			ioStreams, _, out, _ := genericclioptions.NewTestIOStreams()
+
+			o := NewImportK8sOptions(ioStreams)
+			o.namespace, o.name = "default", "jwt-keys"
+			o.ConflictStrategy = tt.conflictStrategy
+			o.DryRun = tt.dryRun
+			o.KubeClient = kubeClient
+			o.Client = client
+
+			if tt.keyFilter != "" {
+				o.keyFilter = regexp.MustCompile(tt.keyFilter)
+			}
+
+			if err := o.Run(nil); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(api.secrets, tt.wantSecrets) {
+				t.Errorf("secrets = %v, want %v\noutput:\n%s", api.secrets, tt.wantSecrets, out)
+			}
+
+			if !reflect.DeepEqual(api.requests, tt.wantRequests) {
+				t.Errorf("requests = %q, want %q", api.requests, tt.wantRequests)
+			}
+
+			// the kubernetes secret is only read.
+			for _, action := range kubeClient.Actions() {
+				if action.GetVerb() != "get" || action.GetResource().Resource != "secrets" {
+					t.Errorf("unexpected kubernetes action %s %s", action.GetVerb(), action.GetResource().Resource)
+				}
+			}
+		})
+	}
+}
+
+func TestImportK8sOptions_Run_K8sError(t *testing.T) {
+	api, client := newFakeSecretAPI(t)
+	kubeClient := fake.NewSimpleClientset()
+	errForbidden := errors.New(`secrets "jwt-keys" is forbidden`)
+	kubeClient.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errForbidden
+	})
+
+	o := NewImportK8sOptions(genericclioptions.NewTestIOStreamsDiscard())
+	o.namespace, o.name = "default", "jwt-keys"
+	o.KubeClient = kubeClient
+	o.Client = client
+
+	if err := o.Run(nil); !errors.Is(err, errForbidden) {
+		t.Errorf("Run() error = %v, want %v", err, errForbidden)
+	}
+
+	if len(api.requests) != 0 {
+		t.Errorf("requests = %q, want none", api.requests)
+	}
+}
+
+func TestImportK8sOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name             string
+		fromK8sSecret    string
+		conflictStrategy string
+		wantErr          bool
+	}{
+		{name: "valid", fromK8sSecret: "default/jwt-keys", conflictStrategy: ConflictStrategySkip},
+		{name: "no namespace", fromK8sSecret: "jwt-keys", conflictStrategy: ConflictStrategySkip, wantErr: true},
+		{name: "empty name", fromK8sSecret: "default/", conflictStrategy: ConflictStrategySkip, wantErr: true},
+		{name: "unknown strategy", fromK8sSecret: "default/jwt-keys", conflictStrategy: "merge", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewImportK8sOptions(genericclioptions.NewTestIOStreamsDiscard())
+			o.FromK8sSecret = tt.fromK8sSecret
+			o.ConflictStrategy = tt.conflictStrategy
+
+			cmd := NewCmdSecretImportK8s(nil, genericclioptions.NewTestIOStreamsDiscard())
+			if err := o.Validate(cmd, nil); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -34,6 +34,9 @@ func NewCmdSecret(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cob
 	cmd.AddCommand(NewCmdList(f, ioStreams))
 	cmd.AddCommand(NewCmdDelete(f, ioStreams))
 	cmd.AddCommand(NewCmdUpdate(f, ioStreams))
+	cmd.AddCommand(NewCmdShare(f, ioStreams))
+	cmd.AddCommand(NewCmdSecretImportK8s(f, ioStreams))
+	cmd.AddCommand(NewCmdSecretVerify(f, ioStreams))
 
 	return cmd
 }
@@ -101,14 +101,11 @@ func (o *CreateOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []s
 		Description: o.Description,
 	}
 
-	clientConfig, err := f.ToRESTConfig()
-	if err != nil {
-		return err
-	}
-	o.Client, err = apiclientv1.NewForConfig(clientConfig)
+	client, err := f.IAMClient()
 	if err != nil {
 		return err
 	}
+	o.Client = client.APIV1()
 
 	return nil
 }
@@ -5,9 +5,18 @@
 package secret
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/ghodss/yaml"
+	v1 "github.com/marmotedu/api/apiserver/v1"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
 	"github.com/marmotedu/marmotedu-sdk-go/marmotedu/service/iam"
 	"github.com/olekukonko/tablewriter"
@@ -20,13 +29,23 @@ import (
 
 const (
 	defaltLimit = 1000
+
+	// FormatTable displays the secrets in a table.
+	FormatTable = "table"
+	// FormatEnv prints the secrets as shell environment variable declarations.
+	FormatEnv = "env"
 )
 
 // ListOptions is an options struct to support list subcommands.
 type ListOptions struct {
-	Offset int64
-	Limit  int64
-
+	Offset   int64
+	Limit    int64
+	Format   string
+	Prefix   string
+	FromFile string
+
+	// keys are the secret keys read from FromFile, by secret name.
+	keys      map[string]string
 	iamclient iam.IamInterface
 	genericclioptions.IOStreams
 }
@@ -36,7 +55,22 @@ var listExample = templates.Examples(`
 		iamctl secret list
 
 		# List secrets with limit and offset 
-		iamctl secret list --offset=0 --limit=5`)
+		iamctl secret list --offset=0 --limit=5
+
+		# Export the secrets into the environment of the shell, the secret keys are prompted
+		eval "$(iamctl secret list --format=env --prefix=APP_)"
+
+		# Export the secrets with the secret keys of keys.yaml, a map of secret names to secret keys
+		iamctl secret list --format=env --from-file=keys.yaml > secrets.env`)
+
+// envNameSeparators matches the characters which can not be part of an environment variable name.
+var envNameSeparators = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// envNameWordBoundaries matches the boundaries of the words of a camelCase name.
+var envNameWordBoundaries = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+
+// envPrefix matches the valid prefixes of the environment variable names.
+var envPrefix = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
 
 // NewListOptions returns an initialized ListOptions instance.
 func NewListOptions(ioStreams genericclioptions.IOStreams) *ListOptions {
@@ -44,6 +78,7 @@ func NewListOptions(ioStreams genericclioptions.IOStreams) *ListOptions {
 		IOStreams: ioStreams,
 		Offset:    0,
 		Limit:     defaltLimit,
+		Format:    FormatTable,
 	}
 }
 
@@ -69,6 +104,13 @@ func NewCmdList(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra
 
 	cmd.Flags().Int64VarP(&o.Offset, "offset", "o", o.Offset, "Specify the offset of the first row to be returned.")
 	cmd.Flags().Int64VarP(&o.Limit, "limit", "l", o.Limit, "Specify the amount records to be returned.")
+	cmd.Flags().StringVar(&o.Format, "format", o.Format, ""+
+		"Output format, one of table or env. env prints sourceable export declarations of "+
+		"<NAME>_ID and <NAME>_KEY for each secret.")
+	cmd.Flags().StringVar(&o.Prefix, "prefix", o.Prefix, "Prefix of the environment variable names, with --format=env.")
+	cmd.Flags().StringVar(&o.FromFile, "from-file", o.FromFile, ""+
+		"YAML or JSON file mapping the secret names to their secret keys, with --format=env. "+
+		"The secret keys missing from the file are prompted.")
 
 	return cmd
 }
@@ -86,6 +128,30 @@ func (o *ListOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []str
 
 // Validate makes sure there is no discrepency in command options.
 func (o *ListOptions) Validate(cmd *cobra.Command, args []string) error {
+	switch o.Format {
+	case FormatTable:
+		if o.Prefix != "" || o.FromFile != "" {
+			return cmdutil.UsageErrorf(cmd, "--prefix and --from-file require --format=%s", FormatEnv)
+		}
+	case FormatEnv:
+		if o.Prefix != "" && !envPrefix.MatchString(o.Prefix) {
+			return cmdutil.UsageErrorf(cmd, "--prefix %q is not a valid environment variable name", o.Prefix)
+		}
+
+		if o.FromFile != "" {
+			data, err := ioutil.ReadFile(o.FromFile)
+			if err != nil {
+				return err
+			}
+
+			if err := yaml.Unmarshal(data, &o.keys); err != nil {
+				return fmt.Errorf("parse %s: %w", o.FromFile, err)
+			}
+		}
+	default:
+		return cmdutil.UsageErrorf(cmd, "--format must be %s or %s, got %s", FormatTable, FormatEnv, o.Format)
+	}
+
 	return nil
 }
 
@@ -99,6 +165,10 @@ func (o *ListOptions) Run(args []string) error {
 		return err
 	}
 
+	if o.Format == FormatEnv {
+		return o.printEnv(secrets.Items)
+	}
+
 	data := make([][]string, 0, len(secrets.Items))
 	table := tablewriter.NewWriter(o.Out)
 
@@ -119,3 +189,58 @@ func (o *ListOptions) Run(args []string) error {
 
 	return nil
 }
+
+// printEnv prints the export declarations of the secret IDs and secret keys of the secrets. The secret
+// keys are read from FromFile, or else prompted, on ErrOut for Out to stay sourceable.
+func (o *ListOptions) printEnv(secrets []*v1.Secret) error {
+	names := make(map[string]string, len(secrets))
+	for _, secret := range secrets {
+		name := o.Prefix + envName(secret.Name)
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("secrets %s and %s have the same environment variable name %s", other, secret.Name, name)
+		}
+
+		names[name] = secret.Name
+	}
+
+	in := bufio.NewReader(o.In)
+
+	for _, secret := range secrets {
+		key, ok := o.keys[secret.Name]
+		if !ok {
+			fmt.Fprintf(o.ErrOut, "Enter the secret key of secret %s: ", secret.Name)
+
+			line, err := in.ReadString('\n')
+			if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+				return fmt.Errorf("read the secret key of secret %s: %w", secret.Name, err)
+			}
+
+			key = strings.TrimRight(line, "\r\n")
+		}
+
+		name := o.Prefix + envName(secret.Name)
+		fmt.Fprintf(o.Out, "export %s_ID=%s\n", name, shellQuote(secret.SecretID))
+		fmt.Fprintf(o.Out, "export %s_KEY=%s\n", name, shellQuote(key))
+	}
+
+	return nil
+}
+
+// envName returns the environment variable name of a secret name, e.g. MY_APP for my-app or myApp.
+func envName(name string) string {
+	name = envNameWordBoundaries.ReplaceAllString(name, "${1}_${2}")
+	name = strings.Trim(envNameSeparators.ReplaceAllString(name, "_"), "_")
+	name = strings.ToUpper(name)
+
+	// the names can not start with a digit.
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+
+	return name
+}
+
+// shellQuote quotes s in single quotes, for the shells not to expand it.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
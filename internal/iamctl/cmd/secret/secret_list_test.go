@@ -0,0 +1,156 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	v1 "github.com/marmotedu/api/apiserver/v1"
+
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+func newSecret(name, secretID string) *v1.Secret {
+	secret := &v1.Secret{SecretID: secretID}
+	secret.Name = name
+
+	return secret
+}
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "secret", want: "SECRET"},
+		{name: "my-app", want: "MY_APP"},
+		{name: "my_app", want: "MY_APP"},
+		{name: "my.app.key", want: "MY_APP_KEY"},
+		{name: "myApp", want: "MY_APP"},
+		{name: "MyAppV2", want: "MY_APP_V2"},
+		{name: "app--prod..1", want: "APP_PROD_1"},
+		{name: "-leading", want: "LEADING"},
+		{name: "2fa", want: "_2FA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := envName(tt.name); got != tt.want {
+				t.Errorf("envName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListOptions_printEnv(t *testing.T) {
+	secrets := []*v1.Secret{
+		newSecret("my-app", "id1"),
+		newSecret("billingService", "id2"),
+		newSecret("2fa.seed", "id3"),
+	}
+
+	tests := []struct {
+		name       string
+		options    *ListOptions
+		in         string
+		want       string
+		wantPrompt string
+		wantErr    bool
+	}{
+		{
+			name:    "keys from file",
+			options: &ListOptions{keys: map[string]string{"my-app": "k1", "billingService": "k2", "2fa.seed": "k3"}},
+			want: "export MY_APP_ID='id1'\nexport MY_APP_KEY='k1'\n" +
+				"export BILLING_SERVICE_ID='id2'\nexport BILLING_SERVICE_KEY='k2'\n" +
+				"export _2FA_SEED_ID='id3'\nexport _2FA_SEED_KEY='k3'\n",
+		},
+		{
+			name:    "prefix",
+			options: &ListOptions{Prefix: "APP_", keys: map[string]string{"my-app": "k1", "billingService": "k2", "2fa.seed": "k3"}},
+			want: "export APP_MY_APP_ID='id1'\nexport APP_MY_APP_KEY='k1'\n" +
+				"export APP_BILLING_SERVICE_ID='id2'\nexport APP_BILLING_SERVICE_KEY='k2'\n" +
+				"export APP__2FA_SEED_ID='id3'\nexport APP__2FA_SEED_KEY='k3'\n",
+		},
+		{
+			name:    "prompted keys",
+			options: &ListOptions{keys: map[string]string{"billingService": "k2"}},
+			in:      "k1\r\nk'3",
+			want: "export MY_APP_ID='id1'\nexport MY_APP_KEY='k1'\n" +
+				"export BILLING_SERVICE_ID='id2'\nexport BILLING_SERVICE_KEY='k2'\n" +
+				"export _2FA_SEED_ID='id3'\nexport _2FA_SEED_KEY='k'\\''3'\n",
+			wantPrompt: "Enter the secret key of secret my-app: Enter the secret key of secret 2fa.seed: ",
+		},
+		{
+			name:    "no more input",
+			options: &ListOptions{},
+			in:      "k1\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ioStreams, in, out, errOut := genericclioptions.NewTestIOStreams()
+			in.WriteString(tt.in)
+			tt.options.IOStreams = ioStreams
+
+			err := tt.options.printEnv(secrets)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("printEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if out.String() != tt.want {
+				t.Errorf("printEnv() output = %q, want %q", out.String(), tt.want)
+			}
+
+			if errOut.String() != tt.wantPrompt {
+				t.Errorf("printEnv() prompts = %q, want %q", errOut.String(), tt.wantPrompt)
+			}
+		})
+	}
+}
+
+func TestListOptions_printEnvSourceable(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+
+	ioStreams, _, out, _ := genericclioptions.NewTestIOStreams()
+	o := &ListOptions{
+		Prefix:    "APP_",
+		keys:      map[string]string{"my-app": `it's $HOME "quoted"`},
+		IOStreams: ioStreams,
+	}
+
+	if err := o.printEnv([]*v1.Secret{newSecret("my-app", "id1")}); err != nil {
+		t.Fatalf("printEnv() error = %v", err)
+	}
+
+	cmd := exec.Command(bash, "-c", out.String()+`printf '%s\n%s' "$APP_MY_APP_ID" "$APP_MY_APP_KEY"`)
+
+	got, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("bash error = %v", err)
+	}
+
+	if want := "id1\nit's $HOME \"quoted\""; string(got) != want {
+		t.Errorf("sourced variables = %q, want %q", got, want)
+	}
+}
+
+func TestListOptions_printEnvConflict(t *testing.T) {
+	ioStreams, _, _, _ := genericclioptions.NewTestIOStreams()
+	o := &ListOptions{IOStreams: ioStreams}
+
+	err := o.printEnv([]*v1.Secret{newSecret("my-app", "id1"), newSecret("my_app", "id2")})
+	if err == nil || !strings.Contains(err.Error(), "MY_APP") {
+		t.Errorf("printEnv() error = %v, want the conflicting name MY_APP", err)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	restclient "github.com/marmotedu/marmotedu-sdk-go/rest"
+	"github.com/spf13/cobra"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+const (
+	shareUsageStr = "share SECRET_NAME --with-user=USERNAME"
+)
+
+// ShareOptions is an options struct to support share subcommands.
+type ShareOptions struct {
+	WithUser string
+	Expires  time.Duration
+
+	Name string
+
+	client *restclient.RESTClient
+
+	genericclioptions.IOStreams
+}
+
+var (
+	shareLong = templates.LongDesc(`Share a secret with another user temporarily.
+
+The user can sign JWT tokens with the secretID and secretKey of the shared secret by setting
+the 'sub' claim to its username, until the share expires. The shared secret is read-only,
+it can not be updated or deleted by the user.`)
+
+	shareExample = templates.Examples(`
+		# Share secret foo with user colin for 1 hour
+		iamctl secret share foo --with-user=colin
+
+		# Share secret foo with user colin for 24 hours
+		iamctl secret share foo --with-user=colin --expires=24h
+
+		# Sign a token with the shared secret as user colin
+		iamctl jwt sign SECRET_ID SECRET_KEY --claim sub=colin`)
+
+	shareUsageErrStr = fmt.Sprintf(
+		"expected '%s'.\nSECRET_NAME is required arguments for the share command",
+		shareUsageStr,
+	)
+)
+
+// NewShareOptions returns an initialized ShareOptions instance.
+func NewShareOptions(ioStreams genericclioptions.IOStreams) *ShareOptions {
+	return &ShareOptions{
+		Expires:   time.Hour,
+		IOStreams: ioStreams,
+	}
+}
+
+// NewCmdShare returns new initialized instance of share sub command.
+func NewCmdShare(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewShareOptions(ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   shareUsageStr,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{},
+		Short:                 "Share a secret with another user temporarily",
+		TraverseChildren:      true,
+		Long:                  shareLong,
+		Example:               shareExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate(cmd, args))
+			cmdutil.CheckErr(o.Run(args))
+		},
+		SuggestFor: []string{},
+	}
+
+	cmd.Flags().StringVar(&o.WithUser, "with-user", o.WithUser, "The user to share the secret with.")
+	cmd.Flags().DurationVar(&o.Expires, "expires", o.Expires, "How long the share is valid for.")
+
+	return cmd
+}
+
+// Complete completes all the required options.
+func (o *ShareOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return cmdutil.UsageErrorf(cmd, shareUsageErrStr)
+	}
+
+	o.Name = args[0]
+
+	var err error
+	o.client, err = f.RESTClient()
+
+	return err
+}
+
+// Validate makes sure there is no discrepency in command options.
+func (o *ShareOptions) Validate(cmd *cobra.Command, args []string) error {
+	if o.WithUser == "" {
+		return cmdutil.UsageErrorf(cmd, "--with-user must be specified")
+	}
+
+	if o.Expires <= 0 {
+		return cmdutil.UsageErrorf(cmd, "--expires must be greater than 0, got %s", o.Expires)
+	}
+
+	return nil
+}
+
+// Run executes a share subcommand using the specified options.
+func (o *ShareOptions) Run(args []string) error {
+	body, err := json.Marshal(&v1.SecretShare{
+		TargetUsername: o.WithUser,
+		ExpiresAt:      time.Now().Add(o.Expires),
+	})
+	if err != nil {
+		return err
+	}
+
+	var share v1.SecretShare
+	if err := o.client.Post().
+		AbsPath("/v1/secrets", o.Name, "share").
+		Body(body).
+		Do(context.TODO()).
+		Into(&share); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(o.Out, "secret/%s shared with %s until %s\n",
+		o.Name, share.TargetUsername, share.ExpiresAt.Format(time.RFC3339))
+
+	return nil
+}
@@ -10,9 +10,10 @@ import (
 
 	v1 "github.com/marmotedu/api/apiserver/v1"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
-	"github.com/marmotedu/marmotedu-sdk-go/marmotedu/service/iam"
+	restclient "github.com/marmotedu/marmotedu-sdk-go/rest"
 	"github.com/spf13/cobra"
 
+	modelv1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
 	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
 	"github.com/marmotedu/iam/internal/iamctl/util/templates"
 	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
@@ -29,11 +30,16 @@ type UpdateOptions struct {
 
 	Secret *v1.Secret
 
-	iamclient iam.IamInterface
+	client restclient.Interface
 	genericclioptions.IOStreams
 }
 
 var (
+	updateLong = templates.LongDesc(`Update a secret resource.
+
+The secret is read before its update, the update is tried again once if the secret is changed
+meanwhile by someone else.`)
+
 	updateExample = templates.Examples(`
 		# Update a secret resource
 		iamctl secret update foo --expires=4h --description="new description"`)
@@ -61,7 +67,7 @@ func NewCmdUpdate(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cob
 		Aliases:               []string{},
 		Short:                 "Update a secret resource",
 		TraverseChildren:      true,
-		Long:                  "Update a secret resource.",
+		Long:                  updateLong,
 		Example:               updateExample,
 		Run: func(cmd *cobra.Command, args []string) {
 			cmdutil.CheckErr(o.Complete(f, cmd, args))
@@ -92,12 +98,9 @@ func (o *UpdateOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []s
 		Expires:     o.Expires,
 	}
 
-	o.iamclient, err = f.IAMClient()
-	if err != nil {
-		return err
-	}
+	o.client, err = f.RESTClient()
 
-	return nil
+	return err
 }
 
 // Validate makes sure there is no discrepency in command options.
@@ -107,12 +110,28 @@ func (o *UpdateOptions) Validate(cmd *cobra.Command, args []string) error {
 
 // Run executes a update subcommand using the specified options.
 func (o *UpdateOptions) Run(args []string) error {
-	secret, err := o.iamclient.APIV1().Secrets().Update(context.TODO(), o.Secret, metav1.UpdateOptions{})
-	if err != nil {
+	var ret modelv1.VersionedSecret
+	if err := cmdutil.RetryOnConflict(func() error { return o.update(&ret) }); err != nil {
 		return err
 	}
 
-	fmt.Fprintf(o.Out, "secret/%s updated\n", secret.Name)
+	fmt.Fprintf(o.Out, "secret/%s updated\n", ret.Name)
 
 	return nil
 }
+
+// update reads the secret, and updates it with the resource version read.
+func (o *UpdateOptions) update(ret *modelv1.VersionedSecret) error {
+	var secret modelv1.VersionedSecret
+	if err := o.client.Get().AbsPath("/v1/secrets", o.Secret.Name).Do(context.TODO()).Into(&secret); err != nil {
+		return err
+	}
+
+	secret.Description = o.Secret.Description
+	secret.Expires = o.Secret.Expires
+
+	// the struct is sent as json, a []byte would be sent as a json array.
+	err := o.client.Put().AbsPath("/v1/secrets", o.Secret.Name).Body(&secret).Do(context.TODO()).Into(ret)
+
+	return cmdutil.ConflictError(err)
+}
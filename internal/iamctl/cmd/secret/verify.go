@@ -0,0 +1,255 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go/v4"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	apiclientv1 "github.com/marmotedu/marmotedu-sdk-go/marmotedu/service/iam/apiserver/v1"
+	"github.com/moby/term"
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/internal/pkg/middleware/auth"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+const (
+	verifyUsageStr = "verify SECRET_NAME"
+
+	// verifyRequest is the trivial authorization request sent to check the token, its decision does not matter.
+	verifyRequest = `{"subject":"iamctl","action":"verify","resource":"secret"}`
+)
+
+// VerifyOptions is an options struct to support verify subcommands.
+type VerifyOptions struct {
+	Key         string
+	AuthzServer string
+	Timeout     time.Duration
+
+	Name string
+
+	Client apiclientv1.APIV1Interface
+	// Sign returns a jwt token signed with the secretKey of the secret secretID.
+	Sign func(secretID, secretKey string) (string, error)
+	// Authorize sends an authorization request authenticated with the token to iam-authz-server
+	// and returns the http status code of the response.
+	Authorize func(ctx context.Context, token string) (int, error)
+
+	genericclioptions.IOStreams
+}
+
+var (
+	verifyLong = templates.LongDesc(`Verify that a secret key is the key of a secret.
+
+A jwt token is signed with the secret key and sent to iam-authz-server, which authenticates
+it with the secret key it knows. OK is printed if the token is accepted and INVALID KEY if
+it is rejected. The secret key is prompted for if --key is not set.`)
+
+	verifyExample = templates.Examples(`
+		# Verify the secret key of secret foo, the key is prompted for
+		iamctl secret verify foo
+
+		# Verify the secret key of secret foo against iam-authz-server listening on another address
+		iamctl secret verify foo --key=iBdEdFNBLN1nR3fV --authz-server=https://127.0.0.1:9443`)
+
+	verifyUsageErrStr = fmt.Sprintf(
+		"expected '%s'.\nSECRET_NAME is required arguments for the verify command",
+		verifyUsageStr,
+	)
+)
+
+// NewVerifyOptions returns an initialized VerifyOptions instance.
+func NewVerifyOptions(ioStreams genericclioptions.IOStreams) *VerifyOptions {
+	return &VerifyOptions{
+		AuthzServer: "http://127.0.0.1:9090",
+		Timeout:     10 * time.Second,
+		Sign:        signToken,
+		IOStreams:   ioStreams,
+	}
+}
+
+// NewCmdSecretVerify returns new initialized instance of verify sub command.
+func NewCmdSecretVerify(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewVerifyOptions(ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   verifyUsageStr,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{},
+		Short:                 "Verify that a secret key is the key of a secret",
+		TraverseChildren:      true,
+		Long:                  verifyLong,
+		Example:               verifyExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate(cmd, args))
+			cmdutil.CheckErr(o.Run(args))
+		},
+		SuggestFor: []string{},
+	}
+
+	cmd.Flags().StringVar(&o.Key, "key", o.Key, "The secret key to verify, it is prompted for if not set.")
+	cmd.Flags().StringVar(&o.AuthzServer, "authz-server", o.AuthzServer, "The address of iam-authz-server.")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", o.Timeout, "The timeout of the request to iam-authz-server.")
+
+	return cmd
+}
+
+// Complete completes all the required options.
+func (o *VerifyOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return cmdutil.UsageErrorf(cmd, verifyUsageErrStr)
+	}
+
+	o.Name = args[0]
+
+	if o.Key == "" {
+		key, err := o.promptKey()
+		if err != nil {
+			return err
+		}
+
+		o.Key = key
+	}
+
+	if o.Authorize == nil {
+		o.Authorize = o.authorize
+	}
+
+	if o.Client != nil {
+		return nil
+	}
+
+	client, err := f.IAMClient()
+	if err != nil {
+		return err
+	}
+
+	o.Client = client.APIV1()
+
+	return nil
+}
+
+// Validate makes sure there is no discrepency in command options.
+func (o *VerifyOptions) Validate(cmd *cobra.Command, args []string) error {
+	if o.Key == "" {
+		return cmdutil.UsageErrorf(cmd, "the secret key must not be empty")
+	}
+
+	if o.AuthzServer == "" {
+		return cmdutil.UsageErrorf(cmd, "--authz-server must not be empty")
+	}
+
+	return nil
+}
+
+// Run executes a verify subcommand using the specified options.
+func (o *VerifyOptions) Run(args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), o.Timeout)
+	defer cancel()
+
+	secret, err := o.Client.Secrets().Get(ctx, o.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	token, err := o.Sign(secret.SecretID, o.Key)
+	if err != nil {
+		return err
+	}
+
+	status, err := o.Authorize(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	switch status {
+	case http.StatusOK:
+		fmt.Fprintln(o.Out, "OK")
+
+		return nil
+	case http.StatusUnauthorized:
+		fmt.Fprintln(o.Out, "INVALID KEY")
+
+		return cmdutil.ErrExit
+	default:
+		return fmt.Errorf("iam-authz-server replied with unexpected status %d", status)
+	}
+}
+
+// promptKey reads the secret key from the input, without echoing it if the input is a terminal.
+func (o *VerifyOptions) promptKey() (string, error) {
+	fmt.Fprint(o.Out, "Secret key: ")
+
+	if fd, isTerminal := term.GetFdInfo(o.In); isTerminal {
+		state, err := term.SaveState(fd)
+		if err != nil {
+			return "", err
+		}
+
+		if err := term.DisableEcho(fd, state); err != nil {
+			return "", err
+		}
+
+		defer func() {
+			_ = term.RestoreTerminal(fd, state)
+
+			fmt.Fprintln(o.Out)
+		}()
+	}
+
+	key, err := bufio.NewReader(o.In).ReadString('\n')
+	if err != nil && key == "" {
+		return "", fmt.Errorf("read secret key failed: %w", err)
+	}
+
+	return strings.TrimSpace(key), nil
+}
+
+// authorize sends the verify request to the authorization api of iam-authz-server.
+func (o *VerifyOptions) authorize(ctx context.Context, token string) (int, error) {
+	url := strings.TrimSuffix(o.AuthzServer, "/") + "/v1/authz"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBufferString(verifyRequest))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+// signToken signs a token accepted by iam-authz-server with the HMAC algorithm it verifies.
+func signToken(secretID, secretKey string) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": now.Add(time.Minute).Unix(),
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"aud": auth.AuthzAudience,
+		"iss": "iamctl",
+	})
+	token.Header["kid"] = secretID
+
+	return token.SignedString([]byte(secretKey))
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go/v4"
+
+	"github.com/marmotedu/iam/internal/pkg/middleware/auth"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+func TestVerifyOptions_Run(t *testing.T) {
+	tests := []struct {
+		name       string
+		secret     string
+		status     int
+		signErr    error
+		wantOut    string
+		wantErr    bool
+		wantSigned bool
+	}{
+		{name: "valid key", secret: "foo", status: http.StatusOK, wantOut: "OK\n", wantSigned: true},
+		{
+			name: "invalid key", secret: "foo", status: http.StatusUnauthorized,
+			wantOut: "INVALID KEY\n", wantErr: true, wantSigned: true,
+		},
+		{
+			name: "unexpected status", secret: "foo", status: http.StatusInternalServerError,
+			wantErr: true, wantSigned: true,
+		},
+		{name: "sign failed", secret: "foo", signErr: errors.New("sign failed"), wantErr: true},
+		{name: "unknown secret", secret: "bar", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newFakeSecretAPI(t, "foo")
+			ioStreams, _, out, _ := genericclioptions.NewTestIOStreams()
+
+			var signedID, signedKey, authorizedToken string
+
+			o := NewVerifyOptions(ioStreams)
+			o.Name = tt.secret
+			o.Key = "new-key"
+			o.Client = client
+			o.Sign = func(secretID, secretKey string) (string, error) {
+				signedID, signedKey = secretID, secretKey
+
+				return "token", tt.signErr
+			}
+			o.Authorize = func(ctx context.Context, token string) (int, error) {
+				authorizedToken = token
+
+				return tt.status, nil
+			}
+
+			if err := o.Run(nil); (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := out.String(); got != tt.wantOut {
+				t.Errorf("Run() output = %q, want %q", got, tt.wantOut)
+			}
+
+			if tt.wantSigned && (signedID != "id-foo" || signedKey != "new-key" || authorizedToken != "token") {
+				t.Errorf("signed %q with %q and authorized %q, want id-foo, new-key and token",
+					signedID, signedKey, authorizedToken)
+			}
+		})
+	}
+}
+
+func TestVerifyOptions_PromptKey(t *testing.T) {
+	ioStreams, in, out, _ := genericclioptions.NewTestIOStreams()
+	in.WriteString("  new-key \n")
+
+	key, err := NewVerifyOptions(ioStreams).promptKey()
+	if err != nil || key != "new-key" {
+		t.Errorf("promptKey() = %q, %v, want new-key", key, err)
+	}
+
+	if got := out.String(); got != "Secret key: " {
+		t.Errorf("promptKey() output = %q, want the prompt", got)
+	}
+}
+
+func TestSignToken(t *testing.T) {
+	token, err := signToken("id-foo", "new-key")
+	if err != nil {
+		t.Fatalf("signToken() error = %v", err)
+	}
+
+	// the token is verified like iam-authz-server does.
+	parsed, err := jwt.ParseWithClaims(token, &jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			t.Errorf("signing method = %v, want HMAC", token.Header["alg"])
+		}
+
+		if kid := token.Header["kid"]; kid != "id-foo" {
+			t.Errorf("kid = %v, want id-foo", kid)
+		}
+
+		return []byte("new-key"), nil
+	}, jwt.WithAudience(auth.AuthzAudience), jwt.WithLeeway(time.Second))
+	if err != nil || !parsed.Valid {
+		t.Errorf("ParseWithClaims() error = %v, want a valid token", err)
+	}
+}
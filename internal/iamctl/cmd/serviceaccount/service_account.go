@@ -0,0 +1,52 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package serviceaccount provides functions to manage service accounts on iam platform.
+package serviceaccount
+
+import (
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+const serviceAccountPath = "/v1/service-accounts"
+
+var serviceAccountLong = templates.LongDesc(`
+	Service account management commands.
+
+	A service account authenticates an internal service calling iam on behalf of itself. The
+	service exchanges the api key of its account for a short-lived service token at
+	POST /v1/token/service.`)
+
+// NewCmdServiceAccount returns new initialized instance of 'service-account' sub command.
+func NewCmdServiceAccount(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "service-account SUBCOMMAND",
+		DisableFlagsInUseLine: true,
+		Short:                 "Manage service accounts on iam platform",
+		Long:                  serviceAccountLong,
+		Run:                   cmdutil.DefaultSubCommandRun(ioStreams.ErrOut),
+	}
+
+	cmd.AddCommand(NewCmdCreate(f, ioStreams))
+	cmd.AddCommand(NewCmdList(f, ioStreams))
+	cmd.AddCommand(NewCmdDelete(f, ioStreams))
+
+	return cmd
+}
+
+// setHeader set headers for service account commands.
+func setHeader(table *tablewriter.Table) *tablewriter.Table {
+	table.SetHeader([]string{"Name", "Scope", "Description", "Created"})
+	table.SetHeaderColor(tablewriter.Colors{tablewriter.FgGreenColor},
+		tablewriter.Colors{tablewriter.FgRedColor},
+		tablewriter.Colors{tablewriter.FgMagentaColor},
+		tablewriter.Colors{tablewriter.FgGreenColor})
+
+	return table
+}
@@ -0,0 +1,130 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package serviceaccount
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	restclient "github.com/marmotedu/marmotedu-sdk-go/rest"
+	"github.com/spf13/cobra"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+const (
+	createUsageStr = "create SERVICE_ID --scope=SCOPE"
+)
+
+// CreateOptions is an options struct to support create subcommands.
+type CreateOptions struct {
+	Scope       string
+	Description string
+
+	ServiceAccount *v1.ServiceAccount
+
+	client *restclient.RESTClient
+	genericclioptions.IOStreams
+}
+
+var (
+	createLong = templates.LongDesc(`Create a service account resource.
+
+The api key of the service account is only printed once, iam keeps its hash only.`)
+
+	createExample = templates.Examples(`
+		# Create a service account billing whose service tokens have the scope authz
+		iamctl service-account create billing --scope=authz --description="billing service"`)
+
+	createUsageErrStr = fmt.Sprintf(
+		"expected '%s'.\nSERVICE_ID is required arguments for the create command",
+		createUsageStr,
+	)
+)
+
+// NewCreateOptions returns an initialized CreateOptions instance.
+func NewCreateOptions(ioStreams genericclioptions.IOStreams) *CreateOptions {
+	return &CreateOptions{
+		IOStreams: ioStreams,
+	}
+}
+
+// NewCmdCreate returns new initialized instance of create sub command.
+func NewCmdCreate(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewCreateOptions(ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   createUsageStr,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{},
+		Short:                 "Create a service account resource",
+		TraverseChildren:      true,
+		Long:                  createLong,
+		Example:               createExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate(cmd, args))
+			cmdutil.CheckErr(o.Run(args))
+		},
+		SuggestFor: []string{},
+	}
+
+	cmd.Flags().StringVar(&o.Scope, "scope", o.Scope, "The scope of the service tokens issued to the service account.")
+	cmd.Flags().StringVar(&o.Description, "description", o.Description, "The description of the service account.")
+
+	return cmd
+}
+
+// Complete completes all the required options.
+func (o *CreateOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return cmdutil.UsageErrorf(cmd, createUsageErrStr)
+	}
+
+	o.ServiceAccount = &v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: args[0],
+		},
+		Scope:       o.Scope,
+		Description: o.Description,
+	}
+
+	var err error
+	o.client, err = f.RESTClient()
+
+	return err
+}
+
+// Validate makes sure there is no discrepency in command options.
+func (o *CreateOptions) Validate(cmd *cobra.Command, args []string) error {
+	if errs := o.ServiceAccount.Validate(); len(errs) != 0 {
+		return errs.ToAggregate()
+	}
+
+	return nil
+}
+
+// Run executes a create subcommand using the specified options.
+func (o *CreateOptions) Run(args []string) error {
+	body, err := json.Marshal(o.ServiceAccount)
+	if err != nil {
+		return err
+	}
+
+	var ret v1.ServiceAccount
+	if err := o.client.Post().AbsPath(serviceAccountPath).Body(body).Do(context.TODO()).Into(&ret); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(o.Out, "service-account/%s created\n", ret.Name)
+	fmt.Fprintf(o.Out, "api key: %s\n", ret.APIKey)
+
+	return nil
+}
@@ -17,7 +17,7 @@ import (
 var userLong = templates.LongDesc(`
 	User management commands.
 
-Administrator can use all subcommands, non-administrator only allow to use create/get/upate. When call get/update non-administrator only allow to operate their own resources, if permission not allowed, will return an 'Permission denied' error.`)
+Administrator can use all subcommands, non-administrator only allow to use create/get/upate/list-tokens. When call get/update/list-tokens non-administrator only allow to operate their own resources, if permission not allowed, will return an 'Permission denied' error.`)
 
 // NewCmdUser returns new initialized instance of 'user' sub command.
 func NewCmdUser(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
@@ -34,6 +34,7 @@ func NewCmdUser(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra
 	cmd.AddCommand(NewCmdList(f, ioStreams))
 	cmd.AddCommand(NewCmdDelete(f, ioStreams))
 	cmd.AddCommand(NewCmdUpdate(f, ioStreams))
+	cmd.AddCommand(NewCmdListTokens(f, ioStreams))
 
 	return cmd
 }
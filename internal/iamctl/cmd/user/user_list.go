@@ -6,9 +6,14 @@ package user
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	v1 "github.com/marmotedu/api/apiserver/v1"
 	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
-	"github.com/marmotedu/marmotedu-sdk-go/marmotedu/service/iam"
+	apiclientv1 "github.com/marmotedu/marmotedu-sdk-go/marmotedu/service/iam/apiserver/v1"
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 
@@ -21,12 +26,22 @@ const (
 	defaultLimit = 1000
 )
 
+// The actions on the users listed by --inactive-since.
+const (
+	ActionReport  = "report"
+	ActionDisable = "disable"
+	ActionDelete  = "delete"
+)
+
 // ListOptions is an options struct to support list subcommands.
 type ListOptions struct {
-	Offset int64
-	Limit  int64
+	Offset        int64
+	Limit         int64
+	InactiveSince string
+	Action        string
 
-	iamclient iam.IamInterface
+	inactiveSince time.Duration
+	Client        apiclientv1.APIV1Interface
 	genericclioptions.IOStreams
 }
 
@@ -35,7 +50,13 @@ var listExample = templates.Examples(`
 		iamctl user list
 
 		# List users with limit and offset
-		iamctl user list --offset=0 --limit=10`)
+		iamctl user list --offset=0 --limit=10
+
+		# List the users not logged in for 90 days
+		iamctl user list --inactive-since=90d
+
+		# Disable the users not logged in for 90 days
+		iamctl user list --inactive-since=90d --action=disable`)
 
 // NewListOptions returns an initialized ListOptions instance.
 func NewListOptions(ioStreams genericclioptions.IOStreams) *ListOptions {
@@ -43,6 +64,7 @@ func NewListOptions(ioStreams genericclioptions.IOStreams) *ListOptions {
 		IOStreams: ioStreams,
 		Offset:    0,
 		Limit:     defaultLimit,
+		Action:    ActionReport,
 	}
 }
 
@@ -68,17 +90,32 @@ func NewCmdList(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra
 
 	cmd.Flags().Int64VarP(&o.Offset, "offset", "o", o.Offset, "Specify the offset of the first row to be returned.")
 	cmd.Flags().Int64VarP(&o.Limit, "limit", "l", o.Limit, "Specify the amount records to be returned.")
+	cmd.Flags().StringVar(&o.InactiveSince, "inactive-since", o.InactiveSince,
+		"Only list the users not logged in for the duration, e.g. 90d or 720h.")
+	cmd.Flags().StringVar(&o.Action, "action", o.Action, fmt.Sprintf(
+		"The action on the users listed by --inactive-since, one of %s, %s or %s.",
+		ActionReport, ActionDisable, ActionDelete,
+	))
 
 	return cmd
 }
 
 // Complete completes all the required options.
 func (o *ListOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
-	var err error
+	if o.InactiveSince != "" {
+		var err error
+		if o.inactiveSince, err = parseInactiveSince(o.InactiveSince); err != nil {
+			return cmdutil.UsageErrorf(cmd, "invalid --inactive-since: %v", err)
+		}
+	}
 
-	o.iamclient, err = f.IAMClient()
-	if err != nil {
-		return err
+	if o.Client == nil {
+		iamclient, err := f.IAMClient()
+		if err != nil {
+			return err
+		}
+
+		o.Client = iamclient.APIV1()
 	}
 
 	return nil
@@ -86,12 +123,48 @@ func (o *ListOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []str
 
 // Validate makes sure there is no discrepency in command options.
 func (o *ListOptions) Validate(cmd *cobra.Command, args []string) error {
+	switch o.Action {
+	case ActionReport:
+	case ActionDisable, ActionDelete:
+		if o.InactiveSince == "" {
+			return cmdutil.UsageErrorf(cmd, "--action=%s requires --inactive-since", o.Action)
+		}
+	default:
+		return cmdutil.UsageErrorf(cmd, "--action must be one of %s, %s or %s", ActionReport, ActionDisable, ActionDelete)
+	}
+
 	return nil
 }
 
+// parseInactiveSince parses a duration of days, like 90d, or a duration of time.ParseDuration.
+func parseInactiveSince(s string) (time.Duration, error) {
+	var (
+		d   time.Duration
+		err error
+	)
+
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%q is not a positive duration, like 90d or 720h", s)
+	}
+
+	return d, nil
+}
+
 // Run executes a list subcommand using the specified options.
 func (o *ListOptions) Run(args []string) error {
-	users, err := o.iamclient.APIV1().Users().List(context.TODO(), metav1.ListOptions{
+	if o.InactiveSince != "" {
+		return o.runInactive(time.Now().Add(-o.inactiveSince))
+	}
+
+	users, err := o.Client.Users().List(context.TODO(), metav1.ListOptions{
 		Offset: &o.Offset,
 		Limit:  &o.Limit,
 	})
@@ -116,3 +189,61 @@ func (o *ListOptions) Run(args []string) error {
 
 	return nil
 }
+
+// runInactive runs the action on the users not logged in since lastLoginBefore.
+func (o *ListOptions) runInactive(lastLoginBefore time.Time) error {
+	var users v1.UserList
+	if err := o.Client.RESTClient().Get().
+		AbsPath(usersPath).
+		Param("last_login_before", strconv.FormatInt(lastLoginBefore.Unix(), 10)).
+		Param("offset", strconv.FormatInt(o.Offset, 10)).
+		Param("limit", strconv.FormatInt(o.Limit, 10)).
+		Do(context.TODO()).
+		Into(&users); err != nil {
+		return err
+	}
+
+	for _, user := range users.Items {
+		switch o.Action {
+		case ActionDisable:
+			// the user can not log in anymore, until re-enabled in the database.
+			user.Status = 0
+			if _, err := o.Client.Users().Update(context.TODO(), user, metav1.UpdateOptions{}); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(o.Out, "user/%s disabled\n", user.Name)
+		case ActionDelete:
+			if err := o.Client.Users().Delete(context.TODO(), user.Name, metav1.DeleteOptions{}); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(o.Out, "user/%s deleted\n", user.Name)
+		}
+	}
+
+	if o.Action != ActionReport {
+		return nil
+	}
+
+	data := make([][]string, 0, len(users.Items))
+	table := tablewriter.NewWriter(o.Out)
+
+	for _, user := range users.Items {
+		lastLogin := "never"
+		if !user.LoginedAt.IsZero() {
+			lastLogin = user.LoginedAt.Format("2006-01-02 15:04:05")
+		}
+
+		data = append(data, []string{
+			user.Name, user.Nickname, user.Email, lastLogin, user.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	table.SetHeader([]string{"Name", "Nickname", "Email", "LastLogin", "Created"})
+	table = cmdutil.TableWriterDefaultConfig(table)
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
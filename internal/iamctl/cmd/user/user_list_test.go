@@ -0,0 +1,186 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	apiclientv1 "github.com/marmotedu/marmotedu-sdk-go/marmotedu/service/iam/apiserver/v1"
+	restclient "github.com/marmotedu/marmotedu-sdk-go/rest"
+
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+// fakeUserAPI serves the user API of iam-apiserver used by the list command.
+type fakeUserAPI struct {
+	mu sync.Mutex
+	// users holds the users not logged in since last_login_before.
+	users []*v1.User
+	// lastLoginBefore is the last_login_before query of the list request.
+	lastLoginBefore string
+	// requests holds the requests received, as "METHOD path" followed by the status of a PUT request.
+	requests []string
+}
+
+func newFakeUserAPI(t *testing.T, users ...*v1.User) (*fakeUserAPI, apiclientv1.APIV1Interface) {
+	t.Helper()
+
+	api := &fakeUserAPI{users: users}
+
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	client, err := apiclientv1.NewForConfig(&restclient.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatalf("NewForConfig() error = %v", err)
+	}
+
+	return api, client
+}
+
+func (a *fakeUserAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	request := r.Method + " " + r.URL.Path
+
+	var reply interface{}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/users":
+		a.lastLoginBefore = r.URL.Query().Get("last_login_before")
+		reply = &v1.UserList{ListMeta: metav1.ListMeta{TotalCount: int64(len(a.users))}, Items: a.users}
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/v1/users/"):
+		var user v1.User
+		if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		request += " status=" + strconv.Itoa(user.Status)
+		reply = user
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/v1/users/"):
+	default:
+		http.NotFound(w, r)
+
+		return
+	}
+
+	a.requests = append(a.requests, request)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(reply)
+}
+
+func TestListOptions_runInactive(t *testing.T) {
+	lastLoginBefore := time.Now().Add(-90 * 24 * time.Hour)
+
+	newUser := func(name string, loginedAt time.Time) *v1.User {
+		return &v1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: name, CreatedAt: lastLoginBefore.AddDate(-1, 0, 0)},
+			Status:     1,
+			Nickname:   name,
+			Email:      name + "@foxmail.com",
+			LoginedAt:  loginedAt,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		action       string
+		wantRequests []string
+		wantOut      []string
+	}{
+		{
+			name:         "report",
+			action:       ActionReport,
+			wantRequests: []string{"GET /v1/users"},
+			wantOut: []string{
+				"colin", "colin@foxmail.com", "never", "alice", lastLoginBefore.AddDate(0, -1, 0).Format("2006-01-02"),
+			},
+		},
+		{
+			name:   "disable",
+			action: ActionDisable,
+			wantRequests: []string{
+				"GET /v1/users", "PUT /v1/users/colin status=0", "PUT /v1/users/alice status=0",
+			},
+			wantOut: []string{"user/colin disabled\nuser/alice disabled\n"},
+		},
+		{
+			name:         "delete",
+			action:       ActionDelete,
+			wantRequests: []string{"GET /v1/users", "DELETE /v1/users/colin", "DELETE /v1/users/alice"},
+			wantOut:      []string{"user/colin deleted\nuser/alice deleted\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, client := newFakeUserAPI(t,
+				newUser("colin", time.Time{}), newUser("alice", lastLoginBefore.AddDate(0, -1, 0)))
+			ioStreams, _, out, _ := genericclioptions.NewTestIOStreams()
+
+			o := NewListOptions(ioStreams)
+			o.Action = tt.action
+			o.Client = client
+
+			if err := o.runInactive(lastLoginBefore); err != nil {
+				t.Fatalf("runInactive() error = %v", err)
+			}
+
+			if want := strconv.FormatInt(lastLoginBefore.Unix(), 10); api.lastLoginBefore != want {
+				t.Errorf("last_login_before = %q, want %q", api.lastLoginBefore, want)
+			}
+
+			if !reflect.DeepEqual(api.requests, tt.wantRequests) {
+				t.Errorf("requests = %q, want %q", api.requests, tt.wantRequests)
+			}
+
+			for _, want := range tt.wantOut {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output = %q, want %q in it", out.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseInactiveSince(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "90d", want: 90 * 24 * time.Hour},
+		{in: "720h", want: 720 * time.Hour},
+		{in: "0d", wantErr: true},
+		{in: "-1h", wantErr: true},
+		{in: "3w", wantErr: true},
+		{in: "d", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseInactiveSince(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseInactiveSince() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("parseInactiveSince() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	restclient "github.com/marmotedu/marmotedu-sdk-go/rest"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	v1 "github.com/marmotedu/iam/internal/apiserver/model/v1"
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+const (
+	listTokensUsageStr = "list-tokens USERNAME"
+	usersPath          = "/v1/users"
+)
+
+// ListTokensOptions is an options struct to support list-tokens subcommands.
+type ListTokensOptions struct {
+	Name   string
+	Offset int64
+	Limit  int64
+
+	client *restclient.RESTClient
+	genericclioptions.IOStreams
+}
+
+var (
+	listTokensExample = templates.Examples(`
+		# List the active jwt tokens of user foo
+		iamctl user list-tokens foo
+
+		# List the active jwt tokens of user foo with limit and offset
+		iamctl user list-tokens foo --offset=0 --limit=10`)
+
+	listTokensUsageErrStr = fmt.Sprintf(
+		"expected '%s'.\nUSERNAME is required arguments for the list-tokens command",
+		listTokensUsageStr,
+	)
+)
+
+// NewListTokensOptions returns an initialized ListTokensOptions instance.
+func NewListTokensOptions(ioStreams genericclioptions.IOStreams) *ListTokensOptions {
+	return &ListTokensOptions{
+		IOStreams: ioStreams,
+		Offset:    0,
+		Limit:     defaultLimit,
+	}
+}
+
+// NewCmdListTokens returns new initialized instance of list-tokens sub command.
+func NewCmdListTokens(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewListTokensOptions(ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   listTokensUsageStr,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{},
+		Short:                 "Display the active jwt tokens of a user",
+		TraverseChildren:      true,
+		Long:                  "Display the jwt tokens of a user which have neither expired nor been revoked by a logout.",
+		Example:               listTokensExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate(cmd, args))
+			cmdutil.CheckErr(o.Run(args))
+		},
+		SuggestFor: []string{},
+	}
+
+	cmd.Flags().Int64VarP(&o.Offset, "offset", "o", o.Offset, "Specify the offset of the first row to be returned.")
+	cmd.Flags().Int64VarP(&o.Limit, "limit", "l", o.Limit, "Specify the amount records to be returned.")
+
+	return cmd
+}
+
+// Complete completes all the required options.
+func (o *ListTokensOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	var err error
+	if len(args) == 0 {
+		return cmdutil.UsageErrorf(cmd, listTokensUsageErrStr)
+	}
+
+	o.Name = args[0]
+
+	o.client, err = f.RESTClient()
+
+	return err
+}
+
+// Validate makes sure there is no discrepency in command options.
+func (o *ListTokensOptions) Validate(cmd *cobra.Command, args []string) error {
+	return nil
+}
+
+// Run executes a list-tokens subcommand using the specified options.
+func (o *ListTokensOptions) Run(args []string) error {
+	var sessions v1.UserSessionList
+	if err := o.client.Get().
+		AbsPath(usersPath, o.Name, "tokens").
+		Param("offset", strconv.FormatInt(o.Offset, 10)).
+		Param("limit", strconv.FormatInt(o.Limit, 10)).
+		Do(context.TODO()).
+		Into(&sessions); err != nil {
+		return err
+	}
+
+	data := make([][]string, 0, len(sessions.Items))
+	table := tablewriter.NewWriter(o.Out)
+
+	for _, session := range sessions.Items {
+		data = append(data, []string{
+			session.JTI,
+			session.IssuedAt.Format("2006-01-02 15:04:05"),
+			session.ExpiresAt.Format("2006-01-02 15:04:05"),
+			session.ClientIP,
+			session.UserAgent,
+		})
+	}
+
+	table.SetHeader([]string{"Jti", "Issued", "Expires", "ClientIP", "UserAgent"})
+	table = cmdutil.TableWriterDefaultConfig(table)
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"encoding/json"
+
+	"github.com/marmotedu/component-base/pkg/core"
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+// ErrConflict is returned by the updates which failed because the resource changed since it was read.
+var ErrConflict = errors.New("the resource was changed since it was read")
+
+// ConflictError returns ErrConflict if err is the error of a request answered with the code of a conflict,
+// err otherwise. The error of a request is the body of the error response.
+func ConflictError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var response core.ErrResponse
+	if json.Unmarshal([]byte(err.Error()), &response) == nil && response.Code == code.ErrResourceVersionConflict {
+		return errors.Wrap(ErrConflict, err.Error())
+	}
+
+	return err
+}
+
+// RetryOnConflict calls update, and calls it once again if it returns ErrConflict. update reads the
+// resource each time, and applies the change to the resource read.
+func RetryOnConflict(update func() error) error {
+	if err := update(); !errors.Is(err, ErrConflict) {
+		return err
+	}
+
+	return update()
+}
@@ -43,7 +43,16 @@ func (f *factoryImpl) IAMClient() (*iam.IamClient, error) {
 	if err != nil {
 		return nil, err
 	}
-	return iam.NewForConfig(clientConfig)
+	clientConfig, socket, err := unixSocketConfig(clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	client, err := iam.NewForConfig(clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	dialIAMUnixSocket(socket, client)
+	return client, nil
 }
 
 func (f *factoryImpl) RESTClient() (*restclient.RESTClient, error) {
@@ -51,6 +60,34 @@ func (f *factoryImpl) RESTClient() (*restclient.RESTClient, error) {
 	if err != nil {
 		return nil, err
 	}
+	clientConfig, socket, err := unixSocketConfig(clientConfig)
+	if err != nil {
+		return nil, err
+	}
 	setIAMDefaults(clientConfig)
-	return restclient.RESTClientFor(clientConfig)
+	client, err := restclient.RESTClientFor(clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	genericclioptions.DialUnixSocket(socket, client)
+	return client, nil
+}
+
+// unixSocketConfig returns a copy of config whose clients must dial the returned unix socket, if its
+// host is a unix:///path address, otherwise config is returned as-is with an empty socket.
+func unixSocketConfig(config *restclient.Config) (*restclient.Config, string, error) {
+	host, socket, err := genericclioptions.ResolveUnixSocketHost(config.Host)
+	if err != nil || socket == "" {
+		return config, "", err
+	}
+
+	configCopy := *config
+	configCopy.Host = host
+
+	return &configCopy, socket, nil
+}
+
+// dialIAMUnixSocket makes the clients of all the iam services of client connect to the unix socket.
+func dialIAMUnixSocket(socket string, client iam.IamInterface) {
+	genericclioptions.DialUnixSocket(socket, client.APIV1().RESTClient(), client.AuthzV1().RESTClient())
 }
@@ -22,6 +22,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
@@ -355,8 +356,13 @@ func CombineRequestErr(resp gorequest.Response, body string, errs []error) error
 }
 
 func NewForConfigOrDie() *marmotedu.Clientset {
+	host, socket, err := genericclioptions.ResolveUnixSocketHost(viper.GetString("server.address"))
+	if err != nil {
+		panic(err)
+	}
+
 	clientConfig := &restclient.Config{
-		Host:          viper.GetString("server.address"),
+		Host:          host,
 		BearerToken:   viper.GetString("user.token"),
 		Username:      viper.GetString("user.username"),
 		Password:      viper.GetString("user.password"),
@@ -367,7 +373,10 @@ func NewForConfigOrDie() *marmotedu.Clientset {
 		RetryInterval: viper.GetDuration("server.retry-interval"),
 	}
 
-	return marmotedu.NewForConfigOrDie(clientConfig)
+	clientset := marmotedu.NewForConfigOrDie(clientConfig)
+	dialIAMUnixSocket(socket, clientset.Iam())
+
+	return clientset
 }
 
 func TableWriterDefaultConfig(table *tablewriter.Table) *tablewriter.Table {
@@ -46,12 +46,19 @@ func (f *MatchVersionFlags) checkMatchingServerVersion() error {
 			return
 		}
 
+		clientConfig, socket, err := unixSocketConfig(clientConfig)
+		if err != nil {
+			f.matchesServerVersionErr = err
+			return
+		}
+
 		setIAMDefaults(clientConfig)
 		restClient, err := rest.RESTClientFor(clientConfig)
 		if err != nil {
 			f.matchesServerVersionErr = err
 			return
 		}
+		genericclioptions.DialUnixSocket(socket, restClient)
 
 		var sVer *version.Info
 		if err := restClient.Get().AbsPath("/version").Do(context.TODO()).Into(&sVer); err != nil {
@@ -0,0 +1,255 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package validate
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Supported policy schema versions.
+const (
+	SchemaVersionV1 = "v1"
+	SchemaVersionV2 = "v2"
+)
+
+//go:embed schema/*.json
+var schemaFS embed.FS
+
+// schema is the subset of JSON Schema used to describe ladon.DefaultPolicy.
+type schema struct {
+	Type                 string             `json:"type"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	Enum                 []string           `json:"enum"`
+	MinItems             int                `json:"minItems"`
+	MinLength            int                `json:"minLength"`
+}
+
+// SchemaError describes a single schema violation and where it occurs in the source file.
+type SchemaError struct {
+	Field   string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e SchemaError) Error() string {
+	return fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Column, e.Field, e.Message)
+}
+
+// loadSchema returns the embedded policy schema for the given version.
+func loadSchema(version string) (*schema, error) {
+	data, err := schemaFS.ReadFile(fmt.Sprintf("schema/policy.%s.json", version))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported schema version %q", version)
+	}
+
+	var s schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid embedded schema %s: %w", version, err)
+	}
+
+	return &s, nil
+}
+
+// ValidatePolicySchema validates a JSON or YAML policy document against the
+// policy schema of the given version. It returns the found schema errors sorted by
+// their position, or an error if the document can not be parsed at all.
+func ValidatePolicySchema(data []byte, version string) ([]SchemaError, error) {
+	s, err := loadSchema(version)
+	if err != nil {
+		return nil, err
+	}
+
+	// yaml is a superset of json, parse both of them into nodes to keep track of positions.
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse policy file failed: %w", err)
+	}
+
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return []SchemaError{{Field: "(root)", Line: 1, Column: 1, Message: "empty policy document"}}, nil
+	}
+
+	v := &schemaValidator{}
+	v.validate(s, doc.Content[0], "")
+
+	sort.SliceStable(v.errs, func(i, j int) bool {
+		if v.errs[i].Line != v.errs[j].Line {
+			return v.errs[i].Line < v.errs[j].Line
+		}
+
+		return v.errs[i].Column < v.errs[j].Column
+	})
+
+	return v.errs, nil
+}
+
+type schemaValidator struct {
+	errs []SchemaError
+}
+
+func (v *schemaValidator) report(node *yaml.Node, field, format string, args ...interface{}) {
+	if field == "" {
+		field = "(root)"
+	}
+
+	v.errs = append(v.errs, SchemaError{
+		Field:   field,
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *schemaValidator) validate(s *schema, node *yaml.Node, field string) {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+
+	if s.Type != "" {
+		if got := nodeType(node); got != s.Type {
+			v.report(node, field, "invalid type, expected %s but got %s", s.Type, got)
+
+			return
+		}
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		v.validateObject(s, node, field)
+	case yaml.SequenceNode:
+		v.validateArray(s, node, field)
+	case yaml.ScalarNode:
+		v.validateScalar(s, node, field)
+	default:
+	}
+}
+
+func (v *schemaValidator) validateObject(s *schema, node *yaml.Node, field string) {
+	present := make(map[string]bool, len(node.Content)/2)
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		present[key.Value] = true
+		child := joinField(field, key.Value)
+
+		if prop, ok := s.Properties[key.Value]; ok {
+			v.validate(prop, value, child)
+
+			continue
+		}
+
+		allowed, additional := s.additional()
+		if !allowed {
+			v.report(key, child, "additional property %q is not allowed", key.Value)
+
+			continue
+		}
+
+		if additional != nil {
+			v.validate(additional, value, child)
+		}
+	}
+
+	for _, name := range s.Required {
+		if !present[name] {
+			v.report(node, joinField(field, name), "required property %q is missing", name)
+		}
+	}
+}
+
+func (v *schemaValidator) validateArray(s *schema, node *yaml.Node, field string) {
+	if len(node.Content) < s.MinItems {
+		v.report(node, field, "must contain at least %d item(s)", s.MinItems)
+	}
+
+	if s.Items == nil {
+		return
+	}
+
+	for i, item := range node.Content {
+		v.validate(s.Items, item, fmt.Sprintf("%s[%d]", field, i))
+	}
+}
+
+func (v *schemaValidator) validateScalar(s *schema, node *yaml.Node, field string) {
+	if s.MinLength > 0 && utf8.RuneCountInString(node.Value) < s.MinLength {
+		v.report(node, field, "must be at least %d character(s) long", s.MinLength)
+	}
+
+	if len(s.Enum) == 0 {
+		return
+	}
+
+	for _, e := range s.Enum {
+		if node.Value == e {
+			return
+		}
+	}
+
+	v.report(node, field, "invalid value %q, must be one of: %s", node.Value, strings.Join(s.Enum, ", "))
+}
+
+// additional reports whether properties not listed in Properties are allowed, and
+// the schema they must conform to if there is one.
+func (s *schema) additional() (bool, *schema) {
+	if len(s.AdditionalProperties) == 0 {
+		return true, nil
+	}
+
+	var allowed bool
+	if err := json.Unmarshal(s.AdditionalProperties, &allowed); err == nil {
+		return allowed, nil
+	}
+
+	var sub schema
+	if err := json.Unmarshal(s.AdditionalProperties, &sub); err != nil {
+		return true, nil
+	}
+
+	return true, &sub
+}
+
+// nodeType maps a yaml node to the corresponding JSON Schema type name.
+func nodeType(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!str":
+			return "string"
+		case "!!bool":
+			return "boolean"
+		case "!!int", "!!float":
+			return "number"
+		case "!!null":
+			return "null"
+		}
+	default:
+	}
+
+	return "unknown"
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+
+	return parent + "." + name
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package validate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidatePolicySchema(t *testing.T) {
+	type args struct {
+		data    string
+		version string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []SchemaError
+		wantErr bool
+	}{
+		{
+			name: "valid json policy",
+			args: args{
+				data: `{"id":"foo","description":"policy","subjects":["users:maria"],"effect":"allow",` +
+					`"resources":["resources:articles:<.*>"],"actions":["delete","<create|update>"],` +
+					`"conditions":{"remoteIPAddress":{"type":"CIDRCondition","options":{"cidr":"192.168.0.1/16"}}}}`,
+				version: SchemaVersionV1,
+			},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "missing id",
+			args: args{
+				data: `subjects: ["users:maria"]
+effect: allow
+resources: ["resources:articles"]
+actions: ["delete"]
+`,
+				version: SchemaVersionV1,
+			},
+			want: []SchemaError{
+				{Field: "id", Line: 1, Column: 1, Message: `required property "id" is missing`},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid effect value",
+			args: args{
+				data: `id: foo
+subjects: ["users:maria"]
+effect: permit
+resources: ["resources:articles"]
+actions: ["delete"]
+`,
+				version: SchemaVersionV1,
+			},
+			want: []SchemaError{
+				{Field: "effect", Line: 3, Column: 9, Message: `invalid value "permit", must be one of: allow, deny`},
+			},
+			wantErr: false,
+		},
+		{
+			name: "deeply nested conditions errors",
+			args: args{
+				data: `id: foo
+subjects: ["users:maria"]
+effect: allow
+resources: ["resources:articles"]
+actions: ["delete"]
+conditions:
+  remoteIPAddress:
+    type: CIDRCondition
+    options: 192.168.0.1/16
+  owner:
+    type: OwnerCondition
+  department:
+    options:
+      value: dev
+`,
+				version: SchemaVersionV1,
+			},
+			want: []SchemaError{
+				{
+					Field:   "conditions.remoteIPAddress.options",
+					Line:    9,
+					Column:  14,
+					Message: "invalid type, expected object but got string",
+				},
+				{
+					Field:  "conditions.owner.type",
+					Line:   11,
+					Column: 11,
+					Message: `invalid value "OwnerCondition", must be one of: BooleanCondition, CIDRCondition, ` +
+						"EqualsSubjectCondition, RemoteCondition, ResourceContainsCondition, StringEqualCondition, " +
+						"StringMatchCondition, StringPairsEqualCondition",
+				},
+				{
+					Field:   "conditions.department.type",
+					Line:    13,
+					Column:  5,
+					Message: `required property "type" is missing`,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "additional property is not allowed in v2",
+			args: args{
+				data:    `{"id":"foo","subjects":["a"],"effect":"deny","resources":["b"],"actions":["c"],"owner":"d"}`,
+				version: SchemaVersionV2,
+			},
+			want: []SchemaError{
+				{Field: "owner", Line: 1, Column: 80, Message: `additional property "owner" is not allowed`},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unsupported schema version",
+			args: args{
+				data:    `{}`,
+				version: "v3",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidatePolicySchema([]byte(tt.args.data), tt.args.version)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePolicySchema() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidatePolicySchema() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -35,7 +35,10 @@ type ValidateInfo struct {
 
 var validateExample = templates.Examples(`
 		# Validate the basic environment for iamctl to run
-		iamctl validate`)
+		iamctl validate
+
+		# Validate a policy file against the policy JSON schema
+		iamctl validate policy-schema policy.json`)
 
 // NewValidateOptions returns an initialized ValidateOptions instance.
 func NewValidateOptions(ioStreams genericclioptions.IOStreams) *ValidateOptions {
@@ -64,6 +67,8 @@ func NewCmdValidate(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *c
 		SuggestFor: []string{},
 	}
 
+	cmd.AddCommand(NewCmdPolicySchema(f, ioStreams))
+
 	return cmd
 }
 
@@ -0,0 +1,136 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package validate
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/marmotedu/iam/internal/iamctl/cmd/util"
+	"github.com/marmotedu/iam/internal/iamctl/util/templates"
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+const (
+	policySchemaUsageStr = "policy-schema FILE"
+)
+
+// PolicySchemaOptions is an options struct to support 'validate policy-schema' sub command.
+type PolicySchemaOptions struct {
+	SchemaVersion string
+
+	filename string
+	data     []byte
+
+	genericclioptions.IOStreams
+}
+
+var (
+	policySchemaLong = templates.LongDesc(`
+		Validate a policy file against the JSON schema of ladon.DefaultPolicy.
+
+		The policy file can be written in JSON or YAML. No request is sent to iam-apiserver,
+		so this command can be used in CI pipelines. The command exits with code 0 if the policy is valid,
+		1 if there are schema errors and 2 if the policy file can not be read.`)
+
+	policySchemaExample = templates.Examples(`
+		# Validate a policy file against the default(v1) schema
+		iamctl validate policy-schema policy.json
+
+		# Validate a yaml policy file against the v2 schema
+		iamctl validate policy-schema --schema-version=v2 policy.yaml`)
+
+	policySchemaUsageErrStr = fmt.Sprintf(
+		"expected '%s'.\nFILE is a required argument for the policy-schema command",
+		policySchemaUsageStr,
+	)
+)
+
+// NewPolicySchemaOptions returns an initialized PolicySchemaOptions instance.
+func NewPolicySchemaOptions(ioStreams genericclioptions.IOStreams) *PolicySchemaOptions {
+	return &PolicySchemaOptions{
+		SchemaVersion: SchemaVersionV1,
+		IOStreams:     ioStreams,
+	}
+}
+
+// NewCmdPolicySchema returns new initialized instance of 'validate policy-schema' sub command.
+func NewCmdPolicySchema(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewPolicySchemaOptions(ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   policySchemaUsageStr,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{},
+		Short:                 "Validate a policy file against the policy JSON schema",
+		TraverseChildren:      true,
+		Long:                  policySchemaLong,
+		Example:               policySchemaExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate(cmd, args))
+			cmdutil.CheckErr(o.Run(args))
+		},
+		SuggestFor: []string{},
+	}
+
+	cmd.Flags().StringVar(&o.SchemaVersion, "schema-version", o.SchemaVersion, "Policy schema version, one of: v1|v2.")
+
+	return cmd
+}
+
+// Complete completes all the required options.
+func (o *PolicySchemaOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return cmdutil.UsageErrorf(cmd, policySchemaUsageErrStr)
+	}
+
+	o.filename = args[0]
+
+	data, err := os.ReadFile(o.filename)
+	if err != nil {
+		// file read errors exit with code 2 to be distinguishable from schema errors.
+		cmdutil.CheckDiffErr(fmt.Errorf("read policy file failed: %w", err))
+	}
+
+	o.data = data
+
+	return nil
+}
+
+// Validate makes sure there is no discrepency in command options.
+func (o *PolicySchemaOptions) Validate(cmd *cobra.Command, args []string) error {
+	switch o.SchemaVersion {
+	case SchemaVersionV1, SchemaVersionV2:
+		return nil
+	default:
+		return cmdutil.UsageErrorf(cmd, "--schema-version must be one of: v1|v2, got %q", o.SchemaVersion)
+	}
+}
+
+// Run executes a validate policy-schema sub command using the specified options.
+func (o *PolicySchemaOptions) Run(args []string) error {
+	errs, err := ValidatePolicySchema(o.data, o.SchemaVersion)
+	if err != nil {
+		return err
+	}
+
+	if len(errs) == 0 {
+		fmt.Fprintf(o.Out, "%s is valid against policy schema %s\n", o.filename, o.SchemaVersion)
+
+		return nil
+	}
+
+	for _, e := range errs {
+		fmt.Fprintf(o.ErrOut, "%s:%s\n", o.filename, e.Error())
+	}
+
+	fmt.Fprintf(o.ErrOut, "%s is invalid against policy schema %s, found %d error(s)\n",
+		o.filename, o.SchemaVersion, len(errs))
+
+	return cmdutil.ErrExit
+}
@@ -21,15 +21,20 @@ import (
 	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
 )
 
-// Version is a struct for version information.
+// Version is a struct for version information. ClientOnly is set when the server version is not
+// requested, with --client, or the server can not be reached.
 type Version struct {
 	ClientVersion *version.Info `json:"clientVersion,omitempty" yaml:"clientVersion,omitempty"`
 	ServerVersion *version.Info `json:"serverVersion,omitempty" yaml:"serverVersion,omitempty"`
+	ClientOnly    bool          `json:"clientOnly,omitempty"    yaml:"clientOnly,omitempty"`
 }
 
 var versionExample = templates.Examples(`
 		# Print the client and server versions for the current context
-		iamctl version`)
+		iamctl version
+
+		# Print the client and server versions as JSON, for the scripts checking the deployed versions
+		iamctl version -o json`)
 
 // Options is a struct to support version command.
 type Options struct {
@@ -111,13 +116,17 @@ func (o *Options) Run() error {
 	versionInfo.ClientVersion = &clientVersion
 
 	if !o.ClientOnly && o.client != nil {
-		// Always request fresh data from the server
+		// Always request fresh data from the server, the client version is printed alone if it fails.
 		if err := o.client.Get().AbsPath("/version").Do(context.TODO()).Into(&serverVersion); err != nil {
-			return err
+			fmt.Fprintf(o.ErrOut, "Unable to get the server version: %s\n", err.Error())
+
+			serverVersion = nil
 		}
 		versionInfo.ServerVersion = serverVersion
 	}
 
+	versionInfo.ClientOnly = versionInfo.ServerVersion == nil
+
 	switch o.Output {
 	case "":
 		if o.Short {
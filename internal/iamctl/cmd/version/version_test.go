@@ -0,0 +1,155 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package version
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marmotedu/component-base/pkg/runtime"
+	"github.com/marmotedu/component-base/pkg/scheme"
+	"github.com/marmotedu/component-base/pkg/version"
+	restclient "github.com/marmotedu/marmotedu-sdk-go/rest"
+
+	"github.com/marmotedu/iam/pkg/cli/genericclioptions"
+)
+
+var serverVersion = version.Info{
+	GitVersion:   "v1.6.2",
+	GitCommit:    "4aeb84aaa4a2d4a8d1c4e5e2ee9c3b2d5b1d3c9e",
+	GitTreeState: "clean",
+	BuildDate:    "2021-11-03T08:16:34Z",
+	GoVersion:    "go1.17.2",
+	Compiler:     "gc",
+	Platform:     "linux/amd64",
+}
+
+// newRESTClient returns the client of iam-apiserver at url, configured as by the factory of iamctl.
+func newRESTClient(t *testing.T, url string) *restclient.RESTClient {
+	t.Helper()
+
+	config := &restclient.Config{Host: url}
+	config.APIPath = "/api"
+	config.GroupVersion = &scheme.GroupVersion{Group: "iam.api", Version: "v1"}
+	config.Negotiator = runtime.NewSimpleClientNegotiator()
+
+	if err := restclient.SetIAMDefaults(config); err != nil {
+		t.Fatalf("SetIAMDefaults() error = %v", err)
+	}
+
+	client, err := restclient.RESTClientFor(config)
+	if err != nil {
+		t.Fatalf("RESTClientFor() error = %v", err)
+	}
+
+	return client
+}
+
+func TestOptions_Run(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/version" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(serverVersion)
+	}))
+	defer srv.Close()
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	tests := []struct {
+		name           string
+		url            string
+		clientOnly     bool
+		wantServer     bool
+		wantClientOnly bool
+		wantErrOut     string
+	}{
+		{name: "client and server", url: srv.URL, wantServer: true},
+		{name: "client only", url: srv.URL, clientOnly: true, wantClientOnly: true},
+		{
+			name:           "server unreachable",
+			url:            unreachable.URL,
+			wantClientOnly: true,
+			wantErrOut:     "Unable to get the server version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ioStreams, _, out, errOut := genericclioptions.NewTestIOStreams()
+
+			o := NewOptions(ioStreams)
+			o.ClientOnly, o.Output = tt.clientOnly, "json"
+
+			if !tt.clientOnly {
+				o.client = newRESTClient(t, tt.url)
+			}
+
+			if err := o.Run(); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if !strings.Contains(errOut.String(), tt.wantErrOut) {
+				t.Errorf("Run() error output = %q, want it to contain %q", errOut.String(), tt.wantErrOut)
+			}
+
+			var got map[string]json.RawMessage
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v, output %s", err, out.String())
+			}
+
+			// the client and the server versions have the same structure, the one of --version=json.
+			var client version.Info
+			if err := json.Unmarshal(got["clientVersion"], &client); err != nil || client.GitVersion == "" {
+				t.Errorf("clientVersion = %s, error = %v", got["clientVersion"], err)
+			}
+
+			_, hasServer := got["serverVersion"]
+			if hasServer != tt.wantServer {
+				t.Errorf("serverVersion = %s, want it set %v", got["serverVersion"], tt.wantServer)
+			}
+
+			if tt.wantServer {
+				var server version.Info
+				if err := json.Unmarshal(got["serverVersion"], &server); err != nil || server != serverVersion {
+					t.Errorf("serverVersion = %+v, want %+v, error = %v", server, serverVersion, err)
+				}
+			}
+
+			if clientOnly := string(got["clientOnly"]) == "true"; clientOnly != tt.wantClientOnly {
+				t.Errorf("clientOnly = %s, want %v", got["clientOnly"], tt.wantClientOnly)
+			}
+		})
+	}
+}
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		output  string
+		wantErr bool
+	}{
+		{output: ""},
+		{output: "yaml"},
+		{output: "json"},
+		{output: "short", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			o := NewOptions(genericclioptions.NewTestIOStreamsDiscard())
+			o.Output = tt.output
+
+			if err := o.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -22,6 +22,9 @@ const (
 
 	//  ErrSecretNotFound - 404: Secret not found.
 	ErrSecretNotFound
+
+	// ErrSecretAlreadyShared - 400: Secret already shared with the user.
+	ErrSecretAlreadyShared
 )
 
 // iam-apiserver: policy errors.
@@ -29,3 +32,27 @@ const (
 	// ErrPolicyNotFound - 404: Policy not found.
 	ErrPolicyNotFound int = iota + 110201
 )
+
+// iam-apiserver: policy group errors.
+const (
+	// ErrPolicyGroupNotFound - 404: Policy group not found.
+	ErrPolicyGroupNotFound int = iota + 110301
+
+	// ErrPolicyInOtherGroup - 400: Policy already belongs to another policy group.
+	ErrPolicyInOtherGroup
+)
+
+// iam-apiserver: service account errors.
+const (
+	// ErrServiceAccountNotFound - 404: Service account not found.
+	ErrServiceAccountNotFound int = iota + 110401
+
+	// ErrServiceAccountAlreadyExist - 400: Service account already exist.
+	ErrServiceAccountAlreadyExist
+
+	// ErrInvalidAPIKey - 401: Invalid service api key.
+	ErrInvalidAPIKey
+
+	// ErrServiceTokenDisabled - 503: Service tokens are not enabled.
+	ErrServiceTokenDisabled
+)
@@ -27,12 +27,24 @@ const (
 
 	// ErrPageNotFound - 404: Page not found.
 	ErrPageNotFound
+
+	// ErrRequestBodyTooLarge - 413: Request body too large.
+	ErrRequestBodyTooLarge
+
+	// ErrInternalServer - 500: Internal server error, please report the request id.
+	ErrInternalServer
 )
 
 // common: database errors.
 const (
 	// ErrDatabase - 500: Database error.
 	ErrDatabase int = iota + 100101
+
+	// ErrDatabaseUnavailable - 503: Database is unavailable, please retry later.
+	ErrDatabaseUnavailable
+
+	// ErrResourceVersionConflict - 409: Resource was changed since it was read, read it again.
+	ErrResourceVersionConflict
 )
 
 // common: authorization and authentication errors.
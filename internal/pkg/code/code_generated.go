@@ -12,14 +12,25 @@ func init() {
 	register(ErrUserAlreadyExist, 400, "User already exist")
 	register(ErrReachMaxCount, 400, "Secret reach the max count")
 	register(ErrSecretNotFound, 404, "Secret not found")
+	register(ErrSecretAlreadyShared, 400, "Secret already shared with the user")
 	register(ErrPolicyNotFound, 404, "Policy not found")
+	register(ErrPolicyGroupNotFound, 404, "Policy group not found")
+	register(ErrPolicyInOtherGroup, 400, "Policy already belongs to another policy group")
+	register(ErrServiceAccountNotFound, 404, "Service account not found")
+	register(ErrServiceAccountAlreadyExist, 400, "Service account already exist")
+	register(ErrInvalidAPIKey, 401, "Invalid service api key")
+	register(ErrServiceTokenDisabled, 503, "Service tokens are not enabled")
 	register(ErrSuccess, 200, "OK")
 	register(ErrUnknown, 500, "Internal server error")
 	register(ErrBind, 400, "Error occurred while binding the request body to the struct")
 	register(ErrValidation, 400, "Validation failed")
 	register(ErrTokenInvalid, 401, "Token invalid")
 	register(ErrPageNotFound, 404, "Page not found")
+	register(ErrRequestBodyTooLarge, 413, "Request body too large")
+	register(ErrInternalServer, 500, "Internal server error, please report the request id")
 	register(ErrDatabase, 500, "Database error")
+	register(ErrDatabaseUnavailable, 503, "Database is unavailable, please retry later")
+	register(ErrResourceVersionConflict, 409, "Resource was changed since it was read, read it again")
 	register(ErrEncrypt, 401, "Error occurred while encrypting the user password")
 	register(ErrSignatureInvalid, 401, "Signature is invalid")
 	register(ErrExpired, 401, "Token expired")
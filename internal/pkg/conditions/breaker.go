@@ -0,0 +1,44 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package conditions
+
+import "time"
+
+const (
+	defaultBreakerThreshold = 3
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+// circuitBreaker stops requesting a remote endpoint after threshold consecutive failures,
+// and allows a single trial request once cooldown has elapsed.
+// It is not safe for concurrent use, callers must hold the owning entry lock.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+}
+
+func newCircuitBreaker() *circuitBreaker {
+	return &circuitBreaker{
+		threshold: defaultBreakerThreshold,
+		cooldown:  defaultBreakerCooldown,
+	}
+}
+
+func (b *circuitBreaker) allow(now time.Time) bool {
+	return b.failures < b.threshold || !now.Before(b.openUntil)
+}
+
+func (b *circuitBreaker) success() {
+	b.failures = 0
+}
+
+func (b *circuitBreaker) failure(now time.Time) {
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = now.Add(b.cooldown)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package conditions
+
+import (
+	"crypto/md5" // nolint: gosec
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/marmotedu/errors"
+)
+
+const digestPrefix = "Digest "
+
+// digestAuthorization answers a HTTP digest authentication challenge(RFC 2617), only the
+// MD5 algorithm and the auth quality of protection are supported.
+func digestAuthorization(challenge, username, password, method, uri string) (string, error) {
+	params, err := parseDigestChallenge(challenge)
+	if err != nil {
+		return "", err
+	}
+
+	if alg := params["algorithm"]; alg != "" && !strings.EqualFold(alg, "MD5") {
+		return "", errors.Errorf("unsupported digest algorithm %s", alg)
+	}
+
+	nonce := params["nonce"]
+	if nonce == "" {
+		return "", errors.New("digest challenge without nonce")
+	}
+
+	realm := params["realm"]
+	ha1 := md5Hex(username + ":" + realm + ":" + password)
+	ha2 := md5Hex(method + ":" + uri)
+
+	fields := []string{
+		fmt.Sprintf(`username="%s"`, username),
+		fmt.Sprintf(`realm="%s"`, realm),
+		fmt.Sprintf(`nonce="%s"`, nonce),
+		fmt.Sprintf(`uri="%s"`, uri),
+	}
+
+	if hasToken(params["qop"], "auth") {
+		cnonce, err := newCnonce()
+		if err != nil {
+			return "", err
+		}
+
+		nc := "00000001"
+		response := md5Hex(strings.Join([]string{ha1, nonce, nc, cnonce, "auth", ha2}, ":"))
+		fields = append(fields, "qop=auth", "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce),
+			fmt.Sprintf(`response="%s"`, response))
+	} else {
+		fields = append(fields, fmt.Sprintf(`response="%s"`, md5Hex(ha1+":"+nonce+":"+ha2)))
+	}
+
+	if opaque, ok := params["opaque"]; ok {
+		fields = append(fields, fmt.Sprintf(`opaque="%s"`, opaque))
+	}
+
+	if alg, ok := params["algorithm"]; ok {
+		fields = append(fields, "algorithm="+alg)
+	}
+
+	return digestPrefix + strings.Join(fields, ", "), nil
+}
+
+// parseDigestChallenge parses the parameters of a WWW-Authenticate digest challenge.
+func parseDigestChallenge(challenge string) (map[string]string, error) {
+	if len(challenge) < len(digestPrefix) || !strings.EqualFold(challenge[:len(digestPrefix)], digestPrefix) {
+		return nil, errors.Errorf("unsupported authentication challenge %q", challenge)
+	}
+
+	params := make(map[string]string)
+
+	s := challenge[len(digestPrefix):]
+	for {
+		s = strings.TrimLeft(s, " ,")
+
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " ")
+
+		var value string
+
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return nil, errors.Errorf("unterminated quoted value of %s", key)
+			}
+
+			value, s = s[1:end+1], s[end+2:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+
+			value, s = strings.TrimSpace(s[:end]), s[end:]
+		}
+
+		params[key] = value
+	}
+
+	return params, nil
+}
+
+func hasToken(list, token string) bool {
+	for _, v := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newCnonce() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "generate cnonce failed")
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s)) // nolint: gosec
+
+	return hex.EncodeToString(sum[:])
+}
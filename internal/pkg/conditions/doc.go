@@ -0,0 +1,7 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package conditions implements additional ladon conditions used by iam, and registers
+// them into ladon.ConditionFactories on import.
+package conditions
@@ -0,0 +1,428 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package conditions
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marmotedu/errors"
+	"github.com/ory/ladon"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+const (
+	// RemoteConditionName is the condition type name used in policy documents.
+	RemoteConditionName = "RemoteCondition"
+
+	defaultRemoteTTL     = 5 * time.Minute
+	defaultRemoteTimeout = 5 * time.Second
+	maxRemoteBodySize    = 1 << 20
+)
+
+// ErrCircuitOpen is returned when the remote endpoint failed too many times in a row and is
+// not requested until the circuit breaker cools down.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// ErrURLNotAllowed is returned when the url of a remote condition is not below one of the endpoints
+// allowed by SetRemoteEndpoints, it is never requested.
+var ErrURLNotAllowed = errors.New("url is not allowed")
+
+// nolint: gochecknoinits
+func init() {
+	ladon.ConditionFactories[RemoteConditionName] = func() ladon.Condition {
+		return new(RemoteCondition)
+	}
+}
+
+// RemoteCondition is fulfilled if any of the conditions fetched from URL is fulfilled.
+// The remote endpoint must return a JSON array of conditions written in the same format
+// as inline conditions, for example:
+//
+//	[{"type":"CIDRCondition","options":{"cidr":"10.0.0.0/8"}}]
+//
+// The fetched conditions are cached for TTL, after which they are revalidated with the
+// ETag returned by the endpoint. If the endpoint can not be reached the condition is
+// fulfilled only when FailOpen is true. URL must be below one of the endpoints allowed by
+// the operator, see SetRemoteEndpoints, the condition is never fulfilled otherwise.
+type RemoteCondition struct {
+	URL      string `json:"url"`
+	TTL      string `json:"ttl,omitempty"`
+	FailOpen bool   `json:"failOpen,omitempty"`
+}
+
+// RemoteEndpoints are the endpoints the remote conditions may fetch their conditions from, they
+// are configured by the operator, not by the policies.
+type RemoteEndpoints struct {
+	// URLs are the base urls of the endpoints, the url of a remote condition must have the scheme
+	// and the host of one of them, and a path below its path.
+	URLs []string
+	// Username and Password are used to answer HTTP digest authentication challenges.
+	Username string
+	Password string
+}
+
+// Validate returns an error if one of the urls is not an absolute http or https url.
+func (e RemoteEndpoints) Validate() error {
+	for _, endpoint := range e.URLs {
+		if _, err := parseEndpoint(endpoint); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetRemoteEndpoints sets the endpoints the remote conditions may fetch their conditions from, no
+// endpoint is allowed until it is called. The cached conditions are dropped.
+func SetRemoteEndpoints(endpoints RemoteEndpoints) error {
+	return defaultFetcher.setEndpoints(endpoints)
+}
+
+// GetName returns the condition's name.
+func (c *RemoteCondition) GetName() string {
+	return RemoteConditionName
+}
+
+// Fulfills returns true if any of the remote conditions is fulfilled by the given value.
+func (c *RemoteCondition) Fulfills(value interface{}, r *ladon.Request) bool {
+	conditions, err := defaultFetcher.fetch(c)
+	if errors.Is(err, ErrURLNotAllowed) {
+		log.Warnf("Evaluate remote condition %s failed: %s", c.URL, err.Error())
+
+		return false
+	}
+
+	if err != nil {
+		log.Warnf("Evaluate remote condition %s failed: %s, fail open: %t", c.URL, err.Error(), c.FailOpen)
+
+		return c.FailOpen
+	}
+
+	for _, condition := range conditions {
+		if condition.Fulfills(value, r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *RemoteCondition) ttl() (time.Duration, error) {
+	if c.TTL == "" {
+		return defaultRemoteTTL, nil
+	}
+
+	ttl, err := time.ParseDuration(c.TTL)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid ttl %q", c.TTL)
+	}
+
+	return ttl, nil
+}
+
+var defaultFetcher = newRemoteFetcher(&http.Client{Timeout: defaultRemoteTimeout})
+
+// remoteFetcher fetches and caches remote conditions, one entry per url, from the allowed endpoints only.
+type remoteFetcher struct {
+	client    *http.Client
+	now       func() time.Time
+	lock      sync.Mutex
+	endpoints []*url.URL
+	username  string
+	password  string
+	entries   map[string]*remoteEntry
+}
+
+type remoteEntry struct {
+	lock       sync.Mutex
+	fetched    bool
+	etag       string
+	expires    time.Time
+	conditions []ladon.Condition
+	breaker    *circuitBreaker
+}
+
+func newRemoteFetcher(client *http.Client) *remoteFetcher {
+	f := &remoteFetcher{
+		now:     time.Now,
+		entries: make(map[string]*remoteEntry),
+	}
+
+	// the endpoints could otherwise redirect to any url.
+	redirected := *client
+	redirected.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+
+		return f.allow(req.URL)
+	}
+	f.client = &redirected
+
+	return f
+}
+
+func (f *remoteFetcher) setEndpoints(endpoints RemoteEndpoints) error {
+	urls := make([]*url.URL, 0, len(endpoints.URLs))
+
+	for _, endpoint := range endpoints.URLs {
+		u, err := parseEndpoint(endpoint)
+		if err != nil {
+			return err
+		}
+
+		urls = append(urls, u)
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.endpoints, f.username, f.password = urls, endpoints.Username, endpoints.Password
+	f.entries = make(map[string]*remoteEntry)
+
+	return nil
+}
+
+// allow returns ErrURLNotAllowed unless u has the scheme and the host of an allowed endpoint and a
+// path below its path.
+func (f *remoteFetcher) allow(u *url.URL) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	// the path could otherwise escape the one of the endpoint.
+	if u.User == nil && !strings.Contains(u.Path, "..") {
+		for _, endpoint := range f.endpoints {
+			if strings.EqualFold(u.Scheme, endpoint.Scheme) && strings.EqualFold(u.Host, endpoint.Host) &&
+				underPath(u.Path, endpoint.Path) {
+				return nil
+			}
+		}
+	}
+
+	return errors.Wrap(ErrURLNotAllowed, u.Redacted())
+}
+
+func (f *remoteFetcher) credentials() (string, string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.username, f.password
+}
+
+func (f *remoteFetcher) entry(key string) *remoteEntry {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	e, ok := f.entries[key]
+	if !ok {
+		e = &remoteEntry{breaker: newCircuitBreaker()}
+		f.entries[key] = e
+	}
+
+	return e
+}
+
+func (f *remoteFetcher) fetch(c *RemoteCondition) ([]ladon.Condition, error) {
+	if c.URL == "" {
+		return nil, errors.New("url must be specified")
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid url %q", c.URL)
+	}
+
+	if err := f.allow(u); err != nil {
+		return nil, err
+	}
+
+	ttl, err := c.ttl()
+	if err != nil {
+		return nil, err
+	}
+
+	e := f.entry(c.URL)
+
+	// hold the entry lock while requesting, so concurrent evaluations share one request.
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	now := f.now()
+	if e.fetched && now.Before(e.expires) {
+		return e.conditions, nil
+	}
+
+	if !e.breaker.allow(now) {
+		return nil, ErrCircuitOpen
+	}
+
+	conditions, etag, modified, err := f.get(c, e.etag)
+	if err != nil {
+		e.breaker.failure(f.now())
+
+		return nil, err
+	}
+
+	e.breaker.success()
+
+	if modified {
+		e.conditions, e.etag, e.fetched = conditions, etag, true
+	}
+
+	e.expires = now.Add(ttl)
+
+	return e.conditions, nil
+}
+
+// get requests the remote conditions. If etag is not empty, the request is made conditional
+// and modified is false when the remote conditions did not change.
+func (f *remoteFetcher) get(c *RemoteCondition, etag string) ([]ladon.Condition, string, bool, error) {
+	resp, err := f.do(c, etag)
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, etag, false, nil
+	case http.StatusOK:
+	default:
+		return nil, "", false, errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRemoteBodySize))
+	if err != nil {
+		return nil, "", false, errors.Wrap(err, "read response body failed")
+	}
+
+	conditions, err := parseRemoteConditions(body)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	return conditions, resp.Header.Get("ETag"), true, nil
+}
+
+// do sends the request and answers a digest authentication challenge if the credentials of the
+// endpoints are set.
+func (f *remoteFetcher) do(c *RemoteCondition, etag string) (*http.Response, error) {
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, c.URL, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "create request failed")
+		}
+
+		req.Header.Set("Accept", "application/json")
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+
+		return req, nil
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "request remote conditions failed")
+	}
+
+	username, password := f.credentials()
+	if resp.StatusCode != http.StatusUnauthorized || username == "" {
+		return resp, nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	authorization, err := digestAuthorization(challenge, username, password, req.Method, req.URL.RequestURI())
+	if err != nil {
+		return nil, err
+	}
+
+	if req, err = newRequest(); err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", authorization)
+
+	resp, err = f.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "request remote conditions failed")
+	}
+
+	return resp, nil
+}
+
+type jsonCondition struct {
+	Type    string          `json:"type"`
+	Options json.RawMessage `json:"options"`
+}
+
+func parseRemoteConditions(data []byte) ([]ladon.Condition, error) {
+	var jcs []jsonCondition
+	if err := json.Unmarshal(data, &jcs); err != nil {
+		return nil, errors.Wrap(err, "decode remote conditions failed")
+	}
+
+	conditions := make([]ladon.Condition, 0, len(jcs))
+
+	for _, jc := range jcs {
+		// remote conditions referencing other remote conditions may never terminate.
+		if jc.Type == RemoteConditionName {
+			return nil, errors.Errorf("condition type %s is not allowed in remote conditions", jc.Type)
+		}
+
+		factory, ok := ladon.ConditionFactories[jc.Type]
+		if !ok {
+			return nil, errors.Errorf("unknown condition type %s", jc.Type)
+		}
+
+		condition := factory()
+		if len(jc.Options) > 0 {
+			if err := json.Unmarshal(jc.Options, condition); err != nil {
+				return nil, errors.Wrapf(err, "decode options of %s failed", jc.Type)
+			}
+		}
+
+		conditions = append(conditions, condition)
+	}
+
+	return conditions, nil
+}
+
+// parseEndpoint parses the base url of an allowed endpoint, it must be an absolute http or https url.
+func parseEndpoint(endpoint string) (*url.URL, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid remote condition endpoint %q", endpoint)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.Errorf("remote condition endpoint %q must be an http or https url", endpoint)
+	}
+
+	return u, nil
+}
+
+// underPath returns whether path is base or one of its sub-paths.
+func underPath(path, base string) bool {
+	base = strings.TrimSuffix(base, "/")
+
+	return path == base || strings.HasPrefix(path, base+"/")
+}
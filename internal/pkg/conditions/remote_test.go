@@ -0,0 +1,378 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package conditions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ory/ladon"
+)
+
+// etagServer serves remote conditions and answers conditional requests.
+type etagServer struct {
+	lock        sync.Mutex
+	body        string
+	etag        string
+	requests    int
+	notModified int
+}
+
+func (s *etagServer) set(body, etag string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.body, s.etag = body, etag
+}
+
+func (s *etagServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.requests++
+	if r.Header.Get("If-None-Match") == s.etag {
+		s.notModified++
+		w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	w.Header().Set("ETag", s.etag)
+	fmt.Fprint(w, s.body)
+}
+
+func equals(value string) string {
+	return fmt.Sprintf(`[{"type":"StringEqualCondition","options":{"equals":%q}}]`, value)
+}
+
+// newAllowedFetcher returns a fetcher of the client of srv allowed to fetch from endpoints, srv by default.
+func newAllowedFetcher(t *testing.T, srv *httptest.Server, endpoints RemoteEndpoints) *remoteFetcher {
+	if endpoints.URLs == nil {
+		endpoints.URLs = []string{srv.URL}
+	}
+
+	f := newRemoteFetcher(srv.Client())
+	if err := f.setEndpoints(endpoints); err != nil {
+		t.Fatalf("setEndpoints() error = %v", err)
+	}
+
+	return f
+}
+
+func fulfills(conditions []ladon.Condition, value interface{}) bool {
+	for _, c := range conditions {
+		if c.Fulfills(value, &ladon.Request{}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestRemoteFetcher_ETag(t *testing.T) {
+	es := &etagServer{}
+	es.set(equals("foo"), `"v1"`)
+
+	srv := httptest.NewServer(es)
+	defer srv.Close()
+
+	now := time.Now()
+	f := newAllowedFetcher(t, srv, RemoteEndpoints{})
+	f.now = func() time.Time { return now }
+
+	c := &RemoteCondition{URL: srv.URL, TTL: "1m"}
+
+	tests := []struct {
+		name            string
+		advance         time.Duration
+		update          func()
+		value           string
+		want            bool
+		wantRequests    int
+		wantNotModified int
+	}{
+		{name: "initial fetch", value: "foo", want: true, wantRequests: 1},
+		{name: "cached within ttl", advance: 30 * time.Second, value: "foo", want: true, wantRequests: 1},
+		{
+			name:            "revalidated after ttl",
+			advance:         time.Minute,
+			value:           "foo",
+			want:            true,
+			wantRequests:    2,
+			wantNotModified: 1,
+		},
+		{
+			name:            "stale within ttl after remote change",
+			advance:         30 * time.Second,
+			update:          func() { es.set(equals("bar"), `"v2"`) },
+			value:           "bar",
+			want:            false,
+			wantRequests:    2,
+			wantNotModified: 1,
+		},
+		{
+			name:            "invalidated on etag change",
+			advance:         time.Minute,
+			value:           "bar",
+			want:            true,
+			wantRequests:    3,
+			wantNotModified: 1,
+		},
+		{name: "old value is not fulfilled", value: "foo", want: false, wantRequests: 3, wantNotModified: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			if tt.update != nil {
+				tt.update()
+			}
+
+			conditions, err := f.fetch(c)
+			if err != nil {
+				t.Fatalf("fetch() error = %v", err)
+			}
+
+			if got := fulfills(conditions, tt.value); got != tt.want {
+				t.Errorf("fulfills(%s) = %v, want %v", tt.value, got, tt.want)
+			}
+
+			if es.requests != tt.wantRequests || es.notModified != tt.wantNotModified {
+				t.Errorf("requests = %d/%d not modified, want %d/%d",
+					es.requests, es.notModified, tt.wantRequests, tt.wantNotModified)
+			}
+		})
+	}
+}
+
+func TestRemoteCondition_FulfillsUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	fetcher := defaultFetcher
+	defer func() { defaultFetcher = fetcher }()
+
+	defaultFetcher = newRemoteFetcher(&http.Client{Timeout: time.Second})
+	if err := SetRemoteEndpoints(RemoteEndpoints{URLs: []string{url}}); err != nil {
+		t.Fatalf("SetRemoteEndpoints() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		failOpen bool
+		want     bool
+	}{
+		{name: "fail closed", failOpen: false, want: false},
+		{name: "fail open", failOpen: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &RemoteCondition{URL: url + "/" + tt.name, FailOpen: tt.failOpen}
+			if got := c.Fulfills("foo", &ladon.Request{}); got != tt.want {
+				t.Errorf("Fulfills() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteFetcher_CircuitBreaker(t *testing.T) {
+	var requests int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	f := newAllowedFetcher(t, srv, RemoteEndpoints{})
+	f.now = func() time.Time { return now }
+
+	c := &RemoteCondition{URL: srv.URL}
+	for i := 0; i < defaultBreakerThreshold+2; i++ {
+		if _, err := f.fetch(c); err == nil {
+			t.Fatalf("fetch() expected error")
+		}
+	}
+
+	if requests != defaultBreakerThreshold {
+		t.Errorf("requests = %d, want %d", requests, defaultBreakerThreshold)
+	}
+
+	now = now.Add(defaultBreakerCooldown)
+	if _, err := f.fetch(c); err == ErrCircuitOpen {
+		t.Errorf("fetch() error = %v after cooldown", err)
+	}
+
+	if requests != defaultBreakerThreshold+1 {
+		t.Errorf("requests = %d, want %d", requests, defaultBreakerThreshold+1)
+	}
+}
+
+func TestRemoteFetcher_DigestAuth(t *testing.T) {
+	const (
+		realm    = "iam"
+		nonce    = "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+		username = "colin"
+		password = "iam59!z$"
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if auth != "" {
+			params, err := parseDigestChallenge(auth)
+			if err == nil {
+				ha1 := md5Hex(username + ":" + realm + ":" + password)
+				ha2 := md5Hex(r.Method + ":" + params["uri"])
+				want := md5Hex(strings.Join([]string{ha1, nonce, params["nc"], params["cnonce"], "auth", ha2}, ":"))
+
+				if params["response"] == want && params["opaque"] == "5ccc" {
+					_ = json.NewEncoder(w).Encode([]map[string]interface{}{
+						{"type": "StringEqualCondition", "options": map[string]string{"equals": "foo"}},
+					})
+
+					return
+				}
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate",
+			fmt.Sprintf(`Digest realm="%s", qop="auth,auth-int", nonce="%s", opaque="5ccc"`, realm, nonce))
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		wantErr  bool
+	}{
+		{name: "valid credentials", username: username, password: password, wantErr: false},
+		{name: "invalid password", username: username, password: "wrong", wantErr: true},
+		{name: "no credentials", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAllowedFetcher(t, srv, RemoteEndpoints{Username: tt.username, Password: tt.password})
+			c := &RemoteCondition{URL: srv.URL + "/conditions?team=dev"}
+
+			conditions, err := f.fetch(c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !fulfills(conditions, "foo") {
+				t.Errorf("fetch() = %v, want conditions fulfilled by foo", conditions)
+			}
+		})
+	}
+}
+
+func TestRemoteFetcher_NotAllowed(t *testing.T) {
+	var requests int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if r.URL.Path == "/conditions/redirect" {
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+
+			return
+		}
+
+		fmt.Fprint(w, equals("foo"))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name         string
+		endpoints    []string
+		url          string
+		wantErr      bool
+		wantRequests int
+	}{
+		{name: "no endpoint", endpoints: []string{}, url: srv.URL + "/conditions", wantErr: true},
+		{name: "endpoint", endpoints: []string{srv.URL + "/conditions"}, url: srv.URL + "/conditions", wantRequests: 1},
+		{
+			name:         "below endpoint",
+			endpoints:    []string{srv.URL + "/conditions/"},
+			url:          srv.URL + "/conditions/dev?team=dev",
+			wantRequests: 1,
+		},
+		{name: "other path", endpoints: []string{srv.URL + "/conditions"}, url: srv.URL + "/conditions-dev", wantErr: true},
+		{name: "path escape", endpoints: []string{srv.URL + "/conditions"}, url: srv.URL + "/conditions/../admin", wantErr: true},
+		{name: "other host", url: "http://169.254.169.254/latest/meta-data", wantErr: true},
+		{name: "other scheme", url: strings.Replace(srv.URL, "http://", "https://", 1), wantErr: true},
+		{name: "user info", url: strings.Replace(srv.URL, "http://", "http://colin@", 1), wantErr: true},
+		{name: "redirect", url: srv.URL + "/conditions/redirect", wantErr: true, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = 0
+			f := newAllowedFetcher(t, srv, RemoteEndpoints{URLs: tt.endpoints})
+
+			_, err := f.fetch(&RemoteCondition{URL: tt.url})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr && !errors.Is(err, ErrURLNotAllowed) {
+				t.Errorf("fetch() error = %v, want %v", err, ErrURLNotAllowed)
+			}
+
+			if requests != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestSetRemoteEndpoints(t *testing.T) {
+	fetcher := defaultFetcher
+	defer func() { defaultFetcher = fetcher }()
+
+	defaultFetcher = newRemoteFetcher(&http.Client{})
+
+	tests := []struct {
+		name     string
+		endpoint string
+		wantErr  bool
+	}{
+		{name: "http", endpoint: "http://127.0.0.1:8080/conditions"},
+		{name: "https", endpoint: "https://conditions.marmotedu.com"},
+		{name: "relative", endpoint: "/conditions", wantErr: true},
+		{name: "other scheme", endpoint: "file:///etc/passwd", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := SetRemoteEndpoints(RemoteEndpoints{URLs: []string{tt.endpoint}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SetRemoteEndpoints() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRemoteCondition_Unmarshal(t *testing.T) {
+	conditions := ladon.Conditions{}
+
+	data := `{"ip":{"type":"RemoteCondition","options":{"url":"http://127.0.0.1/ips","ttl":"10m","failOpen":true}}}`
+	if err := json.Unmarshal([]byte(data), &conditions); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := &RemoteCondition{URL: "http://127.0.0.1/ips", TTL: "10m", FailOpen: true}
+	if got, ok := conditions["ip"].(*RemoteCondition); !ok || *got != *want {
+		t.Errorf("Unmarshal() = %#v, want %#v", conditions["ip"], want)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package grpcauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// AuthorizationKey defines the grpc metadata key which carries the bearer token.
+	AuthorizationKey = "authorization"
+
+	// BearerScheme defines the authorization scheme of the token.
+	BearerScheme = "Bearer"
+)
+
+// tokenCredentials implements credentials.PerRPCCredentials with a static bearer token.
+type tokenCredentials struct {
+	token string
+}
+
+var _ credentials.PerRPCCredentials = (*tokenCredentials)(nil)
+
+// NewTokenCredentials returns a PerRPCCredentials which attaches the given token
+// to every rpc as authorization metadata.
+func NewTokenCredentials(token string) credentials.PerRPCCredentials {
+	return &tokenCredentials{token: token}
+}
+
+// GetRequestMetadata returns the authorization metadata attached to the rpc.
+func (c *tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{
+		AuthorizationKey: BearerScheme + " " + c.token,
+	}, nil
+}
+
+// RequireTransportSecurity makes sure the token is never sent over an insecure connection.
+func (c *tokenCredentials) RequireTransportSecurity() bool {
+	return true
+}
+
+// TokenAuthenticator validates bearer tokens against a set of accepted tokens.
+type TokenAuthenticator struct {
+	tokens [][]byte
+}
+
+// NewTokenAuthenticator returns a TokenAuthenticator which accepts the given tokens.
+// Empty tokens are ignored.
+func NewTokenAuthenticator(tokens []string) *TokenAuthenticator {
+	a := &TokenAuthenticator{}
+	for _, t := range tokens {
+		if t = strings.TrimSpace(t); t != "" {
+			a.tokens = append(a.tokens, []byte(t))
+		}
+	}
+
+	return a
+}
+
+// Authenticate checks the bearer token carried by the incoming context. It returns
+// an Unauthenticated status error when the token is missing or not accepted.
+// The token value is never part of the returned error.
+func (a *TokenAuthenticator) Authenticate(ctx context.Context) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing metadata")
+	}
+
+	values := md.Get(AuthorizationKey)
+	if len(values) == 0 {
+		return status.Error(codes.Unauthenticated, "missing authorization token")
+	}
+
+	parts := strings.SplitN(values[0], " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], BearerScheme) {
+		return status.Error(codes.Unauthenticated, "invalid authorization scheme")
+	}
+
+	token := []byte(strings.TrimSpace(parts[1]))
+	matched := 0
+	// compare against all the tokens to not leak which one matched through timing.
+	for _, t := range a.tokens {
+		matched |= subtle.ConstantTimeCompare(t, token)
+	}
+
+	if matched != 1 {
+		return status.Error(codes.Unauthenticated, "invalid authorization token")
+	}
+
+	return nil
+}
+
+// UnaryServerInterceptor returns a grpc.UnaryServerInterceptor which rejects unauthenticated rpcs.
+func (a *TokenAuthenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if err := a.Authenticate(ctx); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a grpc.StreamServerInterceptor which rejects unauthenticated streams.
+func (a *TokenAuthenticator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := a.Authenticate(ss.Context()); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package grpcauth
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestTokenCredentials_GetRequestMetadata(t *testing.T) {
+	got, err := NewTokenCredentials("s3cr3t").GetRequestMetadata(context.Background())
+	if err != nil {
+		t.Fatalf("GetRequestMetadata() error = %v", err)
+	}
+
+	want := map[string]string{AuthorizationKey: "Bearer s3cr3t"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetRequestMetadata() = %v, want %v", got, want)
+	}
+}
+
+func TestTokenAuthenticator_UnaryServerInterceptor(t *testing.T) {
+	authenticator := NewTokenAuthenticator([]string{"token-a", "token-b", ""})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	tests := []struct {
+		name     string
+		md       metadata.MD
+		want     interface{}
+		wantCode codes.Code
+	}{
+		{
+			name:     "valid token",
+			md:       metadata.Pairs(AuthorizationKey, "Bearer token-b"),
+			want:     "ok",
+			wantCode: codes.OK,
+		},
+		{
+			name:     "missing metadata",
+			md:       nil,
+			want:     nil,
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "missing token",
+			md:       metadata.Pairs("x-request-id", "1"),
+			want:     nil,
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "wrong token",
+			md:       metadata.Pairs(AuthorizationKey, "Bearer token-c"),
+			want:     nil,
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "empty token",
+			md:       metadata.Pairs(AuthorizationKey, "Bearer "),
+			want:     nil,
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "wrong scheme",
+			md:       metadata.Pairs(AuthorizationKey, "Basic token-a"),
+			want:     nil,
+			wantCode: codes.Unauthenticated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+
+			got, err := authenticator.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("UnaryServerInterceptor() code = %v, wantCode %v", code, tt.wantCode)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UnaryServerInterceptor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package grpcquota
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// StreamQuota limits the number of rpcs served at the same time, over all the connections of a
+// grpc server. Unary rpcs are counted as well, as they use a stream of the connection.
+type StreamQuota struct {
+	max    int64
+	active int64
+}
+
+// NewStreamQuota returns a StreamQuota which serves up to max concurrent streams.
+func NewStreamQuota(max uint32) *StreamQuota {
+	return &StreamQuota{max: int64(max)}
+}
+
+// Active returns the number of streams being served.
+func (q *StreamQuota) Active() int64 {
+	return atomic.LoadInt64(&q.active)
+}
+
+// acquire takes a slot for a new stream. It returns a ResourceExhausted status error when
+// all the slots are taken, the rejection is logged.
+func (q *StreamQuota) acquire(ctx context.Context, method string) error {
+	if atomic.AddInt64(&q.active, 1) <= q.max {
+		return nil
+	}
+
+	atomic.AddInt64(&q.active, -1)
+
+	addr := "unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+	}
+
+	log.Warnf("Reject grpc call %s from %s: max concurrent streams %d exceeded", method, addr, q.max)
+
+	return status.Errorf(codes.ResourceExhausted, "max concurrent streams %d exceeded", q.max)
+}
+
+func (q *StreamQuota) release() {
+	atomic.AddInt64(&q.active, -1)
+}
+
+// UnaryServerInterceptor returns a grpc.UnaryServerInterceptor which rejects the rpcs over the quota.
+func (q *StreamQuota) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if err := q.acquire(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		defer q.release()
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a grpc.StreamServerInterceptor which rejects the streams over the quota.
+func (q *StreamQuota) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := q.acquire(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		defer q.release()
+
+		return handler(srv, ss)
+	}
+}
+
+// RegisterMetrics registers the grpc_concurrent_streams gauge into the default prometheus
+// registry, which is exposed by the /metrics endpoint of the generic api server.
+func RegisterMetrics(q *StreamQuota) {
+	prometheus.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "grpc_concurrent_streams",
+			Help: "Number of grpc streams being served.",
+		}, func() float64 {
+			return float64(q.Active())
+		}),
+	)
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package grpcquota
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const maxStreams = 100
+
+// startServer starts a grpc server serving the health service, whose Watch streams stay
+// open until the client cancels them.
+func startServer(t *testing.T, quota *StreamQuota) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	server := grpc.NewServer(
+		grpc.MaxConcurrentStreams(maxStreams),
+		grpc.ChainUnaryInterceptor(quota.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(quota.StreamServerInterceptor()),
+	)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+
+	go func() {
+		_ = server.Serve(ln)
+	}()
+
+	t.Cleanup(server.Stop)
+
+	return ln.Addr().String()
+}
+
+func dial(t *testing.T, addr string) healthpb.HealthClient {
+	t.Helper()
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return healthpb.NewHealthClient(conn)
+}
+
+// watch opens a Watch stream and waits until it is served.
+func watch(ctx context.Context, client healthpb.HealthClient) error {
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+
+	_, err = stream.Recv()
+
+	return err
+}
+
+func TestStreamQuota(t *testing.T) {
+	quota := NewStreamQuota(maxStreams)
+	addr := startServer(t, quota)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a connection only serves maxStreams streams, the next streams are opened by another
+	// replica, i.e. over another connection.
+	first := dial(t, addr)
+	for i := 0; i < maxStreams; i++ {
+		if err := watch(ctx, first); err != nil {
+			t.Fatalf("stream %d failed: %v", i+1, err)
+		}
+	}
+
+	if got := quota.Active(); got != maxStreams {
+		t.Errorf("Active() = %d, want %d", got, maxStreams)
+	}
+
+	second := dial(t, addr)
+
+	err := watch(context.Background(), second)
+	if code := status.Code(err); code != codes.ResourceExhausted {
+		t.Errorf("stream %d error = %v, want code %v", maxStreams+1, err, codes.ResourceExhausted)
+	}
+
+	_, err = second.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if code := status.Code(err); code != codes.ResourceExhausted {
+		t.Errorf("Check() error = %v, want code %v", err, codes.ResourceExhausted)
+	}
+
+	if got := quota.Active(); got != maxStreams {
+		t.Errorf("Active() after rejections = %d, want %d", got, maxStreams)
+	}
+
+	// the slots are released once the streams end
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for quota.Active() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := second.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("Check() after release error = %v", err)
+	}
+}
@@ -0,0 +1,6 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package jwt manages the rotating keys used to sign and verify the jwt tokens issued by iam-apiserver.
+package jwt // import "github.com/marmotedu/iam/internal/pkg/jwt"
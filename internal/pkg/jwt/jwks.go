@@ -0,0 +1,49 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package jwt
+
+import (
+	"encoding/base64"
+	"math/big"
+)
+
+// JSONWebKey is the public part of a signing key, as defined by RFC 7517.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JSONWebKeySet is the set of the public keys which verify the tokens, as defined by RFC 7517.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public keys accepted to verify tokens. Symmetric keys can not be published,
+// so the set is empty for HS256.
+func (r *KeyRotator) JWKS() *JSONWebKeySet {
+	set := &JSONWebKeySet{Keys: []JSONWebKey{}}
+
+	for _, key := range r.Keys().Items {
+		if key.privateKey == nil {
+			continue
+		}
+
+		pub := key.privateKey.PublicKey
+		set.Keys = append(set.Keys, JSONWebKey{
+			Kty: "RSA",
+			Kid: key.ID,
+			Alg: key.Algorithm,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		})
+	}
+
+	return set
+}
@@ -0,0 +1,350 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package jwt
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	gojwt "github.com/dgrijalva/jwt-go/v4"
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// Supported signing algorithms.
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+)
+
+const (
+	rsaKeyBits   = 2048
+	randomKeyLen = 32
+)
+
+// Defined errors.
+var (
+	ErrMissingKID       = errors.New("token is missing the kid header")
+	ErrUnknownKey       = errors.New("token is signed by an unknown or expired key")
+	ErrInvalidAlgorithm = errors.New("token is signed with an unexpected algorithm")
+)
+
+// Key is a key used to sign and verify jwt tokens.
+type Key struct {
+	ID        string    `json:"kid"`
+	Algorithm string    `json:"alg"`
+	CreatedAt time.Time `json:"createdAt"`
+	// ExpiresAt is the time after which the tokens signed by the key are rejected, zero if never.
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Current is true if the key signs the new tokens.
+	Current bool `json:"current"`
+
+	secret     []byte
+	privateKey *rsa.PrivateKey
+}
+
+// KeyList is the list of the keys of a KeyRotator, the current key first.
+type KeyList struct {
+	Items []*Key `json:"items"`
+}
+
+func (k *Key) signingKey() interface{} {
+	if k.privateKey != nil {
+		return k.privateKey
+	}
+
+	return k.secret
+}
+
+func (k *Key) verifyingKey() interface{} {
+	if k.privateKey != nil {
+		return &k.privateKey.PublicKey
+	}
+
+	return k.secret
+}
+
+func (k *Key) expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && now.After(k.ExpiresAt)
+}
+
+// KeyRotator generates a new signing key every interval and retains the previous keys in a ring
+// buffer, so tokens signed before a rotation stay valid until their key is dropped from the ring.
+//
+// HS256 keys are derived from the secret and the rotation period, so all the instances sharing the
+// secret sign and verify with the same keys and restarts do not invalidate the issued tokens. RS256
+// keys are generated randomly and are only known by the instance which generated them.
+type KeyRotator struct {
+	algorithm string
+	secret    []byte
+	interval  time.Duration
+	onRotate  func(*Key)
+	now       func() time.Time
+
+	lock sync.RWMutex
+	// keys is the ring buffer of the current and the retained keys.
+	keys    []*Key
+	current int
+}
+
+// NewKeyRotator creates a key rotator signing with algorithm and rotating the key every interval,
+// the retain previous keys are still accepted to verify tokens. If interval is 0, the key never
+// rotates and the tokens are signed by secret for HS256.
+func NewKeyRotator(algorithm string, secret []byte, interval time.Duration, retain int) (*KeyRotator, error) {
+	if algorithm != AlgorithmHS256 && algorithm != AlgorithmRS256 {
+		return nil, errors.Errorf("unsupported jwt signing algorithm %q", algorithm)
+	}
+
+	if interval < 0 || retain < 0 {
+		return nil, errors.New("the rotation interval and the number of retained keys can not be negative")
+	}
+
+	if interval > 0 && interval < time.Second {
+		return nil, errors.New("the rotation interval can not be less than 1s")
+	}
+
+	if interval == 0 {
+		retain = 0
+	}
+
+	r := &KeyRotator{
+		algorithm: algorithm,
+		secret:    secret,
+		interval:  interval,
+		now:       time.Now,
+		keys:      make([]*Key, retain+1),
+	}
+
+	// the keys of the previous periods are derived again so the tokens they signed are still accepted.
+	start := 0
+	if r.derived() {
+		start = retain
+	}
+
+	now := r.now()
+	for i := start; i >= 0; i-- {
+		key, err := r.newKey(now.Add(-time.Duration(i) * interval))
+		if err != nil {
+			return nil, err
+		}
+
+		r.current = (r.current + 1) % len(r.keys)
+		r.keys[r.current] = key
+	}
+
+	return r, nil
+}
+
+// derived returns whether the keys are derived from the secret.
+func (r *KeyRotator) derived() bool {
+	return r.algorithm == AlgorithmHS256 && len(r.secret) > 0
+}
+
+// newKey creates the key of the rotation period including now.
+func (r *KeyRotator) newKey(now time.Time) (*Key, error) {
+	key := &Key{
+		Algorithm: r.algorithm,
+		CreatedAt: now,
+	}
+
+	switch {
+	case r.interval == 0 && r.derived():
+		// the static key, no kid is required so the tokens issued before are still accepted.
+		key.secret = r.secret
+
+		return key, nil
+	case r.derived():
+		period := now.Unix() / int64(r.interval/time.Second)
+		key.CreatedAt = time.Unix(period*int64(r.interval/time.Second), 0)
+		key.ID = fmt.Sprintf("hs256-%d", period)
+
+		mac := hmac.New(sha256.New, r.secret)
+		mac.Write([]byte(key.ID))
+		key.secret = mac.Sum(nil)
+	case r.algorithm == AlgorithmHS256:
+		key.secret = make([]byte, randomKeyLen)
+		if _, err := rand.Read(key.secret); err != nil {
+			return nil, errors.Wrap(err, "generate hmac key failed")
+		}
+	default:
+		privateKey, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, errors.Wrap(err, "generate rsa key failed")
+		}
+		key.privateKey = privateKey
+	}
+
+	if key.ID == "" {
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return nil, errors.Wrap(err, "generate key id failed")
+		}
+		key.ID = hex.EncodeToString(id)
+	}
+
+	if r.interval > 0 {
+		key.ExpiresAt = key.CreatedAt.Add(time.Duration(len(r.keys)) * r.interval)
+	}
+
+	return key, nil
+}
+
+// OnRotate sets the function called with the new key after each rotation.
+func (r *KeyRotator) OnRotate(fn func(*Key)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.onRotate = fn
+}
+
+// Algorithm returns the signing algorithm of the keys.
+func (r *KeyRotator) Algorithm() string {
+	return r.algorithm
+}
+
+// Current returns the key signing the new tokens.
+func (r *KeyRotator) Current() *Key {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.keys[r.current]
+}
+
+// Keys returns the keys accepted to verify tokens, the current key first.
+func (r *KeyRotator) Keys() *KeyList {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	now := r.now()
+	list := &KeyList{Items: make([]*Key, 0, len(r.keys))}
+	for i := 0; i < len(r.keys); i++ {
+		key := r.keys[(r.current-i+len(r.keys))%len(r.keys)]
+		if key == nil || key.expired(now) {
+			continue
+		}
+
+		k := *key
+		k.Current = i == 0
+		list.Items = append(list.Items, &k)
+	}
+
+	return list
+}
+
+// Rotate replaces the current key by a new key, the previous keys are retained until they are
+// dropped from the ring buffer.
+func (r *KeyRotator) Rotate() (*Key, error) {
+	key, err := r.newKey(r.now())
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	// derived keys only change with the rotation period.
+	if key.ID == r.keys[r.current].ID {
+		r.lock.Unlock()
+
+		return r.keys[r.current], nil
+	}
+
+	r.current = (r.current + 1) % len(r.keys)
+	r.keys[r.current] = key
+	onRotate := r.onRotate
+	r.lock.Unlock()
+
+	if onRotate != nil {
+		onRotate(key)
+	}
+
+	return key, nil
+}
+
+// Start rotates the key every interval until ctx is done.
+func (r *KeyRotator) Start(ctx context.Context) {
+	if r.interval == 0 {
+		return
+	}
+
+	for {
+		wait := r.Current().CreatedAt.Add(r.interval).Sub(r.now())
+		if wait < 0 {
+			wait = 0
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return
+		case <-timer.C:
+		}
+
+		key, err := r.Rotate()
+		if err != nil {
+			log.Errorf("Failed to rotate jwt signing key: %s", err.Error())
+
+			// retry later instead of spinning on the past rotation time.
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Minute):
+			}
+
+			continue
+		}
+
+		log.Infow("Rotated jwt signing key", "kid", key.ID, "expiresAt", key.ExpiresAt)
+	}
+}
+
+// Sign returns the token of claims signed by the current key, its kid header identifies the key.
+func (r *KeyRotator) Sign(claims gojwt.Claims) (string, error) {
+	key := r.Current()
+
+	token := gojwt.NewWithClaims(gojwt.GetSigningMethod(key.Algorithm), claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+
+	return token.SignedString(key.signingKey())
+}
+
+// Keyfunc returns the key which verifies the token, it can be passed to the parse functions of jwt-go.
+func (r *KeyRotator) Keyfunc(token *gojwt.Token) (interface{}, error) {
+	if token.Method.Alg() != r.algorithm {
+		return nil, ErrInvalidAlgorithm
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		// the tokens signed by the static key have no kid.
+		if r.interval == 0 && r.derived() {
+			return r.Current().verifyingKey(), nil
+		}
+
+		return nil, ErrMissingKID
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	now := r.now()
+	for _, key := range r.keys {
+		if key != nil && key.ID == kid && !key.expired(now) {
+			return key.verifyingKey(), nil
+		}
+	}
+
+	return nil, ErrUnknownKey
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package jwt
+
+import (
+	"testing"
+	"time"
+
+	gojwt "github.com/dgrijalva/jwt-go/v4"
+)
+
+func TestKeyRotator_GracePeriod(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		secret    []byte
+	}{
+		{name: "derived hmac", algorithm: AlgorithmHS256, secret: []byte("iam-secret")},
+		{name: "random hmac", algorithm: AlgorithmHS256},
+		{name: "rsa", algorithm: AlgorithmRS256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewKeyRotator(tt.algorithm, tt.secret, time.Hour, 1)
+			if err != nil {
+				t.Fatalf("NewKeyRotator() error = %v", err)
+			}
+
+			clock := time.Now()
+			r.now = func() time.Time { return clock }
+
+			var rotated []*Key
+			r.OnRotate(func(key *Key) { rotated = append(rotated, key) })
+
+			oldToken, err := r.Sign(gojwt.MapClaims{"sub": "admin"})
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+
+			// the token signed by the previous key is still valid during the grace period.
+			clock = clock.Add(time.Hour)
+			if _, err := r.Rotate(); err != nil {
+				t.Fatalf("Rotate() error = %v", err)
+			}
+			if _, err := gojwt.Parse(oldToken, r.Keyfunc); err != nil {
+				t.Errorf("Parse(old token) in grace period error = %v", err)
+			}
+
+			newToken, err := r.Sign(gojwt.MapClaims{"sub": "admin"})
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+
+			// the previous key is dropped once the ring buffer is full.
+			clock = clock.Add(time.Hour)
+			if _, err := r.Rotate(); err != nil {
+				t.Fatalf("Rotate() error = %v", err)
+			}
+			if _, err := gojwt.Parse(oldToken, r.Keyfunc); err == nil {
+				t.Errorf("Parse(old token) after grace period succeeded")
+			}
+			if _, err := gojwt.Parse(newToken, r.Keyfunc); err != nil {
+				t.Errorf("Parse(new token) error = %v", err)
+			}
+
+			if len(rotated) != 2 || rotated[1].ID != r.Current().ID {
+				t.Errorf("OnRotate() called with %v, want the 2 new keys", rotated)
+			}
+
+			keys := r.Keys().Items
+			if len(keys) != 2 || !keys[0].Current || keys[1].Current {
+				t.Errorf("Keys() = %v, want the current key then the retained key", keys)
+			}
+		})
+	}
+}
+
+func TestKeyRotator_Static(t *testing.T) {
+	secret := []byte("iam-secret")
+	r, err := NewKeyRotator(AlgorithmHS256, secret, 0, 2)
+	if err != nil {
+		t.Fatalf("NewKeyRotator() error = %v", err)
+	}
+
+	// the tokens signed by the static key before are still accepted.
+	token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, gojwt.MapClaims{"sub": "admin"}).SignedString(secret)
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	if _, err := gojwt.Parse(token, r.Keyfunc); err != nil {
+		t.Errorf("Parse() error = %v", err)
+	}
+
+	key, err := r.Rotate()
+	if err != nil || key.ID != "" || len(r.Keys().Items) != 1 {
+		t.Errorf("Rotate() = %v, %v, want the static key", key, err)
+	}
+}
+
+func TestKeyRotator_Derived(t *testing.T) {
+	secret := []byte("iam-secret")
+	r1, err := NewKeyRotator(AlgorithmHS256, secret, time.Hour, 2)
+	if err != nil {
+		t.Fatalf("NewKeyRotator() error = %v", err)
+	}
+	r2, err := NewKeyRotator(AlgorithmHS256, secret, time.Hour, 2)
+	if err != nil {
+		t.Fatalf("NewKeyRotator() error = %v", err)
+	}
+
+	// the instances sharing the secret verify the tokens of each other.
+	token, err := r1.Sign(gojwt.MapClaims{"sub": "admin"})
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if _, err := gojwt.Parse(token, r2.Keyfunc); err != nil {
+		t.Errorf("Parse() error = %v", err)
+	}
+
+	if got := len(r1.Keys().Items); got != 3 {
+		t.Errorf("len(Keys()) = %d, want the current key and 2 retained keys", got)
+	}
+
+	other, err := NewKeyRotator(AlgorithmHS256, []byte("other-secret"), time.Hour, 2)
+	if err != nil {
+		t.Fatalf("NewKeyRotator() error = %v", err)
+	}
+	if _, err := gojwt.Parse(token, other.Keyfunc); err == nil {
+		t.Errorf("Parse() with other secret succeeded")
+	}
+}
+
+func TestKeyRotator_JWKS(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		want      int
+	}{
+		{name: "hmac keys are not published", algorithm: AlgorithmHS256, want: 0},
+		{name: "rsa", algorithm: AlgorithmRS256, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewKeyRotator(tt.algorithm, []byte("iam-secret"), time.Hour, 1)
+			if err != nil {
+				t.Fatalf("NewKeyRotator() error = %v", err)
+			}
+
+			got := r.JWKS().Keys
+			if len(got) != tt.want {
+				t.Fatalf("len(JWKS().Keys) = %d, want %d", len(got), tt.want)
+			}
+			if tt.want > 0 && (got[0].Kid != r.Current().ID || got[0].Kty != "RSA" || got[0].N == "") {
+				t.Errorf("JWKS().Keys[0] = %+v", got[0])
+			}
+		})
+	}
+}
+
+func TestNewKeyRotator_Invalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		interval  time.Duration
+		retain    int
+	}{
+		{name: "unknown algorithm", algorithm: "ES256", interval: time.Hour},
+		{name: "negative interval", algorithm: AlgorithmHS256, interval: -time.Hour},
+		{name: "negative retain", algorithm: AlgorithmHS256, interval: time.Hour, retain: -1},
+		{name: "interval less than 1s", algorithm: AlgorithmHS256, interval: time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKeyRotator(tt.algorithm, []byte("iam-secret"), tt.interval, tt.retain); err == nil {
+				t.Errorf("NewKeyRotator() succeeded, want error")
+			}
+		})
+	}
+}
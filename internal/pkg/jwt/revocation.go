@@ -0,0 +1,87 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package jwt
+
+import (
+	"context"
+	"time"
+
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+// revokedKeyPrefix prefixes the redis keys of the revoked tokens, by token id (jti).
+const revokedKeyPrefix = "iam-jwt-revoked-"
+
+// RevocationStorage is the part of the redis storage used by RevocationStore.
+type RevocationStorage interface {
+	SetKey(ctx context.Context, keyName, value string, timeout time.Duration) error
+	GetKey(ctx context.Context, keyName string) (string, error)
+	GetMultiKey(ctx context.Context, keys []string) ([]string, error)
+}
+
+// RevocationStore records the ids (jti) of the revoked tokens in redis, until the tokens expire.
+type RevocationStore struct {
+	store RevocationStorage
+}
+
+// NewRevocationStore returns a RevocationStore recording the revoked tokens in store.
+func NewRevocationStore(store RevocationStorage) *RevocationStore {
+	return &RevocationStore{store: store}
+}
+
+// Revoke revokes the token jti which expires at expiresAt.
+func (s *RevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		// an expired token is rejected anyway.
+		return nil
+	}
+
+	return s.store.SetKey(ctx, revokedKeyPrefix+jti, "1", ttl)
+}
+
+// IsRevoked returns whether the token jti is revoked.
+func (s *RevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	_, err := s.store.GetKey(ctx, revokedKeyPrefix+jti)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Revoked returns the revoked tokens among jtis. The tokens are looked up in one batch, with a
+// single MGET, rather than with one request per token.
+func (s *RevocationStore) Revoked(ctx context.Context, jtis []string) (map[string]bool, error) {
+	revoked := make(map[string]bool)
+	if len(jtis) == 0 {
+		return revoked, nil
+	}
+
+	keys := make([]string, len(jtis))
+	for i, jti := range jtis {
+		keys[i] = revokedKeyPrefix + jti
+	}
+
+	values, err := s.store.GetMultiKey(ctx, keys)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		// none of the tokens is revoked.
+		return revoked, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, value := range values {
+		if value != "" {
+			revoked[jtis[i]] = true
+		}
+	}
+
+	return revoked, nil
+}
@@ -0,0 +1,164 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package jwt
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+// fakeRevocationStorage stores the keys in memory and records the keys requested by each GET and MGET.
+type fakeRevocationStorage struct {
+	keys  map[string]time.Duration
+	gets  []string
+	mgets [][]string
+	// err is returned by the reads, like storage.RedisCluster while redis is down.
+	err error
+}
+
+func (s *fakeRevocationStorage) SetKey(ctx context.Context, keyName, value string, timeout time.Duration) error {
+	s.keys[keyName] = timeout
+
+	return nil
+}
+
+func (s *fakeRevocationStorage) GetKey(ctx context.Context, keyName string) (string, error) {
+	s.gets = append(s.gets, keyName)
+
+	if s.err != nil {
+		return "", s.err
+	}
+
+	if _, ok := s.keys[keyName]; !ok {
+		return "", storage.ErrKeyNotFound
+	}
+
+	return "1", nil
+}
+
+// GetMultiKey behaves like storage.RedisCluster, which returns ErrKeyNotFound if no key exists.
+func (s *fakeRevocationStorage) GetMultiKey(ctx context.Context, keys []string) ([]string, error) {
+	s.mgets = append(s.mgets, keys)
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	var found bool
+
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		if _, ok := s.keys[key]; ok {
+			values[i] = "1"
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, storage.ErrKeyNotFound
+	}
+
+	return values, nil
+}
+
+func TestRevocationStore_Revoked(t *testing.T) {
+	tests := []struct {
+		name    string
+		revoked []string
+		jtis    []string
+		want    map[string]bool
+		mgets   int
+	}{
+		{
+			name:    "some revoked",
+			revoked: []string{"b", "d"},
+			jtis:    []string{"a", "b", "c", "d"},
+			want:    map[string]bool{"b": true, "d": true},
+			mgets:   1,
+		},
+		{
+			name:  "none revoked",
+			jtis:  []string{"a", "b"},
+			want:  map[string]bool{},
+			mgets: 1,
+		},
+		{
+			name: "no token",
+			want: map[string]bool{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &fakeRevocationStorage{keys: make(map[string]time.Duration)}
+			s := NewRevocationStore(backend)
+
+			for _, jti := range tt.revoked {
+				if err := s.Revoke(context.Background(), jti, time.Now().Add(time.Hour)); err != nil {
+					t.Fatalf("Revoke() error = %v", err)
+				}
+			}
+
+			got, err := s.Revoked(context.Background(), tt.jtis)
+			if err != nil {
+				t.Fatalf("Revoked() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Revoked() = %v, want %v", got, tt.want)
+			}
+
+			// all the tokens are looked up with one MGET, never one GET per token.
+			if len(backend.mgets) != tt.mgets || len(backend.gets) != 0 {
+				t.Errorf("sent %d MGET and %d GET, want %d MGET and no GET", len(backend.mgets), len(backend.gets), tt.mgets)
+			}
+
+			if tt.mgets != 0 && len(backend.mgets[0]) != len(tt.jtis) {
+				t.Errorf("MGET keys = %q, want one key per token", backend.mgets[0])
+			}
+		})
+	}
+}
+
+func TestRevocationStore_Revoke(t *testing.T) {
+	backend := &fakeRevocationStorage{keys: make(map[string]time.Duration)}
+	s := NewRevocationStore(backend)
+
+	_ = s.Revoke(context.Background(), "expired", time.Now().Add(-time.Minute))
+	_ = s.Revoke(context.Background(), "active", time.Now().Add(time.Hour))
+
+	if _, ok := backend.keys[revokedKeyPrefix+"expired"]; ok {
+		t.Error("an expired token is recorded as revoked, want it ignored")
+	}
+
+	// the revocation is kept until the token expires.
+	if ttl := backend.keys[revokedKeyPrefix+"active"]; ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("revocation ttl = %v, want about 1h", ttl)
+	}
+
+	for jti, want := range map[string]bool{"active": true, "expired": false} {
+		if got, err := s.IsRevoked(context.Background(), jti); err != nil || got != want {
+			t.Errorf("IsRevoked(%s) = %v, %v, want %v", jti, got, err, want)
+		}
+	}
+}
+
+func TestRevocationStore_RedisDown(t *testing.T) {
+	backend := &fakeRevocationStorage{keys: make(map[string]time.Duration), err: storage.ErrRedisIsDown}
+	s := NewRevocationStore(backend)
+
+	// the error is reported rather than taken as "not revoked", the callers decide how to degrade.
+	if revoked, err := s.IsRevoked(context.Background(), "jti"); !errors.Is(err, storage.ErrRedisIsDown) || revoked {
+		t.Errorf("IsRevoked() = %v, %v, want false, %v", revoked, err, storage.ErrRedisIsDown)
+	}
+
+	if _, err := s.Revoked(context.Background(), []string{"a", "b"}); !errors.Is(err, storage.ErrRedisIsDown) {
+		t.Errorf("Revoked() error = %v, want %v", err, storage.ErrRedisIsDown)
+	}
+}
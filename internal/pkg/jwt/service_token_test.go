@@ -0,0 +1,102 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gojwt "github.com/dgrijalva/jwt-go/v4"
+)
+
+func TestServiceTokenSigner_Sign(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	s := NewServiceTokenSigner(key, "iam-apiserver", 5*time.Minute)
+
+	signed, expire, err := s.Sign("billing", "iam.authz.marmotedu.com", "authz")
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	token, err := gojwt.Parse(signed, func(token *gojwt.Token) (interface{}, error) {
+		if token.Header["kid"] != KeyID(&key.PublicKey) {
+			t.Errorf("kid = %v, want %s", token.Header["kid"], KeyID(&key.PublicKey))
+		}
+
+		return &key.PublicKey, nil
+	}, gojwt.WithAudience("iam.authz.marmotedu.com"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if token.Method != gojwt.SigningMethodRS256 {
+		t.Errorf("signing method = %v, want RS256", token.Method.Alg())
+	}
+
+	claims := token.Claims.(gojwt.MapClaims)
+	if claims["sub"] != "billing" || claims[ScopeClaim] != "authz" || claims["iss"] != "iam-apiserver" {
+		t.Errorf("claims = %v, want sub billing, scope authz and iss iam-apiserver", claims)
+	}
+
+	if got := int64(claims["exp"].(float64)); got != expire.Unix() {
+		t.Errorf("exp = %d, want %d", got, expire.Unix())
+	}
+
+	// the token is rejected by the other keys.
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if _, err := gojwt.Parse(signed, func(*gojwt.Token) (interface{}, error) {
+		return &other.PublicKey, nil
+	}, gojwt.WithAudience("iam.authz.marmotedu.com")); err == nil {
+		t.Error("Parse() with another key succeeded")
+	}
+}
+
+func TestLoadRSAKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	pub, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+
+	dir := t.TempDir()
+	privateFile := filepath.Join(dir, "service.key")
+	publicFile := filepath.Join(dir, "service.pub")
+
+	_ = os.WriteFile(privateFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}), 0o600)
+	_ = os.WriteFile(publicFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}), 0o600)
+
+	private, err := LoadRSAPrivateKey(privateFile)
+	if err != nil {
+		t.Fatalf("LoadRSAPrivateKey() error = %v", err)
+	}
+
+	public, err := LoadRSAPublicKey(publicFile)
+	if err != nil {
+		t.Fatalf("LoadRSAPublicKey() error = %v", err)
+	}
+
+	// the kid of the signer matches the one of the registered public key.
+	if KeyID(&private.PublicKey) != KeyID(public) {
+		t.Errorf("KeyID() of the loaded keys = %s, %s, want equal", KeyID(&private.PublicKey), KeyID(public))
+	}
+
+	if _, err := LoadRSAPublicKey(privateFile); err == nil {
+		t.Error("LoadRSAPublicKey(private key) succeeded")
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// accessLogUnmatchedRoute is the route logged for the requests without route, e.g. 404.
+const accessLogUnmatchedRoute = "unmatched"
+
+// AccessLogConfig configures the AccessLog middleware.
+type AccessLogConfig struct {
+	// SkipPaths are the paths of the requests which are never logged, e.g. /healthz.
+	SkipPaths []string
+	// SampleRate is the fraction of the 2xx responses logged, between 0 and 1. The responses with a
+	// status of 400 or more are always logged.
+	SampleRate float64
+	// Sampler, if set, samples the 2xx responses instead of SampleRate, at a rate which can be changed
+	// while the middleware serves, e.g. on a configuration reload.
+	Sampler *Sampler
+}
+
+// Sampler samples the 2xx responses logged by AccessLog, its rate can be changed concurrently.
+type Sampler struct {
+	// bits are the bits of the float64 rate.
+	bits uint64
+}
+
+// NewSampler creates a Sampler of the given rate, between 0 and 1.
+func NewSampler(rate float64) *Sampler {
+	s := &Sampler{}
+	s.SetRate(rate)
+
+	return s
+}
+
+// SetRate changes the fraction of the responses sampled, between 0 and 1.
+func (s *Sampler) SetRate(rate float64) {
+	atomic.StoreUint64(&s.bits, math.Float64bits(rate))
+}
+
+// Rate returns the fraction of the responses sampled.
+func (s *Sampler) Rate() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&s.bits))
+}
+
+// AccessLog is a middleware function that logs one structured entry per request through pkg/log, with
+// the request id, the method, the route matched, the status, the latency, the size of the response, the
+// authenticated user and the client ip. The entries are encoded by the log package, in json or console
+// format.
+func AccessLog(config AccessLogConfig) gin.HandlerFunc {
+	sampler := config.Sampler
+	if sampler == nil {
+		sampler = NewSampler(config.SampleRate)
+	}
+
+	skip := make(map[string]struct{}, len(config.SkipPaths))
+	for _, path := range config.SkipPaths {
+		skip[path] = struct{}{}
+	}
+
+	return func(c *gin.Context) {
+		if _, ok := skip[c.Request.URL.Path]; ok {
+			c.Next()
+
+			return
+		}
+
+		start := time.Now()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status >= http.StatusOK && status < http.StatusMultipleChoices && !sampled(sampler.Rate()) {
+			return
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = accessLogUnmatchedRoute
+		}
+
+		// the size is -1 if nothing is written.
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+
+		fields := []log.Field{
+			log.String(log.KeyRequestID, c.GetString(XRequestIDKey)),
+			log.String("method", c.Request.Method),
+			log.String("route", route),
+			log.Int("status", status),
+			log.Duration("latency", time.Since(start)),
+			log.Int("bytes", size),
+			log.String("clientIP", c.ClientIP()),
+		}
+
+		if username := c.GetString(UsernameKey); username != "" {
+			fields = append(fields, log.String(log.KeyUsername, username))
+		}
+
+		log.Info("HTTP request", fields...)
+	}
+}
+
+func sampled(rate float64) bool {
+	return rate >= 1 || (rate > 0 && rand.Float64() < rate) // nolint: gosec
+}
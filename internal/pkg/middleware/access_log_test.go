@@ -0,0 +1,185 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/component-base/pkg/json"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// captureLog redirects the logs to a file in json, and returns the function reading the entries logged.
+func captureLog(t *testing.T) func() []map[string]interface{} {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "access.log")
+
+	opts := log.NewOptions()
+	opts.Format = "json"
+	opts.OutputPaths = []string{path}
+	log.Init(opts)
+
+	t.Cleanup(func() { log.Init(log.NewOptions()) })
+
+	return func() []map[string]interface{} {
+		log.Flush()
+
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		defer file.Close()
+
+		var entries []map[string]interface{}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var entry map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				t.Fatalf("Unmarshal(%s) error = %v", scanner.Text(), err)
+			}
+
+			entries = append(entries, entry)
+		}
+
+		return entries
+	}
+}
+
+func accessLogEngine(config AccessLogConfig) *gin.Engine {
+	engine := gin.New()
+	engine.Use(RequestID(), Context(), AccessLog(config))
+	engine.GET("/healthz", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	engine.GET("/v1/users/:name", func(c *gin.Context) {
+		c.Set(UsernameKey, "admin")
+		c.String(http.StatusOK, c.Param("name"))
+	})
+	engine.GET("/v1/secrets/:name", func(c *gin.Context) {
+		c.AbortWithStatus(http.StatusForbidden)
+	})
+
+	return engine
+}
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     AccessLogConfig
+		path       string
+		wantLogged bool
+		wantFields map[string]interface{}
+	}{
+		{
+			name:       "authenticated request",
+			config:     AccessLogConfig{SampleRate: 1},
+			path:       "/v1/users/colin",
+			wantLogged: true,
+			wantFields: map[string]interface{}{
+				"requestID": "request-1",
+				"method":    http.MethodGet,
+				"route":     "/v1/users/:name",
+				"status":    float64(http.StatusOK),
+				"bytes":     float64(len("colin")),
+				"username":  "admin",
+				"clientIP":  "192.0.2.1",
+			},
+		},
+		{
+			name:       "error always logged",
+			config:     AccessLogConfig{SampleRate: 0},
+			path:       "/v1/secrets/colin",
+			wantLogged: true,
+			wantFields: map[string]interface{}{
+				"route":  "/v1/secrets/:name",
+				"status": float64(http.StatusForbidden),
+				"bytes":  float64(0),
+			},
+		},
+		{
+			name:       "unmatched route",
+			config:     AccessLogConfig{SampleRate: 0},
+			path:       "/v1/unknown",
+			wantLogged: true,
+			wantFields: map[string]interface{}{
+				"route":  accessLogUnmatchedRoute,
+				"status": float64(http.StatusNotFound),
+			},
+		},
+		{
+			name:   "2xx sampled out",
+			config: AccessLogConfig{SampleRate: 0},
+			path:   "/v1/users/colin",
+		},
+		{
+			name:   "skipped path",
+			config: AccessLogConfig{SampleRate: 1, SkipPaths: []string{"/healthz"}},
+			path:   "/healthz",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := captureLog(t)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set(XRequestIDKey, "request-1")
+			req.RemoteAddr = "192.0.2.1:1234"
+
+			accessLogEngine(tt.config).ServeHTTP(httptest.NewRecorder(), req)
+
+			logged := entries()
+			if !tt.wantLogged {
+				if len(logged) != 0 {
+					t.Fatalf("logged %v, want nothing", logged)
+				}
+
+				return
+			}
+
+			if len(logged) != 1 {
+				t.Fatalf("logged %d entries, want 1: %v", len(logged), logged)
+			}
+
+			entry := logged[0]
+			for key, want := range tt.wantFields {
+				if entry[key] != want {
+					t.Errorf("%s = %v, want %v", key, entry[key], want)
+				}
+			}
+
+			if _, ok := entry["latency"]; !ok {
+				t.Errorf("latency is not logged: %v", entry)
+			}
+
+			if _, ok := tt.wantFields["username"]; !ok && entry["username"] != nil {
+				t.Errorf("username = %v, want none", entry["username"])
+			}
+		})
+	}
+}
+
+func TestAccessLog_SamplerChanged(t *testing.T) {
+	entries := captureLog(t)
+
+	sampler := NewSampler(0)
+	engine := accessLogEngine(AccessLogConfig{SampleRate: 1, Sampler: sampler})
+
+	// the rate of the sampler is the one in effect, whatever SampleRate.
+	for _, rate := range []float64{0, 1, 0} {
+		sampler.SetRate(rate)
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/users/colin", nil))
+	}
+
+	if logged := entries(); len(logged) != 1 {
+		t.Errorf("logged %d entries, want 1, of the request sampled at rate 1: %v", len(logged), logged)
+	}
+}
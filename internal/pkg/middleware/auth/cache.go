@@ -5,6 +5,7 @@
 package auth
 
 import (
+	"crypto/rsa"
 	"fmt"
 	"time"
 
@@ -14,15 +15,20 @@ import (
 	"github.com/marmotedu/errors"
 
 	"github.com/marmotedu/iam/internal/pkg/code"
+	iamjwt "github.com/marmotedu/iam/internal/pkg/jwt"
 	"github.com/marmotedu/iam/internal/pkg/middleware"
 )
 
 // Defined errors.
 var (
-	ErrMissingKID    = errors.New("Invalid token format: missing kid field in claims")
-	ErrMissingSecret = errors.New("Can not obtain secret information from cache")
+	ErrMissingKID        = errors.New("Invalid token format: missing kid field in claims")
+	ErrMissingSecret     = errors.New("Can not obtain secret information from cache")
+	ErrUnknownServiceKey = errors.New("Service token is signed by an unregistered key")
 )
 
+// ServiceSubjectPrefix prefixes the service id in the username of the requests authenticated by a service token.
+const ServiceSubjectPrefix = "service:"
+
 // Secret contains the basic information of the secret key.
 type Secret struct {
 	Username string
@@ -33,15 +39,30 @@ type Secret struct {
 
 // CacheStrategy defines jwt bearer authentication strategy which called `cache strategy`.
 // Secrets are obtained through grpc api interface and cached in memory.
+//
+// The service tokens issued by iam-apiserver to the internal services are signed with RS256 instead of
+// a secret, they are verified by the registered service public keys.
 type CacheStrategy struct {
 	get func(kid string) (Secret, error)
+	// serviceKeys are the public keys verifying the service tokens, by kid.
+	serviceKeys map[string]*rsa.PublicKey
 }
 
 var _ middleware.AuthStrategy = &CacheStrategy{}
 
 // NewCacheStrategy create cache strategy with function which can list and cache secrets.
 func NewCacheStrategy(get func(kid string) (Secret, error)) CacheStrategy {
-	return CacheStrategy{get}
+	return CacheStrategy{get: get}
+}
+
+// WithServiceKeys returns a copy of the cache strategy which accepts the service tokens verified by keys.
+func (cache CacheStrategy) WithServiceKeys(keys ...*rsa.PublicKey) CacheStrategy {
+	cache.serviceKeys = make(map[string]*rsa.PublicKey, len(keys))
+	for _, key := range keys {
+		cache.serviceKeys[iamjwt.KeyID(key)] = key
+	}
+
+	return cache
 }
 
 // AuthFunc defines cache strategy as the gin authentication middleware.
@@ -62,25 +83,42 @@ func (cache CacheStrategy) AuthFunc() gin.HandlerFunc {
 		// Use own validation logic, see below
 		var secret Secret
 
+		// service is true if the token is a service token, which is verified without secret.
+		var service bool
+
 		claims := &jwt.MapClaims{}
 		// Verify the token
 		parsedT, err := jwt.ParseWithClaims(rawJWT, claims, func(token *jwt.Token) (interface{}, error) {
-			// Validate the alg is HMAC signature
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-
 			kid, ok := token.Header["kid"].(string)
 			if !ok {
 				return nil, ErrMissingKID
 			}
 
+			if token.Method == jwt.SigningMethodRS256 {
+				service = true
+
+				return cache.serviceKey(kid)
+			}
+
+			// Validate the alg is HMAC signature
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
+
 			var err error
 			secret, err = cache.get(kid)
 			if err != nil {
 				return nil, ErrMissingSecret
 			}
 
+			// the secret is owned by another user, it can only be used if it has been shared with the subject.
+			if sub, _ := (*claims)["sub"].(string); sub != "" && sub != secret.Username {
+				secret, err = cache.get(SharedSecretID(kid, sub))
+				if err != nil {
+					return nil, ErrMissingSecret
+				}
+			}
+
 			return []byte(secret.Key), nil
 		}, jwt.WithAudience(AuthzAudience))
 		if err != nil || !parsedT.Valid {
@@ -90,6 +128,17 @@ func (cache CacheStrategy) AuthFunc() gin.HandlerFunc {
 			return
 		}
 
+		if service {
+			sub, _ := (*claims)["sub"].(string)
+			scope, _ := (*claims)[iamjwt.ScopeClaim].(string)
+
+			c.Set(middleware.UsernameKey, ServiceSubject(sub))
+			c.Set(middleware.ServiceScopeKey, scope)
+			c.Next()
+
+			return
+		}
+
 		if KeyExpired(secret.Expires) {
 			tm := time.Unix(secret.Expires, 0).Format("2006-01-02 15:04:05")
 			core.WriteResponse(c, errors.WithCode(code.ErrExpired, "expired at: %s", tm), nil)
@@ -103,6 +152,28 @@ func (cache CacheStrategy) AuthFunc() gin.HandlerFunc {
 	}
 }
 
+// serviceKey returns the registered public key which verifies the service tokens signed by kid.
+func (cache CacheStrategy) serviceKey(kid string) (*rsa.PublicKey, error) {
+	key, ok := cache.serviceKeys[kid]
+	if !ok {
+		return nil, ErrUnknownServiceKey
+	}
+
+	return key, nil
+}
+
+// SharedSecretID returns the id under which the secret kid shared with username is cached.
+func SharedSecretID(kid, username string) string {
+	return kid + "/" + username
+}
+
+// ServiceSubject returns the username of the requests authenticated by a service token of serviceID. The
+// service id is prefixed, a service account is not taken for the user of the same name, e.g. granted the
+// policies of the user.
+func ServiceSubject(serviceID string) string {
+	return ServiceSubjectPrefix + serviceID
+}
+
 // KeyExpired checks if a key has expired, if the value of user.SessionState.Expires is 0, it will be ignored.
 func KeyExpired(expires int64) bool {
 	if expires >= 1 {
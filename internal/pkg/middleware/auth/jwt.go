@@ -5,28 +5,347 @@
 package auth
 
 import (
+	"net/http"
+	"strings"
+	"time"
+
 	ginjwt "github.com/appleboy/gin-jwt/v2"
+	jwt "github.com/dgrijalva/jwt-go/v4"
 	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	uuid "github.com/satori/go.uuid"
 
+	iamjwt "github.com/marmotedu/iam/internal/pkg/jwt"
 	"github.com/marmotedu/iam/internal/pkg/middleware"
+	"github.com/marmotedu/iam/pkg/log"
 )
 
 // AuthzAudience defines the value of jwt audience field.
 const AuthzAudience = "iam.authz.marmotedu.com"
 
+// ErrRevokedToken indicates the token has been revoked by a logout or a refresh.
+var ErrRevokedToken = errors.New("token is revoked")
+
+// ErrRevocationUnavailable indicates the revocations of the tokens can not be checked, e.g. redis is down.
+var ErrRevocationUnavailable = errors.New("token revocation can not be checked")
+
+// RevocationCheckFailures counts the tokens rejected because their revocation could not be checked, the
+// servers tracking the sessions register it.
+var RevocationCheckFailures = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "iam",
+	Name:      "jwt_revocation_check_failures_total",
+	Help:      "Number of jwt tokens rejected because their revocation could not be checked.",
+})
+
 // JWTStrategy defines jwt bearer authentication strategy.
 type JWTStrategy struct {
 	ginjwt.GinJWTMiddleware
+	// keys signs and verifies the tokens instead of the static key of GinJWTMiddleware if set.
+	keys *iamjwt.KeyRotator
+	// sessions records the tokens signed by keys and revokes them on logout if set.
+	sessions SessionTracker
+}
+
+// SessionTracker records the tokens issued by a JWTStrategy, which are identified by their jti claim.
+type SessionTracker interface {
+	// Issued records the token jti issued to username on login or refresh.
+	Issued(c *gin.Context, username string, jti string, issuedAt time.Time, expiresAt time.Time)
+	// Revoke revokes the token jti until it expires at expiresAt.
+	Revoke(c *gin.Context, jti string, expiresAt time.Time) error
+	// IsRevoked returns whether the token jti has been revoked.
+	IsRevoked(c *gin.Context, jti string) (bool, error)
 }
 
 var _ middleware.AuthStrategy = &JWTStrategy{}
 
 // NewJWTStrategy create jwt bearer strategy with GinJWTMiddleware.
 func NewJWTStrategy(gjwt ginjwt.GinJWTMiddleware) JWTStrategy {
-	return JWTStrategy{gjwt}
+	return JWTStrategy{GinJWTMiddleware: gjwt}
+}
+
+// NewRotatingJWTStrategy create jwt bearer strategy with GinJWTMiddleware whose tokens are signed by
+// the current key of keys and verified by the key identified by their kid header.
+func NewRotatingJWTStrategy(gjwt ginjwt.GinJWTMiddleware, keys *iamjwt.KeyRotator) JWTStrategy {
+	return JWTStrategy{GinJWTMiddleware: gjwt, keys: keys}
+}
+
+// WithSessions returns a copy of the rotating jwt strategy which records the tokens it issues in
+// sessions, revokes them on logout and rejects the revoked tokens.
+func (j JWTStrategy) WithSessions(sessions SessionTracker) JWTStrategy {
+	j.sessions = sessions
+
+	return j
 }
 
 // AuthFunc defines jwt bearer strategy as the gin authentication middleware.
 func (j JWTStrategy) AuthFunc() gin.HandlerFunc {
-	return j.MiddlewareFunc()
+	if j.keys == nil {
+		return j.MiddlewareFunc()
+	}
+
+	return func(c *gin.Context) {
+		token, err := j.parseToken(c)
+		if err != nil {
+			j.unauthorized(c, http.StatusUnauthorized, err)
+
+			return
+		}
+
+		claims := ginjwt.MapClaims{}
+		for key, value := range token.Claims.(jwt.MapClaims) {
+			claims[key] = value
+		}
+
+		exp, ok := claims["exp"].(float64)
+		if !ok {
+			j.unauthorized(c, http.StatusBadRequest, ginjwt.ErrMissingExpField)
+
+			return
+		}
+
+		if int64(exp) < j.TimeFunc().Unix() {
+			j.unauthorized(c, http.StatusUnauthorized, ginjwt.ErrExpiredToken)
+
+			return
+		}
+
+		if err := j.checkRevoked(c, claims["jti"]); err != nil {
+			j.unauthorized(c, revokedStatus(err), err)
+
+			return
+		}
+
+		c.Set("JWT_PAYLOAD", claims)
+		identity := j.IdentityHandler(c)
+		if identity != nil {
+			c.Set(j.IdentityKey, identity)
+		}
+
+		if !j.Authorizator(identity, c) {
+			j.unauthorized(c, http.StatusForbidden, ginjwt.ErrForbidden)
+
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// LoginHandler can be used by clients to get a jwt token.
+func (j JWTStrategy) LoginHandler(c *gin.Context) {
+	if j.keys == nil {
+		j.GinJWTMiddleware.LoginHandler(c)
+
+		return
+	}
+
+	data, err := j.Authenticator(c)
+	if err != nil {
+		j.unauthorized(c, http.StatusUnauthorized, err)
+
+		return
+	}
+
+	claims := jwt.MapClaims{}
+	if j.PayloadFunc != nil {
+		for key, value := range j.PayloadFunc(data) {
+			claims[key] = value
+		}
+	}
+
+	j.respondToken(c, claims, j.LoginResponse)
+}
+
+// LogoutHandler can be used by clients to remove the jwt cookie, the token is revoked as well if the
+// sessions are tracked.
+func (j JWTStrategy) LogoutHandler(c *gin.Context) {
+	if j.keys != nil {
+		// an invalid or expired token can not be used anyway.
+		if token, err := j.parseToken(c); err == nil {
+			claims, _ := token.Claims.(jwt.MapClaims)
+			j.revoke(c, claims)
+		}
+	}
+
+	j.GinJWTMiddleware.LogoutHandler(c)
+}
+
+// RefreshHandler can be used to refresh a token, the token still needs to be valid on refresh. The
+// refreshed token is revoked once the new one is issued, so that it can not be refreshed again.
+func (j JWTStrategy) RefreshHandler(c *gin.Context) {
+	if j.keys == nil {
+		j.GinJWTMiddleware.RefreshHandler(c)
+
+		return
+	}
+
+	// an expired token can still be refreshed within MaxRefresh.
+	token, err := j.parseToken(c)
+	if err != nil && (token == nil || !isExpiredError(err)) {
+		j.unauthorized(c, http.StatusUnauthorized, err)
+
+		return
+	}
+
+	claims, _ := token.Claims.(jwt.MapClaims)
+	origIat, ok := claims["orig_iat"].(float64)
+	if !ok || int64(origIat) < j.TimeFunc().Add(-j.MaxRefresh).Unix() {
+		j.unauthorized(c, http.StatusUnauthorized, ginjwt.ErrExpiredToken)
+
+		return
+	}
+
+	if err := j.checkRevoked(c, claims["jti"]); err != nil {
+		j.unauthorized(c, revokedStatus(err), err)
+
+		return
+	}
+
+	newClaims := jwt.MapClaims{}
+	for key, value := range claims {
+		newClaims[key] = value
+	}
+
+	if j.respondToken(c, newClaims, j.RefreshResponse) {
+		j.revoke(c, claims)
+	}
+}
+
+// respondToken signs the claims by the current key and sends the token by respond, it returns false
+// if the token could not be signed.
+func (j JWTStrategy) respondToken(
+	c *gin.Context,
+	claims jwt.MapClaims,
+	respond func(c *gin.Context, code int, token string, expire time.Time),
+) bool {
+	now := j.TimeFunc()
+	expire := now.Add(j.Timeout)
+	jti := uuid.Must(uuid.NewV4()).String()
+	claims["exp"] = expire.Unix()
+	claims["iat"] = now.Unix()
+	claims["jti"] = jti
+	claims["orig_iat"] = now.Unix()
+
+	tokenString, err := j.keys.Sign(claims)
+	if err != nil {
+		j.unauthorized(c, http.StatusUnauthorized, ginjwt.ErrFailedTokenCreation)
+
+		return false
+	}
+
+	if j.sessions != nil {
+		username, _ := claims[j.IdentityKey].(string)
+		j.sessions.Issued(c, username, jti, now, expire)
+	}
+
+	if j.SendCookie {
+		if j.CookieSameSite != 0 {
+			c.SetSameSite(j.CookieSameSite)
+		}
+
+		c.SetCookie(j.CookieName, tokenString, int(j.CookieMaxAge/time.Second), "/",
+			j.CookieDomain, j.SecureCookie, j.CookieHTTPOnly)
+	}
+
+	respond(c, http.StatusOK, tokenString, expire)
+
+	return true
+}
+
+// revoke revokes the token of the claims until it expires, a failure is only logged.
+func (j JWTStrategy) revoke(c *gin.Context, claims jwt.MapClaims) {
+	jti, _ := claims["jti"].(string)
+	if j.sessions == nil || jti == "" {
+		return
+	}
+
+	exp, _ := claims["exp"].(float64)
+	if err := j.sessions.Revoke(c, jti, time.Unix(int64(exp), 0)); err != nil {
+		log.L(c).Errorf("revoke token %s failed: %s", jti, err.Error())
+	}
+}
+
+// checkRevoked returns ErrRevokedToken if the token jti has been revoked. The token is rejected with
+// ErrRevocationUnavailable if the revocations can not be checked, as a revoked token must not be
+// accepted while redis is down.
+func (j JWTStrategy) checkRevoked(c *gin.Context, jti interface{}) error {
+	id, _ := jti.(string)
+	if j.sessions == nil || id == "" {
+		return nil
+	}
+
+	revoked, err := j.sessions.IsRevoked(c, id)
+	if err != nil {
+		log.L(c).Errorf("check whether token %s is revoked failed: %s", id, err.Error())
+		RevocationCheckFailures.Inc()
+
+		return ErrRevocationUnavailable
+	}
+
+	if revoked {
+		return ErrRevokedToken
+	}
+
+	return nil
+}
+
+// revokedStatus returns the http status of the error returned by checkRevoked.
+func revokedStatus(err error) int {
+	if errors.Is(err, ErrRevocationUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusUnauthorized
+}
+
+// parseToken parses the token looked up according to TokenLookup, the token is verified by the
+// key identified by its kid header.
+func (j JWTStrategy) parseToken(c *gin.Context) (*jwt.Token, error) {
+	var token string
+
+	for _, method := range strings.Split(j.TokenLookup, ",") {
+		parts := strings.SplitN(strings.TrimSpace(method), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		name := strings.TrimSpace(parts[1])
+		switch strings.TrimSpace(parts[0]) {
+		case "header":
+			auth := strings.SplitN(c.Request.Header.Get(name), " ", 2)
+			if len(auth) == 2 && auth[0] == j.TokenHeadName {
+				token = auth[1]
+			}
+		case "query":
+			token = c.Query(name)
+		case "cookie":
+			token, _ = c.Cookie(name)
+		case "param":
+			token = c.Param(name)
+		}
+
+		if token != "" {
+			break
+		}
+	}
+
+	if token == "" {
+		return nil, ginjwt.ErrEmptyAuthHeader
+	}
+
+	return jwt.Parse(token, j.keys.Keyfunc, jwt.WithoutAudienceValidation())
+}
+
+func (j JWTStrategy) unauthorized(c *gin.Context, code int, err error) {
+	c.Header("WWW-Authenticate", "JWT realm="+j.Realm)
+	j.Unauthorized(c, code, j.HTTPStatusMessageFunc(err, c))
+	c.Abort()
+}
+
+// isExpiredError returns whether the token is only rejected because it expired.
+func isExpiredError(err error) bool {
+	var expired *jwt.TokenExpiredError
+
+	return errors.As(err, &expired)
 }
@@ -0,0 +1,116 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ginjwt "github.com/appleboy/gin-jwt/v2"
+	jwt "github.com/dgrijalva/jwt-go/v4"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	iamjwt "github.com/marmotedu/iam/internal/pkg/jwt"
+)
+
+type fakeSessions struct {
+	revoked map[string]bool
+	err     error
+}
+
+func (s *fakeSessions) Issued(c *gin.Context, username string, jti string, issuedAt time.Time, expiresAt time.Time) {
+}
+
+func (s *fakeSessions) Revoke(c *gin.Context, jti string, expiresAt time.Time) error {
+	s.revoked[jti] = true
+
+	return nil
+}
+
+func (s *fakeSessions) IsRevoked(c *gin.Context, jti string) (bool, error) {
+	return s.revoked[jti], s.err
+}
+
+func TestJWTStrategy_Revocation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	keys, err := iamjwt.NewKeyRotator(iamjwt.AlgorithmHS256, []byte("iam-secret"), 0, 1)
+	if err != nil {
+		t.Fatalf("NewKeyRotator() error = %v", err)
+	}
+
+	gjwt, err := ginjwt.New(&ginjwt.GinJWTMiddleware{
+		Realm:         "iam jwt",
+		Key:           []byte("iam-secret"),
+		Timeout:       time.Hour,
+		MaxRefresh:    time.Hour,
+		IdentityKey:   "username",
+		TokenLookup:   "header: Authorization",
+		TokenHeadName: "Bearer",
+		TimeFunc:      time.Now,
+		RefreshResponse: func(c *gin.Context, code int, token string, expire time.Time) {
+			c.String(code, token)
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	now := time.Now()
+	token, err := keys.Sign(jwt.MapClaims{
+		"username": "colin",
+		"jti":      "jti-1",
+		"exp":      now.Add(time.Hour).Unix(),
+		"orig_iat": now.Unix(),
+	})
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		revoked     bool
+		err         error
+		wantCode    int
+		wantRevoked bool
+		wantFailure float64
+	}{
+		{name: "refreshed", wantCode: http.StatusOK, wantRevoked: true},
+		{name: "revoked", revoked: true, wantCode: http.StatusUnauthorized, wantRevoked: true},
+		{name: "redis down", err: errors.New("redis is down"), wantCode: http.StatusServiceUnavailable, wantFailure: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := &fakeSessions{revoked: map[string]bool{"jti-1": tt.revoked}, err: tt.err}
+			strategy := NewRotatingJWTStrategy(*gjwt, keys).WithSessions(sessions)
+			failures := testutil.ToFloat64(RevocationCheckFailures)
+
+			r := gin.New()
+			r.POST("/refresh", strategy.RefreshHandler)
+
+			req := httptest.NewRequest(http.MethodPost, "/refresh", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("code = %d, want %d, body %s", w.Code, tt.wantCode, w.Body.String())
+			}
+
+			if sessions.revoked["jti-1"] != tt.wantRevoked {
+				t.Errorf("refreshed token revoked = %v, want %v", sessions.revoked["jti-1"], tt.wantRevoked)
+			}
+
+			if got := testutil.ToFloat64(RevocationCheckFailures) - failures; got != tt.wantFailure {
+				t.Errorf("RevocationCheckFailures increased by %v, want %v", got, tt.wantFailure)
+			}
+		})
+	}
+}
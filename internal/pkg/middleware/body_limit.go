@@ -0,0 +1,101 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+// maxBytesErrorMessage is the message of the error returned by http.MaxBytesReader over its limit.
+const maxBytesErrorMessage = "http: request body too large"
+
+// ErrRequestBodyTooLarge is returned by the request bodies read over their limit.
+var ErrRequestBodyTooLarge = errors.New("request body too large")
+
+// BodyLimit is a middleware function that limits the size of the request bodies to limit bytes with
+// http.MaxBytesReader, zero means no limit. It overrides the limit of the BodyLimit installed before it,
+// the routes which legitimately receive larger bodies, e.g. the batch ones, raise the limit of the server
+// this way.
+// The request bodies are limited whatever their encoding, chunked and multipart included, the handlers
+// map their binding errors to a 413 with BindError once the limit is exceeded.
+func BodyLimit(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+
+			return
+		}
+
+		body, ok := c.Request.Body.(*limitedBody)
+		if !ok {
+			body = &limitedBody{original: c.Request.Body}
+			c.Request.Body = body
+		}
+
+		body.setLimit(c.Writer, limit, c.Request.ContentLength)
+		c.Next()
+	}
+}
+
+// BindError returns the coded error of the failed binding of the request of c, ErrRequestBodyTooLarge
+// if its body exceeded the limit set by BodyLimit, ErrBind otherwise.
+func BindError(c *gin.Context, err error) error {
+	if body, ok := c.Request.Body.(*limitedBody); ok && body.exceeded {
+		return errors.WithCode(code.ErrRequestBodyTooLarge, "request body exceeds %d bytes", body.limit)
+	}
+
+	return errors.WithCode(code.ErrBind, err.Error())
+}
+
+// limitedBody is a request body limited by http.MaxBytesReader, which records if the limit is exceeded.
+type limitedBody struct {
+	original io.ReadCloser
+	reader   io.ReadCloser
+	// limit is the maximum size of the body, zero means no limit.
+	limit int64
+	// tooLarge is set if the Content-Length of the request is over limit, the body is not read then.
+	tooLarge bool
+	exceeded bool
+}
+
+// setLimit replaces the limit of the body, before it is read.
+func (b *limitedBody) setLimit(w http.ResponseWriter, limit int64, contentLength int64) {
+	b.limit = limit
+	b.reader = b.original
+	b.tooLarge = false
+
+	if limit > 0 {
+		b.reader = http.MaxBytesReader(w, b.original, limit)
+		b.tooLarge = contentLength > limit
+	}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.tooLarge {
+		b.exceeded = true
+
+		return 0, ErrRequestBodyTooLarge
+	}
+
+	n, err := b.reader.Read(p)
+	// the error of http.MaxBytesReader is not typed before go 1.19.
+	if err != nil && err.Error() == maxBytesErrorMessage {
+		b.exceeded = true
+
+		return n, ErrRequestBodyTooLarge
+	}
+
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.original.Close()
+}
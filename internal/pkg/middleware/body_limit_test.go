@@ -0,0 +1,179 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/component-base/pkg/core"
+	"github.com/marmotedu/component-base/pkg/json"
+
+	"github.com/marmotedu/iam/internal/pkg/code"
+)
+
+// bodyLimitEngine returns an engine limiting the request bodies to limit bytes, except the /batch route
+// which accepts batchLimit bytes.
+func bodyLimitEngine(limit, batchLimit int64) *gin.Engine {
+	bind := func(c *gin.Context) {
+		var r struct {
+			Name  string   `json:"name"  form:"name"`
+			Items []string `json:"items" form:"items"`
+		}
+		if err := c.ShouldBind(&r); err != nil {
+			core.WriteResponse(c, BindError(c, err), nil)
+
+			return
+		}
+
+		core.WriteResponse(c, nil, r)
+	}
+
+	engine := gin.New()
+	engine.Use(BodyLimit(limit))
+	engine.POST("/objects", bind)
+	engine.POST("/batch", BodyLimit(batchLimit), bind)
+
+	return engine
+}
+
+func jsonBody(size int) string {
+	return `{"name":"` + strings.Repeat("a", size) + `"}`
+}
+
+func multipartBody(size int) (string, string) {
+	var buf bytes.Buffer
+
+	w := multipart.NewWriter(&buf)
+	_ = w.WriteField("name", strings.Repeat("a", size))
+	_ = w.Close()
+
+	return buf.String(), w.FormDataContentType()
+}
+
+func TestBodyLimit(t *testing.T) {
+	small, smallType := multipartBody(100)
+	large, largeType := multipartBody(2000)
+
+	tests := []struct {
+		name        string
+		path        string
+		body        string
+		contentType string
+		chunked     bool
+		wantStatus  int
+	}{
+		{
+			name:        "json under the limit",
+			path:        "/objects",
+			body:        jsonBody(100),
+			contentType: "application/json",
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "json over the limit",
+			path:        "/objects",
+			body:        jsonBody(2000),
+			contentType: "application/json",
+			wantStatus:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "chunked json over the limit",
+			path:        "/objects",
+			body:        jsonBody(2000),
+			contentType: "application/json",
+			chunked:     true,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "multipart under the limit",
+			path:        "/objects",
+			body:        small,
+			contentType: smallType,
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "multipart over the limit",
+			path:        "/objects",
+			body:        large,
+			contentType: largeType,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "chunked multipart over the limit",
+			path:        "/objects",
+			body:        large,
+			contentType: largeType,
+			chunked:     true,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "route override",
+			path:        "/batch",
+			body:        jsonBody(2000),
+			contentType: "application/json",
+			chunked:     true,
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "over the route override",
+			path:        "/batch",
+			body:        jsonBody(5000),
+			contentType: "application/json",
+			wantStatus:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "malformed json",
+			path:        "/objects",
+			body:        `{"name":`,
+			contentType: "application/json",
+			wantStatus:  http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.chunked {
+				// hides the length of the body, the request is sent with the chunked encoding.
+				body = ioutil.NopCloser(body)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, body)
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+
+			w := httptest.NewRecorder()
+			bodyLimitEngine(1024, 4096).ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+
+			var rsp core.ErrResponse
+			_ = json.Unmarshal(w.Body.Bytes(), &rsp)
+
+			wantCode := 0
+			switch tt.wantStatus {
+			case http.StatusRequestEntityTooLarge:
+				wantCode = code.ErrRequestBodyTooLarge
+			case http.StatusBadRequest:
+				wantCode = code.ErrBind
+			}
+
+			if rsp.Code != wantCode {
+				t.Errorf("code = %d, want %d", rsp.Code, wantCode)
+			}
+		})
+	}
+}
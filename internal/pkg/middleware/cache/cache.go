@@ -0,0 +1,103 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package cache implements a gin middleware which caches the responses of idempotent GET endpoints.
+package cache
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Entry is a cached response.
+type Entry struct {
+	ContentType string    `json:"contentType"`
+	Body        []byte    `json:"body"`
+	StoredAt    time.Time `json:"storedAt"`
+}
+
+// CacheStore stores the cached responses, its methods must be safe for concurrent use. A store which
+// fails is a cache miss, the errors are logged by the store.
+type CacheStore interface {
+	// Get returns the entry of key, false if it is missing or expired.
+	Get(ctx context.Context, key string) (*Entry, bool)
+	// Set stores the entry of key for ttl.
+	Set(ctx context.Context, key string, entry *Entry, ttl time.Duration)
+	// Delete deletes the entry of key.
+	Delete(ctx context.Context, key string)
+	// DeletePrefix deletes the entries whose key starts with prefix.
+	DeletePrefix(ctx context.Context, prefix string)
+}
+
+// ResponseCache returns a gin middleware which caches the successful responses of the GET requests
+// for ttl. The requests are cached by the key returned by keyFunc, which must identify everything the
+// response depends on, including the user, the requests whose key is empty are not cached.
+//
+// A cached response is returned with the Cache-Control max-age and Age headers. The entries must be
+// deleted from the store once the resource changes, a response computed while the resource changed
+// may still be cached until ttl elapses.
+func ResponseCache(store CacheStore, ttl time.Duration, keyFunc func(*gin.Context) string) gin.HandlerFunc {
+	maxAge := "max-age=" + strconv.Itoa(int(ttl.Seconds()))
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+
+			return
+		}
+
+		key := keyFunc(c)
+		if key == "" {
+			c.Next()
+
+			return
+		}
+
+		if entry, ok := store.Get(c, key); ok {
+			c.Header("Cache-Control", maxAge)
+			c.Header("Age", strconv.Itoa(int(time.Since(entry.StoredAt).Seconds())))
+			c.Data(http.StatusOK, entry.ContentType, entry.Body)
+			c.Abort()
+
+			return
+		}
+
+		w := &bodyWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		if c.Writer.Status() != http.StatusOK {
+			return
+		}
+
+		store.Set(c, key, &Entry{
+			ContentType: c.Writer.Header().Get("Content-Type"),
+			Body:        w.body.Bytes(),
+			StoredAt:    time.Now(),
+		}, ttl)
+	}
+}
+
+// bodyWriter records the body written to the response.
+type bodyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+
+	return w.ResponseWriter.WriteString(s)
+}
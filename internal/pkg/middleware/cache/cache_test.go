@@ -0,0 +1,178 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestResponseCache(t *testing.T) {
+	tests := []struct {
+		name string
+		// status is the status returned by the handler.
+		status  int
+		method  string
+		path    string
+		ttl     time.Duration
+		wait    time.Duration
+		wantRun int
+		wantAge bool
+	}{
+		{
+			name:    "hit",
+			status:  http.StatusOK,
+			method:  http.MethodGet,
+			path:    "/v1/policies/p1",
+			ttl:     time.Minute,
+			wantRun: 1,
+			wantAge: true,
+		},
+		{
+			name:    "expired",
+			status:  http.StatusOK,
+			method:  http.MethodGet,
+			path:    "/v1/policies/p1",
+			ttl:     10 * time.Millisecond,
+			wait:    20 * time.Millisecond,
+			wantRun: 2,
+		},
+		{
+			name:    "failed request",
+			status:  http.StatusNotFound,
+			method:  http.MethodGet,
+			path:    "/v1/policies/p1",
+			ttl:     time.Minute,
+			wantRun: 2,
+		},
+		{
+			name:    "no key",
+			status:  http.StatusOK,
+			method:  http.MethodGet,
+			path:    "/v1/policies/",
+			ttl:     time.Minute,
+			wantRun: 2,
+		},
+		{
+			name:    "not get",
+			status:  http.StatusOK,
+			method:  http.MethodPut,
+			path:    "/v1/policies/p1",
+			ttl:     time.Minute,
+			wantRun: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := 0
+
+			g := gin.New()
+			g.Handle(tt.method, "/v1/policies/*name", ResponseCache(NewLRUStore(10), tt.ttl, func(c *gin.Context) string {
+				return c.Param("name")[1:]
+			}), func(c *gin.Context) {
+				run++
+				c.JSON(tt.status, gin.H{"run": run})
+			})
+
+			var bodies []string
+			for i := 0; i < 2; i++ {
+				if i > 0 {
+					time.Sleep(tt.wait)
+				}
+
+				w := httptest.NewRecorder()
+				g.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+				if w.Code != tt.status {
+					t.Fatalf("request %d code = %d, want %d", i, w.Code, tt.status)
+				}
+
+				if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+					t.Errorf("request %d Content-Type = %q", i, ct)
+				}
+
+				bodies = append(bodies, w.Body.String())
+
+				if i == 0 {
+					continue
+				}
+
+				if got := w.Header().Get("Age") != ""; got != tt.wantAge {
+					t.Errorf("cached response = %v, want %v", got, tt.wantAge)
+				}
+
+				if tt.wantAge {
+					if got, want := w.Header().Get("Cache-Control"), "max-age="+strconv.Itoa(int(tt.ttl.Seconds())); got != want {
+						t.Errorf("Cache-Control = %q, want %q", got, want)
+					}
+				}
+			}
+
+			if run != tt.wantRun {
+				t.Errorf("handler ran %d times, want %d", run, tt.wantRun)
+			}
+
+			if tt.wantRun == 1 && bodies[0] != bodies[1] {
+				t.Errorf("cached body = %q, want %q", bodies[1], bodies[0])
+			}
+		})
+	}
+}
+
+func Test_lruStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewLRUStore(2)
+
+	for _, key := range []string{"policies/colin/p1", "policies/colin/p2", "secrets/colin/s1"} {
+		store.Set(ctx, key, &Entry{Body: []byte(key)}, time.Minute)
+		// p1 is the most recently used, p2 is evicted by s1.
+		store.Get(ctx, "policies/colin/p1")
+	}
+
+	tests := []struct {
+		name   string
+		delete func()
+		want   map[string]bool
+	}{
+		{
+			name: "evicted",
+			want: map[string]bool{"policies/colin/p1": true, "policies/colin/p2": false, "secrets/colin/s1": true},
+		},
+		{
+			name:   "delete prefix",
+			delete: func() { store.DeletePrefix(ctx, "policies/") },
+			want:   map[string]bool{"policies/colin/p1": false, "secrets/colin/s1": true},
+		},
+		{
+			name:   "delete",
+			delete: func() { store.Delete(ctx, "secrets/colin/s1") },
+			want:   map[string]bool{"secrets/colin/s1": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.delete != nil {
+				tt.delete()
+			}
+
+			for key, want := range tt.want {
+				entry, ok := store.Get(ctx, key)
+				if ok != want {
+					t.Errorf("Get(%q) found = %v, want %v", key, ok, want)
+				}
+
+				if ok && string(entry.Body) != key {
+					t.Errorf("Get(%q) = %q", key, entry.Body)
+				}
+			}
+		})
+	}
+}
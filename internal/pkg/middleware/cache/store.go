@@ -0,0 +1,155 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marmotedu/component-base/pkg/json"
+
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+// lruStore is a CacheStore in memory, which evicts the least recently used entries beyond its size.
+type lruStore struct {
+	lock  sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+type lruItem struct {
+	key     string
+	entry   *Entry
+	expires time.Time
+}
+
+// NewLRUStore returns a CacheStore in memory holding up to size entries. The entries are local to the
+// process, each instance must be invalidated.
+func NewLRUStore(size int) CacheStore {
+	return &lruStore{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (s *lruStore) Get(ctx context.Context, key string) (*Entry, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	elem, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	item, _ := elem.Value.(*lruItem)
+	if time.Now().After(item.expires) {
+		s.remove(elem)
+
+		return nil, false
+	}
+
+	s.order.MoveToFront(elem)
+
+	return item.entry, true
+}
+
+func (s *lruStore) Set(ctx context.Context, key string, entry *Entry, ttl time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	item := &lruItem{key: key, entry: entry, expires: time.Now().Add(ttl)}
+
+	if elem, ok := s.items[key]; ok {
+		elem.Value = item
+		s.order.MoveToFront(elem)
+
+		return
+	}
+
+	s.items[key] = s.order.PushFront(item)
+
+	for s.order.Len() > s.size {
+		s.remove(s.order.Back())
+	}
+}
+
+func (s *lruStore) Delete(ctx context.Context, key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if elem, ok := s.items[key]; ok {
+		s.remove(elem)
+	}
+}
+
+func (s *lruStore) DeletePrefix(ctx context.Context, prefix string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for key, elem := range s.items {
+		if strings.HasPrefix(key, prefix) {
+			s.remove(elem)
+		}
+	}
+}
+
+func (s *lruStore) remove(elem *list.Element) {
+	item, _ := s.order.Remove(elem).(*lruItem)
+	delete(s.items, item.key)
+}
+
+// redisStore is a CacheStore in redis, shared by all the instances.
+type redisStore struct {
+	store *storage.RedisCluster
+}
+
+// NewRedisStore returns a CacheStore in redis, the keys of the entries are prefixed by the KeyPrefix of
+// store.
+func NewRedisStore(store *storage.RedisCluster) CacheStore {
+	return &redisStore{store: store}
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) (*Entry, bool) {
+	value, err := s.store.GetKey(ctx, key)
+	if err != nil {
+		return nil, false
+	}
+
+	var entry Entry
+	if err := json.Unmarshal([]byte(value), &entry); err != nil {
+		log.Warnf("Failed to decode the cached response %s: %s", key, err.Error())
+
+		return nil, false
+	}
+
+	return &entry, true
+}
+
+func (s *redisStore) Set(ctx context.Context, key string, entry *Entry, ttl time.Duration) {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		log.Warnf("Failed to encode the response %s: %s", key, err.Error())
+
+		return
+	}
+
+	// the failures are logged by the storage.
+	_ = s.store.SetKey(ctx, key, string(value), ttl)
+}
+
+func (s *redisStore) Delete(ctx context.Context, key string) {
+	s.store.DeleteKey(ctx, key)
+}
+
+func (s *redisStore) DeletePrefix(ctx context.Context, prefix string) {
+	s.store.DeleteScanMatch(ctx, prefix+"*")
+}
@@ -13,6 +13,13 @@ import (
 // UsernameKey defines the key in gin context which represents the owner of the secret.
 const UsernameKey = "username"
 
+// ServiceScopeKey defines the key in gin context which represents the scope of the service token, it is
+// only set if the request is authenticated by a service token.
+const ServiceScopeKey = "serviceScope"
+
+// RouteKey defines the key in gin context which represents the route matched by the request.
+const RouteKey = "route"
+
 // Context is a middleware that injects common prefix fields to gin.Context.
 func Context() gin.HandlerFunc {
 	return func(c *gin.Context) {
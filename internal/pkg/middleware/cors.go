@@ -5,27 +5,12 @@
 package middleware
 
 import (
-	"time"
-
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-)
 
-const (
-	maxAge = 12
+	"github.com/marmotedu/iam/internal/pkg/middleware/cors"
 )
 
-// Cors add cors headers.
+// Cors add cors headers, the routes can override the allowed origins with cors.AllowOrigins.
 func Cors() gin.HandlerFunc {
-	return cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"PUT", "PATCH", "GET", "POST", "OPTIONS", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type", "Accept"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		AllowOriginFunc: func(origin string) bool {
-			return origin == "https://github.com"
-		},
-		MaxAge: maxAge * time.Hour,
-	})
+	return cors.New()
 }
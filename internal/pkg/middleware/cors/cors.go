@@ -0,0 +1,167 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cors
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// AllowedOriginsKey is the key of the origins allowed by the route in the keys of the gin context,
+	// its value is a []string.
+	AllowedOriginsKey = "cors.allowedOrigins"
+
+	// policiesKey counts the AllowOrigins handlers run by the request.
+	policiesKey = "cors.policies"
+
+	maxAge = 12
+)
+
+// CORSOption configures the global cors policy.
+type CORSOption func(config *cors.Config)
+
+// WithAllowedOrigins allows the cross-origin requests from origins only, no cross-origin request is
+// allowed if origins is empty.
+func WithAllowedOrigins(origins ...string) CORSOption {
+	return func(config *cors.Config) {
+		config.AllowAllOrigins = false
+		config.AllowOrigins = origins
+		config.AllowOriginFunc = nil
+
+		// gin-contrib/cors refuses a config allowing no origin.
+		if len(origins) == 0 {
+			config.AllowOriginFunc = func(origin string) bool { return false }
+		}
+	}
+}
+
+// WithAllowCredentials sets whether the cross-origin requests can include the user credentials.
+func WithAllowCredentials(allow bool) CORSOption {
+	return func(config *cors.Config) {
+		config.AllowCredentials = allow
+	}
+}
+
+// DefaultConfig returns the global cors policy, which allows all the origins.
+func DefaultConfig() cors.Config {
+	return cors.Config{
+		AllowOrigins:     []string{"*"},
+		AllowMethods:     []string{"PUT", "PATCH", "GET", "POST", "OPTIONS", "DELETE"},
+		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type", "Accept"},
+		ExposeHeaders:    []string{"Content-Length"},
+		AllowCredentials: true,
+		AllowOriginFunc: func(origin string) bool {
+			return origin == "https://github.com"
+		},
+		MaxAge: maxAge * time.Hour,
+	}
+}
+
+// New returns the gin middleware enforcing the global cors policy, opts override DefaultConfig.
+func New(opts ...CORSOption) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		CORSFromContext(c, opts...)(c)
+	}
+}
+
+// CORSFromContext returns the cors handler of the request: the global policy, DefaultConfig
+// overridden by opts, whose allowed origins are overridden by the origins recorded in the keys of
+// c under AllowedOriginsKey if any.
+func CORSFromContext(c *gin.Context, opts ...CORSOption) gin.HandlerFunc {
+	config := DefaultConfig()
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	if origins, ok := c.Get(AllowedOriginsKey); ok {
+		origins, _ := origins.([]string)
+		WithAllowedOrigins(origins...)(&config)
+	}
+
+	return cors.New(config)
+}
+
+// ValidateOrigins returns an error if the origins can not be allowed by a route: "*" or an origin
+// without the http or https scheme.
+func ValidateOrigins(origins []string) error {
+	for _, origin := range origins {
+		if origin == "*" {
+			return errors.New("cors: the origins allowed by a route can not contain *")
+		}
+	}
+
+	config := DefaultConfig()
+	WithAllowedOrigins(origins...)(&config)
+
+	return config.Validate()
+}
+
+// AllowOrigins returns a gin middleware which overrides the origins allowed by the global policy, for
+// the route or the route group it is registered on. The origins can not contain "*", they are
+// enforced by the last AllowOrigins handler of the route, so a route-level policy overrides a
+// group-level one. AllowOrigins panics if origins are invalid.
+func AllowOrigins(origins ...string) gin.HandlerFunc {
+	if err := ValidateOrigins(origins); err != nil {
+		panic(err)
+	}
+
+	return originsPolicy(origins).handle
+}
+
+// originsPolicy is the policy of AllowOrigins.
+type originsPolicy []string
+
+// policyName is the name of the AllowOrigins handlers, in c.HandlerNames().
+var policyName string
+
+func init() {
+	policyName = nameOfFunction(originsPolicy(nil).handle)
+}
+
+func (p originsPolicy) handle(c *gin.Context) {
+	c.Set(AllowedOriginsKey, []string(p))
+
+	run := c.GetInt(policiesKey) + 1
+	c.Set(policiesKey, run)
+
+	if run < countHandlers(c, policyName) {
+		return
+	}
+
+	// the headers set by the global policy no longer apply.
+	header := c.Writer.Header()
+	for _, key := range []string{
+		"Access-Control-Allow-Origin",
+		"Access-Control-Allow-Credentials",
+		"Access-Control-Expose-Headers",
+	} {
+		header.Del(key)
+	}
+
+	CORSFromContext(c)(c)
+}
+
+// countHandlers returns the number of handlers named name in the handlers chain of the request.
+func countHandlers(c *gin.Context, name string) int {
+	var n int
+
+	for _, handler := range c.HandlerNames() {
+		if handler == name {
+			n++
+		}
+	}
+
+	return n
+}
+
+func nameOfFunction(f interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
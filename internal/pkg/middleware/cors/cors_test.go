@@ -0,0 +1,146 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	admin   = "https://admin.example.com"
+	console = "https://console.example.com"
+	other   = "https://other.example.com"
+)
+
+// newEngine returns an engine enforcing the global policy, with a group and a route overriding it.
+func newEngine() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+
+	g := gin.New()
+	g.Use(New())
+	g.GET("/public", ok)
+
+	users := g.Group("/users", AllowOrigins(admin))
+	users.GET("", ok)
+	users.GET(":name", AllowOrigins(console), ok)
+	users.DELETE(":name", AllowOrigins(), ok)
+
+	return g
+}
+
+func TestAllowOrigins(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		origin     string
+		wantStatus int
+		wantOrigin string
+	}{
+		{name: "global", method: http.MethodGet, path: "/public", origin: other, wantStatus: http.StatusOK, wantOrigin: "*"},
+		{name: "group allowed", method: http.MethodGet, path: "/users", origin: admin, wantStatus: http.StatusOK, wantOrigin: admin},
+		{name: "group denied", method: http.MethodGet, path: "/users", origin: other, wantStatus: http.StatusForbidden},
+		{name: "route allowed", method: http.MethodGet, path: "/users/colin", origin: console, wantStatus: http.StatusOK, wantOrigin: console},
+		// the route-level policy overrides the group-level policy.
+		{name: "route overrides group", method: http.MethodGet, path: "/users/colin", origin: admin, wantStatus: http.StatusForbidden},
+		{name: "route denies all", method: http.MethodDelete, path: "/users/colin", origin: admin, wantStatus: http.StatusForbidden},
+		{name: "same origin", method: http.MethodDelete, path: "/users/colin", wantStatus: http.StatusOK},
+		// the preflight requests follow the global policy.
+		{name: "preflight", method: http.MethodOptions, path: "/users", origin: other, wantStatus: http.StatusNoContent, wantOrigin: "*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			}
+
+			w := httptest.NewRecorder()
+			newEngine().ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+		})
+	}
+}
+
+func TestCORSFromContext(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []CORSOption
+		keys       []string
+		wantStatus int
+		wantOrigin string
+	}{
+		{name: "default", wantStatus: http.StatusOK, wantOrigin: "*"},
+		{name: "options", opts: []CORSOption{WithAllowedOrigins(admin)}, wantStatus: http.StatusOK, wantOrigin: admin},
+		{name: "options deny all", opts: []CORSOption{WithAllowedOrigins()}, wantStatus: http.StatusForbidden},
+		{
+			name:       "keys override options",
+			opts:       []CORSOption{WithAllowedOrigins(console)},
+			keys:       []string{admin},
+			wantStatus: http.StatusOK,
+			wantOrigin: admin,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request.Header.Set("Origin", admin)
+
+			if tt.keys != nil {
+				c.Set(AllowedOriginsKey, tt.keys)
+			}
+
+			CORSFromContext(c, tt.opts...)(c)
+			c.Writer.WriteHeaderNow()
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+		})
+	}
+}
+
+func TestValidateOrigins(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		wantErr bool
+	}{
+		{name: "none", wantErr: false},
+		{name: "valid", origins: []string{admin, console}, wantErr: false},
+		{name: "all", origins: []string{admin, "*"}, wantErr: true},
+		{name: "no scheme", origins: []string{"admin.example.com"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateOrigins(tt.origins); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateOrigins() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+/*
+Package cors implements a gin middleware which enforces the cors policy of the iam services, the
+allowed origins of a route group or of a single route can override the global policy.
+
+The global policy is installed with the other gin middlewares by the cors middleware, the routes
+and the route groups override its allowed origins with AllowOrigins:
+
+	admin := v1.Group("/users", cors.AllowOrigins("https://admin.example.com"))
+	admin.GET(":name", cors.AllowOrigins("https://admin.example.com", "https://console.example.com"), get)
+
+The allowed origins are recorded in the keys of the gin context under AllowedOriginsKey, the last
+recorded ones win and are enforced once, by the last AllowOrigins handler of the route. Since the
+handlers of a group run before the handlers of its routes, the priority order is:
+
+	route-level AllowOrigins > group-level AllowOrigins > global policy
+
+The preflight requests do not match the routes of the other methods, they are answered by the
+global policy. A cross-origin request which the route does not allow is then rejected with 403,
+without the Access-Control-Allow-Origin header, when it is sent.
+*/
+package cors // import "github.com/marmotedu/iam/internal/pkg/middleware/cors"
@@ -16,8 +16,12 @@ var ErrLimitExceeded = errors.New("Limit exceeded")
 
 // Limit drops (HTTP status 429) the request if the limit is reached.
 func Limit(maxEventsPerSec float64, maxBurstSize int) gin.HandlerFunc {
-	limiter := rate.NewLimiter(rate.Limit(maxEventsPerSec), maxBurstSize)
+	return LimitWith(rate.NewLimiter(rate.Limit(maxEventsPerSec), maxBurstSize))
+}
 
+// LimitWith drops (HTTP status 429) the request if limiter does not allow it. The limiter can be
+// tuned while serving, e.g. on a configuration reload.
+func LimitWith(limiter *rate.Limiter) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if limiter.Allow() {
 			c.Next()
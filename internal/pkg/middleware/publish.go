@@ -12,7 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/marmotedu/component-base/pkg/json"
 
-	"github.com/marmotedu/iam/internal/authzserver/load"
+	"github.com/marmotedu/iam/internal/pkg/notice"
 	"github.com/marmotedu/iam/pkg/log"
 	"github.com/marmotedu/iam/pkg/storage"
 )
@@ -39,21 +39,29 @@ func Publish() gin.HandlerFunc {
 
 		switch resource {
 		case "policies":
-			notify(c, method, load.NoticePolicyChanged)
+			var payload string
+			if name := c.Param("name"); name != "" {
+				payload = notice.PolicyPayload(c.GetString(UsernameKey), name)
+			}
+
+			notify(c, method, notice.PolicyChanged, payload)
+		// the member policies of a policy group are enforced once the group is applied.
+		case "policy-groups":
+			notify(c, method, notice.PolicyChanged, "")
 		case "secrets":
-			notify(c, method, load.NoticeSecretChanged)
+			notify(c, method, notice.SecretChanged, "")
 		default:
 		}
 	}
 }
 
-func notify(ctx context.Context, method string, command load.NotificationCommand) {
+func notify(ctx context.Context, method string, command notice.Command, payload string) {
 	switch method {
 	case "POST", "PUT", "DELETE", "PATH":
 		redisStore := &storage.RedisCluster{}
-		message, _ := json.Marshal(load.Notification{Command: command})
+		message, _ := json.Marshal(notice.Notification{Command: command, Payload: payload})
 
-		if err := redisStore.Publish(load.RedisPubSubChannel, string(message)); err != nil {
+		if err := redisStore.Publish(ctx, notice.RedisPubSubChannel, string(message)); err != nil {
 			log.L(ctx).Errorw("publish redis message failed", "error", err.Error())
 		}
 		log.L(ctx).Debugw("publish redis message", "method", method, "command", command)
@@ -0,0 +1,59 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/component-base/pkg/core"
+	"github.com/marmotedu/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// RecoveredPanics counts the panics recovered by Recovery, the servers exposing their metrics register it.
+var RecoveredPanics = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "iam",
+	Name:      "http_recovered_panics_total",
+	Help:      "Number of panics recovered while serving the http requests.",
+})
+
+// PanicAlertFunc is called with each panic recovered by Recovery, e.g. to page the on-call. It is called
+// before the response is written, so it must not block.
+type PanicAlertFunc func(c *gin.Context, err interface{})
+
+// Recovery returns a middleware that recovers from the panics of the handlers and responds 500.
+// The panics are logged by the request-scoped logger, with the request id and the stack.
+func Recovery() gin.HandlerFunc {
+	return RecoveryWithAlert(nil)
+}
+
+// RecoveryWithAlert returns a Recovery middleware which calls alert with each panic recovered, alert
+// can be nil. The panics are counted by RecoveredPanics and answered with ErrInternalServer, the
+// request id being sent in the X-Request-ID header. It recovers the panics of the middlewares installed
+// after it too.
+func RecoveryWithAlert(alert PanicAlertFunc) gin.HandlerFunc {
+	// gin only writes the broken connections to the nil writer, there is no response to log them for.
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err interface{}) {
+		log.L(c).Errorw("panic recovered", "error", fmt.Sprint(err), "stack", string(debug.Stack()))
+		RecoveredPanics.Inc()
+
+		if alert != nil {
+			alert(c, err)
+		}
+
+		requestID := c.GetString(XRequestIDKey)
+		if requestID != "" {
+			c.Header(XRequestIDKey, requestID)
+		}
+
+		core.WriteResponse(c, errors.WithCode(code.ErrInternalServer, "panic recovered: %v", err), nil)
+		c.Abort()
+	})
+}
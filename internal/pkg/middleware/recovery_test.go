@@ -0,0 +1,128 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/component-base/pkg/core"
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+func TestRecovery(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		// inMiddleware panics in a middleware installed after the recovery, rather than in the handler.
+		inMiddleware bool
+	}{
+		{
+			name:      "incoming request id",
+			requestID: "5b7a3f0c-2c5e-4c8e-9a4b-2f6d3e1a9c11",
+		},
+		{
+			name: "generated request id",
+		},
+		{
+			name:         "panic in a middleware",
+			requestID:    "0e9d8c7b-6a5f-4e3d-8c2b-1a0f9e8d7c6b",
+			inMiddleware: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "iam.log")
+			log.Init(&log.Options{
+				Level:            "info",
+				Format:           "json",
+				OutputPaths:      []string{output},
+				ErrorOutputPaths: []string{output},
+			})
+			defer log.Init(nil)
+
+			var alerted []interface{}
+
+			engine := gin.New()
+			engine.Use(RecoveryWithAlert(func(c *gin.Context, err interface{}) {
+				alerted = append(alerted, err)
+			}), RequestID(), Context())
+			if tt.inMiddleware {
+				engine.Use(func(c *gin.Context) {
+					panic("boom")
+				})
+			}
+			engine.GET("/panic", func(c *gin.Context) {
+				panic("boom")
+			})
+
+			panics := testutil.ToFloat64(RecoveredPanics)
+
+			req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+			if tt.requestID != "" {
+				req.Header.Set(XRequestIDKey, tt.requestID)
+			}
+
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			if w.Code != http.StatusInternalServerError {
+				t.Errorf("code = %d, want %d", w.Code, http.StatusInternalServerError)
+			}
+
+			var rsp core.ErrResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil || rsp.Code != code.ErrInternalServer {
+				t.Errorf("response %s, want the code %d", w.Body.String(), code.ErrInternalServer)
+			}
+
+			requestID := w.Header().Get(XRequestIDKey)
+			if requestID == "" || tt.requestID != "" && requestID != tt.requestID {
+				t.Errorf("%s = %s, want %s", XRequestIDKey, requestID, tt.requestID)
+			}
+
+			if got := testutil.ToFloat64(RecoveredPanics) - panics; got != 1 {
+				t.Errorf("%v panics counted, want 1", got)
+			}
+
+			if len(alerted) != 1 || alerted[0] != "boom" {
+				t.Errorf("alerted %v, want the recovered panic", alerted)
+			}
+
+			log.Flush()
+
+			data, err := ioutil.ReadFile(output)
+			if err != nil {
+				t.Fatalf("read the log output failed: %v", err)
+			}
+
+			var entry map[string]interface{}
+			if err := json.Unmarshal(bytes.TrimSpace(data), &entry); err != nil {
+				t.Fatalf("the log output %q is not a single json entry: %v", data, err)
+			}
+
+			if entry["message"] != "panic recovered" || entry["error"] != "boom" {
+				t.Errorf("logged %v, want the recovered panic", entry)
+			}
+
+			if entry[log.KeyRequestID] != requestID {
+				t.Errorf("logged %s = %v, want %s", log.KeyRequestID, entry[log.KeyRequestID], requestID)
+			}
+
+			if stack, _ := entry["stack"].(string); !strings.Contains(stack, "recovery_test.go") {
+				t.Errorf("logged stack %q does not contain the panicking handler", stack)
+			}
+		})
+	}
+}
@@ -27,9 +27,11 @@ func RequestID() gin.HandlerFunc {
 		if rid == "" {
 			rid = uuid.Must(uuid.NewV4()).String()
 			c.Request.Header.Set(XRequestIDKey, rid)
-			c.Set(XRequestIDKey, rid)
 		}
 
+		// the request-scoped logger reads the request id of the incoming requests too.
+		c.Set(XRequestIDKey, rid)
+
 		// Set XRequestIDKey header
 		c.Writer.Header().Set(XRequestIDKey, rid)
 		c.Next()
@@ -0,0 +1,39 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetryAfter is a middleware function that tells the clients to retry after d when the service is
+// unavailable, the Retry-After header is added to the 503 responses which do not set it.
+func RetryAfter(d time.Duration) gin.HandlerFunc {
+	seconds := strconv.Itoa(int(math.Ceil(d.Seconds())))
+
+	return func(c *gin.Context) {
+		c.Writer = &retryAfterWriter{ResponseWriter: c.Writer, seconds: seconds}
+		c.Next()
+	}
+}
+
+// retryAfterWriter sets the Retry-After header before the status of the response is written.
+type retryAfterWriter struct {
+	gin.ResponseWriter
+	seconds string
+}
+
+func (w *retryAfterWriter) WriteHeader(code int) {
+	if code == http.StatusServiceUnavailable && w.Header().Get("Retry-After") == "" {
+		w.Header().Set("Retry-After", w.seconds)
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package timeout implements a gin middleware which cancels long-running requests.
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// New returns a gin middleware which cancels the request context after timeout. If the deadline
+// is exceeded when the handlers return, a 503 response is sent, unless the handlers have already
+// written one. The long-lived requests, i.e. websocket upgrades and the requests whose path starts
+// with one of skipPaths, e.g. the pprof profiles, are not limited.
+func New(timeout time.Duration, skipPaths ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.IsWebsocket() || hasPrefix(c.Request.URL.Path, skipPaths) {
+			c.Next()
+
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		if ctx.Err() != context.DeadlineExceeded {
+			return
+		}
+
+		if c.Writer.Written() {
+			c.Abort()
+
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"code":    http.StatusServiceUnavailable,
+			"message": "Request timeout",
+		})
+	}
+}
+
+func hasPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
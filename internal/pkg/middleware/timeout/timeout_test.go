@@ -0,0 +1,102 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNew(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// sleep simulates a slow database query which fails once the request context is canceled.
+	sleep := func(d time.Duration) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			select {
+			case <-time.After(d):
+				c.String(http.StatusOK, "ok")
+			case <-c.Request.Context().Done():
+			}
+		}
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		header   http.Header
+		handler  gin.HandlerFunc
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "finished in time",
+			handler:  sleep(0),
+			wantCode: http.StatusOK,
+			wantBody: "ok",
+		},
+		{
+			name:     "timeout",
+			handler:  sleep(time.Second),
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `{"code":503,"message":"Request timeout"}`,
+		},
+		{
+			name: "response written before timeout",
+			handler: func(c *gin.Context) {
+				c.String(http.StatusOK, "partial")
+				time.Sleep(100 * time.Millisecond)
+			},
+			wantCode: http.StatusOK,
+			wantBody: "partial",
+		},
+		{
+			name:     "skipped path",
+			path:     "/debug/pprof/profile",
+			handler:  sleep(100 * time.Millisecond),
+			wantCode: http.StatusOK,
+			wantBody: "ok",
+		},
+		{
+			name:     "websocket",
+			header:   http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}},
+			handler:  sleep(100 * time.Millisecond),
+			wantCode: http.StatusOK,
+			wantBody: "ok",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := tt.path
+			if path == "" {
+				path = "/"
+			}
+
+			r := gin.New()
+			r.Use(New(50*time.Millisecond, "/debug/pprof"))
+			r.GET(path, tt.handler)
+
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("code = %d, want %d", w.Code, tt.wantCode)
+			}
+
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -28,7 +28,7 @@ func Validation() gin.HandlerFunc {
 
 					return
 				}
-			case "/v1/users/:name", "/v1/users/:name/change_password":
+			case "/v1/users/:name", "/v1/users/:name/change_password", "/v1/users/:name/tokens":
 				username := c.GetString("username")
 				if c.Request.Method == http.MethodDelete ||
 					(c.Request.Method != http.MethodDelete && username != c.Param("name")) {
@@ -37,6 +37,11 @@ func Validation() gin.HandlerFunc {
 
 					return
 				}
+			case "/v1/service-accounts", "/v1/service-accounts/:name":
+				core.WriteResponse(c, err, nil)
+				c.Abort()
+
+				return
 			default:
 			}
 		}
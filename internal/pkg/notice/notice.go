@@ -0,0 +1,50 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package notice defines the notifications published by iam-apiserver on the redis pub/sub channel
+// and consumed by iam-authz-server and the other iam-apiserver instances.
+package notice
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// RedisPubSubChannel is the redis pub/sub channel of the notifications.
+const RedisPubSubChannel = "iam.cluster.notifications"
+
+// Command defines a new notification type.
+type Command string
+
+// Define Redis pub/sub events.
+const (
+	// PolicyChanged is published when iam-apiserver changes policies, the payload is the
+	// PolicyPayload of the policy if a single one changed, empty otherwise.
+	PolicyChanged Command = "PolicyChanged"
+	SecretChanged Command = "SecretChanged"
+	// KeyRotated is published when iam-apiserver rotates its jwt signing key, the payload is the new kid.
+	KeyRotated Command = "KeyRotated"
+)
+
+// Notification is a type that encodes a message published to a pub sub channel (shared between implementations).
+type Notification struct {
+	Command       Command     `json:"command"`
+	Payload       string      `json:"payload"`
+	Signature     string      `json:"signature"`
+	SignatureAlgo crypto.Hash `json:"algorithm"`
+}
+
+// PolicyPayload returns the payload of the PolicyChanged notification of the policy name of
+// username.
+func PolicyPayload(username, name string) string {
+	return username + "/" + name
+}
+
+// Sign sign Notification with SHA256 algorithm.
+func (n *Notification) Sign() {
+	n.SignatureAlgo = crypto.SHA256
+	hash := sha256.Sum256([]byte(string(n.Command) + n.Payload))
+	n.Signature = hex.EncodeToString(hash[:])
+}
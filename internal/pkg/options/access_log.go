@@ -0,0 +1,78 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+	"github.com/marmotedu/iam/internal/pkg/server"
+)
+
+// AccessLogOptions contains configuration items related to the access log of the requests.
+type AccessLogOptions struct {
+	Enabled    bool     `json:"enabled"     mapstructure:"enabled"`
+	SkipPaths  []string `json:"skip-paths"  mapstructure:"skip-paths"`
+	SampleRate float64  `json:"sample-rate" mapstructure:"sample-rate"`
+}
+
+// NewAccessLogOptions creates a AccessLogOptions object with default parameters.
+func NewAccessLogOptions() *AccessLogOptions {
+	return &AccessLogOptions{
+		Enabled:    false,
+		SkipPaths:  []string{"/healthz", "/readyz", "/metrics"},
+		SampleRate: 1,
+	}
+}
+
+// ApplyTo applies the run options to the method receiver and returns self.
+func (o *AccessLogOptions) ApplyTo(c *server.Config) error {
+	if !o.Enabled {
+		c.AccessLog = nil
+
+		return nil
+	}
+
+	c.AccessLog = &middleware.AccessLogConfig{
+		SkipPaths:  o.SkipPaths,
+		SampleRate: o.SampleRate,
+	}
+
+	return nil
+}
+
+// Validate is used to parse and validate the parameters entered by the user at
+// the command line when the program starts.
+func (o *AccessLogOptions) Validate() []error {
+	errors := []error{}
+
+	if o.SampleRate < 0 || o.SampleRate > 1 {
+		errors = append(errors, fmt.Errorf("--access-log.sample-rate %v must be between 0 and 1", o.SampleRate))
+	}
+
+	return errors
+}
+
+// AddFlags adds flags related to the access log for a specific api server to the
+// specified FlagSet.
+func (o *AccessLogOptions) AddFlags(fs *pflag.FlagSet) {
+	if fs == nil {
+		return
+	}
+
+	fs.BoolVar(&o.Enabled, "access-log.enabled", o.Enabled, ""+
+		"Log one structured entry per request, with the request id, the method, the route, the status, "+
+		"the latency, the size of the response, the user and the client ip. The entries are encoded "+
+		"like the other logs, see --log.format.")
+
+	fs.StringSliceVar(&o.SkipPaths, "access-log.skip-paths", o.SkipPaths, ""+
+		"The paths of the requests which are not logged.")
+
+	fs.Float64Var(&o.SampleRate, "access-log.sample-rate", o.SampleRate, ""+
+		"The fraction of the 2xx responses logged, between 0 and 1. "+
+		"The responses with a status of 400 or more are always logged.")
+}
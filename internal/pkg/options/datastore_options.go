@@ -0,0 +1,76 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/marmotedu/component-base/pkg/util/stringutil"
+	"github.com/spf13/pflag"
+)
+
+// The database engines of the store.
+const (
+	DatastoreEngineMySQL    = "mysql"
+	DatastoreEnginePostgres = "postgres"
+	DatastoreEngineSqlite   = "sqlite"
+)
+
+// datastoreEngines are the database engines supported by the store.
+var datastoreEngines = []string{DatastoreEngineMySQL, DatastoreEnginePostgres, DatastoreEngineSqlite}
+
+// The behaviors of the apiserver when the database has pending migrations at startup.
+const (
+	PendingMigrationsFail = "fail"
+	PendingMigrationsWarn = "warn"
+)
+
+// DatastoreOptions selects the database engine of the store, configured by the options of the engine,
+// e.g. MySQLOptions.
+type DatastoreOptions struct {
+	Engine            string `json:"engine"             mapstructure:"engine"`
+	AutoMigrate       bool   `json:"auto-migrate"       mapstructure:"auto-migrate"`
+	PendingMigrations string `json:"pending-migrations" mapstructure:"pending-migrations"`
+}
+
+// NewDatastoreOptions create a DatastoreOptions object with default parameters.
+func NewDatastoreOptions() *DatastoreOptions {
+	return &DatastoreOptions{
+		Engine:            DatastoreEngineMySQL,
+		AutoMigrate:       false,
+		PendingMigrations: PendingMigrationsFail,
+	}
+}
+
+// Validate verifies flags passed to DatastoreOptions.
+func (o *DatastoreOptions) Validate() []error {
+	errs := []error{}
+
+	if !stringutil.StringIn(o.Engine, datastoreEngines) {
+		errs = append(errs, fmt.Errorf("--datastore.engine %q must be one of %v", o.Engine, datastoreEngines))
+	}
+
+	if o.PendingMigrations != PendingMigrationsFail && o.PendingMigrations != PendingMigrationsWarn {
+		errs = append(errs, fmt.Errorf("--datastore.pending-migrations %q must be %s or %s",
+			o.PendingMigrations, PendingMigrationsFail, PendingMigrationsWarn))
+	}
+
+	return errs
+}
+
+// AddFlags adds flags related to the datastore for a specific APIServer to the specified FlagSet.
+func (o *DatastoreOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Engine, "datastore.engine", o.Engine, ""+
+		"The database engine of the store, mysql, postgres or sqlite. It is configured by the mysql.*, "+
+		"postgres.* or sqlite.* options respectively. sqlite is meant for the tests and the demos.")
+
+	fs.BoolVar(&o.AutoMigrate, "datastore.auto-migrate", o.AutoMigrate, ""+
+		"Apply the pending migrations of the database at startup. It is meant for the development, "+
+		"the migrations are applied by the migrate command otherwise. The sqlite databases are always migrated.")
+
+	fs.StringVar(&o.PendingMigrations, "datastore.pending-migrations", o.PendingMigrations, ""+
+		"The behavior at startup when the database has pending migrations, fail to refuse to serve, "+
+		"or warn to serve anyway.")
+}
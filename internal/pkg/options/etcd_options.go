@@ -21,7 +21,7 @@ type EtcdOptions struct {
 	RequestTimeout       int      `json:"request-timeout"         mapstructure:"request-timeout"`
 	LeaseExpire          int      `json:"lease-expire"            mapstructure:"lease-expire"`
 	Username             string   `json:"username"                mapstructure:"username"`
-	Password             string   `json:"password"                mapstructure:"password"`
+	Password             string   `json:"-"                       mapstructure:"password"`
 	UseTLS               bool     `json:"use-tls"                 mapstructure:"use-tls"`
 	CaCert               string   `json:"ca-cert"                 mapstructure:"ca-cert"`
 	Cert                 string   `json:"cert"                    mapstructure:"cert"`
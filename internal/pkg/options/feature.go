@@ -5,6 +5,10 @@
 package options
 
 import (
+	"fmt"
+	"net"
+	"time"
+
 	"github.com/spf13/pflag"
 
 	"github.com/marmotedu/iam/internal/pkg/server"
@@ -12,17 +16,35 @@ import (
 
 // FeatureOptions contains configuration items related to API server features.
 type FeatureOptions struct {
-	EnableProfiling bool `json:"profiling"      mapstructure:"profiling"`
-	EnableMetrics   bool `json:"enable-metrics" mapstructure:"enable-metrics"`
+	EnableProfiling           bool          `json:"profiling"                    mapstructure:"profiling"`
+	EnableMetrics             bool          `json:"enable-metrics"               mapstructure:"enable-metrics"`
+	EnablePreStop             bool          `json:"prestop"                      mapstructure:"prestop"`
+	PreStopAddress            string        `json:"prestop-address"              mapstructure:"prestop-address"`
+	PreStopDelay              time.Duration `json:"prestop-delay"                mapstructure:"prestop-delay"`
+	EnableH2C                 bool          `json:"h2c"                          mapstructure:"h2c"`
+	HTTP2MaxConcurrentStreams uint32        `json:"http2-max-concurrent-streams" mapstructure:"http2-max-concurrent-streams"`
+	HTTP2MaxReadFrameSize     uint32        `json:"http2-max-read-frame-size"    mapstructure:"http2-max-read-frame-size"`
 }
 
+// The bounds of the http/2 frame size, see RFC 7540 section 4.2.
+const (
+	minHTTP2FrameSize = 1 << 14
+	maxHTTP2FrameSize = 1<<24 - 1
+)
+
 // NewFeatureOptions creates a FeatureOptions object with default parameters.
 func NewFeatureOptions() *FeatureOptions {
 	defaults := server.NewConfig()
 
 	return &FeatureOptions{
-		EnableMetrics:   defaults.EnableMetrics,
-		EnableProfiling: defaults.EnableProfiling,
+		EnableMetrics:             defaults.EnableMetrics,
+		EnableProfiling:           defaults.EnableProfiling,
+		EnablePreStop:             false,
+		PreStopAddress:            "127.0.0.1:8079",
+		PreStopDelay:              5 * time.Second,
+		EnableH2C:                 defaults.EnableH2C,
+		HTTP2MaxConcurrentStreams: defaults.HTTP2MaxConcurrentStreams,
+		HTTP2MaxReadFrameSize:     defaults.HTTP2MaxReadFrameSize,
 	}
 }
 
@@ -30,6 +52,9 @@ func NewFeatureOptions() *FeatureOptions {
 func (o *FeatureOptions) ApplyTo(c *server.Config) error {
 	c.EnableProfiling = o.EnableProfiling
 	c.EnableMetrics = o.EnableMetrics
+	c.EnableH2C = o.EnableH2C
+	c.HTTP2MaxConcurrentStreams = o.HTTP2MaxConcurrentStreams
+	c.HTTP2MaxReadFrameSize = o.HTTP2MaxReadFrameSize
 
 	return nil
 }
@@ -37,7 +62,34 @@ func (o *FeatureOptions) ApplyTo(c *server.Config) error {
 // Validate is used to parse and validate the parameters entered by the user at
 // the command line when the program starts.
 func (o *FeatureOptions) Validate() []error {
-	return []error{}
+	errors := []error{}
+
+	if o.HTTP2MaxConcurrentStreams == 0 {
+		errors = append(errors, fmt.Errorf("--feature.http2-max-concurrent-streams %d must be positive", o.HTTP2MaxConcurrentStreams))
+	}
+
+	if o.HTTP2MaxReadFrameSize < minHTTP2FrameSize || o.HTTP2MaxReadFrameSize > maxHTTP2FrameSize {
+		errors = append(errors, fmt.Errorf("--feature.http2-max-read-frame-size %d must be between %d and %d",
+			o.HTTP2MaxReadFrameSize, minHTTP2FrameSize, maxHTTP2FrameSize))
+	}
+
+	if !o.EnablePreStop {
+		return errors
+	}
+
+	// the preStop endpoint is not authenticated, it must not be reachable from outside the pod.
+	host, _, err := net.SplitHostPort(o.PreStopAddress)
+	if err != nil {
+		errors = append(errors, fmt.Errorf("--feature.prestop-address %q is invalid: %w", o.PreStopAddress, err))
+	} else if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		errors = append(errors, fmt.Errorf("--feature.prestop-address %s must be a loopback address", o.PreStopAddress))
+	}
+
+	if o.PreStopDelay < 0 {
+		errors = append(errors, fmt.Errorf("--feature.prestop-delay %v can not be negative", o.PreStopDelay))
+	}
+
+	return errors
 }
 
 // AddFlags adds flags related to features for a specific api server to the
@@ -52,4 +104,24 @@ func (o *FeatureOptions) AddFlags(fs *pflag.FlagSet) {
 
 	fs.BoolVar(&o.EnableMetrics, "feature.enable-metrics", o.EnableMetrics,
 		"Enables metrics on the apiserver at /metrics")
+
+	fs.BoolVar(&o.EnablePreStop, "feature.prestop", o.EnablePreStop, ""+
+		"Enables the kubernetes preStop endpoint POST /prestop on --feature.prestop-address, "+
+		"which marks the server not ready and starts the graceful shutdown after --feature.prestop-delay.")
+
+	fs.StringVar(&o.PreStopAddress, "feature.prestop-address", o.PreStopAddress, ""+
+		"The loopback address of the preStop endpoint.")
+
+	fs.DurationVar(&o.PreStopDelay, "feature.prestop-delay", o.PreStopDelay, ""+
+		"The time the load balancers are given to stop sending new requests to a server not ready, "+
+		"before the shutdown starts. A SIGTERM received meanwhile starts it at once.")
+
+	fs.BoolVar(&o.EnableH2C, "feature.h2c", o.EnableH2C, ""+
+		"Enables http/2 cleartext on the insecure port, with prior knowledge or upgraded from http/1.1.")
+
+	fs.Uint32Var(&o.HTTP2MaxConcurrentStreams, "feature.http2-max-concurrent-streams", o.HTTP2MaxConcurrentStreams, ""+
+		"The maximum number of concurrent streams of a http/2 connection.")
+
+	fs.Uint32Var(&o.HTTP2MaxReadFrameSize, "feature.http2-max-read-frame-size", o.HTTP2MaxReadFrameSize, ""+
+		"The maximum size of the http/2 frames the server reads, between 16384 and 16777215.")
 }
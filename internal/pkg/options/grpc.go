@@ -6,6 +6,8 @@ package options
 
 import (
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/spf13/pflag"
 )
@@ -13,21 +15,49 @@ import (
 // GRPCOptions are for creating an unauthenticated, unauthorized, insecure port.
 // No one should be using these anymore.
 type GRPCOptions struct {
-	BindAddress string `json:"bind-address" mapstructure:"bind-address"`
-	BindPort    int    `json:"bind-port"    mapstructure:"bind-port"`
-	MaxMsgSize  int    `json:"max-msg-size" mapstructure:"max-msg-size"`
+	BindAddress          string   `json:"bind-address"           mapstructure:"bind-address"`
+	BindPort             int      `json:"bind-port"              mapstructure:"bind-port"`
+	MaxMsgSize           int      `json:"max-msg-size"           mapstructure:"max-msg-size"`
+	MaxRecvMsgSize       int      `json:"max-recv-msg-size"      mapstructure:"max-recv-msg-size"`
+	MaxSendMsgSize       int      `json:"max-send-msg-size"      mapstructure:"max-send-msg-size"`
+	MaxConcurrentStreams uint32   `json:"max-concurrent-streams" mapstructure:"max-concurrent-streams"`
+	Tokens               []string `json:"-"                      mapstructure:"tokens"`
+	TokensFile           string   `json:"tokens-file"            mapstructure:"tokens-file"`
 }
 
 // NewGRPCOptions is for creating an unauthenticated, unauthorized, insecure port.
 // No one should be using these anymore.
 func NewGRPCOptions() *GRPCOptions {
 	return &GRPCOptions{
-		BindAddress: "0.0.0.0",
-		BindPort:    8081,
-		MaxMsgSize:  4 * 1024 * 1024,
+		BindAddress:          "0.0.0.0",
+		BindPort:             8081,
+		MaxMsgSize:           4 * 1024 * 1024,
+		MaxSendMsgSize:       math.MaxInt32,
+		MaxConcurrentStreams: 100,
+		Tokens:               []string{},
 	}
 }
 
+// Complete reads the accepted tokens from --grpc.tokens-file if set, one per line.
+func (s *GRPCOptions) Complete() error {
+	if s.TokensFile == "" {
+		return nil
+	}
+
+	if len(s.Tokens) > 0 {
+		return fmt.Errorf("--grpc.tokens and --grpc.tokens-file %q can not be both set", s.TokensFile)
+	}
+
+	tokens, err := ReadSecretFile(s.TokensFile)
+	if err != nil {
+		return fmt.Errorf("--grpc.tokens-file: %w", err)
+	}
+
+	s.Tokens = strings.Fields(tokens)
+
+	return nil
+}
+
 // Validate is used to parse and validate the parameters entered by the user at
 // the command line when the program starts.
 func (s *GRPCOptions) Validate() []error {
@@ -37,15 +67,37 @@ func (s *GRPCOptions) Validate() []error {
 		errors = append(
 			errors,
 			fmt.Errorf(
-				"--insecure-port %v must be between 0 and 65535, inclusive. 0 for turning off insecure (HTTP) port",
+				"--grpc.bind-port %v must be between 0 and 65535, inclusive. 0 for turning off the grpc server",
 				s.BindPort,
 			),
 		)
 	}
 
+	if s.MaxRecvMsgSize < 0 {
+		errors = append(errors, fmt.Errorf("--grpc.max-recv-msg-size %v can not be negative", s.MaxRecvMsgSize))
+	}
+
+	if s.MaxSendMsgSize <= 0 {
+		errors = append(errors, fmt.Errorf("--grpc.max-send-msg-size %v must be greater than 0", s.MaxSendMsgSize))
+	}
+
+	if s.MaxConcurrentStreams == 0 {
+		errors = append(errors, fmt.Errorf("--grpc.max-concurrent-streams %d must be greater than 0", s.MaxConcurrentStreams))
+	}
+
 	return errors
 }
 
+// RecvMsgSize returns the max size of the messages received by the grpc server,
+// --grpc.max-recv-msg-size takes precedence over --grpc.max-msg-size.
+func (s *GRPCOptions) RecvMsgSize() int {
+	if s.MaxRecvMsgSize > 0 {
+		return s.MaxRecvMsgSize
+	}
+
+	return s.MaxMsgSize
+}
+
 // AddFlags adds flags related to features for a specific api server to the
 // specified FlagSet.
 func (s *GRPCOptions) AddFlags(fs *pflag.FlagSet) {
@@ -58,5 +110,26 @@ func (s *GRPCOptions) AddFlags(fs *pflag.FlagSet) {
 		"the deployed machine and that port 443 on the iam public address is proxied to this "+
 		"port. This is performed by nginx in the default setup. Set to zero to disable.")
 
-	fs.IntVar(&s.MaxMsgSize, "grpc.max-msg-size", s.MaxMsgSize, "gRPC max message size.")
+	fs.IntVar(&s.MaxMsgSize, "grpc.max-msg-size", s.MaxMsgSize, ""+
+		"gRPC max message size. Deprecated: use --grpc.max-recv-msg-size instead.")
+
+	fs.IntVar(&s.MaxRecvMsgSize, "grpc.max-recv-msg-size", s.MaxRecvMsgSize, ""+
+		"The max size in bytes of the messages the grpc server can receive. "+
+		"If 0, --grpc.max-msg-size is used.")
+
+	fs.IntVar(&s.MaxSendMsgSize, "grpc.max-send-msg-size", s.MaxSendMsgSize,
+		"The max size in bytes of the messages the grpc server can send.")
+
+	fs.Uint32Var(&s.MaxConcurrentStreams, "grpc.max-concurrent-streams", s.MaxConcurrentStreams, ""+
+		"The max number of concurrent streams served by the grpc server, over all the connections. "+
+		"The streams over the limit are rejected with RESOURCE_EXHAUSTED.")
+
+	fs.StringSliceVar(&s.Tokens, "grpc.tokens", s.Tokens, ""+
+		"A set of bearer tokens accepted by the grpc server, comma separated. If set, every grpc "+
+		"request must carry one of them in the authorization metadata. It is recommended to set it "+
+		"in the configuration file rather than in the command line.")
+
+	fs.StringVar(&s.TokensFile, "grpc.tokens-file", s.TokensFile, ""+
+		"File containing the bearer tokens accepted by the grpc server, one per line, e.g. a mounted secret, "+
+		"instead of --grpc.tokens.")
 }
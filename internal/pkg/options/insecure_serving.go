@@ -7,7 +7,10 @@ package options
 import (
 	"fmt"
 	"net"
+	"os"
+	"os/user"
 	"strconv"
+	"strings"
 
 	"github.com/spf13/pflag"
 
@@ -17,16 +20,21 @@ import (
 // InsecureServingOptions are for creating an unauthenticated, unauthorized, insecure port.
 // No one should be using these anymore.
 type InsecureServingOptions struct {
-	BindAddress string `json:"bind-address" mapstructure:"bind-address"`
-	BindPort    int    `json:"bind-port"    mapstructure:"bind-port"`
+	BindAddress string `json:"bind-address"      mapstructure:"bind-address"`
+	BindPort    int    `json:"bind-port"         mapstructure:"bind-port"`
+	// BindSocket is the path of a unix socket to serve on, instead of the port if BindPort is 0.
+	BindSocket      string `json:"bind-socket"       mapstructure:"bind-socket"`
+	BindSocketMode  string `json:"bind-socket-mode"  mapstructure:"bind-socket-mode"`
+	BindSocketOwner string `json:"bind-socket-owner" mapstructure:"bind-socket-owner"`
 }
 
 // NewInsecureServingOptions is for creating an unauthenticated, unauthorized, insecure port.
 // No one should be using these anymore.
 func NewInsecureServingOptions() *InsecureServingOptions {
 	return &InsecureServingOptions{
-		BindAddress: "127.0.0.1",
-		BindPort:    8080,
+		BindAddress:    "127.0.0.1",
+		BindPort:       8080,
+		BindSocketMode: "0660",
 	}
 }
 
@@ -36,9 +44,83 @@ func (s *InsecureServingOptions) ApplyTo(c *server.Config) error {
 		Address: net.JoinHostPort(s.BindAddress, strconv.Itoa(s.BindPort)),
 	}
 
+	if s.BindSocket == "" {
+		return nil
+	}
+
+	// the port is turned off in favor of the socket.
+	if s.BindPort == 0 {
+		c.InsecureServing.Address = ""
+	}
+
+	socket, err := s.socketInfo()
+	if err != nil {
+		return err
+	}
+
+	c.InsecureServing.Socket = socket
+
 	return nil
 }
 
+func (s *InsecureServingOptions) socketInfo() (*server.UnixSocketInfo, error) {
+	mode, err := strconv.ParseUint(s.BindSocketMode, 8, 32)
+	if err != nil || mode > 0o777 {
+		return nil, fmt.Errorf("--insecure.bind-socket-mode %q is not an octal file mode, like 0660", s.BindSocketMode)
+	}
+
+	uid, gid, err := parseOwner(s.BindSocketOwner)
+	if err != nil {
+		return nil, fmt.Errorf("--insecure.bind-socket-owner: %w", err)
+	}
+
+	return &server.UnixSocketInfo{Path: s.BindSocket, Mode: os.FileMode(mode), UID: uid, GID: gid}, nil
+}
+
+// parseOwner parses an owner in the USER[:GROUP] format, of names or numeric ids. -1 is returned for
+// the user or the group not given.
+func parseOwner(owner string) (int, int, error) {
+	uid, gid := -1, -1
+	if owner == "" {
+		return uid, gid, nil
+	}
+
+	name, group, hasGroup := owner, "", false
+	if i := strings.Index(owner, ":"); i != -1 {
+		name, group, hasGroup = owner[:i], owner[i+1:], true
+	}
+
+	if name != "" {
+		id, err := strconv.Atoi(name)
+		if err != nil {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			id, _ = strconv.Atoi(u.Uid)
+		}
+
+		uid = id
+	}
+
+	if hasGroup && group != "" {
+		id, err := strconv.Atoi(group)
+		if err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			id, _ = strconv.Atoi(g.Gid)
+		}
+
+		gid = id
+	}
+
+	return uid, gid, nil
+}
+
 // Validate is used to parse and validate the parameters entered by the user at
 // the command line when the program starts.
 func (s *InsecureServingOptions) Validate() []error {
@@ -54,6 +136,12 @@ func (s *InsecureServingOptions) Validate() []error {
 		)
 	}
 
+	if s.BindSocket != "" {
+		if _, err := s.socketInfo(); err != nil {
+			errors = append(errors, err)
+		}
+	}
+
 	return errors
 }
 
@@ -68,4 +156,13 @@ func (s *InsecureServingOptions) AddFlags(fs *pflag.FlagSet) {
 		"that firewall rules are set up such that this port is not reachable from outside of "+
 		"the deployed machine and that port 443 on the iam public address is proxied to this "+
 		"port. This is performed by nginx in the default setup. Set to zero to disable.")
+	fs.StringVar(&s.BindSocket, "insecure.bind-socket", s.BindSocket, ""+
+		"The path of a unix socket on which to serve unsecured, unauthenticated access, e.g. for a sidecar "+
+		"proxy. The socket is served in addition to --insecure.bind-port, or instead of it if the port is 0. "+
+		"The server refuses to start if the path exists and is not a socket left by a stopped server.")
+	fs.StringVar(&s.BindSocketMode, "insecure.bind-socket-mode", s.BindSocketMode,
+		"The permission of the --insecure.bind-socket file, in octal.")
+	fs.StringVar(&s.BindSocketOwner, "insecure.bind-socket-owner", s.BindSocketOwner, ""+
+		"The owner of the --insecure.bind-socket file in the USER[:GROUP] format, of names or numeric ids. "+
+		"If empty, the user and the group of the process own it.")
 }
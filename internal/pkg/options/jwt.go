@@ -11,15 +11,20 @@ import (
 	"github.com/asaskevich/govalidator"
 	"github.com/spf13/pflag"
 
+	"github.com/marmotedu/iam/internal/pkg/jwt"
 	"github.com/marmotedu/iam/internal/pkg/server"
 )
 
 // JwtOptions contains configuration items related to API server features.
 type JwtOptions struct {
-	Realm      string        `json:"realm"       mapstructure:"realm"`
-	Key        string        `json:"key"         mapstructure:"key"`
-	Timeout    time.Duration `json:"timeout"     mapstructure:"timeout"`
-	MaxRefresh time.Duration `json:"max-refresh" mapstructure:"max-refresh"`
+	Realm            string        `json:"realm"             mapstructure:"realm"`
+	Key              string        `json:"-"                 mapstructure:"key"`
+	KeyFile          string        `json:"key-file"          mapstructure:"key-file"`
+	Timeout          time.Duration `json:"timeout"           mapstructure:"timeout"`
+	MaxRefresh       time.Duration `json:"max-refresh"       mapstructure:"max-refresh"`
+	Algorithm        string        `json:"algorithm"         mapstructure:"algorithm"`
+	RotationInterval time.Duration `json:"rotation-interval" mapstructure:"rotation-interval"`
+	RetainedKeys     int           `json:"retained-keys"     mapstructure:"retained-keys"`
 }
 
 // NewJwtOptions creates a JwtOptions object with default parameters.
@@ -27,32 +32,64 @@ func NewJwtOptions() *JwtOptions {
 	defaults := server.NewConfig()
 
 	return &JwtOptions{
-		Realm:      defaults.Jwt.Realm,
-		Key:        defaults.Jwt.Key,
-		Timeout:    defaults.Jwt.Timeout,
-		MaxRefresh: defaults.Jwt.MaxRefresh,
+		Realm:            defaults.Jwt.Realm,
+		Key:              defaults.Jwt.Key,
+		Timeout:          defaults.Jwt.Timeout,
+		MaxRefresh:       defaults.Jwt.MaxRefresh,
+		Algorithm:        defaults.Jwt.Algorithm,
+		RotationInterval: defaults.Jwt.RotationInterval,
+		RetainedKeys:     defaults.Jwt.RetainedKeys,
 	}
 }
 
 // ApplyTo applies the run options to the method receiver and returns self.
 func (s *JwtOptions) ApplyTo(c *server.Config) error {
 	c.Jwt = &server.JwtInfo{
-		Realm:      s.Realm,
-		Key:        s.Key,
-		Timeout:    s.Timeout,
-		MaxRefresh: s.MaxRefresh,
+		Realm:            s.Realm,
+		Key:              s.Key,
+		Timeout:          s.Timeout,
+		MaxRefresh:       s.MaxRefresh,
+		Algorithm:        s.Algorithm,
+		RotationInterval: s.RotationInterval,
+		RetainedKeys:     s.RetainedKeys,
 	}
 
 	return nil
 }
 
+// Complete reads the signing key from --jwt.key-file if set.
+func (s *JwtOptions) Complete() error {
+	return CompleteSecret(&s.Key, s.KeyFile, "jwt.key")
+}
+
 // Validate is used to parse and validate the parameters entered by the user at
 // the command line when the program starts.
 func (s *JwtOptions) Validate() []error {
 	var errs []error
 
 	if !govalidator.StringLength(s.Key, "6", "32") {
-		errs = append(errs, fmt.Errorf("--secret-key must larger than 5 and little than 33"))
+		// the key is not reported, only its length.
+		errs = append(errs, fmt.Errorf("--jwt.key of %d characters must have between 6 and 32 characters",
+			len(s.Key)))
+	}
+
+	if s.Algorithm != jwt.AlgorithmHS256 && s.Algorithm != jwt.AlgorithmRS256 {
+		errs = append(errs, fmt.Errorf("--jwt.algorithm %q must be %s or %s", s.Algorithm, jwt.AlgorithmHS256, jwt.AlgorithmRS256))
+	}
+
+	if s.RotationInterval != 0 && s.RotationInterval < time.Second {
+		errs = append(errs, fmt.Errorf("--jwt.rotation-interval %v must be 0 or not less than 1s", s.RotationInterval))
+	}
+
+	if s.RetainedKeys < 0 {
+		errs = append(errs, fmt.Errorf("--jwt.retained-keys %d can not be negative", s.RetainedKeys))
+	}
+
+	// a key must be accepted as long as the tokens it signed can be refreshed.
+	if s.RotationInterval > 0 && time.Duration(s.RetainedKeys+1)*s.RotationInterval < s.Timeout+s.MaxRefresh {
+		errs = append(errs, fmt.Errorf("--jwt.rotation-interval %v * (--jwt.retained-keys %d + 1) "+
+			"must not be less than --jwt.timeout %v + --jwt.max-refresh %v",
+			s.RotationInterval, s.RetainedKeys, s.Timeout, s.MaxRefresh))
 	}
 
 	return errs
@@ -67,8 +104,20 @@ func (s *JwtOptions) AddFlags(fs *pflag.FlagSet) {
 
 	fs.StringVar(&s.Realm, "jwt.realm", s.Realm, "Realm name to display to the user.")
 	fs.StringVar(&s.Key, "jwt.key", s.Key, "Private key used to sign jwt token.")
+	fs.StringVar(&s.KeyFile, "jwt.key-file", s.KeyFile, ""+
+		"File containing the private key used to sign jwt token, e.g. a mounted secret, instead of --jwt.key.")
 	fs.DurationVar(&s.Timeout, "jwt.timeout", s.Timeout, "JWT token timeout.")
 
 	fs.DurationVar(&s.MaxRefresh, "jwt.max-refresh", s.MaxRefresh, ""+
 		"This field allows clients to refresh their token until MaxRefresh has passed.")
+
+	fs.StringVar(&s.Algorithm, "jwt.algorithm", s.Algorithm, ""+
+		"Algorithm used to sign jwt token, HS256 or RS256. HS256 keys are derived from --jwt.key so they are "+
+		"shared by all the instances, RS256 keys are generated by each instance.")
+
+	fs.DurationVar(&s.RotationInterval, "jwt.rotation-interval", s.RotationInterval, ""+
+		"Interval to rotate the key used to sign jwt token, 0 disables the rotation and signs with --jwt.key.")
+
+	fs.IntVar(&s.RetainedKeys, "jwt.retained-keys", s.RetainedKeys, ""+
+		"Number of previous keys still accepted to verify jwt token after a rotation.")
 }
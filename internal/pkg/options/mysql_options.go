@@ -5,8 +5,10 @@
 package options
 
 import (
+	"fmt"
 	"time"
 
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"github.com/spf13/pflag"
 	"gorm.io/gorm"
 
@@ -15,34 +17,124 @@ import (
 
 // MySQLOptions defines options for mysql database.
 type MySQLOptions struct {
-	Host                  string        `json:"host,omitempty"                     mapstructure:"host"`
-	Username              string        `json:"username,omitempty"                 mapstructure:"username"`
-	Password              string        `json:"-"                                  mapstructure:"password"`
-	Database              string        `json:"database"                           mapstructure:"database"`
-	MaxIdleConnections    int           `json:"max-idle-connections,omitempty"     mapstructure:"max-idle-connections"`
-	MaxOpenConnections    int           `json:"max-open-connections,omitempty"     mapstructure:"max-open-connections"`
-	MaxConnectionLifeTime time.Duration `json:"max-connection-life-time,omitempty" mapstructure:"max-connection-life-time"`
-	LogLevel              int           `json:"log-level"                          mapstructure:"log-level"`
+	Host                    string        `json:"host,omitempty"                     mapstructure:"host"`
+	Username                string        `json:"username,omitempty"                 mapstructure:"username"`
+	Password                string        `json:"-"                                  mapstructure:"password"`
+	PasswordFile            string        `json:"password-file"                      mapstructure:"password-file"`
+	Database                string        `json:"database"                           mapstructure:"database"`
+	MaxIdleConnections      int           `json:"max-idle-connections,omitempty"     mapstructure:"max-idle-connections"`
+	MaxOpenConnections      int           `json:"max-open-connections,omitempty"     mapstructure:"max-open-connections"`
+	MaxConnectionLifeTime   time.Duration `json:"max-connection-life-time,omitempty" mapstructure:"max-connection-life-time"`
+	MaxConnectionIdleTime   time.Duration `json:"max-connection-idle-time,omitempty" mapstructure:"max-connection-idle-time"`
+	LogLevel                int           `json:"log-level"                          mapstructure:"log-level"`
+	CircuitBreakerThreshold int           `json:"circuit-breaker-threshold"          mapstructure:"circuit-breaker-threshold"`
+	CircuitBreakerTimeout   time.Duration `json:"circuit-breaker-timeout"            mapstructure:"circuit-breaker-timeout"`
+	MaxRetries              int           `json:"max-retries"                        mapstructure:"max-retries"`
+	RetryBackoff            time.Duration `json:"retry-backoff"                      mapstructure:"retry-backoff"`
+	SlowQueryThreshold      time.Duration `json:"slow-query-threshold"               mapstructure:"slow-query-threshold"`
+	QueryMetrics            bool          `json:"query-metrics"                      mapstructure:"query-metrics"`
+	HealthCheckInterval     time.Duration `json:"health-check-interval"              mapstructure:"health-check-interval"`
+	HealthCheckTimeout      time.Duration `json:"health-check-timeout"               mapstructure:"health-check-timeout"`
+	CreateBatchSize         int           `json:"create-batch-size"                  mapstructure:"create-batch-size"`
+	ReplicaDSNs             []string      `json:"-"                                  mapstructure:"replica-dsns"`
 }
 
 // NewMySQLOptions create a `zero` value instance.
 func NewMySQLOptions() *MySQLOptions {
 	return &MySQLOptions{
-		Host:                  "127.0.0.1:3306",
-		Username:              "",
-		Password:              "",
-		Database:              "",
-		MaxIdleConnections:    100,
-		MaxOpenConnections:    100,
-		MaxConnectionLifeTime: time.Duration(10) * time.Second,
-		LogLevel:              1, // Silent
+		Host:                    "127.0.0.1:3306",
+		Username:                "",
+		Password:                "",
+		Database:                "",
+		MaxIdleConnections:      100,
+		MaxOpenConnections:      100,
+		MaxConnectionLifeTime:   time.Duration(10) * time.Second,
+		MaxConnectionIdleTime:   0, // the idle connections are closed by MaxConnectionLifeTime only
+		LogLevel:                1, // Silent
+		CircuitBreakerThreshold: 5,
+		CircuitBreakerTimeout:   30 * time.Second,
+		MaxRetries:              3,
+		RetryBackoff:            10 * time.Millisecond,
+		SlowQueryThreshold:      100 * time.Millisecond,
+		QueryMetrics:            true,
+		HealthCheckInterval:     10 * time.Second,
+		HealthCheckTimeout:      3 * time.Second,
+		CreateBatchSize:         500,
 	}
 }
 
+// Complete reads the password from --mysql.password-file if set.
+func (o *MySQLOptions) Complete() error {
+	return CompleteSecret(&o.Password, o.PasswordFile, "mysql.password")
+}
+
 // Validate verifies flags passed to MySQLOptions.
 func (o *MySQLOptions) Validate() []error {
 	errs := []error{}
 
+	if o.MaxOpenConnections < 0 {
+		errs = append(errs, fmt.Errorf("--mysql.max-open-connections %d cannot be negative", o.MaxOpenConnections))
+	}
+
+	if o.MaxIdleConnections < 0 {
+		errs = append(errs, fmt.Errorf("--mysql.max-idle-connections %d cannot be negative", o.MaxIdleConnections))
+	}
+
+	// database/sql lowers the idle connections to the open ones silently.
+	if o.MaxOpenConnections > 0 && o.MaxIdleConnections > o.MaxOpenConnections {
+		errs = append(errs, fmt.Errorf("--mysql.max-idle-connections %d cannot be greater than "+
+			"--mysql.max-open-connections %d", o.MaxIdleConnections, o.MaxOpenConnections))
+	}
+
+	if o.MaxConnectionLifeTime < 0 {
+		errs = append(errs, fmt.Errorf("--mysql.max-connection-life-time %v cannot be negative", o.MaxConnectionLifeTime))
+	}
+
+	if o.MaxConnectionIdleTime < 0 {
+		errs = append(errs, fmt.Errorf("--mysql.max-connection-idle-time %v cannot be negative", o.MaxConnectionIdleTime))
+	}
+
+	if o.CircuitBreakerThreshold < 0 {
+		errs = append(errs, fmt.Errorf("--mysql.circuit-breaker-threshold %d cannot be negative", o.CircuitBreakerThreshold))
+	}
+
+	if o.CircuitBreakerThreshold > 0 && o.CircuitBreakerTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("--mysql.circuit-breaker-timeout %v must be greater than 0 with "+
+			"--mysql.circuit-breaker-threshold %d", o.CircuitBreakerTimeout, o.CircuitBreakerThreshold))
+	}
+
+	if o.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("--mysql.max-retries %d cannot be negative", o.MaxRetries))
+	}
+
+	if o.RetryBackoff < 0 {
+		errs = append(errs, fmt.Errorf("--mysql.retry-backoff %v cannot be negative", o.RetryBackoff))
+	}
+
+	if o.SlowQueryThreshold < 0 {
+		errs = append(errs, fmt.Errorf("--mysql.slow-query-threshold %v cannot be negative", o.SlowQueryThreshold))
+	}
+
+	if o.HealthCheckInterval < 0 {
+		errs = append(errs, fmt.Errorf("--mysql.health-check-interval %v cannot be negative", o.HealthCheckInterval))
+	}
+
+	if o.HealthCheckInterval > 0 && o.HealthCheckTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("--mysql.health-check-timeout %v must be greater than 0 with "+
+			"--mysql.health-check-interval %v", o.HealthCheckTimeout, o.HealthCheckInterval))
+	}
+
+	if o.CreateBatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("--mysql.create-batch-size %d must be greater than 0", o.CreateBatchSize))
+	}
+
+	for i, dsn := range o.ReplicaDSNs {
+		// the dsn is not reported, it includes the password of the replica.
+		if _, err := mysqldriver.ParseDSN(dsn); err != nil {
+			errs = append(errs, fmt.Errorf("--mysql.replica-dsns: the data source name %d is invalid", i+1))
+		}
+	}
+
 	return errs
 }
 
@@ -57,20 +149,72 @@ func (o *MySQLOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.Password, "mysql.password", o.Password, ""+
 		"Password for access to mysql, should be used pair with password.")
 
+	fs.StringVar(&o.PasswordFile, "mysql.password-file", o.PasswordFile, ""+
+		"File containing the password for access to mysql, e.g. a mounted secret, instead of --mysql.password. "+
+		"The file is read again on the configuration reloads, the new connections use the rotated password.")
+
 	fs.StringVar(&o.Database, "mysql.database", o.Database, ""+
 		"Database name for the server to use.")
 
-	fs.IntVar(&o.MaxIdleConnections, "mysql.max-idle-connections", o.MaxOpenConnections, ""+
-		"Maximum idle connections allowed to connect to mysql.")
+	fs.IntVar(&o.MaxIdleConnections, "mysql.max-idle-connections", o.MaxIdleConnections, ""+
+		"Maximum idle connections allowed to connect to mysql. It cannot be greater than "+
+		"--mysql.max-open-connections, 0 means the connections are closed once idle.")
 
 	fs.IntVar(&o.MaxOpenConnections, "mysql.max-open-connections", o.MaxOpenConnections, ""+
-		"Maximum open connections allowed to connect to mysql.")
+		"Maximum open connections allowed to connect to mysql, 0 means unlimited. The queries wait for a "+
+		"connection once they are all in use, see the iam_db_connections_wait_total metric.")
 
 	fs.DurationVar(&o.MaxConnectionLifeTime, "mysql.max-connection-life-time", o.MaxConnectionLifeTime, ""+
-		"Maximum connection life time allowed to connect to mysql.")
+		"Maximum connection life time allowed to connect to mysql. 0 means the connections are reused forever.")
+
+	fs.DurationVar(&o.MaxConnectionIdleTime, "mysql.max-connection-idle-time", o.MaxConnectionIdleTime, ""+
+		"Maximum time a connection to mysql may be idle before it is closed. 0 means the idle connections are "+
+		"only closed by --mysql.max-connection-life-time.")
 
 	fs.IntVar(&o.LogLevel, "mysql.log-mode", o.LogLevel, ""+
 		"Specify gorm log level.")
+
+	fs.IntVar(&o.CircuitBreakerThreshold, "mysql.circuit-breaker-threshold", o.CircuitBreakerThreshold, ""+
+		"Number of consecutive failed reads or writes after which the reads or writes fail fast with "+
+		"503 Service Unavailable, without waiting for mysql. Set to 0 to disable the circuit breakers.")
+
+	fs.DurationVar(&o.CircuitBreakerTimeout, "mysql.circuit-breaker-timeout", o.CircuitBreakerTimeout, ""+
+		"Duration the operations fail fast once the circuit breaker is open, a trial operation is then let "+
+		"through to check whether mysql is back. It is also sent to the clients in the Retry-After header.")
+
+	fs.IntVar(&o.MaxRetries, "mysql.max-retries", o.MaxRetries, ""+
+		"Number of times the reads and the transactions failing with a deadlock, a lock wait timeout or a bad "+
+		"connection are retried, see the iam_db_retries_total metric. The other writes are never retried. "+
+		"Set to 0 to disable the retries.")
+
+	fs.DurationVar(&o.RetryBackoff, "mysql.retry-backoff", o.RetryBackoff, ""+
+		"Backoff before the first retry of an operation, doubled before each next retry, and jittered.")
+
+	fs.DurationVar(&o.SlowQueryThreshold, "mysql.slow-query-threshold", o.SlowQueryThreshold, ""+
+		"The queries taking longer than this duration are logged as warnings and counted by the "+
+		"iam_db_slow_queries_total metric. Set to 0 to disable the slow query logging.")
+
+	fs.BoolVar(&o.QueryMetrics, "mysql.query-metrics", o.QueryMetrics, ""+
+		"Record the duration and the failures of the mysql operations, by table and operation type, in the "+
+		"iam_db_operation_duration_seconds and iam_db_operation_errors_total metrics.")
+
+	fs.DurationVar(&o.HealthCheckInterval, "mysql.health-check-interval", o.HealthCheckInterval, ""+
+		"Interval between the pings checking mysql. The idle connections are closed once a ping fails, as they "+
+		"are likely stale, and the store is created again if mysql could not be reached before. "+
+		"Set to 0 to only check mysql by /healthz and /readyz.")
+
+	fs.DurationVar(&o.HealthCheckTimeout, "mysql.health-check-timeout", o.HealthCheckTimeout, ""+
+		"Timeout of the pings of --mysql.health-check-interval.")
+
+	fs.IntVar(&o.CreateBatchSize, "mysql.create-batch-size", o.CreateBatchSize, ""+
+		"Number of rows inserted by a statement of the batch creations of users and policies. Lower it if "+
+		"the statements exceed the max_allowed_packet of mysql.")
+
+	fs.StringSliceVar(&o.ReplicaDSNs, "mysql.replica-dsns", o.ReplicaDSNs, ""+
+		"Data source names of the read replicas of mysql, e.g. user:password@tcp(127.0.0.1:3307)/iam. "+
+		"The lists and the user gets of the api, and the bulk reads of the cache service, are served by the "+
+		"replicas in turn, the writes and the versioned gets by --mysql.host. A failed read of a replica is "+
+		"served by --mysql.host.")
 }
 
 // NewClient create mysql store with the given config.
@@ -83,6 +227,7 @@ func (o *MySQLOptions) NewClient() (*gorm.DB, error) {
 		MaxIdleConnections:    o.MaxIdleConnections,
 		MaxOpenConnections:    o.MaxOpenConnections,
 		MaxConnectionLifeTime: o.MaxConnectionLifeTime,
+		MaxConnectionIdleTime: o.MaxConnectionIdleTime,
 		LogLevel:              o.LogLevel,
 	}
 
@@ -0,0 +1,151 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/marmotedu/component-base/pkg/util/stringutil"
+	"github.com/spf13/pflag"
+)
+
+// postgresSSLModes are the sslmode values supported by postgres.
+var postgresSSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// PostgresOptions defines options for postgres database.
+type PostgresOptions struct {
+	Host                    string        `json:"host,omitempty"                     mapstructure:"host"`
+	Username                string        `json:"username,omitempty"                 mapstructure:"username"`
+	Password                string        `json:"-"                                  mapstructure:"password"`
+	PasswordFile            string        `json:"password-file"                      mapstructure:"password-file"`
+	Database                string        `json:"database"                           mapstructure:"database"`
+	SSLMode                 string        `json:"ssl-mode"                           mapstructure:"ssl-mode"`
+	MaxIdleConnections      int           `json:"max-idle-connections,omitempty"     mapstructure:"max-idle-connections"`
+	MaxOpenConnections      int           `json:"max-open-connections,omitempty"     mapstructure:"max-open-connections"`
+	MaxConnectionLifeTime   time.Duration `json:"max-connection-life-time,omitempty" mapstructure:"max-connection-life-time"`
+	LogLevel                int           `json:"log-level"                          mapstructure:"log-level"`
+	CircuitBreakerThreshold int           `json:"circuit-breaker-threshold"          mapstructure:"circuit-breaker-threshold"`
+	CircuitBreakerTimeout   time.Duration `json:"circuit-breaker-timeout"            mapstructure:"circuit-breaker-timeout"`
+	MaxRetries              int           `json:"max-retries"                        mapstructure:"max-retries"`
+	RetryBackoff            time.Duration `json:"retry-backoff"                      mapstructure:"retry-backoff"`
+	SlowQueryThreshold      time.Duration `json:"slow-query-threshold"               mapstructure:"slow-query-threshold"`
+	QueryMetrics            bool          `json:"query-metrics"                      mapstructure:"query-metrics"`
+}
+
+// NewPostgresOptions create a `zero` value instance.
+func NewPostgresOptions() *PostgresOptions {
+	return &PostgresOptions{
+		Host:                    "127.0.0.1:5432",
+		Username:                "",
+		Password:                "",
+		Database:                "",
+		SSLMode:                 "disable",
+		MaxIdleConnections:      100,
+		MaxOpenConnections:      100,
+		MaxConnectionLifeTime:   time.Duration(10) * time.Second,
+		LogLevel:                1, // Silent
+		CircuitBreakerThreshold: 5,
+		CircuitBreakerTimeout:   30 * time.Second,
+		MaxRetries:              3,
+		RetryBackoff:            10 * time.Millisecond,
+		SlowQueryThreshold:      100 * time.Millisecond,
+		QueryMetrics:            true,
+	}
+}
+
+// Complete reads the password from --postgres.password-file if set.
+func (o *PostgresOptions) Complete() error {
+	return CompleteSecret(&o.Password, o.PasswordFile, "postgres.password")
+}
+
+// Validate verifies flags passed to PostgresOptions.
+func (o *PostgresOptions) Validate() []error {
+	errs := []error{}
+
+	if !stringutil.StringIn(o.SSLMode, postgresSSLModes) {
+		errs = append(errs, fmt.Errorf("--postgres.ssl-mode %q must be one of %v", o.SSLMode, postgresSSLModes))
+	}
+
+	if o.CircuitBreakerThreshold < 0 {
+		errs = append(errs, fmt.Errorf("--postgres.circuit-breaker-threshold %d cannot be negative", o.CircuitBreakerThreshold))
+	}
+
+	if o.CircuitBreakerThreshold > 0 && o.CircuitBreakerTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("--postgres.circuit-breaker-timeout %v must be greater than 0 with "+
+			"--postgres.circuit-breaker-threshold %d", o.CircuitBreakerTimeout, o.CircuitBreakerThreshold))
+	}
+
+	if o.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("--postgres.max-retries %d cannot be negative", o.MaxRetries))
+	}
+
+	if o.RetryBackoff < 0 {
+		errs = append(errs, fmt.Errorf("--postgres.retry-backoff %v cannot be negative", o.RetryBackoff))
+	}
+
+	if o.SlowQueryThreshold < 0 {
+		errs = append(errs, fmt.Errorf("--postgres.slow-query-threshold %v cannot be negative", o.SlowQueryThreshold))
+	}
+
+	return errs
+}
+
+// AddFlags adds flags related to postgres storage for a specific APIServer to the specified FlagSet.
+func (o *PostgresOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Host, "postgres.host", o.Host, ""+
+		"PostgreSQL service host address, used when --datastore.engine is postgres.")
+
+	fs.StringVar(&o.Username, "postgres.username", o.Username, ""+
+		"Username for access to postgres service.")
+
+	fs.StringVar(&o.Password, "postgres.password", o.Password, ""+
+		"Password for access to postgres, should be used pair with username.")
+
+	fs.StringVar(&o.PasswordFile, "postgres.password-file", o.PasswordFile, ""+
+		"File containing the password for access to postgres, e.g. a mounted secret, instead of --postgres.password.")
+
+	fs.StringVar(&o.Database, "postgres.database", o.Database, ""+
+		"Database name for the server to use.")
+
+	fs.StringVar(&o.SSLMode, "postgres.ssl-mode", o.SSLMode, ""+
+		"The sslmode of the connections to postgres: disable, allow, prefer, require, verify-ca or verify-full.")
+
+	fs.IntVar(&o.MaxIdleConnections, "postgres.max-idle-connections", o.MaxIdleConnections, ""+
+		"Maximum idle connections allowed to connect to postgres.")
+
+	fs.IntVar(&o.MaxOpenConnections, "postgres.max-open-connections", o.MaxOpenConnections, ""+
+		"Maximum open connections allowed to connect to postgres.")
+
+	fs.DurationVar(&o.MaxConnectionLifeTime, "postgres.max-connection-life-time", o.MaxConnectionLifeTime, ""+
+		"Maximum connection life time allowed to connect to postgres.")
+
+	fs.IntVar(&o.LogLevel, "postgres.log-mode", o.LogLevel, ""+
+		"Specify gorm log level.")
+
+	fs.IntVar(&o.CircuitBreakerThreshold, "postgres.circuit-breaker-threshold", o.CircuitBreakerThreshold, ""+
+		"Number of consecutive failed reads or writes after which the reads or writes fail fast with "+
+		"503 Service Unavailable, without waiting for postgres. Set to 0 to disable the circuit breakers.")
+
+	fs.DurationVar(&o.CircuitBreakerTimeout, "postgres.circuit-breaker-timeout", o.CircuitBreakerTimeout, ""+
+		"Duration the operations fail fast once the circuit breaker is open, a trial operation is then let "+
+		"through to check whether postgres is back. It is also sent to the clients in the Retry-After header.")
+
+	fs.IntVar(&o.MaxRetries, "postgres.max-retries", o.MaxRetries, ""+
+		"Number of times the reads and the transactions failing with a deadlock, a lock wait timeout or a bad "+
+		"connection are retried, see the iam_db_retries_total metric. The other writes are never retried. "+
+		"Set to 0 to disable the retries.")
+
+	fs.DurationVar(&o.RetryBackoff, "postgres.retry-backoff", o.RetryBackoff, ""+
+		"Backoff before the first retry of an operation, doubled before each next retry, and jittered.")
+
+	fs.DurationVar(&o.SlowQueryThreshold, "postgres.slow-query-threshold", o.SlowQueryThreshold, ""+
+		"The queries taking longer than this duration are logged as warnings and counted by the "+
+		"iam_db_slow_queries_total metric. Set to 0 to disable the slow query logging.")
+
+	fs.BoolVar(&o.QueryMetrics, "postgres.query-metrics", o.QueryMetrics, ""+
+		"Record the duration and the failures of the postgres operations, by table and operation type, in the "+
+		"iam_db_operation_duration_seconds and iam_db_operation_errors_total metrics.")
+}
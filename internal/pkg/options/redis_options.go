@@ -5,7 +5,14 @@
 package options
 
 import (
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/marmotedu/errors"
 	"github.com/spf13/pflag"
+
+	"github.com/marmotedu/iam/pkg/storage"
 )
 
 // RedisOptions defines options for redis cluster.
@@ -14,7 +21,8 @@ type RedisOptions struct {
 	Port                  int      `json:"port"`
 	Addrs                 []string `json:"addrs"                    mapstructure:"addrs"`
 	Username              string   `json:"username"                 mapstructure:"username"`
-	Password              string   `json:"password"                 mapstructure:"password"`
+	Password              string   `json:"-"                        mapstructure:"password"`
+	PasswordFile          string   `json:"password-file"            mapstructure:"password-file"`
 	Database              int      `json:"database"                 mapstructure:"database"`
 	MasterName            string   `json:"master-name"              mapstructure:"master-name"`
 	MaxIdle               int      `json:"optimisation-max-idle"    mapstructure:"optimisation-max-idle"`
@@ -23,6 +31,21 @@ type RedisOptions struct {
 	EnableCluster         bool     `json:"enable-cluster"           mapstructure:"enable-cluster"`
 	UseSSL                bool     `json:"use-ssl"                  mapstructure:"use-ssl"`
 	SSLInsecureSkipVerify bool     `json:"ssl-insecure-skip-verify" mapstructure:"ssl-insecure-skip-verify"`
+	CAFile                string   `json:"ssl-ca-file"              mapstructure:"ssl-ca-file"`
+	CertFile              string   `json:"ssl-cert-file"            mapstructure:"ssl-cert-file"`
+	KeyFile               string   `json:"ssl-key-file"             mapstructure:"ssl-key-file"`
+	MinTLSVersion         string   `json:"ssl-min-version"          mapstructure:"ssl-min-version"`
+	SentinelAddrs         []string `json:"sentinel-addrs"           mapstructure:"sentinel-addrs"`
+	SentinelUsername      string   `json:"sentinel-username"        mapstructure:"sentinel-username"`
+	SentinelPassword      string   `json:"-"                        mapstructure:"sentinel-password"`
+	SentinelPasswordFile  string   `json:"sentinel-password-file"   mapstructure:"sentinel-password-file"`
+
+	CircuitBreakerThreshold int           `json:"circuit-breaker-threshold" mapstructure:"circuit-breaker-threshold"`
+	CircuitBreakerTimeout   time.Duration `json:"circuit-breaker-timeout"   mapstructure:"circuit-breaker-timeout"`
+
+	KeyPrefix string `json:"key-prefix" mapstructure:"key-prefix"`
+
+	Type string `json:"type" mapstructure:"type"`
 }
 
 // NewRedisOptions create a `zero` value instance.
@@ -41,16 +64,103 @@ func NewRedisOptions() *RedisOptions {
 		EnableCluster:         false,
 		UseSSL:                false,
 		SSLInsecureSkipVerify: false,
+		CAFile:                "",
+		CertFile:              "",
+		KeyFile:               "",
+		MinTLSVersion:         "1.2",
+		SentinelAddrs:         []string{},
+		SentinelUsername:      "",
+		SentinelPassword:      "",
+
+		CircuitBreakerThreshold: 5,
+		CircuitBreakerTimeout:   10 * time.Second,
+
+		KeyPrefix: "",
+
+		Type: storage.TypeRedis,
 	}
 }
 
+// Complete reads the passwords from --redis.password-file and --redis.sentinel-password-file if set.
+func (o *RedisOptions) Complete() error {
+	var errs []error
+
+	if err := CompleteSecret(&o.Password, o.PasswordFile, "redis.password"); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := CompleteSecret(&o.SentinelPassword, o.SentinelPasswordFile, "redis.sentinel-password"); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.NewAggregate(errs)
+}
+
 // Validate verifies flags passed to RedisOptions.
 func (o *RedisOptions) Validate() []error {
-	errs := []error{}
+	errs := o.StorageConfig().Validate()
+
+	if !o.UseSSL {
+		if o.CAFile != "" || o.CertFile != "" || o.KeyFile != "" {
+			errs = append(errs, fmt.Errorf("--redis.ssl-ca-file, --redis.ssl-cert-file and --redis.ssl-key-file "+
+				"require --redis.use-ssl"))
+		}
+
+		return errs
+	}
+
+	// a client certificate can not be presented without its key.
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		errs = append(errs, fmt.Errorf("--redis.ssl-cert-file %q and --redis.ssl-key-file %q must be set together",
+			o.CertFile, o.KeyFile))
+
+		return errs
+	}
+
+	// build the tls config once at startup, so unreadable or mismatched files are reported early.
+	if _, err := o.TLSConfig(); err != nil {
+		errs = append(errs, fmt.Errorf("invalid redis tls options: %w", err))
+	}
 
 	return errs
 }
 
+// StorageConfig returns the config used to connect to redis.
+func (o *RedisOptions) StorageConfig() *storage.Config {
+	return &storage.Config{
+		Type:                  o.Type,
+		Host:                  o.Host,
+		Port:                  o.Port,
+		Addrs:                 o.Addrs,
+		MasterName:            o.MasterName,
+		Username:              o.Username,
+		Password:              o.Password,
+		Database:              o.Database,
+		MaxIdle:               o.MaxIdle,
+		MaxActive:             o.MaxActive,
+		Timeout:               o.Timeout,
+		EnableCluster:         o.EnableCluster,
+		UseSSL:                o.UseSSL,
+		SSLInsecureSkipVerify: o.SSLInsecureSkipVerify,
+		CAFile:                o.CAFile,
+		CertFile:              o.CertFile,
+		KeyFile:               o.KeyFile,
+		MinTLSVersion:         o.MinTLSVersion,
+		SentinelAddrs:         o.SentinelAddrs,
+		SentinelUsername:      o.SentinelUsername,
+		SentinelPassword:      o.SentinelPassword,
+
+		CircuitBreakerThreshold: o.CircuitBreakerThreshold,
+		CircuitBreakerTimeout:   o.CircuitBreakerTimeout,
+		KeyPrefix:               o.KeyPrefix,
+	}
+}
+
+// TLSConfig returns the tls config used to connect to redis, or nil if --redis.use-ssl is not set.
+func (o *RedisOptions) TLSConfig() (*tls.Config, error) {
+	return storage.NewTLSConfig(o.StorageConfig())
+}
+
 // AddFlags adds flags related to redis storage for a specific APIServer to the specified FlagSet.
 func (o *RedisOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.Host, "redis.host", o.Host, "Hostname of your Redis server.")
@@ -58,12 +168,28 @@ func (o *RedisOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringSliceVar(&o.Addrs, "redis.addrs", o.Addrs, "A set of redis address(format: 127.0.0.1:6379).")
 	fs.StringVar(&o.Username, "redis.username", o.Username, "Username for access to redis service.")
 	fs.StringVar(&o.Password, "redis.password", o.Password, "Optional auth password for Redis db.")
+	fs.StringVar(&o.PasswordFile, "redis.password-file", o.PasswordFile, ""+
+		"File containing the auth password for Redis db, e.g. a mounted secret, instead of --redis.password.")
 
 	fs.IntVar(&o.Database, "redis.database", o.Database, ""+
 		"By default, the database is 0. Setting the database is not supported with redis cluster. "+
 		"As such, if you have --redis.enable-cluster=true, then this value should be omitted or explicitly set to 0.")
 
-	fs.StringVar(&o.MasterName, "redis.master-name", o.MasterName, "The name of master redis instance.")
+	fs.StringVar(&o.MasterName, "redis.master-name", o.MasterName, ""+
+		"The name of master redis instance. If set, IAM connects to the master through the redis sentinels.")
+
+	fs.StringSliceVar(&o.SentinelAddrs, "redis.sentinel-addrs", o.SentinelAddrs, ""+
+		"A set of redis sentinel address(format: 127.0.0.1:26379), --redis.addrs is used if not set. "+
+		"Requires --redis.master-name.")
+
+	fs.StringVar(&o.SentinelUsername, "redis.sentinel-username", o.SentinelUsername, ""+
+		"Username for access to the redis sentinels, --redis.username only applies to the redis instances.")
+
+	fs.StringVar(&o.SentinelPassword, "redis.sentinel-password", o.SentinelPassword, ""+
+		"Optional auth password for the redis sentinels, --redis.password only applies to the redis instances.")
+
+	fs.StringVar(&o.SentinelPasswordFile, "redis.sentinel-password-file", o.SentinelPasswordFile, ""+
+		"File containing the auth password for the redis sentinels, instead of --redis.sentinel-password.")
 
 	fs.IntVar(&o.MaxIdle, "redis.optimisation-max-idle", o.MaxIdle, ""+
 		"This setting will configure how many connections are maintained in the pool when idle (no traffic). "+
@@ -85,4 +211,33 @@ func (o *RedisOptions) AddFlags(fs *pflag.FlagSet) {
 
 	fs.BoolVar(&o.SSLInsecureSkipVerify, "redis.ssl-insecure-skip-verify", o.SSLInsecureSkipVerify, ""+
 		"Allows usage of self-signed certificates when connecting to an encrypted Redis database.")
+
+	fs.StringVar(&o.CAFile, "redis.ssl-ca-file", o.CAFile, ""+
+		"PEM encoded CA bundle used to verify the certificate of Redis, the system CAs are used if not set.")
+
+	fs.StringVar(&o.CertFile, "redis.ssl-cert-file", o.CertFile, ""+
+		"PEM encoded client certificate presented to Redis, must be set together with --redis.ssl-key-file.")
+
+	fs.StringVar(&o.KeyFile, "redis.ssl-key-file", o.KeyFile, ""+
+		"PEM encoded private key of the client certificate set by --redis.ssl-cert-file.")
+
+	fs.StringVar(&o.MinTLSVersion, "redis.ssl-min-version", o.MinTLSVersion, ""+
+		"Minimum TLS version accepted when connecting to Redis, one of 1.0, 1.1, 1.2 and 1.3.")
+
+	fs.IntVar(&o.CircuitBreakerThreshold, "redis.circuit-breaker-threshold", o.CircuitBreakerThreshold, ""+
+		"Number of consecutive redis commands failing to reach Redis after which the commands fail fast, "+
+		"without waiting for the dial timeout. Set to 0 to disable the circuit breaker.")
+
+	fs.DurationVar(&o.CircuitBreakerTimeout, "redis.circuit-breaker-timeout", o.CircuitBreakerTimeout, ""+
+		"Duration the redis commands fail fast once the circuit breaker is open, a trial command is then let "+
+		"through, which closes the circuit breaker if it succeeds.")
+
+	fs.StringVar(&o.KeyPrefix, "redis.key-prefix", o.KeyPrefix, ""+
+		"Namespace of all the keys and pub/sub channels used in Redis, e.g. prod, so that several environments "+
+		"can share a Redis. A ':' is appended if it does not end with one of ':-_./'. All the IAM components "+
+		"sharing data through Redis must use the same prefix.")
+
+	fs.StringVar(&o.Type, "redis.type", o.Type, ""+
+		"Storage type, redis or memory. The memory storage keeps the data in the memory of the process, it is "+
+		"lost on exit and not shared with the other IAM components, use it only for tests and single binary demos.")
 }
@@ -0,0 +1,48 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadSecretFile returns the value of a sensitive option read from file, e.g. a kubernetes secret mounted
+// as a file, trimmed of the surrounding spaces and new lines. The empty files are rejected.
+func ReadSecretFile(file string) (string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	value := strings.TrimSpace(string(content))
+	if value == "" {
+		return "", fmt.Errorf("the file %s is empty", file)
+	}
+
+	return value, nil
+}
+
+// CompleteSecret sets value, of the sensitive option of flag, to the content of file if it is set. The
+// value can be set literally or by its file, not both.
+func CompleteSecret(value *string, file, flag string) error {
+	if file == "" {
+		return nil
+	}
+
+	if *value != "" {
+		return fmt.Errorf("--%s and --%s-file %q can not be both set", flag, flag, file)
+	}
+
+	secret, err := ReadSecretFile(file)
+	if err != nil {
+		return fmt.Errorf("--%s-file: %w", flag, err)
+	}
+
+	*value = secret
+
+	return nil
+}
@@ -5,9 +5,11 @@
 package options
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"path"
+	"strings"
 
 	"github.com/spf13/pflag"
 
@@ -16,13 +18,19 @@ import (
 
 // SecureServingOptions contains configuration items related to HTTPS server startup.
 type SecureServingOptions struct {
-	BindAddress string `json:"bind-address" mapstructure:"bind-address"`
+	BindAddress string `json:"bind-address"                    mapstructure:"bind-address"`
 	// BindPort is ignored when Listener is set, will serve HTTPS even with 0.
-	BindPort int `json:"bind-port"    mapstructure:"bind-port"`
+	BindPort int `json:"bind-port"                       mapstructure:"bind-port"`
 	// Required set to true means that BindPort cannot be zero.
 	Required bool
 	// ServerCert is the TLS cert info for serving secure traffic
-	ServerCert GeneratableKeyCert `json:"tls"          mapstructure:"tls"`
+	ServerCert GeneratableKeyCert `json:"tls"                             mapstructure:"tls"`
+	// MinTLSVersion is the name of the minimum tls version accepted, e.g. VersionTLS12.
+	MinTLSVersion string `json:"tls-min-version"                 mapstructure:"tls-min-version"`
+	// CipherSuites are the names of the TLS 1.0-1.2 cipher suites accepted, empty for the Go defaults.
+	CipherSuites []string `json:"tls-cipher-suites"               mapstructure:"tls-cipher-suites"`
+	// PreferServerCipherSuites selects the cipher suite in the order of CipherSuites.
+	PreferServerCipherSuites bool `json:"tls-prefer-server-cipher-suites" mapstructure:"tls-prefer-server-cipher-suites"`
 	// AdvertiseAddress net.IP
 }
 
@@ -58,22 +66,55 @@ func NewSecureServingOptions() *SecureServingOptions {
 			PairName:      "iam",
 			CertDirectory: "/var/run/iam",
 		},
+		MinTLSVersion: "VersionTLS12",
 	}
 }
 
 // ApplyTo applies the run options to the method receiver and returns self.
 func (s *SecureServingOptions) ApplyTo(c *server.Config) error {
 	// SecureServing is required to serve https
-	c.SecureServing = &server.SecureServingInfo{
+	info, err := s.servingInfo()
+	if err != nil {
+		return err
+	}
+
+	c.SecureServing = info
+
+	return nil
+}
+
+// TLSConfig returns the tls config of the https and grpc servers, without certificate.
+func (s *SecureServingOptions) TLSConfig() (*tls.Config, error) {
+	info, err := s.servingInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	return info.TLSConfig(), nil
+}
+
+func (s *SecureServingOptions) servingInfo() (*server.SecureServingInfo, error) {
+	minVersion, err := server.TLSVersion(s.MinTLSVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	cipherSuites, err := server.CipherSuites(s.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	return &server.SecureServingInfo{
 		BindAddress: s.BindAddress,
 		BindPort:    s.BindPort,
 		CertKey: server.CertKey{
 			CertFile: s.ServerCert.CertKey.CertFile,
 			KeyFile:  s.ServerCert.CertKey.KeyFile,
 		},
-	}
-
-	return nil
+		MinTLSVersion:            minVersion,
+		CipherSuites:             cipherSuites,
+		PreferServerCipherSuites: s.PreferServerCipherSuites,
+	}, nil
 }
 
 // Validate is used to parse and validate the parameters entered by the user at
@@ -97,6 +138,28 @@ func (s *SecureServingOptions) Validate() []error {
 		errors = append(errors, fmt.Errorf("--secure.bind-port %v must be between 0 and 65535, inclusive. 0 for turning off secure port", s.BindPort))
 	}
 
+	// the server can not present a certificate without its key.
+	keyCert := s.ServerCert.CertKey
+	if (keyCert.CertFile == "") != (keyCert.KeyFile == "") {
+		errors = append(errors, fmt.Errorf("--secure.tls.cert-key.cert-file %q and "+
+			"--secure.tls.cert-key.private-key-file %q must be set together", keyCert.CertFile, keyCert.KeyFile))
+	}
+
+	if _, err := server.TLSVersion(s.MinTLSVersion); err != nil {
+		errors = append(errors, fmt.Errorf("--secure.tls-min-version %q: %w", s.MinTLSVersion, err))
+	}
+
+	if _, err := server.CipherSuites(s.CipherSuites); err != nil {
+		errors = append(errors, fmt.Errorf("--secure.tls-cipher-suites %v: %w", s.CipherSuites, err))
+	}
+
+	// the https server fails to start if the cipher suites do not allow http/2.
+	if info, err := s.servingInfo(); err == nil {
+		if err := info.ValidateHTTP2(); err != nil {
+			errors = append(errors, fmt.Errorf("--secure.tls-cipher-suites %v can not serve http/2: %w", s.CipherSuites, err))
+		}
+	}
+
 	return errors
 }
 
@@ -131,6 +194,19 @@ func (s *SecureServingOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&s.ServerCert.CertKey.KeyFile, "secure.tls.cert-key.private-key-file",
 		s.ServerCert.CertKey.KeyFile, ""+
 			"File containing the default x509 private key matching --secure.tls.cert-key.cert-file.")
+
+	fs.StringVar(&s.MinTLSVersion, "secure.tls-min-version", s.MinTLSVersion, ""+
+		"Minimum TLS version accepted by the HTTPS and gRPC servers. "+
+		"Possible values: "+strings.Join(server.TLSVersionNames(), ", ")+".")
+
+	fs.StringSliceVar(&s.CipherSuites, "secure.tls-cipher-suites", s.CipherSuites, ""+
+		"Comma-separated list of the TLS 1.0-1.2 cipher suites accepted by the HTTPS and gRPC servers, "+
+		"the TLS 1.3 cipher suites are not configurable. If omitted, the default Go cipher suites are used. "+
+		"Possible values: "+strings.Join(server.CipherSuiteNames(), ", ")+".")
+
+	fs.BoolVar(&s.PreferServerCipherSuites, "secure.tls-prefer-server-cipher-suites", s.PreferServerCipherSuites, ""+
+		"Select the cipher suite in the order of --secure.tls-cipher-suites rather than the order of the client. "+
+		"The HTTPS server always does, as required by HTTP/2.")
 }
 
 // Complete fills in any fields not set that are required to have valid data.
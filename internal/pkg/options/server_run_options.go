@@ -5,16 +5,36 @@
 package options
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/spf13/pflag"
 
+	"github.com/marmotedu/iam/internal/pkg/middleware/cors"
 	"github.com/marmotedu/iam/internal/pkg/server"
 )
 
 // ServerRunOptions contains the options while running a generic api server.
 type ServerRunOptions struct {
-	Mode        string   `json:"mode"        mapstructure:"mode"`
-	Healthz     bool     `json:"healthz"     mapstructure:"healthz"`
-	Middlewares []string `json:"middlewares" mapstructure:"middlewares"`
+	Mode                 string        `json:"mode"                   mapstructure:"mode"`
+	Healthz              bool          `json:"healthz"                mapstructure:"healthz"`
+	Middlewares          []string      `json:"middlewares"            mapstructure:"middlewares"`
+	RequestTimeout       time.Duration `json:"request-timeout"        mapstructure:"request-timeout"`
+	ShutdownTimeout      time.Duration `json:"shutdown-timeout"       mapstructure:"shutdown-timeout"`
+	ReadinessGracePeriod time.Duration `json:"readiness-grace-period" mapstructure:"readiness-grace-period"`
+	ReadHeaderTimeout    time.Duration `json:"read-header-timeout"    mapstructure:"read-header-timeout"`
+	ReadTimeout          time.Duration `json:"read-timeout"           mapstructure:"read-timeout"`
+	WriteTimeout         time.Duration `json:"write-timeout"          mapstructure:"write-timeout"`
+	IdleTimeout          time.Duration `json:"idle-timeout"           mapstructure:"idle-timeout"`
+	MaxHeaderBytes       int           `json:"max-header-bytes"       mapstructure:"max-header-bytes"`
+	MaxRequestBodyBytes  int64         `json:"max-request-body-bytes" mapstructure:"max-request-body-bytes"`
+	MaxConnections       int           `json:"max-connections"        mapstructure:"max-connections"`
+	// AdminAllowedOrigins is read by the routers installing the admin apis, it is not part of server.Config.
+	AdminAllowedOrigins []string `json:"admin-allowed-origins" mapstructure:"admin-allowed-origins"`
+	// GracefulShutdownTimeout is read by the servers setting up their shutdown, it is not part of server.Config.
+	GracefulShutdownTimeout time.Duration `json:"graceful-shutdown-timeout" mapstructure:"graceful-shutdown-timeout"`
+	// WatchConfig is read by the servers setting up their configuration reloads, it is not part of server.Config.
+	WatchConfig bool `json:"watch-config" mapstructure:"watch-config"`
 }
 
 // NewServerRunOptions creates a new ServerRunOptions object with default parameters.
@@ -22,9 +42,21 @@ func NewServerRunOptions() *ServerRunOptions {
 	defaults := server.NewConfig()
 
 	return &ServerRunOptions{
-		Mode:        defaults.Mode,
-		Healthz:     defaults.Healthz,
-		Middlewares: defaults.Middlewares,
+		Mode:                 defaults.Mode,
+		Healthz:              defaults.Healthz,
+		Middlewares:          defaults.Middlewares,
+		RequestTimeout:       defaults.RequestTimeout,
+		ShutdownTimeout:      defaults.ShutdownTimeout,
+		ReadinessGracePeriod: defaults.ReadinessGracePeriod,
+		ReadHeaderTimeout:    defaults.ReadHeaderTimeout,
+		ReadTimeout:          defaults.ReadTimeout,
+		WriteTimeout:         defaults.WriteTimeout,
+		IdleTimeout:          defaults.IdleTimeout,
+		MaxHeaderBytes:       defaults.MaxHeaderBytes,
+		MaxRequestBodyBytes:  defaults.MaxRequestBodyBytes,
+		MaxConnections:       defaults.MaxConnections,
+		// the default termination grace period of the kubernetes pods.
+		GracefulShutdownTimeout: 30 * time.Second,
 	}
 }
 
@@ -33,6 +65,16 @@ func (s *ServerRunOptions) ApplyTo(c *server.Config) error {
 	c.Mode = s.Mode
 	c.Healthz = s.Healthz
 	c.Middlewares = s.Middlewares
+	c.RequestTimeout = s.RequestTimeout
+	c.ShutdownTimeout = s.ShutdownTimeout
+	c.ReadinessGracePeriod = s.ReadinessGracePeriod
+	c.ReadHeaderTimeout = s.ReadHeaderTimeout
+	c.ReadTimeout = s.ReadTimeout
+	c.WriteTimeout = s.WriteTimeout
+	c.IdleTimeout = s.IdleTimeout
+	c.MaxHeaderBytes = s.MaxHeaderBytes
+	c.MaxRequestBodyBytes = s.MaxRequestBodyBytes
+	c.MaxConnections = s.MaxConnections
 
 	return nil
 }
@@ -41,6 +83,66 @@ func (s *ServerRunOptions) ApplyTo(c *server.Config) error {
 func (s *ServerRunOptions) Validate() []error {
 	errors := []error{}
 
+	if s.RequestTimeout < 0 {
+		errors = append(errors, fmt.Errorf("--server.request-timeout %v can not be negative", s.RequestTimeout))
+	}
+
+	if s.ShutdownTimeout <= 0 {
+		errors = append(errors, fmt.Errorf("--server.shutdown-timeout %v must be positive", s.ShutdownTimeout))
+	}
+
+	if s.ReadinessGracePeriod < 0 {
+		errors = append(errors, fmt.Errorf("--server.readiness-grace-period %v can not be negative",
+			s.ReadinessGracePeriod))
+	}
+
+	// the deadline must leave the time to stop the readiness and drain the in-flight requests.
+	if s.GracefulShutdownTimeout < 0 {
+		errors = append(errors, fmt.Errorf("--server.graceful-shutdown-timeout %v can not be negative",
+			s.GracefulShutdownTimeout))
+	} else if s.GracefulShutdownTimeout > 0 && s.GracefulShutdownTimeout <= s.ReadinessGracePeriod+s.ShutdownTimeout {
+		errors = append(errors, fmt.Errorf("--server.graceful-shutdown-timeout %v must be longer than "+
+			"--server.readiness-grace-period %v plus --server.shutdown-timeout %v",
+			s.GracefulShutdownTimeout, s.ReadinessGracePeriod, s.ShutdownTimeout))
+	}
+
+	for _, timeout := range []struct {
+		flag  string
+		value time.Duration
+	}{
+		{"read-header-timeout", s.ReadHeaderTimeout},
+		{"read-timeout", s.ReadTimeout},
+		{"write-timeout", s.WriteTimeout},
+		{"idle-timeout", s.IdleTimeout},
+	} {
+		if timeout.value < 0 {
+			errors = append(errors, fmt.Errorf("--server.%s %v can not be negative", timeout.flag, timeout.value))
+		}
+	}
+
+	// the requests timed out by the server must still be able to write their 503.
+	if s.WriteTimeout > 0 && s.RequestTimeout > 0 && s.WriteTimeout <= s.RequestTimeout {
+		errors = append(errors, fmt.Errorf("--server.write-timeout %v must be longer than --server.request-timeout %v",
+			s.WriteTimeout, s.RequestTimeout))
+	}
+
+	if s.MaxHeaderBytes < 0 {
+		errors = append(errors, fmt.Errorf("--server.max-header-bytes %d can not be negative", s.MaxHeaderBytes))
+	}
+
+	if s.MaxRequestBodyBytes < 0 {
+		errors = append(errors, fmt.Errorf("--server.max-request-body-bytes %d can not be negative",
+			s.MaxRequestBodyBytes))
+	}
+
+	if s.MaxConnections < 0 {
+		errors = append(errors, fmt.Errorf("--server.max-connections %d can not be negative", s.MaxConnections))
+	}
+
+	if err := cors.ValidateOrigins(s.AdminAllowedOrigins); err != nil {
+		errors = append(errors, fmt.Errorf("--server.admin-allowed-origins %v is invalid: %w", s.AdminAllowedOrigins, err))
+	}
+
 	return errors
 }
 
@@ -52,8 +154,65 @@ func (s *ServerRunOptions) AddFlags(fs *pflag.FlagSet) {
 		"Start the server in a specified server mode. Supported server mode: debug, test, release.")
 
 	fs.BoolVar(&s.Healthz, "server.healthz", s.Healthz, ""+
-		"Add self readiness check and install /healthz router.")
+		"Add self readiness check and install /healthz and /readyz routers.")
 
 	fs.StringSliceVar(&s.Middlewares, "server.middlewares", s.Middlewares, ""+
 		"List of allowed middlewares for server, comma separated. If this list is empty default middlewares will be used.")
+
+	fs.DurationVar(&s.RequestTimeout, "server.request-timeout", s.RequestTimeout, ""+
+		"The maximum duration of a request, after which its context is canceled and 503 is returned. "+
+		"The pprof and websocket requests are not limited. Zero means no timeout.")
+
+	fs.DurationVar(&s.ShutdownTimeout, "server.shutdown-timeout", s.ShutdownTimeout, ""+
+		"The maximum duration of the graceful shutdown, during which /readyz fails and the in-flight requests "+
+		"complete. The requests still in flight after it are dropped.")
+
+	fs.DurationVar(&s.ReadinessGracePeriod, "server.readiness-grace-period", s.ReadinessGracePeriod, ""+
+		"The time /readyz fails at the start of the graceful shutdown before the listeners are closed, "+
+		"for the load balancers to stop sending new requests. It is shortened by the time already spent "+
+		"not ready, e.g. since a preStop hook.")
+
+	fs.DurationVar(&s.GracefulShutdownTimeout, "server.graceful-shutdown-timeout", s.GracefulShutdownTimeout, ""+
+		"The deadline of the whole graceful shutdown, from the readiness stop to the closing of the stores, "+
+		"after which the shutdown steps still running are abandoned and the process exits. It should be "+
+		"shorter than the termination grace period of the pod, minus the preStop delay. Zero means no deadline.")
+
+	fs.BoolVar(&s.WatchConfig, "server.watch-config", s.WatchConfig, ""+
+		"Reload the configuration file once it changed, as on SIGHUP. The new configuration is validated "+
+		"first and rejected wholesale if invalid. The changes which can be applied while running, e.g. of the "+
+		"log level or of the rate limits, are applied, the others are logged as requiring a restart.")
+
+	fs.DurationVar(&s.ReadHeaderTimeout, "server.read-header-timeout", s.ReadHeaderTimeout, ""+
+		"The maximum duration of reading the headers of a request, after which the connection is closed. "+
+		"Zero means no timeout.")
+
+	fs.DurationVar(&s.ReadTimeout, "server.read-timeout", s.ReadTimeout, ""+
+		"The maximum duration of reading a request, including its body, after which the connection is closed. "+
+		"Zero means no timeout.")
+
+	fs.DurationVar(&s.WriteTimeout, "server.write-timeout", s.WriteTimeout, ""+
+		"The maximum duration from the end of reading the headers of a request to the end of writing its "+
+		"response, after which the connection is closed. It must be longer than --server.request-timeout. "+
+		"Zero means no timeout.")
+
+	fs.DurationVar(&s.IdleTimeout, "server.idle-timeout", s.IdleTimeout, ""+
+		"The maximum duration a keep-alive connection waits for its next request, after which it is closed. "+
+		"Zero means --server.read-timeout.")
+
+	fs.IntVar(&s.MaxHeaderBytes, "server.max-header-bytes", s.MaxHeaderBytes, ""+
+		"The maximum size of the headers of a request, 431 is returned for the larger ones.")
+
+	fs.Int64Var(&s.MaxRequestBodyBytes, "server.max-request-body-bytes", s.MaxRequestBodyBytes, ""+
+		"The maximum size of the body of a request, 413 is returned for the larger ones whatever their "+
+		"encoding, chunked and multipart included. Some routes, e.g. the batch ones, accept larger "+
+		"bodies. Zero means no limit.")
+
+	fs.IntVar(&s.MaxConnections, "server.max-connections", s.MaxConnections, ""+
+		"The maximum number of http and https connections open. The new connections over it are closed, "+
+		"after a 503 response over http, and counted by iam_http_rejected_connections_total. "+
+		"Zero means no limit.")
+
+	fs.StringSliceVar(&s.AdminAllowedOrigins, "server.admin-allowed-origins", s.AdminAllowedOrigins, ""+
+		"List of the origins allowed to send cross-origin requests to the admin apis, comma separated. "+
+		"* is not allowed, no cross-origin request is allowed if this list is empty.")
 }
@@ -0,0 +1,62 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/marmotedu/iam/internal/pkg/jwt"
+)
+
+// ServiceTokenOptions contains configuration items related to the service tokens issued to the
+// internal services.
+type ServiceTokenOptions struct {
+	PrivateKeyFile string        `json:"private-key-file" mapstructure:"private-key-file"`
+	Timeout        time.Duration `json:"timeout"          mapstructure:"timeout"`
+}
+
+// NewServiceTokenOptions creates a ServiceTokenOptions object with default parameters.
+func NewServiceTokenOptions() *ServiceTokenOptions {
+	return &ServiceTokenOptions{
+		PrivateKeyFile: "",
+		Timeout:        5 * time.Minute,
+	}
+}
+
+// Validate is used to parse and validate the parameters entered by the user at
+// the command line when the program starts.
+func (o *ServiceTokenOptions) Validate() []error {
+	errors := []error{}
+
+	if o.Timeout <= 0 {
+		errors = append(errors, fmt.Errorf("--service-token.timeout %v must be greater than 0", o.Timeout))
+	}
+
+	if o.PrivateKeyFile != "" {
+		if _, err := jwt.LoadRSAPrivateKey(o.PrivateKeyFile); err != nil {
+			errors = append(errors, fmt.Errorf("--service-token.private-key-file: %w", err))
+		}
+	}
+
+	return errors
+}
+
+// AddFlags adds flags related to the service tokens for a specific api server to the
+// specified FlagSet.
+func (o *ServiceTokenOptions) AddFlags(fs *pflag.FlagSet) {
+	if fs == nil {
+		return
+	}
+
+	fs.StringVar(&o.PrivateKeyFile, "service-token.private-key-file", o.PrivateKeyFile, ""+
+		"File containing the PEM encoded rsa private key used to sign the service tokens, its public key "+
+		"must be registered to the servers accepting them. The service tokens are not issued if not set.")
+
+	fs.DurationVar(&o.Timeout, "service-token.timeout", o.Timeout, ""+
+		"Lifetime of the service tokens, they are short-lived as they can not be revoked.")
+}
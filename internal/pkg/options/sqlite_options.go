@@ -0,0 +1,46 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// SqliteOptions defines options for sqlite database.
+type SqliteOptions struct {
+	Path     string `json:"path"      mapstructure:"path"`
+	LogLevel int    `json:"log-level" mapstructure:"log-level"`
+}
+
+// NewSqliteOptions create a `zero` value instance.
+func NewSqliteOptions() *SqliteOptions {
+	return &SqliteOptions{
+		Path:     ":memory:",
+		LogLevel: 1, // Silent
+	}
+}
+
+// Validate verifies flags passed to SqliteOptions.
+func (o *SqliteOptions) Validate() []error {
+	errs := []error{}
+
+	if o.Path == "" {
+		errs = append(errs, fmt.Errorf("--sqlite.path can not be empty"))
+	}
+
+	return errs
+}
+
+// AddFlags adds flags related to sqlite storage for a specific APIServer to the specified FlagSet.
+func (o *SqliteOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Path, "sqlite.path", o.Path, ""+
+		"The sqlite database file, used when --datastore.engine is sqlite. Its pending migrations are "+
+		"applied when it is opened. The database is kept in memory and lost on exit if it is :memory:.")
+
+	fs.IntVar(&o.LogLevel, "sqlite.log-mode", o.LogLevel, ""+
+		"Specify gorm log level.")
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"sync"
+)
+
+// CertificateProvider serves the certificate of its files to the tls servers, see GetCertificate.
+// The certificate can be reloaded while serving, e.g. once renewed, without restarting the servers.
+type CertificateProvider struct {
+	certFile string
+	keyFile  string
+
+	lock sync.RWMutex
+	cert *tls.Certificate
+}
+
+// NewCertificateProvider creates a CertificateProvider of the PEM-encoded certificate and private key
+// files, it returns an error if they can not be loaded.
+func NewCertificateProvider(certFile, keyFile string) (*CertificateProvider, error) {
+	p := &CertificateProvider{certFile: certFile, keyFile: keyFile}
+	if _, err := p.Reload(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// GetCertificate returns the certificate loaded last, it is meant for tls.Config.GetCertificate.
+func (p *CertificateProvider) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.cert, nil
+}
+
+// Reload re-reads the certificate files, and returns whether the certificate served changed. The
+// certificate served is kept if the files are invalid, e.g. while they are being renewed.
+func (p *CertificateProvider) Reload() (bool, error) {
+	cert, err := tls.LoadX509KeyPair(p.certFile, p.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("load the certificate %s: %w", p.certFile, err)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	changed := p.cert == nil || !bytes.Equal(p.cert.Certificate[0], cert.Certificate[0])
+	p.cert = &cert
+
+	return changed, nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a self-signed certificate of commonName and its key to certFile and keyFile.
+func writeCertificate(t *testing.T, certFile, keyFile, commonName string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey() error = %v", err)
+	}
+
+	for file, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		if err := os.WriteFile(file, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+}
+
+func TestCertificateProvider_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "iam.crt"), filepath.Join(dir, "iam.key")
+
+	if _, err := NewCertificateProvider(certFile, keyFile); err == nil {
+		t.Fatal("NewCertificateProvider() of missing files succeeded")
+	}
+
+	writeCertificate(t, certFile, keyFile, "iam-v1")
+
+	p, err := NewCertificateProvider(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewCertificateProvider() error = %v", err)
+	}
+
+	served := func() []byte {
+		cert, _ := p.GetCertificate(nil)
+
+		return cert.Certificate[0]
+	}
+	first := served()
+
+	tests := []struct {
+		name        string
+		update      func()
+		wantChanged bool
+		wantErr     bool
+		// wantFirst is whether the first certificate is still served.
+		wantFirst bool
+	}{
+		{
+			name:      "unchanged",
+			update:    func() {},
+			wantFirst: true,
+		},
+		{
+			name: "invalid files kept",
+			update: func() {
+				if err := os.WriteFile(certFile, []byte("renewing"), 0o600); err != nil {
+					t.Fatalf("WriteFile() error = %v", err)
+				}
+			},
+			wantErr:   true,
+			wantFirst: true,
+		},
+		{
+			name:        "renewed",
+			update:      func() { writeCertificate(t, certFile, keyFile, "iam-v2") },
+			wantChanged: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.update()
+
+			changed, err := p.Reload()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Reload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if changed != tt.wantChanged {
+				t.Errorf("Reload() changed = %v, want %v", changed, tt.wantChanged)
+			}
+
+			if got := bytes.Equal(served(), first); got != tt.wantFirst {
+				t.Errorf("first certificate served = %v, want %v", got, tt.wantFirst)
+			}
+		})
+	}
+}
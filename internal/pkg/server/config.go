@@ -15,6 +15,7 @@ import (
 	"github.com/marmotedu/component-base/pkg/util/homedir"
 	"github.com/spf13/viper"
 
+	"github.com/marmotedu/iam/internal/pkg/middleware"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
@@ -34,9 +35,25 @@ type Config struct {
 	Jwt             *JwtInfo
 	Mode            string
 	Middlewares     []string
-	Healthz         bool
-	EnableProfiling bool
-	EnableMetrics   bool
+	RequestTimeout  time.Duration
+	ShutdownTimeout time.Duration
+	// ReadinessGracePeriod is the time /readyz fails on shutdown before the listeners are closed.
+	ReadinessGracePeriod      time.Duration
+	ReadHeaderTimeout         time.Duration
+	ReadTimeout               time.Duration
+	WriteTimeout              time.Duration
+	IdleTimeout               time.Duration
+	MaxHeaderBytes            int
+	MaxRequestBodyBytes       int64
+	MaxConnections            int
+	AccessLog                 *middleware.AccessLogConfig
+	PanicAlert                middleware.PanicAlertFunc
+	EnableH2C                 bool
+	HTTP2MaxConcurrentStreams uint32
+	HTTP2MaxReadFrameSize     uint32
+	Healthz                   bool
+	EnableProfiling           bool
+	EnableMetrics             bool
 }
 
 // CertKey contains configuration items related to certificate.
@@ -52,6 +69,13 @@ type SecureServingInfo struct {
 	BindAddress string
 	BindPort    int
 	CertKey     CertKey
+	// MinTLSVersion is the minimum tls version accepted, zero means the crypto/tls default.
+	MinTLSVersion uint16
+	// CipherSuites are the TLS 1.0-1.2 cipher suites accepted, nil means the crypto/tls defaults.
+	CipherSuites []uint16
+	// PreferServerCipherSuites selects the cipher suite in the order of CipherSuites rather than the
+	// client's. The https server always does, as required by http/2.
+	PreferServerCipherSuites bool
 }
 
 // Address join host IP address and host port number into a address string, like: 0.0.0.0:8443.
@@ -61,7 +85,10 @@ func (s *SecureServingInfo) Address() string {
 
 // InsecureServingInfo holds configuration of the insecure http server.
 type InsecureServingInfo struct {
+	// Address is the tcp address listened on, the server does not listen on tcp if it is empty.
 	Address string
+	// Socket is the unix socket listened on, in addition to Address, if not nil.
+	Socket *UnixSocketInfo
 }
 
 // JwtInfo defines jwt fields used to create jwt authentication middleware.
@@ -74,20 +101,40 @@ type JwtInfo struct {
 	Timeout time.Duration
 	// defaults to zero
 	MaxRefresh time.Duration
+	// defaults to "HS256"
+	Algorithm string
+	// defaults to 90 days, zero disables the rotation
+	RotationInterval time.Duration
+	// defaults to 2
+	RetainedKeys int
 }
 
 // NewConfig returns a Config struct with the default values.
 func NewConfig() *Config {
 	return &Config{
-		Healthz:         true,
-		Mode:            gin.ReleaseMode,
-		Middlewares:     []string{},
-		EnableProfiling: true,
-		EnableMetrics:   true,
+		Healthz:                   true,
+		Mode:                      gin.ReleaseMode,
+		Middlewares:               []string{},
+		RequestTimeout:            30 * time.Second,
+		ShutdownTimeout:           10 * time.Second,
+		ReadinessGracePeriod:      5 * time.Second,
+		ReadHeaderTimeout:         10 * time.Second,
+		ReadTimeout:               30 * time.Second,
+		WriteTimeout:              60 * time.Second,
+		IdleTimeout:               120 * time.Second,
+		MaxHeaderBytes:            1 << 20,
+		MaxRequestBodyBytes:       1 << 20,
+		HTTP2MaxConcurrentStreams: 250,
+		HTTP2MaxReadFrameSize:     1 << 20,
+		EnableProfiling:           true,
+		EnableMetrics:             true,
 		Jwt: &JwtInfo{
-			Realm:      "iam jwt",
-			Timeout:    1 * time.Hour,
-			MaxRefresh: 1 * time.Hour,
+			Realm:            "iam jwt",
+			Timeout:          1 * time.Hour,
+			MaxRefresh:       1 * time.Hour,
+			Algorithm:        "HS256",
+			RotationInterval: 90 * 24 * time.Hour,
+			RetainedKeys:     2,
 		},
 	}
 }
@@ -106,17 +153,36 @@ func (c *Config) Complete() CompletedConfig {
 // New returns a new instance of GenericAPIServer from the given config.
 func (c CompletedConfig) New() (*GenericAPIServer, error) {
 	s := &GenericAPIServer{
-		SecureServingInfo:   c.SecureServing,
-		InsecureServingInfo: c.InsecureServing,
-		mode:                c.Mode,
-		healthz:             c.Healthz,
-		enableMetrics:       c.EnableMetrics,
-		enableProfiling:     c.EnableProfiling,
-		middlewares:         c.Middlewares,
-		Engine:              gin.New(),
+		SecureServingInfo:         c.SecureServing,
+		InsecureServingInfo:       c.InsecureServing,
+		mode:                      c.Mode,
+		healthz:                   c.Healthz,
+		enableMetrics:             c.EnableMetrics,
+		enableProfiling:           c.EnableProfiling,
+		middlewares:               c.Middlewares,
+		RequestTimeout:            c.RequestTimeout,
+		ShutdownTimeout:           c.ShutdownTimeout,
+		ReadinessGracePeriod:      c.ReadinessGracePeriod,
+		ReadHeaderTimeout:         c.ReadHeaderTimeout,
+		ReadTimeout:               c.ReadTimeout,
+		WriteTimeout:              c.WriteTimeout,
+		IdleTimeout:               c.IdleTimeout,
+		MaxHeaderBytes:            c.MaxHeaderBytes,
+		MaxRequestBodyBytes:       c.MaxRequestBodyBytes,
+		MaxConnections:            c.MaxConnections,
+		AccessLog:                 c.AccessLog,
+		PanicAlert:                c.PanicAlert,
+		EnableH2C:                 c.EnableH2C,
+		HTTP2MaxConcurrentStreams: c.HTTP2MaxConcurrentStreams,
+		HTTP2MaxReadFrameSize:     c.HTTP2MaxReadFrameSize,
+		Engine:                    gin.New(),
+		drained:                   make(chan struct{}),
 	}
+	s.lifecycle.start()
 
-	initGenericAPIServer(s)
+	if err := initGenericAPIServer(s); err != nil {
+		return nil, err
+	}
 
 	return s, nil
 }
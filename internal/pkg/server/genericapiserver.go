@@ -10,19 +10,26 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-contrib/pprof"
 	"github.com/gin-gonic/gin"
 	"github.com/marmotedu/component-base/pkg/core"
 	"github.com/marmotedu/component-base/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
 	ginprometheus "github.com/zsais/go-gin-prometheus"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/marmotedu/iam/internal/pkg/middleware"
+	"github.com/marmotedu/iam/internal/pkg/middleware/timeout"
+	"github.com/marmotedu/iam/pkg/app/sdnotify"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
+// registerRecoveryMetrics registers middleware.RecoveredPanics once, whatever the number of servers.
+var registerRecoveryMetrics sync.Once
+
 // GenericAPIServer contains state for an iam api server.
 // type GenericAPIServer gin.Engine.
 type GenericAPIServer struct {
@@ -35,40 +42,105 @@ type GenericAPIServer struct {
 	InsecureServingInfo *InsecureServingInfo
 
 	// ShutdownTimeout is the timeout used for server shutdown. This specifies the timeout before server
-	// gracefully shutdown returns.
+	// gracefully shutdown returns, the callers of Shutdown derive its context from it.
 	ShutdownTimeout time.Duration
 
+	// RequestTimeout is the timeout after which the context of a request is canceled, zero means no timeout.
+	RequestTimeout time.Duration
+
+	// ReadinessGracePeriod is the time /readyz fails before the listeners are closed on shutdown, see
+	// StopReadinessCallback.
+	ReadinessGracePeriod time.Duration
+
+	// ReadHeaderTimeout, ReadTimeout, WriteTimeout, IdleTimeout and MaxHeaderBytes configure the http
+	// servers, zero means no limit, or the Go default for MaxHeaderBytes.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+
+	// MaxRequestBodyBytes is the maximum size of the request bodies, zero means no limit. The routes
+	// receiving larger bodies override it with middleware.BodyLimit.
+	MaxRequestBodyBytes int64
+
+	// AccessLog configures the structured access log of the requests, nil disables it.
+	AccessLog *middleware.AccessLogConfig
+	// accessLogSampler samples the access log, its rate is changed by the configuration reloads.
+	accessLogSampler *middleware.Sampler
+
+	// PanicAlert is called with each panic recovered while serving a request, nil disables it.
+	PanicAlert middleware.PanicAlertFunc
+
+	// MaxConnections is the maximum number of http and https connections open, zero means no limit.
+	MaxConnections int
+
+	// EnableH2C makes the insecure server accept the http/2 cleartext connections.
+	EnableH2C bool
+	// HTTP2MaxConcurrentStreams and HTTP2MaxReadFrameSize tune the http/2 connections.
+	HTTP2MaxConcurrentStreams uint32
+	HTTP2MaxReadFrameSize     uint32
+
 	*gin.Engine
 	healthz         bool
 	enableMetrics   bool
 	enableProfiling bool
+	// healthzChecks are run by /healthz and /readyz, readyzChecks only by /readyz.
+	healthzChecks healthChecks
+	readyzChecks  healthChecks
 	// wrapper for gin.Engine
 
 	insecureServer, secureServer *http.Server
+	// connections is the number of connections open, when limited by MaxConnections.
+	connections int64
+
+	// readiness is not ready once StopReadiness or Shutdown is called, /readyz fails from then on.
+	readiness Readiness
+	// drained is closed once Shutdown returned.
+	drained     chan struct{}
+	drainedOnce sync.Once
+
+	lock sync.Mutex
+	// stopping is set once systemd is notified of the shutdown, stopWatchdog stops notifying its watchdog.
+	stopping     bool
+	stopWatchdog context.CancelFunc
+	// certificates serves the certificate of the https server, once started.
+	certificates *CertificateProvider
+
+	// lifecycle is the state of the server: starting, ready, degraded or draining.
+	lifecycle lifecycle
 }
 
-func initGenericAPIServer(s *GenericAPIServer) {
+func initGenericAPIServer(s *GenericAPIServer) error {
 	// do some setup
 	// s.GET(path, ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	s.Setup()
-	s.InstallMiddlewares()
+	if err := s.InstallMiddlewares(); err != nil {
+		return err
+	}
+
 	s.InstallAPIs()
+
+	return nil
 }
 
 // InstallAPIs install generic apis.
 func (s *GenericAPIServer) InstallAPIs() {
 	// install healthz handler
 	if s.healthz {
-		s.GET("/healthz", func(c *gin.Context) {
-			core.WriteResponse(c, nil, map[string]string{"status": "ok"})
-		})
+		s.GET("/healthz", s.healthzHandler(false))
+		s.GET("/readyz", s.healthzHandler(true))
+		s.GET("/debug/state", s.stateHandler)
 	}
 
 	// install metric handler
 	if s.enableMetrics {
 		prometheus := ginprometheus.NewPrometheus("gin")
 		prometheus.Use(s.Engine)
+
+		registerRecoveryMetrics.Do(registerRecoveredPanics)
+		registerStateMetrics.Do(registerServerState)
 	}
 
 	// install pprof handler
@@ -81,6 +153,10 @@ func (s *GenericAPIServer) InstallAPIs() {
 	})
 }
 
+func registerRecoveredPanics() {
+	prometheus.MustRegister(middleware.RecoveredPanics)
+}
+
 // Setup do some setup work for gin engine.
 func (s *GenericAPIServer) Setup() {
 	gin.SetMode(s.mode)
@@ -89,13 +165,40 @@ func (s *GenericAPIServer) Setup() {
 	}
 }
 
-// InstallMiddlewares install generic middlewares.
-func (s *GenericAPIServer) InstallMiddlewares() {
-	// necessary middlewares
-	s.Use(middleware.RequestID())
-	s.Use(middleware.Context())
+// InstallMiddlewares install generic middlewares, ordered by the dependencies between them.
+func (s *GenericAPIServer) InstallMiddlewares() error {
+	chain := NewMiddlewareChain()
+
+	// necessary middlewares, the recovery first to recover the panics of the other middlewares too.
+	chain.Add("recovery", nil, middleware.RecoveryWithAlert(s.PanicAlert))
+	chain.Add("requestid", []string{"recovery"}, middleware.RequestID())
+	chain.Add("context", []string{"requestid"}, middleware.Context())
+
+	last := "context"
+	// the requests are logged once the other middlewares and the handlers returned.
+	if s.AccessLog != nil {
+		config := *s.AccessLog
+		if config.Sampler == nil {
+			config.Sampler = middleware.NewSampler(config.SampleRate)
+		}
 
-	// install custom middlewares
+		s.accessLogSampler = config.Sampler
+		chain.Add("accesslog", []string{last}, middleware.AccessLog(config))
+		last = "accesslog"
+	}
+
+	// the bodies are limited before the custom middlewares, which can read them, e.g. dump.
+	if s.MaxRequestBodyBytes > 0 {
+		chain.Add("bodylimit", []string{last}, middleware.BodyLimit(s.MaxRequestBodyBytes))
+		last = "bodylimit"
+	}
+
+	if s.RequestTimeout > 0 {
+		chain.Add("timeout", []string{last}, timeout.New(s.RequestTimeout, "/debug/pprof"))
+		last = "timeout"
+	}
+
+	// install custom middlewares, after the necessary ones
 	for _, m := range s.middlewares {
 		mw, ok := middleware.Middlewares[m]
 		if !ok {
@@ -104,8 +207,53 @@ func (s *GenericAPIServer) InstallMiddlewares() {
 			continue
 		}
 
+		// the necessary middlewares, e.g. recovery, are installed whether they are listed or not.
+		if chain.has(m) {
+			log.Debugf("middleware %s is already installed", m)
+
+			continue
+		}
+
 		log.Infof("install middleware: %s", m)
-		s.Use(mw)
+		chain.Add(m, []string{last}, mw)
+	}
+
+	middlewares, err := chain.Build()
+	if err != nil {
+		return err
+	}
+
+	s.Use(middlewares...)
+
+	return nil
+}
+
+// Use registers middlewares which run before the route handlers, for the routes installed afterwards.
+// Custom middlewares must be registered before the server installs its routes, i.e. before PrepareRun.
+func (s *GenericAPIServer) Use(middlewares ...gin.HandlerFunc) {
+	s.Engine.Use(middlewares...)
+}
+
+// UseAfterRoute registers middlewares which only run once the request matched a route, before the
+// route handlers. The matched route is set in the gin context under middleware.RouteKey. Unlike the
+// middlewares registered by Use, they are skipped by the requests without route, e.g. 404.
+func (s *GenericAPIServer) UseAfterRoute(middlewares ...gin.HandlerFunc) {
+	for _, mw := range middlewares {
+		s.Engine.Use(afterRoute(mw))
+	}
+}
+
+func afterRoute(mw gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			c.Next()
+
+			return
+		}
+
+		c.Set(middleware.RouteKey, route)
+		mw(c)
 	}
 }
 
@@ -122,23 +270,15 @@ func (s *GenericAPIServer) PrepareRun() preparedGenericAPIServer {
 
 // Run spawns the http server. It only returns when the port cannot be listened on initially.
 func (s *GenericAPIServer) Run() error {
-	// For scalability, use custom HTTP configuration mode here
-	s.insecureServer = &http.Server{
-		Addr:    s.InsecureServingInfo.Address,
-		Handler: s,
-		// ReadTimeout:    10 * time.Second,
-		// WriteTimeout:   10 * time.Second,
-		// MaxHeaderBytes: 1 << 20,
-
+	s.insecureServer = s.newHTTPServer(s.InsecureServingInfo.Address)
+	if s.EnableH2C {
+		s.enableH2C(s.insecureServer)
 	}
 
-	// For scalability, use custom HTTP configuration mode here
-	s.secureServer = &http.Server{
-		Addr:    s.SecureServingInfo.Address(),
-		Handler: s,
-		// ReadTimeout:    10 * time.Second,
-		// WriteTimeout:   10 * time.Second,
-		// MaxHeaderBytes: 1 << 20,
+	s.secureServer = s.newHTTPServer(s.SecureServingInfo.Address())
+	s.secureServer.TLSConfig = s.SecureServingInfo.TLSConfig()
+	if err := s.configureHTTP2(s.secureServer); err != nil {
+		return err
 	}
 
 	var eg errgroup.Group
@@ -146,9 +286,20 @@ func (s *GenericAPIServer) Run() error {
 	// Initializing the server in a goroutine so that
 	// it won't block the graceful shutdown handling below
 	eg.Go(func() error {
+		if s.InsecureServingInfo.Address == "" {
+			return nil
+		}
+
 		log.Infof("Start to listening the incoming requests on http address: %s", s.InsecureServingInfo.Address)
 
-		if err := s.insecureServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		ln, err := s.listen(s.InsecureServingInfo.Address, false)
+		if err != nil {
+			log.Fatal(err.Error())
+
+			return err
+		}
+
+		if err := s.insecureServer.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatal(err.Error())
 
 			return err
@@ -159,6 +310,32 @@ func (s *GenericAPIServer) Run() error {
 		return nil
 	})
 
+	eg.Go(func() error {
+		socket := s.InsecureServingInfo.Socket
+		if socket == nil {
+			return nil
+		}
+
+		log.Infof("Start to listening the incoming requests on unix socket: %s", socket.Path)
+
+		ln, err := s.listenUnix(socket)
+		if err != nil {
+			log.Fatal(err.Error())
+
+			return err
+		}
+
+		if err := s.insecureServer.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err.Error())
+
+			return err
+		}
+
+		log.Infof("Server on %s stopped", socket.Path)
+
+		return nil
+	})
+
 	eg.Go(func() error {
 		key, cert := s.SecureServingInfo.CertKey.KeyFile, s.SecureServingInfo.CertKey.CertFile
 		if cert == "" || key == "" || s.SecureServingInfo.BindPort == 0 {
@@ -167,7 +344,28 @@ func (s *GenericAPIServer) Run() error {
 
 		log.Infof("Start to listening the incoming requests on https address: %s", s.SecureServingInfo.Address())
 
-		if err := s.secureServer.ListenAndServeTLS(cert, key); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		certificates, err := NewCertificateProvider(cert, key)
+		if err != nil {
+			log.Fatal(err.Error())
+
+			return err
+		}
+
+		s.lock.Lock()
+		s.certificates = certificates
+		s.lock.Unlock()
+
+		s.secureServer.TLSConfig.GetCertificate = certificates.GetCertificate
+
+		ln, err := s.listen(s.SecureServingInfo.Address(), true)
+		if err != nil {
+			log.Fatal(err.Error())
+
+			return err
+		}
+
+		// the certificate is served by certificates, for it to be reloaded.
+		if err := s.secureServer.ServeTLS(ln, "", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatal(err.Error())
 
 			return err
@@ -181,57 +379,219 @@ func (s *GenericAPIServer) Run() error {
 	// Ping the server to make sure the router is working.
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+	healthy := true
 	if s.healthz {
-		if err := s.ping(ctx); err != nil {
+		var err error
+		if healthy, err = s.ping(ctx); err != nil {
 			return err
 		}
 	}
 
+	s.started(healthy)
+	s.notifyReady()
+
 	if err := eg.Wait(); err != nil {
 		log.Fatal(err.Error())
 	}
 
+	// the listeners are closed at the start of the shutdown, wait for the in-flight requests.
+	if s.isDraining() {
+		<-s.drained
+	}
+
 	return nil
 }
 
-// Close graceful shutdown the api server.
-func (s *GenericAPIServer) Close() {
-	// The context is used to inform the server it has 10 seconds to finish
-	// the request it is currently handling
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// ReloadCertificate re-reads the certificate files of the https server, e.g. once renewed, the new
+// connections are served the new certificate. It does nothing until the https server is started.
+func (s *GenericAPIServer) ReloadCertificate() error {
+	s.lock.Lock()
+	certificates := s.certificates
+	s.lock.Unlock()
+
+	if certificates == nil {
+		return nil
+	}
+
+	changed, err := certificates.Reload()
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		log.Infof("Reloaded the certificate %s", s.SecureServingInfo.CertKey.CertFile)
+	}
+
+	return nil
+}
+
+// StopReadiness makes /readyz fail from now on, for the load balancers to stop sending new requests
+// before Shutdown. The requests are still served.
+func (s *GenericAPIServer) StopReadiness() {
+	s.readiness.SetNotReady()
+	s.lifecycle.transition(StateDraining)
+	s.notifyStopping()
+}
+
+// Shutdown gracefully shuts down the http servers: /readyz fails from now on, the listeners are
+// closed, then Shutdown waits for the in-flight requests to complete. Once ctx is done, the
+// connections still open are closed, dropping their requests, and the error of ctx is returned.
+func (s *GenericAPIServer) Shutdown(ctx context.Context) error {
+	s.readiness.SetNotReady()
+	s.lifecycle.transition(StateDraining)
+	s.notifyStopping()
+	defer s.drainedOnce.Do(func() { close(s.drained) })
+
+	var eg errgroup.Group
+
+	for _, server := range []*http.Server{s.insecureServer, s.secureServer} {
+		if server == nil {
+			continue
+		}
+
+		server := server
+		eg.Go(func() error {
+			if err := server.Shutdown(ctx); err != nil {
+				_ = server.Close()
+
+				return err
+			}
+
+			log.Infof("Server on %s drained", server.Addr)
+
+			return nil
+		})
+	}
+
+	return eg.Wait()
+}
+
+func (s *GenericAPIServer) isDraining() bool {
+	return !s.readiness.IsReady()
+}
+
+// notifyReady notifies systemd the server is ready, and starts notifying its watchdog as long as
+// the router is working. It is no-op when the server is not run by systemd.
+func (s *GenericAPIServer) notifyReady() {
+	ok, err := sdnotify.Notify(sdnotify.Ready)
+	if err != nil {
+		log.Warnf("Failed to notify systemd of the readiness: %s", err.Error())
+
+		return
+	}
+
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stopping {
+		cancel()
+
+		return
+	}
+
+	s.stopWatchdog = cancel
+
+	go func() {
+		err := sdnotify.RunWatchdog(ctx, s.selfCheck, func(err error) {
+			log.Warnf("Skip the systemd watchdog notification: %s", err.Error())
+		})
+		if err != nil {
+			log.Warnf("Failed to start the systemd watchdog notifications: %s", err.Error())
+		}
+	}()
+}
+
+// notifyStopping notifies systemd the shutdown started, and stops notifying its watchdog.
+func (s *GenericAPIServer) notifyStopping() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stopping {
+		return
+	}
 
-	if err := s.secureServer.Shutdown(ctx); err != nil {
-		log.Warnf("Shutdown secure server failed: %s", err.Error())
+	s.stopping = true
+
+	if s.stopWatchdog != nil {
+		s.stopWatchdog()
+	}
+
+	if _, err := sdnotify.Notify(sdnotify.Stopping); err != nil {
+		log.Warnf("Failed to notify systemd of the shutdown: %s", err.Error())
+	}
+}
+
+// selfCheck makes sure the router is working, the failed health checks of the dependencies are
+// tolerated as a restart would not fix them.
+func (s *GenericAPIServer) selfCheck(ctx context.Context) error {
+	path := "/version"
+	if s.healthz {
+		path = "/healthz"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.localURL(path), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.localClient().Do(req)
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
 
-	if err := s.insecureServer.Shutdown(ctx); err != nil {
-		log.Warnf("Shutdown insecure server failed: %s", err.Error())
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return fmt.Errorf("GET %s returned %d", path, resp.StatusCode)
 	}
+
+	return nil
 }
 
-// ping pings the http server to make sure the router is working.
-func (s *GenericAPIServer) ping(ctx context.Context) error {
-	url := fmt.Sprintf("http://%s/healthz", s.InsecureServingInfo.Address)
+// localURL returns the url of path on the insecure http server, to request with localClient.
+func (s *GenericAPIServer) localURL(path string) string {
+	// the host is ignored by the unix socket client.
+	if s.InsecureServingInfo.Address == "" {
+		return "http://localhost" + path
+	}
+
 	if strings.Contains(s.InsecureServingInfo.Address, "0.0.0.0") {
-		url = fmt.Sprintf("http://127.0.0.1:%s/healthz", strings.Split(s.InsecureServingInfo.Address, ":")[1])
+		return fmt.Sprintf("http://127.0.0.1:%s%s", strings.Split(s.InsecureServingInfo.Address, ":")[1], path)
 	}
 
+	return fmt.Sprintf("http://%s%s", s.InsecureServingInfo.Address, path)
+}
+
+// ping pings the http server to make sure the router is working, it returns whether the health
+// checks passed.
+func (s *GenericAPIServer) ping(ctx context.Context) (bool, error) {
+	url := s.localURL("/healthz")
+
 	for {
 		// Change NewRequest to NewRequestWithContext and pass context it
 		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 		if err != nil {
-			return err
+			return false, err
 		}
 		// Ping the server by sending a GET request to `/healthz`.
 
-		resp, err := http.DefaultClient.Do(req)
-		if err == nil && resp.StatusCode == http.StatusOK {
-			log.Info("The router has been deployed successfully.")
+		resp, err := s.localClient().Do(req)
+		if err == nil && (resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusServiceUnavailable) {
+			// the router is working even if some health checks failed.
+			if resp.StatusCode != http.StatusOK {
+				log.Warn("The router has been deployed, but some health checks failed.")
+			} else {
+				log.Info("The router has been deployed successfully.")
+			}
 
 			resp.Body.Close()
 
-			return nil
+			return resp.StatusCode == http.StatusOK, nil
 		}
 
 		// Sleep for a second to continue the next ping.
@@ -0,0 +1,158 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+)
+
+func TestGenericAPIServer_Use(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+		want     []string
+	}{
+		{
+			name:     "matched route",
+			path:     "/v1/users/colin",
+			wantCode: http.StatusOK,
+			want:     []string{"use", "after route /v1/users/:name", "handler"},
+		},
+		{
+			name:     "no route",
+			path:     "/v1/secrets",
+			wantCode: http.StatusNotFound,
+			want:     []string{"use"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+
+			s := &GenericAPIServer{Engine: gin.New()}
+			s.Use(func(c *gin.Context) {
+				got = append(got, "use")
+			})
+			s.UseAfterRoute(func(c *gin.Context) {
+				got = append(got, "after route "+c.GetString(middleware.RouteKey))
+			})
+			s.GET("/v1/users/:name", func(c *gin.Context) {
+				got = append(got, "handler")
+			})
+
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.wantCode {
+				t.Errorf("ServeHTTP() code = %d, want %d", w.Code, tt.wantCode)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ServeHTTP() ran %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenericAPIServer_Shutdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		wantErr error
+		// wantCode is the status of the in-flight request, 0 if it is dropped.
+		wantCode int
+	}{
+		{
+			name:     "drained",
+			timeout:  5 * time.Second,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:    "timed out",
+			timeout: 50 * time.Millisecond,
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &GenericAPIServer{Engine: gin.New(), drained: make(chan struct{})}
+			s.GET("/readyz", s.healthzHandler(true))
+
+			// the slow request checks /readyz while the server is draining, before completing.
+			started, readyz := make(chan struct{}), make(chan int, 1)
+			s.GET("/slow", func(c *gin.Context) {
+				close(started)
+
+				select {
+				case <-time.After(200 * time.Millisecond):
+					w := httptest.NewRecorder()
+					s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+					readyz <- w.Code
+
+					c.Status(http.StatusOK)
+				case <-c.Request.Context().Done():
+				}
+			})
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("listen failed: %v", err)
+			}
+
+			s.insecureServer = &http.Server{Handler: s}
+			go s.insecureServer.Serve(ln) //nolint: errcheck
+
+			code := make(chan int, 1)
+			go func() {
+				rsp, err := http.Get("http://" + ln.Addr().String() + "/slow")
+				if err != nil {
+					code <- 0
+
+					return
+				}
+
+				rsp.Body.Close()
+				code <- rsp.StatusCode
+			}()
+
+			<-started
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			if err := s.Shutdown(ctx); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Shutdown() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got := <-code; got != tt.wantCode {
+				t.Errorf("in-flight request code = %d, want %d", got, tt.wantCode)
+			}
+
+			if tt.wantCode != 0 {
+				if got := <-readyz; got != http.StatusServiceUnavailable {
+					t.Errorf("/readyz code while draining = %d, want %d", got, http.StatusServiceUnavailable)
+				}
+			}
+
+			select {
+			case <-s.drained:
+			default:
+				t.Error("Shutdown() returned before marking the server drained")
+			}
+		})
+	}
+}
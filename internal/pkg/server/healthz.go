@@ -0,0 +1,160 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthzFunc checks a component of the server, it returns nil if the component is healthy.
+type HealthzFunc = func(ctx context.Context) error
+
+// healthzTimeout is the maximum duration of the checks run by a /healthz or /readyz request.
+const healthzTimeout = 5 * time.Second
+
+// healthChecks is a set of named checks, which can be extended while the server is running.
+type healthChecks struct {
+	lock   sync.RWMutex
+	checks map[string]HealthzFunc
+}
+
+func (h *healthChecks) add(name string, check HealthzFunc) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.checks == nil {
+		h.checks = make(map[string]HealthzFunc)
+	}
+
+	h.checks[name] = check
+}
+
+// copyTo adds the checks to the given map.
+func (h *healthChecks) copyTo(checks map[string]HealthzFunc) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+}
+
+// AddHealthzCheck registers a check run by /healthz and /readyz, a check with the same name
+// is replaced.
+func (s *GenericAPIServer) AddHealthzCheck(name string, check HealthzFunc) {
+	s.healthzChecks.add(name, check)
+}
+
+// AddReadyzCheck registers a check only run by /readyz, a check with the same name is
+// replaced.
+func (s *GenericAPIServer) AddReadyzCheck(name string, check HealthzFunc) {
+	s.readyzChecks.add(name, check)
+}
+
+// healthzHandler returns the handler of /healthz, or of /readyz if ready is true. The results of the
+// checks update the state of the server: a failed check degrades it, /readyz succeeding makes it ready
+// again. The state is added to the results of the requests with the verbose query parameter.
+func (s *GenericAPIServer) healthzHandler(ready bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, verbose := c.GetQuery("verbose")
+
+		// no new request must be routed to a server shutting down.
+		if ready && s.isDraining() {
+			results := map[string]string{"shutdown": "draining the in-flight requests"}
+			if verbose {
+				results["state"] = s.stateSummary()
+			}
+
+			c.JSON(http.StatusServiceUnavailable, results)
+
+			return
+		}
+
+		checks := map[string]HealthzFunc{"ping": func(context.Context) error { return nil }}
+		s.healthzChecks.copyTo(checks)
+
+		if ready {
+			s.readyzChecks.copyTo(checks)
+		}
+
+		results, healthy := runChecks(c.Request.Context(), healthzTimeout, checks)
+
+		switch {
+		case !healthy:
+			s.lifecycle.transition(StateDegraded, StateReady)
+		case ready:
+			s.lifecycle.transition(StateReady, StateDegraded)
+		}
+
+		if verbose {
+			results["state"] = s.stateSummary()
+		}
+
+		if !healthy {
+			c.JSON(http.StatusServiceUnavailable, results)
+
+			return
+		}
+
+		c.JSON(http.StatusOK, results)
+	}
+}
+
+// runChecks runs the checks concurrently and returns the result of each check, "ok" or the
+// error returned. The checks which do not return within timeout are reported as timed out.
+func runChecks(ctx context.Context, timeout time.Duration, checks map[string]HealthzFunc) (map[string]string, bool) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		name string
+		err  error
+	}
+
+	// buffered so that the checks which time out do not leak their goroutine.
+	resultCh := make(chan result, len(checks))
+	for name, check := range checks {
+		go func(name string, check HealthzFunc) {
+			resultCh <- result{name: name, err: check(ctx)}
+		}(name, check)
+	}
+
+	results := make(map[string]string, len(checks))
+	healthy := true
+
+	for len(results) < len(checks) {
+		select {
+		case r := <-resultCh:
+			results[r.name] = "ok"
+			if r.err != nil {
+				results[r.name] = r.err.Error()
+				healthy = false
+			}
+		case <-ctx.Done():
+			for name := range checks {
+				if _, ok := results[name]; !ok {
+					results[name] = "timed out: " + ctx.Err().Error()
+				}
+			}
+
+			return results, false
+		}
+	}
+
+	return results, healthy
+}
+
+// stateSummary returns the state of the server and the time spent in it, e.g. "ready for 1h2m3s".
+func (s *GenericAPIServer) stateSummary() string {
+	info := s.lifecycle.info()
+
+	return fmt.Sprintf("%s for %s", info.State, info.TimeInState)
+}
@@ -0,0 +1,130 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Test_runChecks(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	failed := func(context.Context) error { return errors.New("connection refused") }
+	// blocked ignores the context, it never returns.
+	blocked := func(context.Context) error { select {} }
+
+	tests := []struct {
+		name        string
+		checks      map[string]HealthzFunc
+		want        map[string]string
+		wantHealthy bool
+	}{
+		{
+			name:        "healthy",
+			checks:      map[string]HealthzFunc{"mysql": ok, "redis": ok},
+			want:        map[string]string{"mysql": "ok", "redis": "ok"},
+			wantHealthy: true,
+		},
+		{
+			name:        "failed",
+			checks:      map[string]HealthzFunc{"mysql": ok, "redis": failed},
+			want:        map[string]string{"mysql": "ok", "redis": "connection refused"},
+			wantHealthy: false,
+		},
+		{
+			name:        "timed out",
+			checks:      map[string]HealthzFunc{"mysql": ok, "redis": blocked},
+			want:        map[string]string{"mysql": "ok", "redis": "timed out: context deadline exceeded"},
+			wantHealthy: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+
+			got, healthy := runChecks(context.Background(), 50*time.Millisecond, tt.checks)
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("runChecks() took %v, want the timeout to be enforced", elapsed)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) || healthy != tt.wantHealthy {
+				t.Errorf("runChecks() = %v, %t, want %v, %t", got, healthy, tt.want, tt.wantHealthy)
+			}
+		})
+	}
+}
+
+func Test_runChecks_Concurrent(t *testing.T) {
+	// every check waits for all the others to start, so they only succeed if run concurrently.
+	const n = 5
+
+	var started sync.WaitGroup
+	started.Add(n)
+
+	checks := make(map[string]HealthzFunc, n)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		checks[name] = func(ctx context.Context) error {
+			started.Done()
+			started.Wait()
+
+			return ctx.Err()
+		}
+	}
+
+	got, healthy := runChecks(context.Background(), time.Second, checks)
+	if !healthy {
+		t.Errorf("runChecks() = %v, want all the checks run concurrently", got)
+	}
+}
+
+func TestGenericAPIServer_Healthz(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	s := &GenericAPIServer{Engine: gin.New(), healthz: true}
+	s.InstallAPIs()
+	s.AddHealthzCheck("mysql", func(context.Context) error { return nil })
+	s.AddReadyzCheck("cache", func(context.Context) error { return errors.New("not loaded") })
+
+	tests := []struct {
+		path     string
+		wantCode int
+		want     map[string]string
+	}{
+		{
+			path:     "/healthz",
+			wantCode: http.StatusOK,
+			want:     map[string]string{"ping": "ok", "mysql": "ok"},
+		},
+		{
+			path:     "/readyz",
+			wantCode: http.StatusServiceUnavailable,
+			want:     map[string]string{"ping": "ok", "mysql": "ok", "cache": "not loaded"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			var got map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+
+			if w.Code != tt.wantCode || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GET %s = %d %v, want %d %v", tt.path, w.Code, got, tt.wantCode, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// connContextKey is the key of the connection of a request in its context.
+type connContextKey struct{}
+
+// http2Server returns the http/2 server tuned with the http/2 options.
+func (s *GenericAPIServer) http2Server() *http2.Server {
+	return &http2.Server{
+		MaxConcurrentStreams: s.HTTP2MaxConcurrentStreams,
+		MaxReadFrameSize:     s.HTTP2MaxReadFrameSize,
+		IdleTimeout:          s.IdleTimeout,
+	}
+}
+
+// configureHTTP2 enables http/2 on the tls connections of server, with the http/2 options.
+func (s *GenericAPIServer) configureHTTP2(server *http.Server) error {
+	return http2.ConfigureServer(server, s.http2Server())
+}
+
+// enableH2C makes server accept the http/2 cleartext connections, either with prior knowledge or
+// upgraded from http/1.1.
+func (s *GenericAPIServer) enableH2C(server *http.Server) {
+	handler := h2c.NewHandler(server.Handler, s.http2Server())
+
+	server.ConnContext = func(ctx context.Context, conn net.Conn) context.Context {
+		return context.WithValue(ctx, connContextKey{}, conn)
+	}
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the h2c connection is hijacked to serve many streams, the read and write deadlines set by
+		// server for the first request must not close it.
+		if isH2C(r) {
+			if conn, ok := r.Context().Value(connContextKey{}).(net.Conn); ok {
+				_ = conn.SetDeadline(time.Time{})
+			}
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// isH2C returns whether r starts a http/2 cleartext connection.
+func isH2C(r *http.Request) bool {
+	if r.Method == "PRI" && r.ProtoMajor == 2 {
+		return true
+	}
+
+	return strings.EqualFold(r.Header.Get("Upgrade"), "h2c")
+}
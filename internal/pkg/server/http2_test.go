@@ -0,0 +1,185 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/http2"
+)
+
+// newHTTP2TestServer returns a server answering the health checks and POST /v1/authz like the
+// authorize handler, with the http/2 options.
+func newHTTP2TestServer() *GenericAPIServer {
+	s := &GenericAPIServer{
+		Engine:                    gin.New(),
+		ReadTimeout:               100 * time.Millisecond,
+		WriteTimeout:              100 * time.Millisecond,
+		IdleTimeout:               time.Second,
+		HTTP2MaxConcurrentStreams: 250,
+		HTTP2MaxReadFrameSize:     1 << 20,
+	}
+	s.AddHealthzCheck("redis", func(ctx context.Context) error { return nil })
+	s.GET("/healthz", s.healthzHandler(false))
+	s.GET("/readyz", s.healthzHandler(true))
+	s.POST("/v1/authz", func(c *gin.Context) {
+		var r struct {
+			Subject string `json:"subject"`
+		}
+
+		if err := c.ShouldBindJSON(&r); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"allowed": r.Subject == "users:colin"})
+	})
+
+	return s
+}
+
+func TestGenericAPIServer_HTTP2(t *testing.T) {
+	tests := []struct {
+		name      string
+		start     func(t *testing.T) (*httptest.Server, *http.Client)
+		wantProto int
+	}{
+		{
+			name: "http/1.1",
+			start: func(t *testing.T) (*httptest.Server, *http.Client) {
+				s := newHTTP2TestServer()
+				ts := httptest.NewUnstartedServer(nil)
+				ts.Config = s.newHTTPServer("")
+				ts.Start()
+
+				return ts, ts.Client()
+			},
+			wantProto: 1,
+		},
+		{
+			name: "h2",
+			start: func(t *testing.T) (*httptest.Server, *http.Client) {
+				s := newHTTP2TestServer()
+				ts := httptest.NewUnstartedServer(nil)
+				ts.Config = s.newHTTPServer("")
+				if err := s.configureHTTP2(ts.Config); err != nil {
+					t.Fatalf("configureHTTP2() error = %v", err)
+				}
+				ts.TLS = &tls.Config{NextProtos: []string{http2.NextProtoTLS, "http/1.1"}}
+				ts.EnableHTTP2 = true
+				ts.StartTLS()
+
+				return ts, ts.Client()
+			},
+			wantProto: 2,
+		},
+		{
+			name: "h2c",
+			start: func(t *testing.T) (*httptest.Server, *http.Client) {
+				s := newHTTP2TestServer()
+				ts := httptest.NewUnstartedServer(nil)
+				ts.Config = s.newHTTPServer("")
+				s.enableH2C(ts.Config)
+				ts.Start()
+
+				return ts, &http.Client{Transport: &http2.Transport{
+					AllowHTTP: true,
+					DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+						return net.Dial(network, addr)
+					},
+				}}
+			},
+			wantProto: 2,
+		},
+	}
+
+	requests := []struct {
+		method   string
+		path     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			method:   http.MethodGet,
+			path:     "/healthz",
+			wantCode: http.StatusOK,
+			wantBody: `{"ping":"ok","redis":"ok"}`,
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/readyz",
+			wantCode: http.StatusOK,
+			wantBody: `{"ping":"ok","redis":"ok"}`,
+		},
+		{
+			method:   http.MethodPost,
+			path:     "/v1/authz",
+			body:     `{"subject":"users:colin"}`,
+			wantCode: http.StatusOK,
+			wantBody: `{"allowed":true}`,
+		},
+		{
+			method:   http.MethodPost,
+			path:     "/v1/authz",
+			body:     `{"subject":`,
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"message":"unexpected EOF"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, client := tt.start(t)
+			defer ts.Close()
+
+			for i, r := range requests {
+				// the connection outlives the read and write timeouts of its first request.
+				if i > 0 {
+					time.Sleep(150 * time.Millisecond)
+				}
+
+				reused := false
+				trace := &httptrace.ClientTrace{
+					GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused },
+				}
+
+				req, _ := http.NewRequest(r.method, ts.URL+r.path, strings.NewReader(r.body))
+				req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+				rsp, err := client.Do(req)
+				if err != nil {
+					t.Fatalf("%s %s error = %v", r.method, r.path, err)
+				}
+
+				body, _ := ioutil.ReadAll(rsp.Body)
+				rsp.Body.Close()
+
+				if rsp.ProtoMajor != tt.wantProto {
+					t.Errorf("%s %s protocol = %s, want HTTP/%d", r.method, r.path, rsp.Proto, tt.wantProto)
+				}
+
+				if rsp.StatusCode != r.wantCode || string(body) != r.wantBody {
+					t.Errorf("%s %s = %d %s, want %d %s", r.method, r.path, rsp.StatusCode, body, r.wantCode, r.wantBody)
+				}
+
+				if tt.wantProto == 2 && i > 0 && !reused {
+					t.Errorf("%s %s opened a new http/2 connection", r.method, r.path)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MaxErrorRateWindow is the longest window of RouteErrorRate, the requests older than it are forgotten.
+const MaxErrorRateWindow = 15 * time.Minute
+
+// unmatchedRoute is the route label of the requests without route, e.g. 404.
+const unmatchedRoute = "unmatched"
+
+// InstrumentedEngine wraps a gin engine to count the responses of each route by status class, for the
+// SLO alerts. The requests are counted in the iam_http_route_requests_total counter, and the recent
+// ones are kept in memory for RouteErrorRate.
+type InstrumentedEngine struct {
+	*gin.Engine
+	requests *prometheus.CounterVec
+
+	lock    sync.RWMutex
+	history map[routeKey]*routeHistory
+	// now returns the current time, it is replaced by the tests.
+	now func() time.Time
+}
+
+type routeKey struct {
+	route  string
+	method string
+}
+
+// routeHistory counts the requests and the errors of a route during each second of the last
+// MaxErrorRateWindow, in a ring indexed by the unix time.
+type routeHistory struct {
+	lock    sync.Mutex
+	buckets [MaxErrorRateWindow / time.Second]routeBucket
+}
+
+type routeBucket struct {
+	second   int64
+	requests uint64
+	errors   uint64
+}
+
+// NewInstrumentedEngine instruments the routes of engine installed afterwards. The counter is
+// registered into registerer, unless it is nil.
+func NewInstrumentedEngine(engine *gin.Engine, registerer prometheus.Registerer) *InstrumentedEngine {
+	e := &InstrumentedEngine{
+		Engine: engine,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "iam",
+			Name:      "http_route_requests_total",
+			Help:      "Number of http requests by route, method and status class (2xx, 4xx, 5xx).",
+		}, []string{"route", "method", "status_class"}),
+		history: make(map[routeKey]*routeHistory),
+		now:     time.Now,
+	}
+
+	if registerer != nil {
+		registerer.MustRegister(e.requests)
+	}
+
+	e.Use(e.instrument)
+
+	return e
+}
+
+// RouteErrorRate returns the ratio of the requests to route with method which failed with a 5xx status
+// during the last window, capped to MaxErrorRateWindow. It returns 0 if there was no request.
+func (e *InstrumentedEngine) RouteErrorRate(route, method string, window time.Duration) float64 {
+	e.lock.RLock()
+	h, ok := e.history[routeKey{route: route, method: method}]
+	e.lock.RUnlock()
+
+	if !ok {
+		return 0
+	}
+
+	if window > MaxErrorRateWindow {
+		window = MaxErrorRateWindow
+	}
+
+	now := e.now().Unix()
+	since := now - int64(window/time.Second)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	var requests, errors uint64
+
+	for _, b := range h.buckets {
+		if b.second > since && b.second <= now {
+			requests += b.requests
+			errors += b.errors
+		}
+	}
+
+	if requests == 0 {
+		return 0
+	}
+
+	return float64(errors) / float64(requests)
+}
+
+func (e *InstrumentedEngine) instrument(c *gin.Context) {
+	w := &statusWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = unmatchedRoute
+	}
+
+	status := w.Status()
+	e.requests.WithLabelValues(route, c.Request.Method, statusClass(status)).Inc()
+	e.routeHistory(routeKey{route: route, method: c.Request.Method}).add(e.now().Unix(), status >= 500)
+}
+
+func (e *InstrumentedEngine) routeHistory(key routeKey) *routeHistory {
+	e.lock.RLock()
+	h, ok := e.history[key]
+	e.lock.RUnlock()
+
+	if ok {
+		return h
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if h, ok = e.history[key]; !ok {
+		h = &routeHistory{}
+		e.history[key] = h
+	}
+
+	return h
+}
+
+func (h *routeHistory) add(second int64, failed bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	b := &h.buckets[second%int64(len(h.buckets))]
+	if b.second != second {
+		*b = routeBucket{second: second}
+	}
+
+	b.requests++
+	if failed {
+		b.errors++
+	}
+}
+
+// statusClass returns the class of status, e.g. 4xx.
+func statusClass(status int) string {
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// statusWriter records the status code set by the handlers before the response is written, it falls
+// back to the status of the wrapped writer, which is flushed by gin once the handlers returned.
+type statusWriter struct {
+	gin.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.Written() {
+		w.status = code
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Status() int {
+	if w.status != 0 {
+		return w.status
+	}
+
+	return w.ResponseWriter.Status()
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// installRoutes installs GET /v1/users/:name, failing for the user "error", and POST /v1/authz,
+// answering 400 without body.
+func installRoutes(g gin.IRouter) {
+	g.GET("/v1/users/:name", func(c *gin.Context) {
+		if c.Param("name") == "error" {
+			c.Status(http.StatusInternalServerError)
+
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"name": c.Param("name")})
+	})
+	g.POST("/v1/authz", func(c *gin.Context) {
+		if c.Request.ContentLength == 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "empty body"})
+
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"allowed": true})
+	})
+}
+
+func TestInstrumentedEngine(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{method: http.MethodGet, path: "/v1/users/colin", want: http.StatusOK},
+		{method: http.MethodGet, path: "/v1/users/error", want: http.StatusInternalServerError},
+		{method: http.MethodGet, path: "/v1/users/admin", want: http.StatusOK},
+		{method: http.MethodGet, path: "/v1/users/error", want: http.StatusInternalServerError},
+		{method: http.MethodPost, path: "/v1/authz", body: `{"subject":"users:colin"}`, want: http.StatusOK},
+		{method: http.MethodPost, path: "/v1/authz", want: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/v1/authz", want: http.StatusNotFound},
+	}
+
+	registry := prometheus.NewRegistry()
+	e := NewInstrumentedEngine(gin.New(), registry)
+
+	now := time.Unix(1600000000, 0)
+	e.now = func() time.Time { return now }
+
+	installRoutes(e)
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		rsp := httptest.NewRecorder()
+		e.ServeHTTP(rsp, req)
+
+		if rsp.Code != tt.want {
+			t.Errorf("%s %s code = %d, want %d", tt.method, tt.path, rsp.Code, tt.want)
+		}
+	}
+
+	// a series per route, method and status class answered.
+	if n, err := testutil.GatherAndCount(registry, "iam_http_route_requests_total"); err != nil || n != 5 {
+		t.Errorf("GatherAndCount() = %d, %v, want 5 series", n, err)
+	}
+
+	counters := []struct {
+		route       string
+		method      string
+		statusClass string
+		want        float64
+	}{
+		{route: "/v1/users/:name", method: http.MethodGet, statusClass: "2xx", want: 2},
+		{route: "/v1/users/:name", method: http.MethodGet, statusClass: "5xx", want: 2},
+		{route: "/v1/users/:name", method: http.MethodGet, statusClass: "4xx", want: 0},
+		{route: "/v1/authz", method: http.MethodPost, statusClass: "2xx", want: 1},
+		{route: "/v1/authz", method: http.MethodPost, statusClass: "4xx", want: 1},
+		{route: "/v1/authz", method: http.MethodPost, statusClass: "5xx", want: 0},
+		{route: unmatchedRoute, method: http.MethodGet, statusClass: "4xx", want: 1},
+	}
+	for _, tt := range counters {
+		got := testutil.ToFloat64(e.requests.WithLabelValues(tt.route, tt.method, tt.statusClass))
+		if got != tt.want {
+			t.Errorf("%s %s %s counter = %v, want %v", tt.method, tt.route, tt.statusClass, got, tt.want)
+		}
+	}
+
+	rates := []struct {
+		name   string
+		route  string
+		method string
+		after  time.Duration
+		window time.Duration
+		want   float64
+	}{
+		{name: "half of the users failed", route: "/v1/users/:name", method: http.MethodGet, window: time.Minute, want: 0.5},
+		{name: "4xx are not errors", route: "/v1/authz", method: http.MethodPost, window: time.Minute},
+		{name: "other method", route: "/v1/users/:name", method: http.MethodPost, window: time.Minute},
+		{name: "within the window", route: "/v1/users/:name", method: http.MethodGet, after: 59 * time.Second, window: time.Minute, want: 0.5},
+		{name: "out of the window", route: "/v1/users/:name", method: http.MethodGet, after: time.Minute, window: time.Minute},
+		{name: "capped window", route: "/v1/users/:name", method: http.MethodGet, after: MaxErrorRateWindow, window: time.Hour},
+	}
+	for _, tt := range rates {
+		t.Run(tt.name, func(t *testing.T) {
+			e.now = func() time.Time { return now.Add(tt.after) }
+
+			if got := e.RouteErrorRate(tt.route, tt.method, tt.window); got != tt.want {
+				t.Errorf("RouteErrorRate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstrumentedEngine_RouteErrorRateRing(t *testing.T) {
+	e := NewInstrumentedEngine(gin.New(), nil)
+	installRoutes(e)
+
+	start := time.Unix(1600000000, 0)
+
+	// an error every second during a full window, then successes only.
+	for i := 0; i < 2*int(MaxErrorRateWindow/time.Second); i++ {
+		now := start.Add(time.Duration(i) * time.Second)
+		e.now = func() time.Time { return now }
+
+		path := "/v1/users/colin"
+		if i < int(MaxErrorRateWindow/time.Second) {
+			path = "/v1/users/error"
+		}
+
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := e.RouteErrorRate("/v1/users/:name", http.MethodGet, MaxErrorRateWindow); got != 0 {
+		t.Errorf("RouteErrorRate() = %v once the errors left the window, want 0", got)
+	}
+}
+
+func BenchmarkInstrumentedEngine(b *testing.B) {
+	engines := []struct {
+		name   string
+		engine func() http.Handler
+	}{
+		{
+			name: "gin",
+			engine: func() http.Handler {
+				g := gin.New()
+				installRoutes(g)
+
+				return g
+			},
+		},
+		{
+			name: "instrumented",
+			engine: func() http.Handler {
+				e := NewInstrumentedEngine(gin.New(), prometheus.NewRegistry())
+				installRoutes(e)
+
+				return e
+			},
+		},
+	}
+	for _, bb := range engines {
+		b.Run(bb.name, func(b *testing.B) {
+			engine := bb.engine()
+			req := httptest.NewRequest(http.MethodGet, "/v1/users/colin", nil)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				engine.ServeHTTP(httptest.NewRecorder(), req)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// tooManyConnections is written to the plain http connections rejected by --server.max-connections.
+const tooManyConnections = "HTTP/1.1 503 Service Unavailable\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Connection: close\r\n" +
+	"Content-Length: 21\r\n" +
+	"\r\n" +
+	"too many connections\n"
+
+// rejectWriteTimeout is the maximum duration of writing tooManyConnections.
+const rejectWriteTimeout = time.Second
+
+var (
+	rejectedConnections = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "iam",
+		Name:      "http_rejected_connections_total",
+		Help:      "Number of http connections closed once accepted because of --server.max-connections.",
+	})
+	registerListenerMetrics sync.Once
+)
+
+// newHTTPServer returns the http server serving the router on addr, with the configured timeouts
+// and limits.
+func (s *GenericAPIServer) newHTTPServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		ReadTimeout:       s.ReadTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+		MaxHeaderBytes:    s.MaxHeaderBytes,
+	}
+}
+
+// listen listens on addr. Once the http, https and unix socket connections together reach
+// MaxConnections, the new connections are closed as soon as accepted, after a 503 response if tls is
+// false.
+func (s *GenericAPIServer) listen(addr string, tls bool) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.limit(ln, tls), nil
+}
+
+// limit limits the connections accepted by ln to MaxConnections, see listen.
+func (s *GenericAPIServer) limit(ln net.Listener, tls bool) net.Listener {
+	if s.MaxConnections <= 0 {
+		return ln
+	}
+
+	if s.enableMetrics {
+		registerListenerMetrics.Do(func() {
+			prometheus.MustRegister(rejectedConnections)
+		})
+	}
+
+	return &limitListener{Listener: ln, max: int64(s.MaxConnections), active: &s.connections, tls: tls}
+}
+
+// limitListener limits the number of connections open, which is shared by the listeners of a server.
+type limitListener struct {
+	net.Listener
+	max    int64
+	active *int64
+	tls    bool
+}
+
+// Accept waits for the next connection under the limit, and rejects the ones over it.
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if atomic.AddInt64(l.active, 1) <= l.max {
+			return &limitConn{Conn: conn, active: l.active}, nil
+		}
+
+		atomic.AddInt64(l.active, -1)
+		rejectedConnections.Inc()
+
+		go l.reject(conn)
+	}
+}
+
+func (l *limitListener) reject(conn net.Conn) {
+	defer conn.Close()
+
+	// the tls handshake is not done yet, there is no way to respond.
+	if l.tls {
+		return
+	}
+
+	_ = conn.SetWriteDeadline(time.Now().Add(rejectWriteTimeout))
+	_, _ = conn.Write([]byte(tooManyConnections))
+}
+
+// limitConn releases its place under the limit once closed, including when hijacked.
+type limitConn struct {
+	net.Conn
+	active    *int64
+	closeOnce sync.Once
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() { atomic.AddInt64(c.active, -1) })
+
+	return err
+}
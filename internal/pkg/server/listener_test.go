@@ -0,0 +1,195 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serve serves a router answering GET /ping with the http server of s, and returns its address.
+func serve(t *testing.T, s *GenericAPIServer) string {
+	t.Helper()
+
+	s.Engine = gin.New()
+	s.GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+
+	ln, err := s.listen("127.0.0.1:0", false)
+	if err != nil {
+		t.Fatalf("listen() error = %v", err)
+	}
+
+	server := s.newHTTPServer(ln.Addr().String())
+	go server.Serve(ln) //nolint: errcheck
+	t.Cleanup(func() { server.Close() })
+
+	return ln.Addr().String()
+}
+
+// get sends GET /ping with the header X-Padding of the given size over conn, and returns the
+// status code of the response.
+func get(t *testing.T, conn net.Conn, padding int) int {
+	t.Helper()
+
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest(http.MethodGet, "http://"+conn.RemoteAddr().String()+"/ping", nil)
+	req.Header.Set("X-Padding", strings.Repeat("x", padding))
+
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("write the request failed: %v", err)
+	}
+
+	rsp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		t.Fatalf("read the response failed: %v", err)
+	}
+	rsp.Body.Close()
+
+	return rsp.StatusCode
+}
+
+func TestGenericAPIServer_ReadHeaderTimeout(t *testing.T) {
+	addr := serve(t, &GenericAPIServer{ReadHeaderTimeout: 100 * time.Millisecond})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	// the headers are never completed.
+	if _, err := io.WriteString(conn, "GET /ping HTTP/1.1\r\nHost: iam\r\n"); err != nil {
+		t.Fatalf("write the request line failed: %v", err)
+	}
+
+	start := time.Now()
+	_ = conn.SetReadDeadline(start.Add(5 * time.Second))
+
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("read error = %v, want the connection closed by the server", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the slow client was disconnected after %v, want about 100ms", elapsed)
+	}
+}
+
+func TestGenericAPIServer_MaxHeaderBytes(t *testing.T) {
+	tests := []struct {
+		name    string
+		padding int
+		want    int
+	}{
+		{
+			name:    "under the limit",
+			padding: 512,
+			want:    http.StatusOK,
+		},
+		{
+			name:    "over the limit",
+			padding: 16 << 10,
+			want:    http.StatusRequestHeaderFieldsTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// net/http allows 4096 more bytes than MaxHeaderBytes.
+			addr := serve(t, &GenericAPIServer{MaxHeaderBytes: 4 << 10})
+
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatalf("dial failed: %v", err)
+			}
+			defer conn.Close()
+
+			if got := get(t, conn, tt.padding); got != tt.want {
+				t.Errorf("GET /ping code = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenericAPIServer_MaxConnections(t *testing.T) {
+	s := &GenericAPIServer{MaxConnections: 1}
+	addr := serve(t, s)
+
+	first, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+
+	if got := get(t, first, 0); got != http.StatusOK {
+		t.Fatalf("GET /ping code = %d, want %d", got, http.StatusOK)
+	}
+
+	// the first connection is kept alive, the second one is over the limit.
+	second, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer second.Close()
+
+	_ = second.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	rsp, err := http.ReadResponse(bufio.NewReader(second), nil)
+	if err != nil {
+		t.Fatalf("read the rejection failed: %v", err)
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("rejected connection code = %d, want %d", rsp.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	// closing the first connection makes room for a new one.
+	first.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		third, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+
+		// an accepted connection waits for the request, a rejected one is answered at once.
+		_ = third.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		if _, err := third.Read(make([]byte, 1)); isTimeout(err) {
+			if got := get(t, third, 0); got != http.StatusOK {
+				t.Errorf("GET /ping code = %d, want %d", got, http.StatusOK)
+			}
+
+			third.Close()
+
+			return
+		}
+
+		third.Close()
+
+		// the server may not have seen the first connection closed yet.
+		if time.Now().After(deadline) {
+			t.Fatal("Timeout waiting for the first connection to be released.")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
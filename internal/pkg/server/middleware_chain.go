@@ -0,0 +1,119 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MiddlewareChain orders middlewares by the dependencies declared between them, instead of the
+// order they are registered in. A middleware runs after the middlewares it depends on, the
+// middlewares without dependency between them keep the order they are added in.
+type MiddlewareChain struct {
+	names       []string
+	middlewares map[string]*chainedMiddleware
+	// err is the first error found by Add, returned by Build.
+	err error
+}
+
+type chainedMiddleware struct {
+	deps []string
+	fn   gin.HandlerFunc
+}
+
+// NewMiddlewareChain returns an empty middleware chain.
+func NewMiddlewareChain() *MiddlewareChain {
+	return &MiddlewareChain{middlewares: make(map[string]*chainedMiddleware)}
+}
+
+// Add adds the middleware fn named name, which runs after the middlewares named deps.
+func (c *MiddlewareChain) Add(name string, deps []string, fn gin.HandlerFunc) {
+	if _, ok := c.middlewares[name]; ok {
+		if c.err == nil {
+			c.err = fmt.Errorf("middleware %s is added more than once", name)
+		}
+
+		return
+	}
+
+	c.names = append(c.names, name)
+	c.middlewares[name] = &chainedMiddleware{deps: deps, fn: fn}
+}
+
+// has reports whether the middleware named name is added.
+func (c *MiddlewareChain) has(name string) bool {
+	_, ok := c.middlewares[name]
+
+	return ok
+}
+
+// Build returns the middlewares sorted by their dependencies. An error is returned if a middleware is
+// added twice, depends on a middleware not added, or the dependencies form a cycle.
+func (c *MiddlewareChain) Build() ([]gin.HandlerFunc, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	state := make(map[string]int, len(c.names))
+	sorted := make([]gin.HandlerFunc, 0, len(c.names))
+
+	// path holds the middlewares being visited, to report the cycle found.
+	var path []string
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			cycle := path
+			for i, n := range path {
+				if n == name {
+					cycle = path[i:]
+
+					break
+				}
+			}
+
+			return fmt.Errorf("middleware dependency cycle: %s -> %s", strings.Join(cycle, " -> "), name)
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+
+		m := c.middlewares[name]
+		for _, dep := range m.deps {
+			if _, ok := c.middlewares[dep]; !ok {
+				return fmt.Errorf("middleware %s depends on %s, which is not added", name, dep)
+			}
+
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[name] = visited
+		sorted = append(sorted, m.fn)
+
+		return nil
+	}
+
+	for _, name := range c.names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddlewareChain_Build(t *testing.T) {
+	type middleware struct {
+		name string
+		deps []string
+	}
+
+	tests := []struct {
+		name        string
+		middlewares []middleware
+		want        []string
+		wantErr     string
+	}{
+		{
+			name: "empty",
+			want: []string{},
+		},
+		{
+			name:        "registration order without dependencies",
+			middlewares: []middleware{{name: "a"}, {name: "b"}, {name: "c"}},
+			want:        []string{"a", "b", "c"},
+		},
+		{
+			name: "dependencies first",
+			middlewares: []middleware{
+				{name: "logger", deps: []string{"requestid"}},
+				{name: "auth", deps: []string{"context", "logger"}},
+				{name: "context", deps: []string{"requestid"}},
+				{name: "requestid"},
+				{name: "cors"},
+			},
+			want: []string{"requestid", "logger", "context", "auth", "cors"},
+		},
+		{
+			name: "cycle",
+			middlewares: []middleware{
+				{name: "a", deps: []string{"b"}},
+				{name: "b", deps: []string{"c"}},
+				{name: "c", deps: []string{"b"}},
+			},
+			wantErr: "middleware dependency cycle: b -> c -> b",
+		},
+		{
+			name:        "self dependency",
+			middlewares: []middleware{{name: "a", deps: []string{"a"}}},
+			wantErr:     "middleware dependency cycle: a -> a",
+		},
+		{
+			name:        "missing dependency",
+			middlewares: []middleware{{name: "a"}, {name: "b", deps: []string{"a", "c"}}},
+			wantErr:     "middleware b depends on c, which is not added",
+		},
+		{
+			name:        "added twice",
+			middlewares: []middleware{{name: "a"}, {name: "b"}, {name: "a"}},
+			wantErr:     "middleware a is added more than once",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+
+			chain := NewMiddlewareChain()
+			for _, m := range tt.middlewares {
+				name := m.name
+				chain.Add(name, m.deps, func(c *gin.Context) {
+					got = append(got, name)
+				})
+			}
+
+			middlewares, err := chain.Build()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Build() error = %v, want %s", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+
+			got = []string{}
+			for _, mw := range middlewares {
+				mw(nil)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Build() order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareChain_Execution(t *testing.T) {
+	// counter is shared by the middlewares, each one records its value when it runs.
+	var counter int
+
+	ran := map[string]int{}
+	record := func(name string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			counter++
+			ran[name] = counter
+			c.Next()
+		}
+	}
+
+	chain := NewMiddlewareChain()
+	chain.Add("recovery", []string{"logger"}, record("recovery"))
+	chain.Add("logger", []string{"requestid"}, record("logger"))
+	chain.Add("requestid", nil, record("requestid"))
+
+	middlewares, err := chain.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	s := &GenericAPIServer{Engine: gin.New()}
+	s.Use(middlewares...)
+	s.GET("/ping", func(c *gin.Context) {
+		counter++
+		ran["handler"] = counter
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+	want := map[string]int{"requestid": 1, "logger": 2, "recovery": 3, "handler": 4}
+	if !reflect.DeepEqual(ran, want) {
+		t.Errorf("execution order = %v, want %v", ran, want)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/marmotedu/iam/pkg/shutdown"
+)
+
+// Readiness is the readiness state of a server, shared by /readyz and the shutdown. A server is
+// ready until SetNotReady is called, it never becomes ready again.
+type Readiness struct {
+	lock sync.RWMutex
+	// notReadySince is the time of the first SetNotReady call, zero while the server is ready.
+	notReadySince time.Time
+}
+
+// SetNotReady makes /readyz fail from now on. The time of the first call is kept.
+func (r *Readiness) SetNotReady() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.notReadySince.IsZero() {
+		r.notReadySince = time.Now()
+	}
+}
+
+// IsReady returns false once SetNotReady is called.
+func (r *Readiness) IsReady() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.notReadySince.IsZero()
+}
+
+// NotReadySince returns the time the server stopped being ready, zero if it is still ready.
+func (r *Readiness) NotReadySince() time.Time {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.notReadySince
+}
+
+// StopReadinessCallback returns the shutdown callback which makes /readyz fail, then waits the
+// readiness grace period for the load balancers to observe it before the next callbacks close
+// the listeners. The time the server was already not ready, e.g. since a preStop hook, counts
+// toward the grace period. The wait ends early if the deadline of the shutdown is exceeded.
+func (s *GenericAPIServer) StopReadinessCallback() shutdown.ShutdownCallbackCtx {
+	return shutdown.ShutdownFuncCtx(func(ctx context.Context, _ string) error {
+		s.StopReadiness()
+
+		grace := s.ReadinessGracePeriod - time.Since(s.readiness.NotReadySince())
+		if grace <= 0 {
+			return nil
+		}
+
+		log.Infof("Wait %s for the load balancers to stop sending new requests.", grace)
+
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGenericAPIServer_StopReadinessCallback(t *testing.T) {
+	const grace = 300 * time.Millisecond
+
+	tests := []struct {
+		name string
+		// notReadyFor is the time the server was already not ready, e.g. since a preStop hook.
+		notReadyFor time.Duration
+		// wantWait is the minimum time the callback waits.
+		wantWait time.Duration
+	}{
+		{
+			name:     "ready",
+			wantWait: grace,
+		},
+		{
+			name:        "not ready for part of the grace period",
+			notReadyFor: 200 * time.Millisecond,
+			wantWait:    grace - 200*time.Millisecond,
+		},
+		{
+			name:        "not ready for the grace period",
+			notReadyFor: grace,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &GenericAPIServer{Engine: gin.New(), ReadinessGracePeriod: grace}
+			s.GET("/readyz", s.healthzHandler(true))
+			s.GET("/v1/users/:name", func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			if tt.notReadyFor > 0 {
+				s.readiness.notReadySince = time.Now().Add(-tt.notReadyFor)
+			}
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("listen failed: %v", err)
+			}
+
+			server := &http.Server{Handler: s}
+			go server.Serve(ln) //nolint: errcheck
+			defer server.Close()
+
+			start := time.Now()
+			done := make(chan struct{})
+
+			go func() {
+				defer close(done)
+
+				if err := s.StopReadinessCallback().OnShutdownCtx(context.Background(), ""); err != nil {
+					t.Errorf("OnShutdownCtx() error = %v", err)
+				}
+			}()
+
+			// the requests sent during the grace period are still served, but /readyz fails.
+			for path, want := range map[string]int{
+				"/readyz":         http.StatusServiceUnavailable,
+				"/v1/users/colin": http.StatusOK,
+			} {
+				var code int
+
+				for code != want && time.Since(start) < grace/2 {
+					rsp, err := http.Get("http://" + ln.Addr().String() + path)
+					if err != nil {
+						t.Fatalf("GET %s error = %v", path, err)
+					}
+					rsp.Body.Close()
+
+					code = rsp.StatusCode
+				}
+
+				if code != want {
+					t.Errorf("GET %s code during the grace period = %d, want %d", path, code, want)
+				}
+			}
+
+			<-done
+
+			if wait := time.Since(start); wait < tt.wantWait {
+				t.Errorf("OnShutdown() returned after %v, want at least %v", wait, tt.wantWait)
+			}
+
+			if tt.wantWait == 0 && time.Since(start) >= grace {
+				t.Errorf("OnShutdown() waited although the grace period elapsed")
+			}
+		})
+	}
+}
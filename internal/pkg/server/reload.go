@@ -0,0 +1,52 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/marmotedu/iam/pkg/app"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// AddReloadHooks adds to reloader the hooks applying the generic configuration changes which do not
+// require a restart: the log level, the sample rate of the access log, and the certificate of the https
+// server, whose files are re-read on each reload as they can be renewed without changing the configuration.
+// The log files are rotated on each SIGHUP, e.g. of logrotate, whether the configuration is valid or not,
+// but not on the reloads of a watched configuration file.
+func (s *GenericAPIServer) AddReloadHooks(reloader *app.Reloader) {
+	reloader.AddReloadHook(func() error {
+		level := viper.GetString("log.level")
+		if err := log.SetLevel(level); err != nil {
+			return err
+		}
+
+		log.Infof("Log level set to %s", level)
+
+		return nil
+	}, "log.level")
+
+	reloader.AddReloadHook(func() error {
+		// the access log is enabled on startup only.
+		if s.accessLogSampler == nil {
+			return nil
+		}
+
+		rate := viper.GetFloat64("access-log.sample-rate")
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("access log sample rate %v must be between 0 and 1", rate)
+		}
+
+		s.accessLogSampler.SetRate(rate)
+		log.Infof("Access log sample rate set to %v", rate)
+
+		return nil
+	}, "access-log.sample-rate")
+
+	reloader.AddReloadHook(s.ReloadCertificate)
+	reloader.AddSignalHook(log.Rotate)
+}
@@ -0,0 +1,186 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/component-base/pkg/core"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// State is the lifecycle state of a server, exposed by GET /debug/state, the iam_server_state gauge
+// and the verbose /healthz.
+type State string
+
+const (
+	// StateStarting is the state of a server from its creation, through the PrepareRun of the
+	// servers, until Run made sure its router is working.
+	StateStarting State = "starting"
+	// StateReady is the state of a server serving the requests, its checks passing.
+	StateReady State = "ready"
+	// StateDegraded is the state of a server serving the requests while a check of its dependencies
+	// fails, e.g. redis is down. It is ready again once /readyz succeeds.
+	StateDegraded State = "degraded"
+	// StateDraining is the state of a server shutting down, from StopReadiness or Shutdown. It is final.
+	StateDraining State = "draining"
+)
+
+// states are all the states, the ones of the gauge.
+var states = []State{StateStarting, StateReady, StateDegraded, StateDraining}
+
+// maxStateTransitions is the number of the last transitions returned by GET /debug/state.
+const maxStateTransitions = 10
+
+// ServerState is 1 for the state of the server and 0 for the other states, the servers exposing their
+// metrics register it.
+var ServerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "iam",
+	Name:      "server_state",
+	Help:      "Lifecycle state of the server, 1 for the current state: starting, ready, degraded or draining.",
+}, []string{"state"})
+
+// registerStateMetrics registers ServerState once, whatever the number of servers.
+var registerStateMetrics sync.Once
+
+func registerServerState() {
+	prometheus.MustRegister(ServerState)
+}
+
+// StateTransition is a change of the state of a server.
+type StateTransition struct {
+	From State     `json:"from"`
+	To   State     `json:"to"`
+	Time time.Time `json:"time"`
+}
+
+// StateInfo is the response of GET /debug/state.
+type StateInfo struct {
+	State State     `json:"state"`
+	Since time.Time `json:"since"`
+	// TimeInState is the time spent in the state, as a duration string, e.g. 1h2m3s.
+	TimeInState string `json:"timeInState"`
+	// Transitions are the last transitions, oldest first.
+	Transitions []StateTransition `json:"transitions"`
+}
+
+// lifecycle is the state machine of a server, its zero value is starting.
+type lifecycle struct {
+	lock        sync.RWMutex
+	state       State
+	since       time.Time
+	transitions []StateTransition
+}
+
+// start initializes the state to starting, as of now.
+func (l *lifecycle) start() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.state, l.since = StateStarting, time.Now()
+	setStateGauge(StateStarting)
+}
+
+// transition changes the state to the given one if the current state is one of from, or any state
+// but draining if from is empty. It logs the transition and returns whether the state changed.
+func (l *lifecycle) transition(to State, from ...State) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	current := l.current()
+	if current == to || current == StateDraining || (len(from) > 0 && !containsState(from, current)) {
+		return false
+	}
+
+	now := time.Now()
+	if l.since.IsZero() {
+		l.since = now
+	}
+
+	log.Infow("Server state changed", "from", current, "to", to, "after", now.Sub(l.since).String())
+
+	l.transitions = append(l.transitions, StateTransition{From: current, To: to, Time: now})
+	if len(l.transitions) > maxStateTransitions {
+		l.transitions = l.transitions[len(l.transitions)-maxStateTransitions:]
+	}
+
+	l.state, l.since = to, now
+	setStateGauge(to)
+
+	return true
+}
+
+// info returns the current state and the time spent in it.
+func (l *lifecycle) info() StateInfo {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	since := l.since
+	if since.IsZero() {
+		since = time.Now()
+	}
+
+	return StateInfo{
+		State:       l.current(),
+		Since:       since,
+		TimeInState: time.Since(since).Round(time.Millisecond).String(),
+		Transitions: append([]StateTransition{}, l.transitions...),
+	}
+}
+
+func (l *lifecycle) current() State {
+	if l.state == "" {
+		return StateStarting
+	}
+
+	return l.state
+}
+
+func containsState(states []State, state State) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+
+	return false
+}
+
+func setStateGauge(state State) {
+	for _, s := range states {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+
+		ServerState.WithLabelValues(string(s)).Set(value)
+	}
+}
+
+// started makes the server ready once Run made sure its router is working, or degraded if the health
+// checks of the ping failed.
+func (s *GenericAPIServer) started(healthy bool) {
+	if healthy {
+		s.lifecycle.transition(StateReady, StateStarting)
+
+		return
+	}
+
+	s.lifecycle.transition(StateDegraded, StateStarting)
+}
+
+// State returns the lifecycle state of the server.
+func (s *GenericAPIServer) State() State {
+	return s.lifecycle.info().State
+}
+
+// stateHandler is the handler of GET /debug/state.
+func (s *GenericAPIServer) stateHandler(c *gin.Context) {
+	core.WriteResponse(c, nil, s.lifecycle.info())
+}
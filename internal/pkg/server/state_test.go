@@ -0,0 +1,142 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGenericAPIServer_Lifecycle(t *testing.T) {
+	// the steps of the lifecycle, run in the order of each test.
+	var (
+		redisDown = func(s *GenericAPIServer, redis *error) { *redis = errors.New("redis is down") }
+		redisUp   = func(s *GenericAPIServer, redis *error) { *redis = nil }
+		get       = func(path string) func(*GenericAPIServer, *error) {
+			return func(s *GenericAPIServer, _ *error) {
+				s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+			}
+		}
+		// run is what Run does once the listeners are started: ping /healthz.
+		run = func(s *GenericAPIServer, _ *error) {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			s.started(w.Code == http.StatusOK)
+		}
+		stopReadiness = func(s *GenericAPIServer, _ *error) { s.StopReadiness() }
+		shutdown      = func(s *GenericAPIServer, _ *error) {
+			if err := s.Shutdown(context.Background()); err != nil {
+				t.Errorf("Shutdown() error = %v", err)
+			}
+		}
+	)
+
+	tests := []struct {
+		name  string
+		steps []func(*GenericAPIServer, *error)
+		want  []StateTransition
+	}{
+		{
+			name: "healthy start",
+			steps: []func(*GenericAPIServer, *error){
+				get("/readyz"), run, redisDown, get("/healthz"), redisUp,
+				// /healthz does not run the readyz checks, /readyz makes the server ready again.
+				get("/healthz"), get("/readyz"), stopReadiness, get("/readyz"), shutdown,
+			},
+			want: []StateTransition{
+				{From: StateStarting, To: StateReady},
+				{From: StateReady, To: StateDegraded},
+				{From: StateDegraded, To: StateReady},
+				{From: StateReady, To: StateDraining},
+			},
+		},
+		{
+			name:  "degraded start",
+			steps: []func(*GenericAPIServer, *error){redisDown, run, get("/readyz"), redisUp, get("/readyz"), shutdown},
+			want: []StateTransition{
+				{From: StateStarting, To: StateDegraded},
+				{From: StateDegraded, To: StateReady},
+				{From: StateReady, To: StateDraining},
+			},
+		},
+		{
+			name:  "shut down while starting",
+			steps: []func(*GenericAPIServer, *error){stopReadiness, run, redisDown, get("/healthz")},
+			want:  []StateTransition{{From: StateStarting, To: StateDraining}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &GenericAPIServer{Engine: gin.New(), healthz: true, drained: make(chan struct{})}
+			s.lifecycle.start()
+			s.InstallAPIs()
+
+			var redis error
+			s.AddHealthzCheck("redis", func(context.Context) error { return redis })
+
+			for _, step := range tt.steps {
+				step(s, &redis)
+			}
+
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/state", nil))
+
+			var info StateInfo
+			if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+				t.Fatalf("GET /debug/state returned %s: %v", w.Body.String(), err)
+			}
+
+			for i, transition := range info.Transitions {
+				if transition.Time.IsZero() {
+					t.Errorf("transition %d has no time", i)
+				}
+			}
+
+			if got, want := transitionStates(info.Transitions), transitionStates(tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("transitions = %v, want %v", got, want)
+			}
+
+			want := tt.want[len(tt.want)-1].To
+			if info.State != want || s.State() != want {
+				t.Errorf("state = %s, State() = %s, want %s", info.State, s.State(), want)
+			}
+
+			if got := testutil.ToFloat64(ServerState.WithLabelValues(string(want))); got != 1 {
+				t.Errorf("gauge of %s = %v, want 1", want, got)
+			}
+
+			w = httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz?verbose", nil))
+
+			var results map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+				t.Fatalf("GET /healthz?verbose returned %s: %v", w.Body.String(), err)
+			}
+
+			if !strings.HasPrefix(results["state"], string(want)+" for ") {
+				t.Errorf("GET /healthz?verbose state = %q, want %s for the time in state", results["state"], want)
+			}
+		})
+	}
+}
+
+// transitionStates returns the states of the transitions, without their time.
+func transitionStates(transitions []StateTransition) []string {
+	states := make([]string, 0, len(transitions))
+	for _, transition := range transitions {
+		states = append(states, string(transition.From)+" -> "+string(transition.To))
+	}
+
+	return states
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/http2"
+)
+
+// tlsVersions are the names of the tls versions accepted by TLSVersion.
+var tlsVersions = map[string]uint16{
+	"VersionTLS10": tls.VersionTLS10,
+	"VersionTLS11": tls.VersionTLS11,
+	"VersionTLS12": tls.VersionTLS12,
+	"VersionTLS13": tls.VersionTLS13,
+}
+
+// TLSVersionNames returns the names of the tls versions accepted by TLSVersion, sorted.
+func TLSVersionNames() []string {
+	names := make([]string, 0, len(tlsVersions))
+	for name := range tlsVersions {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// TLSVersion returns the tls version of the given name, e.g. VersionTLS12.
+func TLSVersion(name string) (uint16, error) {
+	version, ok := tlsVersions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown tls version %q, allowed values: %s", name, strings.Join(TLSVersionNames(), ", "))
+	}
+
+	return version, nil
+}
+
+// configurableCipherSuites returns the secure cipher suites of crypto/tls which can be configured, the
+// TLS 1.3 ones are always enabled.
+func configurableCipherSuites() map[string]uint16 {
+	suites := make(map[string]uint16)
+
+	for _, suite := range tls.CipherSuites() {
+		for _, version := range suite.SupportedVersions {
+			if version <= tls.VersionTLS12 {
+				suites[suite.Name] = suite.ID
+
+				break
+			}
+		}
+	}
+
+	return suites
+}
+
+// CipherSuiteNames returns the names of the cipher suites accepted by CipherSuites, sorted.
+func CipherSuiteNames() []string {
+	suites := configurableCipherSuites()
+
+	names := make([]string, 0, len(suites))
+	for name := range suites {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// CipherSuites returns the IDs of the cipher suites of the given names, in the same order. Only the
+// secure TLS 1.0-1.2 cipher suites of crypto/tls are accepted.
+func CipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	suites := configurableCipherSuites()
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := suites[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q, allowed values: %s", name, strings.Join(CipherSuiteNames(), ", "))
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// TLSConfig returns the tls config of the https and grpc servers, without certificate.
+func (s *SecureServingInfo) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:               s.MinTLSVersion,
+		CipherSuites:             s.CipherSuites,
+		PreferServerCipherSuites: s.PreferServerCipherSuites,
+	}
+}
+
+// ValidateHTTP2 returns an error if the https server can not serve http/2 with the tls config, e.g.
+// because the cipher suites miss the ones required by http/2.
+func (s *SecureServingInfo) ValidateHTTP2() error {
+	return http2.ConfigureServer(&http.Server{TLSConfig: s.TLSConfig()}, nil)
+}
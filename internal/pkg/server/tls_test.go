@@ -0,0 +1,202 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCipherSuites(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		want    []uint16
+		wantErr string
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name:  "in the given order",
+			names: []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+			want:  []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		},
+		{
+			name:    "insecure",
+			names:   []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_RSA_WITH_RC4_128_SHA"},
+			wantErr: `unknown cipher suite "TLS_RSA_WITH_RC4_128_SHA", allowed values: `,
+		},
+		{
+			name:    "tls 1.3",
+			names:   []string{"TLS_AES_128_GCM_SHA256"},
+			wantErr: `unknown cipher suite "TLS_AES_128_GCM_SHA256", allowed values: `,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CipherSuites(tt.names)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("CipherSuites() error = %v, want %s...", err, tt.wantErr)
+				}
+
+				// the allowed values are listed.
+				if !strings.Contains(err.Error(), "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256") {
+					t.Errorf("CipherSuites() error = %v, want the allowed values", err)
+				}
+
+				return
+			}
+
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CipherSuites() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTLSVersion(t *testing.T) {
+	if got, err := TLSVersion("VersionTLS12"); err != nil || got != tls.VersionTLS12 {
+		t.Errorf("TLSVersion(VersionTLS12) = %v, %v, want %v", got, err, tls.VersionTLS12)
+	}
+
+	want := `unknown tls version "1.2", allowed values: VersionTLS10, VersionTLS11, VersionTLS12, VersionTLS13`
+	if _, err := TLSVersion("1.2"); err == nil || err.Error() != want {
+		t.Errorf("TLSVersion(1.2) error = %v, want %s", err, want)
+	}
+}
+
+func TestSecureServingInfo_ValidateHTTP2(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    SecureServingInfo
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name: "with an http/2 cipher suite",
+			info: SecureServingInfo{
+				CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA},
+			},
+		},
+		{
+			name:    "without http/2 cipher suite",
+			info:    SecureServingInfo{CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA}},
+			wantErr: true,
+		},
+		{
+			name: "tls 1.3 only",
+			info: SecureServingInfo{
+				MinTLSVersion: tls.VersionTLS13,
+				CipherSuites:  []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.info.ValidateHTTP2(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateHTTP2() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenericAPIServer_TLSConfig(t *testing.T) {
+	s := &GenericAPIServer{
+		Engine: gin.New(),
+		SecureServingInfo: &SecureServingInfo{
+			MinTLSVersion: tls.VersionTLS12,
+			CipherSuites: []uint16{
+				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			},
+		},
+	}
+	s.GET("/healthz", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	// the https server of Run.
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = s.newHTTPServer("")
+	ts.Config.TLSConfig = s.SecureServingInfo.TLSConfig()
+
+	if err := s.configureHTTP2(ts.Config); err != nil {
+		t.Fatalf("configureHTTP2() error = %v", err)
+	}
+
+	ts.TLS = ts.Config.TLSConfig
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+
+	tests := []struct {
+		name   string
+		client *tls.Config
+		want   bool
+	}{
+		{
+			name:   "tls 1.1",
+			client: &tls.Config{MaxVersion: tls.VersionTLS11},
+		},
+		{
+			name: "cipher suites not allowed",
+			client: &tls.Config{
+				MaxVersion: tls.VersionTLS12,
+				CipherSuites: []uint16{
+					tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+					tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+					tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+				},
+			},
+		},
+		{
+			name: "cipher suite allowed",
+			client: &tls.Config{
+				MaxVersion:   tls.VersionTLS12,
+				CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+			},
+			want: true,
+		},
+		{
+			name:   "tls 1.3",
+			client: &tls.Config{MinVersion: tls.VersionTLS13},
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.client.RootCAs = roots
+			tt.client.NextProtos = []string{"h2"}
+
+			conn, err := tls.Dial("tcp", ts.Listener.Addr().String(), tt.client)
+			if (err == nil) != tt.want {
+				t.Fatalf("tls.Dial() error = %v, want success %v", err, tt.want)
+			}
+
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			// http/2 is still negotiated with the restricted cipher suites.
+			if state := conn.ConnectionState(); state.NegotiatedProtocol != "h2" {
+				t.Errorf("negotiated protocol = %q, want h2", state.NegotiatedProtocol)
+			}
+		})
+	}
+}
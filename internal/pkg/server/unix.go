@@ -0,0 +1,102 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// staleSocketTimeout is the time to wait for a connection to an existing socket before considering it
+// stale.
+const staleSocketTimeout = time.Second
+
+// UnixSocketInfo holds configuration of the unix socket the insecure http server listens on.
+type UnixSocketInfo struct {
+	Path string
+	// Mode is the permission of the socket file.
+	Mode os.FileMode
+	// UID and GID own the socket file, -1 keeps the user or the group of the process.
+	UID int
+	GID int
+}
+
+// listenUnix listens on the unix socket, which is removed once the listener is closed. An existing
+// socket is replaced if no process listens on it anymore, any other existing file is an error.
+func (s *GenericAPIServer) listenUnix(info *UnixSocketInfo) (net.Listener, error) {
+	if err := removeStaleSocket(info.Path); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", info.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(info.Path, info.Mode); err != nil {
+		_ = ln.Close()
+
+		return nil, err
+	}
+
+	if info.UID != -1 || info.GID != -1 {
+		if err := os.Chown(info.Path, info.UID, info.GID); err != nil {
+			_ = ln.Close()
+
+			return nil, err
+		}
+	}
+
+	return s.limit(ln, false), nil
+}
+
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s already exists and is not a unix socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, staleSocketTimeout)
+	if err == nil {
+		conn.Close()
+
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+
+	return os.Remove(path)
+}
+
+// localClient returns the client of the urls returned by localURL, which connects to the unix socket
+// when the server does not listen on tcp.
+func (s *GenericAPIServer) localClient() *http.Client {
+	socket := s.InsecureServingInfo.Socket
+	if s.InsecureServingInfo.Address != "" || socket == nil {
+		return http.DefaultClient
+	}
+
+	// the connections are not kept alive for the clients not to leak them.
+	return &http.Client{
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+
+				return d.DialContext(ctx, "unix", socket.Path)
+			},
+		},
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newUnixServer(t *testing.T, info *UnixSocketInfo) (*GenericAPIServer, net.Listener, error) {
+	t.Helper()
+
+	s := &GenericAPIServer{
+		Engine:              gin.New(),
+		InsecureServingInfo: &InsecureServingInfo{Socket: info},
+	}
+	s.GET("/healthz", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	ln, err := s.listenUnix(info)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.insecureServer = s.newHTTPServer("")
+
+	go func() {
+		_ = s.insecureServer.Serve(ln)
+	}()
+
+	return s, ln, nil
+}
+
+func TestGenericAPIServer_listenUnix(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, path string)
+		wantErr bool
+	}{
+		{
+			name:    "new socket",
+			prepare: func(t *testing.T, path string) {},
+		},
+		{
+			name: "stale socket",
+			prepare: func(t *testing.T, path string) {
+				ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// leave the socket file behind, as a killed server does.
+				ln.SetUnlinkOnClose(false)
+				ln.Close()
+			},
+		},
+		{
+			name: "socket in use",
+			prepare: func(t *testing.T, path string) {
+				ln, err := net.Listen("unix", path)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				t.Cleanup(func() { ln.Close() })
+			},
+			wantErr: true,
+		},
+		{
+			name: "regular file",
+			prepare: func(t *testing.T, path string) {
+				if err := ioutil.WriteFile(path, []byte("data"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "iam.sock")
+			tt.prepare(t, path)
+
+			s, _, err := newUnixServer(t, &UnixSocketInfo{Path: path, Mode: 0o640, UID: -1, GID: -1})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("listenUnix() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			fi, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if fi.Mode().Perm() != 0o640 {
+				t.Errorf("socket mode = %v, want %v", fi.Mode().Perm(), os.FileMode(0o640))
+			}
+
+			// the server is requested through the socket, as the local checks do.
+			resp, err := s.localClient().Get(s.localURL("/healthz"))
+			if err != nil {
+				t.Fatalf("GET /healthz error = %v", err)
+			}
+
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+				t.Errorf("GET /healthz = %d %q, want 200 ok", resp.StatusCode, body)
+			}
+
+			if err := s.insecureServer.Shutdown(context.Background()); err != nil {
+				t.Fatalf("Shutdown() error = %v", err)
+			}
+
+			if _, err := os.Lstat(path); !os.IsNotExist(err) {
+				t.Errorf("socket exists after shutdown, error = %v", err)
+			}
+		})
+	}
+}
+
+func TestGenericAPIServer_localClient(t *testing.T) {
+	socket := &UnixSocketInfo{Path: "/run/iam.sock"}
+
+	tests := []struct {
+		name        string
+		info        *InsecureServingInfo
+		wantDefault bool
+		wantURL     string
+	}{
+		{
+			name:        "tcp",
+			info:        &InsecureServingInfo{Address: "127.0.0.1:8080"},
+			wantDefault: true,
+			wantURL:     "http://127.0.0.1:8080/healthz",
+		},
+		{
+			name:        "tcp and unix socket",
+			info:        &InsecureServingInfo{Address: "0.0.0.0:8080", Socket: socket},
+			wantDefault: true,
+			wantURL:     "http://127.0.0.1:8080/healthz",
+		},
+		{
+			name:    "unix socket only",
+			info:    &InsecureServingInfo{Socket: socket},
+			wantURL: "http://localhost/healthz",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &GenericAPIServer{InsecureServingInfo: tt.info}
+
+			if got := s.localClient() == http.DefaultClient; got != tt.wantDefault {
+				t.Errorf("localClient() is the default client = %v, want %v", got, tt.wantDefault)
+			}
+
+			if got := s.localURL("/healthz"); got != tt.wantURL {
+				t.Errorf("localURL() = %v, want %v", got, tt.wantURL)
+			}
+		})
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/marmotedu/component-base/pkg/json"
 )
 
 // AnalyticsRecord encodes the details of a authorization request.
@@ -24,6 +26,30 @@ type AnalyticsRecord struct {
 	ExpireAt   time.Time `json:"expireAt"   bson:"expireAt"`
 }
 
+// ParsePolicies returns the IDs of the policies matched by the request. Policies holds the
+// matched policies serialized to a JSON array by iam-authz-server, nil is returned when it is
+// empty or malformed.
+func (a *AnalyticsRecord) ParsePolicies() []string {
+	if a.Policies == "" {
+		return nil
+	}
+
+	var policies []struct {
+		ID string `json:"id"`
+	}
+
+	if err := json.Unmarshal([]byte(a.Policies), &policies); err != nil {
+		return nil
+	}
+
+	ids := make([]string, 0, len(policies))
+	for _, policy := range policies {
+		ids = append(ids, policy.ID)
+	}
+
+	return ids
+}
+
 // GetFieldNames returns all the AnalyticsRecord field names.
 func (a *AnalyticsRecord) GetFieldNames() []string {
 	val := reflect.ValueOf(a).Elem()
@@ -0,0 +1,57 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package analytics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyticsRecord_ParsePolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies string
+		want     []string
+	}{
+		{
+			name:     "empty",
+			policies: "",
+			want:     nil,
+		},
+		{
+			name:     "no policy",
+			policies: "[]",
+			want:     []string{},
+		},
+		{
+			name:     "null",
+			policies: "null",
+			want:     []string{},
+		},
+		{
+			name:     "single policy",
+			policies: `[{"id":"68","description":"One policy to rule them all.","effect":"allow"}]`,
+			want:     []string{"68"},
+		},
+		{
+			name:     "multiple policies",
+			policies: `[{"id":"68","effect":"allow"},{"id":"69","effect":"deny"}]`,
+			want:     []string{"68", "69"},
+		},
+		{
+			name:     "malformed",
+			policies: "68, 69",
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &AnalyticsRecord{Policies: tt.policies}
+			if got := a.ParsePolicies(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePolicies() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -26,6 +26,7 @@ type PumpConfig struct {
 // Options runs a pumpserver.
 type Options struct {
 	PurgeDelay            int                          `json:"purge-delay"             mapstructure:"purge-delay"`
+	PurgeChunkSize        int64                        `json:"purge-chunk-size"        mapstructure:"purge-chunk-size"`
 	Pumps                 map[string]PumpConfig        `json:"pumps"                   mapstructure:"pumps"`
 	HealthCheckPath       string                       `json:"health-check-path"       mapstructure:"health-check-path"`
 	HealthCheckAddress    string                       `json:"health-check-address"    mapstructure:"health-check-address"`
@@ -37,7 +38,8 @@ type Options struct {
 // NewOptions creates a new Options object with default parameters.
 func NewOptions() *Options {
 	s := Options{
-		PurgeDelay: 10,
+		PurgeDelay:     10,
+		PurgeChunkSize: 10000,
 		Pumps: map[string]PumpConfig{
 			"csv": {
 				Type: "csv",
@@ -65,6 +67,9 @@ func (o *Options) Flags() (fss cliflag.NamedFlagSets) {
 	fs := fss.FlagSet("misc")
 	fs.IntVar(&o.PurgeDelay, "purge-delay", o.PurgeDelay, ""+
 		"This setting the purge delay (in seconds) when purge the data from Redis to MongoDB or other data stores.")
+	fs.Int64Var(&o.PurgeChunkSize, "purge-chunk-size", o.PurgeChunkSize, ""+
+		"The number of authorization logs purged from Redis and written to the pumps at a time, "+
+		"bounding the memory used by large purges.")
 	fs.StringVar(&o.HealthCheckPath, "health-check-path", o.HealthCheckPath, ""+
 		"Specifies liveness health check request path.")
 	fs.StringVar(&o.HealthCheckAddress, "health-check-address", o.HealthCheckAddress, ""+
@@ -80,3 +85,8 @@ func (o *Options) String() string {
 
 	return string(data)
 }
+
+// Complete reads the passwords of redis from their files if set.
+func (o *Options) Complete() error {
+	return o.RedisOptions.Complete()
+}
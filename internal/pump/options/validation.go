@@ -4,10 +4,25 @@
 
 package options
 
+import (
+	"fmt"
+
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
 // Validate checks Options and return a slice of found errs.
 func (o *Options) Validate() []error {
 	var errs []error
 
+	if o.PurgeChunkSize <= 0 {
+		errs = append(errs, fmt.Errorf("--purge-chunk-size %d must be greater than 0", o.PurgeChunkSize))
+	}
+
+	// the data is shared with the other IAM components through redis.
+	if o.RedisOptions.Type == storage.TypeMemory {
+		errs = append(errs, fmt.Errorf("--redis.type %s is not supported by iam-pump", storage.TypeMemory))
+	}
+
 	errs = append(errs, o.RedisOptions.Validate()...)
 	errs = append(errs, o.Log.Validate()...)
 
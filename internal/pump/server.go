@@ -22,12 +22,14 @@ import (
 	"github.com/marmotedu/iam/internal/pump/storage"
 	"github.com/marmotedu/iam/internal/pump/storage/redis"
 	"github.com/marmotedu/iam/pkg/log"
+	genericstorage "github.com/marmotedu/iam/pkg/storage"
 )
 
 var pmps []pumps.Pump
 
 type pumpServer struct {
 	secInterval    int
+	chunkSize      int64
 	omitDetails    bool
 	mutex          *redsync.Mutex
 	analyticsStore storage.AnalyticsStorage
@@ -48,11 +50,14 @@ func createPumpServer(cfg *config.Config) (*pumpServer, error) {
 	})
 
 	rs := redsync.New(goredis.NewPool(client))
+	// the pumps of the environments sharing the redis must not lock each other.
+	mutexName := genericstorage.JoinKeyPrefix(cfg.RedisOptions.KeyPrefix, "iam-pump")
 
 	server := &pumpServer{
 		secInterval:    cfg.PurgeDelay,
+		chunkSize:      cfg.PurgeChunkSize,
 		omitDetails:    cfg.OmitDetailedRecording,
-		mutex:          rs.NewMutex("iam-pump", redsync.WithExpiry(10*time.Minute)),
+		mutex:          rs.NewMutex(mutexName, redsync.WithExpiry(10*time.Minute)),
 		analyticsStore: &redis.RedisClusterStorageManager{},
 		pumps:          cfg.Pumps,
 	}
@@ -101,11 +106,15 @@ func (s *pumpServer) pump() {
 		}
 	}()
 
-	analyticsValues := s.analyticsStore.GetAndDeleteSet(storage.AnalyticsKeyName)
-	if len(analyticsValues) == 0 {
-		return
+	// the authorization logs are purged and written chunk by chunk. writeChunk never fails as the
+	// pumps do not report their failures, a chunk is thus never pushed back and written again.
+	if err := s.analyticsStore.GetAndDeleteSetChunked(storage.AnalyticsKeyName, s.chunkSize, s.writeChunk); err != nil {
+		log.Errorf("purge authorization logs failed: %s", err.Error())
 	}
+}
 
+// writeChunk decodes a chunk of authorization logs and writes them to the pumps.
+func (s *pumpServer) writeChunk(analyticsValues []interface{}) error {
 	// Convert to something clean
 	keys := make([]interface{}, len(analyticsValues))
 
@@ -126,6 +135,8 @@ func (s *pumpServer) pump() {
 
 	// Send to pumps
 	writeToPumps(keys, s.secInterval)
+
+	return nil
 }
 
 func (s *pumpServer) initialize() {
@@ -6,7 +6,7 @@
 package redis
 
 import (
-	"crypto/tls"
+	"fmt"
 	"strconv"
 	"time"
 
@@ -16,6 +16,7 @@ import (
 
 	genericoptions "github.com/marmotedu/iam/internal/pkg/options"
 	"github.com/marmotedu/iam/pkg/log"
+	"github.com/marmotedu/iam/pkg/storage"
 )
 
 // ------------------- REDIS CLUSTER STORAGE MANAGER -------------------------------
@@ -63,18 +64,15 @@ func NewRedisClusterPool(forceReconnect bool, config genericoptions.RedisOptions
 		timeout = time.Duration(config.Timeout) * time.Second
 	}
 
-	var tlsConfig *tls.Config
-	if config.UseSSL {
-		tlsConfig = &tls.Config{
-			InsecureSkipVerify: config.SSLInsecureSkipVerify,
-		}
-	}
+	// the tls options are already validated by Init.
+	tlsConfig, _ := config.TLSConfig()
 
 	var client redis.UniversalClient
 	opts := &RedisOpts{
 		MasterName:   config.MasterName,
 		Addrs:        getRedisAddrs(config),
 		DB:           config.Database,
+		Username:     config.Username,
 		Password:     config.Password,
 		PoolSize:     maxActive,
 		IdleTimeout:  240 * time.Second,
@@ -86,7 +84,7 @@ func NewRedisClusterPool(forceReconnect bool, config genericoptions.RedisOptions
 
 	if opts.MasterName != "" {
 		log.Info("--> [REDIS] Creating sentinel-backed failover client")
-		client = redis.NewFailoverClient(opts.failover())
+		client = redis.NewFailoverClient(opts.failover(config))
 	} else if config.EnableCluster {
 		log.Info("--> [REDIS] Creating cluster client")
 		client = redis.NewClusterClient(opts.cluster())
@@ -127,6 +125,7 @@ func (o *RedisOpts) cluster() *redis.ClusterOptions {
 		Addrs:     o.Addrs,
 		OnConnect: o.OnConnect,
 
+		Username: o.Username,
 		Password: o.Password,
 
 		MaxRedirects:   o.MaxRedirects,
@@ -163,6 +162,7 @@ func (o *RedisOpts) simple() *redis.Options {
 		OnConnect: o.OnConnect,
 
 		DB:       o.DB,
+		Username: o.Username,
 		Password: o.Password,
 
 		MaxRetries:      o.MaxRetries,
@@ -184,17 +184,27 @@ func (o *RedisOpts) simple() *redis.Options {
 	}
 }
 
-func (o *RedisOpts) failover() *redis.FailoverOptions {
-	if len(o.Addrs) == 0 {
-		o.Addrs = []string{"127.0.0.1:26379"}
+// failover returns the options of the sentinel-backed failover client, the sentinels are found at
+// config.SentinelAddrs (o.Addrs if empty) and authenticated with the sentinel credentials of config.
+func (o *RedisOpts) failover(config genericoptions.RedisOptions) *redis.FailoverOptions {
+	sentinelAddrs := config.SentinelAddrs
+	if len(sentinelAddrs) == 0 {
+		sentinelAddrs = o.Addrs
+	}
+
+	if len(sentinelAddrs) == 0 {
+		sentinelAddrs = []string{"127.0.0.1:26379"}
 	}
 
 	return &redis.FailoverOptions{
-		SentinelAddrs: o.Addrs,
-		MasterName:    o.MasterName,
-		OnConnect:     o.OnConnect,
+		SentinelAddrs:    sentinelAddrs,
+		SentinelUsername: config.SentinelUsername,
+		SentinelPassword: config.SentinelPassword,
+		MasterName:       o.MasterName,
+		OnConnect:        o.OnConnect,
 
 		DB:       o.DB,
+		Username: o.Username,
 		Password: o.Password,
 
 		MaxRetries:      o.MaxRetries,
@@ -229,7 +239,18 @@ func (r *RedisClusterStorageManager) Init(config interface{}) error {
 		log.Fatalf("Failed to decode configuration: %s", err.Error())
 	}
 
-	r.KeyPrefix = RedisKeyPrefix
+	if _, err := r.Config.TLSConfig(); err != nil {
+		return errors.Wrap(err, "invalid redis tls options")
+	}
+
+	if r.Config.UseSSL && r.Config.SSLInsecureSkipVerify {
+		log.Warn("!!! ssl-insecure-skip-verify is set, the certificate of redis will NOT be verified " +
+			"and the connection is open to man-in-the-middle attacks, never use it in production !!!")
+	}
+
+	// the analytics records are written by iam-authz-server in the namespace of --redis.key-prefix.
+	r.KeyPrefix = storage.JoinKeyPrefix(r.Config.KeyPrefix, RedisKeyPrefix)
+	log.Infof("Reading the analytics records from the redis keys prefixed with %q", r.KeyPrefix)
 
 	return nil
 }
@@ -304,6 +325,65 @@ func (r *RedisClusterStorageManager) GetAndDeleteSet(keyName string) []interface
 	return result
 }
 
+// popChunkScript removes the ARGV[1] first values of the list KEYS[1] and returns them, like LPOP with
+// a count which is only supported since redis 6.2.
+var popChunkScript = redis.NewScript(`
+local values = redis.call('LRANGE', KEYS[1], 0, tonumber(ARGV[1]) - 1)
+if #values > 0 then
+	redis.call('LTRIM', KEYS[1], #values, -1)
+end
+return values
+`)
+
+// GetAndDeleteSetChunked pops the values of the list keyName chunkSize at a time and calls fn with each
+// chunk. It stops at the first error returned by fn, the chunk is then pushed back to the head of the
+// list and the remaining values are left for the next call, so a chunk may be delivered more than once.
+func (r *RedisClusterStorageManager) GetAndDeleteSetChunked(
+	keyName string,
+	chunkSize int64,
+	fn func(values []interface{}) error,
+) error {
+	if chunkSize <= 0 {
+		return fmt.Errorf("invalid chunk size %d, must be positive", chunkSize)
+	}
+
+	r.ensureConnection()
+	fixedKey := r.fixKey(keyName)
+
+	for {
+		reply, err := popChunkScript.Run(r.db, []string{fixedKey}, chunkSize).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			log.Errorf("Pop chunk failed: %s", err)
+
+			return errors.Wrap(err, "failed to pop chunk")
+		}
+
+		values, _ := reply.([]interface{})
+		log.Debugf("Popped chunk of %d values", len(values))
+
+		if len(values) == 0 {
+			return nil
+		}
+
+		if err := fn(values); err != nil {
+			reversed := make([]interface{}, len(values))
+			for i, val := range values {
+				reversed[len(values)-1-i] = val
+			}
+
+			if pushErr := r.db.LPush(fixedKey, reversed...).Err(); pushErr != nil {
+				log.Errorf("Push back %d values failed, they are lost: %s", len(values), pushErr)
+			}
+
+			return err
+		}
+
+		if int64(len(values)) < chunkSize {
+			return nil
+		}
+	}
+}
+
 // SetKey will create (or update) a key value in the store.
 func (r *RedisClusterStorageManager) SetKey(keyName, session string, timeout int64) error {
 	log.Debugf("[STORE] SET Raw key is: %s", keyName)
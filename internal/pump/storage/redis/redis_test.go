@@ -59,10 +59,31 @@ func TestRedisAddressConfiguration(t *testing.T) {
 		}
 
 		opts.Addrs = []string{}
-		failoverOpts := opts.failover()
+		failoverOpts := opts.failover(genericoptions.RedisOptions{})
 
 		if failoverOpts.SentinelAddrs[0] != "127.0.0.1:26379" || len(failoverOpts.SentinelAddrs) != 1 {
 			t.Fatal("Wrong default sentinel mode address")
 		}
 	})
+
+	t.Run("Sentinel addresses and credentials", func(t *testing.T) {
+		opts := &RedisOpts{Addrs: []string{"redis:6379"}, Username: "node", Password: "node-secret"}
+		failoverOpts := opts.failover(genericoptions.RedisOptions{
+			SentinelAddrs:    []string{"sentinel:26379"},
+			SentinelUsername: "sentinel",
+			SentinelPassword: "sentinel-secret",
+		})
+
+		if failoverOpts.SentinelAddrs[0] != "sentinel:26379" || len(failoverOpts.SentinelAddrs) != 1 {
+			t.Fatal("Wrong sentinel address")
+		}
+
+		if failoverOpts.SentinelUsername != "sentinel" || failoverOpts.SentinelPassword != "sentinel-secret" {
+			t.Fatal("Wrong sentinel credentials")
+		}
+
+		if failoverOpts.Username != "node" || failoverOpts.Password != "node-secret" {
+			t.Fatal("Wrong node credentials")
+		}
+	})
 }
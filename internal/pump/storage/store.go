@@ -11,6 +11,7 @@ type AnalyticsStorage interface {
 	GetName() string
 	Connect() bool
 	GetAndDeleteSet(string) []interface{}
+	GetAndDeleteSetChunked(keyName string, chunkSize int64, fn func(values []interface{}) error) error
 }
 
 const (
@@ -8,6 +8,7 @@ package options
 import (
 	cliflag "github.com/marmotedu/component-base/pkg/cli/flag"
 	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/marmotedu/errors"
 
 	genericoptions "github.com/marmotedu/iam/internal/pkg/options"
 	"github.com/marmotedu/iam/pkg/log"
@@ -95,3 +96,18 @@ func (o *Options) String() string {
 
 	return string(data)
 }
+
+// Complete reads the passwords of mysql and redis from their files if set.
+func (o *Options) Complete() error {
+	var errs []error
+
+	if err := o.MySQLOptions.Complete(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := o.RedisOptions.Complete(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.NewAggregate(errs)
+}
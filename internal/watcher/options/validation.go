@@ -4,10 +4,21 @@
 
 package options
 
+import (
+	"fmt"
+
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
 // Validate checks Options and return a slice of found errs.
 func (o *Options) Validate() []error {
 	var errs []error
 
+	// the data is shared with the other IAM components through redis.
+	if o.RedisOptions.Type == storage.TypeMemory {
+		errs = append(errs, fmt.Errorf("--redis.type %s is not supported by iam-watcher", storage.TypeMemory))
+	}
+
 	errs = append(errs, o.RedisOptions.Validate()...)
 	errs = append(errs, o.MySQLOptions.Validate()...)
 	errs = append(errs, o.Log.Validate()...)
@@ -5,6 +5,7 @@
 package watcher
 
 import (
+	"context"
 	"time"
 
 	"github.com/marmotedu/iam/internal/apiserver/store/mysql"
@@ -32,6 +33,9 @@ type preparedWatcherServer struct {
 func createWatcherServer(cfg *config.Config) *watcherServer {
 	gs := shutdown.New()
 	gs.AddShutdownManager(posixsignal.NewPosixSignalManager())
+	gs.SetErrorHandler(shutdown.ErrorFunc(func(err error) {
+		log.Warnf("Shutdown error: %s", err.Error())
+	}))
 
 	server := &watcherServer{
 		gs:             gs,
@@ -50,9 +54,21 @@ func (s *watcherServer) PrepareRun() preparedWatcherServer {
 		panic(err)
 	}
 
-	s.gs.AddShutdownCallback(shutdown.ShutdownFunc(func(string) error {
+	s.gs.AddShutdownCallbackWithPriority(shutdown.ShutdownFunc(func(string) error {
 		return mysqlStore.Close()
-	}))
+	}), shutdown.PriorityCloseStores)
+
+	// the database is pinged periodically, for the store to heal once mysql is back.
+	if s.mysqlOptions.HealthCheckInterval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		go mysql.RunHealthCheck(ctx, s.mysqlOptions.HealthCheckInterval, s.mysqlOptions.HealthCheckTimeout)
+
+		s.gs.AddShutdownCallbackWithPriority(shutdown.ShutdownFunc(func(string) error {
+			cancel()
+
+			return nil
+		}), shutdown.PriorityCloseStores)
+	}
 
 	s.cron = newWatchJob(s.redisOptions, s.watcherOptions).addWatchers()
 
@@ -61,7 +77,7 @@ func (s *watcherServer) PrepareRun() preparedWatcherServer {
 
 func (s preparedWatcherServer) Run() error {
 	stopCh := make(chan struct{})
-	s.gs.AddShutdownCallback(shutdown.ShutdownFunc(func(string) error {
+	s.gs.AddShutdownCallbackWithPriority(shutdown.ShutdownFunc(func(string) error {
 		// wait for running jobs to complete.
 		ctx := s.cron.Stop()
 		select {
@@ -72,7 +88,7 @@ func (s preparedWatcherServer) Run() error {
 		}
 
 		return nil
-	}))
+	}), shutdown.PriorityStopJobs)
 
 	// start shutdown managers
 	if err := s.gs.Start(); err != nil {
@@ -22,12 +22,15 @@ import (
 	_ "github.com/marmotedu/iam/internal/watcher/watcher/all"
 	"github.com/marmotedu/iam/pkg/log"
 	"github.com/marmotedu/iam/pkg/log/cronlog"
+	"github.com/marmotedu/iam/pkg/storage"
 )
 
 type watchJob struct {
 	*cron.Cron
 	config *options.WatcherOptions
 	rs     *redsync.Redsync
+	// keyPrefix namespaces the names of the watcher locks, see --redis.key-prefix.
+	keyPrefix string
 }
 
 func newWatchJob(redisOptions *genericoptions.RedisOptions, watcherOptions *options.WatcherOptions) *watchJob {
@@ -47,9 +50,10 @@ func newWatchJob(redisOptions *genericoptions.RedisOptions, watcherOptions *opti
 	)
 
 	return &watchJob{
-		Cron:   cron,
-		config: watcherOptions,
-		rs:     rs,
+		Cron:      cron,
+		config:    watcherOptions,
+		rs:        rs,
+		keyPrefix: redisOptions.KeyPrefix,
 	}
 }
 
@@ -59,7 +63,7 @@ func (w *watchJob) addWatchers() *watchJob {
 		//nolint: golint,staticcheck
 		ctx := context.WithValue(context.Background(), log.KeyWatcherName, name)
 
-		if err := watcher.Init(ctx, w.rs.NewMutex(name, redsync.WithExpiry(2*time.Hour)), w.config); err != nil {
+		if err := watcher.Init(ctx, w.rs.NewMutex(storage.JoinKeyPrefix(w.keyPrefix, name), redsync.WithExpiry(2*time.Hour)), w.config); err != nil {
 			log.Panicf("construct watcher %s failed: %s", name, err.Error())
 		}
 
@@ -37,7 +37,13 @@ func (cw *cleanWatcher) Run() {
 		}
 	}()
 
-	db, _ := mysql.GetMySQLFactoryOr(nil)
+	// the store is created again if mysql could not be reached before.
+	db, err := mysql.GetMySQLFactoryOr(nil)
+	if err != nil {
+		log.L(cw.ctx).Errorw("get the mysql store failed", "error", err)
+
+		return
+	}
 
 	rowsAffected, err := db.PolicyAudits().ClearOutdated(cw.ctx, cw.maxReserveDays)
 	if err != nil {
@@ -38,7 +38,13 @@ func (tw *taskWatcher) Run() {
 		}
 	}()
 
-	db, _ := mysql.GetMySQLFactoryOr(nil)
+	// the store is created again if mysql could not be reached before.
+	db, err := mysql.GetMySQLFactoryOr(nil)
+	if err != nil {
+		log.L(tw.ctx).Errorw("get the mysql store failed", "error", err)
+
+		return
+	}
 
 	users, err := db.Users().List(tw.ctx, metav1.ListOptions{})
 	if err != nil {
@@ -13,12 +13,11 @@ import (
 	"github.com/marmotedu/component-base/pkg/cli/globalflag"
 	"github.com/marmotedu/component-base/pkg/term"
 	"github.com/marmotedu/component-base/pkg/version"
-	"github.com/marmotedu/component-base/pkg/version/verflag"
-	"github.com/marmotedu/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/marmotedu/iam/pkg/log"
+	"github.com/marmotedu/iam/pkg/version/verflag"
 )
 
 var (
@@ -130,6 +129,13 @@ func WithNoConfig() Option {
 	}
 }
 
+// WithCommands adds sub commands to the application, before its cobra command is built.
+func WithCommands(cmds ...*Command) Option {
+	return func(a *App) {
+		a.commands = append(a.commands, cmds...)
+	}
+}
+
 // WithValidArgs set the validation function to valid non-flag arguments.
 func WithValidArgs(args cobra.PositionalArgs) Option {
 	return func(a *App) {
@@ -184,6 +190,10 @@ func (a *App) buildCommand() {
 	cmd.SetErr(os.Stderr)
 	cmd.Flags().SortFlags = true
 	cliflag.InitFlags(cmd.Flags())
+	// the usage is not printed on error, the invalid flags, e.g. --version=yaml, point to it.
+	cmd.SetFlagErrorFunc(func(c *cobra.Command, err error) error {
+		return fmt.Errorf("%w, see '%s --help' for usage", err, c.CommandPath())
+	})
 
 	if len(a.commands) > 0 {
 		for _, command := range a.commands {
@@ -244,6 +254,10 @@ func (a *App) runCommand(cmd *cobra.Command, args []string) error {
 			return err
 		}
 
+		if err := bindEnvs(a.options); err != nil {
+			return err
+		}
+
 		if err := viper.Unmarshal(a.options); err != nil {
 			return err
 		}
@@ -278,8 +292,8 @@ func (a *App) applyOptionRules() error {
 		}
 	}
 
-	if errs := a.options.Validate(); len(errs) != 0 {
-		return errors.NewAggregate(errs)
+	if err := newInvalidOptionsError(a.options.Validate()); err != nil {
+		return err
 	}
 
 	if printableOptions, ok := a.options.(PrintableOptions); ok && !a.silence {
@@ -0,0 +1,50 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApp_versionFlag(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr []string
+	}{
+		{
+			name:    "unknown format",
+			args:    []string{"--version=yaml"},
+			wantErr: []string{"must be one of true, false, raw, json, short", "see 'iam-test --help' for usage"},
+		},
+		{
+			name:    "unknown flag",
+			args:    []string{"--versions"},
+			wantErr: []string{"unknown flag: --versions", "see 'iam-test --help' for usage"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewApp("IAM Test", "iam-test",
+				WithNoConfig(),
+				WithSilence(),
+				WithRunFunc(func(string) error { return nil }),
+			).Command()
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if err == nil {
+				t.Fatal("Execute() error = nil, want the invalid flag reported")
+			}
+
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Execute() error = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -12,6 +12,8 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 )
 
 // Command is a sub command structure of a cli application.
@@ -89,10 +91,18 @@ func (c *Command) cobraCommand() *cobra.Command {
 		cmd.Run = c.runCommand
 	}
 	if c.options != nil {
-		for _, f := range c.options.Flags().FlagSets {
+		namedFlagSets := c.options.Flags()
+		for _, f := range namedFlagSets.FlagSets {
 			cmd.Flags().AddFlagSet(f)
 		}
 		// c.options.AddFlags(cmd.Flags())
+
+		// the options are also read from the configuration file of the application.
+		if configFlag := pflag.Lookup(configFlagName); configFlag != nil {
+			namedFlagSets.FlagSet("global").AddFlag(configFlag)
+			cmd.Flags().AddFlag(configFlag)
+		}
+		addCmdTemplate(cmd, namedFlagSets)
 	}
 	addHelpCommandFlag(c.usage, cmd.Flags())
 
@@ -100,6 +110,13 @@ func (c *Command) cobraCommand() *cobra.Command {
 }
 
 func (c *Command) runCommand(cmd *cobra.Command, args []string) {
+	if c.options != nil {
+		if err := c.applyOptions(cmd.Flags()); err != nil {
+			fmt.Printf("%v %v\n", color.RedString("Error:"), err)
+			os.Exit(1)
+		}
+	}
+
 	if c.runFunc != nil {
 		if err := c.runFunc(args); err != nil {
 			fmt.Printf("%v %v\n", color.RedString("Error:"), err)
@@ -108,6 +125,34 @@ func (c *Command) runCommand(cmd *cobra.Command, args []string) {
 	}
 }
 
+// applyOptions reads the options of the command from its flags, the environment variables and the
+// configuration file, like the options of the application, then completes and validates them.
+func (c *Command) applyOptions(fs *pflag.FlagSet) error {
+	if err := viper.BindPFlags(fs); err != nil {
+		return err
+	}
+
+	if err := bindEnvs(c.options); err != nil {
+		return err
+	}
+
+	if err := viper.Unmarshal(c.options); err != nil {
+		return err
+	}
+
+	if completeableOptions, ok := c.options.(CompleteableOptions); ok {
+		if err := completeableOptions.Complete(); err != nil {
+			return err
+		}
+	}
+
+	if err := newInvalidOptionsError(c.options.Validate()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // AddCommand adds sub command to the application.
 func (a *App) AddCommand(cmd *Command) {
 	a.commands = append(a.commands, cmd)
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 
 	"github.com/gosuri/uitable"
@@ -29,11 +30,16 @@ func init() {
 
 // addConfigFlag adds flags for a specific server to the specified FlagSet
 // object.
+//
+// The options are read from the flags, the environment variables, the configuration file and their
+// defaults, in this order of precedence. The environment variable of an option is named after its key
+// prefixed by the basename, in upper case with the dots and dashes replaced by underscores, e.g.
+// IAM_APISERVER_MYSQL_PASSWORD for mysql.password of iam-apiserver.
 func addConfigFlag(basename string, fs *pflag.FlagSet) {
 	fs.AddFlag(pflag.Lookup(configFlagName))
 
 	viper.AutomaticEnv()
-	viper.SetEnvPrefix(strings.Replace(strings.ToUpper(basename), "-", "_", -1))
+	viper.SetEnvPrefix(envPrefix(basename))
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
 
 	cobra.OnInitialize(func() {
@@ -57,6 +63,79 @@ func addConfigFlag(basename string, fs *pflag.FlagSet) {
 	})
 }
 
+// envPrefix returns the prefix of the environment variables of the options of basename.
+func envPrefix(basename string) string {
+	return strings.Replace(strings.ToUpper(basename), "-", "_", -1)
+}
+
+// bindEnvs binds the keys of options to their environment variables. viper only reads the environment
+// variables of the keys it knows, from the flags, the configuration file or the defaults, the keys are
+// bound for the options without a flag to be read from the environment too, whatever the configuration
+// file. The keys are those of the fields of options, by their mapstructure tags like viper.Unmarshal.
+func bindEnvs(options interface{}) error {
+	if options == nil {
+		return nil
+	}
+
+	return bindStructEnvs("", reflect.TypeOf(options))
+}
+
+func bindStructEnvs(prefix string, typ reflect.Type) error {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := field.Name
+		tag := strings.Split(field.Tag.Get("mapstructure"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+
+		if tag[0] != "" {
+			name = tag[0]
+		}
+
+		if len(tag) > 1 && tag[1] == "squash" {
+			if err := bindStructEnvs(prefix, field.Type); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		switch fieldType.Kind() {
+		// the maps can not be read from a single environment variable.
+		case reflect.Map:
+		case reflect.Struct:
+			if err := bindStructEnvs(prefix+name+".", fieldType); err != nil {
+				return err
+			}
+		default:
+			if err := viper.BindEnv(prefix + name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// printConfig prints the configuration items, the values of the keys which may be secrets are redacted.
 func printConfig() {
 	keys := viper.AllKeys()
 	if len(keys) > 0 {
@@ -66,7 +145,7 @@ func printConfig() {
 		table.MaxColWidth = 80
 		table.RightAlign(0)
 		for _, k := range keys {
-			table.AddRow(fmt.Sprintf("%s:", k), viper.Get(k))
+			table.AddRow(fmt.Sprintf("%s:", k), redact(k, viper.Get(k)))
 		}
 		fmt.Printf("%v", table)
 	}
@@ -0,0 +1,90 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type testTLSOptions struct {
+	CertFile string `mapstructure:"cert-file"`
+}
+
+type testServerOptions struct {
+	Mode    string        `mapstructure:"mode"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	// TLS is squashed, its keys are those of the server.
+	TLS testTLSOptions `mapstructure:",squash"`
+}
+
+type testOptions struct {
+	Server   *testServerOptions `mapstructure:"server"`
+	Tokens   []string           `mapstructure:"tokens"`
+	Password string             `mapstructure:"password"`
+	Labels   map[string]string  `mapstructure:"labels"`
+	Internal string             `mapstructure:"-"`
+	Port     int
+}
+
+func Test_bindEnvs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iam-test.yaml")
+	writeConfig(t, path, "server:\n  mode: debug\n  timeout: 10s\npassword: config\n")
+
+	viper.SetConfigFile(path)
+	defer viper.Reset()
+
+	viper.AutomaticEnv()
+	viper.SetEnvPrefix(envPrefix("iam-test"))
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatalf("ReadInConfig() error = %v", err)
+	}
+
+	// the options without a flag, nor in the configuration file, are read from the environment too.
+	t.Setenv("IAM_TEST_SERVER_TIMEOUT", "1m")
+	t.Setenv("IAM_TEST_SERVER_CERT_FILE", "/etc/iam/cert/iam-test.pem")
+	t.Setenv("IAM_TEST_TOKENS", "token1,token2")
+	t.Setenv("IAM_TEST_PASSWORD", "env")
+	t.Setenv("IAM_TEST_PORT", "8080")
+	t.Setenv("IAM_TEST_INTERNAL", "env")
+
+	opts := &testOptions{Server: &testServerOptions{}}
+	if err := bindEnvs(opts); err != nil {
+		t.Fatalf("bindEnvs() error = %v", err)
+	}
+
+	if err := viper.Unmarshal(opts); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := &testOptions{
+		Server: &testServerOptions{
+			Mode:    "debug",
+			Timeout: time.Minute,
+			TLS:     testTLSOptions{CertFile: "/etc/iam/cert/iam-test.pem"},
+		},
+		Tokens:   []string{"token1", "token2"},
+		Password: "env",
+		Port:     8080,
+	}
+	if !reflect.DeepEqual(opts, want) {
+		t.Errorf("options = %+v, want %+v", opts, want)
+	}
+}
+
+func Test_bindEnvs_nil(t *testing.T) {
+	defer viper.Reset()
+
+	if err := bindEnvs(nil); err != nil {
+		t.Errorf("bindEnvs() error = %v", err)
+	}
+}
@@ -5,6 +5,8 @@
 package app
 
 import (
+	"strings"
+
 	cliflag "github.com/marmotedu/component-base/pkg/cli/flag"
 )
 
@@ -34,3 +36,36 @@ type CompleteableOptions interface {
 type PrintableOptions interface {
 	String() string
 }
+
+// invalidOptionsError reports all the violations of the options returned by Validate, one per line, for
+// them to be fixed at once rather than one per restart.
+type invalidOptionsError struct {
+	errs []error
+}
+
+// newInvalidOptionsError returns the error reporting errs, or nil if there is none.
+func newInvalidOptionsError(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return &invalidOptionsError{errs: errs}
+}
+
+func (e *invalidOptionsError) Error() string {
+	var report strings.Builder
+
+	report.WriteString("the options are invalid:")
+
+	for _, err := range e.errs {
+		report.WriteString("\n  ")
+		report.WriteString(err.Error())
+	}
+
+	return report.String()
+}
+
+// Errors returns the violations of the options.
+func (e *invalidOptionsError) Errors() []error {
+	return e.errs
+}
@@ -0,0 +1,54 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_newInvalidOptionsError(t *testing.T) {
+	tests := []struct {
+		name string
+		errs []error
+		want string
+	}{
+		{name: "valid"},
+		{
+			name: "one violation",
+			errs: []error{fmt.Errorf("--mysql.max-open-connections -1 cannot be negative")},
+			want: "the options are invalid:\n" +
+				"  --mysql.max-open-connections -1 cannot be negative",
+		},
+		{
+			name: "all the violations",
+			errs: []error{
+				fmt.Errorf("--mysql.max-open-connections -1 cannot be negative"),
+				fmt.Errorf("--redis.database 1 can not be used with --redis.enable-cluster"),
+				fmt.Errorf("--access-log.sample-rate 2 must be between 0 and 1"),
+			},
+			want: "the options are invalid:\n" +
+				"  --mysql.max-open-connections -1 cannot be negative\n" +
+				"  --redis.database 1 can not be used with --redis.enable-cluster\n" +
+				"  --access-log.sample-rate 2 must be between 0 and 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newInvalidOptionsError(tt.errs)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("newInvalidOptionsError() = %v, want nil", err)
+				}
+
+				return
+			}
+
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("newInvalidOptionsError() = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,510 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/marmotedu/errors"
+	"github.com/spf13/viper"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// reloadSignal is the signal reloading the configuration, instead of terminating the process.
+var reloadSignal os.Signal = syscall.SIGHUP
+
+// redactedValue replaces the values of the sensitive keys in the logs of the configuration changes.
+const redactedValue = "<redacted>"
+
+// watchDebounce is the quiet period after the last change of the configuration file before it is reloaded
+// by Watch, the editors and the kubernetes ConfigMaps changing a file by several writes or renames.
+var watchDebounce = 500 * time.Millisecond
+
+// ReloadFunc applies the configuration re-read by a Reloader, it reads the new values with viper,
+// e.g. viper.GetString("log.level"). The values in effect are kept if it returns an error.
+type ReloadFunc func() error
+
+// SignalFunc is called on each SIGHUP, before the configuration is reloaded, whether it changed or not,
+// e.g. to reopen the log files rotated by logrotate.
+type SignalFunc func() error
+
+// ValidateFunc validates the configuration re-read by a Reloader, before it is applied. The whole new
+// configuration is rejected if it returns an error, the configuration in effect is kept.
+type ValidateFunc func() error
+
+type reloadHook struct {
+	keys   []string
+	reload ReloadFunc
+}
+
+// Reloader re-reads the configuration file on SIGHUP, or once it changed if watched, and calls the hooks
+// applying the changes, e.g. of the log level. The changes of the keys applied by no hook are logged as
+// requiring a restart. Initialize it with NewReloader.
+type Reloader struct {
+	// lock serializes the reloads.
+	lock        sync.Mutex
+	hooks       []reloadHook
+	signalHooks []SignalFunc
+	validators  []ValidateFunc
+	// settings are the settings in effect, as of the last reload, and config the content of the
+	// configuration file they were read from.
+	settings map[string]interface{}
+	config   []byte
+	// secretFiles are the digests of the contents in effect of the secret files, by the key of their path,
+	// e.g. mysql.password-file.
+	secretFiles map[string]string
+
+	signals chan os.Signal
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// NewReloader creates a Reloader of the configuration read by viper.
+func NewReloader() *Reloader {
+	// the content in effect is restored if a new configuration is rejected.
+	config, _ := os.ReadFile(viper.ConfigFileUsed())
+
+	return &Reloader{
+		settings:    allSettings(),
+		config:      config,
+		secretFiles: make(map[string]string),
+		signals:     make(chan os.Signal, 1),
+		stop:        make(chan struct{}),
+	}
+}
+
+// AddSecretFiles adds the keys of the paths of the files of secrets, e.g. mysql.password-file. Such a key
+// is changed by a reload once the content of its file changed, e.g. a rotated kubernetes secret mounted
+// as a file, and the directory of the file is watched by Watch too. Only the digests of the contents are
+// kept, the contents are never logged.
+func (r *Reloader) AddSecretFiles(keys ...string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, key := range keys {
+		r.secretFiles[key] = fileDigest(viper.GetString(key))
+	}
+}
+
+// AddReloadHook adds reload, called on each reload changing one of keys, e.g. log.level. A hook
+// without key is called on each reload, e.g. to re-read the certificate files.
+func (r *Reloader) AddReloadHook(reload ReloadFunc, keys ...string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.hooks = append(r.hooks, reloadHook{keys: keys, reload: reload})
+}
+
+// AddSignalHook adds hook, called on each SIGHUP whatever the configuration, even if it is invalid. It is
+// not called by the reloads of Watch.
+func (r *Reloader) AddSignalHook(hook SignalFunc) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.signalHooks = append(r.signalHooks, hook)
+}
+
+// AddValidator adds validate, called by each reload before the hooks. A new configuration failing one of
+// the validators is rejected wholesale, see ValidateOptions.
+func (r *Reloader) AddValidator(validate ValidateFunc) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.validators = append(r.validators, validate)
+}
+
+// ValidateOptions returns a ValidateFunc reading the configuration into the options returned by
+// newOptions, completing and validating them as on startup, e.g. with options.NewOptions of the server.
+func ValidateOptions(newOptions func() CliOptions) ValidateFunc {
+	return func() error {
+		opts := newOptions()
+		if err := viper.Unmarshal(opts); err != nil {
+			return err
+		}
+
+		if completeableOptions, ok := opts.(CompleteableOptions); ok {
+			if err := completeableOptions.Complete(); err != nil {
+				return err
+			}
+		}
+
+		return newInvalidOptionsError(opts.Validate())
+	}
+}
+
+// Start starts reloading the configuration on SIGHUP, until Stop is called. The process is no longer
+// terminated by SIGHUP.
+func (r *Reloader) Start() {
+	signal.Notify(r.signals, reloadSignal)
+
+	go func() {
+		for {
+			select {
+			case sig := <-r.signals:
+				r.handleSignal(sig)
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Watch starts reloading the configuration once its file, or one of the secret files added before, changed,
+// until Stop is called. The changes are debounced, the file is reloaded once it did not change for
+// watchDebounce. The directories of the files are watched, for the files replaced by a rename to be
+// reloaded too, e.g. the kubernetes ConfigMaps and secrets whose files are symbolic links swapped on change.
+func (r *Reloader) Watch() error {
+	file := viper.ConfigFileUsed()
+	if file == "" {
+		return errors.New("no configuration file to watch")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "create the watcher of the configuration file")
+	}
+
+	file = filepath.Clean(file)
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		_ = watcher.Close()
+
+		return errors.Wrap(err, "watch the configuration file")
+	}
+
+	files := []string{file}
+
+	for _, secretFile := range r.secretFilePaths() {
+		// the secret files out of reach are still read again on the reloads of the configuration file.
+		if err := watcher.Add(filepath.Dir(secretFile)); err != nil {
+			log.Warnf("Failed to watch the secret file %s: %s", secretFile, err.Error())
+
+			continue
+		}
+
+		files = append(files, secretFile)
+	}
+
+	go r.watch(watcher, file, files)
+
+	return nil
+}
+
+// secretFilePaths returns the paths of the secret files set, sorted.
+func (r *Reloader) secretFilePaths() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var paths []string
+
+	for key := range r.secretFiles {
+		if path := viper.GetString(key); path != "" {
+			paths = append(paths, filepath.Clean(path))
+		}
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+func (r *Reloader) watch(watcher *fsnotify.Watcher, file string, files []string) {
+	defer watcher.Close()
+
+	// targets are the files the watched files link to, their change is a change of the watched file.
+	targets := make(map[string]string, len(files))
+	for _, f := range files {
+		targets[f], _ = filepath.EvalSymlinks(f)
+	}
+
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+
+	defer debounce.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if !changedFile(filepath.Clean(event.Name), targets) {
+				continue
+			}
+
+			debounce.Reset(watchDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			log.Warnf("Failed to watch the configuration file %s: %s", file, err.Error())
+		case <-debounce.C:
+			log.Infof("The configuration file %s or its secret files changed, reload it", file)
+
+			if err := r.Reload(); err != nil {
+				log.Errorf("Failed to reload the configuration: %s", err.Error())
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Stop stops reloading the configuration on SIGHUP and on file change, the reload in progress completes.
+func (r *Reloader) Stop() {
+	r.once.Do(func() {
+		signal.Stop(r.signals)
+		close(r.stop)
+	})
+}
+
+func (r *Reloader) handleSignal(sig os.Signal) {
+	if sig != reloadSignal {
+		return
+	}
+
+	r.lock.Lock()
+	hooks := r.signalHooks
+	r.lock.Unlock()
+
+	for _, hook := range hooks {
+		if err := hook(); err != nil {
+			log.Errorf("Failed to handle %s: %s", sig, err.Error())
+		}
+	}
+
+	log.Infof("Received %s, reload the configuration file %s", sig, viper.ConfigFileUsed())
+
+	if err := r.Reload(); err != nil {
+		log.Errorf("Failed to reload the configuration: %s", err.Error())
+	}
+}
+
+// Reload re-reads the configuration file, logs the changes and calls the hooks of the keys changed.
+// The reloads are serialized, the errors of the hooks are aggregated. The configuration in effect is
+// kept if the file can not be read or parsed, or if it fails a validator.
+func (r *Reloader) Reload() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	config, err := os.ReadFile(viper.ConfigFileUsed())
+	if err != nil {
+		return errors.Wrap(err, "read the configuration file")
+	}
+
+	if err := viper.ReadConfig(bytes.NewReader(config)); err != nil {
+		r.restore()
+
+		return errors.Wrap(err, "read the configuration file")
+	}
+
+	var errs []error
+
+	for _, validate := range r.validators {
+		if err := validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		r.restore()
+
+		return errors.Wrap(errors.NewAggregate(errs), "the configuration is invalid, it is not applied")
+	}
+
+	previous, settings := r.settings, allSettings()
+	changed := r.changedSecretFiles(changedKeys(previous, settings))
+	r.settings, r.config = settings, config
+
+	for _, key := range changed {
+		log.Infow("Configuration changed", "key", key,
+			"old", redact(key, previous[key]), "new", redact(key, settings[key]))
+	}
+
+	applied := make(map[string]bool)
+
+	for _, hook := range r.hooks {
+		if !hook.changed(changed) {
+			continue
+		}
+
+		for _, key := range hook.keys {
+			applied[key] = true
+		}
+
+		if err := hook.reload(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	var restart []string
+
+	for _, key := range changed {
+		if !applied[key] {
+			restart = append(restart, key)
+		}
+	}
+
+	if len(restart) > 0 {
+		log.Warnf("The changes of %s require a restart to take effect", strings.Join(restart, ", "))
+	}
+
+	return errors.NewAggregate(errs)
+}
+
+// changedSecretFiles adds to changed the keys of the secret files whose content changed, whatever their
+// path, and keeps the digests of the new contents.
+func (r *Reloader) changedSecretFiles(changed []string) []string {
+	for key, digest := range r.secretFiles {
+		current := fileDigest(viper.GetString(key))
+		if current == digest {
+			continue
+		}
+
+		r.secretFiles[key] = current
+
+		if !containsKey(changed, key) {
+			changed = append(changed, key)
+		}
+	}
+
+	sort.Strings(changed)
+
+	return changed
+}
+
+// restore makes viper read the configuration in effect again, after a rejected reload.
+func (r *Reloader) restore() {
+	if err := viper.ReadConfig(bytes.NewReader(r.config)); err != nil {
+		log.Errorf("Failed to restore the configuration in effect: %s", err.Error())
+	}
+}
+
+func (h reloadHook) changed(changed []string) bool {
+	if len(h.keys) == 0 {
+		return true
+	}
+
+	for _, key := range h.keys {
+		for _, c := range changed {
+			if c == key {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// changedFile tells whether the event of name changed one of the files of targets, by their path or by the
+// file they link to, e.g. a kubernetes ConfigMap or secret updated. The targets are updated.
+func changedFile(name string, targets map[string]string) bool {
+	changed := false
+
+	for file, target := range targets {
+		current, _ := filepath.EvalSymlinks(file)
+		if name == file || current != target {
+			changed = true
+		}
+
+		targets[file] = current
+	}
+
+	return changed
+}
+
+// fileDigest returns the digest of the content of file, empty if it is not set or can not be read.
+func fileDigest(file string) string {
+	if file == "" {
+		return ""
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(content)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allSettings returns the value of each key of viper, from the flags, the environment, the
+// configuration file and the defaults by order of precedence.
+func allSettings() map[string]interface{} {
+	settings := make(map[string]interface{})
+	for _, key := range viper.AllKeys() {
+		settings[key] = viper.Get(key)
+	}
+
+	return settings
+}
+
+// changedKeys returns the keys added, removed or changed, sorted. The values are compared as
+// printed, the ones of the configuration file and of the flags being of different types.
+func changedKeys(old, current map[string]interface{}) []string {
+	var changed []string
+
+	for key, value := range current {
+		if previous, ok := old[key]; !ok || fmt.Sprint(previous) != fmt.Sprint(value) {
+			changed = append(changed, key)
+		}
+	}
+
+	for key := range old {
+		if _, ok := current[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+
+	sort.Strings(changed)
+
+	return changed
+}
+
+// redact hides the values of the keys which may be secrets, e.g. mysql.password. The paths of the files
+// of the secrets, e.g. mysql.password-file, are not.
+func redact(key string, value interface{}) interface{} {
+	name := key[strings.LastIndex(key, ".")+1:]
+	if strings.HasSuffix(name, "-file") {
+		return value
+	}
+
+	for _, sensitive := range []string{"password", "secret", "token"} {
+		if strings.Contains(name, sensitive) {
+			return redactedValue
+		}
+	}
+
+	if name == "key" {
+		return redactedValue
+	}
+
+	return value
+}
@@ -0,0 +1,395 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/marmotedu/errors"
+	"github.com/spf13/viper"
+)
+
+// writeConfig writes the configuration file read by viper.
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+}
+
+func TestReloader_handleSignal(t *testing.T) {
+	const initial = "log:\n  level: info\nmysql:\n  host: 127.0.0.1:3306\n"
+
+	tests := []struct {
+		name string
+		// configs are written before each SIGHUP.
+		configs []string
+		// wantLevels are the levels applied by the hook of log.level.
+		wantLevels []string
+		// wantReloads is the number of calls of the hook without key.
+		wantReloads int
+	}{
+		{
+			name:        "level changed",
+			configs:     []string{"log:\n  level: debug\nmysql:\n  host: 127.0.0.1:3306\n"},
+			wantLevels:  []string{"debug"},
+			wantReloads: 1,
+		},
+		{
+			name:        "restart required only",
+			configs:     []string{"log:\n  level: info\nmysql:\n  host: 10.0.0.1:3306\n"},
+			wantReloads: 1,
+		},
+		{
+			name: "repeated",
+			configs: []string{
+				"log:\n  level: debug\n",
+				"log:\n  level: debug\n",
+				"log:\n  level: warn\n",
+			},
+			wantLevels:  []string{"debug", "warn"},
+			wantReloads: 3,
+		},
+		{
+			name:    "invalid file kept",
+			configs: []string{"log: [\n", "log:\n  level: info\nmysql:\n  host: 127.0.0.1:3306\n"},
+			// the configuration in effect is unchanged, whatever the invalid file.
+			wantReloads: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "iam-apiserver.yaml")
+			writeConfig(t, path, initial)
+
+			viper.SetConfigFile(path)
+			defer viper.Reset()
+
+			if err := viper.ReadInConfig(); err != nil {
+				t.Fatalf("ReadInConfig() error = %v", err)
+			}
+
+			r := NewReloader()
+
+			var levels []string
+			r.AddReloadHook(func() error {
+				levels = append(levels, viper.GetString("log.level"))
+
+				return nil
+			}, "log.level")
+
+			var reloads int
+			r.AddReloadHook(func() error {
+				reloads++
+
+				return nil
+			})
+
+			var signals int
+			r.AddSignalHook(func() error {
+				signals++
+
+				return nil
+			})
+
+			for _, config := range tt.configs {
+				writeConfig(t, path, config)
+				r.handleSignal(syscall.SIGHUP)
+			}
+
+			// the other signals are not handled.
+			r.handleSignal(syscall.SIGINT)
+
+			if !reflect.DeepEqual(levels, tt.wantLevels) {
+				t.Errorf("applied levels %v, want %v", levels, tt.wantLevels)
+			}
+
+			if reloads != tt.wantReloads {
+				t.Errorf("reloads = %d, want %d", reloads, tt.wantReloads)
+			}
+
+			// the signal hooks are called on each SIGHUP, whatever the configuration.
+			if signals != len(tt.configs) {
+				t.Errorf("signals = %d, want %d", signals, len(tt.configs))
+			}
+		})
+	}
+}
+
+func TestReloader_ReloadSerialized(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iam-apiserver.yaml")
+	writeConfig(t, path, "log:\n  level: info\n")
+
+	viper.SetConfigFile(path)
+	defer viper.Reset()
+
+	r := NewReloader()
+
+	var running, overlaps int32
+	r.AddReloadHook(func() error {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		defer atomic.AddInt32(&running, -1)
+
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			r.handleSignal(syscall.SIGHUP)
+		}()
+	}
+
+	wg.Wait()
+
+	if overlaps != 0 {
+		t.Errorf("%d reloads overlapped, want them serialized", overlaps)
+	}
+}
+
+func TestReloader_ReloadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iam-apiserver.yaml")
+	writeConfig(t, path, "log:\n  level: info\n  format: console\n")
+
+	viper.SetConfigFile(path)
+	defer viper.Reset()
+
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatalf("ReadInConfig() error = %v", err)
+	}
+
+	r := NewReloader()
+	r.AddValidator(func() error {
+		if level := viper.GetString("log.level"); level == "invalid" {
+			return errors.Errorf("invalid log level %s", level)
+		}
+
+		return nil
+	})
+
+	var levels []string
+	r.AddReloadHook(func() error {
+		levels = append(levels, viper.GetString("log.level"))
+
+		return nil
+	}, "log.level", "log.format")
+
+	tests := []struct {
+		name       string
+		config     string
+		wantErr    bool
+		wantLevel  string
+		wantFormat string
+	}{
+		{name: "invalid", config: "log:\n  level: invalid\n  format: json\n", wantErr: true, wantLevel: "info", wantFormat: "console"},
+		{name: "unparsable", config: "log: [\n", wantErr: true, wantLevel: "info", wantFormat: "console"},
+		{name: "valid", config: "log:\n  level: debug\n  format: json\n", wantLevel: "debug", wantFormat: "json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeConfig(t, path, tt.config)
+
+			if err := r.Reload(); (err != nil) != tt.wantErr {
+				t.Fatalf("Reload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// the invalid configurations are rejected wholesale, none of their values is in effect.
+			if got := viper.GetString("log.level"); got != tt.wantLevel {
+				t.Errorf("log.level = %s, want %s", got, tt.wantLevel)
+			}
+
+			if got := viper.GetString("log.format"); got != tt.wantFormat {
+				t.Errorf("log.format = %s, want %s", got, tt.wantFormat)
+			}
+		})
+	}
+
+	if want := []string{"debug"}; !reflect.DeepEqual(levels, want) {
+		t.Errorf("applied levels %v, want %v", levels, want)
+	}
+}
+
+func TestReloader_Watch(t *testing.T) {
+	defer func(debounce time.Duration) { watchDebounce = debounce }(watchDebounce)
+	watchDebounce = 50 * time.Millisecond
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "iam-apiserver.yaml")
+	writeConfig(t, path, "log:\n  level: info\n")
+
+	viper.SetConfigFile(path)
+	defer viper.Reset()
+
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatalf("ReadInConfig() error = %v", err)
+	}
+
+	r := NewReloader()
+	defer r.Stop()
+
+	levels := make(chan string, 10)
+	r.AddReloadHook(func() error {
+		levels <- viper.GetString("log.level")
+
+		return nil
+	}, "log.level")
+
+	// the signal hooks are not called by the reloads of the watched file.
+	r.AddSignalHook(func() error {
+		t.Error("signal hook called on the change of the configuration file")
+
+		return nil
+	})
+
+	if err := r.Watch(); err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	// the writes in a row are debounced, the last one is applied.
+	writeConfig(t, path, "log:\n  level: debug\n")
+	writeConfig(t, path, "log:\n  level: warn\n")
+	writeConfig(t, path, "log:\n  level: error\n")
+	wantLevel(t, levels, "error")
+
+	// the files replaced by a rename, like by the editors, are reloaded too.
+	renamed := filepath.Join(dir, "iam-apiserver.yaml.tmp")
+	writeConfig(t, renamed, "log:\n  level: debug\n")
+
+	if err := os.Rename(renamed, path); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+
+	wantLevel(t, levels, "debug")
+
+	// the other files of the directory are ignored.
+	writeConfig(t, filepath.Join(dir, "other.yaml"), "log:\n  level: warn\n")
+
+	select {
+	case level := <-levels:
+		t.Errorf("applied level %s on the change of another file", level)
+	case <-time.After(10 * watchDebounce):
+	}
+}
+
+// wantLevel waits for the next level applied, which must be want, and for no other to follow it.
+func wantLevel(t *testing.T, levels chan string, want string) {
+	t.Helper()
+
+	select {
+	case level := <-levels:
+		if level != want {
+			t.Errorf("applied level %s, want %s", level, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the level %s was not applied", want)
+	}
+
+	select {
+	case level := <-levels:
+		t.Errorf("applied level %s after %s, want the changes debounced", level, want)
+	case <-time.After(5 * watchDebounce):
+	}
+}
+
+func TestReloader_SecretFiles(t *testing.T) {
+	defer func(debounce time.Duration) { watchDebounce = debounce }(watchDebounce)
+	watchDebounce = 50 * time.Millisecond
+
+	// the secrets are mounted out of the directory of the configuration file.
+	secret := filepath.Join(t.TempDir(), "mysql-password")
+	writeConfig(t, secret, "password1\n")
+
+	path := filepath.Join(t.TempDir(), "iam-apiserver.yaml")
+	writeConfig(t, path, "mysql:\n  password-file: "+secret+"\n")
+
+	viper.SetConfigFile(path)
+	defer viper.Reset()
+
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatalf("ReadInConfig() error = %v", err)
+	}
+
+	r := NewReloader()
+	defer r.Stop()
+
+	r.AddSecretFiles("mysql.password-file")
+
+	passwords := make(chan string, 10)
+	r.AddReloadHook(func() error {
+		content, err := os.ReadFile(viper.GetString("mysql.password-file"))
+		if err != nil {
+			return err
+		}
+
+		passwords <- strings.TrimSpace(string(content))
+
+		return nil
+	}, "mysql.password-file")
+
+	// the secret files are not changed by the reloads of the same content.
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	select {
+	case password := <-passwords:
+		t.Fatalf("applied password %s without rotation", password)
+	default:
+	}
+
+	if err := r.Watch(); err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	// the rotated secret is reloaded once its file changed, its path being the same.
+	writeConfig(t, secret, "password2\n")
+
+	select {
+	case password := <-passwords:
+		if password != "password2" {
+			t.Errorf("applied password %s, want password2", password)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the rotated password was not applied")
+	}
+}
+
+func Test_changedKeys(t *testing.T) {
+	old := map[string]interface{}{"log.level": "info", "mysql.host": "127.0.0.1", "redis.port": 6379}
+	current := map[string]interface{}{"log.level": "debug", "redis.port": "6379", "jwt.realm": "iam"}
+
+	want := []string{"jwt.realm", "log.level", "mysql.host"}
+	if got := changedKeys(old, current); !reflect.DeepEqual(got, want) {
+		t.Errorf("changedKeys() = %v, want %v", got, want)
+	}
+}
+
+func Test_redact(t *testing.T) {
+	for key, want := range map[string]interface{}{
+		"mysql.password":      redactedValue,
+		"jwt.key":             redactedValue,
+		"service-token.token": redactedValue,
+		"mysql.password-file": "value",
+		"log.level":           "value",
+	} {
+		if got := redact(key, "value"); got != want {
+			t.Errorf("redact(%s) = %v, want %v", key, got, want)
+		}
+	}
+}
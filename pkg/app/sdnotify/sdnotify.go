@@ -0,0 +1,114 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package sdnotify notifies systemd of the state of the application, see sd_notify(3).
+// All the functions are no-op when the application is not started by systemd with
+// Type=notify, e.g. in a container.
+package sdnotify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// Ready tells systemd the application finished its startup.
+	Ready = "READY=1"
+
+	// Stopping tells systemd the application started its shutdown.
+	Stopping = "STOPPING=1"
+
+	// Watchdog keeps the application alive when WatchdogSec is set in the unit.
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notify sends the state to the socket in $NOTIFY_SOCKET. It returns false, with no
+// error, when $NOTIFY_SOCKET is not set.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+
+	// a leading @ refers to an abstract socket.
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// WatchdogInterval returns the interval of the systemd watchdog, 0 when the watchdog is
+// disabled or not meant for this process.
+func WatchdogInterval() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q", usec)
+	}
+
+	return time.Duration(n) * time.Microsecond, nil
+}
+
+// RunWatchdog keeps the application alive by notifying systemd at half the watchdog
+// interval, as long as check succeeds, until ctx is done. check is given half the
+// interval to complete. It returns at once when the watchdog is disabled.
+func RunWatchdog(ctx context.Context, check func(ctx context.Context) error, onFailure func(err error)) error {
+	interval, err := WatchdogInterval()
+	if err != nil || interval == 0 {
+		return err
+	}
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, interval/2)
+		err := check(checkCtx)
+		cancel()
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// systemd kills the application once no notification is received within the interval.
+		if err != nil {
+			onFailure(err)
+
+			continue
+		}
+
+		if _, err := Notify(Watchdog); err != nil {
+			onFailure(err)
+		}
+	}
+}
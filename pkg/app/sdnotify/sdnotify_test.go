@@ -0,0 +1,179 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package sdnotify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// listen listens on a notify socket, and returns the states it receives.
+func listen(t *testing.T) <-chan string {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("ListenUnixgram() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	states := make(chan string, 10)
+
+	go func() {
+		buf := make([]byte, 1024)
+
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+
+			states <- string(buf[:n])
+		}
+	}()
+
+	return states
+}
+
+func TestNotify(t *testing.T) {
+	states := listen(t)
+
+	ok, err := Notify(Ready)
+	if !ok || err != nil {
+		t.Fatalf("Notify() = %v, %v, want true, nil", ok, err)
+	}
+
+	select {
+	case state := <-states:
+		if state != Ready {
+			t.Errorf("received %q, want %q", state, Ready)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for the notification.")
+	}
+}
+
+func TestNotifyWithoutSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	ok, err := Notify(Ready)
+	if ok || err != nil {
+		t.Errorf("Notify() = %v, %v, want false, nil", ok, err)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	tests := []struct {
+		name    string
+		usec    string
+		pid     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{
+			name: "disabled",
+			want: 0,
+		},
+		{
+			name: "enabled",
+			usec: "30000000",
+			want: 30 * time.Second,
+		},
+		{
+			name: "this process",
+			usec: "30000000",
+			pid:  strconv.Itoa(os.Getpid()),
+			want: 30 * time.Second,
+		},
+		{
+			name: "another process",
+			usec: "30000000",
+			pid:  strconv.Itoa(os.Getpid() + 1),
+			want: 0,
+		},
+		{
+			name:    "invalid",
+			usec:    "30s",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WATCHDOG_USEC", tt.usec)
+			t.Setenv("WATCHDOG_PID", tt.pid)
+
+			got, err := WatchdogInterval()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WatchdogInterval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("WatchdogInterval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunWatchdog(t *testing.T) {
+	states := listen(t)
+	t.Setenv("WATCHDOG_USEC", "20000")
+	t.Setenv("WATCHDOG_PID", "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	checks, failures := 0, make(chan error, 10)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- RunWatchdog(ctx, func(ctx context.Context) error {
+			checks++
+			// the first check fails, the watchdog must not be notified.
+			if checks == 1 {
+				return errors.New("router not responding")
+			}
+
+			return nil
+		}, func(err error) {
+			failures <- err
+		})
+	}()
+
+	select {
+	case <-failures:
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for the failed check.")
+	}
+
+	select {
+	case state := <-states:
+		if state != Watchdog {
+			t.Errorf("received %q, want %q", state, Watchdog)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for the watchdog notification.")
+	}
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("RunWatchdog() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for RunWatchdog to return.")
+	}
+}
@@ -82,6 +82,8 @@ type ConfigFlags struct {
 // Returns a REST client configuration based on a provided path
 // to a .iamconfig file, loading rules, and config flag overrides.
 // Expects the AddFlags method to have been called.
+// A unix:///path server address is returned as-is, the clients connect to the unix socket of the
+// server with ResolveUnixSocketHost and DialUnixSocket.
 func (f *ConfigFlags) ToRESTConfig() (*rest.Config, error) {
 	return f.ToRawIAMConfigLoader().ClientConfig()
 }
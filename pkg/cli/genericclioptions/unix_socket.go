@@ -0,0 +1,62 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package genericclioptions
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/marmotedu/marmotedu-sdk-go/rest"
+)
+
+const (
+	unixSocketScheme = "unix://"
+	// unixSocketHost is the host of the requests sent to a unix socket, it only fills their Host header.
+	unixSocketHost = "http://localhost"
+)
+
+// ResolveUnixSocketHost returns the host to configure the REST clients with and the path of the unix
+// socket they must dial, see DialUnixSocket, for a unix:///path host, e.g. the socket of iam-apiserver
+// --insecure.bind-socket. The other hosts are returned as-is with an empty path.
+func ResolveUnixSocketHost(host string) (string, string, error) {
+	if !strings.HasPrefix(host, unixSocketScheme) {
+		return host, "", nil
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return "", "", err
+	}
+
+	if u.Path == "" {
+		return "", "", fmt.Errorf("%s has no socket path, like unix:///var/run/iam.sock", host)
+	}
+
+	return unixSocketHost, u.Path, nil
+}
+
+// DialUnixSocket makes the REST clients connect to the unix socket path, whatever the host of their
+// requests. The clients are left as-is if path is empty.
+func DialUnixSocket(path string, clients ...rest.Interface) {
+	if path == "" {
+		return
+	}
+
+	dial := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var dialer net.Dialer
+
+		return dialer.DialContext(ctx, "unix", path)
+	}
+
+	for _, client := range clients {
+		// the transport is shared by the requests of the client.
+		if c, ok := client.(*rest.RESTClient); ok && c != nil && c.Client != nil {
+			c.Client.Transport.DialContext = dial
+		}
+	}
+}
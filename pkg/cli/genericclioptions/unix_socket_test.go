@@ -0,0 +1,88 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package genericclioptions
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/marmotedu/component-base/pkg/runtime"
+	"github.com/marmotedu/component-base/pkg/scheme"
+	"github.com/marmotedu/marmotedu-sdk-go/rest"
+)
+
+func TestResolveUnixSocketHost(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     string
+		wantHost string
+		wantPath string
+		wantErr  bool
+	}{
+		{name: "tcp", host: "http://127.0.0.1:8080", wantHost: "http://127.0.0.1:8080"},
+		{name: "no scheme", host: "127.0.0.1:8080", wantHost: "127.0.0.1:8080"},
+		{name: "no socket path", host: "unix://", wantErr: true},
+		{name: "unix socket", host: "unix:///var/run/iam.sock", wantHost: unixSocketHost, wantPath: "/var/run/iam.sock"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, path, err := ResolveUnixSocketHost(tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveUnixSocketHost() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if host != tt.wantHost || path != tt.wantPath {
+				t.Errorf("ResolveUnixSocketHost() = %v, %v, want %v, %v", host, path, tt.wantHost, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestDialUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iam.sock")
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		_ = http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Method + " " + r.URL.Path))
+		}))
+	}()
+
+	host, socket, err := ResolveUnixSocketHost("unix://" + path)
+	if err != nil {
+		t.Fatalf("ResolveUnixSocketHost() error = %v", err)
+	}
+
+	config := &rest.Config{Host: host}
+	config.GroupVersion = &scheme.GroupVersion{Group: "iam.api", Version: "v1"}
+	config.Negotiator = runtime.NewSimpleClientNegotiator()
+
+	client, err := rest.RESTClientFor(config)
+	if err != nil {
+		t.Fatalf("RESTClientFor() error = %v", err)
+	}
+
+	DialUnixSocket(socket, client)
+
+	// all the requests of the client go to the socket.
+	for i := 0; i < 2; i++ {
+		body, err := client.Get().AbsPath("/v1/users").Do(context.TODO()).Raw()
+		if err != nil {
+			t.Fatalf("GET error = %v", err)
+		}
+
+		if string(body) != "GET /v1/users" {
+			t.Errorf("GET body = %q, want %q", body, "GET /v1/users")
+		}
+	}
+}
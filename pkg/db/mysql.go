@@ -5,9 +5,13 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"time"
 
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -22,40 +26,147 @@ type Options struct {
 	MaxIdleConnections    int
 	MaxOpenConnections    int
 	MaxConnectionLifeTime time.Duration
-	LogLevel              int
-	Logger                logger.Interface
+	MaxConnectionIdleTime time.Duration
+	// PasswordFunc returns the password of each new connection instead of Password if set, e.g. for the
+	// password rotated while the process runs, the open connections are kept until they are closed.
+	PasswordFunc func() string
+	// MultiStatements allows the statements executed at once, separated by semicolons, e.g. for the
+	// migrations of the schema.
+	MultiStatements bool
+	LogLevel        int
+	Logger          logger.Interface
 }
 
 // New create a new gorm db instance with the given options.
 func New(opts *Options) (*gorm.DB, error) {
-	dsn := fmt.Sprintf(`%s:%s@tcp(%s)/%s?charset=utf8&parseTime=%t&loc=%s`,
+	dsn := fmt.Sprintf(`%s:%s@tcp(%s)/%s?charset=utf8&parseTime=%t&loc=%s&multiStatements=%t`,
 		opts.Username,
 		opts.Password,
 		opts.Host,
 		opts.Database,
 		true,
-		"Local")
+		"Local",
+		opts.MultiStatements)
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+	dialector, err := mysqlDialector(dsn, opts.PasswordFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger: opts.Logger,
 	})
 	if err != nil {
+		// the connection pool is opened even if mysql cannot be reached, it is closed for the failed
+		// connections not to leak, e.g. when they are tried again until mysql is back.
+		if db != nil {
+			if sqlDB, dbErr := db.DB(); dbErr == nil {
+				_ = sqlDB.Close()
+			}
+		}
+
 		return nil, err
 	}
 
-	sqlDB, err := db.DB()
+	if err := setConnectionPool(db, opts.MaxIdleConnections, opts.MaxOpenConnections,
+		opts.MaxConnectionLifeTime, opts.MaxConnectionIdleTime); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// mysqlDialector returns the dialector of the mysql database of dsn, connecting with the password returned
+// by password if set.
+func mysqlDialector(dsn string, password func() string) (gorm.Dialector, error) {
+	if password == nil {
+		return mysql.Open(dsn), nil
+	}
+
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return mysql.New(mysql.Config{
+		Conn: sql.OpenDB(&passwordConnector{cfg: cfg, password: password}),
+	}), nil
+}
+
+// passwordConnector opens the connections to mysql with the password in effect, the connections opened
+// after a rotation of the password use the new one.
+type passwordConnector struct {
+	cfg      *mysqldriver.Config
+	password func() string
+}
+
+// Connect opens a connection with the current password.
+func (c *passwordConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	cfg := c.cfg.Clone()
+	cfg.Passwd = c.password()
+
+	connector, err := mysqldriver.NewConnector(cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	return connector.Connect(ctx)
+}
+
+// Driver returns the mysql driver.
+func (c *passwordConnector) Driver() driver.Driver {
+	return &mysqldriver.MySQLDriver{}
+}
+
+// NewReplica opens the connection pool of a read replica of the mysql database of dsn, with the pool
+// options of opts. The times are parsed like the ones of New. The replica is only connected once read,
+// so that an unavailable replica does not fail the start of the server.
+func NewReplica(dsn string, opts *Options) (*sql.DB, error) {
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ParseTime = true
+	cfg.Loc = time.Local
+
+	connector, err := mysqldriver.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB := sql.OpenDB(connector)
+	configureConnectionPool(sqlDB, opts.MaxIdleConnections, opts.MaxOpenConnections,
+		opts.MaxConnectionLifeTime, opts.MaxConnectionIdleTime)
+
+	return sqlDB, nil
+}
+
+func setConnectionPool(db *gorm.DB, maxIdleConnections, maxOpenConnections int,
+	maxLifeTime, maxIdleTime time.Duration,
+) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	configureConnectionPool(sqlDB, maxIdleConnections, maxOpenConnections, maxLifeTime, maxIdleTime)
+
+	return nil
+}
+
+func configureConnectionPool(sqlDB *sql.DB, maxIdleConnections, maxOpenConnections int,
+	maxLifeTime, maxIdleTime time.Duration,
+) {
 	// SetMaxOpenConns sets the maximum number of open connections to the database.
-	sqlDB.SetMaxOpenConns(opts.MaxOpenConnections)
+	sqlDB.SetMaxOpenConns(maxOpenConnections)
 
 	// SetConnMaxLifetime sets the maximum amount of time a connection may be reused.
-	sqlDB.SetConnMaxLifetime(opts.MaxConnectionLifeTime)
+	sqlDB.SetConnMaxLifetime(maxLifeTime)
 
-	// SetMaxIdleConns sets the maximum number of connections in the idle connection pool.
-	sqlDB.SetMaxIdleConns(opts.MaxIdleConnections)
+	// SetConnMaxIdleTime sets the maximum amount of time a connection may be idle, zero keeps them.
+	sqlDB.SetConnMaxIdleTime(maxIdleTime)
 
-	return db, nil
+	// SetMaxIdleConns sets the maximum number of connections in the idle connection pool.
+	sqlDB.SetMaxIdleConns(maxIdleConnections)
 }
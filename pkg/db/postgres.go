@@ -0,0 +1,55 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"net/url"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// PostgresOptions defines options for postgres database.
+type PostgresOptions struct {
+	Host     string
+	Username string
+	Password string
+	Database string
+	// SSLMode is the sslmode of the connections, e.g. disable or verify-full.
+	SSLMode               string
+	MaxIdleConnections    int
+	MaxOpenConnections    int
+	MaxConnectionLifeTime time.Duration
+	LogLevel              int
+	Logger                logger.Interface
+}
+
+// NewPostgres create a new gorm db instance of postgres with the given options.
+func NewPostgres(opts *PostgresOptions) (*gorm.DB, error) {
+	// the url escapes the credentials, unlike the keyword/value dsn.
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(opts.Username, opts.Password),
+		Host:     opts.Host,
+		Path:     "/" + opts.Database,
+		RawQuery: url.Values{"sslmode": []string{opts.SSLMode}}.Encode(),
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn.String()), &gorm.Config{
+		Logger: opts.Logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setConnectionPool(db, opts.MaxIdleConnections, opts.MaxOpenConnections,
+		opts.MaxConnectionLifeTime, 0); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
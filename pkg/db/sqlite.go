@@ -0,0 +1,42 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// SqliteMemory is the path of a sqlite database in memory, lost once closed.
+const SqliteMemory = ":memory:"
+
+// SqliteOptions defines options for sqlite database.
+type SqliteOptions struct {
+	// Path is the database file, created if it does not exist, or SqliteMemory.
+	Path     string
+	LogLevel int
+	Logger   logger.Interface
+}
+
+// NewSqlite create a new gorm db instance of sqlite with the given options. The sqlite driver requires
+// cgo, the binaries built without it fail to open the database.
+func NewSqlite(opts *SqliteOptions) (*gorm.DB, error) {
+	// the foreign keys are not checked by default, unlike mysql.
+	db, err := gorm.Open(sqlite.Open(opts.Path+"?_foreign_keys=1"), &gorm.Config{
+		Logger: opts.Logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// each connection to SqliteMemory opens a new database, the single connection is kept forever. sqlite
+	// serializes the writes anyway.
+	if err := setConnectionPool(db, 1, 1, 0, 0); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
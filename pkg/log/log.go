@@ -146,6 +146,8 @@ type zapLogger struct {
 	// deals with our desire to have multiple verbosity levels.
 	zapLogger *zap.Logger
 	infoLogger
+	// atomicLevel is the level of the loggers created by New, it can be changed while logging.
+	atomicLevel zap.AtomicLevel
 }
 
 // handleFields converts a bunch of arbitrary key-value pairs into Zap fields.  It takes
@@ -241,32 +243,35 @@ func New(opts *Options) *zapLogger {
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
 
-	loggerConfig := &zap.Config{
-		Level:             zap.NewAtomicLevelAt(zapLevel),
-		Development:       opts.Development,
-		DisableCaller:     opts.DisableCaller,
-		DisableStacktrace: opts.DisableStacktrace,
-		Sampling: &zap.SamplingConfig{
-			Initial:    100,
-			Thereafter: 100,
-		},
-		Encoding:         opts.Format,
-		EncoderConfig:    encoderConfig,
-		OutputPaths:      opts.OutputPaths,
-		ErrorOutputPaths: opts.ErrorOutputPaths,
+	var encoder zapcore.Encoder
+	switch opts.Format {
+	case consoleFormat:
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	case jsonFormat:
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	default:
+		panic(fmt.Errorf("no encoder registered for name %q", opts.Format))
 	}
 
-	var err error
-	l, err := loggerConfig.Build(zap.AddStacktrace(zapcore.PanicLevel), zap.AddCallerSkip(1))
+	// the cores are built from the sinks rather than by zap.Config, for the log files to be rotated.
+	sink, err := openSinks(opts.OutputPaths, opts)
+	if err != nil {
+		panic(err)
+	}
+	errSink, err := openSinks(opts.ErrorOutputPaths, opts)
 	if err != nil {
 		panic(err)
 	}
+
+	atomicLevel := zap.NewAtomicLevelAt(zapLevel)
+	l := zap.New(zapcore.NewCore(encoder, sink, atomicLevel), buildOptions(opts, errSink)...)
 	logger := &zapLogger{
 		zapLogger: l.Named(opts.Name),
 		infoLogger: infoLogger{
 			log:   l,
 			level: zap.InfoLevel,
 		},
+		atomicLevel: atomicLevel,
 	}
 	klog.InitLogger(l)
 	zap.RedirectStdLog(l)
@@ -274,6 +279,48 @@ func New(opts *Options) *zapLogger {
 	return logger
 }
 
+// buildOptions returns the options of the loggers created by New, as built by zap.Config.
+func buildOptions(opts *Options, errSink zapcore.WriteSyncer) []zap.Option {
+	options := []zap.Option{zap.ErrorOutput(errSink)}
+	if opts.Development {
+		options = append(options, zap.Development())
+	}
+	if !opts.DisableCaller {
+		options = append(options, zap.AddCaller())
+	}
+	stackLevel := zap.ErrorLevel
+	if opts.Development {
+		stackLevel = zap.WarnLevel
+	}
+	if !opts.DisableStacktrace {
+		options = append(options, zap.AddStacktrace(stackLevel))
+	}
+
+	if !opts.DisableSampling {
+		options = append(options, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return newSampler(core, opts)
+		}))
+	}
+
+	return append(options, zap.AddStacktrace(zapcore.PanicLevel), zap.AddCallerSkip(1))
+}
+
+// SetLevel changes the level of the global logger while logging, e.g. on a configuration reload.
+// The level is one of the values accepted by Options.Level, the level is kept if it is invalid.
+func SetLevel(level string) error {
+	var zapLevel zapcore.Level
+	if err := zapLevel.UnmarshalText([]byte(level)); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	std.atomicLevel.SetLevel(zapLevel)
+
+	return nil
+}
+
 // SugaredLogger returns global sugared logger.
 func SugaredLogger() *zap.SugaredLogger {
 	return std.zapLogger.Sugar()
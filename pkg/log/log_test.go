@@ -60,3 +60,19 @@ func Test_Option(t *testing.T) {
 
 	assert.Equal(t, "debug", opt.Level)
 }
+
+func Test_SetLevel(t *testing.T) {
+	log.Init(log.NewOptions())
+	defer log.Init(log.NewOptions())
+
+	assert.False(t, log.V(log.DebugLevel).Enabled())
+
+	assert.Nil(t, log.SetLevel("debug"))
+	assert.True(t, log.V(log.DebugLevel).Enabled())
+
+	assert.NotNil(t, log.SetLevel("verbose"))
+	assert.True(t, log.V(log.DebugLevel).Enabled(), "the level is kept if invalid")
+
+	assert.Nil(t, log.SetLevel("warn"))
+	assert.False(t, log.V(log.InfoLevel).Enabled())
+}
@@ -20,7 +20,9 @@ package log
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/marmotedu/component-base/pkg/json"
 	"github.com/spf13/pflag"
@@ -29,15 +31,24 @@ import (
 )
 
 const (
-	flagLevel             = "log.level"
-	flagDisableCaller     = "log.disable-caller"
-	flagDisableStacktrace = "log.disable-stacktrace"
-	flagFormat            = "log.format"
-	flagEnableColor       = "log.enable-color"
-	flagOutputPaths       = "log.output-paths"
-	flagErrorOutputPaths  = "log.error-output-paths"
-	flagDevelopment       = "log.development"
-	flagName              = "log.name"
+	flagLevel              = "log.level"
+	flagDisableCaller      = "log.disable-caller"
+	flagDisableStacktrace  = "log.disable-stacktrace"
+	flagFormat             = "log.format"
+	flagEnableColor        = "log.enable-color"
+	flagOutputPaths        = "log.output-paths"
+	flagErrorOutputPaths   = "log.error-output-paths"
+	flagDevelopment        = "log.development"
+	flagName               = "log.name"
+	flagMaxSize            = "log.max-size"
+	flagMaxBackups         = "log.max-backups"
+	flagMaxAge             = "log.max-age"
+	flagCompress           = "log.compress"
+	flagDisableSampling    = "log.disable-sampling"
+	flagSamplingInitial    = "log.sampling-initial"
+	flagSamplingThereafter = "log.sampling-thereafter"
+	flagSamplingTick       = "log.sampling-tick"
+	flagSamplingLevels     = "log.sampling-levels"
 
 	consoleFormat = "console"
 	jsonFormat    = "json"
@@ -45,28 +56,45 @@ const (
 
 // Options contains configuration items related to log.
 type Options struct {
-	OutputPaths       []string `json:"output-paths"       mapstructure:"output-paths"`
-	ErrorOutputPaths  []string `json:"error-output-paths" mapstructure:"error-output-paths"`
-	Level             string   `json:"level"              mapstructure:"level"`
-	Format            string   `json:"format"             mapstructure:"format"`
-	DisableCaller     bool     `json:"disable-caller"     mapstructure:"disable-caller"`
-	DisableStacktrace bool     `json:"disable-stacktrace" mapstructure:"disable-stacktrace"`
-	EnableColor       bool     `json:"enable-color"       mapstructure:"enable-color"`
-	Development       bool     `json:"development"        mapstructure:"development"`
-	Name              string   `json:"name"               mapstructure:"name"`
+	OutputPaths        []string      `json:"output-paths"        mapstructure:"output-paths"`
+	ErrorOutputPaths   []string      `json:"error-output-paths"  mapstructure:"error-output-paths"`
+	Level              string        `json:"level"               mapstructure:"level"`
+	Format             string        `json:"format"              mapstructure:"format"`
+	DisableCaller      bool          `json:"disable-caller"      mapstructure:"disable-caller"`
+	DisableStacktrace  bool          `json:"disable-stacktrace"  mapstructure:"disable-stacktrace"`
+	EnableColor        bool          `json:"enable-color"        mapstructure:"enable-color"`
+	Development        bool          `json:"development"         mapstructure:"development"`
+	Name               string        `json:"name"                mapstructure:"name"`
+	MaxSize            int           `json:"max-size"            mapstructure:"max-size"`
+	MaxBackups         int           `json:"max-backups"         mapstructure:"max-backups"`
+	MaxAge             int           `json:"max-age"             mapstructure:"max-age"`
+	Compress           bool          `json:"compress"            mapstructure:"compress"`
+	DisableSampling    bool          `json:"disable-sampling"    mapstructure:"disable-sampling"`
+	SamplingInitial    int           `json:"sampling-initial"    mapstructure:"sampling-initial"`
+	SamplingThereafter int           `json:"sampling-thereafter" mapstructure:"sampling-thereafter"`
+	SamplingTick       time.Duration `json:"sampling-tick"       mapstructure:"sampling-tick"`
+	SamplingLevels     []string      `json:"sampling-levels"     mapstructure:"sampling-levels"`
 }
 
 // NewOptions creates an Options object with default parameters.
 func NewOptions() *Options {
 	return &Options{
-		Level:             zapcore.InfoLevel.String(),
-		DisableCaller:     false,
-		DisableStacktrace: false,
-		Format:            consoleFormat,
-		EnableColor:       false,
-		Development:       false,
-		OutputPaths:       []string{"stdout"},
-		ErrorOutputPaths:  []string{"stderr"},
+		Level:              zapcore.InfoLevel.String(),
+		DisableCaller:      false,
+		DisableStacktrace:  false,
+		Format:             consoleFormat,
+		EnableColor:        false,
+		Development:        false,
+		OutputPaths:        []string{"stdout"},
+		ErrorOutputPaths:   []string{"stderr"},
+		MaxSize:            100,
+		MaxBackups:         10,
+		MaxAge:             30,
+		Compress:           false,
+		DisableSampling:    false,
+		SamplingInitial:    100,
+		SamplingThereafter: 100,
+		SamplingTick:       time.Second,
 	}
 }
 
@@ -84,6 +112,29 @@ func (o *Options) Validate() []error {
 		errs = append(errs, fmt.Errorf("not a valid log format: %q", o.Format))
 	}
 
+	for _, option := range []struct {
+		flag  string
+		value int
+	}{
+		{flagMaxSize, o.MaxSize},
+		{flagMaxBackups, o.MaxBackups},
+		{flagMaxAge, o.MaxAge},
+		{flagSamplingInitial, o.SamplingInitial},
+		{flagSamplingThereafter, o.SamplingThereafter},
+	} {
+		if option.value < 0 {
+			errs = append(errs, fmt.Errorf("--%s %d must not be negative", option.flag, option.value))
+		}
+	}
+
+	if o.SamplingTick < 0 {
+		errs = append(errs, fmt.Errorf("--%s %v must not be negative", flagSamplingTick, o.SamplingTick))
+	}
+
+	_, samplingErrs := o.samplingLevels()
+
+	errs = append(errs, samplingErrs...)
+
 	return errs
 }
 
@@ -105,6 +156,68 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) {
 			"the behavior of DPanicLevel and takes stacktraces more liberally.",
 	)
 	fs.StringVar(&o.Name, flagName, o.Name, "The name of the logger.")
+	fs.IntVar(&o.MaxSize, flagMaxSize, o.MaxSize, "Maximum size in megabytes of the log files before they are "+
+		"rotated, 0 disables the rotation. The stdout and stderr outputs are never rotated.")
+	fs.IntVar(&o.MaxBackups, flagMaxBackups, o.MaxBackups, "Maximum number of rotated log files to retain, "+
+		"0 retains them all.")
+	fs.IntVar(&o.MaxAge, flagMaxAge, o.MaxAge, "Maximum number of days to retain the rotated log files, "+
+		"0 retains them regardless of their age.")
+	fs.BoolVar(&o.Compress, flagCompress, o.Compress, "Compress the rotated log files with gzip.")
+	fs.BoolVar(&o.DisableSampling, flagDisableSampling, o.DisableSampling, "Disable the sampling of the logs, "+
+		"every message is then logged.")
+	fs.IntVar(&o.SamplingInitial, flagSamplingInitial, o.SamplingInitial, "Number of the identical messages "+
+		"of a level logged per --log.sampling-tick before they are sampled.")
+	fs.IntVar(&o.SamplingThereafter, flagSamplingThereafter, o.SamplingThereafter, "Once sampled, only every "+
+		"Nth identical message of a level is logged until the end of the --log.sampling-tick, "+
+		"0 samples with the default settings.")
+	fs.DurationVar(&o.SamplingTick, flagSamplingTick, o.SamplingTick, "Period over which the identical "+
+		"messages are counted by the sampling, 0 counts them over the default period.")
+	fs.StringSliceVar(&o.SamplingLevels, flagSamplingLevels, o.SamplingLevels, "Sampling settings of levels, "+
+		"overriding --log.sampling-initial and --log.sampling-thereafter, as LEVEL=INITIAL:THEREAFTER, "+
+		"e.g. error=1:1000.")
+}
+
+// samplingSettings are the settings of the sampling of a level.
+type samplingSettings struct {
+	initial    int
+	thereafter int
+}
+
+// samplingLevels returns the sampling settings of the levels set by SamplingLevels, and the errors of
+// the invalid ones.
+func (o *Options) samplingLevels() (map[zapcore.Level]samplingSettings, []error) {
+	var errs []error
+
+	levels := make(map[zapcore.Level]samplingSettings, len(o.SamplingLevels))
+	for _, setting := range o.SamplingLevels {
+		var level zapcore.Level
+
+		name, values := splitPair(setting, "=")
+		initialValue, thereafterValue := splitPair(values, ":")
+		initial, initialErr := strconv.Atoi(initialValue)
+		thereafter, thereafterErr := strconv.Atoi(thereafterValue)
+
+		if level.UnmarshalText([]byte(name)) != nil || initialErr != nil || thereafterErr != nil ||
+			initial < 0 || thereafter <= 0 {
+			errs = append(errs, fmt.Errorf("--%s %q must be LEVEL=INITIAL:THEREAFTER, "+
+				"with INITIAL not negative and THEREAFTER greater than 0", flagSamplingLevels, setting))
+
+			continue
+		}
+
+		levels[level] = samplingSettings{initial: initial, thereafter: thereafter}
+	}
+
+	return levels, errs
+}
+
+// splitPair splits s around the first sep, the second value is empty if s has no sep.
+func splitPair(s, sep string) (string, string) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):]
+	}
+
+	return s, ""
 }
 
 func (o *Options) String() string {
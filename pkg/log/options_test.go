@@ -21,6 +21,7 @@ package log_test
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -41,3 +42,28 @@ func Test_Options_Validate(t *testing.T) {
 	expected := `[unrecognized level: "test" not a valid log format: "test"]`
 	assert.Equal(t, expected, fmt.Sprintf("%s", errs))
 }
+
+func Test_Options_Validate_rotation(t *testing.T) {
+	opts := log.NewOptions()
+	opts.MaxSize, opts.MaxBackups, opts.MaxAge = -1, -2, -3
+
+	errs := opts.Validate()
+	expected := "[--log.max-size -1 must not be negative --log.max-backups -2 must not be negative " +
+		"--log.max-age -3 must not be negative]"
+	assert.Equal(t, expected, fmt.Sprintf("%s", errs))
+}
+
+func Test_Options_Validate_sampling(t *testing.T) {
+	opts := log.NewOptions()
+	opts.SamplingInitial, opts.SamplingThereafter, opts.SamplingTick = -1, -1, -time.Second
+	opts.SamplingLevels = []string{"error=1:1000", "error=1", "verbose=1:10"}
+
+	errs := opts.Validate()
+	expected := "[--log.sampling-initial -1 must not be negative --log.sampling-thereafter -1 must not be negative " +
+		"--log.sampling-tick -1s must not be negative " +
+		`--log.sampling-levels "error=1" must be LEVEL=INITIAL:THEREAFTER, ` +
+		"with INITIAL not negative and THEREAFTER greater than 0 " +
+		`--log.sampling-levels "verbose=1:10" must be LEVEL=INITIAL:THEREAFTER, ` +
+		"with INITIAL not negative and THEREAFTER greater than 0]"
+	assert.Equal(t, expected, fmt.Sprintf("%s", errs))
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RateLimitedLogger logs at most one message per interval with the global logger, e.g. the errors repeated
+// by a loop while redis is down. The messages suppressed in between are counted, their count is appended
+// to the next message logged. Create one per call site with NewRateLimitedLogger, it is safe for concurrent
+// use.
+type RateLimitedLogger struct {
+	interval time.Duration
+	// now is time.Now, replaced by the tests.
+	now func() time.Time
+
+	mu         sync.Mutex
+	next       time.Time
+	suppressed int
+}
+
+// NewRateLimitedLogger creates a RateLimitedLogger logging at most one message per interval.
+func NewRateLimitedLogger(interval time.Duration) *RateLimitedLogger {
+	return &RateLimitedLogger{interval: interval, now: time.Now}
+}
+
+// Infof logs a formatted message at info level, unless a message was logged less than the interval ago.
+func (l *RateLimitedLogger) Infof(format string, v ...interface{}) {
+	l.logf(zapcore.InfoLevel, format, v...)
+}
+
+// Warnf logs a formatted message at warn level, unless a message was logged less than the interval ago.
+func (l *RateLimitedLogger) Warnf(format string, v ...interface{}) {
+	l.logf(zapcore.WarnLevel, format, v...)
+}
+
+// Errorf logs a formatted message at error level, unless a message was logged less than the interval ago.
+func (l *RateLimitedLogger) Errorf(format string, v ...interface{}) {
+	l.logf(zapcore.ErrorLevel, format, v...)
+}
+
+func (l *RateLimitedLogger) logf(level zapcore.Level, format string, v ...interface{}) {
+	suppressed, ok := l.allow()
+	if !ok {
+		return
+	}
+
+	msg := fmt.Sprintf(format, v...)
+	if suppressed > 0 {
+		msg = fmt.Sprintf("%s (%d similar messages suppressed)", msg, suppressed)
+	}
+
+	// the caller is the caller of Infof, Warnf or Errorf.
+	if checkedEntry := std.zapLogger.WithOptions(zap.AddCallerSkip(1)).Check(level, msg); checkedEntry != nil {
+		checkedEntry.Write()
+	}
+}
+
+// allow returns whether a message can be logged, and then the number of the messages suppressed since
+// the last one logged.
+func (l *RateLimitedLogger) allow() (int, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Before(l.next) {
+		l.suppressed++
+
+		return 0, false
+	}
+
+	suppressed := l.suppressed
+	l.next, l.suppressed = now.Add(l.interval), 0
+
+	return suppressed, true
+}
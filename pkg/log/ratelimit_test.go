@@ -0,0 +1,57 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitedLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iam.log")
+	opts := NewOptions()
+	opts.OutputPaths = []string{path}
+	opts.DisableSampling = true
+
+	Init(opts)
+	defer Init(NewOptions())
+
+	now := time.Now()
+	logger := NewRateLimitedLogger(time.Minute)
+	logger.now = func() time.Time { return now }
+
+	// logged, then 4 messages suppressed for a minute.
+	for i := 0; i < 5; i++ {
+		logger.Errorf("Connection to Redis failed: %d", i)
+	}
+
+	now = now.Add(59 * time.Second)
+	logger.Warnf("Connection to Redis failed: %d", 5)
+
+	// the next message logged reports the 5 messages suppressed.
+	now = now.Add(time.Second)
+	logger.Errorf("Connection to Redis failed: %d", 6)
+
+	now = now.Add(time.Minute)
+	logger.Infof("Connection to Redis established again")
+	Flush()
+
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], "Connection to Redis failed: 0")
+	assert.NotContains(t, lines[0], "suppressed")
+	assert.Contains(t, lines[1], "Connection to Redis failed: 6 (5 similar messages suppressed)")
+	assert.Contains(t, lines[1], "ratelimit_test.go", "the caller is the caller of Errorf")
+	assert.Contains(t, lines[2], "Connection to Redis established again")
+	assert.NotContains(t, lines[2], "suppressed")
+}
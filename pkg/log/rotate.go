@@ -0,0 +1,122 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/marmotedu/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// rotators are the rotating log files, by absolute path. A file is rotated by a single rotator, shared by
+// the output and error output paths and by the loggers created by New, for it not to be rotated twice.
+var (
+	rotatorsMu sync.Mutex
+	rotators   = make(map[string]*lumberjack.Logger)
+)
+
+// openSinks opens the output paths, the log files being rotated as set by opts. The stdout and stderr
+// outputs, and the outputs set by an URL, are opened by zap and never rotated.
+func openSinks(paths []string, opts *Options) (zapcore.WriteSyncer, error) {
+	var (
+		sinks    []zapcore.WriteSyncer
+		zapPaths []string
+	)
+
+	for _, path := range paths {
+		if opts.MaxSize == 0 || path == "stdout" || path == "stderr" || strings.Contains(path, "://") {
+			zapPaths = append(zapPaths, path)
+
+			continue
+		}
+
+		rotator, err := openRotator(path, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		// lumberjack serializes the writes and the rotations, it is safe for the concurrent loggers.
+		sinks = append(sinks, zapcore.AddSync(rotator))
+	}
+
+	if len(zapPaths) > 0 {
+		sink, _, err := zap.Open(zapPaths...)
+		if err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, sink)
+	}
+
+	return zapcore.NewMultiWriteSyncer(sinks...), nil
+}
+
+// openRotator returns the rotator of the log file path, the rotator of a file already opened with other
+// rotation options is closed and replaced.
+func openRotator(path string, opts *Options) (*lumberjack.Logger, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rotatorsMu.Lock()
+	defer rotatorsMu.Unlock()
+
+	rotator, ok := rotators[path]
+	if ok && rotator.MaxSize == opts.MaxSize && rotator.MaxBackups == opts.MaxBackups &&
+		rotator.MaxAge == opts.MaxAge && rotator.Compress == opts.Compress {
+		return rotator, nil
+	}
+
+	if ok {
+		_ = rotator.Close()
+	}
+
+	rotator = &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    opts.MaxSize,
+		MaxBackups: opts.MaxBackups,
+		MaxAge:     opts.MaxAge,
+		Compress:   opts.Compress,
+		// the backups are named after the local time, as the time of the logs.
+		LocalTime: true,
+	}
+
+	// lumberjack opens the file on the first write, an empty write creates it, and its directory, now
+	// as zap does, for the file to exist once the logger is initialized and the errors to be returned.
+	if _, err := rotator.Write(nil); err != nil {
+		return nil, err
+	}
+
+	rotators[path] = rotator
+
+	return rotator, nil
+}
+
+// Rotate rotates the log files, e.g. on SIGHUP after they were moved away by logrotate. The logs are then
+// written to new files, the previous ones being renamed with the time of the rotation.
+func Rotate() error {
+	rotatorsMu.Lock()
+	defer rotatorsMu.Unlock()
+
+	var errs []error
+
+	for _, rotator := range rotators {
+		if err := rotator.Rotate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.NewAggregate(errs)
+	}
+
+	return nil
+}
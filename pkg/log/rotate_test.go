@@ -0,0 +1,125 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// initFileLogger initializes the global logger writing to the file iam.log of a temporary directory,
+// rotated as set by modify, and returns the directory.
+func initFileLogger(t *testing.T, modify func(opts *log.Options)) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{filepath.Join(dir, "iam.log")}
+	opts.ErrorOutputPaths = []string{filepath.Join(dir, "iam.log")}
+	modify(opts)
+
+	log.Init(opts)
+	t.Cleanup(func() { log.Init(log.NewOptions()) })
+
+	return dir
+}
+
+// logFiles returns the names of the log files of dir, the current one and the rotated ones.
+func logFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	return names
+}
+
+func Test_Rotation(t *testing.T) {
+	message := strings.Repeat("x", 1024)
+
+	tests := []struct {
+		name    string
+		maxSize int
+		rotated bool
+	}{
+		{name: "rotated past the maximum size", maxSize: 1, rotated: true},
+		{name: "not rotated below the maximum size", maxSize: 4},
+		{name: "rotation disabled", maxSize: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := initFileLogger(t, func(opts *log.Options) { opts.MaxSize = tt.maxSize })
+
+			// 3 MB, the messages differ for the lines not to be sampled.
+			for i := 0; i < 3*1024; i++ {
+				log.Infof("%d %s", i, message)
+			}
+			log.Flush()
+
+			files := logFiles(t, dir)
+			assert.Contains(t, files, "iam.log")
+			assert.Equal(t, tt.rotated, len(files) > 1, "files %v", files)
+		})
+	}
+}
+
+func Test_Rotation_concurrent(t *testing.T) {
+	dir := initFileLogger(t, func(opts *log.Options) { opts.MaxSize = 1 })
+	message := strings.Repeat("x", 1024)
+
+	// the writes of the concurrent requests, e.g. by the access log, are not lost by the rotations.
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+
+		go func(worker int) {
+			defer wg.Done()
+
+			for i := 0; i < 512; i++ {
+				log.Infof("%d %d %s", worker, i, message)
+			}
+		}(worker)
+	}
+	wg.Wait()
+	log.Flush()
+
+	lines := 0
+	for _, name := range logFiles(t, dir) {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		assert.Nil(t, err)
+
+		lines += strings.Count(string(content), "\n")
+	}
+	assert.Equal(t, 8*512, lines)
+}
+
+func Test_Rotate(t *testing.T) {
+	dir := initFileLogger(t, func(opts *log.Options) {})
+
+	log.Info("before the rotation")
+	assert.Nil(t, log.Rotate())
+	log.Info("after the rotation")
+	log.Flush()
+
+	files := logFiles(t, dir)
+	assert.Len(t, files, 2, "files %v", files)
+
+	content, err := os.ReadFile(filepath.Join(dir, "iam.log"))
+	assert.Nil(t, err)
+	assert.NotContains(t, string(content), "before the rotation")
+	assert.Contains(t, string(content), "after the rotation")
+}
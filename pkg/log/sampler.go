@@ -0,0 +1,60 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// levelSampler samples the entries of the levels set by Options.SamplingLevels by the sampler of their
+// level, and the entries of the other levels by the default sampler it embeds.
+type levelSampler struct {
+	zapcore.Core
+	levels map[zapcore.Level]zapcore.Core
+}
+
+// newSampler wraps core with the samplers set by opts. The sampler of a level counts the identical
+// messages of the level per tick, the first initial ones are logged and then every thereafter-th one.
+// The invalid settings are ignored, the settings without thereafter or tick, e.g. of the options not
+// created by NewOptions, are those of NewOptions.
+func newSampler(core zapcore.Core, opts *Options) zapcore.Core {
+	defaults := NewOptions()
+
+	initial, thereafter, tick := opts.SamplingInitial, opts.SamplingThereafter, opts.SamplingTick
+	if initial < 0 || thereafter <= 0 {
+		initial, thereafter = defaults.SamplingInitial, defaults.SamplingThereafter
+	}
+	if tick <= 0 {
+		tick = defaults.SamplingTick
+	}
+
+	levels, _ := opts.samplingLevels()
+	sampler := &levelSampler{
+		Core:   zapcore.NewSamplerWithOptions(core, tick, initial, thereafter),
+		levels: make(map[zapcore.Level]zapcore.Core, len(levels)),
+	}
+	for level, settings := range levels {
+		sampler.levels[level] = zapcore.NewSamplerWithOptions(core, tick, settings.initial, settings.thereafter)
+	}
+
+	return sampler
+}
+
+func (s *levelSampler) With(fields []zapcore.Field) zapcore.Core {
+	levels := make(map[zapcore.Level]zapcore.Core, len(s.levels))
+	for level, sampler := range s.levels {
+		levels[level] = sampler.With(fields)
+	}
+
+	return &levelSampler{Core: s.Core.With(fields), levels: levels}
+}
+
+func (s *levelSampler) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if sampler, ok := s.levels[ent.Level]; ok {
+		return sampler.Check(ent, ce)
+	}
+
+	return s.Core.Check(ent, ce)
+}
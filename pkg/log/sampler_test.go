@@ -0,0 +1,75 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+func Test_Sampling(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(opts *log.Options)
+		// wantInfos and wantErrors are the numbers of the 10 identical messages logged.
+		wantInfos  int
+		wantErrors int
+	}{
+		{name: "defaults", modify: func(opts *log.Options) {}, wantInfos: 10, wantErrors: 10},
+		{
+			// the first 2 messages, then the 5th and the 8th.
+			name:       "sampled",
+			modify:     func(opts *log.Options) { opts.SamplingInitial, opts.SamplingThereafter = 2, 3 },
+			wantInfos:  4,
+			wantErrors: 4,
+		},
+		{
+			// the first error message, then the 6th.
+			name: "sampled by level",
+			modify: func(opts *log.Options) {
+				opts.SamplingInitial, opts.SamplingThereafter = 2, 3
+				opts.SamplingLevels = []string{"error=1:5"}
+			},
+			wantInfos:  4,
+			wantErrors: 2,
+		},
+		{
+			name: "sampling disabled",
+			modify: func(opts *log.Options) {
+				opts.SamplingInitial, opts.SamplingThereafter = 1, 100
+				opts.DisableSampling = true
+			},
+			wantInfos:  10,
+			wantErrors: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := initFileLogger(t, func(opts *log.Options) {
+				// a tick long enough for the messages to be counted over a single tick.
+				opts.SamplingTick = time.Hour
+				tt.modify(opts)
+			})
+
+			for i := 0; i < 10; i++ {
+				log.Info("Connection to Redis failed")
+				log.Error("Connection to Redis failed")
+			}
+			log.Flush()
+
+			content, err := os.ReadFile(filepath.Join(dir, "iam.log"))
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantInfos, strings.Count(string(content), "INFO"))
+			assert.Equal(t, tt.wantErrors, strings.Count(string(content), "ERROR"))
+		})
+	}
+}
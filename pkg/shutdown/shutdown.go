@@ -157,11 +157,70 @@ message to correct instance via http on port 7999.
 		// do other stuff
 		time.Sleep(time.Hour * 2)
 	}
+
+Example - ordered shutdown
+
+The callbacks run by stages of ascending priority: the callbacks of a
+stage run in separate go routines, and the next stage starts once they
+all returned. The errors of a stage are reported, they do not prevent
+the next stages from running.
+
+	// the in-flight requests are drained before closing the database they use.
+	gs.AddShutdownCallbackWithPriority(shutdown.ShutdownFunc(func(string) error {
+		return server.Shutdown(context.Background())
+	}), shutdown.PriorityDrain)
+
+	gs.AddShutdownCallbackWithPriority(shutdown.ShutdownFunc(func(string) error {
+		return db.Close()
+	}), shutdown.PriorityCloseStores)
+
+Example - shutdown deadline
+
+SetTimeout bounds the whole shutdown, e.g. to complete before kubernetes
+kills the pod. The callbacks implementing ShutdownCallbackCtx receive a
+context canceled at the deadline. Once it is exceeded, the callbacks still
+running are abandoned, reported to the ErrorHandler by name, and the next
+stages are called with the canceled context.
+
+	gs.SetTimeout(25 * time.Second)
+
+	gs.AddShutdownCallbackCtx("drain", shutdown.ShutdownFuncCtx(func(ctx context.Context, _ string) error {
+		return server.Shutdown(ctx)
+	}), shutdown.PriorityDrain)
 */
 package shutdown
 
 import (
+	"context"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
+)
+
+// abandonDelay is the time given to the callbacks to return once the deadline of the
+// shutdown is exceeded, before they are abandoned: the ones honoring their context
+// return as soon as it is canceled.
+const abandonDelay = 100 * time.Millisecond
+
+// Priority orders the shutdown callbacks, the callbacks of a lower priority return
+// before the ones of a higher priority are called.
+type Priority int
+
+// The priorities of the usual shutdown stages of a server.
+const (
+	// PriorityStopReadiness reports the server not ready, for the load balancers to
+	// stop sending new requests.
+	PriorityStopReadiness Priority = 100
+	// PriorityDrain waits for the in-flight requests to complete.
+	PriorityDrain Priority = 200
+	// PriorityFlush flushes the buffered data, e.g. the analytics records.
+	PriorityFlush Priority = 300
+	// PriorityStopJobs stops the background jobs, e.g. the cache loaders. It is the
+	// priority of the callbacks added by AddShutdownCallback.
+	PriorityStopJobs Priority = 400
+	// PriorityCloseStores closes the connections to the stores, once nothing uses them.
+	PriorityCloseStores Priority = 500
 )
 
 // ShutdownCallback is an interface you have to implement for callbacks.
@@ -180,6 +239,46 @@ func (f ShutdownFunc) OnShutdown(shutdownManager string) error {
 	return f(shutdownManager)
 }
 
+// ShutdownCallbackCtx is the interface of the callbacks which honor the
+// deadline of the shutdown. OnShutdownCtx will be called when shutdown is
+// requested, with a context canceled once the deadline set by SetTimeout is
+// exceeded, and the name of the ShutdownManager that requested shutdown.
+type ShutdownCallbackCtx interface {
+	OnShutdownCtx(ctx context.Context, shutdownManager string) error
+}
+
+// ShutdownFuncCtx is a helper type, so you can easily provide anonymous functions
+// as ShutdownCallbackCtx. It is a ShutdownCallback as well, called without deadline.
+type ShutdownFuncCtx func(ctx context.Context, shutdownManager string) error
+
+// OnShutdownCtx defines the action needed to run when shutdown triggered.
+func (f ShutdownFuncCtx) OnShutdownCtx(ctx context.Context, shutdownManager string) error {
+	return f(ctx, shutdownManager)
+}
+
+// OnShutdown runs the action without deadline.
+func (f ShutdownFuncCtx) OnShutdown(shutdownManager string) error {
+	return f(context.Background(), shutdownManager)
+}
+
+// AdaptShutdownCallback returns the ShutdownCallbackCtx of shutdownCallback: itself
+// if it implements ShutdownCallbackCtx, else an adapter ignoring the context.
+func AdaptShutdownCallback(shutdownCallback ShutdownCallback) ShutdownCallbackCtx {
+	if callback, ok := shutdownCallback.(ShutdownCallbackCtx); ok {
+		return callback
+	}
+
+	return shutdownCallbackAdapter{shutdownCallback}
+}
+
+type shutdownCallbackAdapter struct {
+	ShutdownCallback
+}
+
+func (a shutdownCallbackAdapter) OnShutdownCtx(_ context.Context, shutdownManager string) error {
+	return a.OnShutdown(shutdownManager)
+}
+
 // ShutdownManager is an interface implemnted by ShutdownManagers.
 // GetName returns the name of ShutdownManager.
 // ShutdownManagers start listening for shutdown requests in Start.
@@ -220,15 +319,24 @@ type GSInterface interface {
 // GracefulShutdown is main struct that handles ShutdownCallbacks and
 // ShutdownManagers. Initialize it with New.
 type GracefulShutdown struct {
-	callbacks    []ShutdownCallback
+	lock         sync.Mutex
+	callbacks    []prioritizedCallback
 	managers     []ShutdownManager
 	errorHandler ErrorHandler
+	timeout      time.Duration
+	shutdownOnce sync.Once
+}
+
+type prioritizedCallback struct {
+	name     string
+	callback ShutdownCallbackCtx
+	priority Priority
 }
 
 // New initializes GracefulShutdown.
 func New() *GracefulShutdown {
 	return &GracefulShutdown{
-		callbacks: make([]ShutdownCallback, 0, 10),
+		callbacks: make([]prioritizedCallback, 0, 10),
 		managers:  make([]ShutdownManager, 0, 3),
 	}
 }
@@ -252,7 +360,7 @@ func (gs *GracefulShutdown) AddShutdownManager(manager ShutdownManager) {
 }
 
 // AddShutdownCallback adds a ShutdownCallback that will be called when
-// shutdown is requested.
+// shutdown is requested, with the PriorityStopJobs priority.
 //
 // You can provide anything that implements ShutdownCallback interface,
 // or you can supply a function like this:
@@ -261,7 +369,42 @@ func (gs *GracefulShutdown) AddShutdownManager(manager ShutdownManager) {
 //		return nil
 //	}))
 func (gs *GracefulShutdown) AddShutdownCallback(shutdownCallback ShutdownCallback) {
-	gs.callbacks = append(gs.callbacks, shutdownCallback)
+	gs.AddShutdownCallbackWithPriority(shutdownCallback, PriorityStopJobs)
+}
+
+// AddShutdownCallbackWithPriority adds a ShutdownCallback that will be called
+// in the stage of priority when shutdown is requested, once the callbacks of
+// the lower priorities returned. The callbacks of the same priority are called
+// concurrently.
+func (gs *GracefulShutdown) AddShutdownCallbackWithPriority(shutdownCallback ShutdownCallback, priority Priority) {
+	gs.addShutdownCallback("", AdaptShutdownCallback(shutdownCallback), priority)
+}
+
+// AddShutdownCallbackCtx adds a ShutdownCallbackCtx like AddShutdownCallbackWithPriority.
+// The name identifies the callback in the errors reported if it exceeds the deadline
+// of the shutdown.
+//
+// You can provide anything that implements ShutdownCallbackCtx interface,
+// or you can supply a function like this:
+//	AddShutdownCallbackCtx("drain", shutdown.ShutdownFuncCtx(func(ctx context.Context, _ string) error {
+//		// callback code honoring ctx
+//		return nil
+//	}), shutdown.PriorityDrain)
+func (gs *GracefulShutdown) AddShutdownCallbackCtx(name string, shutdownCallback ShutdownCallbackCtx,
+	priority Priority) {
+	gs.addShutdownCallback(name, shutdownCallback, priority)
+}
+
+func (gs *GracefulShutdown) addShutdownCallback(name string, shutdownCallback ShutdownCallbackCtx, priority Priority) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+
+	// the unnamed callbacks are named after their order of addition.
+	if name == "" {
+		name = fmt.Sprintf("#%d", len(gs.callbacks))
+	}
+
+	gs.callbacks = append(gs.callbacks, prioritizedCallback{name: name, callback: shutdownCallback, priority: priority})
 }
 
 // SetErrorHandler sets an ErrorHandler that will be called when an error
@@ -276,26 +419,131 @@ func (gs *GracefulShutdown) SetErrorHandler(errorHandler ErrorHandler) {
 	gs.errorHandler = errorHandler
 }
 
+// SetTimeout sets the deadline of the shutdown, counted from the StartShutdown
+// call. The callbacks still running at the deadline are abandoned, see
+// StartShutdown. Zero, the default, means no deadline.
+func (gs *GracefulShutdown) SetTimeout(timeout time.Duration) {
+	gs.timeout = timeout
+}
+
 // StartShutdown is called from a ShutdownManager and will initiate shutdown.
 // first call ShutdownStart on Shutdownmanager,
-// call all ShutdownCallbacks, wait for callbacks to finish and
+// call all ShutdownCallbacks stage by stage, wait for callbacks to finish and
 // call ShutdownFinish on ShutdownManager.
+//
+// The shutdown runs once, e.g. when both a preStop hook and SIGTERM request it:
+// the later calls wait for the first one to complete, and do nothing.
+//
+// Once the deadline set by SetTimeout is exceeded, the context of the callbacks
+// is canceled, and the callbacks still running are reported to the ErrorHandler
+// and no longer waited for. The next stages are called nevertheless, their
+// callbacks are expected to return at once, or they are abandoned too.
 func (gs *GracefulShutdown) StartShutdown(sm ShutdownManager) {
-	gs.ReportError(sm.ShutdownStart())
+	gs.shutdownOnce.Do(func() {
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if gs.timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, gs.timeout)
+		}
+		defer cancel()
+
+		gs.ReportError(sm.ShutdownStart())
+
+		for _, stage := range gs.stages() {
+			for _, err := range runStage(ctx, stage, sm.GetName()) {
+				gs.ReportError(err)
+			}
+		}
+
+		gs.ReportError(sm.ShutdownFinish())
+	})
+}
+
+// stages returns the callbacks grouped by priority, in ascending priority. The
+// callbacks of a stage are in the order they were added.
+func (gs *GracefulShutdown) stages() [][]prioritizedCallback {
+	gs.lock.Lock()
+	callbacks := make([]prioritizedCallback, len(gs.callbacks))
+	copy(callbacks, gs.callbacks)
+	gs.lock.Unlock()
+
+	sort.SliceStable(callbacks, func(i, j int) bool {
+		return callbacks[i].priority < callbacks[j].priority
+	})
+
+	var stages [][]prioritizedCallback
+	for i, c := range callbacks {
+		if i == 0 || c.priority != callbacks[i-1].priority {
+			stages = append(stages, nil)
+		}
+
+		stages[len(stages)-1] = append(stages[len(stages)-1], c)
+	}
+
+	return stages
+}
 
-	var wg sync.WaitGroup
-	for _, shutdownCallback := range gs.callbacks {
+// runStage calls the callbacks concurrently, it returns all their errors once they
+// all returned, or shortly after ctx is done. In the latter case, the callbacks still
+// running are abandoned and an error is returned for each of them.
+func runStage(ctx context.Context, callbacks []prioritizedCallback, shutdownManager string) []error {
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+		errs []error
+		// returned records the callbacks which returned, the errors of the ones
+		// returning after the stage was abandoned are dropped.
+		returned  = make([]bool, len(callbacks))
+		abandoned bool
+	)
+
+	for i, c := range callbacks {
 		wg.Add(1)
-		go func(shutdownCallback ShutdownCallback) {
+		go func(i int, c prioritizedCallback) {
 			defer wg.Done()
 
-			gs.ReportError(shutdownCallback.OnShutdown(sm.GetName()))
-		}(shutdownCallback)
+			err := c.callback.OnShutdownCtx(ctx, shutdownManager)
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			returned[i] = true
+			if err != nil && !abandoned {
+				errs = append(errs, err)
+			}
+		}(i, c)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		timer := time.NewTimer(abandonDelay)
+		defer timer.Stop()
+
+		select {
+		case <-done:
+		case <-timer.C:
+		}
 	}
 
-	wg.Wait()
+	lock.Lock()
+	defer lock.Unlock()
+
+	abandoned = true
+
+	for i, c := range callbacks {
+		if !returned[i] {
+			errs = append(errs, fmt.Errorf("shutdown callback %s of priority %d exceeded the shutdown deadline: %w",
+				c.name, c.priority, ctx.Err()))
+		}
+	}
 
-	gs.ReportError(sm.ShutdownFinish())
+	return errs
 }
 
 // ReportError is a function that can be used to report errors to
@@ -5,7 +5,11 @@
 package shutdown
 
 import (
+	"context"
 	"errors"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -252,3 +256,277 @@ func TestShutdownManagerName(t *testing.T) {
 		t.Error("Expected shutdownManager to be 'test-sm'.")
 	}
 }
+
+func TestCallbacksRunByPriority(t *testing.T) {
+	gs := New()
+
+	var (
+		lock  sync.Mutex
+		order []Priority
+	)
+
+	// added out of order, the slow callbacks check that a stage waits for all its callbacks.
+	for _, priority := range []Priority{PriorityCloseStores, PriorityDrain, PriorityStopReadiness, PriorityDrain, PriorityFlush} {
+		priority := priority
+		gs.AddShutdownCallbackWithPriority(ShutdownFunc(func(string) error {
+			time.Sleep(time.Duration(priority/100) * time.Millisecond)
+
+			lock.Lock()
+			order = append(order, priority)
+			lock.Unlock()
+
+			return nil
+		}), priority)
+	}
+
+	gs.AddShutdownCallback(ShutdownFunc(func(string) error {
+		lock.Lock()
+		order = append(order, PriorityStopJobs)
+		lock.Unlock()
+
+		return nil
+	}))
+
+	gs.StartShutdown(SMFinishFunc(func() error {
+		return nil
+	}))
+
+	want := []Priority{
+		PriorityStopReadiness, PriorityDrain, PriorityDrain, PriorityFlush, PriorityStopJobs, PriorityCloseStores,
+	}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("Expected callbacks to run in order %v, got %v", want, order)
+	}
+}
+
+func TestCallbacksOfStageRunConcurrently(t *testing.T) {
+	gs := New()
+
+	// each callback waits for the other one to start.
+	started := []chan struct{}{make(chan struct{}), make(chan struct{})}
+	c := make(chan int, 100)
+
+	for i := range started {
+		self, other := started[i], started[1-i]
+		gs.AddShutdownCallbackWithPriority(ShutdownFunc(func(string) error {
+			close(self)
+
+			select {
+			case <-other:
+				c <- 1
+			case <-time.After(time.Second):
+			}
+
+			return nil
+		}), PriorityDrain)
+	}
+
+	gs.StartShutdown(SMFinishFunc(func() error {
+		return nil
+	}))
+
+	if len(c) != 2 {
+		t.Error("Expected 2 callbacks of the stage to run concurrently, got ", len(c))
+	}
+}
+
+func TestErrorsOfAllStagesGetReported(t *testing.T) {
+	c := make(chan string, 100)
+	gs := New()
+
+	gs.SetErrorHandler(ErrorFunc(func(err error) {
+		c <- err.Error()
+	}))
+
+	for _, priority := range []Priority{PriorityDrain, PriorityDrain, PriorityCloseStores} {
+		priority := priority
+		gs.AddShutdownCallbackWithPriority(ShutdownFunc(func(string) error {
+			return errors.New("my-error")
+		}), priority)
+	}
+
+	ran := make(chan int, 100)
+	gs.AddShutdownCallbackWithPriority(ShutdownFunc(func(string) error {
+		ran <- 1
+
+		return nil
+	}), PriorityCloseStores)
+
+	gs.StartShutdown(SMFinishFunc(func() error {
+		return nil
+	}))
+
+	if len(c) != 3 {
+		t.Error("Expected 3 errors from ShutdownCallbacks, got ", len(c))
+	}
+
+	if len(ran) != 1 {
+		t.Error("Expected the stages after a failed one to run")
+	}
+}
+
+func TestShutdownRunsOnce(t *testing.T) {
+	c := make(chan int, 100)
+	finished := make(chan int, 100)
+	gs := New()
+
+	gs.AddShutdownCallback(ShutdownFunc(func(string) error {
+		time.Sleep(5 * time.Millisecond)
+		c <- 1
+
+		return nil
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			gs.StartShutdown(SMFinishFunc(func() error {
+				finished <- 1
+
+				return nil
+			}))
+
+			// the later call waits for the shutdown to complete.
+			if len(c) != 1 {
+				t.Error("Expected StartShutdown to return once the callbacks returned")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if len(c) != 1 || len(finished) != 1 {
+		t.Error("Expected the callbacks and ShutdownFinish to be called once, got ", len(c), len(finished))
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+
+	// honoring returns once its context is done, straggling ignores it.
+	honoring := ShutdownFuncCtx(func(ctx context.Context, _ string) error {
+		<-ctx.Done()
+
+		return nil
+	})
+	straggling := ShutdownFunc(func(string) error {
+		time.Sleep(time.Second)
+
+		return nil
+	})
+	quick := ShutdownFunc(func(string) error {
+		return nil
+	})
+
+	tests := []struct {
+		name      string
+		timeout   time.Duration
+		callbacks map[string]ShutdownCallback
+		// wantAbandoned are the names of the callbacks reported for exceeding the deadline.
+		wantAbandoned []string
+		wantMax       time.Duration
+	}{
+		{
+			name:      "within the deadline",
+			timeout:   timeout,
+			callbacks: map[string]ShutdownCallback{"quick": quick},
+			wantMax:   timeout,
+		},
+		{
+			name:      "context canceled at the deadline",
+			timeout:   timeout,
+			callbacks: map[string]ShutdownCallback{"honoring": honoring, "quick": quick},
+			wantMax:   time.Second / 2,
+		},
+		{
+			name:          "straggler abandoned",
+			timeout:       timeout,
+			callbacks:     map[string]ShutdownCallback{"straggling": straggling, "quick": quick},
+			wantAbandoned: []string{"straggling"},
+			wantMax:       time.Second / 2,
+		},
+		{
+			name:      "no deadline",
+			callbacks: map[string]ShutdownCallback{"straggling": straggling},
+			wantMax:   2 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := New()
+			gs.SetTimeout(tt.timeout)
+
+			var (
+				lock sync.Mutex
+				errs []string
+			)
+			gs.SetErrorHandler(ErrorFunc(func(err error) {
+				lock.Lock()
+				errs = append(errs, err.Error())
+				lock.Unlock()
+			}))
+
+			for name, callback := range tt.callbacks {
+				gs.AddShutdownCallbackCtx(name, AdaptShutdownCallback(callback), PriorityDrain)
+			}
+
+			// the stages after the deadline are still called.
+			ran := make(chan int, 100)
+			gs.AddShutdownCallbackWithPriority(ShutdownFunc(func(string) error {
+				ran <- 1
+
+				return nil
+			}), PriorityCloseStores)
+
+			start := time.Now()
+			gs.StartShutdown(SMFinishFunc(func() error {
+				return nil
+			}))
+
+			if elapsed := time.Since(start); elapsed > tt.wantMax {
+				t.Errorf("StartShutdown returned after %v, want at most %v", elapsed, tt.wantMax)
+			}
+
+			if len(ran) != 1 {
+				t.Error("Expected the stages after the deadline to run")
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if len(errs) != len(tt.wantAbandoned) {
+				t.Fatalf("Expected %d errors, got %v", len(tt.wantAbandoned), errs)
+			}
+
+			for i, name := range tt.wantAbandoned {
+				if !strings.Contains(errs[i], "shutdown callback "+name+" ") {
+					t.Errorf("Expected the error to name %s, got %s", name, errs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAdaptShutdownCallback(t *testing.T) {
+	callback := ShutdownFuncCtx(func(context.Context, string) error {
+		return nil
+	})
+
+	if _, ok := AdaptShutdownCallback(callback).(ShutdownFuncCtx); !ok {
+		t.Error("Expected a ShutdownCallbackCtx not to be wrapped")
+	}
+
+	c := make(chan string, 1)
+	adapted := AdaptShutdownCallback(ShutdownFunc(func(shutdownManager string) error {
+		c <- shutdownManager
+
+		return nil
+	}))
+
+	if err := adapted.OnShutdownCtx(context.Background(), "test-sm"); err != nil || <-c != "test-sm" {
+		t.Error("Expected the adapter to call OnShutdown, got ", err)
+	}
+}
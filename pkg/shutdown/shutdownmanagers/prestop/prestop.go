@@ -0,0 +1,141 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+/*
+Package prestop provides a listener for the kubernetes preStop hooks. It serves
+POST /prestop, which marks the server not ready, waits for the load balancers to
+stop sending new requests, and then starts the shutdown. The request returns once
+the shutdown is started, kubernetes sends SIGTERM afterwards.
+When ShutdownFinish is called it exits with os.Exit(0)
+*/
+package prestop
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/shutdown"
+)
+
+// Name defines shutdown manager name.
+const Name = "PreStopManager"
+
+// Path is the path of the preStop endpoint.
+const Path = "/prestop"
+
+// PreStopManager implements ShutdownManager interface that is added
+// to GracefulShutdown. Initialize with NewPreStopManager.
+type PreStopManager struct {
+	addr     string
+	delay    time.Duration
+	notReady func()
+
+	lock     sync.Mutex
+	listener net.Listener
+	once     sync.Once
+}
+
+// NewPreStopManager initializes the PreStopManager listening on addr, which
+// should be a loopback address as the endpoint is not authenticated. notReady
+// marks the server not ready, then the shutdown starts after delay, or as soon
+// as SIGINT or SIGTERM is received.
+func NewPreStopManager(addr string, delay time.Duration, notReady func()) *PreStopManager {
+	return &PreStopManager{
+		addr:     addr,
+		delay:    delay,
+		notReady: notReady,
+	}
+}
+
+// GetName returns name of this ShutdownManager.
+func (preStopManager *PreStopManager) GetName() string {
+	return Name
+}
+
+// Start starts listening for the preStop requests.
+func (preStopManager *PreStopManager) Start(gs shutdown.GSInterface) error {
+	listener, err := net.Listen("tcp", preStopManager.addr)
+	if err != nil {
+		return err
+	}
+
+	preStopManager.lock.Lock()
+	preStopManager.listener = listener
+	preStopManager.lock.Unlock()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(Path, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		// the concurrent requests wait for the first one.
+		preStopManager.once.Do(func() {
+			preStopManager.preStop(gs)
+		})
+	})
+
+	//nolint: errcheck
+	go http.Serve(listener, mux)
+
+	return nil
+}
+
+// Addr returns the address the manager listens on, once started.
+func (preStopManager *PreStopManager) Addr() net.Addr {
+	preStopManager.lock.Lock()
+	defer preStopManager.lock.Unlock()
+
+	if preStopManager.listener == nil {
+		return nil
+	}
+
+	return preStopManager.listener.Addr()
+}
+
+func (preStopManager *PreStopManager) preStop(gs shutdown.GSInterface) {
+	// the signal is also received by the PosixSignalManager, whose shutdown is the same.
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(c)
+
+	preStopManager.notReady()
+
+	timer := time.NewTimer(preStopManager.delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-c:
+	}
+
+	go gs.StartShutdown(preStopManager)
+}
+
+// ShutdownStart stops listening for the preStop requests.
+func (preStopManager *PreStopManager) ShutdownStart() error {
+	preStopManager.lock.Lock()
+	defer preStopManager.lock.Unlock()
+
+	if preStopManager.listener == nil {
+		return nil
+	}
+
+	return preStopManager.listener.Close()
+}
+
+// ShutdownFinish exits the app with os.Exit(0).
+func (preStopManager *PreStopManager) ShutdownFinish() error {
+	os.Exit(0)
+
+	return nil
+}
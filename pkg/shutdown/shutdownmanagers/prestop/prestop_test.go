@@ -0,0 +1,136 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package prestop
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/shutdown"
+)
+
+type startShutdownFunc func(sm shutdown.ShutdownManager)
+
+func (f startShutdownFunc) StartShutdown(sm shutdown.ShutdownManager) {
+	f(sm)
+}
+
+func (f startShutdownFunc) ReportError(err error) {
+}
+
+func (f startShutdownFunc) AddShutdownCallback(shutdownCallback shutdown.ShutdownCallback) {
+}
+
+func TestPreStop(t *testing.T) {
+	tests := []struct {
+		name  string
+		delay time.Duration
+		// sigterm sends SIGTERM once the server is not ready.
+		sigterm bool
+		// wantDelay is the minimum time between the readiness flip and the shutdown.
+		wantDelay time.Duration
+	}{
+		{
+			name:      "delay elapsed",
+			delay:     100 * time.Millisecond,
+			wantDelay: 100 * time.Millisecond,
+		},
+		{
+			name:    "sigterm",
+			delay:   time.Minute,
+			sigterm: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				lock     sync.Mutex
+				events   []string
+				notReady time.Time
+			)
+
+			shutdownStarted := make(chan time.Time, 1)
+
+			psm := NewPreStopManager("127.0.0.1:0", tt.delay, func() {
+				lock.Lock()
+				events = append(events, "not ready")
+				notReady = time.Now()
+				lock.Unlock()
+
+				if tt.sigterm {
+					syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+				}
+			})
+
+			err := psm.Start(startShutdownFunc(func(sm shutdown.ShutdownManager) {
+				lock.Lock()
+				// the listeners of the servers are closed by the shutdown callbacks.
+				events = append(events, "shutdown")
+				lock.Unlock()
+
+				shutdownStarted <- time.Now()
+			}))
+			if err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+			defer psm.ShutdownStart() //nolint: errcheck
+
+			rsp, err := http.Post("http://"+psm.Addr().String()+Path, "", nil)
+			if err != nil {
+				t.Fatalf("POST %s error = %v", Path, err)
+			}
+			rsp.Body.Close()
+
+			if rsp.StatusCode != http.StatusOK {
+				t.Errorf("POST %s code = %d, want %d", Path, rsp.StatusCode, http.StatusOK)
+			}
+
+			var started time.Time
+			select {
+			case started = <-shutdownStarted:
+			case <-time.After(time.Second):
+				t.Fatal("Timeout waiting for StartShutdown.")
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if want := []string{"not ready", "shutdown"}; !reflect.DeepEqual(events, want) {
+				t.Errorf("events = %v, want %v", events, want)
+			}
+
+			if delay := started.Sub(notReady); delay < tt.wantDelay {
+				t.Errorf("shutdown started %v after the readiness flip, want at least %v", delay, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestPreStopMethodNotAllowed(t *testing.T) {
+	psm := NewPreStopManager("127.0.0.1:0", 0, func() {
+		t.Error("Expected the server to stay ready.")
+	})
+
+	if err := psm.Start(startShutdownFunc(func(sm shutdown.ShutdownManager) {
+		t.Error("Expected no shutdown.")
+	})); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer psm.ShutdownStart() //nolint: errcheck
+
+	rsp, err := http.Get("http://" + psm.Addr().String() + Path)
+	if err != nil {
+		t.Fatalf("GET %s error = %v", Path, err)
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET %s code = %d, want %d", Path, rsp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
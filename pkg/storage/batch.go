@@ -0,0 +1,142 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+
+	redis "github.com/go-redis/redis/v7"
+	"github.com/marmotedu/errors"
+)
+
+// LookupMultiKey gets the values of the keys in one round trip per redis node. The values are in
+// the order of the keys, found reports whether each key exists: a missing key does not fail the
+// batch, its value is empty. In cluster mode the keys are grouped by hash slot, one MGET is sent
+// per slot and the MGETs are pipelined.
+func (r *RedisCluster) LookupMultiKey(ctx context.Context, keys []string) (values []string, found []bool, err error) {
+	if err := r.up(); err != nil {
+		return nil, nil, err
+	}
+
+	values = make([]string, len(keys))
+	found = make([]bool, len(keys))
+
+	if len(keys) == 0 {
+		return values, found, nil
+	}
+
+	fixedKeys := make([]string, len(keys))
+	for i, key := range keys {
+		fixedKeys[i] = r.fixKey(key)
+	}
+
+	// set stores the values replied by a MGET of the keys at indexes.
+	set := func(indexes []int, replies []interface{}) {
+		for i, reply := range replies {
+			if value, ok := reply.(string); ok {
+				values[indexes[i]], found[indexes[i]] = value, true
+			}
+		}
+	}
+
+	client := r.client(ctx)
+
+	cluster, ok := client.(*redis.ClusterClient)
+	if !ok {
+		replies, err := client.MGet(fixedKeys...).Result()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "get multiple keys failed")
+		}
+
+		indexes := make([]int, len(keys))
+		for i := range indexes {
+			indexes[i] = i
+		}
+
+		set(indexes, replies)
+
+		return values, found, nil
+	}
+
+	// a MGET can not span several hash slots in cluster mode.
+	var slots []int
+
+	indexesBySlot := make(map[int][]int)
+	for i, key := range fixedKeys {
+		slot := keySlot(key)
+		if _, ok := indexesBySlot[slot]; !ok {
+			slots = append(slots, slot)
+		}
+
+		indexesBySlot[slot] = append(indexesBySlot[slot], i)
+	}
+
+	pipe := cluster.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(slots))
+
+	for i, slot := range slots {
+		slotKeys := make([]string, 0, len(indexesBySlot[slot]))
+		for _, index := range indexesBySlot[slot] {
+			slotKeys = append(slotKeys, fixedKeys[index])
+		}
+
+		cmds[i] = pipe.MGet(slotKeys...)
+	}
+
+	if _, err := pipe.Exec(); err != nil {
+		return nil, nil, errors.Wrap(err, "get multiple keys failed")
+	}
+
+	for i, slot := range slots {
+		set(indexesBySlot[slot], cmds[i].Val())
+	}
+
+	return values, found, nil
+}
+
+// Pipe queues the commands of a pipeline, see RedisCluster.Pipeline.
+type Pipe struct {
+	redis.Pipeliner
+	r *RedisCluster
+}
+
+// Key returns the name in redis of keyName, prefixed like the other keys of the RedisCluster.
+func (p *Pipe) Key(keyName string) string {
+	return p.r.fixKey(keyName)
+}
+
+// RawKey returns the name in redis of keyName, which is not prefixed by the RedisCluster.KeyPrefix.
+func (p *Pipe) RawKey(keyName string) string {
+	return rawKey(keyName)
+}
+
+// Pipeline queues the commands of fn and sends them in one round trip per redis node, the
+// commands are not sent if fn returns an error. The keys of the commands must be named with
+// Pipe.Key or Pipe.RawKey. In cluster mode each command is routed to the node serving its key,
+// so the keys of a command with several keys must be in the same hash slot.
+//
+// The commands are returned in the order they were queued, a missing key does not fail the
+// pipeline: the error of its command is redis.Nil. The error returned is the first other error.
+func (r *RedisCluster) Pipeline(ctx context.Context, fn func(pipe *Pipe) error) ([]redis.Cmder, error) {
+	if err := r.up(); err != nil {
+		return nil, err
+	}
+
+	pipe := &Pipe{Pipeliner: r.client(ctx).Pipeline(), r: r}
+	defer pipe.Close()
+
+	if err := fn(pipe); err != nil {
+		return nil, err
+	}
+
+	cmds, _ := pipe.Exec()
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil && !errors.Is(err, redis.Nil) {
+			return cmds, err
+		}
+	}
+
+	return cmds, nil
+}
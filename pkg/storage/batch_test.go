@@ -0,0 +1,313 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	redis "github.com/go-redis/redis/v7"
+)
+
+// kvServer is a redis server holding string values. In cluster mode, it rejects the MGETs of keys
+// in different hash slots like a redis cluster.
+type kvServer struct {
+	mu     sync.Mutex
+	values map[string]string
+	// mgets holds the keys of the MGET commands received
+	mgets   [][]string
+	cluster bool
+	addr    string
+}
+
+func bulkString(value string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+}
+
+func (s *kvServer) reply(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch strings.ToLower(args[0]) {
+	case "get":
+		value, ok := s.values[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+
+		return bulkString(value)
+	case "set":
+		s.values[args[1]] = args[2]
+
+		return "+OK\r\n"
+	case "mget":
+		s.mgets = append(s.mgets, args[1:])
+
+		if s.cluster && !sameSlot(args[1:]) {
+			return "-CROSSSLOT Keys in request don't hash to the same slot\r\n"
+		}
+
+		reply := "*" + strconv.Itoa(len(args)-1) + "\r\n"
+		for _, key := range args[1:] {
+			if value, ok := s.values[key]; ok {
+				reply += bulkString(value)
+			} else {
+				reply += "$-1\r\n"
+			}
+		}
+
+		return reply
+	case "cluster":
+		// a single master serves all the slots
+		host, port, _ := net.SplitHostPort(s.addr)
+
+		return fmt.Sprintf("*1\r\n*3\r\n:0\r\n:16383\r\n*2\r\n$%d\r\n%s\r\n:%s\r\n", len(host), host, port)
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func (s *kvServer) get(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.values[key]
+}
+
+func (s *kvServer) receivedMGets() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.mgets
+}
+
+// kvRedis starts a kvServer holding the values, used by the storage during the test or the benchmark.
+func kvRedis(tb testing.TB, cluster bool, values map[string]string) *kvServer {
+	tb.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("listen failed: %v", err)
+	}
+
+	server := &kvServer{values: values, cluster: cluster, addr: ln.Addr().String()}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				reader := bufio.NewReader(conn)
+				for {
+					args, err := readCommand(reader)
+					if err != nil {
+						return
+					}
+
+					_, _ = conn.Write([]byte(server.reply(args)))
+				}
+			}(conn)
+		}
+	}()
+
+	var client redis.UniversalClient = redis.NewClient(&redis.Options{Addr: server.addr})
+	if cluster {
+		client = redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{server.addr}})
+	}
+
+	useClient(tb, client)
+
+	tb.Cleanup(func() { ln.Close() })
+
+	return server
+}
+
+func TestRedisCluster_LookupMultiKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		cluster   bool
+		keys      []string
+		want      []string
+		wantFound []bool
+		wantMGets [][]string
+	}{
+		{
+			name:      "some missing",
+			keys:      []string{"a", "missing", "b"},
+			want:      []string{"1", "", "2"},
+			wantFound: []bool{true, false, true},
+			wantMGets: [][]string{{"iam-a", "iam-missing", "iam-b"}},
+		},
+		{
+			name:      "all missing",
+			keys:      []string{"missing", "other"},
+			want:      []string{"", ""},
+			wantFound: []bool{false, false},
+			wantMGets: [][]string{{"iam-missing", "iam-other"}},
+		},
+		{name: "no key", keys: []string{}, want: []string{}, wantFound: []bool{}},
+		{
+			name:      "cluster grouped by slot",
+			cluster:   true,
+			keys:      []string{"{user}.a", "a", "{user}.b", "missing", "b"},
+			want:      []string{"3", "1", "", "", "2"},
+			wantFound: []bool{true, true, false, false, true},
+			wantMGets: [][]string{{"iam-{user}.a", "iam-{user}.b"}, {"iam-a"}, {"iam-missing"}, {"iam-b"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := kvRedis(t, tt.cluster, map[string]string{"iam-a": "1", "iam-b": "2", "iam-{user}.a": "3"})
+
+			values, found, err := (&RedisCluster{KeyPrefix: "iam-"}).LookupMultiKey(context.Background(), tt.keys)
+			if err != nil {
+				t.Fatalf("LookupMultiKey() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(values, tt.want) || !reflect.DeepEqual(found, tt.wantFound) {
+				t.Errorf("LookupMultiKey() = %q, %v, want %q, %v", values, found, tt.want, tt.wantFound)
+			}
+
+			if got := server.receivedMGets(); !reflect.DeepEqual(got, tt.wantMGets) {
+				t.Errorf("MGETs = %q, want %q", got, tt.wantMGets)
+			}
+		})
+	}
+}
+
+func TestRedisCluster_GetMultiKey(t *testing.T) {
+	kvRedis(t, true, map[string]string{"iam-a": "1"})
+
+	r := &RedisCluster{KeyPrefix: "iam-"}
+
+	values, err := r.GetMultiKey(context.Background(), []string{"missing", "a"})
+	if err != nil || !reflect.DeepEqual(values, []string{"", "1"}) {
+		t.Errorf("GetMultiKey() = %q, %v, want [\"\" 1]", values, err)
+	}
+
+	if _, err := r.GetMultiKey(context.Background(), []string{"missing"}); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("GetMultiKey() error = %v, want %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestRedisCluster_Pipeline(t *testing.T) {
+	for _, cluster := range []bool{false, true} {
+		t.Run(fmt.Sprintf("cluster=%v", cluster), func(t *testing.T) {
+			server := kvRedis(t, cluster, map[string]string{"iam-a": "1"})
+			r := &RedisCluster{KeyPrefix: "iam-"}
+
+			var get, missing *redis.StringCmd
+
+			cmds, err := r.Pipeline(context.Background(), func(pipe *Pipe) error {
+				pipe.Set(pipe.RawKey("raw"), "2", 0)
+				get = pipe.Get(pipe.Key("a"))
+				missing = pipe.Get(pipe.Key("missing"))
+
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Pipeline() error = %v", err)
+			}
+
+			if len(cmds) != 3 || get.Val() != "1" || !errors.Is(missing.Err(), redis.Nil) {
+				t.Errorf("Pipeline() = %v, want 3 commands getting a and missing", cmds)
+			}
+
+			if value := server.get("raw"); value != "2" {
+				t.Errorf("raw = %q, want 2", value)
+			}
+
+			// the commands are not sent when fn fails.
+			errQueue := errors.New("queue failed")
+			if _, err := r.Pipeline(context.Background(), func(pipe *Pipe) error {
+				pipe.Set(pipe.Key("a"), "3", 0)
+
+				return errQueue
+			}); !errors.Is(err, errQueue) {
+				t.Errorf("Pipeline() error = %v, want %v", err, errQueue)
+			}
+
+			if _, err := r.Pipeline(context.Background(), func(pipe *Pipe) error {
+				pipe.Do("unknown")
+
+				return nil
+			}); err == nil {
+				t.Error("Pipeline() error = nil, want the error of the unknown command")
+			}
+
+			if value, _ := r.GetKey(context.Background(), "a"); value != "1" {
+				t.Errorf("a = %q, want 1", value)
+			}
+		})
+	}
+}
+
+// benchmarkKeys returns n keys and the server values holding them.
+func benchmarkKeys(n int) ([]string, map[string]string) {
+	keys := make([]string, n)
+	values := make(map[string]string, n)
+
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+		values["iam-"+keys[i]] = strconv.Itoa(i)
+	}
+
+	return keys, values
+}
+
+func BenchmarkRedisCluster_SequentialGetKey(b *testing.B) {
+	for _, n := range []int{10, 100} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			keys, values := benchmarkKeys(n)
+			kvRedis(b, false, values)
+			r := &RedisCluster{KeyPrefix: "iam-"}
+			ctx := context.Background()
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				for _, key := range keys {
+					if _, err := r.GetKey(ctx, key); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRedisCluster_LookupMultiKey(b *testing.B) {
+	for _, cluster := range []bool{false, true} {
+		for _, n := range []int{10, 100} {
+			b.Run(fmt.Sprintf("cluster=%v/%d", cluster, n), func(b *testing.B) {
+				keys, values := benchmarkKeys(n)
+				kvRedis(b, cluster, values)
+				r := &RedisCluster{KeyPrefix: "iam-"}
+				ctx := context.Background()
+
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					if _, _, err := r.LookupMultiKey(ctx, keys); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+	"github.com/marmotedu/errors"
+	"github.com/sony/gobreaker"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+var (
+	singleBreaker      atomic.Value
+	singleCacheBreaker atomic.Value
+)
+
+// circuitBreaker guards the commands sent through a connection pool. Once threshold consecutive
+// commands failed to reach redis, the commands fail fast with ErrRedisIsDown for timeout instead of
+// waiting for the dial timeout, then a trial command is let through which closes the breaker if it
+// succeeds. The breaker is a redis.Hook of the client of the pool.
+type circuitBreaker struct {
+	cb *gobreaker.TwoStepCircuitBreaker
+}
+
+var _ redis.Hook = (*circuitBreaker)(nil)
+
+// breakerDoneKey is the context key of the function reporting the result of a command to the breaker.
+type breakerDoneKey struct{}
+
+func newCircuitBreaker(pool string, threshold int, timeout time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		cb: gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
+			Name:    pool,
+			Timeout: timeout,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return counts.ConsecutiveFailures >= uint32(threshold)
+			},
+			OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+				log.Warnw("Redis circuit breaker state changed", "pool", name,
+					"from", from.String(), "to", to.String())
+				breakerTransitions.WithLabelValues(name, from.String(), to.String()).Inc()
+			},
+		}),
+	}
+}
+
+// breaker returns the circuit breaker of the pool, nil if the circuit breaker is disabled.
+func breaker(cache bool) *circuitBreaker {
+	v := singleBreaker.Load()
+	if cache {
+		v = singleCacheBreaker.Load()
+	}
+
+	if b, ok := v.(*circuitBreaker); ok {
+		return b
+	}
+
+	return nil
+}
+
+func storeBreaker(cache bool, b *circuitBreaker) {
+	if cache {
+		singleCacheBreaker.Store(b)
+
+		return
+	}
+
+	singleBreaker.Store(b)
+}
+
+// isOpen reports whether the commands fail fast, a half-open breaker lets the trial command through.
+func (b *circuitBreaker) isOpen() bool {
+	return b != nil && b.cb.State() == gobreaker.StateOpen
+}
+
+// reachedRedis reports whether redis answered the command. The errors replied by redis, like a
+// missing key or a wrong type, and the commands canceled by the caller do not trip the breaker.
+func reachedRedis(err error) bool {
+	var redisErr redis.Error
+
+	return err == nil || errors.Is(err, redis.Nil) || errors.Is(err, context.Canceled) || errors.As(err, &redisErr)
+}
+
+func (b *circuitBreaker) allow(ctx context.Context) (context.Context, error) {
+	done, err := b.cb.Allow()
+	if err != nil {
+		return ctx, ErrRedisIsDown
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return context.WithValue(ctx, breakerDoneKey{}, done), nil
+}
+
+func (b *circuitBreaker) report(ctx context.Context, cmds ...redis.Cmder) {
+	done, ok := ctx.Value(breakerDoneKey{}).(func(bool))
+	if !ok {
+		return
+	}
+
+	for _, cmd := range cmds {
+		if !reachedRedis(cmd.Err()) {
+			done(false)
+
+			return
+		}
+	}
+
+	done(true)
+}
+
+// BeforeProcess implements redis.Hook.
+func (b *circuitBreaker) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return b.allow(ctx)
+}
+
+// AfterProcess implements redis.Hook.
+func (b *circuitBreaker) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	b.report(ctx, cmd)
+
+	return nil
+}
+
+// BeforeProcessPipeline implements redis.Hook, a pipeline counts as one command.
+func (b *circuitBreaker) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return b.allow(ctx)
+}
+
+// AfterProcessPipeline implements redis.Hook.
+func (b *circuitBreaker) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	b.report(ctx, cmds...)
+
+	return nil
+}
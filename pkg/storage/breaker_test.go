@@ -0,0 +1,191 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+	"github.com/marmotedu/errors"
+)
+
+// flakyServer is a redis server which closes the connections while it is down, once up it replies
+// that the keys do not exist and that the messages are published to no subscriber.
+type flakyServer struct {
+	down  int32
+	conns int32
+}
+
+func (s *flakyServer) setDown(down bool) {
+	var v int32
+	if down {
+		v = 1
+	}
+
+	atomic.StoreInt32(&s.down, v)
+}
+
+// connections returns the number of connections accepted since the last call.
+func (s *flakyServer) connections() int32 {
+	return atomic.SwapInt32(&s.conns, 0)
+}
+
+func (s *flakyServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	atomic.AddInt32(&s.conns, 1)
+
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil || atomic.LoadInt32(&s.down) == 1 {
+			return
+		}
+
+		reply := "$-1\r\n"
+		if strings.EqualFold(args[0], "publish") {
+			reply = ":0\r\n"
+		}
+
+		_, _ = conn.Write([]byte(reply))
+	}
+}
+
+// flakyRedis starts a flakyServer, which is down, used through a circuit breaker during the test.
+func flakyRedis(t *testing.T, threshold int, timeout time.Duration) *flakyServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	server := &flakyServer{down: 1}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go server.serve(conn)
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: ln.Addr().String()})
+	b := newCircuitBreaker("default", threshold, timeout)
+	client.AddHook(b)
+	storeBreaker(false, b)
+	useClient(t, client)
+
+	t.Cleanup(func() {
+		ln.Close()
+		storeBreaker(false, nil)
+	})
+
+	return server
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	server := flakyRedis(t, 3, 100*time.Millisecond)
+	ctx := context.Background()
+	r := &RedisCluster{}
+
+	// the commands failing to reach redis open the breaker.
+	for i := 0; i < 3; i++ {
+		if _, err := r.GetKey(ctx, "key"); !errors.Is(err, ErrKeyNotFound) {
+			t.Fatalf("GetKey() error = %v, want %v", err, ErrKeyNotFound)
+		}
+	}
+
+	if !breaker(false).isOpen() {
+		t.Fatal("the circuit breaker is closed after 3 failures, want open")
+	}
+
+	server.connections()
+
+	// the auth and notification paths fail fast while the breaker is open.
+	if _, err := r.GetKey(ctx, "key"); !errors.Is(err, ErrRedisIsDown) {
+		t.Errorf("GetKey() error = %v, want %v", err, ErrRedisIsDown)
+	}
+
+	if _, err := r.GetMultiKey(ctx, []string{"a", "b"}); !errors.Is(err, ErrRedisIsDown) {
+		t.Errorf("GetMultiKey() error = %v, want %v", err, ErrRedisIsDown)
+	}
+
+	if err := r.Publish(ctx, "channel", "message"); !errors.Is(err, ErrRedisIsDown) {
+		t.Errorf("Publish() error = %v, want %v", err, ErrRedisIsDown)
+	}
+
+	if err := r.StartPubSubHandler(ctx, "channel", func(interface{}) {}); !errors.Is(err, ErrRedisIsDown) {
+		t.Errorf("StartPubSubHandler() error = %v, want %v", err, ErrRedisIsDown)
+	}
+
+	// the commands sent directly through the client are rejected by the hook.
+	if err := r.client(ctx).Get("key").Err(); !errors.Is(err, ErrRedisIsDown) {
+		t.Errorf("Get() error = %v, want %v", err, ErrRedisIsDown)
+	}
+
+	if n := server.connections(); n != 0 {
+		t.Errorf("%d connections while the breaker is open, want none", n)
+	}
+
+	// once redis is back, the trial command closes the breaker.
+	server.setDown(false)
+	time.Sleep(150 * time.Millisecond)
+
+	if err := r.Publish(ctx, "channel", "message"); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	if breaker(false).isOpen() {
+		t.Error("the circuit breaker is open after a successful trial command, want closed")
+	}
+}
+
+func TestCircuitBreaker_RedisErrors(t *testing.T) {
+	server := flakyRedis(t, 1, time.Minute)
+	server.setDown(false)
+
+	// a missing key is an answer of redis, it does not trip the breaker.
+	for i := 0; i < 3; i++ {
+		if err := (&RedisCluster{}).client(context.Background()).Get("key").Err(); !errors.Is(err, redis.Nil) {
+			t.Fatalf("Get() error = %v, want %v", err, redis.Nil)
+		}
+	}
+
+	if breaker(false).isOpen() {
+		t.Error("the circuit breaker is open, want closed")
+	}
+}
+
+func TestReachedRedis(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no error", want: true},
+		{name: "nil reply", err: redis.Nil, want: true},
+		{name: "canceled", err: context.Canceled, want: true},
+		{name: "down", err: ErrRedisIsDown, want: false},
+		{name: "deadline", err: context.DeadlineExceeded, want: false},
+		{name: "network", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reachedRedis(tt.err); got != tt.want {
+				t.Errorf("reachedRedis() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
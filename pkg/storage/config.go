@@ -0,0 +1,55 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"fmt"
+)
+
+// Validate checks that the options of the config can be used together and returns the found errs,
+// which name the --redis.* flags of the fields. The combinations redis would reject, or which would
+// be silently ignored, are reported so that the servers refuse to start.
+func (c *Config) Validate() []error {
+	var errs []error
+
+	if c.Type != "" && c.Type != TypeRedis && c.Type != TypeMemory {
+		errs = append(errs, fmt.Errorf("--redis.type %q must be %s or %s", c.Type, TypeRedis, TypeMemory))
+	}
+
+	if c.Database < 0 {
+		errs = append(errs, fmt.Errorf("--redis.database %d must not be negative", c.Database))
+	}
+
+	if c.EnableCluster && c.Database != 0 {
+		errs = append(errs, fmt.Errorf("--redis.database %d can not be used with --redis.enable-cluster, "+
+			"redis cluster only supports the database 0", c.Database))
+	}
+
+	// the sentinel-backed failover client would be created and the cluster mode ignored.
+	if c.EnableCluster && c.MasterName != "" {
+		errs = append(errs, fmt.Errorf("--redis.master-name %q can not be used with --redis.enable-cluster, "+
+			"redis sentinel and redis cluster are exclusive", c.MasterName))
+	}
+
+	if c.MasterName == "" && (len(c.SentinelAddrs) > 0 || c.SentinelUsername != "" || c.SentinelPassword != "") {
+		errs = append(errs, fmt.Errorf("--redis.sentinel-addrs, --redis.sentinel-username and "+
+			"--redis.sentinel-password require --redis.master-name"))
+	}
+
+	if c.CircuitBreakerThreshold < 0 {
+		errs = append(errs, fmt.Errorf("--redis.circuit-breaker-threshold %d cannot be negative", c.CircuitBreakerThreshold))
+	}
+
+	if c.CircuitBreakerThreshold > 0 && c.CircuitBreakerTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("--redis.circuit-breaker-timeout %v must be greater than 0 with "+
+			"--redis.circuit-breaker-threshold %d", c.CircuitBreakerTimeout, c.CircuitBreakerThreshold))
+	}
+
+	if err := ValidateKeyPrefix(c.KeyPrefix); err != nil {
+		errs = append(errs, fmt.Errorf("--redis.key-prefix %q: %w", c.KeyPrefix, err))
+	}
+
+	return errs
+}
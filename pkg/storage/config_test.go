@@ -0,0 +1,102 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		// wantErrs are substrings of the errors, in order
+		wantErrs []string
+	}{
+		{name: "single node", config: Config{Host: "127.0.0.1", Port: 6379, Database: 1}},
+		{name: "cluster", config: Config{Addrs: []string{"127.0.0.1:7000"}, EnableCluster: true}},
+		{
+			name:   "sentinel",
+			config: Config{MasterName: "mymaster", SentinelAddrs: []string{"127.0.0.1:26379"}, Database: 1},
+		},
+		{name: "memory", config: Config{Type: TypeMemory}},
+		{
+			name:     "unknown type",
+			config:   Config{Type: "etcd"},
+			wantErrs: []string{`--redis.type "etcd" must be redis or memory`},
+		},
+		{
+			name:     "negative database",
+			config:   Config{Database: -1},
+			wantErrs: []string{"--redis.database -1 must not be negative"},
+		},
+		{
+			name:     "cluster with database",
+			config:   Config{EnableCluster: true, Database: 1},
+			wantErrs: []string{"--redis.database 1 can not be used with --redis.enable-cluster"},
+		},
+		{
+			name:     "cluster with master name",
+			config:   Config{EnableCluster: true, MasterName: "mymaster"},
+			wantErrs: []string{`--redis.master-name "mymaster" can not be used with --redis.enable-cluster`},
+		},
+		{
+			name:     "sentinel addrs without master name",
+			config:   Config{SentinelAddrs: []string{"127.0.0.1:26379"}},
+			wantErrs: []string{"require --redis.master-name"},
+		},
+		{
+			name:     "sentinel password without master name",
+			config:   Config{SentinelPassword: "secret"},
+			wantErrs: []string{"require --redis.master-name"},
+		},
+		{
+			name:     "negative circuit breaker threshold",
+			config:   Config{CircuitBreakerThreshold: -1},
+			wantErrs: []string{"--redis.circuit-breaker-threshold -1 cannot be negative"},
+		},
+		{
+			name:     "circuit breaker without timeout",
+			config:   Config{CircuitBreakerThreshold: 5},
+			wantErrs: []string{"--redis.circuit-breaker-timeout 0s must be greater than 0"},
+		},
+		{
+			name:     "invalid key prefix",
+			config:   Config{KeyPrefix: "prod*"},
+			wantErrs: []string{"--redis.key-prefix"},
+		},
+		{
+			name: "all aggregated",
+			config: Config{
+				EnableCluster:           true,
+				Database:                2,
+				MasterName:              "mymaster",
+				CircuitBreakerThreshold: 5,
+				CircuitBreakerTimeout:   -time.Second,
+			},
+			wantErrs: []string{
+				"--redis.database 2 can not be used with --redis.enable-cluster",
+				"--redis.master-name \"mymaster\" can not be used with --redis.enable-cluster",
+				"--redis.circuit-breaker-timeout -1s must be greater than 0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.config.Validate()
+			if len(errs) != len(tt.wantErrs) {
+				t.Fatalf("Validate() = %v, want %d errors", errs, len(tt.wantErrs))
+			}
+
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), tt.wantErrs[i]) {
+					t.Errorf("Validate() error %d = %q, want it to contain %q", i, err, tt.wantErrs[i])
+				}
+			}
+		})
+	}
+}
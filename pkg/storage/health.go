@@ -0,0 +1,92 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// Ping sends a PING to redis, it returns an error if redis does not reply before ctx is done.
+// Unlike HealthCheck, which reports the state of the last background connection attempt,
+// every call checks redis again.
+func (r *RedisCluster) Ping(ctx context.Context) error {
+	if memoryStorage() {
+		return nil
+	}
+
+	if r.singleton() == nil {
+		return ErrRedisIsDown
+	}
+
+	if err := r.client(ctx).Ping().Err(); err != nil {
+		return errors.Wrap(err, "ping redis failed")
+	}
+
+	return nil
+}
+
+// LogHealth pings redis and logs the stats of the connection pools every interval until ctx is
+// done. A warning is logged when redis does not reply or when callers timed out waiting for a free
+// connection since the last check, which means the pool is exhausted.
+func LogHealth(ctx context.Context, interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	lastTimeouts := make([]uint32, len(pools))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			for i := range pools {
+				lastTimeouts[i] = logPoolHealth(ctx, pools[i], interval, lastTimeouts[i])
+			}
+		}
+	}
+}
+
+// logPoolHealth logs the health of a pool and returns its timeouts, so that the next check
+// only reports the new ones.
+func logPoolHealth(ctx context.Context, r RedisCluster, timeout time.Duration, lastTimeouts uint32) uint32 {
+	stats := r.PoolStats()
+	if stats == nil {
+		return lastTimeouts
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	keysAndValues := []interface{}{
+		"pool", poolName(r),
+		"hits", stats.Hits,
+		"misses", stats.Misses,
+		"timeouts", stats.Timeouts,
+		"totalConns", stats.TotalConns,
+		"idleConns", stats.IdleConns,
+	}
+
+	if err := r.Ping(ctx); err != nil {
+		log.Warnw("Redis is unhealthy", append(keysAndValues, "error", err.Error())...)
+
+		return stats.Timeouts
+	}
+
+	if stats.Timeouts > lastTimeouts {
+		log.Warnw("Redis connection pool is exhausted, increase --redis.optimisation-max-active",
+			append(keysAndValues, "newTimeouts", stats.Timeouts-lastTimeouts)...)
+
+		return stats.Timeouts
+	}
+
+	log.Debugw("Redis is healthy", keysAndValues...)
+
+	return stats.Timeouts
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+)
+
+// pongRedis starts a server which replies PONG to every command.
+func pongRedis(t *testing.T) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				reader := bufio.NewReader(conn)
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+
+					// the last line of a command is its last argument.
+					if strings.EqualFold(strings.TrimSpace(line), "ping") {
+						_, _ = conn.Write([]byte("+PONG\r\n"))
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	useClient(t, redis.NewClient(&redis.Options{Addr: ln.Addr().String()}))
+
+	t.Cleanup(func() { ln.Close() })
+}
+
+func TestRedisCluster_Ping(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T)
+		wantErr bool
+	}{
+		{
+			name:  "pong",
+			setup: pongRedis,
+		},
+		{
+			name:    "no reply",
+			setup:   blockedRedis,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup(t)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			err := (&RedisCluster{}).Ping(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Ping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedisCluster_Ping_NotConnected(t *testing.T) {
+	singlePool = atomic.Value{}
+
+	if err := (&RedisCluster{}).Ping(context.Background()); !errors.Is(err, ErrRedisIsDown) {
+		t.Errorf("Ping() error = %v, want %v", err, ErrRedisIsDown)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+	"github.com/marmotedu/errors"
+)
+
+// CommandInfo describes a redis command sent by the storage. A pipeline is reported as a single
+// command named "pipeline", which accesses the keys of all its commands.
+type CommandInfo struct {
+	// Name is the lower-case name of the command, e.g. "get".
+	Name string
+	// Keys is the number of keys accessed by the command.
+	Keys int
+	// Duration and Err are set once the command completed. redis.Nil, the reply of the missing keys,
+	// is not an error.
+	Duration time.Duration
+	Err      error
+}
+
+// Hook observes the redis commands of the storage, e.g. to time or to trace them. The hooks are
+// called for every command, they must be fast and safe for concurrent use.
+type Hook interface {
+	// BeforeCommand is called before the command is sent, the returned context is passed to
+	// AfterCommand, e.g. to carry a span.
+	BeforeCommand(ctx context.Context, cmd *CommandInfo) context.Context
+	// AfterCommand is called once the reply is received or the command failed.
+	AfterCommand(ctx context.Context, cmd *CommandInfo)
+}
+
+var (
+	hooksMu sync.Mutex
+	// hooks holds the registered []Hook, it is replaced on each registration.
+	hooks atomic.Value
+)
+
+// AddHook registers a hook for the commands of all the connection pools, including the pools already
+// connected. Hooks cannot be removed.
+func AddHook(hook Hook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+
+	current := loadHooks()
+	next := make([]Hook, len(current), len(current)+1)
+	copy(next, current)
+	hooks.Store(append(next, hook))
+}
+
+func loadHooks() []Hook {
+	hs, _ := hooks.Load().([]Hook)
+
+	return hs
+}
+
+// commandHook is the redis.Hook of the client of each pool calling the registered hooks. It is added
+// after the circuit breaker, the commands rejected by the breaker are not observed.
+type commandHook struct{}
+
+var _ redis.Hook = commandHook{}
+
+// observedCommandKey is the context key of the command being observed.
+type observedCommandKey struct{}
+
+type observedCommand struct {
+	CommandInfo
+	start time.Time
+	hooks []Hook
+}
+
+// BeforeProcess implements redis.Hook.
+func (commandHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	hs := loadHooks()
+	if len(hs) == 0 {
+		return ctx, nil
+	}
+
+	name := cmd.Name()
+
+	return beforeCommand(ctx, hs, name, commandKeyCount(name, cmd.Args())), nil
+}
+
+// AfterProcess implements redis.Hook.
+func (commandHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	// hooks are never removed, none was called before the command if none is registered now.
+	if len(loadHooks()) == 0 {
+		return nil
+	}
+
+	afterCommand(ctx, cmd.Err())
+
+	return nil
+}
+
+// BeforeProcessPipeline implements redis.Hook, a pipeline counts as one command.
+func (commandHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	hs := loadHooks()
+	if len(hs) == 0 {
+		return ctx, nil
+	}
+
+	keys := 0
+	for _, cmd := range cmds {
+		keys += commandKeyCount(cmd.Name(), cmd.Args())
+	}
+
+	return beforeCommand(ctx, hs, "pipeline", keys), nil
+}
+
+// AfterProcessPipeline implements redis.Hook, the error of the pipeline is the first error of its
+// commands.
+func (commandHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	if len(loadHooks()) == 0 {
+		return nil
+	}
+
+	var err error
+	for _, cmd := range cmds {
+		if cmdErr := cmd.Err(); cmdErr != nil && !errors.Is(cmdErr, redis.Nil) {
+			err = cmdErr
+
+			break
+		}
+	}
+
+	afterCommand(ctx, err)
+
+	return nil
+}
+
+// observeCommand calls the hooks around fn, for the commands which are not sent through the hooks of
+// the client, e.g. the pub/sub ones.
+func observeCommand(ctx context.Context, name string, fn func() error) error {
+	hs := loadHooks()
+	if len(hs) == 0 {
+		return fn()
+	}
+
+	ctx = beforeCommand(ctx, hs, name, 0)
+	err := fn()
+	afterCommand(ctx, err)
+
+	return err
+}
+
+func beforeCommand(ctx context.Context, hs []Hook, name string, keys int) context.Context {
+	c := &observedCommand{CommandInfo: CommandInfo{Name: name, Keys: keys}, hooks: hs}
+
+	for _, h := range hs {
+		ctx = h.BeforeCommand(ctx, &c.CommandInfo)
+	}
+
+	c.start = time.Now()
+
+	return context.WithValue(ctx, observedCommandKey{}, c)
+}
+
+func afterCommand(ctx context.Context, err error) {
+	c, ok := ctx.Value(observedCommandKey{}).(*observedCommand)
+	if !ok {
+		return
+	}
+
+	c.Duration = time.Since(c.start)
+	if !errors.Is(err, redis.Nil) {
+		c.Err = err
+	}
+
+	for _, h := range c.hooks {
+		h.AfterCommand(ctx, &c.CommandInfo)
+	}
+}
+
+// commandKeyCount returns the number of keys accessed by the command, the commands without key are the
+// ones of the connection, the server and the pub/sub.
+func commandKeyCount(name string, args []interface{}) int {
+	if len(args) < 2 {
+		return 0
+	}
+
+	switch name {
+	case "auth", "client", "cluster", "config", "dbsize", "echo", "flushall", "flushdb", "hello", "info",
+		"keys", "ping", "psubscribe", "publish", "scan", "script", "select", "subscribe":
+		return 0
+	case "del", "exists", "mget", "touch", "unlink", "watch":
+		return len(args) - 1
+	case "mset", "msetnx":
+		return (len(args) - 1) / 2
+	case "eval", "evalsha":
+		// eval script numkeys key [key ...] arg [arg ...]
+		if len(args) > 2 {
+			if n, ok := args[2].(int); ok {
+				return n
+			}
+		}
+
+		return 0
+	default:
+		return 1
+	}
+}
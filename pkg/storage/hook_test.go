@@ -0,0 +1,187 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	redis "github.com/go-redis/redis/v7"
+)
+
+// recordingHook records the commands it observed, checking that the context returned by BeforeCommand
+// is passed to AfterCommand.
+type recordingHook struct {
+	t        *testing.T
+	commands []CommandInfo
+}
+
+type recordingHookKey struct{}
+
+func (h *recordingHook) BeforeCommand(ctx context.Context, cmd *CommandInfo) context.Context {
+	return context.WithValue(ctx, recordingHookKey{}, cmd.Name)
+}
+
+func (h *recordingHook) AfterCommand(ctx context.Context, cmd *CommandInfo) {
+	if name, _ := ctx.Value(recordingHookKey{}).(string); name != cmd.Name {
+		h.t.Errorf("AfterCommand() context of %q, want %q", name, cmd.Name)
+	}
+
+	if cmd.Duration < 0 {
+		h.t.Errorf("AfterCommand() duration = %v", cmd.Duration)
+	}
+
+	info := *cmd
+	info.Duration = 0
+	h.commands = append(h.commands, info)
+}
+
+// useHooks replaces the registered hooks for the duration of the test.
+func useHooks(t *testing.T, hs ...Hook) {
+	t.Helper()
+
+	previous := loadHooks()
+	hooks.Store([]Hook(nil))
+
+	for _, h := range hs {
+		AddHook(h)
+	}
+
+	t.Cleanup(func() { hooks.Store(previous) })
+}
+
+func withErr(cmd redis.Cmder, err error) redis.Cmder {
+	cmd.SetErr(err)
+
+	return cmd
+}
+
+func TestCommandHook(t *testing.T) {
+	errTimeout := errors.New("i/o timeout")
+
+	tests := []struct {
+		name string
+		cmds []redis.Cmder
+		want CommandInfo
+	}{
+		{
+			name: "single key",
+			cmds: []redis.Cmder{redis.NewStringCmd("get", "k")},
+			want: CommandInfo{Name: "get", Keys: 1},
+		},
+		{
+			name: "missing key",
+			cmds: []redis.Cmder{withErr(redis.NewStringCmd("get", "k"), redis.Nil)},
+			want: CommandInfo{Name: "get", Keys: 1},
+		},
+		{
+			name: "multiple keys",
+			cmds: []redis.Cmder{redis.NewIntCmd("del", "k1", "k2", "k3")},
+			want: CommandInfo{Name: "del", Keys: 3},
+		},
+		{
+			name: "script",
+			cmds: []redis.Cmder{redis.NewCmd("evalsha", "sha", 2, "k1", "k2", "arg")},
+			want: CommandInfo{Name: "evalsha", Keys: 2},
+		},
+		{
+			name: "without key",
+			cmds: []redis.Cmder{redis.NewStatusCmd("ping")},
+			want: CommandInfo{Name: "ping"},
+		},
+		{
+			name: "failed",
+			cmds: []redis.Cmder{withErr(redis.NewStatusCmd("set", "k", "v"), errTimeout)},
+			want: CommandInfo{Name: "set", Keys: 1, Err: errTimeout},
+		},
+		{
+			name: "pipeline",
+			cmds: []redis.Cmder{
+				withErr(redis.NewStringCmd("get", "k1"), redis.Nil),
+				withErr(redis.NewSliceCmd("mget", "k2", "k3"), errTimeout),
+			},
+			want: CommandInfo{Name: "pipeline", Keys: 3, Err: errTimeout},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &recordingHook{t: t}
+			useHooks(t, h)
+
+			ctx := context.Background()
+			hook := commandHook{}
+
+			if len(tt.cmds) == 1 {
+				ctx, _ = hook.BeforeProcess(ctx, tt.cmds[0])
+				_ = hook.AfterProcess(ctx, tt.cmds[0])
+			} else {
+				ctx, _ = hook.BeforeProcessPipeline(ctx, tt.cmds)
+				_ = hook.AfterProcessPipeline(ctx, tt.cmds)
+			}
+
+			if want := []CommandInfo{tt.want}; !reflect.DeepEqual(h.commands, want) {
+				t.Errorf("hook observed %+v, want %+v", h.commands, want)
+			}
+		})
+	}
+}
+
+func TestObserveCommand(t *testing.T) {
+	h := &recordingHook{t: t}
+	useHooks(t, h)
+
+	errClosed := errors.New("use of closed network connection")
+	err := observeCommand(context.Background(), "subscribe", func() error { return errClosed })
+
+	if !errors.Is(err, errClosed) {
+		t.Errorf("observeCommand() error = %v, want %v", err, errClosed)
+	}
+
+	if want := []CommandInfo{{Name: "subscribe", Err: errClosed}}; !reflect.DeepEqual(h.commands, want) {
+		t.Errorf("hook observed %+v, want %+v", h.commands, want)
+	}
+}
+
+// nopHook observes nothing, it measures the cost of calling the hooks.
+type nopHook struct{}
+
+func (nopHook) BeforeCommand(ctx context.Context, cmd *CommandInfo) context.Context { return ctx }
+
+func (nopHook) AfterCommand(ctx context.Context, cmd *CommandInfo) {}
+
+func BenchmarkCommandHook(b *testing.B) {
+	benchmarks := []struct {
+		name  string
+		hooks []Hook
+	}{
+		{name: "no hook"},
+		{name: "one hook", hooks: []Hook{nopHook{}}},
+	}
+	for _, bb := range benchmarks {
+		b.Run(bb.name, func(b *testing.B) {
+			previous := loadHooks()
+			hooks.Store([]Hook(nil))
+			defer hooks.Store(previous)
+
+			for _, h := range bb.hooks {
+				AddHook(h)
+			}
+
+			ctx := context.Background()
+			cmd := redis.NewStringCmd("get", "k")
+			hook := commandHook{}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				hctx, _ := hook.BeforeProcess(ctx, cmd)
+				_ = hook.AfterProcess(hctx, cmd)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/marmotedu/errors"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// ErrLockNotHeld is returned when a lock is released or renewed while it is not held anymore: it
+// expired, and may have been acquired by another owner meanwhile.
+var ErrLockNotHeld = errors.New("storage: the lock is not held")
+
+// releaseLockScript deletes the lock only if it is still held with the token of the caller.
+var releaseLockScript = NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// renewLockScript extends the ttl of the lock, in milliseconds, only if it is still held with the
+// token of the caller.
+var renewLockScript = NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Lock is a lock held by at most one owner at a time, until it is released or its ttl elapses. The
+// lock is a key set with a random token, so that only its owner releases or renews it.
+//
+// The lock is not fencing, it must not be relied upon for correctness:
+//   - an owner paused for longer than the ttl, by a GC or a network partition, still believes it holds
+//     the lock while another owner acquired it. The protected work must be idempotent or checked by the
+//     resource it modifies.
+//   - redis replicates asynchronously, a lock acquired on a master which fails over before replicating
+//     it can be acquired again on the new master.
+//
+// It is meant for efficiency, e.g. so that a single instance runs a periodic job.
+type Lock struct {
+	store *RedisCluster
+	key   string
+	token string
+	ttl   time.Duration
+
+	mu sync.Mutex
+	// stop stops the keep-alive goroutine, done is closed once it returned.
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewLock returns the lock key, held for ttl once acquired, ttl must be positive. Each Lock has its
+// own token, so the Locks of the same key compete with each other.
+func (r *RedisCluster) NewLock(key string, ttl time.Duration) *Lock {
+	return &Lock{
+		store: r,
+		key:   key,
+		token: uuid.Must(uuid.NewV4()).String(),
+		ttl:   ttl,
+	}
+}
+
+// Acquire acquires the lock if it is not held, and returns whether it was acquired. Acquiring a lock
+// already held by l fails too.
+func (l *Lock) Acquire(ctx context.Context) (bool, error) {
+	return l.store.SetKeyIfNotExists(ctx, l.key, l.token, l.ttl)
+}
+
+// Release stops keeping the lock alive and releases it, ErrLockNotHeld is returned if it was not held
+// by l anymore.
+func (l *Lock) Release(ctx context.Context) error {
+	l.stopKeepAlive()
+
+	if m, ok := l.store.memory(); ok {
+		if !memory.compareAndDelete(m.fixKey(l.key), l.token) {
+			return ErrLockNotHeld
+		}
+
+		return nil
+	}
+
+	return l.run(ctx, releaseLockScript)
+}
+
+// Renew resets the ttl of the lock, ErrLockNotHeld is returned if it was not held by l anymore.
+func (l *Lock) Renew(ctx context.Context) error {
+	if m, ok := l.store.memory(); ok {
+		if !memory.compareAndExpire(m.fixKey(l.key), l.token, l.ttl) {
+			return ErrLockNotHeld
+		}
+
+		return nil
+	}
+
+	return l.run(ctx, renewLockScript, l.ttl.Milliseconds())
+}
+
+func (l *Lock) run(ctx context.Context, script *Script, args ...interface{}) error {
+	args = append([]interface{}{l.token}, args...)
+
+	reply, err := l.store.ScriptRunner().Run(ctx, script, []string{l.key}, args...)
+	if err != nil {
+		return err
+	}
+
+	if n, _ := reply.(int64); n == 0 {
+		return ErrLockNotHeld
+	}
+
+	return nil
+}
+
+// KeepAlive renews the lock every third of its ttl, until it is released or ctx is done. The returned
+// channel is closed if the lock is lost: it was not held by l anymore, or it could not be renewed for
+// its whole ttl. The work protected by the lock must then stop.
+func (l *Lock) KeepAlive(ctx context.Context) <-chan struct{} {
+	l.stopKeepAlive()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lost := make(chan struct{})
+	stop, done := make(chan struct{}), make(chan struct{})
+	l.stop, l.done = stop, done
+
+	go func() {
+		defer close(done)
+
+		tick := time.NewTicker(l.ttl / 3)
+		defer tick.Stop()
+
+		renewed := time.Now()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			case <-tick.C:
+			}
+
+			err := l.Renew(ctx)
+			if err == nil {
+				renewed = time.Now()
+
+				continue
+			}
+
+			if errors.Is(err, ErrLockNotHeld) || time.Since(renewed) >= l.ttl {
+				log.Warnf("Lock %s lost: %s", l.key, err.Error())
+				close(lost)
+
+				return
+			}
+
+			log.Warnf("Failed to renew lock %s, retry: %s", l.key, err.Error())
+		}
+	}()
+
+	return lost
+}
+
+// stopKeepAlive stops the keep-alive goroutine, if any, and waits for it.
+func (l *Lock) stopKeepAlive() {
+	l.mu.Lock()
+	stop, done := l.stop, l.done
+	l.stop, l.done = nil, nil
+	l.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+	<-done
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+)
+
+// testLock checks the locks of r against two competing owners.
+func testLock(t *testing.T, r *RedisCluster) {
+	ctx := context.Background()
+
+	t.Run("competing", func(t *testing.T) {
+		var (
+			holders  int32
+			acquired int32
+			wg       sync.WaitGroup
+		)
+
+		for i := 0; i < 2; i++ {
+			lock := r.NewLock("competing", time.Minute)
+
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				for j := 0; j < 50; j++ {
+					ok, err := lock.Acquire(ctx)
+					if err != nil {
+						t.Errorf("Acquire() error = %v", err)
+
+						return
+					}
+
+					if !ok {
+						time.Sleep(time.Millisecond)
+
+						continue
+					}
+
+					atomic.AddInt32(&acquired, 1)
+
+					if n := atomic.AddInt32(&holders, 1); n != 1 {
+						t.Errorf("%d owners hold the lock", n)
+					}
+
+					time.Sleep(time.Millisecond)
+					atomic.AddInt32(&holders, -1)
+
+					if err := lock.Release(ctx); err != nil {
+						t.Errorf("Release() error = %v", err)
+					}
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		if acquired == 0 {
+			t.Error("the lock was never acquired")
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		first, second := r.NewLock("expired", 100*time.Millisecond), r.NewLock("expired", time.Minute)
+
+		if ok, err := first.Acquire(ctx); err != nil || !ok {
+			t.Fatalf("Acquire() = %v, %v, want true", ok, err)
+		}
+
+		if ok, _ := first.Acquire(ctx); ok {
+			t.Error("Acquire() of a held lock = true, want false")
+		}
+
+		time.Sleep(200 * time.Millisecond)
+
+		if ok, err := second.Acquire(ctx); err != nil || !ok {
+			t.Fatalf("Acquire() of an expired lock = %v, %v, want true", ok, err)
+		}
+
+		// the first owner does not release nor renew the lock acquired by the second one.
+		if err := first.Renew(ctx); !errors.Is(err, ErrLockNotHeld) {
+			t.Errorf("Renew() of a lost lock error = %v, want ErrLockNotHeld", err)
+		}
+
+		if err := first.Release(ctx); !errors.Is(err, ErrLockNotHeld) {
+			t.Errorf("Release() of a lost lock error = %v, want ErrLockNotHeld", err)
+		}
+
+		if err := second.Release(ctx); err != nil {
+			t.Errorf("Release() error = %v", err)
+		}
+	})
+
+	t.Run("keep alive", func(t *testing.T) {
+		first, second := r.NewLock("alive", 150*time.Millisecond), r.NewLock("alive", time.Minute)
+
+		if ok, err := first.Acquire(ctx); err != nil || !ok {
+			t.Fatalf("Acquire() = %v, %v, want true", ok, err)
+		}
+
+		lost := first.KeepAlive(ctx)
+
+		time.Sleep(400 * time.Millisecond)
+
+		if ok, _ := second.Acquire(ctx); ok {
+			t.Error("Acquire() of a lock kept alive = true, want false")
+		}
+
+		select {
+		case <-lost:
+			t.Fatal("the lock kept alive was lost")
+		default:
+		}
+
+		if err := first.Release(ctx); err != nil {
+			t.Errorf("Release() error = %v", err)
+		}
+
+		if ok, err := second.Acquire(ctx); err != nil || !ok {
+			t.Errorf("Acquire() of a released lock = %v, %v, want true", ok, err)
+		}
+
+		_ = second.Release(ctx)
+	})
+
+	t.Run("lost", func(t *testing.T) {
+		lock := r.NewLock("lost", 150*time.Millisecond)
+
+		if ok, err := lock.Acquire(ctx); err != nil || !ok {
+			t.Fatalf("Acquire() = %v, %v, want true", ok, err)
+		}
+
+		lost := lock.KeepAlive(ctx)
+		defer lock.Release(ctx) //nolint: errcheck
+
+		// another owner broke the lock.
+		r.DeleteKey(ctx, "lost")
+
+		select {
+		case <-lost:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the lock broken by another owner was not lost")
+		}
+	})
+}
+
+func TestLock_Memory(t *testing.T) {
+	useMemory(t)
+
+	testLock(t, &RedisCluster{KeyPrefix: "lock-"})
+}
+
+// TestLock_Redis runs the lock checks against the redis at IAM_TEST_REDIS_ADDR.
+func TestLock_Redis(t *testing.T) {
+	addr := os.Getenv("IAM_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("IAM_TEST_REDIS_ADDR is not set")
+	}
+
+	useClient(t, redis.NewClient(&redis.Options{Addr: addr}))
+
+	testLock(t, &RedisCluster{KeyPrefix: "lock-" + strconv.FormatInt(time.Now().UnixNano(), 10) + "-"})
+}
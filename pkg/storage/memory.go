@@ -0,0 +1,620 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// The storage types, see Config.Type.
+const (
+	TypeRedis  = "redis"
+	TypeMemory = "memory"
+)
+
+// memorySubscriptionBuffer is the number of messages a subscription of the memory storage holds
+// before the following messages are dropped, like redis disconnects the slow subscribers.
+const memorySubscriptionBuffer = 1000
+
+// memorySweepInterval is the minimum interval between the removals of all the expired keys, the
+// expired keys read meanwhile are removed on access.
+const memorySweepInterval = time.Minute
+
+// Store is the part of the storage used by the iam components: keys with an expiration, lists,
+// sets and pub/sub. It is implemented by RedisCluster, backed by redis, and by MemoryStore, backed
+// by the memory of the process.
+type Store interface {
+	Connect() bool
+	GetKey(ctx context.Context, keyName string) (string, error)
+	GetMultiKey(ctx context.Context, keys []string) ([]string, error)
+	GetRawKey(ctx context.Context, keyName string) (string, error)
+	SetKey(ctx context.Context, keyName, value string, timeout time.Duration) error
+	SetRawKey(ctx context.Context, keyName, value string, timeout time.Duration) error
+	SetKeyIfNotExists(ctx context.Context, keyName, value string, ttl time.Duration) (bool, error)
+	SetKeyIfExists(ctx context.Context, keyName, value string, ttl time.Duration) (bool, error)
+	GetExp(ctx context.Context, keyName string) (int64, error)
+	SetExp(ctx context.Context, keyName string, timeout time.Duration) error
+	Exists(ctx context.Context, keyName string) (bool, error)
+	DeleteKey(ctx context.Context, keyName string) bool
+	DeleteRawKey(ctx context.Context, keyName string) bool
+	AppendToSet(ctx context.Context, keyName, value string)
+	AppendToSetPipelined(ctx context.Context, key string, values [][]byte)
+	GetAndDeleteSet(ctx context.Context, keyName string) []interface{}
+	GetListRange(ctx context.Context, keyName string, from, to int64) ([]string, error)
+	AddToSet(ctx context.Context, keyName, value string)
+	GetSet(ctx context.Context, keyName string) (map[string]string, error)
+	IsMemberOfSet(ctx context.Context, keyName, value string) bool
+	RemoveFromSet(ctx context.Context, keyName, value string)
+	Publish(ctx context.Context, channel, message string) error
+	StartPubSubLoop(ctx context.Context, channel string, callback func(interface{}), reconnect PubSubReconnect)
+}
+
+var (
+	_ Store = (*RedisCluster)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
+
+// memoryEnabled is true once ConnectToRedis is called with the memory storage type, the Store
+// operations of the RedisClusters are then performed by the memory storage.
+var memoryEnabled atomic.Value
+
+func memoryStorage() bool {
+	if v := memoryEnabled.Load(); v != nil {
+		return v.(bool)
+	}
+
+	return false
+}
+
+type memoryEntry struct {
+	value string
+	list  []string
+	set   map[string]struct{}
+	// expireAt is zero if the entry does not expire.
+	expireAt time.Time
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+// memoryDB holds the data of the memory storage, it is shared by all the MemoryStores of the
+// process like a redis server is shared by the RedisClusters.
+type memoryDB struct {
+	mu          sync.Mutex
+	entries     map[string]*memoryEntry
+	subscribers map[string]map[chan *redis.Message]struct{}
+	lastSweep   time.Time
+}
+
+func newMemoryDB() *memoryDB {
+	return &memoryDB{
+		entries:     make(map[string]*memoryEntry),
+		subscribers: make(map[string]map[chan *redis.Message]struct{}),
+		lastSweep:   time.Now(),
+	}
+}
+
+var memory = newMemoryDB()
+
+// get returns the entry of key if it exists, db.mu must be held.
+func (db *memoryDB) get(key string) (*memoryEntry, bool) {
+	entry, ok := db.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	if entry.expired(time.Now()) {
+		delete(db.entries, key)
+
+		return nil, false
+	}
+
+	return entry, true
+}
+
+// getOrCreate returns the entry of key, which is created if it does not exist, db.mu must be held.
+func (db *memoryDB) getOrCreate(key string) *memoryEntry {
+	db.sweep()
+
+	entry, ok := db.get(key)
+	if !ok {
+		entry = &memoryEntry{}
+		db.entries[key] = entry
+	}
+
+	return entry
+}
+
+// sweep removes the expired entries which were not accessed, db.mu must be held.
+func (db *memoryDB) sweep() {
+	now := time.Now()
+	if now.Sub(db.lastSweep) < memorySweepInterval {
+		return
+	}
+
+	db.lastSweep = now
+
+	for key, entry := range db.entries {
+		if entry.expired(now) {
+			delete(db.entries, key)
+		}
+	}
+}
+
+func (db *memoryDB) getValue(key string) (string, bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	entry, ok := db.get(key)
+	if !ok || entry.list != nil || entry.set != nil {
+		return "", false
+	}
+
+	return entry.value, true
+}
+
+// setValue sets the value of key, which expires after timeout unless timeout is not positive.
+func (db *memoryDB) setValue(key, value string, timeout time.Duration) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.sweep()
+	db.put(key, value, timeout)
+}
+
+// setValueIf sets the value of key like setValue, only if key exists when exists is true or only if
+// it does not exist otherwise. It returns whether the value was set.
+func (db *memoryDB) setValueIf(key, value string, timeout time.Duration, exists bool) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.sweep()
+
+	if _, ok := db.get(key); ok != exists {
+		return false
+	}
+
+	db.put(key, value, timeout)
+
+	return true
+}
+
+// put replaces the entry of key with the value, db.mu must be held.
+func (db *memoryDB) put(key, value string, timeout time.Duration) {
+	entry := &memoryEntry{value: value}
+	if timeout > 0 {
+		entry.expireAt = time.Now().Add(timeout)
+	}
+
+	db.entries[key] = entry
+}
+
+// compareAndDelete deletes key if its value is value, and returns whether it was deleted.
+func (db *memoryDB) compareAndDelete(key, value string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	entry, ok := db.get(key)
+	if !ok || entry.list != nil || entry.set != nil || entry.value != value {
+		return false
+	}
+
+	delete(db.entries, key)
+
+	return true
+}
+
+// compareAndExpire makes key expire after timeout if its value is value, and returns whether it
+// was updated.
+func (db *memoryDB) compareAndExpire(key, value string, timeout time.Duration) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	entry, ok := db.get(key)
+	if !ok || entry.list != nil || entry.set != nil || entry.value != value {
+		return false
+	}
+
+	entry.expireAt = time.Now().Add(timeout)
+
+	return true
+}
+
+func (db *memoryDB) delete(key string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, ok := db.get(key)
+	delete(db.entries, key)
+
+	return ok
+}
+
+func (db *memoryDB) subscribe(channel string) chan *redis.Message {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	messages := make(chan *redis.Message, memorySubscriptionBuffer)
+	if db.subscribers[channel] == nil {
+		db.subscribers[channel] = make(map[chan *redis.Message]struct{})
+	}
+
+	db.subscribers[channel][messages] = struct{}{}
+
+	return messages
+}
+
+func (db *memoryDB) unsubscribe(channel string, messages chan *redis.Message) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	delete(db.subscribers[channel], messages)
+
+	if len(db.subscribers[channel]) == 0 {
+		delete(db.subscribers, channel)
+	}
+}
+
+func (db *memoryDB) publish(channel, message string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for messages := range db.subscribers[channel] {
+		select {
+		case messages <- &redis.Message{Channel: channel, Payload: message}:
+		default:
+			log.Warnf("Subscription to %s is too slow, message dropped", channel)
+		}
+	}
+}
+
+// MemoryStore is a Store keeping the keys in the memory of the process, for the tests and the
+// single binary demos. The keys and the pub/sub channels are shared by all the MemoryStores, the
+// keys are named like the keys of a RedisCluster with the same KeyPrefix and HashKeys.
+type MemoryStore struct {
+	KeyPrefix string
+	HashKeys  bool
+}
+
+func (m *MemoryStore) fixKey(keyName string) string {
+	return (&RedisCluster{KeyPrefix: m.KeyPrefix, HashKeys: m.HashKeys}).fixKey(keyName)
+}
+
+// Connect always succeeds.
+func (m *MemoryStore) Connect() bool {
+	return true
+}
+
+// GetKey returns the value of the key, or ErrKeyNotFound.
+func (m *MemoryStore) GetKey(ctx context.Context, keyName string) (string, error) {
+	value, ok := memory.getValue(m.fixKey(keyName))
+	if !ok {
+		return "", ErrKeyNotFound
+	}
+
+	return value, nil
+}
+
+// GetMultiKey gets multiple keys, the values of the missing keys are empty. ErrKeyNotFound is
+// returned if none of the keys exists.
+func (m *MemoryStore) GetMultiKey(ctx context.Context, keys []string) ([]string, error) {
+	values := make([]string, len(keys))
+	found := false
+
+	for i, key := range keys {
+		if value, ok := memory.getValue(m.fixKey(key)); ok {
+			values[i] = value
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, ErrKeyNotFound
+	}
+
+	return values, nil
+}
+
+// GetRawKey returns the value of the key without the KeyPrefix, or ErrKeyNotFound.
+func (m *MemoryStore) GetRawKey(ctx context.Context, keyName string) (string, error) {
+	value, ok := memory.getValue(rawKey(keyName))
+	if !ok {
+		return "", ErrKeyNotFound
+	}
+
+	return value, nil
+}
+
+// SetKey sets the value of the key, which expires after timeout unless timeout is 0.
+func (m *MemoryStore) SetKey(ctx context.Context, keyName, value string, timeout time.Duration) error {
+	memory.setValue(m.fixKey(keyName), value, timeout)
+
+	return nil
+}
+
+// SetRawKey sets the value of the key without the KeyPrefix.
+func (m *MemoryStore) SetRawKey(ctx context.Context, keyName, value string, timeout time.Duration) error {
+	memory.setValue(rawKey(keyName), value, timeout)
+
+	return nil
+}
+
+// SetKeyIfNotExists sets the key only if it does not exist yet, and returns whether it was set.
+func (m *MemoryStore) SetKeyIfNotExists(ctx context.Context, keyName, value string, ttl time.Duration) (bool, error) {
+	return memory.setValueIf(m.fixKey(keyName), value, ttl, false), nil
+}
+
+// SetKeyIfExists sets the key only if it already exists, and returns whether it was set.
+func (m *MemoryStore) SetKeyIfExists(ctx context.Context, keyName, value string, ttl time.Duration) (bool, error) {
+	return memory.setValueIf(m.fixKey(keyName), value, ttl, true), nil
+}
+
+// GetExp returns the seconds left before the key expires, 0 if it does not expire or does not
+// exist, like RedisCluster.GetExp.
+func (m *MemoryStore) GetExp(ctx context.Context, keyName string) (int64, error) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	entry, ok := memory.get(m.fixKey(keyName))
+	if !ok || entry.expireAt.IsZero() {
+		return 0, nil
+	}
+
+	return int64(time.Until(entry.expireAt).Seconds()), nil
+}
+
+// SetExp makes the key expire after timeout, the key is deleted if timeout is not positive.
+func (m *MemoryStore) SetExp(ctx context.Context, keyName string, timeout time.Duration) error {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	key := m.fixKey(keyName)
+
+	entry, ok := memory.get(key)
+	if !ok {
+		return nil
+	}
+
+	if timeout <= 0 {
+		delete(memory.entries, key)
+
+		return nil
+	}
+
+	entry.expireAt = time.Now().Add(timeout)
+
+	return nil
+}
+
+// Exists returns whether the key exists.
+func (m *MemoryStore) Exists(ctx context.Context, keyName string) (bool, error) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	_, ok := memory.get(m.fixKey(keyName))
+
+	return ok, nil
+}
+
+// DeleteKey deletes the key and returns whether it existed.
+func (m *MemoryStore) DeleteKey(ctx context.Context, keyName string) bool {
+	return memory.delete(m.fixKey(keyName))
+}
+
+// DeleteRawKey deletes the key without the KeyPrefix and returns whether it existed.
+func (m *MemoryStore) DeleteRawKey(ctx context.Context, keyName string) bool {
+	return memory.delete(rawKey(keyName))
+}
+
+// AppendToSet appends the value to the list.
+func (m *MemoryStore) AppendToSet(ctx context.Context, keyName, value string) {
+	m.AppendToSetPipelined(ctx, keyName, [][]byte{[]byte(value)})
+}
+
+// AppendToSetPipelined appends the values to the list.
+func (m *MemoryStore) AppendToSetPipelined(ctx context.Context, key string, values [][]byte) {
+	m.appendAndTrim(m.fixKey(key), values, 0, 0)
+}
+
+// appendAndTrim appends the values to the list of the fixed key, then keeps its maxLen last
+// values and makes it expire after expiration, if they are positive.
+func (m *MemoryStore) appendAndTrim(fixedKey string, values [][]byte, maxLen int64, expiration time.Duration) {
+	if len(values) == 0 {
+		return
+	}
+
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	entry := memory.getOrCreate(fixedKey)
+	for _, value := range values {
+		entry.list = append(entry.list, string(value))
+	}
+
+	if maxLen > 0 && int64(len(entry.list)) > maxLen {
+		entry.list = append([]string(nil), entry.list[int64(len(entry.list))-maxLen:]...)
+	}
+
+	if expiration > 0 {
+		entry.expireAt = time.Now().Add(expiration)
+	}
+}
+
+// GetAndDeleteSet returns the values of the list and deletes it, nil if the list is empty.
+func (m *MemoryStore) GetAndDeleteSet(ctx context.Context, keyName string) []interface{} {
+	return m.getAndDelete(m.fixKey(keyName))
+}
+
+func (m *MemoryStore) getAndDelete(fixedKey string) []interface{} {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	entry, ok := memory.get(fixedKey)
+	if !ok || len(entry.list) == 0 {
+		return nil
+	}
+
+	delete(memory.entries, fixedKey)
+
+	result := make([]interface{}, len(entry.list))
+	for i, value := range entry.list {
+		result[i] = value
+	}
+
+	return result
+}
+
+// GetListRange returns the values of the list from index from to index to included, the negative
+// indexes count from the end of the list like in redis.
+func (m *MemoryStore) GetListRange(ctx context.Context, keyName string, from, to int64) ([]string, error) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	entry, ok := memory.get(m.fixKey(keyName))
+	if !ok {
+		return []string{}, nil
+	}
+
+	n := int64(len(entry.list))
+	if from < 0 {
+		from += n
+	}
+
+	if to < 0 {
+		to += n
+	}
+
+	if from < 0 {
+		from = 0
+	}
+
+	if to >= n {
+		to = n - 1
+	}
+
+	if from > to {
+		return []string{}, nil
+	}
+
+	return append([]string(nil), entry.list[from:to+1]...), nil
+}
+
+// AddToSet adds the value to the set.
+func (m *MemoryStore) AddToSet(ctx context.Context, keyName, value string) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	entry := memory.getOrCreate(m.fixKey(keyName))
+	if entry.set == nil {
+		entry.set = make(map[string]struct{})
+	}
+
+	entry.set[value] = struct{}{}
+}
+
+// GetSet returns the members of the set indexed from "0", in lexicographic order.
+func (m *MemoryStore) GetSet(ctx context.Context, keyName string) (map[string]string, error) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	result := make(map[string]string)
+
+	entry, ok := memory.get(m.fixKey(keyName))
+	if !ok {
+		return result, nil
+	}
+
+	members := make([]string, 0, len(entry.set))
+	for member := range entry.set {
+		members = append(members, member)
+	}
+
+	sort.Strings(members)
+
+	for i, member := range members {
+		result[strconv.Itoa(i)] = member
+	}
+
+	return result, nil
+}
+
+// IsMemberOfSet returns whether the value is a member of the set.
+func (m *MemoryStore) IsMemberOfSet(ctx context.Context, keyName, value string) bool {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	entry, ok := memory.get(m.fixKey(keyName))
+	if !ok {
+		return false
+	}
+
+	_, ok = entry.set[value]
+
+	return ok
+}
+
+// RemoveFromSet removes the value from the set, the set is deleted once empty like in redis.
+func (m *MemoryStore) RemoveFromSet(ctx context.Context, keyName, value string) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	key := m.fixKey(keyName)
+
+	entry, ok := memory.get(key)
+	if !ok {
+		return
+	}
+
+	delete(entry.set, value)
+
+	if len(entry.set) == 0 {
+		delete(memory.entries, key)
+	}
+}
+
+// Publish sends the message to the current subscribers of the channel.
+func (m *MemoryStore) Publish(ctx context.Context, channel, message string) error {
+	memory.publish(channelName(channel), message)
+
+	return nil
+}
+
+// StartPubSubLoop runs the callback with the *redis.Message of every message published on the
+// channel, until ctx is done. The subscription is never lost, reconnect is not used.
+func (m *MemoryStore) StartPubSubLoop(ctx context.Context, channel string, callback func(interface{}),
+	reconnect PubSubReconnect) {
+	_, _ = m.subscribe(ctx, channel, callback, func() {})
+}
+
+// subscribe runs the callback for every message published on channel until ctx is done, like
+// RedisCluster.subscribe.
+func (m *MemoryStore) subscribe(ctx context.Context, channel string, callback func(interface{}),
+	established func()) (bool, error) {
+	channel = channelName(channel)
+	messages := memory.subscribe(channel)
+
+	defer memory.unsubscribe(channel, messages)
+
+	established()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case message := <-messages:
+			callback(message)
+		}
+	}
+}
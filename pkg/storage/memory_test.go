@@ -0,0 +1,359 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+)
+
+// useMemory makes the storage use an empty memory storage during the test.
+func useMemory(t *testing.T) {
+	t.Helper()
+
+	memory = newMemoryDB()
+	memoryEnabled.Store(true)
+	redisUp.Store(true)
+
+	t.Cleanup(func() {
+		memoryEnabled.Store(false)
+		redisUp.Store(false)
+		memory = newMemoryDB()
+	})
+}
+
+// testStore checks the behavior the iam components expect from a Store, newStore returns a
+// store whose keys are prefixed with prefix.
+func testStore(t *testing.T, newStore func(prefix string) Store) {
+	ctx := context.Background()
+	prefix := "conformance-" + strconv.FormatInt(time.Now().UnixNano(), 10) + "-"
+	store := newStore(prefix)
+
+	t.Run("keys", func(t *testing.T) {
+		if err := store.SetKey(ctx, "key", "value", 0); err != nil {
+			t.Fatalf("SetKey() error = %v", err)
+		}
+
+		if got, err := store.GetKey(ctx, "key"); err != nil || got != "value" {
+			t.Errorf("GetKey() = %q, %v, want value", got, err)
+		}
+
+		if ok, err := store.Exists(ctx, "key"); err != nil || !ok {
+			t.Errorf("Exists() = %v, %v, want true", ok, err)
+		}
+
+		if got, err := store.GetExp(ctx, "key"); err != nil || got != 0 {
+			t.Errorf("GetExp() = %d, %v, want 0 without expiration", got, err)
+		}
+
+		got, err := store.GetMultiKey(ctx, []string{"key", "missing"})
+		if err != nil || !reflect.DeepEqual(got, []string{"value", ""}) {
+			t.Errorf("GetMultiKey() = %q, %v, want [value \"\"]", got, err)
+		}
+
+		if _, err := store.GetMultiKey(ctx, []string{"missing"}); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("GetMultiKey() of missing keys error = %v, want ErrKeyNotFound", err)
+		}
+
+		if !store.DeleteKey(ctx, "key") {
+			t.Error("DeleteKey() = false, want true")
+		}
+
+		if store.DeleteKey(ctx, "key") {
+			t.Error("DeleteKey() of a deleted key = true, want false")
+		}
+
+		if _, err := store.GetKey(ctx, "key"); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("GetKey() of a deleted key error = %v, want ErrKeyNotFound", err)
+		}
+	})
+
+	t.Run("conditional keys", func(t *testing.T) {
+		if ok, err := store.SetKeyIfExists(ctx, "cond", "first", 0); err != nil || ok {
+			t.Errorf("SetKeyIfExists() of a missing key = %v, %v, want false", ok, err)
+		}
+
+		if ok, err := store.SetKeyIfNotExists(ctx, "cond", "first", time.Minute); err != nil || !ok {
+			t.Errorf("SetKeyIfNotExists() of a missing key = %v, %v, want true", ok, err)
+		}
+
+		if ok, err := store.SetKeyIfNotExists(ctx, "cond", "second", 0); err != nil || ok {
+			t.Errorf("SetKeyIfNotExists() of an existing key = %v, %v, want false", ok, err)
+		}
+
+		if got, _ := store.GetKey(ctx, "cond"); got != "first" {
+			t.Errorf("GetKey() = %q, want the value set first", got)
+		}
+
+		if got, _ := store.GetExp(ctx, "cond"); got <= 0 {
+			t.Errorf("GetExp() = %d, want the ttl of SetKeyIfNotExists", got)
+		}
+
+		if ok, err := store.SetKeyIfExists(ctx, "cond", "third", 0); err != nil || !ok {
+			t.Errorf("SetKeyIfExists() of an existing key = %v, %v, want true", ok, err)
+		}
+
+		if got, _ := store.GetKey(ctx, "cond"); got != "third" {
+			t.Errorf("GetKey() = %q, want the value set by SetKeyIfExists", got)
+		}
+
+		if got, _ := store.GetExp(ctx, "cond"); got != 0 {
+			t.Errorf("GetExp() = %d, want no expiration after SetKeyIfExists without ttl", got)
+		}
+
+		store.DeleteKey(ctx, "cond")
+	})
+
+	t.Run("raw keys", func(t *testing.T) {
+		if err := store.SetRawKey(ctx, prefix+"raw", "value", 0); err != nil {
+			t.Fatalf("SetRawKey() error = %v", err)
+		}
+
+		if got, err := store.GetRawKey(ctx, prefix+"raw"); err != nil || got != "value" {
+			t.Errorf("GetRawKey() = %q, %v, want value", got, err)
+		}
+
+		// the raw keys are not prefixed, prefix was added by hand.
+		if got, err := store.GetKey(ctx, "raw"); err != nil || got != "value" {
+			t.Errorf("GetKey() = %q, %v, want value", got, err)
+		}
+
+		if !store.DeleteRawKey(ctx, prefix+"raw") {
+			t.Error("DeleteRawKey() = false, want true")
+		}
+	})
+
+	t.Run("expiration", func(t *testing.T) {
+		if err := store.SetKey(ctx, "short", "value", 100*time.Millisecond); err != nil {
+			t.Fatalf("SetKey() error = %v", err)
+		}
+
+		if err := store.SetKey(ctx, "long", "value", time.Minute); err != nil {
+			t.Fatalf("SetKey() error = %v", err)
+		}
+
+		defer store.DeleteKey(ctx, "long")
+
+		if got, err := store.GetExp(ctx, "long"); err != nil || got < 55 || got > 60 {
+			t.Errorf("GetExp() = %d, %v, want about 60", got, err)
+		}
+
+		if err := store.SetExp(ctx, "long", 10*time.Second); err != nil {
+			t.Fatalf("SetExp() error = %v", err)
+		}
+
+		if got, err := store.GetExp(ctx, "long"); err != nil || got < 5 || got > 10 {
+			t.Errorf("GetExp() after SetExp() = %d, %v, want about 10", got, err)
+		}
+
+		time.Sleep(200 * time.Millisecond)
+
+		if _, err := store.GetKey(ctx, "short"); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("GetKey() of an expired key error = %v, want ErrKeyNotFound", err)
+		}
+
+		if ok, _ := store.Exists(ctx, "short"); ok {
+			t.Error("Exists() of an expired key = true, want false")
+		}
+
+		if got, err := store.GetExp(ctx, "missing"); err != nil || got != 0 {
+			t.Errorf("GetExp() of a missing key = %d, %v, want 0", got, err)
+		}
+	})
+
+	t.Run("lists", func(t *testing.T) {
+		store.AppendToSet(ctx, "list", "a")
+		store.AppendToSetPipelined(ctx, "list", [][]byte{[]byte("b"), []byte("c"), []byte("d")})
+		store.AppendToSetPipelined(ctx, "list", nil)
+
+		ranges := []struct {
+			from, to int64
+			want     []string
+		}{
+			{from: 0, to: -1, want: []string{"a", "b", "c", "d"}},
+			{from: 1, to: 2, want: []string{"b", "c"}},
+			{from: -2, to: 10, want: []string{"c", "d"}},
+			{from: 3, to: 1, want: []string{}},
+		}
+		for _, r := range ranges {
+			got, err := store.GetListRange(ctx, "list", r.from, r.to)
+			if err != nil || !reflect.DeepEqual(got, r.want) {
+				t.Errorf("GetListRange(%d, %d) = %q, %v, want %q", r.from, r.to, got, err, r.want)
+			}
+		}
+
+		want := []interface{}{"a", "b", "c", "d"}
+		if got := store.GetAndDeleteSet(ctx, "list"); !reflect.DeepEqual(got, want) {
+			t.Errorf("GetAndDeleteSet() = %v, want %v", got, want)
+		}
+
+		if got := store.GetAndDeleteSet(ctx, "list"); got != nil {
+			t.Errorf("GetAndDeleteSet() of a deleted list = %v, want nil", got)
+		}
+	})
+
+	t.Run("sets", func(t *testing.T) {
+		store.AddToSet(ctx, "set", "b")
+		store.AddToSet(ctx, "set", "a")
+		store.AddToSet(ctx, "set", "b")
+
+		members, err := store.GetSet(ctx, "set")
+		if err != nil {
+			t.Fatalf("GetSet() error = %v", err)
+		}
+
+		var got []string
+		for _, member := range members {
+			got = append(got, member)
+		}
+
+		sort.Strings(got)
+
+		if !reflect.DeepEqual(got, []string{"a", "b"}) {
+			t.Errorf("GetSet() = %v, want members a and b", members)
+		}
+
+		if !store.IsMemberOfSet(ctx, "set", "a") || store.IsMemberOfSet(ctx, "set", "c") {
+			t.Error("IsMemberOfSet() does not match the members")
+		}
+
+		store.RemoveFromSet(ctx, "set", "a")
+		store.RemoveFromSet(ctx, "set", "b")
+
+		if ok, _ := store.Exists(ctx, "set"); ok {
+			t.Error("Exists() of an emptied set = true, want false")
+		}
+	})
+
+	t.Run("prefixes", func(t *testing.T) {
+		other := newStore(prefix + "other-")
+
+		if err := store.SetKey(ctx, "shared", "value", 0); err != nil {
+			t.Fatalf("SetKey() error = %v", err)
+		}
+
+		defer store.DeleteKey(ctx, "shared")
+
+		if _, err := other.GetKey(ctx, "shared"); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("GetKey() with another prefix error = %v, want ErrKeyNotFound", err)
+		}
+	})
+
+	t.Run("pubsub", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		channel := prefix + "channel"
+		received := make(chan string, 100)
+
+		go store.StartPubSubLoop(ctx, channel, func(v interface{}) {
+			if msg, ok := v.(*redis.Message); ok {
+				received <- msg.Payload
+			}
+		}, PubSubReconnect{MinBackoff: 10 * time.Millisecond})
+
+		// the messages published before the subscription is established are lost.
+		deadline := time.After(5 * time.Second)
+		tick := time.NewTicker(10 * time.Millisecond)
+		defer tick.Stop()
+
+		for {
+			if err := store.Publish(ctx, channel, "hello"); err != nil {
+				t.Fatalf("Publish() error = %v", err)
+			}
+
+			select {
+			case got := <-received:
+				if got != "hello" {
+					t.Errorf("received %q, want hello", got)
+				}
+
+				return
+			case <-tick.C:
+			case <-deadline:
+				t.Fatal("the published message was not received")
+			}
+		}
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	useMemory(t)
+
+	testStore(t, func(prefix string) Store { return &MemoryStore{KeyPrefix: prefix} })
+}
+
+func TestRedisCluster_Memory(t *testing.T) {
+	useMemory(t)
+
+	testStore(t, func(prefix string) Store { return &RedisCluster{KeyPrefix: prefix} })
+}
+
+// TestRedisCluster_Conformance runs the Store checks against the redis at IAM_TEST_REDIS_ADDR.
+func TestRedisCluster_Conformance(t *testing.T) {
+	addr := os.Getenv("IAM_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("IAM_TEST_REDIS_ADDR is not set")
+	}
+
+	useClient(t, redis.NewClient(&redis.Options{Addr: addr}))
+
+	testStore(t, func(prefix string) Store { return &RedisCluster{KeyPrefix: prefix} })
+}
+
+func TestAnalyticsHandlers_Memory(t *testing.T) {
+	ctx := context.Background()
+	useMemory(t)
+
+	list := &RedisList{RedisCluster: RedisCluster{KeyPrefix: "analytics-"}, MaxLen: 2, Expiration: time.Minute}
+	list.AppendToSetPipelined(ctx, "records", [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+
+	got, err := list.GetListRange(ctx, "records", 0, -1)
+	if err != nil || !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("list = %q, %v, want the MaxLen last records", got, err)
+	}
+
+	if exp, _ := list.GetExp(ctx, "records"); exp <= 0 {
+		t.Errorf("GetExp() = %d, want the list to expire", exp)
+	}
+
+	stream := &RedisStream{RedisCluster: RedisCluster{KeyPrefix: "analytics-"}, MaxLen: 2}
+	stream.AppendToSetPipelined(ctx, "records", [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+
+	want := []interface{}{"b", "c"}
+	if got := stream.GetAndDeleteSet(ctx, "records"); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAndDeleteSet() = %v, want %v", got, want)
+	}
+}
+
+func TestConnectToRedis_Memory(t *testing.T) {
+	useMemory(t)
+	memoryEnabled.Store(false)
+	redisUp.Store(false)
+
+	connected := startConnect(t, &Config{Type: TypeMemory})
+
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("onConnected was not called")
+	}
+
+	if !Connected() || !memoryStorage() {
+		t.Errorf("Connected() = %v, memory storage = %v, want both true", Connected(), memoryStorage())
+	}
+
+	if err := (&RedisCluster{}).Ping(context.Background()); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"sync"
+
+	redis "github.com/go-redis/redis/v7"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsSubsystem = "redis_pool"
+
+var registerMetricsOnce sync.Once
+
+// pools are the connection pools of the storage, the cache pool is used by the RedisCluster
+// whose IsCache is set.
+var pools = []RedisCluster{{}, {IsCache: true}}
+
+func poolName(r RedisCluster) string {
+	if r.IsCache {
+		return "cache"
+	}
+
+	return "default"
+}
+
+// poolStater is implemented by the single-node, failover and cluster clients.
+type poolStater interface {
+	PoolStats() *redis.PoolStats
+}
+
+// PoolStats returns the stats of the connection pool of the client, summed over the nodes in
+// cluster mode. It returns nil if the storage is not connected.
+func (r *RedisCluster) PoolStats() *redis.PoolStats {
+	if c, ok := r.singleton().(poolStater); ok {
+		return c.PoolStats()
+	}
+
+	return nil
+}
+
+// poolCollector exports the stats of the connection pools, the stats are read on each scrape.
+type poolCollector struct {
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+	totalConns *prometheus.Desc
+	idleConns  *prometheus.Desc
+	staleConns *prometheus.Desc
+	// circuitOpen is not a stat of the pool, it is read on each scrape like them.
+	circuitOpen *prometheus.Desc
+}
+
+var _ prometheus.Collector = (*poolCollector)(nil)
+
+func newPoolCollector() *poolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName("iam", metricsSubsystem, name), help, []string{"pool"}, nil)
+	}
+
+	return &poolCollector{
+		hits:       desc("hits", "Number of times a free connection was found in the pool."),
+		misses:     desc("misses", "Number of times a free connection was not found in the pool."),
+		timeouts:   desc("timeouts", "Number of times a wait for a free connection timed out."),
+		totalConns: desc("total_conns", "Number of connections in the pool."),
+		idleConns:  desc("idle_conns", "Number of idle connections in the pool."),
+		staleConns: desc("stale_conns", "Number of stale connections removed from the pool."),
+		circuitOpen: prometheus.NewDesc(prometheus.BuildFQName("iam", "redis", "circuit_open"),
+			"Whether the redis circuit breaker of the pool is open, 1 if open.", []string{"pool"}, nil),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.timeouts
+	ch <- c.totalConns
+	ch <- c.idleConns
+	ch <- c.staleConns
+	ch <- c.circuitOpen
+}
+
+// Collect implements prometheus.Collector, the pools which are not connected are skipped.
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	for i := range pools {
+		stats := pools[i].PoolStats()
+		if stats == nil {
+			continue
+		}
+
+		name := poolName(pools[i])
+		gauge := func(desc *prometheus.Desc, value uint32) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(value), name)
+		}
+
+		gauge(c.hits, stats.Hits)
+		gauge(c.misses, stats.Misses)
+		gauge(c.timeouts, stats.Timeouts)
+		gauge(c.totalConns, stats.TotalConns)
+		gauge(c.idleConns, stats.IdleConns)
+		gauge(c.staleConns, stats.StaleConns)
+
+		var open uint32
+		if breaker(pools[i].IsCache).isOpen() {
+			open = 1
+		}
+
+		gauge(c.circuitOpen, open)
+	}
+}
+
+// breakerTransitions counts the state changes of the circuit breakers.
+var breakerTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "iam",
+	Subsystem: "redis",
+	Name:      "circuit_transitions_total",
+	Help:      "Number of state changes of the redis circuit breaker of the pool.",
+}, []string{"pool", "from", "to"})
+
+// pubSubDisconnects counts the subscriptions of StartPubSubLoop which were lost.
+var pubSubDisconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "iam",
+	Subsystem: "redis",
+	Name:      "pubsub_disconnects_total",
+	Help:      "Number of times the subscription to the channel was lost.",
+}, []string{"channel"})
+
+// commandDuration times the redis commands, a pipeline counts as one command.
+var commandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "iam",
+	Subsystem: "redis",
+	Name:      "command_duration_seconds",
+	Help:      "Duration of the redis commands, a pipeline counts as one command.",
+	Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+}, []string{"command", "status"})
+
+// metricsHook observes the duration of the redis commands into commandDuration.
+type metricsHook struct{}
+
+var _ Hook = metricsHook{}
+
+// BeforeCommand implements Hook.
+func (metricsHook) BeforeCommand(ctx context.Context, cmd *CommandInfo) context.Context {
+	return ctx
+}
+
+// AfterCommand implements Hook.
+func (metricsHook) AfterCommand(ctx context.Context, cmd *CommandInfo) {
+	status := "ok"
+	if cmd.Err != nil {
+		status = "error"
+	}
+
+	commandDuration.WithLabelValues(cmd.Name, status).Observe(cmd.Duration.Seconds())
+}
+
+// RegisterMetrics registers the connection pool gauges, the circuit breaker, the pub/sub and the
+// command duration metrics into the default prometheus registry, which is exposed by the /metrics
+// endpoint of the generic api server. The metrics are registered once, the next calls, e.g. by the
+// servers initialized again by the tests, do nothing.
+func RegisterMetrics() {
+	registerMetricsOnce.Do(func() {
+		prometheus.MustRegister(newPoolCollector(), breakerTransitions, pubSubDisconnects, commandDuration)
+		AddHook(metricsHook{})
+	})
+}
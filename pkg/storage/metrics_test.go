@@ -0,0 +1,45 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPoolCollector(t *testing.T) {
+	singleCachePool = atomic.Value{}
+	pongRedis(t)
+
+	// a ping takes a connection from the pool, which is then reused.
+	for i := 0; i < 2; i++ {
+		if err := (&RedisCluster{}).Ping(context.Background()); err != nil {
+			t.Fatalf("Ping() error = %v", err)
+		}
+	}
+
+	want := `
+# HELP iam_redis_pool_hits Number of times a free connection was found in the pool.
+# TYPE iam_redis_pool_hits gauge
+iam_redis_pool_hits{pool="default"} 1
+# HELP iam_redis_pool_misses Number of times a free connection was not found in the pool.
+# TYPE iam_redis_pool_misses gauge
+iam_redis_pool_misses{pool="default"} 1
+# HELP iam_redis_pool_total_conns Number of connections in the pool.
+# TYPE iam_redis_pool_total_conns gauge
+iam_redis_pool_total_conns{pool="default"} 1
+`
+
+	// the cache pool is not connected, so it is not collected.
+	err := testutil.CollectAndCompare(newPoolCollector(), strings.NewReader(want),
+		"iam_redis_pool_hits", "iam_redis_pool_misses", "iam_redis_pool_total_conns")
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// keySeparators are the characters a key prefix may end with, JoinKeyPrefix appends a ':'
+// to the prefixes which end with none of them.
+const keySeparators = ":-_./"
+
+// keyNamespace holds the namespace of the keys and channels, a string.
+var keyNamespace atomic.Value
+
+// KeyNamespace returns the namespace prepended to every key and pub/sub channel used by the
+// storage, before the RedisCluster.KeyPrefix. It is set from Config.KeyPrefix by ConnectToRedis.
+func KeyNamespace() string {
+	ns, _ := keyNamespace.Load().(string)
+
+	return ns
+}
+
+func setKeyNamespace(ns string) {
+	keyNamespace.Store(ns)
+}
+
+// JoinKeyPrefix joins prefixes into one key prefix. The empty prefixes are skipped and a ':' is
+// appended to the prefixes which do not end with a separator, so that "prod" and "analytics-"
+// give "prod:analytics-" instead of colliding with the keys of a "prodanalytics-" prefix.
+func JoinKeyPrefix(prefixes ...string) string {
+	var b strings.Builder
+
+	for i, prefix := range prefixes {
+		if prefix == "" {
+			continue
+		}
+
+		b.WriteString(prefix)
+
+		// the last prefix is followed by the key names, which are the callers' business.
+		if i < len(prefixes)-1 && !strings.ContainsAny(prefix[len(prefix)-1:], keySeparators) {
+			b.WriteByte(':')
+		}
+	}
+
+	return b.String()
+}
+
+// namespacePrefix returns the key prefix of the namespace ns, which ends with a separator.
+func namespacePrefix(ns string) string {
+	return JoinKeyPrefix(ns, "")
+}
+
+// ValidateKeyPrefix checks that prefix can namespace the keys: it must not contain spaces nor the
+// glob characters of the SCAN patterns, which would make the scans match the keys of other namespaces.
+func ValidateKeyPrefix(prefix string) error {
+	for _, c := range prefix {
+		if unicode.IsSpace(c) || !unicode.IsPrint(c) {
+			return fmt.Errorf("key prefix %q must not contain spaces or control characters", prefix)
+		}
+
+		if strings.ContainsRune(`*?[]\`, c) {
+			return fmt.Errorf("key prefix %q must not contain the glob character %q", prefix, c)
+		}
+	}
+
+	return nil
+}
+
+// rawKey returns the name of keyName in redis, keyName is not prefixed by the RedisCluster.KeyPrefix.
+func rawKey(keyName string) string {
+	return KeyNamespace() + keyName
+}
+
+// stripNamespace returns the name of a key read from redis, without the namespace.
+func stripNamespace(keyName string) string {
+	return strings.TrimPrefix(keyName, KeyNamespace())
+}
+
+// channelName returns the name of channel in redis.
+func channelName(channel string) string {
+	return KeyNamespace() + channel
+}
@@ -0,0 +1,357 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+)
+
+const testNamespace = "staging:"
+
+// useNamespace makes the storage use the namespace ns during the test.
+func useNamespace(t *testing.T, ns string) {
+	t.Helper()
+
+	setKeyNamespace(ns)
+	t.Cleanup(func() { setKeyNamespace("") })
+}
+
+// recordServer is a redis server which records the commands it receives. Its replies only
+// make the storage send its follow-up commands: the scans find one key, the scripts are never
+// loaded and the other commands reply 1 or nothing.
+type recordServer struct {
+	mu       sync.Mutex
+	commands [][]string
+}
+
+func (s *recordServer) reply(args []string) string {
+	s.mu.Lock()
+	s.commands = append(s.commands, args)
+	s.mu.Unlock()
+
+	switch strings.ToLower(args[0]) {
+	case "scan":
+		key := testNamespace + "iam-key"
+
+		return "*2\r\n$1\r\n0\r\n*1\r\n$" + strconv.Itoa(len(key)) + "\r\n" + key + "\r\n"
+	case "evalsha":
+		return "-NOSCRIPT No matching script\r\n"
+	case "subscribe":
+		return "-ERR subscriptions are not served\r\n"
+	case "mget":
+		return "*" + strconv.Itoa(len(args)-1) + strings.Repeat("\r\n$-1", len(args)-1) + "\r\n"
+	case "smembers", "lrange", "zrange", "zrangebyscore", "xrange", "xreadgroup", "eval":
+		return "*0\r\n"
+	case "ping":
+		return "+PONG\r\n"
+	default:
+		return ":1\r\n"
+	}
+}
+
+// received returns the commands received since the last call.
+func (s *recordServer) received() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	commands := s.commands
+	s.commands = nil
+
+	return commands
+}
+
+// recordRedis starts a recordServer used by the storage during the test.
+func recordRedis(t *testing.T) *recordServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	server := &recordServer{}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				reader := bufio.NewReader(conn)
+				for {
+					args, err := readCommand(reader)
+					if err != nil {
+						return
+					}
+
+					_, _ = conn.Write([]byte(server.reply(args)))
+				}
+			}(conn)
+		}
+	}()
+
+	useClient(t, redis.NewClient(&redis.Options{Addr: ln.Addr().String(), MaxRetries: -1}))
+
+	t.Cleanup(func() { ln.Close() })
+
+	return server
+}
+
+// commandKeys returns the keys and channels used by a command sent by the storage, false if the
+// command is unknown. A pattern matching the keys of all the namespaces is returned as "*".
+func commandKeys(args []string) ([]string, bool) {
+	switch strings.ToLower(args[0]) {
+	case "ping", "multi", "exec":
+		return nil, true
+	case "flushall":
+		return []string{"*"}, true
+	case "del", "mget", "exists", "subscribe":
+		return args[1:], true
+	case "get", "set", "ttl", "expire", "decr", "incr", "rpush", "lpush", "lrem", "lrange", "smembers",
+		"sadd", "srem", "sismember", "zadd", "zrange", "zrangebyscore", "zremrangebyscore", "xadd",
+		"xrange", "xack", "publish":
+		return args[1:2], true
+	case "xgroup":
+		return args[2:3], true
+	case "scan":
+		for i := 2; i+1 < len(args); i += 2 {
+			if strings.EqualFold(args[i], "match") {
+				return args[i+1 : i+2], true
+			}
+		}
+
+		return []string{"*"}, true
+	case "eval", "evalsha":
+		n, _ := strconv.Atoi(args[2])
+
+		return args[3 : 3+n], true
+	case "xreadgroup":
+		for i, arg := range args {
+			if strings.EqualFold(arg, "streams") {
+				return args[i+1 : i+1+(len(args)-i-1)/2], true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+func TestKeyNamespace_NoBypass(t *testing.T) {
+	server := recordRedis(t)
+	useNamespace(t, testNamespace)
+
+	r := &RedisCluster{KeyPrefix: "iam-"}
+	list := &RedisList{RedisCluster: *r, MaxLen: 10}
+	stream := &RedisStream{RedisCluster: *r, MaxLen: 10}
+	values := [][]byte{[]byte("a"), []byte("b")}
+	noop := func(interface{}) {}
+
+	// the operations of the storage which send commands, by method.
+	ops := map[string]func(ctx context.Context){
+		"GetKey":         func(ctx context.Context) { _, _ = r.GetKey(ctx, "key") },
+		"GetMultiKey":    func(ctx context.Context) { _, _ = r.GetMultiKey(ctx, []string{"a", "b"}) },
+		"LookupMultiKey": func(ctx context.Context) { _, _, _ = r.LookupMultiKey(ctx, []string{"a", "b"}) },
+		"GetKeyTTL":      func(ctx context.Context) { _, _ = r.GetKeyTTL(ctx, "key") },
+		"GetRawKey":      func(ctx context.Context) { _, _ = r.GetRawKey(ctx, "raw") },
+		"GetExp":         func(ctx context.Context) { _, _ = r.GetExp(ctx, "key") },
+		"SetExp":         func(ctx context.Context) { _ = r.SetExp(ctx, "key", time.Minute) },
+		"SetKey":         func(ctx context.Context) { _ = r.SetKey(ctx, "key", "value", time.Minute) },
+		"SetRawKey":      func(ctx context.Context) { _ = r.SetRawKey(ctx, "raw", "value", time.Minute) },
+		"SetKeyIfNotExists": func(ctx context.Context) {
+			_, _ = r.SetKeyIfNotExists(ctx, "key", "value", time.Minute)
+		},
+		"SetKeyIfExists": func(ctx context.Context) { _, _ = r.SetKeyIfExists(ctx, "key", "value", time.Minute) },
+		"NewLock": func(ctx context.Context) {
+			lock := r.NewLock("lock", time.Minute)
+			_, _ = lock.Acquire(ctx)
+			_ = lock.Renew(ctx)
+			_ = lock.Release(ctx)
+		},
+		"Decrement":                  func(ctx context.Context) { r.Decrement(ctx, "key") },
+		"IncrememntWithExpire":       func(ctx context.Context) { r.IncrememntWithExpire(ctx, "raw", 60) },
+		"ScanKeys":                   func(ctx context.Context) { _ = r.ScanKeys(ctx, "*", 0, func([]string) error { return nil }) },
+		"GetKeys":                    func(ctx context.Context) { r.GetKeys(ctx, "") },
+		"GetKeysAndValuesWithFilter": func(ctx context.Context) { r.GetKeysAndValuesWithFilter(ctx, "k") },
+		"GetKeysAndValues":           func(ctx context.Context) { r.GetKeysAndValues(ctx) },
+		"DeleteKey":                  func(ctx context.Context) { r.DeleteKey(ctx, "key") },
+		"DeleteAllKeys":              func(ctx context.Context) { r.DeleteAllKeys(ctx) },
+		"DeleteRawKey":               func(ctx context.Context) { r.DeleteRawKey(ctx, "raw") },
+		"DeleteScanMatch":            func(ctx context.Context) { r.DeleteScanMatch(ctx, "iam-*") },
+		"DeleteKeys":                 func(ctx context.Context) { r.DeleteKeys(ctx, []string{"a", "b"}) },
+		"StartPubSubHandler":         func(ctx context.Context) { _ = r.StartPubSubHandler(ctx, "channel", noop) },
+		"StartPubSubLoop": func(ctx context.Context) {
+			ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+
+			r.StartPubSubLoop(ctx, "channel", noop, PubSubReconnect{MinBackoff: 10 * time.Millisecond})
+		},
+		"Publish":         func(ctx context.Context) { _ = r.Publish(ctx, "channel", "message") },
+		"GetAndDeleteSet": func(ctx context.Context) { r.GetAndDeleteSet(ctx, "list") },
+		"GetAndDeleteSetChunked": func(ctx context.Context) {
+			_ = r.GetAndDeleteSetChunked(ctx, "list", 10, func([]interface{}) error { return nil })
+		},
+		"AppendToSet":          func(ctx context.Context) { r.AppendToSet(ctx, "list", "value") },
+		"Exists":               func(ctx context.Context) { _, _ = r.Exists(ctx, "key") },
+		"RemoveFromList":       func(ctx context.Context) { _ = r.RemoveFromList(ctx, "list", "value") },
+		"GetListRange":         func(ctx context.Context) { _, _ = r.GetListRange(ctx, "list", 0, -1) },
+		"AppendToSetPipelined": func(ctx context.Context) { r.AppendToSetPipelined(ctx, "list", values) },
+		"GetSet":               func(ctx context.Context) { _, _ = r.GetSet(ctx, "set") },
+		"AddToSet":             func(ctx context.Context) { r.AddToSet(ctx, "set", "value") },
+		"RemoveFromSet":        func(ctx context.Context) { r.RemoveFromSet(ctx, "set", "value") },
+		"IsMemberOfSet":        func(ctx context.Context) { r.IsMemberOfSet(ctx, "set", "value") },
+		"SetRollingWindow": func(ctx context.Context) {
+			r.SetRollingWindow(ctx, "window", 60, "-1", true)
+			r.SetRollingWindow(ctx, "window", 60, "value", false)
+		},
+		"GetRollingWindow": func(ctx context.Context) {
+			r.GetRollingWindow(ctx, "window", 60, true)
+			r.GetRollingWindow(ctx, "window", 60, false)
+		},
+		"AddToSortedSet":       func(ctx context.Context) { r.AddToSortedSet(ctx, "zset", "value", 1) },
+		"GetSortedSetRange":    func(ctx context.Context) { _, _, _ = r.GetSortedSetRange(ctx, "zset", "-inf", "+inf") },
+		"RemoveSortedSetRange": func(ctx context.Context) { _ = r.RemoveSortedSetRange(ctx, "zset", "-inf", "+inf") },
+		"Ping":                 func(ctx context.Context) { _ = r.Ping(ctx) },
+		"ScriptRunner": func(ctx context.Context) {
+			_, _ = r.ScriptRunner().Run(ctx, NewScript("return 1"), []string{"a", "b"})
+		},
+		"Pipeline": func(ctx context.Context) {
+			_, _ = r.Pipeline(ctx, func(pipe *Pipe) error {
+				pipe.Get(pipe.Key("key"))
+				pipe.Get(pipe.RawKey("raw"))
+
+				return nil
+			})
+		},
+		"RedisList.AppendToSetPipelined": func(ctx context.Context) { list.AppendToSetPipelined(ctx, "list", values) },
+		"RedisStream.AppendToSetPipelined": func(ctx context.Context) {
+			stream.AppendToSetPipelined(ctx, "stream", values)
+		},
+		"RedisStream.GetAndDeleteSet": func(ctx context.Context) { stream.GetAndDeleteSet(ctx, "stream") },
+		"RedisStream.CreateGroup":     func(ctx context.Context) { _ = stream.CreateGroup(ctx, "stream", "group", "0") },
+		"RedisStream.ReadGroup": func(ctx context.Context) {
+			_, _ = stream.ReadGroup(ctx, "stream", "group", "consumer", 10, time.Millisecond)
+		},
+		"RedisStream.Ack":         func(ctx context.Context) { _ = stream.Ack(ctx, "stream", "group", "1-0") },
+		"clusterConnectionIsOpen": func(ctx context.Context) { clusterConnectionIsOpen(*r) },
+	}
+
+	// the methods which send no command.
+	withoutCommand := map[string]bool{"Connect": true, "GetKeyPrefix": true, "PoolStats": true}
+
+	// a new method of the storage must be added to ops, so that its keys are checked.
+	typ := reflect.TypeOf(r)
+	for i := 0; i < typ.NumMethod(); i++ {
+		if name := typ.Method(i).Name; ops[name] == nil && !withoutCommand[name] {
+			t.Errorf("method %s is not checked, add it to the operations of the test", name)
+		}
+	}
+
+	for name, op := range ops {
+		op(context.Background())
+
+		commands := server.received()
+		if len(commands) == 0 {
+			t.Errorf("%s sent no command", name)
+		}
+
+		for _, args := range commands {
+			keys, ok := commandKeys(args)
+			if !ok {
+				t.Errorf("%s sent the unknown command %q, add its keys to commandKeys", name, args)
+
+				continue
+			}
+
+			for _, key := range keys {
+				if !strings.HasPrefix(key, testNamespace) {
+					t.Errorf("%s sent %q, the key %q is not in the namespace %q", name, args, key, testNamespace)
+				}
+			}
+		}
+	}
+}
+
+func TestKeyNamespace_Keys(t *testing.T) {
+	server := scanRedis(t, false, "staging:iam-a", "prod:iam-a", "staging:other", "iam-b")
+	useNamespace(t, testNamespace)
+
+	r := &RedisCluster{KeyPrefix: "iam-"}
+
+	// the keys are returned without the namespace nor the prefix.
+	if got := r.GetKeys(context.Background(), ""); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("GetKeys() = %q, want [a]", got)
+	}
+
+	// the keys of the other namespaces are left alone.
+	if !r.DeleteAllKeys(context.Background()) {
+		t.Fatal("DeleteAllKeys() = false, want true")
+	}
+
+	if got, want := server.list(), []string{"prod:iam-a", "iam-b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %q, want %q", got, want)
+	}
+}
+
+func TestJoinKeyPrefix(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefixes []string
+		want     string
+	}{
+		{name: "none", want: ""},
+		{name: "empty", prefixes: []string{"", ""}, want: ""},
+		{name: "single", prefixes: []string{"prod"}, want: "prod"},
+		{name: "separator appended", prefixes: []string{"prod", "analytics-"}, want: "prod:analytics-"},
+		{name: "separator kept", prefixes: []string{"prod-", "analytics-"}, want: "prod-analytics-"},
+		{name: "empty skipped", prefixes: []string{"", "analytics-"}, want: "analytics-"},
+		{name: "namespace", prefixes: []string{"prod", ""}, want: "prod:"},
+		{name: "nested", prefixes: []string{"iam", "prod", "rate-"}, want: "iam:prod:rate-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := JoinKeyPrefix(tt.prefixes...); got != tt.want {
+				t.Errorf("JoinKeyPrefix() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateKeyPrefix(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		wantErr bool
+	}{
+		{prefix: ""},
+		{prefix: "prod"},
+		{prefix: "iam.prod-eu_1:"},
+		{prefix: "prod env", wantErr: true},
+		{prefix: "prod\n", wantErr: true},
+		{prefix: "prod*", wantErr: true},
+		{prefix: "prod?", wantErr: true},
+		{prefix: "prod[1]", wantErr: true},
+		{prefix: `prod\`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			if err := ValidateKeyPrefix(tt.prefix); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateKeyPrefix() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"net"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// pubSubPingInterval is the interval of the pings checking that a quiet subscription is still alive.
+var pubSubPingInterval = 30 * time.Second
+
+// PubSubReconnect configures the reconnections of StartPubSubLoop.
+type PubSubReconnect struct {
+	// MinBackoff is the delay before the first resubscription, it is doubled after each failed
+	// resubscription up to MaxBackoff. It defaults to 1s.
+	MinBackoff time.Duration
+	// MaxBackoff defaults to 30s.
+	MaxBackoff time.Duration
+	// OnResubscribe is called each time the subscription is established again after a gap. The
+	// messages published during the gap are lost, OnResubscribe is where they are compensated for.
+	OnResubscribe func()
+}
+
+func (o *PubSubReconnect) complete() {
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = time.Second
+	}
+
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 30 * time.Second
+	}
+
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = o.MinBackoff
+	}
+}
+
+// StartPubSubLoop runs the callback for every message published on channel, until ctx is done. Unlike
+// StartPubSubHandler, a lost subscription is established again, with an exponential backoff, and
+// reconnect.OnResubscribe is called once it is. The lost subscriptions are counted by the
+// redis_pubsub_disconnects_total counter.
+func (r *RedisCluster) StartPubSubLoop(ctx context.Context, channel string, callback func(interface{}),
+	reconnect PubSubReconnect) {
+	reconnect.complete()
+	backoff := reconnect.MinBackoff
+	// gap is true while the messages published on channel may be lost.
+	gap := false
+	// the subscriptions lost again and again are logged at most once per minute.
+	lostLog := log.NewRateLimitedLogger(time.Minute)
+
+	for {
+		subscribed, err := r.subscribe(ctx, channel, callback, func() {
+			if gap {
+				log.Infof("Subscription to %s established again", channel)
+
+				if reconnect.OnResubscribe != nil {
+					reconnect.OnResubscribe()
+				}
+			}
+
+			gap = false
+			backoff = reconnect.MinBackoff
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		gap = true
+
+		if subscribed {
+			pubSubDisconnects.WithLabelValues(channel).Inc()
+		}
+
+		if !errors.Is(err, ErrRedisIsDown) {
+			lostLog.Warnf("Subscription to %s lost, resubscribe in %v: %s", channel, backoff, err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > reconnect.MaxBackoff {
+			backoff = reconnect.MaxBackoff
+		}
+	}
+}
+
+// subscribe runs the callback for every message published on channel, until the subscription is
+// lost or ctx is done. established is called once redis confirmed the subscription, subscribe
+// returns whether it was.
+func (r *RedisCluster) subscribe(ctx context.Context, channel string, callback func(interface{}),
+	established func()) (bool, error) {
+	if m, ok := r.memory(); ok {
+		return m.subscribe(ctx, channel, callback, established)
+	}
+
+	if err := r.up(); err != nil {
+		return false, err
+	}
+
+	client := r.client(ctx)
+	if client == nil {
+		return false, ErrRedisIsDown
+	}
+
+	pubsub := client.Subscribe(channelName(channel))
+	defer pubsub.Close()
+
+	// unblock the receptions once ctx is done.
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			pubsub.Close()
+		case <-stop:
+		}
+	}()
+
+	// the subscription is observed until redis confirmed it.
+	if err := observeCommand(ctx, "subscribe", func() error {
+		_, err := pubsub.Receive()
+
+		return err
+	}); err != nil {
+		return false, err
+	}
+
+	established()
+
+	// pinged is true while a ping is not answered.
+	pinged := false
+
+	for {
+		msg, err := pubsub.ReceiveTimeout(pubSubPingInterval)
+		if err != nil {
+			var netErr net.Error
+			if !errors.As(err, &netErr) || !netErr.Timeout() {
+				return true, err
+			}
+
+			if pinged {
+				return true, errors.Errorf("no pong received within %v", pubSubPingInterval)
+			}
+
+			// nothing received for a while, the pong or an error comes with the next reception.
+			if err := observeCommand(ctx, "ping", func() error { return pubsub.Ping() }); err != nil {
+				return true, err
+			}
+
+			pinged = true
+
+			continue
+		}
+
+		pinged = false
+
+		if message, ok := msg.(*redis.Message); ok {
+			callback(message)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// pubSubServer is a redis server which only knows SUBSCRIBE and PING, the connections subscribed
+// are sent to subscribed. The connections are closed right away while it is down.
+type pubSubServer struct {
+	down       int32
+	subscribed chan net.Conn
+}
+
+func (s *pubSubServer) serve(conn net.Conn) {
+	if atomic.LoadInt32(&s.down) == 1 {
+		conn.Close()
+
+		return
+	}
+
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			conn.Close()
+
+			return
+		}
+
+		switch strings.ToLower(args[0]) {
+		case "subscribe":
+			_, _ = fmt.Fprintf(conn, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(args[1]), args[1])
+			s.subscribed <- conn
+		case "ping":
+			_, _ = conn.Write([]byte("*2\r\n$4\r\npong\r\n$0\r\n\r\n"))
+		}
+	}
+}
+
+// publish sends a message of channel to the subscribed connection.
+func publish(conn net.Conn, channel, payload string) {
+	_, _ = fmt.Fprintf(conn, "*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n",
+		len(channel), channel, len(payload), payload)
+}
+
+// pubSubRedis starts a pubSubServer used by the storage during the test.
+func pubSubRedis(t *testing.T) *pubSubServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	server := &pubSubServer{subscribed: make(chan net.Conn, 10)}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go server.serve(conn)
+		}
+	}()
+
+	useClient(t, redis.NewClient(&redis.Options{Addr: ln.Addr().String()}))
+
+	t.Cleanup(func() { ln.Close() })
+
+	return server
+}
+
+func TestRedisCluster_StartPubSubLoop(t *testing.T) {
+	const channel = "test.pubsub.loop"
+
+	server := pubSubRedis(t)
+	disconnects := testutil.ToFloat64(pubSubDisconnects.WithLabelValues(channel))
+	ctx, cancel := context.WithCancel(context.Background())
+	messages := make(chan string, 10)
+	resubscribed := make(chan struct{}, 10)
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		(&RedisCluster{}).StartPubSubLoop(ctx, channel, func(v interface{}) {
+			messages <- v.(*redis.Message).Payload
+		}, PubSubReconnect{
+			MinBackoff:    10 * time.Millisecond,
+			MaxBackoff:    20 * time.Millisecond,
+			OnResubscribe: func() { resubscribed <- struct{}{} },
+		})
+	}()
+
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	waitConn := func() net.Conn {
+		t.Helper()
+
+		select {
+		case conn := <-server.subscribed:
+			return conn
+		case <-time.After(5 * time.Second):
+			t.Fatal("no subscription")
+		}
+
+		return nil
+	}
+
+	conn := waitConn()
+	publish(conn, channel, "first")
+
+	if got := <-messages; got != "first" {
+		t.Errorf("message = %q, want first", got)
+	}
+
+	// the first subscription is no resubscription.
+	if len(resubscribed) != 0 {
+		t.Error("OnResubscribe called on the first subscription")
+	}
+
+	// kill the connection while redis is down, the resubscriptions fail until it is restored.
+	atomic.StoreInt32(&server.down, 1)
+	conn.Close()
+	time.Sleep(100 * time.Millisecond)
+	atomic.StoreInt32(&server.down, 0)
+
+	conn = waitConn()
+
+	select {
+	case <-resubscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnResubscribe not called after the subscription was established again")
+	}
+
+	// OnResubscribe is called once per gap, however many resubscriptions failed.
+	if len(resubscribed) != 0 {
+		t.Errorf("OnResubscribe called %d more times, want once", len(resubscribed))
+	}
+
+	if got := testutil.ToFloat64(pubSubDisconnects.WithLabelValues(channel)) - disconnects; got != 1 {
+		t.Errorf("disconnects = %v, want 1", got)
+	}
+
+	publish(conn, channel, "second")
+
+	if got := <-messages; got != "second" {
+		t.Errorf("message = %q, want second", got)
+	}
+}
+
+func TestRedisCluster_StartPubSubLoop_Ping(t *testing.T) {
+	defer func(interval time.Duration) { pubSubPingInterval = interval }(pubSubPingInterval)
+	pubSubPingInterval = 20 * time.Millisecond
+
+	server := pubSubRedis(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		(&RedisCluster{}).StartPubSubLoop(ctx, "test.pubsub.ping", func(interface{}) {}, PubSubReconnect{
+			MinBackoff: 10 * time.Millisecond,
+		})
+	}()
+
+	<-server.subscribed
+
+	// the pongs keep the quiet subscription alive.
+	time.Sleep(200 * time.Millisecond)
+
+	if len(server.subscribed) != 0 {
+		t.Error("the quiet subscription was established again, want kept")
+	}
+
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("StartPubSubLoop() still running after ctx is done")
+	}
+}
@@ -6,10 +6,11 @@ package storage
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
+	"math/rand"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -23,6 +24,10 @@ import (
 
 // Config defines options for redis cluster.
 type Config struct {
+	// Type is the storage type, TypeRedis or TypeMemory. The memory storage is not shared with the
+	// other processes and is lost on exit, it is meant for the tests and the single binary demos.
+	// It defaults to TypeRedis.
+	Type                  string
 	Host                  string
 	Port                  int
 	Addrs                 []string
@@ -36,6 +41,29 @@ type Config struct {
 	EnableCluster         bool
 	UseSSL                bool
 	SSLInsecureSkipVerify bool
+	// CAFile is the PEM encoded CA bundle used to verify the redis server certificate,
+	// the system roots are used if it is empty.
+	CAFile string
+	// CertFile and KeyFile are the PEM encoded client certificate and key presented to redis.
+	CertFile string
+	KeyFile  string
+	// MinTLSVersion is the minimum tls version accepted, one of 1.0, 1.1, 1.2 and 1.3.
+	MinTLSVersion string
+	// SentinelAddrs are the addresses of the sentinels when MasterName is set, Addrs are used if it is empty.
+	SentinelAddrs []string
+	// SentinelUsername and SentinelPassword authenticate the connections to the sentinels,
+	// Username and Password only authenticate the connections to the redis nodes.
+	SentinelUsername string
+	SentinelPassword string
+	// CircuitBreakerThreshold is the number of consecutive commands failing to reach redis which open
+	// the circuit breaker of a pool, 0 disables the circuit breakers.
+	CircuitBreakerThreshold int
+	// CircuitBreakerTimeout is the duration the commands fail fast once the circuit breaker is open.
+	CircuitBreakerTimeout time.Duration
+	// KeyPrefix namespaces every key and pub/sub channel used by the storage, so that several
+	// environments can share a redis. It comes before the RedisCluster.KeyPrefix of the keys,
+	// a ':' is appended if it does not end with a separator. See KeyNamespace.
+	KeyPrefix string
 }
 
 // ErrRedisIsDown is returned when we can't communicate with redis.
@@ -49,6 +77,10 @@ var (
 
 var disableRedis atomic.Value
 
+// appendErrorLog logs the errors of the appends of the analytics records at most once per minute, the
+// flushes of every worker failing alike while redis is down.
+var appendErrorLog = log.NewRateLimitedLogger(time.Minute)
+
 // DisableRedis very handy when testsing it allows to dynamically enable/disable talking with redisW.
 func DisableRedis(ok bool) {
 	if ok {
@@ -78,6 +110,15 @@ func Connected() bool {
 	return false
 }
 
+// HealthCheck returns ErrRedisIsDown if we are not connected to redis.
+func HealthCheck(context.Context) error {
+	if !Connected() {
+		return ErrRedisIsDown
+	}
+
+	return nil
+}
+
 func singleton(cache bool) redis.UniversalClient {
 	if cache {
 		v := singleCachePool.Load()
@@ -98,12 +139,26 @@ func singleton(cache bool) redis.UniversalClient {
 func connectSingleton(cache bool, config *Config) bool {
 	if singleton(cache) == nil {
 		log.Debug("Connecting to redis cluster")
+		client := NewRedisClusterPool(cache, config)
+		if client == nil {
+			return false
+		}
+
+		var b *circuitBreaker
+		if config.CircuitBreakerThreshold > 0 {
+			b = newCircuitBreaker(poolName(RedisCluster{IsCache: cache}), config.CircuitBreakerThreshold,
+				config.CircuitBreakerTimeout)
+			client.AddHook(b)
+		}
+		storeBreaker(cache, b)
+		client.AddHook(commandHook{})
+
 		if cache {
-			singleCachePool.Store(NewRedisClusterPool(cache, config))
+			singleCachePool.Store(client)
 
 			return true
 		}
-		singlePool.Store(NewRedisClusterPool(cache, config))
+		singlePool.Store(client)
 
 		return true
 	}
@@ -120,7 +175,7 @@ type RedisCluster struct {
 
 func clusterConnectionIsOpen(cluster RedisCluster) bool {
 	c := singleton(cluster.IsCache)
-	testKey := "redis-test-" + uuid.Must(uuid.NewV4()).String()
+	testKey := rawKey("redis-test-" + uuid.Must(uuid.NewV4()).String())
 	if err := c.Set(testKey, "test", time.Second).Err(); err != nil {
 		log.Warnf("Error trying to set test key: %s", err.Error())
 
@@ -135,55 +190,116 @@ func clusterConnectionIsOpen(cluster RedisCluster) bool {
 	return true
 }
 
-// ConnectToRedis starts a go routine that periodically tries to connect to redis.
-func ConnectToRedis(ctx context.Context, config *Config) {
-	tick := time.NewTicker(time.Second)
-	defer tick.Stop()
-	c := []RedisCluster{
-		{}, {IsCache: true},
-	}
-	var ok bool
-	for _, v := range c {
-		if !connectSingleton(v.IsCache, config) {
-			break
+var (
+	// connectMinBackoff is the delay before retrying a failed connection to redis, it is doubled
+	// after each failure up to connectMaxBackoff.
+	connectMinBackoff = time.Second
+	connectMaxBackoff = 30 * time.Second
+	// connectCheckInterval is the interval of the checks of an established connection.
+	connectCheckInterval = time.Second
+	// connect connects the pools to redis and checks that the connections are open.
+	connect = connectPools
+)
+
+func connectPools(config *Config) bool {
+	for _, v := range pools {
+		if !connectSingleton(v.IsCache, config) || !clusterConnectionIsOpen(v) {
+			return false
 		}
+	}
+
+	return true
+}
+
+// jitter returns a random duration in [d/2, d), so that the instances do not retry in lockstep.
+func jitter(d time.Duration) time.Duration {
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+
+	return time.Duration(half + rand.Int63n(half))
+}
+
+// ConnectToRedis connects to redis and keeps checking the connection until ctx is done. A failed
+// connection is retried with an exponential backoff, with jitter and capped to 30s. onConnected is
+// called each time the connection is established, the first time and after each reconnection, it
+// is called from the connection loop so it must not block. The process exits if the config is
+// invalid, rather than running without redis.
+func ConnectToRedis(ctx context.Context, config *Config, onConnected ...func()) {
+	if errs := config.Validate(); len(errs) != 0 {
+		log.Fatalf("Invalid redis config: %s", errors.NewAggregate(errs).Error())
+	}
+
+	if config.UseSSL && config.SSLInsecureSkipVerify {
+		log.Warn("!!! --redis.ssl-insecure-skip-verify is set, the certificate of redis will NOT be verified " +
+			"and the connection is open to man-in-the-middle attacks, never use it in production !!!")
+	}
+
+	setKeyNamespace(namespacePrefix(config.KeyPrefix))
+
+	if ns := KeyNamespace(); ns != "" {
+		log.Infof("Redis keys and channels are namespaced with %q", ns)
+	} else {
+		log.Info("Redis keys and channels are not namespaced, set --redis.key-prefix if the redis is shared")
+	}
+
+	if config.Type == TypeMemory {
+		log.Warn("!!! --redis.type is memory, the data is kept in the memory of the process, lost on exit " +
+			"and not shared with the other processes, never use it in production !!!")
 
-		if !clusterConnectionIsOpen(v) {
-			redisUp.Store(false)
+		memoryEnabled.Store(true)
+		redisUp.Store(true)
 
-			break
+		for _, fn := range onConnected {
+			fn()
 		}
-		ok = true
+
+		<-ctx.Done()
+
+		return
 	}
-	redisUp.Store(ok)
-again:
+
+	connected := false
+	backoff := connectMinBackoff
+
 	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-tick.C:
-			if !shouldConnect() {
-				continue
-			}
-			for _, v := range c {
-				if !connectSingleton(v.IsCache, config) {
-					redisUp.Store(false)
+		delay := connectCheckInterval
 
-					goto again
-				}
+		if shouldConnect() {
+			ok := connect(config)
+			redisUp.Store(ok)
+
+			switch {
+			case ok && !connected:
+				log.Info("Connected to redis")
 
-				if !clusterConnectionIsOpen(v) {
-					redisUp.Store(false)
+				backoff = connectMinBackoff
+
+				for _, fn := range onConnected {
+					fn()
+				}
+			case !ok:
+				delay = jitter(backoff)
+				log.Warnf("Failed to connect to redis, retry in %v", delay)
 
-					goto again
+				if backoff *= 2; backoff > connectMaxBackoff {
+					backoff = connectMaxBackoff
 				}
 			}
-			redisUp.Store(true)
+
+			connected = ok
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
 		}
 	}
 }
 
-// NewRedisClusterPool create a redis cluster pool.
+// NewRedisClusterPool create a redis cluster pool, returns nil if the tls config is invalid.
 func NewRedisClusterPool(isCache bool, config *Config) redis.UniversalClient {
 	// redisSingletonMu is locked and we know the singleton is nil
 	log.Debug("Creating new Redis connection pool")
@@ -200,18 +316,18 @@ func NewRedisClusterPool(isCache bool, config *Config) redis.UniversalClient {
 		timeout = time.Duration(config.Timeout) * time.Second
 	}
 
-	var tlsConfig *tls.Config
+	tlsConfig, err := NewTLSConfig(config)
+	if err != nil {
+		log.Errorf("Failed to build redis tls config: %s", err.Error())
 
-	if config.UseSSL {
-		tlsConfig = &tls.Config{
-			InsecureSkipVerify: config.SSLInsecureSkipVerify,
-		}
+		return nil
 	}
 
 	var client redis.UniversalClient
 	opts := &RedisOpts{
 		Addrs:        getRedisAddrs(config),
 		MasterName:   config.MasterName,
+		Username:     config.Username,
 		Password:     config.Password,
 		DB:           config.Database,
 		DialTimeout:  timeout,
@@ -224,7 +340,7 @@ func NewRedisClusterPool(isCache bool, config *Config) redis.UniversalClient {
 
 	if opts.MasterName != "" {
 		log.Info("--> [REDIS] Creating sentinel-backed failover client")
-		client = redis.NewFailoverClient(opts.failover())
+		client = redis.NewFailoverClient(opts.failover(config))
 	} else if config.EnableCluster {
 		log.Info("--> [REDIS] Creating cluster client")
 		client = redis.NewClusterClient(opts.cluster())
@@ -264,6 +380,7 @@ func (o *RedisOpts) cluster() *redis.ClusterOptions {
 		Addrs:     o.Addrs,
 		OnConnect: o.OnConnect,
 
+		Username: o.Username,
 		Password: o.Password,
 
 		MaxRedirects:   o.MaxRedirects,
@@ -300,6 +417,7 @@ func (o *RedisOpts) simple() *redis.Options {
 		OnConnect: o.OnConnect,
 
 		DB:       o.DB,
+		Username: o.Username,
 		Password: o.Password,
 
 		MaxRetries:      o.MaxRetries,
@@ -321,17 +439,27 @@ func (o *RedisOpts) simple() *redis.Options {
 	}
 }
 
-func (o *RedisOpts) failover() *redis.FailoverOptions {
-	if len(o.Addrs) == 0 {
-		o.Addrs = []string{"127.0.0.1:26379"}
+// failover returns the options of the sentinel-backed failover client, the sentinels are found at
+// config.SentinelAddrs (o.Addrs if empty) and authenticated with the sentinel credentials of config.
+func (o *RedisOpts) failover(config *Config) *redis.FailoverOptions {
+	sentinelAddrs := config.SentinelAddrs
+	if len(sentinelAddrs) == 0 {
+		sentinelAddrs = o.Addrs
+	}
+
+	if len(sentinelAddrs) == 0 {
+		sentinelAddrs = []string{"127.0.0.1:26379"}
 	}
 
 	return &redis.FailoverOptions{
-		SentinelAddrs: o.Addrs,
-		MasterName:    o.MasterName,
-		OnConnect:     o.OnConnect,
+		SentinelAddrs:    sentinelAddrs,
+		SentinelUsername: config.SentinelUsername,
+		SentinelPassword: config.SentinelPassword,
+		MasterName:       o.MasterName,
+		OnConnect:        o.OnConnect,
 
 		DB:       o.DB,
+		Username: o.Username,
 		Password: o.Password,
 
 		MaxRetries:      o.MaxRetries,
@@ -358,10 +486,32 @@ func (r *RedisCluster) Connect() bool {
 	return true
 }
 
+// memory returns the MemoryStore performing the Store operations of r with the memory storage.
+func (r *RedisCluster) memory() (*MemoryStore, bool) {
+	if !memoryStorage() {
+		return nil, false
+	}
+
+	return &MemoryStore{KeyPrefix: r.KeyPrefix, HashKeys: r.HashKeys}, true
+}
+
 func (r *RedisCluster) singleton() redis.UniversalClient {
 	return singleton(r.IsCache)
 }
 
+// client returns the redis client bound to ctx: the commands are not sent once ctx is done,
+// and they are aborted when the deadline of ctx is exceeded.
+func (r *RedisCluster) client(ctx context.Context) redis.UniversalClient {
+	switch c := r.singleton().(type) {
+	case *redis.ClusterClient:
+		return c.WithContext(ctx)
+	case *redis.Client:
+		return c.WithContext(ctx)
+	default:
+		return c
+	}
+}
+
 func (r *RedisCluster) hashKey(in string) string {
 	if !r.HashKeys {
 		// Not hashing? Return the raw key
@@ -372,15 +522,17 @@ func (r *RedisCluster) hashKey(in string) string {
 }
 
 func (r *RedisCluster) fixKey(keyName string) string {
-	return r.KeyPrefix + r.hashKey(keyName)
+	return rawKey(r.KeyPrefix + r.hashKey(keyName))
 }
 
 func (r *RedisCluster) cleanKey(keyName string) string {
 	return strings.Replace(keyName, r.KeyPrefix, "", 1)
 }
 
+// up returns ErrRedisIsDown if the commands can not be sent to redis, which is also the case of
+// the operations not supported by the memory storage.
 func (r *RedisCluster) up() error {
-	if !Connected() {
+	if !Connected() || breaker(r.IsCache).isOpen() || memoryStorage() {
 		return ErrRedisIsDown
 	}
 
@@ -388,12 +540,16 @@ func (r *RedisCluster) up() error {
 }
 
 // GetKey will retrieve a key from the database.
-func (r *RedisCluster) GetKey(keyName string) (string, error) {
+func (r *RedisCluster) GetKey(ctx context.Context, keyName string) (string, error) {
+	if m, ok := r.memory(); ok {
+		return m.GetKey(ctx, keyName)
+	}
+
 	if err := r.up(); err != nil {
 		return "", err
 	}
 
-	cluster := r.singleton()
+	cluster := r.client(ctx)
 
 	value, err := cluster.Get(r.fixKey(keyName)).Result()
 	if err != nil {
@@ -405,59 +561,27 @@ func (r *RedisCluster) GetKey(keyName string) (string, error) {
 	return value, nil
 }
 
-// GetMultiKey gets multiple keys from the database.
-func (r *RedisCluster) GetMultiKey(keys []string) ([]string, error) {
-	if err := r.up(); err != nil {
-		return nil, err
+// GetMultiKey gets multiple keys from the database, the values of the missing keys are empty.
+// ErrKeyNotFound is returned if none of the keys exists, use LookupMultiKey to know which keys exist.
+func (r *RedisCluster) GetMultiKey(ctx context.Context, keys []string) ([]string, error) {
+	if m, ok := r.memory(); ok {
+		return m.GetMultiKey(ctx, keys)
 	}
-	cluster := r.singleton()
-	keyNames := make([]string, len(keys))
-	copy(keyNames, keys)
-	for index, val := range keyNames {
-		keyNames[index] = r.fixKey(val)
-	}
-
-	result := make([]string, 0)
 
-	switch v := cluster.(type) {
-	case *redis.ClusterClient:
-		{
-			getCmds := make([]*redis.StringCmd, 0)
-			pipe := v.Pipeline()
-			for _, key := range keyNames {
-				getCmds = append(getCmds, pipe.Get(key))
-			}
-			_, err := pipe.Exec()
-			if err != nil && !errors.Is(err, redis.Nil) {
-				log.Debugf("Error trying to get value: %s", err.Error())
+	values, found, err := r.LookupMultiKey(ctx, keys)
+	if errors.Is(err, ErrRedisIsDown) {
+		return nil, err
+	}
 
-				return nil, ErrKeyNotFound
-			}
-			for _, cmd := range getCmds {
-				result = append(result, cmd.Val())
-			}
-		}
-	case *redis.Client:
-		{
-			values, err := cluster.MGet(keyNames...).Result()
-			if err != nil {
-				log.Debugf("Error trying to get value: %s", err.Error())
+	if err != nil {
+		log.Debugf("Error trying to get value: %s", err.Error())
 
-				return nil, ErrKeyNotFound
-			}
-			for _, val := range values {
-				strVal := fmt.Sprint(val)
-				if strVal == "<nil>" {
-					strVal = ""
-				}
-				result = append(result, strVal)
-			}
-		}
+		return nil, ErrKeyNotFound
 	}
 
-	for _, val := range result {
-		if val != "" {
-			return result, nil
+	for _, ok := range found {
+		if ok {
+			return values, nil
 		}
 	}
 
@@ -465,21 +589,25 @@ func (r *RedisCluster) GetMultiKey(keys []string) ([]string, error) {
 }
 
 // GetKeyTTL return ttl of the given key.
-func (r *RedisCluster) GetKeyTTL(keyName string) (ttl int64, err error) {
+func (r *RedisCluster) GetKeyTTL(ctx context.Context, keyName string) (ttl int64, err error) {
 	if err = r.up(); err != nil {
 		return 0, err
 	}
-	duration, err := r.singleton().TTL(r.fixKey(keyName)).Result()
+	duration, err := r.client(ctx).TTL(r.fixKey(keyName)).Result()
 
 	return int64(duration.Seconds()), err
 }
 
 // GetRawKey return the value of the given key.
-func (r *RedisCluster) GetRawKey(keyName string) (string, error) {
+func (r *RedisCluster) GetRawKey(ctx context.Context, keyName string) (string, error) {
+	if m, ok := r.memory(); ok {
+		return m.GetRawKey(ctx, keyName)
+	}
+
 	if err := r.up(); err != nil {
 		return "", err
 	}
-	value, err := r.singleton().Get(keyName).Result()
+	value, err := r.client(ctx).Get(rawKey(keyName)).Result()
 	if err != nil {
 		log.Debugf("Error trying to get value: %s", err.Error())
 
@@ -490,13 +618,17 @@ func (r *RedisCluster) GetRawKey(keyName string) (string, error) {
 }
 
 // GetExp return the expiry of the given key.
-func (r *RedisCluster) GetExp(keyName string) (int64, error) {
+func (r *RedisCluster) GetExp(ctx context.Context, keyName string) (int64, error) {
+	if m, ok := r.memory(); ok {
+		return m.GetExp(ctx, keyName)
+	}
+
 	log.Debugf("Getting exp for key: %s", r.fixKey(keyName))
 	if err := r.up(); err != nil {
 		return 0, err
 	}
 
-	value, err := r.singleton().TTL(r.fixKey(keyName)).Result()
+	value, err := r.client(ctx).TTL(r.fixKey(keyName)).Result()
 	if err != nil {
 		log.Errorf("Error trying to get TTL: ", err.Error())
 
@@ -507,11 +639,15 @@ func (r *RedisCluster) GetExp(keyName string) (int64, error) {
 }
 
 // SetExp set expiry of the given key.
-func (r *RedisCluster) SetExp(keyName string, timeout time.Duration) error {
+func (r *RedisCluster) SetExp(ctx context.Context, keyName string, timeout time.Duration) error {
+	if m, ok := r.memory(); ok {
+		return m.SetExp(ctx, keyName, timeout)
+	}
+
 	if err := r.up(); err != nil {
 		return err
 	}
-	err := r.singleton().Expire(r.fixKey(keyName), timeout).Err()
+	err := r.client(ctx).Expire(r.fixKey(keyName), timeout).Err()
 	if err != nil {
 		log.Errorf("Could not EXPIRE key: %s", err.Error())
 	}
@@ -520,14 +656,18 @@ func (r *RedisCluster) SetExp(keyName string, timeout time.Duration) error {
 }
 
 // SetKey will create (or update) a key value in the store.
-func (r *RedisCluster) SetKey(keyName, session string, timeout time.Duration) error {
+func (r *RedisCluster) SetKey(ctx context.Context, keyName, session string, timeout time.Duration) error {
+	if m, ok := r.memory(); ok {
+		return m.SetKey(ctx, keyName, session, timeout)
+	}
+
 	log.Debugf("[STORE] SET Raw key is: %s", keyName)
 	log.Debugf("[STORE] Setting key: %s", r.fixKey(keyName))
 
 	if err := r.up(); err != nil {
 		return err
 	}
-	err := r.singleton().Set(r.fixKey(keyName), session, timeout).Err()
+	err := r.client(ctx).Set(r.fixKey(keyName), session, timeout).Err()
 	if err != nil {
 		log.Errorf("Error trying to set value: %s", err.Error())
 
@@ -538,11 +678,15 @@ func (r *RedisCluster) SetKey(keyName, session string, timeout time.Duration) er
 }
 
 // SetRawKey set the value of the given key.
-func (r *RedisCluster) SetRawKey(keyName, session string, timeout time.Duration) error {
+func (r *RedisCluster) SetRawKey(ctx context.Context, keyName, session string, timeout time.Duration) error {
+	if m, ok := r.memory(); ok {
+		return m.SetRawKey(ctx, keyName, session, timeout)
+	}
+
 	if err := r.up(); err != nil {
 		return err
 	}
-	err := r.singleton().Set(keyName, session, timeout).Err()
+	err := r.client(ctx).Set(rawKey(keyName), session, timeout).Err()
 	if err != nil {
 		log.Errorf("Error trying to set value: %s", err.Error())
 
@@ -552,28 +696,70 @@ func (r *RedisCluster) SetRawKey(keyName, session string, timeout time.Duration)
 	return nil
 }
 
+// SetKeyIfNotExists sets the key only if it does not exist yet, like SET NX, and returns whether it
+// was set. The key expires after ttl unless ttl is 0.
+func (r *RedisCluster) SetKeyIfNotExists(ctx context.Context, keyName, value string, ttl time.Duration) (bool, error) {
+	if m, ok := r.memory(); ok {
+		return m.SetKeyIfNotExists(ctx, keyName, value, ttl)
+	}
+
+	if err := r.up(); err != nil {
+		return false, err
+	}
+
+	ok, err := r.client(ctx).SetNX(r.fixKey(keyName), value, ttl).Result()
+	if err != nil {
+		log.Errorf("Error trying to set value if not exists: %s", err.Error())
+
+		return false, err
+	}
+
+	return ok, nil
+}
+
+// SetKeyIfExists sets the key only if it already exists, like SET XX, and returns whether it was
+// set. The key expires after ttl unless ttl is 0, which removes its expiration like SetKey.
+func (r *RedisCluster) SetKeyIfExists(ctx context.Context, keyName, value string, ttl time.Duration) (bool, error) {
+	if m, ok := r.memory(); ok {
+		return m.SetKeyIfExists(ctx, keyName, value, ttl)
+	}
+
+	if err := r.up(); err != nil {
+		return false, err
+	}
+
+	ok, err := r.client(ctx).SetXX(r.fixKey(keyName), value, ttl).Result()
+	if err != nil {
+		log.Errorf("Error trying to set value if exists: %s", err.Error())
+
+		return false, err
+	}
+
+	return ok, nil
+}
+
 // Decrement will decrement a key in redis.
-func (r *RedisCluster) Decrement(keyName string) {
+func (r *RedisCluster) Decrement(ctx context.Context, keyName string) {
 	keyName = r.fixKey(keyName)
 	log.Debugf("Decrementing key: %s", keyName)
 	if err := r.up(); err != nil {
 		return
 	}
-	err := r.singleton().Decr(keyName).Err()
+	err := r.client(ctx).Decr(keyName).Err()
 	if err != nil {
 		log.Errorf("Error trying to decrement value: %s", err.Error())
 	}
 }
 
 // IncrememntWithExpire will increment a key in redis.
-func (r *RedisCluster) IncrememntWithExpire(keyName string, expire int64) int64 {
+func (r *RedisCluster) IncrememntWithExpire(ctx context.Context, keyName string, expire int64) int64 {
 	log.Debugf("Incrementing raw key: %s", keyName)
 	if err := r.up(); err != nil {
 		return 0
 	}
 	// This function uses a raw key, so we shouldn't call fixKey
-	fixedKey := keyName
-	val, err := r.singleton().Incr(fixedKey).Result()
+	fixedKey := rawKey(keyName)
+	val, err := r.client(ctx).Incr(fixedKey).Result()
 
 	if err != nil {
 		log.Errorf("Error trying to increment value: %s", err.Error())
@@ -583,89 +769,115 @@ func (r *RedisCluster) IncrememntWithExpire(keyName string, expire int64) int64
 
 	if val == 1 && expire > 0 {
 		log.Debug("--> Setting Expire")
-		r.singleton().Expire(fixedKey, time.Duration(expire)*time.Second)
+		r.client(ctx).Expire(fixedKey, time.Duration(expire)*time.Second)
 	}
 
 	return val
 }
 
-// GetKeys will return all keys according to the filter (filter is a prefix - e.g. tyk.keys.*).
-func (r *RedisCluster) GetKeys(filter string) []string {
+// ScanKeys iterates over the keys matching pattern with SCAN, on every master in cluster mode, and
+// calls fn with each batch of keys, so the whole key space is never held in memory nor blocks redis
+// like KEYS. The pattern and the keys are not prefixed by the KeyPrefix, but they are relative to the
+// KeyNamespace: only the keys of the namespace are scanned. count is the number of keys hinted to each
+// SCAN, the default of redis if 0. The calls to fn are serialized, the scan stops at the first error
+// returned by fn or when ctx is done.
+//
+// SCAN only guarantees that the keys present during the whole scan are passed to fn: a key may be
+// passed several times, and a key added or deleted during the scan may or may not be passed. The
+// scan terminates as long as the key space does not grow without bounds while it runs.
+func (r *RedisCluster) ScanKeys(ctx context.Context, pattern string, count int64, fn func(keys []string) error) error {
 	if err := r.up(); err != nil {
-		return nil
+		return err
 	}
-	client := r.singleton()
 
-	filterHash := ""
-	if filter != "" {
-		filterHash = r.hashKey(filter)
-	}
-	searchStr := r.KeyPrefix + filterHash + "*"
-	log.Debugf("[STORE] Getting list by: %s", searchStr)
+	var mu sync.Mutex
 
-	fnFetchKeys := func(client *redis.Client) ([]string, error) {
-		values := make([]string, 0)
+	pattern = rawKey(pattern)
 
-		iter := client.Scan(0, searchStr, 0).Iterator()
-		for iter.Next() {
-			values = append(values, iter.Val())
-		}
+	scan := func(client *redis.Client) error {
+		var cursor uint64
 
-		if err := iter.Err(); err != nil {
-			return nil, err
-		}
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 
-		return values, nil
-	}
+			keys, next, err := client.Scan(cursor, pattern, count).Result()
+			if err != nil {
+				return err
+			}
 
-	var err error
-	var values []string
-	sessions := make([]string, 0)
+			if len(keys) > 0 {
+				for i, key := range keys {
+					keys[i] = stripNamespace(key)
+				}
 
-	switch v := client.(type) {
-	case *redis.ClusterClient:
-		ch := make(chan []string)
+				mu.Lock()
+				err = fn(keys)
+				mu.Unlock()
 
-		go func() {
-			err = v.ForEachMaster(func(client *redis.Client) error {
-				values, err = fnFetchKeys(client)
 				if err != nil {
 					return err
 				}
+			}
 
-				ch <- values
-
+			if next == 0 {
 				return nil
-			})
-			close(ch)
-		}()
+			}
 
-		for res := range ch {
-			sessions = append(sessions, res...)
+			cursor = next
 		}
+	}
+
+	switch v := r.client(ctx).(type) {
+	case *redis.ClusterClient:
+		return v.ForEachMaster(func(client *redis.Client) error {
+			return scan(client.WithContext(ctx))
+		})
 	case *redis.Client:
-		sessions, err = fnFetchKeys(v)
+		return scan(v)
+	default:
+		return fmt.Errorf("storage: scan is not supported by redis client %T", v)
+	}
+}
+
+// GetKeys will return all keys according to the filter (filter is a prefix - e.g. tyk.keys.*).
+func (r *RedisCluster) GetKeys(ctx context.Context, filter string) []string {
+	if err := r.up(); err != nil {
+		return nil
+	}
+
+	filterHash := ""
+	if filter != "" {
+		filterHash = r.hashKey(filter)
 	}
+	searchStr := r.KeyPrefix + filterHash + "*"
+	log.Debugf("[STORE] Getting list by: %s", searchStr)
+
+	sessions := make([]string, 0)
+
+	err := r.ScanKeys(ctx, searchStr, 0, func(keys []string) error {
+		for _, key := range keys {
+			sessions = append(sessions, r.cleanKey(key))
+		}
 
+		return nil
+	})
 	if err != nil {
 		log.Errorf("Error while fetching keys: %s", err)
 
 		return nil
 	}
 
-	for i, v := range sessions {
-		sessions[i] = r.cleanKey(v)
-	}
-
 	return sessions
 }
 
 // GetKeysAndValuesWithFilter will return all keys and their values with a filter.
-func (r *RedisCluster) GetKeysAndValuesWithFilter(filter string) map[string]string {
+func (r *RedisCluster) GetKeysAndValuesWithFilter(ctx context.Context, filter string) map[string]string {
 	if err := r.up(); err != nil {
 		return nil
 	}
-	keys := r.GetKeys(filter)
+	keys := r.GetKeys(ctx, filter)
 	if keys == nil {
 		log.Error("Error trying to get filtered client keys")
 
@@ -676,11 +888,12 @@ func (r *RedisCluster) GetKeysAndValuesWithFilter(filter string) map[string]stri
 		return nil
 	}
 
+	fixedKeys := make([]string, len(keys))
 	for i, v := range keys {
-		keys[i] = r.KeyPrefix + v
+		fixedKeys[i] = rawKey(r.KeyPrefix + v)
 	}
 
-	client := r.singleton()
+	client := r.client(ctx)
 	values := make([]string, 0)
 
 	switch v := client.(type) {
@@ -688,7 +901,7 @@ func (r *RedisCluster) GetKeysAndValuesWithFilter(filter string) map[string]stri
 		{
 			getCmds := make([]*redis.StringCmd, 0)
 			pipe := v.Pipeline()
-			for _, key := range keys {
+			for _, key := range fixedKeys {
 				getCmds = append(getCmds, pipe.Get(key))
 			}
 			_, err := pipe.Exec()
@@ -704,7 +917,7 @@ func (r *RedisCluster) GetKeysAndValuesWithFilter(filter string) map[string]stri
 		}
 	case *redis.Client:
 		{
-			result, err := v.MGet(keys...).Result()
+			result, err := v.MGet(fixedKeys...).Result()
 			if err != nil {
 				log.Errorf("Error trying to get client keys: %s", err.Error())
 
@@ -723,26 +936,30 @@ func (r *RedisCluster) GetKeysAndValuesWithFilter(filter string) map[string]stri
 
 	m := make(map[string]string)
 	for i, v := range keys {
-		m[r.cleanKey(v)] = values[i]
+		m[v] = values[i]
 	}
 
 	return m
 }
 
 // GetKeysAndValues will return all keys and their values - not to be used lightly.
-func (r *RedisCluster) GetKeysAndValues() map[string]string {
-	return r.GetKeysAndValuesWithFilter("")
+func (r *RedisCluster) GetKeysAndValues(ctx context.Context) map[string]string {
+	return r.GetKeysAndValuesWithFilter(ctx, "")
 }
 
 // DeleteKey will remove a key from the database.
-func (r *RedisCluster) DeleteKey(keyName string) bool {
+func (r *RedisCluster) DeleteKey(ctx context.Context, keyName string) bool {
+	if m, ok := r.memory(); ok {
+		return m.DeleteKey(ctx, keyName)
+	}
+
 	if err := r.up(); err != nil {
 		// log.Debug(err)
 		return false
 	}
 	log.Debugf("DEL Key was: %s", keyName)
 	log.Debugf("DEL Key became: %s", r.fixKey(keyName))
-	n, err := r.singleton().Del(r.fixKey(keyName)).Result()
+	n, err := r.client(ctx).Del(r.fixKey(keyName)).Result()
 	if err != nil {
 		log.Errorf("Error trying to delete key: %s", err.Error())
 	}
@@ -750,12 +967,18 @@ func (r *RedisCluster) DeleteKey(keyName string) bool {
 	return n > 0
 }
 
-// DeleteAllKeys will remove all keys from the database.
-func (r *RedisCluster) DeleteAllKeys() bool {
+// DeleteAllKeys will remove all keys from the database, only the keys of the KeyNamespace if any.
+func (r *RedisCluster) DeleteAllKeys(ctx context.Context) bool {
 	if err := r.up(); err != nil {
 		return false
 	}
-	n, err := r.singleton().FlushAll().Result()
+
+	// the other namespaces are not ours to flush.
+	if KeyNamespace() != "" {
+		return r.DeleteScanMatch(ctx, "*")
+	}
+
+	n, err := r.client(ctx).FlushAll().Result()
 	if err != nil {
 		log.Errorf("Error trying to delete keys: %s", err.Error())
 	}
@@ -767,12 +990,16 @@ func (r *RedisCluster) DeleteAllKeys() bool {
 	return false
 }
 
-// DeleteRawKey will remove a key from the database without prefixing, assumes user knows what they are doing.
-func (r *RedisCluster) DeleteRawKey(keyName string) bool {
+// DeleteRawKey will remove a key from the database without the KeyPrefix, assumes user knows what they are doing.
+func (r *RedisCluster) DeleteRawKey(ctx context.Context, keyName string) bool {
+	if m, ok := r.memory(); ok {
+		return m.DeleteRawKey(ctx, keyName)
+	}
+
 	if err := r.up(); err != nil {
 		return false
 	}
-	n, err := r.singleton().Del(keyName).Result()
+	n, err := r.client(ctx).Del(rawKey(keyName)).Result()
 	if err != nil {
 		log.Errorf("Error trying to delete key: %s", err.Error())
 	}
@@ -780,72 +1007,48 @@ func (r *RedisCluster) DeleteRawKey(keyName string) bool {
 	return n > 0
 }
 
-// DeleteScanMatch will remove a group of keys in bulk.
-func (r *RedisCluster) DeleteScanMatch(pattern string) bool {
+// DeleteScanMatch will remove a group of keys in bulk, the keys are deleted batch by batch while
+// they are scanned.
+func (r *RedisCluster) DeleteScanMatch(ctx context.Context, pattern string) bool {
 	if err := r.up(); err != nil {
 		return false
 	}
-	client := r.singleton()
+	client := r.client(ctx)
 	log.Debugf("Deleting: %s", pattern)
 
-	fnScan := func(client *redis.Client) ([]string, error) {
-		values := make([]string, 0)
+	var deleted int
 
-		iter := client.Scan(0, pattern, 0).Iterator()
-		for iter.Next() {
-			values = append(values, iter.Val())
-		}
+	err := r.ScanKeys(ctx, pattern, 0, func(keys []string) error {
+		log.Infof("Deleting: %v", keys)
 
-		if err := iter.Err(); err != nil {
-			return nil, err
+		// the keys of a batch may be in different slots, they are deleted one by one.
+		pipe := client.Pipeline()
+		for _, name := range keys {
+			pipe.Del(rawKey(name))
 		}
 
-		return values, nil
-	}
-
-	var err error
-	var keys []string
-	var values []string
-
-	switch v := client.(type) {
-	case *redis.ClusterClient:
-		ch := make(chan []string)
-		go func() {
-			err = v.ForEachMaster(func(client *redis.Client) error {
-				values, err = fnScan(client)
-				if err != nil {
-					return err
-				}
-
-				ch <- values
+		// the failures are logged per key, a redis which is down fails the next SCAN anyway.
+		cmds, _ := pipe.Exec()
+		for i, cmd := range cmds {
+			if err := cmd.Err(); err != nil {
+				log.Errorf("Error trying to delete key: %s - %s", keys[i], err.Error())
 
-				return nil
-			})
-			close(ch)
-		}()
+				continue
+			}
 
-		for vals := range ch {
-			keys = append(keys, vals...)
+			deleted++
 		}
-	case *redis.Client:
-		keys, err = fnScan(v)
-	}
 
+		return nil
+	})
 	if err != nil {
 		log.Errorf("SCAN command field with err: %s", err.Error())
 
 		return false
 	}
 
-	if len(keys) > 0 {
-		for _, name := range keys {
-			log.Infof("Deleting: %s", name)
-			err := client.Del(name).Err()
-			if err != nil {
-				log.Errorf("Error trying to delete key: %s - %s", name, err.Error())
-			}
-		}
-		log.Infof("Deleted: %d records", len(keys))
+	if deleted > 0 {
+		log.Infof("Deleted: %d records", deleted)
 	} else {
 		log.Debug("RedisCluster called DEL - Nothing to delete")
 	}
@@ -854,7 +1057,7 @@ func (r *RedisCluster) DeleteScanMatch(pattern string) bool {
 }
 
 // DeleteKeys will remove a group of keys in bulk.
-func (r *RedisCluster) DeleteKeys(keys []string) bool {
+func (r *RedisCluster) DeleteKeys(ctx context.Context, keys []string) bool {
 	if err := r.up(); err != nil {
 		return false
 	}
@@ -864,7 +1067,7 @@ func (r *RedisCluster) DeleteKeys(keys []string) bool {
 		}
 
 		log.Debugf("Deleting: %v", keys)
-		client := r.singleton()
+		client := r.client(ctx)
 		switch v := client.(type) {
 		case *redis.ClusterClient:
 			{
@@ -893,17 +1096,17 @@ func (r *RedisCluster) DeleteKeys(keys []string) bool {
 }
 
 // StartPubSubHandler will listen for a signal and run the callback for
-// every subscription and message event.
-func (r *RedisCluster) StartPubSubHandler(channel string, callback func(interface{})) error {
+// every subscription and message event, until ctx is done.
+func (r *RedisCluster) StartPubSubHandler(ctx context.Context, channel string, callback func(interface{})) error {
 	if err := r.up(); err != nil {
 		return err
 	}
-	client := r.singleton()
+	client := r.client(ctx)
 	if client == nil {
 		return errors.New("redis connection failed")
 	}
 
-	pubsub := client.Subscribe(channel)
+	pubsub := client.Subscribe(channelName(channel))
 	defer pubsub.Close()
 
 	if _, err := pubsub.Receive(); err != nil {
@@ -912,19 +1115,32 @@ func (r *RedisCluster) StartPubSubHandler(channel string, callback func(interfac
 		return err
 	}
 
-	for msg := range pubsub.Channel() {
-		callback(msg)
-	}
+	ch := pubsub.Channel()
 
-	return nil
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+
+			callback(msg)
+		}
+	}
 }
 
 // Publish publish a message to the specify channel.
-func (r *RedisCluster) Publish(channel, message string) error {
+func (r *RedisCluster) Publish(ctx context.Context, channel, message string) error {
+	if m, ok := r.memory(); ok {
+		return m.Publish(ctx, channel, message)
+	}
+
 	if err := r.up(); err != nil {
 		return err
 	}
-	err := r.singleton().Publish(channel, message).Err()
+	err := r.client(ctx).Publish(channelName(channel), message).Err()
 	if err != nil {
 		log.Errorf("Error trying to set value: %s", err.Error())
 
@@ -935,7 +1151,11 @@ func (r *RedisCluster) Publish(channel, message string) error {
 }
 
 // GetAndDeleteSet get and delete a key.
-func (r *RedisCluster) GetAndDeleteSet(keyName string) []interface{} {
+func (r *RedisCluster) GetAndDeleteSet(ctx context.Context, keyName string) []interface{} {
+	if m, ok := r.memory(); ok {
+		return m.GetAndDeleteSet(ctx, keyName)
+	}
+
 	log.Debugf("Getting raw key set: %s", keyName)
 	if err := r.up(); err != nil {
 		return nil
@@ -944,7 +1164,7 @@ func (r *RedisCluster) GetAndDeleteSet(keyName string) []interface{} {
 	fixedKey := r.fixKey(keyName)
 	log.Debugf("Fixed keyname is: %s", fixedKey)
 
-	client := r.singleton()
+	client := r.client(ctx)
 
 	var lrange *redis.StringSliceCmd
 	_, err := client.TxPipelined(func(pipe redis.Pipeliner) error {
@@ -975,24 +1195,34 @@ func (r *RedisCluster) GetAndDeleteSet(keyName string) []interface{} {
 }
 
 // AppendToSet append a value to the key set.
-func (r *RedisCluster) AppendToSet(keyName, value string) {
+func (r *RedisCluster) AppendToSet(ctx context.Context, keyName, value string) {
+	if m, ok := r.memory(); ok {
+		m.AppendToSet(ctx, keyName, value)
+
+		return
+	}
+
 	fixedKey := r.fixKey(keyName)
 	log.Debug("Pushing to raw key list", log.String("keyName", keyName))
 	log.Debug("Appending to fixed key list", log.String("fixedKey", fixedKey))
 	if err := r.up(); err != nil {
 		return
 	}
-	if err := r.singleton().RPush(fixedKey, value).Err(); err != nil {
+	if err := r.client(ctx).RPush(fixedKey, value).Err(); err != nil {
 		log.Errorf("Error trying to append to set keys: %s", err.Error())
 	}
 }
 
 // Exists check if keyName exists.
-func (r *RedisCluster) Exists(keyName string) (bool, error) {
+func (r *RedisCluster) Exists(ctx context.Context, keyName string) (bool, error) {
+	if m, ok := r.memory(); ok {
+		return m.Exists(ctx, keyName)
+	}
+
 	fixedKey := r.fixKey(keyName)
 	log.Debug("Checking if exists", log.String("keyName", fixedKey))
 
-	exists, err := r.singleton().Exists(fixedKey).Result()
+	exists, err := r.client(ctx).Exists(fixedKey).Result()
 	if err != nil {
 		log.Errorf("Error trying to check if key exists: %s", err.Error())
 
@@ -1006,7 +1236,7 @@ func (r *RedisCluster) Exists(keyName string) (bool, error) {
 }
 
 // RemoveFromList delete an value from a list idetinfied with the keyName.
-func (r *RedisCluster) RemoveFromList(keyName, value string) error {
+func (r *RedisCluster) RemoveFromList(ctx context.Context, keyName, value string) error {
 	fixedKey := r.fixKey(keyName)
 
 	log.Debug(
@@ -1016,7 +1246,7 @@ func (r *RedisCluster) RemoveFromList(keyName, value string) error {
 		log.String("value", value),
 	)
 
-	if err := r.singleton().LRem(fixedKey, 0, value).Err(); err != nil {
+	if err := r.client(ctx).LRem(fixedKey, 0, value).Err(); err != nil {
 		log.Error(
 			"LREM command failed",
 			log.String("keyName", keyName),
@@ -1032,10 +1262,14 @@ func (r *RedisCluster) RemoveFromList(keyName, value string) error {
 }
 
 // GetListRange gets range of elements of list identified by keyName.
-func (r *RedisCluster) GetListRange(keyName string, from, to int64) ([]string, error) {
+func (r *RedisCluster) GetListRange(ctx context.Context, keyName string, from, to int64) ([]string, error) {
+	if m, ok := r.memory(); ok {
+		return m.GetListRange(ctx, keyName, from, to)
+	}
+
 	fixedKey := r.fixKey(keyName)
 
-	elements, err := r.singleton().LRange(fixedKey, from, to).Result()
+	elements, err := r.client(ctx).LRange(fixedKey, from, to).Result()
 	if err != nil {
 		log.Error(
 			"LRANGE command failed",
@@ -1056,7 +1290,13 @@ func (r *RedisCluster) GetListRange(keyName string, from, to int64) ([]string, e
 }
 
 // AppendToSetPipelined append values to redis pipeline.
-func (r *RedisCluster) AppendToSetPipelined(key string, values [][]byte) {
+func (r *RedisCluster) AppendToSetPipelined(ctx context.Context, key string, values [][]byte) {
+	if m, ok := r.memory(); ok {
+		m.AppendToSetPipelined(ctx, key, values)
+
+		return
+	}
+
 	if len(values) == 0 {
 		return
 	}
@@ -1067,7 +1307,7 @@ func (r *RedisCluster) AppendToSetPipelined(key string, values [][]byte) {
 
 		return
 	}
-	client := r.singleton()
+	client := r.client(ctx)
 
 	pipe := client.Pipeline()
 	for _, val := range values {
@@ -1075,27 +1315,31 @@ func (r *RedisCluster) AppendToSetPipelined(key string, values [][]byte) {
 	}
 
 	if _, err := pipe.Exec(); err != nil {
-		log.Errorf("Error trying to append to set keys: %s", err.Error())
+		appendErrorLog.Errorf("Error trying to append to set keys: %s", err.Error())
 	}
 
 	// if we need to set an expiration time
 	if storageExpTime := int64(viper.GetDuration("analytics.storage-expiration-time")); storageExpTime != int64(-1) {
 		// If there is no expiry on the analytics set, we should set it.
-		exp, _ := r.GetExp(key)
+		exp, _ := r.GetExp(ctx, key)
 		if exp == -1 {
-			_ = r.SetExp(key, time.Duration(storageExpTime)*time.Second)
+			_ = r.SetExp(ctx, key, time.Duration(storageExpTime)*time.Second)
 		}
 	}
 }
 
 // GetSet return key set value.
-func (r *RedisCluster) GetSet(keyName string) (map[string]string, error) {
+func (r *RedisCluster) GetSet(ctx context.Context, keyName string) (map[string]string, error) {
+	if m, ok := r.memory(); ok {
+		return m.GetSet(ctx, keyName)
+	}
+
 	log.Debugf("Getting from key set: %s", keyName)
 	log.Debugf("Getting from fixed key set: %s", r.fixKey(keyName))
 	if err := r.up(); err != nil {
 		return nil, err
 	}
-	val, err := r.singleton().SMembers(r.fixKey(keyName)).Result()
+	val, err := r.client(ctx).SMembers(r.fixKey(keyName)).Result()
 	if err != nil {
 		log.Errorf("Error trying to get key set: %s", err.Error())
 
@@ -1111,20 +1355,32 @@ func (r *RedisCluster) GetSet(keyName string) (map[string]string, error) {
 }
 
 // AddToSet add value to key set.
-func (r *RedisCluster) AddToSet(keyName, value string) {
+func (r *RedisCluster) AddToSet(ctx context.Context, keyName, value string) {
+	if m, ok := r.memory(); ok {
+		m.AddToSet(ctx, keyName, value)
+
+		return
+	}
+
 	log.Debugf("Pushing to raw key set: %s", keyName)
 	log.Debugf("Pushing to fixed key set: %s", r.fixKey(keyName))
 	if err := r.up(); err != nil {
 		return
 	}
-	err := r.singleton().SAdd(r.fixKey(keyName), value).Err()
+	err := r.client(ctx).SAdd(r.fixKey(keyName), value).Err()
 	if err != nil {
 		log.Errorf("Error trying to append keys: %s", err.Error())
 	}
 }
 
 // RemoveFromSet remove a value from key set.
-func (r *RedisCluster) RemoveFromSet(keyName, value string) {
+func (r *RedisCluster) RemoveFromSet(ctx context.Context, keyName, value string) {
+	if m, ok := r.memory(); ok {
+		m.RemoveFromSet(ctx, keyName, value)
+
+		return
+	}
+
 	log.Debugf("Removing from raw key set: %s", keyName)
 	log.Debugf("Removing from fixed key set: %s", r.fixKey(keyName))
 	if err := r.up(); err != nil {
@@ -1132,20 +1388,24 @@ func (r *RedisCluster) RemoveFromSet(keyName, value string) {
 
 		return
 	}
-	err := r.singleton().SRem(r.fixKey(keyName), value).Err()
+	err := r.client(ctx).SRem(r.fixKey(keyName), value).Err()
 	if err != nil {
 		log.Errorf("Error trying to remove keys: %s", err.Error())
 	}
 }
 
 // IsMemberOfSet return whether the given value belong to key set.
-func (r *RedisCluster) IsMemberOfSet(keyName, value string) bool {
+func (r *RedisCluster) IsMemberOfSet(ctx context.Context, keyName, value string) bool {
+	if m, ok := r.memory(); ok {
+		return m.IsMemberOfSet(ctx, keyName, value)
+	}
+
 	if err := r.up(); err != nil {
 		log.Debug(err.Error())
 
 		return false
 	}
-	val, err := r.singleton().SIsMember(r.fixKey(keyName), value).Result()
+	val, err := r.client(ctx).SIsMember(r.fixKey(keyName), value).Result()
 	if err != nil {
 		log.Errorf("Error trying to check set member: %s", err.Error())
 
@@ -1159,6 +1419,7 @@ func (r *RedisCluster) IsMemberOfSet(keyName, value string) bool {
 
 // SetRollingWindow will append to a sorted set in redis and extract a timed window of values.
 func (r *RedisCluster) SetRollingWindow(
+	ctx context.Context,
 	keyName string,
 	per int64,
 	valueOverride string,
@@ -1170,13 +1431,14 @@ func (r *RedisCluster) SetRollingWindow(
 
 		return 0, nil
 	}
+	keyName = rawKey(keyName)
 	log.Debugf("keyName is: %s", keyName)
 	now := time.Now()
 	log.Debugf("Now is: %v", now)
 	onePeriodAgo := now.Add(time.Duration(-1*per) * time.Second)
 	log.Debugf("Then is: %v", onePeriodAgo)
 
-	client := r.singleton()
+	client := r.client(ctx)
 	var zrange *redis.StringSliceCmd
 
 	pipeFn := func(pipe redis.Pipeliner) error {
@@ -1232,16 +1494,17 @@ func (r *RedisCluster) SetRollingWindow(
 }
 
 // GetRollingWindow return rolling window.
-func (r RedisCluster) GetRollingWindow(keyName string, per int64, pipeline bool) (int, []interface{}) {
+func (r RedisCluster) GetRollingWindow(ctx context.Context, keyName string, per int64, pipeline bool) (int, []interface{}) {
 	if err := r.up(); err != nil {
 		log.Debug(err.Error())
 
 		return 0, nil
 	}
+	keyName = rawKey(keyName)
 	now := time.Now()
 	onePeriodAgo := now.Add(time.Duration(-1*per) * time.Second)
 
-	client := r.singleton()
+	client := r.client(ctx)
 	var zrange *redis.StringSliceCmd
 
 	pipeFn := func(pipe redis.Pipeliner) error {
@@ -1287,7 +1550,7 @@ func (r *RedisCluster) GetKeyPrefix() string {
 }
 
 // AddToSortedSet adds value with given score to sorted set identified by keyName.
-func (r *RedisCluster) AddToSortedSet(keyName, value string, score float64) {
+func (r *RedisCluster) AddToSortedSet(ctx context.Context, keyName, value string, score float64) {
 	fixedKey := r.fixKey(keyName)
 
 	log.Debug("Pushing raw key to sorted set", log.String("keyName", keyName), log.String("fixedKey", fixedKey))
@@ -1298,7 +1561,7 @@ func (r *RedisCluster) AddToSortedSet(keyName, value string, score float64) {
 		return
 	}
 	member := redis.Z{Score: score, Member: value}
-	if err := r.singleton().ZAdd(fixedKey, &member).Err(); err != nil {
+	if err := r.client(ctx).ZAdd(fixedKey, &member).Err(); err != nil {
 		log.Error(
 			"ZADD command failed",
 			log.String("keyName", keyName),
@@ -1309,7 +1572,7 @@ func (r *RedisCluster) AddToSortedSet(keyName, value string, score float64) {
 }
 
 // GetSortedSetRange gets range of elements of sorted set identified by keyName.
-func (r *RedisCluster) GetSortedSetRange(keyName, scoreFrom, scoreTo string) ([]string, []float64, error) {
+func (r *RedisCluster) GetSortedSetRange(ctx context.Context, keyName, scoreFrom, scoreTo string) ([]string, []float64, error) {
 	fixedKey := r.fixKey(keyName)
 	log.Debug(
 		"Getting sorted set range",
@@ -1323,7 +1586,7 @@ func (r *RedisCluster) GetSortedSetRange(keyName, scoreFrom, scoreTo string) ([]
 	)
 
 	args := redis.ZRangeBy{Min: scoreFrom, Max: scoreTo}
-	values, err := r.singleton().ZRangeByScoreWithScores(fixedKey, &args).Result()
+	values, err := r.client(ctx).ZRangeByScoreWithScores(fixedKey, &args).Result()
 	if err != nil {
 		log.Error(
 			"ZRANGEBYSCORE command failed",
@@ -1356,7 +1619,7 @@ func (r *RedisCluster) GetSortedSetRange(keyName, scoreFrom, scoreTo string) ([]
 }
 
 // RemoveSortedSetRange removes range of elements from sorted set identified by keyName.
-func (r *RedisCluster) RemoveSortedSetRange(keyName, scoreFrom, scoreTo string) error {
+func (r *RedisCluster) RemoveSortedSetRange(ctx context.Context, keyName, scoreFrom, scoreTo string) error {
 	fixedKey := r.fixKey(keyName)
 
 	log.Debug(
@@ -1370,7 +1633,7 @@ func (r *RedisCluster) RemoveSortedSetRange(keyName, scoreFrom, scoreTo string)
 		log.String("scoreTo", scoreTo),
 	)
 
-	if err := r.singleton().ZRemRangeByScore(fixedKey, scoreFrom, scoreTo).Err(); err != nil {
+	if err := r.client(ctx).ZRemRangeByScore(fixedKey, scoreFrom, scoreTo).Err(); err != nil {
 		log.Debug(
 			"ZREMRANGEBYSCORE command failed",
 			log.String("keyName", keyName),
@@ -0,0 +1,650 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+)
+
+// useClient makes the storage use the client during the test.
+func useClient(tb testing.TB, client redis.UniversalClient) {
+	tb.Helper()
+
+	// the pool can not hold clients of different types
+	singlePool = atomic.Value{}
+	singlePool.Store(client)
+	redisUp.Store(true)
+
+	tb.Cleanup(func() {
+		redisUp.Store(false)
+		singlePool = atomic.Value{}
+		client.Close()
+	})
+}
+
+// blockedRedis starts a server which accepts the connections but never replies, the
+// commands sent to it stay blocked until the read timeout of the client.
+func blockedRedis(t *testing.T) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	var conns []net.Conn
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			conns = append(conns, conn)
+		}
+	}()
+
+	useClient(t, redis.NewClient(&redis.Options{
+		Addr:        ln.Addr().String(),
+		PoolSize:    1,
+		ReadTimeout: 10 * time.Second,
+	}))
+
+	t.Cleanup(func() {
+		ln.Close()
+		<-done
+
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+}
+
+func TestRedisCluster_SetKey_Context(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     func() (context.Context, context.CancelFunc)
+		wantErr error
+	}{
+		{
+			name: "deadline exceeded",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 100*time.Millisecond)
+			},
+		},
+		{
+			name: "canceled",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				return ctx, cancel
+			},
+			wantErr: context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blockedRedis(t)
+
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			r := &RedisCluster{}
+			start := time.Now()
+
+			err := r.SetKey(ctx, "key", "value", 0)
+			if err == nil {
+				t.Fatal("SetKey() error = nil, want an error")
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("SetKey() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("SetKey() returned after %v, want it to be aborted promptly", elapsed)
+			}
+		})
+	}
+}
+
+func TestRedisCluster_SetKey_CancelWhileBlocked(t *testing.T) {
+	blockedRedis(t)
+
+	r := &RedisCluster{}
+
+	// the first command holds the only connection of the pool
+	holdCtx, holdCancel := context.WithTimeout(context.Background(), time.Second)
+	defer holdCancel()
+
+	held := make(chan struct{})
+
+	go func() {
+		defer close(held)
+		_ = r.SetKey(holdCtx, "held", "value", 0)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- r.SetKey(ctx, "key", "value", 0)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("SetKey() error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Error("SetKey() is still blocked after its context is canceled")
+	}
+
+	<-held
+}
+
+func TestRedisOpts_Credentials(t *testing.T) {
+	config := &Config{
+		Addrs:            []string{"10.0.0.1:6379"},
+		MasterName:       "mymaster",
+		Username:         "iam",
+		Password:         "data-secret",
+		SentinelUsername: "sentinel",
+		SentinelPassword: "sentinel-secret",
+	}
+	opts := &RedisOpts{
+		Addrs:      getRedisAddrs(config),
+		MasterName: config.MasterName,
+		Username:   config.Username,
+		Password:   config.Password,
+	}
+
+	failover := opts.failover(config)
+	if failover.SentinelUsername != "sentinel" || failover.SentinelPassword != "sentinel-secret" {
+		t.Errorf("failover() sentinel credentials = %q/%q, want sentinel/sentinel-secret",
+			failover.SentinelUsername, failover.SentinelPassword)
+	}
+	if failover.Username != "iam" || failover.Password != "data-secret" {
+		t.Errorf("failover() credentials = %q/%q, want iam/data-secret", failover.Username, failover.Password)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{name: "simple", username: opts.simple().Username, password: opts.simple().Password},
+		{name: "cluster", username: opts.cluster().Username, password: opts.cluster().Password},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.username != "iam" || tt.password != "data-secret" {
+				t.Errorf("credentials = %q/%q, want iam/data-secret", tt.username, tt.password)
+			}
+		})
+	}
+}
+
+func TestRedisOpts_SentinelAddrs(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   []string
+	}{
+		{
+			name:   "sentinel addrs",
+			config: &Config{Addrs: []string{"10.0.0.1:6379"}, SentinelAddrs: []string{"10.0.0.2:26379"}},
+			want:   []string{"10.0.0.2:26379"},
+		},
+		{
+			name:   "fallback to addrs",
+			config: &Config{Addrs: []string{"10.0.0.1:26379"}},
+			want:   []string{"10.0.0.1:26379"},
+		},
+		{
+			name:   "default",
+			config: &Config{},
+			want:   []string{"127.0.0.1:26379"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &RedisOpts{Addrs: getRedisAddrs(tt.config), MasterName: "mymaster"}
+			got := opts.failover(tt.config).SentinelAddrs
+			if len(got) != len(tt.want) || got[0] != tt.want[0] {
+				t.Errorf("failover().SentinelAddrs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// scanServer is a redis server which only knows SCAN and DEL. Like redis, the cursor of a key
+// does not change while the key space changes.
+type scanServer struct {
+	mu sync.Mutex
+	// keys holds the keys in cursor order, the deleted keys are empty
+	keys []string
+	// scans is the number of SCAN commands received
+	scans int
+	// onScan is called on each SCAN command, it can change the key space
+	onScan func(s *scanServer)
+	addr   string
+}
+
+func (s *scanServer) add(keys ...string) {
+	s.keys = append(s.keys, keys...)
+}
+
+func (s *scanServer) del(key string) int {
+	for i, k := range s.keys {
+		if k == key {
+			s.keys[i] = ""
+
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func (s *scanServer) list() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for _, key := range s.keys {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+func (s *scanServer) reply(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch strings.ToLower(args[0]) {
+	case "scan":
+		s.scans++
+		if s.onScan != nil {
+			s.onScan(s)
+		}
+
+		cursor, _ := strconv.Atoi(args[1])
+		pattern, count := "*", 10
+
+		for i := 2; i+1 < len(args); i += 2 {
+			switch strings.ToLower(args[i]) {
+			case "match":
+				pattern = args[i+1]
+			case "count":
+				count, _ = strconv.Atoi(args[i+1])
+			}
+		}
+
+		var keys []string
+
+		next := cursor + count
+		if next > len(s.keys) {
+			next = len(s.keys)
+		}
+
+		for _, key := range s.keys[cursor:next] {
+			if ok, _ := path.Match(pattern, key); ok && key != "" {
+				keys = append(keys, key)
+			}
+		}
+
+		if next == len(s.keys) {
+			next = 0
+		}
+
+		reply := fmt.Sprintf("*2\r\n$%d\r\n%d\r\n*%d\r\n", len(strconv.Itoa(next)), next, len(keys))
+		for _, key := range keys {
+			reply += fmt.Sprintf("$%d\r\n%s\r\n", len(key), key)
+		}
+
+		return reply
+	case "del":
+		return fmt.Sprintf(":%d\r\n", s.del(args[1]))
+	case "cluster":
+		// a single master serves all the slots
+		host, port, _ := net.SplitHostPort(s.addr)
+
+		return fmt.Sprintf("*1\r\n*3\r\n:0\r\n:16383\r\n*2\r\n$%d\r\n%s\r\n:%s\r\n", len(host), host, port)
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+// scanRedis starts a scanServer holding the keys, used by the storage during the test.
+func scanRedis(t *testing.T, cluster bool, keys ...string) *scanServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	server := &scanServer{keys: keys, addr: ln.Addr().String()}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				reader := bufio.NewReader(conn)
+				for {
+					args, err := readCommand(reader)
+					if err != nil {
+						return
+					}
+
+					_, _ = conn.Write([]byte(server.reply(args)))
+				}
+			}(conn)
+		}
+	}()
+
+	if cluster {
+		useClient(t, redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{server.addr}}))
+	} else {
+		useClient(t, redis.NewClient(&redis.Options{Addr: server.addr}))
+	}
+
+	t.Cleanup(func() { ln.Close() })
+
+	return server
+}
+
+func TestRedisCluster_ScanKeys(t *testing.T) {
+	errStop := errors.New("stop")
+
+	tests := []struct {
+		name    string
+		keys    []string
+		pattern string
+		onScan  func(s *scanServer)
+		fn      func(batch []string) error
+		want    []string
+		wantErr error
+	}{
+		{
+			name:    "batches",
+			keys:    []string{"iam-a", "iam-b", "other", "iam-c", "iam-d"},
+			pattern: "iam-*",
+			want:    []string{"iam-a", "iam-b", "iam-c", "iam-d"},
+		},
+		{
+			name:    "keys added and deleted during the scan",
+			keys:    []string{"iam-a", "iam-b", "iam-c", "iam-d"},
+			pattern: "iam-*",
+			onScan: func(s *scanServer) {
+				s.add(fmt.Sprintf("iam-new-%d", s.scans))
+				s.del("iam-d")
+			},
+			// the keys present during the whole scan are all passed
+			want: []string{"iam-a", "iam-b", "iam-c"},
+		},
+		{
+			name:    "callback error",
+			keys:    []string{"iam-a", "iam-b", "iam-c", "iam-d"},
+			pattern: "iam-*",
+			fn:      func(batch []string) error { return errStop },
+			wantErr: errStop,
+		},
+	}
+	for _, mode := range []string{"single", "cluster"} {
+		for _, tt := range tests {
+			t.Run(mode+"/"+tt.name, func(t *testing.T) {
+				server := scanRedis(t, mode == "cluster", tt.keys...)
+				server.onScan = tt.onScan
+
+				seen := make(map[string]bool)
+				fn := func(batch []string) error {
+					if len(batch) > 2 {
+						t.Errorf("batch %q holds more keys than the count hint", batch)
+					}
+
+					for _, key := range batch {
+						seen[key] = true
+					}
+
+					if tt.fn != nil {
+						return tt.fn(batch)
+					}
+
+					return nil
+				}
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				err := (&RedisCluster{}).ScanKeys(ctx, tt.pattern, 2, fn)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ScanKeys() error = %v, want %v", err, tt.wantErr)
+				}
+
+				if tt.wantErr != nil {
+					server.mu.Lock()
+					defer server.mu.Unlock()
+
+					if server.scans != 1 {
+						t.Errorf("SCAN sent %d times, want the scan to stop after the first batch", server.scans)
+					}
+
+					return
+				}
+
+				for _, key := range tt.want {
+					if !seen[key] {
+						t.Errorf("key %s not scanned, scanned %v", key, seen)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestRedisCluster_DeleteScanMatch(t *testing.T) {
+	server := scanRedis(t, false, "iam-a", "other", "iam-b", "iam-c")
+
+	if !(&RedisCluster{}).DeleteScanMatch(context.Background(), "iam-*") {
+		t.Fatal("DeleteScanMatch() = false, want true")
+	}
+
+	if got := server.list(); !reflect.DeepEqual(got, []string{"other"}) {
+		t.Errorf("keys = %q, want [other]", got)
+	}
+}
+
+// fakeDialer fails the connections to redis as long as failures is positive, it records the time
+// of the attempts.
+type fakeDialer struct {
+	mu       sync.Mutex
+	failures int
+	attempts []time.Time
+}
+
+func (d *fakeDialer) connect(*Config) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.attempts = append(d.attempts, time.Now())
+	if d.failures > 0 {
+		d.failures--
+
+		return false
+	}
+
+	return true
+}
+
+func (d *fakeDialer) fail(n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.failures = n
+}
+
+func (d *fakeDialer) gaps() []time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	gaps := make([]time.Duration, 0, len(d.attempts))
+	for i := 1; i < len(d.attempts); i++ {
+		gaps = append(gaps, d.attempts[i].Sub(d.attempts[i-1]))
+	}
+
+	return gaps
+}
+
+// useDialer makes ConnectToRedis connect with the dialer during the test.
+func useDialer(t *testing.T, dialer *fakeDialer) {
+	t.Helper()
+
+	minBackoff, maxBackoff, checkInterval, dial := connectMinBackoff, connectMaxBackoff, connectCheckInterval, connect
+	t.Cleanup(func() {
+		connectMinBackoff, connectMaxBackoff, connectCheckInterval, connect = minBackoff, maxBackoff, checkInterval, dial
+		redisUp.Store(false)
+	})
+
+	connectMinBackoff = 20 * time.Millisecond
+	connectMaxBackoff = 80 * time.Millisecond
+	connectCheckInterval = 10 * time.Millisecond
+	connect = dialer.connect
+}
+
+// startConnect runs ConnectToRedis with the config until the end of the test, the returned channel
+// receives a value each time onConnected is called.
+func startConnect(t *testing.T, config *Config) chan struct{} {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	connected := make(chan struct{}, 10)
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ConnectToRedis(ctx, config, func() { connected <- struct{}{} })
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+
+	return connected
+}
+
+func TestConnectToRedis_Backoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		// wantMinGaps are the lower bounds of the delays between the failed attempts, the
+		// backoff is doubled up to the cap and the jitter waits at least half of it.
+		wantMinGaps []time.Duration
+	}{
+		{name: "first attempt", failures: 0},
+		{name: "one failure", failures: 1, wantMinGaps: []time.Duration{10 * time.Millisecond}},
+		{
+			name:     "capped",
+			failures: 4,
+			wantMinGaps: []time.Duration{
+				10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialer := &fakeDialer{failures: tt.failures}
+			useDialer(t, dialer)
+
+			connected := startConnect(t, &Config{})
+
+			select {
+			case <-connected:
+			case <-time.After(5 * time.Second):
+				t.Fatal("onConnected was not called")
+			}
+
+			if !Connected() {
+				t.Error("Connected() = false after onConnected was called")
+			}
+
+			gaps := dialer.gaps()
+			if len(gaps) != len(tt.wantMinGaps) {
+				t.Fatalf("%d attempts before connecting, want %d", len(gaps)+1, len(tt.wantMinGaps)+1)
+			}
+
+			for i, gap := range gaps {
+				if gap < tt.wantMinGaps[i] {
+					t.Errorf("retry %d after %v, want at least %v", i+1, gap, tt.wantMinGaps[i])
+				}
+			}
+		})
+	}
+}
+
+func TestConnectToRedis_Reconnect(t *testing.T) {
+	dialer := &fakeDialer{}
+	useDialer(t, dialer)
+
+	connected := startConnect(t, &Config{})
+
+	<-connected
+
+	// onConnected is not called again while the connection stays up.
+	select {
+	case <-connected:
+		t.Fatal("onConnected called while the connection stayed up")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	dialer.fail(2)
+
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("onConnected was not called after the reconnection")
+	}
+
+	if len(connected) != 0 {
+		t.Errorf("onConnected called %d more times, want once per connection", len(connected))
+	}
+}
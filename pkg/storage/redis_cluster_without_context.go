@@ -0,0 +1,204 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// RedisClusterWithoutContext provides the methods of RedisCluster without the context parameter,
+// as they were before the context was added. The operations run with context.Background(), so
+// they can not be canceled.
+//
+// Deprecated: use RedisCluster and pass a context instead, RedisClusterWithoutContext will be
+// removed in the next release.
+type RedisClusterWithoutContext struct {
+	RedisCluster
+}
+
+// GetKey calls RedisCluster.GetKey with context.Background().
+func (r *RedisClusterWithoutContext) GetKey(keyName string) (string, error) {
+	return r.RedisCluster.GetKey(context.Background(), keyName)
+}
+
+// GetMultiKey calls RedisCluster.GetMultiKey with context.Background().
+func (r *RedisClusterWithoutContext) GetMultiKey(keys []string) ([]string, error) {
+	return r.RedisCluster.GetMultiKey(context.Background(), keys)
+}
+
+// GetKeyTTL calls RedisCluster.GetKeyTTL with context.Background().
+func (r *RedisClusterWithoutContext) GetKeyTTL(keyName string) (int64, error) {
+	return r.RedisCluster.GetKeyTTL(context.Background(), keyName)
+}
+
+// GetRawKey calls RedisCluster.GetRawKey with context.Background().
+func (r *RedisClusterWithoutContext) GetRawKey(keyName string) (string, error) {
+	return r.RedisCluster.GetRawKey(context.Background(), keyName)
+}
+
+// GetExp calls RedisCluster.GetExp with context.Background().
+func (r *RedisClusterWithoutContext) GetExp(keyName string) (int64, error) {
+	return r.RedisCluster.GetExp(context.Background(), keyName)
+}
+
+// SetExp calls RedisCluster.SetExp with context.Background().
+func (r *RedisClusterWithoutContext) SetExp(keyName string, timeout time.Duration) error {
+	return r.RedisCluster.SetExp(context.Background(), keyName, timeout)
+}
+
+// SetKey calls RedisCluster.SetKey with context.Background().
+func (r *RedisClusterWithoutContext) SetKey(keyName, session string, timeout time.Duration) error {
+	return r.RedisCluster.SetKey(context.Background(), keyName, session, timeout)
+}
+
+// SetRawKey calls RedisCluster.SetRawKey with context.Background().
+func (r *RedisClusterWithoutContext) SetRawKey(keyName, session string, timeout time.Duration) error {
+	return r.RedisCluster.SetRawKey(context.Background(), keyName, session, timeout)
+}
+
+// Decrement calls RedisCluster.Decrement with context.Background().
+func (r *RedisClusterWithoutContext) Decrement(keyName string) {
+	r.RedisCluster.Decrement(context.Background(), keyName)
+}
+
+// IncrememntWithExpire calls RedisCluster.IncrememntWithExpire with context.Background().
+func (r *RedisClusterWithoutContext) IncrememntWithExpire(keyName string, expire int64) int64 {
+	return r.RedisCluster.IncrememntWithExpire(context.Background(), keyName, expire)
+}
+
+// GetKeys calls RedisCluster.GetKeys with context.Background().
+func (r *RedisClusterWithoutContext) GetKeys(filter string) []string {
+	return r.RedisCluster.GetKeys(context.Background(), filter)
+}
+
+// GetKeysAndValuesWithFilter calls RedisCluster.GetKeysAndValuesWithFilter with context.Background().
+func (r *RedisClusterWithoutContext) GetKeysAndValuesWithFilter(filter string) map[string]string {
+	return r.RedisCluster.GetKeysAndValuesWithFilter(context.Background(), filter)
+}
+
+// GetKeysAndValues calls RedisCluster.GetKeysAndValues with context.Background().
+func (r *RedisClusterWithoutContext) GetKeysAndValues() map[string]string {
+	return r.RedisCluster.GetKeysAndValues(context.Background())
+}
+
+// DeleteKey calls RedisCluster.DeleteKey with context.Background().
+func (r *RedisClusterWithoutContext) DeleteKey(keyName string) bool {
+	return r.RedisCluster.DeleteKey(context.Background(), keyName)
+}
+
+// DeleteAllKeys calls RedisCluster.DeleteAllKeys with context.Background().
+func (r *RedisClusterWithoutContext) DeleteAllKeys() bool {
+	return r.RedisCluster.DeleteAllKeys(context.Background())
+}
+
+// DeleteRawKey calls RedisCluster.DeleteRawKey with context.Background().
+func (r *RedisClusterWithoutContext) DeleteRawKey(keyName string) bool {
+	return r.RedisCluster.DeleteRawKey(context.Background(), keyName)
+}
+
+// DeleteScanMatch calls RedisCluster.DeleteScanMatch with context.Background().
+func (r *RedisClusterWithoutContext) DeleteScanMatch(pattern string) bool {
+	return r.RedisCluster.DeleteScanMatch(context.Background(), pattern)
+}
+
+// DeleteKeys calls RedisCluster.DeleteKeys with context.Background().
+func (r *RedisClusterWithoutContext) DeleteKeys(keys []string) bool {
+	return r.RedisCluster.DeleteKeys(context.Background(), keys)
+}
+
+// StartPubSubHandler calls RedisCluster.StartPubSubHandler with context.Background().
+func (r *RedisClusterWithoutContext) StartPubSubHandler(channel string, callback func(interface{})) error {
+	return r.RedisCluster.StartPubSubHandler(context.Background(), channel, callback)
+}
+
+// Publish calls RedisCluster.Publish with context.Background().
+func (r *RedisClusterWithoutContext) Publish(channel, message string) error {
+	return r.RedisCluster.Publish(context.Background(), channel, message)
+}
+
+// GetAndDeleteSet calls RedisCluster.GetAndDeleteSet with context.Background().
+func (r *RedisClusterWithoutContext) GetAndDeleteSet(keyName string) []interface{} {
+	return r.RedisCluster.GetAndDeleteSet(context.Background(), keyName)
+}
+
+// GetAndDeleteSetChunked calls RedisCluster.GetAndDeleteSetChunked with context.Background().
+func (r *RedisClusterWithoutContext) GetAndDeleteSetChunked(
+	keyName string,
+	chunkSize int64,
+	fn func(values []interface{}) error,
+) error {
+	return r.RedisCluster.GetAndDeleteSetChunked(context.Background(), keyName, chunkSize, fn)
+}
+
+// AppendToSet calls RedisCluster.AppendToSet with context.Background().
+func (r *RedisClusterWithoutContext) AppendToSet(keyName, value string) {
+	r.RedisCluster.AppendToSet(context.Background(), keyName, value)
+}
+
+// Exists calls RedisCluster.Exists with context.Background().
+func (r *RedisClusterWithoutContext) Exists(keyName string) (bool, error) {
+	return r.RedisCluster.Exists(context.Background(), keyName)
+}
+
+// RemoveFromList calls RedisCluster.RemoveFromList with context.Background().
+func (r *RedisClusterWithoutContext) RemoveFromList(keyName, value string) error {
+	return r.RedisCluster.RemoveFromList(context.Background(), keyName, value)
+}
+
+// GetListRange calls RedisCluster.GetListRange with context.Background().
+func (r *RedisClusterWithoutContext) GetListRange(keyName string, from, to int64) ([]string, error) {
+	return r.RedisCluster.GetListRange(context.Background(), keyName, from, to)
+}
+
+// AppendToSetPipelined calls RedisCluster.AppendToSetPipelined with context.Background().
+func (r *RedisClusterWithoutContext) AppendToSetPipelined(key string, values [][]byte) {
+	r.RedisCluster.AppendToSetPipelined(context.Background(), key, values)
+}
+
+// GetSet calls RedisCluster.GetSet with context.Background().
+func (r *RedisClusterWithoutContext) GetSet(keyName string) (map[string]string, error) {
+	return r.RedisCluster.GetSet(context.Background(), keyName)
+}
+
+// AddToSet calls RedisCluster.AddToSet with context.Background().
+func (r *RedisClusterWithoutContext) AddToSet(keyName, value string) {
+	r.RedisCluster.AddToSet(context.Background(), keyName, value)
+}
+
+// RemoveFromSet calls RedisCluster.RemoveFromSet with context.Background().
+func (r *RedisClusterWithoutContext) RemoveFromSet(keyName, value string) {
+	r.RedisCluster.RemoveFromSet(context.Background(), keyName, value)
+}
+
+// IsMemberOfSet calls RedisCluster.IsMemberOfSet with context.Background().
+func (r *RedisClusterWithoutContext) IsMemberOfSet(keyName, value string) bool {
+	return r.RedisCluster.IsMemberOfSet(context.Background(), keyName, value)
+}
+
+// SetRollingWindow calls RedisCluster.SetRollingWindow with context.Background().
+func (r *RedisClusterWithoutContext) SetRollingWindow(keyName string, per int64, valueOverride string, pipeline bool) (int, []interface{}) {
+	return r.RedisCluster.SetRollingWindow(context.Background(), keyName, per, valueOverride, pipeline)
+}
+
+// GetRollingWindow calls RedisCluster.GetRollingWindow with context.Background().
+func (r *RedisClusterWithoutContext) GetRollingWindow(keyName string, per int64, pipeline bool) (int, []interface{}) {
+	return r.RedisCluster.GetRollingWindow(context.Background(), keyName, per, pipeline)
+}
+
+// AddToSortedSet calls RedisCluster.AddToSortedSet with context.Background().
+func (r *RedisClusterWithoutContext) AddToSortedSet(keyName, value string, score float64) {
+	r.RedisCluster.AddToSortedSet(context.Background(), keyName, value, score)
+}
+
+// GetSortedSetRange calls RedisCluster.GetSortedSetRange with context.Background().
+func (r *RedisClusterWithoutContext) GetSortedSetRange(keyName, scoreFrom, scoreTo string) ([]string, []float64, error) {
+	return r.RedisCluster.GetSortedSetRange(context.Background(), keyName, scoreFrom, scoreTo)
+}
+
+// RemoveSortedSetRange calls RedisCluster.RemoveSortedSetRange with context.Background().
+func (r *RedisClusterWithoutContext) RemoveSortedSetRange(keyName, scoreFrom, scoreTo string) error {
+	return r.RedisCluster.RemoveSortedSetRange(context.Background(), keyName, scoreFrom, scoreTo)
+}
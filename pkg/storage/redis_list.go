@@ -0,0 +1,155 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// appendAndTrimBatchSize bounds the number of values appended by one run of appendAndTrimScript,
+// lua can not unpack too many arguments at once.
+const appendAndTrimBatchSize = 1000
+
+// appendAndTrimScript appends the values ARGV[3..] to the list KEYS[1], then keeps only its ARGV[1]
+// last values if ARGV[1] is positive, and sets its ttl to ARGV[2] milliseconds if ARGV[2] is
+// positive and the list has no ttl yet. It returns the length of the list.
+var appendAndTrimScript = NewScript(`
+local len = redis.call('RPUSH', KEYS[1], unpack(ARGV, 3))
+local maxlen = tonumber(ARGV[1])
+if maxlen > 0 and len > maxlen then
+	redis.call('LTRIM', KEYS[1], -maxlen, -1)
+	len = maxlen
+end
+local ttl = tonumber(ARGV[2])
+if ttl > 0 and redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return len
+`)
+
+// popChunkScript removes the ARGV[1] first values of the list KEYS[1] and returns them. LPOP only
+// accepts a count since redis 6.2, the script pops a chunk atomically on the older versions too.
+var popChunkScript = NewScript(`
+local values = redis.call('LRANGE', KEYS[1], 0, tonumber(ARGV[1]) - 1)
+if #values > 0 then
+	redis.call('LTRIM', KEYS[1], #values, -1)
+end
+return values
+`)
+
+// RedisList is an AnalyticsHandler which appends the values to a redis list capped to MaxLen values.
+// The values are appended, the list trimmed and its expiration set atomically, so a reader never
+// sees a list longer than MaxLen or without expiration.
+type RedisList struct {
+	RedisCluster
+	// MaxLen caps the list to MaxLen values, the oldest values are evicted.
+	// The list is not capped if MaxLen is 0.
+	MaxLen int64
+	// Expiration is set on the list when it is created, the list does not expire if Expiration is 0.
+	Expiration time.Duration
+}
+
+var _ AnalyticsHandler = (*RedisList)(nil)
+
+// AppendToSetPipelined appends the values to the list, trims it and sets its expiration, in one
+// script run per batch of values.
+func (r *RedisList) AppendToSetPipelined(ctx context.Context, key string, values [][]byte) {
+	if err := r.AppendAndTrim(ctx, key, values); err != nil {
+		appendErrorLog.Errorf("Error trying to append to list %s: %s", r.fixKey(key), err.Error())
+	}
+}
+
+// AppendAndTrim appends the values to the list key, then keeps only its MaxLen last values.
+func (r *RedisList) AppendAndTrim(ctx context.Context, key string, values [][]byte) error {
+	if m, ok := r.memory(); ok {
+		m.appendAndTrim(r.fixKey(key), values, r.MaxLen, r.Expiration)
+
+		return nil
+	}
+
+	runner := r.ScriptRunner()
+
+	for len(values) > 0 {
+		batch := values
+		if len(batch) > appendAndTrimBatchSize {
+			batch = batch[:appendAndTrimBatchSize]
+		}
+
+		values = values[len(batch):]
+
+		args := make([]interface{}, 0, len(batch)+2)
+		args = append(args, r.MaxLen, r.Expiration.Milliseconds())
+
+		for _, val := range batch {
+			args = append(args, val)
+		}
+
+		if _, err := runner.Run(ctx, appendAndTrimScript, []string{key}, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAndDeleteSetChunked removes the values of the list keyName chunkSize at a time and calls fn with
+// each chunk, so that neither redis nor the caller holds the whole list at once. Each chunk is
+// popped atomically, the values appended meanwhile are popped by the following chunks.
+//
+// It stops at the first error returned by fn, the chunk passed to fn is then pushed back to the head
+// of the list and the remaining values are left intact for the next call. The delivery is at least
+// once: a chunk which fn partly processed before failing is passed again to fn by the next call.
+// The values are lost if fn fails and the chunk can not be pushed back, which is logged.
+func (r *RedisCluster) GetAndDeleteSetChunked(
+	ctx context.Context,
+	keyName string,
+	chunkSize int64,
+	fn func(values []interface{}) error,
+) error {
+	if chunkSize <= 0 {
+		return fmt.Errorf("invalid chunk size %d, must be positive", chunkSize)
+	}
+
+	runner := r.ScriptRunner()
+
+	for {
+		reply, err := runner.Run(ctx, popChunkScript, []string{keyName}, chunkSize)
+		if err != nil {
+			return err
+		}
+
+		values, _ := reply.([]interface{})
+		if len(values) == 0 {
+			return nil
+		}
+
+		if err := fn(values); err != nil {
+			r.pushBack(ctx, keyName, values)
+
+			return err
+		}
+
+		if int64(len(values)) < chunkSize {
+			return nil
+		}
+	}
+}
+
+// pushBack pushes the values back to the head of the list keyName, in their order.
+func (r *RedisCluster) pushBack(ctx context.Context, keyName string, values []interface{}) {
+	reversed := make([]interface{}, len(values))
+	for i, val := range values {
+		reversed[len(values)-1-i] = val
+	}
+
+	if err := r.client(ctx).LPush(r.fixKey(keyName), reversed...).Err(); err != nil {
+		log.Errorf("Error trying to push back %d values to list %s, they are lost: %s",
+			len(values), r.fixKey(keyName), err.Error())
+	}
+}
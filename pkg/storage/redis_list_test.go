@@ -0,0 +1,227 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+)
+
+func TestRedisList_AppendAndTrim(t *testing.T) {
+	sha := appendAndTrimScript.SHA()
+
+	tests := []struct {
+		name   string
+		list   *RedisList
+		values [][]byte
+		want   [][]string
+	}{
+		{
+			name:   "capped with expiration",
+			list:   &RedisList{RedisCluster: RedisCluster{KeyPrefix: "analytics-"}, MaxLen: 10, Expiration: time.Minute},
+			values: [][]byte{[]byte("a"), []byte("b"), []byte("c")},
+			want:   [][]string{{"evalsha " + sha + " 1 analytics-list 10 60000 a b c"}},
+		},
+		{
+			name:   "not capped",
+			list:   &RedisList{RedisCluster: RedisCluster{KeyPrefix: "analytics-"}},
+			values: [][]byte{[]byte("a")},
+			want:   [][]string{{"evalsha " + sha + " 1 analytics-list 0 0 a"}},
+		},
+		{
+			name: "empty batch",
+			list: &RedisList{RedisCluster: RedisCluster{KeyPrefix: "analytics-"}, MaxLen: 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := &recordHook{}
+			client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+			client.AddHook(hook)
+			useClient(t, client)
+
+			_ = tt.list.AppendAndTrim(context.Background(), "list", tt.values)
+
+			if !reflect.DeepEqual(hook.pipelines, tt.want) {
+				t.Errorf("commands = %q, want %q", hook.pipelines, tt.want)
+			}
+		})
+	}
+}
+
+// listServer is a redis server holding a single list, which only knows the commands used to pop it
+// chunk by chunk.
+type listServer struct {
+	mu     sync.Mutex
+	values []string
+	// pops holds the sizes of the chunks popped
+	pops []int
+}
+
+func (s *listServer) list() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.values...)
+}
+
+func (s *listServer) popped() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pops
+}
+
+func (s *listServer) reply(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch strings.ToLower(args[0]) {
+	case "evalsha", "eval":
+		n, _ := strconv.Atoi(args[4])
+		if n > len(s.values) {
+			n = len(s.values)
+		}
+
+		chunk := s.values[:n]
+		s.values = s.values[n:]
+		s.pops = append(s.pops, n)
+
+		reply := fmt.Sprintf("*%d\r\n", len(chunk))
+		for _, val := range chunk {
+			reply += fmt.Sprintf("$%d\r\n%s\r\n", len(val), val)
+		}
+
+		return reply
+	case "lpush":
+		for _, val := range args[2:] {
+			s.values = append([]string{val}, s.values...)
+		}
+
+		return fmt.Sprintf(":%d\r\n", len(s.values))
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+// listRedis starts a listServer holding values, used by the storage during the test.
+func listRedis(t *testing.T, values []string) *listServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	server := &listServer{values: values}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				reader := bufio.NewReader(conn)
+				for {
+					args, err := readCommand(reader)
+					if err != nil {
+						return
+					}
+
+					_, _ = conn.Write([]byte(server.reply(args)))
+				}
+			}(conn)
+		}
+	}()
+
+	useClient(t, redis.NewClient(&redis.Options{Addr: ln.Addr().String()}))
+	forgetLoadedScripts()
+
+	t.Cleanup(func() { ln.Close() })
+
+	return server
+}
+
+func TestRedisCluster_GetAndDeleteSetChunked(t *testing.T) {
+	errWrite := errors.New("write failed")
+
+	tests := []struct {
+		name string
+		// failAt is the number of the chunk whose callback fails, no callback fails if 0
+		failAt     int
+		wantChunks [][]string
+		wantPops   []int
+		wantList   []string
+		wantErr    error
+	}{
+		{
+			name:       "drained",
+			wantChunks: [][]string{{"a", "b"}, {"c", "d"}, {"e"}},
+			wantPops:   []int{2, 2, 1},
+			wantList:   []string{},
+		},
+		{
+			name:       "callback error",
+			failAt:     2,
+			wantChunks: [][]string{{"a", "b"}, {"c", "d"}},
+			wantPops:   []int{2, 2},
+			wantList:   []string{"c", "d", "e"},
+			wantErr:    errWrite,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := listRedis(t, []string{"a", "b", "c", "d", "e"})
+
+			var chunks [][]string
+
+			err := (&RedisCluster{}).GetAndDeleteSetChunked(context.Background(), "list", 2,
+				func(values []interface{}) error {
+					chunk := make([]string, len(values))
+					for i, val := range values {
+						chunk[i], _ = val.(string)
+					}
+
+					chunks = append(chunks, chunk)
+					if len(chunks) == tt.failAt {
+						return errWrite
+					}
+
+					return nil
+				})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetAndDeleteSetChunked() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(chunks, tt.wantChunks) {
+				t.Errorf("chunks = %q, want %q", chunks, tt.wantChunks)
+			}
+
+			if pops := server.popped(); !reflect.DeepEqual(pops, tt.wantPops) {
+				t.Errorf("popped chunks of %v values, want %v", pops, tt.wantPops)
+			}
+
+			// the chunk of the failed callback is pushed back in its order.
+			if list := server.list(); !reflect.DeepEqual(list, tt.wantList) {
+				t.Errorf("list = %q, want %q", list, tt.wantList)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// streamValueField is the field of the stream entries which holds the value.
+const streamValueField = "value"
+
+// RedisStream is an AnalyticsHandler which appends the values to a redis stream rather than to
+// a list. Unlike a list, a stream can be read by several consumer groups, and the values which
+// are not acknowledged by a consumer are delivered again.
+//
+// A stream is a single key, all the commands of a batch are sent to the same slot in cluster mode.
+type RedisStream struct {
+	RedisCluster
+	// Stream is the name of the stream, the key given to the methods is used if empty.
+	Stream string
+	// MaxLen caps the stream to about MaxLen entries, the oldest entries are evicted.
+	// The stream is not capped if MaxLen is 0.
+	MaxLen int64
+}
+
+var _ AnalyticsHandler = (*RedisStream)(nil)
+
+// StreamMessage is a value read from a stream.
+type StreamMessage struct {
+	ID    string
+	Value []byte
+}
+
+func (r *RedisStream) streamKey(key string) string {
+	if r.Stream != "" {
+		key = r.Stream
+	}
+
+	return r.fixKey(key)
+}
+
+// AppendToSetPipelined appends each value as a new entry of the stream, the entries of a
+// batch are added in one pipeline.
+func (r *RedisStream) AppendToSetPipelined(ctx context.Context, key string, values [][]byte) {
+	if len(values) == 0 {
+		return
+	}
+
+	// the entries are kept in a list by the memory storage.
+	if m, ok := r.memory(); ok {
+		m.appendAndTrim(r.streamKey(key), values, r.MaxLen, 0)
+
+		return
+	}
+
+	if err := r.up(); err != nil {
+		log.Debug(err.Error())
+
+		return
+	}
+
+	stream := r.streamKey(key)
+	pipe := r.client(ctx).Pipeline()
+
+	for _, val := range values {
+		pipe.XAdd(&redis.XAddArgs{
+			Stream:       stream,
+			MaxLenApprox: r.MaxLen,
+			Values:       map[string]interface{}{streamValueField: val},
+		})
+	}
+
+	if _, err := pipe.Exec(); err != nil {
+		appendErrorLog.Errorf("Error trying to append to stream %s: %s", stream, err.Error())
+	}
+}
+
+// GetAndDeleteSet returns all the values of the stream and deletes it, along with its
+// consumer groups. It is only provided for the consumers of the list backend, the stream
+// should be read with ReadGroup instead.
+func (r *RedisStream) GetAndDeleteSet(ctx context.Context, key string) []interface{} {
+	if m, ok := r.memory(); ok {
+		return m.getAndDelete(r.streamKey(key))
+	}
+
+	if err := r.up(); err != nil {
+		return nil
+	}
+
+	stream := r.streamKey(key)
+
+	var xrange *redis.XMessageSliceCmd
+	_, err := r.client(ctx).TxPipelined(func(pipe redis.Pipeliner) error {
+		xrange = pipe.XRange(stream, "-", "+")
+		pipe.Del(stream)
+
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Multi command failed: %s", err.Error())
+
+		return nil
+	}
+
+	msgs := toStreamMessages(xrange.Val())
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	result := make([]interface{}, len(msgs))
+	for i, msg := range msgs {
+		result[i] = string(msg.Value)
+	}
+
+	return result
+}
+
+// CreateGroup creates the consumer group of the stream if it does not exist yet, the stream
+// is created if needed. The group starts to read after the entry start, use "0" to read the
+// stream from its beginning and "$" to only read the new entries.
+func (r *RedisStream) CreateGroup(ctx context.Context, key, group, start string) error {
+	if err := r.up(); err != nil {
+		return err
+	}
+
+	err := r.client(ctx).XGroupCreateMkStream(r.streamKey(key), group, start).Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+
+	return nil
+}
+
+// ReadGroup reads up to count values of the stream for the consumer of the group. It waits up
+// to block for new values, forever if block is 0, and does not wait if block is negative.
+// The group is created from the beginning of the stream if it does not exist.
+//
+// The values delivered to the consumer which are not acknowledged yet are returned first, so
+// a consumer restarted after a failure processes them again: each value is delivered at least
+// once, until it is acknowledged with Ack.
+func (r *RedisStream) ReadGroup(
+	ctx context.Context,
+	key, group, consumer string,
+	count int64,
+	block time.Duration,
+) ([]StreamMessage, error) {
+	if err := r.up(); err != nil {
+		return nil, err
+	}
+
+	msgs, err := r.readGroup(ctx, key, group, consumer, "0", count, -1)
+	if err != nil && strings.HasPrefix(err.Error(), "NOGROUP") {
+		if err = r.CreateGroup(ctx, key, group, "0"); err == nil {
+			msgs, err = r.readGroup(ctx, key, group, consumer, "0", count, -1)
+		}
+	}
+
+	if err != nil || len(msgs) != 0 {
+		return msgs, err
+	}
+
+	return r.readGroup(ctx, key, group, consumer, ">", count, block)
+}
+
+func (r *RedisStream) readGroup(
+	ctx context.Context,
+	key, group, consumer, id string,
+	count int64,
+	block time.Duration,
+) ([]StreamMessage, error) {
+	stream := r.streamKey(key)
+
+	streams, err := r.client(ctx).XReadGroup(&redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, id},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		xmsgs   []redis.XMessage
+		evicted []string
+	)
+
+	for _, s := range streams {
+		for _, xmsg := range s.Messages {
+			// the pending entries evicted by MaxLen have no value anymore
+			if _, ok := xmsg.Values[streamValueField]; !ok {
+				evicted = append(evicted, xmsg.ID)
+
+				continue
+			}
+
+			xmsgs = append(xmsgs, xmsg)
+		}
+	}
+
+	if len(evicted) != 0 {
+		log.Warnf("%d pending entries of stream %s were evicted before being acknowledged", len(evicted), stream)
+
+		if err := r.client(ctx).XAck(stream, group, evicted...).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return toStreamMessages(xmsgs), nil
+}
+
+// Ack acknowledges the values processed by the consumers of the group, they are not
+// delivered again.
+func (r *RedisStream) Ack(ctx context.Context, key, group string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := r.up(); err != nil {
+		return err
+	}
+
+	return r.client(ctx).XAck(r.streamKey(key), group, ids...).Err()
+}
+
+func toStreamMessages(xmsgs []redis.XMessage) []StreamMessage {
+	msgs := make([]StreamMessage, 0, len(xmsgs))
+
+	for _, xmsg := range xmsgs {
+		value, _ := xmsg.Values[streamValueField].(string)
+		msgs = append(msgs, StreamMessage{ID: xmsg.ID, Value: []byte(value)})
+	}
+
+	return msgs
+}
@@ -0,0 +1,214 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+)
+
+var errAborted = errors.New("aborted by test")
+
+// recordHook records the commands sent to redis and aborts them before they reach the
+// network. The replies of the commands can be scripted as errors. The multi and exec
+// commands of the transactions are not recorded, the cluster client adds them per node.
+type recordHook struct {
+	// replies maps a command name to the errors replied to its successive calls
+	replies map[string][]error
+	// pipelines holds the commands sent, one item per pipeline or single command
+	pipelines [][]string
+}
+
+func (h *recordHook) reply(cmd redis.Cmder) error {
+	if errs := h.replies[cmd.Name()]; len(errs) != 0 {
+		h.replies[cmd.Name()] = errs[1:]
+
+		return errs[0]
+	}
+
+	return errAborted
+}
+
+func (h *recordHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	h.pipelines = append(h.pipelines, []string{formatCmd(cmd)})
+
+	return ctx, h.reply(cmd)
+}
+
+func (h *recordHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *recordHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	var pipeline []string
+	for _, cmd := range cmds {
+		if name := cmd.Name(); name != "multi" && name != "exec" {
+			pipeline = append(pipeline, formatCmd(cmd))
+		}
+	}
+
+	h.pipelines = append(h.pipelines, pipeline)
+
+	return ctx, errAborted
+}
+
+func (h *recordHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func formatCmd(cmd redis.Cmder) string {
+	args := make([]string, 0, len(cmd.Args()))
+	for _, arg := range cmd.Args() {
+		if b, ok := arg.([]byte); ok {
+			arg = string(b)
+		}
+
+		args = append(args, fmt.Sprint(arg))
+	}
+
+	return strings.Join(args, " ")
+}
+
+// commandKey returns the key of a command sent by RedisStream.
+func commandKey(cmd string) string {
+	args := strings.Fields(cmd)
+
+	switch args[0] {
+	case "xadd", "xrange", "del", "xack":
+		return args[1]
+	case "xgroup":
+		return args[2]
+	case "xreadgroup":
+		for i, arg := range args {
+			if arg == "streams" {
+				return args[i+1]
+			}
+		}
+	}
+
+	return ""
+}
+
+func TestRedisStream(t *testing.T) {
+	clients := map[string]func() redis.UniversalClient{
+		"single": func() redis.UniversalClient {
+			return redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+		},
+		"cluster": func() redis.UniversalClient {
+			return redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{"127.0.0.1:0"}})
+		},
+	}
+
+	tests := []struct {
+		name    string
+		replies map[string][]error
+		run     func(r *RedisStream)
+		want    [][]string
+	}{
+		{
+			name: "append batch in one pipeline",
+			run: func(r *RedisStream) {
+				r.AppendToSetPipelined(context.Background(), "ignored", [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+			},
+			want: [][]string{{
+				"xadd analytics-stream maxlen ~ 10 * value a",
+				"xadd analytics-stream maxlen ~ 10 * value b",
+				"xadd analytics-stream maxlen ~ 10 * value c",
+			}},
+		},
+		{
+			name: "append empty batch",
+			run: func(r *RedisStream) {
+				r.AppendToSetPipelined(context.Background(), "ignored", nil)
+			},
+		},
+		{
+			name: "get and delete in one transaction",
+			run: func(r *RedisStream) {
+				r.GetAndDeleteSet(context.Background(), "ignored")
+			},
+			want: [][]string{{
+				"xrange analytics-stream - +",
+				"del analytics-stream",
+			}},
+		},
+		{
+			name: "read group creates the group",
+			replies: map[string][]error{
+				"xreadgroup": {errors.New("NOGROUP No such key")},
+				// the group is created meanwhile by another consumer
+				"xgroup": {errors.New("BUSYGROUP Consumer Group name already exists")},
+			},
+			run: func(r *RedisStream) {
+				_, _ = r.ReadGroup(context.Background(), "ignored", "pump", "pump-1", 100, time.Second)
+			},
+			want: [][]string{
+				{"xreadgroup group pump pump-1 count 100 streams analytics-stream 0"},
+				{"xgroup create analytics-stream pump 0 mkstream"},
+				{"xreadgroup group pump pump-1 count 100 streams analytics-stream 0"},
+			},
+		},
+		{
+			name: "create existing group",
+			replies: map[string][]error{
+				"xgroup": {errors.New("BUSYGROUP Consumer Group name already exists")},
+			},
+			run: func(r *RedisStream) {
+				if err := r.CreateGroup(context.Background(), "ignored", "pump", "$"); err != nil {
+					t.Errorf("CreateGroup() error = %v", err)
+				}
+			},
+			want: [][]string{{"xgroup create analytics-stream pump $ mkstream"}},
+		},
+		{
+			name: "ack",
+			run: func(r *RedisStream) {
+				_ = r.Ack(context.Background(), "ignored", "pump", "1-0", "2-0")
+			},
+			want: [][]string{{"xack analytics-stream pump 1-0 2-0"}},
+		},
+	}
+	for clientName, newClient := range clients {
+		for _, tt := range tests {
+			t.Run(clientName+"/"+tt.name, func(t *testing.T) {
+				hook := &recordHook{replies: make(map[string][]error)}
+				for name, errs := range tt.replies {
+					hook.replies[name] = errs
+				}
+
+				client := newClient()
+				client.AddHook(hook)
+				useClient(t, client)
+
+				tt.run(&RedisStream{
+					RedisCluster: RedisCluster{KeyPrefix: "analytics-"},
+					Stream:       "stream",
+					MaxLen:       10,
+				})
+
+				if !reflect.DeepEqual(hook.pipelines, tt.want) {
+					t.Errorf("commands = %q, want %q", hook.pipelines, tt.want)
+				}
+
+				// in cluster mode, the commands of a pipeline or a transaction must target the
+				// same slot: they all use the stream key.
+				for _, pipeline := range hook.pipelines {
+					for _, cmd := range pipeline {
+						if key := commandKey(cmd); key != "" && key != "analytics-stream" {
+							t.Errorf("command %q uses key %q, want the stream key", cmd, key)
+						}
+					}
+				}
+			})
+		}
+	}
+}
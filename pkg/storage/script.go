@@ -0,0 +1,204 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec // redis identifies the scripts by their sha1 digest
+	"encoding/hex"
+	"strings"
+	"sync"
+
+	redis "github.com/go-redis/redis/v7"
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// clusterSlots is the number of hash slots of a redis cluster.
+const clusterSlots = 16384
+
+// ErrCrossSlot is returned when the keys of a script are not in the same hash slot in cluster mode.
+var ErrCrossSlot = errors.New("storage: the keys of a script must be in the same hash slot in cluster mode")
+
+// Script is a lua script executed atomically by redis, its sha1 digest is computed once.
+type Script struct {
+	src string
+	sha string
+}
+
+// NewScript returns the lua script src. The script must only access the keys passed to Run, in
+// KEYS, to be routed to the right node in cluster mode.
+func NewScript(src string) *Script {
+	sum := sha1.Sum([]byte(src)) //nolint:gosec // redis identifies the scripts by their sha1 digest
+
+	return &Script{src: src, sha: hex.EncodeToString(sum[:])}
+}
+
+// SHA returns the sha1 digest which identifies the script in redis.
+func (s *Script) SHA() string {
+	return s.sha
+}
+
+// loadedScripts holds the sha1 digests of the scripts loaded on each redis node, by node address.
+var loadedScripts = struct {
+	sync.Mutex
+	nodes map[string]map[string]bool
+}{nodes: make(map[string]map[string]bool)}
+
+func scriptLoaded(node, sha string) bool {
+	loadedScripts.Lock()
+	defer loadedScripts.Unlock()
+
+	return loadedScripts.nodes[node][sha]
+}
+
+func setScriptLoaded(node, sha string) {
+	loadedScripts.Lock()
+	defer loadedScripts.Unlock()
+
+	if loadedScripts.nodes[node] == nil {
+		loadedScripts.nodes[node] = make(map[string]bool)
+	}
+
+	loadedScripts.nodes[node][sha] = true
+}
+
+// forgetLoadedScripts is called when a node does not know a script anymore, e.g. after a restart or a
+// failover. The node is unknown in cluster mode, so the scripts of every node are loaded again.
+func forgetLoadedScripts() {
+	loadedScripts.Lock()
+	defer loadedScripts.Unlock()
+
+	loadedScripts.nodes = make(map[string]map[string]bool)
+}
+
+// ScriptRunner executes lua scripts with EVALSHA, so the body of a script is not sent with every
+// call. A script unknown to a node is sent again with EVAL, which loads it on the node.
+type ScriptRunner struct {
+	r *RedisCluster
+}
+
+// ScriptRunner returns the ScriptRunner executing the scripts on the redis of r.
+func (r *RedisCluster) ScriptRunner() *ScriptRunner {
+	return &ScriptRunner{r: r}
+}
+
+// Load loads the scripts on every redis node, every master in cluster mode, which does not hold
+// them yet. Run works without it, Load only saves the EVAL sent by the first run on each node.
+func (s *ScriptRunner) Load(ctx context.Context, scripts ...*Script) error {
+	if err := s.r.up(); err != nil {
+		return err
+	}
+
+	load := func(node *redis.Client) error {
+		addr := node.Options().Addr
+
+		for _, script := range scripts {
+			if scriptLoaded(addr, script.sha) {
+				continue
+			}
+
+			if err := node.ScriptLoad(script.src).Err(); err != nil {
+				return errors.Wrapf(err, "load script %s on %s", script.sha, addr)
+			}
+
+			setScriptLoaded(addr, script.sha)
+		}
+
+		return nil
+	}
+
+	switch c := s.r.client(ctx).(type) {
+	case *redis.ClusterClient:
+		return c.ForEachMaster(func(node *redis.Client) error {
+			return load(node.WithContext(ctx))
+		})
+	case *redis.Client:
+		return load(c)
+	default:
+		return errors.Errorf("storage: scripts are not supported by redis client %T", c)
+	}
+}
+
+// Run executes the script on the keys with the args and returns its result, nil if the script
+// returns nil. The keys are prefixed like the other keys of r and, in cluster mode, they must be
+// in the same hash slot: use a hash tag, like {user}.profile and {user}.sessions.
+func (s *ScriptRunner) Run(
+	ctx context.Context,
+	script *Script,
+	keys []string,
+	args ...interface{},
+) (interface{}, error) {
+	if err := s.r.up(); err != nil {
+		return nil, err
+	}
+
+	fixedKeys := make([]string, len(keys))
+	for i, key := range keys {
+		fixedKeys[i] = s.r.fixKey(key)
+	}
+
+	client := s.r.client(ctx)
+	if _, ok := client.(*redis.ClusterClient); ok && !sameSlot(fixedKeys) {
+		return nil, ErrCrossSlot
+	}
+
+	result, err := client.EvalSha(script.sha, fixedKeys, args...).Result()
+	if err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		log.Debugw("Redis script is not loaded, sending it again", "sha", script.sha)
+		forgetLoadedScripts()
+
+		result, err = client.Eval(script.src, fixedKeys, args...).Result()
+	}
+
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+
+	return result, err
+}
+
+// sameSlot reports whether the keys are in the same hash slot of a redis cluster.
+func sameSlot(keys []string) bool {
+	for _, key := range keys {
+		if keySlot(key) != keySlot(keys[0]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// keySlot returns the hash slot of the key in a redis cluster, only the hash tag of the key is
+// hashed if it has one.
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+
+	return int(crc16(key) % clusterSlots)
+}
+
+// crc16 implements the CRC16-CCITT (XMODEM) checksum used by redis cluster.
+func crc16(s string) uint16 {
+	var crc uint16
+
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
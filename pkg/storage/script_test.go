@@ -0,0 +1,291 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	redis "github.com/go-redis/redis/v7"
+)
+
+// scriptServer is a redis server which only knows the script commands, the scripts reply the
+// number of their keys.
+type scriptServer struct {
+	mu sync.Mutex
+	// scripts holds the scripts loaded, by sha1 digest
+	scripts map[string]string
+	// commands holds the names of the commands received
+	commands []string
+}
+
+// flush makes the server forget its scripts, like a restarted redis.
+func (s *scriptServer) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scripts = make(map[string]string)
+}
+
+func (s *scriptServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	commands := s.commands
+	s.commands = nil
+
+	return commands
+}
+
+func (s *scriptServer) reply(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := strings.ToLower(args[0])
+	s.commands = append(s.commands, name)
+
+	switch name {
+	case "script":
+		sha := NewScript(args[2]).SHA()
+		s.scripts[sha] = args[2]
+
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(sha), sha)
+	case "evalsha":
+		if _, ok := s.scripts[args[1]]; !ok {
+			return "-NOSCRIPT No matching script. Please use EVAL.\r\n"
+		}
+
+		return ":" + args[2] + "\r\n"
+	case "eval":
+		s.scripts[NewScript(args[1]).SHA()] = args[1]
+
+		return ":" + args[2] + "\r\n"
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+// readCommand reads a command sent by a client as an array of bulk strings.
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	readLine := func() (string, error) {
+		line, err := reader.ReadString('\n')
+
+		return strings.TrimSuffix(line, "\r\n"), err
+	}
+
+	line, err := readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	n, _ := strconv.Atoi(strings.TrimPrefix(line, "*"))
+	args := make([]string, n)
+
+	for i := range args {
+		if line, err = readLine(); err != nil {
+			return nil, err
+		}
+
+		size, _ := strconv.Atoi(strings.TrimPrefix(line, "$"))
+		buf := make([]byte, size+2)
+
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+
+		args[i] = string(buf[:size])
+	}
+
+	return args, nil
+}
+
+// scriptRedis starts a scriptServer used by the storage during the test.
+func scriptRedis(t *testing.T) *scriptServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	server := &scriptServer{scripts: make(map[string]string)}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				reader := bufio.NewReader(conn)
+				for {
+					args, err := readCommand(reader)
+					if err != nil {
+						return
+					}
+
+					_, _ = conn.Write([]byte(server.reply(args)))
+				}
+			}(conn)
+		}
+	}()
+
+	useClient(t, redis.NewClient(&redis.Options{Addr: ln.Addr().String()}))
+	forgetLoadedScripts()
+
+	t.Cleanup(func() { ln.Close() })
+
+	return server
+}
+
+func TestScriptRunner_Run(t *testing.T) {
+	script := NewScript("return #KEYS")
+
+	tests := []struct {
+		name  string
+		setup func(s *scriptServer, runner *ScriptRunner)
+		want  []string
+	}{
+		{
+			name: "not loaded",
+			want: []string{"evalsha", "eval"},
+		},
+		{
+			name: "loaded",
+			setup: func(s *scriptServer, runner *ScriptRunner) {
+				_ = runner.Load(context.Background(), script)
+			},
+			want: []string{"evalsha"},
+		},
+		{
+			name: "flushed after load",
+			setup: func(s *scriptServer, runner *ScriptRunner) {
+				_ = runner.Load(context.Background(), script)
+				s.flush()
+			},
+			want: []string{"evalsha", "eval"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := scriptRedis(t)
+			runner := (&RedisCluster{}).ScriptRunner()
+
+			if tt.setup != nil {
+				tt.setup(server, runner)
+				server.received()
+			}
+
+			got, err := runner.Run(context.Background(), script, []string{"a", "b"})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if got != int64(2) {
+				t.Errorf("Run() = %v, want 2", got)
+			}
+
+			if commands := server.received(); !reflect.DeepEqual(commands, tt.want) {
+				t.Errorf("commands = %q, want %q", commands, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptRunner_Load(t *testing.T) {
+	server := scriptRedis(t)
+	runner := (&RedisCluster{}).ScriptRunner()
+	scripts := []*Script{NewScript("return 1"), NewScript("return 2")}
+
+	if err := runner.Load(context.Background(), scripts...); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if commands := server.received(); len(commands) != len(scripts) {
+		t.Errorf("commands = %q, want one script load per script", commands)
+	}
+
+	// the scripts are loaded once on each node.
+	if err := runner.Load(context.Background(), scripts...); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if commands := server.received(); len(commands) != 0 {
+		t.Errorf("commands = %q, want none", commands)
+	}
+}
+
+func TestScriptRunner_Run_Cluster(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []string
+		wantErr error
+	}{
+		{
+			name: "hash tag",
+			keys: []string{"{user}.profile", "{user}.sessions"},
+		},
+		{
+			name:    "cross slot",
+			keys:    []string{"foo", "bar"},
+			wantErr: ErrCrossSlot,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := &recordHook{}
+			client := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{"127.0.0.1:0"}})
+			client.AddHook(hook)
+			useClient(t, client)
+
+			script := NewScript("return 1")
+			_, err := (&RedisCluster{KeyPrefix: "iam-"}).ScriptRunner().Run(context.Background(), script, tt.keys)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || len(hook.pipelines) != 0 {
+					t.Errorf("Run() error = %v, commands = %q, want %v and no command", err, hook.pipelines, tt.wantErr)
+				}
+
+				return
+			}
+
+			want := [][]string{{"evalsha " + script.SHA() + " 2 iam-{user}.profile iam-{user}.sessions"}}
+			if !reflect.DeepEqual(hook.pipelines, want) {
+				t.Errorf("commands = %q, want %q", hook.pipelines, want)
+			}
+		})
+	}
+}
+
+func TestKeySlot(t *testing.T) {
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{key: "123456789", want: 12739},
+		{key: "foo", want: 12182},
+		{key: "{foo}.bar", want: 12182},
+		{key: "bar", want: 5061},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := keySlot(tt.key); got != tt.want {
+				t.Errorf("keySlot() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
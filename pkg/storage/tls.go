@@ -0,0 +1,72 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+
+	"github.com/marmotedu/errors"
+)
+
+// tlsVersions maps the supported values of Config.MinTLSVersion to tls versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewTLSConfig returns the tls config used to connect to redis, or nil if UseSSL is false.
+// It fails if the CA, certificate or key files can not be loaded, or the certificate does
+// not match the key.
+func NewTLSConfig(config *Config) (*tls.Config, error) {
+	if !config.UseSSL {
+		return nil, nil
+	}
+
+	// nolint: gosec // InsecureSkipVerify is only set on explicit request of the user.
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.SSLInsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if config.MinTLSVersion != "" {
+		version, ok := tlsVersions[config.MinTLSVersion]
+		if !ok {
+			return nil, errors.Errorf("unsupported minimum tls version %q, must be one of 1.0, 1.1, 1.2 and 1.3",
+				config.MinTLSVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if config.CAFile != "" {
+		data, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "read redis ca file failed")
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.Errorf("no valid certificate found in redis ca file %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return nil, errors.New("both the redis client certificate file and key file must be set")
+	}
+
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "load redis client certificate failed")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,184 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		wantNil   bool
+		wantErr   bool
+		wantRoots bool
+		wantCerts int
+		wantMin   uint16
+	}{
+		{
+			name:    "ssl disabled",
+			config:  Config{CAFile: "testdata/ca.pem"},
+			wantNil: true,
+		},
+		{
+			name:    "system roots",
+			config:  Config{UseSSL: true},
+			wantMin: tls.VersionTLS12,
+		},
+		{
+			name:      "custom ca",
+			config:    Config{UseSSL: true, CAFile: "testdata/ca.pem"},
+			wantRoots: true,
+			wantMin:   tls.VersionTLS12,
+		},
+		{
+			name: "client certificate",
+			config: Config{
+				UseSSL:        true,
+				CAFile:        "testdata/ca.pem",
+				CertFile:      "testdata/client.pem",
+				KeyFile:       "testdata/client-key.pem",
+				MinTLSVersion: "1.3",
+			},
+			wantRoots: true,
+			wantCerts: 1,
+			wantMin:   tls.VersionTLS13,
+		},
+		{
+			name:    "unreadable ca file",
+			config:  Config{UseSSL: true, CAFile: "testdata/not-exist.pem"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid ca file",
+			config:  Config{UseSSL: true, CAFile: "testdata/invalid.pem"},
+			wantErr: true,
+		},
+		{
+			name:    "certificate without key",
+			config:  Config{UseSSL: true, CertFile: "testdata/client.pem"},
+			wantErr: true,
+		},
+		{
+			name:    "mismatched key",
+			config:  Config{UseSSL: true, CertFile: "testdata/client.pem", KeyFile: "testdata/server-key.pem"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown min version",
+			config:  Config{UseSSL: true, MinTLSVersion: "1.4"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewTLSConfig(&tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTLSConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("NewTLSConfig() = %v, wantNil %v", got, tt.wantNil)
+			}
+			if got == nil {
+				return
+			}
+			if (got.RootCAs != nil) != tt.wantRoots {
+				t.Errorf("NewTLSConfig() RootCAs = %v, wantRoots %v", got.RootCAs, tt.wantRoots)
+			}
+			if len(got.Certificates) != tt.wantCerts {
+				t.Errorf("NewTLSConfig() len(Certificates) = %d, want %d", len(got.Certificates), tt.wantCerts)
+			}
+			if got.MinVersion != tt.wantMin {
+				t.Errorf("NewTLSConfig() MinVersion = %x, want %x", got.MinVersion, tt.wantMin)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfig_Handshake(t *testing.T) {
+	caPEM, err := ioutil.ReadFile("testdata/ca.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caPEM)
+
+	serverCert, err := tls.LoadX509KeyPair("testdata/server.pem", "testdata/server-key.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the server only accepts clients presenting a certificate signed by the test CA.
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{
+			name: "client certificate",
+			config: Config{
+				UseSSL:   true,
+				CAFile:   "testdata/ca.pem",
+				CertFile: "testdata/client.pem",
+				KeyFile:  "testdata/client-key.pem",
+			},
+		},
+		{
+			name:    "unknown server ca",
+			config:  Config{UseSSL: true, CertFile: "testdata/client.pem", KeyFile: "testdata/client-key.pem"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := NewTLSConfig(&tt.config)
+			if err != nil {
+				t.Fatalf("NewTLSConfig() error = %v", err)
+			}
+
+			conn, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			tlsConfig.ServerName = "127.0.0.1"
+			err = tls.Client(conn, tlsConfig).Handshake()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Handshake() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
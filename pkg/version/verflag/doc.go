@@ -0,0 +1,7 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package verflag defines the --version flag of the iam commands, printing their version information in
+// the human, raw, json or short format.
+package verflag // import "github.com/marmotedu/iam/pkg/version/verflag"
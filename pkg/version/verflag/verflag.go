@@ -0,0 +1,111 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package verflag
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/marmotedu/component-base/pkg/version"
+	"github.com/spf13/pflag"
+)
+
+// The formats of the version information, --version alone prints the human one.
+const (
+	FormatHuman = "true"
+	FormatRaw   = "raw"
+	FormatJSON  = "json"
+	FormatShort = "short"
+
+	formatNone = "false"
+)
+
+const versionFlagName = "version"
+
+// formats are the values accepted by --version.
+var formats = []string{FormatHuman, formatNone, FormatRaw, FormatJSON, FormatShort}
+
+type versionValue string
+
+var versionFlag = versionValue(formatNone)
+
+// IsBoolFlag makes --version alone a --version=true.
+func (v *versionValue) IsBoolFlag() bool {
+	return true
+}
+
+func (v *versionValue) Set(s string) error {
+	switch s {
+	case FormatRaw, FormatJSON, FormatShort:
+		*v = versionValue(s)
+
+		return nil
+	}
+
+	enabled, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("must be one of %s", strings.Join(formats, ", "))
+	}
+
+	*v = versionValue(strconv.FormatBool(enabled))
+
+	return nil
+}
+
+func (v *versionValue) String() string {
+	return string(*v)
+}
+
+// Type is the type of the flag as required by the pflag.Value interface.
+func (v *versionValue) Type() string {
+	return "version"
+}
+
+// AddFlags registers the --version flag on fs. The flags of all the FlagSets point to the same value.
+func AddFlags(fs *pflag.FlagSet) {
+	fs.Var(&versionFlag, versionFlagName, ""+
+		"Print version information and quit. --version=json prints it as a JSON object, --version=short "+
+		"prints the version number only and --version=raw prints the go representation.")
+	fs.Lookup(versionFlagName).NoOptDefVal = FormatHuman
+}
+
+// PrintAndExitIfRequested prints the version information and exits if the --version flag was passed.
+func PrintAndExitIfRequested() {
+	if versionFlag == formatNone {
+		return
+	}
+
+	if err := Print(os.Stdout, string(versionFlag), version.Get()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+// Print writes info to w in format, one of FormatHuman, FormatRaw, FormatJSON and FormatShort. The
+// JSON object is written on a single line, for the scripts checking the deployed versions.
+func Print(w io.Writer, format string, info version.Info) error {
+	var err error
+
+	switch format {
+	case FormatHuman:
+		_, err = fmt.Fprintf(w, "%s\n", info)
+	case FormatRaw:
+		_, err = fmt.Fprintf(w, "%#v\n", info)
+	case FormatJSON:
+		_, err = fmt.Fprintln(w, info.ToJSON())
+	case FormatShort:
+		_, err = fmt.Fprintln(w, info.GitVersion)
+	default:
+		err = fmt.Errorf("unknown version format %q, must be one of %s", format,
+			strings.Join(formats, ", "))
+	}
+
+	return err
+}
@@ -0,0 +1,131 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package verflag
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/marmotedu/component-base/pkg/version"
+	"github.com/spf13/pflag"
+)
+
+func TestAddFlags(t *testing.T) {
+	defer func() { versionFlag = formatNone }()
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    versionValue
+		wantErr string
+	}{
+		{name: "unset", want: formatNone},
+		{name: "human", args: []string{"--version"}, want: FormatHuman},
+		{name: "disabled", args: []string{"--version=false"}, want: formatNone},
+		{name: "raw", args: []string{"--version=raw"}, want: FormatRaw},
+		{name: "json", args: []string{"--version=json"}, want: FormatJSON},
+		{name: "short", args: []string{"--version=short"}, want: FormatShort},
+		{
+			name:    "unknown format",
+			args:    []string{"--version=yaml"},
+			wantErr: `invalid argument "yaml" for "--version" flag: must be one of true, false, raw, json, short`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versionFlag = formatNone
+
+			fs := pflag.NewFlagSet("iam-apiserver", pflag.ContinueOnError)
+			fs.SetOutput(&bytes.Buffer{})
+			AddFlags(fs)
+
+			err := fs.Parse(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Parse() error = %v, want %s", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			if versionFlag != tt.want {
+				t.Errorf("--version = %s, want %s", versionFlag, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrint(t *testing.T) {
+	info := version.Info{
+		GitVersion:   "v1.6.2",
+		GitCommit:    "4aeb84aaa4a2d4a8d1c4e5e2ee9c3b2d5b1d3c9e",
+		GitTreeState: "clean",
+		BuildDate:    "2021-11-03T08:16:34Z",
+		GoVersion:    "go1.17.2",
+		Compiler:     "gc",
+		Platform:     "linux/amd64",
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := Print(&out, FormatJSON, info); err != nil {
+			t.Fatalf("Print() error = %v", err)
+		}
+
+		// a single object, on a single line.
+		if lines := strings.Count(out.String(), "\n"); lines != 1 {
+			t.Errorf("Print() = %q, want a single line", out.String())
+		}
+
+		var got map[string]interface{}
+		if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+
+		for key, want := range map[string]string{
+			"gitVersion":   info.GitVersion,
+			"gitCommit":    info.GitCommit,
+			"gitTreeState": info.GitTreeState,
+			"buildDate":    info.BuildDate,
+			"goVersion":    info.GoVersion,
+			"platform":     info.Platform,
+		} {
+			if got[key] != want {
+				t.Errorf("%s = %v, want %s", key, got[key], want)
+			}
+		}
+	})
+
+	tests := []struct {
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{format: FormatShort, want: "v1.6.2\n"},
+		{format: FormatHuman, want: info.String() + "\n"},
+		{format: FormatRaw, want: `version.Info{GitVersion:"v1.6.2"`},
+		{format: "yaml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+
+			err := Print(&out, tt.format, info)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Print() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !strings.HasPrefix(out.String(), tt.want) {
+				t.Errorf("Print() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -41,7 +41,10 @@ function iam::apiserver::install()
   echo ${LINUX_PASSWORD} | sudo -S bash -c \
     "./scripts/genconfig.sh ${ENV_FILE} init/iam-apiserver.service > /etc/systemd/system/iam-apiserver.service"
 
-  # 5. 启动iam-apiserver服务
+  # 5. 执行数据库迁移，iam-apiserver 拒绝在有待执行迁移的数据库上启动
+  iam::common::sudo "${IAM_INSTALL_DIR}/bin/iam-apiserver migrate up --config=${IAM_CONFIG_DIR}/iam-apiserver.yaml" || return 1
+
+  # 6. 启动iam-apiserver服务
   iam::common::sudo "systemctl daemon-reload"
   iam::common::sudo "systemctl restart iam-apiserver"
   iam::common::sudo "systemctl enable iam-apiserver"